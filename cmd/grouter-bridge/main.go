@@ -0,0 +1,91 @@
+// Command grouter-bridge runs the gRouter components without services, as a
+// protocol bridge sidecar for applications that only speak HTTP or MQTT: the
+// HTTP to NATS gateway, the NATS to Server-Sent Events bridge, the webhook
+// forwarder, the MQTT bridge, health probes and metrics, all from the config.
+//
+//	grouter-bridge --config configs/bridge.yaml
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"grouter/pkg/config"
+	"grouter/pkg/manager"
+
+	"go.uber.org/zap"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Stderr))
+}
+
+// run bridges until ctx is done and returns the exit status
+func run(ctx context.Context, args []string, stderr io.Writer) int {
+	mgr, err := manager.New(manager.WithLoader(config.NewLoader(config.WithArgs(args))))
+	if err != nil {
+		fmt.Fprintf(stderr, "grouter-bridge: %v\n", err)
+		return 1
+	}
+	log := mgr.Logger()
+	stopManager := func() int {
+		shutdown, cancel := context.WithTimeout(context.Background(), mgr.Config().Web.ShutdownTimeout+mgr.Config().NATS.PreStop.Grace)
+		defer cancel()
+		if err := mgr.Stop(shutdown); err != nil {
+			log.Error("Failed to stop bridge", zap.Error(err))
+			return 1
+		}
+		return 0
+	}
+
+	if err := checkBridge(mgr.Config()); err != nil {
+		fmt.Fprintf(stderr, "grouter-bridge: %v\n", err)
+		stopManager()
+		return 2
+	}
+	if err := mgr.Start(ctx); err != nil {
+		log.Error("Failed to start bridge", zap.Error(err))
+		stopManager()
+		return 1
+	}
+	log.Info("Bridge running",
+		zap.Bool("nats_gateway", mgr.Config().Web.NATSGateway.Enabled),
+		zap.Int("routes", len(mgr.Config().Web.NATSGateway.Routes)),
+		zap.Bool("sse", mgr.Config().Web.SSE.Enabled),
+		zap.Bool("webhooks", mgr.Config().Webhooks.Enabled),
+		zap.Int("webhook_targets", len(mgr.Config().Webhooks.Targets)),
+		zap.Bool("mqtt", mgr.Config().MQTT.Enabled),
+		zap.Int("mqtt_routes", len(mgr.Config().MQTT.Routes)),
+	)
+
+	<-ctx.Done()
+	log.Info("Stopping bridge")
+	return stopManager()
+}
+
+// checkBridge reports the settings the bridge cannot run without: NATS, the
+// web server of the probes and metrics, and something to bridge. Services
+// are not loaded, not even from plugins.
+func checkBridge(cfg *config.Config) error {
+	var errs []error
+	if !cfg.NATS.Enabled {
+		errs = append(errs, errors.New("nats.enabled is required"))
+	}
+	if !cfg.Web.Enabled {
+		errs = append(errs, errors.New("web.enabled is required to serve health and metrics"))
+	}
+	if !cfg.Web.NATSGateway.Enabled && !cfg.Web.SSE.Enabled && !cfg.Webhooks.Enabled && !cfg.MQTT.Enabled {
+		errs = append(errs, errors.New("nothing to bridge: enable web.nats_gateway, web.sse, webhooks or mqtt"))
+	}
+	if cfg.Plugins.Enabled {
+		errs = append(errs, errors.New("plugins are not loaded by the bridge, disable plugins.enabled"))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"grouter/pkg/config"
+	messaging "grouter/pkg/messaging/nats"
+	grtest "grouter/pkg/testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckBridge(t *testing.T) {
+	bridge := func() *config.Config {
+		cfg := &config.Config{}
+		cfg.NATS.Enabled = true
+		cfg.Web.Enabled = true
+		cfg.Web.NATSGateway.Enabled = true
+		return cfg
+	}
+	tests := []struct {
+		name    string
+		mutate  func(cfg *config.Config)
+		wantErr string
+	}{
+		{"gateway", func(cfg *config.Config) {}, ""},
+		{"sse", func(cfg *config.Config) {
+			cfg.Web.NATSGateway.Enabled = false
+			cfg.Web.SSE.Enabled = true
+		}, ""},
+		{"webhooks", func(cfg *config.Config) {
+			cfg.Web.NATSGateway.Enabled = false
+			cfg.Webhooks.Enabled = true
+		}, ""},
+		{"mqtt", func(cfg *config.Config) {
+			cfg.Web.NATSGateway.Enabled = false
+			cfg.MQTT.Enabled = true
+		}, ""},
+		{"no nats", func(cfg *config.Config) { cfg.NATS.Enabled = false }, "nats.enabled is required"},
+		{"no web", func(cfg *config.Config) { cfg.Web.Enabled = false }, "web.enabled is required"},
+		{"nothing to bridge", func(cfg *config.Config) { cfg.Web.NATSGateway.Enabled = false }, "nothing to bridge"},
+		{"plugins", func(cfg *config.Config) { cfg.Plugins.Enabled = true }, "plugins are not loaded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := bridge()
+			tt.mutate(cfg)
+			err := checkBridge(cfg)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBridgeConfig(t *testing.T) {
+	loader := config.NewLoader(config.WithArgs([]string{"--config", "../../configs/bridge.yaml"}))
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+	assert.NoError(t, checkBridge(cfg))
+}
+
+func TestRun_Bridge(t *testing.T) {
+	s := grtest.RunNATSServer(t)
+	port := grtest.FreePort(t)
+	path := filepath.Join(t.TempDir(), "bridge.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(fmt.Sprintf(`
+app:
+  name: "bridgetest"
+log:
+  level: "error"
+tracing:
+  enabled: false
+nats:
+  enabled: true
+  url: %q
+web:
+  enabled: true
+  port: %d
+  mode: "test"
+  nats_gateway:
+    enabled: true
+    timeout: "2s"
+    routes:
+      - method: "GET"
+        path: "/api/orders/:id"
+        subject: "bridgetest.orders.{id}.get"
+        type: "order.get"
+`, s.ClientURL(), port)), 0o644))
+
+	nc, err := nats.Connect(s.ClientURL())
+	require.NoError(t, err)
+	defer nc.Close()
+	_, err = nc.Subscribe("bridgetest.orders.*.get", func(msg *nats.Msg) {
+		id := strings.Split(msg.Subject, ".")[2]
+		reply, _ := json.Marshal(messaging.MessageEnvelope{ID: "r1", Type: "order", Data: json.RawMessage(`{"id":"` + id + `"}`)})
+		_ = msg.Respond(reply)
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var stderr bytes.Buffer
+	done := make(chan int, 1)
+	go func() { done <- run(ctx, []string{"--config", path}, &stderr) }()
+
+	base := fmt.Sprintf("http://127.0.0.1:%d", port)
+	get := func(path string) (int, string) {
+		resp, err := http.Get(base + path)
+		if err != nil {
+			return 0, err.Error()
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+	require.Eventually(t, func() bool {
+		code, _ := get("/health/ready")
+		return code == http.StatusOK
+	}, 10*time.Second, 50*time.Millisecond, stderr.String())
+
+	code, body := get("/api/orders/42")
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"id":"42"}`, body)
+
+	code, body = get("/metrics")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "go_goroutines")
+
+	cancel()
+	select {
+	case code := <-done:
+		assert.Equal(t, 0, code, stderr.String())
+	case <-time.After(15 * time.Second):
+		t.Fatal("bridge did not stop")
+	}
+}
+
+func TestRun_NothingToBridge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bridge.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("app:\n  name: \"bridgetest\"\nlog:\n  level: \"error\"\ntracing:\n  enabled: false\nnats:\n  enabled: false\nweb:\n  enabled: false\n"), 0o644))
+	var stderr bytes.Buffer
+	assert.Equal(t, 2, run(context.Background(), []string{"--config", path}, &stderr))
+	assert.Contains(t, stderr.String(), "nothing to bridge")
+}
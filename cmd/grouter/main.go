@@ -0,0 +1,87 @@
+// Command grouter generates the code of grouter services.
+//
+//	grouter new service <name> [flags]
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"grouter/pkg/scaffold"
+
+	"github.com/spf13/pflag"
+)
+
+const usageText = `Usage: grouter new service <name> [flags]
+
+Generate the skeleton of a service in <dir>/<name>: a main package, an app
+wiring the service manager, the service package, its config file, a
+Dockerfile, tests and Bazel targets.
+
+Flags:
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the command line args and returns the exit status
+func run(args []string, stdout, stderr io.Writer) int {
+	opts := scaffold.Options{}
+	fs := pflag.NewFlagSet("grouter", pflag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.BoolVar(&opts.Web, "web", false, "Serve HTTP endpoints and the admin API")
+	fs.BoolVar(&opts.NATS, "nats", false, "Subscribe to NATS subjects and answer requests")
+	fs.BoolVar(&opts.Database, "database", false, "Open the database configured in the database section")
+	fs.BoolVar(&opts.Scheduler, "scheduler", false, "Run a job at a configured interval")
+	fs.StringVar(&opts.Root, "root", ".", "Directory of the Go module")
+	fs.StringVar(&opts.Dir, "dir", "services", "Directory of the services, relative to --root")
+	fs.StringVar(&opts.Module, "module", "", "Go module path (read from <root>/go.mod by default)")
+	fs.IntVar(&opts.Port, "port", 8080, "Port of the web server")
+	fs.BoolVar(&opts.Force, "force", false, "Overwrite the files of an existing service")
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usageText)
+		fmt.Fprint(stderr, fs.FlagUsages())
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(stderr, "grouter: %v\n", err)
+		fs.Usage()
+		return 2
+	}
+	if fs.NArg() != 3 || fs.Arg(0) != "new" || fs.Arg(1) != "service" {
+		fs.Usage()
+		return 2
+	}
+	opts.Name = fs.Arg(2)
+
+	if opts.Module == "" {
+		module, err := scaffold.ModulePath(opts.Root)
+		if err != nil {
+			fmt.Fprintf(stderr, "grouter: %v, set --module\n", err)
+			return 1
+		}
+		opts.Module = module
+	}
+
+	written, err := scaffold.Generate(opts)
+	for _, path := range written {
+		if rel, err := filepath.Rel(opts.Root, path); err == nil {
+			path = rel
+		}
+		fmt.Fprintf(stdout, "created %s\n", filepath.ToSlash(path))
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "grouter: %v\n", err)
+		if errors.Is(err, scaffold.ErrExists) {
+			fmt.Fprintln(stderr, "grouter: use --force to overwrite it")
+		}
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runGrouter runs grouter and returns its exit status and output
+func runGrouter(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+// newModule returns a directory holding a go.mod for module example.com/app
+func newModule(t *testing.T) string {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "go.mod"), []byte("module example.com/app\n\ngo 1.24\n"), 0o644))
+	return root
+}
+
+func TestRun_Usage(t *testing.T) {
+	tests := [][]string{
+		{},
+		{"new"},
+		{"new", "service"},
+		{"new", "worker", "orderssvc"},
+		{"new", "service", "orderssvc", "extra"},
+		{"--unknown"},
+	}
+	for _, args := range tests {
+		code, _, stderr := runGrouter(args...)
+		assert.Equal(t, 2, code, "args %v", args)
+		assert.Contains(t, stderr, "Usage: grouter new service", "args %v", args)
+	}
+
+	code, _, _ := runGrouter("--help")
+	assert.Equal(t, 0, code)
+}
+
+func TestRun_NewService(t *testing.T) {
+	root := newModule(t)
+	code, stdout, stderr := runGrouter("new", "service", "orderssvc", "--root", root, "--web", "--nats")
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "created services/orderssvc/cmd/orderssvc/main.go\n")
+	assert.Contains(t, stdout, "created services/orderssvc/internal/pkg/orders/service.go\n")
+
+	app, err := os.ReadFile(filepath.Join(root, "services/orderssvc/internal/app/app.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(app), `"example.com/app/services/orderssvc/internal/pkg/orders"`, "the module is read from go.mod")
+
+	code, _, stderr = runGrouter("new", "service", "orderssvc", "--root", root)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "already exists")
+	assert.Contains(t, stderr, "--force")
+
+	code, _, stderr = runGrouter("new", "service", "orderssvc", "--root", root, "--force")
+	assert.Equal(t, 0, code, stderr)
+}
+
+func TestRun_Errors(t *testing.T) {
+	code, _, stderr := runGrouter("new", "service", "orderssvc", "--root", t.TempDir())
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "set --module")
+
+	code, _, stderr = runGrouter("new", "service", "Orders", "--root", newModule(t))
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "invalid service name")
+}
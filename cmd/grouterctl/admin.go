@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"grouter/pkg/manager"
+
+	"github.com/spf13/pflag"
+)
+
+func runServices(c *cli, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("%w: unexpected arguments %v", errUsage, args)
+	}
+	var services []manager.ServiceInfo
+	if err := c.admin(http.MethodGet, "/admin/services", &services); err != nil {
+		return err
+	}
+	if c.output == "json" {
+		return c.printJSON(services)
+	}
+	rows := make([][]string, 0, len(services))
+	for _, svc := range services {
+		rows = append(rows, []string{svc.Name, svc.State, svc.Error})
+	}
+	return c.table([]string{"NAME", "STATE", "ERROR"}, rows)
+}
+
+func runStart(c *cli, args []string) error {
+	return changeState(c, args, "start")
+}
+
+func runStop(c *cli, args []string) error {
+	return changeState(c, args, "stop")
+}
+
+// changeState starts or stops the service of args and prints its new state
+func changeState(c *cli, args []string, action string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: want a single service name", errUsage)
+	}
+	var info manager.ServiceInfo
+	path := "/admin/services/" + url.PathEscape(args[0]) + "/" + action
+	if err := c.admin(http.MethodPost, path, &info); err != nil {
+		return err
+	}
+	if c.output == "json" {
+		return c.printJSON(info)
+	}
+	fmt.Fprintf(c.out, "%s %s\n", info.Name, info.State)
+	return nil
+}
+
+func runReload(c *cli, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("%w: unexpected arguments %v", errUsage, args)
+	}
+	var result map[string]string
+	if err := c.admin(http.MethodPost, "/admin/reload", &result); err != nil {
+		return err
+	}
+	if c.output == "json" {
+		return c.printJSON(result)
+	}
+	fmt.Fprintln(c.out, result["status"])
+	return nil
+}
+
+func runSubscriptions(c *cli, args []string) error {
+	var service string
+	fs, err := parseFlags("subscriptions", args, func(fs *pflag.FlagSet) {
+		fs.StringVar(&service, "service", "", "Only list the subscriptions of this service")
+	})
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return fmt.Errorf("%w: unexpected arguments %v", errUsage, fs.Args())
+	}
+
+	path := "/admin/subscriptions"
+	if service != "" {
+		path += "?service=" + url.QueryEscape(service)
+	}
+	var subs []manager.SubscriptionInfo
+	if err := c.admin(http.MethodGet, path, &subs); err != nil {
+		return err
+	}
+	if c.output == "json" {
+		return c.printJSON(subs)
+	}
+	rows := make([][]string, 0, len(subs))
+	for _, sub := range subs {
+		rows = append(rows, []string{
+			sub.Service,
+			sub.Subject,
+			sub.QueueGroup,
+			strconv.FormatUint(sub.Received, 10),
+			strconv.FormatUint(sub.Failed, 10),
+			strconv.FormatInt(sub.InFlight, 10),
+		})
+	}
+	return c.table([]string{"SERVICE", "SUBJECT", "QUEUE", "RECEIVED", "FAILED", "IN FLIGHT"}, rows)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+)
+
+// cli holds the global flags and the connections of a command
+type cli struct {
+	app       string
+	natsURL   string
+	natsToken string
+	url       string
+	token     string
+	timeout   time.Duration
+	output    string
+	out       io.Writer
+
+	messenger *messaging.Messenger
+}
+
+// connect returns the messenger of the NATS server, connecting on first use
+func (c *cli) connect() (*messaging.Messenger, error) {
+	if c.messenger != nil {
+		return c.messenger, nil
+	}
+	m := &messaging.Messenger{}
+	cfg := messaging.Config{URL: c.natsURL, Token: c.natsToken, ConnectionTimeout: c.timeout}
+	if err := m.Init(cfg, zap.NewNop(), "grouterctl"); err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	c.messenger = m
+	return m, nil
+}
+
+func (c *cli) close() {
+	if c.messenger != nil {
+		_ = c.messenger.Close()
+	}
+}
+
+// subject returns the subject of the app for suffix
+func (c *cli) subject(suffix string) string {
+	return c.app + "." + suffix
+}
+
+// call sends a request to the web server and returns the status and body
+func (c *cli) call(method, path string, body []byte) (int, []byte, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, strings.TrimSuffix(c.url, "/")+path, reader)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := (&http.Client{Timeout: c.timeout}).Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to call %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return resp.StatusCode, data, nil
+}
+
+// admin calls the admin API and decodes the JSON response into v, failing on
+// error statuses
+func (c *cli) admin(method, path string, v any) error {
+	status, data, err := c.call(method, path, nil)
+	if err != nil {
+		return err
+	}
+	if status >= http.StatusBadRequest {
+		return fmt.Errorf("%s %s: %s", method, path, errorMessage(status, data))
+	}
+	if v == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// errorMessage returns the message of a web.Error response, or its body
+func errorMessage(status int, data []byte) string {
+	var body struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(data, &body) == nil && body.Error.Message != "" {
+		return fmt.Sprintf("%d %s", status, body.Error.Message)
+	}
+	return fmt.Sprintf("%d %s", status, strings.TrimSpace(string(data)))
+}
+
+// printJSON writes v as indented JSON
+func (c *cli) printJSON(v any) error {
+	enc := json.NewEncoder(c.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// table writes the rows under header, aligned
+func (c *cli) table(header []string, rows [][]string) error {
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+// parseFlags parses the flags of a command, which may follow its arguments
+func parseFlags(name string, args []string, define func(fs *pflag.FlagSet)) (*pflag.FlagSet, error) {
+	fs := pflag.NewFlagSet(name, pflag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if define != nil {
+		define(fs)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, fmt.Errorf("%w: %v", errUsage, err)
+	}
+	return fs, nil
+}
+
+// jsonArg returns the JSON document of arg, an empty object when empty
+func jsonArg(arg string) (json.RawMessage, error) {
+	if arg == "" {
+		return json.RawMessage("{}"), nil
+	}
+	if !json.Valid([]byte(arg)) {
+		return nil, fmt.Errorf("%w: data is not valid JSON: %s", errUsage, arg)
+	}
+	return json.RawMessage(arg), nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+func runHTTP(c *cli, args []string) error {
+	if len(args) < 2 || len(args) > 3 {
+		return fmt.Errorf("%w: want a method, a path and optional JSON body", errUsage)
+	}
+	method, path := strings.ToUpper(args[0]), args[1]
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	var body []byte
+	if len(args) == 3 {
+		data, err := jsonArg(args[2])
+		if err != nil {
+			return err
+		}
+		body = data
+	}
+
+	status, data, err := c.call(method, path, body)
+	if err != nil {
+		return err
+	}
+	if err := c.printBody(data); err != nil {
+		return err
+	}
+	if status < http.StatusOK || status >= http.StatusMultipleChoices {
+		return fmt.Errorf("%s %s: status %d", method, path, status)
+	}
+	return nil
+}
+
+// printBody writes a response body, indenting JSON
+func (c *cli) printBody(data []byte) error {
+	var out bytes.Buffer
+	if json.Indent(&out, data, "", "  ") != nil {
+		out.Reset()
+		out.Write(bytes.TrimSpace(data))
+	}
+	if out.Len() == 0 {
+		return nil
+	}
+	out.WriteByte('\n')
+	_, err := c.out.Write(out.Bytes())
+	return err
+}
@@ -0,0 +1,114 @@
+// Command grouterctl operates grouter services: it lists their services and
+// starts, stops and reloads them through the admin API, queries their health,
+// tails and publishes envelopes and inspects JetStream streams over NATS.
+//
+//	grouterctl [global flags] <command> [flags] [args]
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// command is a subcommand of grouterctl
+type command struct {
+	usage string
+	help  string
+	run   func(c *cli, args []string) error
+}
+
+var commands = map[string]command{
+	"services":      {"services", "List the services and their state", runServices},
+	"start":         {"start <service>", "Start a stopped service", runStart},
+	"stop":          {"stop <service>", "Stop a running service", runStop},
+	"reload":        {"reload", "Re-read the configuration and apply it", runReload},
+	"subscriptions": {"subscriptions [--service name]", "List the NATS subscriptions and their counters", runSubscriptions},
+	"health":        {"health [live|ready|startup|detail]", "Query the health of the service", runHealth},
+	"signal":        {"signal <name> [json]", "Publish a control signal on <app>.<name>, e.g. start", runSignal},
+	"publish":       {"publish <subject> <type> [json]", "Publish a test envelope, or send a request with --request", runPublish},
+	"tail":          {"tail [subject]", "Print the envelopes published on subject (<app>.> by default)", runTail},
+	"streams":       {"streams [stream]", "List the JetStream streams, or show one", runStreams},
+	"http":          {"http <method> <path> [json]", "Call an endpoint of the web server", runHTTP},
+}
+
+// errUsage reports invalid arguments, exiting with status 2
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the command line args and returns the exit status
+func run(args []string, stdout, stderr io.Writer) int {
+	c := &cli{out: stdout}
+	fs := pflag.NewFlagSet("grouterctl", pflag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.SetInterspersed(false)
+	fs.StringVar(&c.app, "app", envOr("GROUTER_APP_NAME", "grouter"), "App name, the prefix of the control and health subjects")
+	fs.StringVar(&c.natsURL, "nats-url", envOr("GROUTER_NATS_URL", "nats://localhost:4222"), "NATS server URL")
+	fs.StringVar(&c.natsToken, "nats-token", os.Getenv("GROUTER_NATS_TOKEN"), "NATS auth token")
+	fs.StringVar(&c.url, "url", envOr("GROUTER_URL", "http://localhost:8080"), "Web server URL, serving the admin API")
+	fs.StringVar(&c.token, "token", os.Getenv("GROUTER_TOKEN"), "Bearer token of the web server")
+	fs.DurationVar(&c.timeout, "timeout", 5*time.Second, "Timeout of connections, requests and replies")
+	fs.StringVarP(&c.output, "output", "o", "text", "Output format: text or json")
+	fs.Usage = func() { usage(stderr, fs) }
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	if c.output != "text" && c.output != "json" {
+		fmt.Fprintf(stderr, "grouterctl: invalid output %q, want text or json\n", c.output)
+		return 2
+	}
+
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(stderr, "grouterctl: unknown command %q\n", fs.Arg(0))
+		fs.Usage()
+		return 2
+	}
+	defer c.close()
+	if err := cmd.run(c, fs.Args()[1:]); err != nil {
+		if errors.Is(err, errUsage) {
+			fmt.Fprintf(stderr, "grouterctl: %v\nusage: grouterctl %s\n", err, cmd.usage)
+			return 2
+		}
+		fmt.Fprintf(stderr, "grouterctl: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func usage(w io.Writer, fs *pflag.FlagSet) {
+	fmt.Fprintln(w, "Usage: grouterctl [global flags] <command> [flags] [args]")
+	fmt.Fprintln(w, "\nCommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-36s %s\n", commands[name].usage, commands[name].help)
+	}
+	fmt.Fprintln(w, "\nGlobal flags:")
+	fmt.Fprint(w, fs.FlagUsages())
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"grouter/pkg/config"
+	"grouter/pkg/manager"
+	"grouter/pkg/manager/mocks"
+	messaging "grouter/pkg/messaging/nats"
+	grtest "grouter/pkg/testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// env is a running service operated by grouterctl
+type env struct {
+	nats *server.Server
+	web  *httptest.Server
+	svc  *mocks.Service
+}
+
+func newEnv(t *testing.T) *env {
+	gin.SetMode(gin.TestMode)
+	s := grtest.RunNATSServer(t, grtest.WithJetStream())
+	mgr := grtest.NewManager(t, func(cfg *config.Config) {
+		cfg.App.Name = "ctltest"
+		cfg.NATS.Embedded = false
+		cfg.NATS.URL = s.ClientURL()
+	})
+	svc := mocks.NewService("orders", manager.SubjectSpec{Subject: "orders.>"})
+	require.NoError(t, mgr.RegisterService(svc))
+	require.NoError(t, mgr.Start(context.Background()))
+
+	engine := gin.New()
+	manager.NewAdminService(mgr).RegisterRoutes(&engine.RouterGroup)
+	engine.GET("/hello", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"message": "hello"}) })
+	web := httptest.NewServer(engine)
+	t.Cleanup(web.Close)
+	return &env{nats: s, web: web, svc: svc}
+}
+
+// run runs grouterctl against e and returns its exit status and output
+func (e *env) run(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	global := []string{"--app", "ctltest", "--nats-url", e.nats.ClientURL(), "--url", e.web.URL, "--timeout", "2s"}
+	code := run(append(global, args...), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun_Usage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 2, run(nil, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "Commands:")
+	assert.Equal(t, 2, run([]string{"bogus"}, &stdout, &stderr))
+	assert.Equal(t, 2, run([]string{"-o", "yaml", "services"}, &stdout, &stderr))
+
+	stderr.Reset()
+	assert.Equal(t, 2, run([]string{"start"}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "usage: grouterctl start <service>")
+	assert.Equal(t, 2, run([]string{"publish", "orders.created", "created", "{bad"}, &stdout, &stderr))
+}
+
+func TestAdminCommands(t *testing.T) {
+	e := newEnv(t)
+
+	code, out, _ := e.run("services")
+	require.Equal(t, 0, code)
+	assert.Contains(t, out, "orders")
+	assert.Contains(t, out, manager.ServiceRunning)
+
+	code, out, _ = e.run("stop", "orders")
+	require.Equal(t, 0, code)
+	assert.Equal(t, "orders stopped\n", out)
+
+	code, out, _ = e.run("-o", "json", "services")
+	require.Equal(t, 0, code)
+	var services []manager.ServiceInfo
+	require.NoError(t, json.Unmarshal([]byte(out), &services))
+	assert.Equal(t, []manager.ServiceInfo{{Name: "orders", State: manager.ServiceStopped}}, services)
+
+	code, out, _ = e.run("start", "orders")
+	require.Equal(t, 0, code)
+	assert.Equal(t, "orders running\n", out)
+
+	code, _, stderr := e.run("stop", "billing")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "404")
+
+	code, out, _ = e.run("subscriptions", "--service", "orders")
+	require.Equal(t, 0, code)
+	assert.Contains(t, out, "orders.>")
+
+	code, _, stderr = e.run("reload")
+	assert.Equal(t, 1, code, "the configuration of the manager was not loaded from a file")
+	assert.Contains(t, stderr, "503")
+}
+
+func TestHealthCommand(t *testing.T) {
+	e := newEnv(t)
+
+	code, out, stderr := e.run("health")
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, out, "live up")
+
+	code, out, _ = e.run("-o", "json", "health", "ready")
+	require.Equal(t, 0, code)
+	var report struct {
+		Status string `json:"status"`
+		App    string `json:"app"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(out), &report))
+	assert.Equal(t, "ctltest", report.App)
+
+	code, _, stderr = e.run("health", "bogus")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "ctltest.health.bogus")
+
+	code, _, _ = e.run("health", "--http")
+	assert.Equal(t, 1, code, "the test web server has no health endpoints")
+}
+
+func TestPublishCommands(t *testing.T) {
+	e := newEnv(t)
+	sub := grtest.Subscribe(t, grtest.Connect(t, e.nats), "ctltest.>")
+
+	code, out, _ := e.run("signal", "start")
+	require.Equal(t, 0, code)
+	assert.Equal(t, "sent start to ctltest.start\n", out)
+	env := sub.Next(2 * time.Second)
+	require.NotNil(t, env)
+	grtest.AssertEnvelope(t, env, "start", map[string]any{})
+
+	code, _, _ = e.run("publish", "--tenant", "acme", "ctltest.orders", "order.created", `{"id":"42"}`)
+	require.Equal(t, 0, code)
+	env = sub.Next(2 * time.Second)
+	require.NotNil(t, env)
+	grtest.AssertEnvelope(t, env, "order.created", map[string]any{"id": "42"})
+	assert.Equal(t, "acme", env.TenantID())
+
+	responder := grtest.NewMessenger(t, e.nats, "responder")
+	require.NoError(t, responder.Subscriber.Subscribe("echo", func(ctx context.Context, _ string, env *messaging.MessageEnvelope) error {
+		return responder.Publisher.Publish(ctx, env.Reply, "echoed", env.Data, nil)
+	}, nil))
+	require.NoError(t, responder.Client.Conn().Flush())
+	code, out, _ = e.run("publish", "--request", "echo", "ping", `{"n":1}`)
+	require.Equal(t, 0, code)
+	assert.Contains(t, out, `echoed`)
+	assert.Contains(t, out, `{"n":1}`)
+}
+
+func TestTailCommand(t *testing.T) {
+	e := newEnv(t)
+	nc := grtest.Connect(t, e.nats)
+
+	done := make(chan string)
+	go func() {
+		_, out, _ := e.run("-o", "json", "tail", "-n", "1", "ctltest.events")
+		done <- out
+	}()
+
+	// Publish until tail has subscribed and received one
+	for {
+		grtest.Publish(t, nc, "ctltest.events", "event", map[string]int{"n": 1})
+		select {
+		case out := <-done:
+			var line struct {
+				Subject string `json:"subject"`
+				Type    string `json:"type"`
+			}
+			require.NoError(t, json.Unmarshal([]byte(out), &line))
+			assert.Equal(t, "ctltest.events", line.Subject)
+			assert.Equal(t, "event", line.Type)
+			return
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+func TestStreamsCommand(t *testing.T) {
+	e := newEnv(t)
+	js, err := grtest.Connect(t, e.nats).JetStream()
+	require.NoError(t, err)
+	_, err = js.AddStream(&nats.StreamConfig{Name: "ORDERS", Subjects: []string{"orders.>"}})
+	require.NoError(t, err)
+	_, err = js.Publish("orders.created", []byte("{}"))
+	require.NoError(t, err)
+
+	code, out, _ := e.run("streams")
+	require.Equal(t, 0, code)
+	assert.Contains(t, out, "ORDERS")
+	assert.Contains(t, out, "orders.>")
+
+	code, out, _ = e.run("-o", "json", "streams", "ORDERS")
+	require.Equal(t, 0, code)
+	var info nats.StreamInfo
+	require.NoError(t, json.Unmarshal([]byte(out), &info))
+	assert.Equal(t, uint64(1), info.State.Msgs)
+
+	code, _, _ = e.run("streams", "MISSING")
+	assert.Equal(t, 1, code)
+}
+
+func TestHTTPCommand(t *testing.T) {
+	e := newEnv(t)
+
+	code, out, _ := e.run("http", "get", "/hello")
+	require.Equal(t, 0, code)
+	assert.Contains(t, out, `"message": "hello"`)
+
+	code, _, stderr := e.run("http", "GET", "missing")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "status 404")
+}
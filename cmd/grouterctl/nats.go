@@ -0,0 +1,360 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"grouter/pkg/health"
+	"grouter/pkg/manager"
+	messaging "grouter/pkg/messaging/nats"
+	"grouter/pkg/tenant"
+
+	"github.com/nats-io/nats.go"
+	"github.com/spf13/pflag"
+)
+
+// errUnhealthy reports a probe that is down
+var errUnhealthy = errors.New("unhealthy")
+
+func runHealth(c *cli, args []string) error {
+	var (
+		useHTTP bool
+		wait    time.Duration
+	)
+	fs, err := parseFlags("health", args, func(fs *pflag.FlagSet) {
+		fs.BoolVar(&useHTTP, "http", false, "Query the /health endpoints of the web server instead of NATS")
+		fs.DurationVar(&wait, "wait", 0, "Retry until the probe is up or the duration elapses")
+	})
+	if err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return fmt.Errorf("%w: want at most one probe", errUsage)
+	}
+	probe := "live"
+	if fs.NArg() == 1 {
+		probe = fs.Arg(0)
+	}
+
+	check := func() error { return healthNATS(c, probe) }
+	if useHTTP {
+		check = func() error { return healthHTTP(c, probe) }
+	}
+	deadline := time.Now().Add(wait)
+	for {
+		err := check()
+		if err == nil || time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// healthNATS requests the report of probe on <app>.health.<probe>
+func healthNATS(c *cli, probe string) error {
+	m, err := c.connect()
+	if err != nil {
+		return err
+	}
+	subject := c.subject("health." + probe)
+	reply, err := m.Publisher.Request(context.Background(), subject, "health."+probe, nil, c.timeout)
+	if err != nil {
+		return fmt.Errorf("failed to query %s: %w", subject, err)
+	}
+	if reply.Type != manager.HealthReportType {
+		return fmt.Errorf("failed to query %s: %s", subject, replyError(reply))
+	}
+	var report health.Report
+	if err := json.Unmarshal(reply.Data, &report); err != nil {
+		return fmt.Errorf("failed to decode health report: %w", err)
+	}
+
+	if c.output == "json" {
+		if err := c.printJSON(report); err != nil {
+			return err
+		}
+	} else {
+		if err := printReport(c, &report); err != nil {
+			return err
+		}
+	}
+	if report.Status == health.StatusDown {
+		return fmt.Errorf("%s: %w", probe, errUnhealthy)
+	}
+	return nil
+}
+
+func printReport(c *cli, report *health.Report) error {
+	fmt.Fprintf(c.out, "%s %s\n", report.Probe, report.Status)
+	var rows [][]string
+	for kind, results := range map[string]map[string]health.CheckResult{
+		"liveness":  report.Liveness,
+		"readiness": report.Readiness,
+		"startup":   report.Startup,
+	} {
+		for name, result := range results {
+			rows = append(rows, []string{kind, name, result.Status, result.Error})
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i][0]+"/"+rows[i][1] < rows[j][0]+"/"+rows[j][1]
+	})
+	return c.table([]string{"PROBE", "CHECK", "STATUS", "ERROR"}, rows)
+}
+
+// healthHTTP queries /health/<probe> of the web server, which answers 200
+// unless the probe is down
+func healthHTTP(c *cli, probe string) error {
+	status, data, err := c.call(http.MethodGet, "/health/"+probe, nil)
+	if err != nil {
+		return err
+	}
+	if err := c.printBody(data); err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("%s: %w: status %d", probe, errUnhealthy, status)
+	}
+	return nil
+}
+
+// replyError returns the error of an "error" reply
+func replyError(reply *messaging.MessageEnvelope) string {
+	var body struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(reply.Data, &body) == nil && body.Error != "" {
+		return body.Error
+	}
+	return fmt.Sprintf("unexpected reply of type %q", reply.Type)
+}
+
+func runSignal(c *cli, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("%w: want a signal name and optional JSON data", errUsage)
+	}
+	name := args[0]
+	data, err := jsonArg(optionalArg(args, 1))
+	if err != nil {
+		return err
+	}
+	m, err := c.connect()
+	if err != nil {
+		return err
+	}
+	subject := c.subject(name)
+	if err := m.Publisher.Publish(context.Background(), subject, name, data, nil); err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", subject, err)
+	}
+	fmt.Fprintf(c.out, "sent %s to %s\n", name, subject)
+	return nil
+}
+
+func runPublish(c *cli, args []string) error {
+	var (
+		request  bool
+		tenantID string
+	)
+	fs, err := parseFlags("publish", args, func(fs *pflag.FlagSet) {
+		fs.BoolVar(&request, "request", false, "Send a request and print the reply")
+		fs.StringVar(&tenantID, "tenant", "", "Tenant of the envelope")
+	})
+	if err != nil {
+		return err
+	}
+	if fs.NArg() < 2 || fs.NArg() > 3 {
+		return fmt.Errorf("%w: want a subject, a type and optional JSON data", errUsage)
+	}
+	subject, msgType := fs.Arg(0), fs.Arg(1)
+	data, err := jsonArg(fs.Arg(2))
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if tenantID != "" {
+		if err := tenant.Validate(tenantID); err != nil {
+			return fmt.Errorf("%w: %v", errUsage, err)
+		}
+		ctx = tenant.NewContext(ctx, tenantID)
+	}
+	m, err := c.connect()
+	if err != nil {
+		return err
+	}
+
+	if !request {
+		if err := m.Publisher.Publish(ctx, subject, msgType, data, nil); err != nil {
+			return fmt.Errorf("failed to publish to %s: %w", subject, err)
+		}
+		fmt.Fprintf(c.out, "published %s to %s\n", msgType, subject)
+		return nil
+	}
+	reply, err := m.Publisher.Request(ctx, subject, msgType, data, c.timeout)
+	if err != nil {
+		return fmt.Errorf("failed to request %s: %w", subject, err)
+	}
+	if err := c.printEnvelope(subject, reply); err != nil {
+		return err
+	}
+	if reply.Type == "error" {
+		return fmt.Errorf("request %s: %s", subject, replyError(reply))
+	}
+	return nil
+}
+
+func runTail(c *cli, args []string) error {
+	var count int
+	fs, err := parseFlags("tail", args, func(fs *pflag.FlagSet) {
+		fs.IntVarP(&count, "count", "n", 0, "Exit after this many envelopes; 0 tails until interrupted")
+	})
+	if err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return fmt.Errorf("%w: want at most one subject", errUsage)
+	}
+	subject := c.subject(">")
+	if fs.NArg() == 1 {
+		subject = fs.Arg(0)
+	}
+	m, err := c.connect()
+	if err != nil {
+		return err
+	}
+
+	type received struct {
+		subject  string
+		envelope *messaging.MessageEnvelope
+	}
+	messages := make(chan received, 64)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	handler := func(_ context.Context, subject string, env *messaging.MessageEnvelope) error {
+		select {
+		case messages <- received{subject, env}:
+		case <-ctx.Done():
+		}
+		return nil
+	}
+	if err := m.Subscriber.Subscribe(subject, handler, nil); err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", subject, err)
+	}
+	// Flush so that the server delivers the envelopes published from now on
+	if err := m.Client.Conn().Flush(); err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", subject, err)
+	}
+
+	for n := 0; count == 0 || n < count; n++ {
+		select {
+		case msg := <-messages:
+			if err := c.printEnvelope(msg.subject, msg.envelope); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return nil
+}
+
+// printEnvelope writes env received on subject, as one line of text or one
+// JSON document
+func (c *cli) printEnvelope(subject string, env *messaging.MessageEnvelope) error {
+	if c.output == "json" {
+		return json.NewEncoder(c.out).Encode(struct {
+			Subject string `json:"subject"`
+			*messaging.MessageEnvelope
+		}{subject, env})
+	}
+	_, err := fmt.Fprintf(c.out, "%s %s %s %s %s\n",
+		env.Timestamp.Format(time.RFC3339Nano), subject, env.Type, env.ID, env.Data)
+	return err
+}
+
+func runStreams(c *cli, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("%w: want at most one stream", errUsage)
+	}
+	m, err := c.connect()
+	if err != nil {
+		return err
+	}
+	js, err := m.Client.JetStream()
+	if err != nil {
+		return fmt.Errorf("failed to get JetStream context: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	if len(args) == 1 {
+		info, err := js.StreamInfo(args[0], nats.Context(ctx))
+		if err != nil {
+			return fmt.Errorf("failed to get stream %s: %w", args[0], err)
+		}
+		if c.output == "json" {
+			return c.printJSON(info)
+		}
+		return printStream(c, info)
+	}
+
+	var streams []*nats.StreamInfo
+	for info := range js.Streams(nats.Context(ctx)) {
+		streams = append(streams, info)
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("failed to list streams: %w", err)
+	}
+	sort.Slice(streams, func(i, j int) bool { return streams[i].Config.Name < streams[j].Config.Name })
+	if c.output == "json" {
+		return c.printJSON(streams)
+	}
+	rows := make([][]string, 0, len(streams))
+	for _, info := range streams {
+		rows = append(rows, []string{
+			info.Config.Name,
+			strings.Join(info.Config.Subjects, ","),
+			strconv.FormatUint(info.State.Msgs, 10),
+			strconv.FormatUint(info.State.Bytes, 10),
+			strconv.Itoa(info.State.Consumers),
+		})
+	}
+	return c.table([]string{"NAME", "SUBJECTS", "MESSAGES", "BYTES", "CONSUMERS"}, rows)
+}
+
+func printStream(c *cli, info *nats.StreamInfo) error {
+	return c.table([]string{"FIELD", "VALUE"}, [][]string{
+		{"name", info.Config.Name},
+		{"subjects", strings.Join(info.Config.Subjects, ",")},
+		{"storage", info.Config.Storage.String()},
+		{"retention", info.Config.Retention.String()},
+		{"replicas", strconv.Itoa(info.Config.Replicas)},
+		{"messages", strconv.FormatUint(info.State.Msgs, 10)},
+		{"bytes", strconv.FormatUint(info.State.Bytes, 10)},
+		{"first_seq", strconv.FormatUint(info.State.FirstSeq, 10)},
+		{"last_seq", strconv.FormatUint(info.State.LastSeq, 10)},
+		{"consumers", strconv.Itoa(info.State.Consumers)},
+		{"created", info.Created.Format(time.RFC3339)},
+	})
+}
+
+func optionalArg(args []string, i int) string {
+	if i < len(args) {
+		return args[i]
+	}
+	return ""
+}
@@ -0,0 +1,340 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+	"grouter/pkg/telemetry"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// Record is an archived envelope.
+type Record struct {
+	// Subject is the subject the envelope was received on.
+	Subject string `json:"subject"`
+	// ArchivedAt is when the envelope was received by the archiver.
+	ArchivedAt time.Time `json:"archived_at"`
+	// Envelope is the envelope as received.
+	Envelope *messaging.MessageEnvelope `json:"envelope"`
+}
+
+// Store persists archived envelopes.
+type Store interface {
+	// Write persists a batch of records.
+	Write(ctx context.Context, records []Record) error
+	// Prune deletes the records archived before the given time and returns
+	// how many records, or objects of records, were deleted.
+	Prune(ctx context.Context, before time.Time) (int, error)
+}
+
+// Config holds archiver settings.
+type Config struct {
+	// Subjects are the subjects to archive.
+	Subjects []Subject `mapstructure:"subjects"`
+	// BatchSize is the number of envelopes written to the store at once.
+	BatchSize int `mapstructure:"batch_size"`
+	// FlushInterval bounds how long envelopes wait for their batch to fill.
+	FlushInterval time.Duration `mapstructure:"flush_interval"`
+	// WriteTimeout is the timeout of a batch write.
+	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+	// Retention is how long records are kept. Zero keeps them forever.
+	Retention time.Duration `mapstructure:"retention"`
+	// RetentionInterval is the interval between two prunes of the store.
+	RetentionInterval time.Duration `mapstructure:"retention_interval"`
+	// Registry receives the archiver metrics. Nil uses the global registry.
+	Registry *telemetry.MetricsRegistry `mapstructure:"-"`
+}
+
+// Subject is a subject to archive.
+type Subject struct {
+	// Subject is the NATS subject to archive (wildcards allowed).
+	Subject string `mapstructure:"subject"`
+	// Durable reads the subject with a JetStream pull consumer of that name,
+	// acknowledging the envelopes once written: a stream must store the
+	// subject. Empty subscribes to the subject with core NATS, where the
+	// envelopes of a failed write are lost.
+	Durable string `mapstructure:"durable"`
+	// QueueGroup shares a core NATS subject between instances so each
+	// envelope is archived once. Defaults to "archive".
+	QueueGroup string `mapstructure:"queue_group"`
+}
+
+// DefaultConfig returns the default archiver configuration.
+func DefaultConfig() Config {
+	return Config{
+		BatchSize:         100,
+		FlushInterval:     5 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		RetentionInterval: time.Hour,
+	}
+}
+
+// archiverMetrics are the metrics of an Archiver.
+type archiverMetrics struct {
+	records  *prometheus.CounterVec
+	batches  *prometheus.CounterVec
+	duration prometheus.Observer
+	pruned   prometheus.Counter
+}
+
+func newArchiverMetrics(reg *telemetry.MetricsRegistry) *archiverMetrics {
+	return &archiverMetrics{
+		records: reg.CounterVec(prometheus.CounterOpts{
+			Name: "archive_records_total",
+			Help: "Total number of archived envelopes by result (success, failed)",
+		}, []string{"result"}),
+		batches: reg.CounterVec(prometheus.CounterOpts{
+			Name: "archive_batches_total",
+			Help: "Total number of batch writes by result (success, failed)",
+		}, []string{"result"}),
+		duration: reg.HistogramVec(prometheus.HistogramOpts{
+			Name:    "archive_write_duration_seconds",
+			Help:    "Duration of batch writes in seconds",
+			Buckets: prometheus.DefBuckets,
+		}, nil).WithLabelValues(),
+		pruned: reg.CounterVec(prometheus.CounterOpts{
+			Name: "archive_pruned_records_total",
+			Help: "Total number of records deleted by the retention policy",
+		}, nil).WithLabelValues(),
+	}
+}
+
+// pending is a received envelope waiting for its batch.
+type pending struct {
+	record Record
+	// acker acknowledges the JetStream message of the envelope, nil for
+	// core NATS.
+	acker messaging.Acker
+}
+
+// Archiver subscribes to the configured subjects and writes their envelopes
+// to a Store in batches, flushed when full or after FlushInterval. The
+// envelopes of JetStream subjects are acknowledged once written and
+// redelivered when the write fails, so a store may see an envelope twice.
+type Archiver struct {
+	cfg        Config
+	subscriber messaging.Subscriber
+	store      Store
+	logger     *zap.Logger
+	metrics    *archiverMetrics
+
+	mu    sync.Mutex
+	batch []pending
+	// flushMu serializes the writes of the batches
+	flushMu sync.Mutex
+
+	subs   []messaging.Subscription
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New creates an Archiver writing to store, validating the subjects and
+// applying defaults.
+func New(subscriber messaging.Subscriber, store Store, cfg Config, logger *zap.Logger) (*Archiver, error) {
+	if store == nil {
+		return nil, fmt.Errorf("archiver requires a store")
+	}
+	defaults := DefaultConfig()
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaults.BatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaults.FlushInterval
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = defaults.WriteTimeout
+	}
+	if cfg.RetentionInterval <= 0 {
+		cfg.RetentionInterval = defaults.RetentionInterval
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	subjects := make([]Subject, len(cfg.Subjects))
+	for i, s := range cfg.Subjects {
+		if s.Subject == "" {
+			return nil, fmt.Errorf("archive subject %d: subject is required", i)
+		}
+		if s.QueueGroup == "" {
+			s.QueueGroup = "archive"
+		}
+		subjects[i] = s
+	}
+	cfg.Subjects = subjects
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Archiver{
+		cfg:        cfg,
+		subscriber: subscriber,
+		store:      store,
+		logger:     logger,
+		metrics:    newArchiverMetrics(cfg.Registry),
+		ctx:        ctx,
+		cancel:     cancel,
+	}, nil
+}
+
+// Start subscribes to every subject and launches the flush and retention
+// loops.
+func (a *Archiver) Start() error {
+	if a.subscriber == nil {
+		return fmt.Errorf("archiver requires a subscriber")
+	}
+
+	a.wg.Add(1)
+	go a.flushLoop()
+	if a.cfg.Retention > 0 {
+		a.wg.Add(1)
+		go a.retentionLoop()
+	}
+
+	for _, s := range a.cfg.Subjects {
+		var sub messaging.Subscription
+		var err error
+		if s.Durable != "" {
+			handler := messaging.WithAckPolicy(messaging.AckManual, a.archive)
+			sub, err = a.subscriber.SubscribePull(s.Subject, s.Durable, handler, messaging.WithBatchSize(a.cfg.BatchSize))
+		} else {
+			sub, err = a.subscriber.SubscribeSubject(s.Subject, a.archive, &messaging.SubscribeOptions{QueueGroup: s.QueueGroup})
+		}
+		if err != nil {
+			return fmt.Errorf("failed to subscribe archiver to %s: %w", s.Subject, err)
+		}
+		a.subs = append(a.subs, sub)
+		a.logger.Info("Archiving subject",
+			zap.String("subject", s.Subject),
+			zap.String("durable", s.Durable),
+		)
+	}
+	return nil
+}
+
+// Stop unsubscribes, writes the envelopes still waiting for their batch and
+// stops the loops.
+func (a *Archiver) Stop() {
+	for _, sub := range a.subs {
+		if err := sub.Unsubscribe(); err != nil {
+			a.logger.Warn("Failed to unsubscribe archiver", zap.String("subject", sub.Subject()), zap.Error(err))
+		}
+	}
+	a.cancel()
+	a.wg.Wait()
+	a.Flush(context.Background())
+}
+
+// archive adds an envelope to the batch, writing the batch once full.
+func (a *Archiver) archive(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+	a.mu.Lock()
+	a.batch = append(a.batch, pending{
+		record: Record{Subject: subject, ArchivedAt: time.Now().UTC(), Envelope: env},
+		acker:  messaging.AckerFromContext(ctx),
+	})
+	full := len(a.batch) >= a.cfg.BatchSize
+	a.mu.Unlock()
+
+	// Writing in the handler holds up the subscription while the store is
+	// slow, instead of buffering without bound
+	if full {
+		a.Flush(ctx)
+	}
+	return nil
+}
+
+// Flush writes the envelopes waiting for their batch.
+func (a *Archiver) Flush(ctx context.Context) {
+	a.flushMu.Lock()
+	defer a.flushMu.Unlock()
+
+	a.mu.Lock()
+	batch := a.batch
+	a.batch = nil
+	a.mu.Unlock()
+	if len(batch) == 0 {
+		return
+	}
+
+	records := make([]Record, len(batch))
+	for i, p := range batch {
+		records[i] = p.record
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), a.cfg.WriteTimeout)
+	defer cancel()
+	start := time.Now()
+	err := a.store.Write(ctx, records)
+	a.metrics.duration.Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		a.metrics.batches.WithLabelValues("failed").Inc()
+		a.metrics.records.WithLabelValues("failed").Add(float64(len(batch)))
+		a.logger.Error("Failed to archive envelopes", zap.Int("count", len(batch)), zap.Error(err))
+	} else {
+		a.metrics.batches.WithLabelValues("success").Inc()
+		a.metrics.records.WithLabelValues("success").Add(float64(len(batch)))
+	}
+
+	for _, p := range batch {
+		if p.acker == nil {
+			continue
+		}
+		ack := p.acker.Ack
+		if err != nil {
+			ack = p.acker.Nak
+		}
+		if ackErr := ack(); ackErr != nil {
+			a.logger.Warn("Failed to acknowledge archived envelope",
+				zap.String("id", p.record.Envelope.ID),
+				zap.Error(ackErr),
+			)
+		}
+	}
+}
+
+func (a *Archiver) flushLoop() {
+	defer a.wg.Done()
+	ticker := time.NewTicker(a.cfg.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C:
+			a.Flush(a.ctx)
+		}
+	}
+}
+
+func (a *Archiver) retentionLoop() {
+	defer a.wg.Done()
+	ticker := time.NewTicker(a.cfg.RetentionInterval)
+	defer ticker.Stop()
+	for {
+		a.prune()
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// prune deletes the records older than the retention.
+func (a *Archiver) prune() {
+	ctx, cancel := context.WithTimeout(a.ctx, a.cfg.WriteTimeout)
+	defer cancel()
+	n, err := a.store.Prune(ctx, time.Now().Add(-a.cfg.Retention))
+	if err != nil {
+		a.logger.Error("Failed to prune archive", zap.Error(err))
+		return
+	}
+	a.metrics.pruned.Add(float64(n))
+	if n > 0 {
+		a.logger.Info("Pruned archive", zap.Int("count", n), zap.Duration("retention", a.cfg.Retention))
+	}
+}
@@ -0,0 +1,224 @@
+package archive_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"grouter/pkg/archive"
+	messaging "grouter/pkg/messaging/nats"
+	"grouter/pkg/messaging/nats/mocks"
+	"grouter/pkg/telemetry"
+	grtest "grouter/pkg/testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// memoryStore is an archive.Store in memory, failing its writes while err is set
+type memoryStore struct {
+	mu      sync.Mutex
+	batches [][]archive.Record
+	err     error
+}
+
+func (s *memoryStore) Write(ctx context.Context, records []archive.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.batches = append(s.batches, records)
+	return nil
+}
+
+func (s *memoryStore) Prune(ctx context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for i, batch := range s.batches {
+		kept := batch[:0]
+		for _, r := range batch {
+			if r.ArchivedAt.Before(before) {
+				n++
+				continue
+			}
+			kept = append(kept, r)
+		}
+		s.batches[i] = kept
+	}
+	return n, nil
+}
+
+func (s *memoryStore) setErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// records returns the IDs of the archived envelopes
+func (s *memoryStore) records() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ids []string
+	for _, batch := range s.batches {
+		for _, r := range batch {
+			ids = append(ids, r.Envelope.ID)
+		}
+	}
+	return ids
+}
+
+func (s *memoryStore) sizes() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var sizes []int
+	for _, batch := range s.batches {
+		sizes = append(sizes, len(batch))
+	}
+	return sizes
+}
+
+// runMessenger returns a messenger on a NATS server run for the test
+func runMessenger(t *testing.T, opts ...grtest.ServerOption) *messaging.Messenger {
+	t.Helper()
+	return grtest.NewMessenger(t, grtest.RunNATSServer(t, opts...), "test-service")
+}
+
+func startArchiver(t *testing.T, subscriber messaging.Subscriber, store archive.Store, cfg archive.Config) *archive.Archiver {
+	t.Helper()
+	if cfg.Registry == nil {
+		cfg.Registry = telemetry.NewMetricsRegistry()
+	}
+	a, err := archive.New(subscriber, store, cfg, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, a.Start())
+	t.Cleanup(a.Stop)
+	return a
+}
+
+func TestArchiver_Batches(t *testing.T) {
+	m := runMessenger(t)
+	store := &memoryStore{}
+	a := startArchiver(t, m.Subscriber, store, archive.Config{
+		Subjects:      []archive.Subject{{Subject: "orders.>"}},
+		BatchSize:     3,
+		FlushInterval: 200 * time.Millisecond,
+	})
+
+	ctx := context.Background()
+	for i := 0; i < 4; i++ {
+		require.NoError(t, m.Publisher.Publish(ctx, "orders.created", "order", map[string]int{"n": i}, nil))
+	}
+	require.Eventually(t, func() bool { return len(store.records()) == 4 }, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []int{3, 1}, store.sizes(), "full batches are written at once, the rest after the flush interval")
+
+	rec := store.batches[0][0]
+	assert.Equal(t, "orders.created", rec.Subject)
+	assert.Equal(t, "order", rec.Envelope.Type)
+	assert.False(t, rec.ArchivedAt.IsZero())
+
+	assert.Equal(t, float64(4), a.Records("success"))
+	assert.Equal(t, float64(2), a.Batches("success"))
+}
+
+func TestArchiver_Stop(t *testing.T) {
+	m := runMessenger(t)
+	store := &memoryStore{}
+	a, err := archive.New(m.Subscriber, store, archive.Config{
+		Subjects:      []archive.Subject{{Subject: "orders.>"}},
+		FlushInterval: time.Hour,
+		Registry:      telemetry.NewMetricsRegistry(),
+	}, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, a.Start())
+
+	require.NoError(t, m.Publisher.Publish(context.Background(), "orders.created", "order", nil, nil))
+	require.Eventually(t, func() bool {
+		return a.Pending() == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	a.Stop()
+	assert.Len(t, store.records(), 1, "stopping writes the pending envelopes")
+}
+
+func TestArchiver_JetStream(t *testing.T) {
+	m := runMessenger(t, grtest.WithJetStream())
+	js, err := m.Client.JetStream()
+	require.NoError(t, err)
+	_, err = js.AddStream(&nats.StreamConfig{Name: "ORDERS", Subjects: []string{"orders.>"}, Storage: nats.MemoryStorage})
+	require.NoError(t, err)
+
+	store := &memoryStore{err: errors.New("store unavailable")}
+	a := startArchiver(t, m.Subscriber, store, archive.Config{
+		Subjects:      []archive.Subject{{Subject: "orders.created", Durable: "archive"}},
+		BatchSize:     2,
+		FlushInterval: 100 * time.Millisecond,
+	})
+
+	ack, err := m.Publisher.PublishJS(context.Background(), "orders.created", "order", nil)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return a.Records("failed") >= 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	store.setErr(nil)
+	require.Eventually(t, func() bool { return len(store.records()) > 0 }, 5*time.Second, 10*time.Millisecond,
+		"the envelopes of failed writes are delivered again")
+
+	require.Eventually(t, func() bool {
+		info, err := js.ConsumerInfo("ORDERS", "archive")
+		return err == nil && info.AckFloor.Stream == ack.Sequence
+	}, 2*time.Second, 10*time.Millisecond, "written envelopes are acknowledged")
+}
+
+func TestArchiver_StopKeepsOtherSubscriptions(t *testing.T) {
+	subscriber := mocks.NewSubscriber()
+	handler := func(context.Context, string, *messaging.MessageEnvelope) error { return nil }
+	_, err := subscriber.SubscribePull("orders.created", "billing", handler)
+	require.NoError(t, err)
+	require.NoError(t, subscriber.Subscribe("orders.created", handler, nil))
+
+	a, err := archive.New(subscriber, &memoryStore{}, archive.Config{
+		Subjects: []archive.Subject{{Subject: "orders.created", Durable: "archive"}, {Subject: "orders.created"}},
+		Registry: telemetry.NewMetricsRegistry(),
+	}, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, a.Start())
+	require.Len(t, subscriber.Subscriptions(), 4)
+
+	a.Stop()
+	subs := subscriber.Subscriptions()
+	require.Len(t, subs, 2, "only the subscriptions of the archiver are removed")
+	assert.Equal(t, "billing", subs[0].Durable)
+}
+
+func TestArchiver_Retention(t *testing.T) {
+	m := runMessenger(t)
+	old := archive.Record{ArchivedAt: time.Now().Add(-2 * time.Hour), Envelope: &messaging.MessageEnvelope{ID: "old"}}
+	recent := archive.Record{ArchivedAt: time.Now(), Envelope: &messaging.MessageEnvelope{ID: "recent"}}
+	store := &memoryStore{batches: [][]archive.Record{{old, recent}}}
+
+	a := startArchiver(t, m.Subscriber, store, archive.Config{Retention: time.Hour})
+	require.Eventually(t, func() bool { return len(store.records()) == 1 }, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"recent"}, store.records())
+	assert.Equal(t, float64(1), a.Pruned())
+}
+
+func TestNew(t *testing.T) {
+	_, err := archive.New(nil, nil, archive.Config{}, nil)
+	assert.EqualError(t, err, "archiver requires a store")
+
+	_, err = archive.New(nil, &memoryStore{}, archive.Config{Subjects: []archive.Subject{{}}}, nil)
+	assert.EqualError(t, err, "archive subject 0: subject is required")
+
+	a, err := archive.New(nil, &memoryStore{}, archive.Config{Subjects: []archive.Subject{{Subject: "orders"}}, Registry: telemetry.NewMetricsRegistry()}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "archive", a.Config().Subjects[0].QueueGroup)
+	assert.Equal(t, 100, a.Config().BatchSize)
+	assert.EqualError(t, a.Start(), "archiver requires a subscriber")
+}
@@ -0,0 +1,140 @@
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ArchivedEnvelope is the row of an archived envelope in the
+// archived_envelopes table. The envelope is stored whole, along with the
+// fields audits and replays look up.
+type ArchivedEnvelope struct {
+	ID            uint      `gorm:"primarykey"`
+	EnvelopeID    string    `gorm:"size:64;uniqueIndex"`
+	Subject       string    `gorm:"size:255;index"`
+	Type          string    `gorm:"size:255;index"`
+	Source        string    `gorm:"size:255"`
+	CorrelationID string    `gorm:"size:64;index"`
+	TenantID      string    `gorm:"size:255;index"`
+	Timestamp     time.Time `gorm:"index"`
+	ArchivedAt    time.Time `gorm:"index"`
+	Envelope      []byte
+}
+
+// TableName returns the table of the archived envelopes.
+func (ArchivedEnvelope) TableName() string {
+	return "archived_envelopes"
+}
+
+// Query selects archived envelopes. Zero fields select everything.
+type Query struct {
+	Subject       string
+	Type          string
+	CorrelationID string
+	TenantID      string
+	// From and To bound the envelope timestamps, To excluded.
+	From time.Time
+	To   time.Time
+	// Limit caps the number of records, 100 when zero.
+	Limit int
+}
+
+// DBStore is a Store on a database, e.g. Postgres through pkg/database. An
+// envelope is stored once, whatever the number of its deliveries.
+type DBStore struct {
+	db *gorm.DB
+}
+
+// NewDBStore creates a DBStore on db, migrating the archived_envelopes
+// table.
+func NewDBStore(db *gorm.DB) (*DBStore, error) {
+	if err := db.AutoMigrate(&ArchivedEnvelope{}); err != nil {
+		return nil, fmt.Errorf("failed to migrate archived envelopes: %w", err)
+	}
+	return &DBStore{db: db}, nil
+}
+
+// Write inserts the records, skipping the envelopes already archived.
+func (s *DBStore) Write(ctx context.Context, records []Record) error {
+	rows := make([]ArchivedEnvelope, 0, len(records))
+	for _, r := range records {
+		data, err := json.Marshal(r.Envelope)
+		if err != nil {
+			return fmt.Errorf("failed to encode envelope %s: %w", r.Envelope.ID, err)
+		}
+		rows = append(rows, ArchivedEnvelope{
+			EnvelopeID:    r.Envelope.ID,
+			Subject:       r.Subject,
+			Type:          r.Envelope.Type,
+			Source:        r.Envelope.Source,
+			CorrelationID: r.Envelope.CorrelationID,
+			TenantID:      r.Envelope.TenantID(),
+			Timestamp:     r.Envelope.Timestamp,
+			ArchivedAt:    r.ArchivedAt,
+			Envelope:      data,
+		})
+	}
+	err := s.db.WithContext(ctx).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "envelope_id"}}, DoNothing: true}).
+		Create(&rows).Error
+	if err != nil {
+		return fmt.Errorf("failed to archive envelopes: %w", err)
+	}
+	return nil
+}
+
+// Prune deletes the records archived before the given time.
+func (s *DBStore) Prune(ctx context.Context, before time.Time) (int, error) {
+	res := s.db.WithContext(ctx).Where("archived_at < ?", before).Delete(&ArchivedEnvelope{})
+	if res.Error != nil {
+		return 0, fmt.Errorf("failed to prune archived envelopes: %w", res.Error)
+	}
+	return int(res.RowsAffected), nil
+}
+
+// Find returns the records selected by q, oldest envelope first.
+func (s *DBStore) Find(ctx context.Context, q Query) ([]Record, error) {
+	tx := s.db.WithContext(ctx).Model(&ArchivedEnvelope{})
+	if q.Subject != "" {
+		tx = tx.Where("subject = ?", q.Subject)
+	}
+	if q.Type != "" {
+		tx = tx.Where("type = ?", q.Type)
+	}
+	if q.CorrelationID != "" {
+		tx = tx.Where("correlation_id = ?", q.CorrelationID)
+	}
+	if q.TenantID != "" {
+		tx = tx.Where("tenant_id = ?", q.TenantID)
+	}
+	if !q.From.IsZero() {
+		tx = tx.Where("timestamp >= ?", q.From)
+	}
+	if !q.To.IsZero() {
+		tx = tx.Where("timestamp < ?", q.To)
+	}
+	if q.Limit <= 0 {
+		q.Limit = 100
+	}
+
+	var rows []ArchivedEnvelope
+	if err := tx.Order("timestamp, id").Limit(q.Limit).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to find archived envelopes: %w", err)
+	}
+	records := make([]Record, len(rows))
+	for i, row := range rows {
+		env := &messaging.MessageEnvelope{}
+		if err := json.Unmarshal(row.Envelope, env); err != nil {
+			return nil, fmt.Errorf("failed to decode envelope %s: %w", row.EnvelopeID, err)
+		}
+		records[i] = Record{Subject: row.Subject, ArchivedAt: row.ArchivedAt, Envelope: env}
+	}
+	return records, nil
+}
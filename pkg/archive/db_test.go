@@ -0,0 +1,97 @@
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newEnvelope returns an envelope of msgType carrying data as JSON
+func newEnvelope(t *testing.T, msgType string, data any) *messaging.MessageEnvelope {
+	t.Helper()
+	raw, err := json.Marshal(data)
+	require.NoError(t, err)
+	id := uuid.NewString()
+	return &messaging.MessageEnvelope{
+		ID:            id,
+		CorrelationID: id,
+		Type:          msgType,
+		Timestamp:     time.Now().UTC(),
+		Source:        "test",
+		Data:          raw,
+	}
+}
+
+func newDBStore(t *testing.T) *DBStore {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "archive.db")), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+	store, err := NewDBStore(db)
+	require.NoError(t, err)
+	return store
+}
+
+func TestDBStore(t *testing.T) {
+	store := newDBStore(t)
+	ctx := context.Background()
+
+	created := newEnvelope(t, "order.created", map[string]string{"id": "1"})
+	created.SetTenantID("acme")
+	shipped := newEnvelope(t, "order.shipped", nil)
+	shipped.Timestamp = created.Timestamp.Add(time.Second)
+	now := time.Now().UTC()
+	require.NoError(t, store.Write(ctx, []Record{
+		{Subject: "orders.created", ArchivedAt: now, Envelope: created},
+		{Subject: "orders.shipped", ArchivedAt: now, Envelope: shipped},
+	}))
+	require.NoError(t, store.Write(ctx, []Record{{Subject: "orders.created", ArchivedAt: now, Envelope: created}}),
+		"redelivered envelopes are skipped")
+
+	records, err := store.Find(ctx, Query{})
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, created.ID, records[0].Envelope.ID)
+	assert.JSONEq(t, `{"id":"1"}`, string(records[0].Envelope.Data))
+	assert.Equal(t, "orders.shipped", records[1].Subject)
+
+	records, err = store.Find(ctx, Query{TenantID: "acme"})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "order.created", records[0].Envelope.Type)
+
+	records, err = store.Find(ctx, Query{From: shipped.Timestamp, Type: "order.shipped"})
+	require.NoError(t, err)
+	assert.Len(t, records, 1)
+
+	records, err = store.Find(ctx, Query{CorrelationID: shipped.CorrelationID, To: shipped.Timestamp})
+	require.NoError(t, err)
+	assert.Empty(t, records)
+}
+
+func TestDBStore_Prune(t *testing.T) {
+	store := newDBStore(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+	require.NoError(t, store.Write(ctx, []Record{
+		{Subject: "orders", ArchivedAt: now.Add(-2 * time.Hour), Envelope: newEnvelope(t, "order", nil)},
+		{Subject: "orders", ArchivedAt: now, Envelope: newEnvelope(t, "order", nil)},
+	}))
+
+	n, err := store.Prune(ctx, now.Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	records, err := store.Find(ctx, Query{Subject: "orders"})
+	require.NoError(t, err)
+	assert.Len(t, records, 1)
+}
@@ -0,0 +1,33 @@
+package archive
+
+import "github.com/prometheus/client_golang/prometheus/testutil"
+
+// The internals read by the tests of package archive_test, which run the
+// archiver on the grtest harness: it imports the manager, and so this package
+
+// Config returns the configuration with its defaults applied
+func (a *Archiver) Config() Config {
+	return a.cfg
+}
+
+// Pending returns the number of envelopes waiting for their batch
+func (a *Archiver) Pending() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.batch)
+}
+
+// Records returns the number of envelopes archived with result
+func (a *Archiver) Records(result string) float64 {
+	return testutil.ToFloat64(a.metrics.records.WithLabelValues(result))
+}
+
+// Batches returns the number of batch writes with result
+func (a *Archiver) Batches(result string) float64 {
+	return testutil.ToFloat64(a.metrics.batches.WithLabelValues(result))
+}
+
+// Pruned returns the number of records deleted by the retention policy
+func (a *Archiver) Pruned() float64 {
+	return testutil.ToFloat64(a.metrics.pruned)
+}
@@ -0,0 +1,143 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/google/uuid"
+)
+
+// maxDeleteObjects is the number of keys of a DeleteObjects call.
+const maxDeleteObjects = 1000
+
+// S3API is the part of the S3 client used by S3Store.
+type S3API interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error)
+}
+
+// S3Config holds the settings of an S3Store.
+type S3Config struct {
+	// Bucket holds the archive objects.
+	Bucket string `mapstructure:"bucket"`
+	// Prefix starts the keys of the archive objects.
+	Prefix string `mapstructure:"prefix"`
+	// Region is the AWS region, from the environment when empty.
+	Region string `mapstructure:"region"`
+	// Endpoint overrides the S3 endpoint, e.g. MinIO.
+	Endpoint string `mapstructure:"endpoint"`
+	// UsePathStyle addresses the bucket in the path instead of the host, as
+	// most S3-compatible stores require.
+	UsePathStyle bool `mapstructure:"use_path_style"`
+}
+
+// S3Store is a Store on S3-compatible object storage. Every batch is an
+// object of JSON lines, one Record per line, under
+// <prefix>/<yyyy>/<mm>/<dd>/<hh>/ of the hour it was written. The records of
+// redelivered envelopes are written again.
+type S3Store struct {
+	client S3API
+	cfg    S3Config
+}
+
+// DialS3 creates an S3Store with a client of cfg using the default AWS
+// credential chain.
+func DialS3(ctx context.Context, cfg S3Config) (*S3Store, error) {
+	var opts []func(*awsconfig.LoadOptions) error
+	if cfg.Region != "" {
+		opts = append(opts, awsconfig.WithRegion(cfg.Region))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load aws config: %w", err)
+	}
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+		o.UsePathStyle = cfg.UsePathStyle
+	})
+	return NewS3Store(client, cfg)
+}
+
+// NewS3Store creates an S3Store with client.
+func NewS3Store(client S3API, cfg S3Config) (*S3Store, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("s3 archive requires a bucket")
+	}
+	return &S3Store{client: client, cfg: cfg}, nil
+}
+
+// Write writes the records as a new object.
+func (s *S3Store) Write(ctx context.Context, records []Record) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return fmt.Errorf("failed to encode envelope %s: %w", r.Envelope.ID, err)
+		}
+	}
+
+	now := time.Now().UTC()
+	key := path.Join(s.cfg.Prefix, now.Format("2006/01/02/15"), fmt.Sprintf("%d-%s.jsonl", now.UnixNano(), uuid.NewString()))
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.cfg.Bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(buf.Bytes()),
+		ContentType: aws.String("application/x-ndjson"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put archive object %s: %w", key, err)
+	}
+	return nil
+}
+
+// Prune deletes the objects last modified before the given time and returns
+// how many objects were deleted. Bucket lifecycle rules are an alternative
+// to a Retention.
+func (s *S3Store) Prune(ctx context.Context, before time.Time) (int, error) {
+	var expired []types.ObjectIdentifier
+	input := &s3.ListObjectsV2Input{Bucket: aws.String(s.cfg.Bucket)}
+	if s.cfg.Prefix != "" {
+		input.Prefix = aws.String(s.cfg.Prefix + "/")
+	}
+	for {
+		out, err := s.client.ListObjectsV2(ctx, input)
+		if err != nil {
+			return 0, fmt.Errorf("failed to list archive objects: %w", err)
+		}
+		for _, obj := range out.Contents {
+			if obj.LastModified != nil && obj.LastModified.Before(before) {
+				expired = append(expired, types.ObjectIdentifier{Key: obj.Key})
+			}
+		}
+		if !aws.ToBool(out.IsTruncated) {
+			break
+		}
+		input.ContinuationToken = out.NextContinuationToken
+	}
+
+	deleted := 0
+	for len(expired) > 0 {
+		chunk := expired[:min(len(expired), maxDeleteObjects)]
+		expired = expired[len(chunk):]
+		out, err := s.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(s.cfg.Bucket),
+			Delete: &types.Delete{Objects: chunk, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete archive objects: %w", err)
+		}
+		deleted += len(chunk) - len(out.Errors)
+	}
+	return deleted, nil
+}
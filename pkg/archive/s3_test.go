@@ -0,0 +1,141 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeObject struct {
+	data     []byte
+	modified time.Time
+}
+
+// fakeS3 is an S3API on a bucket in memory, listing pageSize keys per page
+type fakeS3 struct {
+	mu       sync.Mutex
+	objects  map[string]fakeObject
+	pageSize int
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{objects: make(map[string]fakeObject), pageSize: 2}
+}
+
+func (f *fakeS3) PutObject(ctx context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	data, err := io.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.objects[aws.ToString(in.Key)] = fakeObject{data: data, modified: time.Now()}
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (f *fakeS3) ListObjectsV2(ctx context.Context, in *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, aws.ToString(in.Prefix)) && key > aws.ToString(in.ContinuationToken) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	out := &s3.ListObjectsV2Output{IsTruncated: aws.Bool(len(keys) > f.pageSize)}
+	if len(keys) > f.pageSize {
+		keys = keys[:f.pageSize]
+		out.NextContinuationToken = aws.String(keys[len(keys)-1])
+	}
+	for _, key := range keys {
+		out.Contents = append(out.Contents, types.Object{Key: aws.String(key), LastModified: aws.Time(f.objects[key].modified)})
+	}
+	return out, nil
+}
+
+func (f *fakeS3) DeleteObjects(ctx context.Context, in *s3.DeleteObjectsInput, _ ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, obj := range in.Delete.Objects {
+		delete(f.objects, aws.ToString(obj.Key))
+	}
+	return &s3.DeleteObjectsOutput{}, nil
+}
+
+func (f *fakeS3) keys() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var keys []string
+	for key := range f.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestS3Store_Write(t *testing.T) {
+	client := newFakeS3()
+	store, err := NewS3Store(client, S3Config{Bucket: "archive", Prefix: "events"})
+	require.NoError(t, err)
+
+	env := newEnvelope(t, "order", map[string]string{"id": "1"})
+	now := time.Now().UTC()
+	require.NoError(t, store.Write(context.Background(), []Record{
+		{Subject: "orders.created", ArchivedAt: now, Envelope: env},
+		{Subject: "orders.shipped", ArchivedAt: now, Envelope: newEnvelope(t, "order", nil)},
+	}))
+
+	keys := client.keys()
+	require.Len(t, keys, 1)
+	assert.True(t, strings.HasPrefix(keys[0], "events/"+now.Format("2006/01/02/15")+"/"), keys[0])
+	assert.True(t, strings.HasSuffix(keys[0], ".jsonl"))
+
+	var records []Record
+	scanner := bufio.NewScanner(bytes.NewReader(client.objects[keys[0]].data))
+	for scanner.Scan() {
+		var r Record
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &r))
+		records = append(records, r)
+	}
+	require.Len(t, records, 2, "a batch is an object of JSON lines")
+	assert.Equal(t, "orders.created", records[0].Subject)
+	assert.Equal(t, env.ID, records[0].Envelope.ID)
+	assert.JSONEq(t, `{"id":"1"}`, string(records[0].Envelope.Data))
+}
+
+func TestS3Store_Prune(t *testing.T) {
+	client := newFakeS3()
+	store, err := NewS3Store(client, S3Config{Bucket: "archive", Prefix: "events"})
+	require.NoError(t, err)
+	old := time.Now().Add(-2 * time.Hour)
+	for _, key := range []string{"events/a", "events/b", "events/c"} {
+		client.objects[key] = fakeObject{modified: old}
+	}
+	client.objects["events/d"] = fakeObject{modified: time.Now()}
+	client.objects["other/a"] = fakeObject{modified: old}
+
+	n, err := store.Prune(context.Background(), time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 3, n, "the expired objects of every page are deleted")
+	assert.Equal(t, []string{"events/d", "other/a"}, client.keys())
+}
+
+func TestNewS3Store(t *testing.T) {
+	_, err := NewS3Store(newFakeS3(), S3Config{})
+	assert.EqualError(t, err, "s3 archive requires a bucket")
+}
@@ -0,0 +1,281 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"grouter/pkg/config"
+	"grouter/pkg/telemetry"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
+)
+
+// Stores
+const (
+	StoreMemory = "memory"
+	StoreRedis  = "redis"
+)
+
+// DefaultName labels the metrics and spans of a cache created without WithName
+const DefaultName = "default"
+
+// ErrNotFound is returned by Get when the key is missing or expired
+var ErrNotFound = errors.New("cache: key not found")
+
+// LoadFunc produces the value of a missing key
+type LoadFunc func(ctx context.Context) ([]byte, error)
+
+// Cache is a key/value cache shared by services and web middleware.
+// Implementations must be safe for concurrent use.
+type Cache interface {
+	// Get returns the value of key, or ErrNotFound
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set stores value for ttl (0 uses the default TTL of the cache)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+	// GetOrLoad returns the cached value of key, or calls load and caches its
+	// result for ttl. Concurrent misses of the same key share one load.
+	GetOrLoad(ctx context.Context, key string, ttl time.Duration, load LoadFunc) ([]byte, error)
+	// Ping checks that the store is reachable
+	Ping(ctx context.Context) error
+	// Close releases the store
+	Close() error
+}
+
+// Store is the storage backend of a Cache. A zero ttl never expires.
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// Options configures a Cache
+type Options struct {
+	// Name labels the metrics and spans (default DefaultName)
+	Name string
+	// DefaultTTL applies when Set or GetOrLoad are given no TTL. Zero keeps
+	// entries until they are evicted.
+	DefaultTTL time.Duration
+	// Metrics records hits, misses and latencies. Nil disables the metrics.
+	Metrics *telemetry.MetricsRegistry
+}
+
+// Option is a functional option for New
+type Option func(*Options)
+
+// WithName sets the name of the cache
+func WithName(name string) Option {
+	return func(o *Options) {
+		o.Name = name
+	}
+}
+
+// WithDefaultTTL sets the TTL of entries stored without one
+func WithDefaultTTL(ttl time.Duration) Option {
+	return func(o *Options) {
+		o.DefaultTTL = ttl
+	}
+}
+
+// WithMetrics records the cache metrics in reg
+func WithMetrics(reg *telemetry.MetricsRegistry) Option {
+	return func(o *Options) {
+		o.Metrics = reg
+	}
+}
+
+// NewFromConfig creates the cache and store selected in the configuration
+func NewFromConfig(cfg config.CacheConfig, opts ...Option) (Cache, error) {
+	var store Store
+	switch cfg.Store {
+	case "", StoreMemory:
+		store = NewMemoryStore(cfg.MaxEntries)
+	case StoreRedis:
+		if cfg.Redis.Addr == "" {
+			return nil, fmt.Errorf("redis cache store requires an address")
+		}
+		client := redis.NewClient(&redis.Options{
+			Addr:     cfg.Redis.Addr,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+		})
+		store = NewRedisStore(client, cfg.Redis.Prefix)
+	default:
+		return nil, fmt.Errorf("invalid cache store %q", cfg.Store)
+	}
+	opts = append([]Option{WithDefaultTTL(cfg.DefaultTTL)}, opts...)
+	return New(store, opts...), nil
+}
+
+// New creates a cache on top of store
+func New(store Store, opts ...Option) Cache {
+	var options Options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.Name == "" {
+		options.Name = DefaultName
+	}
+
+	c := &cache{
+		store:  store,
+		opts:   options,
+		tracer: otel.Tracer(instrumentationName),
+	}
+	if options.Metrics != nil {
+		c.metrics = newMetrics(options.Metrics)
+	}
+	return c
+}
+
+const instrumentationName = "grouter/pkg/cache"
+
+type cache struct {
+	store   Store
+	opts    Options
+	group   singleflight.Group
+	tracer  trace.Tracer
+	metrics *metrics
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+func (c *cache) Get(ctx context.Context, key string) ([]byte, error) {
+	ctx, span := c.start(ctx, "get", key)
+	defer span.End()
+
+	start := time.Now()
+	value, err := c.store.Get(ctx, key)
+	c.observe("get", start)
+	c.record(span, err)
+	return value, err
+}
+
+func (c *cache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	ctx, span := c.start(ctx, "set", key)
+	defer span.End()
+
+	start := time.Now()
+	err := c.store.Set(ctx, key, value, c.ttl(ttl))
+	c.observe("set", start)
+	endSpan(span, err)
+	return err
+}
+
+func (c *cache) Delete(ctx context.Context, key string) error {
+	ctx, span := c.start(ctx, "delete", key)
+	defer span.End()
+
+	start := time.Now()
+	err := c.store.Delete(ctx, key)
+	c.observe("delete", start)
+	endSpan(span, err)
+	return err
+}
+
+func (c *cache) GetOrLoad(ctx context.Context, key string, ttl time.Duration, load LoadFunc) ([]byte, error) {
+	// An unreachable store degrades to loading every time rather than failing
+	if value, err := c.Get(ctx, key); err == nil {
+		return value, nil
+	}
+
+	// The load is shared, so one caller giving up must not cancel it for the
+	// others
+	loadCtx := context.WithoutCancel(ctx)
+	ch := c.group.DoChan(key, func() (interface{}, error) {
+		ctx, span := c.start(loadCtx, "load", key)
+		defer span.End()
+
+		start := time.Now()
+		value, err := load(ctx)
+		c.observe("load", start)
+		if err != nil {
+			endSpan(span, err)
+			return nil, err
+		}
+		// The value is returned even when it cannot be stored
+		_ = c.Set(ctx, key, value, ttl)
+		return value, nil
+	})
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.([]byte), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *cache) Ping(ctx context.Context) error {
+	return c.store.Ping(ctx)
+}
+
+func (c *cache) Close() error {
+	return c.store.Close()
+}
+
+func (c *cache) ttl(ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		return c.opts.DefaultTTL
+	}
+	return ttl
+}
+
+func (c *cache) start(ctx context.Context, op, key string) (context.Context, trace.Span) {
+	return c.tracer.Start(ctx, "cache."+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("cache.name", c.opts.Name),
+			attribute.String("cache.key", key),
+		))
+}
+
+// record counts the result of a Get
+func (c *cache) record(span trace.Span, err error) {
+	result := resultHit
+	switch {
+	case err == nil:
+		c.hits.Add(1)
+	case errors.Is(err, ErrNotFound):
+		result = resultMiss
+		c.misses.Add(1)
+	default:
+		result = resultError
+		endSpan(span, err)
+	}
+	span.SetAttributes(attribute.String("cache.result", result))
+
+	if c.metrics != nil {
+		c.metrics.requests.WithLabelValues(c.opts.Name, result).Inc()
+		if hits, misses := c.hits.Load(), c.misses.Load(); hits+misses > 0 {
+			c.metrics.hitRatio.WithLabelValues(c.opts.Name).Set(float64(hits) / float64(hits+misses))
+		}
+	}
+}
+
+func (c *cache) observe(op string, start time.Time) {
+	if c.metrics != nil {
+		c.metrics.duration.WithLabelValues(c.opts.Name, op).Observe(time.Since(start).Seconds())
+	}
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
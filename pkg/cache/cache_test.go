@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"grouter/pkg/config"
+	"grouter/pkg/telemetry"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingStore fails every operation, like an unreachable Redis
+type failingStore struct{ *MemoryStore }
+
+var errUnreachable = errors.New("unreachable")
+
+func (failingStore) Get(context.Context, string) ([]byte, error) { return nil, errUnreachable }
+func (failingStore) Set(context.Context, string, []byte, time.Duration) error {
+	return errUnreachable
+}
+
+func TestCache_GetSetDelete(t *testing.T) {
+	ctx := context.Background()
+	c := New(NewMemoryStore(0))
+
+	_, err := c.Get(ctx, "k")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, c.Set(ctx, "k", []byte("v"), 0))
+	v, err := c.Get(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v"), v)
+
+	require.NoError(t, c.Delete(ctx, "k"))
+	_, err = c.Get(ctx, "k")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NoError(t, c.Delete(ctx, "k"))
+}
+
+func TestCache_DefaultTTL(t *testing.T) {
+	store := NewMemoryStore(0)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	c := New(store, WithDefaultTTL(time.Minute))
+
+	require.NoError(t, c.Set(context.Background(), "k", []byte("v"), 0))
+	now = now.Add(2 * time.Minute)
+	_, err := c.Get(context.Background(), "k")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestCache_GetOrLoad_Singleflight(t *testing.T) {
+	c := New(NewMemoryStore(0))
+	var loads int32
+	release := make(chan struct{})
+	load := func(context.Context) ([]byte, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return []byte("loaded"), nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := c.GetOrLoad(context.Background(), "k", time.Minute, load)
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("loaded"), v)
+		}()
+	}
+	// Let the callers pile up on the in-flight load
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+
+	// Later calls are served from the cache
+	v, err := c.GetOrLoad(context.Background(), "k", time.Minute, func(context.Context) ([]byte, error) {
+		t.Fatal("unexpected load")
+		return nil, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("loaded"), v)
+}
+
+func TestCache_GetOrLoad_Errors(t *testing.T) {
+	ctx := context.Background()
+	c := New(NewMemoryStore(0))
+
+	// Load errors are returned and not cached
+	_, err := c.GetOrLoad(ctx, "k", 0, func(context.Context) ([]byte, error) {
+		return nil, errors.New("boom")
+	})
+	assert.EqualError(t, err, "boom")
+	_, err = c.Get(ctx, "k")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// An unreachable store still serves loaded values
+	c = New(failingStore{NewMemoryStore(0)})
+	v, err := c.GetOrLoad(ctx, "k", 0, func(context.Context) ([]byte, error) {
+		return []byte("v"), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v"), v)
+}
+
+func TestCache_GetOrLoad_CallerCancelled(t *testing.T) {
+	c := New(NewMemoryStore(0))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := c.GetOrLoad(ctx, "k", 0, func(ctx context.Context) ([]byte, error) {
+		// The load itself is not cancelled with the caller
+		assert.NoError(t, ctx.Err())
+		return []byte("v"), nil
+	})
+	if err != nil {
+		assert.ErrorIs(t, err, context.Canceled)
+	}
+}
+
+func TestCache_Metrics(t *testing.T) {
+	reg := telemetry.NewMetricsRegistry()
+	ctx := context.Background()
+	c := New(NewMemoryStore(0), WithName("users"), WithMetrics(reg))
+	cc := c.(*cache)
+
+	_, _ = c.Get(ctx, "k")
+	require.NoError(t, c.Set(ctx, "k", []byte("v"), 0))
+	_, _ = c.Get(ctx, "k")
+	_, _ = c.Get(ctx, "k")
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(cc.metrics.requests.WithLabelValues("users", resultHit)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(cc.metrics.requests.WithLabelValues("users", resultMiss)))
+	assert.InDelta(t, 2.0/3.0, testutil.ToFloat64(cc.metrics.hitRatio.WithLabelValues("users")), 0.001)
+	assert.Equal(t, 2, testutil.CollectAndCount(cc.metrics.duration))
+
+	// A second cache shares the registered metrics
+	other := New(NewMemoryStore(0), WithName("orders"), WithMetrics(reg)).(*cache)
+	assert.Same(t, cc.metrics.requests, other.metrics.requests)
+}
+
+func TestNewFromConfig(t *testing.T) {
+	c, err := NewFromConfig(config.CacheConfig{MaxEntries: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 1, c.(*cache).store.(*MemoryStore).maxEntries)
+
+	_, err = NewFromConfig(config.CacheConfig{Store: StoreRedis})
+	assert.Error(t, err)
+
+	c, err = NewFromConfig(config.CacheConfig{Store: StoreRedis, Redis: config.RateLimitRedisConfig{Addr: "localhost:6379"}})
+	require.NoError(t, err)
+	assert.IsType(t, &RedisStore{}, c.(*cache).store)
+	assert.NoError(t, c.Close())
+
+	_, err = NewFromConfig(config.CacheConfig{Store: "disk"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// GetJSON decodes the cached value of key into a T
+func GetJSON[T any](ctx context.Context, c Cache, key string) (T, error) {
+	var v T
+	data, err := c.Get(ctx, key)
+	if err != nil {
+		return v, err
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return v, fmt.Errorf("failed to decode cached %s: %w", key, err)
+	}
+	return v, nil
+}
+
+// SetJSON stores v as JSON
+func SetJSON[T any](ctx context.Context, c Cache, key string, v T, ttl time.Duration) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", key, err)
+	}
+	return c.Set(ctx, key, data, ttl)
+}
+
+// GetOrLoadJSON is GetOrLoad for values stored as JSON
+func GetOrLoadJSON[T any](ctx context.Context, c Cache, key string, ttl time.Duration, load func(ctx context.Context) (T, error)) (T, error) {
+	var v T
+	data, err := c.GetOrLoad(ctx, key, ttl, func(ctx context.Context) ([]byte, error) {
+		loaded, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(loaded)
+	})
+	if err != nil {
+		return v, err
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return v, fmt.Errorf("failed to decode cached %s: %w", key, err)
+	}
+	return v, nil
+}
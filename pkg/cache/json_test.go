@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type user struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestJSON(t *testing.T) {
+	ctx := context.Background()
+	c := New(NewMemoryStore(0))
+
+	require.NoError(t, SetJSON(ctx, c, "user:1", user{ID: 1, Name: "ada"}, 0))
+	u, err := GetJSON[user](ctx, c, "user:1")
+	require.NoError(t, err)
+	assert.Equal(t, user{ID: 1, Name: "ada"}, u)
+
+	loads := 0
+	load := func(context.Context) (user, error) {
+		loads++
+		return user{ID: 2, Name: "grace"}, nil
+	}
+	for i := 0; i < 2; i++ {
+		u, err = GetOrLoadJSON(ctx, c, "user:2", 0, load)
+		require.NoError(t, err)
+		assert.Equal(t, "grace", u.Name)
+	}
+	assert.Equal(t, 1, loads)
+
+	require.NoError(t, c.Set(ctx, "user:3", []byte("not json"), 0))
+	_, err = GetJSON[user](ctx, c, "user:3")
+	assert.Error(t, err)
+}
@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore_Expiry(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore(0)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	require.NoError(t, s.Set(ctx, "short", []byte("1"), time.Second))
+	require.NoError(t, s.Set(ctx, "forever", []byte("2"), 0))
+
+	now = now.Add(time.Hour)
+	_, err := s.Get(ctx, "short")
+	assert.ErrorIs(t, err, ErrNotFound)
+	v, err := s.Get(ctx, "forever")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("2"), v)
+	assert.Equal(t, 1, s.Len())
+}
+
+func TestMemoryStore_EvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore(2)
+
+	require.NoError(t, s.Set(ctx, "a", []byte("a"), 0))
+	require.NoError(t, s.Set(ctx, "b", []byte("b"), 0))
+	// Reading a makes b the least recently used
+	_, err := s.Get(ctx, "a")
+	require.NoError(t, err)
+	require.NoError(t, s.Set(ctx, "c", []byte("c"), 0))
+
+	_, err = s.Get(ctx, "b")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = s.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, s.Len())
+
+	// Overwriting does not evict
+	require.NoError(t, s.Set(ctx, "c", []byte("c2"), 0))
+	assert.Equal(t, 2, s.Len())
+
+	require.NoError(t, s.Close())
+	assert.Equal(t, 0, s.Len())
+}
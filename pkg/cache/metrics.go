@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"grouter/pkg/telemetry"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Results of a Get
+const (
+	resultHit   = "hit"
+	resultMiss  = "miss"
+	resultError = "error"
+)
+
+type metrics struct {
+	requests *prometheus.CounterVec
+	hitRatio *prometheus.GaugeVec
+	duration *prometheus.HistogramVec
+}
+
+// newMetrics registers the cache metrics in reg. Creating them again, e.g. for
+// another cache, reuses the registered ones.
+func newMetrics(reg *telemetry.MetricsRegistry) *metrics {
+	return &metrics{
+		requests: reg.CounterVec(prometheus.CounterOpts{
+			Name: "cache_requests_total",
+			Help: "Cache lookups by result (hit, miss or error).",
+		}, []string{"cache", "result"}),
+		hitRatio: reg.GaugeVec(prometheus.GaugeOpts{
+			Name: "cache_hit_ratio",
+			Help: "Ratio of cache lookups that were hits since the cache was created.",
+		}, []string{"cache"}),
+		duration: reg.HistogramVec(prometheus.HistogramOpts{
+			Name:    "cache_operation_duration_seconds",
+			Help:    "Latency of cache operations (get, set, delete, load).",
+			Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"cache", "operation"}),
+	}
+}
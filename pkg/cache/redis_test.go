@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisStore(t *testing.T) {
+	mr := miniredis.RunT(t)
+	s := NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}), "")
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.Ping(ctx))
+	_, err := s.Get(ctx, "k")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, s.Set(ctx, "k", []byte("v"), time.Minute))
+	assert.True(t, mr.Exists("cache:k"))
+	v, err := s.Get(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v"), v)
+
+	mr.FastForward(2 * time.Minute)
+	_, err = s.Get(ctx, "k")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, s.Set(ctx, "k", []byte("v"), 0))
+	require.NoError(t, s.Delete(ctx, "k"))
+	assert.False(t, mr.Exists("cache:k"))
+
+	mr.Close()
+	assert.Error(t, s.Ping(ctx))
+}
+
+func TestRedisStore_SharedAcrossCaches(t *testing.T) {
+	mr := miniredis.RunT(t)
+	ctx := context.Background()
+	a := New(NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}), "app:"))
+	b := New(NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}), "app:"))
+	defer a.Close()
+	defer b.Close()
+
+	require.NoError(t, a.Set(ctx, "k", []byte("v"), 0))
+	v, err := b.Get(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v"), v)
+}
@@ -0,0 +1,216 @@
+// Package chaos injects latency, errors and drops into a percentage of the
+// HTTP requests and NATS messages, for resilience experiments in staging. The
+// web and messaging middlewares ask an Injector which fault to inject; its
+// rules can be replaced at runtime.
+package chaos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// ErrInjected is the error of the error faults injected into messages
+var ErrInjected = errors.New("chaos: injected error")
+
+// Target is what a rule applies to
+type Target string
+
+// Targets
+const (
+	// TargetHTTP rules match request paths by prefix
+	TargetHTTP Target = "http"
+	// TargetNATS rules match message subjects, NATS wildcards allowed
+	TargetNATS Target = "nats"
+)
+
+// Fault is what a rule injects
+type Fault string
+
+// Faults
+const (
+	// FaultLatency delays the request or message by Latency
+	FaultLatency Fault = "latency"
+	// FaultError fails the request with Status (500 when unset), or the
+	// message handler with ErrInjected
+	FaultError Fault = "error"
+	// FaultDrop closes the connection of the request without a response, or
+	// drops the message without handling it
+	FaultDrop Fault = "drop"
+)
+
+// Rule injects Fault into Percent of the requests or messages of Target
+// matching Match
+type Rule struct {
+	Target Target `json:"target"`
+	// Match is the path prefix or subject pattern, empty for all
+	Match string `json:"match,omitempty"`
+	// Percent of the matching requests or messages affected (0-100]
+	Percent float64 `json:"percent"`
+	Fault   Fault   `json:"fault"`
+	// Latency of the latency faults
+	Latency time.Duration `json:"latency,omitempty"`
+	// Status of the HTTP error faults (500 when unset)
+	Status int `json:"status,omitempty"`
+}
+
+// rule is Rule with the latency as a duration string ("250ms")
+type rule struct {
+	Target  Target  `json:"target"`
+	Match   string  `json:"match,omitempty"`
+	Percent float64 `json:"percent"`
+	Fault   Fault   `json:"fault"`
+	Latency string  `json:"latency,omitempty"`
+	Status  int     `json:"status,omitempty"`
+}
+
+// MarshalJSON writes the latency as a duration string
+func (r Rule) MarshalJSON() ([]byte, error) {
+	out := rule{Target: r.Target, Match: r.Match, Percent: r.Percent, Fault: r.Fault, Status: r.Status}
+	if r.Latency > 0 {
+		out.Latency = r.Latency.String()
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON reads the latency as a duration string
+func (r *Rule) UnmarshalJSON(data []byte) error {
+	var in rule
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	*r = Rule{Target: in.Target, Match: in.Match, Percent: in.Percent, Fault: in.Fault, Status: in.Status}
+	if in.Latency != "" {
+		d, err := time.ParseDuration(in.Latency)
+		if err != nil {
+			return fmt.Errorf("invalid latency %q: %w", in.Latency, err)
+		}
+		r.Latency = d
+	}
+	return nil
+}
+
+// Validate reports the first invalid setting of the rule
+func (r Rule) Validate() error {
+	switch r.Target {
+	case TargetHTTP, TargetNATS:
+	default:
+		return fmt.Errorf("invalid target %q, want http or nats", r.Target)
+	}
+	if r.Percent <= 0 || r.Percent > 100 {
+		return fmt.Errorf("percent must be in (0, 100], got %g", r.Percent)
+	}
+	switch r.Fault {
+	case FaultLatency:
+		if r.Latency <= 0 {
+			return fmt.Errorf("latency must be positive, got %s", r.Latency)
+		}
+	case FaultError:
+		if r.Status != 0 && (r.Status < 400 || r.Status > 599) {
+			return fmt.Errorf("status must be an HTTP error status, got %d", r.Status)
+		}
+	case FaultDrop:
+	default:
+		return fmt.Errorf("invalid fault %q, want latency, error or drop", r.Fault)
+	}
+	return nil
+}
+
+// State is whether an Injector injects faults, and its rules
+type State struct {
+	Active bool   `json:"active"`
+	Rules  []Rule `json:"rules"`
+}
+
+// Validate reports the first invalid rule
+func (s State) Validate() error {
+	for i, r := range s.Rules {
+		if err := r.Validate(); err != nil {
+			return fmt.Errorf("rule %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Injector picks the faults to inject by the rules of its state. It injects
+// nothing while inactive.
+type Injector struct {
+	mu    sync.RWMutex
+	state State
+
+	// roll returns a percentage in [0, 100)
+	roll func() float64
+}
+
+// New creates an injector in state
+func New(state State) (*Injector, error) {
+	i := &Injector{roll: func() float64 { return rand.Float64() * 100 }}
+	if err := i.SetState(state); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// State returns the state of the injector
+func (i *Injector) State() State {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return State{Active: i.state.Active, Rules: append([]Rule{}, i.state.Rules...)}
+}
+
+// SetState replaces the state of the injector, unless it is invalid
+func (i *Injector) SetState(state State) error {
+	if err := state.Validate(); err != nil {
+		return err
+	}
+	state.Rules = append([]Rule{}, state.Rules...)
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.state = state
+	return nil
+}
+
+// SetActive starts or stops the injection of faults, keeping the rules
+func (i *Injector) SetActive(active bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.state.Active = active
+}
+
+// Pick returns the first rule of target whose pattern matches and whose
+// percentage roll hits, if any. A nil injector picks nothing.
+func (i *Injector) Pick(target Target, match func(pattern string) bool) (Rule, bool) {
+	if i == nil {
+		return Rule{}, false
+	}
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	if !i.state.Active {
+		return Rule{}, false
+	}
+	for _, r := range i.state.Rules {
+		if r.Target != target || (r.Match != "" && !match(r.Match)) {
+			continue
+		}
+		if i.roll() < r.Percent {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+// Sleep waits for d, or until ctx is done
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
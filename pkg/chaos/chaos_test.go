@@ -0,0 +1,88 @@
+package chaos
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func prefix(path string) func(string) bool {
+	return func(pattern string) bool { return strings.HasPrefix(path, pattern) }
+}
+
+func TestInjector_Pick(t *testing.T) {
+	i, err := New(State{Rules: []Rule{
+		{Target: TargetHTTP, Match: "/api/orders", Percent: 50, Fault: FaultError, Status: 503},
+		{Target: TargetHTTP, Percent: 100, Fault: FaultLatency, Latency: time.Second},
+		{Target: TargetNATS, Percent: 100, Fault: FaultDrop},
+	}})
+	require.NoError(t, err)
+
+	_, ok := i.Pick(TargetHTTP, prefix("/api/orders"))
+	assert.False(t, ok, "inactive injectors pick nothing")
+
+	i.SetActive(true)
+	i.roll = func() float64 { return 10 }
+	r, ok := i.Pick(TargetHTTP, prefix("/api/orders/1"))
+	require.True(t, ok)
+	assert.Equal(t, FaultError, r.Fault)
+
+	// A missed roll falls through to the next matching rule
+	i.roll = func() float64 { return 75 }
+	r, ok = i.Pick(TargetHTTP, prefix("/api/orders/1"))
+	require.True(t, ok)
+	assert.Equal(t, FaultLatency, r.Fault)
+
+	r, ok = i.Pick(TargetNATS, func(string) bool { return false })
+	require.True(t, ok, "empty patterns match everything")
+	assert.Equal(t, FaultDrop, r.Fault)
+
+	var none *Injector
+	_, ok = none.Pick(TargetHTTP, prefix("/"))
+	assert.False(t, ok)
+}
+
+func TestInjector_SetState(t *testing.T) {
+	i, err := New(State{})
+	require.NoError(t, err)
+
+	for name, r := range map[string]Rule{
+		"target":  {Target: "grpc", Percent: 10, Fault: FaultDrop},
+		"percent": {Target: TargetHTTP, Percent: 0, Fault: FaultDrop},
+		"fault":   {Target: TargetHTTP, Percent: 10, Fault: "crash"},
+		"latency": {Target: TargetHTTP, Percent: 10, Fault: FaultLatency},
+		"status":  {Target: TargetHTTP, Percent: 10, Fault: FaultError, Status: 200},
+	} {
+		assert.Error(t, i.SetState(State{Active: true, Rules: []Rule{r}}), name)
+	}
+	assert.False(t, i.State().Active, "invalid states are not applied")
+
+	_, err = New(State{Rules: []Rule{{Target: TargetNATS}}})
+	assert.Error(t, err)
+}
+
+func TestRule_JSON(t *testing.T) {
+	var state State
+	require.NoError(t, json.Unmarshal([]byte(`{"active":true,"rules":[{"target":"nats","match":"orders.>","percent":20,"fault":"latency","latency":"250ms"}]}`), &state))
+	require.Len(t, state.Rules, 1)
+	assert.Equal(t, 250*time.Millisecond, state.Rules[0].Latency)
+
+	raw, err := json.Marshal(state.Rules[0])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"target":"nats","match":"orders.>","percent":20,"fault":"latency","latency":"250ms"}`, string(raw))
+
+	assert.Error(t, json.Unmarshal([]byte(`{"latency":"soon"}`), &Rule{}))
+}
+
+func TestSleep(t *testing.T) {
+	assert.NoError(t, Sleep(context.Background(), time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, Sleep(ctx, time.Hour), context.Canceled)
+}
@@ -1,109 +1,62 @@
 package config
 
 import (
-	"fmt"
-	"strings"
+	"os"
+	"sync"
 
-	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
 var (
-	globalConfig *Config
+	// std is the loader of the package-level functions, bound to the global
+	// viper instance and command line flag set
+	std   *Loader
+	stdMu sync.Mutex
+
+	// exit ends the process in --validate-config mode
+	exit = os.Exit
 )
 
-// Load initializes and loads configuration from file, environment, and flags
+// Load initializes and loads configuration from file, environment, and flags.
+// With --validate-config, it reports the result and exits instead of
+// returning.
+//
+// Deprecated: Load shares the global viper instance and flag set; use
+// NewLoader.
 func Load() (*Config, error) {
-	// Define command-line flags
-	pflag.String("config", "configs/config.yaml", "Path to configuration file")
-	pflag.String("log-level", "", "Log level (debug, info, warn, error)")
-	pflag.String("nats-url", "", "NATS server URL")
-	pflag.Parse()
-
-	// Bind flags to viper
-	if err := viper.BindPFlags(pflag.CommandLine); err != nil {
-		return nil, fmt.Errorf("failed to bind flags: %w", err)
-	}
-
-	// Set config file
-	configFile := viper.GetString("config")
-	viper.SetConfigFile(configFile)
-
-	// Enable environment variable support
-	viper.SetEnvPrefix("GROUTER")
-	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
-	viper.AutomaticEnv()
-
-	// Read config file
-	if err := viper.ReadInConfig(); err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
-	}
-
-	// Unmarshal into config struct
-	var cfg Config
-	if err := viper.Unmarshal(&cfg); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
-	}
-
-	// Override with command-line flags if provided
-	if logLevel := viper.GetString("log-level"); logLevel != "" {
-		cfg.Log.Level = logLevel
-	}
-	if natsURL := viper.GetString("nats-url"); natsURL != "" {
-		cfg.NATS.URL = natsURL
-	}
-
-	// Validate configuration
-	if err := validate(&cfg); err != nil {
-		return nil, fmt.Errorf("config validation failed: %w", err)
-	}
-
-	globalConfig = &cfg
-	return &cfg, nil
+	l := NewLoader(WithViper(viper.GetViper()), WithFlagSet(pflag.CommandLine))
+	stdMu.Lock()
+	if std != nil {
+		std.Close()
+	}
+	std = l
+	stdMu.Unlock()
+	return l.Load()
 }
 
 // Get returns the global configuration
+//
+// Deprecated: use Loader.Config.
 func Get() *Config {
-	return globalConfig
+	if l := stdLoader(); l != nil {
+		return l.Config()
+	}
+	return nil
 }
 
-// Watch watches for configuration changes and reloads
+// Watch watches the configuration file and the remote store for changes and
+// reloads
+//
+// Deprecated: use Loader.Watch.
 func Watch(callback func(*Config)) {
-	viper.OnConfigChange(func(e fsnotify.Event) {
-		var cfg Config
-		if err := viper.Unmarshal(&cfg); err != nil {
-			fmt.Printf("Error reloading config: %v\n", err)
-			return
-		}
-		if err := validate(&cfg); err != nil {
-			fmt.Printf("Config validation failed after reload: %v\n", err)
-			return
-		}
-		globalConfig = &cfg
-		if callback != nil {
-			callback(&cfg)
-		}
-	})
-	viper.WatchConfig()
+	if l := stdLoader(); l != nil {
+		l.Watch(callback)
+	}
 }
 
-// validate performs configuration validation
-func validate(cfg *Config) error {
-	if cfg.App.Name == "" {
-		return fmt.Errorf("app.name is required")
-	}
-	if cfg.NATS.Enabled && cfg.NATS.URL == "" {
-		return fmt.Errorf("nats.url is required")
-	}
-	validLogLevels := map[string]bool{
-		"debug": true,
-		"info":  true,
-		"warn":  true,
-		"error": true,
-	}
-	if !validLogLevels[cfg.Log.Level] {
-		return fmt.Errorf("invalid log level: %s", cfg.Log.Level)
-	}
-	return nil
+func stdLoader() *Loader {
+	stdMu.Lock()
+	defer stdMu.Unlock()
+	return std
 }
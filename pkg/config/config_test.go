@@ -12,7 +12,10 @@ import (
 
 // resetConfig resets global state between tests
 func resetConfig() {
-	globalConfig = nil
+	if std != nil {
+		std.Close()
+		std = nil
+	}
 	viper.Reset()
 	pflag.CommandLine = pflag.NewFlagSet(os.Args[0], pflag.ExitOnError)
 }
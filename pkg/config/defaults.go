@@ -0,0 +1,228 @@
+package config
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Default returns the configuration made of the defaults alone
+func Default() *Config {
+	v := viper.New()
+	setDefaults(v)
+	var cfg Config
+	// The defaults are plain values, which always decode
+	_ = v.Unmarshal(&cfg)
+	return &cfg
+}
+
+// setDefaults registers the default of every setting, so that configuration
+// files only hold what differs. Optional features stay disabled; their
+// settings default to the values used once they are enabled. Settings whose
+// empty value has a meaning (e.g. web.metrics.path falling back to
+// metrics.path) have no default.
+func setDefaults(v *viper.Viper) {
+	v.SetDefault("app.environment", "development")
+
+	v.SetDefault("log.level", "info")
+	v.SetDefault("log.format", "json")
+	v.SetDefault("log.output_path", "stdout")
+	v.SetDefault("log.rotation.max_size_mb", 100)
+	v.SetDefault("log.rotation.max_age_days", 7)
+	v.SetDefault("log.rotation.max_backups", 10)
+	v.SetDefault("log.rotation.compress", true)
+	v.SetDefault("log.sampling.initial", 100)
+	v.SetDefault("log.sampling.thereafter", 100)
+	v.SetDefault("log.sampling.tick", time.Second)
+
+	v.SetDefault("metrics.enabled", true)
+	v.SetDefault("metrics.path", "/metrics")
+	v.SetDefault("metrics.otlp.protocol", "http")
+	v.SetDefault("metrics.otlp.interval", time.Minute)
+	v.SetDefault("metrics.otlp.bridge_prometheus", true)
+
+	v.SetDefault("tracing.exporter", "stdout")
+	v.SetDefault("tracing.sampler.ratio", 1.0)
+
+	setWebDefaults(v)
+
+	v.SetDefault("grpc.port", 9090)
+	v.SetDefault("grpc.shutdown_timeout", 5*time.Second)
+	v.SetDefault("grpc.reflection", true)
+	v.SetDefault("grpc.metrics.enabled", true)
+	v.SetDefault("grpc.logging.enabled", true)
+	v.SetDefault("grpc.gateway.path_prefix", "/api")
+	v.SetDefault("grpc.gateway.openapi.path", "/openapi")
+	v.SetDefault("grpc.gateway.openapi.dir", "api/openapi")
+
+	v.SetDefault("nats.url", "nats://localhost:4222")
+	v.SetDefault("nats.embedded_server.host", "127.0.0.1")
+	v.SetDefault("nats.embedded_server.port", 4222)
+	v.SetDefault("nats.embedded_server.ready_timeout", 10*time.Second)
+	v.SetDefault("nats.max_reconnects", 5)
+	v.SetDefault("nats.reconnect_wait", 2*time.Second)
+	v.SetDefault("nats.connection_timeout", 2*time.Second)
+	v.SetDefault("nats.auth_rotation.jitter", 10*time.Second)
+	v.SetDefault("nats.signing.verify", true)
+	v.SetDefault("nats.signing.max_age", 5*time.Minute)
+	v.SetDefault("nats.large_payloads.bucket", "payloads")
+	v.SetDefault("nats.large_payloads.ttl", time.Hour)
+	v.SetDefault("nats.large_payloads.max_size", 64<<20)
+	v.SetDefault("nats.large_payloads.timeout", 10*time.Second)
+	v.SetDefault("nats.compression.algorithm", "zstd")
+	v.SetDefault("nats.compression.threshold", 1024)
+	v.SetDefault("nats.monitoring.interval", 30*time.Second)
+	v.SetDefault("nats.logging.sampling.initial", 100)
+	v.SetDefault("nats.logging.sampling.thereafter", 100)
+	v.SetDefault("nats.logging.sampling.tick", time.Second)
+	v.SetDefault("nats.quarantine.max_deliveries", 5)
+	v.SetDefault("nats.quarantine.stream", "QUARANTINE")
+	v.SetDefault("nats.quarantine.prefix", "quarantine")
+	v.SetDefault("nats.response_cache.ttl", time.Minute)
+	v.SetDefault("nats.load_shedding.interval", time.Second)
+	v.SetDefault("nats.load_shedding.retry_after", time.Second)
+	v.SetDefault("nats.pre_stop.grace", 10*time.Second)
+
+	v.SetDefault("database.driver", "sqlite")
+	v.SetDefault("database.host", "localhost")
+	v.SetDefault("database.dbname", "grouter")
+	v.SetDefault("database.ssl_mode", "disable")
+	v.SetDefault("database.log_level", "warn")
+	v.SetDefault("database.max_open_conns", 25)
+	v.SetDefault("database.max_idle_conns", 5)
+	v.SetDefault("database.conn_max_lifetime", time.Hour)
+	v.SetDefault("database.replica_policy", "random")
+
+	v.SetDefault("webhooks.workers", 4)
+	v.SetDefault("webhooks.queue_size", 1000)
+
+	v.SetDefault("archive.backend", "database")
+	v.SetDefault("archive.batch_size", 100)
+	v.SetDefault("archive.flush_interval", 5*time.Second)
+	v.SetDefault("archive.write_timeout", 30*time.Second)
+	v.SetDefault("archive.retention_interval", time.Hour)
+
+	v.SetDefault("slo.window", 24*time.Hour)
+	v.SetDefault("slo.evaluation_interval", 30*time.Second)
+
+	v.SetDefault("mqtt.clean_session", true)
+	v.SetDefault("mqtt.keep_alive", 30*time.Second)
+	v.SetDefault("mqtt.connect_timeout", 10*time.Second)
+	v.SetDefault("mqtt.publish_timeout", 5*time.Second)
+
+	v.SetDefault("profiling.subject", "grouter.control.profile")
+	v.SetDefault("profiling.bucket", "profiles")
+	v.SetDefault("profiling.ttl", 7*24*time.Hour)
+	v.SetDefault("profiling.default_duration", 30*time.Second)
+	v.SetDefault("profiling.max_duration", 2*time.Minute)
+
+	v.SetDefault("health.timeout", 5*time.Second)
+
+	v.SetDefault("cache.store", "memory")
+	v.SetDefault("cache.default_ttl", 5*time.Minute)
+	v.SetDefault("cache.max_entries", 10000)
+	v.SetDefault("cache.redis.addr", "localhost:6379")
+	v.SetDefault("cache.redis.prefix", "cache:")
+
+	v.SetDefault("secrets.provider", SecretProviderEnv)
+	v.SetDefault("secrets.timeout", defaultSecretTimeout)
+
+	v.SetDefault("remote.bucket", defaultRemoteBucket)
+	v.SetDefault("remote.refresh_interval", defaultRemoteRefresh)
+	v.SetDefault("remote.timeout", defaultRemoteTimeout)
+
+	v.SetDefault("tenancy.header", "X-Tenant-ID")
+	v.SetDefault("tenancy.claim", "tenant_id")
+}
+
+// setWebDefaults registers the web server defaults, matching web.DefaultConfig
+func setWebDefaults(v *viper.Viper) {
+	v.SetDefault("web.port", 8080)
+	v.SetDefault("web.mode", "release")
+	v.SetDefault("web.read_timeout", 10*time.Second)
+	v.SetDefault("web.write_timeout", 10*time.Second)
+	v.SetDefault("web.shutdown_timeout", 5*time.Second)
+	v.SetDefault("web.metrics.enabled", true)
+	v.SetDefault("web.http2.enabled", true)
+	v.SetDefault("web.logging.enabled", true)
+
+	v.SetDefault("web.tls.acme.cache_dir", "acme-cache")
+	v.SetDefault("web.tls.acme.http_addr", ":80")
+
+	v.SetDefault("web.cors.allowed_origins", []string{"*"})
+	v.SetDefault("web.cors.allowed_methods", []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"})
+	v.SetDefault("web.cors.allowed_headers", []string{"Origin", "Content-Type", "Authorization", "Accept"})
+	v.SetDefault("web.cors.exposed_headers", []string{"Content-Length"})
+	v.SetDefault("web.cors.max_age", 12*60*60)
+
+	v.SetDefault("web.security.xss_protection", "1; mode=block")
+	v.SetDefault("web.security.content_type_nosniff", "nosniff")
+	v.SetDefault("web.security.x_frame_options", "DENY")
+	v.SetDefault("web.security.hsts_max_age", 365*24*60*60)
+	v.SetDefault("web.security.content_security_policy", "default-src 'self'")
+	v.SetDefault("web.security.referrer_policy", "strict-origin-when-cross-origin")
+
+	v.SetDefault("web.rate_limit.enabled", true)
+	v.SetDefault("web.rate_limit.requests_per_second", 100)
+	v.SetDefault("web.rate_limit.burst", 200)
+	v.SetDefault("web.rate_limit.key", "ip")
+	v.SetDefault("web.rate_limit.store", "memory")
+	v.SetDefault("web.rate_limit.redis.addr", "localhost:6379")
+	v.SetDefault("web.rate_limit.redis.prefix", "ratelimit:")
+
+	v.SetDefault("web.limits.max_body_size", 1<<20)
+	v.SetDefault("web.limits.read_timeout", 10*time.Second)
+	v.SetDefault("web.limits.handler_timeout", 30*time.Second)
+	v.SetDefault("web.load_shedding.interval", time.Second)
+	v.SetDefault("web.load_shedding.retry_after", time.Second)
+
+	v.SetDefault("web.compression.encodings", []string{"br", "gzip", "deflate"})
+	v.SetDefault("web.compression.min_size", 1024)
+	v.SetDefault("web.compression.content_types", []string{"application/json", "text/", "application/javascript", "application/xml"})
+
+	v.SetDefault("web.session.cookie_name", "grouter_session")
+	v.SetDefault("web.session.store", "cookie")
+	v.SetDefault("web.session.idle_timeout", 30*time.Minute)
+	v.SetDefault("web.session.absolute_timeout", 24*time.Hour)
+	v.SetDefault("web.session.path", "/")
+	v.SetDefault("web.session.secure", true)
+	v.SetDefault("web.session.same_site", "lax")
+	v.SetDefault("web.session.redis.addr", "localhost:6379")
+	v.SetDefault("web.session.redis.prefix", "session:")
+
+	v.SetDefault("web.idempotency.header", "Idempotency-Key")
+	v.SetDefault("web.idempotency.ttl", 24*time.Hour)
+	v.SetDefault("web.idempotency.lock_timeout", time.Minute)
+	v.SetDefault("web.idempotency.methods", []string{"POST", "PATCH"})
+	v.SetDefault("web.idempotency.max_body_size", 1<<20)
+	v.SetDefault("web.idempotency.store", "memory")
+	v.SetDefault("web.idempotency.redis.addr", "localhost:6379")
+	v.SetDefault("web.idempotency.redis.prefix", "idempotency:")
+
+	v.SetDefault("web.response_cache.ttl", time.Minute)
+	v.SetDefault("web.response_cache.max_ttl", time.Hour)
+	v.SetDefault("web.response_cache.vary_headers", []string{"Accept", "Accept-Encoding"})
+	v.SetDefault("web.response_cache.max_body_size", 1<<20)
+	v.SetDefault("web.response_cache.key_prefix", "httpcache:")
+
+	v.SetDefault("web.swagger.enabled", true)
+	v.SetDefault("web.swagger.path", "/swagger")
+	v.SetDefault("web.profiling.path", "/debug/pprof")
+
+	v.SetDefault("web.openapi.path", "/openapi.json")
+	v.SetDefault("web.openapi.title", "gRouter API")
+	v.SetDefault("web.openapi.version", "1.0")
+	v.SetDefault("web.openapi.merge_swagger", true)
+
+	v.SetDefault("web.auth.mode", "required")
+	v.SetDefault("web.auth.algorithms", []string{"RS256"})
+	v.SetDefault("web.auth.roles_claim", "roles")
+	v.SetDefault("web.auth.api_key_header", "X-API-Key")
+
+	v.SetDefault("web.sse.path", "/events")
+	v.SetDefault("web.sse.buffer_size", 100)
+	v.SetDefault("web.sse.client_buffer_size", 64)
+	v.SetDefault("web.sse.keep_alive", 15*time.Second)
+
+	v.SetDefault("web.nats_gateway.timeout", 5*time.Second)
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDefault(t *testing.T) {
+	cfg := Default()
+
+	if cfg.Log.Level != "info" || cfg.Log.Format != "json" {
+		t.Errorf("Unexpected log defaults: %+v", cfg.Log)
+	}
+	if cfg.Web.Port != 8080 || cfg.Web.ReadTimeout != 10*time.Second || cfg.Web.Mode != "release" {
+		t.Errorf("Unexpected web defaults: port=%d read=%v mode=%q", cfg.Web.Port, cfg.Web.ReadTimeout, cfg.Web.Mode)
+	}
+	if !reflect.DeepEqual(cfg.Web.Idempotency.Methods, []string{"POST", "PATCH"}) {
+		t.Errorf("Unexpected idempotency methods: %v", cfg.Web.Idempotency.Methods)
+	}
+	if cfg.GRPC.Port != 9090 || !cfg.GRPC.Reflection {
+		t.Errorf("Unexpected grpc defaults: %+v", cfg.GRPC)
+	}
+	if cfg.NATS.URL != "nats://localhost:4222" || cfg.NATS.ReconnectWait != 2*time.Second {
+		t.Errorf("Unexpected nats defaults: url=%q wait=%v", cfg.NATS.URL, cfg.NATS.ReconnectWait)
+	}
+	if cfg.Database.MaxOpenConns != 25 || cfg.Database.ConnMaxLifetime != time.Hour {
+		t.Errorf("Unexpected database defaults: %+v", cfg.Database)
+	}
+	if cfg.Health.Timeout != 5*time.Second || cfg.Cache.MaxEntries != 10000 {
+		t.Errorf("Unexpected health/cache defaults: %v, %d", cfg.Health.Timeout, cfg.Cache.MaxEntries)
+	}
+	if cfg.Tenancy.Header != "X-Tenant-ID" || cfg.Tenancy.Claim != "tenant_id" {
+		t.Errorf("Unexpected tenancy defaults: %+v", cfg.Tenancy)
+	}
+
+	// Optional features stay disabled
+	if cfg.Web.Enabled || cfg.NATS.Enabled || cfg.GRPC.Enabled || cfg.Tracing.Enabled || cfg.Cache.Enabled {
+		t.Error("Optional features should be disabled by default")
+	}
+	// and empty values with a meaning keep it
+	if cfg.Web.Metrics.Path != "" || cfg.Web.ResponseCache.InvalidationSubject != "" {
+		t.Error("Settings with fallbacks should have no default")
+	}
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	resetConfig()
+
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	configContent := `
+app:
+  name: "test-app"
+web:
+  port: 9000
+  cors:
+    allowed_methods: ["GET"]
+`
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+	os.Args = []string{"test", "--config", configFile}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	// The file overrides the defaults it sets and keeps the others
+	if cfg.Web.Port != 9000 || cfg.Web.WriteTimeout != 10*time.Second {
+		t.Errorf("Unexpected web config: port=%d write=%v", cfg.Web.Port, cfg.Web.WriteTimeout)
+	}
+	if !reflect.DeepEqual(cfg.Web.CORS.AllowedMethods, []string{"GET"}) {
+		t.Errorf("Expected file list to replace the default, got %v", cfg.Web.CORS.AllowedMethods)
+	}
+	if cfg.Log.Level != "info" {
+		t.Errorf("Expected default log level, got %q", cfg.Log.Level)
+	}
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// readConfig reads the config file and merges its overlays over it
+func (l *Loader) readConfig() error {
+	if err := l.v.ReadInConfig(); err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	return l.mergeOverlays()
+}
+
+// overlayFiles returns the overlays of the config file, in merge order: the
+// environment overlay next to it (config.<app.environment>.yaml), if it
+// exists, then the files given with --config-overlay
+func (l *Loader) overlayFiles() []string {
+	var files []string
+	if base := l.v.ConfigFileUsed(); base != "" {
+		if env := l.v.GetString("app.environment"); env != "" {
+			ext := filepath.Ext(base)
+			file := strings.TrimSuffix(base, ext) + "." + env + ext
+			if _, err := os.Stat(file); err == nil {
+				files = append(files, file)
+			}
+		}
+	}
+	return append(files, l.v.GetStringSlice("config-overlay")...)
+}
+
+// mergeOverlays merges the overlays over the settings read so far. Maps merge
+// key by key; any other value, including a list, replaces the previous one.
+func (l *Loader) mergeOverlays() error {
+	for _, file := range l.overlayFiles() {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read config overlay: %w", err)
+		}
+		if err := l.mergeDocument(data, strings.TrimPrefix(filepath.Ext(file), ".")); err != nil {
+			return fmt.Errorf("failed to merge config overlay %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// mergeDocument parses a configuration document and merges it over the
+// current settings
+func (l *Loader) mergeDocument(data []byte, format string) error {
+	v := viper.New()
+	v.SetConfigType(format)
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("failed to parse config: %w", err)
+	}
+	return l.v.MergeConfigMap(v.AllSettings())
+}
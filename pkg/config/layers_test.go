@@ -0,0 +1,95 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeLayer(t *testing.T, file, content string) {
+	t.Helper()
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", file, err)
+	}
+}
+
+func TestLoad_Layers(t *testing.T) {
+	resetConfig()
+
+	dir := t.TempDir()
+	base := filepath.Join(dir, "config.yaml")
+	writeLayer(t, base, `
+app:
+  name: "base"
+  version: "1.0.0"
+  environment: "prod"
+log:
+  level: "debug"
+web:
+  port: 8080
+  cors:
+    allowed_origins: ["http://localhost"]
+`)
+	writeLayer(t, filepath.Join(dir, "config.prod.yaml"), `
+log:
+  level: "warn"
+web:
+  port: 80
+  cors:
+    allowed_origins: ["https://example.com"]
+`)
+	writeLayer(t, filepath.Join(dir, "config.staging.yaml"), `
+log:
+  level: "error"
+`)
+	local := filepath.Join(dir, "local.json")
+	writeLayer(t, local, `{"web": {"port": 8443}}`)
+
+	os.Args = []string{"test", "--config", base, "--config-overlay", local}
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+
+	// base < config.prod.yaml < --config-overlay
+	if cfg.Log.Level != "warn" {
+		t.Errorf("Expected environment overlay log level, got %q", cfg.Log.Level)
+	}
+	if cfg.Web.Port != 8443 {
+		t.Errorf("Expected explicit overlay port, got %d", cfg.Web.Port)
+	}
+	if cfg.App.Name != "base" || cfg.App.Version != "1.0.0" {
+		t.Errorf("Expected base values to be kept, got %+v", cfg.App)
+	}
+	if !reflect.DeepEqual(cfg.Web.CORS.AllowedOrigins, []string{"https://example.com"}) {
+		t.Errorf("Expected overlay list to replace the base list, got %v", cfg.Web.CORS.AllowedOrigins)
+	}
+
+	// The environment variable selects another overlay
+	resetConfig()
+	t.Setenv("GROUTER_APP_ENVIRONMENT", "staging")
+	os.Args = []string{"test", "--config", base}
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.Log.Level != "error" || cfg.Web.Port != 8080 {
+		t.Errorf("Expected staging overlay, got level=%q port=%d", cfg.Log.Level, cfg.Web.Port)
+	}
+}
+
+func TestLoad_MissingOverlay(t *testing.T) {
+	resetConfig()
+
+	dir := t.TempDir()
+	base := filepath.Join(dir, "config.yaml")
+	writeLayer(t, base, "app:\n  name: base\n")
+
+	os.Args = []string{"test", "--config", base, "--config-overlay", filepath.Join(dir, "missing.yaml")}
+	_, err := Load()
+	if err == nil || !strings.Contains(err.Error(), "overlay") {
+		t.Errorf("Expected overlay error, got %v", err)
+	}
+}
@@ -0,0 +1,277 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Loader loads and watches the configuration of one service. Loaders keep
+// their settings and flags to themselves, so several can run in one process.
+type Loader struct {
+	v     *viper.Viper
+	flags *pflag.FlagSet
+	args  []string
+
+	cfg atomic.Pointer[Config]
+
+	// mu serializes reloads from the file watcher and the remote store
+	mu sync.Mutex
+	// remote is the store of the loaded configuration, nil without one
+	remote *remoteLoader
+}
+
+// LoaderOption configures a Loader
+type LoaderOption func(*Loader)
+
+// WithArgs parses args instead of the command line arguments
+func WithArgs(args []string) LoaderOption {
+	return func(l *Loader) {
+		l.args = args
+	}
+}
+
+// WithViper keeps the settings in v instead of a new viper instance
+func WithViper(v *viper.Viper) LoaderOption {
+	return func(l *Loader) {
+		l.v = v
+	}
+}
+
+// WithFlagSet defines the flags on fs instead of a new flag set
+func WithFlagSet(fs *pflag.FlagSet) LoaderOption {
+	return func(l *Loader) {
+		l.flags = fs
+	}
+}
+
+// NewLoader creates a loader reading the command line arguments
+func NewLoader(opts ...LoaderOption) *Loader {
+	l := &Loader{args: os.Args[1:]}
+	for _, opt := range opts {
+		opt(l)
+	}
+	if l.v == nil {
+		l.v = viper.New()
+	}
+	if l.flags == nil {
+		l.flags = pflag.NewFlagSet(os.Args[0], pflag.ExitOnError)
+	}
+	return l
+}
+
+// Load loads the configuration from file, environment, and flags. With
+// --validate-config, it reports the result and exits instead of returning.
+func (l *Loader) Load() (*Config, error) {
+	cfg, err := l.load()
+	if l.v.GetBool("validate-config") {
+		exit(l.reportValidation(err))
+	}
+	return cfg, err
+}
+
+// Config returns the last loaded configuration, nil before Load
+func (l *Loader) Config() *Config {
+	return l.cfg.Load()
+}
+
+// Close stops the refresh of the remote store
+func (l *Loader) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.remote == nil {
+		return
+	}
+	close(l.remote.stop)
+	l.remote.source.Close()
+	l.remote = nil
+}
+
+// reportValidation prints the result of --validate-config and returns the
+// exit code
+func (l *Loader) reportValidation(err error) int {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Configuration %s is valid\n", l.v.ConfigFileUsed())
+	return 0
+}
+
+func (l *Loader) load() (*Config, error) {
+	// Define command-line flags
+	l.flags.String("config", "configs/config.yaml", "Path to configuration file")
+	l.flags.String("log-level", "", "Log level (debug, info, warn, error)")
+	l.flags.String("nats-url", "", "NATS server URL")
+	l.flags.StringSlice("config-overlay", nil, "Config files merged over the config file, in order")
+	l.flags.Bool("validate-config", false, "Validate the configuration and exit")
+	if err := l.flags.Parse(l.args); err != nil {
+		return nil, fmt.Errorf("failed to parse flags: %w", err)
+	}
+
+	// Bind flags to viper
+	if err := l.v.BindPFlags(l.flags); err != nil {
+		return nil, fmt.Errorf("failed to bind flags: %w", err)
+	}
+
+	// Set config file
+	configFile := l.v.GetString("config")
+	l.v.SetConfigFile(configFile)
+
+	// Enable environment variable support
+	l.v.SetEnvPrefix("GROUTER")
+	l.v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	l.v.AutomaticEnv()
+
+	// Read the defaults, the config file and its overlays
+	setDefaults(l.v)
+	if err := l.readConfig(); err != nil {
+		return nil, err
+	}
+
+	// Merge the document of the remote store, if any, over the file
+	if err := l.loadRemote(); err != nil {
+		return nil, err
+	}
+
+	// Unmarshal into config struct
+	var cfg Config
+	if err := l.unmarshal(&cfg); err != nil {
+		return nil, err
+	}
+
+	// Override with command-line flags if provided
+	l.overrideFlags(&cfg)
+
+	// Validate configuration
+	if err := validate(&cfg); err != nil {
+		return nil, fmt.Errorf("config validation failed: %w", err)
+	}
+
+	l.cfg.Store(&cfg)
+	return &cfg, nil
+}
+
+// overrideFlags applies the --log-level and --nats-url flags, if given
+func (l *Loader) overrideFlags(cfg *Config) {
+	if logLevel := l.v.GetString("log-level"); logLevel != "" {
+		cfg.Log.Level = logLevel
+	}
+	if natsURL := l.v.GetString("nats-url"); natsURL != "" {
+		// The flag replaces the whole server pool
+		cfg.NATS.URL = natsURL
+		cfg.NATS.URLs = nil
+	}
+}
+
+// Watch watches the configuration file and the remote store for changes and
+// reloads
+func (l *Loader) Watch(callback func(*Config)) {
+	l.v.OnConfigChange(func(e fsnotify.Event) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		// The file was re-read, so the overlays and the remote document must
+		// be merged again
+		if err := l.mergeOverlays(); err != nil {
+			fmt.Printf("Error merging config overlays: %v\n", err)
+			return
+		}
+		if l.remote != nil && l.remote.data != nil {
+			if err := l.remote.merge(l.remote.data); err != nil {
+				fmt.Printf("Error merging remote config: %v\n", err)
+				return
+			}
+		}
+		l.reload(callback)
+	})
+	l.v.WatchConfig()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.remote != nil {
+		l.remote.watch(callback)
+	}
+}
+
+// Reload re-reads the config file and its overlays, merges the last document
+// of the remote store over them and returns the new configuration, unless it
+// is invalid
+func (l *Loader) Reload() (*Config, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.readConfig(); err != nil {
+		return nil, err
+	}
+	if l.remote != nil && l.remote.data != nil {
+		if err := l.remote.merge(l.remote.data); err != nil {
+			return nil, fmt.Errorf("failed to merge remote config: %w", err)
+		}
+	}
+	var cfg Config
+	if err := l.unmarshal(&cfg); err != nil {
+		return nil, fmt.Errorf("failed to decode config: %w", err)
+	}
+	l.overrideFlags(&cfg)
+	if err := validate(&cfg); err != nil {
+		return nil, fmt.Errorf("config validation failed: %w", err)
+	}
+	l.cfg.Store(&cfg)
+	return &cfg, nil
+}
+
+// reload decodes and validates the current settings and publishes them.
+// Callers hold mu.
+func (l *Loader) reload(callback func(*Config)) bool {
+	var cfg Config
+	if err := l.unmarshal(&cfg); err != nil {
+		fmt.Printf("Error reloading config: %v\n", err)
+		return false
+	}
+	if err := validate(&cfg); err != nil {
+		fmt.Printf("Config validation failed after reload: %v\n", err)
+		return false
+	}
+	l.cfg.Store(&cfg)
+	if callback != nil {
+		callback(&cfg)
+	}
+	return true
+}
+
+// decodeHook returns the decode hooks of the configuration, replacing
+// ${secret:...} placeholders with the values of the providers configured in
+// the secrets section
+func (l *Loader) decodeHook() (viper.DecoderConfigOption, error) {
+	var secrets SecretsConfig
+	if err := l.v.UnmarshalKey("secrets", &secrets); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal secrets config: %w", err)
+	}
+	resolver := NewSecretResolver(secrets)
+
+	// Keep the default hooks of viper after the secret hook, so that
+	// durations and lists can come from secrets too
+	return viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		resolver.DecodeHook(),
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToWeakSliceHookFunc(","),
+	)), nil
+}
+
+// unmarshal decodes the configuration
+func (l *Loader) unmarshal(cfg *Config) error {
+	hook, err := l.decodeHook()
+	if err != nil {
+		return err
+	}
+	if err := l.v.Unmarshal(cfg, hook); err != nil {
+		return fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+	return nil
+}
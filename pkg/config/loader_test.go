@@ -0,0 +1,91 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func writeLoaderConfig(t *testing.T, name string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	content := "app:\n  name: " + name + "\nlog:\n  level: info\n"
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+	return file
+}
+
+func TestLoader_Independent(t *testing.T) {
+	resetConfig()
+
+	first := NewLoader(WithArgs([]string{"--config", writeLoaderConfig(t, "first")}))
+	second := NewLoader(WithArgs([]string{"--config", writeLoaderConfig(t, "second"), "--log-level", "debug"}))
+
+	cfg1, err := first.Load()
+	if err != nil {
+		t.Fatalf("first Load() error = %v", err)
+	}
+	cfg2, err := second.Load()
+	if err != nil {
+		t.Fatalf("second Load() error = %v", err)
+	}
+
+	if cfg1.App.Name != "first" || cfg1.Log.Level != "info" {
+		t.Errorf("first config = %q/%q, want first/info", cfg1.App.Name, cfg1.Log.Level)
+	}
+	if cfg2.App.Name != "second" || cfg2.Log.Level != "debug" {
+		t.Errorf("second config = %q/%q, want second/debug", cfg2.App.Name, cfg2.Log.Level)
+	}
+	if first.Config() != cfg1 || second.Config() != cfg2 {
+		t.Error("Config() should return the loaded config")
+	}
+
+	// The package-level state is untouched
+	if Get() != nil {
+		t.Error("Get() should not return the config of a Loader")
+	}
+	if viper.IsSet("app.name") {
+		t.Error("the global viper instance should not hold the settings of a Loader")
+	}
+}
+
+func TestLoader_ConfigBeforeLoad(t *testing.T) {
+	if NewLoader().Config() != nil {
+		t.Error("Config() should be nil before Load")
+	}
+}
+
+func TestLoader_Reload(t *testing.T) {
+	file := writeLoaderConfig(t, "orders")
+	l := NewLoader(WithArgs([]string{"--config", file, "--nats-url", "nats://flag:4222"}))
+	if _, err := l.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if err := os.WriteFile(file, []byte("app:\n  name: orders\nlog:\n  level: debug\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := l.Reload()
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if cfg.Log.Level != "debug" || l.Config() != cfg {
+		t.Errorf("Reload() level = %q, want the new config", cfg.Log.Level)
+	}
+	if cfg.NATS.URL != "nats://flag:4222" {
+		t.Errorf("Reload() nats url = %q, want the flag", cfg.NATS.URL)
+	}
+
+	if err := os.WriteFile(file, []byte("app:\n  name: orders\nlog:\n  level: verbose\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Reload(); err == nil {
+		t.Error("Reload() should reject an invalid config")
+	}
+	if l.Config() != cfg {
+		t.Error("an invalid reload should keep the config")
+	}
+}
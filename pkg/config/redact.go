@@ -0,0 +1,149 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// RedactedValue replaces the secrets in the output of Redacted
+const RedactedValue = "******"
+
+// sensitiveKeys are the settings whose values are secrets
+var sensitiveKeys = map[string]bool{
+	"password":    true,
+	"token":       true,
+	"secret":      true,
+	"secrets":     true,
+	"private_key": true,
+}
+
+// keyLists are the lists whose elements hold their secret under "key", e.g.
+// the encryption keys and the API keys
+var keyLists = map[string]bool{
+	"keys":     true,
+	"api_keys": true,
+}
+
+// Redacted returns cfg as a document keyed like the configuration file, with
+// passwords, tokens, secrets and keys replaced by RedactedValue and the
+// passwords of URLs redacted. Durations are rendered as strings.
+func Redacted(cfg *Config) map[string]any {
+	if cfg == nil {
+		return map[string]any{}
+	}
+	doc, _ := redact(reflect.ValueOf(*cfg), "").(map[string]any)
+	return doc
+}
+
+// redact converts v, the value of the setting key, for Redacted
+func redact(v reflect.Value, key string) any {
+	if !v.IsValid() {
+		return nil
+	}
+	if d, ok := v.Interface().(time.Duration); ok {
+		return d.String()
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return redact(v.Elem(), key)
+	case reflect.Struct:
+		doc := make(map[string]any, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(field.Name)
+			}
+			doc[name] = redactField(v.Field(i), name, key)
+		}
+		return doc
+	case reflect.Map:
+		doc := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			name := fmt.Sprint(iter.Key().Interface())
+			doc[name] = redactField(iter.Value(), name, key)
+		}
+		return doc
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return []any{}
+		}
+		items := make([]any, v.Len())
+		for i := range items {
+			items[i] = redact(v.Index(i), key)
+		}
+		return items
+	case reflect.String:
+		return redactURL(v.String())
+	default:
+		return v.Interface()
+	}
+}
+
+// redactField converts the setting name of the section parent, the elements
+// of a list taking the name of the list
+func redactField(v reflect.Value, name, parent string) any {
+	if sensitiveKeys[name] || (name == "key" && keyLists[parent]) {
+		if masked, ok := mask(v); ok {
+			return masked
+		}
+	}
+	return redact(v, name)
+}
+
+// mask replaces a secret string, or the strings of a list, by RedactedValue;
+// empty values are kept to show the secret is unset. Sections are not
+// masked but walked.
+func mask(v reflect.Value) (any, bool) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, true
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.String:
+		if v.Len() == 0 {
+			return "", true
+		}
+		return RedactedValue, true
+	case reflect.Slice, reflect.Array:
+		items := make([]any, v.Len())
+		for i := range items {
+			item, ok := mask(v.Index(i))
+			if !ok {
+				return nil, false
+			}
+			items[i] = item
+		}
+		return items, true
+	default:
+		return nil, false
+	}
+}
+
+// redactURL redacts the password of s when it is a URL
+func redactURL(s string) string {
+	if !strings.Contains(s, "://") {
+		return s
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,230 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Remote configuration providers
+const (
+	RemoteProviderConsul = "consul"
+	RemoteProviderEtcd   = "etcd"
+	RemoteProviderNATS   = "nats"
+)
+
+const (
+	defaultRemoteRefresh = 30 * time.Second
+	defaultRemoteTimeout = 5 * time.Second
+	defaultRemoteBucket  = "config"
+)
+
+// RemoteSource fetches the configuration document from a central store
+type RemoteSource interface {
+	// Fetch returns the document stored under the configured key
+	Fetch(ctx context.Context) ([]byte, error)
+	Close() error
+}
+
+// NewRemoteSource creates the source of cfg.Provider
+func NewRemoteSource(cfg RemoteConfig) (RemoteSource, error) {
+	switch cfg.Provider {
+	case RemoteProviderConsul:
+		return NewConsulSource(cfg), nil
+	case RemoteProviderEtcd:
+		return NewEtcdSource(cfg), nil
+	case RemoteProviderNATS:
+		return NewNATSKVSource(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown remote config provider %q", cfg.Provider)
+	}
+}
+
+// remoteLoader merges the document of a remote store over the settings of
+// the local file and refreshes it
+type remoteLoader struct {
+	loader *Loader
+	cfg    RemoteConfig
+	source RemoteSource
+
+	// data is the applied document, nil while the store was never reached
+	data []byte
+	// last is the latest fetched document, which may have been rejected
+	last []byte
+
+	watchOnce sync.Once
+	stop      chan struct{}
+}
+
+// loadRemote merges the document of the store configured in the remote
+// section over the file settings. When the store is unreachable, the cache
+// file is used instead, then the local file alone unless remote.required is
+// set.
+func (l *Loader) loadRemote() error {
+	l.Close()
+
+	hook, err := l.decodeHook()
+	if err != nil {
+		return err
+	}
+	var cfg RemoteConfig
+	if err := l.v.UnmarshalKey("remote", &cfg, hook); err != nil {
+		return fmt.Errorf("failed to unmarshal remote config: %w", err)
+	}
+	if cfg.Provider == "" {
+		return nil
+	}
+	cfg = l.remoteDefaults(cfg)
+
+	source, err := NewRemoteSource(cfg)
+	if err != nil {
+		return err
+	}
+	r := &remoteLoader{loader: l, cfg: cfg, source: source, stop: make(chan struct{})}
+
+	data, err := r.fetch()
+	if err == nil {
+		r.cache(data)
+	} else if cached, cerr := os.ReadFile(cfg.CacheFile); cfg.CacheFile != "" && cerr == nil {
+		fmt.Printf("Remote config unavailable, using cached copy: %v\n", err)
+		data = cached
+	} else if cfg.Required {
+		source.Close()
+		return fmt.Errorf("failed to fetch remote config: %w", err)
+	} else {
+		fmt.Printf("Remote config unavailable, using local file: %v\n", err)
+	}
+
+	if data != nil {
+		if err := r.merge(data); err != nil {
+			source.Close()
+			return err
+		}
+		r.data, r.last = data, data
+	}
+
+	l.mu.Lock()
+	l.remote = r
+	l.mu.Unlock()
+	return nil
+}
+
+func (l *Loader) remoteDefaults(cfg RemoteConfig) RemoteConfig {
+	if cfg.Provider == RemoteProviderNATS {
+		if cfg.Endpoint == "" {
+			// The whole server pool of the nats section
+			servers := append([]string{l.v.GetString("nats.url")}, l.v.GetStringSlice("nats.urls")...)
+			cfg.Endpoint = strings.Join(slices.DeleteFunc(servers, func(s string) bool { return s == "" }), ",")
+		}
+		if cfg.Bucket == "" {
+			cfg.Bucket = defaultRemoteBucket
+		}
+	}
+	if cfg.Format == "" {
+		ext := strings.TrimPrefix(filepath.Ext(cfg.Key), ".")
+		if slices.Contains(viper.SupportedExts, ext) {
+			cfg.Format = ext
+		} else {
+			cfg.Format = "yaml"
+		}
+	}
+	if cfg.RefreshInterval == 0 {
+		cfg.RefreshInterval = defaultRemoteRefresh
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultRemoteTimeout
+	}
+	return cfg
+}
+
+func (l *remoteLoader) fetch() ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), l.cfg.Timeout)
+	defer cancel()
+	return l.source.Fetch(ctx)
+}
+
+// cache keeps the last fetched document for restarts while the store is down
+func (l *remoteLoader) cache(data []byte) {
+	if l.cfg.CacheFile == "" {
+		return
+	}
+	if err := os.WriteFile(l.cfg.CacheFile, data, 0600); err != nil {
+		fmt.Printf("Error caching remote config: %v\n", err)
+	}
+}
+
+// merge parses data and merges it over the current settings
+func (l *remoteLoader) merge(data []byte) error {
+	if err := l.loader.mergeDocument(data, l.cfg.Format); err != nil {
+		return fmt.Errorf("failed to merge remote config: %w", err)
+	}
+	return nil
+}
+
+// watch polls the store every refresh interval
+func (l *remoteLoader) watch(callback func(*Config)) {
+	if l.cfg.RefreshInterval < 0 {
+		return
+	}
+	l.watchOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(l.cfg.RefreshInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-l.stop:
+					return
+				case <-ticker.C:
+					l.refresh(callback)
+				}
+			}
+		}()
+	})
+}
+
+// refresh reloads the configuration when the remote document changed
+func (l *remoteLoader) refresh(callback func(*Config)) {
+	data, err := l.fetch()
+	if err != nil {
+		fmt.Printf("Error refreshing remote config: %v\n", err)
+		return
+	}
+
+	l.loader.mu.Lock()
+	defer l.loader.mu.Unlock()
+	if bytes.Equal(data, l.last) {
+		return
+	}
+	l.last = data
+
+	if err := l.apply(data); err != nil {
+		fmt.Printf("Error applying remote config: %v\n", err)
+	} else if l.loader.reload(callback) {
+		l.data = data
+		l.cache(data)
+		return
+	}
+	// Restore the settings of the last applied document
+	if err := l.apply(l.data); err != nil {
+		fmt.Printf("Error restoring remote config: %v\n", err)
+	}
+}
+
+// apply re-reads the local files and merges data over them
+func (l *remoteLoader) apply(data []byte) error {
+	if err := l.loader.readConfig(); err != nil {
+		return err
+	}
+	if data == nil {
+		return nil
+	}
+	return l.merge(data)
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// ConsulSource reads the configuration document from the Consul KV store
+type ConsulSource struct {
+	endpoint string
+	key      string
+	token    string
+	client   *http.Client
+}
+
+// NewConsulSource creates a Consul source. The endpoint defaults to
+// CONSUL_HTTP_ADDR, then http://localhost:8500.
+func NewConsulSource(cfg RemoteConfig) *ConsulSource {
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = os.Getenv("CONSUL_HTTP_ADDR")
+	}
+	if endpoint == "" {
+		endpoint = "http://localhost:8500"
+	}
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "http://" + endpoint
+	}
+	token := cfg.Token
+	if token == "" {
+		token = os.Getenv("CONSUL_HTTP_TOKEN")
+	}
+	return &ConsulSource{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		key:      strings.TrimLeft(cfg.Key, "/"),
+		token:    token,
+		client:   &http.Client{},
+	}
+}
+
+// Fetch returns the raw value of the key
+func (s *ConsulSource) Fetch(ctx context.Context) ([]byte, error) {
+	u := s.endpoint + "/v1/kv/" + (&url.URL{Path: s.key}).EscapedPath() + "?raw"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.token != "" {
+		req.Header.Set("X-Consul-Token", s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from consul: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("consul key %s not found", s.key)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("consul returned %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// Close implements RemoteSource
+func (s *ConsulSource) Close() error {
+	return nil
+}
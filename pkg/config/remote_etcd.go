@@ -0,0 +1,84 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// EtcdSource reads the configuration document from etcd through its v3 JSON
+// gateway
+type EtcdSource struct {
+	endpoint string
+	key      string
+	token    string
+	client   *http.Client
+}
+
+// NewEtcdSource creates an etcd source. The endpoint defaults to
+// http://localhost:2379.
+func NewEtcdSource(cfg RemoteConfig) *EtcdSource {
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "http://localhost:2379"
+	}
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "http://" + endpoint
+	}
+	return &EtcdSource{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		key:      cfg.Key,
+		token:    cfg.Token,
+		client:   &http.Client{},
+	}
+}
+
+// Fetch returns the value of the key
+func (s *EtcdSource) Fetch(ctx context.Context) ([]byte, error) {
+	// The gateway encodes keys and values as base64, which encoding/json
+	// applies to byte slices
+	body, err := json.Marshal(struct {
+		Key []byte `json:"key"`
+	}{Key: []byte(s.key)})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint+"/v3/kv/range", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from etcd: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("etcd returned %s", resp.Status)
+	}
+
+	var out struct {
+		Kvs []struct {
+			Value []byte `json:"value"`
+		} `json:"kvs"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("failed to decode etcd response: %w", err)
+	}
+	if len(out.Kvs) == 0 {
+		return nil, fmt.Errorf("etcd key %s not found", s.key)
+	}
+	return out.Kvs[0].Value, nil
+}
+
+// Close implements RemoteSource
+func (s *EtcdSource) Close() error {
+	return nil
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATSKVSource reads the configuration document from a JetStream key-value
+// bucket
+type NATSKVSource struct {
+	cfg RemoteConfig
+
+	mu sync.Mutex
+	nc *nats.Conn
+	kv nats.KeyValue
+}
+
+// NewNATSKVSource creates a NATS KV source. The connection is opened on the
+// first fetch and kept for the refreshes.
+func NewNATSKVSource(cfg RemoteConfig) *NATSKVSource {
+	return &NATSKVSource{cfg: cfg}
+}
+
+// Fetch returns the value of the key. The key-value API takes no context, so
+// requests are bounded by the configured timeout instead.
+func (s *NATSKVSource) Fetch(ctx context.Context) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	kv, err := s.bucket()
+	if err != nil {
+		return nil, err
+	}
+	entry, err := kv.Get(s.cfg.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s from bucket %s: %w", s.cfg.Key, s.cfg.Bucket, err)
+	}
+	return entry.Value(), nil
+}
+
+func (s *NATSKVSource) bucket() (nats.KeyValue, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.kv != nil {
+		return s.kv, nil
+	}
+
+	opts := []nats.Option{nats.Name("grouter-config"), nats.Timeout(s.cfg.Timeout)}
+	if s.cfg.Token != "" {
+		opts = append(opts, nats.Token(s.cfg.Token))
+	}
+	nc, err := nats.Connect(s.cfg.Endpoint, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to nats: %w", err)
+	}
+	js, err := nc.JetStream(nats.MaxWait(s.cfg.Timeout))
+	if err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("failed to create jetstream context: %w", err)
+	}
+	kv, err := js.KeyValue(s.cfg.Bucket)
+	if err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("failed to bind bucket %s: %w", s.cfg.Bucket, err)
+	}
+	s.nc, s.kv = nc, kv
+	return kv, nil
+}
+
+// Close closes the connection
+func (s *NATSKVSource) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.nc != nil {
+		s.nc.Close()
+		s.nc, s.kv = nil, nil
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func TestNATSKVSource(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	s, err := server.NewServer(&server.Options{Port: -1, JetStream: true, StoreDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	go s.Start()
+	defer s.Shutdown()
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server not ready")
+	}
+
+	nc, err := nats.Connect(s.ClientURL())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer nc.Close()
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatalf("Failed to create jetstream context: %v", err)
+	}
+	kv, err := js.CreateKeyValue(&nats.KeyValueConfig{Bucket: defaultRemoteBucket})
+	if err != nil {
+		t.Fatalf("Failed to create bucket: %v", err)
+	}
+	if _, err := kv.Put("grouter", []byte("app:\n  name: kv-app\n")); err != nil {
+		t.Fatalf("Failed to put config: %v", err)
+	}
+
+	src := NewNATSKVSource(NewLoader().remoteDefaults(RemoteConfig{Provider: RemoteProviderNATS, Endpoint: s.ClientURL(), Key: "grouter"}))
+	defer src.Close()
+	data, err := src.Fetch(t.Context())
+	if err != nil {
+		t.Fatalf("Fetch error: %v", err)
+	}
+	if string(data) != "app:\n  name: kv-app\n" {
+		t.Errorf("Unexpected document %q", data)
+	}
+
+	// The connection is reused for refreshes
+	if _, err := kv.Put("grouter", []byte("app:\n  name: kv-app-2\n")); err != nil {
+		t.Fatalf("Failed to put config: %v", err)
+	}
+	data, err = src.Fetch(t.Context())
+	if err != nil || string(data) != "app:\n  name: kv-app-2\n" {
+		t.Errorf("Unexpected refresh %q, %v", data, err)
+	}
+
+	missing := NewNATSKVSource(NewLoader().remoteDefaults(RemoteConfig{Provider: RemoteProviderNATS, Endpoint: s.ClientURL(), Key: "other"}))
+	defer missing.Close()
+	if _, err := missing.Fetch(t.Context()); err == nil {
+		t.Error("Expected error for missing key")
+	}
+}
@@ -0,0 +1,237 @@
+package config
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeConsul serves one key of the Consul KV API
+type fakeConsul struct {
+	mu    sync.Mutex
+	value string
+	down  bool
+}
+
+func (f *fakeConsul) set(value string, down bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.value, f.down = value, down
+}
+
+func (f *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case f.down:
+		w.WriteHeader(http.StatusServiceUnavailable)
+	case r.URL.Path != "/v1/kv/grouter/config" || !r.URL.Query().Has("raw"):
+		w.WriteHeader(http.StatusNotFound)
+	default:
+		w.Write([]byte(f.value))
+	}
+}
+
+func writeRemoteConfig(t *testing.T, remote string) string {
+	t.Helper()
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+app:
+  name: "local-app"
+  version: "1.0.0"
+log:
+  level: "info"
+remote:
+` + remote
+	if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+	os.Args = []string{"test", "--config", configFile}
+	return configFile
+}
+
+func TestLoad_RemoteConsul(t *testing.T) {
+	resetConfig()
+	defer resetConfig()
+
+	consul := &fakeConsul{value: "app:\n  name: remote-app\nlog:\n  level: debug\n"}
+	srv := httptest.NewServer(consul)
+	defer srv.Close()
+	writeRemoteConfig(t, `
+  provider: consul
+  endpoint: "`+srv.URL+`"
+  key: grouter/config
+`)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	// Remote values override the file, which keeps the others
+	if cfg.App.Name != "remote-app" || cfg.Log.Level != "debug" {
+		t.Errorf("Expected remote values, got name=%q level=%q", cfg.App.Name, cfg.Log.Level)
+	}
+	if cfg.App.Version != "1.0.0" {
+		t.Errorf("Expected local version, got %q", cfg.App.Version)
+	}
+}
+
+func TestLoad_RemoteFallback(t *testing.T) {
+	resetConfig()
+	defer resetConfig()
+
+	consul := &fakeConsul{value: "app:\n  name: remote-app\n"}
+	srv := httptest.NewServer(consul)
+	defer srv.Close()
+	cacheFile := filepath.Join(t.TempDir(), "remote.yaml")
+	writeRemoteConfig(t, `
+  provider: consul
+  endpoint: "`+srv.URL+`"
+  key: grouter/config
+  cache_file: "`+cacheFile+`"
+`)
+
+	// A successful load caches the document
+	if _, err := Load(); err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+
+	// which is used while the store is down
+	consul.set("", true)
+	resetConfig()
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.App.Name != "remote-app" {
+		t.Errorf("Expected cached remote name, got %q", cfg.App.Name)
+	}
+
+	// Without a cached copy the local file is used
+	os.Remove(cacheFile)
+	resetConfig()
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.App.Name != "local-app" {
+		t.Errorf("Expected local name, got %q", cfg.App.Name)
+	}
+}
+
+func TestLoad_RemoteRequired(t *testing.T) {
+	resetConfig()
+	defer resetConfig()
+
+	srv := httptest.NewServer(&fakeConsul{down: true})
+	defer srv.Close()
+	writeRemoteConfig(t, `
+  provider: consul
+  endpoint: "`+srv.URL+`"
+  key: grouter/config
+  required: true
+`)
+
+	_, err := Load()
+	if err == nil || !strings.Contains(err.Error(), "remote config") {
+		t.Errorf("Expected remote config error, got %v", err)
+	}
+}
+
+func TestWatch_RemoteRefresh(t *testing.T) {
+	resetConfig()
+	defer resetConfig()
+
+	consul := &fakeConsul{value: "app:\n  name: v1\n"}
+	srv := httptest.NewServer(consul)
+	defer srv.Close()
+	writeRemoteConfig(t, `
+  provider: consul
+  endpoint: "`+srv.URL+`"
+  key: grouter/config
+  refresh_interval: 20ms
+`)
+
+	if _, err := Load(); err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	changes := make(chan *Config, 10)
+	Watch(func(cfg *Config) { changes <- cfg })
+
+	// An invalid document is rejected and the previous one kept
+	consul.set("log:\n  level: verbose\n", false)
+	time.Sleep(100 * time.Millisecond)
+	if len(changes) != 0 {
+		t.Fatal("Invalid remote config should not be published")
+	}
+
+	consul.set("app:\n  name: v2\n", false)
+	select {
+	case cfg := <-changes:
+		if cfg.App.Name != "v2" || cfg.App.Version != "1.0.0" {
+			t.Errorf("Unexpected reloaded config: name=%q version=%q", cfg.App.Name, cfg.App.Version)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for the remote change")
+	}
+	if Get().App.Name != "v2" {
+		t.Errorf("Expected global config to be updated, got %q", Get().App.Name)
+	}
+}
+
+func TestEtcdSource(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Key []byte `json:"key"`
+		}
+		if r.URL.Path != "/v3/kv/range" || json.NewDecoder(r.Body).Decode(&req) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if string(req.Key) != "/grouter/config" {
+			w.Write([]byte(`{"header":{}}`))
+			return
+		}
+		value := base64.StdEncoding.EncodeToString([]byte(`{"app":{"name":"etcd-app"}}`))
+		w.Write([]byte(`{"kvs":[{"value":"` + value + `"}]}`))
+	}))
+	defer srv.Close()
+
+	s := NewEtcdSource(RemoteConfig{Endpoint: srv.URL, Key: "/grouter/config"})
+	data, err := s.Fetch(t.Context())
+	if err != nil {
+		t.Fatalf("Fetch error: %v", err)
+	}
+	if string(data) != `{"app":{"name":"etcd-app"}}` {
+		t.Errorf("Unexpected document %q", data)
+	}
+
+	s = NewEtcdSource(RemoteConfig{Endpoint: srv.URL, Key: "/missing"})
+	if _, err := s.Fetch(t.Context()); err == nil {
+		t.Error("Expected error for missing key")
+	}
+}
+
+func TestRemoteDefaults(t *testing.T) {
+	l := NewLoader()
+	cfg := l.remoteDefaults(RemoteConfig{Provider: RemoteProviderNATS, Key: "app.json"})
+	if cfg.Bucket != defaultRemoteBucket || cfg.Format != "json" {
+		t.Errorf("Unexpected defaults: bucket=%q format=%q", cfg.Bucket, cfg.Format)
+	}
+	if cfg.RefreshInterval != defaultRemoteRefresh || cfg.Timeout != defaultRemoteTimeout {
+		t.Errorf("Unexpected intervals: refresh=%v timeout=%v", cfg.RefreshInterval, cfg.Timeout)
+	}
+	if cfg := l.remoteDefaults(RemoteConfig{Key: "grouter/config"}); cfg.Format != "yaml" {
+		t.Errorf("Expected yaml format, got %q", cfg.Format)
+	}
+	if _, err := NewRemoteSource(RemoteConfig{Provider: "zookeeper"}); err == nil {
+		t.Error("Expected error for unknown provider")
+	}
+}
@@ -0,0 +1,222 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-viper/mapstructure/v2"
+)
+
+// Secret providers
+const (
+	SecretProviderEnv   = "env"
+	SecretProviderFile  = "file"
+	SecretProviderVault = "vault"
+	SecretProviderAWS   = "aws"
+)
+
+// defaultSecretTimeout bounds the fetch of a single secret
+const defaultSecretTimeout = 10 * time.Second
+
+// secretPattern matches ${secret:path} and ${secret:path#key}
+var secretPattern = regexp.MustCompile(`\$\{secret:([^}#]+)(?:#([^}]*))?\}`)
+
+// SecretProvider fetches secrets from a secret store
+type SecretProvider interface {
+	// GetSecret returns the raw value of the secret at path. Structured
+	// secrets are returned as a JSON object.
+	GetSecret(ctx context.Context, path string) (string, error)
+}
+
+// SecretResolver replaces ${secret:path#key} placeholders in configuration
+// values. The path may start with a provider name ("vault:secret/data/db")
+// to override the default provider. With a key, the secret must be a JSON
+// object and the value of the key is used.
+type SecretResolver struct {
+	providers map[string]SecretProvider
+	def       string
+	timeout   time.Duration
+
+	mu sync.Mutex
+	// cache holds the secrets fetched so far, so that a secret referenced by
+	// several keys is fetched once
+	cache map[string]string
+}
+
+// NewSecretResolver creates a resolver with the env, file, vault and aws
+// providers configured by cfg
+func NewSecretResolver(cfg SecretsConfig) *SecretResolver {
+	def := cfg.Provider
+	if def == "" {
+		def = SecretProviderEnv
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultSecretTimeout
+	}
+	r := &SecretResolver{
+		providers: make(map[string]SecretProvider),
+		def:       def,
+		timeout:   timeout,
+		cache:     make(map[string]string),
+	}
+	r.Register(SecretProviderEnv, EnvSecretProvider{})
+	r.Register(SecretProviderFile, FileSecretProvider{Dir: cfg.File.Dir})
+	r.Register(SecretProviderVault, NewVaultSecretProvider(cfg.Vault))
+	r.Register(SecretProviderAWS, NewAWSSecretProvider(cfg.AWS))
+	return r
+}
+
+// Register adds or replaces the provider called name
+func (r *SecretResolver) Register(name string, p SecretProvider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.providers[name] = p
+}
+
+// Resolve replaces the secret placeholders in s
+func (r *SecretResolver) Resolve(ctx context.Context, s string) (string, error) {
+	matches := secretPattern.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s, nil
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		path := s[m[2]:m[3]]
+		key := ""
+		if m[4] >= 0 {
+			key = s[m[4]:m[5]]
+		}
+		value, err := r.lookup(ctx, path, key)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(s[last:m[0]])
+		b.WriteString(value)
+		last = m[1]
+	}
+	b.WriteString(s[last:])
+	return b.String(), nil
+}
+
+// DecodeHook resolves the placeholders of string values while unmarshaling
+func (r *SecretResolver) DecodeHook() mapstructure.DecodeHookFunc {
+	return func(f reflect.Type, _ reflect.Type, data interface{}) (interface{}, error) {
+		if f.Kind() != reflect.String {
+			return data, nil
+		}
+		return r.Resolve(context.Background(), data.(string))
+	}
+}
+
+func (r *SecretResolver) lookup(ctx context.Context, path, key string) (string, error) {
+	name := r.def
+	if prefix, rest, ok := strings.Cut(path, ":"); ok {
+		r.mu.Lock()
+		_, known := r.providers[prefix]
+		r.mu.Unlock()
+		if known {
+			name, path = prefix, rest
+		}
+	}
+
+	raw, err := r.fetch(ctx, name, path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve secret %s:%s: %w", name, path, err)
+	}
+	if key == "" {
+		return raw, nil
+	}
+	value, err := secretKey(raw, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve secret %s:%s#%s: %w", name, path, key, err)
+	}
+	return value, nil
+}
+
+func (r *SecretResolver) fetch(ctx context.Context, name, path string) (string, error) {
+	r.mu.Lock()
+	p, ok := r.providers[name]
+	raw, cached := r.cache[name+":"+path]
+	r.mu.Unlock()
+	if !ok {
+		return "", fmt.Errorf("unknown secret provider %q", name)
+	}
+	if cached {
+		return raw, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	raw, err := p.GetSecret(ctx, path)
+	if err != nil {
+		return "", err
+	}
+
+	r.mu.Lock()
+	r.cache[name+":"+path] = raw
+	r.mu.Unlock()
+	return raw, nil
+}
+
+// secretKey returns the value of key in a secret holding a JSON object
+func secretKey(raw, key string) (string, error) {
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &data); err != nil {
+		return "", fmt.Errorf("secret is not a JSON object: %w", err)
+	}
+	v, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("key %q not found", key)
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// EnvSecretProvider reads secrets from environment variables named by the path
+type EnvSecretProvider struct{}
+
+// GetSecret returns the value of the environment variable path
+func (EnvSecretProvider) GetSecret(_ context.Context, path string) (string, error) {
+	value, ok := os.LookupEnv(path)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", path)
+	}
+	return value, nil
+}
+
+// FileSecretProvider reads secrets from files, e.g. Docker or Kubernetes
+// secrets mounted under /run/secrets
+type FileSecretProvider struct {
+	// Dir resolves relative paths (default the working directory)
+	Dir string
+}
+
+// GetSecret returns the content of the file at path, without the trailing
+// newline
+func (p FileSecretProvider) GetSecret(_ context.Context, path string) (string, error) {
+	if !filepath.IsAbs(path) && p.Dir != "" {
+		path = filepath.Join(p.Dir, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+// SecretsManagerAPI is the part of the AWS Secrets Manager client used by
+// AWSSecretProvider
+type SecretsManagerAPI interface {
+	GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+}
+
+// AWSSecretProvider reads secrets from AWS Secrets Manager. Paths are secret
+// names or ARNs.
+type AWSSecretProvider struct {
+	cfg SecretsAWSConfig
+
+	// The client is created on first use, so that configurations without AWS
+	// secrets never load the AWS credentials
+	once   sync.Once
+	client SecretsManagerAPI
+	err    error
+}
+
+// NewAWSSecretProvider creates a provider using the default AWS credential
+// chain
+func NewAWSSecretProvider(cfg SecretsAWSConfig) *AWSSecretProvider {
+	return &AWSSecretProvider{cfg: cfg}
+}
+
+// NewAWSSecretProviderWithClient creates a provider using client
+func NewAWSSecretProviderWithClient(client SecretsManagerAPI) *AWSSecretProvider {
+	p := &AWSSecretProvider{client: client}
+	p.once.Do(func() {})
+	return p
+}
+
+// GetSecret returns the string value of the secret
+func (p *AWSSecretProvider) GetSecret(ctx context.Context, path string) (string, error) {
+	p.once.Do(func() {
+		p.client, p.err = p.newClient(ctx)
+	})
+	if p.err != nil {
+		return "", p.err
+	}
+
+	out, err := p.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(path)})
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret value: %w", err)
+	}
+	if out.SecretString != nil {
+		return *out.SecretString, nil
+	}
+	return string(out.SecretBinary), nil
+}
+
+func (p *AWSSecretProvider) newClient(ctx context.Context) (SecretsManagerAPI, error) {
+	var opts []func(*awsconfig.LoadOptions) error
+	if p.cfg.Region != "" {
+		opts = append(opts, awsconfig.WithRegion(p.cfg.Region))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load aws config: %w", err)
+	}
+	return secretsmanager.NewFromConfig(awsCfg, func(o *secretsmanager.Options) {
+		if p.cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(p.cfg.Endpoint)
+		}
+	}), nil
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+type fakeSecretsManager struct {
+	secrets map[string]string
+}
+
+func (f *fakeSecretsManager) GetSecretValue(_ context.Context, in *secretsmanager.GetSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	v, ok := f.secrets[aws.ToString(in.SecretId)]
+	if !ok {
+		return nil, errors.New("ResourceNotFoundException")
+	}
+	return &secretsmanager.GetSecretValueOutput{SecretString: aws.String(v)}, nil
+}
+
+func TestAWSSecretProvider(t *testing.T) {
+	r := NewSecretResolver(SecretsConfig{})
+	r.Register(SecretProviderAWS, NewAWSSecretProviderWithClient(&fakeSecretsManager{secrets: map[string]string{
+		"prod/db":  `{"username":"app","password":"awspw"}`,
+		"prod/key": "plain",
+	}}))
+
+	for in, want := range map[string]string{
+		"${secret:aws:prod/db#password}": "awspw",
+		"${secret:aws:prod/key}":         "plain",
+	} {
+		got, err := r.Resolve(context.Background(), in)
+		if err != nil {
+			t.Fatalf("Resolve(%q) error: %v", in, err)
+		}
+		if got != want {
+			t.Errorf("Resolve(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	if _, err := r.Resolve(context.Background(), "${secret:aws:prod/missing}"); err == nil {
+		t.Error("Expected error for missing secret")
+	}
+}
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// staticProvider serves secrets from a map and counts the fetches
+type staticProvider struct {
+	secrets map[string]string
+	calls   int
+}
+
+func (p *staticProvider) GetSecret(_ context.Context, path string) (string, error) {
+	p.calls++
+	v, ok := p.secrets[path]
+	if !ok {
+		return "", errors.New("not found")
+	}
+	return v, nil
+}
+
+func TestSecretResolver_Resolve(t *testing.T) {
+	t.Setenv("TEST_SECRET_PASSWORD", "s3cret")
+
+	r := NewSecretResolver(SecretsConfig{})
+	p := &staticProvider{secrets: map[string]string{
+		"db": `{"user":"app","password":"pw","port":5432}`,
+	}}
+	r.Register("static", p)
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"plain", "plain"},
+		{"${secret:TEST_SECRET_PASSWORD}", "s3cret"},
+		{"${secret:env:TEST_SECRET_PASSWORD}", "s3cret"},
+		{"${secret:static:db#user}", "app"},
+		{"${secret:static:db#port}", "5432"},
+		{"postgres://${secret:static:db#user}:${secret:static:db#password}@host/db", "postgres://app:pw@host/db"},
+	}
+	for _, tt := range tests {
+		got, err := r.Resolve(context.Background(), tt.in)
+		if err != nil {
+			t.Fatalf("Resolve(%q) error: %v", tt.in, err)
+		}
+		if got != tt.want {
+			t.Errorf("Resolve(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	// Each secret is fetched once
+	if p.calls != 1 {
+		t.Errorf("Expected 1 fetch, got %d", p.calls)
+	}
+}
+
+func TestSecretResolver_Errors(t *testing.T) {
+	r := NewSecretResolver(SecretsConfig{})
+	r.Register("static", &staticProvider{secrets: map[string]string{"plain": "value"}})
+
+	for _, in := range []string{
+		"${secret:GROUTER_TEST_UNSET_SECRET}",
+		"${secret:static:missing}",
+		"${secret:static:plain#key}",
+	} {
+		if _, err := r.Resolve(context.Background(), in); err == nil {
+			t.Errorf("Resolve(%q) expected error", in)
+		}
+	}
+
+	r = NewSecretResolver(SecretsConfig{Provider: "unknown"})
+	if _, err := r.Resolve(context.Background(), "${secret:x}"); err == nil {
+		t.Error("Expected error for unknown provider")
+	}
+}
+
+func TestFileSecretProvider(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "nats_password"), []byte("filepw\n"), 0600); err != nil {
+		t.Fatalf("Failed to write secret: %v", err)
+	}
+
+	p := FileSecretProvider{Dir: dir}
+	got, err := p.GetSecret(context.Background(), "nats_password")
+	if err != nil {
+		t.Fatalf("GetSecret error: %v", err)
+	}
+	if got != "filepw" {
+		t.Errorf("Expected filepw, got %q", got)
+	}
+
+	got, err = p.GetSecret(context.Background(), filepath.Join(dir, "nats_password"))
+	if err != nil || got != "filepw" {
+		t.Errorf("Expected absolute path to be read, got %q, %v", got, err)
+	}
+}
+
+func TestLoad_Secrets(t *testing.T) {
+	resetConfig()
+
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "db.json"), []byte(`{"password":"dbpw"}`), 0600); err != nil {
+		t.Fatalf("Failed to write secret: %v", err)
+	}
+	t.Setenv("TEST_NATS_PASSWORD", "natspw")
+	t.Setenv("TEST_RECONNECT_WAIT", "3s")
+
+	configFile := filepath.Join(tmpDir, "config.yaml")
+	configContent := `
+app:
+  name: "test-app"
+
+nats:
+  url: "nats://localhost:4222"
+  password: "${secret:TEST_NATS_PASSWORD}"
+  reconnect_wait: "${secret:TEST_RECONNECT_WAIT}"
+
+log:
+  level: "info"
+
+database:
+  password: "${secret:file:db.json#password}"
+
+secrets:
+  provider: env
+  file:
+    dir: "` + tmpDir + `"
+`
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+	os.Args = []string{"test", "--config", configFile}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.NATS.Password != "natspw" {
+		t.Errorf("Expected NATS password natspw, got %q", cfg.NATS.Password)
+	}
+	if cfg.NATS.ReconnectWait != 3*time.Second {
+		t.Errorf("Expected reconnect wait 3s, got %v", cfg.NATS.ReconnectWait)
+	}
+	if cfg.Database.Password != "dbpw" {
+		t.Errorf("Expected database password dbpw, got %q", cfg.Database.Password)
+	}
+}
+
+func TestLoad_MissingSecret(t *testing.T) {
+	resetConfig()
+
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "config.yaml")
+	configContent := `
+app:
+  name: "test-app"
+nats:
+  password: "${secret:GROUTER_TEST_UNSET_SECRET}"
+log:
+  level: "info"
+`
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+	os.Args = []string{"test", "--config", configFile}
+
+	_, err := Load()
+	if err == nil || !strings.Contains(err.Error(), "GROUTER_TEST_UNSET_SECRET") {
+		t.Errorf("Expected error naming the missing secret, got %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// VaultSecretProvider reads secrets from the HashiCorp Vault HTTP API. Paths
+// are API paths below /v1, e.g. "secret/data/nats" for a KV v2 mount.
+type VaultSecretProvider struct {
+	address   string
+	token     string
+	namespace string
+	client    *http.Client
+}
+
+// NewVaultSecretProvider creates a Vault provider. The address and token
+// default to the VAULT_ADDR and VAULT_TOKEN environment variables.
+func NewVaultSecretProvider(cfg SecretsVaultConfig) *VaultSecretProvider {
+	address := cfg.Address
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	token := cfg.Token
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	namespace := cfg.Namespace
+	if namespace == "" {
+		namespace = os.Getenv("VAULT_NAMESPACE")
+	}
+	return &VaultSecretProvider{
+		address:   strings.TrimRight(address, "/"),
+		token:     token,
+		namespace: namespace,
+		client:    &http.Client{},
+	}
+}
+
+// GetSecret returns the data of the secret at path as a JSON object
+func (p *VaultSecretProvider) GetSecret(ctx context.Context, path string) (string, error) {
+	if p.address == "" {
+		return "", fmt.Errorf("vault address is not configured")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.address+"/v1/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", p.token)
+	if p.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.namespace)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to read from vault: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("failed to read vault response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault returned %s", resp.Status)
+	}
+
+	var secret struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return "", fmt.Errorf("failed to decode vault response: %w", err)
+	}
+	// KV v2 nests the values under data.data, next to data.metadata
+	data, nested := secret.Data["data"]
+	if _, versioned := secret.Data["metadata"]; nested && versioned {
+		return string(data), nil
+	}
+	out, err := json.Marshal(secret.Data)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
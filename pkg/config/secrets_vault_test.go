@@ -0,0 +1,73 @@
+package config
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newVaultServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/nats":
+			w.Write([]byte(`{"data":{"data":{"password":"vaultpw"},"metadata":{"version":1}}}`))
+		case "/v1/kv/db":
+			w.Write([]byte(`{"data":{"password":"kv1pw"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestVaultSecretProvider(t *testing.T) {
+	srv := newVaultServer(t)
+	r := NewSecretResolver(SecretsConfig{
+		Provider: SecretProviderVault,
+		Vault:    SecretsVaultConfig{Address: srv.URL, Token: "root"},
+	})
+
+	// KV v2 and KV v1 mounts
+	for in, want := range map[string]string{
+		"${secret:secret/data/nats#password}": "vaultpw",
+		"${secret:kv/db#password}":            "kv1pw",
+	} {
+		got, err := r.Resolve(context.Background(), in)
+		if err != nil {
+			t.Fatalf("Resolve(%q) error: %v", in, err)
+		}
+		if got != want {
+			t.Errorf("Resolve(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	if _, err := r.Resolve(context.Background(), "${secret:secret/data/missing#password}"); err == nil {
+		t.Error("Expected error for missing secret")
+	}
+}
+
+func TestVaultSecretProvider_Env(t *testing.T) {
+	srv := newVaultServer(t)
+	t.Setenv("VAULT_ADDR", srv.URL)
+	t.Setenv("VAULT_TOKEN", "root")
+
+	got, err := NewVaultSecretProvider(SecretsVaultConfig{}).GetSecret(context.Background(), "kv/db")
+	if err != nil {
+		t.Fatalf("GetSecret error: %v", err)
+	}
+	if got != `{"password":"kv1pw"}` {
+		t.Errorf("Unexpected secret %q", got)
+	}
+
+	t.Setenv("VAULT_TOKEN", "wrong")
+	if _, err := NewVaultSecretProvider(SecretsVaultConfig{}).GetSecret(context.Background(), "kv/db"); err == nil {
+		t.Error("Expected error for a rejected token")
+	}
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-viper/mapstructure/v2"
+)
+
+// ServiceValidator is implemented by service configs that check their
+// settings. Validate may return a *ValidationError whose fields are relative
+// to the service section.
+type ServiceValidator interface {
+	Validate() error
+}
+
+// Decode decodes the settings of the service name into target, a pointer to a
+// struct with mapstructure tags, and validates them when target implements
+// ServiceValidator. A missing section leaves target unchanged before
+// validation.
+func (s ServicesConfig) Decode(name string, target any) error {
+	if raw, ok := s[name]; ok && raw != nil {
+		if err := decodeSettings(raw, target); err != nil {
+			return fmt.Errorf("failed to decode config of service %s: %w", name, err)
+		}
+	}
+
+	v, ok := target.(ServiceValidator)
+	if !ok {
+		return nil
+	}
+	err := v.Validate()
+	if err == nil {
+		return nil
+	}
+	// Report the fields with their full key
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		errs := make([]FieldError, len(verr.Errors))
+		for i, fe := range verr.Errors {
+			errs[i] = FieldError{Field: "services." + name + "." + fe.Field, Message: fe.Message}
+		}
+		return &ValidationError{Errors: errs}
+	}
+	return fmt.Errorf("invalid config of service %s: %w", name, err)
+}
+
+// decodeSettings decodes raw, a section of the config, into target, a
+// pointer to a struct with mapstructure tags
+func decodeSettings(raw, target any) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Result:           target,
+		TagName:          "mapstructure",
+		WeaklyTypedInput: true,
+		// Maps of the section replace the default ones instead of being
+		// merged into them
+		ZeroFields: true,
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToWeakSliceHookFunc(","),
+		),
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(raw)
+}
+
+// Decode decodes the settings of the messaging driver name into target, a
+// pointer to its config struct with mapstructure tags. A missing section
+// leaves target unchanged.
+func (d MessagingDrivers) Decode(name string, target any) error {
+	raw, ok := d[name]
+	if !ok || raw == nil {
+		return nil
+	}
+	if err := decodeSettings(raw, target); err != nil {
+		return fmt.Errorf("failed to decode config of messaging driver %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type testServiceConfig struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	Subject  string        `mapstructure:"subject"`
+	Interval time.Duration `mapstructure:"interval"`
+	Tags     []string      `mapstructure:"tags"`
+}
+
+func (c *testServiceConfig) Validate() error {
+	if c.Enabled && c.Subject == "" {
+		return &ValidationError{Errors: []FieldError{{Field: "subject", Message: "is required"}}}
+	}
+	return nil
+}
+
+func TestServicesConfig_Decode(t *testing.T) {
+	services := ServicesConfig{
+		"orders": map[string]interface{}{
+			"enabled":  true,
+			"subject":  "orders",
+			"interval": "5s",
+			"tags":     "a,b",
+		},
+	}
+
+	var cfg testServiceConfig
+	if err := services.Decode("orders", &cfg); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !cfg.Enabled || cfg.Subject != "orders" || cfg.Interval != 5*time.Second {
+		t.Errorf("Decode() = %+v", cfg)
+	}
+	if len(cfg.Tags) != 2 || cfg.Tags[1] != "b" {
+		t.Errorf("Tags = %v, want [a b]", cfg.Tags)
+	}
+}
+
+func TestServicesConfig_Decode_Missing(t *testing.T) {
+	cfg := testServiceConfig{Subject: "default"}
+	services := ServicesConfig{}
+	if err := services.Decode("orders", &cfg); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if cfg.Subject != "default" {
+		t.Errorf("Subject = %q, want the default", cfg.Subject)
+	}
+}
+
+func TestServicesConfig_Decode_Invalid(t *testing.T) {
+	services := ServicesConfig{"orders": map[string]interface{}{"enabled": true}}
+
+	var cfg testServiceConfig
+	err := services.Decode("orders", &cfg)
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Decode() error = %v, want a ValidationError", err)
+	}
+	if verr.Errors[0].Field != "services.orders.subject" {
+		t.Errorf("Field = %q, want services.orders.subject", verr.Errors[0].Field)
+	}
+
+	services = ServicesConfig{"orders": map[string]interface{}{"interval": "soon"}}
+	if err := services.Decode("orders", &cfg); err == nil {
+		t.Error("Decode() should fail on an invalid duration")
+	}
+}
+
+func TestMessagingDrivers_Decode(t *testing.T) {
+	drivers := MessagingDrivers{
+		"redis": map[string]interface{}{"subject": "orders", "interval": "2s"},
+		"bad":   map[string]interface{}{"interval": "soon"},
+	}
+
+	cfg := testServiceConfig{Tags: []string{"default"}}
+	if err := drivers.Decode("redis", &cfg); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if cfg.Subject != "orders" || cfg.Interval != 2*time.Second || len(cfg.Tags) != 1 {
+		t.Errorf("Decode() = %+v", cfg)
+	}
+	if err := drivers.Decode("amqp", &cfg); err != nil || cfg.Subject != "orders" {
+		t.Errorf("Decode() of a missing section = %+v, %v", cfg, err)
+	}
+	if err := drivers.Decode("bad", &cfg); err == nil {
+		t.Error("Decode() of an invalid duration succeeded")
+	}
+}
@@ -4,14 +4,30 @@ import "time"
 
 // Config represents the complete application configuration
 type Config struct {
-	App      AppConfig      `mapstructure:"app"`
-	NATS     NATSConfig     `mapstructure:"nats"`
-	Log      LogConfig      `mapstructure:"log"`
-	Web      WebConfig      `mapstructure:"web"`
-	Tracing  TracingConfig  `mapstructure:"tracing"`
-	Services ServicesConfig `mapstructure:"services"`
-	Database DatabaseConfig `mapstructure:"database"`
-	Metrics  MetricsConfig  `mapstructure:"metrics"`
+	App       AppConfig       `mapstructure:"app"`
+	NATS      NATSConfig      `mapstructure:"nats"`
+	Log       LogConfig       `mapstructure:"log"`
+	Web       WebConfig       `mapstructure:"web"`
+	GRPC      GRPCConfig      `mapstructure:"grpc"`
+	Tracing   TracingConfig   `mapstructure:"tracing"`
+	Services  ServicesConfig  `mapstructure:"services"`
+	Database  DatabaseConfig  `mapstructure:"database"`
+	Metrics   MetricsConfig   `mapstructure:"metrics"`
+	Webhooks  WebhooksConfig  `mapstructure:"webhooks"`
+	Archive   ArchiveConfig   `mapstructure:"archive"`
+	RBAC      RBACConfig      `mapstructure:"rbac"`
+	Profiling ProfilingConfig `mapstructure:"profiling"`
+	Health    HealthConfig    `mapstructure:"health"`
+	Cache     CacheConfig     `mapstructure:"cache"`
+	Secrets   SecretsConfig   `mapstructure:"secrets"`
+	Remote    RemoteConfig    `mapstructure:"remote"`
+	Tenancy   TenancyConfig   `mapstructure:"tenancy"`
+	Chaos     ChaosConfig     `mapstructure:"chaos"`
+	SLO       SLOConfig       `mapstructure:"slo"`
+	EventBus  EventBusConfig  `mapstructure:"event_bus"`
+	Plugins   PluginsConfig   `mapstructure:"plugins"`
+	MQTT      MQTTConfig      `mapstructure:"mqtt"`
+	Messaging MessagingConfig `mapstructure:"messaging"`
 }
 
 // AppConfig holds application-level settings
@@ -23,22 +39,406 @@ type AppConfig struct {
 
 // NATSConfig holds NATS connection settings
 type NATSConfig struct {
-	Enabled           bool          `mapstructure:"enabled"`
-	URL               string        `mapstructure:"url"`
-	MaxReconnects     int           `mapstructure:"max_reconnects"`
-	ReconnectWait     time.Duration `mapstructure:"reconnect_wait"`
-	ConnectionTimeout time.Duration `mapstructure:"connection_timeout"`
-	Token             string        `mapstructure:"token"`
-	Username          string        `mapstructure:"username"`
-	Password          string        `mapstructure:"password"`
-	CredsFile         string        `mapstructure:"creds_file"`
-	UseTLS            bool          `mapstructure:"use_tls"`
-	SkipVerify        bool          `mapstructure:"skip_verify"`
-	CAFile            string        `mapstructure:"ca_file"`
-	CertFile          string        `mapstructure:"cert_file"`
-	KeyFile           string        `mapstructure:"key_file"`
-	Metrics           MetricsConfig `mapstructure:"metrics"`
-	Logging           LoggingConfig `mapstructure:"logging"`
+	Enabled           bool              `mapstructure:"enabled"`
+	URL               string            `mapstructure:"url"`  // server, or comma separated servers
+	URLs              []string          `mapstructure:"urls"` // more servers of the pool, e.g. the cluster members
+	NoRandomize       bool              `mapstructure:"no_randomize"`
+	Embedded          bool              `mapstructure:"embedded"`
+	EmbeddedServer    NATSEmbedded      `mapstructure:"embedded_server"`
+	MaxReconnects     int               `mapstructure:"max_reconnects"`
+	ReconnectWait     time.Duration     `mapstructure:"reconnect_wait"`
+	ConnectionTimeout time.Duration     `mapstructure:"connection_timeout"`
+	Token             string            `mapstructure:"token"`
+	Username          string            `mapstructure:"username"`
+	Password          string            `mapstructure:"password"`
+	CredsFile         string            `mapstructure:"creds_file"`
+	AuthRotation      NATSAuthRotation  `mapstructure:"auth_rotation"`
+	UseTLS            bool              `mapstructure:"use_tls"`
+	SkipVerify        bool              `mapstructure:"skip_verify"`
+	CAFile            string            `mapstructure:"ca_file"`
+	CertFile          string            `mapstructure:"cert_file"`
+	KeyFile           string            `mapstructure:"key_file"`
+	SkipFlush         bool              `mapstructure:"skip_flush"`
+	CoalesceRequests  bool              `mapstructure:"coalesce_requests"`
+	Metrics           NATSMetrics       `mapstructure:"metrics"`
+	Logging           NATSLogging       `mapstructure:"logging"`
+	Signing           NATSSigning       `mapstructure:"signing"`
+	LargePayloads     NATSLargePayloads `mapstructure:"large_payloads"`
+	Compression       NATSCompression   `mapstructure:"compression"`
+	Encryption        NATSEncryption    `mapstructure:"encryption"`
+	Monitoring        NATSMonitoring    `mapstructure:"monitoring"`
+	Quarantine        NATSQuarantine    `mapstructure:"quarantine"`
+	ResponseCache     NATSResponseCache `mapstructure:"response_cache"`
+	LoadShedding      LoadShedding      `mapstructure:"load_shedding"`
+	PreStop           NATSPreStop       `mapstructure:"pre_stop"`
+}
+
+// MessagingConfig selects the transport of the messenger: the NATS server of
+// the nats settings by default, or the driver registered under Driver with
+// messaging.RegisterDriver, e.g. "redis" or "amqp", set up by its settings
+// in Drivers
+type MessagingConfig struct {
+	Driver  string           `mapstructure:"driver"`
+	Drivers MessagingDrivers `mapstructure:"drivers"`
+}
+
+// MessagingDrivers holds the raw settings of the messaging drivers, by name
+type MessagingDrivers map[string]any
+
+// NATSAuthRotation holds the settings of the rotation of the credentials
+// while connected: with watch_creds, a change of the creds file reconnects
+// with it. Reconnections with new credentials are delayed by up to jitter so
+// that the instances sharing them do not reconnect at once.
+type NATSAuthRotation struct {
+	WatchCreds bool          `mapstructure:"watch_creds"`
+	Jitter     time.Duration `mapstructure:"jitter"`
+}
+
+// NATSEmbedded holds the settings of the NATS server run in the process with
+// nats.embedded, used instead of url. It requires the token or username and
+// password of the nats section when set.
+type NATSEmbedded struct {
+	Host      string `mapstructure:"host"`
+	Port      int    `mapstructure:"port"` // -1 for a random port
+	JetStream bool   `mapstructure:"jetstream"`
+	// StoreDir is the JetStream data dir, a temporary directory when empty
+	StoreDir     string        `mapstructure:"store_dir"`
+	ReadyTimeout time.Duration `mapstructure:"ready_timeout"`
+}
+
+// NATSMetrics holds the settings of the NATS metrics middleware. The
+// cardinality settings bound the values of the subject, type and tenant
+// labels.
+type NATSMetrics struct {
+	Enabled     bool                   `mapstructure:"enabled"`
+	Path        string                 `mapstructure:"path"`
+	Cardinality NATSMetricsCardinality `mapstructure:"cardinality"`
+}
+
+// NATSMetricsCardinality bounds the label values of the messaging metrics:
+// subjects are labeled with the first subject template they match or cut
+// to subject_depth tokens, and the values not allowed or past max_values
+// are labeled "other"
+type NATSMetricsCardinality struct {
+	Subjects     []string            `mapstructure:"subjects"`
+	SubjectDepth int                 `mapstructure:"subject_depth"`
+	Allow        map[string][]string `mapstructure:"allow"`
+	MaxValues    int                 `mapstructure:"max_values"`
+}
+
+// NATSLogging holds the settings of the NATS logging middleware. With
+// sampling, successful messages are sampled instead of logged one by one.
+type NATSLogging struct {
+	Enabled  bool              `mapstructure:"enabled"`
+	Sampling LogSamplingConfig `mapstructure:"sampling"`
+}
+
+// NATSQuarantine holds the settings of the quarantine of the JetStream
+// messages failing max_deliveries deliveries
+type NATSQuarantine struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	MaxDeliveries int           `mapstructure:"max_deliveries"`
+	Stream        string        `mapstructure:"stream"`
+	Prefix        string        `mapstructure:"prefix"`
+	MaxAge        time.Duration `mapstructure:"max_age"`
+}
+
+// NATSPreStop holds the settings of the phase run first on Stop, draining
+// the queue group subscriptions for up to grace so that the other members
+// take over their messages. With announce, a leaving event is published
+// first.
+type NATSPreStop struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	Grace    time.Duration `mapstructure:"grace"`
+	Announce bool          `mapstructure:"announce"`
+}
+
+// NATSResponseCache holds the settings of the caching of the replies to
+// requests on subjects (all when empty), kept in the shared cache when
+// enabled and in memory otherwise
+type NATSResponseCache struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	TTL      time.Duration `mapstructure:"ttl"`
+	Subjects []string      `mapstructure:"subjects"`
+}
+
+// NATSMonitoring holds the settings of the JetStream stream and consumer lag
+// metrics and health check. Zero thresholds are not checked.
+type NATSMonitoring struct {
+	Enabled        bool          `mapstructure:"enabled"`
+	Interval       time.Duration `mapstructure:"interval"`
+	Streams        []string      `mapstructure:"streams"`
+	MaxPending     uint64        `mapstructure:"max_pending"`
+	MaxAckPending  int           `mapstructure:"max_ack_pending"`
+	MaxRedelivered int           `mapstructure:"max_redelivered"`
+}
+
+// NATSEncryption holds the envelope data encryption settings
+type NATSEncryption struct {
+	Enabled  bool                `mapstructure:"enabled"`
+	KeyID    string              `mapstructure:"key_id"`
+	Keys     []NATSEncryptionKey `mapstructure:"keys"`
+	Subjects []string            `mapstructure:"subjects"`
+}
+
+// NATSEncryptionKey is a named base64 encoded AES-128, AES-192 or AES-256 key
+type NATSEncryptionKey struct {
+	ID  string `mapstructure:"id"`
+	Key string `mapstructure:"key"`
+}
+
+// NATSCompression holds the settings of the compression of envelope data
+type NATSCompression struct {
+	Enabled   bool   `mapstructure:"enabled"`
+	Algorithm string `mapstructure:"algorithm"`
+	Threshold int    `mapstructure:"threshold"`
+}
+
+// NATSLargePayloads holds the settings of the spillover of envelopes above
+// the server's max payload to a JetStream object store
+type NATSLargePayloads struct {
+	Enabled bool          `mapstructure:"enabled"`
+	Bucket  string        `mapstructure:"bucket"`
+	TTL     time.Duration `mapstructure:"ttl"`
+	MaxSize int64         `mapstructure:"max_size"`
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// NATSSigning holds the envelope signing and verification settings
+type NATSSigning struct {
+	Enabled  bool             `mapstructure:"enabled"`
+	KeyID    string           `mapstructure:"key_id"`
+	Keys     []NATSSigningKey `mapstructure:"keys"`
+	Verify   bool             `mapstructure:"verify"`
+	Subjects []string         `mapstructure:"subjects"`
+	MaxAge   time.Duration    `mapstructure:"max_age"`
+}
+
+// NATSSigningKey is a named HMAC secret or base64 encoded ed25519 key
+type NATSSigningKey struct {
+	ID         string `mapstructure:"id"`
+	Algorithm  string `mapstructure:"algorithm"`
+	Secret     string `mapstructure:"secret"`
+	PublicKey  string `mapstructure:"public_key"`
+	PrivateKey string `mapstructure:"private_key"`
+}
+
+// TenancyConfig holds the multi-tenancy settings: where requests carry their
+// tenant, and how subjects and metrics are scoped by tenant
+type TenancyConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Header and Claim name the request header and the JWT claim holding
+	// the tenant; the claim wins over the header
+	Header string `mapstructure:"header"`
+	Claim  string `mapstructure:"claim"`
+	// Required rejects requests without a tenant
+	Required bool `mapstructure:"required"`
+	// Subjects requires routed subjects of the form
+	// <app.name>.<tenant>.<service>.<op>
+	Subjects bool `mapstructure:"subjects"`
+	// MetricsLabel adds a tenant label to the messaging metrics
+	MetricsLabel bool `mapstructure:"metrics_label"`
+	// SkipPaths are exempt from Required. Unset, the web.auth.skip_paths
+	// are exempt; an empty list exempts none.
+	SkipPaths []string `mapstructure:"skip_paths"`
+}
+
+// ChaosConfig holds the fault injection of chaos experiments. Enabled
+// installs the middlewares and the admin endpoints toggling them; Active
+// injects the faults of Rules from the start.
+type ChaosConfig struct {
+	Enabled bool        `mapstructure:"enabled"`
+	Active  bool        `mapstructure:"active"`
+	Rules   []ChaosRule `mapstructure:"rules"`
+}
+
+// ChaosRule injects Fault into Percent of the requests (http, by path
+// prefix) or messages (nats, by subject pattern) matching Match
+type ChaosRule struct {
+	Target  string        `mapstructure:"target"`
+	Match   string        `mapstructure:"match"`
+	Percent float64       `mapstructure:"percent"`
+	Fault   string        `mapstructure:"fault"`
+	Latency time.Duration `mapstructure:"latency"`
+	Status  int           `mapstructure:"status"`
+}
+
+// SLOConfig holds the service level objectives of the HTTP routes and NATS
+// subjects. Alerts are published on AlertSubject when the error budgets burn
+// fast.
+type SLOConfig struct {
+	Enabled            bool           `mapstructure:"enabled"`
+	Window             time.Duration  `mapstructure:"window"`              // rolling compliance window (default 24h)
+	EvaluationInterval time.Duration  `mapstructure:"evaluation_interval"` // default 30s
+	AlertSubject       string         `mapstructure:"alert_subject"`       // default <app>.slo.alerts; "-" disables the alerts
+	Alerts             []SLOAlertRule `mapstructure:"alerts"`              // default page (1h/5m at 14.4) and ticket (6h/30m at 6)
+	Objectives         []SLOObjective `mapstructure:"objectives"`
+}
+
+// SLOObjective is the fraction Target of good requests (http, by route
+// prefix) or messages (nats, by subject pattern) matching Match. Failures
+// are bad, and so are the events slower than Latency when set.
+type SLOObjective struct {
+	Name    string        `mapstructure:"name"`
+	Kind    string        `mapstructure:"kind"`
+	Match   string        `mapstructure:"match"`
+	Method  string        `mapstructure:"method"`
+	Latency time.Duration `mapstructure:"latency"`
+	Target  float64       `mapstructure:"target"`
+}
+
+// SLOAlertRule fires when the error budget of an objective burns BurnRate
+// times too fast over both windows
+type SLOAlertRule struct {
+	Name        string        `mapstructure:"name"`
+	LongWindow  time.Duration `mapstructure:"long_window"`
+	ShortWindow time.Duration `mapstructure:"short_window"`
+	BurnRate    float64       `mapstructure:"burn_rate"`
+}
+
+// EventBusConfig holds the NATS bridges of the in-process event bus
+type EventBusConfig struct {
+	Bridges []EventBridge `mapstructure:"bridges"`
+}
+
+// EventBridge also publishes the local events of Topic on the NATS Subject
+type EventBridge struct {
+	Topic   string `mapstructure:"topic"`
+	Subject string `mapstructure:"subject"`
+}
+
+// PluginsConfig holds the services loaded at startup: those of the Go
+// plugins of Dir, or those listed by Manifest
+type PluginsConfig struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	Dir      string `mapstructure:"dir"`
+	Manifest string `mapstructure:"manifest"`
+}
+
+// MQTTConfig holds the MQTT broker bridged to NATS and its routes
+type MQTTConfig struct {
+	Enabled        bool          `mapstructure:"enabled"`
+	Broker         string        `mapstructure:"broker"` // tcp://, ssl://, ws:// or wss://
+	ClientID       string        `mapstructure:"client_id"`
+	Username       string        `mapstructure:"username"`
+	Password       string        `mapstructure:"password"`
+	CleanSession   bool          `mapstructure:"clean_session"`
+	KeepAlive      time.Duration `mapstructure:"keep_alive"`
+	ConnectTimeout time.Duration `mapstructure:"connect_timeout"`
+	PublishTimeout time.Duration `mapstructure:"publish_timeout"`
+	Routes         []MQTTRoute   `mapstructure:"routes"`
+}
+
+// MQTTRoute maps the MQTT topics of Topic to the NATS subjects of Subject
+// (inbound), or the reverse (outbound)
+type MQTTRoute struct {
+	Name       string `mapstructure:"name"`
+	Direction  string `mapstructure:"direction"` // inbound, outbound
+	Topic      string `mapstructure:"topic"`
+	Subject    string `mapstructure:"subject"`
+	QoS        int    `mapstructure:"qos"`
+	Type       string `mapstructure:"type"`
+	Retain     bool   `mapstructure:"retain"`
+	Envelope   bool   `mapstructure:"envelope"`
+	QueueGroup string `mapstructure:"queue_group"`
+}
+
+// RBACConfig holds the authorization policy shared by HTTP routes and NATS messages
+type RBACConfig struct {
+	Enabled     bool                `mapstructure:"enabled"`
+	DefaultDeny bool                `mapstructure:"default_deny"`
+	Roles       map[string]RBACRole `mapstructure:"roles"`
+	Sources     map[string][]string `mapstructure:"sources"`
+}
+
+// RBACRole lists the routes and message types a role may access
+type RBACRole struct {
+	Routes   []string `mapstructure:"routes"`
+	Messages []string `mapstructure:"messages"`
+}
+
+// ProfilingConfig holds the on-demand profile capture settings (NATS control
+// subjects scoped by app name and instance, profiles uploaded to a JetStream
+// object store)
+type ProfilingConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Subject string `mapstructure:"subject"`
+	// Broadcast also serves the requests on Subject, received by every
+	// instance of every service on the NATS cluster
+	Broadcast       bool          `mapstructure:"broadcast"`
+	Bucket          string        `mapstructure:"bucket"`
+	TTL             time.Duration `mapstructure:"ttl"`
+	DefaultDuration time.Duration `mapstructure:"default_duration"`
+	MaxDuration     time.Duration `mapstructure:"max_duration"`
+}
+
+// HealthConfig holds the execution settings of the health checks
+type HealthConfig struct {
+	// Timeout bounds each check (default 5s)
+	Timeout time.Duration `mapstructure:"timeout"`
+	// CacheTTL reuses check results for this long. 0 runs the checks on every probe.
+	CacheTTL time.Duration `mapstructure:"cache_ttl"`
+	// RefreshInterval runs the checks in the background; probes then serve
+	// the last results. 0 runs the checks when probed.
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"`
+}
+
+// WebhooksConfig holds the NATS to HTTP webhook forwarder settings
+type WebhooksConfig struct {
+	Enabled   bool            `mapstructure:"enabled"`
+	Workers   int             `mapstructure:"workers"`
+	QueueSize int             `mapstructure:"queue_size"`
+	Targets   []WebhookTarget `mapstructure:"targets"`
+}
+
+// WebhookTarget forwards the envelopes of a subject to a webhook URL
+type WebhookTarget struct {
+	Name         string            `mapstructure:"name"`
+	Subject      string            `mapstructure:"subject"`
+	QueueGroup   string            `mapstructure:"queue_group"`
+	URL          string            `mapstructure:"url"`
+	Secret       string            `mapstructure:"secret"`
+	Headers      map[string]string `mapstructure:"headers"`
+	Timeout      time.Duration     `mapstructure:"timeout"`
+	MaxRetries   int               `mapstructure:"max_retries"`
+	RetryBackoff time.Duration     `mapstructure:"retry_backoff"`
+}
+
+// Archive backends
+const (
+	ArchiveBackendDatabase = "database"
+	ArchiveBackendS3       = "s3"
+)
+
+// ArchiveConfig holds the settings of the archiver, persisting the envelopes
+// of subjects to the database or S3-compatible storage
+type ArchiveConfig struct {
+	Enabled           bool             `mapstructure:"enabled"`
+	Backend           string           `mapstructure:"backend"` // database or s3
+	Subjects          []ArchiveSubject `mapstructure:"subjects"`
+	BatchSize         int              `mapstructure:"batch_size"`
+	FlushInterval     time.Duration    `mapstructure:"flush_interval"`
+	WriteTimeout      time.Duration    `mapstructure:"write_timeout"`
+	Retention         time.Duration    `mapstructure:"retention"` // 0 keeps the records forever
+	RetentionInterval time.Duration    `mapstructure:"retention_interval"`
+	S3                ArchiveS3Config  `mapstructure:"s3"`
+}
+
+// ArchiveSubject is a subject archived, through the JetStream pull consumer
+// durable when set
+type ArchiveSubject struct {
+	Subject    string `mapstructure:"subject"`
+	Durable    string `mapstructure:"durable"`
+	QueueGroup string `mapstructure:"queue_group"`
+}
+
+// ArchiveS3Config holds the bucket of the s3 archive backend
+type ArchiveS3Config struct {
+	Bucket       string `mapstructure:"bucket"`
+	Prefix       string `mapstructure:"prefix"`
+	Region       string `mapstructure:"region"`   // from the AWS environment when empty
+	Endpoint     string `mapstructure:"endpoint"` // e.g. MinIO
+	UsePathStyle bool   `mapstructure:"use_path_style"`
 }
 
 // LoggingConfig holds configuration for logging middleware
@@ -48,40 +448,240 @@ type LoggingConfig struct {
 
 // LogConfig holds logging configuration
 type LogConfig struct {
-	Level      string `mapstructure:"level"`
-	Format     string `mapstructure:"format"` // json or console
-	OutputPath string `mapstructure:"output_path"`
+	Level      string            `mapstructure:"level"`
+	Format     string            `mapstructure:"format"` // json or console
+	OutputPath string            `mapstructure:"output_path"`
+	Rotation   LogRotationConfig `mapstructure:"rotation"` // for a file output_path
+	// Sinks replace output_path with several outputs, each with its own level
+	Sinks    []LogSinkConfig   `mapstructure:"sinks"`
+	Sampling LogSamplingConfig `mapstructure:"sampling"`
+}
+
+// LogSinkConfig is one log output
+type LogSinkConfig struct {
+	Output   string            `mapstructure:"output"` // stdout, stderr or file path
+	Level    string            `mapstructure:"level"`  // defaults to log.level
+	Format   string            `mapstructure:"format"` // defaults to log.format
+	Rotation LogRotationConfig `mapstructure:"rotation"`
+}
+
+// LogRotationConfig holds size-based rotation settings for log files
+type LogRotationConfig struct {
+	Enabled    bool `mapstructure:"enabled"`
+	MaxSizeMB  int  `mapstructure:"max_size_mb"`
+	MaxAgeDays int  `mapstructure:"max_age_days"`
+	MaxBackups int  `mapstructure:"max_backups"`
+	Compress   bool `mapstructure:"compress"`
+	LocalTime  bool `mapstructure:"local_time"`
+}
+
+// LogSamplingConfig limits repeated log entries per tick
+type LogSamplingConfig struct {
+	Enabled    bool          `mapstructure:"enabled"`
+	Initial    int           `mapstructure:"initial"`
+	Thereafter int           `mapstructure:"thereafter"`
+	Tick       time.Duration `mapstructure:"tick"`
 }
 
 // WebConfig holds web server configuration
 type WebConfig struct {
-	Enabled         bool            `mapstructure:"enabled"`
-	Port            int             `mapstructure:"port"`
-	ReadTimeout     time.Duration   `mapstructure:"read_timeout"`
-	WriteTimeout    time.Duration   `mapstructure:"write_timeout"`
-	ShutdownTimeout time.Duration   `mapstructure:"shutdown_timeout"`
-	Mode            string          `mapstructure:"mode"`
-	Metrics         MetricsConfig   `mapstructure:"metrics"`
-	TLS             TLSConfig       `mapstructure:"tls"`
-	CORS            CORSConfig      `mapstructure:"cors"`
-	Security        SecurityConfig  `mapstructure:"security"`
-	RateLimit       RateLimitConfig `mapstructure:"rate_limit"`
-	Swagger         SwaggerConfig   `mapstructure:"swagger"`
-	Logging         LoggingConfig   `mapstructure:"logging"`
-	Auth            AuthConfig      `mapstructure:"auth"`
+	Enabled         bool                `mapstructure:"enabled"`
+	Port            int                 `mapstructure:"port"`
+	ReadTimeout     time.Duration       `mapstructure:"read_timeout"`
+	WriteTimeout    time.Duration       `mapstructure:"write_timeout"`
+	ShutdownTimeout time.Duration       `mapstructure:"shutdown_timeout"`
+	Mode            string              `mapstructure:"mode"`
+	Metrics         MetricsConfig       `mapstructure:"metrics"`
+	TLS             TLSConfig           `mapstructure:"tls"`
+	HTTP2           HTTP2Config         `mapstructure:"http2"`
+	CORS            CORSConfig          `mapstructure:"cors"`
+	Security        SecurityConfig      `mapstructure:"security"`
+	RateLimit       RateLimitConfig     `mapstructure:"rate_limit"`
+	Limits          LimitsConfig        `mapstructure:"limits"`
+	LoadShedding    LoadShedding        `mapstructure:"load_shedding"`
+	Compression     CompressionConfig   `mapstructure:"compression"`
+	Session         SessionConfig       `mapstructure:"session"`
+	Idempotency     IdempotencyConfig   `mapstructure:"idempotency"`
+	ResponseCache   ResponseCacheConfig `mapstructure:"response_cache"`
+	Swagger         SwaggerConfig       `mapstructure:"swagger"`
+	Profiling       WebProfilingConfig  `mapstructure:"profiling"`
+	OpenAPI         WebOpenAPIConfig    `mapstructure:"openapi"`
+	Logging         LoggingConfig       `mapstructure:"logging"`
+	Auth            AuthConfig          `mapstructure:"auth"`
+	SSE             SSEConfig           `mapstructure:"sse"`
+	NATSGateway     NATSGatewayConfig   `mapstructure:"nats_gateway"`
+	Versioning      VersioningConfig    `mapstructure:"versioning"`
+	Validation      ValidationConfig    `mapstructure:"validation"`
+}
+
+// ValidationConfig holds the request validation message settings
+type ValidationConfig struct {
+	Translate bool     `mapstructure:"translate"`
+	Locales   []string `mapstructure:"locales"`
+}
+
+// VersioningConfig holds the lifecycle policy of each API version
+type VersioningConfig struct {
+	Versions map[string]VersionPolicy `mapstructure:"versions"`
+}
+
+// VersionPolicy holds the deprecation settings of an API version
+type VersionPolicy struct {
+	Deprecated bool   `mapstructure:"deprecated"`
+	Sunset     string `mapstructure:"sunset"`
+	Link       string `mapstructure:"link"`
+}
+
+// LimitsConfig holds request body size limits and timeouts
+type LimitsConfig struct {
+	Enabled        bool          `mapstructure:"enabled"`
+	MaxBodySize    int64         `mapstructure:"max_body_size"`
+	ReadTimeout    time.Duration `mapstructure:"read_timeout"`
+	HandlerTimeout time.Duration `mapstructure:"handler_timeout"`
+	Routes         []RouteLimits `mapstructure:"routes"`
+}
+
+// LoadShedding holds the thresholds above which HTTP requests (429) or NATS
+// messages (nak or drop) are shed. Zero thresholds are not checked;
+// max_queue_depth applies to NATS and exclude_paths to HTTP.
+type LoadShedding struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	MaxInFlight   int           `mapstructure:"max_in_flight"`
+	MaxQueueDepth int           `mapstructure:"max_queue_depth"`
+	MaxCPU        float64       `mapstructure:"max_cpu"`
+	MaxMemory     int64         `mapstructure:"max_memory"`
+	Interval      time.Duration `mapstructure:"interval"`
+	RetryAfter    time.Duration `mapstructure:"retry_after"`
+	ExcludePaths  []string      `mapstructure:"exclude_paths"`
+}
+
+// CompressionConfig holds response compression settings
+type CompressionConfig struct {
+	Enabled      bool     `mapstructure:"enabled"`
+	Encodings    []string `mapstructure:"encodings"`
+	Level        int      `mapstructure:"level"`
+	MinSize      int      `mapstructure:"min_size"`
+	ContentTypes []string `mapstructure:"content_types"`
+	ExcludePaths []string `mapstructure:"exclude_paths"`
+}
+
+// RouteLimits overrides the limits for a route group
+type RouteLimits struct {
+	PathPrefix     string        `mapstructure:"path_prefix"`
+	MaxBodySize    int64         `mapstructure:"max_body_size"`
+	ReadTimeout    time.Duration `mapstructure:"read_timeout"`
+	HandlerTimeout time.Duration `mapstructure:"handler_timeout"`
+}
+
+// NATSGatewayConfig holds the HTTP to NATS request/reply gateway settings
+type NATSGatewayConfig struct {
+	Enabled bool               `mapstructure:"enabled"`
+	Timeout time.Duration      `mapstructure:"timeout"`
+	Routes  []NATSGatewayRoute `mapstructure:"routes"`
+}
+
+// NATSGatewayRoute maps an HTTP endpoint to a NATS subject
+type NATSGatewayRoute struct {
+	Method  string        `mapstructure:"method"`
+	Path    string        `mapstructure:"path"`
+	Subject string        `mapstructure:"subject"`
+	Type    string        `mapstructure:"type"`
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// SSEConfig holds the NATS to Server-Sent Events bridge settings
+type SSEConfig struct {
+	Enabled          bool          `mapstructure:"enabled"`
+	Path             string        `mapstructure:"path"`
+	Subject          string        `mapstructure:"subject"`
+	BufferSize       int           `mapstructure:"buffer_size"`
+	ClientBufferSize int           `mapstructure:"client_buffer_size"`
+	KeepAlive        time.Duration `mapstructure:"keep_alive"`
+}
+
+// GRPCConfig holds gRPC server configuration
+type GRPCConfig struct {
+	Enabled         bool          `mapstructure:"enabled"`
+	Port            int           `mapstructure:"port"`
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+	Reflection      bool          `mapstructure:"reflection"`
+	MaxRecvMsgSize  int           `mapstructure:"max_recv_msg_size"`
+	MaxSendMsgSize  int           `mapstructure:"max_send_msg_size"`
+	TLS             TLSConfig     `mapstructure:"tls"`
+	Metrics         MetricsConfig `mapstructure:"metrics"`
+	Logging         LoggingConfig `mapstructure:"logging"`
+	Gateway         GatewayConfig `mapstructure:"gateway"`
+}
+
+// GatewayConfig holds grpc-gateway REST transcoding settings
+type GatewayConfig struct {
+	Enabled    bool          `mapstructure:"enabled"`
+	PathPrefix string        `mapstructure:"path_prefix"`
+	OpenAPI    OpenAPIConfig `mapstructure:"openapi"`
+}
+
+// WebOpenAPIConfig holds settings for the OpenAPI document generated from the
+// registered web routes
+type WebOpenAPIConfig struct {
+	Enabled      bool   `mapstructure:"enabled"`
+	Path         string `mapstructure:"path"`
+	Title        string `mapstructure:"title"`
+	Version      string `mapstructure:"version"`
+	Description  string `mapstructure:"description"`
+	MergeSwagger bool   `mapstructure:"merge_swagger"`
+}
+
+// OpenAPIConfig holds settings for serving generated OpenAPI documents
+type OpenAPIConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Path    string `mapstructure:"path"`
+	Dir     string `mapstructure:"dir"`
 }
 
 type AuthConfig struct {
-	Enabled  bool   `mapstructure:"enabled"`
-	Issuer   string `mapstructure:"issuer"`
-	Audience string `mapstructure:"audience"`
+	Enabled      bool           `mapstructure:"enabled"`
+	Mode         string         `mapstructure:"mode"`
+	Issuer       string         `mapstructure:"issuer"`
+	Audience     string         `mapstructure:"audience"`
+	JWKSURL      string         `mapstructure:"jwks_url"`
+	Algorithms   []string       `mapstructure:"algorithms"`
+	RolesClaim   string         `mapstructure:"roles_claim"`
+	APIKeyHeader string         `mapstructure:"api_key_header"`
+	APIKeys      []APIKeyConfig `mapstructure:"api_keys"`
+	SkipPaths    []string       `mapstructure:"skip_paths"`
+}
+
+// APIKeyConfig holds a static API key and the identity it maps to
+type APIKeyConfig struct {
+	Name  string   `mapstructure:"name"`
+	Key   string   `mapstructure:"key"`
+	Roles []string `mapstructure:"roles"`
 }
 
 // TLSConfig holds configuration for TLS
 type TLSConfig struct {
-	Enabled  bool   `mapstructure:"enabled"`
-	CertFile string `mapstructure:"cert_file"`
-	KeyFile  string `mapstructure:"key_file"`
+	Enabled  bool       `mapstructure:"enabled"`
+	CertFile string     `mapstructure:"cert_file"`
+	KeyFile  string     `mapstructure:"key_file"`
+	Reload   bool       `mapstructure:"reload"`
+	ACME     ACMEConfig `mapstructure:"acme"`
+}
+
+// ACMEConfig holds automatic certificate (ACME / Let's Encrypt) settings
+type ACMEConfig struct {
+	Enabled      bool     `mapstructure:"enabled"`
+	Domains      []string `mapstructure:"domains"`
+	Email        string   `mapstructure:"email"`
+	CacheDir     string   `mapstructure:"cache_dir"`
+	DirectoryURL string   `mapstructure:"directory_url"`
+	HTTPAddr     string   `mapstructure:"http_addr"`
+}
+
+// HTTP2Config holds HTTP/2 and h2c settings
+type HTTP2Config struct {
+	Enabled              bool `mapstructure:"enabled"`
+	H2C                  bool `mapstructure:"h2c"`
+	MaxConcurrentStreams int  `mapstructure:"max_concurrent_streams"`
 }
 
 // CORSConfig holds configuration for CORS
@@ -110,9 +710,92 @@ type SecurityConfig struct {
 
 // RateLimitConfig holds configuration for rate limiting
 type RateLimitConfig struct {
-	Enabled           bool    `mapstructure:"enabled"`
+	Enabled           bool                 `mapstructure:"enabled"`
+	RequestsPerSecond float64              `mapstructure:"requests_per_second"`
+	Burst             int                  `mapstructure:"burst"`
+	Key               string               `mapstructure:"key"`
+	Header            string               `mapstructure:"header"`
+	Store             string               `mapstructure:"store"`
+	Redis             RateLimitRedisConfig `mapstructure:"redis"`
+	Routes            []RateLimitRoute     `mapstructure:"routes"`
+}
+
+// SessionConfig holds the cookie session settings
+type SessionConfig struct {
+	Enabled         bool                 `mapstructure:"enabled"`
+	CookieName      string               `mapstructure:"cookie_name"`
+	Secrets         []string             `mapstructure:"secrets"`
+	Store           string               `mapstructure:"store"`
+	Redis           RateLimitRedisConfig `mapstructure:"redis"`
+	IdleTimeout     time.Duration        `mapstructure:"idle_timeout"`
+	AbsoluteTimeout time.Duration        `mapstructure:"absolute_timeout"`
+	Domain          string               `mapstructure:"domain"`
+	Path            string               `mapstructure:"path"`
+	Secure          bool                 `mapstructure:"secure"`
+	SameSite        string               `mapstructure:"same_site"`
+}
+
+// IdempotencyConfig holds the Idempotency-Key middleware settings
+type IdempotencyConfig struct {
+	Enabled     bool                 `mapstructure:"enabled"`
+	Header      string               `mapstructure:"header"`
+	TTL         time.Duration        `mapstructure:"ttl"`
+	LockTimeout time.Duration        `mapstructure:"lock_timeout"`
+	Methods     []string             `mapstructure:"methods"`
+	Paths       []string             `mapstructure:"paths"`
+	Required    bool                 `mapstructure:"required"`
+	MaxBodySize int                  `mapstructure:"max_body_size"`
+	Store       string               `mapstructure:"store"`
+	Redis       RateLimitRedisConfig `mapstructure:"redis"`
+}
+
+// ResponseCacheConfig holds the GET response cache settings
+type ResponseCacheConfig struct {
+	Enabled             bool          `mapstructure:"enabled"`
+	TTL                 time.Duration `mapstructure:"ttl"`
+	MaxTTL              time.Duration `mapstructure:"max_ttl"`
+	Paths               []string      `mapstructure:"paths"`
+	ExcludePaths        []string      `mapstructure:"exclude_paths"`
+	VaryHeaders         []string      `mapstructure:"vary_headers"`
+	MaxBodySize         int           `mapstructure:"max_body_size"`
+	KeyPrefix           string        `mapstructure:"key_prefix"`
+	InvalidationSubject string        `mapstructure:"invalidation_subject"`
+}
+
+// RateLimitRedisConfig holds the Redis connection of a shared store (rate
+// limits, idempotency keys, sessions, cache)
+type RateLimitRedisConfig struct {
+	Addr     string `mapstructure:"addr"`
+	Password string `mapstructure:"password"`
+	DB       int    `mapstructure:"db"`
+	Prefix   string `mapstructure:"prefix"`
+}
+
+// CacheConfig holds the shared cache settings
+type CacheConfig struct {
+	Enabled    bool                 `mapstructure:"enabled"`
+	Store      string               `mapstructure:"store"` // memory or redis
+	DefaultTTL time.Duration        `mapstructure:"default_ttl"`
+	MaxEntries int                  `mapstructure:"max_entries"` // memory store only
+	Redis      RateLimitRedisConfig `mapstructure:"redis"`
+}
+
+// RateLimitRoute is the limit of a route group
+type RateLimitRoute struct {
+	PathPrefix        string  `mapstructure:"path_prefix"`
 	RequestsPerSecond float64 `mapstructure:"requests_per_second"`
 	Burst             int     `mapstructure:"burst"`
+	Key               string  `mapstructure:"key"`
+	Header            string  `mapstructure:"header"`
+}
+
+// WebProfilingConfig holds the pprof endpoint settings
+type WebProfilingConfig struct {
+	Enabled              bool     `mapstructure:"enabled"`
+	Path                 string   `mapstructure:"path"`
+	Port                 int      `mapstructure:"port"` // 0 mounts on the main server
+	Roles                []string `mapstructure:"roles"`
+	AllowUnauthenticated bool     `mapstructure:"allow_unauthenticated"`
 }
 
 // SwaggerConfig holds configuration for Swagger documentation
@@ -125,6 +808,22 @@ type SwaggerConfig struct {
 type MetricsConfig struct {
 	Enabled bool   `mapstructure:"enabled"`
 	Path    string `mapstructure:"path"`
+	// OTelPrometheus serves OpenTelemetry instruments on the Prometheus
+	// endpoint
+	OTelPrometheus bool              `mapstructure:"otel_prometheus"`
+	OTLP           MetricsOTLPConfig `mapstructure:"otlp"`
+}
+
+// MetricsOTLPConfig holds the OTLP metrics push settings
+type MetricsOTLPConfig struct {
+	Enabled  bool              `mapstructure:"enabled"`
+	Protocol string            `mapstructure:"protocol"` // http (default), grpc
+	Endpoint string            `mapstructure:"endpoint"` // e.g. "http://localhost:4318" or "localhost:4317"
+	Insecure bool              `mapstructure:"insecure"`
+	Headers  map[string]string `mapstructure:"headers"`
+	Interval time.Duration     `mapstructure:"interval"`
+	// BridgePrometheus also pushes the Prometheus registry over OTLP
+	BridgePrometheus bool `mapstructure:"bridge_prometheus"`
 }
 
 // ServicesConfig holds service-specific settings
@@ -134,13 +833,44 @@ type ServicesConfig map[string]interface{}
 type TracingConfig struct {
 	Enabled     bool   `mapstructure:"enabled"`
 	ServiceName string `mapstructure:"service_name"`
-	Exporter    string `mapstructure:"exporter"` // e.g., "jaeger", "stdout"
-	Endpoint    string `mapstructure:"endpoint"` // e.g., "http://localhost:14268/api/traces"
+	Exporter    string `mapstructure:"exporter"` // stdout, otlp (HTTP), otlp-grpc, jaeger, zipkin
+	Endpoint    string `mapstructure:"endpoint"` // e.g., "http://localhost:4318" or "localhost:4317"
+	// Insecure disables TLS for host:port endpoints; URL endpoints use their
+	// scheme
+	Insecure bool                  `mapstructure:"insecure"`
+	Headers  map[string]string     `mapstructure:"headers"`
+	Sampler  TracingSamplerConfig  `mapstructure:"sampler"`
+	Resource TracingResourceConfig `mapstructure:"resource"`
+	Batch    TracingBatchConfig    `mapstructure:"batch"`
+}
+
+// TracingSamplerConfig selects which traces are recorded
+type TracingSamplerConfig struct {
+	// Type is always, never, ratio or parent (default: follow the parent,
+	// sample every root span, or Ratio of them when set to parent)
+	Type  string  `mapstructure:"type"`
+	Ratio float64 `mapstructure:"ratio"`
+}
+
+// TracingResourceConfig holds attributes describing the service instance
+type TracingResourceConfig struct {
+	Environment string            `mapstructure:"environment"` // defaults to app.environment
+	Version     string            `mapstructure:"version"`     // defaults to app.version
+	InstanceID  string            `mapstructure:"instance_id"` // defaults to the hostname
+	Attributes  map[string]string `mapstructure:"attributes"`
+}
+
+// TracingBatchConfig tunes the batch span processor; zero keeps the defaults
+type TracingBatchConfig struct {
+	MaxQueueSize       int           `mapstructure:"max_queue_size"`
+	MaxExportBatchSize int           `mapstructure:"max_export_batch_size"`
+	BatchTimeout       time.Duration `mapstructure:"batch_timeout"`
+	ExportTimeout      time.Duration `mapstructure:"export_timeout"`
 }
 
 // DatabaseConfig holds database connection settings
 type DatabaseConfig struct {
-	Driver          string        `mapstructure:"driver"` // postgres, sqlite, etc.
+	Driver          string        `mapstructure:"driver"` // postgres, mysql, sqlserver, sqlite
 	Host            string        `mapstructure:"host"`
 	Port            int           `mapstructure:"port"`
 	User            string        `mapstructure:"user"`
@@ -151,4 +881,61 @@ type DatabaseConfig struct {
 	MaxOpenConns    int           `mapstructure:"max_open_conns"`
 	MaxIdleConns    int           `mapstructure:"max_idle_conns"`
 	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`
+
+	// Replicas serve reads; writes and transactions go to the primary above
+	Replicas []DatabaseReplicaConfig `mapstructure:"replicas"`
+	// ReplicaPolicy picks the replica of each read: random (default) or round_robin
+	ReplicaPolicy string `mapstructure:"replica_policy"`
+}
+
+// DatabaseReplicaConfig holds a read replica. Empty fields use the primary's values.
+type DatabaseReplicaConfig struct {
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	User     string `mapstructure:"user"`
+	Password string `mapstructure:"password"`
+	DBName   string `mapstructure:"dbname"`
+}
+
+// SecretsConfig configures the providers resolving ${secret:path#key}
+// placeholders in configuration values
+type SecretsConfig struct {
+	Provider string             `mapstructure:"provider"` // default provider: env (default), file, vault or aws
+	Timeout  time.Duration      `mapstructure:"timeout"`  // per secret (default 10s)
+	File     SecretsFileConfig  `mapstructure:"file"`
+	Vault    SecretsVaultConfig `mapstructure:"vault"`
+	AWS      SecretsAWSConfig   `mapstructure:"aws"`
+}
+
+// SecretsFileConfig configures the file secret provider
+type SecretsFileConfig struct {
+	Dir string `mapstructure:"dir"` // base directory of relative paths, e.g. /run/secrets
+}
+
+// SecretsVaultConfig configures the HashiCorp Vault secret provider
+type SecretsVaultConfig struct {
+	Address   string `mapstructure:"address"`   // default VAULT_ADDR
+	Token     string `mapstructure:"token"`     // default VAULT_TOKEN
+	Namespace string `mapstructure:"namespace"` // default VAULT_NAMESPACE
+}
+
+// SecretsAWSConfig configures the AWS Secrets Manager secret provider
+type SecretsAWSConfig struct {
+	Region   string `mapstructure:"region"`   // default from the AWS environment
+	Endpoint string `mapstructure:"endpoint"` // custom endpoint, e.g. LocalStack
+}
+
+// RemoteConfig configures a central configuration store. Its document is
+// merged over the local file, which stays the fallback.
+type RemoteConfig struct {
+	Provider        string        `mapstructure:"provider"`         // consul, etcd or nats; empty disables remote configuration
+	Endpoint        string        `mapstructure:"endpoint"`         // store address; nats defaults to nats.url and nats.urls
+	Key             string        `mapstructure:"key"`              // key holding the document
+	Bucket          string        `mapstructure:"bucket"`           // nats KV bucket (default "config")
+	Format          string        `mapstructure:"format"`           // document format (default from the key extension, then yaml)
+	Token           string        `mapstructure:"token"`            // consul ACL, etcd auth or nats token
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"` // polling period of Watch (default 30s, negative disables)
+	Timeout         time.Duration `mapstructure:"timeout"`          // per fetch (default 5s)
+	CacheFile       string        `mapstructure:"cache_file"`       // last fetched document, used while the store is unreachable
+	Required        bool          `mapstructure:"required"`         // fail Load without the store or a cached copy
 }
@@ -0,0 +1,630 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// FieldError is a setting violating a rule
+type FieldError struct {
+	Field   string // dotted key, e.g. web.tls.cert_file
+	Message string
+}
+
+func (e FieldError) String() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationError reports every invalid setting of a configuration
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].String()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d invalid settings:", len(e.Errors))
+	for _, fe := range e.Errors {
+		b.WriteString("\n  - ")
+		b.WriteString(fe.String())
+	}
+	return b.String()
+}
+
+// validator collects the violations of the checks
+type validator struct {
+	errs []FieldError
+}
+
+func (v *validator) add(field, format string, args ...interface{}) {
+	v.errs = append(v.errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) required(field, value string) {
+	if value == "" {
+		v.add(field, "is required")
+	}
+}
+
+// oneOf accepts an empty value, which selects the default
+func (v *validator) oneOf(field, value string, allowed ...string) {
+	if value != "" && !slices.Contains(allowed, value) {
+		v.add(field, "must be one of %s, got %q", strings.Join(allowed, ", "), value)
+	}
+}
+
+func (v *validator) port(field string, port int) {
+	if port < 1 || port > 65535 {
+		v.add(field, "must be between 1 and 65535, got %d", port)
+	}
+}
+
+func (v *validator) nonNegative(field string, n int) {
+	if n < 0 {
+		v.add(field, "must not be negative, got %d", n)
+	}
+}
+
+// duration rejects negative durations; zero selects a default or disables
+// the setting
+func (v *validator) duration(field string, d time.Duration) {
+	if d < 0 {
+		v.add(field, "must not be negative, got %s", d)
+	}
+}
+
+func (v *validator) positiveDuration(field string, d time.Duration) {
+	if d <= 0 {
+		v.add(field, "must be positive, got %s", d)
+	}
+}
+
+// file accepts an empty path, for optional files
+func (v *validator) file(field, path string) {
+	if path == "" {
+		return
+	}
+	if info, err := os.Stat(path); err != nil {
+		v.add(field, "cannot read %s: %v", path, err)
+	} else if info.IsDir() {
+		v.add(field, "%s is a directory", path)
+	}
+}
+
+func (v *validator) url(field, raw string, schemes ...string) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || !slices.Contains(schemes, u.Scheme) {
+		v.add(field, "must be a %s URL, got %q", strings.Join(schemes, "/"), raw)
+	}
+}
+
+// origin accepts "*" or a scheme://host[:port] origin without a path
+func (v *validator) origin(field, origin string) {
+	if origin == "*" {
+		return
+	}
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+		strings.Contains(u.Host, "*") || strings.Trim(u.Path, "/") != "" || u.RawQuery != "" {
+		v.add(field, "must be \"*\" or an http(s)://host[:port] origin, got %q", origin)
+	}
+}
+
+// validate checks the whole configuration and reports every violation in a
+// *ValidationError. Sections of disabled features are only checked for
+// their syntax.
+func validate(cfg *Config) error {
+	v := &validator{}
+	v.required("app.name", cfg.App.Name)
+	validateLog(v, &cfg.Log)
+	validateNATS(v, &cfg.NATS)
+	validateWeb(v, &cfg.Web)
+	validateGRPC(v, &cfg.GRPC)
+	if cfg.Web.Enabled && cfg.GRPC.Enabled && cfg.Web.Port == cfg.GRPC.Port {
+		v.add("grpc.port", "conflicts with web.port %d", cfg.Web.Port)
+	}
+	validateTelemetry(v, cfg)
+	validateDatabase(v, &cfg.Database)
+	validateFeatures(v, cfg)
+	if len(v.errs) > 0 {
+		return &ValidationError{Errors: v.errs}
+	}
+	return nil
+}
+
+// Validate checks a configuration built in code, which loaders do for the
+// configurations they load
+func (c *Config) Validate() error {
+	return validate(c)
+}
+
+var (
+	logLevels = []string{"debug", "info", "warn", "error"}
+	// The logger writes text for any format but json
+	logFormats = []string{"json", "console", "text"}
+)
+
+func validateLog(v *validator, cfg *LogConfig) {
+	if !slices.Contains(logLevels, cfg.Level) {
+		v.add("log.level", "must be one of %s, got %q", strings.Join(logLevels, ", "), cfg.Level)
+	}
+	v.oneOf("log.format", cfg.Format, logFormats...)
+	validateRotation(v, "log.rotation", &cfg.Rotation)
+	for i, sink := range cfg.Sinks {
+		field := fmt.Sprintf("log.sinks[%d]", i)
+		v.required(field+".output", sink.Output)
+		v.oneOf(field+".level", sink.Level, logLevels...)
+		v.oneOf(field+".format", sink.Format, logFormats...)
+		validateRotation(v, field+".rotation", &sink.Rotation)
+	}
+	if cfg.Sampling.Enabled {
+		v.positiveDuration("log.sampling.tick", cfg.Sampling.Tick)
+	}
+}
+
+func validateRotation(v *validator, field string, cfg *LogRotationConfig) {
+	v.nonNegative(field+".max_size_mb", cfg.MaxSizeMB)
+	v.nonNegative(field+".max_age_days", cfg.MaxAgeDays)
+	v.nonNegative(field+".max_backups", cfg.MaxBackups)
+}
+
+func validateNATS(v *validator, cfg *NATSConfig) {
+	v.duration("nats.reconnect_wait", cfg.ReconnectWait)
+	v.duration("nats.connection_timeout", cfg.ConnectionTimeout)
+	if cfg.MaxReconnects < -1 {
+		v.add("nats.max_reconnects", "must be -1 (unlimited) or more, got %d", cfg.MaxReconnects)
+	}
+	if !cfg.Enabled {
+		return
+	}
+	if cfg.Embedded {
+		if cfg.EmbeddedServer.Port < -1 || cfg.EmbeddedServer.Port > 65535 {
+			v.add("nats.embedded_server.port", "must be -1 (random) or between 0 and 65535, got %d", cfg.EmbeddedServer.Port)
+		}
+		v.duration("nats.embedded_server.ready_timeout", cfg.EmbeddedServer.ReadyTimeout)
+		if cfg.UseTLS || cfg.CredsFile != "" {
+			v.add("nats.embedded", "does not support use_tls or creds_file")
+		}
+	} else if cfg.URL == "" && len(cfg.URLs) == 0 {
+		v.add("nats.url", "is required")
+	} else {
+		if cfg.URL != "" {
+			for _, server := range strings.Split(cfg.URL, ",") {
+				v.url("nats.url", strings.TrimSpace(server), "nats", "tls", "ws", "wss")
+			}
+		}
+		for i, server := range cfg.URLs {
+			v.url(fmt.Sprintf("nats.urls[%d]", i), server, "nats", "tls", "ws", "wss")
+		}
+	}
+	v.file("nats.creds_file", cfg.CredsFile)
+	v.duration("nats.auth_rotation.jitter", cfg.AuthRotation.Jitter)
+	if cfg.AuthRotation.WatchCreds && cfg.CredsFile == "" {
+		v.add("nats.auth_rotation.watch_creds", "requires creds_file")
+	}
+	v.file("nats.ca_file", cfg.CAFile)
+	v.file("nats.cert_file", cfg.CertFile)
+	v.file("nats.key_file", cfg.KeyFile)
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		v.add("nats.key_file", "cert_file and key_file must be set together")
+	}
+
+	if cfg.Signing.Enabled {
+		ids := make([]string, 0, len(cfg.Signing.Keys))
+		for i, key := range cfg.Signing.Keys {
+			field := fmt.Sprintf("nats.signing.keys[%d]", i)
+			v.required(field+".id", key.ID)
+			v.oneOf(field+".algorithm", key.Algorithm, "hmac-sha256", "ed25519")
+			ids = append(ids, key.ID)
+		}
+		if cfg.Signing.KeyID != "" && !slices.Contains(ids, cfg.Signing.KeyID) {
+			v.add("nats.signing.key_id", "does not match any key, got %q", cfg.Signing.KeyID)
+		}
+		v.duration("nats.signing.max_age", cfg.Signing.MaxAge)
+	}
+
+	if cfg.LargePayloads.Enabled {
+		v.required("nats.large_payloads.bucket", cfg.LargePayloads.Bucket)
+		v.duration("nats.large_payloads.ttl", cfg.LargePayloads.TTL)
+		v.nonNegative("nats.large_payloads.max_size", int(cfg.LargePayloads.MaxSize))
+		v.duration("nats.large_payloads.timeout", cfg.LargePayloads.Timeout)
+	}
+
+	if cfg.Compression.Enabled {
+		v.oneOf("nats.compression.algorithm", cfg.Compression.Algorithm, "gzip", "zstd")
+		v.nonNegative("nats.compression.threshold", cfg.Compression.Threshold)
+	}
+
+	if cfg.Logging.Sampling.Enabled {
+		v.positiveDuration("nats.logging.sampling.tick", cfg.Logging.Sampling.Tick)
+	}
+
+	if cfg.Metrics.Enabled {
+		v.nonNegative("nats.metrics.cardinality.subject_depth", cfg.Metrics.Cardinality.SubjectDepth)
+		v.nonNegative("nats.metrics.cardinality.max_values", cfg.Metrics.Cardinality.MaxValues)
+		for label := range cfg.Metrics.Cardinality.Allow {
+			v.oneOf("nats.metrics.cardinality.allow", label, "subject", "type", "tenant")
+		}
+	}
+
+	if cfg.Monitoring.Enabled {
+		v.positiveDuration("nats.monitoring.interval", cfg.Monitoring.Interval)
+		v.nonNegative("nats.monitoring.max_ack_pending", cfg.Monitoring.MaxAckPending)
+		v.nonNegative("nats.monitoring.max_redelivered", cfg.Monitoring.MaxRedelivered)
+	}
+
+	if cfg.Quarantine.Enabled {
+		if cfg.Quarantine.MaxDeliveries < 1 {
+			v.add("nats.quarantine.max_deliveries", "must be at least 1, got %d", cfg.Quarantine.MaxDeliveries)
+		}
+		v.required("nats.quarantine.stream", cfg.Quarantine.Stream)
+		v.required("nats.quarantine.prefix", cfg.Quarantine.Prefix)
+		v.duration("nats.quarantine.max_age", cfg.Quarantine.MaxAge)
+	}
+
+	if cfg.ResponseCache.Enabled {
+		v.positiveDuration("nats.response_cache.ttl", cfg.ResponseCache.TTL)
+	}
+
+	validateLoadShedding(v, "nats.load_shedding", cfg.LoadShedding)
+
+	if cfg.PreStop.Enabled {
+		v.positiveDuration("nats.pre_stop.grace", cfg.PreStop.Grace)
+	}
+
+	if cfg.Encryption.Enabled {
+		ids := make([]string, 0, len(cfg.Encryption.Keys))
+		for i, key := range cfg.Encryption.Keys {
+			field := fmt.Sprintf("nats.encryption.keys[%d]", i)
+			v.required(field+".id", key.ID)
+			if raw, err := base64.StdEncoding.DecodeString(key.Key); err != nil {
+				v.add(field+".key", "must be base64 encoded: %v", err)
+			} else if n := len(raw); n != 16 && n != 24 && n != 32 {
+				v.add(field+".key", "must be 16, 24 or 32 bytes, got %d", n)
+			}
+			ids = append(ids, key.ID)
+		}
+		if len(ids) == 0 {
+			v.add("nats.encryption.keys", "at least one key is required")
+		}
+		if cfg.Encryption.KeyID != "" && !slices.Contains(ids, cfg.Encryption.KeyID) {
+			v.add("nats.encryption.key_id", "does not match any key, got %q", cfg.Encryption.KeyID)
+		}
+	}
+}
+
+func validateWeb(v *validator, cfg *WebConfig) {
+	v.oneOf("web.mode", cfg.Mode, "debug", "release", "test")
+	v.duration("web.read_timeout", cfg.ReadTimeout)
+	v.duration("web.write_timeout", cfg.WriteTimeout)
+	v.duration("web.shutdown_timeout", cfg.ShutdownTimeout)
+	if !cfg.Enabled {
+		return
+	}
+	v.port("web.port", cfg.Port)
+	validateTLS(v, "web.tls", &cfg.TLS)
+
+	if cfg.CORS.Enabled {
+		for i, origin := range cfg.CORS.AllowedOrigins {
+			v.origin(fmt.Sprintf("web.cors.allowed_origins[%d]", i), origin)
+		}
+		v.nonNegative("web.cors.max_age", cfg.CORS.MaxAge)
+	}
+	if cfg.RateLimit.Enabled {
+		if cfg.RateLimit.RequestsPerSecond <= 0 {
+			v.add("web.rate_limit.requests_per_second", "must be positive, got %v", cfg.RateLimit.RequestsPerSecond)
+		}
+		v.nonNegative("web.rate_limit.burst", cfg.RateLimit.Burst)
+		validateRateLimitKey(v, "web.rate_limit", cfg.RateLimit.Key, cfg.RateLimit.Header)
+		validateStore(v, "web.rate_limit", cfg.RateLimit.Store, &cfg.RateLimit.Redis, "memory", "redis")
+		for i, route := range cfg.RateLimit.Routes {
+			field := fmt.Sprintf("web.rate_limit.routes[%d]", i)
+			v.required(field+".path_prefix", route.PathPrefix)
+			validateRateLimitKey(v, field, route.Key, route.Header)
+		}
+	}
+	if cfg.Limits.Enabled {
+		v.duration("web.limits.read_timeout", cfg.Limits.ReadTimeout)
+		v.duration("web.limits.handler_timeout", cfg.Limits.HandlerTimeout)
+		for i, route := range cfg.Limits.Routes {
+			v.required(fmt.Sprintf("web.limits.routes[%d].path_prefix", i), route.PathPrefix)
+		}
+	}
+	validateLoadShedding(v, "web.load_shedding", cfg.LoadShedding)
+	if cfg.Session.Enabled {
+		if len(cfg.Session.Secrets) == 0 {
+			v.add("web.session.secrets", "at least one secret is required")
+		}
+		for i, secret := range cfg.Session.Secrets {
+			if len(secret) < 32 {
+				v.add(fmt.Sprintf("web.session.secrets[%d]", i), "must be at least 32 bytes")
+			}
+		}
+		validateStore(v, "web.session", cfg.Session.Store, &cfg.Session.Redis, "cookie", "memory", "redis")
+		v.oneOf("web.session.same_site", cfg.Session.SameSite, "lax", "strict", "none")
+	}
+	if cfg.Idempotency.Enabled {
+		v.duration("web.idempotency.ttl", cfg.Idempotency.TTL)
+		validateStore(v, "web.idempotency", cfg.Idempotency.Store, &cfg.Idempotency.Redis, "memory", "redis")
+	}
+	if cfg.Profiling.Enabled && cfg.Profiling.Port != 0 {
+		v.port("web.profiling.port", cfg.Profiling.Port)
+	}
+	if cfg.Auth.Enabled {
+		v.oneOf("web.auth.mode", cfg.Auth.Mode, "required", "optional")
+		if cfg.Auth.JWKSURL != "" {
+			v.url("web.auth.jwks_url", cfg.Auth.JWKSURL, "http", "https")
+		}
+		for i, key := range cfg.Auth.APIKeys {
+			v.required(fmt.Sprintf("web.auth.api_keys[%d].key", i), key.Key)
+		}
+	}
+	if cfg.NATSGateway.Enabled {
+		for i, route := range cfg.NATSGateway.Routes {
+			field := fmt.Sprintf("web.nats_gateway.routes[%d]", i)
+			v.required(field+".path", route.Path)
+			v.required(field+".subject", route.Subject)
+		}
+	}
+}
+
+func validateRateLimitKey(v *validator, field, key, header string) {
+	v.oneOf(field+".key", key, "ip", "api_key", "header")
+	if key == "header" && header == "" {
+		v.add(field+".header", "is required for key \"header\"")
+	}
+}
+
+func validateStore(v *validator, field, store string, redis *RateLimitRedisConfig, allowed ...string) {
+	v.oneOf(field+".store", store, allowed...)
+	if store == "redis" {
+		v.required(field+".redis.addr", redis.Addr)
+	}
+}
+
+func validateTLS(v *validator, field string, cfg *TLSConfig) {
+	if !cfg.Enabled {
+		return
+	}
+	if cfg.ACME.Enabled {
+		if len(cfg.ACME.Domains) == 0 {
+			v.add(field+".acme.domains", "at least one domain is required")
+		}
+		return
+	}
+	v.required(field+".cert_file", cfg.CertFile)
+	v.required(field+".key_file", cfg.KeyFile)
+	v.file(field+".cert_file", cfg.CertFile)
+	v.file(field+".key_file", cfg.KeyFile)
+}
+
+func validateGRPC(v *validator, cfg *GRPCConfig) {
+	v.duration("grpc.shutdown_timeout", cfg.ShutdownTimeout)
+	v.nonNegative("grpc.max_recv_msg_size", cfg.MaxRecvMsgSize)
+	v.nonNegative("grpc.max_send_msg_size", cfg.MaxSendMsgSize)
+	if !cfg.Enabled {
+		return
+	}
+	v.port("grpc.port", cfg.Port)
+	validateTLS(v, "grpc.tls", &cfg.TLS)
+}
+
+func validateTelemetry(v *validator, cfg *Config) {
+	if cfg.Tracing.Enabled {
+		v.oneOf("tracing.exporter", cfg.Tracing.Exporter, "stdout", "otlp", "otlp-grpc", "jaeger", "zipkin")
+	}
+	v.oneOf("tracing.sampler.type", cfg.Tracing.Sampler.Type, "always", "never", "ratio", "parent")
+	if r := cfg.Tracing.Sampler.Ratio; r < 0 || r > 1 {
+		v.add("tracing.sampler.ratio", "must be between 0 and 1, got %v", r)
+	}
+	v.duration("tracing.batch.batch_timeout", cfg.Tracing.Batch.BatchTimeout)
+	v.duration("tracing.batch.export_timeout", cfg.Tracing.Batch.ExportTimeout)
+
+	if cfg.Metrics.OTLP.Enabled {
+		v.oneOf("metrics.otlp.protocol", cfg.Metrics.OTLP.Protocol, "http", "grpc")
+		v.required("metrics.otlp.endpoint", cfg.Metrics.OTLP.Endpoint)
+		v.duration("metrics.otlp.interval", cfg.Metrics.OTLP.Interval)
+	}
+}
+
+func validateDatabase(v *validator, cfg *DatabaseConfig) {
+	v.oneOf("database.driver", cfg.Driver, "postgres", "postgresql", "mysql", "mariadb", "sqlserver", "mssql", "sqlite", "sqlite3")
+	v.nonNegative("database.max_open_conns", cfg.MaxOpenConns)
+	v.nonNegative("database.max_idle_conns", cfg.MaxIdleConns)
+	v.duration("database.conn_max_lifetime", cfg.ConnMaxLifetime)
+	v.oneOf("database.replica_policy", cfg.ReplicaPolicy, "random", "round_robin")
+	if cfg.Port != 0 {
+		v.port("database.port", cfg.Port)
+	}
+	for i, replica := range cfg.Replicas {
+		if replica.Port != 0 {
+			v.port(fmt.Sprintf("database.replicas[%d].port", i), replica.Port)
+		}
+	}
+}
+
+// validateFeatures checks the optional components
+func validateFeatures(v *validator, cfg *Config) {
+	if cfg.Webhooks.Enabled {
+		if cfg.Webhooks.Workers <= 0 {
+			v.add("webhooks.workers", "must be positive, got %d", cfg.Webhooks.Workers)
+		}
+		v.nonNegative("webhooks.queue_size", cfg.Webhooks.QueueSize)
+		for i, target := range cfg.Webhooks.Targets {
+			field := fmt.Sprintf("webhooks.targets[%d]", i)
+			v.required(field+".subject", target.Subject)
+			v.url(field+".url", target.URL, "http", "https")
+			v.duration(field+".timeout", target.Timeout)
+			v.nonNegative(field+".max_retries", target.MaxRetries)
+		}
+	}
+
+	if cfg.Archive.Enabled {
+		v.oneOf("archive.backend", cfg.Archive.Backend, ArchiveBackendDatabase, ArchiveBackendS3)
+		if cfg.Archive.Backend == ArchiveBackendS3 {
+			v.required("archive.s3.bucket", cfg.Archive.S3.Bucket)
+		}
+		if len(cfg.Archive.Subjects) == 0 {
+			v.add("archive.subjects", "must not be empty")
+		}
+		for i, subject := range cfg.Archive.Subjects {
+			v.required(fmt.Sprintf("archive.subjects[%d].subject", i), subject.Subject)
+		}
+		v.nonNegative("archive.batch_size", cfg.Archive.BatchSize)
+		v.duration("archive.flush_interval", cfg.Archive.FlushInterval)
+		v.duration("archive.write_timeout", cfg.Archive.WriteTimeout)
+		v.duration("archive.retention", cfg.Archive.Retention)
+		v.duration("archive.retention_interval", cfg.Archive.RetentionInterval)
+	}
+
+	if cfg.Profiling.Enabled {
+		v.required("profiling.subject", cfg.Profiling.Subject)
+		if cfg.Profiling.MaxDuration > 0 && cfg.Profiling.DefaultDuration > cfg.Profiling.MaxDuration {
+			v.add("profiling.default_duration", "exceeds max_duration %s", cfg.Profiling.MaxDuration)
+		}
+	}
+
+	v.duration("health.timeout", cfg.Health.Timeout)
+	v.duration("health.cache_ttl", cfg.Health.CacheTTL)
+	v.duration("health.refresh_interval", cfg.Health.RefreshInterval)
+
+	if cfg.Cache.Enabled {
+		validateStore(v, "cache", cfg.Cache.Store, &cfg.Cache.Redis, "memory", "redis")
+		v.duration("cache.default_ttl", cfg.Cache.DefaultTTL)
+		v.nonNegative("cache.max_entries", cfg.Cache.MaxEntries)
+	}
+
+	v.oneOf("secrets.provider", cfg.Secrets.Provider, SecretProviderEnv, SecretProviderFile, SecretProviderVault, SecretProviderAWS)
+	v.oneOf("remote.provider", cfg.Remote.Provider, RemoteProviderConsul, RemoteProviderEtcd, RemoteProviderNATS)
+	if cfg.Remote.Provider != "" {
+		v.required("remote.key", cfg.Remote.Key)
+	}
+
+	if cfg.Tenancy.Enabled && cfg.Tenancy.Header == "" && cfg.Tenancy.Claim == "" {
+		v.add("tenancy.header", "is required without tenancy.claim")
+	}
+
+	for i, b := range cfg.EventBus.Bridges {
+		field := fmt.Sprintf("event_bus.bridges[%d]", i)
+		v.required(field+".topic", b.Topic)
+		v.required(field+".subject", b.Subject)
+		if strings.ContainsAny(b.Subject, "*> ") {
+			v.add(field+".subject", "must be a subject without wildcards, got %q", b.Subject)
+		}
+	}
+
+	if cfg.Plugins.Enabled {
+		if cfg.Plugins.Dir == "" && cfg.Plugins.Manifest == "" {
+			v.add("plugins.dir", "is required without plugins.manifest")
+		}
+		v.file("plugins.manifest", cfg.Plugins.Manifest)
+	}
+
+	if cfg.MQTT.Enabled {
+		if !cfg.NATS.Enabled {
+			v.add("mqtt.enabled", "requires nats.enabled")
+		}
+		v.url("mqtt.broker", cfg.MQTT.Broker, "tcp", "ssl", "ws", "wss")
+		v.duration("mqtt.keep_alive", cfg.MQTT.KeepAlive)
+		v.duration("mqtt.connect_timeout", cfg.MQTT.ConnectTimeout)
+		v.duration("mqtt.publish_timeout", cfg.MQTT.PublishTimeout)
+		for i, r := range cfg.MQTT.Routes {
+			field := fmt.Sprintf("mqtt.routes[%d]", i)
+			v.required(field+".name", r.Name)
+			v.required(field+".direction", r.Direction)
+			v.oneOf(field+".direction", r.Direction, "inbound", "outbound")
+			v.required(field+".topic", r.Topic)
+			v.required(field+".subject", r.Subject)
+			if r.QoS < 0 || r.QoS > 2 {
+				v.add(field+".qos", "must be 0, 1 or 2, got %d", r.QoS)
+			}
+		}
+	}
+
+	if cfg.SLO.Enabled {
+		validateSLO(v, &cfg.SLO)
+	}
+
+	if cfg.Chaos.Enabled {
+		for i, r := range cfg.Chaos.Rules {
+			field := fmt.Sprintf("chaos.rules[%d]", i)
+			v.oneOf(field+".target", r.Target, "http", "nats")
+			v.required(field+".target", r.Target)
+			v.oneOf(field+".fault", r.Fault, "latency", "error", "drop")
+			v.required(field+".fault", r.Fault)
+			if r.Percent <= 0 || r.Percent > 100 {
+				v.add(field+".percent", "must be in (0, 100], got %g", r.Percent)
+			}
+			if r.Fault == "latency" {
+				v.positiveDuration(field+".latency", r.Latency)
+			}
+			if r.Status != 0 && (r.Status < 400 || r.Status > 599) {
+				v.add(field+".status", "must be an HTTP error status, got %d", r.Status)
+			}
+		}
+	}
+}
+
+// validateSLO checks the objectives and the windows of the alert rules
+func validateSLO(v *validator, cfg *SLOConfig) {
+	v.duration("slo.window", cfg.Window)
+	v.duration("slo.evaluation_interval", cfg.EvaluationInterval)
+	names := make(map[string]bool, len(cfg.Objectives))
+	for i, o := range cfg.Objectives {
+		field := fmt.Sprintf("slo.objectives[%d]", i)
+		v.required(field+".name", o.Name)
+		if names[o.Name] {
+			v.add(field+".name", "duplicates %q", o.Name)
+		}
+		names[o.Name] = true
+		v.required(field+".kind", o.Kind)
+		v.oneOf(field+".kind", o.Kind, "http", "nats")
+		v.duration(field+".latency", o.Latency)
+		if o.Target <= 0 || o.Target >= 1 {
+			v.add(field+".target", "must be in (0, 1), got %g", o.Target)
+		}
+	}
+	if len(cfg.Alerts) == 0 && cfg.Window > 0 && cfg.Window < 6*time.Hour {
+		v.add("slo.window", "must cover the 6h of the default alert rules, got %s", cfg.Window)
+	}
+	for i, r := range cfg.Alerts {
+		field := fmt.Sprintf("slo.alerts[%d]", i)
+		v.required(field+".name", r.Name)
+		if r.ShortWindow < time.Minute {
+			v.add(field+".short_window", "must be at least 1m, got %s", r.ShortWindow)
+		}
+		if r.LongWindow < r.ShortWindow {
+			v.add(field+".long_window", "must not be shorter than short_window %s", r.ShortWindow)
+		}
+		if cfg.Window > 0 && r.LongWindow > cfg.Window {
+			v.add(field+".long_window", "exceeds window %s", cfg.Window)
+		}
+		if r.BurnRate <= 0 {
+			v.add(field+".burn_rate", "must be positive, got %g", r.BurnRate)
+		}
+	}
+}
+
+// validateLoadShedding checks the thresholds of enabled load shedding
+func validateLoadShedding(v *validator, field string, cfg LoadShedding) {
+	if !cfg.Enabled {
+		return
+	}
+	v.nonNegative(field+".max_in_flight", cfg.MaxInFlight)
+	v.nonNegative(field+".max_queue_depth", cfg.MaxQueueDepth)
+	if cfg.MaxCPU < 0 || cfg.MaxCPU > 1 {
+		v.add(field+".max_cpu", "must be between 0 and 1, got %g", cfg.MaxCPU)
+	}
+	if cfg.MaxMemory < 0 {
+		v.add(field+".max_memory", "must not be negative, got %d", cfg.MaxMemory)
+	}
+	v.positiveDuration(field+".interval", cfg.Interval)
+	v.positiveDuration(field+".retry_after", cfg.RetryAfter)
+}
@@ -0,0 +1,284 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// validConfig returns the defaults with the required settings
+func validConfig() *Config {
+	cfg := Default()
+	cfg.App.Name = "test-app"
+	return cfg
+}
+
+func TestValidate_Aggregated(t *testing.T) {
+	cfg := validConfig()
+	cfg.App.Name = ""
+	cfg.Web.Enabled = true
+	cfg.Web.Port = 70000
+	cfg.NATS.ReconnectWait = -time.Second
+	cfg.Tracing.Enabled = true
+	cfg.Tracing.Exporter = "datadog"
+
+	err := validate(cfg)
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Expected *ValidationError, got %v", err)
+	}
+
+	fields := make([]string, 0, len(verr.Errors))
+	for _, fe := range verr.Errors {
+		fields = append(fields, fe.Field)
+	}
+	want := []string{"app.name", "nats.reconnect_wait", "web.port", "tracing.exporter"}
+	if strings.Join(fields, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected violations %v, got %v", want, fields)
+	}
+	if !strings.HasPrefix(err.Error(), "4 invalid settings:") || !strings.Contains(err.Error(), "web.port: must be between 1 and 65535, got 70000") {
+		t.Errorf("Unexpected report:\n%v", err)
+	}
+}
+
+func TestValidate_Rules(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	if err := os.WriteFile(certFile, []byte("cert"), 0600); err != nil {
+		t.Fatalf("Failed to write cert: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		field  string // empty when valid
+	}{
+		{"defaults", func(*Config) {}, ""},
+		{"cors wildcard", func(c *Config) {
+			c.Web.Enabled, c.Web.CORS.Enabled = true, true
+			c.Web.CORS.AllowedOrigins = []string{"*", "https://example.com", "http://localhost:3000"}
+		}, ""},
+		{"cors path", func(c *Config) {
+			c.Web.Enabled, c.Web.CORS.Enabled = true, true
+			c.Web.CORS.AllowedOrigins = []string{"https://example.com/app"}
+		}, "web.cors.allowed_origins[0]"},
+		{"cors scheme", func(c *Config) {
+			c.Web.Enabled, c.Web.CORS.Enabled = true, true
+			c.Web.CORS.AllowedOrigins = []string{"https://ok.example.com", "example.com"}
+		}, "web.cors.allowed_origins[1]"},
+		{"tls files", func(c *Config) {
+			c.Web.Enabled, c.Web.TLS.Enabled = true, true
+			c.Web.TLS.CertFile, c.Web.TLS.KeyFile = certFile, filepath.Join(dir, "missing.pem")
+		}, "web.tls.key_file"},
+		{"tls acme", func(c *Config) {
+			c.Web.Enabled, c.Web.TLS.Enabled, c.Web.TLS.ACME.Enabled = true, true, true
+		}, "web.tls.acme.domains"},
+		{"disabled sections", func(c *Config) {
+			c.Web.Port, c.GRPC.Port = 0, 0
+			c.Web.TLS = TLSConfig{Enabled: true}
+		}, ""},
+		{"port conflict", func(c *Config) {
+			c.Web.Enabled, c.GRPC.Enabled = true, true
+			c.GRPC.Port = c.Web.Port
+		}, "grpc.port"},
+		{"nats url", func(c *Config) {
+			c.NATS.Enabled = true
+			c.NATS.URL = "nats://a:4222, http://b:4222"
+		}, "nats.url"},
+		{"nats urls", func(c *Config) {
+			c.NATS.Enabled = true
+			c.NATS.URLs = []string{"nats://b:4222", "b:4222"}
+		}, "nats.urls[1]"},
+		{"watch creds without creds file", func(c *Config) {
+			c.NATS.Enabled = true
+			c.NATS.AuthRotation = NATSAuthRotation{WatchCreds: true}
+		}, "nats.auth_rotation.watch_creds"},
+		{"signing key id", func(c *Config) {
+			c.NATS.Enabled = true
+			c.NATS.Signing = NATSSigning{Enabled: true, KeyID: "other", Keys: []NATSSigningKey{{ID: "main", Algorithm: "hmac-sha256"}}}
+		}, "nats.signing.key_id"},
+		{"large payloads bucket", func(c *Config) {
+			c.NATS.Enabled = true
+			c.NATS.LargePayloads = NATSLargePayloads{Enabled: true}
+		}, "nats.large_payloads.bucket"},
+		{"metrics cardinality labels", func(c *Config) {
+			c.NATS.Enabled = true
+			c.NATS.Metrics = NATSMetrics{Enabled: true, Cardinality: NATSMetricsCardinality{Allow: map[string][]string{"status": {"success"}}}}
+		}, "nats.metrics.cardinality.allow"},
+		{"metrics cardinality cap", func(c *Config) {
+			c.NATS.Enabled = true
+			c.NATS.Metrics = NATSMetrics{Enabled: true, Cardinality: NATSMetricsCardinality{MaxValues: -1}}
+		}, "nats.metrics.cardinality.max_values"},
+		{"compression algorithm", func(c *Config) {
+			c.NATS.Enabled = true
+			c.NATS.Compression = NATSCompression{Enabled: true, Algorithm: "lz4"}
+		}, "nats.compression.algorithm"},
+		{"encryption key size", func(c *Config) {
+			c.NATS.Enabled = true
+			c.NATS.Encryption = NATSEncryption{Enabled: true, KeyID: "pii", Keys: []NATSEncryptionKey{{ID: "pii", Key: "c2hvcnQ="}}}
+		}, "nats.encryption.keys[0].key"},
+		{"monitoring interval", func(c *Config) {
+			c.NATS.Enabled = true
+			c.NATS.Monitoring = NATSMonitoring{Enabled: true}
+		}, "nats.monitoring.interval"},
+		{"nats log sampling tick", func(c *Config) {
+			c.NATS.Enabled = true
+			c.NATS.Logging.Sampling = LogSamplingConfig{Enabled: true}
+		}, "nats.logging.sampling.tick"},
+		{"quarantine max deliveries", func(c *Config) {
+			c.NATS.Enabled = true
+			c.NATS.Quarantine = NATSQuarantine{Enabled: true, Stream: "QUARANTINE", Prefix: "quarantine"}
+		}, "nats.quarantine.max_deliveries"},
+		{"embedded nats tls", func(c *Config) {
+			c.NATS.Enabled = true
+			c.NATS.Embedded = true
+			c.NATS.UseTLS = true
+		}, "nats.embedded"},
+		{"chaos percent", func(c *Config) {
+			c.Chaos = ChaosConfig{Enabled: true, Rules: []ChaosRule{{Target: "nats", Fault: "drop", Percent: 150}}}
+		}, "chaos.rules[0].percent"},
+		{"slo target", func(c *Config) {
+			c.SLO = SLOConfig{Enabled: true, Window: 24 * time.Hour, Objectives: []SLOObjective{{Name: "orders", Kind: "http", Target: 99.9}}}
+		}, "slo.objectives[0].target"},
+		{"slo kind", func(c *Config) {
+			c.SLO = SLOConfig{Enabled: true, Window: 24 * time.Hour, Objectives: []SLOObjective{{Name: "orders", Kind: "grpc", Target: 0.999}}}
+		}, "slo.objectives[0].kind"},
+		{"slo alert window", func(c *Config) {
+			c.SLO = SLOConfig{Enabled: true, Window: 24 * time.Hour, Alerts: []SLOAlertRule{
+				{Name: "page", LongWindow: 48 * time.Hour, ShortWindow: time.Hour, BurnRate: 2},
+			}}
+		}, "slo.alerts[0].long_window"},
+		{"slo window of default alerts", func(c *Config) {
+			c.SLO = SLOConfig{Enabled: true, Window: time.Hour}
+		}, "slo.window"},
+		{"event bridge subject", func(c *Config) {
+			c.EventBus.Bridges = []EventBridge{{Topic: "orders.placed", Subject: "grouter.events.>"}}
+		}, "event_bus.bridges[0].subject"},
+		{"plugins source", func(c *Config) {
+			c.Plugins = PluginsConfig{Enabled: true}
+		}, "plugins.dir"},
+		{"mqtt broker", func(c *Config) {
+			c.NATS.Enabled = true
+			c.MQTT = MQTTConfig{Enabled: true, Broker: "localhost:1883"}
+		}, "mqtt.broker"},
+		{"mqtt without nats", func(c *Config) {
+			c.MQTT = MQTTConfig{Enabled: true, Broker: "tcp://localhost:1883"}
+		}, "mqtt.enabled"},
+		{"mqtt route qos", func(c *Config) {
+			c.NATS.Enabled = true
+			c.MQTT = MQTTConfig{Enabled: true, Broker: "tcp://localhost:1883", Routes: []MQTTRoute{
+				{Name: "telemetry", Direction: "inbound", Topic: "devices/+/telemetry", Subject: "iot.telemetry", QoS: 3},
+			}}
+		}, "mqtt.routes[0].qos"},
+		{"mqtt route direction", func(c *Config) {
+			c.NATS.Enabled = true
+			c.MQTT = MQTTConfig{Enabled: true, Broker: "tcp://localhost:1883", Routes: []MQTTRoute{
+				{Name: "telemetry", Direction: "both", Topic: "devices/+/telemetry", Subject: "iot.telemetry"},
+			}}
+		}, "mqtt.routes[0].direction"},
+		{"load shedding cpu", func(c *Config) {
+			c.Web.Enabled = true
+			c.Web.LoadShedding = LoadShedding{Enabled: true, MaxCPU: 80, Interval: time.Second, RetryAfter: time.Second}
+		}, "web.load_shedding.max_cpu"},
+		{"load shedding interval", func(c *Config) {
+			c.NATS.Enabled = true
+			c.NATS.LoadShedding = LoadShedding{Enabled: true, MaxInFlight: 100, RetryAfter: time.Second}
+		}, "nats.load_shedding.interval"},
+		{"response cache ttl", func(c *Config) {
+			c.NATS.Enabled = true
+			c.NATS.ResponseCache = NATSResponseCache{Enabled: true}
+		}, "nats.response_cache.ttl"},
+		{"pre-stop grace", func(c *Config) {
+			c.NATS.Enabled = true
+			c.NATS.PreStop = NATSPreStop{Enabled: true}
+		}, "nats.pre_stop.grace"},
+		{"sampler ratio", func(c *Config) { c.Tracing.Sampler.Ratio = 2 }, "tracing.sampler.ratio"},
+		{"log sink level", func(c *Config) { c.Log.Sinks = []LogSinkConfig{{Output: "stdout", Level: "trace"}} }, "log.sinks[0].level"},
+		{"database driver", func(c *Config) { c.Database.Driver = "oracle" }, "database.driver"},
+		{"rate limit header", func(c *Config) {
+			c.Web.Enabled = true
+			c.Web.RateLimit.Key = "header"
+		}, "web.rate_limit.header"},
+		{"session secret", func(c *Config) {
+			c.Web.Enabled, c.Web.Session.Enabled = true, true
+			c.Web.Session.Secrets = []string{"short"}
+		}, "web.session.secrets[0]"},
+		{"redis store", func(c *Config) {
+			c.Cache.Enabled, c.Cache.Store, c.Cache.Redis.Addr = true, "redis", ""
+		}, "cache.redis.addr"},
+		{"webhook url", func(c *Config) {
+			c.Webhooks.Enabled = true
+			c.Webhooks.Targets = []WebhookTarget{{Subject: "a.b", URL: "example.com/hook"}}
+		}, "webhooks.targets[0].url"},
+		{"archive backend", func(c *Config) {
+			c.Archive = ArchiveConfig{Enabled: true, Backend: "ftp", Subjects: []ArchiveSubject{{Subject: "a.b"}}}
+		}, "archive.backend"},
+		{"archive bucket", func(c *Config) {
+			c.Archive = ArchiveConfig{Enabled: true, Backend: ArchiveBackendS3, Subjects: []ArchiveSubject{{Subject: "a.b"}}}
+		}, "archive.s3.bucket"},
+		{"archive subjects", func(c *Config) {
+			c.Archive = ArchiveConfig{Enabled: true, Backend: ArchiveBackendDatabase}
+		}, "archive.subjects"},
+		{"remote key", func(c *Config) { c.Remote.Provider = RemoteProviderConsul }, "remote.key"},
+		{"tenancy source", func(c *Config) { c.Tenancy = TenancyConfig{Enabled: true} }, "tenancy.header"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(cfg)
+			err := validate(cfg)
+			if tt.field == "" {
+				if err != nil {
+					t.Errorf("Expected valid config, got %v", err)
+				}
+				return
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) || len(verr.Errors) != 1 || verr.Errors[0].Field != tt.field {
+				t.Errorf("Expected one violation of %s, got %v", tt.field, err)
+			}
+		})
+	}
+}
+
+func TestLoad_ReferenceConfig(t *testing.T) {
+	resetConfig()
+
+	// The reference file documents every setting and must stay valid
+	os.Args = []string{"test", "--config", "../../configs/config.yaml"}
+	if _, err := Load(); err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+}
+
+func TestLoad_ValidateConfig(t *testing.T) {
+	defer func() { exit = os.Exit }()
+	code := -1
+	exit = func(c int) { code = c }
+
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(content string) {
+		if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create config file: %v", err)
+		}
+	}
+
+	resetConfig()
+	write("app:\n  name: test-app\n")
+	os.Args = []string{"test", "--config", configFile, "--validate-config"}
+	Load()
+	if code != 0 {
+		t.Errorf("Expected exit code 0, got %d", code)
+	}
+
+	resetConfig()
+	write("app:\n  name: test-app\nweb:\n  enabled: true\n  port: -1\n")
+	Load()
+	if code != 1 {
+		t.Errorf("Expected exit code 1, got %d", code)
+	}
+}
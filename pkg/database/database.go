@@ -2,16 +2,19 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
-	"gorm.io/driver/postgres"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	gormlogger "gorm.io/gorm/logger"
+	"gorm.io/plugin/dbresolver"
 	"gorm.io/plugin/opentelemetry/tracing"
 
 	"grouter/pkg/config"
+	"grouter/pkg/health"
+	"grouter/pkg/telemetry"
 
 	"go.uber.org/zap"
 )
@@ -19,21 +22,60 @@ import (
 // Database wraps the GORM DB connection
 type Database struct {
 	*gorm.DB
+
+	// replicas are the read replica pools, routed to by dbresolver
+	replicas []*sql.DB
+	// collectors report the pool stats of the primary and the replicas
+	collectors []*MetricsCollector
+}
+
+// Options configures optional behavior of New
+type Options struct {
+	// Health receives a "database" readiness check pinging the primary and a
+	// non-critical "database.replica.<n>" check per replica
+	Health *health.HealthService
+	// HealthTimeout bounds the ping (default health.DefaultCheckTimeout)
+	HealthTimeout time.Duration
+
+	// Metrics receives the connection pool stats of the primary as the
+	// MetricsName series and of each replica as "<MetricsName>.replica.<n>"
+	Metrics         *telemetry.MetricsRegistry
+	MetricsName     string
+	MetricsInterval time.Duration
+}
+
+// Option is a functional option for New
+type Option func(*Options)
+
+// WithHealth registers readiness checks on h that ping the primary and each
+// replica, giving up after timeout (0 uses health.DefaultCheckTimeout)
+func WithHealth(h *health.HealthService, timeout time.Duration) Option {
+	return func(o *Options) {
+		o.Health = h
+		o.HealthTimeout = timeout
+	}
+}
+
+// WithMetrics reports the connection pool stats as the name series of reg
+// every interval until the database is closed
+func WithMetrics(name string, reg *telemetry.MetricsRegistry, interval time.Duration) Option {
+	return func(o *Options) {
+		o.Metrics = reg
+		o.MetricsName = name
+		o.MetricsInterval = interval
+	}
 }
 
 // New creates a new database connection based on configuration
-func New(cfg config.DatabaseConfig, logger *zap.Logger) (*Database, error) {
-	var dialect gorm.Dialector
-
-	switch cfg.Driver {
-	case "postgres":
-		dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-			cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DBName, cfg.SSLMode)
-		dialect = postgres.Open(dsn)
-	case "sqlite", "sqlite3":
-		dialect = sqlite.Open(cfg.DBName)
-	default:
-		return nil, fmt.Errorf("unsupported database driver: %s", cfg.Driver)
+func New(cfg config.DatabaseConfig, logger *zap.Logger, opts ...Option) (*Database, error) {
+	var options Options
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	driver, err := normalizeDriver(cfg.Driver)
+	if err != nil {
+		return nil, err
 	}
 
 	// Configure GORM Logger
@@ -43,7 +85,7 @@ func New(cfg config.DatabaseConfig, logger *zap.Logger) (*Database, error) {
 		Logger: gormLog,
 	}
 
-	db, err := gorm.Open(dialect, gormConfig)
+	db, err := gorm.Open(dialector(driver, cfg), gormConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database connection: %w", err)
 	}
@@ -58,18 +100,39 @@ func New(cfg config.DatabaseConfig, logger *zap.Logger) (*Database, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to get sql.DB from gorm: %w", err)
 	}
+	configurePool(sqlDB, cfg)
 
-	if cfg.MaxOpenConns > 0 {
-		sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	d := &Database{DB: db}
+	if len(cfg.Replicas) > 0 {
+		if err := d.useReplicas(driver, cfg, gormLog); err != nil {
+			_ = sqlDB.Close()
+			return nil, err
+		}
 	}
-	if cfg.MaxIdleConns > 0 {
-		sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+
+	if options.Health != nil {
+		options.Health.AddReadinessCheck("database", health.WithTimeout(options.HealthTimeout, d.HealthCheck))
+		// A lost replica degrades the service rather than taking it down
+		for i, r := range d.replicas {
+			options.Health.AddReadinessCheck(fmt.Sprintf("database.replica.%d", i),
+				health.WithTimeout(options.HealthTimeout, r.PingContext), health.WithCritical(false))
+		}
 	}
-	if cfg.ConnMaxLifetime > 0 {
-		sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	if options.MetricsName != "" {
+		interval := options.MetricsInterval
+		if interval <= 0 {
+			interval = defaultMetricsInterval
+		}
+		d.collectors = append(d.collectors, NewMetricsCollector(options.MetricsName, sqlDB, options.Metrics))
+		for i, r := range d.replicas {
+			d.collectors = append(d.collectors,
+				NewMetricsCollector(fmt.Sprintf("%s.replica.%d", options.MetricsName, i), r, options.Metrics))
+		}
+		for _, c := range d.collectors {
+			c.Start(context.Background(), interval)
+		}
 	}
-
-	return &Database{DB: db}, nil
+	return d, nil
 }
 
 // WithTransaction executes a function within a database transaction
@@ -79,6 +142,12 @@ func (d *Database) WithTransaction(ctx context.Context, fn func(txDB *Database)
 	})
 }
 
+// AutoMigrate migrates the schema of dst on the primary. gorm's own
+// AutoMigrate would inspect the existing schema on a replica.
+func (d *Database) AutoMigrate(dst ...interface{}) error {
+	return d.DB.Clauses(dbresolver.Write).AutoMigrate(dst...)
+}
+
 // HealthCheck executes a simple query to verify database connectivity
 func (d *Database) HealthCheck(ctx context.Context) error {
 	sqlDB, err := d.DB.DB()
@@ -88,6 +157,23 @@ func (d *Database) HealthCheck(ctx context.Context) error {
 	return sqlDB.PingContext(ctx)
 }
 
+// Close stops the metrics collection and closes the primary and replica
+// connection pools
+func (d *Database) Close() error {
+	for _, c := range d.collectors {
+		c.Stop()
+	}
+	sqlDB, err := d.DB.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get underlying sql.DB: %w", err)
+	}
+	errs := []error{sqlDB.Close()}
+	for _, r := range d.replicas {
+		errs = append(errs, r.Close())
+	}
+	return errors.Join(errs...)
+}
+
 // NewGormLogger creates a GORM logger that outputs to a Zap logger
 func NewGormLogger(zapLogger *zap.Logger, logLevel string) gormlogger.Interface {
 	var lvl gormlogger.LogLevel
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"grouter/pkg/config"
+	"grouter/pkg/health"
 
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
@@ -49,3 +50,21 @@ func TestNewDatabase_SQLite(t *testing.T) {
 	assert.NoError(t, result.Error)
 	assert.Equal(t, "test", readItem.Name)
 }
+
+func TestNewDatabase_WithHealth(t *testing.T) {
+	hs := health.NewHealthService()
+	db, err := New(config.DatabaseConfig{Driver: "sqlite", DBName: ":memory:"}, zap.NewNop(), WithHealth(hs, 0))
+	assert.NoError(t, err)
+
+	results, err := hs.CheckReadiness()
+	assert.NoError(t, err)
+	assert.Equal(t, "OK", results["database"])
+
+	// A closed connection fails readiness
+	sqlDB, err := db.DB.DB()
+	assert.NoError(t, err)
+	assert.NoError(t, sqlDB.Close())
+	results, err = hs.CheckReadiness()
+	assert.Error(t, err)
+	assert.NotEqual(t, "OK", results["database"])
+}
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/driver/sqlserver"
+	"gorm.io/gorm"
+
+	"grouter/pkg/config"
+)
+
+// Supported drivers
+const (
+	DriverPostgres  = "postgres"
+	DriverMySQL     = "mysql"
+	DriverSQLServer = "sqlserver"
+	DriverSQLite    = "sqlite"
+)
+
+// normalizeDriver maps driver aliases to the supported driver names
+func normalizeDriver(driver string) (string, error) {
+	switch driver {
+	case "postgres", "postgresql":
+		return DriverPostgres, nil
+	case "mysql", "mariadb":
+		return DriverMySQL, nil
+	case "sqlserver", "mssql":
+		return DriverSQLServer, nil
+	case "sqlite", "sqlite3":
+		return DriverSQLite, nil
+	default:
+		return "", fmt.Errorf("unsupported database driver: %s", driver)
+	}
+}
+
+// dsn builds the connection string of the driver. SSLMode uses the
+// PostgreSQL values (disable, require, verify-ca, verify-full) for all drivers.
+func dsn(driver string, cfg config.DatabaseConfig) string {
+	switch driver {
+	case DriverPostgres:
+		return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+			cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DBName, cfg.SSLMode)
+	case DriverMySQL:
+		query := url.Values{}
+		query.Set("parseTime", "true")
+		query.Set("charset", "utf8mb4")
+		switch cfg.SSLMode {
+		case "require":
+			query.Set("tls", "skip-verify")
+		case "verify-ca", "verify-full":
+			query.Set("tls", "true")
+		}
+		return fmt.Sprintf("%s:%s@tcp(%s)/%s?%s",
+			cfg.User, cfg.Password, net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)), cfg.DBName, query.Encode())
+	case DriverSQLServer:
+		query := url.Values{}
+		query.Set("database", cfg.DBName)
+		switch cfg.SSLMode {
+		case "disable":
+			query.Set("encrypt", "disable")
+		case "require":
+			query.Set("encrypt", "true")
+			query.Set("TrustServerCertificate", "true")
+		case "verify-ca", "verify-full":
+			query.Set("encrypt", "true")
+		}
+		u := url.URL{
+			Scheme:   "sqlserver",
+			User:     url.UserPassword(cfg.User, cfg.Password),
+			Host:     net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+			RawQuery: query.Encode(),
+		}
+		return u.String()
+	default:
+		return cfg.DBName
+	}
+}
+
+// dialector opens a new connection for the node
+func dialector(driver string, cfg config.DatabaseConfig) gorm.Dialector {
+	switch driver {
+	case DriverPostgres:
+		return postgres.Open(dsn(driver, cfg))
+	case DriverMySQL:
+		return mysql.Open(dsn(driver, cfg))
+	case DriverSQLServer:
+		return sqlserver.Open(dsn(driver, cfg))
+	default:
+		return sqlite.Open(dsn(driver, cfg))
+	}
+}
+
+// connDialector wraps an already opened connection pool
+func connDialector(driver string, conn gorm.ConnPool) gorm.Dialector {
+	switch driver {
+	case DriverPostgres:
+		return postgres.New(postgres.Config{Conn: conn})
+	case DriverMySQL:
+		return mysql.New(mysql.Config{Conn: conn})
+	case DriverSQLServer:
+		return sqlserver.New(sqlserver.Config{Conn: conn})
+	default:
+		return sqlite.New(sqlite.Config{Conn: conn})
+	}
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"testing"
+
+	"grouter/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeDriver(t *testing.T) {
+	for alias, want := range map[string]string{
+		"postgresql": DriverPostgres,
+		"mariadb":    DriverMySQL,
+		"mssql":      DriverSQLServer,
+		"sqlite3":    DriverSQLite,
+		"mysql":      DriverMySQL,
+	} {
+		got, err := normalizeDriver(alias)
+		require.NoError(t, err)
+		assert.Equal(t, want, got, alias)
+	}
+
+	_, err := normalizeDriver("oracle")
+	assert.Error(t, err)
+}
+
+func TestDSN(t *testing.T) {
+	cfg := config.DatabaseConfig{
+		Host:     "db",
+		Port:     3306,
+		User:     "app",
+		Password: "secret",
+		DBName:   "grouter",
+		SSLMode:  "require",
+	}
+
+	assert.Equal(t, "host=db port=3306 user=app password=secret dbname=grouter sslmode=require",
+		dsn(DriverPostgres, cfg))
+	assert.Equal(t, "app:secret@tcp(db:3306)/grouter?charset=utf8mb4&parseTime=true&tls=skip-verify",
+		dsn(DriverMySQL, cfg))
+
+	cfg.Port = 1433
+	assert.Equal(t, "sqlserver://app:secret@db:1433?TrustServerCertificate=true&database=grouter&encrypt=true",
+		dsn(DriverSQLServer, cfg))
+
+	cfg.SSLMode = "disable"
+	assert.Equal(t, "sqlserver://app:secret@db:1433?database=grouter&encrypt=disable",
+		dsn(DriverSQLServer, cfg))
+	assert.Equal(t, "grouter", dsn(DriverSQLite, cfg))
+}
@@ -1,17 +1,29 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"sync"
 	"time"
 
+	"grouter/pkg/telemetry"
+
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-// MetricsCollector holds the Prometheus metrics for database stats
+// defaultMetricsInterval is the collection interval of WithMetrics when none is given
+const defaultMetricsInterval = 15 * time.Second
+
+// MetricsCollector holds the Prometheus metrics for database stats. Each
+// database is a db_name series, so several databases can share a registry.
 type MetricsCollector struct {
 	dbName string
 	db     *sql.DB
 
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+
 	openConnections  *prometheus.GaugeVec
 	idleConnections  *prometheus.GaugeVec
 	inUseConnections *prometheus.GaugeVec
@@ -19,57 +31,92 @@ type MetricsCollector struct {
 	waitDuration     *prometheus.GaugeVec
 }
 
-// NewMetricsCollector creates a new collector for the given database
-func NewMetricsCollector(dbName string, db *sql.DB) *MetricsCollector {
-	m := &MetricsCollector{
+// NewMetricsCollector creates a new collector for the given database. Metrics
+// are registered in reg (nil uses the global registry); creating a collector
+// again reuses them.
+func NewMetricsCollector(dbName string, db *sql.DB, reg *telemetry.MetricsRegistry) *MetricsCollector {
+	return &MetricsCollector{
 		dbName: dbName,
 		db:     db,
-		openConnections: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		openConnections: reg.GaugeVec(prometheus.GaugeOpts{
 			Name: "db_open_connections",
 			Help: "The number of established connections both in use and idle.",
 		}, []string{"db_name"}),
-		idleConnections: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		idleConnections: reg.GaugeVec(prometheus.GaugeOpts{
 			Name: "db_idle_connections",
 			Help: "The number of idle connections.",
 		}, []string{"db_name"}),
-		inUseConnections: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		inUseConnections: reg.GaugeVec(prometheus.GaugeOpts{
 			Name: "db_in_use_connections",
 			Help: "The number of connections currently in use.",
 		}, []string{"db_name"}),
-		waitCount: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		waitCount: reg.GaugeVec(prometheus.GaugeOpts{
 			Name: "db_wait_count",
 			Help: "The total number of connections waited for.",
 		}, []string{"db_name"}),
-		waitDuration: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		waitDuration: reg.GaugeVec(prometheus.GaugeOpts{
 			Name: "db_wait_duration_seconds",
 			Help: "The total time blocked waiting for a new connection.",
 		}, []string{"db_name"}),
 	}
+}
 
-	// Register metrics with the global registry
-	prometheus.MustRegister(m.openConnections)
-	prometheus.MustRegister(m.idleConnections)
-	prometheus.MustRegister(m.inUseConnections)
-	prometheus.MustRegister(m.waitCount)
-	prometheus.MustRegister(m.waitDuration)
-
-	return m
+// Start collects the stats every interval in the background until ctx is
+// cancelled or Stop is called. Starting a running collector does nothing.
+func (m *MetricsCollector) Start(ctx context.Context, interval time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cancel != nil {
+		return
+	}
+	ctx, m.cancel = context.WithCancel(ctx)
+	m.done = make(chan struct{})
+	go m.run(ctx, interval, m.done)
 }
 
-// Start begins collecting metrics in the background
-func (m *MetricsCollector) Start(interval time.Duration) {
-	go func() {
-		ticker := time.NewTicker(interval)
-		defer ticker.Stop()
+// Stop ends the collection and removes the series of this database, so a
+// closed database does not keep reporting its last stats
+func (m *MetricsCollector) Stop() {
+	m.mu.Lock()
+	cancel, done := m.cancel, m.done
+	m.cancel, m.done = nil, nil
+	m.mu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+
+	for _, g := range m.gauges() {
+		g.DeleteLabelValues(m.dbName)
+	}
+}
 
-		for range ticker.C {
-			stats := m.db.Stats()
+func (m *MetricsCollector) run(ctx context.Context, interval time.Duration, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
-			m.openConnections.WithLabelValues(m.dbName).Set(float64(stats.OpenConnections))
-			m.idleConnections.WithLabelValues(m.dbName).Set(float64(stats.Idle))
-			m.inUseConnections.WithLabelValues(m.dbName).Set(float64(stats.InUse))
-			m.waitCount.WithLabelValues(m.dbName).Set(float64(stats.WaitCount))
-			m.waitDuration.WithLabelValues(m.dbName).Set(stats.WaitDuration.Seconds())
+	for {
+		m.collect()
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
 		}
-	}()
+	}
+}
+
+func (m *MetricsCollector) collect() {
+	stats := m.db.Stats()
+
+	m.openConnections.WithLabelValues(m.dbName).Set(float64(stats.OpenConnections))
+	m.idleConnections.WithLabelValues(m.dbName).Set(float64(stats.Idle))
+	m.inUseConnections.WithLabelValues(m.dbName).Set(float64(stats.InUse))
+	m.waitCount.WithLabelValues(m.dbName).Set(float64(stats.WaitCount))
+	m.waitDuration.WithLabelValues(m.dbName).Set(stats.WaitDuration.Seconds())
+}
+
+func (m *MetricsCollector) gauges() []*prometheus.GaugeVec {
+	return []*prometheus.GaugeVec{m.openConnections, m.idleConnections, m.inUseConnections, m.waitCount, m.waitDuration}
 }
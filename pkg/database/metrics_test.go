@@ -0,0 +1,106 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"grouter/pkg/config"
+	"grouter/pkg/telemetry"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func openTestSQLDB(t *testing.T) *sql.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "metrics.db")), &gorm.Config{})
+	assert.NoError(t, err)
+	sqlDB, err := db.DB()
+	assert.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	return sqlDB
+}
+
+func TestMetricsCollector_Registry(t *testing.T) {
+	reg := telemetry.NewMetricsRegistry()
+	sqlDB := openTestSQLDB(t)
+
+	// Collectors can be created again, e.g. after a reconnect
+	first := NewMetricsCollector("main", sqlDB, reg)
+	second := NewMetricsCollector("main", sqlDB, reg)
+	assert.Same(t, first.openConnections, second.openConnections)
+
+	assert.NoError(t, sqlDB.Ping())
+	second.Start(context.Background(), 10*time.Millisecond)
+	defer second.Stop()
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(second.idleConnections.WithLabelValues("main")) >= 1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestMetricsCollector_MultipleDatabases(t *testing.T) {
+	reg := telemetry.NewMetricsRegistry()
+	mainDB, auditDB := openTestSQLDB(t), openTestSQLDB(t)
+	assert.NoError(t, mainDB.Ping())
+
+	main := NewMetricsCollector("main", mainDB, reg)
+	audit := NewMetricsCollector("audit", auditDB, reg)
+	main.Start(context.Background(), time.Hour)
+	audit.Start(context.Background(), time.Hour)
+
+	// Both databases report their own series
+	assert.Eventually(t, func() bool {
+		return testutil.CollectAndCount(main.openConnections) == 2 &&
+			testutil.ToFloat64(main.openConnections.WithLabelValues("main")) >= 1
+	}, time.Second, 10*time.Millisecond)
+
+	// Stopping one removes only its series
+	audit.Stop()
+	assert.Equal(t, 1, testutil.CollectAndCount(main.openConnections))
+	main.Stop()
+	assert.Equal(t, 0, testutil.CollectAndCount(main.openConnections))
+
+	// Stop is idempotent
+	main.Stop()
+}
+
+func TestMetricsCollector_ContextCancel(t *testing.T) {
+	m := NewMetricsCollector("main", openTestSQLDB(t), telemetry.NewMetricsRegistry())
+	ctx, cancel := context.WithCancel(context.Background())
+	m.Start(ctx, 10*time.Millisecond)
+	done := m.done
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("collector did not stop on context cancellation")
+	}
+	m.Stop()
+}
+
+func TestNew_WithMetrics(t *testing.T) {
+	reg := telemetry.NewMetricsRegistry()
+	dir := t.TempDir()
+	db, err := New(config.DatabaseConfig{
+		Driver:   "sqlite",
+		DBName:   filepath.Join(dir, "primary.db"),
+		Replicas: []config.DatabaseReplicaConfig{{DBName: filepath.Join(dir, "replica.db")}},
+	}, zap.NewNop(), WithMetrics("orders", reg, time.Hour))
+	assert.NoError(t, err)
+
+	// The primary and the replica are reported on start
+	gauge := db.collectors[0].openConnections
+	assert.Eventually(t, func() bool {
+		return testutil.CollectAndCount(gauge) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	// Closing the database ends the collection and removes its series
+	assert.NoError(t, db.Close())
+	assert.Equal(t, 0, testutil.CollectAndCount(gauge))
+}
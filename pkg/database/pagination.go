@@ -1,11 +1,30 @@
 package database
 
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
 // Pagination holds pagination and sorting parameters
 type Pagination struct {
 	Page     int
 	PageSize int
 	Sort     string                 // e.g., "created_at desc"
 	Filters  map[string]interface{} // Dynamic filters, e.g., {"status": "active"}
+	// Conditions are filters with an operator, e.g. {"age", OpGte, 18}
+	Conditions []Filter
+	// IncludeDeleted also lists soft-deleted records
+	IncludeDeleted bool
 }
 
 // GetOffset computes the SQL offset
@@ -26,3 +45,223 @@ func (p Pagination) GetLimit() int {
 	}
 	return p.PageSize
 }
+
+// TotalPages returns the number of pages needed for total records
+func (p Pagination) TotalPages(total int64) int {
+	limit := int64(p.GetLimit())
+	return int((total + limit - 1) / limit)
+}
+
+// CursorPagination holds keyset pagination parameters. Pages are ordered by
+// Field, which must be unique and sortable (default the primary key), so
+// pages stay stable while records are inserted.
+type CursorPagination struct {
+	// Cursor is the NextCursor of the previous page; empty starts at the first page
+	Cursor string
+	Limit  int
+	// Field is the struct field or column to order by
+	Field string
+	// Desc orders from the highest value down
+	Desc           bool
+	Filters        map[string]interface{}
+	Conditions     []Filter
+	IncludeDeleted bool
+}
+
+// GetLimit returns the page size constraint
+func (p CursorPagination) GetLimit() int {
+	if p.Limit < 1 {
+		return 10
+	}
+	return p.Limit
+}
+
+// CursorPage is a page of a cursor listing
+type CursorPage[T any] struct {
+	Items []T
+	// NextCursor fetches the following page; empty on the last page
+	NextCursor string
+	HasMore    bool
+}
+
+// Filter operators
+const (
+	OpEq   = "eq"
+	OpNe   = "ne"
+	OpGt   = "gt"
+	OpGte  = "gte"
+	OpLt   = "lt"
+	OpLte  = "lte"
+	OpLike = "like"
+	OpIn   = "in"
+)
+
+// Filter is a condition on a column
+type Filter struct {
+	Field string
+	Op    string
+	Value interface{}
+}
+
+// columnPattern restricts filter and sort columns to plain identifiers, as
+// they typically come from query parameters
+var columnPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+func column(name string) (clause.Column, error) {
+	if !columnPattern.MatchString(name) {
+		return clause.Column{}, fmt.Errorf("invalid column name: %q", name)
+	}
+	if table, col, ok := strings.Cut(name, "."); ok {
+		return clause.Column{Table: table, Name: col}, nil
+	}
+	return clause.Column{Name: name}, nil
+}
+
+// expression converts the filter to a clause expression
+func (f Filter) expression() (clause.Expression, error) {
+	col, err := column(f.Field)
+	if err != nil {
+		return nil, err
+	}
+	switch f.Op {
+	case OpEq, "":
+		return clause.Eq{Column: col, Value: f.Value}, nil
+	case OpNe:
+		return clause.Neq{Column: col, Value: f.Value}, nil
+	case OpGt:
+		return clause.Gt{Column: col, Value: f.Value}, nil
+	case OpGte:
+		return clause.Gte{Column: col, Value: f.Value}, nil
+	case OpLt:
+		return clause.Lt{Column: col, Value: f.Value}, nil
+	case OpLte:
+		return clause.Lte{Column: col, Value: f.Value}, nil
+	case OpLike:
+		return clause.Like{Column: col, Value: f.Value}, nil
+	case OpIn:
+		v := reflect.ValueOf(f.Value)
+		if v.Kind() != reflect.Slice {
+			return nil, fmt.Errorf("filter %q: in requires a slice value", f.Field)
+		}
+		values := make([]interface{}, v.Len())
+		for i := range values {
+			values[i] = v.Index(i).Interface()
+		}
+		return clause.IN{Column: col, Values: values}, nil
+	default:
+		return nil, fmt.Errorf("filter %q: unsupported operator %q", f.Field, f.Op)
+	}
+}
+
+// applyFilters adds the equality filters and conditions to db
+func applyFilters(db *gorm.DB, filters map[string]interface{}, conditions []Filter) (*gorm.DB, error) {
+	fields := make([]string, 0, len(filters))
+	for field := range filters {
+		fields = append(fields, field)
+	}
+	// Sorted so that the same filters always produce the same statement
+	sort.Strings(fields)
+	for _, field := range fields {
+		conditions = append(conditions, Filter{Field: field, Op: OpEq, Value: filters[field]})
+	}
+	for _, f := range conditions {
+		expr, err := f.expression()
+		if err != nil {
+			return nil, err
+		}
+		db = db.Where(expr)
+	}
+	return db, nil
+}
+
+// parseSort converts "name asc, created_at desc" to an ORDER BY clause
+func parseSort(spec string) (clause.OrderBy, error) {
+	var order clause.OrderBy
+	for _, part := range strings.Split(spec, ",") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 || len(fields) > 2 {
+			return order, fmt.Errorf("invalid sort: %q", spec)
+		}
+		col, err := column(fields[0])
+		if err != nil {
+			return order, err
+		}
+		desc := false
+		if len(fields) == 2 {
+			switch strings.ToLower(fields[1]) {
+			case "asc":
+			case "desc":
+				desc = true
+			default:
+				return order, fmt.Errorf("invalid sort direction: %q", fields[1])
+			}
+		}
+		order.Columns = append(order.Columns, clause.OrderByColumn{Column: col, Desc: desc})
+	}
+	return order, nil
+}
+
+// cursor is the decoded form of an opaque cursor. The kind keeps the value
+// type so that it binds to the column as it was read.
+type cursor struct {
+	Kind  string `json:"k"`
+	Value string `json:"v"`
+}
+
+func encodeCursor(value interface{}) (string, error) {
+	var c cursor
+	switch v := value.(type) {
+	case time.Time:
+		c = cursor{Kind: "time", Value: v.Format(time.RFC3339Nano)}
+	case string:
+		c = cursor{Kind: "string", Value: v}
+	default:
+		rv := reflect.ValueOf(value)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			c = cursor{Kind: "int", Value: strconv.FormatInt(rv.Int(), 10)}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			c = cursor{Kind: "uint", Value: strconv.FormatUint(rv.Uint(), 10)}
+		case reflect.Float32, reflect.Float64:
+			c = cursor{Kind: "float", Value: strconv.FormatFloat(rv.Float(), 'g', -1, 64)}
+		default:
+			return "", fmt.Errorf("unsupported cursor field type %T", value)
+		}
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(s string) (interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	var value interface{}
+	switch c.Kind {
+	case "time":
+		value, err = time.Parse(time.RFC3339Nano, c.Value)
+	case "string":
+		value = c.Value
+	case "int":
+		value, err = strconv.ParseInt(c.Value, 10, 64)
+	case "uint":
+		value, err = strconv.ParseUint(c.Value, 10, 64)
+	case "float":
+		value, err = strconv.ParseFloat(c.Value, 64)
+	default:
+		err = fmt.Errorf("unknown kind %q", c.Kind)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+	return value, nil
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPagination_TotalPages(t *testing.T) {
+	assert.Equal(t, 0, Pagination{PageSize: 10}.TotalPages(0))
+	assert.Equal(t, 1, Pagination{PageSize: 10}.TotalPages(10))
+	assert.Equal(t, 3, Pagination{PageSize: 10}.TotalPages(21))
+	assert.Equal(t, 2, Pagination{}.TotalPages(11)) // default page size 10
+}
+
+func TestParseSort(t *testing.T) {
+	order, err := parseSort("name, created_at DESC")
+	require.NoError(t, err)
+	require.Len(t, order.Columns, 2)
+	assert.Equal(t, "name", order.Columns[0].Column.Name)
+	assert.False(t, order.Columns[0].Desc)
+	assert.Equal(t, "created_at", order.Columns[1].Column.Name)
+	assert.True(t, order.Columns[1].Desc)
+
+	for _, bad := range []string{"", "name sideways", "name asc extra", "name; drop", "(select 1)"} {
+		_, err := parseSort(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestCursorEncoding(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 6, time.UTC)
+	for _, tc := range []struct {
+		in   interface{}
+		want interface{}
+	}{
+		{uint(42), uint64(42)},
+		{int32(-7), int64(-7)},
+		{"abc", "abc"},
+		{1.5, 1.5},
+		{now, now},
+	} {
+		c, err := encodeCursor(tc.in)
+		require.NoError(t, err)
+		got, err := decodeCursor(c)
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, got)
+	}
+
+	_, err := encodeCursor(struct{}{})
+	assert.Error(t, err)
+	_, err = decodeCursor("!!")
+	assert.Error(t, err)
+}
@@ -2,17 +2,34 @@ package database
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"reflect"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
 )
 
-// Repository defines the standard CRUD interface for any entity T
+// ErrNotFound is returned when no record matches
+var ErrNotFound = gorm.ErrRecordNotFound
+
+// ErrSoftDeleteUnsupported is returned by Restore for models without a
+// gorm.DeletedAt field
+var ErrSoftDeleteUnsupported = errors.New("model does not support soft delete")
+
+// Repository defines the standard CRUD interface for any entity T. Models
+// with a gorm.DeletedAt field are soft-deleted: Delete hides them from reads,
+// Restore brings them back and ForceDelete removes them permanently.
 type Repository[T any] interface {
 	Create(ctx context.Context, entity *T) error
 	FindByID(ctx context.Context, id interface{}) (*T, error)
 	List(ctx context.Context, pagination Pagination) ([]T, int64, error)
+	ListCursor(ctx context.Context, pagination CursorPagination) (*CursorPage[T], error)
 	Update(ctx context.Context, entity *T) error
 	Delete(ctx context.Context, id interface{}) error
+	Restore(ctx context.Context, id interface{}) error
+	ForceDelete(ctx context.Context, id interface{}) error
 }
 
 // GORMRepository implements Repository[T] using GORM
@@ -37,15 +54,22 @@ func (r *GORMRepository[T]) FindByID(ctx context.Context, id interface{}) (*T, e
 	return &entity, nil
 }
 
+// query starts a filtered query on the model
+func (r *GORMRepository[T]) query(ctx context.Context, filters map[string]interface{}, conditions []Filter, includeDeleted bool) (*gorm.DB, error) {
+	db := r.db.WithContext(ctx).Model(new(T))
+	if includeDeleted {
+		db = db.Unscoped()
+	}
+	return applyFilters(db, filters, conditions)
+}
+
 func (r *GORMRepository[T]) List(ctx context.Context, p Pagination) ([]T, int64, error) {
 	var entities []T
 	var total int64
 
-	db := r.db.WithContext(ctx).Model(new(T))
-
-	// Apply filters
-	if len(p.Filters) > 0 {
-		db = db.Where(p.Filters)
+	db, err := r.query(ctx, p.Filters, p.Conditions, p.IncludeDeleted)
+	if err != nil {
+		return nil, 0, err
 	}
 
 	// Count total records (after filters)
@@ -55,11 +79,15 @@ func (r *GORMRepository[T]) List(ctx context.Context, p Pagination) ([]T, int64,
 
 	// Apply sorting
 	if p.Sort != "" {
-		db = db.Order(p.Sort)
+		order, err := parseSort(p.Sort)
+		if err != nil {
+			return nil, 0, err
+		}
+		db = db.Clauses(order)
 	}
 
 	// Apply pagination
-	err := db.Offset(p.GetOffset()).Limit(p.GetLimit()).Find(&entities).Error
+	err = db.Offset(p.GetOffset()).Limit(p.GetLimit()).Find(&entities).Error
 	if err != nil {
 		return nil, 0, err
 	}
@@ -67,11 +95,110 @@ func (r *GORMRepository[T]) List(ctx context.Context, p Pagination) ([]T, int64,
 	return entities, total, nil
 }
 
+// ListCursor returns the page following p.Cursor, ordered by p.Field
+func (r *GORMRepository[T]) ListCursor(ctx context.Context, p CursorPagination) (*CursorPage[T], error) {
+	s, err := r.schema()
+	if err != nil {
+		return nil, err
+	}
+	field := s.PrioritizedPrimaryField
+	if p.Field != "" {
+		field = s.LookUpField(p.Field)
+	}
+	if field == nil || field.DBName == "" {
+		return nil, fmt.Errorf("invalid cursor field %q for %s", p.Field, s.Name)
+	}
+
+	db, err := r.query(ctx, p.Filters, p.Conditions, p.IncludeDeleted)
+	if err != nil {
+		return nil, err
+	}
+	col := clause.Column{Table: clause.CurrentTable, Name: field.DBName}
+	if p.Cursor != "" {
+		after, err := decodeCursor(p.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if p.Desc {
+			db = db.Where(clause.Lt{Column: col, Value: after})
+		} else {
+			db = db.Where(clause.Gt{Column: col, Value: after})
+		}
+	}
+
+	// Fetch one extra record to know whether another page follows
+	limit := p.GetLimit()
+	var items []T
+	err = db.Order(clause.OrderByColumn{Column: col, Desc: p.Desc}).Limit(limit + 1).Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+
+	page := &CursorPage[T]{Items: items}
+	if len(items) > limit {
+		page.Items = items[:limit]
+		page.HasMore = true
+		last, _ := field.ValueOf(ctx, reflect.ValueOf(&page.Items[limit-1]).Elem())
+		if page.NextCursor, err = encodeCursor(last); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
 func (r *GORMRepository[T]) Update(ctx context.Context, entity *T) error {
 	return r.db.WithContext(ctx).Save(entity).Error
 }
 
+// Delete soft-deletes the record if the model supports it, otherwise removes it
 func (r *GORMRepository[T]) Delete(ctx context.Context, id interface{}) error {
 	var entity T
 	return r.db.WithContext(ctx).Delete(&entity, id).Error
 }
+
+// Restore undoes a soft delete
+func (r *GORMRepository[T]) Restore(ctx context.Context, id interface{}) error {
+	s, err := r.schema()
+	if err != nil {
+		return err
+	}
+	var deletedAt *schema.Field
+	for _, f := range s.Fields {
+		if f.FieldType == reflect.TypeOf(gorm.DeletedAt{}) {
+			deletedAt = f
+			break
+		}
+	}
+	if deletedAt == nil {
+		return ErrSoftDeleteUnsupported
+	}
+	if s.PrioritizedPrimaryField == nil {
+		return fmt.Errorf("model %s has no primary key", s.Name)
+	}
+
+	res := r.db.WithContext(ctx).Unscoped().Model(new(T)).
+		Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: s.PrioritizedPrimaryField.DBName}, Value: id}).
+		Update(deletedAt.DBName, nil)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ForceDelete permanently removes the record, even if it is soft-deleted
+func (r *GORMRepository[T]) ForceDelete(ctx context.Context, id interface{}) error {
+	var entity T
+	return r.db.WithContext(ctx).Unscoped().Delete(&entity, id).Error
+}
+
+// schema returns the parsed GORM schema of T
+func (r *GORMRepository[T]) schema() (*schema.Schema, error) {
+	stmt := &gorm.Statement{DB: r.db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, fmt.Errorf("failed to parse model: %w", err)
+	}
+	return stmt.Schema, nil
+}
@@ -7,7 +7,9 @@ import (
 	"grouter/pkg/config"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 type User struct {
@@ -93,3 +95,131 @@ func TestGORMRepository(t *testing.T) {
 	_, err = repo.FindByID(ctx, user.ID)
 	assert.Error(t, err) // Should not find
 }
+
+type Account struct {
+	ID        uint `gorm:"primarykey"`
+	Name      string
+	Age       int
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+func newAccountRepo(t *testing.T, names ...string) Repository[Account] {
+	t.Helper()
+	db, err := New(config.DatabaseConfig{Driver: "sqlite", DBName: ":memory:", LogLevel: "silent"}, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&Account{}))
+
+	repo := NewRepository[Account](db.DB)
+	for i, name := range names {
+		require.NoError(t, repo.Create(context.Background(), &Account{Name: name, Age: 20 + i}))
+	}
+	return repo
+}
+
+func TestGORMRepository_Conditions(t *testing.T) {
+	repo := newAccountRepo(t, "alice", "bob", "carol", "dave")
+	ctx := context.Background()
+
+	accounts, total, err := repo.List(ctx, Pagination{
+		Conditions: []Filter{{Field: "age", Op: OpGte, Value: 21}, {Field: "name", Op: OpNe, Value: "dave"}},
+		Sort:       "age desc",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Equal(t, "carol", accounts[0].Name)
+	assert.Equal(t, "bob", accounts[1].Name)
+
+	accounts, _, err = repo.List(ctx, Pagination{
+		Conditions: []Filter{{Field: "name", Op: OpIn, Value: []string{"alice", "dave"}}},
+	})
+	require.NoError(t, err)
+	assert.Len(t, accounts, 2)
+
+	accounts, _, err = repo.List(ctx, Pagination{
+		Conditions: []Filter{{Field: "name", Op: OpLike, Value: "%a%"}},
+	})
+	require.NoError(t, err)
+	assert.Len(t, accounts, 3)
+
+	_, _, err = repo.List(ctx, Pagination{Conditions: []Filter{{Field: "age", Op: "between", Value: 1}}})
+	assert.Error(t, err)
+	_, _, err = repo.List(ctx, Pagination{Sort: "name; DROP TABLE accounts"})
+	assert.Error(t, err)
+	_, _, err = repo.List(ctx, Pagination{Filters: map[string]interface{}{"1=1 OR name": "x"}})
+	assert.Error(t, err)
+}
+
+func TestGORMRepository_ListCursor(t *testing.T) {
+	repo := newAccountRepo(t, "a", "b", "c", "d", "e")
+	ctx := context.Background()
+
+	var names []string
+	p := CursorPagination{Limit: 2}
+	for {
+		page, err := repo.ListCursor(ctx, p)
+		require.NoError(t, err)
+		for _, a := range page.Items {
+			names = append(names, a.Name)
+		}
+		if !page.HasMore {
+			assert.Empty(t, page.NextCursor)
+			break
+		}
+		p.Cursor = page.NextCursor
+	}
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, names)
+
+	// Descending by another field, filtered
+	page, err := repo.ListCursor(ctx, CursorPagination{
+		Limit:      2,
+		Field:      "Age",
+		Desc:       true,
+		Conditions: []Filter{{Field: "age", Op: OpLt, Value: 24}},
+	})
+	require.NoError(t, err)
+	require.True(t, page.HasMore)
+	assert.Equal(t, "d", page.Items[0].Name)
+	assert.Equal(t, "c", page.Items[1].Name)
+
+	page, err = repo.ListCursor(ctx, CursorPagination{Limit: 2, Field: "Age", Desc: true, Cursor: page.NextCursor,
+		Conditions: []Filter{{Field: "age", Op: OpLt, Value: 24}}})
+	require.NoError(t, err)
+	assert.False(t, page.HasMore)
+	assert.Equal(t, "b", page.Items[0].Name)
+	assert.Equal(t, "a", page.Items[1].Name)
+
+	_, err = repo.ListCursor(ctx, CursorPagination{Field: "missing"})
+	assert.Error(t, err)
+	_, err = repo.ListCursor(ctx, CursorPagination{Cursor: "not-a-cursor"})
+	assert.Error(t, err)
+}
+
+func TestGORMRepository_SoftDelete(t *testing.T) {
+	repo := newAccountRepo(t, "alice", "bob")
+	ctx := context.Background()
+
+	require.NoError(t, repo.Delete(ctx, 1))
+	_, err := repo.FindByID(ctx, 1)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, total, err := repo.List(ctx, Pagination{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	_, total, err = repo.List(ctx, Pagination{IncludeDeleted: true})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+
+	require.NoError(t, repo.Restore(ctx, 1))
+	restored, err := repo.FindByID(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", restored.Name)
+	assert.ErrorIs(t, repo.Restore(ctx, 99), ErrNotFound)
+
+	require.NoError(t, repo.ForceDelete(ctx, 1))
+	_, total, err = repo.List(ctx, Pagination{IncludeDeleted: true})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+
+	users := NewRepository[User](repo.(*GORMRepository[Account]).db)
+	assert.ErrorIs(t, users.Restore(ctx, 1), ErrSoftDeleteUnsupported)
+}
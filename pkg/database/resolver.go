@@ -0,0 +1,108 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+	"gorm.io/plugin/dbresolver"
+
+	"grouter/pkg/config"
+)
+
+// Replica policies
+const (
+	ReplicaPolicyRandom     = "random"
+	ReplicaPolicyRoundRobin = "round_robin"
+)
+
+// replicaConfig returns the node config of a replica, inheriting the
+// primary's values for empty fields
+func replicaConfig(primary config.DatabaseConfig, r config.DatabaseReplicaConfig) config.DatabaseConfig {
+	node := primary
+	if r.Host != "" {
+		node.Host = r.Host
+	}
+	if r.Port != 0 {
+		node.Port = r.Port
+	}
+	if r.User != "" {
+		node.User = r.User
+	}
+	if r.Password != "" {
+		node.Password = r.Password
+	}
+	if r.DBName != "" {
+		node.DBName = r.DBName
+	}
+	return node
+}
+
+func replicaPolicy(name string) (dbresolver.Policy, error) {
+	switch name {
+	case "", ReplicaPolicyRandom:
+		return dbresolver.RandomPolicy{}, nil
+	case ReplicaPolicyRoundRobin:
+		return dbresolver.RoundRobinPolicy(), nil
+	default:
+		return nil, fmt.Errorf("unsupported replica policy: %s", name)
+	}
+}
+
+// useReplicas routes reads to the replicas and writes to the primary. The
+// replica pools are opened here, rather than by dbresolver, so that each of
+// them can be health checked.
+func (d *Database) useReplicas(driver string, cfg config.DatabaseConfig, gormLog gormlogger.Interface) (err error) {
+	policy, err := replicaPolicy(cfg.ReplicaPolicy)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			for _, r := range d.replicas {
+				_ = r.Close()
+			}
+			d.replicas = nil
+		}
+	}()
+
+	dialectors := make([]gorm.Dialector, 0, len(cfg.Replicas))
+	for i, r := range cfg.Replicas {
+		// gorm.Open stores the pool in its config, so each node needs its own
+		node, err := gorm.Open(dialector(driver, replicaConfig(cfg, r)), &gorm.Config{Logger: gormLog})
+		if err != nil {
+			return fmt.Errorf("failed to open replica %d: %w", i, err)
+		}
+		sqlDB, err := node.DB()
+		if err != nil {
+			return fmt.Errorf("failed to get sql.DB of replica %d: %w", i, err)
+		}
+		configurePool(sqlDB, cfg)
+		d.replicas = append(d.replicas, sqlDB)
+		dialectors = append(dialectors, connDialector(driver, sqlDB))
+	}
+
+	if err := d.DB.Use(dbresolver.Register(dbresolver.Config{
+		Replicas:          dialectors,
+		Policy:            policy,
+		TraceResolverMode: true,
+	})); err != nil {
+		return fmt.Errorf("failed to register replicas: %w", err)
+	}
+	return nil
+}
+
+// configurePool applies the connection pool settings
+func configurePool(sqlDB *sql.DB, cfg config.DatabaseConfig) {
+	if cfg.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+}
@@ -0,0 +1,90 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"grouter/pkg/config"
+	"grouter/pkg/health"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestNew_ReadReplicas(t *testing.T) {
+	dir := t.TempDir()
+	primaryPath := filepath.Join(dir, "primary.db")
+	replicaPath := filepath.Join(dir, "replica.db")
+
+	// Seed the replica with a row that only it has
+	seed, err := New(config.DatabaseConfig{Driver: "sqlite", DBName: replicaPath}, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, seed.AutoMigrate(&User{}))
+	require.NoError(t, seed.Create(&User{Name: "from-replica"}).Error)
+	require.NoError(t, seed.Close())
+
+	hs := health.NewHealthService()
+	db, err := New(config.DatabaseConfig{
+		Driver:        "sqlite",
+		DBName:        primaryPath,
+		LogLevel:      "silent",
+		Replicas:      []config.DatabaseReplicaConfig{{DBName: replicaPath}},
+		ReplicaPolicy: ReplicaPolicyRoundRobin,
+	}, zap.NewNop(), WithHealth(hs, 0))
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, db.AutoMigrate(&User{}))
+
+	ctx := context.Background()
+	repo := NewRepository[User](db.DB)
+	require.NoError(t, repo.Create(ctx, &User{Name: "written"}))
+
+	// Reads go to the replica
+	users, _, err := repo.List(ctx, Pagination{})
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, "from-replica", users[0].Name)
+
+	// Writes and transactions go to the primary
+	err = db.WithTransaction(ctx, func(tx *Database) error {
+		var names []string
+		if err := tx.Model(&User{}).Pluck("name", &names).Error; err != nil {
+			return err
+		}
+		assert.Equal(t, []string{"written"}, names)
+		return nil
+	})
+	require.NoError(t, err)
+
+	checks, err := hs.CheckReadiness()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"database": "OK", "database.replica.0": "OK"}, checks)
+
+	// A lost replica degrades readiness instead of failing it
+	require.NoError(t, db.replicas[0].Close())
+	report, err := hs.Report(health.ProbeReady)
+	require.NoError(t, err)
+	assert.Equal(t, health.StatusDegraded, report.Status)
+}
+
+func TestNew_InvalidReplicaPolicy(t *testing.T) {
+	_, err := New(config.DatabaseConfig{
+		Driver:        "sqlite",
+		DBName:        ":memory:",
+		Replicas:      []config.DatabaseReplicaConfig{{}},
+		ReplicaPolicy: "fastest",
+	}, zap.NewNop())
+	assert.Error(t, err)
+}
+
+func TestReplicaConfig(t *testing.T) {
+	primary := config.DatabaseConfig{Driver: "postgres", Host: "primary", Port: 5432, User: "app", Password: "secret", DBName: "grouter"}
+	node := replicaConfig(primary, config.DatabaseReplicaConfig{Host: "replica-1", Port: 5433})
+	assert.Equal(t, "replica-1", node.Host)
+	assert.Equal(t, 5433, node.Port)
+	assert.Equal(t, "app", node.User)
+	assert.Equal(t, "secret", node.Password)
+	assert.Equal(t, "grouter", node.DBName)
+}
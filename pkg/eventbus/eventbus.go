@@ -0,0 +1,208 @@
+// Package eventbus is an in-process publish/subscribe bus for the local
+// events of the services, with typed topics. Selected topics are bridged to
+// NATS subjects, so that their events are also published externally.
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	messaging "grouter/pkg/messaging/nats"
+
+	"go.uber.org/zap"
+)
+
+// Handler receives the events of a topic
+type Handler[T any] func(ctx context.Context, event T) error
+
+// Bus dispatches the events published on its topics to their handlers
+type Bus struct {
+	logger *zap.Logger
+
+	mu        sync.RWMutex
+	topics    map[string]*topic
+	publisher messaging.Publisher
+}
+
+// topic is the state of a topic, shared by the Topic values of its name
+type topic struct {
+	// typ is the type of the events, nil until a Topic is created
+	typ      reflect.Type
+	next     uint64
+	handlers []subscription
+	// subject is the NATS subject of the bridged topic, or ""
+	subject string
+}
+
+type subscription struct {
+	id uint64
+	fn func(ctx context.Context, event any) error
+}
+
+// New creates a Bus; a nil logger discards the logs
+func New(logger *zap.Logger) *Bus {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &Bus{logger: logger, topics: make(map[string]*topic)}
+}
+
+// topic returns the topic name, created on first use. b.mu must be held.
+func (b *Bus) topic(name string) *topic {
+	t, ok := b.topics[name]
+	if !ok {
+		t = &topic{}
+		b.topics[name] = t
+	}
+	return t
+}
+
+// SetPublisher sets the publisher of the bridged topics; until then, or with
+// nil, their events stay local
+func (b *Bus) SetPublisher(p messaging.Publisher) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.publisher = p
+}
+
+// Bridge publishes the events of the topic name on the NATS subject as well,
+// with the topic name as message type. An empty subject stops bridging.
+// Events received on subject are not published on the bus.
+func (b *Bus) Bridge(name, subject string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.topic(name).subject = subject
+}
+
+// Bridges returns the bridged topics and their subjects
+func (b *Bus) Bridges() map[string]string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	bridges := make(map[string]string)
+	for name, t := range b.topics {
+		if t.subject != "" {
+			bridges[name] = t.subject
+		}
+	}
+	return bridges
+}
+
+// Topics returns the names of the topics, sorted
+func (b *Bus) Topics() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	names := make([]string, 0, len(b.topics))
+	for name := range b.topics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Topic is a topic of events of type T
+type Topic[T any] struct {
+	bus  *Bus
+	name string
+}
+
+// NewTopic returns the topic name of bus. It panics when the topic was
+// created with another event type, as it would deliver events of the wrong
+// type.
+func NewTopic[T any](bus *Bus, name string) *Topic[T] {
+	typ := reflect.TypeFor[T]()
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	t := bus.topic(name)
+	if t.typ == nil {
+		t.typ = typ
+	} else if t.typ != typ {
+		panic(fmt.Sprintf("eventbus: topic %q has events of type %s, not %s", name, t.typ, typ))
+	}
+	return &Topic[T]{bus: bus, name: name}
+}
+
+// Name returns the name of the topic
+func (t *Topic[T]) Name() string {
+	return t.name
+}
+
+// Bridge publishes the events of the topic on the NATS subject as well, see
+// Bus.Bridge
+func (t *Topic[T]) Bridge(subject string) {
+	t.bus.Bridge(t.name, subject)
+}
+
+// Subscribe calls h with the events of the topic, until the returned function
+// is called
+func (t *Topic[T]) Subscribe(h Handler[T]) (unsubscribe func()) {
+	b := t.bus
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	tp := b.topic(t.name)
+	id := tp.next
+	tp.next++
+	tp.handlers = append(tp.handlers, subscription{id: id, fn: func(ctx context.Context, event any) error {
+		return h(ctx, event.(T))
+	}})
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			tp.handlers = removeSubscription(tp.handlers, id)
+		})
+	}
+}
+
+func removeSubscription(subs []subscription, id uint64) []subscription {
+	kept := make([]subscription, 0, len(subs))
+	for _, s := range subs {
+		if s.id != id {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
+
+// Publish calls the handlers of the topic with event, one after the other in
+// the order they subscribed, then publishes it on the NATS subject of a
+// bridged topic. The errors of the handlers and of the bridge are joined;
+// a failed handler does not stop the others.
+func (t *Topic[T]) Publish(ctx context.Context, event T) error {
+	b := t.bus
+	b.mu.RLock()
+	// Created by NewTopic
+	tp := b.topics[t.name]
+	handlers, subject, publisher := tp.handlers, tp.subject, b.publisher
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, s := range handlers {
+		if err := call(ctx, s.fn, event); err != nil {
+			errs = append(errs, fmt.Errorf("handler of %s failed: %w", t.name, err))
+		}
+	}
+	if subject != "" {
+		if publisher == nil {
+			b.logger.Debug("Event bus not connected to NATS, event kept local",
+				zap.String("topic", t.name), zap.String("subject", subject))
+		} else if err := publisher.Publish(ctx, subject, t.name, event, nil); err != nil {
+			errs = append(errs, fmt.Errorf("failed to bridge %s to %s: %w", t.name, subject, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// call runs fn, returning its panic as an error
+func call(ctx context.Context, fn func(context.Context, any) error, event any) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(ctx, event)
+}
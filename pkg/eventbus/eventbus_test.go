@@ -0,0 +1,106 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"grouter/pkg/messaging/nats/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type orderPlaced struct {
+	ID    string
+	Total int
+}
+
+func TestTopic_PublishSubscribe(t *testing.T) {
+	bus := New(nil)
+	orders := NewTopic[orderPlaced](bus, "orders.placed")
+	assert.Equal(t, "orders.placed", orders.Name())
+
+	var got []string
+	stopFirst := orders.Subscribe(func(ctx context.Context, ev orderPlaced) error {
+		got = append(got, "first:"+ev.ID)
+		return nil
+	})
+	orders.Subscribe(func(ctx context.Context, ev orderPlaced) error {
+		got = append(got, "second:"+ev.ID)
+		return nil
+	})
+
+	require.NoError(t, orders.Publish(context.Background(), orderPlaced{ID: "1"}))
+	assert.Equal(t, []string{"first:1", "second:1"}, got)
+
+	// Topics of the same name share the handlers
+	require.NoError(t, NewTopic[orderPlaced](bus, "orders.placed").Publish(context.Background(), orderPlaced{ID: "2"}))
+	assert.Equal(t, []string{"first:1", "second:1", "first:2", "second:2"}, got)
+
+	stopFirst()
+	stopFirst()
+	got = nil
+	require.NoError(t, orders.Publish(context.Background(), orderPlaced{ID: "3"}))
+	assert.Equal(t, []string{"second:3"}, got)
+	assert.Equal(t, []string{"orders.placed"}, bus.Topics())
+}
+
+func TestTopic_HandlerErrors(t *testing.T) {
+	bus := New(nil)
+	topic := NewTopic[string](bus, "test")
+	errFailed := errors.New("failed")
+	called := 0
+	topic.Subscribe(func(ctx context.Context, ev string) error { return errFailed })
+	topic.Subscribe(func(ctx context.Context, ev string) error { panic("boom") })
+	topic.Subscribe(func(ctx context.Context, ev string) error {
+		called++
+		return nil
+	})
+
+	err := topic.Publish(context.Background(), "x")
+	assert.ErrorIs(t, err, errFailed)
+	assert.ErrorContains(t, err, "panic: boom")
+	assert.Equal(t, 1, called, "failed handlers do not stop the others")
+}
+
+func TestNewTopic_TypeMismatch(t *testing.T) {
+	bus := New(nil)
+	NewTopic[orderPlaced](bus, "orders.placed")
+	assert.Panics(t, func() { NewTopic[string](bus, "orders.placed") })
+}
+
+func TestBus_Bridge(t *testing.T) {
+	bus := New(nil)
+	orders := NewTopic[orderPlaced](bus, "orders.placed")
+	local := NewTopic[string](bus, "cache.warmed")
+	orders.Bridge("grouter.events.orders.placed")
+	assert.Equal(t, map[string]string{"orders.placed": "grouter.events.orders.placed"}, bus.Bridges())
+
+	// Without a publisher, bridged events stay local
+	require.NoError(t, orders.Publish(context.Background(), orderPlaced{ID: "1"}))
+
+	pub := mocks.NewPublisher()
+	bus.SetPublisher(pub)
+	require.NoError(t, orders.Publish(context.Background(), orderPlaced{ID: "2", Total: 5}))
+	require.NoError(t, local.Publish(context.Background(), "x"))
+	msgs := pub.Messages()
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "grouter.events.orders.placed", msgs[0].Subject)
+	assert.Equal(t, "orders.placed", msgs[0].Type)
+	assert.Equal(t, orderPlaced{ID: "2", Total: 5}, msgs[0].Data)
+
+	errDown := errors.New("down")
+	pub.FailWith(errDown)
+	assert.ErrorIs(t, orders.Publish(context.Background(), orderPlaced{ID: "3"}), errDown)
+
+	// Bridging is by name, before or after the topic is created
+	bus.Bridge("users.created", "grouter.events.users.created")
+	pub.FailWith(nil)
+	require.NoError(t, NewTopic[string](bus, "users.created").Publish(context.Background(), "u1"))
+	last, _ := pub.Last()
+	assert.Equal(t, "grouter.events.users.created", last.Subject)
+
+	orders.Bridge("")
+	assert.Equal(t, map[string]string{"users.created": "grouter.events.users.created"}, bus.Bridges())
+}
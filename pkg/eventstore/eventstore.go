@@ -0,0 +1,301 @@
+package eventstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+// AnySequence disables the optimistic concurrency check on append.
+const AnySequence = ^uint64(0)
+
+// ErrConcurrencyConflict is returned by AppendToStream when the aggregate
+// has been modified since the expected sequence was read.
+var ErrConcurrencyConflict = errors.New("event stream was modified concurrently")
+
+// Config holds event store settings.
+type Config struct {
+	// StreamName is the JetStream stream holding all events.
+	StreamName string `mapstructure:"stream_name"`
+	// SubjectPrefix is prepended to the aggregate ID to build the subject
+	// of each event: <prefix>.<aggregate_id>.
+	SubjectPrefix string `mapstructure:"subject_prefix"`
+	// Replicas is the number of stream replicas in a cluster.
+	Replicas int `mapstructure:"replicas"`
+	// MaxAge limits how long events are retained. Zero keeps them forever.
+	MaxAge time.Duration `mapstructure:"max_age"`
+}
+
+// DefaultConfig returns the default event store configuration.
+func DefaultConfig() Config {
+	return Config{
+		StreamName:    "EVENTS",
+		SubjectPrefix: "events",
+		Replicas:      1,
+	}
+}
+
+// Event is an event to be appended to an aggregate stream.
+type Event struct {
+	Type string
+	Data interface{}
+}
+
+// RecordedEvent is an event read back from the store.
+type RecordedEvent struct {
+	messaging.MessageEnvelope
+	// AggregateID is the aggregate the event belongs to.
+	AggregateID string
+	// Sequence is the JetStream stream sequence of the event. The sequence of
+	// the last event of an aggregate is the expected sequence for the next append.
+	Sequence uint64
+}
+
+// EventHandler processes events delivered by Subscribe.
+type EventHandler func(ctx context.Context, ev RecordedEvent) error
+
+// EventStore provides event sourcing primitives on top of a JetStream stream,
+// using one subject per aggregate.
+type EventStore struct {
+	cfg       Config
+	js        nats.JetStreamContext
+	publisher messaging.Publisher
+	logger    *zap.Logger
+}
+
+// New creates an EventStore using the messenger's connection and publisher,
+// creating the backing stream if it does not exist.
+func New(m *messaging.Messenger, cfg Config, logger *zap.Logger) (*EventStore, error) {
+	if m == nil || m.Client == nil || m.Publisher == nil {
+		return nil, fmt.Errorf("messenger is not initialized")
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	defaults := DefaultConfig()
+	if cfg.StreamName == "" {
+		cfg.StreamName = defaults.StreamName
+	}
+	if cfg.SubjectPrefix == "" {
+		cfg.SubjectPrefix = defaults.SubjectPrefix
+	}
+	if cfg.Replicas < 1 {
+		cfg.Replicas = defaults.Replicas
+	}
+
+	js, err := m.Client.JetStream()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &EventStore{
+		cfg:       cfg,
+		js:        js,
+		publisher: m.Publisher,
+		logger:    logger,
+	}
+	if err := s.ensureStream(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *EventStore) ensureStream() error {
+	_, err := s.js.StreamInfo(s.cfg.StreamName)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, nats.ErrStreamNotFound) {
+		return fmt.Errorf("failed to look up stream %q: %w", s.cfg.StreamName, err)
+	}
+	_, err = s.js.AddStream(&nats.StreamConfig{
+		Name:     s.cfg.StreamName,
+		Subjects: []string{s.cfg.SubjectPrefix + ".>"},
+		Storage:  nats.FileStorage,
+		Replicas: s.cfg.Replicas,
+		MaxAge:   s.cfg.MaxAge,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create stream %q: %w", s.cfg.StreamName, err)
+	}
+	s.logger.Info("Created event stream",
+		zap.String("stream", s.cfg.StreamName),
+		zap.String("subjects", s.cfg.SubjectPrefix+".>"),
+	)
+	return nil
+}
+
+// Subject returns the subject used for the given aggregate.
+func (s *EventStore) Subject(aggregateID string) string {
+	return s.cfg.SubjectPrefix + "." + aggregateID
+}
+
+func validateAggregateID(id string) error {
+	if id == "" {
+		return fmt.Errorf("aggregate id is required")
+	}
+	if strings.ContainsAny(id, "*> \t\r\n") {
+		return fmt.Errorf("invalid aggregate id %q", id)
+	}
+	for _, token := range strings.Split(id, ".") {
+		if token == "" {
+			return fmt.Errorf("invalid aggregate id %q", id)
+		}
+	}
+	return nil
+}
+
+// AppendToStream appends events to the aggregate's stream. expectedSequence
+// is the sequence of the last event the caller has seen for the aggregate
+// (0 for a new aggregate, AnySequence to skip the check). It returns the
+// sequence of the last appended event.
+//
+// Each event is checked against the sequence of the one before it, so a
+// concurrent writer can only interleave before the first event; the batch is
+// not atomic if the connection fails part way through.
+func (s *EventStore) AppendToStream(ctx context.Context, aggregateID string, expectedSequence uint64, events ...Event) (uint64, error) {
+	if err := validateAggregateID(aggregateID); err != nil {
+		return 0, err
+	}
+	subject := s.Subject(aggregateID)
+	last := expectedSequence
+
+	for _, ev := range events {
+		var opts []nats.PubOpt
+		if last != AnySequence {
+			opts = append(opts, nats.ExpectLastSequencePerSubject(last))
+		}
+		ack, err := s.publisher.PublishJS(ctx, subject, ev.Type, ev.Data, opts...)
+		if err != nil {
+			if isWrongLastSequence(err) {
+				return 0, fmt.Errorf("%w: aggregate %s, expected sequence %d", ErrConcurrencyConflict, aggregateID, last)
+			}
+			return 0, fmt.Errorf("failed to append event %s: %w", ev.Type, err)
+		}
+		last = ack.Sequence
+	}
+	return last, nil
+}
+
+func isWrongLastSequence(err error) bool {
+	var jsErr nats.JetStreamError
+	if errors.As(err, &jsErr) && jsErr.APIError() != nil {
+		return jsErr.APIError().ErrorCode == nats.JSErrCodeStreamWrongLastSequence
+	}
+	return false
+}
+
+// LastSequence returns the sequence of the last event of the aggregate, or
+// 0 if the aggregate has no events.
+func (s *EventStore) LastSequence(ctx context.Context, aggregateID string) (uint64, error) {
+	if err := validateAggregateID(aggregateID); err != nil {
+		return 0, err
+	}
+	msg, err := s.js.GetLastMsg(s.cfg.StreamName, s.Subject(aggregateID), nats.Context(ctx))
+	if errors.Is(err, nats.ErrMsgNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last event: %w", err)
+	}
+	return msg.Sequence, nil
+}
+
+// ReadStream returns all events of the aggregate in order.
+func (s *EventStore) ReadStream(ctx context.Context, aggregateID string) ([]RecordedEvent, error) {
+	last, err := s.LastSequence(ctx, aggregateID)
+	if err != nil {
+		return nil, err
+	}
+	if last == 0 {
+		return []RecordedEvent{}, nil
+	}
+
+	sub, err := s.js.SubscribeSync(s.Subject(aggregateID),
+		nats.BindStream(s.cfg.StreamName),
+		nats.OrderedConsumer(),
+		nats.DeliverAll(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stream: %w", err)
+	}
+	defer sub.Unsubscribe()
+
+	var out []RecordedEvent
+	for {
+		msg, err := sub.NextMsgWithContext(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stream: %w", err)
+		}
+		ev, err := s.decode(msg)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ev)
+		if ev.Sequence >= last {
+			return out, nil
+		}
+	}
+}
+
+// Subscribe delivers every event in the store, across all aggregates,
+// starting at fromSequence (0 for the beginning of the stream). Delivery
+// stops when ctx is cancelled. Handler errors are logged; use the sequence
+// of the last processed event to resume after a restart.
+func (s *EventStore) Subscribe(ctx context.Context, fromSequence uint64, handler EventHandler) error {
+	opts := []nats.SubOpt{
+		nats.BindStream(s.cfg.StreamName),
+		nats.OrderedConsumer(),
+	}
+	if fromSequence > 0 {
+		opts = append(opts, nats.StartSequence(fromSequence))
+	} else {
+		opts = append(opts, nats.DeliverAll())
+	}
+
+	sub, err := s.js.Subscribe(s.cfg.SubjectPrefix+".>", func(msg *nats.Msg) {
+		ev, err := s.decode(msg)
+		if err != nil {
+			s.logger.Error("Failed to decode event", zap.Error(err), zap.String("subject", msg.Subject))
+			return
+		}
+		if err := handler(ctx, ev); err != nil {
+			s.logger.Error("Event handler error",
+				zap.Error(err),
+				zap.String("aggregate_id", ev.AggregateID),
+				zap.Uint64("sequence", ev.Sequence),
+			)
+		}
+	}, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to events: %w", err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		_ = sub.Unsubscribe()
+	}()
+	return nil
+}
+
+func (s *EventStore) decode(msg *nats.Msg) (RecordedEvent, error) {
+	var ev RecordedEvent
+	if err := json.Unmarshal(msg.Data, &ev.MessageEnvelope); err != nil {
+		return ev, fmt.Errorf("failed to unmarshal event: %w", err)
+	}
+	meta, err := msg.Metadata()
+	if err != nil {
+		return ev, fmt.Errorf("failed to read event metadata: %w", err)
+	}
+	ev.Sequence = meta.Sequence.Stream
+	ev.AggregateID = strings.TrimPrefix(msg.Subject, s.cfg.SubjectPrefix+".")
+	return ev, nil
+}
@@ -0,0 +1,146 @@
+package eventstore
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTestStore(t *testing.T) *EventStore {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	s, err := server.NewServer(&server.Options{
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+	})
+	require.NoError(t, err)
+	go s.Start()
+	t.Cleanup(s.Shutdown)
+	require.True(t, s.ReadyForConnections(5*time.Second))
+
+	logger := zap.NewNop()
+	m := &messaging.Messenger{}
+	require.NoError(t, m.Init(messaging.Config{
+		URL:               s.ClientURL(),
+		ConnectionTimeout: 2 * time.Second,
+	}, logger, "eventstore-test"))
+	t.Cleanup(func() { _ = m.Close() })
+
+	store, err := New(m, Config{}, logger)
+	require.NoError(t, err)
+	return store
+}
+
+type amountAdded struct {
+	Amount int `json:"amount"`
+}
+
+func TestEventStore_AppendAndRead(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	events, err := store.ReadStream(ctx, "account.1")
+	require.NoError(t, err)
+	assert.Empty(t, events)
+
+	seq, err := store.AppendToStream(ctx, "account.1", 0,
+		Event{Type: "account.opened", Data: map[string]string{"owner": "alice"}},
+		Event{Type: "account.credited", Data: amountAdded{Amount: 10}},
+	)
+	require.NoError(t, err)
+
+	// Events of another aggregate must not show up in account.1
+	_, err = store.AppendToStream(ctx, "account.2", 0, Event{Type: "account.opened", Data: nil})
+	require.NoError(t, err)
+
+	events, err = store.ReadStream(ctx, "account.1")
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "account.opened", events[0].Type)
+	assert.Equal(t, "account.credited", events[1].Type)
+	assert.Equal(t, "account.1", events[1].AggregateID)
+	assert.Equal(t, seq, events[1].Sequence)
+
+	var credited amountAdded
+	require.NoError(t, json.Unmarshal(events[1].Data, &credited))
+	assert.Equal(t, 10, credited.Amount)
+
+	last, err := store.LastSequence(ctx, "account.1")
+	require.NoError(t, err)
+	assert.Equal(t, seq, last)
+}
+
+func TestEventStore_OptimisticConcurrency(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	seq, err := store.AppendToStream(ctx, "order.1", 0, Event{Type: "order.created"})
+	require.NoError(t, err)
+
+	// Appending as if the aggregate were new must conflict.
+	_, err = store.AppendToStream(ctx, "order.1", 0, Event{Type: "order.created"})
+	assert.ErrorIs(t, err, ErrConcurrencyConflict)
+
+	// A stale expected sequence must conflict too.
+	seq2, err := store.AppendToStream(ctx, "order.1", seq, Event{Type: "order.paid"})
+	require.NoError(t, err)
+	_, err = store.AppendToStream(ctx, "order.1", seq, Event{Type: "order.cancelled"})
+	assert.ErrorIs(t, err, ErrConcurrencyConflict)
+
+	// AnySequence skips the check.
+	seq3, err := store.AppendToStream(ctx, "order.1", AnySequence, Event{Type: "order.shipped"})
+	require.NoError(t, err)
+	assert.Greater(t, seq3, seq2)
+}
+
+func TestEventStore_Subscribe(t *testing.T) {
+	store := newTestStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	first, err := store.AppendToStream(ctx, "cart.1", 0, Event{Type: "cart.created"})
+	require.NoError(t, err)
+	_, err = store.AppendToStream(ctx, "cart.2", 0, Event{Type: "cart.created"})
+	require.NoError(t, err)
+
+	received := make(chan RecordedEvent, 10)
+	err = store.Subscribe(ctx, first+1, func(ctx context.Context, ev RecordedEvent) error {
+		received <- ev
+		return nil
+	})
+	require.NoError(t, err)
+
+	_, err = store.AppendToStream(ctx, "cart.1", first, Event{Type: "cart.item_added"})
+	require.NoError(t, err)
+
+	var got []string
+	for len(got) < 2 {
+		select {
+		case ev := <-received:
+			got = append(got, ev.AggregateID+":"+ev.Type)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for events, got %v", got)
+		}
+	}
+	assert.Equal(t, []string{"cart.2:cart.created", "cart.1:cart.item_added"}, got)
+}
+
+func TestValidateAggregateID(t *testing.T) {
+	assert.NoError(t, validateAggregateID("order.42"))
+	assert.Error(t, validateAggregateID(""))
+	assert.Error(t, validateAggregateID("order.*"))
+	assert.Error(t, validateAggregateID("order..42"))
+	assert.Error(t, validateAggregateID("order 42"))
+}
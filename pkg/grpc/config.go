@@ -0,0 +1,79 @@
+package grpc
+
+import (
+	"time"
+
+	"grouter/pkg/telemetry"
+)
+
+// Config holds configuration for the gRPC Server
+type Config struct {
+	// Port is the TCP port to listen on
+	Port int `mapstructure:"port"`
+
+	// ShutdownTimeout is the duration to wait for in-flight RPCs to finish during shutdown
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+
+	// Reflection enables the gRPC server reflection service
+	Reflection bool `mapstructure:"reflection"`
+
+	// MaxRecvMsgSize is the maximum message size in bytes the server can receive (0 uses the gRPC default of 4MB)
+	MaxRecvMsgSize int `mapstructure:"max_recv_msg_size"`
+
+	// MaxSendMsgSize is the maximum message size in bytes the server can send (0 uses the gRPC default)
+	MaxSendMsgSize int `mapstructure:"max_send_msg_size"`
+
+	// TLS configuration
+	TLS TLSConfig `mapstructure:"tls"`
+
+	// Metrics configuration
+	Metrics MetricsConfig `mapstructure:"metrics"`
+
+	// Tracing configuration
+	Tracing TracingConfig `mapstructure:"tracing"`
+
+	// Logging configuration
+	Logging LoggingConfig `mapstructure:"logging"`
+}
+
+// TLSConfig holds configuration for TLS
+type TLSConfig struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	CertFile string `mapstructure:"cert_file"`
+	KeyFile  string `mapstructure:"key_file"`
+}
+
+// MetricsConfig holds configuration for metrics
+type MetricsConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Registry receives the RPC metrics (nil uses the global registry)
+	Registry *telemetry.MetricsRegistry `mapstructure:"-"`
+}
+
+// TracingConfig holds configuration for tracing
+type TracingConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+}
+
+// LoggingConfig holds configuration for request logging
+type LoggingConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+}
+
+// DefaultConfig returns the default configuration
+func DefaultConfig() Config {
+	return Config{
+		Port:            9090,
+		ShutdownTimeout: 5 * time.Second,
+		Reflection:      true,
+		Metrics: MetricsConfig{
+			Enabled: true,
+		},
+		Tracing: TracingConfig{
+			Enabled: true,
+		},
+		Logging: LoggingConfig{
+			Enabled: true,
+		},
+	}
+}
@@ -0,0 +1,156 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/metadata"
+)
+
+// Metadata keys used to forward identity established by the Gin middleware
+// chain (request ID and auth) to gateway-backed gRPC handlers
+const (
+	MetadataRequestID = "x-request-id"
+	MetadataUserID    = "x-user-id"
+	MetadataUserEmail = "x-user-email"
+)
+
+// GatewayService defines a gRPC service that is also exposed as REST through
+// grpc-gateway. Services implementing this interface are registered with the
+// Gateway automatically by the ServiceManager.
+type GatewayService interface {
+	// RegisterGateway registers the service's HTTP handlers on the provided mux,
+	// typically by calling the generated pb.RegisterXxxHandlerServer functions.
+	RegisterGateway(ctx context.Context, mux *runtime.ServeMux) error
+}
+
+// GatewayConfig holds configuration for the grpc-gateway REST transcoding
+type GatewayConfig struct {
+	// PathPrefix is the Gin path the gateway is mounted on. It must match the
+	// prefix used in the google.api.http annotations of the proto files and
+	// must not collide with routes registered by web services.
+	PathPrefix string `mapstructure:"path_prefix"`
+
+	// OpenAPI configuration
+	OpenAPI OpenAPIConfig `mapstructure:"openapi"`
+}
+
+// OpenAPIConfig holds configuration for serving generated OpenAPI documents
+type OpenAPIConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+
+	// Path is the URL path the documents are served under
+	Path string `mapstructure:"path"`
+
+	// Dir is the directory containing the *.swagger.json files generated by protoc-gen-openapiv2
+	Dir string `mapstructure:"dir"`
+}
+
+// DefaultGatewayConfig returns the default gateway configuration
+func DefaultGatewayConfig() GatewayConfig {
+	return GatewayConfig{
+		PathPrefix: "/api",
+		OpenAPI: OpenAPIConfig{
+			Path: "/openapi",
+			Dir:  "api/openapi",
+		},
+	}
+}
+
+// Gateway transcodes REST calls into gRPC handler calls and is served by the
+// Gin engine, so the web middleware chain (auth, rate limiting, CORS, logging,
+// metrics) applies to gateway routes as well
+type Gateway struct {
+	mu         sync.Mutex
+	mux        *runtime.ServeMux
+	registered map[string]bool
+
+	cfg    GatewayConfig
+	logger *zap.Logger
+}
+
+// NewGateway creates a new Gateway instance
+func NewGateway(cfg GatewayConfig, logger *zap.Logger, opts ...runtime.ServeMuxOption) *Gateway {
+	cfg.PathPrefix = strings.TrimSuffix(cfg.PathPrefix, "/")
+	if cfg.PathPrefix == "" {
+		cfg.PathPrefix = "/api"
+	}
+	if cfg.OpenAPI.Path == "" {
+		cfg.OpenAPI.Path = "/openapi"
+	}
+
+	opts = append([]runtime.ServeMuxOption{runtime.WithMetadata(forwardedMetadata)}, opts...)
+	return &Gateway{
+		mux:        runtime.NewServeMux(opts...),
+		registered: make(map[string]bool),
+		cfg:        cfg,
+		logger:     logger,
+	}
+}
+
+// Mux returns the underlying grpc-gateway ServeMux
+func (g *Gateway) Mux() *runtime.ServeMux {
+	return g.mux
+}
+
+// RegisterGatewayService registers a service's HTTP handlers with the gateway.
+// The ServeMux cannot drop handlers, so a service is only registered once per
+// name; subsequent calls are no-ops.
+func (g *Gateway) RegisterGatewayService(ctx context.Context, name string, service GatewayService) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.registered[name] {
+		return nil
+	}
+	if err := service.RegisterGateway(ctx, g.mux); err != nil {
+		return fmt.Errorf("failed to register gateway handlers: %w", err)
+	}
+	g.registered[name] = true
+	g.logger.Info("Registered gRPC gateway service", zap.String("service", name))
+	return nil
+}
+
+// Handler returns a Gin handler that serves the gateway mux
+func (g *Gateway) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		md := metadata.MD{}
+		if id := c.GetString("RequestID"); id != "" {
+			md.Set(MetadataRequestID, id)
+		}
+		if id := c.GetString("user_id"); id != "" {
+			md.Set(MetadataUserID, id)
+		}
+		if email := c.GetString("user_email"); email != "" {
+			md.Set(MetadataUserEmail, email)
+		}
+		if len(md) > 0 {
+			c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), forwardedMetadataKey{}, md))
+		}
+		g.mux.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+// RegisterRoutes mounts the gateway (and the OpenAPI documents, if enabled) on
+// the provided router group. It implements web.WebService.
+func (g *Gateway) RegisterRoutes(router *gin.RouterGroup) {
+	if g.cfg.OpenAPI.Enabled {
+		router.StaticFS(g.cfg.OpenAPI.Path, http.Dir(g.cfg.OpenAPI.Dir))
+	}
+
+	router.Any(g.cfg.PathPrefix+"/*path", g.Handler())
+}
+
+type forwardedMetadataKey struct{}
+
+// forwardedMetadata adds the identity captured by Handler to the incoming gRPC metadata
+func forwardedMetadata(ctx context.Context, r *http.Request) metadata.MD {
+	md, _ := r.Context().Value(forwardedMetadataKey{}).(metadata.MD)
+	return md
+}
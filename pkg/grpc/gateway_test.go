@@ -0,0 +1,111 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/metadata"
+)
+
+// echoGatewayService mimics the handlers generated by protoc-gen-grpc-gateway:
+// it annotates the request context and echoes the resulting gRPC metadata.
+type echoGatewayService struct {
+	calls int
+}
+
+func (s *echoGatewayService) RegisterGateway(ctx context.Context, mux *runtime.ServeMux) error {
+	s.calls++
+	return mux.HandlePath(http.MethodGet, "/api/v1/echo", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		ctx, err := runtime.AnnotateIncomingContext(r.Context(), mux, r, "/test.Echo/Echo")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		md, _ := metadata.FromIncomingContext(ctx)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"request_id":    first(md.Get(MetadataRequestID)),
+			"user_id":       first(md.Get(MetadataUserID)),
+			"authorization": first(md.Get("authorization")),
+		})
+	})
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func newGatewayEngine(gw *Gateway) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	// Stand-in for the RequestID and Auth middleware of pkg/web
+	engine.Use(func(c *gin.Context) {
+		c.Set("RequestID", "req-1")
+		if c.GetHeader("Authorization") != "" {
+			c.Set("user_id", "user-42")
+		}
+		c.Next()
+	})
+	gw.RegisterRoutes(engine.Group("/"))
+	return engine
+}
+
+func TestGateway_ForwardsIdentity(t *testing.T) {
+	gw := NewGateway(DefaultGatewayConfig(), zap.NewNop())
+	svc := &echoGatewayService{}
+	require.NoError(t, gw.RegisterGatewayService(context.Background(), "echo", svc))
+	engine := newGatewayEngine(gw)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/echo", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "req-1", body["request_id"])
+	assert.Equal(t, "user-42", body["user_id"])
+	assert.Equal(t, "Bearer token", body["authorization"])
+
+	// Unknown paths under the prefix are answered by the gateway
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/missing", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGateway_RegistersOnce(t *testing.T) {
+	gw := NewGateway(GatewayConfig{}, zap.NewNop())
+	svc := &echoGatewayService{}
+
+	require.NoError(t, gw.RegisterGatewayService(context.Background(), "echo", svc))
+	require.NoError(t, gw.RegisterGatewayService(context.Background(), "echo", svc))
+	assert.Equal(t, 1, svc.calls)
+}
+
+func TestGateway_OpenAPI(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "echo.swagger.json"), []byte(`{"swagger":"2.0"}`), 0o644))
+
+	cfg := DefaultGatewayConfig()
+	cfg.OpenAPI.Enabled = true
+	cfg.OpenAPI.Dir = dir
+	engine := newGatewayEngine(NewGateway(cfg, zap.NewNop()))
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi/echo.swagger.json", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"swagger":"2.0"}`, w.Body.String())
+}
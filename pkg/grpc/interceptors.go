@@ -0,0 +1,221 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"grouter/pkg/telemetry"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// rpcMetrics returns the RPC counter and duration of reg
+func rpcMetrics(reg *telemetry.MetricsRegistry) (*prometheus.CounterVec, *prometheus.HistogramVec) {
+	total := reg.CounterVec(prometheus.CounterOpts{
+		Name: "grpc_server_handled_total",
+		Help: "Total number of RPCs completed on the server",
+	}, []string{"method", "code"})
+	duration := reg.HistogramVec(prometheus.HistogramOpts{
+		Name:    "grpc_server_handling_seconds",
+		Help:    "Duration of RPCs handled by the server in seconds",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method"})
+	return total, duration
+}
+
+// --- Recovery Interceptor ---
+
+// RecoveryUnaryInterceptor converts panics in handlers into codes.Internal errors
+func RecoveryUnaryInterceptor(logger *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				logger.Error("gRPC handler panic",
+					zap.String("method", info.FullMethod),
+					zap.Any("panic", r),
+					zap.ByteString("stack", debug.Stack()),
+				)
+				err = status.Errorf(codes.Internal, "internal error")
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// RecoveryStreamInterceptor converts panics in stream handlers into codes.Internal errors
+func RecoveryStreamInterceptor(logger *zap.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				logger.Error("gRPC stream handler panic",
+					zap.String("method", info.FullMethod),
+					zap.Any("panic", r),
+					zap.ByteString("stack", debug.Stack()),
+				)
+				err = status.Errorf(codes.Internal, "internal error")
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+// --- Logging Interceptor ---
+
+func logRPC(logger *zap.Logger, ctx context.Context, method string, start time.Time, err error) {
+	fields := []zap.Field{
+		zap.String("method", method),
+		zap.String("code", status.Code(err).String()),
+		zap.Duration("latency", time.Since(start)),
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		fields = append(fields, zap.String("peer", p.Addr.String()))
+	}
+
+	if err != nil {
+		logger.Error("gRPC Request", append(fields, zap.Error(err))...)
+	} else {
+		logger.Info("gRPC Request", fields...)
+	}
+}
+
+// LoggingUnaryInterceptor logs unary RPCs using zap
+func LoggingUnaryInterceptor(logger *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logRPC(logger, ctx, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// LoggingStreamInterceptor logs streaming RPCs using zap
+func LoggingStreamInterceptor(logger *zap.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		logRPC(logger, ss.Context(), info.FullMethod, start, err)
+		return err
+	}
+}
+
+// --- Metrics Interceptor ---
+
+// MetricsUnaryInterceptor records RPC metrics for unary calls in reg (nil
+// uses the global registry)
+func MetricsUnaryInterceptor(reg *telemetry.MetricsRegistry) grpc.UnaryServerInterceptor {
+	grpcRequestsTotal, grpcRequestDuration := rpcMetrics(reg)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		grpcRequestsTotal.WithLabelValues(info.FullMethod, status.Code(err).String()).Inc()
+		grpcRequestDuration.WithLabelValues(info.FullMethod).Observe(time.Since(start).Seconds())
+
+		return resp, err
+	}
+}
+
+// MetricsStreamInterceptor records RPC metrics for streaming calls in reg
+// (nil uses the global registry)
+func MetricsStreamInterceptor(reg *telemetry.MetricsRegistry) grpc.StreamServerInterceptor {
+	grpcRequestsTotal, grpcRequestDuration := rpcMetrics(reg)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+
+		grpcRequestsTotal.WithLabelValues(info.FullMethod, status.Code(err).String()).Inc()
+		grpcRequestDuration.WithLabelValues(info.FullMethod).Observe(time.Since(start).Seconds())
+
+		return err
+	}
+}
+
+// --- Tracing Interceptor ---
+
+// metadataCarrier implements propagation.TextMapCarrier for gRPC metadata
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	values := metadata.MD(c).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (c metadataCarrier) Set(key string, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func startSpan(ctx context.Context, tracer trace.Tracer, method string) (context.Context, trace.Span) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+	}
+	return tracer.Start(ctx, method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("rpc.system", "grpc"),
+			attribute.String("rpc.method", method),
+		),
+	)
+}
+
+func endSpan(span trace.Span, err error) {
+	code := status.Code(err)
+	span.SetAttributes(attribute.Int64("rpc.grpc.status_code", int64(code)))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, fmt.Sprintf("%s: %s", code, status.Convert(err).Message()))
+	}
+	span.End()
+}
+
+// TracingUnaryInterceptor extracts trace context from incoming metadata and starts a server span
+func TracingUnaryInterceptor(tracer trace.Tracer) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, span := startSpan(ctx, tracer, info.FullMethod)
+		resp, err := handler(ctx, req)
+		endSpan(span, err)
+		return resp, err
+	}
+}
+
+// tracedStream overrides the stream context so handlers see the server span
+type tracedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *tracedStream) Context() context.Context {
+	return s.ctx
+}
+
+// TracingStreamInterceptor extracts trace context from incoming metadata and starts a server span
+func TracingStreamInterceptor(tracer trace.Tracer) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, span := startSpan(ss.Context(), tracer, info.FullMethod)
+		err := handler(srv, &tracedStream{ServerStream: ss, ctx: ctx})
+		endSpan(span, err)
+		return err
+	}
+}
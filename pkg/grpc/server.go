@@ -0,0 +1,245 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+
+	"grouter/pkg/health"
+)
+
+// Server wraps a grpc.Server and manages its lifecycle
+type Server struct {
+	mu       sync.Mutex
+	server   *grpc.Server
+	listener net.Listener
+	serving  bool
+
+	cfg    Config
+	logger *zap.Logger
+	health *health.HealthService
+}
+
+// NewGRPCServer creates a new gRPC Server instance
+func NewGRPCServer(cfg Config, logger *zap.Logger, healthSvc *health.HealthService) (*Server, error) {
+	s := &Server{
+		cfg:    cfg,
+		logger: logger,
+		health: healthSvc,
+	}
+	server, err := s.newServer()
+	if err != nil {
+		return nil, err
+	}
+	s.server = server
+	return s, nil
+}
+
+// ServerOptions builds the grpc.ServerOption list for the given configuration
+func ServerOptions(cfg Config, logger *zap.Logger) ([]grpc.ServerOption, error) {
+	var unary []grpc.UnaryServerInterceptor
+	var stream []grpc.StreamServerInterceptor
+
+	if cfg.Tracing.Enabled {
+		tracer := otel.Tracer("grouter/pkg/grpc")
+		unary = append(unary, TracingUnaryInterceptor(tracer))
+		stream = append(stream, TracingStreamInterceptor(tracer))
+	}
+	if cfg.Logging.Enabled {
+		unary = append(unary, LoggingUnaryInterceptor(logger))
+		stream = append(stream, LoggingStreamInterceptor(logger))
+	}
+	if cfg.Metrics.Enabled {
+		unary = append(unary, MetricsUnaryInterceptor(cfg.Metrics.Registry))
+		stream = append(stream, MetricsStreamInterceptor(cfg.Metrics.Registry))
+	}
+
+	// Recovery runs closest to the handler so that panics are reported as
+	// codes.Internal to the logging, metrics and tracing interceptors
+	unary = append(unary, RecoveryUnaryInterceptor(logger))
+	stream = append(stream, RecoveryStreamInterceptor(logger))
+
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	}
+	if cfg.MaxRecvMsgSize > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(cfg.MaxRecvMsgSize))
+	}
+	if cfg.MaxSendMsgSize > 0 {
+		opts = append(opts, grpc.MaxSendMsgSize(cfg.MaxSendMsgSize))
+	}
+	if cfg.TLS.Enabled {
+		if cfg.TLS.CertFile == "" || cfg.TLS.KeyFile == "" {
+			return nil, fmt.Errorf("TLS enabled but cert or key file missing")
+		}
+		creds, err := credentials.NewServerTLSFromFile(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS credentials: %w", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	return opts, nil
+}
+
+func (s *Server) newServer() (*grpc.Server, error) {
+	opts, err := ServerOptions(s.cfg, s.logger)
+	if err != nil {
+		return nil, err
+	}
+	server := grpc.NewServer(opts...)
+
+	if s.health != nil {
+		healthpb.RegisterHealthServer(server, &healthServer{health: s.health})
+	}
+	if s.cfg.Reflection {
+		reflection.Register(server)
+	}
+	return server, nil
+}
+
+// RegisterGRPCService registers a service's implementations with the server.
+// gRPC does not allow registration once the server is serving; use Reset to
+// rebuild the server in that case.
+func (s *Server) RegisterGRPCService(service GRPCService) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.serving {
+		return fmt.Errorf("cannot register gRPC service while the server is running; call Reset first")
+	}
+	service.RegisterGRPC(s.server)
+	return nil
+}
+
+// GRPCServer returns the underlying grpc.Server
+func (s *Server) GRPCServer() *grpc.Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.server
+}
+
+// Health returns the underlying health service
+func (s *Server) Health() *health.HealthService {
+	return s.health
+}
+
+// Addr returns the address the server is listening on, or nil if it is not started
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Start starts serving gRPC requests
+func (s *Server) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.serving {
+		return nil
+	}
+
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", s.cfg.Port))
+	if err != nil {
+		return fmt.Errorf("failed to listen on port %d: %w", s.cfg.Port, err)
+	}
+	s.listener = lis
+	s.serving = true
+
+	s.logger.Info("Starting gRPC server", zap.String("addr", lis.Addr().String()), zap.Bool("tls", s.cfg.TLS.Enabled))
+
+	server := s.server
+	go func() {
+		if err := server.Serve(lis); err != nil && err != grpc.ErrServerStopped {
+			s.logger.Error("gRPC server stopped", zap.Error(err))
+		}
+	}()
+
+	return nil
+}
+
+// Stop gracefully shuts down the gRPC server, forcing it closed if in-flight
+// RPCs do not finish within the shutdown timeout
+func (s *Server) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.logger.Info("Stopping gRPC server")
+
+	if !s.serving {
+		return nil
+	}
+	s.serving = false
+	s.listener = nil
+
+	if s.cfg.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.cfg.ShutdownTimeout)
+		defer cancel()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+		return fmt.Errorf("gRPC server forced to shutdown: %w", ctx.Err())
+	}
+}
+
+// Reset stops the server and replaces it with a fresh one that has no
+// application services registered, so services can be registered again
+func (s *Server) Reset(ctx context.Context) error {
+	s.logger.Info("Resetting gRPC server...")
+
+	if err := s.Stop(ctx); err != nil {
+		s.logger.Error("Failed to stop gRPC server during reset", zap.Error(err))
+		// Proceeding with a fresh server anyway
+	}
+
+	server, err := s.newServer()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.server = server
+	s.mu.Unlock()
+	return nil
+}
+
+// healthServer exposes the shared HealthService readiness checks through the
+// standard grpc.health.v1 protocol
+type healthServer struct {
+	healthpb.UnimplementedHealthServer
+	health *health.HealthService
+}
+
+func (h *healthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	if req.GetService() != "" {
+		return nil, status.Errorf(codes.NotFound, "unknown service %q", req.GetService())
+	}
+	if _, err := h.health.CheckReadiness(); err != nil {
+		return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_NOT_SERVING}, nil
+	}
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
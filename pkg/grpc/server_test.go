@@ -0,0 +1,155 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"grouter/pkg/health"
+	"grouter/pkg/telemetry"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// testService exposes a health implementation that fails on demand, which is
+// enough to exercise the interceptors without generated code.
+type testService struct {
+	healthpb.UnimplementedHealthServer
+}
+
+func (s *testService) RegisterGRPC(server grpc.ServiceRegistrar) {
+	healthpb.RegisterHealthServer(server, s)
+}
+
+func (s *testService) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	switch req.GetService() {
+	case "panic":
+		panic("boom")
+	case "fail":
+		return nil, status.Error(codes.FailedPrecondition, "failed")
+	}
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+func dial(t *testing.T, s *Server) healthpb.HealthClient {
+	t.Helper()
+	conn, err := grpc.NewClient(s.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return healthpb.NewHealthClient(conn)
+}
+
+func TestServer_StartStop(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	cfg := DefaultConfig()
+	cfg.Port = 0
+	cfg.Metrics.Registry = telemetry.NewMetricsRegistry()
+
+	s, err := NewGRPCServer(cfg, zap.New(core), nil)
+	require.NoError(t, err)
+	assert.Nil(t, s.Addr())
+
+	require.NoError(t, s.RegisterGRPCService(&testService{}))
+	require.NoError(t, s.Start())
+	require.NotNil(t, s.Addr())
+
+	// Registration is rejected while serving
+	assert.Error(t, s.RegisterGRPCService(&testService{}))
+
+	client := dial(t, s)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: "fail"})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: "panic"})
+	assert.Equal(t, codes.Internal, status.Code(err))
+
+	method := "/grpc.health.v1.Health/Check"
+	grpcRequestsTotal, _ := rpcMetrics(cfg.Metrics.Registry)
+	assert.Equal(t, float64(1), testutil.ToFloat64(grpcRequestsTotal.WithLabelValues(method, codes.OK.String())))
+	assert.Equal(t, float64(1), testutil.ToFloat64(grpcRequestsTotal.WithLabelValues(method, codes.Internal.String())))
+	assert.Equal(t, 3, logs.FilterMessage("gRPC Request").Len())
+
+	require.NoError(t, s.Stop(context.Background()))
+	assert.Nil(t, s.Addr())
+}
+
+func TestServer_Reset(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Port = 0
+	cfg.Logging.Enabled = false
+	cfg.Metrics.Enabled = false
+
+	s, err := NewGRPCServer(cfg, zap.NewNop(), nil)
+	require.NoError(t, err)
+	require.NoError(t, s.Start())
+	assert.Error(t, s.RegisterGRPCService(&testService{}))
+
+	require.NoError(t, s.Reset(context.Background()))
+	require.NoError(t, s.RegisterGRPCService(&testService{}))
+	require.NoError(t, s.Start())
+	defer s.Stop(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = dial(t, s).Check(ctx, &healthpb.HealthCheckRequest{})
+	assert.NoError(t, err)
+}
+
+func TestServer_HealthService(t *testing.T) {
+	hs := health.NewHealthService()
+	ready := errors.New("not ready")
+	hs.AddReadinessCheck("dep", func() error { return ready })
+
+	cfg := DefaultConfig()
+	cfg.Port = 0
+	s, err := NewGRPCServer(cfg, zap.NewNop(), hs)
+	require.NoError(t, err)
+	assert.Equal(t, hs, s.Health())
+	require.NoError(t, s.Start())
+	defer s.Stop(context.Background())
+
+	client := dial(t, s)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, resp.Status)
+
+	ready = nil
+	resp, err = client.Check(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: "other"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestServerOptions_TLSMissingFiles(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.TLS.Enabled = true
+	_, err := ServerOptions(cfg, zap.NewNop())
+	assert.Error(t, err)
+
+	cfg.TLS.CertFile = "missing.pem"
+	cfg.TLS.KeyFile = "missing.key"
+	_, err = NewGRPCServer(cfg, zap.NewNop(), nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,12 @@
+package grpc
+
+import "google.golang.org/grpc"
+
+// GRPCService defines a component that exposes gRPC services.
+// Services implementing this interface are registered with the gRPC Server
+// automatically by the ServiceManager.
+type GRPCService interface {
+	// RegisterGRPC registers the service's implementations on the provided server,
+	// typically by calling the generated pb.RegisterXxxServer functions.
+	RegisterGRPC(server grpc.ServiceRegistrar)
+}
@@ -0,0 +1,195 @@
+package health
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"grouter/pkg/telemetry"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Probe labels of the check metrics
+const (
+	probeLiveness  = "liveness"
+	probeReadiness = "readiness"
+	probeStartup   = "startup"
+)
+
+// CheckOptions configures a single check
+type CheckOptions struct {
+	// Timeout overrides the service timeout
+	Timeout time.Duration
+	// CacheTTL overrides the service cache TTL. A negative TTL disables
+	// caching for the check.
+	CacheTTL time.Duration
+	// Critical checks take the probe down when they fail. Failing
+	// non-critical checks only mark it degraded, which still passes.
+	// Checks are critical unless set otherwise.
+	Critical bool
+}
+
+// CheckOption is a functional option for AddLivenessCheck and AddReadinessCheck
+type CheckOption func(*CheckOptions)
+
+// WithCheckTimeout sets the timeout of the check
+func WithCheckTimeout(timeout time.Duration) CheckOption {
+	return func(o *CheckOptions) {
+		o.Timeout = timeout
+	}
+}
+
+// WithCheckCacheTTL sets how long the result of the check is reused
+func WithCheckCacheTTL(ttl time.Duration) CheckOption {
+	return func(o *CheckOptions) {
+		o.CacheTTL = ttl
+	}
+}
+
+// WithCritical sets whether a failure of the check takes the probe down
+// (default) or only degrades it
+func WithCritical(critical bool) CheckOption {
+	return func(o *CheckOptions) {
+		o.Critical = critical
+	}
+}
+
+// check is a registered checker with its last result
+type check struct {
+	probe    string
+	name     string
+	fn       HealthChecker
+	timeout  time.Duration
+	cacheTTL time.Duration
+	critical bool
+	metrics  *checkMetrics
+
+	mu      sync.Mutex
+	result  CheckResult
+	checked time.Time
+	// inflight is closed when the running execution completes. A check that
+	// timed out keeps running; later probes wait for it instead of piling up
+	// more executions.
+	inflight chan struct{}
+}
+
+func (s *HealthService) newCheck(probe, name string, fn HealthChecker, opts []CheckOption) *check {
+	options := CheckOptions{Timeout: s.opts.Timeout, CacheTTL: s.opts.CacheTTL, Critical: true}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = s.opts.Timeout
+	}
+	return &check{
+		probe:    probe,
+		name:     name,
+		fn:       fn,
+		timeout:  options.Timeout,
+		cacheTTL: options.CacheTTL,
+		critical: options.Critical,
+		metrics:  s.metrics,
+	}
+}
+
+// last returns the latest result, if the check ran
+func (c *check) last() (CheckResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.result, !c.checked.IsZero()
+}
+
+// cached returns the latest result if it is younger than the cache TTL
+func (c *check) cached() (CheckResult, bool) {
+	if c.cacheTTL <= 0 {
+		return CheckResult{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.checked.IsZero() || time.Since(c.checked) >= c.cacheTTL {
+		return CheckResult{}, false
+	}
+	return c.result, true
+}
+
+// execute runs the check, or joins the execution in flight, and waits for it
+// up to the timeout
+func (c *check) execute() CheckResult {
+	c.mu.Lock()
+	done := c.inflight
+	if done == nil {
+		done = make(chan struct{})
+		c.inflight = done
+		go c.call(done)
+	}
+	c.mu.Unlock()
+
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		r, _ := c.last()
+		return r
+	case <-timer.C:
+		return CheckResult{
+			Status:     StatusDown,
+			Error:      fmt.Sprintf("check timed out after %s", c.timeout),
+			Critical:   c.critical,
+			DurationMs: milliseconds(c.timeout),
+		}
+	}
+}
+
+// call runs the checker and stores its result
+func (c *check) call(done chan struct{}) {
+	start := time.Now()
+	err := c.fn()
+	elapsed := time.Since(start)
+
+	result := CheckResult{Status: StatusUp, Critical: c.critical, DurationMs: milliseconds(elapsed)}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	c.metrics.observe(c.probe, c.name, elapsed, err == nil)
+
+	c.mu.Lock()
+	c.result = result
+	c.checked = time.Now()
+	c.inflight = nil
+	c.mu.Unlock()
+	close(done)
+}
+
+// checkMetrics holds the per-check Prometheus metrics
+type checkMetrics struct {
+	duration *prometheus.HistogramVec
+	status   *prometheus.GaugeVec
+}
+
+func newCheckMetrics(reg *telemetry.MetricsRegistry) *checkMetrics {
+	return &checkMetrics{
+		duration: reg.HistogramVec(prometheus.HistogramOpts{
+			Name:    "health_check_duration_seconds",
+			Help:    "Duration of health check executions.",
+			Buckets: []float64{.001, .005, .01, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		}, []string{"probe", "check"}),
+		status: reg.GaugeVec(prometheus.GaugeOpts{
+			Name: "health_check_status",
+			Help: "Result of the last health check execution (1 up, 0 down).",
+		}, []string{"probe", "check"}),
+	}
+}
+
+func (m *checkMetrics) observe(probe, name string, elapsed time.Duration, up bool) {
+	if m == nil {
+		return
+	}
+	m.duration.WithLabelValues(probe, name).Observe(elapsed.Seconds())
+	status := 0.0
+	if up {
+		status = 1
+	}
+	m.status.WithLabelValues(probe, name).Set(status)
+}
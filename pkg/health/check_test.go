@@ -0,0 +1,122 @@
+package health
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"grouter/pkg/telemetry"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthService_CheckTimeout(t *testing.T) {
+	s := NewHealthService()
+	release := make(chan struct{})
+	defer close(release)
+	var calls atomic.Int32
+	s.AddLivenessCheck("slow", func() error {
+		calls.Add(1)
+		<-release
+		return nil
+	}, WithCheckTimeout(20*time.Millisecond))
+	s.AddLivenessCheck("fast", func() error { return nil })
+
+	start := time.Now()
+	checks, err := s.CheckLiveness()
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Contains(t, checks["slow"], "timed out")
+	assert.Equal(t, "OK", checks["fast"])
+
+	// The hanging execution is joined instead of starting another one
+	_, _ = s.CheckLiveness()
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestHealthService_ChecksRunConcurrently(t *testing.T) {
+	s := NewHealthService()
+	for _, name := range []string{"a", "b", "c"} {
+		s.AddReadinessCheck(name, func() error {
+			time.Sleep(100 * time.Millisecond)
+			return nil
+		})
+	}
+
+	start := time.Now()
+	_, err := s.CheckReadiness()
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), 250*time.Millisecond)
+}
+
+func TestHealthService_CacheTTL(t *testing.T) {
+	s := NewHealthService(WithCacheTTL(time.Hour))
+	var cached, uncached atomic.Int32
+	s.AddReadinessCheck("cached", func() error {
+		cached.Add(1)
+		return nil
+	})
+	s.AddReadinessCheck("uncached", func() error {
+		uncached.Add(1)
+		return nil
+	}, WithCheckCacheTTL(-1))
+
+	for i := 0; i < 3; i++ {
+		_, err := s.CheckReadiness()
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(1), cached.Load())
+	assert.Equal(t, int32(3), uncached.Load())
+}
+
+func TestHealthService_BackgroundRefresh(t *testing.T) {
+	s := NewHealthService(WithRefreshInterval(10 * time.Millisecond))
+	var calls atomic.Int32
+	var failing atomic.Bool
+	s.AddReadinessCheck("db", func() error {
+		calls.Add(1)
+		if failing.Load() {
+			return errors.New("down")
+		}
+		return nil
+	})
+
+	s.Start()
+	defer s.Stop()
+	require.Eventually(t, func() bool { return calls.Load() >= 3 }, time.Second, 5*time.Millisecond)
+
+	// Probes serve the refreshed result without running the check
+	before := calls.Load()
+	_, err := s.CheckReadiness()
+	require.NoError(t, err)
+	assert.LessOrEqual(t, calls.Load()-before, int32(1))
+
+	failing.Store(true)
+	require.Eventually(t, func() bool {
+		_, err := s.CheckReadiness()
+		return err != nil
+	}, time.Second, 5*time.Millisecond)
+
+	s.Stop()
+	stopped := calls.Load()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, stopped, calls.Load())
+}
+
+func TestHealthService_Metrics(t *testing.T) {
+	reg := telemetry.NewMetricsRegistry()
+	s := NewHealthService(WithMetrics(reg))
+	s.AddLivenessCheck("web", func() error { return nil })
+	s.AddReadinessCheck("nats", func() error { return errors.New("disconnected") })
+
+	_, _ = s.CheckLiveness()
+	_, _ = s.CheckReadiness()
+
+	m := newCheckMetrics(reg)
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.status.WithLabelValues(probeLiveness, "web")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.status.WithLabelValues(probeReadiness, "nats")))
+	assert.Equal(t, 2, testutil.CollectAndCount(m.duration))
+}
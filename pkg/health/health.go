@@ -1,9 +1,13 @@
 package health
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"sync"
+	"time"
+
+	"grouter/pkg/telemetry"
 
 	"github.com/gin-gonic/gin"
 )
@@ -11,33 +15,126 @@ import (
 // HealthChecker is a function that returns an error if the check fails
 type HealthChecker func() error
 
+// DefaultCheckTimeout bounds checks created with WithTimeout when no timeout is given
+const DefaultCheckTimeout = 5 * time.Second
+
+// WithTimeout adapts a context-aware check to a HealthChecker that gives up
+// after timeout, so a hanging dependency cannot block the probe
+func WithTimeout(timeout time.Duration, check func(ctx context.Context) error) HealthChecker {
+	if timeout <= 0 {
+		timeout = DefaultCheckTimeout
+	}
+	return func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		return check(ctx)
+	}
+}
+
+// Options configures a HealthService
+type Options struct {
+	// Timeout bounds each check unless it sets its own (default
+	// DefaultCheckTimeout). A check still running is reported as failed.
+	Timeout time.Duration
+	// CacheTTL reuses a check result for this long instead of running the
+	// check on every probe. Zero disables caching.
+	CacheTTL time.Duration
+	// RefreshInterval runs the checks in the background once Start is called;
+	// probes then serve the last results without waiting for the checks
+	RefreshInterval time.Duration
+	// Metrics records the latency and status of every check. Nil disables
+	// the metrics.
+	Metrics *telemetry.MetricsRegistry
+}
+
+// Option is a functional option for NewHealthService
+type Option func(*Options)
+
+// WithDefaultTimeout sets the timeout of checks that do not set their own
+func WithDefaultTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.Timeout = timeout
+	}
+}
+
+// WithCacheTTL caches check results for ttl
+func WithCacheTTL(ttl time.Duration) Option {
+	return func(o *Options) {
+		o.CacheTTL = ttl
+	}
+}
+
+// WithRefreshInterval refreshes the checks in the background every interval
+func WithRefreshInterval(interval time.Duration) Option {
+	return func(o *Options) {
+		o.RefreshInterval = interval
+	}
+}
+
+// WithMetrics records per-check latency and status metrics in reg
+func WithMetrics(reg *telemetry.MetricsRegistry) Option {
+	return func(o *Options) {
+		o.Metrics = reg
+	}
+}
+
 // HealthService manages health checks
 type HealthService struct {
 	mu        sync.RWMutex
-	readiness map[string]HealthChecker
-	liveness  map[string]HealthChecker
+	readiness map[string]*check
+	liveness  map[string]*check
+	startup   map[string]*check
+
+	opts    Options
+	metrics *checkMetrics
+
+	// stop ends the background refresh started by Start
+	stop chan struct{}
+	done chan struct{}
 }
 
 // NewHealthService creates a new HealthService
-func NewHealthService() *HealthService {
-	return &HealthService{
-		readiness: make(map[string]HealthChecker),
-		liveness:  make(map[string]HealthChecker),
+func NewHealthService(opts ...Option) *HealthService {
+	var options Options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultCheckTimeout
+	}
+
+	s := &HealthService{
+		readiness: make(map[string]*check),
+		liveness:  make(map[string]*check),
+		startup:   make(map[string]*check),
+		opts:      options,
+	}
+	if options.Metrics != nil {
+		s.metrics = newCheckMetrics(options.Metrics)
 	}
+	return s
 }
 
 // AddReadinessCheck adds a readiness check
-func (s *HealthService) AddReadinessCheck(name string, check HealthChecker) {
+func (s *HealthService) AddReadinessCheck(name string, check HealthChecker, opts ...CheckOption) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.readiness[name] = check
+	s.readiness[name] = s.newCheck(probeReadiness, name, check, opts)
 }
 
 // AddLivenessCheck adds a liveness check
-func (s *HealthService) AddLivenessCheck(name string, check HealthChecker) {
+func (s *HealthService) AddLivenessCheck(name string, check HealthChecker, opts ...CheckOption) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.liveness[name] = check
+	s.liveness[name] = s.newCheck(probeLiveness, name, check, opts)
+}
+
+// AddStartupCheck adds a startup check. Orchestrators hold off the liveness
+// and readiness probes until the startup probe passes.
+func (s *HealthService) AddStartupCheck(name string, check HealthChecker, opts ...CheckOption) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.startup[name] = s.newCheck(probeStartup, name, check, opts)
 }
 
 // RemoveReadinessCheck removes a readiness check
@@ -54,84 +151,181 @@ func (s *HealthService) RemoveLivenessCheck(name string) {
 	delete(s.liveness, name)
 }
 
-// CheckLiveness performs all liveness checks
+// RemoveStartupCheck removes a startup check
+func (s *HealthService) RemoveStartupCheck(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.startup, name)
+}
+
+// CheckLiveness performs all liveness checks. It only fails when a critical
+// check fails.
 func (s *HealthService) CheckLiveness() (map[string]string, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	return summarize(s.run(s.snapshot(s.liveness)), "liveness")
+}
 
-	errors := make(map[string]string)
-	hasError := false
+// CheckReadiness performs all readiness checks. It only fails when a critical
+// check fails.
+func (s *HealthService) CheckReadiness() (map[string]string, error) {
+	return summarize(s.run(s.snapshot(s.readiness)), "readiness")
+}
 
-	for name, check := range s.liveness {
-		if err := check(); err != nil {
-			errors[name] = err.Error()
-			hasError = true
-		} else {
-			errors[name] = "OK"
-		}
+// CheckStartup performs all startup checks. It only fails when a critical
+// check fails.
+func (s *HealthService) CheckStartup() (map[string]string, error) {
+	return summarize(s.run(s.snapshot(s.startup)), "startup")
+}
+
+// Start refreshes the checks every RefreshInterval in the background until
+// Stop is called. It does nothing without a RefreshInterval.
+func (s *HealthService) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.opts.RefreshInterval <= 0 || s.stop != nil {
+		return
 	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.refresh(s.stop, s.done)
+}
 
-	if hasError {
-		return errors, fmt.Errorf("liveness check failed")
+// Stop ends the background refresh
+func (s *HealthService) Stop() {
+	s.mu.Lock()
+	stop, done := s.stop, s.done
+	s.stop, s.done = nil, nil
+	s.mu.Unlock()
+	if stop == nil {
+		return
 	}
-	return errors, nil
+	close(stop)
+	<-done
 }
 
-// CheckReadiness performs all readiness checks
-func (s *HealthService) CheckReadiness() (map[string]string, error) {
+func (s *HealthService) refresh(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(s.opts.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		checks := append(s.snapshot(s.liveness), s.snapshot(s.readiness)...)
+		checks = append(checks, s.snapshot(s.startup)...)
+		runAll(checks, func(c *check) CheckResult { return c.execute() })
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// refreshing reports whether results are kept fresh by the background refresh
+func (s *HealthService) refreshing() bool {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
+	return s.stop != nil
+}
 
-	errors := make(map[string]string)
-	hasError := false
+// snapshot copies the checks so that they run without holding the lock
+func (s *HealthService) snapshot(checks map[string]*check) []*check {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list := make([]*check, 0, len(checks))
+	for _, c := range checks {
+		list = append(list, c)
+	}
+	return list
+}
 
-	for name, check := range s.readiness {
-		if err := check(); err != nil {
-			errors[name] = err.Error()
-			hasError = true
-		} else {
-			errors[name] = "OK"
+// run executes the checks concurrently, serving cached results when they are
+// still fresh
+func (s *HealthService) run(checks []*check) map[string]CheckResult {
+	refreshing := s.refreshing()
+	return runAll(checks, func(c *check) CheckResult {
+		if refreshing {
+			// Checks added since the last refresh have no result yet
+			if r, ok := c.last(); ok {
+				return r
+			}
+		} else if r, ok := c.cached(); ok {
+			return r
 		}
+		return c.execute()
+	})
+}
+
+func runAll(checks []*check, run func(*check) CheckResult) map[string]CheckResult {
+	results := make(map[string]CheckResult, len(checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range checks {
+		wg.Add(1)
+		go func(c *check) {
+			defer wg.Done()
+			r := run(c)
+			mu.Lock()
+			results[c.name] = r
+			mu.Unlock()
+		}(c)
 	}
+	wg.Wait()
+	return results
+}
 
-	if hasError {
-		return errors, fmt.Errorf("readiness check failed")
+// summarize converts results to the name -> "OK" or error format of the
+// probes, failing if a critical check failed
+func summarize(results map[string]CheckResult, probe string) (map[string]string, error) {
+	checks := make(map[string]string, len(results))
+	for name, r := range results {
+		if r.Status == StatusUp {
+			checks[name] = "OK"
+		} else {
+			checks[name] = r.Error
+		}
 	}
-	return errors, nil
+	if overall(results) == StatusDown {
+		return checks, fmt.Errorf("%s check failed", probe)
+	}
+	return checks, nil
 }
 
 // LivenessHandler handles liveness probes
 func (s *HealthService) LivenessHandler(c *gin.Context) {
-	checks, err := s.CheckLiveness()
-	if err != nil {
-		c.JSON(http.StatusServiceUnavailable, gin.H{
-			"status": "down",
-			"checks": checks,
-			"error":  err.Error(),
-		})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"status": "up",
-		"checks": checks,
-	})
+	s.respond(c, s.snapshot(s.liveness), "liveness", "up", "down")
 }
 
 // ReadinessHandler handles readiness probes
 func (s *HealthService) ReadinessHandler(c *gin.Context) {
-	checks, err := s.CheckReadiness()
-	if err != nil {
+	s.respond(c, s.snapshot(s.readiness), "readiness", "ready", "not ready")
+}
+
+// StartupHandler handles startup probes
+func (s *HealthService) StartupHandler(c *gin.Context) {
+	s.respond(c, s.snapshot(s.startup), "startup", "started", "starting")
+}
+
+// respond runs the checks and writes the probe response. A degraded probe
+// still answers 200 so that orchestrators keep routing traffic.
+func (s *HealthService) respond(c *gin.Context, checks []*check, probe, upStatus, downStatus string) {
+	results := s.run(checks)
+	summary, err := summarize(results, probe)
+
+	switch overall(results) {
+	case StatusDown:
 		c.JSON(http.StatusServiceUnavailable, gin.H{
-			"status": "not ready",
-			"checks": checks,
+			"status": downStatus,
+			"checks": summary,
 			"error":  err.Error(),
 		})
-		return
+	case StatusDegraded:
+		c.JSON(http.StatusOK, gin.H{
+			"status": StatusDegraded,
+			"checks": summary,
+		})
+	default:
+		c.JSON(http.StatusOK, gin.H{
+			"status": upStatus,
+			"checks": summary,
+		})
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"status": "ready",
-		"checks": checks,
-	})
 }
@@ -1,12 +1,14 @@
 package health
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -170,3 +172,70 @@ func TestHealthService_RemoveReadinessCheck(t *testing.T) {
 	checks, _ = s.CheckReadiness()
 	assert.NotContains(t, checks, "test")
 }
+
+func TestWithTimeout(t *testing.T) {
+	check := WithTimeout(10*time.Millisecond, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	assert.ErrorIs(t, check(), context.DeadlineExceeded)
+
+	ok := WithTimeout(0, func(ctx context.Context) error {
+		deadline, set := ctx.Deadline()
+		assert.True(t, set)
+		assert.WithinDuration(t, time.Now().Add(DefaultCheckTimeout), deadline, time.Second)
+		return nil
+	})
+	assert.NoError(t, ok())
+}
+
+func TestHealthService_NonCriticalCheck(t *testing.T) {
+	s := NewHealthService()
+	s.AddReadinessCheck("cache", func() error { return errors.New("unreachable") }, WithCritical(false))
+
+	checks, err := s.CheckReadiness()
+	assert.NoError(t, err)
+	assert.Equal(t, "unreachable", checks["cache"])
+
+	s.AddReadinessCheck("db", func() error { return errors.New("failed") })
+	_, err = s.CheckReadiness()
+	assert.Error(t, err)
+}
+
+func TestReadinessHandler_Degraded(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := NewHealthService()
+	s.AddReadinessCheck("cache", func() error { return errors.New("unreachable") }, WithCritical(false))
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	s.ReadinessHandler(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "degraded", resp["status"])
+	assert.Equal(t, "unreachable", resp["checks"].(map[string]interface{})["cache"])
+}
+
+func TestStartupHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := NewHealthService()
+	s.AddStartupCheck("init", func() error { return errors.New("loading") })
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	s.StartupHandler(c)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	var resp map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "starting", resp["status"])
+
+	s.RemoveStartupCheck("init")
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	s.StartupHandler(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "started", resp["status"])
+}
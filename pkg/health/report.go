@@ -0,0 +1,97 @@
+package health
+
+import (
+	"fmt"
+	"time"
+)
+
+// Probes answered by Report
+const (
+	ProbeLive    = "live"
+	ProbeReady   = "ready"
+	ProbeStartup = "startup"
+	ProbeDetail  = "detail"
+)
+
+// Status values of reports and checks. Checks are up or down; a report is
+// degraded when only non-critical checks are down.
+const (
+	StatusUp       = "up"
+	StatusDegraded = "degraded"
+	StatusDown     = "down"
+)
+
+// CheckResult is the outcome of a single check
+type CheckResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// Critical checks take the probe down when they fail; the others only
+	// degrade it
+	Critical bool `json:"critical"`
+	// DurationMs is how long the check took in milliseconds
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// Report is the standardized health document. Live, ready and startup reports
+// only carry the checks of their probe; detail reports carry all of them.
+type Report struct {
+	Status     string                 `json:"status"`
+	Probe      string                 `json:"probe"`
+	App        string                 `json:"app,omitempty"`
+	Version    string                 `json:"version,omitempty"`
+	Instance   string                 `json:"instance,omitempty"`
+	Timestamp  time.Time              `json:"timestamp"`
+	DurationMs float64                `json:"duration_ms"`
+	Liveness   map[string]CheckResult `json:"liveness,omitempty"`
+	Readiness  map[string]CheckResult `json:"readiness,omitempty"`
+	Startup    map[string]CheckResult `json:"startup,omitempty"`
+}
+
+// Report runs the checks of the probe and returns the document, which is down
+// if a critical check failed and degraded if only non-critical checks failed
+func (s *HealthService) Report(probe string) (*Report, error) {
+	report := &Report{
+		Probe:     probe,
+		Timestamp: time.Now().UTC(),
+	}
+
+	switch probe {
+	case ProbeLive:
+		report.Liveness = s.run(s.snapshot(s.liveness))
+	case ProbeReady:
+		report.Readiness = s.run(s.snapshot(s.readiness))
+	case ProbeStartup:
+		report.Startup = s.run(s.snapshot(s.startup))
+	case ProbeDetail:
+		report.Liveness = s.run(s.snapshot(s.liveness))
+		report.Readiness = s.run(s.snapshot(s.readiness))
+		report.Startup = s.run(s.snapshot(s.startup))
+	default:
+		return nil, fmt.Errorf("unknown health probe: %q", probe)
+	}
+
+	report.Status = overall(report.Liveness, report.Readiness, report.Startup)
+	report.DurationMs = milliseconds(time.Since(report.Timestamp))
+	return report, nil
+}
+
+// overall combines check results into up, degraded or down
+func overall(results ...map[string]CheckResult) string {
+	status := StatusUp
+	for _, checks := range results {
+		for _, r := range checks {
+			if r.Status == StatusUp {
+				continue
+			}
+			if r.Critical {
+				return StatusDown
+			}
+			status = StatusDegraded
+		}
+	}
+	return status
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,86 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthService_Report(t *testing.T) {
+	hs := NewHealthService()
+	hs.AddLivenessCheck("web", func() error { return nil })
+	hs.AddReadinessCheck("nats", func() error { return errors.New("disconnected") })
+
+	live, err := hs.Report(ProbeLive)
+	require.NoError(t, err)
+	assert.Equal(t, StatusUp, live.Status)
+	assert.Equal(t, ProbeLive, live.Probe)
+	assert.Equal(t, StatusUp, live.Liveness["web"].Status)
+	assert.Nil(t, live.Readiness)
+
+	ready, err := hs.Report(ProbeReady)
+	require.NoError(t, err)
+	assert.Equal(t, StatusDown, ready.Status)
+	assert.Equal(t, CheckResult{Status: StatusDown, Error: "disconnected", Critical: true, DurationMs: ready.Readiness["nats"].DurationMs}, ready.Readiness["nats"])
+
+	detail, err := hs.Report(ProbeDetail)
+	require.NoError(t, err)
+	assert.Equal(t, StatusDown, detail.Status)
+	assert.Len(t, detail.Liveness, 1)
+	assert.Len(t, detail.Readiness, 1)
+
+	_, err = hs.Report("nope")
+	assert.Error(t, err)
+}
+
+func TestReport_JSON(t *testing.T) {
+	hs := NewHealthService()
+	hs.AddLivenessCheck("web", func() error { return nil })
+
+	report, err := hs.Report(ProbeLive)
+	require.NoError(t, err)
+	report.App = "grouter"
+
+	data, err := json.Marshal(report)
+	require.NoError(t, err)
+
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &doc))
+	assert.Equal(t, "up", doc["status"])
+	assert.Equal(t, "live", doc["probe"])
+	assert.Equal(t, "grouter", doc["app"])
+	assert.Contains(t, doc, "timestamp")
+	assert.Contains(t, doc, "duration_ms")
+	assert.NotContains(t, doc, "readiness")
+	check := doc["liveness"].(map[string]interface{})["web"].(map[string]interface{})
+	assert.Equal(t, "up", check["status"])
+	assert.NotContains(t, check, "error")
+}
+
+func TestHealthService_ReportDegraded(t *testing.T) {
+	hs := NewHealthService()
+	hs.AddReadinessCheck("nats", func() error { return nil })
+	hs.AddReadinessCheck("cache", func() error { return errors.New("unreachable") }, WithCritical(false))
+	hs.AddStartupCheck("migrations", func() error { return nil })
+
+	ready, err := hs.Report(ProbeReady)
+	require.NoError(t, err)
+	assert.Equal(t, StatusDegraded, ready.Status)
+	assert.False(t, ready.Readiness["cache"].Critical)
+	assert.True(t, ready.Readiness["nats"].Critical)
+
+	startup, err := hs.Report(ProbeStartup)
+	require.NoError(t, err)
+	assert.Equal(t, StatusUp, startup.Status)
+	assert.Contains(t, startup.Startup, "migrations")
+	assert.Nil(t, startup.Readiness)
+
+	hs.AddStartupCheck("migrations", func() error { return errors.New("pending") })
+	detail, err := hs.Report(ProbeDetail)
+	require.NoError(t, err)
+	assert.Equal(t, StatusDown, detail.Status)
+	assert.Len(t, detail.Startup, 1)
+}
@@ -0,0 +1,172 @@
+// Package loadshed rejects work once the process is under pressure (too many
+// requests in flight, deep queues, CPU or memory), so that the admitted work
+// keeps its latency
+package loadshed
+
+import (
+	"math"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/procfs"
+)
+
+// Config holds the thresholds above which work is shed. Zero thresholds are
+// not checked.
+type Config struct {
+	Enabled bool `mapstructure:"enabled"`
+	// MaxInFlight is the number of units of work running at once
+	MaxInFlight int `mapstructure:"max_in_flight"`
+	// MaxQueueDepth is the number of units of work waiting, for shedders with
+	// a queue depth (see WithQueueDepth)
+	MaxQueueDepth int `mapstructure:"max_queue_depth"`
+	// MaxCPU is the CPU usage of the process, as a fraction of the CPUs (0-1)
+	MaxCPU float64 `mapstructure:"max_cpu"`
+	// MaxMemory is the resident memory of the process in bytes
+	MaxMemory int64 `mapstructure:"max_memory"`
+	// Interval between two samples of the queue depth, CPU and memory
+	// (1s when unset)
+	Interval time.Duration `mapstructure:"interval"`
+	// RetryAfter is how long shed work should wait before being retried
+	// (1s when unset)
+	RetryAfter time.Duration `mapstructure:"retry_after"`
+}
+
+// Reason is the threshold that shed a unit of work
+type Reason string
+
+// Reasons
+const (
+	ReasonInFlight   Reason = "in_flight"
+	ReasonQueueDepth Reason = "queue_depth"
+	ReasonCPU        Reason = "cpu"
+	ReasonMemory     Reason = "memory"
+)
+
+// Option configures a Shedder
+type Option func(*Shedder)
+
+// WithQueueDepth sets the function returning the number of units of work
+// waiting, checked against MaxQueueDepth
+func WithQueueDepth(depth func() int) Option {
+	return func(s *Shedder) {
+		s.depth = depth
+	}
+}
+
+// Shedder admits units of work while the process is below its thresholds.
+// The queue depth, CPU and memory are sampled at most once per interval, by
+// the caller of Acquire that finds the last sample outdated.
+type Shedder struct {
+	cfg   Config
+	depth func() int
+
+	inFlight atomic.Int64
+
+	// Last sample
+	queued   atomic.Int64
+	cpu      atomic.Uint64 // math.Float64bits of the CPU usage
+	memory   atomic.Int64
+	sampleAt atomic.Int64 // UnixNano of the next sample
+
+	sampling sync.Mutex
+	proc     *procfs.Proc // nil where /proc is unavailable
+	cpuTime  float64
+	cpuAt    time.Time
+}
+
+// New creates a shedder with the thresholds of cfg
+func New(cfg Config, opts ...Option) *Shedder {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.RetryAfter <= 0 {
+		cfg.RetryAfter = time.Second
+	}
+	s := &Shedder{cfg: cfg}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if cfg.MaxCPU > 0 || cfg.MaxMemory > 0 {
+		// Without /proc (outside Linux) the CPU and memory are not checked
+		if proc, err := procfs.Self(); err == nil {
+			s.proc = &proc
+		}
+	}
+	s.sample(time.Now())
+	return s
+}
+
+// Acquire admits one more unit of work and returns the function ending it,
+// or the threshold the process is above
+func (s *Shedder) Acquire() (release func(), reason Reason, ok bool) {
+	if now := time.Now(); now.UnixNano() >= s.sampleAt.Load() && s.sampling.TryLock() {
+		s.sample(now)
+		s.sampling.Unlock()
+	}
+
+	if reason, over := s.overloaded(); over {
+		return nil, reason, false
+	}
+	if n := s.inFlight.Add(1); s.cfg.MaxInFlight > 0 && n > int64(s.cfg.MaxInFlight) {
+		s.inFlight.Add(-1)
+		return nil, ReasonInFlight, false
+	}
+	var once sync.Once
+	return func() { once.Do(func() { s.inFlight.Add(-1) }) }, "", true
+}
+
+// overloaded returns the sampled threshold the process is above, if any
+func (s *Shedder) overloaded() (Reason, bool) {
+	switch {
+	case s.cfg.MaxQueueDepth > 0 && s.queued.Load() > int64(s.cfg.MaxQueueDepth):
+		return ReasonQueueDepth, true
+	case s.cfg.MaxCPU > 0 && s.CPU() > s.cfg.MaxCPU:
+		return ReasonCPU, true
+	case s.cfg.MaxMemory > 0 && s.memory.Load() > s.cfg.MaxMemory:
+		return ReasonMemory, true
+	}
+	return "", false
+}
+
+// sample reads the queue depth, CPU and memory; the CPU usage is the one
+// since the previous sample
+func (s *Shedder) sample(now time.Time) {
+	s.sampleAt.Store(now.Add(s.cfg.Interval).UnixNano())
+	if s.depth != nil {
+		s.queued.Store(int64(s.depth()))
+	}
+	if s.proc == nil {
+		return
+	}
+	stat, err := s.proc.Stat()
+	if err != nil {
+		return
+	}
+	s.memory.Store(int64(stat.ResidentMemory()))
+	cpuTime := stat.CPUTime()
+	if !s.cpuAt.IsZero() {
+		if elapsed := now.Sub(s.cpuAt).Seconds(); elapsed > 0 {
+			usage := (cpuTime - s.cpuTime) / elapsed / float64(runtime.NumCPU())
+			s.cpu.Store(math.Float64bits(usage))
+		}
+	}
+	s.cpuTime, s.cpuAt = cpuTime, now
+}
+
+// InFlight returns the number of units of work admitted and not released
+func (s *Shedder) InFlight() int {
+	return int(s.inFlight.Load())
+}
+
+// CPU returns the last sampled CPU usage, as a fraction of the CPUs
+func (s *Shedder) CPU() float64 {
+	return math.Float64frombits(s.cpu.Load())
+}
+
+// RetryAfter returns how long shed work should wait before being retried
+func (s *Shedder) RetryAfter() time.Duration {
+	return s.cfg.RetryAfter
+}
@@ -0,0 +1,71 @@
+package loadshed
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShedder_InFlight(t *testing.T) {
+	s := New(Config{Enabled: true, MaxInFlight: 2})
+
+	first, _, ok := s.Acquire()
+	require.True(t, ok)
+	second, _, ok := s.Acquire()
+	require.True(t, ok)
+	_, reason, ok := s.Acquire()
+	assert.False(t, ok)
+	assert.Equal(t, ReasonInFlight, reason)
+	assert.Equal(t, 2, s.InFlight())
+
+	// Releasing twice frees one slot only
+	first()
+	first()
+	assert.Equal(t, 1, s.InFlight())
+	third, _, ok := s.Acquire()
+	require.True(t, ok)
+	_, _, ok = s.Acquire()
+	assert.False(t, ok)
+
+	second()
+	third()
+	assert.Equal(t, 0, s.InFlight())
+	assert.Equal(t, time.Second, s.RetryAfter(), "default retry after")
+}
+
+func TestShedder_QueueDepth(t *testing.T) {
+	depth := 10
+	s := New(Config{Enabled: true, MaxQueueDepth: 5, Interval: time.Millisecond},
+		WithQueueDepth(func() int { return depth }))
+
+	_, reason, ok := s.Acquire()
+	assert.False(t, ok)
+	assert.Equal(t, ReasonQueueDepth, reason)
+
+	// The depth is sampled again once the interval elapsed
+	depth = 0
+	time.Sleep(5 * time.Millisecond)
+	release, _, ok := s.Acquire()
+	require.True(t, ok)
+	release()
+}
+
+func TestShedder_Memory(t *testing.T) {
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		t.Skip("/proc is unavailable")
+	}
+
+	// Any process uses more than one byte
+	s := New(Config{Enabled: true, MaxMemory: 1})
+	_, reason, ok := s.Acquire()
+	assert.False(t, ok)
+	assert.Equal(t, ReasonMemory, reason)
+
+	s = New(Config{Enabled: true, MaxMemory: 1 << 50})
+	release, _, ok := s.Acquire()
+	require.True(t, ok)
+	release()
+}
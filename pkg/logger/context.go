@@ -3,6 +3,7 @@ package logger
 import (
 	"context"
 
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -10,28 +11,63 @@ type contextKey string
 
 const loggerKey contextKey = "logger"
 
+// ctxLogger is the logger stored in a context. base carries the fields added
+// through the context helpers; traced is base annotated with the IDs of span,
+// so trace fields are never duplicated when a child span starts.
+type ctxLogger struct {
+	base   *zap.Logger
+	traced *zap.Logger
+	span   trace.SpanContext
+}
+
 // WithContext adds a logger to the context
 func WithContext(ctx context.Context, logger *zap.Logger) context.Context {
-	return context.WithValue(ctx, loggerKey, logger)
+	entry := &ctxLogger{base: logger, traced: logger}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		entry.traced = logger.With(TraceFields(sc)...)
+		entry.span = sc
+	}
+	return context.WithValue(ctx, loggerKey, entry)
 }
 
 // FromContext retrieves a logger from the context
-// If no logger is found, returns the global logger
+// If no logger is found, returns the global logger. When the context carries
+// an active span the logger includes its trace_id and span_id.
 func FromContext(ctx context.Context) *zap.Logger {
-	if logger, ok := ctx.Value(loggerKey).(*zap.Logger); ok {
-		return logger
+	sc := trace.SpanContextFromContext(ctx)
+	entry, ok := ctx.Value(loggerKey).(*ctxLogger)
+	if !ok {
+		if sc.IsValid() {
+			return Get().With(TraceFields(sc)...)
+		}
+		return Get()
+	}
+	if !sc.IsValid() {
+		return entry.base
+	}
+	if sc.Equal(entry.span) {
+		return entry.traced
+	}
+	return entry.base.With(TraceFields(sc)...)
+}
+
+// baseFromContext returns the context logger without trace fields
+func baseFromContext(ctx context.Context) *zap.Logger {
+	if entry, ok := ctx.Value(loggerKey).(*ctxLogger); ok {
+		return entry.base
 	}
 	return Get()
 }
 
 // WithRequestID adds a request ID to the logger in context
 func WithRequestID(ctx context.Context, requestID string) context.Context {
-	logger := FromContext(ctx).With(zap.String("request_id", requestID))
+	logger := baseFromContext(ctx).With(zap.String("request_id", requestID))
 	return WithContext(ctx, logger)
 }
 
 // WithTraceID adds a trace ID to the logger in context
+// Prefer starting a span: FromContext adds the IDs of the active span
 func WithTraceID(ctx context.Context, traceID string) context.Context {
-	logger := FromContext(ctx).With(zap.String("trace_id", traceID))
+	logger := baseFromContext(ctx).With(zap.String("trace_id", traceID))
 	return WithContext(ctx, logger)
 }
@@ -2,7 +2,6 @@ package logger
 
 import (
 	"fmt"
-	"os"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -11,70 +10,148 @@ import (
 var (
 	globalLogger *zap.Logger
 	sugar        *zap.SugaredLogger
+	// level is the level of the last logger created by New
+	level = zap.NewAtomicLevel()
 )
 
+// Logger is a zap.Logger whose level can change while running
+type Logger struct {
+	*zap.Logger
+	// level is shared by the sinks without a level of their own
+	level zap.AtomicLevel
+}
+
 // Config holds logger configuration
 type Config struct {
 	Level      string
 	Format     string // json or console
 	OutputPath string
+	// Rotation applies to OutputPath when it is a file
+	Rotation RotationConfig
+	// Sinks replace OutputPath with several outputs, each with its own
+	// level and format (e.g. console info + file debug)
+	Sinks    []SinkConfig
+	Sampling SamplingConfig
 }
 
-// New creates a new logger instance
+// New creates a new logger instance and makes it the global logger
+//
+// Deprecated: the global logger is shared by every user of the package; use
+// NewLogger.
 func New(cfg Config) (*zap.Logger, error) {
-	// Parse log level
-	level, err := zapcore.ParseLevel(cfg.Level)
+	l, err := NewLogger(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("invalid log level: %w", err)
+		return nil, err
 	}
+	globalLogger = l.Logger
+	sugar = l.Sugar()
+	level = l.level
+	return l.Logger, nil
+}
 
-	// Configure encoder
-	var encoderConfig zapcore.EncoderConfig
-	if cfg.Format == "json" {
-		encoderConfig = zap.NewProductionEncoderConfig()
-	} else {
-		encoderConfig = zap.NewDevelopmentEncoderConfig()
-		encoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+// NewLogger creates a logger, leaving the global logger untouched
+func NewLogger(cfg Config) (*Logger, error) {
+	// Parse log level
+	defaultLevel, err := zapcore.ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log level: %w", err)
 	}
-	encoderConfig.TimeKey = "timestamp"
-	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
-	encoderConfig.StacktraceKey = "" // Disable stacktrace
-
-	// Create encoder
-	var encoder zapcore.Encoder
-	if cfg.Format == "json" {
-		encoder = zapcore.NewJSONEncoder(encoderConfig)
-	} else {
-		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	atom := zap.NewAtomicLevelAt(defaultLevel)
+
+	sinks := cfg.Sinks
+	if len(sinks) == 0 {
+		sinks = []SinkConfig{{
+			Output:   cfg.OutputPath,
+			Format:   cfg.Format,
+			Rotation: cfg.Rotation,
+		}}
 	}
 
-	// Configure output
-	var writer zapcore.WriteSyncer
-	if cfg.OutputPath == "" || cfg.OutputPath == "stdout" {
-		writer = zapcore.AddSync(os.Stdout)
-	} else {
-		file, err := os.OpenFile(cfg.OutputPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	cores := make([]zapcore.Core, 0, len(sinks))
+	for _, sink := range sinks {
+		var sinkLevel zapcore.LevelEnabler = atom
+		if sink.Level != "" {
+			if sinkLevel, err = zapcore.ParseLevel(sink.Level); err != nil {
+				return nil, fmt.Errorf("invalid log level for sink %q: %w", sink.Output, err)
+			}
+		}
+		format := sink.Format
+		if format == "" {
+			format = cfg.Format
+		}
+		writer, err := openSink(sink)
 		if err != nil {
-			return nil, fmt.Errorf("failed to open log file: %w", err)
+			return nil, err
 		}
-		writer = zapcore.AddSync(file)
+		cores = append(cores, zapcore.NewCore(newEncoder(format), writer, sinkLevel))
 	}
 
 	// Create core
-	core := zapcore.NewCore(encoder, writer, level)
+	core := zapcore.NewTee(cores...)
+	if cfg.Sampling.Enabled {
+		core = newSampler(core, cfg.Sampling)
+	}
 
 	// Create logger
 	//logger := zap.New(core, zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel))
 	// Disable stacktrace
 	logger := zap.New(core, zap.AddCaller())
 
-	globalLogger = logger
-	sugar = logger.Sugar()
+	return &Logger{Logger: logger, level: atom}, nil
+}
 
-	return logger, nil
+// Sample returns a copy of logger applying the sampling policy of cfg
+func Sample(logger *zap.Logger, cfg SamplingConfig) *zap.Logger {
+	return logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return newSampler(core, cfg)
+	}))
+}
+
+// SetLevel changes the level of the logger, except for the sinks with a level
+// of their own
+func (l *Logger) SetLevel(lvl string) error {
+	return setLevel(l.level, lvl)
+}
+
+// SetLevel changes the level of the last logger created by New
+//
+// Deprecated: use Logger.SetLevel.
+func SetLevel(lvl string) error {
+	return setLevel(level, lvl)
+}
+
+func setLevel(atom zap.AtomicLevel, lvl string) error {
+	parsed, err := zapcore.ParseLevel(lvl)
+	if err != nil {
+		return fmt.Errorf("invalid log level: %w", err)
+	}
+	atom.SetLevel(parsed)
+	return nil
+}
+
+// newEncoder creates the json or console (default) encoder
+func newEncoder(format string) zapcore.Encoder {
+	// Configure encoder
+	var encoderConfig zapcore.EncoderConfig
+	if format == "json" {
+		encoderConfig = zap.NewProductionEncoderConfig()
+	} else {
+		encoderConfig = zap.NewDevelopmentEncoderConfig()
+		encoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+	}
+	encoderConfig.TimeKey = "timestamp"
+	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	encoderConfig.StacktraceKey = "" // Disable stacktrace
+
+	if format == "json" {
+		return zapcore.NewJSONEncoder(encoderConfig)
+	}
+	return zapcore.NewConsoleEncoder(encoderConfig)
 }
 
 // Get returns the global logger
+//
+// Deprecated: use a Logger created with NewLogger.
 func Get() *zap.Logger {
 	if globalLogger == nil {
 		// Create a default logger if none exists
@@ -85,6 +162,8 @@ func Get() *zap.Logger {
 }
 
 // Sugar returns the global sugared logger
+//
+// Deprecated: use a Logger created with NewLogger.
 func Sugar() *zap.SugaredLogger {
 	if sugar == nil {
 		sugar = Get().Sugar()
@@ -93,36 +172,50 @@ func Sugar() *zap.SugaredLogger {
 }
 
 // WithFields creates a logger with additional fields
+//
+// Deprecated: use a Logger created with NewLogger.
 func WithFields(fields ...zap.Field) *zap.Logger {
 	return Get().With(fields...)
 }
 
 // Debug logs a debug message
+//
+// Deprecated: use a Logger created with NewLogger.
 func Debug(msg string, fields ...zap.Field) {
 	Get().Debug(msg, fields...)
 }
 
 // Info logs an info message
+//
+// Deprecated: use a Logger created with NewLogger.
 func Info(msg string, fields ...zap.Field) {
 	Get().Info(msg, fields...)
 }
 
 // Warn logs a warning message
+//
+// Deprecated: use a Logger created with NewLogger.
 func Warn(msg string, fields ...zap.Field) {
 	Get().Warn(msg, fields...)
 }
 
 // Error logs an error message
+//
+// Deprecated: use a Logger created with NewLogger.
 func Error(msg string, fields ...zap.Field) {
 	Get().Error(msg, fields...)
 }
 
 // Fatal logs a fatal message and exits
+//
+// Deprecated: use a Logger created with NewLogger.
 func Fatal(msg string, fields ...zap.Field) {
 	Get().Fatal(msg, fields...)
 }
 
 // Sync flushes any buffered log entries
+//
+// Deprecated: use a Logger created with NewLogger.
 func Sync() error {
 	if globalLogger != nil {
 		return globalLogger.Sync()
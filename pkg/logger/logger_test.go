@@ -4,9 +4,11 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func TestNew(t *testing.T) {
@@ -362,3 +364,86 @@ func TestMultipleLoggerCreation(t *testing.T) {
 		}
 	}
 }
+
+func TestSetLevel(t *testing.T) {
+	log, err := New(Config{
+		Level:  "info",
+		Format: "json",
+		Sinks: []SinkConfig{
+			{Output: "stdout"},
+			{Output: "stderr", Level: "error"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if log.Core().Enabled(zapcore.DebugLevel) {
+		t.Fatal("debug should be disabled at info level")
+	}
+
+	if err := SetLevel("debug"); err != nil {
+		t.Fatalf("SetLevel() error = %v", err)
+	}
+	if !log.Core().Enabled(zapcore.DebugLevel) {
+		t.Error("debug should be enabled after SetLevel(debug)")
+	}
+
+	if err := SetLevel("error"); err != nil {
+		t.Fatalf("SetLevel() error = %v", err)
+	}
+	if log.Core().Enabled(zapcore.WarnLevel) {
+		t.Error("warn should be disabled after SetLevel(error)")
+	}
+
+	if err := SetLevel("verbose"); err == nil {
+		t.Error("SetLevel() should reject an unknown level")
+	}
+}
+
+func TestNewLogger_Independent(t *testing.T) {
+	global, err := New(Config{Level: "info", Format: "json", OutputPath: "stdout"})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	first, err := NewLogger(Config{Level: "info", Format: "json", OutputPath: "stdout"})
+	if err != nil {
+		t.Fatalf("NewLogger() error = %v", err)
+	}
+	second, err := NewLogger(Config{Level: "info", Format: "json", OutputPath: "stdout"})
+	if err != nil {
+		t.Fatalf("NewLogger() error = %v", err)
+	}
+	if Get() != global {
+		t.Error("NewLogger() should not replace the global logger")
+	}
+
+	if err := first.SetLevel("debug"); err != nil {
+		t.Fatalf("SetLevel() error = %v", err)
+	}
+	if !first.Core().Enabled(zapcore.DebugLevel) {
+		t.Error("debug should be enabled on the first logger")
+	}
+	if second.Core().Enabled(zapcore.DebugLevel) || global.Core().Enabled(zapcore.DebugLevel) {
+		t.Error("the level of the other loggers should not change")
+	}
+}
+
+func TestSample(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+	sampled := Sample(logger, SamplingConfig{Enabled: true, Initial: 1, Thereafter: 3, Tick: time.Minute})
+
+	for i := 0; i < 7; i++ {
+		sampled.Info("repeated")
+	}
+	logger.Info("unsampled")
+
+	// First 1, then every 3rd of the remaining 6
+	if got := logs.FilterMessage("repeated").Len(); got != 3 {
+		t.Errorf("sampled entries = %d, want 3", got)
+	}
+	if got := logs.FilterMessage("unsampled").Len(); got != 1 {
+		t.Errorf("original logger entries = %d, want 1", got)
+	}
+}
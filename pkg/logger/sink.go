@@ -0,0 +1,82 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// SinkConfig is one log output
+type SinkConfig struct {
+	Output   string // stdout (default), stderr or a file path
+	Level    string // defaults to Config.Level
+	Format   string // json or console, defaults to Config.Format
+	Rotation RotationConfig
+}
+
+// RotationConfig rotates file outputs by size, keeping a bounded number of
+// backups for a bounded time
+type RotationConfig struct {
+	Enabled    bool
+	MaxSizeMB  int  // size that triggers a rotation (default 100)
+	MaxAgeDays int  // days to keep rotated files, 0 keeps them
+	MaxBackups int  // rotated files to keep, 0 keeps them all
+	Compress   bool // gzip rotated files
+	LocalTime  bool // use local time in backup names instead of UTC
+}
+
+// SamplingConfig caps repeated log entries: per Tick, the first Initial
+// entries with the same level and message are logged, then every
+// Thereafter-th one
+type SamplingConfig struct {
+	Enabled    bool
+	Initial    int           // default 100
+	Thereafter int           // default 100
+	Tick       time.Duration // default 1s
+}
+
+// openSink returns the writer of the sink output
+func openSink(cfg SinkConfig) (zapcore.WriteSyncer, error) {
+	switch cfg.Output {
+	case "", "stdout":
+		return zapcore.Lock(os.Stdout), nil
+	case "stderr":
+		return zapcore.Lock(os.Stderr), nil
+	}
+
+	if cfg.Rotation.Enabled {
+		// lumberjack opens the file on first write and serializes writes
+		return zapcore.AddSync(&lumberjack.Logger{
+			Filename:   cfg.Output,
+			MaxSize:    cfg.Rotation.MaxSizeMB,
+			MaxAge:     cfg.Rotation.MaxAgeDays,
+			MaxBackups: cfg.Rotation.MaxBackups,
+			Compress:   cfg.Rotation.Compress,
+			LocalTime:  cfg.Rotation.LocalTime,
+		}), nil
+	}
+
+	file, err := os.OpenFile(cfg.Output, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	return zapcore.AddSync(file), nil
+}
+
+// newSampler wraps core with the sampling policy
+func newSampler(core zapcore.Core, cfg SamplingConfig) zapcore.Core {
+	initial, thereafter, tick := cfg.Initial, cfg.Thereafter, cfg.Tick
+	if initial <= 0 {
+		initial = 100
+	}
+	if thereafter <= 0 {
+		thereafter = 100
+	}
+	if tick <= 0 {
+		tick = time.Second
+	}
+	return zapcore.NewSamplerWithOptions(core, tick, initial, thereafter)
+}
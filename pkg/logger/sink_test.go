@@ -0,0 +1,110 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNew_SinksWithLevels(t *testing.T) {
+	tmpDir := t.TempDir()
+	infoFile := filepath.Join(tmpDir, "info.log")
+	debugFile := filepath.Join(tmpDir, "debug.log")
+
+	logger, err := New(Config{
+		Level:  "info",
+		Format: "json",
+		Sinks: []SinkConfig{
+			{Output: infoFile},
+			{Output: debugFile, Level: "debug", Format: "console"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	logger.Debug("debug message")
+	logger.Info("info message")
+	logger.Sync()
+
+	info, _ := os.ReadFile(infoFile)
+	debug, _ := os.ReadFile(debugFile)
+
+	if strings.Contains(string(info), "debug message") {
+		t.Error("info sink should not contain debug entries")
+	}
+	if !strings.Contains(string(info), `"msg":"info message"`) {
+		t.Errorf("info sink should contain JSON info entry, got %q", info)
+	}
+	if !strings.Contains(string(debug), "debug message") || !strings.Contains(string(debug), "info message") {
+		t.Errorf("debug sink should contain both entries, got %q", debug)
+	}
+}
+
+func TestNew_InvalidSinkLevel(t *testing.T) {
+	_, err := New(Config{
+		Level: "info",
+		Sinks: []SinkConfig{{Output: "stdout", Level: "verbose"}},
+	})
+	if err == nil {
+		t.Error("New() should fail on an invalid sink level")
+	}
+}
+
+func TestNew_Rotation(t *testing.T) {
+	tmpDir := t.TempDir()
+	logFile := filepath.Join(tmpDir, "app.log")
+
+	logger, err := New(Config{
+		Level:      "info",
+		Format:     "json",
+		OutputPath: logFile,
+		Rotation:   RotationConfig{Enabled: true, MaxSizeMB: 1, MaxBackups: 2},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	// Write a bit more than 1 MB to force a rotation
+	payload := strings.Repeat("x", 1024)
+	for i := 0; i < 1100; i++ {
+		logger.Info(payload)
+	}
+	logger.Sync()
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	if len(entries) < 2 {
+		t.Errorf("expected a rotated backup next to app.log, got %d files", len(entries))
+	}
+}
+
+func TestNew_Sampling(t *testing.T) {
+	tmpDir := t.TempDir()
+	logFile := filepath.Join(tmpDir, "sampled.log")
+
+	logger, err := New(Config{
+		Level:      "info",
+		Format:     "json",
+		OutputPath: logFile,
+		Sampling:   SamplingConfig{Enabled: true, Initial: 2, Thereafter: 5, Tick: time.Minute},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	for i := 0; i < 12; i++ {
+		logger.Info("repeated")
+	}
+	logger.Sync()
+
+	data, _ := os.ReadFile(logFile)
+	// First 2, then every 5th of the remaining 10
+	if got := strings.Count(string(data), "repeated"); got != 4 {
+		t.Errorf("sampled entries = %d, want 4", got)
+	}
+}
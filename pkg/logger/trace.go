@@ -0,0 +1,43 @@
+package logger
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const (
+	// TraceIDKey is the log field holding the OpenTelemetry trace ID
+	TraceIDKey = "trace_id"
+	// SpanIDKey is the log field holding the OpenTelemetry span ID
+	SpanIDKey = "span_id"
+)
+
+// TraceFields returns the trace_id and span_id fields of sc, or nil when sc is
+// not valid. The IDs use the W3C hex encoding, matching what tracing backends
+// such as Tempo and Jaeger display, so logs can be joined with traces.
+func TraceFields(sc trace.SpanContext) []zap.Field {
+	if !sc.IsValid() {
+		return nil
+	}
+	return []zap.Field{
+		zap.String(TraceIDKey, sc.TraceID().String()),
+		zap.String(SpanIDKey, sc.SpanID().String()),
+	}
+}
+
+// TraceFieldsFromContext returns the trace fields of the span active in ctx
+func TraceFieldsFromContext(ctx context.Context) []zap.Field {
+	return TraceFields(trace.SpanContextFromContext(ctx))
+}
+
+// WithTrace returns l annotated with the trace fields of the span active in
+// ctx, or l itself when there is none
+func WithTrace(ctx context.Context, l *zap.Logger) *zap.Logger {
+	fields := TraceFieldsFromContext(ctx)
+	if len(fields) == 0 {
+		return l
+	}
+	return l.With(fields...)
+}
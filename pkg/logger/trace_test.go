@@ -0,0 +1,86 @@
+package logger
+
+import (
+	"context"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func fieldValue(fields []zap.Field, key string) string {
+	for _, f := range fields {
+		if f.Key == key {
+			return f.String
+		}
+	}
+	return ""
+}
+
+func TestTraceFields_NoSpan(t *testing.T) {
+	if fields := TraceFieldsFromContext(context.Background()); fields != nil {
+		t.Errorf("TraceFieldsFromContext() = %v, want nil", fields)
+	}
+}
+
+func TestFromContext_AddsTraceFields(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	tp := sdktrace.NewTracerProvider()
+	defer tp.Shutdown(context.Background())
+
+	ctx := WithContext(context.Background(), zap.New(core))
+	ctx = WithRequestID(ctx, "req-1")
+	ctx, span := tp.Tracer("test").Start(ctx, "parent")
+	defer span.End()
+
+	FromContext(ctx).Info("in parent")
+
+	ctx, child := tp.Tracer("test").Start(ctx, "child")
+	defer child.End()
+	FromContext(ctx).Info("in child")
+
+	entries := logs.All()
+	if len(entries) != 2 {
+		t.Fatalf("got %d log entries, want 2", len(entries))
+	}
+
+	parent := entries[0].Context
+	if got := fieldValue(parent, "request_id"); got != "req-1" {
+		t.Errorf("request_id = %q, want req-1", got)
+	}
+	if got := fieldValue(parent, TraceIDKey); got != span.SpanContext().TraceID().String() {
+		t.Errorf("trace_id = %q, want %q", got, span.SpanContext().TraceID())
+	}
+	if got := fieldValue(parent, SpanIDKey); got != span.SpanContext().SpanID().String() {
+		t.Errorf("span_id = %q, want %q", got, span.SpanContext().SpanID())
+	}
+
+	// The child logger carries the child span once, not both spans
+	childFields := entries[1].Context
+	if got := fieldValue(childFields, SpanIDKey); got != child.SpanContext().SpanID().String() {
+		t.Errorf("child span_id = %q, want %q", got, child.SpanContext().SpanID())
+	}
+	count := 0
+	for _, f := range childFields {
+		if f.Key == SpanIDKey {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("child log has %d span_id fields, want 1", count)
+	}
+}
+
+func TestFromContext_SameSpanReusesLogger(t *testing.T) {
+	tp := sdktrace.NewTracerProvider()
+	defer tp.Shutdown(context.Background())
+
+	ctx, span := tp.Tracer("test").Start(context.Background(), "op")
+	defer span.End()
+
+	ctx = WithContext(ctx, zap.NewNop())
+	if FromContext(ctx) != FromContext(ctx) {
+		t.Error("FromContext() should reuse the annotated logger for the same span")
+	}
+}
@@ -0,0 +1,296 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"grouter/pkg/chaos"
+	"grouter/pkg/config"
+	"grouter/pkg/health"
+	messaging "grouter/pkg/messaging/nats"
+
+	"grouter/pkg/web"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminService exposes the services, health, NATS subscriptions, recent
+// errors, configuration, objectives, replays, quarantine and chaos
+// experiments of the manager over HTTP, and reloads its configuration. The
+// dashboard served under /admin/ui shows them.
+// Registering it with the ServiceManager mounts its routes on the web server.
+type AdminService struct {
+	manager *ServiceManager
+}
+
+// NewAdminService creates a new AdminService for the given manager.
+func NewAdminService(m *ServiceManager) *AdminService {
+	return &AdminService{manager: m}
+}
+
+// Name returns the service name.
+func (s *AdminService) Name() string {
+	return "admin"
+}
+
+// RegisterRoutes registers the admin endpoints.
+func (s *AdminService) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/admin/services", s.ListServicesHandler)
+	router.POST("/admin/services/:name/start", s.StartServiceHandler)
+	router.POST("/admin/services/:name/stop", s.StopServiceHandler)
+	router.POST("/admin/reload", s.ReloadHandler)
+	router.GET("/admin/health", s.HealthHandler)
+	router.GET("/admin/subscriptions", s.SubscriptionsHandler)
+	router.GET("/admin/errors", s.ErrorsHandler)
+	router.GET("/admin/config", s.ConfigHandler)
+	router.GET("/admin/slo", s.SLOHandler)
+	router.GET("/admin/replays", s.ListReplaysHandler)
+	router.POST("/admin/replays", s.StartReplayHandler)
+	router.GET("/admin/replays/:id", s.GetReplayHandler)
+	router.DELETE("/admin/replays/:id", s.CancelReplayHandler)
+	router.GET("/admin/quarantine", s.ListQuarantinedHandler)
+	router.GET("/admin/quarantine/:seq", s.GetQuarantinedHandler)
+	router.POST("/admin/quarantine/:seq/requeue", s.RequeueQuarantinedHandler)
+	router.GET("/admin/chaos", s.GetChaosHandler)
+	router.PUT("/admin/chaos", s.SetChaosHandler)
+	web.NewAdminUI().RegisterRoutes(router)
+}
+
+// ListServicesHandler returns the state of the registered services
+func (s *AdminService) ListServicesHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, s.manager.ServiceStates(c.Request.Context()))
+}
+
+// StartServiceHandler starts a stopped service and returns its state
+func (s *AdminService) StartServiceHandler(c *gin.Context) {
+	s.lifecycle(c, s.manager.StartService)
+}
+
+// StopServiceHandler stops a running service and returns its state
+func (s *AdminService) StopServiceHandler(c *gin.Context) {
+	s.lifecycle(c, s.manager.StopService)
+}
+
+func (s *AdminService) lifecycle(c *gin.Context, action func(ctx context.Context, name string) error) {
+	name := c.Param("name")
+	if err := action(c.Request.Context(), name); err != nil {
+		switch {
+		case errors.Is(err, ErrServiceNotFound):
+			web.AbortWithError(c, web.NotFound(err.Error()))
+		case errors.Is(err, ErrNoLifecycle):
+			web.AbortWithError(c, web.Conflict(err.Error()))
+		default:
+			web.AbortWithError(c, web.Internal(err))
+		}
+		return
+	}
+	info, err := s.manager.ServiceState(c.Request.Context(), name)
+	if err != nil {
+		web.AbortWithError(c, web.NotFound(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, info)
+}
+
+// ReloadHandler re-reads the configuration and applies it
+func (s *AdminService) ReloadHandler(c *gin.Context) {
+	if err := s.manager.ReloadConfig(); err != nil {
+		if errors.Is(err, ErrNoConfigLoader) {
+			web.AbortWithError(c, web.Unavailable(err.Error()))
+			return
+		}
+		web.AbortWithError(c, web.BadRequest(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "reloaded"})
+}
+
+// HealthHandler returns the health report of ?probe=, detail by default
+func (s *AdminService) HealthHandler(c *gin.Context) {
+	probe := c.DefaultQuery("probe", health.ProbeDetail)
+	report, err := s.manager.HealthReport(probe)
+	if err != nil {
+		if s.manager.Health() == nil {
+			web.AbortWithError(c, web.Unavailable(err.Error()))
+			return
+		}
+		web.AbortWithError(c, web.BadRequest(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// ErrorsHandler returns the last errors of the message handlers, newest
+// first
+func (s *AdminService) ErrorsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, s.manager.RecentErrors())
+}
+
+// ConfigHandler returns the configuration with its secrets redacted
+func (s *AdminService) ConfigHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, config.Redacted(s.manager.Config()))
+}
+
+// SLOHandler returns the state of the service level objectives
+func (s *AdminService) SLOHandler(c *gin.Context) {
+	status, err := s.manager.SLOStatus()
+	if err != nil {
+		web.AbortWithError(c, web.Unavailable(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// SubscriptionsHandler returns the subscriptions, optionally filtered by
+// ?service=
+func (s *AdminService) SubscriptionsHandler(c *gin.Context) {
+	subs := s.manager.ListSubscriptions()
+	if service := c.Query("service"); service != "" {
+		filtered := make([]SubscriptionInfo, 0, len(subs))
+		for _, sub := range subs {
+			if normalizeService(sub.Service) == normalizeService(service) {
+				filtered = append(filtered, sub)
+			}
+		}
+		subs = filtered
+	}
+	c.JSON(http.StatusOK, subs)
+}
+
+// ListReplaysHandler returns the replay jobs, the most recent first
+func (s *AdminService) ListReplaysHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, s.manager.ListReplays())
+}
+
+// StartReplayHandler starts the replay of the ReplayRequest body and returns
+// its job
+func (s *AdminService) StartReplayHandler(c *gin.Context) {
+	var req ReplayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		web.AbortWithError(c, web.BadRequest("invalid replay request").Wrap(err))
+		return
+	}
+	job, err := s.manager.StartReplay(req)
+	if err != nil {
+		web.AbortWithError(c, web.BadRequest(err.Error()))
+		return
+	}
+	c.JSON(http.StatusAccepted, job)
+}
+
+// GetReplayHandler returns the replay job :id
+func (s *AdminService) GetReplayHandler(c *gin.Context) {
+	job, err := s.manager.GetReplay(c.Param("id"))
+	if err != nil {
+		replayError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// CancelReplayHandler cancels the replay job :id and returns it
+func (s *AdminService) CancelReplayHandler(c *gin.Context) {
+	job, err := s.manager.CancelReplay(c.Param("id"))
+	if err != nil {
+		replayError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+func replayError(c *gin.Context, err error) {
+	if errors.Is(err, ErrReplayNotFound) {
+		web.AbortWithError(c, web.NotFound(err.Error()))
+		return
+	}
+	web.AbortWithError(c, web.Internal(err))
+}
+
+// ListQuarantinedHandler returns the quarantined messages, the oldest first,
+// up to ?limit=
+func (s *AdminService) ListQuarantinedHandler(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if err != nil || limit < 0 {
+		web.AbortWithError(c, web.BadRequest("invalid limit"))
+		return
+	}
+	msgs, err := s.manager.ListQuarantined(c.Request.Context(), limit)
+	if err != nil {
+		quarantineError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, msgs)
+}
+
+// GetQuarantinedHandler returns the quarantined message :seq
+func (s *AdminService) GetQuarantinedHandler(c *gin.Context) {
+	seq, err := strconv.ParseUint(c.Param("seq"), 10, 64)
+	if err != nil {
+		web.AbortWithError(c, web.BadRequest("invalid sequence"))
+		return
+	}
+	msg, err := s.manager.GetQuarantined(c.Request.Context(), seq)
+	if err != nil {
+		quarantineError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, msg)
+}
+
+// RequeueQuarantinedHandler publishes the quarantined message :seq again on
+// its subject and returns it
+func (s *AdminService) RequeueQuarantinedHandler(c *gin.Context) {
+	seq, err := strconv.ParseUint(c.Param("seq"), 10, 64)
+	if err != nil {
+		web.AbortWithError(c, web.BadRequest("invalid sequence"))
+		return
+	}
+	msg, err := s.manager.RequeueQuarantined(c.Request.Context(), seq)
+	if err != nil {
+		quarantineError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, msg)
+}
+
+func quarantineError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, messaging.ErrNotQuarantined):
+		web.AbortWithError(c, web.NotFound(err.Error()))
+	case errors.Is(err, ErrQuarantineDisabled):
+		web.AbortWithError(c, web.Unavailable(err.Error()))
+	default:
+		web.AbortWithError(c, web.Internal(err))
+	}
+}
+
+// GetChaosHandler returns whether faults are injected, and their rules
+func (s *AdminService) GetChaosHandler(c *gin.Context) {
+	state, err := s.manager.ChaosState()
+	if err != nil {
+		web.AbortWithError(c, web.Unavailable(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, state)
+}
+
+// SetChaosHandler replaces the chaos state with the chaos.State body, e.g.
+// {"active": false} to stop an experiment, and returns it
+func (s *AdminService) SetChaosHandler(c *gin.Context) {
+	var state chaos.State
+	if err := c.ShouldBindJSON(&state); err != nil {
+		web.AbortWithError(c, web.BadRequest("invalid chaos state").Wrap(err))
+		return
+	}
+	if err := s.manager.SetChaosState(state); err != nil {
+		if errors.Is(err, ErrChaosDisabled) {
+			web.AbortWithError(c, web.Unavailable(err.Error()))
+			return
+		}
+		web.AbortWithError(c, web.BadRequest(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, state)
+}
@@ -0,0 +1,247 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"grouter/pkg/config"
+	"grouter/pkg/health"
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminService_Subscriptions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mgr := newNATSManager(t, runNATSServer(t, false))
+	require.NoError(t, mgr.RegisterService(&subjectService{name: "orders", subjects: []SubjectSpec{{Subject: "orders.>"}}}))
+	require.NoError(t, mgr.RegisterService(&subjectService{name: "audit", subjects: []SubjectSpec{{Subject: "audit.>"}}}))
+
+	engine := gin.New()
+	admin := NewAdminService(mgr)
+	admin.RegisterRoutes(&engine.RouterGroup)
+	assert.Equal(t, "admin", admin.Name())
+
+	get := func(url string) []SubscriptionInfo {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		var subs []SubscriptionInfo
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &subs))
+		return subs
+	}
+
+	assert.Len(t, get("/admin/subscriptions"), 2)
+	subs := get("/admin/subscriptions?service=Orders")
+	require.Len(t, subs, 1)
+	assert.Equal(t, "orders.>", subs[0].Subject)
+	assert.Empty(t, get("/admin/subscriptions?service=billing"))
+}
+
+func TestAdminService_Replays(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mgr := newReplayManager(t, 2)
+	engine := gin.New()
+	NewAdminService(mgr).RegisterRoutes(&engine.RouterGroup)
+
+	serve := func(method, url, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(method, url, strings.NewReader(body)))
+		return w
+	}
+
+	w := serve(http.MethodPost, "/admin/replays", `{"stream":"ORDERS","dry_run":true}`)
+	require.Equal(t, http.StatusAccepted, w.Code)
+	var job ReplayJob
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	assert.Equal(t, "ORDERS", job.Request.Stream)
+	assert.True(t, job.Request.DryRun)
+	waitReplay(t, mgr, job.ID)
+
+	w = serve(http.MethodGet, "/admin/replays/"+job.ID, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	assert.Equal(t, ReplayDone, job.Status)
+	assert.Equal(t, uint64(2), job.Progress.Matched)
+
+	w = serve(http.MethodGet, "/admin/replays", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var jobs []ReplayJob
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &jobs))
+	assert.Len(t, jobs, 1)
+
+	w = serve(http.MethodDelete, "/admin/replays/"+job.ID, "")
+	assert.Equal(t, http.StatusOK, w.Code, "finished jobs can be cancelled")
+
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/admin/replays/unknown", "").Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodDelete, "/admin/replays/unknown", "").Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/admin/replays", `{"stream":`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/admin/replays", `{}`).Code)
+}
+
+func TestAdminService_Quarantine(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mgr := newQuarantineManager(t)
+	engine := gin.New()
+	NewAdminService(mgr).RegisterRoutes(&engine.RouterGroup)
+
+	serve := func(method, url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(method, url, nil))
+		return w
+	}
+
+	w := serve(http.MethodGet, "/admin/quarantine?limit=10")
+	require.Equal(t, http.StatusOK, w.Code)
+	var msgs []messaging.QuarantinedMessage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &msgs))
+	require.Len(t, msgs, 1)
+	seq := strconv.FormatUint(msgs[0].Seq, 10)
+
+	w = serve(http.MethodGet, "/admin/quarantine/"+seq)
+	require.Equal(t, http.StatusOK, w.Code)
+	var msg messaging.QuarantinedMessage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &msg))
+	assert.Equal(t, "orders.created", msg.Subject)
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/admin/quarantine/"+seq+"/requeue").Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodPost, "/admin/quarantine/"+seq+"/requeue").Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/admin/quarantine/"+seq).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodGet, "/admin/quarantine/abc").Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodGet, "/admin/quarantine?limit=-1").Code)
+}
+
+func TestAdminService_Services(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var events []string
+	var mu sync.Mutex
+	mgr := newLifecycleManager()
+	require.NoError(t, mgr.RegisterService(newLifecycleService("orders", &events, &mu)))
+	require.NoError(t, mgr.RegisterService(&mockService{name: "legacy"}))
+	require.NoError(t, mgr.Start(context.Background()))
+	engine := gin.New()
+	NewAdminService(mgr).RegisterRoutes(&engine.RouterGroup)
+
+	serve := func(method, url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(method, url, nil))
+		return w
+	}
+
+	w := serve(http.MethodPost, "/admin/services/orders/stop")
+	require.Equal(t, http.StatusOK, w.Code)
+	var info ServiceInfo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &info))
+	assert.Equal(t, ServiceStopped, info.State)
+
+	w = serve(http.MethodGet, "/admin/services")
+	require.Equal(t, http.StatusOK, w.Code)
+	var infos []ServiceInfo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &infos))
+	assert.Equal(t, []ServiceInfo{{Name: "legacy", State: ServiceRegistered}, {Name: "orders", State: ServiceStopped}}, infos)
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/admin/services/orders/start").Code)
+	assert.Equal(t, http.StatusConflict, serve(http.MethodPost, "/admin/services/legacy/start").Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodPost, "/admin/services/billing/stop").Code)
+}
+
+func TestAdminService_Reload(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mgr := newLifecycleManager()
+	engine := gin.New()
+	NewAdminService(mgr).RegisterRoutes(&engine.RouterGroup)
+	reload := func() int {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/reload", nil))
+		return w.Code
+	}
+	assert.Equal(t, http.StatusServiceUnavailable, reload(), "configurations given with WithConfig cannot be reloaded")
+
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("app:\n  name: grouter\nlog:\n  level: info\n"), 0o644))
+	mgr.loader = config.NewLoader(config.WithArgs([]string{"--config", file}))
+	cfg, err := mgr.loader.Load()
+	require.NoError(t, err)
+	mgr.cfg = cfg
+
+	require.NoError(t, os.WriteFile(file, []byte("app:\n  name: grouter\nlog:\n  level: debug\n"), 0o644))
+	assert.Equal(t, http.StatusOK, reload())
+	assert.Equal(t, "debug", mgr.Config().Log.Level)
+
+	require.NoError(t, os.WriteFile(file, []byte("app:\n  name: grouter\nlog:\n  level: verbose\n"), 0o644))
+	assert.Equal(t, http.StatusBadRequest, reload())
+	assert.Equal(t, "debug", mgr.Config().Log.Level)
+}
+
+func TestAdminService_Dashboard(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mgr := newNATSManager(t, runNATSServer(t, false), func(cfg *config.Config) {
+		cfg.Database = config.DatabaseConfig{Driver: "postgres", Password: "db-pass"}
+	})
+	mgr.health.AddReadinessCheck("store", func() error { return errors.New("store unavailable") })
+	svc := &subjectService{name: "orders", subjects: []SubjectSpec{{Subject: "orders.>"}}, err: errors.New("invalid order")}
+	require.NoError(t, mgr.RegisterService(svc))
+
+	engine := gin.New()
+	NewAdminService(mgr).RegisterRoutes(&engine.RouterGroup)
+	get := func(url string, v any) int {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+		if v != nil && w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), v))
+		}
+		return w.Code
+	}
+
+	t.Run("health", func(t *testing.T) {
+		var report health.Report
+		require.Equal(t, http.StatusOK, get("/admin/health", &report))
+		assert.Equal(t, health.ProbeDetail, report.Probe)
+		assert.Equal(t, health.StatusDown, report.Status)
+		assert.Equal(t, "grouter", report.App)
+		assert.Equal(t, "instance-1", report.Instance)
+		assert.Equal(t, "store unavailable", report.Readiness["store"].Error)
+
+		assert.Equal(t, http.StatusOK, get("/admin/health?probe=live", nil))
+		assert.Equal(t, http.StatusBadRequest, get("/admin/health?probe=nope", nil))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		require.NoError(t, mgr.messenger.Publisher.Publish(context.Background(), "orders.created", "order.created", nil, nil))
+		var errs []ErrorInfo
+		require.Eventually(t, func() bool {
+			return get("/admin/errors", &errs) == http.StatusOK && len(errs) == 1
+		}, 2*time.Second, 10*time.Millisecond)
+		assert.Equal(t, "orders", errs[0].Service)
+		assert.Equal(t, "orders.created", errs[0].Topic)
+		assert.Equal(t, "order.created", errs[0].Type)
+		assert.Equal(t, "invalid order", errs[0].Error)
+	})
+
+	t.Run("config", func(t *testing.T) {
+		var doc map[string]any
+		require.Equal(t, http.StatusOK, get("/admin/config", &doc))
+		db := doc["database"].(map[string]any)
+		assert.Equal(t, "postgres", db["driver"])
+		assert.Equal(t, config.RedactedValue, db["password"])
+	})
+
+	t.Run("ui", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/ui/", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "gRouter admin")
+	})
+}
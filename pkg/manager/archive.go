@@ -0,0 +1,68 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+
+	"grouter/pkg/archive"
+	"grouter/pkg/config"
+	"grouter/pkg/database"
+
+	"go.uber.org/zap"
+)
+
+// initArchive starts archiving the configured subjects to the archive
+// backend
+func (m *ServiceManager) initArchive() error {
+	store, err := m.archiveStore()
+	if err != nil {
+		return fmt.Errorf("failed to create archive store: %w", err)
+	}
+
+	cfg := archive.Config{
+		BatchSize:         m.cfg.Archive.BatchSize,
+		FlushInterval:     m.cfg.Archive.FlushInterval,
+		WriteTimeout:      m.cfg.Archive.WriteTimeout,
+		Retention:         m.cfg.Archive.Retention,
+		RetentionInterval: m.cfg.Archive.RetentionInterval,
+		Registry:          m.metrics,
+	}
+	for _, s := range m.cfg.Archive.Subjects {
+		cfg.Subjects = append(cfg.Subjects, archive.Subject(s))
+	}
+
+	archiver, err := archive.New(m.messenger.Subscriber, store, cfg, m.log)
+	if err != nil {
+		return fmt.Errorf("failed to create archiver: %w", err)
+	}
+	if err := archiver.Start(); err != nil {
+		archiver.Stop()
+		return fmt.Errorf("failed to start archiver: %w", err)
+	}
+	m.archiver = archiver
+
+	m.log.Info("Archive started",
+		zap.String("backend", m.cfg.Archive.Backend),
+		zap.Int("subjects", len(cfg.Subjects)),
+		zap.Duration("retention", cfg.Retention),
+	)
+	return nil
+}
+
+// archiveStore returns the store of the archive backend, the database given
+// with WithDatabase unless s3
+func (m *ServiceManager) archiveStore() (archive.Store, error) {
+	if m.cfg.Archive.Backend == config.ArchiveBackendS3 {
+		return archive.DialS3(context.Background(), archive.S3Config(m.cfg.Archive.S3))
+	}
+	db, ok := m.db.(*database.Database)
+	if !ok {
+		return nil, fmt.Errorf("the database backend requires a *database.Database given with WithDatabase")
+	}
+	return archive.NewDBStore(db.DB)
+}
+
+// Archiver returns the archiver, or nil if the archive is disabled
+func (m *ServiceManager) Archiver() *archive.Archiver {
+	return m.archiver
+}
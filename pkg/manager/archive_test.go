@@ -0,0 +1,46 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"grouter/pkg/archive"
+	"grouter/pkg/config"
+	"grouter/pkg/database"
+	"grouter/pkg/telemetry"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestServiceManager_InitArchive(t *testing.T) {
+	s := runNATSServer(t, false)
+	mgr := newNATSManager(t, s)
+	mgr.metrics = telemetry.NewMetricsRegistry()
+	mgr.cfg.Archive = config.ArchiveConfig{
+		Enabled:       true,
+		Backend:       config.ArchiveBackendDatabase,
+		Subjects:      []config.ArchiveSubject{{Subject: "orders.>"}},
+		FlushInterval: 50 * time.Millisecond,
+	}
+
+	assert.ErrorContains(t, mgr.initArchive(), "requires a *database.Database")
+
+	db, err := database.New(config.DatabaseConfig{Driver: "sqlite", DBName: ":memory:", LogLevel: "silent"}, zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	mgr.db = db
+	require.NoError(t, mgr.initArchive())
+	require.NotNil(t, mgr.Archiver())
+	t.Cleanup(mgr.archiver.Stop)
+
+	require.NoError(t, mgr.messenger.Publisher.Publish(context.Background(), "orders.created", "order", nil, nil))
+	store, err := archive.NewDBStore(db.DB)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		records, err := store.Find(context.Background(), archive.Query{Subject: "orders.created"})
+		return err == nil && len(records) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+}
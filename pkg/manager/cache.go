@@ -0,0 +1,70 @@
+package manager
+
+import (
+	"fmt"
+
+	"grouter/pkg/cache"
+	"grouter/pkg/health"
+
+	"go.uber.org/zap"
+)
+
+// InitCache creates the shared cache from the cache configuration. It does
+// nothing when the cache is disabled.
+func (m *ServiceManager) InitCache() error {
+	if m.cfg == nil || m.log == nil {
+		return fmt.Errorf("init cache: config or logger is nil")
+	}
+	if !m.cfg.Cache.Enabled {
+		return nil
+	}
+
+	opts := []cache.Option{cache.WithName(m.cfg.App.Name)}
+	if m.cfg.Metrics.Enabled {
+		opts = append(opts, cache.WithMetrics(m.metrics))
+	}
+	c, err := cache.NewFromConfig(m.cfg.Cache, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to create cache: %w", err)
+	}
+	m.cache = c
+
+	// Callers fall back to loading the value, so an unreachable cache only
+	// degrades the service
+	if m.health != nil {
+		m.health.AddReadinessCheck("cache", health.WithTimeout(0, c.Ping), health.WithCritical(false))
+	}
+
+	m.log.Info("Cache initialized", zap.String("store", m.cfg.Cache.Store))
+	return nil
+}
+
+// Cache returns the shared cache, or nil if the cache is disabled
+func (m *ServiceManager) Cache() cache.Cache {
+	return m.cache
+}
+
+// cacheInvalidationSubject is the subject of response cache invalidations,
+// "<app>.cache.invalidate" unless configured
+func (m *ServiceManager) cacheInvalidationSubject() string {
+	if s := m.cfg.Web.ResponseCache.InvalidationSubject; s != "" {
+		return s
+	}
+	return m.cfg.App.Name + ".cache.invalidate"
+}
+
+// initResponseCacheInvalidation drops cached responses on the invalidation
+// requests published over NATS
+func (m *ServiceManager) initResponseCacheInvalidation() error {
+	subject := m.cacheInvalidationSubject()
+	if m.messenger == nil {
+		m.log.Warn("Response cache enabled but NATS is not initialized, skipping invalidation subscription",
+			zap.String("subject", subject))
+		return nil
+	}
+	if err := m.messenger.Subscriber.Subscribe(subject, m.webServer.ResponseCache().HandleInvalidation, nil); err != nil {
+		return fmt.Errorf("failed to subscribe response cache to %s: %w", subject, err)
+	}
+	m.log.Info("Response cache invalidation enabled", zap.String("subject", subject))
+	return nil
+}
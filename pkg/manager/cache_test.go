@@ -0,0 +1,113 @@
+package manager
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"grouter/pkg/config"
+	"grouter/pkg/health"
+	"grouter/pkg/telemetry"
+	"grouter/pkg/web"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newCacheManager(cfg config.CacheConfig) *ServiceManager {
+	return &ServiceManager{
+		log:     zap.NewNop(),
+		health:  health.NewHealthService(),
+		metrics: telemetry.NewMetricsRegistry(),
+		cfg: &config.Config{
+			App:     config.AppConfig{Name: "grouter"},
+			Metrics: config.MetricsConfig{Enabled: true},
+			Cache:   cfg,
+		},
+	}
+}
+
+func TestServiceManager_InitCache_Disabled(t *testing.T) {
+	mgr := newCacheManager(config.CacheConfig{})
+	require.NoError(t, mgr.InitCache())
+	assert.Nil(t, mgr.Cache())
+}
+
+func TestServiceManager_InitCache_Redis(t *testing.T) {
+	mr := miniredis.RunT(t)
+	mgr := newCacheManager(config.CacheConfig{
+		Enabled: true,
+		Store:   "redis",
+		Redis:   config.RateLimitRedisConfig{Addr: mr.Addr()},
+	})
+	require.NoError(t, mgr.InitCache())
+	require.NotNil(t, mgr.Cache())
+
+	ctx := context.Background()
+	require.NoError(t, mgr.Cache().Set(ctx, "k", []byte("v"), 0))
+	assert.True(t, mr.Exists("cache:k"))
+
+	checks, err := mgr.health.CheckReadiness()
+	require.NoError(t, err)
+	assert.Equal(t, "OK", checks["cache"])
+
+	// Losing Redis degrades readiness instead of failing it
+	mr.Close()
+	checks, err = mgr.health.CheckReadiness()
+	assert.NoError(t, err)
+	assert.NotEqual(t, "OK", checks["cache"])
+
+	require.NoError(t, mgr.Stop(ctx))
+}
+
+func TestServiceManager_InitCache_InvalidStore(t *testing.T) {
+	mgr := newCacheManager(config.CacheConfig{Enabled: true, Store: "disk"})
+	assert.Error(t, mgr.InitCache())
+}
+
+type countingWebService struct{ calls atomic.Int64 }
+
+func (s *countingWebService) RegisterRoutes(r *gin.RouterGroup) {
+	r.GET("/items", func(c *gin.Context) {
+		s.calls.Add(1)
+		c.String(http.StatusOK, "items")
+	})
+}
+
+func TestServiceManager_ResponseCacheInvalidation(t *testing.T) {
+	mgr := newNATSManager(t, runNATSServer(t, false))
+	mgr.cfg.Cache = config.CacheConfig{Enabled: true}
+	mgr.cfg.Web = config.WebConfig{
+		Enabled:       true,
+		Mode:          "test",
+		ResponseCache: config.ResponseCacheConfig{Enabled: true},
+	}
+	require.NoError(t, mgr.InitCache())
+	require.NoError(t, mgr.InitWebServer())
+	t.Cleanup(func() { _ = mgr.webServer.Stop(context.Background()) })
+	// Invalidations must not reach the service router
+	require.NoError(t, mgr.SubscribeToTopics("grouter.>", ""))
+
+	// The responses are kept in the shared cache
+	assert.Same(t, mgr.Cache(), mgr.webServer.ResponseCache().Cache())
+
+	svc := &countingWebService{}
+	mgr.webServer.RegisterWebService(svc)
+	get := func() string {
+		w := httptest.NewRecorder()
+		mgr.webServer.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items", nil))
+		return w.Header().Get(web.HeaderXCache)
+	}
+	get()
+	require.Equal(t, "HIT", get())
+
+	require.NoError(t, mgr.messenger.Publisher.Publish(context.Background(), "grouter.cache.invalidate",
+		web.ResponseCacheInvalidateType, web.ResponseCacheInvalidation{Paths: []string{"/items"}}, nil))
+	require.Eventually(t, func() bool { return get() == "MISS" }, 2*time.Second, 20*time.Millisecond)
+}
@@ -0,0 +1,72 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+
+	"grouter/pkg/chaos"
+	"grouter/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+// ErrChaosDisabled is returned when chaos experiments are disabled
+var ErrChaosDisabled = errors.New("chaos disabled")
+
+// initChaos creates the fault injector of the web and NATS middlewares when
+// chaos experiments are enabled. Changes of the configured rules replace the
+// ones set through the admin API.
+func (m *ServiceManager) initChaos() error {
+	inj, err := chaos.New(chaosState(m.cfg))
+	if err != nil {
+		return fmt.Errorf("failed to initialize chaos: %w", err)
+	}
+	m.chaos = inj
+	m.AddReloadable("chaos", ReloadableFunc(func(cfg *config.Config) error {
+		return m.SetChaosState(chaosState(cfg))
+	}), func(cfg *config.Config) any { return cfg.Chaos })
+	m.log.Warn("Chaos fault injection enabled",
+		zap.Bool("active", m.cfg.Chaos.Active),
+		zap.Int("rules", len(m.cfg.Chaos.Rules)),
+	)
+	return nil
+}
+
+// chaosState converts the configured chaos rules
+func chaosState(cfg *config.Config) chaos.State {
+	state := chaos.State{Active: cfg.Chaos.Active, Rules: make([]chaos.Rule, 0, len(cfg.Chaos.Rules))}
+	for _, r := range cfg.Chaos.Rules {
+		state.Rules = append(state.Rules, chaos.Rule{
+			Target:  chaos.Target(r.Target),
+			Match:   r.Match,
+			Percent: r.Percent,
+			Fault:   chaos.Fault(r.Fault),
+			Latency: r.Latency,
+			Status:  r.Status,
+		})
+	}
+	return state
+}
+
+// ChaosState returns whether faults are injected, and their rules
+func (m *ServiceManager) ChaosState() (chaos.State, error) {
+	if m.chaos == nil {
+		return chaos.State{}, ErrChaosDisabled
+	}
+	return m.chaos.State(), nil
+}
+
+// SetChaosState starts, stops or changes the injection of faults
+func (m *ServiceManager) SetChaosState(state chaos.State) error {
+	if m.chaos == nil {
+		return ErrChaosDisabled
+	}
+	if err := m.chaos.SetState(state); err != nil {
+		return err
+	}
+	m.log.Warn("Chaos state changed",
+		zap.Bool("active", state.Active),
+		zap.Int("rules", len(state.Rules)),
+	)
+	return nil
+}
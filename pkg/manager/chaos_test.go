@@ -0,0 +1,80 @@
+package manager
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"grouter/pkg/chaos"
+	"grouter/pkg/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newChaosManager(t *testing.T, cfg config.ChaosConfig) *ServiceManager {
+	t.Helper()
+	mgr := NewServiceManager()
+	mgr.log = zap.NewNop()
+	mgr.cfg = &config.Config{Chaos: cfg}
+	require.NoError(t, mgr.initChaos())
+	return mgr
+}
+
+func TestAdminService_Chaos(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mgr := newChaosManager(t, config.ChaosConfig{Enabled: true, Rules: []config.ChaosRule{
+		{Target: "nats", Match: "orders.>", Percent: 10, Fault: "latency", Latency: time.Second},
+	}})
+	engine := gin.New()
+	NewAdminService(mgr).RegisterRoutes(&engine.RouterGroup)
+
+	serve := func(method, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(method, "/admin/chaos", strings.NewReader(body)))
+		return w
+	}
+	state := func(w *httptest.ResponseRecorder) chaos.State {
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var s chaos.State
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &s))
+		return s
+	}
+
+	s := state(serve(http.MethodGet, ""))
+	assert.False(t, s.Active)
+	require.Len(t, s.Rules, 1)
+	assert.Equal(t, time.Second, s.Rules[0].Latency)
+
+	s = state(serve(http.MethodPut, `{"active":true,"rules":[{"target":"http","match":"/api","percent":50,"fault":"error","status":503}]}`))
+	assert.True(t, s.Active)
+	_, ok := mgr.chaos.Pick(chaos.TargetNATS, func(string) bool { return true })
+	assert.False(t, ok, "the rules are replaced")
+
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPut, `{"active":true,"rules":[{"target":"http","percent":50,"fault":"crash"}]}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPut, `{"rules":[{"latency":"soon"}]}`).Code)
+	assert.True(t, state(serve(http.MethodGet, "")).Active, "invalid states are not applied")
+
+	// Configuration changes replace the state again
+	require.NoError(t, mgr.ApplyConfig(&config.Config{Chaos: config.ChaosConfig{Enabled: true, Active: true}}))
+	s = state(serve(http.MethodGet, ""))
+	assert.True(t, s.Active)
+	assert.Empty(t, s.Rules)
+}
+
+func TestAdminService_ChaosDisabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	NewAdminService(NewServiceManager()).RegisterRoutes(&engine.RouterGroup)
+
+	for _, method := range []string{http.MethodGet, http.MethodPut} {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(method, "/admin/chaos", strings.NewReader(`{"active":true}`)))
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code, method)
+	}
+}
@@ -0,0 +1,161 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// PipelineDashboardUID is the uid of the Grafana dashboard of PipelineDashboard
+const PipelineDashboardUID = "grouter-pipeline"
+
+// grafanaPanel is the subset of a Grafana time series panel the pipeline
+// dashboard uses
+type grafanaPanel struct {
+	ID          int             `json:"id"`
+	Type        string          `json:"type"`
+	Title       string          `json:"title"`
+	Description string          `json:"description,omitempty"`
+	Datasource  string          `json:"datasource"`
+	GridPos     grafanaGridPos  `json:"gridPos"`
+	FieldConfig json.RawMessage `json:"fieldConfig"`
+	Targets     []grafanaTarget `json:"targets"`
+}
+
+type grafanaGridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type grafanaTarget struct {
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat"`
+	RefID        string `json:"refId"`
+}
+
+// pipelinePanel is a panel of the pipeline dashboard before layout
+type pipelinePanel struct {
+	title, description, unit string
+	queries                  []grafanaTarget
+}
+
+// pipelinePanels are the panels of the pipeline dashboard, built from the
+// metric names so that they follow renames
+func pipelinePanels() []pipelinePanel {
+	const service = `service=~"$service"`
+	rate := func(metric, by string) string {
+		return fmt.Sprintf("sum by (%s) (rate(%s{%s}[$__rate_interval]))", by, metric, service)
+	}
+	quantile := func(q string) string {
+		return fmt.Sprintf("histogram_quantile(%s, sum by (service, le) (rate(%s_bucket{%s}[$__rate_interval])))",
+			q, metricHandlerDuration, service)
+	}
+	return []pipelinePanel{
+		{
+			title:       "Messages routed by service",
+			description: "Messages delivered to the services per second",
+			unit:        "reqps",
+			queries:     []grafanaTarget{{Expr: rate(metricMessagesRouted, "service"), LegendFormat: "{{service}}"}},
+		},
+		{
+			title:       "Messages routed by type",
+			description: "Messages delivered to the services per second, by message type",
+			unit:        "reqps",
+			queries:     []grafanaTarget{{Expr: rate(metricMessagesRouted, "service, type"), LegendFormat: "{{service}} {{type}}"}},
+		},
+		{
+			title:       "Handler duration",
+			description: "Median and 95th percentile duration of the message handlers",
+			unit:        "s",
+			queries: []grafanaTarget{
+				{Expr: quantile("0.5"), LegendFormat: "p50 {{service}}"},
+				{Expr: quantile("0.95"), LegendFormat: "p95 {{service}}"},
+			},
+		},
+		{
+			title:       "Handler error ratio",
+			description: "Share of the messages the handlers failed",
+			unit:        "percentunit",
+			queries: []grafanaTarget{{
+				Expr:         rate(metricHandlerErrors, "service") + " / " + rate(metricMessagesRouted, "service"),
+				LegendFormat: "{{service}}",
+			}},
+		},
+		{
+			title:       "Error replies",
+			description: "Error replies sent for failed requests per second",
+			unit:        "reqps",
+			queries:     []grafanaTarget{{Expr: rate(metricErrorReplies, "service, type"), LegendFormat: "{{service}} {{type}}"}},
+		},
+		{
+			title:       "Routing failures",
+			description: "Messages no service could be found for per second, by reason",
+			unit:        "reqps",
+			queries: []grafanaTarget{{
+				Expr:         fmt.Sprintf("sum by (reason) (rate(%s[$__rate_interval]))", metricRoutingFailures),
+				LegendFormat: "{{reason}}",
+			}},
+		},
+	}
+}
+
+// PipelineDashboard returns the Grafana dashboard of the message pipeline
+// metrics, per service, as JSON for provisioning
+func PipelineDashboard() ([]byte, error) {
+	var panels []grafanaPanel
+	for i, p := range pipelinePanels() {
+		fieldConfig, err := json.Marshal(map[string]any{
+			"defaults":  map[string]any{"unit": p.unit},
+			"overrides": []any{},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode panel %q: %w", p.title, err)
+		}
+		targets := make([]grafanaTarget, len(p.queries))
+		for j, q := range p.queries {
+			q.RefID = string(rune('A' + j))
+			targets[j] = q
+		}
+		panels = append(panels, grafanaPanel{
+			ID:          i + 1,
+			Type:        "timeseries",
+			Title:       p.title,
+			Description: p.description,
+			Datasource:  "Prometheus",
+			GridPos:     grafanaGridPos{H: 8, W: 12, X: 12 * (i % 2), Y: 8 * (i / 2)},
+			FieldConfig: fieldConfig,
+			Targets:     targets,
+		})
+	}
+
+	dashboard := map[string]any{
+		"uid":           PipelineDashboardUID,
+		"title":         "gRouter Message Pipeline",
+		"tags":          []string{"grouter", "nats"},
+		"editable":      true,
+		"schemaVersion": 36,
+		"refresh":       "30s",
+		"time":          map[string]string{"from": "now-1h", "to": "now"},
+		"templating": map[string]any{
+			"list": []any{map[string]any{
+				"name":       "service",
+				"label":      "Service",
+				"type":       "query",
+				"datasource": "Prometheus",
+				"query":      fmt.Sprintf("label_values(%s, service)", metricMessagesRouted),
+				"refresh":    2,
+				"multi":      true,
+				"includeAll": true,
+				"allValue":   ".*",
+				"current":    map[string]any{"text": "All", "value": "$__all"},
+			}},
+		},
+		"panels": panels,
+	}
+	data, err := json.MarshalIndent(dashboard, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode dashboard: %w", err)
+	}
+	return append(data, '\n'), nil
+}
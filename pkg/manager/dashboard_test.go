@@ -0,0 +1,52 @@
+package manager
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateDashboard = flag.Bool("update-dashboard", false, "rewrite the provisioned pipeline dashboard")
+
+const pipelineDashboardFile = "../../deployments/docker-compose/config/grafana/provisioning/dashboards/json/grouter-pipeline.json"
+
+func TestPipelineDashboard(t *testing.T) {
+	data, err := PipelineDashboard()
+	require.NoError(t, err)
+
+	var dashboard struct {
+		UID    string `json:"uid"`
+		Panels []struct {
+			Targets []struct {
+				Expr string `json:"expr"`
+			} `json:"targets"`
+		} `json:"panels"`
+	}
+	require.NoError(t, json.Unmarshal(data, &dashboard))
+	assert.Equal(t, PipelineDashboardUID, dashboard.UID)
+
+	var exprs []string
+	for _, p := range dashboard.Panels {
+		for _, target := range p.Targets {
+			exprs = append(exprs, target.Expr)
+		}
+	}
+	all := strings.Join(exprs, "\n")
+	for _, metric := range []string{metricMessagesRouted, metricRoutingFailures, metricHandlerDuration, metricHandlerErrors, metricErrorReplies} {
+		assert.Contains(t, all, metric)
+	}
+
+	// The provisioned dashboard is the generated one; go test ./pkg/manager
+	// -run TestPipelineDashboard -update-dashboard rewrites it
+	if *updateDashboard {
+		require.NoError(t, os.WriteFile(pipelineDashboardFile, data, 0o644))
+	}
+	provisioned, err := os.ReadFile(pipelineDashboardFile)
+	require.NoError(t, err)
+	assert.Equal(t, string(data), string(provisioned), "run with -update-dashboard")
+}
@@ -0,0 +1,58 @@
+package manager
+
+import (
+	"sync"
+	"time"
+)
+
+// errorLogSize is the number of handler errors kept by the manager
+const errorLogSize = 100
+
+// ErrorInfo describes an error returned by a handler for a message
+type ErrorInfo struct {
+	Time    time.Time `json:"time"`
+	Service string    `json:"service,omitempty"`
+	Topic   string    `json:"topic"`
+	ID      string    `json:"id,omitempty"`
+	Type    string    `json:"type,omitempty"`
+	Error   string    `json:"error"`
+}
+
+// errorLog keeps the last errorLogSize handler errors; its zero value is
+// ready to use
+type errorLog struct {
+	mu     sync.Mutex
+	errors []ErrorInfo
+	next   int
+}
+
+// add records info, dropping the oldest error when the log is full
+func (l *errorLog) add(info ErrorInfo) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.errors) < errorLogSize {
+		l.errors = append(l.errors, info)
+		return
+	}
+	l.errors[l.next] = info
+	l.next = (l.next + 1) % errorLogSize
+}
+
+// list returns the recorded errors, newest first
+func (l *errorLog) list() []ErrorInfo {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	infos := make([]ErrorInfo, 0, len(l.errors))
+	for i := range l.errors {
+		// the newest error is just before next once the log wrapped
+		idx := (l.next - 1 - i + 2*len(l.errors)) % len(l.errors)
+		infos = append(infos, l.errors[idx])
+	}
+	return infos
+}
+
+// RecentErrors returns the last errors returned by the handlers of the
+// manager, newest first
+func (m *ServiceManager) RecentErrors() []ErrorInfo {
+	return m.recentErrors.list()
+}
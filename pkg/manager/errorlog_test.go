@@ -0,0 +1,28 @@
+package manager
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorLog(t *testing.T) {
+	var log errorLog
+	assert.Empty(t, log.list())
+
+	log.add(ErrorInfo{ID: "0"})
+	log.add(ErrorInfo{ID: "1"})
+	infos := log.list()
+	require.Len(t, infos, 2)
+	assert.Equal(t, "1", infos[0].ID, "the newest error comes first")
+
+	for i := 2; i < errorLogSize+5; i++ {
+		log.add(ErrorInfo{ID: strconv.Itoa(i)})
+	}
+	infos = log.list()
+	require.Len(t, infos, errorLogSize, "the oldest errors are dropped")
+	assert.Equal(t, strconv.Itoa(errorLogSize+4), infos[0].ID)
+	assert.Equal(t, "5", infos[errorLogSize-1].ID)
+}
@@ -0,0 +1,80 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"grouter/pkg/health"
+	messaging "grouter/pkg/messaging/nats"
+
+	"go.uber.org/zap"
+)
+
+// HealthReportType is the message type of health replies
+const HealthReportType = "health.report"
+
+// healthPrefix returns the subject prefix of the health responder, e.g.
+// "grouter.health." answering "grouter.health.live"
+func (m *ServiceManager) healthPrefix() string {
+	return m.cfg.App.Name + ".health."
+}
+
+// initHealthResponder answers <app>.health.{live,ready,detail} requests with
+// a health.Report built from the same HealthService as the HTTP probes
+func (m *ServiceManager) initHealthResponder() error {
+	if m.health == nil {
+		return nil
+	}
+	subject := m.healthPrefix() + "*"
+	if err := m.messenger.Subscriber.Subscribe(subject, m.onHealthRequest, nil); err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", subject, err)
+	}
+	m.log.Info("Health responder enabled", zap.String("subject", subject))
+	return nil
+}
+
+func (m *ServiceManager) onHealthRequest(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+	if env.Reply == "" {
+		return nil
+	}
+
+	probe := strings.TrimPrefix(subject, m.healthPrefix())
+	report, err := m.HealthReport(probe)
+	if err != nil {
+		return m.messenger.Publisher.PublishError(ctx, env.Reply, err.Error())
+	}
+
+	return m.messenger.Publisher.Publish(ctx, env.Reply, HealthReportType, report, nil)
+}
+
+// HealthReport runs the checks of probe and returns the report, stamped with
+// the application and this instance
+func (m *ServiceManager) HealthReport(probe string) (*health.Report, error) {
+	if m.health == nil {
+		return nil, errors.New("health service not initialized")
+	}
+	report, err := m.health.Report(probe)
+	if err != nil {
+		return nil, err
+	}
+	report.App = m.cfg.App.Name
+	report.Version = m.cfg.App.Version
+	report.Instance = m.instanceID()
+	return report, nil
+}
+
+// instanceID identifies this process in health reports, preferring the
+// configured trace resource instance and falling back to the hostname
+func (m *ServiceManager) instanceID() string {
+	if id := m.cfg.Tracing.Resource.InstanceID; id != "" {
+		return id
+	}
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return "unknown"
+	}
+	return host
+}
@@ -0,0 +1,240 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"grouter/pkg/config"
+	"grouter/pkg/health"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// runNATSServer starts an embedded NATS server for the test
+func runNATSServer(t *testing.T, jetStream bool) *server.Server {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	opts := &server.Options{Port: -1, JetStream: jetStream}
+	if jetStream {
+		opts.StoreDir = t.TempDir()
+	}
+	s, err := server.NewServer(opts)
+	require.NoError(t, err)
+	go s.Start()
+	t.Cleanup(s.Shutdown)
+	require.True(t, s.ReadyForConnections(5*time.Second))
+	return s
+}
+
+// newNATSManager returns a manager connected to s, its config changed by
+// configure before the messenger starts
+func newNATSManager(t *testing.T, s *server.Server, configure ...func(*config.Config)) *ServiceManager {
+	t.Helper()
+	mgr := &ServiceManager{
+		log:    zap.NewNop(),
+		router: NewServiceRouter(),
+		health: health.NewHealthService(),
+		cfg: &config.Config{
+			App: config.AppConfig{Name: "grouter", Version: "1.2.3"},
+			NATS: config.NATSConfig{
+				Enabled:           true,
+				URL:               s.ClientURL(),
+				ConnectionTimeout: 2 * time.Second,
+			},
+			Tracing: config.TracingConfig{
+				Resource: config.TracingResourceConfig{InstanceID: "instance-1"},
+			},
+		},
+	}
+	for _, fn := range configure {
+		fn(mgr.cfg)
+	}
+	require.NoError(t, mgr.InitNATS())
+	t.Cleanup(func() { _ = mgr.messenger.Close() })
+	return mgr
+}
+
+func requestHealth(t *testing.T, mgr *ServiceManager, probe string) *health.Report {
+	t.Helper()
+	reply, err := mgr.messenger.Publisher.Request(context.Background(), "grouter.health."+probe, "health."+probe, nil, 2*time.Second)
+	require.NoError(t, err)
+	require.Equal(t, HealthReportType, reply.Type)
+
+	var report health.Report
+	require.NoError(t, json.Unmarshal(reply.Data, &report))
+	return &report
+}
+
+func TestServiceManager_HealthResponder(t *testing.T) {
+	mgr := newNATSManager(t, runNATSServer(t, true))
+	mgr.health.AddLivenessCheck("app", func() error { return nil })
+
+	live := requestHealth(t, mgr, health.ProbeLive)
+	assert.Equal(t, health.StatusUp, live.Status)
+	assert.Equal(t, health.ProbeLive, live.Probe)
+	assert.Equal(t, "grouter", live.App)
+	assert.Equal(t, "1.2.3", live.Version)
+	assert.Equal(t, "instance-1", live.Instance)
+	assert.Contains(t, live.Liveness, "app")
+	assert.Nil(t, live.Readiness)
+
+	ready := requestHealth(t, mgr, health.ProbeReady)
+	assert.Equal(t, health.StatusUp, ready.Status)
+	assert.Equal(t, health.StatusUp, ready.Readiness["nats"].Status)
+	assert.Equal(t, health.StatusUp, ready.Readiness["jetstream"].Status)
+
+	mgr.health.AddReadinessCheck("cache", func() error { return errors.New("unreachable") }, health.WithCritical(false))
+	mgr.health.AddStartupCheck("init", func() error { return nil })
+	detail := requestHealth(t, mgr, health.ProbeDetail)
+	assert.Equal(t, health.StatusDegraded, detail.Status)
+	assert.Contains(t, detail.Liveness, "app")
+	assert.Contains(t, detail.Startup, "init")
+	assert.Equal(t, "unreachable", detail.Readiness["cache"].Error)
+	assert.False(t, detail.Readiness["cache"].Critical)
+
+	startup := requestHealth(t, mgr, health.ProbeStartup)
+	assert.Equal(t, health.StatusUp, startup.Status)
+
+	mgr.health.AddReadinessCheck("db", func() error { return errors.New("refused") })
+	assert.Equal(t, health.StatusDown, requestHealth(t, mgr, health.ProbeReady).Status)
+}
+
+func TestServiceManager_HealthResponderUnknownProbe(t *testing.T) {
+	mgr := newNATSManager(t, runNATSServer(t, false))
+
+	reply, err := mgr.messenger.Publisher.Request(context.Background(), "grouter.health.nope", "health.nope", nil, 2*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "error", reply.Type)
+	assert.Contains(t, string(reply.Data), "unknown health probe")
+}
+
+func TestServiceManager_HealthSubjectsNotRouted(t *testing.T) {
+	mgr := newNATSManager(t, runNATSServer(t, false))
+	// An application-wide subscription must not answer health requests with a
+	// routing error
+	require.NoError(t, mgr.SubscribeToTopics("grouter.>", ""))
+
+	report := requestHealth(t, mgr, health.ProbeReady)
+	assert.Equal(t, health.StatusUp, report.Status)
+}
+
+func TestServiceManager_DefaultHealthChecks(t *testing.T) {
+	s := runNATSServer(t, true)
+	mgr := newNATSManager(t, s)
+	mgr.cfg.Web = config.WebConfig{Enabled: true, Mode: "test"}
+	require.NoError(t, mgr.InitWebServer())
+	t.Cleanup(func() { _ = mgr.webServer.Stop(context.Background()) })
+
+	ready, err := mgr.health.CheckReadiness()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"nats": "OK", "nats_slow_consumers": "OK", "jetstream": "OK"}, ready)
+
+	live, err := mgr.health.CheckLiveness()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"web": "OK"}, live)
+
+	// Losing the server makes the instance unready
+	s.Shutdown()
+	require.Eventually(t, func() bool {
+		_, err := mgr.health.CheckReadiness()
+		return err != nil
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestServiceManager_HealthChecksWithoutJetStream(t *testing.T) {
+	mgr := newNATSManager(t, runNATSServer(t, false))
+
+	ready, err := mgr.health.CheckReadiness()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"nats": "OK", "nats_slow_consumers": "OK"}, ready)
+}
+
+func TestServiceManager_JetStreamLagCheck(t *testing.T) {
+	s := runNATSServer(t, true)
+	mgr := &ServiceManager{
+		log:    zap.NewNop(),
+		router: NewServiceRouter(),
+		health: health.NewHealthService(),
+		cfg: &config.Config{
+			App: config.AppConfig{Name: "grouter"},
+			NATS: config.NATSConfig{
+				Enabled:           true,
+				URL:               s.ClientURL(),
+				ConnectionTimeout: 2 * time.Second,
+				Monitoring:        config.NATSMonitoring{Enabled: true, Interval: 50 * time.Millisecond, MaxPending: 1},
+			},
+		},
+	}
+	require.NoError(t, mgr.InitNATS())
+	t.Cleanup(func() { _ = mgr.messenger.Close() })
+
+	js, err := mgr.messenger.Client.JetStream()
+	require.NoError(t, err)
+	_, err = js.AddStream(&nats.StreamConfig{Name: "ORDERS", Subjects: []string{"orders.>"}, Storage: nats.MemoryStorage})
+	require.NoError(t, err)
+	_, err = js.AddConsumer("ORDERS", &nats.ConsumerConfig{Durable: "billing", AckPolicy: nats.AckExplicitPolicy})
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err := mgr.messenger.Publisher.PublishJS(context.Background(), "orders.created", "orders.created", i)
+		require.NoError(t, err)
+	}
+
+	var report *health.Report
+	require.Eventually(t, func() bool {
+		report, err = mgr.health.Report(health.ProbeReady)
+		require.NoError(t, err)
+		return report.Readiness["jetstream_lag"].Status == health.StatusDown
+	}, 5*time.Second, 50*time.Millisecond)
+	assert.Equal(t, health.StatusDegraded, report.Status, "lag does not take readiness down")
+	assert.Contains(t, report.Readiness["jetstream_lag"].Error, "ORDERS/billing (2 pending)")
+}
+
+func TestServiceManager_InitHealth(t *testing.T) {
+	mgr := NewServiceManager()
+	mgr.log = zap.NewNop()
+	mgr.cfg = &config.Config{
+		Metrics: config.MetricsConfig{Enabled: true},
+		Health: config.HealthConfig{
+			Timeout:         time.Second,
+			RefreshInterval: 10 * time.Millisecond,
+		},
+	}
+	mgr.initHealth()
+	defer mgr.health.Stop()
+
+	mgr.health.AddReadinessCheck("db", func() error { return nil })
+	require.Eventually(t, func() bool {
+		families, err := mgr.metrics.Gatherer().Gather()
+		require.NoError(t, err)
+		for _, f := range families {
+			if f.GetName() == "health_check_status" {
+				return true
+			}
+		}
+		return false
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestServiceManager_StartupProbe(t *testing.T) {
+	mgr := NewServiceManager()
+	mgr.log = zap.NewNop()
+	mgr.cfg = &config.Config{}
+	mgr.initHealth()
+
+	_, err := mgr.health.CheckStartup()
+	assert.Error(t, err)
+
+	require.NoError(t, mgr.Start(context.Background()))
+	_, err = mgr.health.CheckStartup()
+	assert.NoError(t, err)
+}
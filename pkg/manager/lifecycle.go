@@ -0,0 +1,252 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync/atomic"
+
+	"grouter/pkg/health"
+
+	"go.uber.org/zap"
+)
+
+// ErrServiceNotFound is returned for services that are not registered
+var ErrServiceNotFound = errors.New("service not found")
+
+// ErrNoLifecycle is returned when starting or stopping a service that is not
+// a ServiceV2
+var ErrNoLifecycle = errors.New("service has no lifecycle")
+
+// Service states of ServiceInfo
+const (
+	ServiceRunning = "running"
+	ServiceStopped = "stopped"
+	// ServiceRegistered services are not ServiceV2, they have no lifecycle
+	ServiceRegistered = "registered"
+)
+
+// ServiceInfo is the state of a registered service
+type ServiceInfo struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+	// Error is the failed health check of a running service
+	Error string `json:"error,omitempty"`
+}
+
+// managedService is a ServiceV2 registered with the manager
+type managedService struct {
+	svc     ServiceV2
+	started atomic.Bool
+}
+
+// deps returns the components handed to services
+func (m *ServiceManager) deps() Deps {
+	return Deps{
+		Config:    m.cfg,
+		Logger:    m.log,
+		Messenger: m.messenger,
+		Events:    m.events,
+		Cache:     m.cache,
+		Health:    m.health,
+		Metrics:   m.metrics,
+		Database:  m.db,
+	}
+}
+
+// healthCheckName is the readiness check of the service name
+func healthCheckName(name string) string {
+	return "service." + normalizeService(name)
+}
+
+// managed returns the registered entry of the service name, or nil
+func (m *ServiceManager) managed(name string) *managedService {
+	m.lifecycleMu.Lock()
+	defer m.lifecycleMu.Unlock()
+	key := normalizeService(name)
+	for _, ms := range m.lifecycle {
+		if normalizeService(ms.svc.Name()) == key {
+			return ms
+		}
+	}
+	return nil
+}
+
+// initService initializes svc and starts it when the manager is running.
+// Registering the same instance again does nothing; another service of the
+// same name replaces it.
+func (m *ServiceManager) initService(svc ServiceV2) error {
+	if prev := m.managed(svc.Name()); prev != nil {
+		if prev.svc == svc {
+			return nil
+		}
+		m.stopService(prev)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+	if err := svc.Init(ctx, m.deps()); err != nil {
+		return fmt.Errorf("failed to initialize service %q: %w", svc.Name(), err)
+	}
+	ms := &managedService{svc: svc}
+	if m.started.Load() {
+		if err := svc.Start(ctx); err != nil {
+			return fmt.Errorf("failed to start service %q: %w", svc.Name(), err)
+		}
+		ms.started.Store(true)
+	}
+
+	m.lifecycleMu.Lock()
+	m.lifecycle = append(m.lifecycle, ms)
+	m.lifecycleMu.Unlock()
+	if m.health != nil {
+		m.health.AddReadinessCheck(healthCheckName(svc.Name()), health.WithTimeout(0, svc.Health))
+	}
+	return nil
+}
+
+// startServices starts the registered services in registration order. If one
+// fails, those started by this call are stopped again.
+func (m *ServiceManager) startServices(ctx context.Context) error {
+	m.lifecycleMu.Lock()
+	services := append([]*managedService(nil), m.lifecycle...)
+	m.lifecycleMu.Unlock()
+
+	var started []*managedService
+	for _, ms := range services {
+		if ms.started.Load() {
+			continue
+		}
+		if err := ms.svc.Start(ctx); err != nil {
+			for i := len(started) - 1; i >= 0; i-- {
+				m.stopService(started[i])
+			}
+			return fmt.Errorf("failed to start service %q: %w", ms.svc.Name(), err)
+		}
+		ms.started.Store(true)
+		started = append(started, ms)
+	}
+	return nil
+}
+
+// stopServices stops the registered services in reverse registration order
+func (m *ServiceManager) stopServices(ctx context.Context) {
+	m.lifecycleMu.Lock()
+	services := append([]*managedService(nil), m.lifecycle...)
+	m.lifecycleMu.Unlock()
+
+	for i := len(services) - 1; i >= 0; i-- {
+		ms := services[i]
+		if !ms.started.CompareAndSwap(true, false) {
+			continue
+		}
+		if err := ms.svc.Stop(ctx); err != nil {
+			m.log.Error("Failed to stop service", zap.String("service", ms.svc.Name()), zap.Error(err))
+		}
+	}
+}
+
+// stopService stops ms and forgets it
+func (m *ServiceManager) stopService(ms *managedService) {
+	if ms.started.CompareAndSwap(true, false) {
+		ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+		defer cancel()
+		if err := ms.svc.Stop(ctx); err != nil {
+			m.log.Error("Failed to stop service", zap.String("service", ms.svc.Name()), zap.Error(err))
+		}
+	}
+	if m.health != nil {
+		m.health.RemoveReadinessCheck(healthCheckName(ms.svc.Name()))
+	}
+
+	m.lifecycleMu.Lock()
+	defer m.lifecycleMu.Unlock()
+	for i, other := range m.lifecycle {
+		if other == ms {
+			m.lifecycle = append(m.lifecycle[:i], m.lifecycle[i+1:]...)
+			break
+		}
+	}
+}
+
+// ServiceStates returns the state of the registered services by name,
+// checking the health of the running ones
+func (m *ServiceManager) ServiceStates(ctx context.Context) []ServiceInfo {
+	names := m.ListServices()
+	sort.Strings(names)
+	infos := make([]ServiceInfo, 0, len(names))
+	for _, name := range names {
+		info, err := m.ServiceState(ctx, name)
+		if err == nil {
+			infos = append(infos, info)
+		}
+	}
+	return infos
+}
+
+// ServiceState returns the state of the service name
+func (m *ServiceManager) ServiceState(ctx context.Context, name string) (ServiceInfo, error) {
+	svc, ok := m.GetService(name)
+	if !ok {
+		return ServiceInfo{}, fmt.Errorf("%w: %s", ErrServiceNotFound, name)
+	}
+	info := ServiceInfo{Name: svc.Name(), State: ServiceRegistered}
+	ms := m.managed(name)
+	if ms == nil {
+		return info, nil
+	}
+	info.State = ServiceStopped
+	if ms.started.Load() {
+		info.State = ServiceRunning
+		if err := ms.svc.Health(ctx); err != nil {
+			info.Error = err.Error()
+		}
+	}
+	return info, nil
+}
+
+// lifecycleService returns the ServiceV2 entry of name
+func (m *ServiceManager) lifecycleService(name string) (*managedService, error) {
+	if ms := m.managed(name); ms != nil {
+		return ms, nil
+	}
+	if _, ok := m.GetService(name); ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoLifecycle, name)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrServiceNotFound, name)
+}
+
+// StartService starts the ServiceV2 name, if it is stopped. It keeps
+// receiving its messages and requests while stopped.
+func (m *ServiceManager) StartService(ctx context.Context, name string) error {
+	ms, err := m.lifecycleService(name)
+	if err != nil {
+		return err
+	}
+	if ms.started.Load() {
+		return nil
+	}
+	if err := ms.svc.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start service %q: %w", ms.svc.Name(), err)
+	}
+	ms.started.Store(true)
+	m.log.Info("Service started", zap.String("service", ms.svc.Name()))
+	return nil
+}
+
+// StopService stops the ServiceV2 name, if it runs, keeping it registered
+func (m *ServiceManager) StopService(ctx context.Context, name string) error {
+	ms, err := m.lifecycleService(name)
+	if err != nil {
+		return err
+	}
+	if !ms.started.CompareAndSwap(true, false) {
+		return nil
+	}
+	if err := ms.svc.Stop(ctx); err != nil {
+		return fmt.Errorf("failed to stop service %q: %w", ms.svc.Name(), err)
+	}
+	m.log.Info("Service stopped", zap.String("service", ms.svc.Name()))
+	return nil
+}
@@ -0,0 +1,174 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"grouter/pkg/config"
+	"grouter/pkg/health"
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// lifecycleService records the lifecycle calls of the manager in events
+type lifecycleService struct {
+	name     string
+	events   *[]string
+	mu       *sync.Mutex
+	deps     Deps
+	startErr error
+	health   error
+}
+
+func newLifecycleService(name string, events *[]string, mu *sync.Mutex) *lifecycleService {
+	return &lifecycleService{name: name, events: events, mu: mu}
+}
+
+func (s *lifecycleService) record(event string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	*s.events = append(*s.events, s.name+":"+event)
+}
+
+func (s *lifecycleService) Name() string { return s.name }
+
+func (s *lifecycleService) Init(ctx context.Context, deps Deps) error {
+	s.deps = deps
+	s.record("init")
+	return nil
+}
+
+func (s *lifecycleService) Start(ctx context.Context) error {
+	s.record("start")
+	return s.startErr
+}
+
+func (s *lifecycleService) Stop(ctx context.Context) error {
+	s.record("stop")
+	return nil
+}
+
+func (s *lifecycleService) Health(ctx context.Context) error { return s.health }
+
+func (s *lifecycleService) Handle(ctx context.Context, topic string, msg *messaging.MessageEnvelope) error {
+	return nil
+}
+
+func newLifecycleManager() *ServiceManager {
+	return &ServiceManager{
+		log:     zap.NewNop(),
+		router:  NewServiceRouter(),
+		health:  health.NewHealthService(),
+		timeout: time.Second,
+		cfg:     &config.Config{App: config.AppConfig{Name: "grouter"}},
+	}
+}
+
+func TestServiceManager_ServiceV2_Lifecycle(t *testing.T) {
+	mgr := newLifecycleManager()
+	var events []string
+	var mu sync.Mutex
+
+	orders := newLifecycleService("orders", &events, &mu)
+	payments := newLifecycleService("payments", &events, &mu)
+	require.NoError(t, mgr.RegisterService(orders))
+	require.NoError(t, mgr.RegisterService(payments))
+	assert.Same(t, mgr.health, orders.deps.Health)
+	assert.Same(t, mgr.cfg, orders.deps.Config)
+
+	require.NoError(t, mgr.Start(context.Background()))
+
+	// Registered while running: started right away
+	shipping := newLifecycleService("shipping", &events, &mu)
+	require.NoError(t, mgr.RegisterService(shipping))
+	// Registering the same instance again is a no-op
+	require.NoError(t, mgr.RegisterService(shipping))
+
+	mgr.UnregisterService("payments")
+	require.NoError(t, mgr.Stop(context.Background()))
+
+	assert.Equal(t, []string{
+		"orders:init", "payments:init",
+		"orders:start", "payments:start",
+		"shipping:init", "shipping:start",
+		"payments:stop",
+		"shipping:stop", "orders:stop",
+	}, events)
+}
+
+func TestServiceManager_ServiceV2_StartFailure(t *testing.T) {
+	mgr := newLifecycleManager()
+	var events []string
+	var mu sync.Mutex
+
+	orders := newLifecycleService("orders", &events, &mu)
+	broken := newLifecycleService("broken", &events, &mu)
+	broken.startErr = errors.New("port in use")
+	require.NoError(t, mgr.RegisterService(orders))
+	require.NoError(t, mgr.RegisterService(broken))
+
+	err := mgr.Start(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `"broken"`)
+	assert.Equal(t, []string{"orders:init", "broken:init", "orders:start", "broken:start", "orders:stop"}, events,
+		"the services already started are stopped")
+}
+
+func TestServiceManager_ServiceV2_Health(t *testing.T) {
+	mgr := newLifecycleManager()
+	var events []string
+	var mu sync.Mutex
+
+	orders := newLifecycleService("orders", &events, &mu)
+	require.NoError(t, mgr.RegisterService(orders))
+
+	status, err := mgr.health.CheckReadiness()
+	require.NoError(t, err)
+	assert.Equal(t, "OK", status["service.orders"])
+
+	orders.health = errors.New("queue full")
+	_, err = mgr.health.CheckReadiness()
+	assert.Error(t, err)
+
+	mgr.UnregisterService("orders")
+	status, err = mgr.health.CheckReadiness()
+	require.NoError(t, err)
+	assert.NotContains(t, status, "service.orders")
+}
+
+func TestServiceManager_StartStopService(t *testing.T) {
+	var events []string
+	var mu sync.Mutex
+	mgr := newLifecycleManager()
+	svc := newLifecycleService("orders", &events, &mu)
+	require.NoError(t, mgr.RegisterService(svc))
+	require.NoError(t, mgr.RegisterService(&mockService{name: "legacy"}))
+	ctx := context.Background()
+	require.NoError(t, mgr.Start(ctx))
+
+	assert.Equal(t, []ServiceInfo{
+		{Name: "legacy", State: ServiceRegistered},
+		{Name: "orders", State: ServiceRunning},
+	}, mgr.ServiceStates(ctx))
+
+	require.NoError(t, mgr.StopService(ctx, "orders"))
+	require.NoError(t, mgr.StopService(ctx, "orders"), "stopping a stopped service does nothing")
+	info, err := mgr.ServiceState(ctx, "orders")
+	require.NoError(t, err)
+	assert.Equal(t, ServiceStopped, info.State)
+
+	svc.health = errors.New("degraded")
+	require.NoError(t, mgr.StartService(ctx, "orders"))
+	info, _ = mgr.ServiceState(ctx, "orders")
+	assert.Equal(t, ServiceInfo{Name: "orders", State: ServiceRunning, Error: "degraded"}, info)
+	assert.Equal(t, []string{"orders:init", "orders:start", "orders:stop", "orders:start"}, events)
+
+	assert.ErrorIs(t, mgr.StartService(ctx, "legacy"), ErrNoLifecycle)
+	assert.ErrorIs(t, mgr.StopService(ctx, "billing"), ErrServiceNotFound)
+}
@@ -2,16 +2,32 @@ package manager
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"grouter/pkg/archive"
+	"grouter/pkg/cache"
+	"grouter/pkg/chaos"
 	"grouter/pkg/config"
+	"grouter/pkg/eventbus"
+	grpcserver "grouter/pkg/grpc"
 	"grouter/pkg/health"
+	"grouter/pkg/loadshed"
 	"grouter/pkg/logger"
+	"grouter/pkg/messaging/mqtt"
 	messaging "grouter/pkg/messaging/nats"
+	"grouter/pkg/profiling"
+	"grouter/pkg/rbac"
+	"grouter/pkg/slo"
 	"grouter/pkg/telemetry"
 	"grouter/pkg/web"
+	"grouter/pkg/webhook"
 
+	"github.com/nats-io/nats.go"
 	"go.uber.org/zap"
 )
 
@@ -20,28 +36,88 @@ type ServiceManager struct {
 	cfg *config.Config
 	log *zap.Logger
 
+	// loader and logger are the instances behind cfg and log, owned by the
+	// manager so that several managers can run in one process
+	loader *config.Loader
+	logger *logger.Logger
+
 	router *ServiceRouter
 
 	messenger *messaging.Messenger
+	webhooks  *webhook.Forwarder
+	archiver  *archive.Archiver
+	mqtt      *mqtt.Bridge
 
 	webServer *web.Server
+	// webComponents are framework-provided routes (gateway, SSE) that must be
+	// mounted again whenever the web engine is reset
+	webComponents []web.WebService
+	sseBridge     *web.SSEBridge
+
+	grpcServer *grpcserver.Server
+	gateway    *grpcserver.Gateway
 
 	health  *health.HealthService
+	db      Database
+	rbac    *rbac.Engine
+	cache   cache.Cache
+	chaos   *chaos.Injector
+	slo     *slo.Tracker
 	timeout time.Duration
+	// started is set once Start has run, passing the startup probe
+	started atomic.Bool
+
+	// metrics is the registry shared by every component, served on the web
+	// server's metrics endpoint
+	metrics *telemetry.MetricsRegistry
+	// events is the in-process event bus of the services
+	events *eventbus.Bus
+	// pipeline counts the messages delivered to the services, nil in tests
+	// building the manager by hand
+	pipeline *pipelineMetrics
 
 	// Cleanup for OpenTelemetry
 	tracerShutdown func(context.Context) error
+	meterShutdown  func(context.Context) error
+
+	// reloadables receive the configuration changes, see ApplyConfig
+	reloadMu    sync.Mutex
+	reloadables []reloadable
+	watchOnce   sync.Once
+	// subjects are the subscriptions and handlers in flight of the
+	// NATService services by name, see ListSubscriptions
+	subjectsMu sync.Mutex
+	subjects   map[string]*serviceSubscriptions
+	// recentErrors are the last handler errors, see RecentErrors
+	recentErrors errorLog
+
+	// replays are the replay jobs by ID, see StartReplay
+	replaysMu sync.Mutex
+	replays   map[string]*replayJob
+
+	// lifecycle holds the ServiceV2 services in registration order
+	lifecycleMu sync.Mutex
+	lifecycle   []*managedService
+
+	// serviceConfigs are the typed service sections, see
+	// RegisterServiceConfig
+	serviceConfigs serviceConfigs
 }
 
 // NewServiceManager creates a new ServiceManager with default settings.
 func NewServiceManager() *ServiceManager {
-	return &ServiceManager{
+	m := &ServiceManager{
 		router:  NewServiceRouter(),
 		timeout: 10 * time.Second,
+		metrics: telemetry.NewMetricsRegistry(),
 	}
+	m.metrics.MustRegister(newManagerCollector(m))
+	m.pipeline = newPipelineMetrics(m.metrics)
+	return m
 }
 
-// Init initializes configuration, logger, NATS, and registers services.
+// Init initializes configuration, logger, telemetry, health and RBAC. New
+// runs it along with the other Init methods.
 func (m *ServiceManager) Init() error {
 	if err := m.initConfig(); err != nil {
 		return err
@@ -51,11 +127,24 @@ func (m *ServiceManager) Init() error {
 	}
 
 	// Initialize OpenTelemetry
-	shutdown, err := telemetry.InitTracer(m.cfg.Tracing)
+	shutdown, err := telemetry.InitTracer(tracingConfig(m.cfg))
 	if err != nil {
 		return fmt.Errorf("failed to initialize tracer: %w", err)
 	}
 	m.tracerShutdown = shutdown
+	m.AddReloadable("tracing", ReloadableFunc(m.reloadTracing), tracingSettings)
+
+	serviceName := m.cfg.Tracing.ServiceName
+	if serviceName == "" {
+		serviceName = m.cfg.App.Name
+	}
+	meterShutdown, err := telemetry.InitMeter(m.cfg.Metrics, serviceName, m.metrics)
+	if err != nil {
+		return fmt.Errorf("failed to initialize meter: %w", err)
+	}
+	m.meterShutdown = meterShutdown
+
+	telemetry.RegisterBuildInfo(m.metrics, m.cfg.App.Name, telemetry.ReadBuildInfo(m.cfg.App.Version))
 
 	m.log.Info("Initializing gRouter service",
 		zap.String("name", m.cfg.App.Name),
@@ -64,13 +153,112 @@ func (m *ServiceManager) Init() error {
 	)
 
 	// Register health service
-	m.health = health.NewHealthService()
+	m.initHealth()
+	if m.db != nil {
+		m.health.AddReadinessCheck("database", health.WithTimeout(m.cfg.Health.Timeout, m.db.HealthCheck))
+	}
+
+	if m.cfg.RBAC.Enabled {
+		m.initRBAC()
+	}
+	if m.cfg.Tenancy.Enabled && m.cfg.Tenancy.Subjects {
+		m.router.EnforceTenants(m.cfg.App.Name)
+		m.log.Info("Tenant subjects enforced", zap.String("prefix", m.cfg.App.Name))
+	}
+	if m.cfg.Chaos.Enabled {
+		if err := m.initChaos(); err != nil {
+			return err
+		}
+	}
+	if m.cfg.SLO.Enabled {
+		if err := m.initSLO(); err != nil {
+			return err
+		}
+	}
+	m.initEventBus()
 
 	return nil
 }
 
+// initEventBus creates the event bus of the services, with the bridges of
+// the config. InitNATS connects them.
+func (m *ServiceManager) initEventBus() {
+	m.events = eventbus.New(m.log)
+	for _, b := range m.cfg.EventBus.Bridges {
+		m.events.Bridge(b.Topic, b.Subject)
+	}
+}
+
+// tracingConfig returns the tracing settings of cfg, describing the
+// resource with the app settings by default
+func tracingConfig(cfg *config.Config) config.TracingConfig {
+	tc := cfg.Tracing
+	if tc.Resource.Environment == "" {
+		tc.Resource.Environment = cfg.App.Environment
+	}
+	if tc.Resource.Version == "" {
+		tc.Resource.Version = cfg.App.Version
+	}
+	return tc
+}
+
+// initHealth creates the health service, unless one was given with
+// WithHealth, and starts its background refresh when configured
+func (m *ServiceManager) initHealth() {
+	if m.health == nil {
+		opts := []health.Option{
+			health.WithDefaultTimeout(m.cfg.Health.Timeout),
+			health.WithCacheTTL(m.cfg.Health.CacheTTL),
+			health.WithRefreshInterval(m.cfg.Health.RefreshInterval),
+		}
+		if m.cfg.Metrics.Enabled {
+			opts = append(opts, health.WithMetrics(m.metrics))
+		}
+		m.health = health.NewHealthService(opts...)
+	}
+	m.health.AddStartupCheck("manager", func() error {
+		if !m.started.Load() {
+			return fmt.Errorf("service manager not started")
+		}
+		return nil
+	})
+	m.health.Start()
+}
+
+// initRBAC builds the policy engine from configuration and enforces it on
+// routed NATS messages. InitWebServer enforces it on HTTP routes.
+func (m *ServiceManager) initRBAC() {
+	policy := rbac.Policy{
+		DefaultDeny: m.cfg.RBAC.DefaultDeny,
+		Roles:       make(map[string]rbac.Permissions, len(m.cfg.RBAC.Roles)),
+	}
+	for name, role := range m.cfg.RBAC.Roles {
+		policy.Roles[name] = rbac.Permissions{
+			Routes:   role.Routes,
+			Messages: role.Messages,
+		}
+	}
+	m.rbac = rbac.NewEngine(policy)
+	m.router.Use(rbac.MessageMiddleware(m.rbac, m.cfg.RBAC.Sources))
+
+	m.log.Info("RBAC enabled",
+		zap.Int("roles", len(policy.Roles)),
+		zap.Bool("default_deny", policy.DefaultDeny),
+	)
+}
+
 func (m *ServiceManager) initConfig() error {
-	cfg, err := config.Load()
+	if m.cfg != nil && m.loader == nil {
+		// Given with WithConfig
+		if err := m.cfg.Validate(); err != nil {
+			return fmt.Errorf("config validation failed: %w", err)
+		}
+		return nil
+	}
+	if m.loader == nil {
+		m.loader = config.NewLoader()
+	}
+	cfg, err := m.loader.Load()
 	if err != nil {
 		return fmt.Errorf("failed to load configuration: %w", err)
 	}
@@ -82,15 +270,33 @@ func (m *ServiceManager) initLogger() error {
 	if m.cfg == nil {
 		return fmt.Errorf("init logger: config is nil")
 	}
-	log, err := logger.New(logger.Config{
+	if m.log != nil {
+		// Given with WithLogger
+		return nil
+	}
+	sinks := make([]logger.SinkConfig, 0, len(m.cfg.Log.Sinks))
+	for _, sink := range m.cfg.Log.Sinks {
+		sinks = append(sinks, logger.SinkConfig{
+			Output:   sink.Output,
+			Level:    sink.Level,
+			Format:   sink.Format,
+			Rotation: logger.RotationConfig(sink.Rotation),
+		})
+	}
+	log, err := logger.NewLogger(logger.Config{
 		Level:      m.cfg.Log.Level,
 		Format:     m.cfg.Log.Format,
 		OutputPath: m.cfg.Log.OutputPath,
+		Rotation:   logger.RotationConfig(m.cfg.Log.Rotation),
+		Sinks:      sinks,
+		Sampling:   logger.SamplingConfig(m.cfg.Log.Sampling),
 	})
 	if err != nil {
 		return fmt.Errorf("failed to initialize logger: %w", err)
 	}
-	m.log = log
+	m.logger = log
+	m.log = log.Logger
+	m.AddReloadable("logger", ReloadableFunc(m.reloadLogger), logSettings)
 	return nil
 }
 
@@ -99,137 +305,812 @@ func (m *ServiceManager) InitNATS() error {
 		return fmt.Errorf("init nats: config or logger is nil")
 	}
 
-	if !m.cfg.NATS.Enabled {
-		//m.log.Info("NATS disabled")
-		return nil
+	if m.messenger != nil {
+		// Given with WithMessagingDriver, connected by the caller
+		m.log.Info("NATS initialized with the provided messenger", zap.String("app", m.cfg.App.Name))
+	} else if driver := m.cfg.Messaging.Driver; driver != "" && driver != "nats" {
+		// Registered by the driver package linked into the binary
+		msg, err := messaging.OpenDriver(driver, func(cfg any) error {
+			return m.cfg.Messaging.Drivers.Decode(driver, cfg)
+		}, m.log, m.cfg.App.Name)
+		if err != nil {
+			return err
+		}
+		m.messenger = msg
+		m.log.Info("Messaging initialized via driver",
+			zap.String("driver", driver),
+			zap.String("app", m.cfg.App.Name),
+		)
+	} else {
+		if !m.cfg.NATS.Enabled {
+			//m.log.Info("NATS disabled")
+			return nil
+		}
+
+		// Initialize Messenger
+		m.messenger = &messaging.Messenger{}
+		if err := m.messenger.Init(m.natsConfig(m.cfg), m.log, m.cfg.App.Name); err != nil {
+			m.messenger = nil
+			return fmt.Errorf("failed to initialize messenger: %w", err)
+		}
+		m.AddReloadable("messenger", ReloadableFunc(m.reloadMessenger), natsAuthSettings)
+
+		m.log.Info("NATS initialized via Messenger",
+			zap.String("url", m.messenger.Client.Conn().ConnectedUrlRedacted()),
+			zap.Strings("servers", m.messenger.Client.Servers()),
+			zap.Bool("embedded", m.cfg.NATS.Embedded),
+			zap.String("app", m.cfg.App.Name),
+		)
 	}
 
-	// Initialize Messenger
-	m.messenger = &messaging.Messenger{}
-	if err := m.messenger.Init(messaging.Config{
-		URL:               m.cfg.NATS.URL,
-		MaxReconnects:     m.cfg.NATS.MaxReconnects,
-		ReconnectWait:     m.cfg.NATS.ReconnectWait,
-		ConnectionTimeout: m.cfg.NATS.ConnectionTimeout,
-		Token:             m.cfg.NATS.Token,
-		Username:          m.cfg.NATS.Username,
-		Password:          m.cfg.NATS.Password,
-		CredsFile:         m.cfg.NATS.CredsFile,
-		UseTLS:            m.cfg.NATS.UseTLS,
-		SkipVerify:        m.cfg.NATS.SkipVerify,
-		CAFile:            m.cfg.NATS.CAFile,
-		CertFile:          m.cfg.NATS.CertFile,
-		KeyFile:           m.cfg.NATS.KeyFile,
+	m.registerNATSHealthChecks()
+	if m.events != nil {
+		m.events.SetPublisher(m.messenger.Publisher)
+		if bridges := m.events.Bridges(); len(bridges) > 0 {
+			m.log.Info("Event bus bridged to NATS", zap.Any("bridges", bridges))
+		}
+	}
+	if err := m.initHealthResponder(); err != nil {
+		return err
+	}
+
+	if m.cfg.Webhooks.Enabled {
+		if err := m.initWebhooks(); err != nil {
+			return err
+		}
+	}
+
+	if m.cfg.Archive.Enabled {
+		if err := m.initArchive(); err != nil {
+			return err
+		}
+	}
+
+	if m.cfg.MQTT.Enabled {
+		if err := m.initMQTT(); err != nil {
+			return err
+		}
+	}
+
+	if m.cfg.Profiling.Enabled {
+		if err := m.initProfiling(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// natsConfig converts the NATS settings of cfg to the messenger config
+func (m *ServiceManager) natsConfig(cfg *config.Config) messaging.Config {
+	return messaging.Config{
+		URL:         cfg.NATS.URL,
+		URLs:        cfg.NATS.URLs,
+		NoRandomize: cfg.NATS.NoRandomize,
+		Embedded:    cfg.NATS.Embedded,
+		EmbeddedServer: messaging.EmbeddedServerConfig{
+			Host:         cfg.NATS.EmbeddedServer.Host,
+			Port:         cfg.NATS.EmbeddedServer.Port,
+			JetStream:    cfg.NATS.EmbeddedServer.JetStream,
+			StoreDir:     cfg.NATS.EmbeddedServer.StoreDir,
+			ReadyTimeout: cfg.NATS.EmbeddedServer.ReadyTimeout,
+		},
+		MaxReconnects:     cfg.NATS.MaxReconnects,
+		ReconnectWait:     cfg.NATS.ReconnectWait,
+		ConnectionTimeout: cfg.NATS.ConnectionTimeout,
+		Token:             cfg.NATS.Token,
+		Username:          cfg.NATS.Username,
+		Password:          cfg.NATS.Password,
+		CredsFile:         cfg.NATS.CredsFile,
+		UseTLS:            cfg.NATS.UseTLS,
+		SkipVerify:        cfg.NATS.SkipVerify,
+		CAFile:            cfg.NATS.CAFile,
+		CertFile:          cfg.NATS.CertFile,
+		KeyFile:           cfg.NATS.KeyFile,
+		SkipFlush:         cfg.NATS.SkipFlush,
+		CoalesceRequests:  cfg.NATS.CoalesceRequests,
+		AuthRotation: messaging.AuthRotationConfig{
+			WatchCreds: cfg.NATS.AuthRotation.WatchCreds,
+			Jitter:     cfg.NATS.AuthRotation.Jitter,
+		},
 		Metrics: messaging.MetricsConfig{
-			Enabled: m.cfg.NATS.Metrics.Enabled,
-			Path:    m.cfg.NATS.Metrics.Path,
+			Enabled:  cfg.NATS.Metrics.Enabled,
+			Path:     cfg.NATS.Metrics.Path,
+			Registry: m.metrics,
+			// Tenants are only labeled when tenancy is enabled
+			TenantLabel: cfg.Tenancy.Enabled && cfg.Tenancy.MetricsLabel,
+			Cardinality: messaging.CardinalityConfig(cfg.NATS.Metrics.Cardinality),
 		},
 		Logging: messaging.LoggingConfig{
-			Enabled: m.cfg.NATS.Logging.Enabled,
+			Enabled:  cfg.NATS.Logging.Enabled,
+			Sampling: logger.SamplingConfig(cfg.NATS.Logging.Sampling),
 		},
 		Tracing: messaging.TracingConfig{
-			Enabled: m.cfg.Tracing.Enabled,
+			Enabled: cfg.Tracing.Enabled,
+		},
+		Signing: natsSigningConfig(cfg.NATS.Signing),
+		LargePayloads: messaging.LargePayloadConfig{
+			Enabled: cfg.NATS.LargePayloads.Enabled,
+			Bucket:  cfg.NATS.LargePayloads.Bucket,
+			TTL:     cfg.NATS.LargePayloads.TTL,
+			MaxSize: cfg.NATS.LargePayloads.MaxSize,
+			Timeout: cfg.NATS.LargePayloads.Timeout,
+		},
+		Compression: messaging.CompressionConfig{
+			Enabled:   cfg.NATS.Compression.Enabled,
+			Algorithm: cfg.NATS.Compression.Algorithm,
+			Threshold: cfg.NATS.Compression.Threshold,
 		},
-	}, m.log, m.cfg.App.Name); err != nil {
-		return fmt.Errorf("failed to initialize messenger: %w", err)
+		Encryption: natsEncryptionConfig(cfg.NATS.Encryption),
+		Monitoring: messaging.MonitorConfig{
+			Enabled:        cfg.NATS.Monitoring.Enabled,
+			Interval:       cfg.NATS.Monitoring.Interval,
+			Streams:        cfg.NATS.Monitoring.Streams,
+			MaxPending:     cfg.NATS.Monitoring.MaxPending,
+			MaxAckPending:  cfg.NATS.Monitoring.MaxAckPending,
+			MaxRedelivered: cfg.NATS.Monitoring.MaxRedelivered,
+		},
+		Quarantine: messaging.QuarantineConfig{
+			Enabled:       cfg.NATS.Quarantine.Enabled,
+			MaxDeliveries: cfg.NATS.Quarantine.MaxDeliveries,
+			Stream:        cfg.NATS.Quarantine.Stream,
+			Prefix:        cfg.NATS.Quarantine.Prefix,
+			MaxAge:        cfg.NATS.Quarantine.MaxAge,
+		},
+		ResponseCache: messaging.ResponseCacheConfig{
+			Enabled:  cfg.NATS.ResponseCache.Enabled,
+			TTL:      cfg.NATS.ResponseCache.TTL,
+			Subjects: cfg.NATS.ResponseCache.Subjects,
+			Cache:    m.cache,
+		},
+		LoadShedding: loadShedConfig(cfg.NATS.LoadShedding),
+		Chaos:        m.chaos,
+		SLO:          m.slo,
 	}
+}
 
-	m.log.Info("NATS initialized via Messenger",
-		zap.String("url", m.cfg.NATS.URL),
-		zap.String("app", m.cfg.App.Name),
-	)
+// registerNATSHealthChecks makes readiness depend on the NATS connection and,
+// when the account has it enabled, on JetStream. Slow and lagging consumers
+// only degrade readiness.
+func (m *ServiceManager) registerNATSHealthChecks() {
+	if m.health == nil {
+		return
+	}
+	client := m.messenger.Client
+	if client == nil {
+		// Drivers of other brokers, given with WithMessagingDriver, report
+		// their connection through their publisher
+		if checker, ok := m.messenger.Publisher.(interface{ HealthCheck() error }); ok {
+			m.health.AddReadinessCheck("messaging", checker.HealthCheck)
+		}
+		return
+	}
+	m.health.AddReadinessCheck("nats", client.HealthCheck)
+	m.health.AddReadinessCheck("nats_slow_consumers", client.SlowConsumerCheck, health.WithCritical(false))
+
+	// A server without JetStream would otherwise never become ready
+	js, err := client.JetStream()
+	if err == nil {
+		_, err = js.AccountInfo()
+	}
+	if errors.Is(err, nats.ErrJetStreamNotEnabled) || errors.Is(err, nats.ErrJetStreamNotEnabledForAccount) {
+		m.log.Info("JetStream not enabled, skipping jetstream readiness check")
+		return
+	}
+	m.health.AddReadinessCheck("jetstream", health.WithTimeout(0, client.JetStreamHealthCheck))
+	if monitor := m.messenger.Monitor(); monitor != nil {
+		m.health.AddReadinessCheck("jetstream_lag", monitor.Check, health.WithCritical(false))
+	}
+}
 
+// initProfiling serves on-demand profile captures requested over NATS
+func (m *ServiceManager) initProfiling() error {
+	ctrl, err := profiling.New(m.messenger, profiling.Config{
+		Subject:         m.cfg.Profiling.Subject,
+		Service:         m.cfg.App.Name,
+		Broadcast:       m.cfg.Profiling.Broadcast,
+		Bucket:          m.cfg.Profiling.Bucket,
+		TTL:             m.cfg.Profiling.TTL,
+		DefaultDuration: m.cfg.Profiling.DefaultDuration,
+		MaxDuration:     m.cfg.Profiling.MaxDuration,
+	}, m.log)
+	if err != nil {
+		return fmt.Errorf("failed to create profiling controller: %w", err)
+	}
+	if err := ctrl.Start(); err != nil {
+		return fmt.Errorf("failed to start profiling controller: %w", err)
+	}
 	return nil
 }
 
-func (m *ServiceManager) InitWebServer() error {
-	if m.cfg == nil || m.log == nil {
-		return fmt.Errorf("init web server: config or logger is nil")
+// rateLimitRoutes converts the per route group rate limits
+func rateLimitRoutes(routes []config.RateLimitRoute) []web.RateLimitRoute {
+	out := make([]web.RateLimitRoute, 0, len(routes))
+	for _, r := range routes {
+		out = append(out, web.RateLimitRoute{
+			PathPrefix:        r.PathPrefix,
+			RequestsPerSecond: r.RequestsPerSecond,
+			Burst:             r.Burst,
+			Key:               r.Key,
+			Header:            r.Header,
+		})
 	}
+	return out
+}
 
-	if !m.cfg.Web.Enabled {
-		m.log.Info("Web server disabled")
-		return nil
+// loadShedConfig converts the load shedding thresholds
+func loadShedConfig(cfg config.LoadShedding) loadshed.Config {
+	return loadshed.Config{
+		Enabled:       cfg.Enabled,
+		MaxInFlight:   cfg.MaxInFlight,
+		MaxQueueDepth: cfg.MaxQueueDepth,
+		MaxCPU:        cfg.MaxCPU,
+		MaxMemory:     cfg.MaxMemory,
+		Interval:      cfg.Interval,
+		RetryAfter:    cfg.RetryAfter,
+	}
+}
+
+// routeLimits converts the per route group body size limits and timeouts
+func routeLimits(routes []config.RouteLimits) []web.RouteLimits {
+	out := make([]web.RouteLimits, 0, len(routes))
+	for _, r := range routes {
+		out = append(out, web.RouteLimits{
+			PathPrefix:     r.PathPrefix,
+			MaxBodySize:    r.MaxBodySize,
+			ReadTimeout:    r.ReadTimeout,
+			HandlerTimeout: r.HandlerTimeout,
+		})
+	}
+	return out
+}
+
+// versioningConfig converts the API version policies to the web config
+func versioningConfig(c config.VersioningConfig) web.VersioningConfig {
+	out := web.VersioningConfig{Versions: make(map[string]web.VersionPolicy, len(c.Versions))}
+	for name, p := range c.Versions {
+		out.Versions[name] = web.VersionPolicy{
+			Deprecated: p.Deprecated,
+			Sunset:     p.Sunset,
+			Link:       p.Link,
+		}
+	}
+	return out
+}
+
+// natsSigningConfig converts the signing settings to the messaging config
+func natsSigningConfig(c config.NATSSigning) messaging.SigningConfig {
+	sc := messaging.SigningConfig{
+		Enabled:  c.Enabled,
+		KeyID:    c.KeyID,
+		Verify:   c.Verify,
+		Subjects: c.Subjects,
+		MaxAge:   c.MaxAge,
+	}
+	for _, k := range c.Keys {
+		sc.Keys = append(sc.Keys, messaging.SigningKey{
+			ID:         k.ID,
+			Algorithm:  k.Algorithm,
+			Secret:     k.Secret,
+			PublicKey:  k.PublicKey,
+			PrivateKey: k.PrivateKey,
+		})
+	}
+	return sc
+}
+
+// natsEncryptionConfig converts the encryption settings to the messaging config
+func natsEncryptionConfig(c config.NATSEncryption) messaging.EncryptionConfig {
+	ec := messaging.EncryptionConfig{
+		Enabled:  c.Enabled,
+		KeyID:    c.KeyID,
+		Subjects: c.Subjects,
+	}
+	for _, k := range c.Keys {
+		ec.Keys = append(ec.Keys, messaging.EncryptionKey{ID: k.ID, Key: k.Key})
+	}
+	return ec
+}
+
+// initWebhooks starts forwarding the configured subjects to webhook URLs
+func (m *ServiceManager) initWebhooks() error {
+	cfg := webhook.Config{
+		Workers:   m.cfg.Webhooks.Workers,
+		QueueSize: m.cfg.Webhooks.QueueSize,
+		Registry:  m.metrics,
+	}
+	for _, t := range m.cfg.Webhooks.Targets {
+		cfg.Targets = append(cfg.Targets, webhook.Target{
+			Name:         t.Name,
+			Subject:      t.Subject,
+			QueueGroup:   t.QueueGroup,
+			URL:          t.URL,
+			Secret:       t.Secret,
+			Headers:      t.Headers,
+			Timeout:      t.Timeout,
+			MaxRetries:   t.MaxRetries,
+			RetryBackoff: t.RetryBackoff,
+		})
+	}
+
+	forwarder, err := webhook.New(m.messenger.Subscriber, cfg, m.log)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook forwarder: %w", err)
+	}
+	if err := forwarder.Start(); err != nil {
+		forwarder.Stop()
+		return fmt.Errorf("failed to start webhook forwarder: %w", err)
+	}
+	m.webhooks = forwarder
+	return nil
+}
+
+// initMQTT bridges the configured MQTT topics and NATS subjects
+func (m *ServiceManager) initMQTT() error {
+	cfg := mqtt.Config{
+		Broker:         m.cfg.MQTT.Broker,
+		ClientID:       m.cfg.MQTT.ClientID,
+		Username:       m.cfg.MQTT.Username,
+		Password:       m.cfg.MQTT.Password,
+		CleanSession:   m.cfg.MQTT.CleanSession,
+		KeepAlive:      m.cfg.MQTT.KeepAlive,
+		ConnectTimeout: m.cfg.MQTT.ConnectTimeout,
+		PublishTimeout: m.cfg.MQTT.PublishTimeout,
+		Registry:       m.metrics,
+	}
+	for _, r := range m.cfg.MQTT.Routes {
+		cfg.Routes = append(cfg.Routes, mqtt.Route{
+			Name:       r.Name,
+			Direction:  mqtt.Direction(r.Direction),
+			Topic:      r.Topic,
+			Subject:    r.Subject,
+			QoS:        byte(r.QoS),
+			Type:       r.Type,
+			Retain:     r.Retain,
+			Envelope:   r.Envelope,
+			QueueGroup: r.QueueGroup,
+		})
 	}
 
-	webConfig := web.Config{
-		Port:            m.cfg.Web.Port,
-		ReadTimeout:     m.cfg.Web.ReadTimeout,
-		WriteTimeout:    m.cfg.Web.WriteTimeout,
-		ShutdownTimeout: m.cfg.Web.ShutdownTimeout,
-		Mode:            m.cfg.Web.Mode,
+	bridge, err := mqtt.New(m.messenger.Publisher, m.messenger.Subscriber, cfg, m.log)
+	if err != nil {
+		return fmt.Errorf("failed to create MQTT bridge: %w", err)
+	}
+	if err := bridge.Start(); err != nil {
+		return fmt.Errorf("failed to start MQTT bridge: %w", err)
+	}
+	m.mqtt = bridge
+	if m.health != nil {
+		m.health.AddReadinessCheck("mqtt", bridge.HealthCheck)
+	}
+	return nil
+}
+
+// tenantSkipPaths returns the paths exempt from a required tenant:
+// tenancy.skip_paths, or web.auth.skip_paths when it is unset
+func tenantSkipPaths(cfg *config.Config) []string {
+	if cfg.Tenancy.SkipPaths != nil {
+		return cfg.Tenancy.SkipPaths
+	}
+	return cfg.Web.Auth.SkipPaths
+}
+
+// webConfig converts the web settings of cfg to the web server config
+func (m *ServiceManager) webConfig(cfg *config.Config) web.Config {
+	wc := web.Config{
+		Port:            cfg.Web.Port,
+		ReadTimeout:     cfg.Web.ReadTimeout,
+		WriteTimeout:    cfg.Web.WriteTimeout,
+		ShutdownTimeout: cfg.Web.ShutdownTimeout,
+		Mode:            cfg.Web.Mode,
 		Metrics: web.MetricsConfig{
-			Enabled: m.cfg.Web.Metrics.Enabled,
-			Path:    m.cfg.Web.Metrics.Path,
+			Enabled:  cfg.Web.Metrics.Enabled,
+			Path:     metricsPath(cfg),
+			Registry: m.metrics,
 		},
 		Tracing: web.TracingConfig{
-			Enabled:     m.cfg.Tracing.Enabled,
-			ServiceName: m.cfg.Tracing.ServiceName,
+			Enabled:     cfg.Tracing.Enabled,
+			ServiceName: cfg.Tracing.ServiceName,
 		},
 		TLS: web.TLSConfig{
-			Enabled:  m.cfg.Web.TLS.Enabled,
-			CertFile: m.cfg.Web.TLS.CertFile,
-			KeyFile:  m.cfg.Web.TLS.KeyFile,
+			Enabled:  cfg.Web.TLS.Enabled,
+			CertFile: cfg.Web.TLS.CertFile,
+			KeyFile:  cfg.Web.TLS.KeyFile,
+			Reload:   cfg.Web.TLS.Reload,
+			ACME: web.ACMEConfig{
+				Enabled:      cfg.Web.TLS.ACME.Enabled,
+				Domains:      cfg.Web.TLS.ACME.Domains,
+				Email:        cfg.Web.TLS.ACME.Email,
+				CacheDir:     cfg.Web.TLS.ACME.CacheDir,
+				DirectoryURL: cfg.Web.TLS.ACME.DirectoryURL,
+				HTTPAddr:     cfg.Web.TLS.ACME.HTTPAddr,
+			},
+		},
+		HTTP2: web.HTTP2Config{
+			Enabled:              cfg.Web.HTTP2.Enabled,
+			H2C:                  cfg.Web.HTTP2.H2C,
+			MaxConcurrentStreams: cfg.Web.HTTP2.MaxConcurrentStreams,
 		},
 		CORS: web.CORSConfig{
-			Enabled:          m.cfg.Web.CORS.Enabled,
-			AllowedOrigins:   m.cfg.Web.CORS.AllowedOrigins,
-			AllowedMethods:   m.cfg.Web.CORS.AllowedMethods,
-			AllowedHeaders:   m.cfg.Web.CORS.AllowedHeaders,
-			ExposedHeaders:   m.cfg.Web.CORS.ExposedHeaders,
-			AllowCredentials: m.cfg.Web.CORS.AllowCredentials,
-			MaxAge:           m.cfg.Web.CORS.MaxAge,
+			Enabled:          cfg.Web.CORS.Enabled,
+			AllowedOrigins:   cfg.Web.CORS.AllowedOrigins,
+			AllowedMethods:   cfg.Web.CORS.AllowedMethods,
+			AllowedHeaders:   cfg.Web.CORS.AllowedHeaders,
+			ExposedHeaders:   cfg.Web.CORS.ExposedHeaders,
+			AllowCredentials: cfg.Web.CORS.AllowCredentials,
+			MaxAge:           cfg.Web.CORS.MaxAge,
 		},
 		Security: web.SecurityConfig{
-			Enabled:               m.cfg.Web.Security.Enabled,
-			XSSProtection:         m.cfg.Web.Security.XSSProtection,
-			ContentTypeNosniff:    m.cfg.Web.Security.ContentTypeNosniff,
-			XFrameOptions:         m.cfg.Web.Security.XFrameOptions,
-			HSTSMaxAge:            m.cfg.Web.Security.HSTSMaxAge,
-			HSTSExcludeSubdomains: m.cfg.Web.Security.HSTSExcludeSubdomains,
-			ContentSecurityPolicy: m.cfg.Web.Security.ContentSecurityPolicy,
-			ReferrerPolicy:        m.cfg.Web.Security.ReferrerPolicy,
-			CustomHeaders:         m.cfg.Web.Security.CustomHeaders,
+			Enabled:               cfg.Web.Security.Enabled,
+			XSSProtection:         cfg.Web.Security.XSSProtection,
+			ContentTypeNosniff:    cfg.Web.Security.ContentTypeNosniff,
+			XFrameOptions:         cfg.Web.Security.XFrameOptions,
+			HSTSMaxAge:            cfg.Web.Security.HSTSMaxAge,
+			HSTSExcludeSubdomains: cfg.Web.Security.HSTSExcludeSubdomains,
+			ContentSecurityPolicy: cfg.Web.Security.ContentSecurityPolicy,
+			ReferrerPolicy:        cfg.Web.Security.ReferrerPolicy,
+			CustomHeaders:         cfg.Web.Security.CustomHeaders,
 		},
 		RateLimit: web.RateLimitConfig{
-			Enabled:           m.cfg.Web.RateLimit.Enabled,
-			RequestsPerSecond: m.cfg.Web.RateLimit.RequestsPerSecond,
-			Burst:             m.cfg.Web.RateLimit.Burst,
+			Enabled:           cfg.Web.RateLimit.Enabled,
+			RequestsPerSecond: cfg.Web.RateLimit.RequestsPerSecond,
+			Burst:             cfg.Web.RateLimit.Burst,
+			Key:               cfg.Web.RateLimit.Key,
+			Header:            cfg.Web.RateLimit.Header,
+			Store:             cfg.Web.RateLimit.Store,
+			Redis: web.RateLimitRedisConfig{
+				Addr:     cfg.Web.RateLimit.Redis.Addr,
+				Password: cfg.Web.RateLimit.Redis.Password,
+				DB:       cfg.Web.RateLimit.Redis.DB,
+				Prefix:   cfg.Web.RateLimit.Redis.Prefix,
+			},
+			Routes: rateLimitRoutes(cfg.Web.RateLimit.Routes),
+		},
+		Limits: web.LimitsConfig{
+			Enabled:        cfg.Web.Limits.Enabled,
+			MaxBodySize:    cfg.Web.Limits.MaxBodySize,
+			ReadTimeout:    cfg.Web.Limits.ReadTimeout,
+			HandlerTimeout: cfg.Web.Limits.HandlerTimeout,
+			Routes:         routeLimits(cfg.Web.Limits.Routes),
+		},
+		LoadShedding: web.LoadSheddingConfig{
+			Config:       loadShedConfig(cfg.Web.LoadShedding),
+			ExcludePaths: cfg.Web.LoadShedding.ExcludePaths,
+		},
+		Chaos: m.chaos,
+		SLO:   m.slo,
+		Compression: web.CompressionConfig{
+			Enabled:      cfg.Web.Compression.Enabled,
+			Encodings:    cfg.Web.Compression.Encodings,
+			Level:        cfg.Web.Compression.Level,
+			MinSize:      cfg.Web.Compression.MinSize,
+			ContentTypes: cfg.Web.Compression.ContentTypes,
+			ExcludePaths: cfg.Web.Compression.ExcludePaths,
+		},
+		Session: web.SessionConfig{
+			Enabled:    cfg.Web.Session.Enabled,
+			CookieName: cfg.Web.Session.CookieName,
+			Secrets:    cfg.Web.Session.Secrets,
+			Store:      cfg.Web.Session.Store,
+			Redis: web.RateLimitRedisConfig{
+				Addr:     cfg.Web.Session.Redis.Addr,
+				Password: cfg.Web.Session.Redis.Password,
+				DB:       cfg.Web.Session.Redis.DB,
+				Prefix:   cfg.Web.Session.Redis.Prefix,
+			},
+			IdleTimeout:     cfg.Web.Session.IdleTimeout,
+			AbsoluteTimeout: cfg.Web.Session.AbsoluteTimeout,
+			Domain:          cfg.Web.Session.Domain,
+			Path:            cfg.Web.Session.Path,
+			Secure:          cfg.Web.Session.Secure,
+			SameSite:        cfg.Web.Session.SameSite,
+		},
+		Idempotency: web.IdempotencyConfig{
+			Enabled:     cfg.Web.Idempotency.Enabled,
+			Header:      cfg.Web.Idempotency.Header,
+			TTL:         cfg.Web.Idempotency.TTL,
+			LockTimeout: cfg.Web.Idempotency.LockTimeout,
+			Methods:     cfg.Web.Idempotency.Methods,
+			Paths:       cfg.Web.Idempotency.Paths,
+			Required:    cfg.Web.Idempotency.Required,
+			MaxBodySize: cfg.Web.Idempotency.MaxBodySize,
+			Store:       cfg.Web.Idempotency.Store,
+			Redis: web.RateLimitRedisConfig{
+				Addr:     cfg.Web.Idempotency.Redis.Addr,
+				Password: cfg.Web.Idempotency.Redis.Password,
+				DB:       cfg.Web.Idempotency.Redis.DB,
+				Prefix:   cfg.Web.Idempotency.Redis.Prefix,
+			},
+		},
+		ResponseCache: web.ResponseCacheConfig{
+			Enabled:             cfg.Web.ResponseCache.Enabled,
+			TTL:                 cfg.Web.ResponseCache.TTL,
+			MaxTTL:              cfg.Web.ResponseCache.MaxTTL,
+			Paths:               cfg.Web.ResponseCache.Paths,
+			ExcludePaths:        cfg.Web.ResponseCache.ExcludePaths,
+			VaryHeaders:         cfg.Web.ResponseCache.VaryHeaders,
+			MaxBodySize:         cfg.Web.ResponseCache.MaxBodySize,
+			KeyPrefix:           cfg.Web.ResponseCache.KeyPrefix,
+			InvalidationSubject: cfg.Web.ResponseCache.InvalidationSubject,
+			Cache:               m.cache,
 		},
 		Swagger: web.SwaggerConfig{
-			Enabled: m.cfg.Web.Swagger.Enabled,
-			Path:    m.cfg.Web.Swagger.Path,
+			Enabled: cfg.Web.Swagger.Enabled,
+			Path:    cfg.Web.Swagger.Path,
+		},
+		Profiling: web.ProfilingConfig{
+			Enabled:              cfg.Web.Profiling.Enabled,
+			Path:                 cfg.Web.Profiling.Path,
+			Port:                 cfg.Web.Profiling.Port,
+			Roles:                cfg.Web.Profiling.Roles,
+			AllowUnauthenticated: cfg.Web.Profiling.AllowUnauthenticated,
+		},
+		OpenAPI: web.OpenAPIConfig{
+			Enabled:      cfg.Web.OpenAPI.Enabled,
+			Path:         cfg.Web.OpenAPI.Path,
+			Title:        cfg.Web.OpenAPI.Title,
+			Version:      cfg.Web.OpenAPI.Version,
+			Description:  cfg.Web.OpenAPI.Description,
+			MergeSwagger: cfg.Web.OpenAPI.MergeSwagger,
 		},
 		Logging: web.LoggingConfig{
-			Enabled: m.cfg.Web.Logging.Enabled,
+			Enabled: cfg.Web.Logging.Enabled,
+		},
+		Tenant: web.TenantConfig{
+			Enabled:   cfg.Tenancy.Enabled,
+			Header:    cfg.Tenancy.Header,
+			Claim:     cfg.Tenancy.Claim,
+			Required:  cfg.Tenancy.Required,
+			SkipPaths: tenantSkipPaths(cfg),
 		},
 		Auth: web.AuthConfig{
-			Enabled:  m.cfg.Web.Auth.Enabled,
-			Issuer:   m.cfg.Web.Auth.Issuer,
-			Audience: m.cfg.Web.Auth.Audience,
+			Enabled:      cfg.Web.Auth.Enabled,
+			Mode:         cfg.Web.Auth.Mode,
+			Issuer:       cfg.Web.Auth.Issuer,
+			Audience:     cfg.Web.Auth.Audience,
+			JWKSURL:      cfg.Web.Auth.JWKSURL,
+			Algorithms:   cfg.Web.Auth.Algorithms,
+			RolesClaim:   cfg.Web.Auth.RolesClaim,
+			APIKeyHeader: cfg.Web.Auth.APIKeyHeader,
+			SkipPaths:    cfg.Web.Auth.SkipPaths,
+		},
+		SSE: web.SSEConfig{
+			Enabled:          cfg.Web.SSE.Enabled,
+			Path:             cfg.Web.SSE.Path,
+			Subject:          cfg.Web.SSE.Subject,
+			BufferSize:       cfg.Web.SSE.BufferSize,
+			ClientBufferSize: cfg.Web.SSE.ClientBufferSize,
+			KeepAlive:        cfg.Web.SSE.KeepAlive,
+		},
+		NATSGateway: web.NATSGatewayConfig{
+			Enabled: cfg.Web.NATSGateway.Enabled,
+			Timeout: cfg.Web.NATSGateway.Timeout,
 		},
+		Versioning: versioningConfig(cfg.Web.Versioning),
+		Validation: web.ValidationConfig{
+			Translate: cfg.Web.Validation.Translate,
+			Locales:   cfg.Web.Validation.Locales,
+		},
+	}
+	for _, k := range cfg.Web.Auth.APIKeys {
+		wc.Auth.APIKeys = append(wc.Auth.APIKeys, web.APIKeyConfig{
+			Name:  k.Name,
+			Key:   k.Key,
+			Roles: k.Roles,
+		})
+	}
+	for _, r := range cfg.Web.NATSGateway.Routes {
+		wc.NATSGateway.Routes = append(wc.NATSGateway.Routes, web.NATSRoute{
+			Method:  r.Method,
+			Path:    r.Path,
+			Subject: r.Subject,
+			Type:    r.Type,
+			Timeout: r.Timeout,
+		})
+	}
+	return wc
+}
+
+func (m *ServiceManager) InitWebServer() error {
+	if m.cfg == nil || m.log == nil {
+		return fmt.Errorf("init web server: config or logger is nil")
+	}
+
+	if !m.cfg.Web.Enabled {
+		m.log.Info("Web server disabled")
+		return nil
+	}
+
+	webConfig := m.webConfig(m.cfg)
+	webServer, err := web.NewWebServer(webConfig, m.log, m.health)
+	if err != nil {
+		return fmt.Errorf("init web server: %w", err)
+	}
+	m.webServer = webServer
+	m.AddReloadable("web", ReloadableFunc(m.reloadWebServer), func(cfg *config.Config) any {
+		return m.webConfig(cfg)
+	})
+	if m.rbac != nil {
+		m.webServer.Use(rbac.GinMiddleware(m.rbac))
+	}
+
+	if webConfig.SSE.Enabled {
+		if err := m.initSSEBridge(webConfig.SSE); err != nil {
+			return err
+		}
+	}
+
+	if webConfig.ResponseCache.Enabled {
+		if err := m.initResponseCacheInvalidation(); err != nil {
+			return err
+		}
+	}
+
+	if webConfig.NATSGateway.Enabled {
+		if m.messenger == nil {
+			m.log.Warn("NATS gateway enabled but NATS is not initialized, skipping gateway routes")
+		} else {
+			gw, err := web.NewNATSGateway(webConfig.NATSGateway, m.messenger.Publisher, m.log)
+			if err != nil {
+				return fmt.Errorf("failed to create nats gateway: %w", err)
+			}
+			m.mountWebComponent(gw)
+		}
 	}
-	m.webServer = web.NewWebServer(webConfig, m.log, m.health)
 
 	// Start web server
 	if err := m.webServer.Start(); err != nil {
 		return fmt.Errorf("failed to start web server: %w", err)
 	}
+	if m.health != nil {
+		m.health.AddLivenessCheck("web", m.webServer.HealthCheck)
+	}
+
+	return nil
+}
+
+// initSSEBridge mounts the SSE endpoint and feeds it from the configured NATS subject
+func (m *ServiceManager) initSSEBridge(cfg web.SSEConfig) error {
+	m.sseBridge = web.NewSSEBridge(cfg, m.log)
+	m.mountWebComponent(m.sseBridge)
+
+	if cfg.Subject == "" {
+		m.log.Warn("SSE enabled without a subject; only events broadcast by services are streamed")
+		return nil
+	}
+	if m.messenger == nil {
+		m.log.Warn("SSE subject configured but NATS is not initialized, skipping bridge subscription",
+			zap.String("subject", cfg.Subject))
+		return nil
+	}
+	if err := m.messenger.Subscriber.Subscribe(cfg.Subject, m.sseBridge.HandleMessage, nil); err != nil {
+		return fmt.Errorf("failed to subscribe sse bridge to %s: %w", cfg.Subject, err)
+	}
+	m.log.Info("SSE bridge initialized", zap.String("path", cfg.Path), zap.String("subject", cfg.Subject))
+	return nil
+}
+
+// mountWebComponent registers framework routes on the web server and remembers
+// them for ReRegisterServices
+func (m *ServiceManager) mountWebComponent(c web.WebService) {
+	m.webComponents = append(m.webComponents, c)
+	m.webServer.RegisterWebService(c)
+}
+
+func (m *ServiceManager) InitGRPCServer() error {
+	if m.cfg == nil || m.log == nil {
+		return fmt.Errorf("init grpc server: config or logger is nil")
+	}
+
+	if !m.cfg.GRPC.Enabled {
+		m.log.Info("gRPC server disabled")
+		return nil
+	}
+
+	grpcConfig := grpcserver.Config{
+		Port:            m.cfg.GRPC.Port,
+		ShutdownTimeout: m.cfg.GRPC.ShutdownTimeout,
+		Reflection:      m.cfg.GRPC.Reflection,
+		MaxRecvMsgSize:  m.cfg.GRPC.MaxRecvMsgSize,
+		MaxSendMsgSize:  m.cfg.GRPC.MaxSendMsgSize,
+		TLS: grpcserver.TLSConfig{
+			Enabled:  m.cfg.GRPC.TLS.Enabled,
+			CertFile: m.cfg.GRPC.TLS.CertFile,
+			KeyFile:  m.cfg.GRPC.TLS.KeyFile,
+		},
+		Metrics: grpcserver.MetricsConfig{
+			Enabled:  m.cfg.GRPC.Metrics.Enabled,
+			Registry: m.metrics,
+		},
+		Tracing: grpcserver.TracingConfig{
+			Enabled: m.cfg.Tracing.Enabled,
+		},
+		Logging: grpcserver.LoggingConfig{
+			Enabled: m.cfg.GRPC.Logging.Enabled,
+		},
+	}
+	server, err := grpcserver.NewGRPCServer(grpcConfig, m.log, m.health)
+	if err != nil {
+		return fmt.Errorf("failed to create grpc server: %w", err)
+	}
+	// The server is started by Start, because gRPC services cannot be
+	// registered once the server is serving.
+	m.grpcServer = server
+
+	if m.cfg.GRPC.Gateway.Enabled {
+		if m.webServer == nil {
+			m.log.Warn("gRPC gateway enabled but web server is not initialized, skipping gateway")
+			return nil
+		}
+		m.gateway = grpcserver.NewGateway(grpcserver.GatewayConfig{
+			PathPrefix: m.cfg.GRPC.Gateway.PathPrefix,
+			OpenAPI: grpcserver.OpenAPIConfig{
+				Enabled: m.cfg.GRPC.Gateway.OpenAPI.Enabled,
+				Path:    m.cfg.GRPC.Gateway.OpenAPI.Path,
+				Dir:     m.cfg.GRPC.Gateway.OpenAPI.Dir,
+			},
+		}, m.log)
+		m.mountWebComponent(m.gateway)
+	}
 
 	return nil
 }
 
 // RegisterService registers a service with the manager.
-// It automatically detects and registers capabilities (Web, NATS).
+// It automatically detects and registers capabilities (Web, gRPC, NATS).
+// A ServiceV2 is initialized first, and started if the manager runs. If a
+// capability fails to register, a service not registered before is removed
+// again, see UnregisterService; a gRPC registration cannot be undone.
 func (m *ServiceManager) RegisterService(svc Service) error {
 	if svc == nil {
 		return nil
 	}
+	prev, registered := m.GetService(svc.Name())
+	fail := func(err error) error {
+		if !registered || prev != svc {
+			m.UnregisterService(svc.Name())
+		}
+		return err
+	}
+
+	if v2, ok := svc.(ServiceV2); ok {
+		if err := m.initService(v2); err != nil {
+			return err
+		}
+	}
 	m.router.Register(svc.Name(), svc)
 
-	// Check for Web Capability
+	// Check for NATS Capability
+	if natSvc, ok := svc.(NATService); ok {
+		if err := m.subscribeService(natSvc); err != nil {
+			return fail(err)
+		}
+	}
+
+	// Check for gRPC Capability
+	if m.grpcServer != nil {
+		if grpcSvc, ok := svc.(grpcserver.GRPCService); ok {
+			if err := m.grpcServer.RegisterGRPCService(grpcSvc); err != nil {
+				return fail(fmt.Errorf("failed to register grpc service %q: %w", svc.Name(), err))
+			}
+		}
+	}
+
+	// Check for gRPC Gateway Capability
+	if m.gateway != nil {
+		if gwSvc, ok := svc.(grpcserver.GatewayService); ok {
+			if err := m.gateway.RegisterGatewayService(context.Background(), svc.Name(), gwSvc); err != nil {
+				return fail(fmt.Errorf("failed to register gateway service %q: %w", svc.Name(), err))
+			}
+		}
+	}
+
+	// Check for Web Capability. Routes cannot be removed from the engine, so
+	// they are mounted once the other capabilities are registered.
 	if m.webServer != nil {
-		if webSvc, ok := svc.(web.WebService); ok {
+		if versioned, ok := svc.(web.VersionedWebService); ok {
+			m.webServer.RegisterWebServiceV(versioned, versioned.APIVersion())
+		} else if webSvc, ok := svc.(web.WebService); ok {
 			m.webServer.RegisterWebService(webSvc)
 		}
 	}
@@ -240,6 +1121,13 @@ func (m *ServiceManager) RegisterService(svc Service) error {
 // ReRegisterServices iterates over all currently defined services and re-registers them.
 // This is useful during a restart to ensure all services are active.
 func (m *ServiceManager) ReRegisterServices() {
+	// Framework routes (gateway, SSE) live on the web engine, which is rebuilt
+	// by ResetEngine
+	if m.webServer != nil {
+		for _, c := range m.webComponents {
+			m.webServer.RegisterWebService(c)
+		}
+	}
 	for _, serviceName := range m.ListServices() {
 		if svc, ok := m.GetService(serviceName); ok {
 			m.RegisterService(svc)
@@ -247,9 +1135,30 @@ func (m *ServiceManager) ReRegisterServices() {
 	}
 }
 
-// UnregisterService removes a service from the manager.
+// ReloadWebServer rebuilds the web routes from the registered services and
+// swaps them in atomically. The listener keeps running and requests in flight
+// finish on the previous routes.
+func (m *ServiceManager) ReloadWebServer(ctx context.Context) error {
+	if m.webServer == nil {
+		return nil
+	}
+	if err := m.webServer.ResetEngine(ctx); err != nil {
+		return fmt.Errorf("failed to reset web engine: %w", err)
+	}
+	m.ReRegisterServices()
+	m.webServer.Activate()
+	return nil
+}
+
+// UnregisterService removes a service from the manager, removing the
+// subscriptions of a NATSService and stopping a ServiceV2. It returns once the
+// handlers in flight of the service are done, or after the manager timeout.
 func (m *ServiceManager) UnregisterService(name string) {
 	m.router.Unregister(name)
+	m.unsubscribeService(name)
+	if ms := m.managed(name); ms != nil {
+		m.stopService(ms)
+	}
 }
 
 // Logger returns the initialized logger.
@@ -267,19 +1176,66 @@ func (m *ServiceManager) Messenger() *messaging.Messenger {
 	return m.messenger
 }
 
+// EventBus returns the in-process event bus of the services, nil before
+// Init
+func (m *ServiceManager) EventBus() *eventbus.Bus {
+	return m.events
+}
+
 func (m *ServiceManager) Config() *config.Config {
 	return m.cfg
 }
 
+// RBAC returns the authorization policy engine, or nil if RBAC is disabled.
+// Call Update on it to replace the policy, e.g. after loading it from a database.
+func (m *ServiceManager) RBAC() *rbac.Engine {
+	return m.rbac
+}
+
 // Health returns the shared HealthService instance
 func (m *ServiceManager) Health() *health.HealthService {
 	return m.health
 }
 
+// Database returns the database given with WithDatabase, or nil
+func (m *ServiceManager) Database() Database {
+	return m.db
+}
+
+// Metrics returns the metrics registry shared by the framework; services
+// register their own metrics in it to have them served on the same endpoint
+func (m *ServiceManager) Metrics() *telemetry.MetricsRegistry {
+	return m.metrics
+}
+
+// metricsPath is the web metrics endpoint: web.metrics.path, else the global
+// metrics.path
+func metricsPath(cfg *config.Config) string {
+	if cfg.Web.Metrics.Path != "" {
+		return cfg.Web.Metrics.Path
+	}
+	return cfg.Metrics.Path
+}
+
 func (m *ServiceManager) WebServer() *web.Server {
 	return m.webServer
 }
 
+// GRPCServer returns the gRPC server, or nil if gRPC is disabled
+func (m *ServiceManager) GRPCServer() *grpcserver.Server {
+	return m.grpcServer
+}
+
+// SSEBridge returns the SSE bridge, or nil if SSE is disabled
+func (m *ServiceManager) SSEBridge() *web.SSEBridge {
+	return m.sseBridge
+}
+
+// Gateway returns the gRPC gateway, or nil if it is disabled
+func (m *ServiceManager) Gateway() *grpcserver.Gateway {
+	return m.gateway
+}
+
 func (m *ServiceManager) ListServices() []string {
 	return m.router.store.List()
 }
@@ -288,34 +1244,91 @@ func (m *ServiceManager) GetService(name string) (Service, bool) {
 	return m.router.store.Get(name)
 }
 
-// Start begins listening for messages on the configured topics.
+// Start starts the registered ServiceV2 services and the gRPC server if it is
+// enabled.
 func (m *ServiceManager) Start(ctx context.Context) error {
+	if err := m.startServices(ctx); err != nil {
+		return err
+	}
+	if m.grpcServer != nil {
+		if err := m.grpcServer.Start(); err != nil {
+			m.stopServices(ctx)
+			return fmt.Errorf("failed to start grpc server: %w", err)
+		}
+	}
+	m.started.Store(true)
+	if m.loader != nil {
+		m.WatchConfig()
+	}
 	m.log.Debug("ServiceManager started successfully")
 	return nil
 }
 
 func (m *ServiceManager) onNATSMessage(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+	if err := m.routeMessage(ctx, subject, env); err != nil {
+		return m.handlerError(ctx, m.topicService(env.Type), env.Type, env, err)
+	}
+	return nil
+}
+
+// routeMessage delivers a message of SubscribeToTopics to the service of its
+// type, returning the error of the service
+func (m *ServiceManager) routeMessage(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
 	m.log.Debug("Received message",
 		zap.String("subject", subject),
 		zap.String("type", env.Type),
 		zap.String("id", env.ID),
 	)
-	//topic := strings.TrimPrefix(subject, m.cfg.App.Name+".")
-	topic := env.Type
-	err := m.router.HandleMessage(ctx, topic, env)
+	if strings.HasPrefix(subject, m.healthPrefix()) || subject == m.cacheInvalidationSubject() {
+		// Handled by the health responder and the response cache, which have
+		// their own subscriptions
+		return nil
+	}
+	ctx, span := startRouteSpan(ctx, subject, env)
+	service, err := m.route(ctx, subject, env)
+	endRouteSpan(span, service, err)
+	return err
+}
+
+// route delivers a message to the service of its type, returning the name of
+// the service, "" when there is none, and its error
+func (m *ServiceManager) route(ctx context.Context, subject string, env *messaging.MessageEnvelope) (string, error) {
+	ctx, topic, err := m.router.RouteSubject(ctx, subject, env)
 	if err != nil {
-		m.log.Error("HandleMessage failed",
-			zap.Error(err),
-			zap.String("topic", topic),
-			zap.String("id", env.ID),
-		)
-		if env.Reply != "" && m.messenger != nil && m.messenger.Publisher != nil {
-			return m.messenger.Publisher.PublishError(ctx, env.Reply, err.Error())
+		m.pipeline.unrouted(routeInvalidSubject)
+		return "", err
+	}
+	svc, err := m.router.RouteByTopic(topic)
+	if err != nil {
+		// The router middleware still sees the message, and the router
+		// returns the error
+		m.pipeline.unrouted(routeUnknownService)
+		return "", m.router.HandleMessage(ctx, topic, env)
+	}
+	if _, ok := svc.(NATService); !ok {
+		m.pipeline.unrouted(routeNotNATS)
+		return svc.Name(), m.router.HandleMessage(ctx, topic, env)
+	}
+	if entry := m.serviceEntry(svc.Name()); entry != nil {
+		if !entry.begin() {
+			// Delivered while the service was being unregistered
+			return svc.Name(), nil
 		}
-		return nil
+		defer entry.end()
 	}
+	start := time.Now()
+	err = m.router.HandleMessage(ctx, topic, env)
+	m.pipeline.handled(svc.Name(), env.Type, start, err)
+	return svc.Name(), err
+}
 
-	return nil
+// topicService returns the name of the service of topic, or ""
+func (m *ServiceManager) topicService(topic string) string {
+	svc, err := m.router.RouteByTopic(topic)
+	if err != nil {
+		return ""
+	}
+	return svc.Name()
 }
 
 // replyError is deprecated. Use m.messenger.Publisher.PublishError instead.
@@ -325,16 +1338,68 @@ func (m *ServiceManager) onNATSMessage(ctx context.Context, subject string, env
 func (m *ServiceManager) Stop(ctx context.Context) error {
 	m.log.Info("Stopping gRouter service")
 
+	// The other members of the queue groups take over first
+	m.preStop(ctx)
+
+	// Replays hand messages to the services
+	m.stopReplays(ctx)
+
+	// Services may still publish while stopping
+	m.stopServices(ctx)
+
+	// Devices may still publish until the bridge disconnects
+	if m.mqtt != nil {
+		m.mqtt.Stop()
+	}
+
+	// The archiver acknowledges the envelopes it writes while stopping
+	if m.archiver != nil {
+		m.archiver.Stop()
+	}
+
+	// Alerts are published while the messenger is open
+	if m.slo != nil {
+		m.slo.Stop()
+	}
+
 	if m.messenger != nil {
 		if err := m.messenger.Close(); err != nil {
 			m.log.Error("Failed to close messenger", zap.Error(err))
 		}
 	}
+	if m.health != nil {
+		m.health.Stop()
+	}
+	if m.webhooks != nil {
+		m.webhooks.Stop()
+	}
+	if m.cache != nil {
+		if err := m.cache.Close(); err != nil {
+			m.log.Error("Failed to close cache", zap.Error(err))
+		}
+	}
+	if m.sseBridge != nil {
+		// Open streams would otherwise hold up the web server shutdown
+		m.sseBridge.Close()
+	}
 	if m.webServer != nil {
 		if err := m.webServer.Stop(ctx); err != nil {
 			m.log.Error("Failed to stop web server", zap.Error(err))
 		}
 	}
+	if m.grpcServer != nil {
+		if err := m.grpcServer.Stop(ctx); err != nil {
+			m.log.Error("Failed to stop grpc server", zap.Error(err))
+		}
+	}
+	if m.db != nil {
+		if err := m.db.Close(); err != nil {
+			m.log.Error("Failed to close database", zap.Error(err))
+		}
+	}
+	if m.loader != nil {
+		m.loader.Close()
+	}
 	if m.log != nil {
 		_ = m.log.Sync()
 	}
@@ -344,6 +1409,11 @@ func (m *ServiceManager) Stop(ctx context.Context) error {
 			m.log.Warn("Failed to shutdown tracer", zap.Error(err))
 		}
 	}
+	if m.meterShutdown != nil {
+		if err := m.meterShutdown(ctx); err != nil {
+			m.log.Warn("Failed to shutdown meter", zap.Error(err))
+		}
+	}
 	return nil
 }
 
@@ -355,14 +1425,33 @@ func (m *ServiceManager) SubscribeToTopics(topic string, queueGroup string) erro
 		return nil
 	}
 
-	if err := m.messenger.Subscriber.Subscribe(
+	t := &trackedSubscription{spec: SubjectSpec{Subject: topic, QueueGroup: queueGroup}}
+	sub, err := m.messenger.Subscriber.SubscribeSubject(
 		topic,
-		m.onNATSMessage,
+		t.handle(m.routeMessage, func(ctx context.Context, _ string, env *messaging.MessageEnvelope, err error) error {
+			return m.handlerError(ctx, m.topicService(env.Type), env.Type, env, err)
+		}),
 		&messaging.SubscribeOptions{
 			QueueGroup: queueGroup,
-		}); err != nil {
+		})
+	if err != nil {
 		return fmt.Errorf("failed to subscribe: %w", err)
 	}
+	t.sub = sub
+	m.trackTopic(t)
+
+	return nil
+}
 
+// UnsubscribeFromTopics removes the subscription made by SubscribeToTopics on
+// topic, leaving the other subscriptions in place
+func (m *ServiceManager) UnsubscribeFromTopics(topic string) error {
+	if m.messenger == nil {
+		return nil
+	}
+	if err := m.messenger.Subscriber.UnsubscribeSubject(topic); err != nil {
+		return fmt.Errorf("failed to unsubscribe: %w", err)
+	}
+	m.untrackTopic(topic)
 	return nil
 }
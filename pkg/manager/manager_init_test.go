@@ -5,9 +5,12 @@ import (
 	"path/filepath"
 	"testing"
 
+	"grouter/pkg/config"
+
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
 )
 
 func resetFlags() {
@@ -126,3 +129,25 @@ log:
 		assert.NotNil(t, mgr.messenger)
 	}
 }
+
+func TestServiceManager_Init_TwoManagers(t *testing.T) {
+	newManager := func(name, level string) *ServiceManager {
+		configFile := filepath.Join(t.TempDir(), "config.yaml")
+		content := "app:\n  name: " + name + "\nlog:\n  level: " + level + "\n  format: json\n"
+		assert.NoError(t, os.WriteFile(configFile, []byte(content), 0644))
+
+		mgr := NewServiceManager()
+		mgr.loader = config.NewLoader(config.WithArgs([]string{"--config", configFile}))
+		assert.NoError(t, mgr.Init())
+		return mgr
+	}
+
+	// Each manager owns its config and logger instances
+	first := newManager("first", "info")
+	second := newManager("second", "debug")
+	assert.Equal(t, "first", first.Config().App.Name)
+	assert.Equal(t, "second", second.Config().App.Name)
+	assert.False(t, first.Logger().Core().Enabled(zapcore.DebugLevel))
+	assert.True(t, second.Logger().Core().Enabled(zapcore.DebugLevel))
+	assert.Nil(t, config.Get(), "the package-level config is not used")
+}
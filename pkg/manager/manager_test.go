@@ -4,67 +4,28 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
 	"grouter/pkg/config"
+	"grouter/pkg/eventbus"
 	messaging "grouter/pkg/messaging/nats"
+	"grouter/pkg/messaging/nats/mocks"
 
-	"github.com/nats-io/nats.go"
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/gin-gonic/gin"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
 )
 
-type mockPublisher struct {
-	publishedSubject string
-	publishedType    string
-	publishedData    interface{}
-}
-
-func (m *mockPublisher) Publish(ctx context.Context, subject string, msgType string, data interface{}, opts *messaging.PublishOptions) error {
-	m.publishedSubject = subject
-	m.publishedType = msgType
-	m.publishedData = data
-	return nil
-}
-
-func (m *mockPublisher) PublishError(ctx context.Context, subject string, errMsg string) error {
-	m.publishedSubject = subject
-	m.publishedType = "error"
-	m.publishedData = map[string]string{"error": errMsg}
-	return nil
-}
-
-func (m *mockPublisher) Request(ctx context.Context, subject string, msgType string, data interface{}, timeout time.Duration) (*messaging.MessageEnvelope, error) {
-	return nil, nil
-}
-
-func (m *mockPublisher) PublishJS(ctx context.Context, subject string, msgType string, data interface{}, opts ...nats.PubOpt) (*nats.PubAck, error) {
-	m.publishedSubject = subject
-	m.publishedType = msgType
-	m.publishedData = data
-	return &nats.PubAck{}, nil
-}
-
-func (m *mockPublisher) PublishAsyncJS(ctx context.Context, subject string, msgType string, data interface{}, opts ...nats.PubOpt) (nats.PubAckFuture, error) {
-	m.publishedSubject = subject
-	m.publishedType = msgType
-	m.publishedData = data
-	return nil, nil
-}
-
-func (m *mockPublisher) Use(mw ...messaging.PublisherMiddleware) {
-	// no-op for mock
-}
-
-func (m *mockPublisher) UseRequest(mw ...messaging.RequestMiddleware) {
-	// no-op for mock
-}
-
-func (m *mockPublisher) SetValidator(v messaging.Validator) {
-	// no-op for mock
-}
-
 func TestServiceManager_OnMessage(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
 	router := NewServiceRouter()
@@ -72,7 +33,7 @@ func TestServiceManager_OnMessage(t *testing.T) {
 	svc := &mockService{name: "test"}
 	router.Register("test", svc)
 
-	pub := &mockPublisher{}
+	pub := mocks.NewPublisher()
 	// Create mock messenger
 	messenger := &messaging.Messenger{
 		Publisher: pub,
@@ -146,12 +107,11 @@ func TestServiceManager_OnMessage(t *testing.T) {
 		assert.NoError(t, err)
 
 		// Verify that PublishError was called on the mock publisher
-		assert.Equal(t, "inbox.error", pub.publishedSubject)
-		assert.Equal(t, "error", pub.publishedType)
-		// Check data if needed
-		dataMap, ok := pub.publishedData.(map[string]string)
+		msg, ok := pub.Last()
 		assert.True(t, ok)
-		assert.Equal(t, "intentional error", dataMap["error"])
+		assert.Equal(t, "inbox.error", msg.Subject)
+		assert.Equal(t, "error", msg.Type)
+		assert.Equal(t, map[string]string{"error": "intentional error"}, msg.Data)
 	})
 }
 
@@ -172,3 +132,321 @@ type errorService struct {
 func (s *errorService) Handle(ctx context.Context, topic string, msg *messaging.MessageEnvelope) error {
 	return fmt.Errorf("intentional error")
 }
+
+type grpcService struct {
+	mockService
+	registered int
+}
+
+func (s *grpcService) RegisterGRPC(server grpc.ServiceRegistrar) {
+	s.registered++
+}
+
+func TestServiceManager_GRPC(t *testing.T) {
+	mgr := &ServiceManager{
+		log:    zap.NewNop(),
+		router: NewServiceRouter(),
+		cfg: &config.Config{
+			App: config.AppConfig{Name: "grouter"},
+			GRPC: config.GRPCConfig{
+				Enabled: true,
+				Port:    0,
+			},
+		},
+	}
+
+	assert.NoError(t, mgr.InitGRPCServer())
+	assert.NotNil(t, mgr.GRPCServer())
+
+	svc := &grpcService{mockService: mockService{name: "greeter"}}
+	assert.NoError(t, mgr.RegisterService(svc))
+	assert.Equal(t, 1, svc.registered)
+
+	assert.NoError(t, mgr.Start(context.Background()))
+	assert.NotNil(t, mgr.GRPCServer().Addr())
+
+	// gRPC services cannot be added once the server is serving
+	late := &grpcService{mockService: mockService{name: "late"}}
+	assert.Error(t, mgr.RegisterService(late))
+	_, ok := mgr.GetService("late")
+	assert.False(t, ok, "a service failing to register is removed again")
+	assert.Error(t, mgr.RegisterService(svc))
+	_, ok = mgr.GetService("greeter")
+	assert.True(t, ok, "a registered service is kept when registered again")
+
+	assert.NoError(t, mgr.Stop(context.Background()))
+	assert.Nil(t, mgr.GRPCServer().Addr())
+}
+
+type gatewayService struct {
+	grpcService
+}
+
+func (s *gatewayService) RegisterGateway(ctx context.Context, mux *runtime.ServeMux) error {
+	return mux.HandlePath(http.MethodGet, "/api/v1/hello", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func TestServiceManager_GRPCGateway(t *testing.T) {
+	mgr := &ServiceManager{
+		log:    zap.NewNop(),
+		router: NewServiceRouter(),
+		cfg: &config.Config{
+			App: config.AppConfig{Name: "grouter"},
+			Web: config.WebConfig{
+				Enabled: true,
+				Mode:    "test",
+			},
+			GRPC: config.GRPCConfig{
+				Enabled: true,
+				Gateway: config.GatewayConfig{
+					Enabled:    true,
+					PathPrefix: "/api",
+				},
+			},
+		},
+	}
+
+	assert.NoError(t, mgr.InitWebServer())
+	assert.NoError(t, mgr.InitGRPCServer())
+	assert.NotNil(t, mgr.Gateway())
+
+	svc := &gatewayService{grpcService: grpcService{mockService: mockService{name: "greeter"}}}
+	assert.NoError(t, mgr.RegisterService(svc))
+	assert.Equal(t, 1, svc.registered)
+
+	engine := gin.New()
+	mgr.Gateway().RegisterRoutes(engine.Group("/"))
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/hello", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestServiceManager_MetricsRegistry(t *testing.T) {
+	mgr := NewServiceManager()
+	mgr.log = zap.NewNop()
+	mgr.cfg = &config.Config{
+		App:     config.AppConfig{Name: "grouter"},
+		Metrics: config.MetricsConfig{Enabled: true, Path: "/internal/metrics"},
+		Web: config.WebConfig{
+			Enabled: true,
+			Mode:    "test",
+			Metrics: config.MetricsConfig{Enabled: true},
+		},
+	}
+	mgr.Metrics().CounterVec(prometheus.CounterOpts{Name: "orders_created_total", Help: "Orders"}, nil).WithLabelValues().Inc()
+
+	assert.NoError(t, mgr.InitWebServer())
+	// Resetting the engine installs the metrics middleware again
+	assert.NoError(t, mgr.ReloadWebServer(context.Background()))
+
+	w := httptest.NewRecorder()
+	mgr.WebServer().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/internal/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "orders_created_total 1")
+	assert.Contains(t, w.Body.String(), "go_goroutines")
+}
+
+func TestServiceManager_SSEBridge(t *testing.T) {
+	mgr := &ServiceManager{
+		log:    zap.NewNop(),
+		router: NewServiceRouter(),
+		cfg: &config.Config{
+			App: config.AppConfig{Name: "grouter"},
+			Web: config.WebConfig{
+				Enabled: true,
+				Mode:    "test",
+				SSE: config.SSEConfig{
+					Enabled: true,
+					Path:    "/events",
+					Subject: "gRouter.events.>",
+				},
+			},
+		},
+	}
+
+	// Without NATS the endpoint is still mounted, only the subscription is skipped
+	assert.NoError(t, mgr.InitWebServer())
+	assert.NotNil(t, mgr.SSEBridge())
+	assert.Len(t, mgr.webComponents, 1)
+
+	assert.NoError(t, mgr.Stop(context.Background()))
+}
+
+type routeService struct {
+	mockService
+}
+
+func (s *routeService) RegisterRoutes(g *gin.RouterGroup) {
+	g.GET("/"+s.name, func(c *gin.Context) { c.Status(http.StatusOK) })
+}
+
+func TestServiceManager_ReloadWebServer(t *testing.T) {
+	mgr := &ServiceManager{
+		log:    zap.NewNop(),
+		router: NewServiceRouter(),
+		cfg: &config.Config{
+			App: config.AppConfig{Name: "grouter"},
+			Web: config.WebConfig{
+				Enabled: true,
+				Mode:    "test",
+				SSE:     config.SSEConfig{Enabled: true, Path: "/events"},
+			},
+		},
+	}
+	assert.NoError(t, mgr.InitWebServer())
+	assert.NoError(t, mgr.RegisterService(&routeService{mockService{name: "orders"}}))
+
+	status := func(path string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		mgr.WebServer().ServeHTTP(w, req)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusOK, status("/orders"))
+
+	mgr.UnregisterService("orders")
+	assert.NoError(t, mgr.RegisterService(&routeService{mockService{name: "billing"}}))
+	assert.NoError(t, mgr.ReloadWebServer(context.Background()))
+
+	assert.Equal(t, http.StatusNotFound, status("/orders"))
+	assert.Equal(t, http.StatusOK, status("/billing"))
+	assert.Len(t, mgr.webComponents, 1, "framework routes are re-mounted, not duplicated")
+
+	assert.NoError(t, mgr.Stop(context.Background()))
+}
+
+type versionedRouteService struct {
+	routeService
+}
+
+func (s *versionedRouteService) APIVersion() string { return "v2" }
+
+func TestServiceManager_RegisterVersionedService(t *testing.T) {
+	mgr := &ServiceManager{
+		log:    zap.NewNop(),
+		router: NewServiceRouter(),
+		cfg: &config.Config{
+			App: config.AppConfig{Name: "grouter"},
+			Web: config.WebConfig{Enabled: true, Mode: "test"},
+		},
+	}
+	assert.NoError(t, mgr.InitWebServer())
+	assert.NoError(t, mgr.RegisterService(&versionedRouteService{routeService{mockService{name: "orders"}}}))
+	mgr.WebServer().Activate()
+
+	w := httptest.NewRecorder()
+	mgr.WebServer().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v2/orders", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestServiceManager_EventBus(t *testing.T) {
+	s := runNATSServer(t, false)
+	mgr := &ServiceManager{
+		log:    zap.NewNop(),
+		router: NewServiceRouter(),
+		cfg: &config.Config{
+			App:  config.AppConfig{Name: "grouter"},
+			NATS: config.NATSConfig{Enabled: true, URL: s.ClientURL(), ConnectionTimeout: 2 * time.Second},
+			EventBus: config.EventBusConfig{Bridges: []config.EventBridge{
+				{Topic: "orders.placed", Subject: "grouter.events.orders.placed"},
+			}},
+		},
+	}
+	mgr.initEventBus()
+	require.NoError(t, mgr.InitNATS())
+	t.Cleanup(func() { _ = mgr.messenger.Close() })
+	require.Same(t, mgr.events, mgr.deps().Events)
+
+	received := make(chan *messaging.MessageEnvelope, 1)
+	require.NoError(t, mgr.messenger.Subscriber.Subscribe("grouter.events.orders.placed",
+		func(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+			received <- env
+			return nil
+		}, nil))
+	require.NoError(t, mgr.messenger.Client.Conn().Flush())
+
+	local := 0
+	placed := eventbus.NewTopic[map[string]string](mgr.EventBus(), "orders.placed")
+	placed.Subscribe(func(ctx context.Context, ev map[string]string) error {
+		local++
+		return nil
+	})
+	require.NoError(t, placed.Publish(context.Background(), map[string]string{"id": "1"}))
+	assert.Equal(t, 1, local)
+
+	select {
+	case env := <-received:
+		assert.Equal(t, "orders.placed", env.Type)
+		assert.JSONEq(t, `{"id":"1"}`, string(env.Data))
+	case <-time.After(5 * time.Second):
+		t.Fatal("bridged event not published")
+	}
+}
+
+func TestServiceManager_MQTT(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+	// The NATS server is the MQTT broker too
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	mqttPort := l.Addr().(*net.TCPAddr).Port
+	require.NoError(t, l.Close())
+	s, err := server.NewServer(&server.Options{
+		ServerName: "mqtt",
+		Port:       -1,
+		JetStream:  true,
+		StoreDir:   t.TempDir(),
+		MQTT:       server.MQTTOpts{Host: "127.0.0.1", Port: mqttPort},
+	})
+	require.NoError(t, err)
+	go s.Start()
+	t.Cleanup(s.Shutdown)
+	require.True(t, s.ReadyForConnections(10*time.Second))
+	broker := fmt.Sprintf("tcp://127.0.0.1:%d", mqttPort)
+
+	mgr := newNATSManager(t, s, func(cfg *config.Config) {
+		cfg.MQTT = config.MQTTConfig{Enabled: true, Broker: broker, Routes: []config.MQTTRoute{
+			{Name: "telemetry", Direction: "inbound", Topic: "devices/{device}/telemetry", Subject: "iot.{device}.telemetry", QoS: 1, Type: "device.telemetry"},
+		}}
+	})
+	t.Cleanup(mgr.mqtt.Stop)
+	results, err := mgr.health.CheckReadiness()
+	require.NoError(t, err)
+	assert.Contains(t, results, "mqtt")
+
+	received := make(chan *messaging.MessageEnvelope, 1)
+	require.NoError(t, mgr.messenger.Subscriber.Subscribe("iot.*.telemetry",
+		func(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+			received <- env
+			return nil
+		}, nil))
+	require.NoError(t, mgr.messenger.Client.Conn().Flush())
+
+	device := paho.NewClient(paho.NewClientOptions().AddBroker(broker).SetClientID("device-1"))
+	require.True(t, device.Connect().WaitTimeout(5*time.Second))
+	defer device.Disconnect(0)
+	require.NoError(t, device.Publish("devices/d1/telemetry", 1, false, `{"celsius":21}`).Error())
+
+	select {
+	case env := <-received:
+		assert.Equal(t, "device.telemetry", env.Type)
+		assert.JSONEq(t, `{"celsius":21}`, string(env.Data))
+	case <-time.After(5 * time.Second):
+		t.Fatal("MQTT message not republished on NATS")
+	}
+}
+
+func TestTenantSkipPaths(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Web.Auth.SkipPaths = []string{"/health/live"}
+	assert.Equal(t, []string{"/health/live"}, tenantSkipPaths(cfg))
+
+	cfg.Tenancy.SkipPaths = []string{"/public"}
+	assert.Equal(t, []string{"/public"}, tenantSkipPaths(cfg))
+
+	cfg.Tenancy.SkipPaths = []string{}
+	assert.Empty(t, tenantSkipPaths(cfg))
+}
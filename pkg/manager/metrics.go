@@ -0,0 +1,71 @@
+package manager
+
+import (
+	"time"
+
+	grpcserver "grouter/pkg/grpc"
+	"grouter/pkg/web"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// managerCollector reports the uptime and registered services of a manager,
+// computed at scrape time
+type managerCollector struct {
+	m       *ServiceManager
+	started time.Time
+
+	uptime   *prometheus.Desc
+	start    *prometheus.Desc
+	services *prometheus.Desc
+}
+
+func newManagerCollector(m *ServiceManager) *managerCollector {
+	return &managerCollector{
+		m:       m,
+		started: time.Now(),
+		uptime: prometheus.NewDesc("grouter_uptime_seconds",
+			"Seconds since the service manager was created", nil, nil),
+		start: prometheus.NewDesc("grouter_start_time_seconds",
+			"Unix time the service manager was created", nil, nil),
+		services: prometheus.NewDesc("grouter_services",
+			"Registered services by capability (all, nats, web, grpc, gateway)", []string{"capability"}, nil),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *managerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.uptime
+	ch <- c.start
+	ch <- c.services
+}
+
+// Collect implements prometheus.Collector
+func (c *managerCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(c.uptime, prometheus.GaugeValue, time.Since(c.started).Seconds())
+	ch <- prometheus.MustNewConstMetric(c.start, prometheus.GaugeValue, float64(c.started.UnixNano())/1e9)
+
+	counts := map[string]int{"all": 0, "nats": 0, "web": 0, "grpc": 0, "gateway": 0}
+	for _, name := range c.m.ListServices() {
+		svc, ok := c.m.GetService(name)
+		if !ok {
+			continue
+		}
+		counts["all"]++
+		if _, ok := svc.(NATService); ok {
+			counts["nats"]++
+		}
+		if _, ok := svc.(web.WebService); ok {
+			counts["web"]++
+		}
+		if _, ok := svc.(grpcserver.GRPCService); ok {
+			counts["grpc"]++
+		}
+		if _, ok := svc.(grpcserver.GatewayService); ok {
+			counts["gateway"]++
+		}
+	}
+	for capability, n := range counts {
+		ch <- prometheus.MustNewConstMetric(c.services, prometheus.GaugeValue, float64(n), capability)
+	}
+}
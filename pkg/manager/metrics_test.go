@@ -0,0 +1,38 @@
+package manager
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceManager_RuntimeMetrics(t *testing.T) {
+	mgr := NewServiceManager()
+	require.NoError(t, mgr.RegisterService(&mockService{name: "orders"}))
+	require.NoError(t, mgr.RegisterService(&routeService{mockService{name: "pages"}}))
+
+	expected := `
+# HELP grouter_services Registered services by capability (all, nats, web, grpc, gateway)
+# TYPE grouter_services gauge
+grouter_services{capability="all"} 2
+grouter_services{capability="gateway"} 0
+grouter_services{capability="grpc"} 0
+grouter_services{capability="nats"} 2
+grouter_services{capability="web"} 1
+`
+	assert.NoError(t, testutil.GatherAndCompare(mgr.Metrics().Gatherer(), strings.NewReader(expected), "grouter_services"))
+
+	count, err := testutil.GatherAndCount(mgr.Metrics().Gatherer(), "grouter_uptime_seconds", "grouter_start_time_seconds")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	mgr.UnregisterService("pages")
+	assert.NoError(t, testutil.GatherAndCompare(mgr.Metrics().Gatherer(), strings.NewReader(strings.NewReplacer(
+		`capability="all"} 2`, `capability="all"} 1`,
+		`capability="nats"} 2`, `capability="nats"} 1`,
+		`capability="web"} 1`, `capability="web"} 0`,
+	).Replace(expected)), "grouter_services"))
+}
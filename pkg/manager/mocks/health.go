@@ -0,0 +1,75 @@
+package mocks
+
+import (
+	"sync"
+
+	"grouter/pkg/health"
+)
+
+// Check is a scriptable health check counting its runs
+type Check struct {
+	mu    sync.Mutex
+	err   error
+	calls int
+}
+
+// NewCheck creates a passing check
+func NewCheck() *Check {
+	return &Check{}
+}
+
+// FailWith makes the check fail with err, nil to pass again
+func (c *Check) FailWith(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err = err
+}
+
+// Calls returns the number of runs of the check
+func (c *Check) Calls() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls
+}
+
+// Func returns the check as a health.HealthChecker
+func (c *Check) Func() health.HealthChecker {
+	return func() error {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.calls++
+		return c.err
+	}
+}
+
+// HealthService is a health.HealthService with scriptable checks
+type HealthService struct {
+	*health.HealthService
+}
+
+// NewHealthService creates a health service without background refresh or
+// caching, so that every probe runs the checks
+func NewHealthService() *HealthService {
+	return &HealthService{HealthService: health.NewHealthService()}
+}
+
+// Readiness adds a readiness check named name and returns it
+func (h *HealthService) Readiness(name string) *Check {
+	c := NewCheck()
+	h.AddReadinessCheck(name, c.Func())
+	return c
+}
+
+// Liveness adds a liveness check named name and returns it
+func (h *HealthService) Liveness(name string) *Check {
+	c := NewCheck()
+	h.AddLivenessCheck(name, c.Func())
+	return c
+}
+
+// Startup adds a startup check named name and returns it
+func (h *HealthService) Startup(name string) *Check {
+	c := NewCheck()
+	h.AddStartupCheck(name, c.Func())
+	return c
+}
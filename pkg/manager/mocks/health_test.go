@@ -0,0 +1,26 @@
+package mocks
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthService(t *testing.T) {
+	h := NewHealthService()
+	db := h.Readiness("database")
+	h.Liveness("loop")
+
+	status, err := h.CheckReadiness()
+	assert.NoError(t, err)
+	assert.Equal(t, "OK", status["database"])
+
+	db.FailWith(errors.New("down"))
+	_, err = h.CheckReadiness()
+	assert.Error(t, err)
+	assert.Equal(t, 2, db.Calls())
+
+	_, err = h.CheckLiveness()
+	assert.NoError(t, err)
+}
@@ -0,0 +1,132 @@
+// Package mocks provides fakes of the manager services and health checks for
+// unit tests. They record the calls and return the scripted errors. The
+// messaging fakes live in grouter/pkg/messaging/nats/mocks.
+package mocks
+
+import (
+	"context"
+	"sync"
+
+	"grouter/pkg/manager"
+	messaging "grouter/pkg/messaging/nats"
+)
+
+// Service calls, as recorded by Service
+const (
+	CallInit   = "init"
+	CallStart  = "start"
+	CallStop   = "stop"
+	CallHealth = "health"
+	CallHandle = "handle"
+)
+
+// Handled is a message handled by Service
+type Handled struct {
+	Topic    string
+	Envelope *messaging.MessageEnvelope
+}
+
+// Service is a fake manager.ServiceV2 and manager.NATSService recording its
+// lifecycle calls and handled messages
+type Service struct {
+	name     string
+	subjects []manager.SubjectSpec
+
+	mu      sync.Mutex
+	calls   []string
+	handled []Handled
+	errs    map[string]error
+	handle  func(ctx context.Context, topic string, env *messaging.MessageEnvelope) error
+	deps    manager.Deps
+}
+
+var (
+	_ manager.ServiceV2   = (*Service)(nil)
+	_ manager.NATSService = (*Service)(nil)
+)
+
+// NewService creates a fake service subscribing to subjects, if any
+func NewService(name string, subjects ...manager.SubjectSpec) *Service {
+	return &Service{name: name, subjects: subjects, errs: make(map[string]error)}
+}
+
+// FailOn makes call (CallInit, CallStart, ...) return err, nil to succeed
+// again
+func (s *Service) FailOn(call string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errs[call] = err
+}
+
+// OnHandle handles the messages with fn, after recording them
+func (s *Service) OnHandle(fn func(ctx context.Context, topic string, env *messaging.MessageEnvelope) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handle = fn
+}
+
+// Calls returns the calls in order
+func (s *Service) Calls() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.calls...)
+}
+
+// Handled returns the handled messages in order
+func (s *Service) Handled() []Handled {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Handled{}, s.handled...)
+}
+
+// Deps returns the dependencies given to Init
+func (s *Service) Deps() manager.Deps {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.deps
+}
+
+func (s *Service) call(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, name)
+	return s.errs[name]
+}
+
+// Name returns the name of the service
+func (s *Service) Name() string { return s.name }
+
+// Subjects returns the subscriptions of the service
+func (s *Service) Subjects() []manager.SubjectSpec { return s.subjects }
+
+// Init records the call and deps
+func (s *Service) Init(ctx context.Context, deps manager.Deps) error {
+	s.mu.Lock()
+	s.deps = deps
+	s.mu.Unlock()
+	return s.call(CallInit)
+}
+
+// Start records the call
+func (s *Service) Start(ctx context.Context) error { return s.call(CallStart) }
+
+// Stop records the call
+func (s *Service) Stop(ctx context.Context) error { return s.call(CallStop) }
+
+// Health records the call
+func (s *Service) Health(ctx context.Context) error { return s.call(CallHealth) }
+
+// Handle records the message and passes it to the OnHandle function
+func (s *Service) Handle(ctx context.Context, topic string, env *messaging.MessageEnvelope) error {
+	s.mu.Lock()
+	s.handled = append(s.handled, Handled{Topic: topic, Envelope: env})
+	fn := s.handle
+	s.mu.Unlock()
+	if err := s.call(CallHandle); err != nil {
+		return err
+	}
+	if fn != nil {
+		return fn(ctx, topic, env)
+	}
+	return nil
+}
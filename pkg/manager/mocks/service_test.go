@@ -0,0 +1,71 @@
+package mocks
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"grouter/pkg/config"
+	"grouter/pkg/manager"
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestService_Records(t *testing.T) {
+	s := NewService("orders", manager.SubjectSpec{Subject: "orders.>"})
+	ctx := context.Background()
+	logger := zap.NewNop()
+
+	require.NoError(t, s.Init(ctx, manager.Deps{Logger: logger}))
+	require.NoError(t, s.Start(ctx))
+	require.NoError(t, s.Handle(ctx, "orders.create", &messaging.MessageEnvelope{ID: "1"}))
+	require.NoError(t, s.Health(ctx))
+	require.NoError(t, s.Stop(ctx))
+
+	assert.Equal(t, "orders", s.Name())
+	assert.Equal(t, "orders.>", s.Subjects()[0].Subject)
+	assert.Same(t, logger, s.Deps().Logger)
+	assert.Equal(t, []string{CallInit, CallStart, CallHandle, CallHealth, CallStop}, s.Calls())
+	require.Len(t, s.Handled(), 1)
+	assert.Equal(t, "1", s.Handled()[0].Envelope.ID)
+}
+
+func TestService_Scripted(t *testing.T) {
+	s := NewService("orders")
+	ctx := context.Background()
+	boom := errors.New("boom")
+
+	s.FailOn(CallStart, boom)
+	assert.ErrorIs(t, s.Start(ctx), boom)
+	s.FailOn(CallStart, nil)
+	assert.NoError(t, s.Start(ctx))
+
+	s.OnHandle(func(ctx context.Context, topic string, env *messaging.MessageEnvelope) error {
+		return boom
+	})
+	assert.ErrorIs(t, s.Handle(ctx, "orders.create", &messaging.MessageEnvelope{}), boom)
+	assert.Len(t, s.Handled(), 1, "failed messages are recorded")
+}
+
+func TestService_Manager(t *testing.T) {
+	m, err := manager.New(manager.WithConfig(testConfig()), manager.WithLogger(zap.NewNop()))
+	require.NoError(t, err)
+	s := NewService("orders")
+	require.NoError(t, m.RegisterService(s))
+
+	ctx := context.Background()
+	require.NoError(t, m.Start(ctx))
+	require.NoError(t, m.Stop(ctx))
+	assert.Equal(t, []string{CallInit, CallStart, CallStop}, s.Calls())
+}
+
+func testConfig() *config.Config {
+	cfg := config.Default()
+	cfg.App.Name = "mocks"
+	cfg.NATS.Enabled = false
+	cfg.Web.Enabled = false
+	return cfg
+}
@@ -0,0 +1,97 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+
+	"grouter/pkg/config"
+	"grouter/pkg/health"
+	messaging "grouter/pkg/messaging/nats"
+
+	"go.uber.org/zap"
+)
+
+// Option configures a ServiceManager created with New
+type Option func(*ServiceManager)
+
+// WithConfig uses cfg instead of loading the configuration. It is validated
+// by Init and not watched for changes.
+func WithConfig(cfg *config.Config) Option {
+	return func(m *ServiceManager) {
+		m.cfg = cfg
+		m.loader = nil
+	}
+}
+
+// WithLoader loads and watches the configuration with l instead of a loader
+// reading the command line
+func WithLoader(l *config.Loader) Option {
+	return func(m *ServiceManager) {
+		m.loader = l
+		m.cfg = nil
+	}
+}
+
+// WithLogger logs to log instead of a logger built from the log settings.
+// Changes of the log level are then left to the caller.
+func WithLogger(log *zap.Logger) Option {
+	return func(m *ServiceManager) {
+		m.log = log
+	}
+}
+
+// WithMessagingDriver routes messages through msg, a connected messenger,
+// instead of connecting to the NATS server of the configuration. NATS is
+// then enabled whatever nats.enabled says.
+func WithMessagingDriver(msg *messaging.Messenger) Option {
+	return func(m *ServiceManager) {
+		m.messenger = msg
+	}
+}
+
+// WithDatabase makes readiness depend on db and closes it on Stop
+func WithDatabase(db Database) Option {
+	return func(m *ServiceManager) {
+		m.db = db
+	}
+}
+
+// WithHealth registers the checks of the manager on h instead of a health
+// service built from the health settings
+func WithHealth(h *health.HealthService) Option {
+	return func(m *ServiceManager) {
+		m.health = h
+	}
+}
+
+// New creates a manager and initializes its components in order: config,
+// logger, telemetry, health, database, RBAC, cache, NATS, web server and
+// gRPC server, then registers the services of the plugins. The returned
+// manager is ready for RegisterService and Start.
+// On error, the components already initialized are stopped.
+func New(opts ...Option) (*ServiceManager, error) {
+	m := NewServiceManager()
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	steps := []func() error{
+		m.Init,
+		m.InitCache,
+		m.InitNATS,
+		m.InitWebServer,
+		m.InitGRPCServer,
+		m.LoadPlugins,
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			if m.log != nil {
+				ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+				_ = m.Stop(ctx)
+				cancel()
+			}
+			return nil, fmt.Errorf("failed to initialize service manager: %w", err)
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,152 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"grouter/pkg/config"
+	"grouter/pkg/health"
+	messaging "grouter/pkg/messaging/nats"
+	"grouter/pkg/messaging/nats/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeDatabase is a Database whose ping fails with err
+type fakeDatabase struct {
+	err    error
+	closed bool
+}
+
+func (d *fakeDatabase) HealthCheck(ctx context.Context) error { return d.err }
+
+func (d *fakeDatabase) Close() error {
+	d.closed = true
+	return nil
+}
+
+// testConfig is the default configuration without listeners
+func testConfig() *config.Config {
+	cfg := config.Default()
+	cfg.App.Name = "grouter"
+	cfg.Metrics.Enabled = false
+	return cfg
+}
+
+func TestNew(t *testing.T) {
+	cfg := testConfig()
+	log := zap.NewNop()
+	h := health.NewHealthService()
+	db := &fakeDatabase{}
+
+	mgr, err := New(WithConfig(cfg), WithLogger(log), WithHealth(h), WithDatabase(db))
+	require.NoError(t, err)
+	assert.Same(t, cfg, mgr.Config())
+	assert.Same(t, log, mgr.Logger())
+	assert.Same(t, h, mgr.Health())
+	assert.Same(t, db, mgr.Database())
+	assert.Nil(t, mgr.Messenger(), "NATS is disabled")
+	assert.Nil(t, mgr.WebServer(), "the web server is disabled")
+
+	_, err = h.CheckReadiness()
+	assert.NoError(t, err)
+	db.err = errors.New("connection refused")
+	_, err = h.CheckReadiness()
+	assert.Error(t, err, "readiness depends on the database")
+
+	require.NoError(t, mgr.Start(context.Background()))
+	require.NoError(t, mgr.Stop(context.Background()))
+	assert.True(t, db.closed)
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	cfg := testConfig()
+	cfg.App.Name = ""
+	db := &fakeDatabase{}
+
+	_, err := New(WithConfig(cfg), WithLogger(zap.NewNop()), WithDatabase(db))
+	var verr *config.ValidationError
+	require.ErrorAs(t, err, &verr)
+	assert.Equal(t, "app.name", verr.Errors[0].Field)
+}
+
+// checkedPublisher is the publisher of a driver reporting its connection
+type checkedPublisher struct {
+	*mocks.Publisher
+	err error
+}
+
+func (p *checkedPublisher) HealthCheck() error { return p.err }
+
+func TestNew_WithMessagingDriver_NoClient(t *testing.T) {
+	_, pub, sub := mocks.NewMessenger()
+	checked := &checkedPublisher{Publisher: pub, err: errors.New("connection closed")}
+	msg := messaging.NewMessenger(nil, checked, sub)
+
+	mgr, err := New(WithConfig(testConfig()), WithLogger(zap.NewNop()), WithMessagingDriver(msg))
+	require.NoError(t, err)
+	checks, err := mgr.Health().CheckReadiness()
+	assert.Error(t, err, "the driver connection makes readiness")
+	assert.Contains(t, checks, "messaging")
+	assert.NotContains(t, checks, "nats")
+
+	checked.err = nil
+	_, err = mgr.Health().CheckReadiness()
+	assert.NoError(t, err)
+	require.NoError(t, mgr.Stop(context.Background()))
+}
+
+func TestNew_MessagingDriverConfig(t *testing.T) {
+	_, pub, sub := mocks.NewMessenger()
+	checked := &checkedPublisher{Publisher: pub}
+	var decoded struct {
+		URL string `mapstructure:"url"`
+	}
+	messaging.RegisterDriver("manager-test", func(decode func(cfg any) error, logger *zap.Logger, source string) (*messaging.Messenger, error) {
+		if err := decode(&decoded); err != nil {
+			return nil, err
+		}
+		return messaging.NewMessenger(nil, checked, sub), nil
+	})
+
+	cfg := testConfig()
+	cfg.Messaging = config.MessagingConfig{
+		Driver:  "manager-test",
+		Drivers: config.MessagingDrivers{"manager-test": map[string]any{"url": "test://broker"}},
+	}
+	mgr, err := New(WithConfig(cfg), WithLogger(zap.NewNop()))
+	require.NoError(t, err)
+	assert.Equal(t, "test://broker", decoded.URL, "the driver gets its settings")
+	assert.Same(t, checked, mgr.Messenger().Publisher)
+	checks, err := mgr.Health().CheckReadiness()
+	require.NoError(t, err)
+	assert.Contains(t, checks, "messaging")
+	require.NoError(t, mgr.Stop(context.Background()))
+
+	cfg.Messaging.Driver = "unknown"
+	_, err = New(WithConfig(cfg), WithLogger(zap.NewNop()))
+	assert.ErrorContains(t, err, `messaging driver "unknown" is not registered`)
+}
+
+func TestNew_WithMessagingDriver(t *testing.T) {
+	s := runNATSServer(t, false)
+	msg := &messaging.Messenger{}
+	require.NoError(t, msg.Init(messaging.Config{URL: s.ClientURL(), ConnectionTimeout: 2 * time.Second}, zap.NewNop(), "grouter"))
+
+	// nats.enabled is false, the given messenger enables NATS
+	mgr, err := New(WithConfig(testConfig()), WithLogger(zap.NewNop()), WithMessagingDriver(msg))
+	require.NoError(t, err)
+	assert.Same(t, msg, mgr.Messenger())
+	require.NoError(t, mgr.SubscribeToTopics("grouter.>", ""))
+
+	report := requestHealth(t, mgr, health.ProbeReady)
+	assert.Equal(t, health.StatusUp, report.Status)
+	assert.Equal(t, health.StatusUp, report.Readiness["nats"].Status)
+
+	require.NoError(t, mgr.Stop(context.Background()))
+	assert.False(t, msg.IsConnected(), "Stop closes the messenger")
+}
@@ -0,0 +1,94 @@
+package manager
+
+import (
+	"time"
+
+	"grouter/pkg/telemetry"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Names of the message pipeline metrics, also used by PipelineDashboard
+const (
+	metricMessagesRouted  = "grouter_messages_routed_total"
+	metricRoutingFailures = "grouter_routing_failures_total"
+	metricHandlerDuration = "grouter_handler_duration_seconds"
+	metricHandlerErrors   = "grouter_handler_errors_total"
+	metricErrorReplies    = "grouter_error_replies_total"
+)
+
+// Reasons of the routing failures
+const (
+	// routeUnknownService is a message type naming no registered service
+	routeUnknownService = "unknown_service"
+	// routeInvalidSubject is a subject outside the tenant subjects, or of
+	// another tenant than the message
+	routeInvalidSubject = "invalid_subject"
+	// routeNotNATS is a service without a NATS handler
+	routeNotNATS = "not_nats"
+)
+
+// pipelineMetrics count the messages delivered to the services by the
+// manager, labelled by service and message type. A nil pipelineMetrics
+// records nothing.
+type pipelineMetrics struct {
+	routed   *prometheus.CounterVec
+	failures *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+	replies  *prometheus.CounterVec
+}
+
+func newPipelineMetrics(reg *telemetry.MetricsRegistry) *pipelineMetrics {
+	return &pipelineMetrics{
+		routed: reg.CounterVec(prometheus.CounterOpts{
+			Name: metricMessagesRouted,
+			Help: "Total number of messages delivered to a service",
+		}, []string{"service", "type"}),
+		failures: reg.CounterVec(prometheus.CounterOpts{
+			Name: metricRoutingFailures,
+			Help: "Total number of messages no service could be found for, by reason",
+		}, []string{"reason"}),
+		duration: reg.HistogramVec(prometheus.HistogramOpts{
+			Name:    metricHandlerDuration,
+			Help:    "Duration of the message handlers of the services in seconds",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"service", "type"}),
+		errors: reg.CounterVec(prometheus.CounterOpts{
+			Name: metricHandlerErrors,
+			Help: "Total number of messages the handler of a service failed",
+		}, []string{"service", "type"}),
+		replies: reg.CounterVec(prometheus.CounterOpts{
+			Name: metricErrorReplies,
+			Help: "Total number of error replies sent for failed requests",
+		}, []string{"service", "type"}),
+	}
+}
+
+// handled records a message of type typ handled by service since start
+func (p *pipelineMetrics) handled(service, typ string, start time.Time, err error) {
+	if p == nil {
+		return
+	}
+	p.routed.WithLabelValues(service, typ).Inc()
+	p.duration.WithLabelValues(service, typ).Observe(time.Since(start).Seconds())
+	if err != nil {
+		p.errors.WithLabelValues(service, typ).Inc()
+	}
+}
+
+// unrouted records a message no service could be found for
+func (p *pipelineMetrics) unrouted(reason string) {
+	if p == nil {
+		return
+	}
+	p.failures.WithLabelValues(reason).Inc()
+}
+
+// errorReply records an error reply to a request of type typ for service
+func (p *pipelineMetrics) errorReply(service, typ string) {
+	if p == nil {
+		return
+	}
+	p.replies.WithLabelValues(service, typ).Inc()
+}
@@ -0,0 +1,56 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"grouter/pkg/config"
+	messaging "grouter/pkg/messaging/nats"
+	"grouter/pkg/messaging/nats/mocks"
+	"grouter/pkg/telemetry"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestServiceManager_PipelineMetrics(t *testing.T) {
+	reg := telemetry.NewMetricsRegistry()
+	router := NewServiceRouter()
+	router.Register("orders", &mockService{name: "orders"})
+	router.Register("error", &errorService{mockService{name: "error"}})
+	mgr := &ServiceManager{
+		log:       zap.NewNop(),
+		router:    router,
+		messenger: &messaging.Messenger{Publisher: mocks.NewPublisher()},
+		timeout:   time.Second,
+		cfg:       &config.Config{App: config.AppConfig{Name: "grouter"}},
+		pipeline:  newPipelineMetrics(reg),
+	}
+	metrics := mgr.pipeline
+	ctx := context.Background()
+
+	for range 2 {
+		assert.NoError(t, mgr.onNATSMessage(ctx, "grouter.orders.create", &messaging.MessageEnvelope{ID: "1", Type: "orders.create"}))
+	}
+	assert.Equal(t, float64(2), testutil.ToFloat64(metrics.routed.WithLabelValues("orders", "orders.create")))
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.duration, metricHandlerDuration))
+
+	assert.NoError(t, mgr.onNATSMessage(ctx, "grouter.unknown.op", &messaging.MessageEnvelope{ID: "2", Type: "unknown.op"}))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.failures.WithLabelValues(routeUnknownService)))
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.routed), "unrouted messages are not routed")
+
+	// Failed requests count as routed, failed and replied to
+	assert.NoError(t, mgr.onNATSMessage(ctx, "grouter.error.op", &messaging.MessageEnvelope{ID: "3", Type: "error.op", Reply: "inbox.3"}))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.routed.WithLabelValues("error", "error.op")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.errors.WithLabelValues("error", "error.op")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.replies.WithLabelValues("error", "error.op")))
+}
+
+func TestPipelineMetrics_Nil(t *testing.T) {
+	var p *pipelineMetrics
+	p.handled("orders", "orders.create", time.Now(), nil)
+	p.unrouted(routeUnknownService)
+	p.errorReply("orders", "orders.create")
+}
@@ -0,0 +1,261 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"plugin"
+	"sort"
+	"strings"
+	"sync"
+
+	"grouter/pkg/config"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// PluginAPIVersion is the version of the plugin handshake. Plugins and
+// manifests of another version are refused, as they were built against an
+// incompatible manager.
+const PluginAPIVersion = 1
+
+// PluginSymbol is the name of the *Plugin exported by the Go plugins
+const PluginSymbol = "GRouterPlugin"
+
+// ServiceFactory creates a service of a plugin. The manager then registers
+// it, calling Init with the Deps of a ServiceV2.
+type ServiceFactory func() (Service, error)
+
+// Plugin describes the services of a Go plugin, built with
+// -buildmode=plugin and exporting it as:
+//
+//	var GRouterPlugin = &manager.Plugin{
+//		APIVersion: manager.PluginAPIVersion,
+//		Name:       "billing",
+//		Services:   map[string]manager.ServiceFactory{"billing": NewBillingService},
+//	}
+type Plugin struct {
+	APIVersion int
+	Name       string
+	Version    string
+	// Services are the factories of the services, by name
+	Services map[string]ServiceFactory
+}
+
+// factories are the service factories linked into the binary, enabled by
+// name from a manifest
+var factories = struct {
+	sync.RWMutex
+	m map[string]ServiceFactory
+}{m: make(map[string]ServiceFactory)}
+
+// RegisterFactory makes the factory of an optional module linked into the
+// binary available to manifests under name, typically from an init
+// function. Registering a name again replaces it.
+func RegisterFactory(name string, f ServiceFactory) {
+	factories.Lock()
+	defer factories.Unlock()
+	factories.m[name] = f
+}
+
+func registeredFactory(name string) (ServiceFactory, bool) {
+	factories.RLock()
+	defer factories.RUnlock()
+	f, ok := factories.m[name]
+	return f, ok
+}
+
+// PluginManifest lists the services to load, from plugins or linked into
+// the binary, in YAML or JSON
+type PluginManifest struct {
+	APIVersion int                   `mapstructure:"api_version"`
+	Services   []PluginManifestEntry `mapstructure:"services"`
+}
+
+// PluginManifestEntry is a service of a manifest: the factory Name of the
+// plugin file Plugin, relative to the plugins directory, or of
+// RegisterFactory without Plugin
+type PluginManifestEntry struct {
+	Name     string `mapstructure:"name"`
+	Plugin   string `mapstructure:"plugin"`
+	Disabled bool   `mapstructure:"disabled"`
+}
+
+// symbolLookup is the part of *plugin.Plugin the loader uses
+type symbolLookup interface {
+	Lookup(name string) (plugin.Symbol, error)
+}
+
+// PluginLoader creates the services of the Go plugins of a directory, or of
+// the entries of a manifest
+type PluginLoader struct {
+	cfg config.PluginsConfig
+	log *zap.Logger
+	// open opens a plugin file; plugin.Open outside tests
+	open    func(path string) (symbolLookup, error)
+	plugins map[string]*Plugin
+}
+
+// NewPluginLoader creates a loader of the plugins of cfg
+func NewPluginLoader(cfg config.PluginsConfig, log *zap.Logger) *PluginLoader {
+	if log == nil {
+		log = zap.NewNop()
+	}
+	return &PluginLoader{
+		cfg:     cfg,
+		log:     log,
+		open:    func(path string) (symbolLookup, error) { return plugin.Open(path) },
+		plugins: make(map[string]*Plugin),
+	}
+}
+
+// Load creates the services of the manifest when there is one, else those
+// of every plugin (*.so) of the directory, in the order of the files and of
+// the service names
+func (l *PluginLoader) Load() ([]Service, error) {
+	if l.cfg.Manifest != "" {
+		return l.loadManifest()
+	}
+	paths, err := filepath.Glob(filepath.Join(l.cfg.Dir, "*.so"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan plugins directory: %w", err)
+	}
+	sort.Strings(paths)
+	var services []Service
+	for _, path := range paths {
+		p, err := l.plugin(path)
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, 0, len(p.Services))
+		for name := range p.Services {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			svc, err := l.create(name, p.Services[name], path)
+			if err != nil {
+				return nil, err
+			}
+			services = append(services, svc)
+		}
+	}
+	return services, nil
+}
+
+func (l *PluginLoader) loadManifest() ([]Service, error) {
+	v := viper.New()
+	v.SetConfigFile(l.cfg.Manifest)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read plugin manifest: %w", err)
+	}
+	var manifest PluginManifest
+	if err := v.Unmarshal(&manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode plugin manifest: %w", err)
+	}
+	if manifest.APIVersion != PluginAPIVersion {
+		return nil, fmt.Errorf("plugin manifest %s has api_version %d, want %d",
+			l.cfg.Manifest, manifest.APIVersion, PluginAPIVersion)
+	}
+
+	var services []Service
+	for i, entry := range manifest.Services {
+		if entry.Disabled {
+			continue
+		}
+		if entry.Name == "" {
+			return nil, fmt.Errorf("plugin manifest service %d has no name", i)
+		}
+		var (
+			factory ServiceFactory
+			ok      bool
+			source  = "binary"
+		)
+		if entry.Plugin == "" {
+			factory, ok = registeredFactory(entry.Name)
+		} else {
+			source = filepath.Join(l.cfg.Dir, entry.Plugin)
+			p, err := l.plugin(source)
+			if err != nil {
+				return nil, err
+			}
+			factory, ok = p.Services[entry.Name]
+		}
+		if !ok {
+			return nil, fmt.Errorf("no service factory %q in %s", entry.Name, source)
+		}
+		svc, err := l.create(entry.Name, factory, source)
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, svc)
+	}
+	return services, nil
+}
+
+// plugin opens the plugin file path and checks its handshake
+func (l *PluginLoader) plugin(path string) (*Plugin, error) {
+	if p, ok := l.plugins[path]; ok {
+		return p, nil
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("failed to open plugin: %w", err)
+	}
+	lib, err := l.open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open plugin %s: %w", path, err)
+	}
+	sym, err := lib.Lookup(PluginSymbol)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s does not export %s: %w", path, PluginSymbol, err)
+	}
+	p, ok := sym.(*Plugin)
+	if !ok || p == nil {
+		return nil, fmt.Errorf("plugin %s exports %s as %T, want *manager.Plugin", path, PluginSymbol, sym)
+	}
+	if p.APIVersion != PluginAPIVersion {
+		return nil, fmt.Errorf("plugin %s has api version %d, want %d", path, p.APIVersion, PluginAPIVersion)
+	}
+	l.log.Info("Plugin loaded",
+		zap.String("path", path),
+		zap.String("plugin", p.Name),
+		zap.String("version", p.Version),
+	)
+	l.plugins[path] = p
+	return p, nil
+}
+
+// create runs the factory name of source
+func (l *PluginLoader) create(name string, factory ServiceFactory, source string) (Service, error) {
+	if factory == nil {
+		return nil, fmt.Errorf("service factory %q of %s is nil", name, source)
+	}
+	svc, err := factory()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create service %q of %s: %w", name, source, err)
+	}
+	if svc == nil || strings.TrimSpace(svc.Name()) == "" {
+		return nil, fmt.Errorf("service factory %q of %s returned no named service", name, source)
+	}
+	return svc, nil
+}
+
+// LoadPlugins registers the services of the plugins of the config, when
+// plugins are enabled. New runs it last.
+func (m *ServiceManager) LoadPlugins() error {
+	if m.cfg == nil || !m.cfg.Plugins.Enabled {
+		return nil
+	}
+	services, err := NewPluginLoader(m.cfg.Plugins, m.log).Load()
+	if err != nil {
+		return fmt.Errorf("failed to load plugins: %w", err)
+	}
+	for _, svc := range services {
+		if err := m.RegisterService(svc); err != nil {
+			return fmt.Errorf("failed to register plugin service %q: %w", svc.Name(), err)
+		}
+		m.log.Info("Plugin service registered", zap.String("service", svc.Name()))
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package manager
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"plugin"
+	"testing"
+
+	"grouter/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakePlugin is an opened plugin exporting symbols
+type fakePlugin map[string]plugin.Symbol
+
+func (p fakePlugin) Lookup(name string) (plugin.Symbol, error) {
+	sym, ok := p[name]
+	if !ok {
+		return nil, errors.New("symbol not found")
+	}
+	return sym, nil
+}
+
+// newTestLoader returns a loader of cfg opening the plugins of libs by file
+// name, each written to dir
+func newTestLoader(t *testing.T, cfg config.PluginsConfig, libs map[string]fakePlugin) *PluginLoader {
+	t.Helper()
+	for name := range libs {
+		require.NoError(t, os.WriteFile(filepath.Join(cfg.Dir, name), nil, 0o644))
+	}
+	l := NewPluginLoader(cfg, nil)
+	l.open = func(path string) (symbolLookup, error) {
+		return libs[filepath.Base(path)], nil
+	}
+	return l
+}
+
+func factory(name string) ServiceFactory {
+	return func() (Service, error) { return &mockService{name: name}, nil }
+}
+
+func serviceNames(services []Service) []string {
+	names := make([]string, 0, len(services))
+	for _, svc := range services {
+		names = append(names, svc.Name())
+	}
+	return names
+}
+
+func TestPluginLoader_Dir(t *testing.T) {
+	dir := t.TempDir()
+	l := newTestLoader(t, config.PluginsConfig{Dir: dir}, map[string]fakePlugin{
+		"billing.so": {PluginSymbol: &Plugin{APIVersion: PluginAPIVersion, Name: "billing", Services: map[string]ServiceFactory{
+			"invoices": factory("invoices"),
+			"billing":  factory("billing"),
+		}}},
+		"audit.so": {PluginSymbol: &Plugin{APIVersion: PluginAPIVersion, Name: "audit", Services: map[string]ServiceFactory{
+			"audit": factory("audit"),
+		}}},
+	})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), nil, 0o644))
+
+	services, err := l.Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"audit", "billing", "invoices"}, serviceNames(services))
+}
+
+func TestPluginLoader_Handshake(t *testing.T) {
+	for name, lib := range map[string]fakePlugin{
+		"api version":   {PluginSymbol: &Plugin{APIVersion: PluginAPIVersion + 1}},
+		"no symbol":     {},
+		"symbol type":   {PluginSymbol: &struct{}{}},
+		"nil factory":   {PluginSymbol: &Plugin{APIVersion: PluginAPIVersion, Services: map[string]ServiceFactory{"x": nil}}},
+		"factory error": {PluginSymbol: &Plugin{APIVersion: PluginAPIVersion, Services: map[string]ServiceFactory{"x": func() (Service, error) { return nil, errors.New("boom") }}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			l := newTestLoader(t, config.PluginsConfig{Dir: t.TempDir()}, map[string]fakePlugin{"x.so": lib})
+			_, err := l.Load()
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestPluginLoader_Manifest(t *testing.T) {
+	dir := t.TempDir()
+	RegisterFactory("test-linked", factory("linked"))
+	l := newTestLoader(t, config.PluginsConfig{Dir: dir, Manifest: filepath.Join(dir, "plugins.yaml")}, map[string]fakePlugin{
+		"billing.so": {PluginSymbol: &Plugin{APIVersion: PluginAPIVersion, Services: map[string]ServiceFactory{
+			"billing":  factory("billing"),
+			"invoices": factory("invoices"),
+		}}},
+	})
+
+	write := func(manifest string) {
+		require.NoError(t, os.WriteFile(l.cfg.Manifest, []byte(manifest), 0o644))
+	}
+	write(`
+api_version: 1
+services:
+  - name: invoices
+    plugin: billing.so
+  - name: billing
+    plugin: billing.so
+    disabled: true
+  - name: test-linked
+`)
+	services, err := l.Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"invoices", "linked"}, serviceNames(services))
+
+	write("api_version: 2\nservices: []\n")
+	_, err = l.Load()
+	assert.ErrorContains(t, err, "api_version 2")
+
+	write("api_version: 1\nservices:\n  - name: missing\n")
+	_, err = l.Load()
+	assert.ErrorContains(t, err, `no service factory "missing"`)
+
+	write("api_version: 1\nservices:\n  - name: x\n    plugin: missing.so\n")
+	_, err = l.Load()
+	assert.ErrorContains(t, err, "failed to open plugin")
+}
+
+func TestServiceManager_LoadPlugins(t *testing.T) {
+	dir := t.TempDir()
+	RegisterFactory("test-orders", factory("orders"))
+	manifest := filepath.Join(dir, "plugins.json")
+	require.NoError(t, os.WriteFile(manifest, []byte(`{"api_version": 1, "services": [{"name": "test-orders"}]}`), 0o644))
+
+	mgr := NewServiceManager()
+	mgr.log = zap.NewNop()
+	mgr.cfg = &config.Config{}
+	require.NoError(t, mgr.LoadPlugins(), "plugins disabled")
+	assert.Empty(t, mgr.ListServices())
+
+	mgr.cfg.Plugins = config.PluginsConfig{Enabled: true, Manifest: manifest}
+	require.NoError(t, mgr.LoadPlugins())
+	_, ok := mgr.GetService("orders")
+	assert.True(t, ok)
+}
@@ -0,0 +1,131 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// LeavingEventType is the message type of the event an instance publishes
+// before draining its queue groups
+const LeavingEventType = "instance.leaving"
+
+// LeavingEvent announces that an instance stops taking the messages of its
+// queue groups, by Deadline at the latest
+type LeavingEvent struct {
+	App         string    `json:"app"`
+	Instance    string    `json:"instance"`
+	QueueGroups []string  `json:"queue_groups"`
+	Deadline    time.Time `json:"deadline"`
+}
+
+// preStopPoll is the interval at which preStop checks the handlers in flight
+const preStopPoll = 10 * time.Millisecond
+
+// leavingSubject returns the subject of the leaving events,
+// "<app>.instance.leaving"
+func (m *ServiceManager) leavingSubject() string {
+	return m.cfg.App.Name + ".instance.leaving"
+}
+
+// queueGroupSubscriptions returns the subscriptions in a queue group
+func (m *ServiceManager) queueGroupSubscriptions() []*trackedSubscription {
+	m.subjectsMu.Lock()
+	defer m.subjectsMu.Unlock()
+	var subs []*trackedSubscription
+	for _, entry := range m.subjects {
+		for _, t := range entry.subs {
+			if t.spec.QueueGroup != "" {
+				subs = append(subs, t)
+			}
+		}
+	}
+	return subs
+}
+
+// preStop drains the queue group subscriptions, so that the other members of
+// the groups take over the new messages while this instance handles those it
+// received, and waits for their handlers, for up to nats.pre_stop.grace.
+// With announce, a LeavingEvent is published first.
+func (m *ServiceManager) preStop(ctx context.Context) {
+	if m.cfg == nil || !m.cfg.NATS.PreStop.Enabled || m.messenger == nil {
+		return
+	}
+	subs := m.queueGroupSubscriptions()
+	if len(subs) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, m.cfg.NATS.PreStop.Grace)
+	defer cancel()
+
+	if m.cfg.NATS.PreStop.Announce {
+		m.announceLeaving(ctx, subs)
+	}
+
+	start := time.Now()
+	errs := make([]error, len(subs))
+	var wg sync.WaitGroup
+	for i, t := range subs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = t.sub.Drain(ctx)
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		m.log.Warn("Queue groups not drained within the grace period", zap.Error(err))
+		return
+	}
+
+	// Drain returns once the messages are handed to the handlers
+	ticker := time.NewTicker(preStopPoll)
+	defer ticker.Stop()
+	for inFlight(subs) > 0 {
+		select {
+		case <-ctx.Done():
+			m.log.Warn("Queue group handlers still running after the grace period",
+				zap.Int64("in_flight", inFlight(subs)))
+			return
+		case <-ticker.C:
+		}
+	}
+	m.log.Info("Queue groups drained",
+		zap.Int("subscriptions", len(subs)),
+		zap.Duration("duration", time.Since(start)))
+}
+
+// announceLeaving publishes the LeavingEvent of the queue groups of subs
+func (m *ServiceManager) announceLeaving(ctx context.Context, subs []*trackedSubscription) {
+	seen := make(map[string]bool)
+	event := LeavingEvent{App: m.cfg.App.Name, Instance: m.instanceID(), QueueGroups: []string{}}
+	for _, t := range subs {
+		if !seen[t.spec.QueueGroup] {
+			seen[t.spec.QueueGroup] = true
+			event.QueueGroups = append(event.QueueGroups, t.spec.QueueGroup)
+		}
+	}
+	sort.Strings(event.QueueGroups)
+	event.Deadline, _ = ctx.Deadline()
+
+	if err := m.messenger.Publisher.Publish(ctx, m.leavingSubject(), LeavingEventType, event, nil); err != nil {
+		m.log.Error("Failed to announce leaving", zap.Error(err))
+		return
+	}
+	m.log.Info("Leaving announced",
+		zap.String("subject", m.leavingSubject()),
+		zap.Strings("queue_groups", event.QueueGroups))
+}
+
+// inFlight returns the number of handlers running for subs
+func inFlight(subs []*trackedSubscription) int64 {
+	var n int64
+	for _, t := range subs {
+		n += t.inFlight.Load()
+	}
+	return n
+}
@@ -0,0 +1,116 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"grouter/pkg/config"
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceManager_PreStop(t *testing.T) {
+	s := runNATSServer(t, false)
+	leaving := newNATSManager(t, s, func(c *config.Config) {
+		c.NATS.PreStop = config.NATSPreStop{Enabled: true, Grace: 5 * time.Second, Announce: true}
+	})
+	svc := &blockingService{
+		subjectService: subjectService{name: "orders", subjects: []SubjectSpec{
+			{Subject: "orders.create", QueueGroup: "orders"},
+			{Subject: "orders.audit"},
+		}},
+		started: make(chan struct{}, 1),
+		release: make(chan struct{}),
+	}
+	require.NoError(t, leaving.RegisterService(svc))
+
+	peer := newNATSManager(t, s)
+	events := make(chan *messaging.MessageEnvelope, 1)
+	require.NoError(t, peer.messenger.Subscriber.Subscribe("grouter.instance.leaving", func(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+		events <- env
+		return nil
+	}, nil))
+
+	// The peer joins the group once the leaving instance handles a message
+	ctx := context.Background()
+	require.NoError(t, leaving.messenger.Publisher.Publish(ctx, "orders.create", "orders.create", nil, nil))
+	<-svc.started
+	other := &subjectService{name: "orders", subjects: []SubjectSpec{{Subject: "orders.create", QueueGroup: "orders"}}}
+	require.NoError(t, peer.RegisterService(other))
+
+	done := make(chan struct{})
+	go func() {
+		leaving.preStop(ctx)
+		close(done)
+	}()
+
+	select {
+	case env := <-events:
+		assert.Equal(t, LeavingEventType, env.Type)
+		var event LeavingEvent
+		require.NoError(t, json.Unmarshal(env.Data, &event))
+		assert.Equal(t, "grouter", event.App)
+		assert.Equal(t, "instance-1", event.Instance)
+		assert.Equal(t, []string{"orders"}, event.QueueGroups)
+		assert.WithinDuration(t, time.Now().Add(5*time.Second), event.Deadline, time.Second)
+	case <-time.After(2 * time.Second):
+		t.Fatal("leaving event not published")
+	}
+
+	select {
+	case <-done:
+		t.Fatal("preStop returned with a handler in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(svc.release)
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("preStop did not return")
+	}
+
+	for range 10 {
+		require.NoError(t, peer.messenger.Publisher.Publish(ctx, "orders.create", "orders.create", nil, nil))
+	}
+	require.Eventually(t, func() bool { return other.count() == 10 }, 2*time.Second, 10*time.Millisecond,
+		"the peer takes over the queue group")
+	assert.Equal(t, 1, svc.count())
+
+	// Subscriptions outside queue groups are kept until the services stop
+	require.NoError(t, peer.messenger.Publisher.Publish(ctx, "orders.audit", "orders.audit", nil, nil))
+	<-svc.started
+	require.Eventually(t, func() bool { return svc.count() == 2 }, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestServiceManager_PreStop_Grace(t *testing.T) {
+	mgr := newNATSManager(t, runNATSServer(t, false), func(c *config.Config) {
+		c.NATS.PreStop = config.NATSPreStop{Enabled: true, Grace: 100 * time.Millisecond}
+	})
+	svc := &blockingService{
+		subjectService: subjectService{name: "orders", subjects: []SubjectSpec{{Subject: "orders.create", QueueGroup: "orders"}}},
+		started:        make(chan struct{}, 1),
+		release:        make(chan struct{}),
+	}
+	defer close(svc.release)
+	require.NoError(t, mgr.RegisterService(svc))
+	require.NoError(t, mgr.messenger.Publisher.Publish(context.Background(), "orders.create", "orders.create", nil, nil))
+	<-svc.started
+
+	start := time.Now()
+	mgr.preStop(context.Background())
+	assert.Less(t, time.Since(start), time.Second, "preStop gives up after the grace period")
+}
+
+func TestServiceManager_PreStop_Disabled(t *testing.T) {
+	mgr := newNATSManager(t, runNATSServer(t, false))
+	svc := &subjectService{name: "orders", subjects: []SubjectSpec{{Subject: "orders.create", QueueGroup: "orders"}}}
+	require.NoError(t, mgr.RegisterService(svc))
+
+	mgr.preStop(context.Background())
+	require.NoError(t, mgr.messenger.Publisher.Publish(context.Background(), "orders.create", "orders.create", nil, nil))
+	require.Eventually(t, func() bool { return svc.count() == 1 }, 2*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,47 @@
+package manager
+
+import (
+	"context"
+	"errors"
+
+	messaging "grouter/pkg/messaging/nats"
+)
+
+// ErrQuarantineDisabled is returned when NATS or its quarantine is disabled
+var ErrQuarantineDisabled = errors.New("quarantine disabled")
+
+func (m *ServiceManager) quarantine() (*messaging.Quarantine, error) {
+	if m.messenger == nil || m.messenger.Quarantine() == nil {
+		return nil, ErrQuarantineDisabled
+	}
+	return m.messenger.Quarantine(), nil
+}
+
+// ListQuarantined returns up to limit quarantined messages (all when zero),
+// the oldest first
+func (m *ServiceManager) ListQuarantined(ctx context.Context, limit int) ([]messaging.QuarantinedMessage, error) {
+	q, err := m.quarantine()
+	if err != nil {
+		return nil, err
+	}
+	return q.List(ctx, limit)
+}
+
+// GetQuarantined returns the quarantined message seq
+func (m *ServiceManager) GetQuarantined(ctx context.Context, seq uint64) (messaging.QuarantinedMessage, error) {
+	q, err := m.quarantine()
+	if err != nil {
+		return messaging.QuarantinedMessage{}, err
+	}
+	return q.Get(ctx, seq)
+}
+
+// RequeueQuarantined publishes the quarantined message seq again on its
+// subject and removes it from the quarantine
+func (m *ServiceManager) RequeueQuarantined(ctx context.Context, seq uint64) (messaging.QuarantinedMessage, error) {
+	q, err := m.quarantine()
+	if err != nil {
+		return messaging.QuarantinedMessage{}, err
+	}
+	return q.Requeue(ctx, seq)
+}
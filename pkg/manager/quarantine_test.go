@@ -0,0 +1,70 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"grouter/pkg/config"
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newQuarantineManager returns a manager quarantining the messages of an
+// "ORDERS" stream on their first failure, with one message quarantined
+func newQuarantineManager(t *testing.T) *ServiceManager {
+	t.Helper()
+	mgr := newNATSManager(t, runNATSServer(t, true), func(cfg *config.Config) {
+		cfg.NATS.Quarantine = config.NATSQuarantine{Enabled: true, MaxDeliveries: 1}
+	})
+	js, err := mgr.messenger.Client.JetStream()
+	require.NoError(t, err)
+	_, err = js.AddStream(&nats.StreamConfig{Name: "ORDERS", Subjects: []string{"orders.>"}, Storage: nats.MemoryStorage})
+	require.NoError(t, err)
+
+	handler := func(ctx context.Context, subject string, msg *messaging.MessageEnvelope) error {
+		if msg.Type == "orders.poison" {
+			return assert.AnError
+		}
+		return nil
+	}
+	require.NoError(t, mgr.messenger.Subscriber.SubscribePush("orders.>", handler, nats.Durable("orders")))
+	_, err = mgr.messenger.Publisher.PublishJS(context.Background(), "orders.created", "orders.poison", 1)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		msgs, err := mgr.ListQuarantined(context.Background(), 0)
+		return err == nil && len(msgs) == 1
+	}, 5*time.Second, 20*time.Millisecond)
+	return mgr
+}
+
+func TestServiceManager_Quarantine(t *testing.T) {
+	mgr := newQuarantineManager(t)
+	ctx := context.Background()
+
+	msgs, err := mgr.ListQuarantined(ctx, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "orders.created", msgs[0].Subject)
+	require.Len(t, msgs[0].Errors, 1)
+	assert.Equal(t, assert.AnError.Error(), msgs[0].Errors[0].Error)
+
+	msg, err := mgr.GetQuarantined(ctx, msgs[0].Seq)
+	require.NoError(t, err)
+	assert.Equal(t, msgs[0], msg)
+
+	_, err = mgr.RequeueQuarantined(ctx, msg.Seq)
+	require.NoError(t, err)
+	_, err = mgr.GetQuarantined(ctx, msg.Seq)
+	assert.ErrorIs(t, err, messaging.ErrNotQuarantined)
+}
+
+func TestServiceManager_QuarantineDisabled(t *testing.T) {
+	mgr := newNATSManager(t, runNATSServer(t, true))
+	_, err := mgr.ListQuarantined(context.Background(), 0)
+	assert.ErrorIs(t, err, ErrQuarantineDisabled)
+	_, err = (&ServiceManager{}).RequeueQuarantined(context.Background(), 1)
+	assert.ErrorIs(t, err, ErrQuarantineDisabled)
+}
@@ -0,0 +1,189 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"grouter/pkg/config"
+	"grouter/pkg/telemetry"
+
+	"go.uber.org/zap"
+)
+
+// ErrNoConfigLoader is returned by ReloadConfig when the configuration was
+// given with WithConfig instead of loaded
+var ErrNoConfigLoader = errors.New("configuration not loaded from a file")
+
+// Reloadable is a component that applies a changed configuration while the
+// service is running
+type Reloadable interface {
+	ApplyConfig(cfg *config.Config) error
+}
+
+// ReloadableFunc adapts a function to Reloadable
+type ReloadableFunc func(cfg *config.Config) error
+
+// ApplyConfig calls f(cfg)
+func (f ReloadableFunc) ApplyConfig(cfg *config.Config) error {
+	return f(cfg)
+}
+
+// reloadable is a component registered with AddReloadable
+type reloadable struct {
+	name      string
+	component Reloadable
+	// settings selects the part of the configuration the component depends
+	// on, nil for all of it
+	settings func(cfg *config.Config) any
+}
+
+// changed reports whether the settings of the component differ between old
+// and cfg
+func (r reloadable) changed(old, cfg *config.Config) bool {
+	if r.settings == nil {
+		return !reflect.DeepEqual(old, cfg)
+	}
+	return !reflect.DeepEqual(r.settings(old), r.settings(cfg))
+}
+
+// AddReloadable registers a component to receive configuration changes.
+// settings selects the part of the configuration it depends on, the component
+// is only called when that part changes (nil for any change). Components are
+// applied in the order they were added.
+func (m *ServiceManager) AddReloadable(name string, r Reloadable, settings func(cfg *config.Config) any) {
+	m.reloadMu.Lock()
+	defer m.reloadMu.Unlock()
+	m.reloadables = append(m.reloadables, reloadable{name: name, component: r, settings: settings})
+}
+
+// ApplyConfig applies cfg to the components whose settings changed. If one of
+// them fails, the components already applied are restored to the current
+// configuration and the error is returned; the current configuration stays in
+// effect.
+func (m *ServiceManager) ApplyConfig(cfg *config.Config) error {
+	m.reloadMu.Lock()
+	defer m.reloadMu.Unlock()
+
+	old := m.cfg
+	var applied []reloadable
+	for _, r := range m.reloadables {
+		if !r.changed(old, cfg) {
+			continue
+		}
+		if err := r.component.ApplyConfig(cfg); err != nil {
+			for i := len(applied) - 1; i >= 0; i-- {
+				if rerr := applied[i].component.ApplyConfig(old); rerr != nil {
+					m.log.Error("Failed to roll back config change",
+						zap.String("component", applied[i].name), zap.Error(rerr))
+				}
+			}
+			return fmt.Errorf("failed to apply config to %s: %w", r.name, err)
+		}
+		applied = append(applied, r)
+	}
+
+	m.cfg = cfg
+	names := make([]string, 0, len(applied))
+	for _, r := range applied {
+		names = append(names, r.name)
+	}
+	m.log.Info("Configuration applied", zap.Strings("components", names))
+	return nil
+}
+
+// ReloadConfig re-reads the configuration and applies it, as a change of the
+// config file would. It requires a configuration loaded by Init.
+func (m *ServiceManager) ReloadConfig() error {
+	if m.loader == nil {
+		return ErrNoConfigLoader
+	}
+	cfg, err := m.loader.Reload()
+	if err != nil {
+		return fmt.Errorf("failed to reload config: %w", err)
+	}
+	return m.ApplyConfig(cfg)
+}
+
+// WatchConfig applies the changes of the config file and the remote store
+// while the service runs. Start calls it when Init loaded the configuration.
+func (m *ServiceManager) WatchConfig() {
+	if m.loader == nil {
+		return
+	}
+	m.watchOnce.Do(func() {
+		m.loader.Watch(func(cfg *config.Config) {
+			if err := m.ApplyConfig(cfg); err != nil {
+				m.log.Error("Config change rejected", zap.Error(err))
+			}
+		})
+	})
+}
+
+// reloadLogger changes the log level. The outputs and formats are fixed when
+// the logger is created.
+func (m *ServiceManager) reloadLogger(cfg *config.Config) error {
+	if err := m.logger.SetLevel(cfg.Log.Level); err != nil {
+		return err
+	}
+	next, current := cfg.Log, m.cfg.Log
+	next.Level, current.Level = "", ""
+	if !reflect.DeepEqual(next, current) {
+		m.log.Warn("Log settings other than the level apply after a restart")
+	}
+	return nil
+}
+
+// reloadTracing replaces the tracer provider, flushing the previous one
+func (m *ServiceManager) reloadTracing(cfg *config.Config) error {
+	shutdown, err := telemetry.InitTracer(tracingConfig(cfg))
+	if err != nil {
+		return fmt.Errorf("failed to initialize tracer: %w", err)
+	}
+	previous := m.tracerShutdown
+	m.tracerShutdown = shutdown
+	if previous != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+		defer cancel()
+		if err := previous(ctx); err != nil {
+			m.log.Warn("Failed to shutdown previous tracer", zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// reloadMessenger rotates the NATS credentials and encryption keys
+func (m *ServiceManager) reloadMessenger(cfg *config.Config) error {
+	return m.messenger.ApplyConfig(m.natsConfig(cfg))
+}
+
+// reloadWebServer applies the web settings and rebuilds the routes with them
+func (m *ServiceManager) reloadWebServer(cfg *config.Config) error {
+	if err := m.webServer.ApplyConfig(m.webConfig(cfg)); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+	if err := m.ReloadWebServer(ctx); err != nil {
+		// The engine serving requests is unchanged
+		_ = m.webServer.ApplyConfig(m.webConfig(m.cfg))
+		return err
+	}
+	return nil
+}
+
+// natsAuth is the part of the NATS settings reloadMessenger applies. Creds
+// files are read again on every reconnect.
+type natsAuth struct {
+	Token, Username, Password string
+	Encryption                config.NATSEncryption
+}
+
+func natsAuthSettings(cfg *config.Config) any {
+	return natsAuth{cfg.NATS.Token, cfg.NATS.Username, cfg.NATS.Password, cfg.NATS.Encryption}
+}
+
+func logSettings(cfg *config.Config) any { return cfg.Log }
+
+func tracingSettings(cfg *config.Config) any { return tracingConfig(cfg) }
@@ -0,0 +1,119 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"grouter/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestServiceManager_ApplyConfig_OnlyChanged(t *testing.T) {
+	mgr := &ServiceManager{log: zap.NewNop(), cfg: &config.Config{Log: config.LogConfig{Level: "info"}}}
+
+	var calls []string
+	record := func(name string) Reloadable {
+		return ReloadableFunc(func(cfg *config.Config) error {
+			calls = append(calls, name)
+			return nil
+		})
+	}
+	mgr.AddReloadable("log", record("log"), logSettings)
+	mgr.AddReloadable("nats", record("nats"), natsAuthSettings)
+	mgr.AddReloadable("all", record("all"), nil)
+
+	next := &config.Config{Log: config.LogConfig{Level: "debug"}}
+	require.NoError(t, mgr.ApplyConfig(next))
+	assert.Equal(t, []string{"log", "all"}, calls)
+	assert.Same(t, next, mgr.Config())
+
+	calls = nil
+	require.NoError(t, mgr.ApplyConfig(&config.Config{Log: config.LogConfig{Level: "debug"}}))
+	assert.Empty(t, calls, "nothing changed")
+}
+
+func TestServiceManager_ApplyConfig_Rollback(t *testing.T) {
+	current := &config.Config{Log: config.LogConfig{Level: "info"}}
+	mgr := &ServiceManager{log: zap.NewNop(), cfg: current}
+
+	var applied []string
+	mgr.AddReloadable("first", ReloadableFunc(func(cfg *config.Config) error {
+		applied = append(applied, "first:"+cfg.Log.Level)
+		return nil
+	}), nil)
+	mgr.AddReloadable("second", ReloadableFunc(func(cfg *config.Config) error {
+		applied = append(applied, "second:"+cfg.Log.Level)
+		return nil
+	}), nil)
+	mgr.AddReloadable("broken", ReloadableFunc(func(cfg *config.Config) error {
+		return errors.New("boom")
+	}), nil)
+
+	err := mgr.ApplyConfig(&config.Config{Log: config.LogConfig{Level: "debug"}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "broken")
+	assert.Equal(t, []string{"first:debug", "second:debug", "second:info", "first:info"}, applied,
+		"applied components are restored in reverse order")
+	assert.Same(t, current, mgr.Config())
+}
+
+func TestServiceManager_ApplyConfig_LogLevel(t *testing.T) {
+	mgr := &ServiceManager{cfg: &config.Config{Log: config.LogConfig{Level: "info", Format: "json", OutputPath: "stdout"}}}
+	require.NoError(t, mgr.initLogger())
+	assert.False(t, mgr.Logger().Core().Enabled(zapcore.DebugLevel))
+
+	next := *mgr.cfg
+	next.Log.Level = "debug"
+	require.NoError(t, mgr.ApplyConfig(&next))
+	assert.True(t, mgr.Logger().Core().Enabled(zapcore.DebugLevel))
+
+	invalid := next
+	invalid.Log.Level = "verbose"
+	assert.Error(t, mgr.ApplyConfig(&invalid))
+	assert.Equal(t, "debug", mgr.Config().Log.Level)
+}
+
+func TestServiceManager_ApplyConfig_Web(t *testing.T) {
+	mgr := &ServiceManager{
+		log:     zap.NewNop(),
+		router:  NewServiceRouter(),
+		timeout: 10 * time.Second,
+		cfg: &config.Config{
+			App: config.AppConfig{Name: "grouter"},
+			Web: config.WebConfig{Enabled: true, Mode: "test"},
+		},
+	}
+	require.NoError(t, mgr.InitWebServer())
+	require.NoError(t, mgr.RegisterService(&routeService{mockService{name: "orders"}}))
+
+	allowOrigin := func() string {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		mgr.WebServer().ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		return w.Header().Get("Access-Control-Allow-Origin")
+	}
+	assert.Empty(t, allowOrigin())
+
+	next := *mgr.cfg
+	next.Web.CORS = config.CORSConfig{Enabled: true, AllowedOrigins: []string{"https://app.example.com"}}
+	require.NoError(t, mgr.ApplyConfig(&next))
+	assert.Equal(t, "https://app.example.com", allowOrigin(), "routes are rebuilt with the new settings")
+
+	invalid := next
+	invalid.Web.CORS = config.CORSConfig{}
+	invalid.Web.RateLimit = config.RateLimitConfig{Enabled: true, RequestsPerSecond: 1, Burst: 1, Store: "memcached"}
+	assert.Error(t, mgr.ApplyConfig(&invalid))
+	assert.Equal(t, "https://app.example.com", allowOrigin(), "the previous routes keep serving")
+
+	assert.NoError(t, mgr.Stop(context.Background()))
+}
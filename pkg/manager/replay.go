@@ -0,0 +1,197 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Replay job states
+const (
+	ReplayRunning  = "running"
+	ReplayDone     = "done"
+	ReplayFailed   = "failed"
+	ReplayCanceled = "canceled"
+)
+
+// ErrReplayNotFound is returned for unknown replay jobs
+var ErrReplayNotFound = errors.New("replay not found")
+
+// ReplayRequest selects the messages to replay. Without a target subject,
+// they are handled again: by Service when set, through the router middleware
+// as on its own subjects, else routed by type as the messages of
+// SubscribeToTopics.
+type ReplayRequest struct {
+	messaging.ReplayOptions
+	Service string `json:"service,omitempty"`
+}
+
+// ReplayJob is a replay running in the background and its progress
+type ReplayJob struct {
+	ID         string                   `json:"id"`
+	Request    ReplayRequest            `json:"request"`
+	Status     string                   `json:"status"`
+	Progress   messaging.ReplayProgress `json:"progress"`
+	Error      string                   `json:"error,omitempty"`
+	StartedAt  time.Time                `json:"started_at"`
+	FinishedAt time.Time                `json:"finished_at,omitzero"`
+}
+
+// replayJob is a ReplayJob with the means to cancel it
+type replayJob struct {
+	mu     sync.Mutex
+	job    ReplayJob
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (j *replayJob) snapshot() ReplayJob {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.job
+}
+
+// StartReplay starts a replay in the background and returns its job. The
+// replay runs until every selected message is replayed, CancelReplay or Stop.
+func (m *ServiceManager) StartReplay(req ReplayRequest) (ReplayJob, error) {
+	if m.messenger == nil {
+		return ReplayJob{}, fmt.Errorf("NATS disabled or messenger not initialized")
+	}
+	if req.Stream == "" {
+		return ReplayJob{}, fmt.Errorf("replay requires a stream")
+	}
+	var handler messaging.HandlerFunc
+	switch {
+	case req.Target != "" || req.DryRun:
+	case req.Service != "":
+		entry := m.serviceEntry(req.Service)
+		if entry == nil || entry.svc == nil {
+			return ReplayJob{}, fmt.Errorf("service %q is not registered", req.Service)
+		}
+		handler = m.serviceHandler(entry)
+	default:
+		handler = m.routeMessage
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &replayJob{
+		job:    ReplayJob{ID: uuid.NewString(), Request: req, Status: ReplayRunning, StartedAt: time.Now()},
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	m.replaysMu.Lock()
+	if m.replays == nil {
+		m.replays = make(map[string]*replayJob)
+	}
+	m.replays[j.job.ID] = j
+	m.replaysMu.Unlock()
+
+	replayer := m.messenger.Replayer()
+	replayer.OnProgress(func(p messaging.ReplayProgress) {
+		j.mu.Lock()
+		j.job.Progress = p
+		j.mu.Unlock()
+	})
+	m.log.Info("Replay started",
+		zap.String("id", j.job.ID),
+		zap.String("stream", req.Stream),
+		zap.String("subject", req.Subject),
+		zap.String("target", req.Target),
+		zap.String("service", req.Service),
+		zap.Bool("dry_run", req.DryRun),
+	)
+
+	go func() {
+		defer close(j.done)
+		defer cancel()
+		progress, err := replayer.Replay(ctx, req.ReplayOptions, handler)
+
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		j.job.Progress = progress
+		j.job.FinishedAt = time.Now()
+		switch {
+		case errors.Is(err, context.Canceled):
+			j.job.Status = ReplayCanceled
+		case err != nil:
+			j.job.Status = ReplayFailed
+			j.job.Error = err.Error()
+		default:
+			j.job.Status = ReplayDone
+		}
+		m.log.Info("Replay finished",
+			zap.String("id", j.job.ID),
+			zap.String("status", j.job.Status),
+			zap.Uint64("matched", progress.Matched),
+			zap.Uint64("replayed", progress.Replayed),
+			zap.Uint64("failed", progress.Failed),
+			zap.Error(err),
+		)
+	}()
+	return j.snapshot(), nil
+}
+
+// GetReplay returns the replay job id
+func (m *ServiceManager) GetReplay(id string) (ReplayJob, error) {
+	m.replaysMu.Lock()
+	j, ok := m.replays[id]
+	m.replaysMu.Unlock()
+	if !ok {
+		return ReplayJob{}, fmt.Errorf("%w: %s", ErrReplayNotFound, id)
+	}
+	return j.snapshot(), nil
+}
+
+// ListReplays returns the replay jobs, the most recent first
+func (m *ServiceManager) ListReplays() []ReplayJob {
+	m.replaysMu.Lock()
+	jobs := make([]ReplayJob, 0, len(m.replays))
+	for _, j := range m.replays {
+		jobs = append(jobs, j.snapshot())
+	}
+	m.replaysMu.Unlock()
+	sort.Slice(jobs, func(i, k int) bool {
+		return jobs[i].StartedAt.After(jobs[k].StartedAt)
+	})
+	return jobs
+}
+
+// CancelReplay stops the replay job id and waits for it to finish. Finished
+// jobs are left as they are.
+func (m *ServiceManager) CancelReplay(id string) (ReplayJob, error) {
+	m.replaysMu.Lock()
+	j, ok := m.replays[id]
+	m.replaysMu.Unlock()
+	if !ok {
+		return ReplayJob{}, fmt.Errorf("%w: %s", ErrReplayNotFound, id)
+	}
+	j.cancel()
+	<-j.done
+	return j.snapshot(), nil
+}
+
+// stopReplays cancels the running replays and waits for them, up to ctx
+func (m *ServiceManager) stopReplays(ctx context.Context) {
+	m.replaysMu.Lock()
+	jobs := make([]*replayJob, 0, len(m.replays))
+	for _, j := range m.replays {
+		jobs = append(jobs, j)
+	}
+	m.replaysMu.Unlock()
+	for _, j := range jobs {
+		j.cancel()
+		select {
+		case <-j.done:
+		case <-ctx.Done():
+			m.log.Warn("Replay still running at shutdown", zap.String("id", j.job.ID))
+		}
+	}
+}
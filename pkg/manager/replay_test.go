@@ -0,0 +1,113 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newReplayManager returns a manager on a JetStream server with an "ORDERS"
+// stream holding n messages on orders.created
+func newReplayManager(t *testing.T, n int) *ServiceManager {
+	t.Helper()
+	mgr := newNATSManager(t, runNATSServer(t, true))
+	js, err := mgr.messenger.Client.JetStream()
+	require.NoError(t, err)
+	_, err = js.AddStream(&nats.StreamConfig{Name: "ORDERS", Subjects: []string{"orders.>"}, Storage: nats.MemoryStorage})
+	require.NoError(t, err)
+	for i := 0; i < n; i++ {
+		_, err := mgr.messenger.Publisher.PublishJS(context.Background(), "orders.created", "orders.created", i)
+		require.NoError(t, err)
+	}
+	return mgr
+}
+
+// waitReplay waits for the replay job id to finish
+func waitReplay(t *testing.T, mgr *ServiceManager, id string) ReplayJob {
+	t.Helper()
+	var job ReplayJob
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = mgr.GetReplay(id)
+		require.NoError(t, err)
+		return job.Status != ReplayRunning
+	}, 5*time.Second, 10*time.Millisecond)
+	return job
+}
+
+func TestServiceManager_Replay(t *testing.T) {
+	mgr := newReplayManager(t, 3)
+	svc := &subjectService{name: "orders", subjects: []SubjectSpec{{Subject: "orders.live"}}}
+	require.NoError(t, mgr.RegisterService(svc))
+
+	job, err := mgr.StartReplay(ReplayRequest{ReplayOptions: messaging.ReplayOptions{Stream: "ORDERS"}, Service: "orders"})
+	require.NoError(t, err)
+	assert.Equal(t, ReplayRunning, job.Status)
+
+	job = waitReplay(t, mgr, job.ID)
+	assert.Equal(t, ReplayDone, job.Status)
+	assert.Equal(t, uint64(3), job.Progress.Replayed)
+	assert.False(t, job.FinishedAt.IsZero())
+	svc.mu.Lock()
+	assert.Equal(t, []string{"orders.created", "orders.created", "orders.created"}, svc.received, "handled as on the service subjects")
+	svc.mu.Unlock()
+
+	t.Run("dry run", func(t *testing.T) {
+		job, err := mgr.StartReplay(ReplayRequest{ReplayOptions: messaging.ReplayOptions{Stream: "ORDERS", DryRun: true}})
+		require.NoError(t, err)
+		job = waitReplay(t, mgr, job.ID)
+		assert.Equal(t, ReplayDone, job.Status)
+		assert.Equal(t, uint64(3), job.Progress.Matched)
+		assert.Zero(t, job.Progress.Replayed)
+		assert.Equal(t, 3, svc.count())
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		job, err := mgr.StartReplay(ReplayRequest{ReplayOptions: messaging.ReplayOptions{Stream: "ORDERS", Rate: 1}, Service: "orders"})
+		require.NoError(t, err)
+		job, err = mgr.CancelReplay(job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, ReplayCanceled, job.Status)
+		assert.Less(t, job.Progress.Replayed, uint64(3))
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		job, err := mgr.StartReplay(ReplayRequest{ReplayOptions: messaging.ReplayOptions{Stream: "MISSING", DryRun: true}})
+		require.NoError(t, err)
+		job = waitReplay(t, mgr, job.ID)
+		assert.Equal(t, ReplayFailed, job.Status)
+		assert.Contains(t, job.Error, "stream not found")
+	})
+
+	assert.Len(t, mgr.ListReplays(), 4)
+	_, err = mgr.GetReplay("unknown")
+	assert.ErrorIs(t, err, ErrReplayNotFound)
+}
+
+func TestServiceManager_ReplayErrors(t *testing.T) {
+	_, err := (&ServiceManager{}).StartReplay(ReplayRequest{ReplayOptions: messaging.ReplayOptions{Stream: "ORDERS"}})
+	assert.Error(t, err, "NATS disabled")
+
+	mgr := newReplayManager(t, 0)
+	_, err = mgr.StartReplay(ReplayRequest{})
+	assert.Error(t, err, "stream required")
+	_, err = mgr.StartReplay(ReplayRequest{ReplayOptions: messaging.ReplayOptions{Stream: "ORDERS"}, Service: "billing"})
+	assert.Error(t, err, "unknown service")
+}
+
+func TestServiceManager_StopCancelsReplays(t *testing.T) {
+	mgr := newReplayManager(t, 3)
+	job, err := mgr.StartReplay(ReplayRequest{ReplayOptions: messaging.ReplayOptions{Stream: "ORDERS", Target: "replayed.orders", Rate: 1}})
+	require.NoError(t, err)
+
+	mgr.stopReplays(context.Background())
+	job, err = mgr.GetReplay(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, ReplayCanceled, job.Status)
+}
@@ -6,11 +6,16 @@ import (
 	"strings"
 
 	messaging "grouter/pkg/messaging/nats"
+	"grouter/pkg/tenant"
 )
 
 // ServiceRouter routes messages to the appropriate service based on the topic.
 type ServiceRouter struct {
-	store *ServiceStore
+	store      *ServiceStore
+	middleware []messaging.SubscriberMiddleware
+	// tenantPrefix is the prefix of the tenant subjects, empty unless
+	// tenants are enforced
+	tenantPrefix string
 }
 
 // NewServiceRouter creates a new ServiceRouter.
@@ -23,6 +28,38 @@ func (r *ServiceRouter) Register(name string, svc Service) {
 	r.store.Add(name, svc)
 }
 
+// Use adds middleware that wraps every routed message, e.g. authorization.
+// The subject passed to the middleware is the routing topic.
+func (r *ServiceRouter) Use(mw ...messaging.SubscriberMiddleware) {
+	r.middleware = append(r.middleware, mw...)
+}
+
+// EnforceTenants makes the router accept only subjects of the form
+// <prefix>.<tenant>.<service>.<op>, see RouteSubject. An empty prefix turns
+// it off.
+func (r *ServiceRouter) EnforceTenants(prefix string) {
+	r.tenantPrefix = prefix
+}
+
+// RouteSubject returns the routing topic of a message received on subject,
+// and ctx carrying its tenant. Without tenant enforcement the topic is the
+// message type. Otherwise it is the part of the subject after the tenant,
+// which must match the tenant of the envelope if it has one.
+func (r *ServiceRouter) RouteSubject(ctx context.Context, subject string, env *messaging.MessageEnvelope) (context.Context, string, error) {
+	if r.tenantPrefix == "" {
+		return ctx, env.Type, nil
+	}
+	id, topic, err := tenant.ParseSubject(r.tenantPrefix, subject)
+	if err != nil {
+		return ctx, "", err
+	}
+	if envID := env.TenantID(); envID != "" && envID != id {
+		return ctx, "", fmt.Errorf("tenant %q of the message does not match subject %q", envID, subject)
+	}
+	env.SetTenantID(id)
+	return tenant.NewContext(ctx, id), topic, nil
+}
+
 // Unregister removes a service from the router.
 func (r *ServiceRouter) Unregister(name string) {
 	r.store.Delete(name)
@@ -66,6 +103,19 @@ func (r *ServiceRouter) HandleMessage(ctx context.Context, topic string, env *me
 	if env == nil {
 		return fmt.Errorf("nil envelope")
 	}
+	return r.Wrap(r.dispatch)(ctx, topic, env)
+}
+
+// Wrap applies the router middleware to h, for messages delivered to a
+// service without routing
+func (r *ServiceRouter) Wrap(h messaging.HandlerFunc) messaging.HandlerFunc {
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		h = r.middleware[i](h)
+	}
+	return h
+}
+
+func (r *ServiceRouter) dispatch(ctx context.Context, topic string, env *messaging.MessageEnvelope) error {
 	svc, err := r.RouteByTopic(topic)
 	if err != nil {
 		return err
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	messaging "grouter/pkg/messaging/nats"
+	"grouter/pkg/tenant"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -76,3 +77,51 @@ func TestServiceRouter(t *testing.T) {
 		})
 	}
 }
+
+func TestServiceRouter_Middleware(t *testing.T) {
+	router := NewServiceRouter()
+	router.Register("natdemo", &mockService{name: "natdemo"})
+
+	var order []string
+	mw := func(name string, block bool) messaging.SubscriberMiddleware {
+		return func(next messaging.HandlerFunc) messaging.HandlerFunc {
+			return func(ctx context.Context, topic string, msg *messaging.MessageEnvelope) error {
+				order = append(order, name)
+				if block {
+					return assert.AnError
+				}
+				return next(ctx, topic, msg)
+			}
+		}
+	}
+	router.Use(mw("first", false), mw("second", false))
+
+	env := &messaging.MessageEnvelope{Type: "natdemo.delete"}
+	assert.NoError(t, router.HandleMessage(context.Background(), "natdemo.delete", env))
+	assert.Equal(t, []string{"first", "second"}, order)
+
+	router.Use(mw("deny", true))
+	assert.ErrorIs(t, router.HandleMessage(context.Background(), "natdemo.delete", env), assert.AnError)
+}
+
+func TestServiceRouter_RouteSubject(t *testing.T) {
+	router := NewServiceRouter()
+	env := &messaging.MessageEnvelope{Type: "orders.create"}
+
+	_, topic, err := router.RouteSubject(context.Background(), "gRouter.acme.orders.create", env)
+	assert.NoError(t, err)
+	assert.Equal(t, "orders.create", topic, "without enforcement the type is the topic")
+	assert.Empty(t, env.TenantID())
+
+	router.EnforceTenants("gRouter")
+	ctx, topic, err := router.RouteSubject(context.Background(), "gRouter.acme.orders.get", env)
+	assert.NoError(t, err)
+	assert.Equal(t, "orders.get", topic, "the subject after the tenant is the topic")
+	assert.Equal(t, "acme", tenant.FromContext(ctx))
+	assert.Equal(t, "acme", env.TenantID(), "the envelope gets the tenant of the subject")
+
+	_, _, err = router.RouteSubject(context.Background(), "gRouter.globex.orders.get", env)
+	assert.Error(t, err, "the envelope tenant must match the subject")
+	_, _, err = router.RouteSubject(context.Background(), "gRouter.orders", &messaging.MessageEnvelope{})
+	assert.Error(t, err, "subjects without a tenant are rejected")
+}
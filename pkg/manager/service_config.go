@@ -0,0 +1,100 @@
+package manager
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"grouter/pkg/config"
+)
+
+// ServiceConfigFunc receives the new typed config of a service, a pointer of
+// the type given to RegisterServiceConfig. Returning an error rejects the
+// configuration change.
+type ServiceConfigFunc func(cfg any) error
+
+// serviceConfig is a section registered with RegisterServiceConfig
+type serviceConfig struct {
+	// defaults is a copy of the target before the first decoding, the
+	// starting point of every reload
+	defaults reflect.Value
+	current  any
+	onChange []ServiceConfigFunc
+}
+
+// serviceConfigs holds the registered sections by service name
+type serviceConfigs struct {
+	mu      sync.RWMutex
+	entries map[string]*serviceConfig
+}
+
+// RegisterServiceConfig decodes the services.<name> section into target, a
+// pointer to a struct with mapstructure tags, and validates it (see
+// config.ServicesConfig.Decode). The values of target before the call are
+// the defaults. When the section changes on reload, it is decoded over the
+// defaults into a new value of the same type and passed to onChange; an
+// invalid section or a failing handler rejects the whole change. Registering
+// the name again replaces the target and the handlers.
+func (m *ServiceManager) RegisterServiceConfig(name string, target any, onChange ...ServiceConfigFunc) error {
+	typ := reflect.TypeOf(target)
+	if typ == nil || typ.Kind() != reflect.Pointer || typ.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config target of service %s must be a pointer to a struct, got %T", name, target)
+	}
+	if m.cfg == nil {
+		return fmt.Errorf("config not initialized")
+	}
+	defaults := reflect.New(typ.Elem()).Elem()
+	defaults.Set(reflect.ValueOf(target).Elem())
+	if err := m.cfg.Services.Decode(name, target); err != nil {
+		return err
+	}
+
+	m.serviceConfigs.mu.Lock()
+	defer m.serviceConfigs.mu.Unlock()
+	if m.serviceConfigs.entries == nil {
+		m.serviceConfigs.entries = make(map[string]*serviceConfig)
+	}
+	_, registered := m.serviceConfigs.entries[name]
+	m.serviceConfigs.entries[name] = &serviceConfig{defaults: defaults, current: target, onChange: onChange}
+	if !registered {
+		m.AddReloadable("service:"+name, ReloadableFunc(func(cfg *config.Config) error {
+			return m.reloadServiceConfig(name, cfg)
+		}), func(cfg *config.Config) any { return cfg.Services[name] })
+	}
+	return nil
+}
+
+// ServiceConfig returns the current typed config of the service name, nil
+// when it is not registered
+func (m *ServiceManager) ServiceConfig(name string) any {
+	m.serviceConfigs.mu.RLock()
+	defer m.serviceConfigs.mu.RUnlock()
+	if sc, ok := m.serviceConfigs.entries[name]; ok {
+		return sc.current
+	}
+	return nil
+}
+
+// reloadServiceConfig decodes the changed section of the service name and
+// hands it to the handlers
+func (m *ServiceManager) reloadServiceConfig(name string, cfg *config.Config) error {
+	m.serviceConfigs.mu.RLock()
+	sc := m.serviceConfigs.entries[name]
+	m.serviceConfigs.mu.RUnlock()
+
+	next := reflect.New(sc.defaults.Type())
+	next.Elem().Set(sc.defaults)
+	if err := cfg.Services.Decode(name, next.Interface()); err != nil {
+		return err
+	}
+	for _, fn := range sc.onChange {
+		if err := fn(next.Interface()); err != nil {
+			return err
+		}
+	}
+
+	m.serviceConfigs.mu.Lock()
+	sc.current = next.Interface()
+	m.serviceConfigs.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,107 @@
+package manager
+
+import (
+	"errors"
+	"testing"
+
+	"grouter/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type ordersConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Subject string `mapstructure:"subject"`
+	Queue   string `mapstructure:"queue"`
+}
+
+func (c *ordersConfig) Validate() error {
+	if c.Enabled && c.Subject == "" {
+		return &config.ValidationError{Errors: []config.FieldError{{Field: "subject", Message: "is required"}}}
+	}
+	return nil
+}
+
+func withServices(services config.ServicesConfig) *config.Config {
+	return &config.Config{Services: services}
+}
+
+func TestServiceManager_RegisterServiceConfig(t *testing.T) {
+	mgr := &ServiceManager{log: zap.NewNop(), cfg: withServices(config.ServicesConfig{
+		"orders": map[string]interface{}{"enabled": true, "subject": "orders"},
+	})}
+
+	cfg := ordersConfig{Queue: "workers"}
+	var changes []*ordersConfig
+	require.NoError(t, mgr.RegisterServiceConfig("orders", &cfg, func(c any) error {
+		changes = append(changes, c.(*ordersConfig))
+		return nil
+	}))
+	assert.Equal(t, ordersConfig{Enabled: true, Subject: "orders", Queue: "workers"}, cfg)
+	assert.Same(t, &cfg, mgr.ServiceConfig("orders"))
+
+	// Other sections do not notify the service
+	next := withServices(config.ServicesConfig{
+		"orders":   map[string]interface{}{"enabled": true, "subject": "orders"},
+		"payments": map[string]interface{}{"enabled": true},
+	})
+	require.NoError(t, mgr.ApplyConfig(next))
+	assert.Empty(t, changes)
+
+	next = withServices(config.ServicesConfig{"orders": map[string]interface{}{"enabled": true, "subject": "orders.v2"}})
+	require.NoError(t, mgr.ApplyConfig(next))
+	require.Len(t, changes, 1)
+	assert.Equal(t, ordersConfig{Enabled: true, Subject: "orders.v2", Queue: "workers"}, *changes[0],
+		"the section is decoded over the defaults")
+	assert.Same(t, changes[0], mgr.ServiceConfig("orders"))
+	assert.Equal(t, "orders", cfg.Subject, "the registered target is left untouched")
+}
+
+func TestServiceManager_RegisterServiceConfig_Invalid(t *testing.T) {
+	mgr := &ServiceManager{log: zap.NewNop(), cfg: withServices(config.ServicesConfig{
+		"orders": map[string]interface{}{"enabled": true},
+	})}
+
+	var cfg ordersConfig
+	err := mgr.RegisterServiceConfig("orders", &cfg)
+	var verr *config.ValidationError
+	require.ErrorAs(t, err, &verr)
+	assert.Equal(t, "services.orders.subject", verr.Errors[0].Field)
+
+	assert.Error(t, mgr.RegisterServiceConfig("orders", cfg), "target must be a pointer")
+	assert.Nil(t, mgr.ServiceConfig("orders"))
+}
+
+func TestServiceManager_RegisterServiceConfig_RejectsChange(t *testing.T) {
+	current := withServices(config.ServicesConfig{"orders": map[string]interface{}{"subject": "orders"}})
+	mgr := &ServiceManager{log: zap.NewNop(), cfg: current}
+
+	var cfg ordersConfig
+	require.NoError(t, mgr.RegisterServiceConfig("orders", &cfg, func(c any) error {
+		if c.(*ordersConfig).Subject == "forbidden" {
+			return errors.New("subject in use")
+		}
+		return nil
+	}))
+
+	invalid := withServices(config.ServicesConfig{"orders": map[string]interface{}{"enabled": true, "subject": ""}})
+	assert.Error(t, mgr.ApplyConfig(invalid), "an invalid section rejects the change")
+
+	refused := withServices(config.ServicesConfig{"orders": map[string]interface{}{"subject": "forbidden"}})
+	assert.ErrorContains(t, mgr.ApplyConfig(refused), "subject in use")
+
+	assert.Same(t, current, mgr.Config())
+	assert.Same(t, &cfg, mgr.ServiceConfig("orders"))
+}
+
+func TestServiceManager_RegisterServiceConfig_Again(t *testing.T) {
+	mgr := &ServiceManager{log: zap.NewNop(), cfg: withServices(nil)}
+
+	var first, second ordersConfig
+	require.NoError(t, mgr.RegisterServiceConfig("orders", &first))
+	require.NoError(t, mgr.RegisterServiceConfig("orders", &second))
+	assert.Same(t, &second, mgr.ServiceConfig("orders"))
+	assert.Len(t, mgr.reloadables, 1, "the section is reloaded once")
+}
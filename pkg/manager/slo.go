@@ -0,0 +1,122 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"grouter/pkg/config"
+	"grouter/pkg/slo"
+
+	"go.uber.org/zap"
+)
+
+// SLOAlertType is the message type of the SLO alerts
+const SLOAlertType = "slo.alert"
+
+// ErrSLODisabled is returned when SLO tracking is disabled
+var ErrSLODisabled = errors.New("slo tracking disabled")
+
+// initSLO creates the tracker of the objectives observed by the web and NATS
+// middlewares and starts evaluating them. Changes of the configured
+// objectives apply on reload.
+func (m *ServiceManager) initSLO() error {
+	tracker, err := slo.New(slo.Config{
+		Objectives:         sloObjectives(m.cfg),
+		Window:             m.cfg.SLO.Window,
+		EvaluationInterval: m.cfg.SLO.EvaluationInterval,
+		Alerts:             sloAlertRules(m.cfg),
+		OnAlert:            m.publishSLOAlert,
+		Registry:           m.metrics,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to initialize slo tracking: %w", err)
+	}
+	m.slo = tracker
+	tracker.Start()
+	m.AddReloadable("slo", ReloadableFunc(func(cfg *config.Config) error {
+		return m.slo.SetObjectives(sloObjectives(cfg))
+	}), func(cfg *config.Config) any { return cfg.SLO.Objectives })
+	m.log.Info("SLO tracking enabled",
+		zap.Int("objectives", len(m.cfg.SLO.Objectives)),
+		zap.String("alert_subject", m.sloAlertSubject()),
+	)
+	return nil
+}
+
+// sloObjectives converts the configured objectives
+func sloObjectives(cfg *config.Config) []slo.Objective {
+	objectives := make([]slo.Objective, 0, len(cfg.SLO.Objectives))
+	for _, o := range cfg.SLO.Objectives {
+		objectives = append(objectives, slo.Objective{
+			Name:    o.Name,
+			Kind:    slo.Kind(o.Kind),
+			Match:   o.Match,
+			Method:  o.Method,
+			Latency: o.Latency,
+			Target:  o.Target,
+		})
+	}
+	return objectives
+}
+
+// sloAlertRules converts the configured alert rules, nil for the defaults
+func sloAlertRules(cfg *config.Config) []slo.AlertRule {
+	var rules []slo.AlertRule
+	for _, r := range cfg.SLO.Alerts {
+		rules = append(rules, slo.AlertRule(r))
+	}
+	return rules
+}
+
+// sloAlertSubject returns the subject of the SLO alerts, "" when disabled
+func (m *ServiceManager) sloAlertSubject() string {
+	switch subject := m.cfg.SLO.AlertSubject; subject {
+	case "-":
+		return ""
+	case "":
+		return m.cfg.App.Name + ".slo.alerts"
+	default:
+		return subject
+	}
+}
+
+// publishSLOAlert logs the alert and publishes it on the alert subject
+func (m *ServiceManager) publishSLOAlert(alert slo.Alert) {
+	fields := []zap.Field{
+		zap.String("slo", alert.SLO),
+		zap.String("rule", alert.Rule),
+		zap.Float64("burn_rate", alert.BurnRate),
+		zap.Float64("threshold", alert.Threshold),
+	}
+	if alert.Firing {
+		m.log.Warn("SLO error budget burning fast", fields...)
+	} else {
+		m.log.Info("SLO burn rate alert resolved", fields...)
+	}
+
+	subject := m.sloAlertSubject()
+	if subject == "" || m.messenger == nil || m.messenger.Publisher == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+	if err := m.messenger.Publisher.Publish(ctx, subject, SLOAlertType, alert, nil); err != nil {
+		m.log.Error("Failed to publish SLO alert", zap.Error(err), zap.String("subject", subject))
+	}
+}
+
+// SLOStatus returns the compliance, error budget, burn rates and firing
+// alerts of the objectives
+func (m *ServiceManager) SLOStatus() ([]slo.Status, error) {
+	if m.slo == nil {
+		return nil, ErrSLODisabled
+	}
+	return m.slo.Status(), nil
+}
+
+// SLOTracker returns the tracker of the objectives, or nil if SLO tracking is
+// disabled
+func (m *ServiceManager) SLOTracker() *slo.Tracker {
+	return m.slo
+}
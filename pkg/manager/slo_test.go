@@ -0,0 +1,111 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"grouter/pkg/config"
+	messaging "grouter/pkg/messaging/nats"
+	"grouter/pkg/slo"
+	"grouter/pkg/telemetry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestAdminService_SLO(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mgr := NewServiceManager()
+	mgr.log = zap.NewNop()
+	mgr.metrics = telemetry.NewMetricsRegistry()
+	mgr.cfg = &config.Config{SLO: config.SLOConfig{
+		Enabled:      true,
+		Window:       24 * time.Hour,
+		AlertSubject: "-",
+		Objectives: []config.SLOObjective{
+			{Name: "orders", Kind: "http", Match: "/api/orders", Target: 0.99},
+		},
+	}}
+	require.NoError(t, mgr.initSLO())
+	t.Cleanup(mgr.slo.Stop)
+	engine := gin.New()
+	NewAdminService(mgr).RegisterRoutes(&engine.RouterGroup)
+
+	status := func() []slo.Status {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/slo", nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var s []slo.Status
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &s))
+		return s
+	}
+
+	mgr.SLOTracker().Observe(slo.KindHTTP, "GET", func(p string) bool { return p == "/api/orders" }, 0, true)
+	s := status()
+	require.Len(t, s, 1)
+	assert.Equal(t, "orders", s[0].Name)
+	assert.Equal(t, uint64(1), s[0].Bad)
+
+	// Configuration changes replace the objectives
+	cfg := *mgr.cfg
+	cfg.SLO.Objectives = append(cfg.SLO.Objectives, config.SLOObjective{Name: "events", Kind: "nats", Target: 0.999})
+	require.NoError(t, mgr.ApplyConfig(&cfg))
+	s = status()
+	require.Len(t, s, 2)
+	assert.Equal(t, uint64(1), s[0].Total, "unchanged objectives keep their events")
+	assert.Equal(t, "events", s[1].Name)
+}
+
+func TestAdminService_SLODisabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	NewAdminService(NewServiceManager()).RegisterRoutes(&engine.RouterGroup)
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/slo", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestServiceManager_SLOAlerts(t *testing.T) {
+	mgr := newNATSManager(t, runNATSServer(t, false), func(cfg *config.Config) {
+		cfg.SLO = config.SLOConfig{
+			Enabled: true,
+			Window:  time.Hour,
+			Alerts:  []config.SLOAlertRule{{Name: "page", LongWindow: 10 * time.Minute, ShortWindow: time.Minute, BurnRate: 2}},
+			Objectives: []config.SLOObjective{
+				{Name: "orders", Kind: "nats", Target: 0.9},
+			},
+		}
+	})
+	mgr.timeout = 2 * time.Second
+	mgr.metrics = telemetry.NewMetricsRegistry()
+	require.NoError(t, mgr.initSLO())
+	t.Cleanup(mgr.slo.Stop)
+
+	alerts := make(chan *messaging.MessageEnvelope, 1)
+	require.NoError(t, mgr.messenger.Subscriber.Subscribe("grouter.slo.alerts", func(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+		alerts <- env
+		return nil
+	}, nil))
+
+	mgr.slo.Observe(slo.KindNATS, "", func(string) bool { return true }, 0, true)
+	mgr.slo.Evaluate()
+
+	select {
+	case env := <-alerts:
+		assert.Equal(t, SLOAlertType, env.Type)
+		var alert slo.Alert
+		require.NoError(t, json.Unmarshal(env.Data, &alert))
+		assert.Equal(t, "orders", alert.SLO)
+		assert.Equal(t, "page", alert.Rule)
+		assert.True(t, alert.Firing)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no SLO alert published")
+	}
+}
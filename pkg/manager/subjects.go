@@ -0,0 +1,298 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+
+	"go.uber.org/zap"
+)
+
+// SubscriptionInfo describes a NATS subscription of the manager
+type SubscriptionInfo struct {
+	// Service owns the subscription; empty for the subscriptions of
+	// SubscribeToTopics, whose messages are routed by type
+	Service    string `json:"service,omitempty"`
+	Subject    string `json:"subject"`
+	QueueGroup string `json:"queue_group,omitempty"`
+	Workers    int    `json:"workers,omitempty"`
+	Priority   bool   `json:"priority,omitempty"`
+	// Received counts the messages delivered, Failed those whose handler
+	// returned an error and InFlight those being handled
+	Received uint64 `json:"received"`
+	Failed   uint64 `json:"failed"`
+	InFlight int64  `json:"in_flight"`
+	// Pending counts the messages received and waiting to be handled, the
+	// lag of the subscription
+	Pending int `json:"pending"`
+}
+
+// errorReporter handles the error of a service for a message
+type errorReporter func(ctx context.Context, topic string, env *messaging.MessageEnvelope, err error) error
+
+// trackedSubscription is a subscription with its counters
+type trackedSubscription struct {
+	spec     SubjectSpec
+	sub      messaging.Subscription
+	received atomic.Uint64
+	failed   atomic.Uint64
+	inFlight atomic.Int64
+}
+
+// pending returns the queue depth of the subscription, when it reports one
+func (t *trackedSubscription) pending() int {
+	if q, ok := t.sub.(interface{ QueueDepth() int }); ok {
+		return q.QueueDepth()
+	}
+	return 0
+}
+
+// handle counts the messages handled by h, passing its errors to report
+func (t *trackedSubscription) handle(h messaging.HandlerFunc, report errorReporter) messaging.HandlerFunc {
+	return func(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+		t.received.Add(1)
+		t.inFlight.Add(1)
+		defer t.inFlight.Add(-1)
+		if err := h(ctx, subject, env); err != nil {
+			t.failed.Add(1)
+			return report(ctx, subject, env, err)
+		}
+		return nil
+	}
+}
+
+// serviceSubscriptions are the subscriptions of a service and its handlers
+// in flight. The entry of the empty name holds the subscriptions of
+// SubscribeToTopics.
+type serviceSubscriptions struct {
+	name string
+	svc  NATService
+	subs []*trackedSubscription
+
+	// mu guards closed, so that no handler is added to wg once release
+	// waits for it
+	mu     sync.Mutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// begin records a handler in flight; false once the service is released
+func (s *serviceSubscriptions) begin() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.wg.Add(1)
+	return true
+}
+
+func (s *serviceSubscriptions) end() {
+	s.wg.Done()
+}
+
+// release removes the subscriptions and waits up to timeout for the
+// handlers in flight
+func (s *serviceSubscriptions) release(log *zap.Logger, timeout time.Duration) {
+	for _, t := range s.subs {
+		if err := t.sub.Unsubscribe(); err != nil {
+			log.Error("Failed to unsubscribe service subject",
+				zap.String("service", s.name), zap.String("subject", t.spec.Subject), zap.Error(err))
+		}
+	}
+
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Warn("Service handlers still running after unregistration", zap.String("service", s.name))
+	}
+}
+
+// subscribeService tracks the handlers of svc and subscribes its subjects
+// when it is a NATSService. Subscribing the same instance again does
+// nothing; another service of the same name replaces it.
+func (m *ServiceManager) subscribeService(svc NATService) error {
+	key := normalizeService(svc.Name())
+	m.subjectsMu.Lock()
+	prev, ok := m.subjects[key]
+	m.subjectsMu.Unlock()
+	if ok {
+		if prev.svc == svc {
+			return nil
+		}
+		m.unsubscribeService(key)
+	}
+
+	entry := &serviceSubscriptions{name: svc.Name(), svc: svc}
+	if subjSvc, ok := svc.(NATSService); ok {
+		if m.messenger == nil {
+			m.log.Warn("NATS disabled or messenger not initialized, skipping service subjects",
+				zap.String("service", svc.Name()))
+		} else {
+			for _, spec := range subjSvc.Subjects() {
+				t := &trackedSubscription{spec: spec}
+				sub, err := m.messenger.Subscriber.SubscribeSubject(spec.Subject, t.handle(m.serviceHandler(entry), m.serviceErrors(entry.name)),
+					&messaging.SubscribeOptions{QueueGroup: spec.QueueGroup, MaxWorkers: spec.Workers, Priority: spec.Priority})
+				if err != nil {
+					entry.release(m.log, m.timeout)
+					return fmt.Errorf("failed to subscribe service %q to %s: %w", svc.Name(), spec.Subject, err)
+				}
+				t.sub = sub
+				entry.subs = append(entry.subs, t)
+			}
+		}
+	}
+	m.subjectsMu.Lock()
+	defer m.subjectsMu.Unlock()
+	if m.subjects == nil {
+		m.subjects = make(map[string]*serviceSubscriptions)
+	}
+	m.subjects[key] = entry
+	return nil
+}
+
+// unsubscribeService removes the subscriptions of the service name and waits
+// for its handlers in flight
+func (m *ServiceManager) unsubscribeService(name string) {
+	key := normalizeService(name)
+	if key == "" {
+		return
+	}
+	m.subjectsMu.Lock()
+	entry, ok := m.subjects[key]
+	delete(m.subjects, key)
+	m.subjectsMu.Unlock()
+	if ok {
+		entry.release(m.log, m.timeout)
+	}
+}
+
+// serviceEntry returns the subscriptions of the service name, or nil
+func (m *ServiceManager) serviceEntry(name string) *serviceSubscriptions {
+	m.subjectsMu.Lock()
+	defer m.subjectsMu.Unlock()
+	return m.subjects[normalizeService(name)]
+}
+
+// serviceHandler delivers the messages of the subscriptions of entry through
+// the router middleware
+func (m *ServiceManager) serviceHandler(entry *serviceSubscriptions) messaging.HandlerFunc {
+	return func(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+		if !entry.begin() {
+			// Delivered while the service was being unregistered
+			return nil
+		}
+		defer entry.end()
+		ctx, span := startRouteSpan(ctx, subject, env)
+		start := time.Now()
+		err := m.router.Wrap(entry.svc.Handle)(ctx, subject, env)
+		m.pipeline.handled(entry.name, env.Type, start, err)
+		endRouteSpan(span, entry.name, err)
+		return err
+	}
+}
+
+// serviceErrors reports the errors of the handlers of the service name
+func (m *ServiceManager) serviceErrors(name string) errorReporter {
+	return func(ctx context.Context, topic string, env *messaging.MessageEnvelope, err error) error {
+		return m.handlerError(ctx, name, topic, env, err)
+	}
+}
+
+// handlerError logs the error of service, "" when the message was not
+// routed, and replies with it to requests
+func (m *ServiceManager) handlerError(ctx context.Context, service, topic string, env *messaging.MessageEnvelope, err error) error {
+	m.log.Error("HandleMessage failed",
+		zap.Error(err),
+		zap.String("topic", topic),
+		zap.String("id", env.ID),
+	)
+	m.recentErrors.add(ErrorInfo{
+		Time:    time.Now().UTC(),
+		Service: service,
+		Topic:   topic,
+		ID:      env.ID,
+		Type:    env.Type,
+		Error:   err.Error(),
+	})
+	if env.Reply != "" && m.messenger != nil && m.messenger.Publisher != nil {
+		m.pipeline.errorReply(service, env.Type)
+		return m.messenger.Publisher.PublishError(ctx, env.Reply, err.Error())
+	}
+	return nil
+}
+
+// trackTopic records the subscription of SubscribeToTopics on topic
+func (m *ServiceManager) trackTopic(t *trackedSubscription) {
+	m.subjectsMu.Lock()
+	defer m.subjectsMu.Unlock()
+	if m.subjects == nil {
+		m.subjects = make(map[string]*serviceSubscriptions)
+	}
+	entry, ok := m.subjects[""]
+	if !ok {
+		entry = &serviceSubscriptions{}
+		m.subjects[""] = entry
+	}
+	entry.subs = append(entry.subs, t)
+}
+
+// untrackTopic forgets the subscriptions of SubscribeToTopics on topic
+func (m *ServiceManager) untrackTopic(topic string) {
+	m.subjectsMu.Lock()
+	defer m.subjectsMu.Unlock()
+	entry, ok := m.subjects[""]
+	if !ok {
+		return
+	}
+	kept := entry.subs[:0]
+	for _, t := range entry.subs {
+		if t.spec.Subject != topic {
+			kept = append(kept, t)
+		}
+	}
+	entry.subs = kept
+}
+
+// ListSubscriptions returns the NATS subscriptions of the manager by service
+// and subject, with their counters
+func (m *ServiceManager) ListSubscriptions() []SubscriptionInfo {
+	m.subjectsMu.Lock()
+	defer m.subjectsMu.Unlock()
+	infos := []SubscriptionInfo{}
+	for _, entry := range m.subjects {
+		for _, t := range entry.subs {
+			infos = append(infos, SubscriptionInfo{
+				Service:    entry.name,
+				Subject:    t.spec.Subject,
+				QueueGroup: t.spec.QueueGroup,
+				Workers:    t.spec.Workers,
+				Priority:   t.spec.Priority,
+				Received:   t.received.Load(),
+				Failed:     t.failed.Load(),
+				InFlight:   t.inFlight.Load(),
+				Pending:    t.pending(),
+			})
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Service != infos[j].Service {
+			return infos[i].Service < infos[j].Service
+		}
+		return infos[i].Subject < infos[j].Subject
+	})
+	return infos
+}
@@ -0,0 +1,244 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+	"grouter/pkg/tenant"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// subjectService records the subjects of the messages it handles
+type subjectService struct {
+	name     string
+	subjects []SubjectSpec
+	err      error
+
+	mu       sync.Mutex
+	received []string
+}
+
+func (s *subjectService) Name() string            { return s.name }
+func (s *subjectService) Subjects() []SubjectSpec { return s.subjects }
+
+func (s *subjectService) Handle(ctx context.Context, topic string, msg *messaging.MessageEnvelope) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.received = append(s.received, topic)
+	return s.err
+}
+
+func (s *subjectService) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.received)
+}
+
+func TestServiceManager_NATSService_Subjects(t *testing.T) {
+	mgr := newNATSManager(t, runNATSServer(t, false))
+	svc := &subjectService{name: "orders", subjects: []SubjectSpec{
+		{Subject: "orders.>", QueueGroup: "orders", Workers: 2},
+		{Subject: "audit.orders"},
+	}}
+	require.NoError(t, mgr.RegisterService(svc))
+	// Registering the same instance again does not subscribe twice
+	require.NoError(t, mgr.RegisterService(svc))
+
+	pub := mgr.messenger.Publisher
+	ctx := context.Background()
+	require.NoError(t, pub.Publish(ctx, "orders.created", "orders.created", nil, nil))
+	require.NoError(t, pub.Publish(ctx, "audit.orders", "audit", nil, nil))
+	require.Eventually(t, func() bool { return svc.count() == 2 }, 2*time.Second, 10*time.Millisecond)
+	assert.ElementsMatch(t, []string{"orders.created", "audit.orders"}, svc.received)
+
+	mgr.UnregisterService("orders")
+	require.NoError(t, pub.Publish(ctx, "orders.created", "orders.created", nil, nil))
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 2, svc.count(), "no messages after UnregisterService")
+}
+
+func TestServiceManager_NATSService_ReplyError(t *testing.T) {
+	mgr := newNATSManager(t, runNATSServer(t, false))
+	var seen []string
+	mgr.router.Use(func(next messaging.HandlerFunc) messaging.HandlerFunc {
+		return func(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+			seen = append(seen, subject)
+			return next(ctx, subject, env)
+		}
+	})
+	svc := &subjectService{name: "orders", subjects: []SubjectSpec{{Subject: "orders.get"}}, err: errors.New("not found")}
+	require.NoError(t, mgr.RegisterService(svc))
+
+	reply, err := mgr.messenger.Publisher.Request(context.Background(), "orders.get", "orders.get", nil, 2*time.Second)
+	require.NoError(t, err)
+	assert.Contains(t, string(reply.Data), "not found")
+	assert.Equal(t, []string{"orders.get"}, seen, "the router middleware applies")
+}
+
+func TestServiceManager_NATSService_WithoutNATS(t *testing.T) {
+	mgr := newLifecycleManager()
+	svc := &subjectService{name: "orders", subjects: []SubjectSpec{{Subject: "orders.>"}}}
+	require.NoError(t, mgr.RegisterService(svc), "subjects are skipped without NATS")
+	assert.Contains(t, mgr.ListServices(), "orders")
+}
+
+func TestServiceManager_UnsubscribeFromTopics(t *testing.T) {
+	mgr := newNATSManager(t, runNATSServer(t, false))
+	orders := &subjectService{name: "orders"}
+	payments := &subjectService{name: "payments"}
+	require.NoError(t, mgr.RegisterService(orders))
+	require.NoError(t, mgr.RegisterService(payments))
+	require.NoError(t, mgr.SubscribeToTopics("grouter.orders.>", ""))
+	require.NoError(t, mgr.SubscribeToTopics("grouter.payments.>", ""))
+
+	require.NoError(t, mgr.UnsubscribeFromTopics("grouter.orders.>"))
+
+	pub := mgr.messenger.Publisher
+	ctx := context.Background()
+	require.NoError(t, pub.Publish(ctx, "grouter.orders.create", "orders.create", nil, nil))
+	require.NoError(t, pub.Publish(ctx, "grouter.payments.create", "payments.create", nil, nil))
+	require.Eventually(t, func() bool { return payments.count() == 1 }, 2*time.Second, 10*time.Millisecond)
+	assert.Zero(t, orders.count(), "only the orders topic was removed")
+}
+
+func TestServiceManager_ListSubscriptions(t *testing.T) {
+	mgr := newNATSManager(t, runNATSServer(t, false))
+	orders := &subjectService{name: "orders", subjects: []SubjectSpec{
+		{Subject: "orders.>", QueueGroup: "orders", Workers: 2},
+	}}
+	audit := &subjectService{name: "audit", subjects: []SubjectSpec{{Subject: "audit.>"}}, err: errors.New("rejected")}
+	require.NoError(t, mgr.RegisterService(orders))
+	require.NoError(t, mgr.RegisterService(audit))
+	require.NoError(t, mgr.SubscribeToTopics("grouter.>", ""))
+
+	pub := mgr.messenger.Publisher
+	ctx := context.Background()
+	require.NoError(t, pub.Publish(ctx, "orders.created", "orders.created", nil, nil))
+	require.NoError(t, pub.Publish(ctx, "audit.orders", "audit", nil, nil))
+	require.NoError(t, pub.Publish(ctx, "grouter.orders.get", "orders.get", nil, nil))
+	require.Eventually(t, func() bool { return orders.count() == 2 && audit.count() == 1 }, 2*time.Second, 10*time.Millisecond)
+
+	require.Eventually(t, func() bool {
+		subs := mgr.ListSubscriptions()
+		return len(subs) == 3 && subs[0].Received == 1 && subs[1].Failed == 1 && subs[2].Received == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	subs := mgr.ListSubscriptions()
+	assert.Equal(t, SubscriptionInfo{Subject: "grouter.>", Received: 1}, subs[0], "SubscribeToTopics has no service")
+	assert.Equal(t, SubscriptionInfo{Service: "audit", Subject: "audit.>", Received: 1, Failed: 1}, subs[1])
+	assert.Equal(t, SubscriptionInfo{Service: "orders", Subject: "orders.>", QueueGroup: "orders", Workers: 2, Received: 1}, subs[2])
+
+	mgr.UnregisterService("audit")
+	require.NoError(t, mgr.UnsubscribeFromTopics("grouter.>"))
+	subs = mgr.ListSubscriptions()
+	require.Len(t, subs, 1)
+	assert.Equal(t, "orders", subs[0].Service)
+}
+
+// blockingService blocks its handlers until release is closed
+type blockingService struct {
+	subjectService
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingService) Handle(ctx context.Context, topic string, msg *messaging.MessageEnvelope) error {
+	s.started <- struct{}{}
+	<-s.release
+	return s.subjectService.Handle(ctx, topic, msg)
+}
+
+func TestServiceManager_UnregisterService_WaitsForHandlers(t *testing.T) {
+	for _, routed := range []bool{false, true} {
+		mgr := newNATSManager(t, runNATSServer(t, false))
+		mgr.timeout = 5 * time.Second
+		svc := &blockingService{
+			subjectService: subjectService{name: "orders"},
+			started:        make(chan struct{}, 1),
+			release:        make(chan struct{}),
+		}
+		subject := "grouter.orders.create"
+		if routed {
+			require.NoError(t, mgr.SubscribeToTopics("grouter.>", ""))
+		} else {
+			svc.subjects = []SubjectSpec{{Subject: subject}}
+		}
+		require.NoError(t, mgr.RegisterService(svc))
+		require.NoError(t, mgr.messenger.Publisher.Publish(context.Background(), subject, "orders.create", nil, nil))
+		<-svc.started
+
+		done := make(chan struct{})
+		go func() {
+			mgr.UnregisterService("orders")
+			close(done)
+		}()
+		select {
+		case <-done:
+			t.Fatalf("UnregisterService returned with a handler in flight (routed: %v)", routed)
+		case <-time.After(100 * time.Millisecond):
+		}
+		close(svc.release)
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("UnregisterService did not return (routed: %v)", routed)
+		}
+		assert.Equal(t, 1, svc.count())
+	}
+}
+
+func TestServiceManager_TenantSubjects(t *testing.T) {
+	mgr := newNATSManager(t, runNATSServer(t, false))
+	mgr.router.EnforceTenants("grouter")
+	tenants := make(chan string, 1)
+	svc := &tenantService{name: "orders", tenants: tenants}
+	require.NoError(t, mgr.RegisterService(svc))
+	require.NoError(t, mgr.SubscribeToTopics("grouter.*.orders.>", ""))
+
+	ctx := context.Background()
+	require.NoError(t, mgr.messenger.Publisher.Publish(ctx, "grouter.acme.orders.create", "orders.create", nil, nil))
+	select {
+	case id := <-tenants:
+		assert.Equal(t, "acme", id)
+	case <-time.After(2 * time.Second):
+		t.Fatal("message not routed")
+	}
+
+	// A message published for another tenant is rejected
+	reply, err := mgr.messenger.Publisher.Request(tenant.NewContext(ctx, "globex"), "grouter.acme.orders.get", "orders.get", nil, 2*time.Second)
+	require.NoError(t, err)
+	assert.Contains(t, string(reply.Data), "does not match")
+	assert.Empty(t, tenants)
+}
+
+// tenantService reports the tenant of the messages it handles
+type tenantService struct {
+	name    string
+	tenants chan string
+}
+
+func (s *tenantService) Name() string { return s.name }
+
+func (s *tenantService) Handle(ctx context.Context, topic string, msg *messaging.MessageEnvelope) error {
+	s.tenants <- tenant.FromContext(ctx)
+	return nil
+}
+
+func TestServiceManager_NATSService_PriorityLanes(t *testing.T) {
+	mgr := newNATSManager(t, runNATSServer(t, false))
+	svc := &subjectService{name: "jobs", subjects: []SubjectSpec{{Subject: "jobs.run", Workers: 4, Priority: true}}}
+	require.NoError(t, mgr.RegisterService(svc))
+
+	pub := mgr.messenger.Publisher
+	ctx := context.Background()
+	require.NoError(t, pub.Publish(ctx, "jobs.run", "jobs.run", nil, &messaging.PublishOptions{Priority: messaging.PriorityHigh}))
+	require.NoError(t, pub.Publish(ctx, "jobs.run", "jobs.run", nil, nil))
+	require.Eventually(t, func() bool { return svc.count() == 2 }, 2*time.Second, 10*time.Millisecond)
+	assert.ElementsMatch(t, []string{"jobs.run.p0", "jobs.run"}, svc.received)
+	assert.True(t, mgr.ListSubscriptions()[0].Priority)
+}
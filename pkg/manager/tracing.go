@@ -0,0 +1,46 @@
+package manager
+
+import (
+	"context"
+
+	messaging "grouter/pkg/messaging/nats"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName is the name of the tracer of the manager
+const instrumentationName = "grouter/pkg/manager"
+
+// spanNameRoute is the span name for routing messages to services
+const spanNameRoute = "grouter.route"
+
+// startRouteSpan starts the span routing a message received on subject to
+// its service, child of the consumer span of the subscriber. The replies of
+// the service are children of it. The tracer is looked up for each message,
+// so that the spans follow the tracer provider replaced on reload.
+func startRouteSpan(ctx context.Context, subject string, env *messaging.MessageEnvelope) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, spanNameRoute+" "+subject,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			attribute.String("messaging.subject", subject),
+			attribute.String("messaging.message_type", env.Type),
+			attribute.String("messaging.message_id", env.ID),
+		),
+	)
+}
+
+// endRouteSpan records the service, "" when the message was not routed, and
+// the error of a routing span, and ends it
+func endRouteSpan(span trace.Span, service string, err error) {
+	if service != "" {
+		span.SetAttributes(attribute.String("grouter.service", service))
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
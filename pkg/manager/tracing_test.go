@@ -0,0 +1,138 @@
+package manager
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"grouter/pkg/config"
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// replyService answers the messages of its topics on their reply subject
+type replyService struct {
+	mockService
+	mgr *ServiceManager
+}
+
+func (s *replyService) Handle(ctx context.Context, topic string, msg *messaging.MessageEnvelope) error {
+	return s.mgr.messenger.Publisher.Publish(ctx, msg.Reply, topic+".reply", map[string]string{"status": "ok"}, nil)
+}
+
+// traceRecorder records the spans of the global tracer provider, with the
+// W3C trace context propagator, until the end of the test
+func traceRecorder(t *testing.T) (*tracetest.InMemoryExporter, trace.Tracer) {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	provider, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(provider)
+		otel.SetTextMapPropagator(propagator)
+	})
+	return exporter, tp.Tracer("test")
+}
+
+// waitSpans returns the recorded spans by name once all the names are
+// recorded
+func waitSpans(t *testing.T, exporter *tracetest.InMemoryExporter, names ...string) map[string]tracetest.SpanStub {
+	t.Helper()
+	spans := make(map[string]tracetest.SpanStub)
+	require.Eventually(t, func() bool {
+		for _, span := range exporter.GetSpans() {
+			spans[span.Name] = span
+		}
+		for _, name := range names {
+			if _, ok := spans[name]; !ok {
+				return false
+			}
+		}
+		return true
+	}, 2*time.Second, 10*time.Millisecond, "spans %v recorded", names)
+	return spans
+}
+
+// replySpan returns the reply span once recorded
+func replySpan(t *testing.T, exporter *tracetest.InMemoryExporter) tracetest.SpanStub {
+	t.Helper()
+	var reply tracetest.SpanStub
+	require.Eventually(t, func() bool {
+		for _, span := range exporter.GetSpans() {
+			if strings.HasPrefix(span.Name, "messaging.reply ") {
+				reply = span
+				return true
+			}
+		}
+		return false
+	}, 2*time.Second, 10*time.Millisecond, "reply span recorded")
+	return reply
+}
+
+func TestServiceManager_TracePropagation(t *testing.T) {
+	exporter, tracer := traceRecorder(t)
+	mgr := newNATSManager(t, runNATSServer(t, false), func(cfg *config.Config) {
+		cfg.Tracing.Enabled = true
+	})
+	mgr.router.Register("orders", &replyService{mockService: mockService{name: "orders"}, mgr: mgr})
+	require.NoError(t, mgr.SubscribeToTopics("orders.>", ""))
+
+	ctx, client := tracer.Start(context.Background(), "client")
+	reply, err := mgr.messenger.Publisher.Request(ctx, "orders.get", "orders.get", nil, 2*time.Second)
+	client.End()
+	require.NoError(t, err)
+	assert.Equal(t, "orders.get.reply", reply.Type)
+
+	spans := waitSpans(t, exporter, "client", "messaging.request orders.get", "nats.process orders.get", "grouter.route orders.get")
+	request, process, route := spans["messaging.request orders.get"], spans["nats.process orders.get"], spans["grouter.route orders.get"]
+	response := replySpan(t, exporter)
+
+	traceID := spans["client"].SpanContext.TraceID()
+	for _, span := range []tracetest.SpanStub{request, process, route, response} {
+		assert.Equal(t, traceID, span.SpanContext.TraceID(), "%s belongs to the trace of the client", span.Name)
+	}
+	assert.Equal(t, spans["client"].SpanContext.SpanID(), request.Parent.SpanID())
+	assert.Equal(t, request.SpanContext.SpanID(), process.Parent.SpanID(), "the consumer continues the request")
+	assert.True(t, process.Parent.IsRemote())
+	assert.Equal(t, process.SpanContext.SpanID(), route.Parent.SpanID(), "routing happens within the consumer span")
+	assert.Equal(t, route.SpanContext.SpanID(), response.Parent.SpanID(), "the reply continues the routing span")
+	assert.Contains(t, route.Attributes, attribute.String("grouter.service", "orders"))
+
+	require.Len(t, response.Links, 1)
+	assert.Equal(t, request.SpanContext.SpanID(), response.Links[0].SpanContext.SpanID(), "the reply links to the request")
+	require.Len(t, request.Links, 1)
+	assert.Equal(t, response.SpanContext.SpanID(), request.Links[0].SpanContext.SpanID(), "the request links to the reply")
+}
+
+func TestServiceManager_TraceRoutingError(t *testing.T) {
+	exporter, _ := traceRecorder(t)
+	mgr := newNATSManager(t, runNATSServer(t, false), func(cfg *config.Config) {
+		cfg.Tracing.Enabled = true
+	})
+	require.NoError(t, mgr.SubscribeToTopics("unknown.>", ""))
+
+	reply, err := mgr.messenger.Publisher.Request(context.Background(), "unknown.get", "unknown.get", nil, 2*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "error", reply.Type)
+
+	route := waitSpans(t, exporter, "grouter.route unknown.get")["grouter.route unknown.get"]
+	assert.Equal(t, codes.Error, route.Status.Code, "routing errors fail the span")
+	require.Len(t, route.Events, 1)
+	assert.Equal(t, "exception", route.Events[0].Name)
+
+	response := replySpan(t, exporter)
+	assert.Contains(t, response.Attributes, attribute.String("messaging.message_type", "error"), "error replies are replies too")
+	require.Len(t, response.Links, 1)
+}
@@ -2,7 +2,15 @@ package manager
 
 import (
 	"context"
+
+	"grouter/pkg/cache"
+	"grouter/pkg/config"
+	"grouter/pkg/eventbus"
+	"grouter/pkg/health"
 	messaging "grouter/pkg/messaging/nats"
+	"grouter/pkg/telemetry"
+
+	"go.uber.org/zap"
 )
 
 // Service defines the base lifecycle interface for internal services.
@@ -17,3 +25,63 @@ type NATService interface {
 	// Handle processes an incoming message and returns a response envelope.
 	Handle(ctx context.Context, topic string, msg *messaging.MessageEnvelope) error
 }
+
+// NATSService is a NATService subscribing to subjects of its own.
+// RegisterService subscribes them and UnregisterService removes them; their
+// messages reach Handle with the subject as topic, through the router
+// middleware.
+type NATSService interface {
+	NATService
+	// Subjects returns the subscriptions of the service.
+	Subjects() []SubjectSpec
+}
+
+// SubjectSpec is a subscription of a NATSService
+type SubjectSpec struct {
+	// Subject may contain wildcards, e.g. "orders.>"
+	Subject string
+	// QueueGroup shares the messages between the instances of the service
+	QueueGroup string
+	// Workers bounds the messages handled at once (0 for no bound)
+	Workers int
+	// Priority also subscribes the .p0, .p1 and .p2 lanes of the subject,
+	// splitting Workers among them (see messaging.SubscribeOptions)
+	Priority bool
+}
+
+// ServiceV2 is a service whose lifecycle is driven by the manager.
+// RegisterService calls Init, Start runs with the manager (or on
+// registration once the manager runs), Stop on UnregisterService or when the
+// manager stops, and Health becomes the "service.<name>" readiness check.
+type ServiceV2 interface {
+	NATService
+	// Init prepares the service with the framework components, before any
+	// message or request reaches it.
+	Init(ctx context.Context, deps Deps) error
+	// Start begins the background work of the service.
+	Start(ctx context.Context) error
+	// Stop ends the background work of the service.
+	Stop(ctx context.Context) error
+	// Health reports whether the service can serve.
+	Health(ctx context.Context) error
+}
+
+// Deps are the framework components handed to ServiceV2.Init. Components
+// that are disabled are nil.
+type Deps struct {
+	Config    *config.Config
+	Logger    *zap.Logger
+	Messenger *messaging.Messenger
+	Events    *eventbus.Bus
+	Cache     cache.Cache
+	Health    *health.HealthService
+	Metrics   *telemetry.MetricsRegistry
+	Database  Database
+}
+
+// Database is a store owned by the manager, such as *database.Database
+type Database interface {
+	// HealthCheck pings the store
+	HealthCheck(ctx context.Context) error
+	Close() error
+}
@@ -0,0 +1,46 @@
+package amqp
+
+import (
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// message is the messaging.DriverMessage of a delivery, settled by a
+// messaging.DriverAcker. Rejected messages go to the DeadLetterExchange of
+// the queue, if any; those left unacknowledged under AckManual are requeued
+// by the broker when the subscription closes.
+type message struct {
+	delivery amqp.Delivery
+}
+
+var _ messaging.DriverMessage = message{}
+
+func (m message) Ack() error {
+	return m.delivery.Ack(false)
+}
+
+// Nak requeues the delivery, after delay if any, holding it meanwhile
+func (m message) Nak(delay time.Duration) error {
+	if delay <= 0 {
+		return m.delivery.Nack(false, true)
+	}
+	time.AfterFunc(delay, func() { _ = m.delivery.Nack(false, true) })
+	return nil
+}
+
+// InProgress does nothing: AMQP deliveries have no ack deadline
+func (m message) InProgress() error {
+	return nil
+}
+
+// Term rejects the delivery without requeueing it
+func (m message) Term() error {
+	return m.delivery.Nack(false, false)
+}
+
+func (m message) Redelivered() bool {
+	return m.delivery.Redelivered
+}
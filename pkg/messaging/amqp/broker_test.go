@@ -0,0 +1,437 @@
+package amqp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// fakeBroker is an in-memory AMQP broker of topic exchanges, enough for the
+// driver: queues with their bindings and round-robin consumers, acks, nacks
+// and requeues, exclusive and auto-delete queues, and the default exchange
+type fakeBroker struct {
+	mu        sync.Mutex
+	exchanges map[string]bool
+	queues    map[string]*fakeQueue
+	seq       int
+	// published are the messages published, by exchange
+	published map[string][]amqp.Publishing
+	// dead are the messages rejected without requeue
+	dead []amqp.Delivery
+	// nack makes the broker refuse to confirm publishes
+	nack bool
+}
+
+type fakeQueue struct {
+	name       string
+	durable    bool
+	autoDelete bool
+	owner      *fakeChannel
+	args       amqp.Table
+	bindings   []fakeBinding
+	ready      []amqp.Delivery
+	consumers  []*fakeConsumer
+	next       int
+}
+
+type fakeBinding struct {
+	exchange, key string
+}
+
+type fakeConsumer struct {
+	tag     string
+	ch      *fakeChannel
+	autoAck bool
+	out     chan amqp.Delivery
+}
+
+func newFakeBroker() *fakeBroker {
+	return &fakeBroker{
+		exchanges: map[string]bool{"": true},
+		queues:    make(map[string]*fakeQueue),
+		published: make(map[string][]amqp.Publishing),
+	}
+}
+
+// connect returns a new connection to the broker
+func (b *fakeBroker) connect() *fakeConnection {
+	return &fakeConnection{broker: b}
+}
+
+// queue returns the queue name, or nil
+func (b *fakeBroker) queue(name string) *fakeQueue {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.queues[name]
+}
+
+// deadLetters returns the messages rejected without requeue
+func (b *fakeBroker) deadLetters() []amqp.Delivery {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]amqp.Delivery(nil), b.dead...)
+}
+
+// messages returns the messages published on exchange
+func (b *fakeBroker) messages(exchange string) []amqp.Publishing {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]amqp.Publishing(nil), b.published[exchange]...)
+}
+
+// matchKey reports whether a routing key matches a binding key, where "*"
+// matches a word and "#" zero or more words
+func matchKey(binding, key string) bool {
+	return matchWords(strings.Split(binding, "."), strings.Split(key, "."))
+}
+
+func matchWords(binding, key []string) bool {
+	if len(binding) == 0 {
+		return len(key) == 0
+	}
+	if binding[0] == "#" {
+		for i := 0; i <= len(key); i++ {
+			if matchWords(binding[1:], key[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(key) == 0 || (binding[0] != "*" && binding[0] != key[0]) {
+		return false
+	}
+	return matchWords(binding[1:], key[1:])
+}
+
+// route enqueues msg on the queues bound to exchange by key; b.mu is held
+func (b *fakeBroker) route(exchange, key string, msg amqp.Publishing) {
+	if exchange == "" {
+		if q := b.queues[key]; q != nil {
+			b.enqueue(q, delivery(exchange, key, msg))
+		}
+		return
+	}
+	for _, q := range b.queues {
+		for _, binding := range q.bindings {
+			if binding.exchange == exchange && matchKey(binding.key, key) {
+				b.enqueue(q, delivery(exchange, key, msg))
+				break
+			}
+		}
+	}
+}
+
+func delivery(exchange, key string, msg amqp.Publishing) amqp.Delivery {
+	return amqp.Delivery{
+		Headers:       msg.Headers,
+		ContentType:   msg.ContentType,
+		DeliveryMode:  msg.DeliveryMode,
+		CorrelationId: msg.CorrelationId,
+		ReplyTo:       msg.ReplyTo,
+		Expiration:    msg.Expiration,
+		MessageId:     msg.MessageId,
+		Timestamp:     msg.Timestamp,
+		Type:          msg.Type,
+		AppId:         msg.AppId,
+		Exchange:      exchange,
+		RoutingKey:    key,
+		Body:          append([]byte(nil), msg.Body...),
+	}
+}
+
+// enqueue hands d to the next consumer of q, or keeps it; b.mu is held
+func (b *fakeBroker) enqueue(q *fakeQueue, d amqp.Delivery) {
+	if len(q.consumers) == 0 {
+		q.ready = append(q.ready, d)
+		return
+	}
+	c := q.consumers[q.next%len(q.consumers)]
+	q.next++
+	c.ch.seq++
+	d.DeliveryTag = c.ch.seq
+	d.ConsumerTag = c.tag
+	d.Acknowledger = c.ch
+	if !c.autoAck {
+		c.ch.unacked[d.DeliveryTag] = unacked{delivery: d, queue: q}
+	}
+	c.out <- d
+}
+
+// removeConsumer cancels the consumer tag of q, deleting q when it is
+// auto-delete and has no consumer left; b.mu is held
+func (b *fakeBroker) removeConsumer(q *fakeQueue, tag string) {
+	for i, c := range q.consumers {
+		if c.tag == tag {
+			close(c.out)
+			q.consumers = append(q.consumers[:i], q.consumers[i+1:]...)
+			break
+		}
+	}
+	if q.autoDelete && len(q.consumers) == 0 {
+		delete(b.queues, q.name)
+	}
+}
+
+type fakeConnection struct {
+	broker *fakeBroker
+
+	mu       sync.Mutex
+	closed   bool
+	channels []*fakeChannel
+}
+
+func (c *fakeConnection) Channel() (channel, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil, amqp.ErrClosed
+	}
+	ch := &fakeChannel{broker: c.broker, unacked: make(map[uint64]unacked)}
+	c.channels = append(c.channels, ch)
+	return ch, nil
+}
+
+func (c *fakeConnection) IsClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+func (c *fakeConnection) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return amqp.ErrClosed
+	}
+	c.closed = true
+	channels := c.channels
+	c.mu.Unlock()
+	for _, ch := range channels {
+		_ = ch.Close()
+	}
+	return nil
+}
+
+// unacked is a delivery of a channel waiting for its acknowledgement
+type unacked struct {
+	delivery amqp.Delivery
+	queue    *fakeQueue
+}
+
+type fakeChannel struct {
+	broker  *fakeBroker
+	closed  bool
+	confirm bool
+	seq     uint64
+	unacked map[uint64]unacked
+	// consumers are the consumer tags of the channel by queue
+	consumers map[string]*fakeQueue
+}
+
+var errChannelClosed = errors.New("fake channel closed")
+
+func (ch *fakeChannel) ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error {
+	b := ch.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ch.closed {
+		return errChannelClosed
+	}
+	b.exchanges[name] = true
+	return nil
+}
+
+func (ch *fakeChannel) QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	b := ch.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ch.closed {
+		return amqp.Queue{}, errChannelClosed
+	}
+	if name == "" {
+		b.seq++
+		name = fmt.Sprintf("%s%d", replyPrefix, b.seq)
+	}
+	q := b.queues[name]
+	if q == nil {
+		q = &fakeQueue{name: name, durable: durable, autoDelete: autoDelete, args: args}
+		if exclusive {
+			q.owner = ch
+		}
+		b.queues[name] = q
+	}
+	return amqp.Queue{Name: name, Messages: len(q.ready), Consumers: len(q.consumers)}, nil
+}
+
+func (ch *fakeChannel) QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error {
+	b := ch.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	q := b.queues[name]
+	switch {
+	case ch.closed:
+		return errChannelClosed
+	case q == nil:
+		return fmt.Errorf("no queue %s", name)
+	case !b.exchanges[exchange]:
+		return fmt.Errorf("no exchange %s", exchange)
+	}
+	q.bindings = append(q.bindings, fakeBinding{exchange: exchange, key: key})
+	return nil
+}
+
+func (ch *fakeChannel) Qos(prefetchCount, prefetchSize int, global bool) error {
+	return nil
+}
+
+func (ch *fakeChannel) Confirm(noWait bool) error {
+	ch.confirm = true
+	return nil
+}
+
+func (ch *fakeChannel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error) {
+	b := ch.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	q := b.queues[queue]
+	if ch.closed {
+		return nil, errChannelClosed
+	}
+	if q == nil {
+		return nil, fmt.Errorf("no queue %s", queue)
+	}
+	if consumer == "" {
+		b.seq++
+		consumer = fmt.Sprintf("ctag-%d", b.seq)
+	}
+	if ch.consumers == nil {
+		ch.consumers = make(map[string]*fakeQueue)
+	}
+	ch.consumers[consumer] = q
+	c := &fakeConsumer{tag: consumer, ch: ch, autoAck: autoAck, out: make(chan amqp.Delivery, 1024)}
+	q.consumers = append(q.consumers, c)
+	ready := q.ready
+	q.ready = nil
+	for _, d := range ready {
+		b.enqueue(q, d)
+	}
+	return c.out, nil
+}
+
+func (ch *fakeChannel) Cancel(consumer string, noWait bool) error {
+	b := ch.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+	if q := ch.consumers[consumer]; q != nil {
+		delete(ch.consumers, consumer)
+		b.removeConsumer(q, consumer)
+	}
+	return nil
+}
+
+func (ch *fakeChannel) IsClosed() bool {
+	ch.broker.mu.Lock()
+	defer ch.broker.mu.Unlock()
+	return ch.closed
+}
+
+// Close cancels the consumers of the channel, requeues its unacknowledged
+// deliveries and deletes its exclusive queues
+func (ch *fakeChannel) Close() error {
+	b := ch.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+	ch.closed = true
+	for tag, q := range ch.consumers {
+		b.removeConsumer(q, tag)
+	}
+	for _, u := range ch.unacked {
+		if b.queues[u.queue.name] == u.queue {
+			d := u.delivery
+			d.Redelivered = true
+			b.enqueue(u.queue, d)
+		}
+	}
+	ch.unacked = nil
+	for name, q := range b.queues {
+		if q.owner == ch {
+			delete(b.queues, name)
+		}
+	}
+	return nil
+}
+
+func (ch *fakeChannel) publish(ctx context.Context, exchange, key string, msg amqp.Publishing) (confirmation, error) {
+	b := ch.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ch.closed {
+		return nil, amqp.ErrClosed
+	}
+	if !b.exchanges[exchange] {
+		// A channel error of the broker closes the channel
+		ch.closed = true
+		return nil, fmt.Errorf("no exchange %s", exchange)
+	}
+	b.published[exchange] = append(b.published[exchange], msg)
+	b.route(exchange, key, msg)
+	if !ch.confirm {
+		return nil, nil
+	}
+	return fakeConfirmation(!b.nack), nil
+}
+
+// fakeConfirmation is the confirmation of a publish, acked or not
+type fakeConfirmation bool
+
+func (c fakeConfirmation) WaitContext(ctx context.Context) (bool, error) {
+	return bool(c), nil
+}
+
+// settle removes the delivery tag from the unacknowledged deliveries,
+// requeueing or dead-lettering it
+func (ch *fakeChannel) settle(tag uint64, ack, requeue bool) error {
+	b := ch.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+	u, ok := ch.unacked[tag]
+	if !ok {
+		return fmt.Errorf("unknown delivery tag %d", tag)
+	}
+	delete(ch.unacked, tag)
+	switch {
+	case ack:
+	case requeue:
+		d := u.delivery
+		d.Redelivered = true
+		b.enqueue(u.queue, d)
+	default:
+		b.dead = append(b.dead, u.delivery)
+	}
+	return nil
+}
+
+func (ch *fakeChannel) Ack(tag uint64, multiple bool) error {
+	return ch.settle(tag, true, false)
+}
+
+func (ch *fakeChannel) Nack(tag uint64, multiple, requeue bool) error {
+	return ch.settle(tag, false, requeue)
+}
+
+func (ch *fakeChannel) Reject(tag uint64, requeue bool) error {
+	return ch.settle(tag, false, requeue)
+}
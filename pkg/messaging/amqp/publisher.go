@@ -0,0 +1,170 @@
+package amqp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/nats-io/nats.go"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Publisher publishes envelopes on the exchanges of their subjects. It
+// implements messaging.Publisher, without JetStream and payload store.
+type Publisher struct {
+	*messaging.DriverPublisher
+	driver *Driver
+	// skipConfirm returns sync publishes without waiting for the broker
+	// confirmation
+	skipConfirm bool
+}
+
+var _ messaging.Publisher = (*Publisher)(nil)
+
+func newPublisher(d *Driver, source string) *Publisher {
+	p := &Publisher{driver: d}
+	p.DriverPublisher = messaging.NewDriverPublisher(source, d.logger, p.publish, p.request)
+	return p
+}
+
+// SetPayloadStore is a no-op: the broker takes messages of any size up to
+// its max message size
+func (p *Publisher) SetPayloadStore(messaging.PayloadStore) {}
+
+// SetSkipFlush makes sync publishes return without waiting for the
+// confirmation of the broker, like async ones
+func (p *Publisher) SetSkipFlush(skip bool) {
+	p.skipConfirm = skip
+}
+
+// HealthCheck reports whether the connection to the broker is open
+func (p *Publisher) HealthCheck() error {
+	return p.driver.HealthCheck()
+}
+
+// publish sends the envelope, waiting for the confirmation of the broker
+// unless async
+func (p *Publisher) publish(ctx context.Context, subject string, envelope *messaging.MessageEnvelope, opts *messaging.PublishOptions) error {
+	wait := (opts == nil || !opts.Async) && !p.skipConfirm
+	return p.send(ctx, subject, envelope, "", wait)
+}
+
+// message returns the AMQP message of the signed envelope published on
+// subject: the JSON envelope, or its data for plain exchange routes
+func (p *Publisher) message(subject string, envelope *messaging.MessageEnvelope, replyTo string) (amqp.Publishing, error) {
+	msg := amqp.Publishing{
+		ContentType:   "application/json",
+		DeliveryMode:  amqp.Persistent,
+		MessageId:     envelope.ID,
+		Type:          envelope.Type,
+		Timestamp:     envelope.Timestamp,
+		AppId:         envelope.Source,
+		CorrelationId: envelope.CorrelationID,
+		ReplyTo:       replyTo,
+	}
+	if !envelope.ExpiresAt.IsZero() {
+		msg.Expiration = strconv.FormatInt(max(time.Until(envelope.ExpiresAt).Milliseconds(), 1), 10)
+	}
+
+	if r := p.driver.route(subject); r != nil && r.Plain {
+		msg.Headers = amqp.Table{}
+		for k, v := range envelope.Metadata {
+			msg.Headers[k] = v
+		}
+		msg.Body = envelope.Data
+		return msg, nil
+	}
+
+	body, err := p.Marshal(subject, envelope)
+	if err != nil {
+		return amqp.Publishing{}, err
+	}
+	msg.Headers = amqp.Table{HeaderEnvelope: int32(envelope.Version)}
+	msg.Body = body
+	return msg, nil
+}
+
+// send publishes the envelope on the exchange of subject, waiting for the
+// confirmation of the broker when wait is set
+func (p *Publisher) send(ctx context.Context, subject string, envelope *messaging.MessageEnvelope, replyTo string, wait bool) error {
+	msg, err := p.message(subject, envelope, replyTo)
+	if err != nil {
+		return err
+	}
+	ch, err := p.driver.channel()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.driver.cfg.PublishTimeout)
+	defer cancel()
+	confirm, err := ch.publish(ctx, p.driver.exchange(subject), subject, msg)
+	if err != nil {
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
+	if !wait || confirm == nil {
+		return nil
+	}
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to confirm message: %w", err)
+	}
+	if !acked {
+		return errors.New("message not confirmed by the broker")
+	}
+	return nil
+}
+
+// request publishes the request with an exclusive queue of the broker as
+// reply subject, and waits for the first message of the queue
+func (p *Publisher) request(ctx context.Context, subject string, envelope *messaging.MessageEnvelope) (*messaging.MessageEnvelope, error) {
+	// The queue is deleted with the channel
+	ch, err := p.driver.conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open AMQP channel: %w", err)
+	}
+	defer func() { _ = ch.Close() }()
+	queue, err := ch.QueueDeclare("", false, true, true, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to declare reply queue: %w", err)
+	}
+	replies, err := ch.Consume(queue.Name, "", true, true, false, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume reply queue: %w", err)
+	}
+
+	if err := p.send(ctx, subject, envelope, queue.Name, true); err != nil {
+		return nil, err
+	}
+
+	var reply amqp.Delivery
+	select {
+	case d, ok := <-replies:
+		if !ok {
+			return nil, fmt.Errorf("request failed: %w", amqp.ErrClosed)
+		}
+		reply = d
+	case <-ctx.Done():
+		return nil, fmt.Errorf("request failed: %w", ctx.Err())
+	}
+
+	var response messaging.MessageEnvelope
+	if err := decode(reply, p.Codec(), &response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return &response, nil
+}
+
+// PublishJS is not supported: AMQP has no JetStream
+func (p *Publisher) PublishJS(ctx context.Context, subject string, msgType string, data interface{}, opts ...nats.PubOpt) (*nats.PubAck, error) {
+	return nil, fmt.Errorf("amqp: PublishJS: %w, use Publish: queues are durable", errors.ErrUnsupported)
+}
+
+// PublishAsyncJS is not supported: AMQP has no JetStream
+func (p *Publisher) PublishAsyncJS(ctx context.Context, subject string, msgType string, data interface{}, opts ...nats.PubOpt) (nats.PubAckFuture, error) {
+	return nil, fmt.Errorf("amqp: PublishAsyncJS: %w, use Publish with Async", errors.ErrUnsupported)
+}
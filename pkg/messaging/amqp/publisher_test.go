@@ -0,0 +1,94 @@
+package amqp
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublisher_Publish(t *testing.T) {
+	broker := newFakeBroker()
+	d := connectFake(t, broker, Config{})
+	ctx := messaging.WithCorrelationID(context.Background(), "flow-1")
+
+	require.NoError(t, d.Publisher().Publish(ctx, "orders.created", "order.created", map[string]int{"id": 42}, &messaging.PublishOptions{TTL: time.Minute}))
+
+	msgs := broker.messages("grouter")
+	require.Len(t, msgs, 1)
+	msg := msgs[0]
+	assert.Equal(t, "order.created", msg.Type)
+	assert.Equal(t, "test", msg.AppId)
+	assert.Equal(t, "flow-1", msg.CorrelationId)
+	assert.NotEmpty(t, msg.Expiration, "the TTL expires the message in the queues")
+	assert.Contains(t, msg.Headers, HeaderEnvelope)
+
+	var env messaging.MessageEnvelope
+	require.NoError(t, json.Unmarshal(msg.Body, &env))
+	assert.Equal(t, msg.MessageId, env.ID)
+	assert.JSONEq(t, `{"id":42}`, string(env.Data))
+	assert.False(t, env.ExpiresAt.IsZero())
+}
+
+func TestPublisher_PlainExchange(t *testing.T) {
+	broker := newFakeBroker()
+	d := connectFake(t, broker, Config{Exchanges: []ExchangeRoute{{Subject: "legacy.>", Exchange: "legacy", Plain: true}}})
+
+	require.NoError(t, d.Publisher().Publish(context.Background(), "legacy.orders.created", "order.created", map[string]int{"id": 42}, nil))
+
+	msgs := broker.messages("legacy")
+	require.Len(t, msgs, 1)
+	assert.JSONEq(t, `{"id":42}`, string(msgs[0].Body), "plain messages carry the data")
+	assert.Equal(t, "order.created", msgs[0].Type)
+	assert.NotContains(t, msgs[0].Headers, HeaderEnvelope)
+}
+
+func TestPublisher_Confirms(t *testing.T) {
+	broker := newFakeBroker()
+	d := connectFake(t, broker, Config{})
+	broker.nack = true
+
+	err := d.Publisher().Publish(context.Background(), "orders.created", "order.created", nil, nil)
+	assert.ErrorContains(t, err, "not confirmed")
+	assert.NoError(t, d.Publisher().Publish(context.Background(), "orders.created", "order.created", nil, &messaging.PublishOptions{Async: true}), "async publishes do not wait")
+
+	d.Publisher().SetSkipFlush(true)
+	assert.NoError(t, d.Publisher().Publish(context.Background(), "orders.created", "order.created", nil, nil))
+}
+
+func TestPublisher_ReopensChannel(t *testing.T) {
+	broker := newFakeBroker()
+	d := connectFake(t, broker, Config{})
+	ch, err := d.channel()
+	require.NoError(t, err)
+	require.NoError(t, ch.Close())
+
+	require.NoError(t, d.Publisher().Publish(context.Background(), "orders.created", "order.created", nil, nil))
+	assert.Len(t, broker.messages("grouter"), 1)
+}
+
+func TestPublisher_Request(t *testing.T) {
+	broker := newFakeBroker()
+	client, server := connectFake(t, broker, Config{}), connectFake(t, broker, Config{})
+	require.NoError(t, server.Subscriber().Subscribe("echo", func(ctx context.Context, subject string, msg *messaging.MessageEnvelope) error {
+		_, ok := messaging.RequestDeadline(ctx)
+		assert.True(t, ok, "requests carry their deadline")
+		return messaging.Reply(ctx, server.Publisher(), msg, "echo.reply", json.RawMessage(msg.Data))
+	}, nil))
+
+	reply, err := client.Publisher().Request(context.Background(), "echo", "echo", map[string]string{"msg": "hi"}, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "echo.reply", reply.Type)
+	assert.JSONEq(t, `{"msg":"hi"}`, string(reply.Data))
+
+	_, err = client.Publisher().Request(context.Background(), "nobody", "echo", nil, 50*time.Millisecond)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	assert.Len(t, broker.queues, 1, "reply queues are deleted, the queue of the responder is left")
+}
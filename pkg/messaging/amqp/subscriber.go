@@ -0,0 +1,229 @@
+package amqp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.uber.org/zap"
+)
+
+// Subscriber consumes queues bound to the exchanges of the subscribed
+// subjects. It implements messaging.Subscriber, without JetStream, payload
+// store and priority lanes.
+//
+// A subscription without queue group consumes a queue of its own, deleted
+// with the subscription. The members of a queue group share the durable
+// queue <QueuePrefix><group>.<subject>, which keeps the messages while no
+// member runs.
+//
+// Messages are acknowledged once handled, see message. Messages without the
+// HeaderEnvelope header, from RabbitMQ applications, are handed as
+// envelopes of their body: its JSON, or its bytes otherwise.
+type Subscriber struct {
+	*messaging.DriverSubscriber
+	driver *Driver
+}
+
+var _ messaging.Subscriber = (*Subscriber)(nil)
+
+func newSubscriber(d *Driver) *Subscriber {
+	s := &Subscriber{driver: d}
+	s.DriverSubscriber = messaging.NewDriverSubscriber("amqp", d.logger, s.subscribe, d.close)
+	return s
+}
+
+// subscribe declares the queue of a subscription, binds it to the exchange
+// of subject and consumes it with MaxWorkers handlers (default 1)
+func (s *Subscriber) subscribe(subject string, handler messaging.HandlerFunc, opts *messaging.SubscribeOptions) (messaging.DriverSubscription, error) {
+	cfg := s.driver.cfg
+	workers := max(opts.MaxWorkers, 1)
+
+	ch, err := s.driver.conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open AMQP channel: %w", err)
+	}
+	sub, err := s.consume(ch, subject, opts.QueueGroup, max(cfg.Prefetch, workers))
+	if err != nil {
+		_ = ch.Close()
+		return nil, err
+	}
+
+	for i := 0; i < workers; i++ {
+		sub.workers.Add(1)
+		go sub.run(handler)
+	}
+
+	s.driver.logger.Info("Subscribed to subject",
+		zap.String("subject", subject),
+		zap.String("queue", sub.queue),
+		zap.String("queue_group", opts.QueueGroup),
+	)
+	return sub, nil
+}
+
+// consume declares and binds the queue of a subscription to subject on ch,
+// and starts consuming it
+func (s *Subscriber) consume(ch channel, subject, group string, prefetch int) (*subscription, error) {
+	cfg := s.driver.cfg
+	if err := ch.Qos(prefetch, 0, false); err != nil {
+		return nil, fmt.Errorf("failed to set prefetch: %w", err)
+	}
+
+	var queue amqp.Queue
+	var err error
+	if group == "" {
+		queue, err = ch.QueueDeclare("", false, true, true, false, nil)
+	} else {
+		var args amqp.Table
+		if cfg.DeadLetterExchange != "" {
+			args = amqp.Table{"x-dead-letter-exchange": cfg.DeadLetterExchange}
+		}
+		queue, err = ch.QueueDeclare(cfg.QueuePrefix+group+"."+subject, true, false, false, false, args)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to declare queue of %s: %w", subject, err)
+	}
+	exchange := s.driver.exchange(subject)
+	if err := ch.QueueBind(queue.Name, bindingKey(subject), exchange, false, nil); err != nil {
+		return nil, fmt.Errorf("failed to bind queue %s to %s: %w", queue.Name, exchange, err)
+	}
+
+	tag := "grouter-" + uuid.NewString()
+	deliveries, err := ch.Consume(queue.Name, tag, false, false, false, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume queue %s: %w", queue.Name, err)
+	}
+	return &subscription{
+		subscriber: s,
+		subject:    subject,
+		queue:      queue.Name,
+		tag:        tag,
+		ch:         ch,
+		deliveries: deliveries,
+		done:       make(chan struct{}),
+	}, nil
+}
+
+// decode reads the envelope of a delivery: the body of the messages with
+// the HeaderEnvelope header, or a new envelope of the body of plain messages
+func decode(d amqp.Delivery, codec messaging.Codec, envelope *messaging.MessageEnvelope) error {
+	if _, ok := d.Headers[HeaderEnvelope]; ok {
+		return messaging.DecodeEnvelope(d.Body, d.RoutingKey, codec, envelope)
+	}
+
+	data := json.RawMessage(d.Body)
+	if !json.Valid(d.Body) {
+		// []byte marshals to a base64 string
+		raw, err := json.Marshal(d.Body)
+		if err != nil {
+			return err
+		}
+		data = raw
+	}
+	*envelope = messaging.MessageEnvelope{
+		ID:            d.MessageId,
+		Type:          d.Type,
+		Timestamp:     d.Timestamp,
+		Source:        d.AppId,
+		CorrelationID: d.CorrelationId,
+		Data:          data,
+		Version:       messaging.EnvelopeVersion,
+	}
+	if envelope.ID == "" {
+		envelope.ID = uuid.NewString()
+	}
+	if envelope.Type == "" {
+		envelope.Type = DefaultMessageType
+	}
+	if envelope.Timestamp.IsZero() {
+		envelope.Timestamp = time.Now()
+	}
+	for k, v := range d.Headers {
+		if s, ok := v.(string); ok {
+			if envelope.Metadata == nil {
+				envelope.Metadata = make(map[string]string)
+			}
+			envelope.Metadata[k] = s
+		}
+	}
+	return nil
+}
+
+// process hands a delivery to the handler and acknowledges it
+func (s *Subscriber) process(d amqp.Delivery, handler messaging.HandlerFunc) {
+	s.Process(messaging.Delivery{
+		Subject: d.RoutingKey,
+		Message: message{delivery: d},
+		Decode: func(codec messaging.Codec, envelope *messaging.MessageEnvelope) error {
+			if err := decode(d, codec, envelope); err != nil {
+				return err
+			}
+			envelope.Reply = d.ReplyTo
+			return nil
+		},
+	}, handler)
+}
+
+// subscription is a consumer of the queue of a subject, on a channel of its
+// own
+type subscription struct {
+	subscriber *Subscriber
+	subject    string
+	queue      string
+	tag        string
+	ch         channel
+	deliveries <-chan amqp.Delivery
+	// workers are the handlers consuming deliveries
+	workers  sync.WaitGroup
+	stopOnce sync.Once
+	// done is closed once the handlers returned and the channel is closed
+	done chan struct{}
+}
+
+func (s *subscription) Subject() string {
+	return s.subject
+}
+
+// run hands the deliveries to handler until the consumer is canceled
+func (s *subscription) run(handler messaging.HandlerFunc) {
+	defer s.workers.Done()
+	for d := range s.deliveries {
+		s.subscriber.AddPending(1)
+		// The binding key of ">" also matches the subject itself
+		if messaging.MatchSubject(s.subject, d.RoutingKey) {
+			s.subscriber.process(d, handler)
+		} else {
+			_ = d.Ack(false)
+		}
+		s.subscriber.AddPending(-1)
+	}
+}
+
+// Stop cancels the consumer, then closes the channel once the handlers
+// return, so that they can still acknowledge their messages. The broker
+// requeues the messages left unacknowledged.
+func (s *subscription) Stop() error {
+	var err error
+	s.stopOnce.Do(func() {
+		if cerr := s.ch.Cancel(s.tag, false); cerr != nil && !errors.Is(cerr, amqp.ErrClosed) {
+			err = fmt.Errorf("failed to cancel consumer of %s: %w", s.subject, cerr)
+		}
+		go func() {
+			defer close(s.done)
+			s.workers.Wait()
+			_ = s.ch.Close()
+		}()
+	})
+	return err
+}
+
+func (s *subscription) Done() <-chan struct{} {
+	return s.done
+}
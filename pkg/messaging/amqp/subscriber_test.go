@@ -0,0 +1,84 @@
+package amqp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// publishPlain publishes body on exchange as a RabbitMQ application does
+func publishPlain(t *testing.T, broker *fakeBroker, exchange, key string, msg amqp.Publishing) {
+	t.Helper()
+	ch, err := broker.connect().Channel()
+	require.NoError(t, err)
+	_, err = ch.publish(context.Background(), exchange, key, msg)
+	require.NoError(t, err)
+}
+
+func TestSubscriber_PlainMessages(t *testing.T) {
+	broker := newFakeBroker()
+	d := connectFake(t, broker, Config{Exchanges: []ExchangeRoute{{Subject: "legacy.>", Exchange: "legacy"}}})
+	received := make(chan *messaging.MessageEnvelope, 2)
+	require.NoError(t, d.Subscriber().Subscribe("legacy.orders.*", func(ctx context.Context, subject string, msg *messaging.MessageEnvelope) error {
+		assert.Equal(t, "legacy.orders.created", subject)
+		received <- msg
+		return nil
+	}, nil))
+
+	publishPlain(t, broker, "legacy", "legacy.orders.created", amqp.Publishing{
+		MessageId: "m1", Type: "order.created", AppId: "shop", CorrelationId: "c1",
+		Headers: amqp.Table{"tenant_id": "acme"},
+		Body:    []byte(`{"id":42}`),
+	})
+	publishPlain(t, broker, "legacy", "legacy.orders.created", amqp.Publishing{Body: []byte("raw")})
+
+	env := <-received
+	assert.Equal(t, "m1", env.ID)
+	assert.Equal(t, "order.created", env.Type)
+	assert.Equal(t, "shop", env.Source)
+	assert.Equal(t, "c1", env.CorrelationID)
+	assert.Equal(t, "acme", env.TenantID())
+	assert.JSONEq(t, `{"id":42}`, string(env.Data))
+
+	env = <-received
+	assert.NotEmpty(t, env.ID)
+	assert.Equal(t, DefaultMessageType, env.Type)
+	assert.JSONEq(t, `"cmF3"`, string(env.Data), "non-JSON bodies are bytes")
+}
+
+func TestSubscriber_QueueGroupQueue(t *testing.T) {
+	broker := newFakeBroker()
+	d := connectFake(t, broker, Config{QueuePrefix: "svc.", DeadLetterExchange: "dlx"})
+	sub, err := d.Subscriber().SubscribeSubject("orders.>", func(context.Context, string, *messaging.MessageEnvelope) error { return nil }, &messaging.SubscribeOptions{QueueGroup: "billing", MaxWorkers: 4})
+	require.NoError(t, err)
+
+	q := broker.queue("svc.billing.orders.>")
+	require.NotNil(t, q)
+	assert.True(t, q.durable)
+	assert.Equal(t, amqp.Table{"x-dead-letter-exchange": "dlx"}, q.args)
+	assert.Equal(t, []fakeBinding{{exchange: "grouter", key: "orders.#"}}, q.bindings)
+
+	require.NoError(t, sub.Drain(context.Background()))
+	assert.NotNil(t, broker.queue("svc.billing.orders.>"), "queue group queues outlive their members")
+}
+
+func TestSubscriber_QueueDepth(t *testing.T) {
+	broker := newFakeBroker()
+	d := connectFake(t, broker, Config{})
+	release := make(chan struct{})
+	require.NoError(t, d.Subscriber().Subscribe("slow", func(context.Context, string, *messaging.MessageEnvelope) error {
+		<-release
+		return nil
+	}, nil))
+	require.NoError(t, d.Publisher().Publish(context.Background(), "slow", "slow", nil, nil))
+
+	assert.Eventually(t, func() bool { return d.Subscriber().QueueDepth() == 1 }, time.Second, 10*time.Millisecond)
+	close(release)
+	assert.Eventually(t, func() bool { return d.Subscriber().QueueDepth() == 0 }, time.Second, 10*time.Millisecond)
+}
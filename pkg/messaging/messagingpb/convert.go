@@ -0,0 +1,191 @@
+// Package messagingpb holds the Go types generated from the protocol buffers
+// definitions of api/proto/grouter/messaging/v1: the message envelope, the
+// health reports and the control messages, the wire contract of services not
+// written in Go. The helpers convert them from and to the types of
+// pkg/messaging/nats, pkg/health and pkg/profiling.
+//
+// Regenerate the types with buf from the repository root:
+//
+//	buf generate
+package messagingpb
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"time"
+
+	"grouter/pkg/health"
+	messaging "grouter/pkg/messaging/nats"
+	"grouter/pkg/profiling"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// FromEnvelope returns the protobuf form of env
+func FromEnvelope(env *messaging.MessageEnvelope) *Envelope {
+	return &Envelope{
+		Id:            env.ID,
+		Type:          env.Type,
+		Timestamp:     timestamp(env.Timestamp),
+		Source:        env.Source,
+		Reply:         env.Reply,
+		CorrelationId: env.CorrelationID,
+		CausationId:   env.CausationID,
+		Data:          env.Data,
+		Metadata:      maps.Clone(env.Metadata),
+		ExpiresAt:     timestamp(env.ExpiresAt),
+		Version:       int32(env.Version),
+	}
+}
+
+// ToEnvelope returns the MessageEnvelope of x
+func (x *Envelope) ToEnvelope() *messaging.MessageEnvelope {
+	return &messaging.MessageEnvelope{
+		ID:            x.GetId(),
+		Type:          x.GetType(),
+		Timestamp:     goTime(x.GetTimestamp()),
+		Source:        x.GetSource(),
+		Reply:         x.GetReply(),
+		CorrelationID: x.GetCorrelationId(),
+		CausationID:   x.GetCausationId(),
+		Data:          json.RawMessage(x.GetData()),
+		Metadata:      maps.Clone(x.GetMetadata()),
+		ExpiresAt:     goTime(x.GetExpiresAt()),
+		Version:       int(x.GetVersion()),
+	}
+}
+
+// MarshalEnvelope encodes env in the protobuf binary format
+func MarshalEnvelope(env *messaging.MessageEnvelope) ([]byte, error) {
+	data, err := proto.Marshal(FromEnvelope(env))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal envelope: %w", err)
+	}
+	return data, nil
+}
+
+// UnmarshalEnvelope decodes an envelope in the protobuf binary format
+func UnmarshalEnvelope(data []byte) (*messaging.MessageEnvelope, error) {
+	var env Envelope
+	if err := proto.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal envelope: %w", err)
+	}
+	return env.ToEnvelope(), nil
+}
+
+// UnmarshalData decodes the JSON data of env into m, e.g. a HealthReport of
+// a "health.report" reply. Unknown fields are ignored, so that data gaining
+// fields keeps decoding.
+func UnmarshalData(env *messaging.MessageEnvelope, m proto.Message) error {
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(env.Data, m); err != nil {
+		return fmt.Errorf("failed to unmarshal %s data: %w", env.Type, err)
+	}
+	return nil
+}
+
+// FromHealthReport returns the protobuf form of r
+func FromHealthReport(r *health.Report) *HealthReport {
+	return &HealthReport{
+		Status:     r.Status,
+		Probe:      r.Probe,
+		App:        r.App,
+		Version:    r.Version,
+		Instance:   r.Instance,
+		Timestamp:  timestamp(r.Timestamp),
+		DurationMs: r.DurationMs,
+		Liveness:   fromChecks(r.Liveness),
+		Readiness:  fromChecks(r.Readiness),
+		Startup:    fromChecks(r.Startup),
+	}
+}
+
+// ToReport returns the health.Report of x
+func (x *HealthReport) ToReport() *health.Report {
+	return &health.Report{
+		Status:     x.GetStatus(),
+		Probe:      x.GetProbe(),
+		App:        x.GetApp(),
+		Version:    x.GetVersion(),
+		Instance:   x.GetInstance(),
+		Timestamp:  goTime(x.GetTimestamp()),
+		DurationMs: x.GetDurationMs(),
+		Liveness:   toChecks(x.GetLiveness()),
+		Readiness:  toChecks(x.GetReadiness()),
+		Startup:    toChecks(x.GetStartup()),
+	}
+}
+
+func fromChecks(checks map[string]health.CheckResult) map[string]*CheckResult {
+	if checks == nil {
+		return nil
+	}
+	out := make(map[string]*CheckResult, len(checks))
+	for name, c := range checks {
+		out[name] = &CheckResult{Status: c.Status, Error: c.Error, Critical: c.Critical, DurationMs: c.DurationMs}
+	}
+	return out
+}
+
+func toChecks(checks map[string]*CheckResult) map[string]health.CheckResult {
+	if checks == nil {
+		return nil
+	}
+	out := make(map[string]health.CheckResult, len(checks))
+	for name, c := range checks {
+		out[name] = health.CheckResult{Status: c.GetStatus(), Error: c.GetError(), Critical: c.GetCritical(), DurationMs: c.GetDurationMs()}
+	}
+	return out
+}
+
+// FromProfileRequest returns the protobuf form of r
+func FromProfileRequest(r profiling.Request) *ProfileRequest {
+	return &ProfileRequest{Type: r.Type, Seconds: int32(r.Seconds)}
+}
+
+// ToRequest returns the profiling.Request of x
+func (x *ProfileRequest) ToRequest() profiling.Request {
+	return profiling.Request{Type: x.GetType(), Seconds: int(x.GetSeconds())}
+}
+
+// FromProfileResult returns the protobuf form of r
+func FromProfileResult(r *profiling.Result) *ProfileResult {
+	return &ProfileResult{
+		Instance: r.Instance,
+		Type:     r.Type,
+		Bucket:   r.Bucket,
+		Object:   r.Object,
+		Size:     r.Size,
+		Captured: timestamp(r.Captured),
+	}
+}
+
+// ToResult returns the profiling.Result of x
+func (x *ProfileResult) ToResult() *profiling.Result {
+	return &profiling.Result{
+		Instance: x.GetInstance(),
+		Type:     x.GetType(),
+		Bucket:   x.GetBucket(),
+		Object:   x.GetObject(),
+		Size:     x.GetSize(),
+		Captured: goTime(x.GetCaptured()),
+	}
+}
+
+// timestamp returns the protobuf form of t, nil for the zero time
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// goTime returns the UTC time of ts, the zero time when unset
+func goTime(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
@@ -0,0 +1,127 @@
+package messagingpb
+
+import (
+	"encoding/json"
+	"sort"
+	"testing"
+	"time"
+
+	"grouter/pkg/health"
+	messaging "grouter/pkg/messaging/nats"
+	"grouter/pkg/profiling"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func testEnvelope() *messaging.MessageEnvelope {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 6, time.UTC)
+	return &messaging.MessageEnvelope{
+		ID:            "id-1",
+		Type:          "order.created",
+		Timestamp:     now,
+		Source:        "orders",
+		Reply:         "_INBOX.1",
+		CorrelationID: "flow-1",
+		CausationID:   "id-0",
+		Data:          json.RawMessage(`{"id":42}`),
+		Metadata:      map[string]string{messaging.MetadataTenantID: "acme"},
+		ExpiresAt:     now.Add(time.Minute),
+		Version:       messaging.EnvelopeVersion,
+	}
+}
+
+func TestEnvelope_RoundTrip(t *testing.T) {
+	env := testEnvelope()
+	data, err := MarshalEnvelope(env)
+	require.NoError(t, err)
+
+	got, err := UnmarshalEnvelope(data)
+	require.NoError(t, err)
+	assert.Equal(t, env, got)
+
+	got, err = UnmarshalEnvelope([]byte{})
+	require.NoError(t, err)
+	assert.True(t, got.Timestamp.IsZero(), "unset times are zero")
+	assert.True(t, got.ExpiresAt.IsZero())
+	assert.Nil(t, FromEnvelope(got).ExpiresAt, "zero times are unset")
+
+	_, err = UnmarshalEnvelope([]byte("not protobuf"))
+	assert.ErrorContains(t, err, "failed to unmarshal envelope")
+}
+
+func TestEnvelope_FieldNames(t *testing.T) {
+	data, err := json.Marshal(testEnvelope())
+	require.NoError(t, err)
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal(data, &doc))
+	var jsonNames []string
+	for name := range doc {
+		jsonNames = append(jsonNames, name)
+	}
+
+	var protoNames []string
+	fields := (&Envelope{}).ProtoReflect().Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		protoNames = append(protoNames, string(fields.Get(i).Name()))
+	}
+	sort.Strings(jsonNames)
+	sort.Strings(protoNames)
+	assert.Equal(t, jsonNames, protoNames, "the JSON envelope and the protobuf one share their field names")
+}
+
+func TestHealthReport(t *testing.T) {
+	report := &health.Report{
+		Status:     health.StatusDegraded,
+		Probe:      health.ProbeDetail,
+		App:        "grouter",
+		Version:    "1.2.3",
+		Instance:   "pod-1",
+		Timestamp:  time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		DurationMs: 1.5,
+		Liveness:   map[string]health.CheckResult{"goroutines": {Status: health.StatusUp, Critical: true, DurationMs: 0.1}},
+		Readiness:  map[string]health.CheckResult{"cache": {Status: health.StatusDown, Error: "timeout", DurationMs: 1}},
+	}
+	data, err := json.Marshal(report)
+	require.NoError(t, err)
+
+	var got HealthReport
+	require.NoError(t, UnmarshalData(&messaging.MessageEnvelope{Type: "health.report", Data: data}, &got))
+	assert.True(t, proto.Equal(FromHealthReport(report), &got), "the JSON replies decode into the protobuf report")
+	assert.Equal(t, report, got.ToReport())
+}
+
+func TestProfileMessages(t *testing.T) {
+	req := profiling.Request{Type: profiling.TypeCPU, Seconds: 10}
+	data, err := json.Marshal(req)
+	require.NoError(t, err)
+	var pbReq ProfileRequest
+	require.NoError(t, UnmarshalData(&messaging.MessageEnvelope{Type: profiling.RequestType, Data: data}, &pbReq))
+	assert.True(t, proto.Equal(FromProfileRequest(req), &pbReq))
+	assert.Equal(t, req, pbReq.ToRequest())
+
+	res := &profiling.Result{
+		Instance: "pod-1",
+		Type:     profiling.TypeHeap,
+		Bucket:   "profiles",
+		Object:   "pod-1/heap-20260102T030405Z.pprof",
+		Size:     1 << 40,
+		Captured: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	data, err = json.Marshal(res)
+	require.NoError(t, err)
+	var pbRes ProfileResult
+	require.NoError(t, UnmarshalData(&messaging.MessageEnvelope{Type: profiling.ResultType, Data: data}, &pbRes))
+	assert.True(t, proto.Equal(FromProfileResult(res), &pbRes))
+	assert.Equal(t, res, pbRes.ToResult())
+}
+
+func TestUnmarshalData(t *testing.T) {
+	var reply ErrorReply
+	require.NoError(t, UnmarshalData(&messaging.MessageEnvelope{Type: "error", Data: json.RawMessage(`{"error":"boom","code":7}`)}, &reply))
+	assert.Equal(t, "boom", reply.GetError(), "unknown fields are ignored")
+
+	err := UnmarshalData(&messaging.MessageEnvelope{Type: "error", Data: json.RawMessage(`{"error":1}`)}, &reply)
+	assert.ErrorContains(t, err, "failed to unmarshal error data")
+}
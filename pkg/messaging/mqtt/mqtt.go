@@ -0,0 +1,462 @@
+// Package mqtt bridges an MQTT broker and NATS for IoT deployments. Inbound
+// routes republish the messages of MQTT topics as envelopes on NATS
+// subjects; outbound routes publish the envelopes of NATS subjects on MQTT
+// topics.
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+	"grouter/pkg/telemetry"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// DefaultMessageType is the envelope type of inbound messages of routes
+// without a type
+const DefaultMessageType = "mqtt.message"
+
+// Direction is the direction of the messages of a route
+type Direction string
+
+const (
+	// Inbound routes republish MQTT messages on NATS
+	Inbound Direction = "inbound"
+	// Outbound routes publish NATS envelopes on MQTT
+	Outbound Direction = "outbound"
+)
+
+// Results of the bridged messages, in the metrics.
+const (
+	resultForwarded = "forwarded"
+	resultFailed    = "failed"
+	resultInvalid   = "invalid"
+)
+
+// Config holds the broker connection and the routes of a Bridge.
+type Config struct {
+	// Broker is the URL of the MQTT broker: tcp://, ssl://, ws:// or wss://.
+	Broker string `mapstructure:"broker"`
+	// ClientID identifies the session on the broker. Defaults to a random
+	// grouter-<uuid>, which cannot resume a session.
+	ClientID string `mapstructure:"client_id"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	// CleanSession discards the session of ClientID on connect. Without it,
+	// the broker keeps the subscriptions and the unacknowledged QoS 1 and 2
+	// messages while the bridge is away.
+	CleanSession bool `mapstructure:"clean_session"`
+	// KeepAlive is the interval of the pings to the broker.
+	KeepAlive time.Duration `mapstructure:"keep_alive"`
+	// ConnectTimeout bounds the first connection and the subscriptions.
+	ConnectTimeout time.Duration `mapstructure:"connect_timeout"`
+	// PublishTimeout bounds the publishes on NATS of inbound messages and
+	// the acknowledgements of outbound publishes.
+	PublishTimeout time.Duration `mapstructure:"publish_timeout"`
+	// Routes are the topics and subjects bridged.
+	Routes []Route `mapstructure:"routes"`
+	// Registry receives the metrics. Nil uses the global registry.
+	Registry *telemetry.MetricsRegistry `mapstructure:"-"`
+}
+
+// Route maps MQTT topics to NATS subjects, or the reverse. Patterns capture
+// levels with {name} wildcards and the trailing multi-level wildcard (# or >)
+// as {rest}; templates use the captures of the pattern:
+//
+//	inbound:  topic devices/{device}/telemetry/#  subject iot.{device}.telemetry.{rest}
+//	outbound: subject iot.{device}.commands       topic   devices/{device}/commands
+type Route struct {
+	// Name identifies the route in logs and metrics.
+	Name      string    `mapstructure:"name"`
+	Direction Direction `mapstructure:"direction"`
+	// Topic is the MQTT topic pattern of inbound routes, or the MQTT topic
+	// template of outbound routes.
+	Topic string `mapstructure:"topic"`
+	// Subject is the NATS subject template of inbound routes, or the NATS
+	// subject pattern of outbound routes.
+	Subject string `mapstructure:"subject"`
+	// QoS is the MQTT quality of service of the subscription of inbound
+	// routes, or of the publishes of outbound routes: 0, 1 or 2.
+	QoS byte `mapstructure:"qos"`
+	// Type is the envelope type of inbound messages, DefaultMessageType
+	// when empty.
+	Type string `mapstructure:"type"`
+	// Retain publishes outbound messages as retained messages.
+	Retain bool `mapstructure:"retain"`
+	// Envelope publishes the whole JSON envelope of outbound messages
+	// instead of their data.
+	Envelope bool `mapstructure:"envelope"`
+	// QueueGroup shares the outbound subscription between the instances so
+	// that each envelope is published once. Defaults to mqtt.<name>.
+	QueueGroup string `mapstructure:"queue_group"`
+}
+
+// DefaultConfig returns the default bridge configuration.
+func DefaultConfig() Config {
+	return Config{
+		CleanSession:   true,
+		KeepAlive:      30 * time.Second,
+		ConnectTimeout: 10 * time.Second,
+		PublishTimeout: 5 * time.Second,
+	}
+}
+
+// bridgeMetrics are the per-route metrics of a Bridge.
+type bridgeMetrics struct {
+	messages  *prometheus.CounterVec
+	bytes     *prometheus.CounterVec
+	connected *prometheus.GaugeVec
+}
+
+func newBridgeMetrics(reg *telemetry.MetricsRegistry) *bridgeMetrics {
+	return &bridgeMetrics{
+		messages: reg.CounterVec(prometheus.CounterOpts{
+			Name: "mqtt_bridge_messages_total",
+			Help: "Total number of messages bridged between MQTT and NATS by route, direction and result (forwarded, failed, invalid)",
+		}, []string{"route", "direction", "result"}),
+		bytes: reg.CounterVec(prometheus.CounterOpts{
+			Name: "mqtt_bridge_message_bytes_total",
+			Help: "Total payload bytes of the messages forwarded between MQTT and NATS",
+		}, []string{"route", "direction"}),
+		connected: reg.GaugeVec(prometheus.GaugeOpts{
+			Name: "mqtt_bridge_connected",
+			Help: "Whether the bridge is connected to the MQTT broker (1) or not (0)",
+		}, []string{"broker"}),
+	}
+}
+
+func (m *bridgeMetrics) count(r *Route, result string, size int) {
+	m.messages.WithLabelValues(r.Name, string(r.Direction), result).Inc()
+	if result == resultForwarded {
+		m.bytes.WithLabelValues(r.Name, string(r.Direction)).Add(float64(size))
+	}
+}
+
+// inboundRoute is an inbound route with its parsed topic and subject
+type inboundRoute struct {
+	Route
+	topic   *pattern
+	subject *template
+}
+
+// outboundRoute is an outbound route with its parsed subject and topic
+type outboundRoute struct {
+	Route
+	subject *pattern
+	topic   *template
+}
+
+// Bridge forwards messages between an MQTT broker and NATS.
+type Bridge struct {
+	cfg        Config
+	publisher  messaging.Publisher
+	subscriber messaging.Subscriber
+	logger     *zap.Logger
+	metrics    *bridgeMetrics
+
+	inbound  []*inboundRoute
+	outbound []*outboundRoute
+
+	client  paho.Client
+	started atomic.Bool
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	mu   sync.Mutex
+	subs []messaging.Subscription
+}
+
+// New creates a Bridge, validating the routes and applying defaults. The
+// publisher is required by inbound routes, the subscriber by outbound ones.
+func New(publisher messaging.Publisher, subscriber messaging.Subscriber, cfg Config, logger *zap.Logger) (*Bridge, error) {
+	defaults := DefaultConfig()
+	if cfg.Broker == "" {
+		return nil, errors.New("mqtt broker is required")
+	}
+	if cfg.ClientID == "" {
+		cfg.ClientID = "grouter-" + uuid.NewString()
+	}
+	if cfg.KeepAlive <= 0 {
+		cfg.KeepAlive = defaults.KeepAlive
+	}
+	if cfg.ConnectTimeout <= 0 {
+		cfg.ConnectTimeout = defaults.ConnectTimeout
+	}
+	if cfg.PublishTimeout <= 0 {
+		cfg.PublishTimeout = defaults.PublishTimeout
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &Bridge{
+		cfg:        cfg,
+		publisher:  publisher,
+		subscriber: subscriber,
+		logger:     logger.With(zap.String("broker", cfg.Broker)),
+		metrics:    newBridgeMetrics(cfg.Registry),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+	for i, r := range cfg.Routes {
+		if err := b.addRoute(r); err != nil {
+			cancel()
+			return nil, fmt.Errorf("mqtt route %d: %w", i, err)
+		}
+	}
+	if len(b.inbound) > 0 && publisher == nil {
+		cancel()
+		return nil, errors.New("mqtt inbound routes require a publisher")
+	}
+	if len(b.outbound) > 0 && subscriber == nil {
+		cancel()
+		return nil, errors.New("mqtt outbound routes require a subscriber")
+	}
+
+	opts := paho.NewClientOptions().
+		AddBroker(cfg.Broker).
+		SetClientID(cfg.ClientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetCleanSession(cfg.CleanSession).
+		SetKeepAlive(cfg.KeepAlive).
+		SetConnectTimeout(cfg.ConnectTimeout).
+		SetAutoReconnect(true).
+		// Inbound messages are acknowledged once published on NATS
+		SetAutoAckDisabled(true).
+		SetOnConnectHandler(b.onConnect).
+		SetConnectionLostHandler(b.onConnectionLost)
+	b.client = paho.NewClient(opts)
+	return b, nil
+}
+
+func (b *Bridge) addRoute(r Route) error {
+	if r.Name == "" {
+		return errors.New("name is required")
+	}
+	if r.QoS > 2 {
+		return fmt.Errorf("route %s: qos must be 0, 1 or 2, got %d", r.Name, r.QoS)
+	}
+	switch r.Direction {
+	case Inbound:
+		topic, err := parsePattern(r.Topic, mqttSyntax)
+		if err != nil {
+			return fmt.Errorf("route %s: %w", r.Name, err)
+		}
+		subject, err := parseTemplate(r.Subject, natsSyntax, topic)
+		if err != nil {
+			return fmt.Errorf("route %s: %w", r.Name, err)
+		}
+		if r.Type == "" {
+			r.Type = DefaultMessageType
+		}
+		b.inbound = append(b.inbound, &inboundRoute{Route: r, topic: topic, subject: subject})
+	case Outbound:
+		subject, err := parsePattern(r.Subject, natsSyntax)
+		if err != nil {
+			return fmt.Errorf("route %s: %w", r.Name, err)
+		}
+		topic, err := parseTemplate(r.Topic, mqttSyntax, subject)
+		if err != nil {
+			return fmt.Errorf("route %s: %w", r.Name, err)
+		}
+		if r.QueueGroup == "" {
+			r.QueueGroup = "mqtt." + r.Name
+		}
+		b.outbound = append(b.outbound, &outboundRoute{Route: r, subject: subject, topic: topic})
+	default:
+		return fmt.Errorf("route %s: direction must be %s or %s, got %q", r.Name, Inbound, Outbound, r.Direction)
+	}
+	return nil
+}
+
+// Start connects to the broker, subscribes to the topics of the inbound
+// routes and to the subjects of the outbound routes
+func (b *Bridge) Start() error {
+	token := b.client.Connect()
+	if !token.WaitTimeout(b.cfg.ConnectTimeout) {
+		b.client.Disconnect(0)
+		return fmt.Errorf("failed to connect to MQTT broker %s: timeout after %s", b.cfg.Broker, b.cfg.ConnectTimeout)
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("failed to connect to MQTT broker %s: %w", b.cfg.Broker, err)
+	}
+	if err := b.subscribeTopics(); err != nil {
+		b.Stop()
+		return err
+	}
+	b.started.Store(true)
+
+	for _, r := range b.outbound {
+		sub, err := b.subscriber.SubscribeSubject(r.subject.filter(), b.outboundHandler(r), &messaging.SubscribeOptions{QueueGroup: r.QueueGroup})
+		if err != nil {
+			b.Stop()
+			return fmt.Errorf("failed to subscribe mqtt route %s to %s: %w", r.Name, r.subject.filter(), err)
+		}
+		b.mu.Lock()
+		b.subs = append(b.subs, sub)
+		b.mu.Unlock()
+	}
+	for _, r := range b.inbound {
+		b.logger.Info("MQTT route registered", zap.String("route", r.Name), zap.String("direction", string(r.Direction)),
+			zap.String("topic", r.topic.filter()), zap.String("subject", r.Subject))
+	}
+	for _, r := range b.outbound {
+		b.logger.Info("MQTT route registered", zap.String("route", r.Name), zap.String("direction", string(r.Direction)),
+			zap.String("subject", r.subject.filter()), zap.String("topic", r.Topic))
+	}
+	return nil
+}
+
+// subscribeTopics subscribes to the topics of the inbound routes
+func (b *Bridge) subscribeTopics() error {
+	for _, r := range b.inbound {
+		token := b.client.Subscribe(r.topic.filter(), r.QoS, b.inboundHandler(r))
+		if !token.WaitTimeout(b.cfg.ConnectTimeout) {
+			return fmt.Errorf("failed to subscribe mqtt route %s to %s: timeout", r.Name, r.topic.filter())
+		}
+		if err := token.Error(); err != nil {
+			return fmt.Errorf("failed to subscribe mqtt route %s to %s: %w", r.Name, r.topic.filter(), err)
+		}
+	}
+	return nil
+}
+
+// Stop unsubscribes from NATS and disconnects from the broker, letting the
+// pending work end for up to a quarter of a second
+func (b *Bridge) Stop() {
+	b.mu.Lock()
+	subs := b.subs
+	b.subs = nil
+	b.mu.Unlock()
+	for _, sub := range subs {
+		if err := sub.Unsubscribe(); err != nil {
+			b.logger.Warn("Failed to unsubscribe MQTT route", zap.Error(err))
+		}
+	}
+	b.started.Store(false)
+	b.client.Disconnect(250)
+	b.cancel()
+	b.metrics.connected.WithLabelValues(b.cfg.Broker).Set(0)
+}
+
+// HealthCheck reports an error while the bridge is not connected to the
+// broker
+func (b *Bridge) HealthCheck() error {
+	if !b.client.IsConnectionOpen() {
+		return fmt.Errorf("not connected to MQTT broker %s", b.cfg.Broker)
+	}
+	return nil
+}
+
+func (b *Bridge) onConnect(paho.Client) {
+	b.metrics.connected.WithLabelValues(b.cfg.Broker).Set(1)
+	if !b.started.Load() {
+		return
+	}
+	// A clean session lost the subscriptions
+	b.logger.Info("Reconnected to MQTT broker")
+	if err := b.subscribeTopics(); err != nil {
+		b.logger.Error("Failed to resubscribe to MQTT topics", zap.Error(err))
+	}
+}
+
+func (b *Bridge) onConnectionLost(_ paho.Client, err error) {
+	b.metrics.connected.WithLabelValues(b.cfg.Broker).Set(0)
+	b.logger.Warn("Lost connection to MQTT broker, reconnecting", zap.Error(err))
+}
+
+// inboundHandler publishes the messages of r on NATS. Messages are
+// acknowledged once published, so that a broker keeping the session
+// redelivers the QoS 1 and 2 messages NATS did not get. Messages whose topic
+// makes no valid subject are acknowledged and dropped.
+func (b *Bridge) inboundHandler(r *inboundRoute) paho.MessageHandler {
+	return func(_ paho.Client, msg paho.Message) {
+		subject, err := b.inboundSubject(r, msg.Topic())
+		if err != nil {
+			b.metrics.count(&r.Route, resultInvalid, 0)
+			b.logger.Warn("MQTT message dropped", zap.String("route", r.Name), zap.String("topic", msg.Topic()), zap.Error(err))
+			msg.Ack()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(b.ctx, b.cfg.PublishTimeout)
+		defer cancel()
+		if err := b.publisher.Publish(ctx, subject, r.Type, payloadData(msg.Payload()), nil); err != nil {
+			b.metrics.count(&r.Route, resultFailed, 0)
+			b.logger.Error("Failed to publish MQTT message on NATS",
+				zap.String("route", r.Name), zap.String("topic", msg.Topic()), zap.String("subject", subject), zap.Error(err))
+			if msg.Qos() == 0 {
+				msg.Ack()
+			}
+			return
+		}
+		b.metrics.count(&r.Route, resultForwarded, len(msg.Payload()))
+		msg.Ack()
+	}
+}
+
+func (b *Bridge) inboundSubject(r *inboundRoute, topic string) (string, error) {
+	c, ok := r.topic.match(topic)
+	if !ok {
+		return "", fmt.Errorf("topic does not match %s", r.topic.filter())
+	}
+	return r.subject.render(c)
+}
+
+// payloadData returns the envelope data of an MQTT payload: the payload
+// itself when it is JSON, else the payload as bytes, a base64 JSON string
+func payloadData(payload []byte) any {
+	if len(payload) > 0 && json.Valid(payload) {
+		return json.RawMessage(payload)
+	}
+	return payload
+}
+
+// outboundHandler publishes the envelopes of r on MQTT, waiting for the
+// acknowledgement of the broker
+func (b *Bridge) outboundHandler(r *outboundRoute) messaging.HandlerFunc {
+	return func(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+		c, ok := r.subject.match(subject)
+		if !ok {
+			b.metrics.count(&r.Route, resultInvalid, 0)
+			return fmt.Errorf("subject %s does not match %s", subject, r.subject.filter())
+		}
+		topic, err := r.topic.render(c)
+		if err != nil {
+			b.metrics.count(&r.Route, resultInvalid, 0)
+			b.logger.Warn("NATS message dropped", zap.String("route", r.Name), zap.String("subject", subject), zap.Error(err))
+			return nil
+		}
+
+		payload := []byte(env.Data)
+		if r.Envelope {
+			if payload, err = json.Marshal(env); err != nil {
+				b.metrics.count(&r.Route, resultFailed, 0)
+				return fmt.Errorf("failed to marshal envelope: %w", err)
+			}
+		}
+		token := b.client.Publish(topic, r.QoS, r.Retain, payload)
+		if !token.WaitTimeout(b.cfg.PublishTimeout) {
+			b.metrics.count(&r.Route, resultFailed, 0)
+			return fmt.Errorf("failed to publish on MQTT topic %s: timeout after %s", topic, b.cfg.PublishTimeout)
+		}
+		if err := token.Error(); err != nil {
+			b.metrics.count(&r.Route, resultFailed, 0)
+			return fmt.Errorf("failed to publish on MQTT topic %s: %w", topic, err)
+		}
+		b.metrics.count(&r.Route, resultForwarded, len(payload))
+		return nil
+	}
+}
@@ -0,0 +1,190 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+	"grouter/pkg/messaging/nats/mocks"
+	"grouter/pkg/telemetry"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// runBroker runs a NATS server with its MQTT listener until the end of the
+// test and returns the broker URL
+func runBroker(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	require.NoError(t, l.Close())
+
+	s, err := server.NewServer(&server.Options{
+		ServerName: "mqtt-test",
+		Host:       "127.0.0.1",
+		Port:       -1,
+		NoLog:      true,
+		NoSigs:     true,
+		JetStream:  true,
+		StoreDir:   t.TempDir(),
+		MQTT:       server.MQTTOpts{Host: "127.0.0.1", Port: port},
+	})
+	require.NoError(t, err)
+	go s.Start()
+	t.Cleanup(s.Shutdown)
+	require.True(t, s.ReadyForConnections(10*time.Second), "broker not ready")
+	return fmt.Sprintf("tcp://127.0.0.1:%d", port)
+}
+
+// connect returns an MQTT client of broker, standing for a device
+func connect(t *testing.T, broker string) paho.Client {
+	t.Helper()
+	c := paho.NewClient(paho.NewClientOptions().AddBroker(broker).SetClientID("device-" + t.Name()))
+	token := c.Connect()
+	require.True(t, token.WaitTimeout(5*time.Second))
+	require.NoError(t, token.Error())
+	t.Cleanup(func() { c.Disconnect(0) })
+	return c
+}
+
+func startBridge(t *testing.T, cfg Config) (*Bridge, *mocks.Publisher, *mocks.Subscriber) {
+	t.Helper()
+	pub, sub := mocks.NewPublisher(), mocks.NewSubscriber()
+	if cfg.Registry == nil {
+		cfg.Registry = telemetry.NewMetricsRegistry()
+	}
+	b, err := New(pub, sub, cfg, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, b.Start())
+	t.Cleanup(b.Stop)
+	return b, pub, sub
+}
+
+func TestBridge_Inbound(t *testing.T) {
+	broker := runBroker(t)
+	b, pub, _ := startBridge(t, Config{Broker: broker, Routes: []Route{{
+		Name:      "telemetry",
+		Direction: Inbound,
+		Topic:     "devices/{device}/telemetry/#",
+		Subject:   "iot.{device}.telemetry.{rest}",
+		QoS:       1,
+		Type:      "device.telemetry",
+	}}})
+	require.NoError(t, b.HealthCheck())
+
+	device := connect(t, broker)
+	require.NoError(t, device.Publish("devices/d1/telemetry/temp", 1, false, `{"celsius":21.5}`).Error())
+	require.NoError(t, device.Publish("devices/d.2/telemetry/temp", 1, false, `{}`).Error())
+	require.NoError(t, device.Publish("devices/d3/telemetry/raw", 1, false, []byte{0x01, 0x02}).Error())
+
+	require.Eventually(t, func() bool {
+		return len(pub.Messages()) == 2 &&
+			testutil.ToFloat64(b.metrics.messages.WithLabelValues("telemetry", "inbound", resultInvalid)) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	msgs := pub.Messages()
+	assert.Equal(t, "iot.d1.telemetry.temp", msgs[0].Subject)
+	assert.Equal(t, "device.telemetry", msgs[0].Type)
+	assert.Equal(t, json.RawMessage(`{"celsius":21.5}`), msgs[0].Data)
+	assert.Equal(t, "iot.d3.telemetry.raw", msgs[1].Subject)
+	assert.Equal(t, []byte{0x01, 0x02}, msgs[1].Data, "binary payloads are bytes, base64 in the envelope")
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(b.metrics.messages.WithLabelValues("telemetry", "inbound", resultForwarded)))
+	assert.Equal(t, 18.0, testutil.ToFloat64(b.metrics.bytes.WithLabelValues("telemetry", "inbound")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(b.metrics.connected.WithLabelValues(broker)))
+}
+
+func TestBridge_InboundPublishFailure(t *testing.T) {
+	broker := runBroker(t)
+	b, pub, _ := startBridge(t, Config{Broker: broker, Routes: []Route{{
+		Name: "status", Direction: Inbound, Topic: "devices/+/status", Subject: "iot.status",
+	}}})
+	pub.FailWith(errors.New("nats down"))
+
+	device := connect(t, broker)
+	require.NoError(t, device.Publish("devices/d1/status", 0, false, "online").Error())
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(b.metrics.messages.WithLabelValues("status", "inbound", resultFailed)) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestBridge_Outbound(t *testing.T) {
+	broker := runBroker(t)
+	b, _, sub := startBridge(t, Config{Broker: broker, Routes: []Route{
+		{Name: "commands", Direction: Outbound, Subject: "iot.{device}.commands", Topic: "devices/{device}/commands", QoS: 1},
+		{Name: "events", Direction: Outbound, Subject: "iot.events.>", Topic: "events/{rest}", Envelope: true},
+	}})
+
+	device := connect(t, broker)
+	received := make(chan paho.Message, 2)
+	token := device.Subscribe("#", 1, func(_ paho.Client, msg paho.Message) { received <- msg })
+	require.True(t, token.WaitTimeout(5*time.Second))
+	require.NoError(t, token.Error())
+
+	commands, ok := sub.Subscription("iot.*.commands")
+	require.True(t, ok)
+	assert.Equal(t, "mqtt.commands", commands.QueueGroup())
+	ctx := context.Background()
+	require.NoError(t, sub.Deliver(ctx, "iot.d1.commands", &messaging.MessageEnvelope{ID: "c1", Type: "reboot", Data: json.RawMessage(`{"delay":5}`)}))
+	require.NoError(t, sub.Deliver(ctx, "iot.events.fw.updated", &messaging.MessageEnvelope{ID: "e1", Type: "fw.updated", Data: json.RawMessage(`{}`)}))
+
+	got := map[string][]byte{}
+	for len(got) < 2 {
+		select {
+		case msg := <-received:
+			got[msg.Topic()] = msg.Payload()
+		case <-time.After(5 * time.Second):
+			t.Fatalf("MQTT messages not received, got %v", got)
+		}
+	}
+	assert.JSONEq(t, `{"delay":5}`, string(got["devices/d1/commands"]))
+	var env messaging.MessageEnvelope
+	require.NoError(t, json.Unmarshal(got["events/fw/updated"], &env))
+	assert.Equal(t, "e1", env.ID)
+	assert.Equal(t, 1.0, testutil.ToFloat64(b.metrics.messages.WithLabelValues("commands", "outbound", resultForwarded)))
+
+	b.Stop()
+	assert.Empty(t, sub.Subscriptions(), "Stop unsubscribes from NATS")
+	assert.Error(t, b.HealthCheck())
+}
+
+func TestNew_Errors(t *testing.T) {
+	inbound := Route{Name: "in", Direction: Inbound, Topic: "a/{id}", Subject: "a.{id}"}
+	tests := []struct {
+		name string
+		cfg  Config
+		pub  messaging.Publisher
+		sub  messaging.Subscriber
+	}{
+		{"no broker", Config{}, mocks.NewPublisher(), nil},
+		{"no name", Config{Broker: "tcp://x:1883", Routes: []Route{{Direction: Inbound, Topic: "a", Subject: "a"}}}, mocks.NewPublisher(), nil},
+		{"direction", Config{Broker: "tcp://x:1883", Routes: []Route{{Name: "x", Topic: "a", Subject: "a"}}}, mocks.NewPublisher(), nil},
+		{"qos", Config{Broker: "tcp://x:1883", Routes: []Route{{Name: "x", Direction: Inbound, Topic: "a", Subject: "a", QoS: 3}}}, mocks.NewPublisher(), nil},
+		{"template", Config{Broker: "tcp://x:1883", Routes: []Route{{Name: "x", Direction: Inbound, Topic: "a/+", Subject: "a.{id}"}}}, mocks.NewPublisher(), nil},
+		{"no publisher", Config{Broker: "tcp://x:1883", Routes: []Route{inbound}}, nil, mocks.NewSubscriber()},
+		{"no subscriber", Config{Broker: "tcp://x:1883", Routes: []Route{{Name: "out", Direction: Outbound, Topic: "a", Subject: "a"}}}, mocks.NewPublisher(), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.pub, tt.sub, tt.cfg, nil)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestBridge_StartUnreachable(t *testing.T) {
+	b, err := New(mocks.NewPublisher(), nil, Config{Broker: "tcp://127.0.0.1:1", ConnectTimeout: time.Second, Registry: telemetry.NewMetricsRegistry()}, nil)
+	require.NoError(t, err)
+	assert.ErrorContains(t, b.Start(), "failed to connect to MQTT broker")
+}
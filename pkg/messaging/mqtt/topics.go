@@ -0,0 +1,195 @@
+package mqtt
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RestName is the name of the levels matched by the trailing multi-level
+// wildcard of a pattern, # in MQTT and > in NATS, in templates: {rest}
+const RestName = "rest"
+
+var placeholderRe = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// syntax is the level separator and wildcards of MQTT topics or NATS
+// subjects, and the characters a level cannot hold
+type syntax struct {
+	name     string
+	sep      string
+	single   string
+	multi    string
+	reserved string
+}
+
+var (
+	mqttSyntax = syntax{name: "topic", sep: "/", single: "+", multi: "#", reserved: "/+#"}
+	natsSyntax = syntax{name: "subject", sep: ".", single: "*", multi: ">", reserved: ".*> \t\r\n"}
+)
+
+// validLevel reports whether s is a valid concrete level
+func (s syntax) validLevel(level string) bool {
+	if level == "" {
+		return s.sep == mqttSyntax.sep
+	}
+	return !strings.ContainsAny(level, s.reserved)
+}
+
+// pattern is an MQTT topic filter or a NATS subject with wildcards: {name}
+// matches a level and captures it, the single-level wildcard matches a level
+// without capturing it, and a trailing multi-level wildcard captures the
+// remaining levels as {rest}
+type pattern struct {
+	syntax syntax
+	// levels are the literal levels, "" for wildcards
+	levels []string
+	// names are the captures of the wildcard levels, "" for anonymous ones
+	names []string
+	rest  bool
+}
+
+func parsePattern(s string, syn syntax) (*pattern, error) {
+	if s == "" {
+		return nil, fmt.Errorf("empty %s", syn.name)
+	}
+	p := &pattern{syntax: syn}
+	parts := strings.Split(s, syn.sep)
+	seen := make(map[string]bool)
+	for i, level := range parts {
+		switch {
+		case level == syn.multi && i == len(parts)-1:
+			p.rest = true
+		case level == syn.single:
+			p.levels = append(p.levels, "")
+			p.names = append(p.names, "")
+		case strings.HasPrefix(level, "{") && strings.HasSuffix(level, "}"):
+			name := level[1 : len(level)-1]
+			if name == "" || name == RestName || strings.ContainsAny(name, "{}") {
+				return nil, fmt.Errorf("invalid wildcard %q in %s %q", level, syn.name, s)
+			}
+			if seen[name] {
+				return nil, fmt.Errorf("wildcard {%s} repeated in %s %q", name, syn.name, s)
+			}
+			seen[name] = true
+			p.levels = append(p.levels, "")
+			p.names = append(p.names, name)
+		case level == "" || !syn.validLevel(level) || strings.ContainsAny(level, "{}"):
+			return nil, fmt.Errorf("invalid level %q in %s %q", level, syn.name, s)
+		default:
+			p.levels = append(p.levels, level)
+			p.names = append(p.names, "")
+		}
+	}
+	return p, nil
+}
+
+// filter returns the pattern with plain wildcards, to subscribe with
+func (p *pattern) filter() string {
+	levels := make([]string, 0, len(p.levels)+1)
+	for _, level := range p.levels {
+		if level == "" {
+			level = p.syntax.single
+		}
+		levels = append(levels, level)
+	}
+	if p.rest {
+		levels = append(levels, p.syntax.multi)
+	}
+	return strings.Join(levels, p.syntax.sep)
+}
+
+// match returns the captures of topic, or false when it does not match
+func (p *pattern) match(topic string) (captures, bool) {
+	parts := strings.Split(topic, p.syntax.sep)
+	if len(parts) < len(p.levels) || (!p.rest && len(parts) != len(p.levels)) {
+		return captures{}, false
+	}
+	c := captures{values: make(map[string]string, len(p.names))}
+	for i, level := range p.levels {
+		if level != "" {
+			if parts[i] != level {
+				return captures{}, false
+			}
+			continue
+		}
+		if p.names[i] != "" {
+			c.values[p.names[i]] = parts[i]
+		}
+	}
+	if p.rest {
+		c.rest = parts[len(p.levels):]
+	}
+	return c, true
+}
+
+// captures are the levels a pattern matched
+type captures struct {
+	values map[string]string
+	rest   []string
+}
+
+// template is an MQTT topic or NATS subject with {name} placeholders
+// replaced by the captures of a pattern
+type template struct {
+	syntax syntax
+	raw    string
+}
+
+// parseTemplate parses s, whose placeholders must be captured by from
+func parseTemplate(s string, syn syntax, from *pattern) (*template, error) {
+	if s == "" {
+		return nil, fmt.Errorf("empty %s", syn.name)
+	}
+	known := make(map[string]bool)
+	for _, name := range from.names {
+		known[name] = true
+	}
+	for _, m := range placeholderRe.FindAllStringSubmatch(s, -1) {
+		name := m[1]
+		if name == RestName && from.rest {
+			continue
+		}
+		if !known[name] || name == "" {
+			return nil, fmt.Errorf("%s %q uses {%s}, not captured by %q", syn.name, s, name, from.filter())
+		}
+	}
+	literal := placeholderRe.ReplaceAllString(s, "x")
+	for _, level := range strings.Split(literal, syn.sep) {
+		if !syn.validLevel(level) || strings.ContainsAny(level, "{}") {
+			return nil, fmt.Errorf("invalid level %q in %s %q", level, syn.name, s)
+		}
+	}
+	return &template{syntax: syn, raw: s}, nil
+}
+
+// render returns the template with the captures of c. It fails when a
+// capture would not make a valid level, e.g. an MQTT level holding a dot
+// in a NATS subject.
+func (t *template) render(c captures) (string, error) {
+	var err error
+	out := placeholderRe.ReplaceAllStringFunc(t.raw, func(m string) string {
+		name := m[1 : len(m)-1]
+		if name == RestName {
+			for _, level := range c.rest {
+				if !t.syntax.validLevel(level) {
+					err = fmt.Errorf("level %q cannot be part of a %s", level, t.syntax.name)
+				}
+			}
+			return strings.Join(c.rest, t.syntax.sep)
+		}
+		value := c.values[name]
+		if !t.syntax.validLevel(value) {
+			err = fmt.Errorf("level %q cannot be part of a %s", value, t.syntax.name)
+		}
+		return value
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, level := range strings.Split(out, t.syntax.sep) {
+		if !t.syntax.validLevel(level) {
+			return "", fmt.Errorf("invalid %s %q", t.syntax.name, out)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,98 @@
+package mqtt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		syntax  syntax
+		filter  string
+		wantErr bool
+	}{
+		{"devices/{device}/telemetry", mqttSyntax, "devices/+/telemetry", false},
+		{"devices/+/{kind}/#", mqttSyntax, "devices/+/+/#", false},
+		{"iot.{device}.commands.>", natsSyntax, "iot.*.commands.>", false},
+		{"iot.*", natsSyntax, "iot.*", false},
+		{"", mqttSyntax, "", true},
+		{"devices/#/x", mqttSyntax, "", true},
+		{"devices/{}/x", mqttSyntax, "", true},
+		{"devices/{rest}", mqttSyntax, "", true},
+		{"a/{id}/{id}", mqttSyntax, "", true},
+		{"a//b", mqttSyntax, "", true},
+		{"a/b+", mqttSyntax, "", true},
+		{"iot..x", natsSyntax, "", true},
+	}
+	for _, tt := range tests {
+		p, err := parsePattern(tt.pattern, tt.syntax)
+		if tt.wantErr {
+			assert.Error(t, err, tt.pattern)
+			continue
+		}
+		require.NoError(t, err, tt.pattern)
+		assert.Equal(t, tt.filter, p.filter(), tt.pattern)
+	}
+}
+
+func TestTemplate_Render(t *testing.T) {
+	tests := []struct {
+		name     string
+		pattern  string
+		from     syntax
+		template string
+		to       syntax
+		topic    string
+		want     string
+		wantErr  bool
+	}{
+		{"named", "devices/{device}/telemetry", mqttSyntax, "iot.{device}.telemetry", natsSyntax, "devices/d1/telemetry", "iot.d1.telemetry", false},
+		{"rest", "devices/{device}/#", mqttSyntax, "iot.{device}.{rest}", natsSyntax, "devices/d1/a/b", "iot.d1.a.b", false},
+		{"outbound", "iot.{device}.cmd.>", natsSyntax, "devices/{device}/{rest}", mqttSyntax, "iot.d1.cmd.reboot.now", "devices/d1/reboot/now", false},
+		{"level with a dot", "devices/{device}/telemetry", mqttSyntax, "iot.{device}", natsSyntax, "devices/d.1/telemetry", "", true},
+		{"level with a space", "devices/{device}/telemetry", mqttSyntax, "iot.{device}", natsSyntax, "devices/d 1/telemetry", "", true},
+		{"empty rest", "devices/#", mqttSyntax, "iot.{rest}", natsSyntax, "devices", "", true},
+		{"empty level", "devices/{device}/telemetry", mqttSyntax, "iot.{device}", natsSyntax, "devices//telemetry", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := parsePattern(tt.pattern, tt.from)
+			require.NoError(t, err)
+			tmpl, err := parseTemplate(tt.template, tt.to, p)
+			require.NoError(t, err)
+			c, ok := p.match(tt.topic)
+			require.True(t, ok)
+			got, err := tmpl.render(c)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPattern_Match(t *testing.T) {
+	p, err := parsePattern("devices/{device}/telemetry", mqttSyntax)
+	require.NoError(t, err)
+	_, ok := p.match("devices/d1/status")
+	assert.False(t, ok)
+	_, ok = p.match("devices/d1/telemetry/x")
+	assert.False(t, ok)
+	c, ok := p.match("devices/d1/telemetry")
+	assert.True(t, ok)
+	assert.Equal(t, "d1", c.values["device"])
+}
+
+func TestParseTemplate_Errors(t *testing.T) {
+	p, err := parsePattern("devices/{device}/telemetry", mqttSyntax)
+	require.NoError(t, err)
+	for _, tmpl := range []string{"", "iot.{id}", "iot.{rest}", "iot.*.{device}", "iot..{device}"} {
+		_, err := parseTemplate(tmpl, natsSyntax, p)
+		assert.Error(t, err, tmpl)
+	}
+}
@@ -0,0 +1,265 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// AckPolicy decides how the subscriber acknowledges a JetStream message once
+// its handler returns
+type AckPolicy int
+
+const (
+	// AckAuto acks the message when the handler succeeds and naks it, for an
+	// immediate redelivery, when it fails (default)
+	AckAuto AckPolicy = iota
+	// AckOnSuccess acks the message when the handler succeeds and leaves it
+	// unacknowledged when it fails, so it is redelivered after AckWait
+	AckOnSuccess
+	// AckManual leaves the acknowledgement to the handler, through the Acker
+	// of its context
+	AckManual
+)
+
+// Acker acknowledges the JetStream message being handled. Once the message is
+// acked, naked or terminated, the subscriber leaves it alone whatever the
+// policy.
+type Acker interface {
+	// Ack acknowledges the message
+	Ack() error
+	// AckSync acknowledges the message and waits for the server to confirm
+	// it (double ack), so a confirmed message is never redelivered
+	AckSync() error
+	// Nak asks for the redelivery of the message
+	Nak() error
+	// NakWithDelay asks for the redelivery of the message after delay
+	NakWithDelay(delay time.Duration) error
+	// InProgress resets the AckWait of the message, for long handlers
+	InProgress() error
+	// Term stops the redelivery of the message
+	Term() error
+}
+
+// PolicyAcker is an Acker following the policy chosen with WithAckPolicy.
+// The subscribers of other drivers implement it for their messages.
+type PolicyAcker interface {
+	Acker
+	SetAckPolicy(policy AckPolicy)
+}
+
+type ackerKey struct{}
+
+// ContextWithAcker returns a context handing a to the handler, for drivers
+// whose messages are acknowledged
+func ContextWithAcker(ctx context.Context, a Acker) context.Context {
+	return context.WithValue(ctx, ackerKey{}, a)
+}
+
+// AckerFromContext returns the Acker of the JetStream message being handled,
+// or nil for core NATS messages
+func AckerFromContext(ctx context.Context) Acker {
+	a, _ := ctx.Value(ackerKey{}).(Acker)
+	return a
+}
+
+// WithAckPolicy makes the subscriber acknowledge the JetStream messages of
+// handler with policy instead of AckAuto
+func WithAckPolicy(policy AckPolicy, handler HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, subject string, msg *MessageEnvelope) error {
+		if a, ok := ctx.Value(ackerKey{}).(PolicyAcker); ok {
+			a.SetAckPolicy(policy)
+		}
+		return handler(ctx, subject, msg)
+	}
+}
+
+// WithDoubleAck makes the subscriber acknowledge the JetStream messages of
+// handler with AckSync, for critical operations that must not be repeated
+func WithDoubleAck(handler HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, subject string, msg *MessageEnvelope) error {
+		if a, ok := ctx.Value(ackerKey{}).(*jsAcker); ok {
+			a.mu.Lock()
+			a.doubleAck = true
+			a.mu.Unlock()
+		}
+		return handler(ctx, subject, msg)
+	}
+}
+
+// ackState is the policy of a message and whether it was settled (acked,
+// naked or terminated), shared by the ackers of every driver
+type ackState struct {
+	mu      sync.Mutex
+	policy  AckPolicy
+	settled bool
+}
+
+// SetAckPolicy sets the policy applied once the handler returns
+func (a *ackState) SetAckPolicy(policy AckPolicy) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.policy = policy
+}
+
+// settle runs fn unless the message is settled already
+func (a *ackState) settle(fn func() error) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.settled {
+		return fmt.Errorf("message already acknowledged")
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	a.settled = true
+	return nil
+}
+
+// owned reports whether the subscriber still settles the message: it is not
+// settled and its policy is not AckManual
+func (a *ackState) owned() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return !a.settled && a.policy != AckManual
+}
+
+// jsAcker is the Acker of a JetStream message
+type jsAcker struct {
+	ackState
+	msg *nats.Msg
+	// doubleAck is guarded by mu
+	doubleAck bool
+}
+
+func newJSAcker(msg *nats.Msg) *jsAcker {
+	return &jsAcker{msg: msg}
+}
+
+func (a *jsAcker) Ack() error {
+	return a.settle(func() error { return a.msg.Ack() })
+}
+
+func (a *jsAcker) AckSync() error {
+	return a.settle(func() error { return a.msg.AckSync() })
+}
+
+func (a *jsAcker) Nak() error {
+	return a.settle(func() error { return a.msg.Nak() })
+}
+
+func (a *jsAcker) NakWithDelay(delay time.Duration) error {
+	return a.settle(func() error { return a.msg.NakWithDelay(delay) })
+}
+
+func (a *jsAcker) InProgress() error {
+	return a.msg.InProgress()
+}
+
+func (a *jsAcker) Term() error {
+	return a.settle(func() error { return a.msg.Term() })
+}
+
+// finish applies the policy to the outcome of the handler, unless the
+// handler settled the message itself
+func (a *jsAcker) finish(handlerErr error) error {
+	a.mu.Lock()
+	policy, doubleAck, settled := a.policy, a.doubleAck, a.settled
+	a.mu.Unlock()
+	if settled || policy == AckManual {
+		return nil
+	}
+	switch {
+	case handlerErr == nil && doubleAck:
+		return a.AckSync()
+	case handlerErr == nil:
+		return a.Ack()
+	case policy == AckAuto:
+		return a.Nak()
+	}
+	return nil
+}
+
+// DriverMessage is a message received by the subscriber of another driver,
+// settled through its DriverAcker
+type DriverMessage interface {
+	// Ack acknowledges the message
+	Ack() error
+	// Nak asks for the message again after delay
+	Nak(delay time.Duration) error
+	// InProgress extends the time the message is held for its handler
+	InProgress() error
+	// Term rejects the message, to the dead letters of the driver if any
+	Term() error
+	// Redelivered reports whether the message was delivered before
+	Redelivered() bool
+}
+
+// DriverAcker is the PolicyAcker of a DriverMessage. Once the handler
+// returns, Finish settles the message with the policy chosen with
+// WithAckPolicy:
+//
+//   - AckAuto acks it on success and asks for it again on failure, once: a
+//     redelivered message failing again is terminated
+//   - AckOnSuccess acks it on success and terminates it on failure
+//   - AckManual leaves it to the handler
+//
+// The transports have no MaxDeliver, so the messages failing for good are
+// terminated instead of being left to redeliveries.
+type DriverAcker struct {
+	ackState
+	msg DriverMessage
+}
+
+var _ PolicyAcker = (*DriverAcker)(nil)
+
+// NewDriverAcker returns the acker of msg
+func NewDriverAcker(msg DriverMessage) *DriverAcker {
+	return &DriverAcker{msg: msg}
+}
+
+func (a *DriverAcker) Ack() error {
+	return a.settle(a.msg.Ack)
+}
+
+// AckSync acks the message: the drivers have no double ack
+func (a *DriverAcker) AckSync() error {
+	return a.Ack()
+}
+
+func (a *DriverAcker) Nak() error {
+	return a.NakWithDelay(0)
+}
+
+func (a *DriverAcker) NakWithDelay(delay time.Duration) error {
+	return a.settle(func() error { return a.msg.Nak(delay) })
+}
+
+func (a *DriverAcker) InProgress() error {
+	return a.msg.InProgress()
+}
+
+func (a *DriverAcker) Term() error {
+	return a.settle(a.msg.Term)
+}
+
+// Finish applies the policy to the outcome of the handler, unless the
+// handler settled the message itself
+func (a *DriverAcker) Finish(handlerErr error) error {
+	a.mu.Lock()
+	policy, settled := a.policy, a.settled
+	a.mu.Unlock()
+	if settled || policy == AckManual {
+		return nil
+	}
+	switch {
+	case handlerErr == nil:
+		return a.Ack()
+	case policy == AckAuto && !a.msg.Redelivered():
+		return a.Nak()
+	}
+	return a.Term()
+}
@@ -0,0 +1,249 @@
+package nats
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// deliveries records the times a handler got each message
+type deliveries struct {
+	mu    sync.Mutex
+	times map[string][]time.Time
+	ch    chan string
+}
+
+func newDeliveries() *deliveries {
+	return &deliveries{times: make(map[string][]time.Time), ch: make(chan string, 20)}
+}
+
+func (d *deliveries) record(msg *MessageEnvelope) int {
+	d.mu.Lock()
+	d.times[msg.Type] = append(d.times[msg.Type], time.Now())
+	n := len(d.times[msg.Type])
+	d.mu.Unlock()
+	d.ch <- msg.Type
+	return n
+}
+
+func (d *deliveries) get(msgType string) []time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.times[msgType]
+}
+
+func TestSubscriber_AckPolicies(t *testing.T) {
+	client, js := runReplayServer(t)
+	_, err := js.AddStream(&nats.StreamConfig{Name: "JOBS", Subjects: []string{"jobs.>"}, Storage: nats.MemoryStorage})
+	require.NoError(t, err)
+	publisher := NewPublisher(client, "test-service")
+	ackWait := 300 * time.Millisecond
+
+	tests := []struct {
+		name    string
+		wrap    func(HandlerFunc) HandlerFunc
+		handler func(ctx context.Context, n int) error
+		// wantGap is the least time between the first two deliveries, zero
+		// for a single delivery
+		wantGap time.Duration
+		maxGap  time.Duration
+	}{
+		{
+			name:    "auto naks on error",
+			wrap:    func(h HandlerFunc) HandlerFunc { return h },
+			handler: func(ctx context.Context, n int) error { return errIf(n == 1) },
+			maxGap:  ackWait / 2,
+		},
+		{
+			name:    "on success leaves errors to AckWait",
+			wrap:    func(h HandlerFunc) HandlerFunc { return WithAckPolicy(AckOnSuccess, h) },
+			handler: func(ctx context.Context, n int) error { return errIf(n == 1) },
+			wantGap: ackWait * 3 / 4,
+		},
+		{
+			name: "manual term",
+			wrap: func(h HandlerFunc) HandlerFunc { return WithAckPolicy(AckManual, h) },
+			handler: func(ctx context.Context, n int) error {
+				require.NoError(t, AckerFromContext(ctx).Term())
+				return ErrHandlerFailed
+			},
+		},
+		{
+			name:    "manual without ack is redelivered",
+			wrap:    func(h HandlerFunc) HandlerFunc { return WithAckPolicy(AckManual, h) },
+			handler: func(ctx context.Context, n int) error { return nil },
+			wantGap: ackWait * 3 / 4,
+		},
+		{
+			name: "in progress extends AckWait",
+			wrap: func(h HandlerFunc) HandlerFunc { return h },
+			handler: func(ctx context.Context, n int) error {
+				for i := 0; i < 3; i++ {
+					time.Sleep(ackWait / 2)
+					require.NoError(t, AckerFromContext(ctx).InProgress())
+				}
+				return nil
+			},
+		},
+		{
+			name: "double ack",
+			wrap: WithDoubleAck,
+			handler: func(ctx context.Context, n int) error {
+				return nil
+			},
+		},
+		{
+			name: "handler ack wins",
+			wrap: func(h HandlerFunc) HandlerFunc { return h },
+			handler: func(ctx context.Context, n int) error {
+				acker := AckerFromContext(ctx)
+				require.NoError(t, acker.AckSync())
+				assert.Error(t, acker.Nak(), "already acknowledged")
+				return ErrHandlerFailed
+			},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subject := "jobs.policy" + string(rune('a'+i))
+			durable := "policy" + string(rune('a'+i))
+			subscriber := NewSubscriber(client, "test-subscriber")
+			defer subscriber.Close()
+
+			got := newDeliveries()
+			handler := tt.wrap(func(ctx context.Context, subject string, msg *MessageEnvelope) error {
+				return tt.handler(ctx, got.record(msg))
+			})
+			require.NoError(t, subscriber.SubscribePush(subject, handler, nats.Durable(durable), nats.AckWait(ackWait)))
+			_, err := publisher.PublishJS(context.Background(), subject, "job", i)
+			require.NoError(t, err)
+
+			if tt.wantGap == 0 && tt.maxGap == 0 {
+				<-got.ch
+				require.Eventually(t, func() bool {
+					info, err := js.ConsumerInfo("JOBS", durable)
+					require.NoError(t, err)
+					return info.NumAckPending == 0 && info.NumPending == 0
+				}, 3*time.Second, 20*time.Millisecond)
+				time.Sleep(ackWait + 100*time.Millisecond)
+				assert.Len(t, got.get("job"), 1, "not redelivered")
+				return
+			}
+			<-got.ch
+			select {
+			case <-got.ch:
+			case <-time.After(3 * time.Second):
+				t.Fatal("not redelivered")
+			}
+			times := got.get("job")
+			gap := times[1].Sub(times[0])
+			if tt.wantGap > 0 {
+				assert.GreaterOrEqual(t, gap, tt.wantGap)
+			}
+			if tt.maxGap > 0 {
+				assert.Less(t, gap, tt.maxGap)
+			}
+		})
+	}
+}
+
+func TestSubscriber_AckPolicyPull(t *testing.T) {
+	client, js := runReplayServer(t)
+	_, err := js.AddStream(&nats.StreamConfig{Name: "JOBS", Subjects: []string{"jobs.>"}, Storage: nats.MemoryStorage})
+	require.NoError(t, err)
+	subscriber := NewSubscriber(client, "test-subscriber")
+	defer subscriber.Close()
+
+	got := newDeliveries()
+	handler := WithAckPolicy(AckManual, func(ctx context.Context, subject string, msg *MessageEnvelope) error {
+		got.record(msg)
+		return AckerFromContext(ctx).Ack()
+	})
+	_, err = subscriber.SubscribePull("jobs.pull", "puller", handler, WithFetchTimeout(100*time.Millisecond))
+	require.NoError(t, err)
+	_, err = NewPublisher(client, "test-service").PublishJS(context.Background(), "jobs.pull", "job", 1)
+	require.NoError(t, err)
+
+	<-got.ch
+	require.Eventually(t, func() bool {
+		info, err := js.ConsumerInfo("JOBS", "puller")
+		require.NoError(t, err)
+		return info.AckFloor.Stream > 0
+	}, 3*time.Second, 20*time.Millisecond)
+}
+
+func TestAckerFromContext_Core(t *testing.T) {
+	assert.Nil(t, AckerFromContext(context.Background()))
+	called := false
+	h := WithDoubleAck(WithAckPolicy(AckManual, func(ctx context.Context, subject string, msg *MessageEnvelope) error {
+		called = true
+		return nil
+	}))
+	require.NoError(t, h(context.Background(), "core", &MessageEnvelope{}))
+	assert.True(t, called, "policies are ignored for core messages")
+}
+
+func errIf(fail bool) error {
+	if fail {
+		return ErrHandlerFailed
+	}
+	return nil
+}
+
+// driverMessage records how a DriverAcker settles it
+type driverMessage struct {
+	redelivered bool
+	settled     []string
+}
+
+func (m *driverMessage) Ack() error                    { return m.record("ack") }
+func (m *driverMessage) Nak(delay time.Duration) error { return m.record("nak " + delay.String()) }
+func (m *driverMessage) InProgress() error             { return m.record("in progress") }
+func (m *driverMessage) Term() error                   { return m.record("term") }
+func (m *driverMessage) Redelivered() bool             { return m.redelivered }
+
+func (m *driverMessage) record(op string) error {
+	m.settled = append(m.settled, op)
+	return nil
+}
+
+func TestDriverAcker_Finish(t *testing.T) {
+	failed := assert.AnError
+	tests := []struct {
+		name        string
+		policy      AckPolicy
+		redelivered bool
+		err         error
+		want        []string
+	}{
+		{name: "auto success", policy: AckAuto, want: []string{"ack"}},
+		{name: "auto failure", policy: AckAuto, err: failed, want: []string{"nak 0s"}},
+		{name: "auto failure redelivered", policy: AckAuto, redelivered: true, err: failed, want: []string{"term"}},
+		{name: "on success failure", policy: AckOnSuccess, err: failed, want: []string{"term"}},
+		{name: "manual", policy: AckManual, err: failed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := &driverMessage{redelivered: tt.redelivered}
+			a := NewDriverAcker(msg)
+			a.SetAckPolicy(tt.policy)
+			require.NoError(t, a.Finish(tt.err))
+			assert.Equal(t, tt.want, msg.settled)
+		})
+	}
+}
+
+func TestDriverAcker_SettledOnce(t *testing.T) {
+	msg := &driverMessage{}
+	a := NewDriverAcker(msg)
+	require.NoError(t, a.InProgress())
+	require.NoError(t, a.NakWithDelay(time.Second))
+	assert.Error(t, a.Ack(), "settled once")
+	require.NoError(t, a.Finish(nil), "left alone once settled by the handler")
+	assert.Equal(t, []string{"in progress", "nak 1s"}, msg.settled)
+}
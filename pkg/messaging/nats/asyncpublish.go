@@ -0,0 +1,227 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"grouter/pkg/telemetry"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// ErrAsyncPublisherClosed is returned by the publishes of a closed
+// AsyncPublisher
+var ErrAsyncPublisherClosed = errors.New("async publisher closed")
+
+// AsyncPublishConfig configures an AsyncPublisher
+type AsyncPublishConfig struct {
+	// MaxInFlight is the most publishes awaiting their ack, further
+	// publishes waiting for one of them (default 256)
+	MaxInFlight int `mapstructure:"max_in_flight"`
+	// MaxRetries is the number of times a publish the server failed to ack
+	// is published again (default 3, negative for none)
+	MaxRetries int `mapstructure:"max_retries"`
+	// RetryBackoff is the wait before the first retry, doubled by every
+	// retry (default 100ms)
+	RetryBackoff time.Duration `mapstructure:"retry_backoff"`
+	// AckTimeout bounds the wait for the ack of every attempt (default 5s)
+	AckTimeout time.Duration `mapstructure:"ack_timeout"`
+	// OnError receives the messages whose publish failed after their
+	// retries, with the last error
+	OnError func(msg *nats.Msg, err error) `mapstructure:"-"`
+}
+
+// withDefaults returns cfg with the defaults of its unset fields
+func (cfg AsyncPublishConfig) withDefaults() AsyncPublishConfig {
+	if cfg.MaxInFlight <= 0 {
+		cfg.MaxInFlight = 256
+	}
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = 3
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = 100 * time.Millisecond
+	}
+	if cfg.AckTimeout <= 0 {
+		cfg.AckTimeout = 5 * time.Second
+	}
+	return cfg
+}
+
+// AsyncPublisher publishes to JetStream asynchronously and tracks the acks
+// of the publishes instead of its callers: at most MaxInFlight publishes
+// await their ack, the publishes the server fails to ack are published
+// again, and those failing after their retries go to OnError. Every
+// publish carries a message ID, so that the stream drops the duplicates of
+// retried publishes within its duplicate window.
+type AsyncPublisher struct {
+	client *Client
+	pub    Publisher
+	cfg    AsyncPublishConfig
+	logger *zap.Logger
+
+	// slots holds a token per publish awaiting its ack
+	slots       chan struct{}
+	outstanding prometheus.Gauge
+	retried     prometheus.Counter
+	failed      prometheus.Counter
+
+	mu      sync.RWMutex
+	closed  bool
+	pending sync.WaitGroup
+}
+
+// NewAsyncPublisher creates an async publisher publishing with pub on the
+// connection of client, exporting its metrics to reg (nil uses the global
+// registry)
+func NewAsyncPublisher(client *Client, pub Publisher, cfg AsyncPublishConfig, reg *telemetry.MetricsRegistry, logger *zap.Logger) *AsyncPublisher {
+	cfg = cfg.withDefaults()
+	return &AsyncPublisher{
+		client: client,
+		pub:    pub,
+		cfg:    cfg,
+		logger: logger,
+		slots:  make(chan struct{}, cfg.MaxInFlight),
+		outstanding: reg.GaugeVec(prometheus.GaugeOpts{
+			Name: "messaging_async_publish_outstanding",
+			Help: "Number of async JetStream publishes awaiting their ack",
+		}, nil).WithLabelValues(),
+		retried: reg.CounterVec(prometheus.CounterOpts{
+			Name: "messaging_async_publish_retries_total",
+			Help: "Total number of async JetStream publishes published again after a failed ack",
+		}, nil).WithLabelValues(),
+		failed: reg.CounterVec(prometheus.CounterOpts{
+			Name: "messaging_async_publish_failed_total",
+			Help: "Total number of async JetStream publishes failing after their retries",
+		}, nil).WithLabelValues(),
+	}
+}
+
+// Publish publishes a message to a JetStream subject, returning once sent.
+// It waits for a slot while MaxInFlight publishes await their ack, until ctx
+// is done.
+func (a *AsyncPublisher) Publish(ctx context.Context, subject string, msgType string, data interface{}, opts ...nats.PubOpt) error {
+	if a.isClosed() {
+		return ErrAsyncPublisherClosed
+	}
+	select {
+	case a.slots <- struct{}{}:
+	case <-ctx.Done():
+		return fmt.Errorf("failed to publish async to JetStream: %w", ctx.Err())
+	}
+
+	a.mu.RLock()
+	if a.closed {
+		a.mu.RUnlock()
+		<-a.slots
+		return ErrAsyncPublisherClosed
+	}
+	a.pending.Add(1)
+	a.mu.RUnlock()
+
+	future, err := a.pub.PublishAsyncJS(ctx, subject, msgType, data, append([]nats.PubOpt{nats.MsgId(uuid.NewString())}, opts...)...)
+	if err != nil {
+		<-a.slots
+		a.pending.Done()
+		return err
+	}
+	a.outstanding.Inc()
+	go a.track(future)
+	return nil
+}
+
+// track waits for the ack of future, publishing its message again while
+// the server fails to ack it, then frees its slot
+func (a *AsyncPublisher) track(future nats.PubAckFuture) {
+	defer func() {
+		a.outstanding.Dec()
+		<-a.slots
+		a.pending.Done()
+	}()
+
+	msg := future.Msg()
+	err := a.wait(future)
+	for retry := 0; err != nil && retry < a.cfg.MaxRetries; retry++ {
+		a.retried.Inc()
+		time.Sleep(a.cfg.RetryBackoff << retry)
+		err = a.republish(msg)
+	}
+	if err == nil {
+		return
+	}
+
+	a.failed.Inc()
+	a.logger.Warn("Async JetStream publish failed",
+		zap.String("subject", msg.Subject),
+		zap.String("msg_id", msg.Header.Get(nats.MsgIdHdr)),
+		zap.Error(err),
+	)
+	if a.cfg.OnError != nil {
+		a.cfg.OnError(msg, err)
+	}
+}
+
+// republish publishes msg again and waits for its ack
+func (a *AsyncPublisher) republish(msg *nats.Msg) error {
+	js, err := a.client.JetStream()
+	if err != nil {
+		return err
+	}
+	future, err := js.PublishMsgAsync(msg)
+	if err != nil {
+		return fmt.Errorf("failed to publish async to JetStream: %w", err)
+	}
+	return a.wait(future)
+}
+
+// wait returns the error of future, nats.ErrTimeout without ack within
+// AckTimeout
+func (a *AsyncPublisher) wait(future nats.PubAckFuture) error {
+	timer := time.NewTimer(a.cfg.AckTimeout)
+	defer timer.Stop()
+	select {
+	case <-future.Ok():
+		return nil
+	case err := <-future.Err():
+		return err
+	case <-timer.C:
+		return fmt.Errorf("no ack within %s: %w", a.cfg.AckTimeout, nats.ErrTimeout)
+	}
+}
+
+// Outstanding returns the number of publishes awaiting their ack
+func (a *AsyncPublisher) Outstanding() int {
+	return len(a.slots)
+}
+
+func (a *AsyncPublisher) isClosed() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.closed
+}
+
+// Close stops the publishes and waits for the acks, retries included, of
+// the outstanding ones until ctx is done
+func (a *AsyncPublisher) Close(ctx context.Context) error {
+	a.mu.Lock()
+	a.closed = true
+	a.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		a.pending.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to drain %d async publishes: %w", a.Outstanding(), ctx.Err())
+	}
+}
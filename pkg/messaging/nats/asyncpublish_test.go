@@ -0,0 +1,111 @@
+package nats
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"grouter/pkg/telemetry"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// runAsyncServer runs a JetStream server with an "ORDERS" stream on
+// orders.>
+func runAsyncServer(t *testing.T) (*Client, nats.JetStreamContext) {
+	t.Helper()
+	s := runServer(t, &server.Options{JetStream: true, StoreDir: t.TempDir()})
+	client, err := NewNATSClient(Config{URL: s.ClientURL(), ConnectionTimeout: time.Second}, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, client.Connect())
+	t.Cleanup(func() { _ = client.Close() })
+
+	js, err := client.JetStream()
+	require.NoError(t, err)
+	_, err = js.AddStream(&nats.StreamConfig{Name: "ORDERS", Subjects: []string{"orders.>"}, Storage: nats.MemoryStorage})
+	require.NoError(t, err)
+	return client, js
+}
+
+func TestAsyncPublisher_Publish(t *testing.T) {
+	client, js := runAsyncServer(t)
+	reg := telemetry.NewMetricsRegistry()
+	a := NewAsyncPublisher(client, NewPublisher(client, "test-service"), AsyncPublishConfig{MaxInFlight: 4}, reg, zap.NewNop())
+
+	for i := 0; i < 20; i++ {
+		require.NoError(t, a.Publish(context.Background(), "orders.created", "order", i))
+		assert.LessOrEqual(t, a.Outstanding(), 4, "the window bounds the publishes awaiting their ack")
+	}
+	require.NoError(t, a.Close(context.Background()))
+	assert.Zero(t, a.Outstanding(), "closing drains the publishes")
+
+	info, err := js.StreamInfo("ORDERS")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(20), info.State.Msgs)
+	msg, err := js.GetMsg("ORDERS", 1)
+	require.NoError(t, err)
+	assert.NotEmpty(t, msg.Header.Get(nats.MsgIdHdr), "publishes carry a message ID")
+
+	assert.ErrorIs(t, a.Publish(context.Background(), "orders.created", "order", nil), ErrAsyncPublisherClosed)
+	assert.Zero(t, testutil.ToFloat64(a.failed))
+}
+
+func TestAsyncPublisher_Retries(t *testing.T) {
+	client, js := runAsyncServer(t)
+	reg := telemetry.NewMetricsRegistry()
+
+	var mu sync.Mutex
+	var failed []*nats.Msg
+	a := NewAsyncPublisher(client, NewPublisher(client, "test-service"), AsyncPublishConfig{
+		MaxRetries:   2,
+		RetryBackoff: 10 * time.Millisecond,
+		OnError: func(msg *nats.Msg, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			failed = append(failed, msg)
+		},
+	}, reg, zap.NewNop())
+
+	// No stream stores payments
+	require.NoError(t, a.Publish(context.Background(), "payments.created", "payment", nil))
+	require.NoError(t, a.Close(context.Background()))
+	require.Len(t, failed, 1, "publishes failing after their retries go to the error callback")
+	assert.Equal(t, "payments.created", failed[0].Subject)
+	assert.Equal(t, float64(2), testutil.ToFloat64(a.retried))
+	assert.Equal(t, float64(1), testutil.ToFloat64(a.failed))
+
+	// The stream appears while the publish is retried
+	a = NewAsyncPublisher(client, NewPublisher(client, "test-service"), AsyncPublishConfig{RetryBackoff: 200 * time.Millisecond}, reg, zap.NewNop())
+	require.NoError(t, a.Publish(context.Background(), "payments.created", "payment", nil))
+	_, err := js.AddStream(&nats.StreamConfig{Name: "PAYMENTS", Subjects: []string{"payments.>"}, Storage: nats.MemoryStorage})
+	require.NoError(t, err)
+	require.NoError(t, a.Close(context.Background()))
+	info, err := js.StreamInfo("PAYMENTS")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), info.State.Msgs)
+	assert.Len(t, failed, 1)
+}
+
+func TestAsyncPublisher_Window(t *testing.T) {
+	client, _ := runAsyncServer(t)
+	a := NewAsyncPublisher(client, NewPublisher(client, "test-service"), AsyncPublishConfig{
+		MaxInFlight:  1,
+		RetryBackoff: time.Second,
+	}, telemetry.NewMetricsRegistry(), zap.NewNop())
+
+	require.NoError(t, a.Publish(context.Background(), "payments.created", "payment", nil))
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, a.Publish(ctx, "orders.created", "order", nil), context.DeadlineExceeded, "publishes wait for a slot")
+	assert.Equal(t, 1, a.Outstanding())
+
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, a.Close(ctx), context.DeadlineExceeded, "the retries outlast the drain")
+}
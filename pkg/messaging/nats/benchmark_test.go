@@ -0,0 +1,215 @@
+package nats
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	applog "grouter/pkg/logger"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// benchOrder is a typical small payload
+type benchOrder struct {
+	ID       string   `json:"id"`
+	Customer string   `json:"customer"`
+	Items    []string `json:"items"`
+	Total    float64  `json:"total"`
+}
+
+var order = benchOrder{ID: "order-1", Customer: "customer-1", Items: []string{"a", "b", "c"}, Total: 42.5}
+
+// runBenchServer runs a NATS server and returns a client connected to it
+func runBenchServer(b *testing.B) *Client {
+	b.Helper()
+	s, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1, NoLog: true, NoSigs: true})
+	if err != nil {
+		b.Fatal(err)
+	}
+	go s.Start()
+	if !s.ReadyForConnections(5 * time.Second) {
+		b.Fatal("server not ready")
+	}
+	b.Cleanup(s.Shutdown)
+
+	client, err := NewNATSClient(Config{URL: s.ClientURL(), ConnectionTimeout: time.Second}, zap.NewNop())
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := client.Connect(); err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func BenchmarkPublish(b *testing.B) {
+	client := runBenchServer(b)
+	ctx := context.Background()
+
+	b.Run("sync", func(b *testing.B) {
+		publisher := NewPublisher(client, "bench")
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := publisher.Publish(ctx, "bench.sync", "order", order, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("skip flush", func(b *testing.B) {
+		publisher := NewPublisher(client, "bench")
+		publisher.SetSkipFlush(true)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := publisher.Publish(ctx, "bench.noflush", "order", order, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("async", func(b *testing.B) {
+		publisher := NewPublisher(client, "bench")
+		opts := &PublishOptions{Async: true}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := publisher.Publish(ctx, "bench.async", "order", order, opts); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("async with middleware", func(b *testing.B) {
+		publisher := NewPublisher(client, "bench")
+		publisher.Use(PublisherLoggingMiddleware(zap.NewNop()))
+		opts := &PublishOptions{Async: true}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := publisher.Publish(ctx, "bench.async", "order", order, opts); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("async parallel", func(b *testing.B) {
+		publisher := NewPublisher(client, "bench")
+		opts := &PublishOptions{Async: true}
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if err := publisher.Publish(ctx, "bench.parallel", "order", order, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	})
+}
+
+func BenchmarkSubscribe(b *testing.B) {
+	client := runBenchServer(b)
+	publisher := NewPublisher(client, "bench")
+	subscriber := NewSubscriber(client, "bench")
+	b.Cleanup(func() { _ = subscriber.Close() })
+
+	received := make(chan struct{}, 1024)
+	handler := func(ctx context.Context, subject string, msg *MessageEnvelope) error {
+		received <- struct{}{}
+		return nil
+	}
+	if err := subscriber.Subscribe("bench.sub", handler, nil); err != nil {
+		b.Fatal(err)
+	}
+	ctx := context.Background()
+	opts := &PublishOptions{Async: true}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := publisher.Publish(ctx, "bench.sub", "order", order, opts); err != nil {
+			b.Fatal(err)
+		}
+		<-received
+	}
+}
+
+func BenchmarkRequest(b *testing.B) {
+	client := runBenchServer(b)
+	publisher := NewPublisher(client, "bench")
+	subscriber := NewSubscriber(client, "bench")
+	b.Cleanup(func() { _ = subscriber.Close() })
+
+	handler := func(ctx context.Context, subject string, msg *MessageEnvelope) error {
+		return publisher.Publish(ctx, msg.Reply, "reply", msg.Data, &PublishOptions{Async: true})
+	}
+	if err := subscriber.Subscribe("bench.req", handler, nil); err != nil {
+		b.Fatal(err)
+	}
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := publisher.Request(ctx, "bench.req", "order", order, time.Second); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMarshalEnvelope(b *testing.B) {
+	client := runBenchServer(b)
+	publisher := NewPublisher(client, "bench").(*NATSPublisher)
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf := getBuffer()
+		env, err := publisher.newEnvelope(ctx, "order", order, buf)
+		if err != nil {
+			b.Fatal(err)
+		}
+		envBuf := getBuffer()
+		if _, err := publisher.marshal(ctx, "bench.marshal", &env, envBuf); err != nil {
+			b.Fatal(err)
+		}
+		putBuffer(envBuf)
+		putBuffer(buf)
+	}
+}
+
+// BenchmarkLoggingMiddleware measures the cost of the message logs per
+// message, reported as msgs/s, with a JSON logger writing to io.Discard
+func BenchmarkLoggingMiddleware(b *testing.B) {
+	newLogger := func(level zapcore.Level) *zap.Logger {
+		encoder := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+		return zap.New(zapcore.NewCore(encoder, zapcore.AddSync(io.Discard), level))
+	}
+	next := func(ctx context.Context, subject string, msg *MessageEnvelope) error { return nil }
+	env := &MessageEnvelope{ID: "id", Type: "order", Source: "bench", Metadata: map[string]string{MetadataTenantID: "acme"}}
+
+	tests := []struct {
+		name   string
+		level  zapcore.Level
+		opts   []LoggingOption
+		logged bool
+	}{
+		{name: "every message", level: zap.InfoLevel},
+		{name: "sampled", level: zap.InfoLevel, opts: []LoggingOption{WithLogSampling(applog.SamplingConfig{Enabled: true, Initial: 10, Thereafter: 1000, Tick: time.Second})}},
+		{name: "level disabled", level: zap.WarnLevel},
+	}
+	for _, tt := range tests {
+		b.Run(tt.name, func(b *testing.B) {
+			handler := LoggingMiddleware(newLogger(tt.level), tt.opts...)(next)
+			ctx := context.Background()
+			b.ReportAllocs()
+			start := time.Now()
+			for i := 0; i < b.N; i++ {
+				_ = handler(ctx, "orders.created", env)
+			}
+			b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "msgs/s")
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package nats
+
+import (
+	"strings"
+	"sync"
+
+	"grouter/pkg/telemetry"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// OverflowLabel is the label value of the values past the cardinality
+// limits of the messaging metrics
+const OverflowLabel = "other"
+
+// CardinalityConfig bounds the label values of the messaging metrics, so
+// that per-entity subjects don't grow a series per entity
+type CardinalityConfig struct {
+	// Subjects are subject templates, NATS patterns such as
+	// "orders.*.created": subjects matching one are labeled with the first
+	// they match
+	Subjects []string `mapstructure:"subjects"`
+	// SubjectDepth keeps the first tokens of the subjects matching no
+	// template, the others becoming ">" (0 keeps subjects whole)
+	SubjectDepth int `mapstructure:"subject_depth"`
+	// Allow lists the values allowed per label (subject, type or tenant),
+	// the others being labeled OverflowLabel. Subjects are allowed once
+	// normalized.
+	Allow map[string][]string `mapstructure:"allow"`
+	// MaxValues caps the values of each label of each metric, the values
+	// past the cap being labeled OverflowLabel (0 = no cap)
+	MaxValues int `mapstructure:"max_values"`
+}
+
+// enabled reports whether cfg bounds any label
+func (cfg CardinalityConfig) enabled() bool {
+	return len(cfg.Subjects) > 0 || cfg.SubjectDepth > 0 || len(cfg.Allow) > 0 || cfg.MaxValues > 0
+}
+
+// labeler bounds the label values of a metric
+type labeler struct {
+	templates []string
+	depth     int
+	allow     map[string]map[string]bool
+	max       int
+	overflow  *prometheus.CounterVec
+
+	mu   sync.Mutex
+	seen map[string]map[string]struct{}
+}
+
+func newLabeler(reg *telemetry.MetricsRegistry, cfg CardinalityConfig) *labeler {
+	l := &labeler{
+		templates: cfg.Subjects,
+		depth:     cfg.SubjectDepth,
+		allow:     make(map[string]map[string]bool, len(cfg.Allow)),
+		max:       cfg.MaxValues,
+		overflow:  labelOverflowMetric(reg),
+		seen:      make(map[string]map[string]struct{}),
+	}
+	for label, values := range cfg.Allow {
+		l.allow[label] = make(map[string]bool, len(values))
+		for _, v := range values {
+			l.allow[label][v] = true
+		}
+	}
+	return l
+}
+
+// subject returns the subject label of subject: its template, or its first
+// tokens, within the limits of the subject label
+func (l *labeler) subject(subject string) string {
+	return l.value("subject", l.normalize(subject))
+}
+
+// normalize returns the first template subject matches, or subject cut to
+// its first depth tokens
+func (l *labeler) normalize(subject string) string {
+	for _, t := range l.templates {
+		if MatchSubject(t, subject) {
+			return t
+		}
+	}
+	if l.depth <= 0 {
+		return subject
+	}
+	i := 0
+	for n := 0; n < l.depth; n++ {
+		next := strings.IndexByte(subject[i:], '.')
+		if next < 0 {
+			return subject
+		}
+		i += next + 1
+	}
+	return subject[:i] + ">"
+}
+
+// value returns v if allowed for label and within the cap of its values,
+// OverflowLabel otherwise
+func (l *labeler) value(label, v string) string {
+	if allowed, ok := l.allow[label]; ok && !allowed[v] {
+		l.overflow.WithLabelValues(label).Inc()
+		return OverflowLabel
+	}
+	if l.max <= 0 {
+		return v
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	seen := l.seen[label]
+	if seen == nil {
+		seen = make(map[string]struct{})
+		l.seen[label] = seen
+	}
+	if _, ok := seen[v]; ok {
+		return v
+	}
+	if len(seen) >= l.max {
+		l.overflow.WithLabelValues(label).Inc()
+		return OverflowLabel
+	}
+	seen[v] = struct{}{}
+	return v
+}
+
+func labelOverflowMetric(reg *telemetry.MetricsRegistry) *prometheus.CounterVec {
+	return reg.CounterVec(prometheus.CounterOpts{
+		Name: "messaging_metrics_label_overflow_total",
+		Help: "Total number of messaging metric observations labeled other past the cardinality limits",
+	}, []string{"label"})
+}
@@ -0,0 +1,73 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"grouter/pkg/telemetry"
+	"grouter/pkg/tenant"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLabeler_Subjects(t *testing.T) {
+	l := newLabeler(telemetry.NewMetricsRegistry(), CardinalityConfig{
+		Subjects:     []string{"orders.*.created", "users.>"},
+		SubjectDepth: 2,
+	})
+
+	for subject, want := range map[string]string{
+		"orders.42.created":   "orders.*.created",
+		"users.7.profile.get": "users.>",
+		"orders.42.shipped":   "orders.42.>",
+		"grouter.ipsec":       "grouter.ipsec",
+		"health":              "health",
+	} {
+		assert.Equal(t, want, l.subject(subject), subject)
+	}
+}
+
+func TestLabeler_Limits(t *testing.T) {
+	reg := telemetry.NewMetricsRegistry()
+	l := newLabeler(reg, CardinalityConfig{
+		Allow:     map[string][]string{"tenant": {"acme", "globex"}},
+		MaxValues: 2,
+	})
+	overflow := labelOverflowMetric(reg)
+
+	assert.Equal(t, "acme", l.value("tenant", "acme"))
+	assert.Equal(t, OverflowLabel, l.value("tenant", "initech"), "values not allowed")
+	assert.Equal(t, float64(1), testutil.ToFloat64(overflow.WithLabelValues("tenant")))
+
+	assert.Equal(t, "a", l.value("type", "a"))
+	assert.Equal(t, "b", l.value("type", "b"))
+	assert.Equal(t, OverflowLabel, l.value("type", "c"), "values past the cap")
+	assert.Equal(t, "a", l.value("type", "a"), "known values stay")
+	assert.Equal(t, float64(1), testutil.ToFloat64(overflow.WithLabelValues("type")))
+}
+
+func TestMetricsMiddleware_Cardinality(t *testing.T) {
+	reg := telemetry.NewMetricsRegistry()
+	opts := []MetricsOption{WithTenantLabel(), WithCardinality(CardinalityConfig{
+		Subjects:  []string{"orders.*"},
+		Allow:     map[string][]string{"tenant": {"acme"}},
+		MaxValues: 3,
+	})}
+	publish := PublisherMetricsMiddleware(reg, opts...)(func(ctx context.Context, subject string, msgType string, data interface{}, opts *PublishOptions) error {
+		return nil
+	})
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, publish(tenant.NewContext(context.Background(), "acme"), fmt.Sprintf("orders.%d", i), "order", nil, nil))
+		require.NoError(t, publish(tenant.NewContext(context.Background(), fmt.Sprintf("tenant-%d", i)), fmt.Sprintf("users.%d", i), "user", nil, nil))
+	}
+
+	counter := publishMetrics(reg, opts).counter
+	assert.Equal(t, float64(10), testutil.ToFloat64(counter.WithLabelValues("orders.*", "order", "acme", "success")), "subjects labeled with their template")
+	assert.Equal(t, float64(1), testutil.ToFloat64(counter.WithLabelValues("users.1", "user", OverflowLabel, "success")), "tenants not allowed overflow")
+	assert.Equal(t, float64(8), testutil.ToFloat64(counter.WithLabelValues(OverflowLabel, "user", OverflowLabel, "success")), "subjects past the cap overflow")
+	assert.Equal(t, 4, testutil.CollectAndCount(counter), "the series are bounded")
+}
@@ -1,10 +1,19 @@
 package nats
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
+	"grouter/pkg/chaos"
+	"grouter/pkg/loadshed"
+	applog "grouter/pkg/logger"
+	"grouter/pkg/slo"
+	"grouter/pkg/telemetry"
+
 	"github.com/nats-io/nats.go"
 	"go.uber.org/zap"
 )
@@ -12,14 +21,43 @@ import (
 // Client wraps NATS connection
 type Client struct {
 	conn   *nats.Conn
-	js     nats.JetStreamContext
 	logger *zap.Logger
 	config Config
+
+	// js is created on first use by JetStream, which may be called from
+	// several goroutines (health checks, stream monitor, subscribers)
+	jsMu sync.Mutex
+	js   nats.JetStreamContext
+
+	// auth guards the credentials read by the connection on every
+	// (re)connect, so that UpdateAuth can rotate them
+	auth sync.RWMutex
+
+	// server is the redacted URL of the server connected to
+	serverMu sync.Mutex
+	server   string
+	metrics  *serverMetrics // nil without metrics
+
+	// rotation is the pending reconnection with new credentials, creds the
+	// watcher of the creds file
+	rotationMu sync.Mutex
+	rotation   *time.Timer
+	creds      *credsWatcher
+
+	events connectionEvents
+	slow   slowConsumers
 }
 
 // Config holds NATS client configuration
 type Config struct {
-	URL               string        `mapstructure:"url"`
+	// URL is the server, or the comma separated servers, to connect to
+	URL string `mapstructure:"url"`
+	// URLs are servers of the pool in addition to URL, e.g. the members of
+	// a cluster
+	URLs []string `mapstructure:"urls"`
+	// NoRandomize connects to the servers in order instead of shuffling
+	// them, which spreads the clients over the cluster
+	NoRandomize       bool          `mapstructure:"no_randomize"`
 	MaxReconnects     int           `mapstructure:"max_reconnects"`
 	ReconnectWait     time.Duration `mapstructure:"reconnect_wait"`
 	ConnectionTimeout time.Duration `mapstructure:"connection_timeout"`
@@ -34,23 +72,65 @@ type Config struct {
 	KeyFile    string `mapstructure:"key_file"`
 	// NATS 2.0+ Credentials
 	CredsFile string `mapstructure:"creds_file"`
+	// Rotation of the credentials while connected
+	AuthRotation AuthRotationConfig `mapstructure:"auth_rotation"`
+	// Embedded runs a NATS server in the process and connects to it instead
+	// of URL
+	Embedded       bool                 `mapstructure:"embedded"`
+	EmbeddedServer EmbeddedServerConfig `mapstructure:"embedded_server"`
+	// SkipFlush stops sync publishes from flushing the connection
+	SkipFlush bool `mapstructure:"skip_flush"`
+	// CoalesceRequests shares one request among concurrent identical requests
+	CoalesceRequests bool `mapstructure:"coalesce_requests"`
 	// Metrics configuration
 	Metrics MetricsConfig `mapstructure:"metrics"`
 	// Logging configuration
 	Logging LoggingConfig `mapstructure:"logging"`
 	// Tracing configuration
 	Tracing TracingConfig `mapstructure:"tracing"`
+	// Envelope signing configuration
+	Signing SigningConfig `mapstructure:"signing"`
+	// Spillover of large envelopes to an object store
+	LargePayloads LargePayloadConfig `mapstructure:"large_payloads"`
+	// Compression of the envelope data
+	Compression CompressionConfig `mapstructure:"compression"`
+	// Encryption of the envelope data
+	Encryption EncryptionConfig `mapstructure:"encryption"`
+	// Stream and consumer lag monitoring
+	Monitoring MonitorConfig `mapstructure:"monitoring"`
+	// Quarantine of poison JetStream messages
+	Quarantine QuarantineConfig `mapstructure:"quarantine"`
+	// Caching of the replies to requests
+	ResponseCache ResponseCacheConfig `mapstructure:"response_cache"`
+	// Shedding of the messages received under load
+	LoadShedding loadshed.Config `mapstructure:"load_shedding"`
+	// Chaos injects the faults of chaos experiments into the messages
+	// received, none when nil
+	Chaos *chaos.Injector `mapstructure:"-"`
+	// SLO observes the messages of the nats objectives, none when nil
+	SLO *slo.Tracker `mapstructure:"-"`
+	// OnConnectionEvent receives the connection events from the first
+	// connection on, see Client.OnConnectionEvent
+	OnConnectionEvent ConnectionEventHandler `mapstructure:"-"`
 }
 
 // MetricsConfig holds configuration for metrics
 type MetricsConfig struct {
 	Enabled bool   `mapstructure:"enabled"`
 	Path    string `mapstructure:"path"`
+	// Registry receives the messaging metrics (nil uses the global registry)
+	Registry *telemetry.MetricsRegistry `mapstructure:"-"`
+	// TenantLabel adds a tenant label to the messaging metrics
+	TenantLabel bool `mapstructure:"tenant_label"`
+	// Cardinality bounds the label values of the messaging metrics
+	Cardinality CardinalityConfig `mapstructure:"cardinality"`
 }
 
 // LoggingConfig holds configuration for logging
 type LoggingConfig struct {
 	Enabled bool `mapstructure:"enabled"`
+	// Sampling, when enabled, samples the log lines of successful messages
+	Sampling applog.SamplingConfig `mapstructure:"sampling"`
 }
 
 // TracingConfig holds configuration for tracing
@@ -64,10 +144,14 @@ func NewNATSClient(cfg Config, logger *zap.Logger) (*Client, error) {
 		return nil, fmt.Errorf("logger is required")
 	}
 
-	return &Client{
+	c := &Client{
 		config: cfg,
 		logger: logger,
-	}, nil
+	}
+	if cfg.OnConnectionEvent != nil {
+		c.OnConnectionEvent(cfg.OnConnectionEvent)
+	}
+	return c, nil
 }
 
 // Connect establishes connection to NATS server
@@ -77,26 +161,31 @@ func (c *Client) Connect() error {
 		nats.ReconnectWait(c.config.ReconnectWait),
 		nats.Timeout(c.config.ConnectionTimeout),
 		nats.RetryOnFailedConnect(true),
-		nats.DisconnectErrHandler(func(nc *nats.Conn, err error) {
-			if err != nil {
-				c.logger.Error("NATS disconnected", zap.Error(err))
-			}
-		}),
-		nats.ReconnectHandler(func(nc *nats.Conn) {
-			c.logger.Info("NATS reconnected", zap.String("url", nc.ConnectedUrl()))
-		}),
-		nats.ClosedHandler(func(nc *nats.Conn) {
-			c.logger.Warn("NATS connection closed")
-		}),
-	}
-
-	// Add authentication if provided
-	if c.config.CredsFile != "" {
+	}
+	if c.config.Metrics.Enabled {
+		c.metrics = newServerMetrics(c.config.Metrics.Registry)
+		c.slow.total = slowConsumerMetric(c)
+	}
+	opts = append(opts, c.serverOptions()...)
+
+	// Add authentication if provided. Tokens and passwords are read through
+	// handlers so that UpdateAuth can rotate them; creds files are read again
+	// on every connect.
+	switch authMethod(c.config) {
+	case "creds":
 		opts = append(opts, nats.UserCredentials(c.config.CredsFile))
-	} else if c.config.Token != "" {
-		opts = append(opts, nats.Token(c.config.Token))
-	} else if c.config.Username != "" && c.config.Password != "" {
-		opts = append(opts, nats.UserInfo(c.config.Username, c.config.Password))
+	case "token":
+		opts = append(opts, nats.TokenHandler(func() string {
+			c.auth.RLock()
+			defer c.auth.RUnlock()
+			return c.config.Token
+		}))
+	case "user":
+		opts = append(opts, nats.UserInfoHandler(func() (string, string) {
+			c.auth.RLock()
+			defer c.auth.RUnlock()
+			return c.config.Username, c.config.Password
+		}))
 	}
 
 	// Add TLS if enabled
@@ -126,22 +215,73 @@ func (c *Client) Connect() error {
 		opts = append(opts, nats.Secure(tlsConfig))
 	}
 
-	conn, err := nats.Connect(c.config.URL, opts...)
+	servers := c.config.Servers()
+	conn, err := nats.Connect(strings.Join(servers, ","), opts...)
 	if err != nil {
 		return fmt.Errorf("failed to connect to NATS: %w", err)
 	}
 
 	c.conn = conn
+	if c.config.AuthRotation.WatchCreds && c.config.CredsFile != "" {
+		creds, err := watchCreds(c)
+		if err != nil {
+			return err
+		}
+		c.creds = creds
+	}
 	if c.conn.IsConnected() {
-		c.logger.Info("Connected to NATS", zap.String("url", c.config.URL))
+		c.logger.Info("Connected to NATS",
+			zap.String("url", c.conn.ConnectedUrlRedacted()),
+			zap.Strings("servers", redactURLs(servers)))
 	} else {
-		c.logger.Warn("NATS connection established but not yet connected (reconnecting mode)", zap.String("url", c.config.URL))
+		c.logger.Warn("NATS connection established but not yet connected (reconnecting mode)",
+			zap.Strings("servers", redactURLs(servers)))
 	}
 	return nil
 }
 
+// authMethod returns the authentication used by the configuration: creds,
+// token, user or "" for none
+func authMethod(cfg Config) string {
+	switch {
+	case cfg.CredsFile != "":
+		return "creds"
+	case cfg.Token != "":
+		return "token"
+	case cfg.Username != "" && cfg.Password != "":
+		return "user"
+	}
+	return ""
+}
+
+// UpdateAuth rotates the token or the user credentials and reconnects with
+// them, after up to AuthRotation.Jitter. Pending requests fail with the old
+// connection. Switching to another
+// authentication method requires a new client.
+func (c *Client) UpdateAuth(token, username, password string) error {
+	c.auth.Lock()
+	next := c.config
+	next.Token, next.Username, next.Password = token, username, password
+	if authMethod(next) != authMethod(c.config) {
+		c.auth.Unlock()
+		return fmt.Errorf("changing the NATS authentication method from %q to %q requires a restart", authMethod(c.config), authMethod(next))
+	}
+	changed := next.Token != c.config.Token || next.Username != c.config.Username || next.Password != c.config.Password
+	c.config.Token, c.config.Username, c.config.Password = token, username, password
+	c.auth.Unlock()
+
+	if !changed {
+		return nil
+	}
+	return c.reauthenticate(authMethod(next))
+}
+
 // Close gracefully closes the NATS connection
 func (c *Client) Close() error {
+	if c.creds != nil {
+		c.creds.close()
+	}
+	c.stopRotation()
 	if c.conn != nil {
 		c.conn.Drain()
 		c.conn.Close()
@@ -155,6 +295,30 @@ func (c *Client) IsConnected() bool {
 	return c.conn != nil && c.conn.IsConnected()
 }
 
+// HealthCheck reports an error unless the connection is established
+func (c *Client) HealthCheck() error {
+	if c.conn == nil {
+		return fmt.Errorf("not connected to NATS")
+	}
+	if status := c.conn.Status(); status != nats.CONNECTED {
+		return fmt.Errorf("nats connection is %s", status)
+	}
+	return nil
+}
+
+// JetStreamHealthCheck verifies JetStream is enabled for the account by
+// fetching the account info
+func (c *Client) JetStreamHealthCheck(ctx context.Context) error {
+	js, err := c.JetStream()
+	if err != nil {
+		return err
+	}
+	if _, err := js.AccountInfo(nats.Context(ctx)); err != nil {
+		return fmt.Errorf("jetstream unavailable: %w", err)
+	}
+	return nil
+}
+
 // Conn returns the underlying NATS connection
 func (c *Client) Conn() *nats.Conn {
 	return c.conn
@@ -162,6 +326,8 @@ func (c *Client) Conn() *nats.Conn {
 
 // JetStream returns the JetStream context, initializing it if necessary
 func (c *Client) JetStream() (nats.JetStreamContext, error) {
+	c.jsMu.Lock()
+	defer c.jsMu.Unlock()
 	if c.js != nil {
 		return c.js, nil
 	}
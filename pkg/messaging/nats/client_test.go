@@ -1,9 +1,13 @@
 package nats
 
 import (
+	"context"
 	"testing"
 	"time"
 
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
 
@@ -186,3 +190,50 @@ func TestClient_WithAuthentication(t *testing.T) {
 		})
 	}
 }
+
+func TestClient_HealthCheck_NotConnected(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	client, err := NewNATSClient(Config{URL: "nats://localhost:4222"}, logger)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	if err := client.HealthCheck(); err == nil {
+		t.Error("HealthCheck() should fail before Connect() is called")
+	}
+	if err := client.JetStreamHealthCheck(context.Background()); err == nil {
+		t.Error("JetStreamHealthCheck() should fail before Connect() is called")
+	}
+}
+
+func TestClient_UpdateAuth(t *testing.T) {
+	opts := &server.Options{Host: "127.0.0.1", Port: -1, NoLog: true, NoSigs: true, Authorization: "old-token"}
+	s, err := server.NewServer(opts)
+	require.NoError(t, err)
+	go s.Start()
+	require.True(t, s.ReadyForConnections(5*time.Second))
+	defer s.Shutdown()
+
+	client, err := NewNATSClient(Config{
+		URL:               s.ClientURL(),
+		Token:             "old-token",
+		MaxReconnects:     -1,
+		ReconnectWait:     50 * time.Millisecond,
+		ConnectionTimeout: time.Second,
+	}, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, client.Connect())
+	defer client.Close()
+	require.Eventually(t, client.IsConnected, 5*time.Second, 10*time.Millisecond)
+
+	// The server drops the client once its token is revoked
+	reloaded := opts.Clone()
+	reloaded.Authorization = "new-token"
+	require.NoError(t, s.ReloadOptions(reloaded))
+	require.Eventually(t, func() bool { return !client.IsConnected() }, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, client.UpdateAuth("new-token", "", ""))
+	assert.Eventually(t, client.IsConnected, 5*time.Second, 10*time.Millisecond)
+
+	assert.Error(t, client.UpdateAuth("", "user", "pass"), "switching to user credentials needs a new client")
+}
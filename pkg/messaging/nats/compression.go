@@ -0,0 +1,188 @@
+package nats
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"grouter/pkg/telemetry"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MetadataContentEncoding is the compression of the envelope data
+const MetadataContentEncoding = "content_encoding"
+
+// Compression algorithms
+const (
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// maxDecompressedSize bounds the data of a compressed envelope, so a small
+// message cannot expand into an unbounded allocation
+const maxDecompressedSize = 64 << 20
+
+// CompressionConfig configures the compression of the envelope data
+type CompressionConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Algorithm is gzip or zstd (default)
+	Algorithm string `mapstructure:"algorithm"`
+	// Threshold is the data size in bytes from which it is compressed
+	Threshold int `mapstructure:"threshold"`
+}
+
+// Compressor compresses the data of outgoing envelopes above a threshold
+type Compressor struct {
+	algorithm string
+	threshold int
+	encode    func([]byte) ([]byte, error)
+	saved     prometheus.Counter
+	messages  prometheus.Counter
+}
+
+// NewCompressor creates a compressor counting the bytes saved in reg (nil
+// uses the global registry)
+func NewCompressor(cfg CompressionConfig, reg *telemetry.MetricsRegistry) (*Compressor, error) {
+	algorithm := cfg.Algorithm
+	if algorithm == "" {
+		algorithm = CompressionZstd
+	}
+	var encode func([]byte) ([]byte, error)
+	switch algorithm {
+	case CompressionGzip:
+		encode = gzipEncode
+	case CompressionZstd:
+		enc, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd encoder: %w", err)
+		}
+		encode = func(data []byte) ([]byte, error) {
+			return enc.EncodeAll(data, nil), nil
+		}
+	default:
+		return nil, fmt.Errorf("unknown compression algorithm %q", cfg.Algorithm)
+	}
+
+	return &Compressor{
+		algorithm: algorithm,
+		threshold: cfg.Threshold,
+		encode:    encode,
+		saved:     compressionSavedMetric(reg).WithLabelValues(algorithm),
+		messages:  compressedMetric(reg).WithLabelValues(algorithm),
+	}, nil
+}
+
+func compressionSavedMetric(reg *telemetry.MetricsRegistry) *prometheus.CounterVec {
+	return reg.CounterVec(prometheus.CounterOpts{
+		Name: "messaging_compression_saved_bytes_total",
+		Help: "Total number of envelope bytes saved by compression",
+	}, []string{"algorithm"})
+}
+
+func compressedMetric(reg *telemetry.MetricsRegistry) *prometheus.CounterVec {
+	return reg.CounterVec(prometheus.CounterOpts{
+		Name: "messaging_compressed_total",
+		Help: "Total number of envelopes published with compressed data",
+	}, []string{"algorithm"})
+}
+
+// Compress replaces the data of env with its compressed form, a base64 JSON
+// string, when the data reaches the threshold and compressing makes it smaller
+func (c *Compressor) Compress(env *MessageEnvelope) error {
+	if len(env.Data) < c.threshold {
+		return nil
+	}
+	compressed, err := c.encode(env.Data)
+	if err != nil {
+		return fmt.Errorf("failed to compress data: %w", err)
+	}
+	encoded, err := json.Marshal(compressed)
+	if err != nil {
+		return fmt.Errorf("failed to encode compressed data: %w", err)
+	}
+	if len(encoded) >= len(env.Data) {
+		return nil
+	}
+
+	c.saved.Add(float64(len(env.Data) - len(encoded)))
+	c.messages.Inc()
+	env.Data = encoded
+	if env.Metadata == nil {
+		env.Metadata = make(map[string]string)
+	}
+	env.Metadata[MetadataContentEncoding] = c.algorithm
+	return nil
+}
+
+// Decompress restores the data of an envelope compressed by a Compressor.
+// Envelopes without a content encoding are left untouched.
+func Decompress(env *MessageEnvelope) error {
+	algorithm := env.Metadata[MetadataContentEncoding]
+	if algorithm == "" {
+		return nil
+	}
+	var compressed []byte
+	if err := json.Unmarshal(env.Data, &compressed); err != nil {
+		return fmt.Errorf("failed to decode compressed data: %w", err)
+	}
+
+	var data []byte
+	var err error
+	switch algorithm {
+	case CompressionGzip:
+		data, err = gzipDecode(compressed)
+	case CompressionZstd:
+		var dec *zstd.Decoder
+		if dec, err = zstdDecoder(); err == nil {
+			data, err = dec.DecodeAll(compressed, nil)
+		}
+	default:
+		return fmt.Errorf("unknown content encoding %q", algorithm)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to decompress data: %w", err)
+	}
+
+	env.Data = data
+	// The envelope is signed before it is compressed
+	delete(env.Metadata, MetadataContentEncoding)
+	return nil
+}
+
+func gzipEncode(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func gzipDecode(compressed []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(io.LimitReader(r, maxDecompressedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxDecompressedSize {
+		return nil, fmt.Errorf("data exceeds %d bytes", maxDecompressedSize)
+	}
+	return data, nil
+}
+
+// zstdDecoder is shared, as DecodeAll is safe for concurrent use
+var zstdDecoder = sync.OnceValues(func() (*zstd.Decoder, error) {
+	return zstd.NewReader(nil, zstd.WithDecoderConcurrency(0), zstd.WithDecoderMaxMemory(maxDecompressedSize))
+})
@@ -0,0 +1,127 @@
+package nats
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"grouter/pkg/telemetry"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestCompressor(t *testing.T) {
+	data, err := json.Marshal(strings.Repeat("compressible ", 200))
+	require.NoError(t, err)
+
+	for _, algorithm := range []string{CompressionGzip, CompressionZstd} {
+		t.Run(algorithm, func(t *testing.T) {
+			reg := telemetry.NewMetricsRegistry()
+			c, err := NewCompressor(CompressionConfig{Algorithm: algorithm, Threshold: 1024}, reg)
+			require.NoError(t, err)
+
+			env := &MessageEnvelope{ID: "1", Data: data}
+			require.NoError(t, c.Compress(env))
+			assert.Equal(t, algorithm, env.Metadata[MetadataContentEncoding])
+			assert.Less(t, len(env.Data), len(data))
+			saved := testutil.ToFloat64(compressionSavedMetric(reg).WithLabelValues(algorithm))
+			assert.Equal(t, float64(len(data)-len(env.Data)), saved)
+			assert.Equal(t, float64(1), testutil.ToFloat64(compressedMetric(reg).WithLabelValues(algorithm)))
+
+			require.NoError(t, Decompress(env))
+			assert.JSONEq(t, string(data), string(env.Data))
+			assert.NotContains(t, env.Metadata, MetadataContentEncoding)
+		})
+	}
+}
+
+func TestCompressor_Skips(t *testing.T) {
+	c, err := NewCompressor(CompressionConfig{Threshold: 1024}, telemetry.NewMetricsRegistry())
+	require.NoError(t, err)
+
+	small := &MessageEnvelope{Data: json.RawMessage(`"small"`)}
+	require.NoError(t, c.Compress(small))
+	assert.Equal(t, `"small"`, string(small.Data), "data below the threshold is left as is")
+
+	random := make([]byte, 4096)
+	_, err = rand.Read(random)
+	require.NoError(t, err)
+	data, err := json.Marshal(random)
+	require.NoError(t, err)
+	incompressible := &MessageEnvelope{Data: data}
+	require.NoError(t, c.Compress(incompressible))
+	assert.Equal(t, string(data), string(incompressible.Data), "data that does not shrink is left as is")
+	assert.NotContains(t, incompressible.Metadata, MetadataContentEncoding)
+
+	_, err = NewCompressor(CompressionConfig{Algorithm: "lz4"}, nil)
+	assert.Error(t, err)
+}
+
+func TestDecompress_Errors(t *testing.T) {
+	assert.Error(t, Decompress(&MessageEnvelope{
+		Data:     json.RawMessage(`"bm90IGd6aXA="`),
+		Metadata: map[string]string{MetadataContentEncoding: CompressionGzip},
+	}))
+	assert.Error(t, Decompress(&MessageEnvelope{
+		Data:     json.RawMessage(`"AA=="`),
+		Metadata: map[string]string{MetadataContentEncoding: "br"},
+	}))
+
+	// Data expanding past the limit is refused
+	bomb, err := gzipEncode(make([]byte, maxDecompressedSize+1))
+	require.NoError(t, err)
+	encoded, err := json.Marshal(bomb)
+	require.NoError(t, err)
+	err = Decompress(&MessageEnvelope{Data: encoded, Metadata: map[string]string{MetadataContentEncoding: CompressionGzip}})
+	assert.ErrorContains(t, err, "exceeds")
+}
+
+func TestPublisher_Compression(t *testing.T) {
+	s, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1, NoLog: true, NoSigs: true})
+	require.NoError(t, err)
+	go s.Start()
+	require.True(t, s.ReadyForConnections(5*time.Second))
+	defer s.Shutdown()
+
+	client, err := NewNATSClient(Config{URL: s.ClientURL(), ConnectionTimeout: time.Second}, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, client.Connect())
+	defer client.Close()
+
+	signer, err := NewEnvelopeSigner(SigningConfig{KeyID: "ctl", Keys: []SigningKey{{ID: "ctl", Secret: "s3cret"}}})
+	require.NoError(t, err)
+	compressor, err := NewCompressor(CompressionConfig{Threshold: 64}, telemetry.NewMetricsRegistry())
+	require.NoError(t, err)
+
+	publisher := NewPublisher(client, "test-service")
+	publisher.SetSigner(signer)
+	publisher.SetCompressor(compressor)
+	subscriber := NewSubscriber(client, "test-subscriber")
+	subscriber.Use(VerificationMiddleware(signer, nil, telemetry.NewMetricsRegistry()))
+
+	received := make(chan string, 1)
+	require.NoError(t, subscriber.Subscribe("test.compressed", func(ctx context.Context, subject string, msg *MessageEnvelope) error {
+		var data string
+		if err := json.Unmarshal(msg.Data, &data); err != nil {
+			return err
+		}
+		received <- data
+		return nil
+	}, nil))
+
+	large := strings.Repeat("compressible ", 100)
+	require.NoError(t, publisher.Publish(context.Background(), "test.compressed", "report", large, nil))
+	select {
+	case data := <-received:
+		assert.Equal(t, large, data, "the data is decompressed and the signature verified")
+	case <-time.After(2 * time.Second):
+		t.Fatal("message not received")
+	}
+}
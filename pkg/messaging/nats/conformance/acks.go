@@ -0,0 +1,160 @@
+package conformance
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// AckBroker is a messenger of a driver settling its messages itself, like
+// the DriverAcker of messaging.DriverSubscriber, with a dead letter
+// destination
+type AckBroker struct {
+	Messenger *messaging.Messenger
+	// DeadLetters returns the number of messages rejected so far
+	DeadLetters func() int
+	// NakDelay is the delay asked with NakWithDelay, the shortest one the
+	// transport honours
+	NakDelay time.Duration
+	// Redelivery is the time after which the messages left unacknowledged
+	// are delivered again, if ever
+	Redelivery time.Duration
+}
+
+// AckFactory returns a new AckBroker on a bus of its own, closed at the end
+// of the test
+type AckFactory func(t *testing.T) AckBroker
+
+// RunAcks runs the tests of the acknowledgement policies on the brokers of
+// factory
+func RunAcks(t *testing.T, factory AckFactory) {
+	t.Run("AckAuto", func(t *testing.T) { testAckAuto(t, factory) })
+	t.Run("AckOnSuccess", func(t *testing.T) { testAckOnSuccess(t, factory) })
+	t.Run("AckManual", func(t *testing.T) { testAckManual(t, factory) })
+	t.Run("InvalidMessages", func(t *testing.T) { testInvalidMessages(t, factory) })
+}
+
+// RunUnsupported checks that the messengers of factory refuse the JetStream
+// API, priority lanes and ordered workers with errors.ErrUnsupported
+func RunUnsupported(t *testing.T, factory BrokerFactory) {
+	m := factory(t)
+	handler := func(context.Context, string, *messaging.MessageEnvelope) error { return nil }
+
+	_, err := m.Publisher.PublishJS(context.Background(), "orders", "order", nil)
+	assert.ErrorIs(t, err, errors.ErrUnsupported, "PublishJS")
+	_, err = m.Publisher.PublishAsyncJS(context.Background(), "orders", "order", nil)
+	assert.ErrorIs(t, err, errors.ErrUnsupported, "PublishAsyncJS")
+	assert.ErrorIs(t, m.Subscriber.SubscribePush("orders", handler), errors.ErrUnsupported, "SubscribePush")
+	_, err = m.Subscriber.SubscribePull("orders", "durable", handler)
+	assert.ErrorIs(t, err, errors.ErrUnsupported, "SubscribePull")
+	_, err = m.Subscriber.SubscribeSubject("orders", handler, &messaging.SubscribeOptions{Priority: true})
+	assert.ErrorIs(t, err, errors.ErrUnsupported, "priority lanes")
+	_, err = m.Subscriber.SubscribeSubject("orders", handler, &messaging.SubscribeOptions{Ordered: true, MaxWorkers: 2})
+	assert.ErrorIs(t, err, errors.ErrUnsupported, "ordered workers")
+}
+
+// WaitFor fails the test unless cond holds within Timeout
+func WaitFor(t *testing.T, cond func() bool, msg string) {
+	t.Helper()
+	assert.Eventually(t, cond, Timeout, 10*time.Millisecond, msg)
+}
+
+// RejectAll is a validator rejecting every message
+type RejectAll struct{}
+
+func (RejectAll) Validate(msgType string, data []byte) error {
+	return errors.New("rejected")
+}
+
+// subscribe subscribes handler to subject in a queue group, and waits until
+// the subscription is active
+func subscribe(t *testing.T, m *messaging.Messenger, subject string, handler messaging.HandlerFunc) {
+	t.Helper()
+	require.NoError(t, m.Subscriber.Subscribe(subject, handler, &messaging.SubscribeOptions{QueueGroup: "workers"}))
+	await(t, m, m)
+}
+
+// testAckAuto checks that a failed message is delivered again once, then
+// rejected
+func testAckAuto(t *testing.T, factory AckFactory) {
+	b := factory(t)
+	subject := prefix() + ".jobs"
+	var calls atomic.Int32
+	subscribe(t, b.Messenger, subject, func(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+		calls.Add(1)
+		return errors.New("boom")
+	})
+	require.NoError(t, b.Messenger.Publisher.Publish(context.Background(), subject, "job", nil, nil))
+
+	WaitFor(t, func() bool { return b.DeadLetters() == 1 }, "failing twice rejects the message")
+	assert.Equal(t, int32(2), calls.Load(), "delivered again once, then rejected")
+}
+
+// testAckOnSuccess checks that a failed message is rejected at once
+func testAckOnSuccess(t *testing.T, factory AckFactory) {
+	b := factory(t)
+	subject := prefix() + ".orders"
+	var calls atomic.Int32
+	subscribe(t, b.Messenger, subject, messaging.WithAckPolicy(messaging.AckOnSuccess, func(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+		calls.Add(1)
+		return errors.New("boom")
+	}))
+	require.NoError(t, b.Messenger.Publisher.Publish(context.Background(), subject, "order", nil, nil))
+
+	WaitFor(t, func() bool { return b.DeadLetters() == 1 }, "AckOnSuccess rejects on failure")
+	assert.Equal(t, int32(1), calls.Load(), "without redelivery")
+}
+
+// testAckManual checks that the handler settles its message once, and that
+// a message asked again comes back after the delay
+func testAckManual(t *testing.T, factory AckFactory) {
+	b := factory(t)
+	subject := prefix() + ".payments"
+	var calls atomic.Int32
+	received := make(chan time.Time, 2)
+	subscribe(t, b.Messenger, subject, messaging.WithAckPolicy(messaging.AckManual, func(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+		received <- time.Now()
+		acker := messaging.AckerFromContext(ctx)
+		if calls.Add(1) == 1 {
+			return acker.NakWithDelay(b.NakDelay)
+		}
+		assert.NoError(t, acker.Ack())
+		assert.Error(t, acker.Ack(), "settled once")
+		return nil
+	}))
+	require.NoError(t, b.Messenger.Publisher.Publish(context.Background(), subject, "payment", nil, nil))
+
+	WaitFor(t, func() bool { return calls.Load() == 2 }, "NakWithDelay asks for the message again")
+	first, second := <-received, <-received
+	assert.GreaterOrEqual(t, second.Sub(first), b.NakDelay, "after the delay")
+	time.Sleep(max(2*b.Redelivery, 100*time.Millisecond))
+	assert.Equal(t, int32(2), calls.Load(), "acknowledged messages are not delivered again")
+	assert.Zero(t, b.DeadLetters())
+}
+
+// testInvalidMessages checks that the messages failing validation are
+// rejected without reaching the handler
+func testInvalidMessages(t *testing.T, factory AckFactory) {
+	b := factory(t)
+	subject := prefix() + ".orders"
+	var calls atomic.Int32
+	// The validator would reject the probes of await too: the subscriptions
+	// of the drivers with dead letters are active once Subscribe returns
+	b.Messenger.Subscriber.SetValidator(RejectAll{})
+	require.NoError(t, b.Messenger.Subscriber.Subscribe(subject, func(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+		calls.Add(1)
+		return nil
+	}, &messaging.SubscribeOptions{QueueGroup: "workers"}))
+	require.NoError(t, b.Messenger.Publisher.Publish(context.Background(), subject, "order", nil, nil))
+
+	WaitFor(t, func() bool { return b.DeadLetters() == 1 }, "invalid messages are rejected")
+	assert.Zero(t, calls.Load())
+	assert.Zero(t, b.Messenger.Subscriber.QueueDepth())
+}
@@ -0,0 +1,386 @@
+// Package conformance is the contract test suite of the messaging drivers.
+// A driver is a Messenger whose Publisher and Subscriber move envelopes over
+// a bus, like the NATS one of messaging.Messenger.Init; other transports
+// (Kafka, in-memory, ...) prove that the manager can run on them by passing
+// Run:
+//
+//	func TestConformance(t *testing.T) {
+//		bus := mydriver.NewBus()
+//		conformance.Run(t, func(t *testing.T) *messaging.Messenger {
+//			m := mydriver.Connect(bus)
+//			t.Cleanup(func() { _ = m.Close() })
+//			return m
+//		})
+//	}
+//
+// Drivers settling their messages themselves, with a dead letter
+// destination, also pass RunAcks and RunUnsupported.
+package conformance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Timeout bounds every wait of the suite
+var Timeout = 5 * time.Second
+
+// BrokerFactory returns a new messenger connected to the bus shared by all
+// the messengers of the test, with its own publisher and subscriber, and
+// closes it at the end of the test
+type BrokerFactory func(t *testing.T) *messaging.Messenger
+
+// Run runs the contract tests on the messengers of factory
+func Run(t *testing.T, factory BrokerFactory) {
+	t.Run("PubSub", func(t *testing.T) { testPubSub(t, factory) })
+	t.Run("Wildcards", func(t *testing.T) { testWildcards(t, factory) })
+	t.Run("QueueGroups", func(t *testing.T) { testQueueGroups(t, factory) })
+	t.Run("RequestReply", func(t *testing.T) { testRequestReply(t, factory) })
+	t.Run("MiddlewareOrder", func(t *testing.T) { testMiddlewareOrder(t, factory) })
+	t.Run("Unsubscribe", func(t *testing.T) { testUnsubscribe(t, factory) })
+	t.Run("GracefulShutdown", func(t *testing.T) { testGracefulShutdown(t, factory) })
+}
+
+var prefixes atomic.Int64
+
+// prefix returns a subject prefix of its own to each test, so that the
+// tests of a shared bus do not see each other's messages
+func prefix() string {
+	return fmt.Sprintf("conformance.%d.%d", time.Now().UnixNano(), prefixes.Add(1))
+}
+
+// inbox collects the envelopes received by a handler
+type inbox struct {
+	ch chan *messaging.MessageEnvelope
+}
+
+func newInbox() *inbox {
+	return &inbox{ch: make(chan *messaging.MessageEnvelope, 1024)}
+}
+
+func (i *inbox) handler(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+	i.ch <- env
+	return nil
+}
+
+// next returns the next envelope, failing the test after Timeout
+func (i *inbox) next(t *testing.T) *messaging.MessageEnvelope {
+	t.Helper()
+	select {
+	case env := <-i.ch:
+		return env
+	case <-time.After(Timeout):
+		require.FailNow(t, "no message received")
+		return nil
+	}
+}
+
+// empty asserts that no envelope arrives for a short while
+func (i *inbox) empty(t *testing.T) {
+	t.Helper()
+	select {
+	case env := <-i.ch:
+		assert.Failf(t, "unexpected message", "type %s", env.Type)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// await waits until the subscriptions made on sub so far receive the
+// messages of pub. Drivers may register subscriptions asynchronously: a
+// probe subscribed after them reaches sub once they are active.
+func await(t *testing.T, pub, sub *messaging.Messenger) {
+	t.Helper()
+	subject := prefix() + ".ready"
+	ready := make(chan struct{}, 1)
+	require.NoError(t, sub.Subscriber.Subscribe(subject, func(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+		select {
+		case ready <- struct{}{}:
+		default:
+		}
+		return nil
+	}, nil))
+	defer func() { _ = sub.Subscriber.UnsubscribeSubject(subject) }()
+
+	deadline := time.After(Timeout)
+	for {
+		require.NoError(t, pub.Publisher.Publish(context.Background(), subject, "conformance.ready", nil, nil))
+		select {
+		case <-ready:
+			return
+		case <-time.After(20 * time.Millisecond):
+		case <-deadline:
+			require.FailNow(t, "subscriptions not active")
+		}
+	}
+}
+
+func decode(t *testing.T, env *messaging.MessageEnvelope) map[string]any {
+	t.Helper()
+	var data map[string]any
+	require.NoError(t, json.Unmarshal(env.Data, &data))
+	return data
+}
+
+// testPubSub checks that a subscriber receives the envelopes of a publisher
+// of another messenger, in order
+func testPubSub(t *testing.T, factory BrokerFactory) {
+	pub, sub := factory(t), factory(t)
+	subject := prefix() + ".orders.created"
+	in := newInbox()
+	require.NoError(t, sub.Subscriber.Subscribe(subject, in.handler, nil))
+	await(t, pub, sub)
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, pub.Publisher.Publish(ctx, subject, "order.created", map[string]any{"seq": i}, nil))
+	}
+	for i := 0; i < 3; i++ {
+		env := in.next(t)
+		assert.NotEmpty(t, env.ID)
+		assert.Equal(t, "order.created", env.Type)
+		assert.False(t, env.Timestamp.IsZero(), "timestamp")
+		assert.EqualValues(t, i, decode(t, env)["seq"], "messages of a publisher keep their order")
+	}
+}
+
+// testWildcards checks the subject wildcards: "*" matches a token, ">" the
+// remaining tokens
+func testWildcards(t *testing.T, factory BrokerFactory) {
+	pub, sub := factory(t), factory(t)
+	p := prefix()
+	one, rest := newInbox(), newInbox()
+	require.NoError(t, sub.Subscriber.Subscribe(p+".orders.*", one.handler, nil))
+	require.NoError(t, sub.Subscriber.Subscribe(p+".orders.>", rest.handler, nil))
+	await(t, pub, sub)
+
+	ctx := context.Background()
+	require.NoError(t, pub.Publisher.Publish(ctx, p+".orders.eu.created", "deep", nil, nil))
+	require.NoError(t, pub.Publisher.Publish(ctx, p+".orders.created", "shallow", nil, nil))
+
+	assert.Equal(t, "shallow", one.next(t).Type)
+	one.empty(t)
+	assert.Equal(t, "deep", rest.next(t).Type)
+	assert.Equal(t, "shallow", rest.next(t).Type)
+}
+
+// testQueueGroups checks that each message reaches a single member of a
+// queue group, and every plain subscriber
+func testQueueGroups(t *testing.T, factory BrokerFactory) {
+	pub, a, b, all := factory(t), factory(t), factory(t), factory(t)
+	subject := prefix() + ".jobs"
+	const n = 50
+
+	var mu sync.Mutex
+	seen := make(map[string]int)
+	members := make(map[string]int)
+	var handled sync.WaitGroup
+	handled.Add(n)
+	member := func(name string) messaging.HandlerFunc {
+		return func(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+			if env.Type != "job" {
+				return nil
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			seen[env.ID]++
+			members[name]++
+			handled.Done()
+			return nil
+		}
+	}
+	group := &messaging.SubscribeOptions{QueueGroup: "workers"}
+	require.NoError(t, a.Subscriber.Subscribe(subject, member("a"), group))
+	require.NoError(t, b.Subscriber.Subscribe(subject, member("b"), group))
+	in := newInbox()
+	require.NoError(t, all.Subscriber.Subscribe(subject, in.handler, nil))
+	for _, sub := range []*messaging.Messenger{a, b, all} {
+		await(t, pub, sub)
+	}
+
+	for i := 0; i < n; i++ {
+		require.NoError(t, pub.Publisher.Publish(context.Background(), subject, "job", map[string]any{"seq": i}, nil))
+	}
+	for i := 0; i < n; i++ {
+		in.next(t)
+	}
+	done := make(chan struct{})
+	go func() { handled.Wait(); close(done) }()
+	select {
+	case <-done:
+	case <-time.After(Timeout):
+		require.FailNow(t, "queue group did not handle every message")
+	}
+	in.empty(t)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, seen, n)
+	for id, count := range seen {
+		assert.Equal(t, 1, count, "message %s handled by a single member", id)
+	}
+	assert.Positive(t, members["a"], "both members receive messages")
+	assert.Positive(t, members["b"], "both members receive messages")
+}
+
+// testRequestReply checks that a request gets the reply published on the
+// reply subject of its envelope, and fails without responders
+func testRequestReply(t *testing.T, factory BrokerFactory) {
+	client, server := factory(t), factory(t)
+	subject := prefix() + ".echo"
+	require.NoError(t, server.Subscriber.Subscribe(subject, func(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+		if env.Reply == "" {
+			return fmt.Errorf("request without reply subject")
+		}
+		var data map[string]any
+		_ = json.Unmarshal(env.Data, &data)
+		return server.Publisher.Publish(ctx, env.Reply, "echo.reply", data, nil)
+	}, nil))
+	await(t, client, server)
+
+	reply, err := client.Publisher.Request(context.Background(), subject, "echo", map[string]any{"msg": "hi"}, Timeout)
+	require.NoError(t, err)
+	assert.Equal(t, "echo.reply", reply.Type)
+	assert.Equal(t, "hi", decode(t, reply)["msg"])
+
+	_, err = client.Publisher.Request(context.Background(), prefix()+".nobody", "echo", nil, 200*time.Millisecond)
+	assert.Error(t, err, "requests without responders fail")
+}
+
+// testMiddlewareOrder checks that middlewares run in registration order,
+// the first one outermost, across Use calls
+func testMiddlewareOrder(t *testing.T, factory BrokerFactory) {
+	pub, sub := factory(t), factory(t)
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, name)
+	}
+	pubMW := func(name string) messaging.PublisherMiddleware {
+		return func(next messaging.PublisherFunc) messaging.PublisherFunc {
+			return func(ctx context.Context, subject, msgType string, data interface{}, opts *messaging.PublishOptions) error {
+				record(name)
+				return next(ctx, subject, msgType, data, opts)
+			}
+		}
+	}
+	reqMW := func(name string) messaging.RequestMiddleware {
+		return func(next messaging.RequestFunc) messaging.RequestFunc {
+			return func(ctx context.Context, subject, msgType string, data interface{}, timeout time.Duration) (*messaging.MessageEnvelope, error) {
+				record(name)
+				return next(ctx, subject, msgType, data, timeout)
+			}
+		}
+	}
+	subMW := func(name string) messaging.SubscriberMiddleware {
+		return func(next messaging.HandlerFunc) messaging.HandlerFunc {
+			return func(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+				record(name)
+				return next(ctx, subject, env)
+			}
+		}
+	}
+
+	subject := prefix() + ".ordered"
+	in := newInbox()
+	sub.Subscriber.Use(subMW("sub1"), subMW("sub2"))
+	sub.Subscriber.Use(subMW("sub3"))
+	require.NoError(t, sub.Subscriber.Subscribe(subject, in.handler, nil))
+	await(t, pub, sub)
+	mu.Lock()
+	order = nil
+	mu.Unlock()
+
+	pub.Publisher.Use(pubMW("pub1"), pubMW("pub2"))
+	pub.Publisher.Use(pubMW("pub3"))
+	require.NoError(t, pub.Publisher.Publish(context.Background(), subject, "ordered", nil, nil))
+	in.next(t)
+
+	pub.Publisher.UseRequest(reqMW("req1"), reqMW("req2"))
+	_, _ = pub.Publisher.Request(context.Background(), prefix()+".nobody", "ordered", nil, 100*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"pub1", "pub2", "pub3", "sub1", "sub2", "sub3", "req1", "req2"}, order)
+}
+
+// testUnsubscribe checks that UnsubscribeSubject stops the deliveries of its
+// subject only, and Unsubscribe those of every subject
+func testUnsubscribe(t *testing.T, factory BrokerFactory) {
+	pub, sub := factory(t), factory(t)
+	p := prefix()
+	orders, payments := newInbox(), newInbox()
+	require.NoError(t, sub.Subscriber.Subscribe(p+".orders", orders.handler, nil))
+	require.NoError(t, sub.Subscriber.Subscribe(p+".payments", payments.handler, nil))
+	await(t, pub, sub)
+
+	ctx := context.Background()
+	require.NoError(t, sub.Subscriber.UnsubscribeSubject(p+".orders"))
+	require.NoError(t, pub.Publisher.Publish(ctx, p+".orders", "order", nil, nil))
+	require.NoError(t, pub.Publisher.Publish(ctx, p+".payments", "payment", nil, nil))
+	assert.Equal(t, "payment", payments.next(t).Type)
+	orders.empty(t)
+
+	require.NoError(t, sub.Subscriber.Unsubscribe())
+	require.NoError(t, pub.Publisher.Publish(ctx, p+".payments", "payment", nil, nil))
+	payments.empty(t)
+}
+
+// testGracefulShutdown checks that closing a subscriber waits for the
+// handlers in flight and stops the deliveries
+func testGracefulShutdown(t *testing.T, factory BrokerFactory) {
+	pub, sub := factory(t), factory(t)
+	subject := prefix() + ".slow"
+	started, release := make(chan struct{}), make(chan struct{})
+	var finished, calls atomic.Int32
+	require.NoError(t, sub.Subscriber.Subscribe(subject, func(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+		if env.Type != "slow" {
+			return nil
+		}
+		if calls.Add(1) == 1 {
+			close(started)
+			<-release
+		}
+		finished.Add(1)
+		return nil
+	}, nil))
+	await(t, pub, sub)
+
+	require.NoError(t, pub.Publisher.Publish(context.Background(), subject, "slow", nil, nil))
+	select {
+	case <-started:
+	case <-time.After(Timeout):
+		require.FailNow(t, "handler not called")
+	}
+
+	closed := make(chan error, 1)
+	go func() { closed <- sub.Subscriber.Close() }()
+	select {
+	case <-closed:
+		require.FailNow(t, "Close returned before the handler in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case err := <-closed:
+		require.NoError(t, err)
+	case <-time.After(Timeout):
+		require.FailNow(t, "Close did not return")
+	}
+	assert.Equal(t, int32(1), finished.Load(), "the handler in flight finished")
+
+	require.NoError(t, pub.Publisher.Publish(context.Background(), subject, "slow", nil, nil))
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int32(1), calls.Load(), "closed subscribers receive nothing")
+}
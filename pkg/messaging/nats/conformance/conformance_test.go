@@ -0,0 +1,15 @@
+package conformance
+
+import (
+	"testing"
+
+	messaging "grouter/pkg/messaging/nats"
+	grtest "grouter/pkg/testing"
+)
+
+func TestNATS(t *testing.T) {
+	s := grtest.RunNATSServer(t)
+	Run(t, func(t *testing.T) *messaging.Messenger {
+		return grtest.NewMessenger(t, s, "conformance")
+	})
+}
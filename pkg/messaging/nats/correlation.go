@@ -0,0 +1,82 @@
+package nats
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type correlationKey struct{}
+
+// correlation is the correlation and causation of the messages published
+// with a context, and the reply subject of the request being handled with
+// the span context of the requester
+type correlation struct {
+	correlationID string
+	causationID   string
+	reply         string
+	request       trace.SpanContext
+}
+
+// WithCorrelationID returns a context publishing the messages of the flow
+// id, e.g. the ID of the HTTP request starting it
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	c, _ := ctx.Value(correlationKey{}).(correlation)
+	c.correlationID = id
+	return context.WithValue(ctx, correlationKey{}, c)
+}
+
+// ContextWithEnvelope returns a context publishing the messages caused by
+// env: they keep its correlation ID and take its ID as causation ID.
+// Subscribers pass it to handlers, so that replies and follow-up messages are
+// correlated without handler code. Replies to env, published on its reply
+// subject, are traced as replies linked to the span of the request.
+func ContextWithEnvelope(ctx context.Context, env *MessageEnvelope) context.Context {
+	c := correlation{correlationID: env.CorrelationID, causationID: env.ID, reply: env.Reply}
+	// Envelopes of version 1 and older carry no correlation and start a flow
+	if c.correlationID == "" {
+		c.correlationID = env.ID
+	}
+	if env.Reply != "" {
+		remote := otel.GetTextMapPropagator().Extract(context.Background(), metadataCarrier(env.Metadata))
+		c.request = trace.SpanContextFromContext(remote)
+	}
+	return context.WithValue(ctx, correlationKey{}, c)
+}
+
+// CorrelationIDFromContext returns the correlation ID of the messages
+// published with ctx, or ""
+func CorrelationIDFromContext(ctx context.Context) string {
+	c, _ := ctx.Value(correlationKey{}).(correlation)
+	return c.correlationID
+}
+
+// CausationIDFromContext returns the ID of the message causing the messages
+// published with ctx, or ""
+func CausationIDFromContext(ctx context.Context) string {
+	c, _ := ctx.Value(correlationKey{}).(correlation)
+	return c.causationID
+}
+
+// replyRequest reports whether subject is the reply subject of the request
+// handled with ctx, returning the span context of the requester
+func replyRequest(ctx context.Context, subject string) (trace.SpanContext, bool) {
+	c, _ := ctx.Value(correlationKey{}).(correlation)
+	if c.reply == "" || c.reply != subject {
+		return trace.SpanContext{}, false
+	}
+	return c.request, true
+}
+
+// correlate sets the correlation and causation IDs of env, published with
+// ctx. Without correlation in ctx, env starts a flow: its correlation ID is
+// its ID.
+func correlate(ctx context.Context, env *MessageEnvelope) {
+	c, _ := ctx.Value(correlationKey{}).(correlation)
+	env.CorrelationID = c.correlationID
+	if env.CorrelationID == "" {
+		env.CorrelationID = env.ID
+	}
+	env.CausationID = c.causationID
+}
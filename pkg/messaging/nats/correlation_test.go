@@ -0,0 +1,122 @@
+package nats
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"grouter/pkg/telemetry"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestCorrelationContext(t *testing.T) {
+	ctx := context.Background()
+	assert.Empty(t, CorrelationIDFromContext(ctx))
+	assert.Empty(t, CausationIDFromContext(ctx))
+
+	env := &MessageEnvelope{ID: "m-1"}
+	correlate(ctx, env)
+	assert.Equal(t, "m-1", env.CorrelationID, "a message without correlation starts a flow")
+	assert.Empty(t, env.CausationID)
+
+	ctx = WithCorrelationID(ctx, "req-1")
+	env = &MessageEnvelope{ID: "m-2"}
+	correlate(ctx, env)
+	assert.Equal(t, "req-1", env.CorrelationID)
+	assert.Empty(t, env.CausationID)
+
+	ctx = ContextWithEnvelope(context.Background(), &MessageEnvelope{ID: "m-2", CorrelationID: "req-1"})
+	assert.Equal(t, "req-1", CorrelationIDFromContext(ctx))
+	assert.Equal(t, "m-2", CausationIDFromContext(ctx))
+
+	ctx = WithCorrelationID(ctx, "req-2")
+	assert.Equal(t, "req-2", CorrelationIDFromContext(ctx))
+	assert.Equal(t, "m-2", CausationIDFromContext(ctx), "the causation is kept")
+
+	ctx = ContextWithEnvelope(context.Background(), &MessageEnvelope{ID: "legacy"})
+	assert.Equal(t, "legacy", CorrelationIDFromContext(ctx), "envelopes without correlation start a flow")
+}
+
+func TestCorrelation_Propagation(t *testing.T) {
+	client := runPoolServer(t)
+	publisher := NewPublisher(client, "test-service")
+	subscriber := NewSubscriber(client, "test-subscriber")
+
+	// order.create causes order.created; order.get is answered
+	require.NoError(t, subscriber.Subscribe("orders.create", func(ctx context.Context, subject string, msg *MessageEnvelope) error {
+		return publisher.Publish(ctx, "orders.created", "order.created", nil, nil)
+	}, nil))
+	require.NoError(t, subscriber.Subscribe("orders.get", func(ctx context.Context, subject string, msg *MessageEnvelope) error {
+		return publisher.Publish(ctx, msg.Reply, "order", nil, nil)
+	}, nil))
+	received := make(chan *MessageEnvelope, 2)
+	require.NoError(t, subscriber.Subscribe("orders.>", func(ctx context.Context, subject string, msg *MessageEnvelope) error {
+		received <- msg
+		return nil
+	}, nil))
+
+	ctx := WithCorrelationID(context.Background(), "req-1")
+	require.NoError(t, publisher.Publish(ctx, "orders.create", "order.create", nil, nil))
+	var create *MessageEnvelope
+	for range 2 {
+		select {
+		case msg := <-received:
+			assert.Equal(t, "req-1", msg.CorrelationID, msg.Type)
+			if msg.Type == "order.create" {
+				create = msg
+			} else {
+				require.NotNil(t, create, "order.create is received first")
+				assert.Equal(t, create.ID, msg.CausationID, "follow-up messages are caused by the triggering one")
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("message not received")
+		}
+	}
+
+	reply, err := publisher.Request(context.Background(), "orders.get", "order.get", nil, 2*time.Second)
+	require.NoError(t, err)
+	request := <-received
+	assert.Equal(t, request.ID, request.CorrelationID, "a request without correlation starts a flow")
+	assert.Equal(t, request.CorrelationID, reply.CorrelationID, "replies inherit the correlation ID")
+	assert.Equal(t, request.ID, reply.CausationID)
+}
+
+func TestCorrelation_LogsAndMetrics(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	reg := telemetry.NewMetricsRegistry()
+	handler := LoggingMiddleware(zap.New(core))(MetricsMiddleware(reg)(func(ctx context.Context, subject string, env *MessageEnvelope) error {
+		return nil
+	}))
+	env := &MessageEnvelope{ID: "m-2", Type: "order.created", CorrelationID: "req-1", CausationID: "m-1"}
+	require.NoError(t, handler(context.Background(), "orders.created", env))
+
+	require.Equal(t, 1, logs.Len())
+	fields := logs.All()[0].ContextMap()
+	assert.Equal(t, "req-1", fields["correlation_id"])
+	assert.Equal(t, "m-1", fields["causation_id"])
+
+	publish := PublisherLoggingMiddleware(zap.New(core))(func(ctx context.Context, subject string, msgType string, data interface{}, opts *PublishOptions) error {
+		return nil
+	})
+	require.NoError(t, publish(ContextWithEnvelope(context.Background(), env), "orders.shipped", "order.shipped", nil, nil))
+	fields = logs.All()[1].ContextMap()
+	assert.Equal(t, "req-1", fields["correlation_id"])
+	assert.Equal(t, "m-2", fields["causation_id"])
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	w := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(w, req)
+	assert.Regexp(t, `messaging_subscribe_duration_seconds_bucket\{.*\} 1 # \{correlation_id="req-1"\}`, w.Body.String(),
+		"the duration has the correlation ID as exemplar")
+
+	long := &MessageEnvelope{ID: "m-3", Type: "order.created", CorrelationID: strings.Repeat("x", 200)}
+	assert.NotPanics(t, func() { _ = handler(context.Background(), "orders.created", long) }, "long correlation IDs are not exemplars")
+}
@@ -0,0 +1,183 @@
+package nats
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/nats-io/nkeys"
+	"go.uber.org/zap"
+)
+
+// AuthRotationConfig holds the settings of the rotation of the credentials
+// of a connected client
+type AuthRotationConfig struct {
+	// WatchCreds reconnects with the creds file once it changes, before the
+	// credentials it replaces expire
+	WatchCreds bool `mapstructure:"watch_creds"`
+	// Jitter delays the reconnections with new credentials by a random
+	// duration up to Jitter, so that the instances sharing them do not
+	// reconnect at once
+	Jitter time.Duration `mapstructure:"jitter"`
+}
+
+// reauthenticate reconnects with the current credentials, after a random
+// delay up to the rotation jitter while connected. Rotations during the
+// delay share the pending reconnection, which reads the latest credentials.
+func (c *Client) reauthenticate(reason string) error {
+	if c.conn == nil {
+		return nil
+	}
+	jitter := c.config.AuthRotation.Jitter
+	// A disconnected client has no connection to keep, and the servers
+	// already refuse its old credentials
+	if jitter <= 0 || !c.conn.IsConnected() {
+		return c.reconnect(reason)
+	}
+
+	c.rotationMu.Lock()
+	defer c.rotationMu.Unlock()
+	if c.rotation != nil {
+		return nil
+	}
+	delay := rand.N(jitter)
+	c.rotation = time.AfterFunc(delay, func() {
+		c.rotationMu.Lock()
+		c.rotation = nil
+		c.rotationMu.Unlock()
+		if err := c.reconnect(reason); err != nil {
+			c.logger.Error("Failed to rotate NATS credentials", zap.String("reason", reason), zap.Error(err))
+		}
+	})
+	c.logger.Info("NATS credentials rotated, reconnection scheduled",
+		zap.String("reason", reason), zap.Duration("delay", delay))
+	return nil
+}
+
+func (c *Client) reconnect(reason string) error {
+	if err := c.conn.ForceReconnect(); err != nil {
+		return fmt.Errorf("failed to reconnect with new credentials: %w", err)
+	}
+	c.logger.Info("NATS credentials rotated, reconnecting", zap.String("reason", reason))
+	return nil
+}
+
+// stopRotation cancels the pending reconnection
+func (c *Client) stopRotation() {
+	c.rotationMu.Lock()
+	defer c.rotationMu.Unlock()
+	if c.rotation != nil {
+		c.rotation.Stop()
+		c.rotation = nil
+	}
+}
+
+// credsWatcher reauthenticates a client when its creds file changes. The
+// directory is watched rather than the file so that atomic replacements
+// (e.g. Kubernetes secret updates) are seen.
+type credsWatcher struct {
+	client  *Client
+	path    string
+	sum     [sha256.Size]byte
+	watcher *fsnotify.Watcher
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// watchCreds starts watching the creds file of c
+func watchCreds(c *Client) (*credsWatcher, error) {
+	data, err := os.ReadFile(c.config.CredsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read creds file: %w", err)
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	dir := filepath.Dir(c.config.CredsFile)
+	if err := watcher.Add(dir); err != nil {
+		_ = watcher.Close()
+		return nil, fmt.Errorf("failed to watch %s: %w", dir, err)
+	}
+	w := &credsWatcher{
+		client:  c,
+		path:    c.config.CredsFile,
+		sum:     sha256.Sum256(data),
+		watcher: watcher,
+		done:    make(chan struct{}),
+	}
+	go w.loop()
+	return w, nil
+}
+
+func (w *credsWatcher) loop() {
+	for {
+		select {
+		case ev, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if ev.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) == 0 {
+				continue
+			}
+			w.check()
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			w.client.logger.Warn("NATS creds file watcher error", zap.Error(err))
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// check reauthenticates once the creds file holds new valid credentials
+func (w *credsWatcher) check() {
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		// Removed while being replaced; the next event has the new file
+		return
+	}
+	sum := sha256.Sum256(data)
+	if sum == w.sum {
+		return
+	}
+	if err := validateCreds(data); err != nil {
+		// Files are often written in several steps; the next event completes
+		// them
+		w.client.logger.Warn("Invalid NATS creds file, keeping the current credentials",
+			zap.String("path", w.path), zap.Error(err))
+		return
+	}
+	w.sum = sum
+	if err := w.client.reauthenticate("creds file"); err != nil {
+		w.client.logger.Error("Failed to rotate NATS credentials", zap.String("path", w.path), zap.Error(err))
+	}
+}
+
+func (w *credsWatcher) close() {
+	w.closeOnce.Do(func() {
+		close(w.done)
+		_ = w.watcher.Close()
+	})
+}
+
+// validateCreds reports whether data holds a user JWT and its seed
+func validateCreds(data []byte) error {
+	if _, err := nkeys.ParseDecoratedJWT(data); err != nil {
+		return fmt.Errorf("invalid user JWT: %w", err)
+	}
+	kp, err := nkeys.ParseDecoratedNKey(data)
+	if err != nil {
+		return fmt.Errorf("invalid user seed: %w", err)
+	}
+	kp.Wipe()
+	return nil
+}
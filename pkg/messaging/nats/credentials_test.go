@@ -0,0 +1,92 @@
+package nats
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nkeys"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// writeCreds writes a creds file of a new user to path
+func writeCreds(t *testing.T, path string) {
+	t.Helper()
+	kp, err := nkeys.CreateUser()
+	require.NoError(t, err)
+	seed, err := kp.Seed()
+	require.NoError(t, err)
+	// Servers without authentication do not check the JWT
+	creds := fmt.Sprintf("-----BEGIN NATS USER JWT-----\n%s\n------END NATS USER JWT------\n\n"+
+		"-----BEGIN USER NKEY SEED-----\n%s\n------END USER NKEY SEED------\n",
+		"eyJ0eXAiOiJKV1QiLCJhbGciOiJlZDI1NTE5LW5rZXkifQ.e30.c2ln", seed)
+	require.NoError(t, os.WriteFile(path, []byte(creds), 0o600))
+}
+
+func TestValidateCreds(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "user.creds")
+	writeCreds(t, path)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NoError(t, validateCreds(data))
+
+	assert.Error(t, validateCreds(data[:len(data)/2]), "partially written")
+	assert.Error(t, validateCreds([]byte("not creds")))
+}
+
+func TestClient_UpdateAuth_Jitter(t *testing.T) {
+	s := runServer(t, &server.Options{})
+	client, err := NewNATSClient(Config{
+		URL:               s.ClientURL(),
+		Token:             "old-token",
+		MaxReconnects:     -1,
+		ReconnectWait:     10 * time.Millisecond,
+		ConnectionTimeout: time.Second,
+		AuthRotation:      AuthRotationConfig{Jitter: 300 * time.Millisecond},
+	}, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, client.Connect())
+	defer client.Close()
+	require.Eventually(t, client.IsConnected, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, client.UpdateAuth("new-token", "", ""))
+	require.NoError(t, client.UpdateAuth("newer-token", "", ""))
+	assert.Zero(t, client.Conn().Stats().Reconnects, "the reconnection waits for the jitter")
+	require.Eventually(t, func() bool { return client.Conn().Stats().Reconnects == 1 }, 2*time.Second, 10*time.Millisecond)
+	time.Sleep(400 * time.Millisecond)
+	assert.Equal(t, uint64(1), client.Conn().Stats().Reconnects, "rotations during the delay share one reconnection")
+}
+
+func TestClient_WatchCreds(t *testing.T) {
+	s := runServer(t, &server.Options{})
+	path := filepath.Join(t.TempDir(), "user.creds")
+	writeCreds(t, path)
+	client, err := NewNATSClient(Config{
+		URL:               s.ClientURL(),
+		CredsFile:         path,
+		MaxReconnects:     -1,
+		ReconnectWait:     10 * time.Millisecond,
+		ConnectionTimeout: time.Second,
+		AuthRotation:      AuthRotationConfig{WatchCreds: true},
+	}, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, client.Connect())
+	defer client.Close()
+	require.Eventually(t, client.IsConnected, 5*time.Second, 10*time.Millisecond)
+	reconnects := func() uint64 { return client.Conn().Stats().Reconnects }
+
+	// Invalid and unchanged files keep the connection
+	require.NoError(t, os.WriteFile(path+".tmp", []byte("partial"), 0o600))
+	require.NoError(t, os.Rename(path+".tmp", path))
+	time.Sleep(200 * time.Millisecond)
+	assert.Zero(t, reconnects())
+
+	writeCreds(t, path)
+	require.Eventually(t, func() bool { return reconnects() == 1 }, 2*time.Second, 10*time.Millisecond)
+	assert.Eventually(t, client.IsConnected, 5*time.Second, 10*time.Millisecond)
+}
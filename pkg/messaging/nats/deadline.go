@@ -0,0 +1,133 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"grouter/pkg/telemetry"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// MetadataDeadline is the metadata key holding the time after which the
+// requester of a message stops waiting for its reply, in RFC 3339
+const MetadataDeadline = "deadline"
+
+var (
+	// ErrNoReplySubject is returned by Reply for messages without reply
+	// subject
+	ErrNoReplySubject = errors.New("message has no reply subject")
+	// ErrDeadlineExceeded is returned by Reply once the requester stopped
+	// waiting
+	ErrDeadlineExceeded = errors.New("request deadline exceeded before reply")
+)
+
+type deadlineKey struct{}
+
+// setDeadline records in metadata the deadline of a request sent with ctx
+// and timeout: the earliest of both
+func setDeadline(ctx context.Context, metadata map[string]string, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	metadata[MetadataDeadline] = deadline.UTC().Format(time.RFC3339Nano)
+}
+
+// envelopeDeadline returns the deadline of the request env, if it has a
+// valid one
+func envelopeDeadline(env *MessageEnvelope) (time.Time, bool) {
+	raw := env.Metadata[MetadataDeadline]
+	if raw == "" {
+		return time.Time{}, false
+	}
+	deadline, err := time.Parse(time.RFC3339Nano, raw)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return deadline, true
+}
+
+// withRequestDeadline returns ctx canceled at the deadline of the request
+// env, which RequestDeadline returns. Without deadline, ctx is returned as is.
+func withRequestDeadline(ctx context.Context, env *MessageEnvelope) (context.Context, context.CancelFunc) {
+	deadline, ok := envelopeDeadline(env)
+	if !ok {
+		return ctx, func() {}
+	}
+	ctx = context.WithValue(ctx, deadlineKey{}, deadline)
+	return context.WithDeadline(ctx, deadline)
+}
+
+// RequestDeadline returns the time after which the requester of the message
+// handled with ctx stops waiting for the reply. Handlers of requests get a
+// context canceled then, so that work that can no longer be answered in time
+// stops. Deadlines are absolute: hosts need synchronized clocks.
+func RequestDeadline(ctx context.Context) (time.Time, bool) {
+	deadline, ok := ctx.Value(deadlineKey{}).(time.Time)
+	return deadline, ok
+}
+
+// Reply publishes the reply of msgType and data to the requester of msg,
+// handled with ctx. It returns ErrDeadlineExceeded instead once the
+// requester stopped waiting.
+func Reply(ctx context.Context, p Publisher, msg *MessageEnvelope, msgType string, data interface{}) error {
+	if msg.Reply == "" {
+		return ErrNoReplySubject
+	}
+	if deadline, ok := RequestDeadline(ctx); ok && time.Now().After(deadline) {
+		return ErrDeadlineExceeded
+	}
+	return p.Publish(ctx, msg.Reply, msgType, data, nil)
+}
+
+// DeadlineMiddleware returns a middleware that drops the requests received
+// past their deadline, which no reply can meet, and counts in reg (nil uses
+// the global registry) the requests received late and those handled after
+// their deadline. Dropped requests are not errors.
+func DeadlineMiddleware(logger *zap.Logger, reg *telemetry.MetricsRegistry) SubscriberMiddleware {
+	exceeded := deadlineMetric(reg)
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, subject string, env *MessageEnvelope) error {
+			deadline, ok := RequestDeadline(ctx)
+			if !ok {
+				return next(ctx, subject, env)
+			}
+			if time.Now().After(deadline) {
+				exceeded.WithLabelValues(subject, env.Type, "received").Inc()
+				if ce := logger.Check(zap.DebugLevel, "Dropped request past its deadline"); ce != nil {
+					ce.Write(
+						zap.String("subject", subject),
+						zap.String("type", env.Type),
+						zap.String("id", env.ID),
+						zap.Time("deadline", deadline),
+					)
+				}
+				return nil
+			}
+
+			err := next(ctx, subject, env)
+			if time.Now().After(deadline) {
+				exceeded.WithLabelValues(subject, env.Type, "handled").Inc()
+				if ce := logger.Check(zap.WarnLevel, "Request deadline exceeded before reply"); ce != nil {
+					ce.Write(
+						zap.String("subject", subject),
+						zap.String("type", env.Type),
+						zap.String("id", env.ID),
+						zap.Duration("late", time.Since(deadline)),
+					)
+				}
+			}
+			return err
+		}
+	}
+}
+
+func deadlineMetric(reg *telemetry.MetricsRegistry) *prometheus.CounterVec {
+	return reg.CounterVec(prometheus.CounterOpts{
+		Name: "messaging_deadline_exceeded_total",
+		Help: "Total number of requests received (stage received) or handled (stage handled) past their deadline",
+	}, []string{"subject", "type", "stage"})
+}
@@ -0,0 +1,134 @@
+package nats
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"grouter/pkg/telemetry"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSetDeadline(t *testing.T) {
+	metadata := map[string]string{}
+	setDeadline(context.Background(), metadata, time.Minute)
+	deadline, ok := envelopeDeadline(&MessageEnvelope{Metadata: metadata})
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	setDeadline(ctx, metadata, time.Minute)
+	deadline, ok = envelopeDeadline(&MessageEnvelope{Metadata: metadata})
+	require.True(t, ok)
+	want, _ := ctx.Deadline()
+	assert.True(t, want.Equal(deadline), "the earlier deadline of the context wins")
+
+	_, ok = envelopeDeadline(&MessageEnvelope{Metadata: map[string]string{MetadataDeadline: "soon"}})
+	assert.False(t, ok)
+}
+
+func TestRequest_Deadline(t *testing.T) {
+	client := runPoolServer(t)
+	publisher := NewPublisher(client, "test-service")
+	subscriber := NewSubscriber(client, "test-subscriber")
+
+	deadlines := make(chan time.Time, 1)
+	require.NoError(t, subscriber.Subscribe("quotes.get", func(ctx context.Context, subject string, msg *MessageEnvelope) error {
+		deadline, _ := RequestDeadline(ctx)
+		deadlines <- deadline
+		return Reply(ctx, publisher, msg, "quote", "42")
+	}, nil))
+	canceled := make(chan error, 1)
+	require.NoError(t, subscriber.Subscribe("quotes.slow", func(ctx context.Context, subject string, msg *MessageEnvelope) error {
+		select {
+		case <-ctx.Done():
+			canceled <- ctx.Err()
+		case <-time.After(5 * time.Second):
+			canceled <- nil
+		}
+		return nil
+	}, nil))
+	require.NoError(t, subscriber.Subscribe("quotes.event", func(ctx context.Context, subject string, msg *MessageEnvelope) error {
+		deadline, _ := RequestDeadline(ctx)
+		deadlines <- deadline
+		return nil
+	}, nil))
+
+	start := time.Now()
+	reply, err := publisher.Request(context.Background(), "quotes.get", "quote.get", nil, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "quote", reply.Type)
+	assert.WithinDuration(t, start.Add(time.Second), <-deadlines, 100*time.Millisecond)
+
+	_, err = publisher.Request(context.Background(), "quotes.slow", "quote.get", nil, 100*time.Millisecond)
+	assert.Error(t, err)
+	select {
+	case err := <-canceled:
+		assert.ErrorIs(t, err, context.DeadlineExceeded, "the responder stops with the requester")
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler not canceled")
+	}
+
+	require.NoError(t, publisher.Publish(context.Background(), "quotes.event", "quote.changed", nil, nil))
+	assert.True(t, (<-deadlines).IsZero(), "published messages have no deadline")
+}
+
+func TestReply(t *testing.T) {
+	client := runPoolServer(t)
+	publisher := NewPublisher(client, "test-service")
+	ctx := context.Background()
+
+	assert.ErrorIs(t, Reply(ctx, publisher, &MessageEnvelope{ID: "1"}, "reply", nil), ErrNoReplySubject)
+
+	late := &MessageEnvelope{ID: "1", Reply: "_INBOX.1", Metadata: map[string]string{
+		MetadataDeadline: time.Now().Add(-time.Second).Format(time.RFC3339Nano),
+	}}
+	lateCtx, cancel := withRequestDeadline(ctx, late)
+	defer cancel()
+	assert.ErrorIs(t, Reply(lateCtx, publisher, late, "reply", nil), ErrDeadlineExceeded)
+
+	sub, err := client.Conn().SubscribeSync("_INBOX.2")
+	require.NoError(t, err)
+	require.NoError(t, Reply(ctx, publisher, &MessageEnvelope{ID: "1", Reply: "_INBOX.2"}, "reply", nil))
+	_, err = sub.NextMsg(time.Second)
+	assert.NoError(t, err)
+}
+
+func TestDeadlineMiddleware(t *testing.T) {
+	reg := telemetry.NewMetricsRegistry()
+	called := 0
+	var wait time.Duration
+	handler := DeadlineMiddleware(zap.NewNop(), reg)(func(ctx context.Context, subject string, env *MessageEnvelope) error {
+		called++
+		time.Sleep(wait)
+		return nil
+	})
+	request := func(deadline time.Duration) error {
+		env := &MessageEnvelope{ID: "1", Type: "quote.get", Metadata: map[string]string{
+			MetadataDeadline: time.Now().Add(deadline).Format(time.RFC3339Nano),
+		}}
+		ctx, cancel := withRequestDeadline(context.Background(), env)
+		defer cancel()
+		return handler(ctx, "quotes.get", env)
+	}
+
+	assert.NoError(t, handler(context.Background(), "quotes.get", &MessageEnvelope{ID: "1", Type: "quote.get"}))
+	assert.NoError(t, request(time.Minute))
+	assert.Equal(t, 2, called)
+
+	assert.NoError(t, request(-time.Second), "late requests are dropped, not failed")
+	assert.Equal(t, 2, called)
+
+	wait = 20 * time.Millisecond
+	assert.NoError(t, request(5*time.Millisecond))
+	assert.Equal(t, 3, called)
+
+	metric := deadlineMetric(reg)
+	assert.Equal(t, float64(1), testutil.ToFloat64(metric.WithLabelValues("quotes.get", "quote.get", "received")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metric.WithLabelValues("quotes.get", "quote.get", "handled")))
+}
@@ -0,0 +1,542 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"grouter/pkg/telemetry"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+// The helpers below let drivers of other transports (see the conformance
+// subpackage) build and read envelopes like the NATS publisher and
+// subscriber do.
+
+// NewEnvelope returns the envelope of msgType and data published by source
+// with ctx, carrying the trace context, the tenant and the correlation of ctx
+func NewEnvelope(ctx context.Context, source, msgType string, data interface{}) (MessageEnvelope, error) {
+	p := &publishPipeline{source: source}
+	return p.newEnvelope(ctx, msgType, data, nil)
+}
+
+// SetRequestDeadline records in env the time after which a request sent
+// with ctx and timeout is no longer waited for, see RequestDeadline
+func SetRequestDeadline(ctx context.Context, env *MessageEnvelope, timeout time.Duration) {
+	if env.Metadata == nil {
+		env.Metadata = make(map[string]string)
+	}
+	setDeadline(ctx, env.Metadata, timeout)
+}
+
+// DecodeEnvelope unmarshals and upgrades the envelope data received on
+// subject, then decodes it with codec (nil for none) and decompresses it
+func DecodeEnvelope(data []byte, subject string, codec Codec, env *MessageEnvelope) error {
+	return decodeEnvelope(data, subject, nil, codec, env)
+}
+
+// HandlerContext returns the context of the handler of env: its trace
+// context, tenant and correlation, canceled at the deadline of requests.
+// cancel releases it once the handler returns.
+func HandlerContext(env *MessageEnvelope) (ctx context.Context, cancel context.CancelFunc) {
+	ctx = extractContext(env)
+	if env.Reply == "" {
+		return ctx, func() {}
+	}
+	return withRequestDeadline(ctx, env)
+}
+
+// SendFunc publishes the signed envelope on subject over the transport of a
+// driver, see DriverPublisher. opts are those of Publish.
+type SendFunc func(ctx context.Context, subject string, envelope *MessageEnvelope, opts *PublishOptions) error
+
+// RoundTripFunc sends the signed request envelope on subject over the
+// transport of a driver and returns its response, waiting until ctx is done
+type RoundTripFunc func(ctx context.Context, subject string, envelope *MessageEnvelope) (*MessageEnvelope, error)
+
+// DriverPublisher is the part of a Publisher shared by the drivers of other
+// transports, which embed it: it applies the middleware, then creates,
+// validates and signs the envelopes of Publish and Request like
+// NATSPublisher does, and hands them to the transport of the driver.
+type DriverPublisher struct {
+	publishPipeline
+	logger    *zap.Logger
+	send      SendFunc
+	roundTrip RoundTripFunc
+}
+
+// NewDriverPublisher returns the publisher of the envelopes of source over
+// send and roundTrip, which marshal them with Marshal
+func NewDriverPublisher(source string, logger *zap.Logger, send SendFunc, roundTrip RoundTripFunc) *DriverPublisher {
+	return &DriverPublisher{
+		publishPipeline: publishPipeline{source: source},
+		logger:          logger,
+		send:            send,
+		roundTrip:       roundTrip,
+	}
+}
+
+// Codec returns the codec of the publisher, which decodes the responses to
+// its requests
+func (p *DriverPublisher) Codec() Codec {
+	return p.codec
+}
+
+// Marshal compresses and encodes the data of the signed envelope published
+// on subject, and returns its JSON
+func (p *DriverPublisher) Marshal(subject string, envelope *MessageEnvelope) ([]byte, error) {
+	if err := p.encode(subject, envelope); err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal envelope: %w", err)
+	}
+	return body, nil
+}
+
+// Publish publishes a message to a subject
+func (p *DriverPublisher) Publish(ctx context.Context, subject string, msgType string, data interface{}, opts *PublishOptions) error {
+	return p.publishChain(p.publish)(ctx, subject, msgType, data, opts)
+}
+
+func (p *DriverPublisher) publish(ctx context.Context, subject string, msgType string, data interface{}, opts *PublishOptions) error {
+	if opts != nil {
+		subject = LaneSubject(subject, opts.Priority)
+	}
+	envelope, err := p.newEnvelope(ctx, msgType, data, nil)
+	if err != nil {
+		return err
+	}
+	if err := p.validate(&envelope); err != nil {
+		return err
+	}
+	applyOptions(&envelope, opts)
+	if err := p.sign(&envelope); err != nil {
+		return err
+	}
+
+	if err := p.send(ctx, subject, &envelope, opts); err != nil {
+		return err
+	}
+	if ce := p.logger.Check(zap.DebugLevel, "Published message"); ce != nil {
+		ce.Write(
+			zap.String("subject", subject),
+			zap.String("type", msgType),
+			zap.String("id", envelope.ID),
+		)
+	}
+	return nil
+}
+
+// PublishError publishes an error message to a reply subject
+func (p *DriverPublisher) PublishError(ctx context.Context, subject string, errMsg string) error {
+	if subject == "" {
+		return nil
+	}
+	return p.Publish(ctx, subject, "error", map[string]string{"error": errMsg}, nil)
+}
+
+// Request sends a request and waits for a response
+func (p *DriverPublisher) Request(ctx context.Context, subject string, msgType string, data interface{}, timeout time.Duration) (*MessageEnvelope, error) {
+	return p.requestChain(p.request)(ctx, subject, msgType, data, timeout)
+}
+
+func (p *DriverPublisher) request(ctx context.Context, subject string, msgType string, data interface{}, timeout time.Duration) (*MessageEnvelope, error) {
+	envelope, err := p.newEnvelope(ctx, msgType, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Tell the responder how long we wait
+	setDeadline(ctx, envelope.Metadata, timeout)
+	if err := p.sign(&envelope); err != nil {
+		return nil, err
+	}
+
+	requestCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	response, err := p.roundTrip(requestCtx, subject, &envelope)
+	if err != nil {
+		return nil, err
+	}
+	if ce := p.logger.Check(zap.DebugLevel, "Request completed"); ce != nil {
+		ce.Write(
+			zap.String("subject", subject),
+			zap.String("request_id", envelope.ID),
+			zap.String("response_id", response.ID),
+		)
+	}
+	return response, nil
+}
+
+// Delivery is a message received by the subscriber of a driver, handed to
+// DriverSubscriber.Process
+type Delivery struct {
+	// Subject is the subject the message was published on
+	Subject string
+	// Message settles the message
+	Message DriverMessage
+	// Decode reads the envelope of the message, decoding its data with codec
+	Decode func(codec Codec, envelope *MessageEnvelope) error
+	// Response marks the responses to the requests of the publisher, handed
+	// to their handler without validation nor middleware
+	Response bool
+	// Fields identify the message in the logs of its failed
+	// acknowledgements
+	Fields []zap.Field
+}
+
+// DriverSubscription is a subscription of a driver, started by its
+// SubscribeFunc
+type DriverSubscription interface {
+	// Subject returns the subject of the subscription
+	Subject() string
+	// Stop stops the receives; the subscription cleans up once its handlers
+	// return, so that they can still acknowledge their messages. Calls after
+	// the first do nothing.
+	Stop() error
+	// Done is closed once Stop was called, the handlers returned and the
+	// subscription is cleaned up
+	Done() <-chan struct{}
+}
+
+// SubscribeFunc starts receiving subject on a transport and handing the
+// messages to handler with DriverSubscriber.Process, with the options
+// supported by DriverSubscriber.SubscribeSubject
+type SubscribeFunc func(subject string, handler HandlerFunc, opts *SubscribeOptions) (DriverSubscription, error)
+
+// DriverSubscriber is the part of a Subscriber shared by the drivers of
+// other transports, which embed it and hand it the messages they receive
+// with Process. It keeps the subscriptions started by the subscribe function
+// of the driver and stops them, without JetStream, payload store and
+// priority lanes.
+type DriverSubscriber struct {
+	subscribePipeline
+	name      string
+	logger    *zap.Logger
+	subscribe SubscribeFunc
+	closer    func() error
+	// pending counts the messages received and not handled yet
+	pending atomic.Int64
+
+	subscriptions []*driverSubscription
+	mu            sync.Mutex
+}
+
+// NewDriverSubscriber returns the subscriber of the driver name logging to
+// logger, starting its subscriptions with subscribe. Close runs closer once
+// the subscriptions are stopped, to close the connection of the driver.
+func NewDriverSubscriber(name string, logger *zap.Logger, subscribe SubscribeFunc, closer func() error) *DriverSubscriber {
+	return &DriverSubscriber{name: name, logger: logger, subscribe: subscribe, closer: closer}
+}
+
+// SetPayloadStore is a no-op: the publishers of drivers never spill
+// envelopes
+func (s *DriverSubscriber) SetPayloadStore(PayloadStore) {}
+
+// SetQuarantine is a no-op: drivers dead letter the rejected messages on
+// their transport
+func (s *DriverSubscriber) SetQuarantine(*Quarantine) {}
+
+// SetPoolMetrics is a no-op: the prefetch of drivers bounds the messages
+// received
+func (s *DriverSubscriber) SetPoolMetrics(*telemetry.MetricsRegistry) {}
+
+// Subscribe subscribes to a subject with a handler
+func (s *DriverSubscriber) Subscribe(subject string, handler HandlerFunc, opts *SubscribeOptions) error {
+	_, err := s.SubscribeSubject(subject, handler, opts)
+	return err
+}
+
+// SubscribeSubject starts a subscription of the driver to subject, with
+// MaxWorkers handlers (default 1)
+func (s *DriverSubscriber) SubscribeSubject(subject string, handler HandlerFunc, opts *SubscribeOptions) (Subscription, error) {
+	if opts == nil {
+		opts = &SubscribeOptions{}
+	}
+	if opts.Priority {
+		return nil, fmt.Errorf("%s: priority lanes: %w", s.name, errors.ErrUnsupported)
+	}
+	if opts.Ordered && opts.MaxWorkers > 1 {
+		return nil, fmt.Errorf("%s: ordered workers: %w", s.name, errors.ErrUnsupported)
+	}
+	started, err := s.subscribe(subject, handler, opts)
+	if err != nil {
+		return nil, err
+	}
+	sub := &driverSubscription{DriverSubscription: started, subscriber: s}
+	s.mu.Lock()
+	s.subscriptions = append(s.subscriptions, sub)
+	s.mu.Unlock()
+	return sub, nil
+}
+
+// SubscribePush is not supported: drivers have no JetStream
+func (s *DriverSubscriber) SubscribePush(subject string, handler HandlerFunc, opts ...nats.SubOpt) error {
+	return fmt.Errorf("%s: SubscribePush: %w, use Subscribe with a queue group", s.name, errors.ErrUnsupported)
+}
+
+// SubscribePull is not supported: drivers have no JetStream
+func (s *DriverSubscriber) SubscribePull(subject, durable string, handler HandlerFunc, opts ...PullOption) (Subscription, error) {
+	return nil, fmt.Errorf("%s: SubscribePull: %w, use Subscribe with a queue group", s.name, errors.ErrUnsupported)
+}
+
+// Unsubscribe stops all the subscriptions
+func (s *DriverSubscriber) Unsubscribe() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sub := range s.subscriptions {
+		if err := sub.Stop(); err != nil {
+			s.logger.Error("Failed to unsubscribe", zap.Error(err))
+		}
+	}
+	s.subscriptions = nil
+	return nil
+}
+
+// UnsubscribeSubject stops the subscriptions on subject, leaving the others
+// in place. It does nothing when there is none.
+func (s *DriverSubscriber) UnsubscribeSubject(subject string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	kept := s.subscriptions[:0]
+	for _, sub := range s.subscriptions {
+		if sub.Subject() != subject {
+			kept = append(kept, sub)
+			continue
+		}
+		errs = append(errs, sub.Stop())
+	}
+	clear(s.subscriptions[len(kept):])
+	s.subscriptions = kept
+	return errors.Join(errs...)
+}
+
+// Close stops the subscriptions, waits for the handlers in flight, then
+// closes the connection of the driver, as the NATS messenger closes its
+// client
+func (s *DriverSubscriber) Close() error {
+	s.mu.Lock()
+	subs := slices.Clone(s.subscriptions)
+	s.mu.Unlock()
+	if err := s.Unsubscribe(); err != nil {
+		return err
+	}
+
+	timeout := time.After(5 * time.Second)
+	for _, sub := range subs {
+		select {
+		case <-sub.Done():
+		case <-timeout:
+			s.logger.Warn("Subscriber closed with active handlers (timeout)")
+			return s.closer()
+		}
+	}
+	s.logger.Info("Subscriber closed gracefully")
+	return s.closer()
+}
+
+// remove removes sub from the subscriptions
+func (s *DriverSubscriber) remove(sub *driverSubscription) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := slices.Index(s.subscriptions, sub); i >= 0 {
+		s.subscriptions = slices.Delete(s.subscriptions, i, i+1)
+	}
+}
+
+// driverSubscription is a subscription of a driver kept by its
+// DriverSubscriber
+type driverSubscription struct {
+	DriverSubscription
+	subscriber *DriverSubscriber
+}
+
+// Unsubscribe stops the subscription and removes it from the subscriber
+func (s *driverSubscription) Unsubscribe() error {
+	s.subscriber.remove(s)
+	return s.Stop()
+}
+
+// Drain stops the subscription, so that the members of its queue group get
+// the new messages, and returns once the messages received are handled or
+// ctx is done
+func (s *driverSubscription) Drain(ctx context.Context) error {
+	if err := s.Unsubscribe(); err != nil {
+		return err
+	}
+	select {
+	case <-s.Done():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// DriverSubscriptionOf returns the subscription of the driver behind sub,
+// returned by DriverSubscriber.SubscribeSubject, or nil
+func DriverSubscriptionOf(sub Subscription) DriverSubscription {
+	if s, ok := sub.(*driverSubscription); ok {
+		return s.DriverSubscription
+	}
+	return nil
+}
+
+// QueueDepth returns the number of messages received and not handled yet
+func (s *DriverSubscriber) QueueDepth() int {
+	return int(s.pending.Load())
+}
+
+// AddPending adds delta to the number of messages received and not handled
+// yet, reported by QueueDepth
+func (s *DriverSubscriber) AddPending(delta int) {
+	s.pending.Add(int64(delta))
+}
+
+// Process decodes and validates the message of d and passes it to handler
+// through the middleware, like NATSSubscriber does. The handler gets the
+// DriverAcker of the message from its context, and a context canceled at the
+// deadline of requests. Messages that cannot be decoded or validated are
+// terminated, the others settled per the AckPolicy of the handler once it
+// returns.
+func (s *DriverSubscriber) Process(d Delivery, handler HandlerFunc) {
+	acker := NewDriverAcker(d.Message)
+
+	var envelope MessageEnvelope
+	if err := d.Decode(s.codec, &envelope); err != nil {
+		s.logger.Error("Failed to unmarshal message", zap.Error(err), zap.String("subject", d.Subject))
+		s.settle(d, acker.Term)
+		return
+	}
+
+	// Extract trace context, tenant and request deadline
+	ctx, cancel := HandlerContext(&envelope)
+	defer cancel()
+	ctx = ContextWithAcker(ctx, acker)
+
+	redelivered := zap.Bool("redelivered", d.Message.Redelivered())
+	if !d.Response {
+		if err := s.validate(s.logger, d.Subject, &envelope, redelivered); err != nil {
+			s.settle(d, acker.Term)
+			return
+		}
+		handler = s.chain(handler)
+	}
+
+	err := handle(ctx, s.logger, d.Subject, &envelope, handler, redelivered)
+	s.settle(d, func() error { return acker.Finish(err) })
+}
+
+// settle runs an acknowledgement of the message of d, logging its failure
+func (s *DriverSubscriber) settle(d Delivery, fn func() error) {
+	if err := fn(); err != nil {
+		s.logger.Error("Failed to acknowledge message", append([]zap.Field{
+			zap.Error(err),
+			zap.String("subject", d.Subject),
+		}, d.Fields...)...)
+	}
+}
+
+// ApplyDefaults sets the unset fields of cfg, a pointer to the config struct
+// of a driver, to those of defaults: the empty strings and the numbers and
+// durations not above zero. Fields without default are left alone.
+func ApplyDefaults[C any](cfg *C, defaults C) {
+	v, d := reflect.ValueOf(cfg).Elem(), reflect.ValueOf(defaults)
+	for i := 0; i < v.NumField(); i++ {
+		field, def := v.Field(i), d.Field(i)
+		if !field.CanSet() || def.IsZero() {
+			continue
+		}
+		var unset bool
+		switch field.Kind() {
+		case reflect.String:
+			unset = field.String() == ""
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			unset = field.Int() <= 0
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			unset = field.Uint() == 0
+		case reflect.Float32, reflect.Float64:
+			unset = field.Float() <= 0
+		}
+		if unset {
+			field.Set(def)
+		}
+	}
+}
+
+// RegisterDialer registers under name a driver whose config, starting from
+// defaults, is decoded from the messaging settings, then dialed. It is the
+// usual RegisterDriver of the driver packages.
+func RegisterDialer[C any, D interface{ Messenger() *Messenger }](name string, defaults func() C, dial func(cfg C, logger *zap.Logger, source string) (D, error)) {
+	RegisterDriver(name, func(decode func(cfg any) error, logger *zap.Logger, source string) (*Messenger, error) {
+		cfg := defaults()
+		if err := decode(&cfg); err != nil {
+			return nil, err
+		}
+		d, err := dial(cfg, logger, source)
+		if err != nil {
+			return nil, err
+		}
+		return d.Messenger(), nil
+	})
+}
+
+// DriverFunc opens a messenger over another transport. decode decodes the
+// settings of the driver into cfg, a pointer to its config struct with
+// mapstructure tags. source is the source of the published envelopes,
+// usually the app name.
+type DriverFunc func(decode func(cfg any) error, logger *zap.Logger, source string) (*Messenger, error)
+
+// drivers are the messaging drivers linked into the binary, selected by name
+// with messaging.driver
+var drivers = struct {
+	sync.RWMutex
+	m map[string]DriverFunc
+}{m: make(map[string]DriverFunc)}
+
+// RegisterDriver makes the driver of a transport linked into the binary
+// available under name, typically from an init function of its package.
+// Registering a name again replaces it.
+func RegisterDriver(name string, open DriverFunc) {
+	drivers.Lock()
+	defer drivers.Unlock()
+	drivers.m[name] = open
+}
+
+// OpenDriver opens a messenger with the driver registered under name
+func OpenDriver(name string, decode func(cfg any) error, logger *zap.Logger, source string) (*Messenger, error) {
+	drivers.RLock()
+	open, ok := drivers.m[name]
+	drivers.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("messaging driver %q is not registered, link its package into the binary", name)
+	}
+	msg, err := open(decode, logger, source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open messaging driver %s: %w", name, err)
+	}
+	return msg, nil
+}
+
+// Drivers returns the names of the registered drivers, sorted
+func Drivers() []string {
+	drivers.RLock()
+	defer drivers.RUnlock()
+	names := make([]string, 0, len(drivers.m))
+	for name := range drivers.m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
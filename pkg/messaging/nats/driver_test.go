@@ -0,0 +1,320 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"grouter/pkg/tenant"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestDriverHelpers_RoundTrip(t *testing.T) {
+	ctx := tenant.NewContext(WithCorrelationID(context.Background(), "flow-1"), "acme")
+	env, err := NewEnvelope(ctx, "orders", "order.created", map[string]int{"id": 42})
+	require.NoError(t, err)
+	assert.NotEmpty(t, env.ID)
+	assert.Equal(t, "orders", env.Source)
+	assert.Equal(t, EnvelopeVersion, env.Version)
+	assert.Equal(t, "flow-1", env.CorrelationID)
+	SetRequestDeadline(ctx, &env, time.Minute)
+
+	data, err := json.Marshal(env)
+	require.NoError(t, err)
+	var received MessageEnvelope
+	require.NoError(t, DecodeEnvelope(data, "orders.created", nil, &received))
+	assert.JSONEq(t, `{"id":42}`, string(received.Data))
+
+	handlerCtx, cancel := HandlerContext(&received)
+	defer cancel()
+	assert.Equal(t, "acme", tenant.FromContext(handlerCtx))
+	assert.Equal(t, "flow-1", CorrelationIDFromContext(handlerCtx))
+	assert.Equal(t, env.ID, CausationIDFromContext(handlerCtx))
+	_, ok := RequestDeadline(handlerCtx)
+	assert.False(t, ok, "messages without reply subject are not requests")
+
+	received.Reply = "inbox"
+	handlerCtx, cancel = HandlerContext(&received)
+	defer cancel()
+	_, ok = handlerCtx.Deadline()
+	assert.True(t, ok, "requests are canceled at their deadline")
+}
+
+// policyAcker records the policy set by WithAckPolicy
+type policyAcker struct {
+	Acker
+	policy AckPolicy
+}
+
+func (a *policyAcker) SetAckPolicy(policy AckPolicy) { a.policy = policy }
+
+func TestContextWithAcker(t *testing.T) {
+	a := &policyAcker{}
+	ctx := ContextWithAcker(context.Background(), a)
+	assert.Same(t, a, AckerFromContext(ctx))
+
+	h := WithAckPolicy(AckManual, func(ctx context.Context, subject string, msg *MessageEnvelope) error { return nil })
+	require.NoError(t, h(ctx, "orders", &MessageEnvelope{}))
+	assert.Equal(t, AckManual, a.policy, "drivers follow the ack policy")
+}
+
+func TestRegisterDriver(t *testing.T) {
+	var decoded struct{ URL string }
+	RegisterDriver("test", func(decode func(cfg any) error, logger *zap.Logger, source string) (*Messenger, error) {
+		if err := decode(&decoded); err != nil {
+			return nil, err
+		}
+		return NewMessenger(nil, nil, nil), nil
+	})
+	assert.Contains(t, Drivers(), "test")
+
+	msg, err := OpenDriver("test", func(cfg any) error {
+		cfg.(*struct{ URL string }).URL = "test://"
+		return nil
+	}, zap.NewNop(), "orders")
+	require.NoError(t, err)
+	assert.NotNil(t, msg)
+	assert.Equal(t, "test://", decoded.URL)
+
+	_, err = OpenDriver("test", func(cfg any) error { return errors.New("bad settings") }, zap.NewNop(), "orders")
+	assert.ErrorContains(t, err, "failed to open messaging driver test: bad settings")
+
+	_, err = OpenDriver("unknown", nil, zap.NewNop(), "orders")
+	assert.ErrorContains(t, err, `messaging driver "unknown" is not registered`)
+}
+
+func TestDriverPublisher(t *testing.T) {
+	type sent struct {
+		subject  string
+		envelope MessageEnvelope
+		opts     *PublishOptions
+	}
+	var published []sent
+	p := NewDriverPublisher("orders", zap.NewNop(),
+		func(ctx context.Context, subject string, env *MessageEnvelope, opts *PublishOptions) error {
+			published = append(published, sent{subject, *env, opts})
+			return nil
+		},
+		func(ctx context.Context, subject string, env *MessageEnvelope) (*MessageEnvelope, error) {
+			_, ok := ctx.Deadline()
+			assert.True(t, ok, "requests are bounded by their timeout")
+			assert.NotEmpty(t, env.Metadata[MetadataDeadline], "and tell their deadline")
+			return &MessageEnvelope{ID: "response", Type: "reply"}, nil
+		})
+	var order []string
+	p.Use(func(next PublisherFunc) PublisherFunc {
+		return func(ctx context.Context, subject, msgType string, data interface{}, opts *PublishOptions) error {
+			order = append(order, "publish")
+			return next(ctx, subject, msgType, data, opts)
+		}
+	})
+	p.UseRequest(func(next RequestFunc) RequestFunc {
+		return func(ctx context.Context, subject, msgType string, data interface{}, timeout time.Duration) (*MessageEnvelope, error) {
+			order = append(order, "request")
+			return next(ctx, subject, msgType, data, timeout)
+		}
+	})
+
+	opts := &PublishOptions{Priority: PriorityHigh, TTL: time.Minute, PartitionKey: "customer-1"}
+	require.NoError(t, p.Publish(context.Background(), "orders.created", "order.created", map[string]int{"id": 42}, opts))
+	require.Len(t, published, 1)
+	assert.Equal(t, LaneSubject("orders.created", PriorityHigh), published[0].subject)
+	assert.Equal(t, "orders", published[0].envelope.Source)
+	assert.Equal(t, "customer-1", published[0].envelope.Metadata[MetadataPartitionKey])
+	assert.False(t, published[0].envelope.ExpiresAt.IsZero())
+	assert.Same(t, opts, published[0].opts)
+
+	response, err := p.Request(context.Background(), "orders.get", "order.get", nil, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "response", response.ID)
+	assert.Equal(t, []string{"publish", "request"}, order)
+
+	require.NoError(t, p.PublishError(context.Background(), "", "ignored"), "errors without reply subject")
+	assert.Len(t, published, 1)
+
+	p.SetValidator(rejectAll{})
+	err = p.Publish(context.Background(), "orders.created", "order.created", nil, nil)
+	assert.ErrorContains(t, err, "validation failed for type order.created")
+	assert.Len(t, published, 1)
+}
+
+func TestDriverPublisher_Marshal(t *testing.T) {
+	p := NewDriverPublisher("orders", zap.NewNop(), nil, nil)
+	compressor, err := NewCompressor(CompressionConfig{Enabled: true, Threshold: 1}, nil)
+	require.NoError(t, err)
+	p.SetCompressor(compressor)
+	env, err := NewEnvelope(context.Background(), "orders", "order.created", map[string]string{"note": "hello hello hello"})
+	require.NoError(t, err)
+
+	body, err := p.Marshal("orders.created", &env)
+	require.NoError(t, err)
+	var received MessageEnvelope
+	require.NoError(t, DecodeEnvelope(body, "orders.created", nil, &received))
+	assert.JSONEq(t, `{"note":"hello hello hello"}`, string(received.Data), "compressed, then decompressed on receipt")
+}
+
+func TestDriverSubscriber_Process(t *testing.T) {
+	s := NewDriverSubscriber("test", zap.NewNop(), nil, nil)
+	var order []string
+	s.Use(func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, subject string, env *MessageEnvelope) error {
+			order = append(order, "middleware")
+			return next(ctx, subject, env)
+		}
+	})
+	delivery := func(msg *driverMessage, data string, response bool) Delivery {
+		return Delivery{
+			Subject: "orders.created",
+			Message: msg,
+			Decode: func(codec Codec, env *MessageEnvelope) error {
+				return DecodeEnvelope([]byte(data), "orders.created", codec, env)
+			},
+			Response: response,
+		}
+	}
+	handler := func(ctx context.Context, subject string, env *MessageEnvelope) error {
+		assert.NotNil(t, AckerFromContext(ctx))
+		order = append(order, "handler")
+		return nil
+	}
+
+	ok := &driverMessage{}
+	s.Process(delivery(ok, `{"id":"1","type":"order.created","data":{}}`, false), handler)
+	assert.Equal(t, []string{"ack"}, ok.settled)
+	assert.Equal(t, []string{"middleware", "handler"}, order)
+
+	undecodable := &driverMessage{}
+	s.Process(delivery(undecodable, "not json", false), handler)
+	assert.Equal(t, []string{"term"}, undecodable.settled)
+
+	s.SetValidator(rejectAll{})
+	invalid := &driverMessage{}
+	s.Process(delivery(invalid, `{"id":"2","type":"order.created","data":{}}`, false), handler)
+	assert.Equal(t, []string{"term"}, invalid.settled)
+
+	order = nil
+	response := &driverMessage{}
+	s.Process(delivery(response, `{"id":"3","type":"reply","data":{}}`, true), handler)
+	assert.Equal(t, []string{"ack"}, response.settled)
+	assert.Equal(t, []string{"handler"}, order, "responses skip the validator and the middleware")
+}
+
+// stoppable is a subscription of a driver, done once stopped
+type stoppable struct {
+	subject string
+	stops   int
+	done    chan struct{}
+}
+
+func (s *stoppable) Subject() string { return s.subject }
+
+func (s *stoppable) Stop() error {
+	if s.stops++; s.stops == 1 {
+		close(s.done)
+	}
+	return nil
+}
+
+func (s *stoppable) Done() <-chan struct{} { return s.done }
+
+func TestDriverSubscriber_Lifecycle(t *testing.T) {
+	var started []*stoppable
+	closed := 0
+	s := NewDriverSubscriber("test", zap.NewNop(), func(subject string, handler HandlerFunc, opts *SubscribeOptions) (DriverSubscription, error) {
+		sub := &stoppable{subject: subject, done: make(chan struct{})}
+		started = append(started, sub)
+		return sub, nil
+	}, func() error {
+		closed++
+		return nil
+	})
+	handler := func(ctx context.Context, subject string, env *MessageEnvelope) error { return nil }
+
+	_, err := s.SubscribeSubject("orders", handler, &SubscribeOptions{Priority: true})
+	assert.ErrorIs(t, err, errors.ErrUnsupported)
+	assert.ErrorContains(t, err, "test: priority lanes")
+	_, err = s.SubscribeSubject("orders", handler, &SubscribeOptions{Ordered: true, MaxWorkers: 2})
+	assert.ErrorIs(t, err, errors.ErrUnsupported)
+	assert.ErrorIs(t, s.SubscribePush("orders", handler), errors.ErrUnsupported)
+	_, err = s.SubscribePull("orders", "durable", handler)
+	assert.ErrorIs(t, err, errors.ErrUnsupported)
+	assert.Empty(t, started, "unsupported options start nothing")
+
+	orders, err := s.SubscribeSubject("orders", handler, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "orders", orders.Subject())
+	assert.Same(t, started[0], DriverSubscriptionOf(orders))
+	require.NoError(t, s.Subscribe("payments", handler, nil))
+	require.NoError(t, s.Subscribe("audit", handler, nil))
+
+	require.NoError(t, orders.Drain(context.Background()))
+	assert.Equal(t, 1, started[0].stops)
+	require.NoError(t, s.UnsubscribeSubject("payments"))
+	assert.Equal(t, 1, started[1].stops)
+	require.NoError(t, s.UnsubscribeSubject("payments"), "nothing left on the subject")
+
+	require.NoError(t, s.Close())
+	assert.Equal(t, []int{1, 1, 1}, []int{started[0].stops, started[1].stops, started[2].stops},
+		"removed subscriptions are not stopped again")
+	assert.Equal(t, 1, closed)
+}
+
+func TestApplyDefaults(t *testing.T) {
+	type config struct {
+		URL      string
+		Prefix   string
+		Workers  int
+		Timeout  time.Duration
+		DB       int
+		Ordering bool
+		unset    string
+	}
+	defaults := config{Prefix: "grouter.", Workers: 4, Timeout: time.Second, Ordering: true, unset: "x"}
+
+	cfg := config{URL: "test://", Workers: -1, DB: -1}
+	ApplyDefaults(&cfg, defaults)
+	assert.Equal(t, config{URL: "test://", Prefix: "grouter.", Workers: 4, Timeout: time.Second, DB: -1}, cfg,
+		"strings, numbers and durations without default or set are left alone, as booleans")
+}
+
+// dialed is a driver opened by RegisterDialer
+type dialed struct {
+	cfg struct{ URL, Exchange string }
+}
+
+func (d *dialed) Messenger() *Messenger { return NewMessenger(nil, nil, nil) }
+
+func TestRegisterDialer(t *testing.T) {
+	type config = struct{ URL, Exchange string }
+	var got *dialed
+	RegisterDialer("dialer", func() config { return config{Exchange: "grouter"} }, func(cfg config, logger *zap.Logger, source string) (*dialed, error) {
+		if cfg.URL == "" {
+			return nil, errors.New("url is required")
+		}
+		got = &dialed{cfg: cfg}
+		return got, nil
+	})
+
+	msg, err := OpenDriver("dialer", func(cfg any) error {
+		cfg.(*config).URL = "test://"
+		return nil
+	}, zap.NewNop(), "orders")
+	require.NoError(t, err)
+	assert.NotNil(t, msg)
+	assert.Equal(t, config{URL: "test://", Exchange: "grouter"}, got.cfg, "settings decoded over the defaults")
+
+	_, err = OpenDriver("dialer", func(cfg any) error { return nil }, zap.NewNop(), "orders")
+	assert.ErrorContains(t, err, "url is required")
+}
+
+// rejectAll is a validator rejecting every message
+type rejectAll struct{}
+
+func (rejectAll) Validate(msgType string, data []byte) error {
+	return errors.New("rejected")
+}
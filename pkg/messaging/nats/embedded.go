@@ -0,0 +1,113 @@
+package nats
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"go.uber.org/zap"
+)
+
+// EmbeddedServerConfig configures the NATS server run in the process when
+// Config.Embedded is set, for single binary deployments and local
+// development without an external broker
+type EmbeddedServerConfig struct {
+	// Host and Port to listen on (127.0.0.1:4222 when unset, -1 for a random
+	// port)
+	Host string `mapstructure:"host"`
+	Port int    `mapstructure:"port"`
+	// JetStream enables JetStream, storing the streams in StoreDir (a
+	// temporary directory when empty)
+	JetStream bool   `mapstructure:"jetstream"`
+	StoreDir  string `mapstructure:"store_dir"`
+	// ReadyTimeout is how long to wait for the server to accept connections
+	// (10s when unset)
+	ReadyTimeout time.Duration `mapstructure:"ready_timeout"`
+}
+
+// EmbeddedServer is a NATS server running in the process
+type EmbeddedServer struct {
+	server *server.Server
+	opts   *server.Options
+	logger *zap.Logger
+}
+
+// StartEmbeddedServer starts the NATS server of cfg. It requires the token or
+// the username and password of auth when set, so that the client of auth
+// connects to it as to an external server.
+func StartEmbeddedServer(cfg EmbeddedServerConfig, auth Config, logger *zap.Logger) (*EmbeddedServer, error) {
+	if logger == nil {
+		return nil, fmt.Errorf("logger is required")
+	}
+	opts := &server.Options{
+		Host:          cfg.Host,
+		Port:          cfg.Port,
+		JetStream:     cfg.JetStream,
+		StoreDir:      cfg.StoreDir,
+		NoSigs:        true,
+		Authorization: auth.Token,
+		Username:      auth.Username,
+		Password:      auth.Password,
+	}
+	if opts.Host == "" {
+		opts.Host = "127.0.0.1"
+	}
+	timeout := cfg.ReadyTimeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	s, err := server.NewServer(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embedded NATS server: %w", err)
+	}
+	logger = logger.With(zap.String("component", "nats-server"))
+	s.SetLoggerV2(serverLogger{logger.Sugar()}, false, false, false)
+	go s.Start()
+	if !s.ReadyForConnections(timeout) {
+		s.Shutdown()
+		return nil, fmt.Errorf("embedded NATS server not ready after %s", timeout)
+	}
+	logger.Info("Embedded NATS server started",
+		zap.String("url", s.ClientURL()),
+		zap.Bool("jetstream", s.JetStreamEnabled()),
+		zap.String("store_dir", s.StoreDir()),
+	)
+	return &EmbeddedServer{server: s, opts: opts, logger: logger}, nil
+}
+
+// ClientURL returns the URL the clients connect to
+func (e *EmbeddedServer) ClientURL() string {
+	return e.server.ClientURL()
+}
+
+// UpdateAuth replaces the credentials the server requires; the clients
+// connected with other ones are disconnected
+func (e *EmbeddedServer) UpdateAuth(token, username, password string) error {
+	opts := e.opts.Clone()
+	opts.Authorization, opts.Username, opts.Password = token, username, password
+	if err := e.server.ReloadOptions(opts); err != nil {
+		return fmt.Errorf("failed to update embedded server auth: %w", err)
+	}
+	e.opts = opts
+	return nil
+}
+
+// Shutdown stops the server, closing its client connections
+func (e *EmbeddedServer) Shutdown() {
+	e.server.Shutdown()
+	e.server.WaitForShutdown()
+	e.logger.Info("Embedded NATS server stopped")
+}
+
+// serverLogger writes the logs of the embedded server to zap
+type serverLogger struct {
+	log *zap.SugaredLogger
+}
+
+func (l serverLogger) Noticef(format string, v ...any) { l.log.Infof(format, v...) }
+func (l serverLogger) Warnf(format string, v ...any)   { l.log.Warnf(format, v...) }
+func (l serverLogger) Fatalf(format string, v ...any)  { l.log.Errorf(format, v...) }
+func (l serverLogger) Errorf(format string, v ...any)  { l.log.Errorf(format, v...) }
+func (l serverLogger) Debugf(format string, v ...any)  { l.log.Debugf(format, v...) }
+func (l serverLogger) Tracef(format string, v ...any)  { l.log.Debugf(format, v...) }
@@ -0,0 +1,69 @@
+package nats
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestMessenger_Embedded(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{
+		Embedded:          true,
+		EmbeddedServer:    EmbeddedServerConfig{Port: -1, JetStream: true, StoreDir: dir},
+		Token:             "s3cret",
+		ConnectionTimeout: time.Second,
+		ReconnectWait:     10 * time.Millisecond,
+		MaxReconnects:     -1,
+	}
+	m := &Messenger{}
+	require.NoError(t, m.Init(cfg, zap.NewNop(), "test-app"))
+	require.NotNil(t, m.EmbeddedServer())
+	url := m.EmbeddedServer().ClientURL()
+	assert.Equal(t, url, m.Client.Conn().ConnectedUrl())
+
+	// JetStream stores its streams in the data dir
+	js, err := m.Client.JetStream()
+	require.NoError(t, err)
+	info, err := js.AddStream(&nats.StreamConfig{Name: "ORDERS", Subjects: []string{"orders.>"}})
+	require.NoError(t, err)
+	assert.Equal(t, nats.FileStorage, info.Config.Storage)
+	assert.DirExists(t, dir+"/jetstream")
+
+	received := make(chan string, 1)
+	require.NoError(t, m.Subscriber.Subscribe("greet", func(ctx context.Context, subject string, env *MessageEnvelope) error {
+		received <- env.Type
+		return nil
+	}, nil))
+	require.NoError(t, m.Publisher.Publish(context.Background(), "greet", "Hello", "world", nil))
+	select {
+	case msgType := <-received:
+		assert.Equal(t, "Hello", msgType)
+	case <-time.After(2 * time.Second):
+		t.Fatal("message not received")
+	}
+
+	// The server requires the configured token, rotated with the client's
+	_, err = nats.Connect(url)
+	assert.Error(t, err, "connections without the token are rejected")
+	cfg.Token = "rotated"
+	require.NoError(t, m.ApplyConfig(cfg))
+	_, err = nats.Connect(url, nats.Token("s3cret"))
+	assert.Error(t, err, "the old token is rejected")
+	assert.Eventually(t, m.IsConnected, 2*time.Second, 10*time.Millisecond)
+
+	// Closing the messenger stops the server
+	require.NoError(t, m.Close())
+	_, err = nats.Connect(url, nats.Token("rotated"))
+	assert.Error(t, err)
+}
+
+func TestStartEmbeddedServer_RequiresLogger(t *testing.T) {
+	_, err := StartEmbeddedServer(EmbeddedServerConfig{Port: -1}, Config{}, nil)
+	assert.Error(t, err)
+}
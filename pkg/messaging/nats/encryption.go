@@ -0,0 +1,223 @@
+package nats
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// MetadataEncryptionKeyID names the key the envelope data is encrypted with
+const MetadataEncryptionKeyID = "encryption_key_id"
+
+// Encryption errors
+var (
+	ErrUnknownEncryptionKey = errors.New("unknown encryption key")
+	ErrNotEncrypted         = errors.New("envelope data is not encrypted")
+)
+
+// EncryptionConfig configures the encryption of the envelope data
+type EncryptionConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// KeyID selects the key used to encrypt. Leave empty for services that
+	// only decrypt; they publish plaintext.
+	KeyID string `mapstructure:"key_id"`
+	// Keys are the known keys, used for encryption (KeyID) and decryption
+	Keys []EncryptionKey `mapstructure:"keys"`
+	// Subjects limits encryption to these subjects (NATS wildcards allowed).
+	// Empty encrypts every subject. Plaintext envelopes on them are rejected.
+	Subjects []string `mapstructure:"subjects"`
+}
+
+// EncryptionKey is a named base64 encoded AES key of 16, 24 or 32 bytes
+type EncryptionKey struct {
+	ID  string `mapstructure:"id"`
+	Key string `mapstructure:"key"`
+}
+
+// Codec transforms the data of envelopes on their way to and from NATS
+type Codec interface {
+	Encode(subject string, env *MessageEnvelope) error
+	Decode(subject string, env *MessageEnvelope) error
+}
+
+// KeyProvider supplies the keys of an EncryptingCodec
+type KeyProvider interface {
+	// CurrentKey returns the ID and key used to encrypt, or an empty ID to
+	// leave the data in plaintext
+	CurrentKey() (string, []byte, error)
+	// Key returns the key with the ID, to decrypt
+	Key(id string) ([]byte, error)
+}
+
+// KeyRing is a KeyProvider holding the configured keys. Update replaces them
+// while running, so keys rotate without a restart.
+type KeyRing struct {
+	mu    sync.RWMutex
+	keyID string
+	keys  map[string][]byte
+}
+
+// NewKeyRing creates a KeyRing from the configured keys
+func NewKeyRing(cfg EncryptionConfig) (*KeyRing, error) {
+	r := &KeyRing{}
+	if err := r.Update(cfg); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Update replaces the keys and the current key. On error, the keys are left
+// unchanged.
+func (r *KeyRing) Update(cfg EncryptionConfig) error {
+	keys := make(map[string][]byte, len(cfg.Keys))
+	for _, k := range cfg.Keys {
+		if k.ID == "" {
+			return fmt.Errorf("encryption key without id")
+		}
+		raw, err := base64.StdEncoding.DecodeString(k.Key)
+		if err != nil {
+			return fmt.Errorf("invalid encryption key %q: %w", k.ID, err)
+		}
+		if _, err := aes.NewCipher(raw); err != nil {
+			return fmt.Errorf("invalid encryption key %q: %w", k.ID, err)
+		}
+		keys[k.ID] = raw
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("encryption enabled but no keys configured")
+	}
+	if _, ok := keys[cfg.KeyID]; cfg.KeyID != "" && !ok {
+		return fmt.Errorf("encryption key %q not found", cfg.KeyID)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keyID = cfg.KeyID
+	r.keys = keys
+	return nil
+}
+
+func (r *KeyRing) CurrentKey() (string, []byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.keyID, r.keys[r.keyID], nil
+}
+
+func (r *KeyRing) Key(id string) ([]byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	key, ok := r.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownEncryptionKey, id)
+	}
+	return key, nil
+}
+
+// EncryptingCodec encrypts the data of envelopes with AES-GCM. The key ID
+// travels in the metadata, which stays readable for routing; the ID and type
+// of the envelope are authenticated with the data, so ciphertexts cannot be
+// moved to another envelope.
+type EncryptingCodec struct {
+	keys     KeyProvider
+	subjects []string
+}
+
+// NewEncryptingCodec creates a codec encrypting the data on subjects matching
+// one of the patterns (all subjects when empty)
+func NewEncryptingCodec(keys KeyProvider, subjects []string) *EncryptingCodec {
+	return &EncryptingCodec{keys: keys, subjects: subjects}
+}
+
+func (c *EncryptingCodec) covers(subject string) bool {
+	return len(c.subjects) == 0 || matchAnySubject(c.subjects, subject)
+}
+
+// Encode encrypts the data of env when subject is covered
+func (c *EncryptingCodec) Encode(subject string, env *MessageEnvelope) error {
+	if !c.covers(subject) {
+		return nil
+	}
+	keyID, key, err := c.keys.CurrentKey()
+	if err != nil {
+		return fmt.Errorf("failed to encrypt data: %w", err)
+	}
+	if keyID == "" {
+		return nil
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt data: %w", err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to encrypt data: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, env.Data, encryptionAAD(env, keyID))
+	encoded, err := json.Marshal(sealed)
+	if err != nil {
+		return fmt.Errorf("failed to encode encrypted data: %w", err)
+	}
+
+	env.Data = encoded
+	if env.Metadata == nil {
+		env.Metadata = make(map[string]string)
+	}
+	env.Metadata[MetadataEncryptionKeyID] = keyID
+	return nil
+}
+
+// Decode decrypts the data of env. Plaintext envelopes are rejected on the
+// subjects the codec covers, and passed through on the others and on
+// responses, which are decoded without a subject.
+func (c *EncryptingCodec) Decode(subject string, env *MessageEnvelope) error {
+	keyID := env.Metadata[MetadataEncryptionKeyID]
+	if keyID == "" {
+		if subject != "" && c.covers(subject) {
+			return ErrNotEncrypted
+		}
+		return nil
+	}
+	key, err := c.keys.Key(keyID)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt data: %w", err)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt data: %w", err)
+	}
+	var sealed []byte
+	if err := json.Unmarshal(env.Data, &sealed); err != nil {
+		return fmt.Errorf("failed to decode encrypted data: %w", err)
+	}
+	if len(sealed) < aead.NonceSize() {
+		return fmt.Errorf("failed to decrypt data: ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	data, err := aead.Open(nil, nonce, ciphertext, encryptionAAD(env, keyID))
+	if err != nil {
+		return fmt.Errorf("failed to decrypt data: %w", err)
+	}
+
+	env.Data = data
+	// The envelope is signed before it is encrypted
+	delete(env.Metadata, MetadataEncryptionKeyID)
+	return nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptionAAD binds the ciphertext to its envelope and key
+func encryptionAAD(env *MessageEnvelope, keyID string) []byte {
+	return []byte(env.ID + "\n" + env.Type + "\n" + keyID)
+}
@@ -0,0 +1,96 @@
+package nats
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testEncryptionKey(b byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(b), 32)))
+}
+
+func TestNewKeyRing_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  EncryptionConfig
+	}{
+		{"no keys", EncryptionConfig{}},
+		{"missing id", EncryptionConfig{Keys: []EncryptionKey{{Key: testEncryptionKey('a')}}}},
+		{"not base64", EncryptionConfig{Keys: []EncryptionKey{{ID: "a", Key: "%%"}}}},
+		{"bad size", EncryptionConfig{Keys: []EncryptionKey{{ID: "a", Key: "c2hvcnQ="}}}},
+		{"unknown key id", EncryptionConfig{KeyID: "b", Keys: []EncryptionKey{{ID: "a", Key: testEncryptionKey('a')}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewKeyRing(tt.cfg)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestEncryptingCodec(t *testing.T) {
+	keys, err := NewKeyRing(EncryptionConfig{KeyID: "pii", Keys: []EncryptionKey{{ID: "pii", Key: testEncryptionKey('a')}}})
+	require.NoError(t, err)
+	codec := NewEncryptingCodec(keys, []string{"users.>"})
+
+	data := json.RawMessage(`{"email":"jane@example.com"}`)
+	env := &MessageEnvelope{ID: "1", Type: "UserCreated", Data: data}
+	require.NoError(t, codec.Encode("users.created", env))
+	assert.Equal(t, "pii", env.Metadata[MetadataEncryptionKeyID])
+	assert.NotContains(t, string(env.Data), "jane@example.com")
+	sealed := env.Data
+
+	require.NoError(t, codec.Decode("users.created", env))
+	assert.JSONEq(t, string(data), string(env.Data))
+	assert.NotContains(t, env.Metadata, MetadataEncryptionKeyID)
+
+	// The ciphertext is bound to its envelope
+	moved := &MessageEnvelope{ID: "2", Type: "UserCreated", Data: sealed, Metadata: map[string]string{MetadataEncryptionKeyID: "pii"}}
+	assert.Error(t, codec.Decode("users.created", moved))
+
+	// Subjects outside the patterns stay in plaintext
+	other := &MessageEnvelope{ID: "3", Data: data}
+	require.NoError(t, codec.Encode("orders.created", other))
+	assert.Equal(t, string(data), string(other.Data))
+	assert.NoError(t, codec.Decode("orders.created", other))
+
+	// Plaintext is rejected where encryption is expected, except on responses
+	assert.ErrorIs(t, codec.Decode("users.created", &MessageEnvelope{Data: data}), ErrNotEncrypted)
+	assert.NoError(t, codec.Decode("", &MessageEnvelope{Data: data}))
+
+	unknown := &MessageEnvelope{ID: "4", Data: sealed, Metadata: map[string]string{MetadataEncryptionKeyID: "old"}}
+	assert.ErrorIs(t, codec.Decode("users.created", unknown), ErrUnknownEncryptionKey)
+}
+
+func TestEncryptingCodec_Rotation(t *testing.T) {
+	v1 := EncryptionKey{ID: "v1", Key: testEncryptionKey('a')}
+	v2 := EncryptionKey{ID: "v2", Key: testEncryptionKey('b')}
+	keys, err := NewKeyRing(EncryptionConfig{KeyID: "v1", Keys: []EncryptionKey{v1}})
+	require.NoError(t, err)
+	codec := NewEncryptingCodec(keys, nil)
+
+	inFlight := &MessageEnvelope{ID: "1", Data: json.RawMessage(`"v1 data"`)}
+	require.NoError(t, codec.Encode("jobs", inFlight))
+
+	// The new key is added and selected; the old one still decrypts
+	require.NoError(t, keys.Update(EncryptionConfig{KeyID: "v2", Keys: []EncryptionKey{v1, v2}}))
+	rotated := &MessageEnvelope{ID: "2", Data: json.RawMessage(`"v2 data"`)}
+	require.NoError(t, codec.Encode("jobs", rotated))
+	assert.Equal(t, "v2", rotated.Metadata[MetadataEncryptionKeyID])
+
+	require.NoError(t, codec.Decode("jobs", inFlight))
+	assert.Equal(t, `"v1 data"`, string(inFlight.Data))
+	require.NoError(t, codec.Decode("jobs", rotated))
+	assert.Equal(t, `"v2 data"`, string(rotated.Data))
+
+	// An invalid update keeps the current keys
+	assert.Error(t, keys.Update(EncryptionConfig{KeyID: "v3", Keys: []EncryptionKey{v1, v2}}))
+	id, _, err := keys.CurrentKey()
+	require.NoError(t, err)
+	assert.Equal(t, "v2", id)
+}
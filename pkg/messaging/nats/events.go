@@ -0,0 +1,129 @@
+package nats
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+// ConnectionEventType is the kind of a ConnectionEvent
+type ConnectionEventType string
+
+// Connection event types
+const (
+	// EventConnected is the first connection of the client
+	EventConnected ConnectionEventType = "connected"
+	// EventDisconnected is the loss of the server; Err holds the cause, if
+	// any. The client reconnects unless it is closed.
+	EventDisconnected ConnectionEventType = "disconnected"
+	// EventReconnected is a connection after a disconnection
+	EventReconnected ConnectionEventType = "reconnected"
+	// EventClosed is the end of the connection, for good
+	EventClosed ConnectionEventType = "closed"
+	// EventError is an asynchronous error of the connection or of the
+	// subscription on Subject
+	EventError ConnectionEventType = "error"
+	// EventSlowConsumer is the drop of messages by the subscription on
+	// Subject, which does not keep up with them
+	EventSlowConsumer ConnectionEventType = "slow_consumer"
+	// EventServersDiscovered is the addition of cluster servers to the pool
+	EventServersDiscovered ConnectionEventType = "servers_discovered"
+	// EventLameDuck is the notice of the server connected to that it shuts
+	// down soon; the client moves to another server
+	EventLameDuck ConnectionEventType = "lame_duck"
+)
+
+// ConnectionEvent is a change of the connection of a client
+type ConnectionEvent struct {
+	Type ConnectionEventType
+	// Server is the URL of the server concerned, passwords redacted
+	Server  string
+	Subject string
+	Err     error
+	Time    time.Time
+}
+
+// ConnectionEventHandler receives the connection events of a client. Handlers
+// run one at a time, in the order of the events, and must not block.
+type ConnectionEventHandler func(ConnectionEvent)
+
+// connectionEvents is the registry of the connection event handlers
+type connectionEvents struct {
+	mu       sync.RWMutex
+	next     int
+	handlers map[int]ConnectionEventHandler
+}
+
+func (e *connectionEvents) add(h ConnectionEventHandler) func() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.handlers == nil {
+		e.handlers = make(map[int]ConnectionEventHandler)
+	}
+	id := e.next
+	e.next++
+	e.handlers[id] = h
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			e.mu.Lock()
+			defer e.mu.Unlock()
+			delete(e.handlers, id)
+		})
+	}
+}
+
+func (e *connectionEvents) emit(ev ConnectionEvent) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for _, h := range e.handlers {
+		h(ev)
+	}
+}
+
+// OnConnectionEvent registers h for the connection events of the client,
+// until the returned function is called. Register before Connect to receive
+// EventConnected; IsConnected gives the state at registration.
+func (c *Client) OnConnectionEvent(h ConnectionEventHandler) (unregister func()) {
+	return c.events.add(h)
+}
+
+// ConnectionEvents returns a channel receiving the connection events of the
+// client, up to buffer events behind the reader; later events are dropped
+// until it catches up. stop unregisters the channel, which is not closed.
+func (c *Client) ConnectionEvents(buffer int) (events <-chan ConnectionEvent, stop func()) {
+	ch := make(chan ConnectionEvent, buffer)
+	stop = c.OnConnectionEvent(func(ev ConnectionEvent) {
+		select {
+		case ch <- ev:
+		default:
+			c.logger.Warn("Connection event dropped, reader behind", zap.String("type", string(ev.Type)))
+		}
+	})
+	return ch, stop
+}
+
+// emit hands an event of type t to the handlers
+func (c *Client) emit(t ConnectionEventType, server string, err error) {
+	c.events.emit(ConnectionEvent{Type: t, Server: server, Err: err, Time: time.Now()})
+}
+
+// onAsyncError reports the asynchronous errors of the connection, slow
+// consumers on their own
+func (c *Client) onAsyncError(nc *nats.Conn, sub *nats.Subscription, err error) {
+	ev := ConnectionEvent{Type: EventError, Server: nc.ConnectedUrlRedacted(), Err: err, Time: time.Now()}
+	if sub != nil {
+		ev.Subject = sub.Subject
+	}
+	if errors.Is(err, nats.ErrSlowConsumer) {
+		// The policy of the subscription logs it
+		ev.Type = EventSlowConsumer
+		c.slowConsumer(sub)
+	} else {
+		c.logger.Error("NATS async error", zap.String("subject", ev.Subject), zap.Error(err))
+	}
+	c.events.emit(ev)
+}
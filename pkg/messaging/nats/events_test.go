@@ -0,0 +1,80 @@
+package nats
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// nextEvent returns the next event of events of type t, skipping the others
+func nextEvent(t *testing.T, events <-chan ConnectionEvent, typ ConnectionEventType) ConnectionEvent {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case ev := <-events:
+			if ev.Type == typ {
+				return ev
+			}
+		case <-timeout:
+			t.Fatalf("no %s event", typ)
+		}
+	}
+}
+
+func TestClient_ConnectionEvents(t *testing.T) {
+	s := runServer(t, &server.Options{})
+	url := s.ClientURL()
+
+	received := make(chan ConnectionEvent, 10)
+	client, err := NewNATSClient(Config{
+		URL:               url,
+		MaxReconnects:     -1,
+		ReconnectWait:     10 * time.Millisecond,
+		ConnectionTimeout: time.Second,
+		OnConnectionEvent: func(ev ConnectionEvent) { received <- ev },
+	}, zap.NewNop())
+	require.NoError(t, err)
+	events, stop := client.ConnectionEvents(10)
+	require.NoError(t, client.Connect())
+
+	ev := nextEvent(t, received, EventConnected)
+	assert.Equal(t, url, ev.Server)
+	assert.False(t, ev.Time.IsZero())
+	nextEvent(t, events, EventConnected)
+
+	// The subscription drops the messages beyond its pending limit
+	sub, err := client.Conn().SubscribeSync("test.slow")
+	require.NoError(t, err)
+	require.NoError(t, sub.SetPendingLimits(1, -1))
+	for range 10 {
+		require.NoError(t, client.Conn().Publish("test.slow", []byte("x")))
+	}
+	require.NoError(t, client.Conn().Flush())
+	ev = nextEvent(t, events, EventSlowConsumer)
+	assert.Equal(t, "test.slow", ev.Subject)
+	assert.Error(t, ev.Err)
+
+	// The client reconnects to the restarted server
+	port := s.Addr().(*net.TCPAddr).Port
+	s.Shutdown()
+	ev = nextEvent(t, events, EventDisconnected)
+	assert.Equal(t, url, ev.Server)
+	restarted := runServer(t, &server.Options{Port: port})
+	ev = nextEvent(t, events, EventReconnected)
+	assert.Equal(t, restarted.ClientURL(), ev.Server)
+
+	stop()
+	require.NoError(t, client.Close())
+	nextEvent(t, received, EventClosed)
+	select {
+	case ev := <-events:
+		t.Fatalf("event %s received after stop", ev.Type)
+	default:
+	}
+}
@@ -0,0 +1,149 @@
+package nats
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// MetadataPayloadRef names the stored envelope a reference envelope stands for
+const MetadataPayloadRef = "payload_ref"
+
+// ErrPayloadTooLarge is returned for envelopes above LargePayloadConfig.MaxSize
+var ErrPayloadTooLarge = errors.New("payload too large")
+
+// LargePayloadConfig configures the spillover of envelopes above the server's
+// max payload to a JetStream object store
+type LargePayloadConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Bucket is the object store, created when missing
+	Bucket string `mapstructure:"bucket"`
+	// TTL removes stored envelopes once every subscriber had time to fetch them
+	TTL time.Duration `mapstructure:"ttl"`
+	// MaxSize bounds the stored envelopes, on publish and on fetch. Zero is
+	// unbounded.
+	MaxSize int64 `mapstructure:"max_size"`
+	// Timeout bounds the fetch of a stored envelope, 10s when zero
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// PayloadStore keeps the envelopes too large for a NATS message. Get bounds
+// the fetch itself: the subscribers call it without a deadline.
+type PayloadStore interface {
+	Put(ctx context.Context, name string, data []byte) error
+	Get(ctx context.Context, name string) ([]byte, error)
+}
+
+// objectPayloadStore is a PayloadStore on a JetStream object store
+type objectPayloadStore struct {
+	store   nats.ObjectStore
+	maxSize int64
+	timeout time.Duration
+	// chunkSize keeps the chunks of the objects within the max payload
+	chunkSize uint32
+}
+
+// maxChunkSize is the default chunk size of the object store
+const maxChunkSize = 128 * 1024
+
+// NewObjectPayloadStore opens the object store of cfg, creating it when missing
+func NewObjectPayloadStore(client *Client, cfg LargePayloadConfig) (PayloadStore, error) {
+	js, err := client.JetStream()
+	if err != nil {
+		return nil, err
+	}
+	store, err := js.ObjectStore(cfg.Bucket)
+	if errors.Is(err, nats.ErrStreamNotFound) || errors.Is(err, nats.ErrBucketNotFound) {
+		store, err = js.CreateObjectStore(&nats.ObjectStoreConfig{
+			Bucket:      cfg.Bucket,
+			Description: "Envelopes above the NATS max payload",
+			TTL:         cfg.TTL,
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open object store %q: %w", cfg.Bucket, err)
+	}
+	chunkSize := uint32(min(client.Conn().MaxPayload()/2, maxChunkSize))
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultPayloadTimeout
+	}
+	return &objectPayloadStore{store: store, maxSize: cfg.MaxSize, timeout: timeout, chunkSize: chunkSize}, nil
+}
+
+func (s *objectPayloadStore) Put(ctx context.Context, name string, data []byte) error {
+	if s.maxSize > 0 && int64(len(data)) > s.maxSize {
+		return fmt.Errorf("%w: %d bytes, max %d", ErrPayloadTooLarge, len(data), s.maxSize)
+	}
+	meta := &nats.ObjectMeta{Name: name, Opts: &nats.ObjectMetaOptions{ChunkSize: s.chunkSize}}
+	if _, err := s.store.Put(meta, bytes.NewReader(data), nats.Context(ctx)); err != nil {
+		return fmt.Errorf("failed to store payload %s: %w", name, err)
+	}
+	return nil
+}
+
+func (s *objectPayloadStore) Get(ctx context.Context, name string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	info, err := s.store.GetInfo(name, nats.Context(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payload %s: %w", name, err)
+	}
+	// Checked before reading, so an oversized object is never held in memory
+	if s.maxSize > 0 && int64(info.Size) > s.maxSize {
+		return nil, fmt.Errorf("%w: %d bytes, max %d", ErrPayloadTooLarge, info.Size, s.maxSize)
+	}
+	data, err := s.store.GetBytes(name, nats.Context(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payload %s: %w", name, err)
+	}
+	return data, nil
+}
+
+// spill stores data, the marshaled envelope, and returns a reference envelope
+// to publish instead: the envelope without its data, naming the stored one
+func spill(ctx context.Context, store PayloadStore, env *MessageEnvelope, data []byte) ([]byte, error) {
+	if err := store.Put(ctx, env.ID, data); err != nil {
+		return nil, err
+	}
+	ref := *env
+	ref.Data = nil
+	ref.Metadata = make(map[string]string, len(env.Metadata)+1)
+	for k, v := range env.Metadata {
+		ref.Metadata[k] = v
+	}
+	ref.Metadata[MetadataPayloadRef] = env.ID
+	refBytes, err := json.Marshal(ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal reference envelope: %w", err)
+	}
+	return refBytes, nil
+}
+
+// defaultPayloadTimeout bounds the fetch of a stored envelope when
+// LargePayloadConfig.Timeout is zero
+const defaultPayloadTimeout = 10 * time.Second
+
+// unspill replaces a reference envelope with the stored one, fetching it
+// within the timeout of the store
+func unspill(ctx context.Context, store PayloadStore, env *MessageEnvelope) error {
+	name := env.Metadata[MetadataPayloadRef]
+	if store == nil {
+		return fmt.Errorf("no payload store to fetch %s", name)
+	}
+	data, err := store.Get(ctx, name)
+	if err != nil {
+		return err
+	}
+	var stored MessageEnvelope
+	if err := unmarshalEnvelope(data, &stored); err != nil {
+		return fmt.Errorf("failed to unmarshal stored envelope %s: %w", name, err)
+	}
+	*env = stored
+	return nil
+}
@@ -0,0 +1,129 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// runLargePayloadServer runs a JetStream server with a 1KB max payload
+func runLargePayloadServer(t *testing.T) *Client {
+	t.Helper()
+	s, err := server.NewServer(&server.Options{
+		Host: "127.0.0.1", Port: -1, NoLog: true, NoSigs: true,
+		MaxPayload: 1024, JetStream: true, StoreDir: t.TempDir(),
+	})
+	require.NoError(t, err)
+	go s.Start()
+	require.True(t, s.ReadyForConnections(5*time.Second))
+	t.Cleanup(s.Shutdown)
+
+	client, err := NewNATSClient(Config{URL: s.ClientURL(), ConnectionTimeout: time.Second}, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, client.Connect())
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func TestLargePayloads(t *testing.T) {
+	client := runLargePayloadServer(t)
+	store, err := NewObjectPayloadStore(client, LargePayloadConfig{Bucket: "payloads", TTL: time.Minute, MaxSize: 64 << 10})
+	require.NoError(t, err)
+
+	publisher := NewPublisher(client, "test-service")
+	publisher.SetPayloadStore(store)
+	subscriber := NewSubscriber(client, "test-subscriber")
+	subscriber.SetPayloadStore(store)
+
+	received := make(chan *MessageEnvelope, 2)
+	require.NoError(t, subscriber.Subscribe("test.large", func(ctx context.Context, subject string, msg *MessageEnvelope) error {
+		received <- msg
+		return nil
+	}, nil))
+	wire := make(chan *nats.Msg, 2)
+	raw, err := client.Conn().ChanSubscribe("test.large", wire)
+	require.NoError(t, err)
+	defer raw.Unsubscribe()
+
+	ctx := context.Background()
+	large := strings.Repeat("x", 10<<10)
+	require.NoError(t, publisher.Publish(ctx, "test.large", "report", large, nil))
+	require.NoError(t, publisher.Publish(ctx, "test.large", "small", "ok", nil))
+
+	for _, want := range []struct{ msgType, data string }{{"report", large}, {"small", "ok"}} {
+		select {
+		case msg := <-received:
+			assert.Equal(t, want.msgType, msg.Type)
+			var data string
+			require.NoError(t, json.Unmarshal(msg.Data, &data))
+			assert.Equal(t, want.data, data)
+			assert.Empty(t, msg.Metadata[MetadataPayloadRef], "handlers get the stored envelope")
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%s message not received", want.msgType)
+		}
+	}
+
+	ref := <-wire
+	assert.LessOrEqual(t, int64(len(ref.Data)), client.Conn().MaxPayload())
+	var env MessageEnvelope
+	require.NoError(t, json.Unmarshal(ref.Data, &env))
+	assert.Equal(t, env.ID, env.Metadata[MetadataPayloadRef], "the large envelope is published as a reference")
+	small := <-wire
+	assert.NotContains(t, string(small.Data), MetadataPayloadRef, "small envelopes are published as is")
+
+	err = publisher.Publish(ctx, "test.large", "huge", strings.Repeat("x", 128<<10), nil)
+	assert.ErrorIs(t, err, ErrPayloadTooLarge)
+}
+
+func TestLargePayloads_NoStore(t *testing.T) {
+	client := runLargePayloadServer(t)
+	publisher := NewPublisher(client, "test-service")
+
+	err := publisher.Publish(context.Background(), "test.large", "report", strings.Repeat("x", 10<<10), nil)
+	assert.ErrorIs(t, err, nats.ErrMaxPayload, "without a store, large envelopes are rejected by NATS")
+
+	// A reference cannot be resolved without a store
+	var env MessageEnvelope
+	err = decodeEnvelope([]byte(`{"id":"1","metadata":{"payload_ref":"1"}}`), "test.large", nil, nil, &env)
+	assert.ErrorContains(t, err, "no payload store")
+}
+
+func TestObjectPayloadStore_Timeout(t *testing.T) {
+	client := runLargePayloadServer(t)
+	store, err := NewObjectPayloadStore(client, LargePayloadConfig{Bucket: "payloads"})
+	require.NoError(t, err)
+	assert.Equal(t, defaultPayloadTimeout, store.(*objectPayloadStore).timeout, "fetches are bounded by default")
+
+	store, err = NewObjectPayloadStore(client, LargePayloadConfig{Bucket: "payloads", Timeout: time.Second})
+	require.NoError(t, err)
+	assert.Equal(t, time.Second, store.(*objectPayloadStore).timeout)
+}
+
+func TestObjectPayloadStore_MaxSize(t *testing.T) {
+	client := runLargePayloadServer(t)
+	store, err := NewObjectPayloadStore(client, LargePayloadConfig{Bucket: "payloads", TTL: time.Minute})
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, store.Put(ctx, "big", make([]byte, 4<<10)))
+
+	// A second store on the bucket, with a lower limit, refuses to fetch it
+	limited, err := NewObjectPayloadStore(client, LargePayloadConfig{Bucket: "payloads", MaxSize: 1 << 10, Timeout: time.Second})
+	require.NoError(t, err)
+	_, err = limited.Get(ctx, "big")
+	assert.ErrorIs(t, err, ErrPayloadTooLarge)
+
+	data, err := store.Get(ctx, "big")
+	require.NoError(t, err)
+	assert.Len(t, data, 4<<10)
+
+	_, err = store.Get(ctx, "missing")
+	assert.Error(t, err)
+}
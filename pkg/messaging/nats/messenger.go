@@ -3,6 +3,8 @@ package nats
 import (
 	"fmt"
 
+	"grouter/pkg/loadshed"
+
 	"go.opentelemetry.io/otel"
 	"go.uber.org/zap"
 )
@@ -12,10 +14,23 @@ type Messenger struct {
 	Client     *Client
 	Publisher  Publisher
 	Subscriber Subscriber
+	// keys are the encryption keys, nil without encryption
+	keys *KeyRing
+	// codec and payloads decode the envelopes read by replays
+	codec    Codec
+	payloads PayloadStore
+	// quarantine keeps the poison messages, nil when disabled
+	quarantine *Quarantine
+	// responseCache caches the replies to requests, nil when disabled
+	responseCache *ResponseCache
+	// monitor polls the streams and consumers, nil when disabled
+	monitor *StreamMonitor
+	// embedded is the server run in the process, nil when external
+	embedded *EmbeddedServer
 }
 
 func (m *Messenger) IsConnected() bool {
-	return m.Client.IsConnected()
+	return m.Client != nil && m.Client.IsConnected()
 }
 
 // NewMessenger creates a new Messenger.
@@ -28,7 +43,25 @@ func NewMessenger(client *Client, pub Publisher, sub Subscriber) *Messenger {
 }
 
 // Init initializes the Messenger with configuration, connecting to NATS and setting up pub/sub.
-func (m *Messenger) Init(cfg Config, logger *zap.Logger, source string) error {
+func (m *Messenger) Init(cfg Config, logger *zap.Logger, source string) (err error) {
+	if cfg.Embedded {
+		embedded, err := StartEmbeddedServer(cfg.EmbeddedServer, cfg, logger)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err != nil {
+				embedded.Shutdown()
+				m.embedded = nil
+			}
+		}()
+		m.embedded = embedded
+		cfg.URL = embedded.ClientURL()
+		cfg.URLs = nil
+		// The only client of the credentials reconnects at once
+		cfg.AuthRotation.Jitter = 0
+	}
+
 	client, err := NewNATSClient(cfg, logger)
 	if err != nil {
 		return fmt.Errorf("failed to create NATS client: %w", err)
@@ -42,24 +75,92 @@ func (m *Messenger) Init(cfg Config, logger *zap.Logger, source string) error {
 	m.Client = client
 	m.Publisher = NewPublisher(client, source)
 	m.Subscriber = NewSubscriber(client, source)
+	if cfg.SkipFlush {
+		m.Publisher.SetSkipFlush(true)
+	}
+
+	// Compress the data of large envelopes; subscribers always decompress
+	if cfg.Compression.Enabled {
+		compressor, err := NewCompressor(cfg.Compression, cfg.Metrics.Registry)
+		if err != nil {
+			_ = client.Close()
+			return fmt.Errorf("failed to init compression: %w", err)
+		}
+		m.Publisher.SetCompressor(compressor)
+		logger.Info("Envelope compression enabled for NATS",
+			zap.String("algorithm", compressor.algorithm),
+			zap.Int("threshold", cfg.Compression.Threshold),
+		)
+	}
+
+	// Encrypt the data on the configured subjects, after compression
+	if cfg.Encryption.Enabled {
+		keys, err := NewKeyRing(cfg.Encryption)
+		if err != nil {
+			_ = client.Close()
+			return fmt.Errorf("failed to init encryption: %w", err)
+		}
+		codec := NewEncryptingCodec(keys, cfg.Encryption.Subjects)
+		m.Publisher.SetCodec(codec)
+		m.Subscriber.SetCodec(codec)
+		m.keys = keys
+		m.codec = codec
+		logger.Info("Envelope encryption enabled for NATS",
+			zap.String("key_id", cfg.Encryption.KeyID),
+			zap.Strings("subjects", cfg.Encryption.Subjects),
+		)
+	}
+
+	// Spill envelopes above the max payload to the object store
+	if cfg.LargePayloads.Enabled {
+		store, err := NewObjectPayloadStore(client, cfg.LargePayloads)
+		if err != nil {
+			_ = client.Close()
+			return fmt.Errorf("failed to init large payloads: %w", err)
+		}
+		m.Publisher.SetPayloadStore(store)
+		m.Subscriber.SetPayloadStore(store)
+		m.payloads = store
+		logger.Info("Large payload spillover enabled for NATS",
+			zap.String("bucket", cfg.LargePayloads.Bucket),
+			zap.Int64("max_payload", client.Conn().MaxPayload()),
+		)
+	}
+
+	// Drop expired messages before any other middleware sees them,
+	m.Subscriber.Use(ExpiryMiddleware(logger, cfg.Metrics.Registry))
+	// and requests no reply can meet anymore
+	m.Subscriber.Use(DeadlineMiddleware(logger, cfg.Metrics.Registry))
+
+	// Shed load before the other middleware runs
+	if cfg.LoadShedding.Enabled {
+		shedder := loadshed.New(cfg.LoadShedding, loadshed.WithQueueDepth(m.Subscriber.QueueDepth))
+		m.Subscriber.Use(LoadSheddingMiddleware(shedder, cfg.Metrics.Registry))
+		logger.Info("Load shedding enabled for NATS",
+			zap.Int("max_in_flight", cfg.LoadShedding.MaxInFlight),
+			zap.Int("max_queue_depth", cfg.LoadShedding.MaxQueueDepth),
+			zap.Float64("max_cpu", cfg.LoadShedding.MaxCPU),
+			zap.Int64("max_memory", cfg.LoadShedding.MaxMemory),
+		)
+	}
 
 	// Enable metrics middleware if configured
 	if cfg.Metrics.Enabled {
-		m.Publisher.Use(PublisherMetricsMiddleware())
-		m.Publisher.UseRequest(RequestMetricsMiddleware())
-		m.Subscriber.Use(MetricsMiddleware())
+		var opts []MetricsOption
+		if cfg.Metrics.TenantLabel {
+			opts = append(opts, WithTenantLabel())
+		}
+		if cfg.Metrics.Cardinality.enabled() {
+			opts = append(opts, WithCardinality(cfg.Metrics.Cardinality))
+		}
+		m.Publisher.Use(PublisherMetricsMiddleware(cfg.Metrics.Registry, opts...))
+		m.Publisher.UseRequest(RequestMetricsMiddleware(cfg.Metrics.Registry, opts...))
+		m.Subscriber.Use(MetricsMiddleware(cfg.Metrics.Registry, opts...))
+		m.Subscriber.SetPoolMetrics(cfg.Metrics.Registry)
 		logger.Info("Metrics middleware enabled for NATS")
 	}
 
-	// Enable Logging Middleware
-	if cfg.Logging.Enabled {
-		m.Publisher.Use(PublisherLoggingMiddleware(logger))
-		m.Publisher.UseRequest(RequestLoggingMiddleware(logger))
-		m.Subscriber.Use(LoggingMiddleware(logger))
-		logger.Info("Logging middleware enabled for NATS")
-	}
-
-	// Enable Tracing Middleware
+	// Enable Tracing Middleware (before logging, so log lines carry the span IDs)
 	if cfg.Tracing.Enabled {
 		tracer := otel.Tracer("nats")
 		m.Publisher.Use(PublisherTracingMiddleware(tracer))
@@ -68,16 +169,173 @@ func (m *Messenger) Init(cfg Config, logger *zap.Logger, source string) error {
 		logger.Info("Tracing middleware enabled for NATS")
 	}
 
+	// Enable Logging Middleware
+	if cfg.Logging.Enabled {
+		var opts []LoggingOption
+		if cfg.Logging.Sampling.Enabled {
+			opts = append(opts, WithLogSampling(cfg.Logging.Sampling))
+		}
+		m.Publisher.Use(PublisherLoggingMiddleware(logger, opts...))
+		m.Publisher.UseRequest(RequestLoggingMiddleware(logger, opts...))
+		m.Subscriber.Use(LoggingMiddleware(logger, opts...))
+		logger.Info("Logging middleware enabled for NATS",
+			zap.Bool("sampling", cfg.Logging.Sampling.Enabled),
+		)
+	}
+
+	// Injected faults count against the objectives
+	if cfg.SLO != nil {
+		m.Subscriber.Use(SLOMiddleware(cfg.SLO))
+		logger.Info("SLO middleware enabled for NATS")
+	}
+
+	// Injected faults show in the message logs, metrics and traces
+	if cfg.Chaos != nil {
+		m.Subscriber.Use(ChaosMiddleware(cfg.Chaos, cfg.Metrics.Registry))
+		logger.Warn("Chaos middleware enabled for NATS")
+	}
+
+	// Answer the requests on read-mostly subjects from the cache
+	if cfg.ResponseCache.Enabled {
+		m.responseCache = NewResponseCache(cfg.ResponseCache)
+		m.Publisher.UseRequest(m.responseCache.Middleware())
+		logger.Info("Response cache enabled for NATS",
+			zap.Duration("ttl", m.responseCache.ttl),
+			zap.Strings("subjects", cfg.ResponseCache.Subjects),
+			zap.Bool("shared", cfg.ResponseCache.Cache != nil),
+		)
+	}
+
+	// Coalesce identical requests last, so every caller is still measured,
+	// traced and logged
+	if cfg.CoalesceRequests {
+		m.Publisher.UseRequest(RequestCoalescingMiddleware(cfg.Metrics.Registry))
+		logger.Info("Request coalescing enabled for NATS")
+	}
+
+	// Enable envelope signing and verification
+	if cfg.Signing.Enabled {
+		signer, err := NewEnvelopeSigner(cfg.Signing)
+		if err != nil {
+			_ = client.Close()
+			return fmt.Errorf("failed to init envelope signing: %w", err)
+		}
+		m.Publisher.SetSigner(signer)
+		if cfg.Signing.Verify {
+			m.Subscriber.Use(VerificationMiddleware(signer, cfg.Signing.Subjects, cfg.Metrics.Registry))
+		}
+		logger.Info("Envelope signing enabled for NATS",
+			zap.String("key_id", cfg.Signing.KeyID),
+			zap.Bool("verify", cfg.Signing.Verify),
+			zap.Strings("subjects", cfg.Signing.Subjects),
+		)
+	}
+
+	// Terminate and quarantine the JetStream messages failing too many deliveries
+	if cfg.Quarantine.Enabled {
+		quarantine, err := NewQuarantine(client, cfg.Quarantine, cfg.Metrics.Registry, logger)
+		if err != nil {
+			_ = client.Close()
+			return fmt.Errorf("failed to init quarantine: %w", err)
+		}
+		m.Subscriber.SetQuarantine(quarantine)
+		m.quarantine = quarantine
+		logger.Info("Poison message quarantine enabled for NATS",
+			zap.String("stream", quarantine.cfg.Stream),
+			zap.Int("max_deliveries", quarantine.cfg.MaxDeliveries),
+		)
+	}
+
+	// Export the sizes of the streams and the lag of their consumers, once
+	// nothing else can fail
+	if cfg.Monitoring.Enabled {
+		m.monitor = NewStreamMonitor(client, cfg.Monitoring, cfg.Metrics.Registry, logger)
+		m.monitor.Start()
+		logger.Info("Stream monitoring enabled for NATS",
+			zap.Duration("interval", cfg.Monitoring.Interval),
+			zap.Strings("streams", cfg.Monitoring.Streams),
+		)
+	}
+
 	return nil
 }
 
+// ApplyConfig applies the settings that can change while connected: the token
+// or user credentials and the encryption keys are rotated. Other settings
+// require a new Messenger.
+func (m *Messenger) ApplyConfig(cfg Config) error {
+	if m.Client == nil {
+		return fmt.Errorf("messenger is not initialized")
+	}
+	if cfg.Encryption.Enabled != (m.keys != nil) {
+		return fmt.Errorf("enabling or disabling encryption requires a restart")
+	}
+	if m.keys != nil {
+		if err := m.keys.Update(cfg.Encryption); err != nil {
+			return fmt.Errorf("failed to rotate encryption keys: %w", err)
+		}
+	}
+	// The embedded server requires the new credentials before the client
+	// reconnects with them
+	if m.embedded != nil {
+		if err := m.embedded.UpdateAuth(cfg.Token, cfg.Username, cfg.Password); err != nil {
+			return err
+		}
+	}
+	return m.Client.UpdateAuth(cfg.Token, cfg.Username, cfg.Password)
+}
+
+// OnConnectionEvent registers h for the connection events of the client
+// until the returned function is called. Config.OnConnectionEvent also
+// receives the first connection.
+func (m *Messenger) OnConnectionEvent(h ConnectionEventHandler) (unregister func()) {
+	return m.Client.OnConnectionEvent(h)
+}
+
+// Replayer returns a Replayer decoding envelopes as the subscriber does
+func (m *Messenger) Replayer() *Replayer {
+	r := NewReplayer(m.Client)
+	r.SetCodec(m.codec)
+	r.SetPayloadStore(m.payloads)
+	return r
+}
+
+// Monitor returns the stream monitor, or nil when monitoring is disabled
+func (m *Messenger) Monitor() *StreamMonitor {
+	return m.monitor
+}
+
+// Quarantine returns the quarantine of poison messages, or nil when disabled
+func (m *Messenger) Quarantine() *Quarantine {
+	return m.quarantine
+}
+
+// EmbeddedServer returns the NATS server run in the process, nil when the
+// messenger connects to an external one
+func (m *Messenger) EmbeddedServer() *EmbeddedServer {
+	return m.embedded
+}
+
+// ResponseCache returns the cache of the replies to requests, or nil when
+// disabled
+func (m *Messenger) ResponseCache() *ResponseCache {
+	return m.responseCache
+}
+
 // Close closes the underlying client and subscriber.
 func (m *Messenger) Close() error {
+	if m.monitor != nil {
+		m.monitor.Stop()
+	}
 	if m.Subscriber != nil {
 		_ = m.Subscriber.Close()
 	}
+	var err error
 	if m.Client != nil {
-		return m.Client.Close()
+		err = m.Client.Close()
 	}
-	return nil
+	if m.embedded != nil {
+		m.embedded.Shutdown()
+	}
+	return err
 }
@@ -1,10 +1,16 @@
 package nats
 
 import (
+	"context"
+	"encoding/json"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
 
@@ -49,3 +55,66 @@ func TestMessenger_Close(t *testing.T) {
 	err := m.Close()
 	assert.NoError(t, err)
 }
+
+func TestMessenger_ApplyConfig_NotInitialized(t *testing.T) {
+	m := &Messenger{}
+	assert.Error(t, m.ApplyConfig(Config{Token: "token"}))
+}
+
+func TestMessenger_Encryption(t *testing.T) {
+	s, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1, NoLog: true, NoSigs: true})
+	require.NoError(t, err)
+	go s.Start()
+	require.True(t, s.ReadyForConnections(5*time.Second))
+	defer s.Shutdown()
+
+	v1 := EncryptionKey{ID: "v1", Key: testEncryptionKey('a')}
+	cfg := Config{
+		URL:               s.ClientURL(),
+		ConnectionTimeout: time.Second,
+		Compression:       CompressionConfig{Enabled: true, Threshold: 64},
+		Encryption:        EncryptionConfig{Enabled: true, KeyID: "v1", Keys: []EncryptionKey{v1}, Subjects: []string{"users.>"}},
+	}
+	m := &Messenger{}
+	require.NoError(t, m.Init(cfg, zap.NewNop(), "test-app"))
+	defer m.Close()
+
+	received := make(chan string, 1)
+	require.NoError(t, m.Subscriber.Subscribe("users.created", func(ctx context.Context, subject string, msg *MessageEnvelope) error {
+		var data string
+		if err := json.Unmarshal(msg.Data, &data); err != nil {
+			return err
+		}
+		received <- data
+		return nil
+	}, nil))
+	wire := make(chan *nats.Msg, 1)
+	raw, err := m.Client.Conn().ChanSubscribe("users.created", wire)
+	require.NoError(t, err)
+	defer raw.Unsubscribe()
+
+	pii := strings.Repeat("jane@example.com ", 20)
+	require.NoError(t, m.Publisher.Publish(context.Background(), "users.created", "UserCreated", pii, nil))
+	select {
+	case data := <-received:
+		assert.Equal(t, pii, data)
+	case <-time.After(2 * time.Second):
+		t.Fatal("message not received")
+	}
+	var env MessageEnvelope
+	require.NoError(t, json.Unmarshal((<-wire).Data, &env))
+	assert.Equal(t, "v1", env.Metadata[MetadataEncryptionKeyID], "the data is encrypted on the wire")
+	assert.Equal(t, CompressionZstd, env.Metadata[MetadataContentEncoding], "and compressed before")
+	assert.NotContains(t, string(env.Data), "jane@example.com")
+
+	// Keys rotate with the config; toggling encryption needs a restart
+	cfg.Encryption.KeyID = "v2"
+	cfg.Encryption.Keys = append(cfg.Encryption.Keys, EncryptionKey{ID: "v2", Key: testEncryptionKey('b')})
+	require.NoError(t, m.ApplyConfig(cfg))
+	id, _, err := m.keys.CurrentKey()
+	require.NoError(t, err)
+	assert.Equal(t, "v2", id)
+
+	cfg.Encryption.Enabled = false
+	assert.Error(t, m.ApplyConfig(cfg))
+}
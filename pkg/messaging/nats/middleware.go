@@ -1,55 +1,206 @@
 package nats
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
+	"maps"
 	"time"
+	"unicode/utf8"
+
+	"grouter/pkg/chaos"
+	"grouter/pkg/loadshed"
+	applog "grouter/pkg/logger"
+	"grouter/pkg/slo"
+	"grouter/pkg/telemetry"
+	"grouter/pkg/tenant"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/sync/singleflight"
 )
 
-var (
-	// Metrics for publishers
-	publishCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+// MetricsOption configures the metrics middleware
+type MetricsOption func(*metricsOptions)
+
+type metricsOptions struct {
+	tenant      bool
+	cardinality CardinalityConfig
+}
+
+// WithTenantLabel adds a tenant label to the messaging metrics. Every
+// middleware of a registry must agree on it, as the label set of a metric is
+// fixed once registered.
+func WithTenantLabel() MetricsOption {
+	return func(o *metricsOptions) {
+		o.tenant = true
+	}
+}
+
+// WithCardinality bounds the label values of the messaging metrics with cfg
+func WithCardinality(cfg CardinalityConfig) MetricsOption {
+	return func(o *metricsOptions) {
+		o.cardinality = cfg
+	}
+}
+
+// messagingMetrics are the counter and duration of published or received
+// messages
+type messagingMetrics struct {
+	counter  *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	tenant   bool
+	// labels bounds the label values, nil when unbounded
+	labels *labeler
+}
+
+func newMessagingMetrics(reg *telemetry.MetricsRegistry, counter prometheus.CounterOpts, duration prometheus.HistogramOpts, opts []MetricsOption) *messagingMetrics {
+	var o metricsOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	labels := []string{"subject", "type"}
+	if o.tenant {
+		labels = append(labels, "tenant")
+	}
+	m := &messagingMetrics{
+		counter:  reg.CounterVec(counter, append(labels, "status")),
+		duration: reg.HistogramVec(duration, labels),
+		tenant:   o.tenant,
+	}
+	if o.cardinality.enabled() {
+		m.labels = newLabeler(reg, o.cardinality)
+	}
+	return m
+}
+
+// observe records a message of msgType on subject for the tenant id. Its
+// correlation ID, when known, is the exemplar of the duration, linking the
+// histogram to the logs of the flow without a label per flow.
+func (m *messagingMetrics) observe(id, correlationID, subject, msgType string, d time.Duration, err error) {
+	if m.labels != nil {
+		subject, msgType = m.labels.subject(subject), m.labels.value("type", msgType)
+		if m.tenant {
+			id = m.labels.value("tenant", id)
+		}
+	}
+	labels := []string{subject, msgType}
+	if m.tenant {
+		labels = append(labels, id)
+	}
+	status := "success"
+	if err != nil {
+		status = "error"
+	}
+	m.counter.WithLabelValues(append(labels, status)...).Inc()
+	observer := m.duration.WithLabelValues(labels...)
+	if eo, ok := observer.(prometheus.ExemplarObserver); ok && validExemplar(correlationID) {
+		eo.ObserveWithExemplar(d.Seconds(), prometheus.Labels{"correlation_id": correlationID})
+		return
+	}
+	observer.Observe(d.Seconds())
+}
+
+// validExemplar reports whether the correlation ID fits an exemplar, whose
+// labels are limited to 128 characters; invalid exemplars panic
+func validExemplar(correlationID string) bool {
+	return correlationID != "" && len(correlationID) <= 64 && utf8.ValidString(correlationID)
+}
+
+// publishMetrics returns the publish counter and duration of reg
+func publishMetrics(reg *telemetry.MetricsRegistry, opts []MetricsOption) *messagingMetrics {
+	return newMessagingMetrics(reg, prometheus.CounterOpts{
 		Name: "messaging_publish_total",
 		Help: "Total number of messages published",
-	}, []string{"subject", "type", "status"})
-
-	publishDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	}, prometheus.HistogramOpts{
 		Name:    "messaging_publish_duration_seconds",
 		Help:    "Duration of message publishing in seconds",
 		Buckets: prometheus.DefBuckets,
-	}, []string{"subject", "type"})
+	}, opts)
+}
 
-	// Metrics for subscribers
-	subscribeCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+// subscribeMetrics returns the subscribe counter and duration of reg
+func subscribeMetrics(reg *telemetry.MetricsRegistry, opts []MetricsOption) *messagingMetrics {
+	return newMessagingMetrics(reg, prometheus.CounterOpts{
 		Name: "messaging_subscribe_total",
 		Help: "Total number of messages received",
-	}, []string{"subject", "type", "status"})
-
-	subscribeDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	}, prometheus.HistogramOpts{
 		Name:    "messaging_subscribe_duration_seconds",
 		Help:    "Duration of message processing in seconds",
 		Buckets: prometheus.DefBuckets,
-	}, []string{"subject", "type"})
-)
+	}, opts)
+}
 
 // --- Logging Middleware ---
 
-// LoggingMiddleware returns a middleware that logs message processing
-func LoggingMiddleware(logger *zap.Logger) SubscriberMiddleware {
+// LoggingOption configures the logging middleware
+type LoggingOption func(*loggingOptions)
+
+type loggingOptions struct {
+	sampling *applog.SamplingConfig
+}
+
+// WithLogSampling samples the log lines of successful messages, per cfg,
+// instead of writing one per message. Failures are always logged.
+func WithLogSampling(cfg applog.SamplingConfig) LoggingOption {
+	return func(o *loggingOptions) {
+		o.sampling = &cfg
+	}
+}
+
+// successLogger returns the logger of the successful messages: logger, or a
+// sampled copy of it
+func successLogger(logger *zap.Logger, opts []LoggingOption) *zap.Logger {
+	var o loggingOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.sampling == nil {
+		return logger
+	}
+	return applog.Sample(logger, *o.sampling)
+}
+
+// LoggingMiddleware returns a middleware that logs message processing. The
+// fields of a message are only built when its log line is written.
+func LoggingMiddleware(logger *zap.Logger, logOpts ...LoggingOption) SubscriberMiddleware {
+	success := successLogger(logger, logOpts)
 	return func(next HandlerFunc) HandlerFunc {
 		return func(ctx context.Context, subject string, env *MessageEnvelope) error {
+			// Handlers get a message-scoped logger through applog.FromContext,
+			// including trace_id/span_id of the consumer span. Its fields are
+			// only encoded once the handler logs.
+			scope := []zap.Field{
+				zap.String("subject", subject),
+				zap.String("id", env.ID),
+			}
+			scope = append(scope, correlationFields(env.CorrelationID, env.CausationID)...)
+			if id := env.TenantID(); id != "" {
+				scope = append(scope, zap.String("tenant_id", id))
+			}
+			ctx = applog.WithContext(ctx, logger.WithLazy(scope...))
+
 			start := time.Now()
 			err := next(ctx, subject, env)
 			duration := time.Since(start)
 
+			var ce *zapcore.CheckedEntry
+			if err != nil {
+				ce = logger.Check(zap.ErrorLevel, "Message processing failed")
+			} else {
+				ce = success.Check(zap.InfoLevel, "Message processed successfully")
+			}
+			if ce == nil {
+				return err
+			}
+
 			fields := []zap.Field{
 				zap.String("subject", subject),
 				zap.String("type", env.Type),
@@ -57,37 +208,67 @@ func LoggingMiddleware(logger *zap.Logger) SubscriberMiddleware {
 				zap.String("source", env.Source),
 				zap.Duration("duration", duration),
 			}
-
+			fields = append(fields, correlationFields(env.CorrelationID, env.CausationID)...)
+			if id := env.TenantID(); id != "" {
+				fields = append(fields, zap.String("tenant_id", id))
+			}
+			fields = append(fields, applog.TraceFieldsFromContext(ctx)...)
 			if err != nil {
-				logger.Error("Message processing failed", append(fields, zap.Error(err))...)
-			} else {
-				logger.Info("Message processed successfully", fields...)
+				fields = append(fields, zap.Error(err))
 			}
+			ce.Write(fields...)
 
 			return err
 		}
 	}
 }
 
+// correlationFields returns the log fields of the correlation and causation
+// IDs that are set
+func correlationFields(correlationID, causationID string) []zap.Field {
+	fields := make([]zap.Field, 0, 2)
+	if correlationID != "" {
+		fields = append(fields, zap.String("correlation_id", correlationID))
+	}
+	if causationID != "" {
+		fields = append(fields, zap.String("causation_id", causationID))
+	}
+	return fields
+}
+
 // PublisherLoggingMiddleware returns a middleware that logs message publishing
-func PublisherLoggingMiddleware(logger *zap.Logger) PublisherMiddleware {
+func PublisherLoggingMiddleware(logger *zap.Logger, logOpts ...LoggingOption) PublisherMiddleware {
+	success := successLogger(logger, logOpts)
 	return func(next PublisherFunc) PublisherFunc {
 		return func(ctx context.Context, subject string, msgType string, data interface{}, opts *PublishOptions) error {
 			start := time.Now()
 			err := next(ctx, subject, msgType, data, opts)
 			duration := time.Since(start)
 
+			var ce *zapcore.CheckedEntry
+			if err != nil {
+				ce = logger.Check(zap.ErrorLevel, "Message publishing failed")
+			} else {
+				ce = success.Check(zap.DebugLevel, "Message published successfully")
+			}
+			if ce == nil {
+				return err
+			}
+
 			fields := []zap.Field{
 				zap.String("subject", subject),
 				zap.String("type", msgType),
 				zap.Duration("duration", duration),
 			}
-
+			fields = append(fields, correlationFields(CorrelationIDFromContext(ctx), CausationIDFromContext(ctx))...)
+			if id := tenant.FromContext(ctx); id != "" {
+				fields = append(fields, zap.String("tenant_id", id))
+			}
+			fields = append(fields, applog.TraceFieldsFromContext(ctx)...)
 			if err != nil {
-				logger.Error("Message publishing failed", append(fields, zap.Error(err))...)
-			} else {
-				logger.Debug("Message published successfully", fields...)
+				fields = append(fields, zap.Error(err))
 			}
+			ce.Write(fields...)
 
 			return err
 		}
@@ -95,29 +276,43 @@ func PublisherLoggingMiddleware(logger *zap.Logger) PublisherMiddleware {
 }
 
 // RequestLoggingMiddleware returns a middleware that logs request-reply interactions
-func RequestLoggingMiddleware(logger *zap.Logger) RequestMiddleware {
+func RequestLoggingMiddleware(logger *zap.Logger, logOpts ...LoggingOption) RequestMiddleware {
+	success := successLogger(logger, logOpts)
 	return func(next RequestFunc) RequestFunc {
 		return func(ctx context.Context, subject string, msgType string, data interface{}, timeout time.Duration) (*MessageEnvelope, error) {
 			start := time.Now()
 			resp, err := next(ctx, subject, msgType, data, timeout)
 			duration := time.Since(start)
 
+			var ce *zapcore.CheckedEntry
+			if err != nil {
+				ce = logger.Check(zap.ErrorLevel, "Request failed")
+			} else {
+				ce = success.Check(zap.DebugLevel, "Request completed successfully")
+			}
+			if ce == nil {
+				return resp, err
+			}
+
 			fields := []zap.Field{
 				zap.String("subject", subject),
 				zap.String("type", msgType),
 				zap.Duration("duration", duration),
 			}
-
+			fields = append(fields, correlationFields(CorrelationIDFromContext(ctx), CausationIDFromContext(ctx))...)
+			if id := tenant.FromContext(ctx); id != "" {
+				fields = append(fields, zap.String("tenant_id", id))
+			}
+			fields = append(fields, applog.TraceFieldsFromContext(ctx)...)
 			if err != nil {
-				logger.Error("Request failed", append(fields, zap.Error(err))...)
+				fields = append(fields, zap.Error(err))
 			} else {
-				logger.Debug("Request completed successfully",
-					append(fields,
-						zap.String("response_id", resp.ID),
-						zap.String("response_type", resp.Type),
-					)...,
+				fields = append(fields,
+					zap.String("response_id", resp.ID),
+					zap.String("response_type", resp.Type),
 				)
 			}
+			ce.Write(fields...)
 
 			return resp, err
 		}
@@ -126,66 +321,45 @@ func RequestLoggingMiddleware(logger *zap.Logger) RequestMiddleware {
 
 // --- Metrics Middleware ---
 
-// MetricsMiddleware returns a middleware that tracks message processing metrics
-func MetricsMiddleware() SubscriberMiddleware {
+// MetricsMiddleware returns a middleware that tracks message processing
+// metrics in reg (nil uses the global registry)
+func MetricsMiddleware(reg *telemetry.MetricsRegistry, opts ...MetricsOption) SubscriberMiddleware {
+	metrics := subscribeMetrics(reg, opts)
 	return func(next HandlerFunc) HandlerFunc {
 		return func(ctx context.Context, subject string, env *MessageEnvelope) error {
 			start := time.Now()
 			err := next(ctx, subject, env)
-			duration := time.Since(start)
-
-			status := "success"
-			if err != nil {
-				status = "error"
-			}
-
-			subscribeCounter.WithLabelValues(subject, env.Type, status).Inc()
-			subscribeDuration.WithLabelValues(subject, env.Type).Observe(duration.Seconds())
-
+			metrics.observe(env.TenantID(), env.CorrelationID, subject, env.Type, time.Since(start), err)
 			return err
 		}
 	}
 }
 
-// PublisherMetricsMiddleware returns a middleware that tracks message publishing metrics
-func PublisherMetricsMiddleware() PublisherMiddleware {
+// PublisherMetricsMiddleware returns a middleware that tracks message
+// publishing metrics in reg (nil uses the global registry)
+func PublisherMetricsMiddleware(reg *telemetry.MetricsRegistry, opts ...MetricsOption) PublisherMiddleware {
+	metrics := publishMetrics(reg, opts)
 	return func(next PublisherFunc) PublisherFunc {
 		return func(ctx context.Context, subject string, msgType string, data interface{}, opts *PublishOptions) error {
 			start := time.Now()
 			err := next(ctx, subject, msgType, data, opts)
-			duration := time.Since(start)
-
-			status := "success"
-			if err != nil {
-				status = "error"
-			}
-
-			publishCounter.WithLabelValues(subject, msgType, status).Inc()
-			publishDuration.WithLabelValues(subject, msgType).Observe(duration.Seconds())
-
+			metrics.observe(tenant.FromContext(ctx), CorrelationIDFromContext(ctx), subject, msgType, time.Since(start), err)
 			return err
 		}
 	}
 }
 
 // RequestMetricsMiddleware returns a middleware that tracks request metrics
-func RequestMetricsMiddleware() RequestMiddleware {
+// in reg (nil uses the global registry)
+func RequestMetricsMiddleware(reg *telemetry.MetricsRegistry, opts ...MetricsOption) RequestMiddleware {
+	metrics := publishMetrics(reg, opts)
 	return func(next RequestFunc) RequestFunc {
 		return func(ctx context.Context, subject string, msgType string, data interface{}, timeout time.Duration) (*MessageEnvelope, error) {
 			start := time.Now()
 			resp, err := next(ctx, subject, msgType, data, timeout)
-			duration := time.Since(start)
-
-			status := "success"
-			if err != nil {
-				status = "error"
-			}
-
 			// We reuse the publish metrics, or we could create request specific ones.
 			// Reusing fits the "publish" concept (we are publishing a request).
-			publishCounter.WithLabelValues(subject, msgType, status).Inc()
-			publishDuration.WithLabelValues(subject, msgType).Observe(duration.Seconds())
-
+			metrics.observe(tenant.FromContext(ctx), CorrelationIDFromContext(ctx), subject, msgType, time.Since(start), err)
 			return resp, err
 		}
 	}
@@ -235,6 +409,8 @@ func TracingMiddleware(tracer trace.Tracer) SubscriberMiddleware {
 					semconv.MessagingMessageID(env.ID),
 					attribute.String("messaging.source", env.Source),
 					attribute.String("messaging.message_type", env.Type),
+					semconv.MessagingMessageConversationID(env.CorrelationID),
+					attribute.String("messaging.causation_id", env.CausationID),
 				),
 			)
 			defer span.End()
@@ -250,29 +426,33 @@ func TracingMiddleware(tracer trace.Tracer) SubscriberMiddleware {
 	}
 }
 
-// PublisherTracingMiddleware returns a middleware that injects trace context into message metadata
+// PublisherTracingMiddleware returns a middleware tracing publishes with a
+// producer span, whose context the publisher injects into the envelope
+// metadata. Replies published by handlers on the reply subject of their
+// request get a reply span linked to the span of the requester.
 func PublisherTracingMiddleware(tracer trace.Tracer) PublisherMiddleware {
 	return func(next PublisherFunc) PublisherFunc {
 		return func(ctx context.Context, subject string, msgType string, data interface{}, opts *PublishOptions) error {
-			// Start span
-			ctx, span := tracer.Start(ctx, fmt.Sprintf("messaging.send %s", subject),
+			name := "messaging.send " + subject
+			attrs := []attribute.KeyValue{
+				attribute.String("messaging.subject", subject),
+				attribute.String("messaging.message_type", msgType),
+			}
+			var links []trace.Link
+			if request, ok := replyRequest(ctx, subject); ok {
+				name = "messaging.reply " + subject
+				attrs = append(attrs, attribute.String("messaging.operation", "reply"))
+				if request.IsValid() {
+					links = append(links, trace.Link{SpanContext: request})
+				}
+			}
+			ctx, span := tracer.Start(ctx, name,
 				trace.WithSpanKind(trace.SpanKindProducer),
-				trace.WithAttributes(
-					attribute.String("messaging.subject", subject),
-					attribute.String("messaging.message_type", msgType),
-				),
+				trace.WithAttributes(attrs...),
+				trace.WithLinks(links...),
 			)
 			defer span.End()
 
-			// We need to inject context into the envelope metadata.
-			// However, PublisherFunc doesn't give us access to the envelope directly.
-			// The envelope is created inside the publisher.publish method.
-			// This is a design limitation. To fix this, we would need to refactor
-			// the publisher to allow middleware to modify the envelope or pass metadata.
-
-			// For now, we'll just wrap the call. Tracing will work for the local process,
-			// but propagation to the subscriber will require a refactor of the Publisher.
-
 			err := next(ctx, subject, msgType, data, opts)
 			if err != nil {
 				span.RecordError(err)
@@ -284,8 +464,12 @@ func PublisherTracingMiddleware(tracer trace.Tracer) PublisherMiddleware {
 	}
 }
 
-// RequestTracingMiddleware returns a middleware that injects trace context into request metadata
+// RequestTracingMiddleware returns a middleware tracing requests with a span
+// whose context the publisher injects into the request metadata. The span
+// is linked to the reply span of the responder.
 func RequestTracingMiddleware(tracer trace.Tracer) RequestMiddleware {
+	propagator := otel.GetTextMapPropagator()
+
 	return func(next RequestFunc) RequestFunc {
 		return func(ctx context.Context, subject string, msgType string, data interface{}, timeout time.Duration) (*MessageEnvelope, error) {
 			// Start span
@@ -299,10 +483,6 @@ func RequestTracingMiddleware(tracer trace.Tracer) RequestMiddleware {
 			)
 			defer span.End()
 
-			// Note: Similar to PublisherTracingMiddleware, we cannot easily inject metadata
-			// into the envelope here without refactoring. The envelope is created inside `next` (the p.request method).
-			// However, unlike Publish, Request waits for a response.
-
 			resp, err := next(ctx, subject, msgType, data, timeout)
 			if err != nil {
 				span.RecordError(err)
@@ -312,6 +492,10 @@ func RequestTracingMiddleware(tracer trace.Tracer) RequestMiddleware {
 					attribute.String("messaging.response_id", resp.ID),
 					attribute.String("messaging.response_type", resp.Type),
 				)
+				reply := trace.SpanContextFromContext(propagator.Extract(context.Background(), metadataCarrier(resp.Metadata)))
+				if reply.IsValid() {
+					span.AddLink(trace.Link{SpanContext: reply})
+				}
 			}
 
 			return resp, err
@@ -319,6 +503,193 @@ func RequestTracingMiddleware(tracer trace.Tracer) RequestMiddleware {
 	}
 }
 
-// Note: To fully support trace propagation, we should update MessagePublisher interface
-// and Publisher implementation to accept metadata or a context that can be used to
-// populate the envelope's metadata.
+// --- Expiry Middleware ---
+
+// ExpiryMiddleware returns a middleware that drops messages past their
+// ExpiresAt instead of handling them, logging them at debug level and counting
+// them in reg (nil uses the global registry). Dropped messages are not errors,
+// so JetStream acks them.
+func ExpiryMiddleware(logger *zap.Logger, reg *telemetry.MetricsRegistry) SubscriberMiddleware {
+	expired := expiredMetric(reg)
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, subject string, env *MessageEnvelope) error {
+			if env.Expired(time.Now()) {
+				expired.WithLabelValues(subject, env.Type).Inc()
+				if ce := logger.Check(zap.DebugLevel, "Dropped expired message"); ce != nil {
+					ce.Write(
+						zap.String("subject", subject),
+						zap.String("type", env.Type),
+						zap.String("id", env.ID),
+						zap.Time("expires_at", env.ExpiresAt),
+					)
+				}
+				return nil
+			}
+			return next(ctx, subject, env)
+		}
+	}
+}
+
+func expiredMetric(reg *telemetry.MetricsRegistry) *prometheus.CounterVec {
+	return reg.CounterVec(prometheus.CounterOpts{
+		Name: "messaging_expired_total",
+		Help: "Total number of messages dropped past their expiry",
+	}, []string{"subject", "type"})
+}
+
+// --- Coalescing Middleware ---
+
+// RequestCoalescingMiddleware returns a middleware that coalesces concurrent
+// identical requests (same tenant, subject, type, data and timeout) into one
+// request, whose reply goes to every caller. Requests answered by another's
+// reply are counted in reg (nil uses the global registry).
+func RequestCoalescingMiddleware(reg *telemetry.MetricsRegistry) RequestMiddleware {
+	coalesced := coalescedMetric(reg)
+	var group singleflight.Group
+	return func(next RequestFunc) RequestFunc {
+		return func(ctx context.Context, subject string, msgType string, data interface{}, timeout time.Duration) (*MessageEnvelope, error) {
+			key, err := coalescingKey(ctx, subject, msgType, data, timeout)
+			if err != nil {
+				// Left to next to report
+				return next(ctx, subject, msgType, data, timeout)
+			}
+
+			// The request is shared, so the caller that started it giving up
+			// must not cancel it for the others
+			requestCtx := context.WithoutCancel(ctx)
+			var started bool
+			ch := group.DoChan(key, func() (interface{}, error) {
+				started = true
+				return next(requestCtx, subject, msgType, data, timeout)
+			})
+			select {
+			case res := <-ch:
+				if !started {
+					coalesced.WithLabelValues(subject).Inc()
+				}
+				if res.Err != nil {
+					return nil, res.Err
+				}
+				// Every caller gets its own copy to modify
+				return cloneEnvelope(res.Val.(*MessageEnvelope)), nil
+			case <-ctx.Done():
+				return nil, fmt.Errorf("request failed: %w", ctx.Err())
+			}
+		}
+	}
+}
+
+func coalescedMetric(reg *telemetry.MetricsRegistry) *prometheus.CounterVec {
+	return reg.CounterVec(prometheus.CounterOpts{
+		Name: "messaging_requests_coalesced_total",
+		Help: "Total number of requests answered by the reply of an identical in-flight request",
+	}, []string{"subject"})
+}
+
+// coalescingKey identifies identical requests, hashing their data as JSON
+func coalescingKey(ctx context.Context, subject, msgType string, data interface{}, timeout time.Duration) (string, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(raw)
+	return fmt.Sprintf("%s\x00%s\x00%s\x00%s\x00%x", tenant.FromContext(ctx), subject, msgType, timeout, sum), nil
+}
+
+// cloneEnvelope copies env, its metadata and its data
+func cloneEnvelope(env *MessageEnvelope) *MessageEnvelope {
+	clone := *env
+	clone.Metadata = maps.Clone(env.Metadata)
+	clone.Data = bytes.Clone(env.Data)
+	return &clone
+}
+
+// --- Load Shedding Middleware ---
+
+// LoadSheddingMiddleware returns a middleware that drops the messages arriving
+// while s is above one of its thresholds, counting them in reg (nil uses the
+// global registry). JetStream messages are naked for a redelivery after
+// RetryAfter; core NATS messages are lost, and requests time out.
+func LoadSheddingMiddleware(s *loadshed.Shedder, reg *telemetry.MetricsRegistry) SubscriberMiddleware {
+	shed := shedMetric(reg)
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, subject string, env *MessageEnvelope) error {
+			release, reason, ok := s.Acquire()
+			if !ok {
+				shed.WithLabelValues(subject, string(reason)).Inc()
+				if acker := AckerFromContext(ctx); acker != nil {
+					_ = acker.NakWithDelay(s.RetryAfter())
+				}
+				return nil
+			}
+			defer release()
+			return next(ctx, subject, env)
+		}
+	}
+}
+
+func shedMetric(reg *telemetry.MetricsRegistry) *prometheus.CounterVec {
+	return reg.CounterVec(prometheus.CounterOpts{
+		Name: "messaging_messages_shed_total",
+		Help: "Total number of messages dropped under load",
+	}, []string{"subject", "reason"})
+}
+
+// --- Chaos Middleware ---
+
+// ChaosMiddleware returns a middleware injecting the faults of the nats rules
+// of inj into the messages on the matching subjects, counting them in reg
+// (nil uses the global registry). Error faults fail the handler with
+// chaos.ErrInjected, so JetStream redelivers the message; dropped messages
+// are not handled and count as handled.
+func ChaosMiddleware(inj *chaos.Injector, reg *telemetry.MetricsRegistry) SubscriberMiddleware {
+	injected := chaosMetric(reg)
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, subject string, env *MessageEnvelope) error {
+			rule, ok := inj.Pick(chaos.TargetNATS, func(pattern string) bool {
+				return MatchSubject(pattern, subject)
+			})
+			if !ok {
+				return next(ctx, subject, env)
+			}
+			injected.WithLabelValues(subject, string(rule.Fault)).Inc()
+
+			switch rule.Fault {
+			case chaos.FaultLatency:
+				if err := chaos.Sleep(ctx, rule.Latency); err != nil {
+					return err
+				}
+			case chaos.FaultError:
+				return chaos.ErrInjected
+			case chaos.FaultDrop:
+				return nil
+			}
+			return next(ctx, subject, env)
+		}
+	}
+}
+
+func chaosMetric(reg *telemetry.MetricsRegistry) *prometheus.CounterVec {
+	return reg.CounterVec(prometheus.CounterOpts{
+		Name: "messaging_chaos_faults_total",
+		Help: "Total number of faults injected into messages",
+	}, []string{"subject", "fault"})
+}
+
+// --- SLO Middleware ---
+
+// SLOMiddleware returns a middleware observing the messages of the nats
+// objectives of tracker on the matching subjects, counting the handler
+// errors as failures
+func SLOMiddleware(tracker *slo.Tracker) SubscriberMiddleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, subject string, env *MessageEnvelope) error {
+			start := time.Now()
+			err := next(ctx, subject, env)
+			tracker.Observe(slo.KindNATS, "", func(pattern string) bool {
+				return MatchSubject(pattern, subject)
+			}, time.Since(start), err != nil)
+			return err
+		}
+	}
+}
@@ -2,14 +2,28 @@ package nats
 
 import (
 	"context"
+	"errors"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"grouter/pkg/chaos"
+	"grouter/pkg/loadshed"
+	applog "grouter/pkg/logger"
+	"grouter/pkg/slo"
+	"grouter/pkg/telemetry"
+	"grouter/pkg/tenant"
 
 	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/trace"
 	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	oteltrace "go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"go.uber.org/zap/zaptest/observer"
 )
 
@@ -34,8 +48,36 @@ func TestLoggingMiddleware(t *testing.T) {
 	assert.Equal(t, "Message processed successfully", obs.All()[0].Message)
 }
 
+func TestLoggingMiddleware_TraceCorrelation(t *testing.T) {
+	core, obs := observer.New(zap.InfoLevel)
+	logger := zap.New(core)
+	tp := trace.NewTracerProvider()
+	defer tp.Shutdown(context.Background())
+
+	var spanID string
+	chain := TracingMiddleware(tp.Tracer("test"))(LoggingMiddleware(logger)(
+		func(ctx context.Context, subject string, env *MessageEnvelope) error {
+			spanID = oteltrace.SpanContextFromContext(ctx).SpanID().String()
+			applog.FromContext(ctx).Info("handling")
+			return nil
+		},
+	))
+
+	env := &MessageEnvelope{ID: "test-id", Type: "test-type"}
+	assert.NoError(t, chain(context.Background(), "test.subject", env))
+
+	require.Equal(t, 2, obs.Len())
+	for _, entry := range obs.All() {
+		fields := entry.ContextMap()
+		assert.Equal(t, spanID, fields[applog.SpanIDKey], entry.Message)
+		assert.NotEmpty(t, fields[applog.TraceIDKey], entry.Message)
+	}
+	assert.Equal(t, "test-id", obs.All()[0].ContextMap()["id"])
+}
+
 func TestMetricsMiddleware(t *testing.T) {
-	mw := MetricsMiddleware()
+	reg := telemetry.NewMetricsRegistry()
+	mw := MetricsMiddleware(reg)
 	handler := mw(func(ctx context.Context, subject string, env *MessageEnvelope) error {
 		return nil
 	})
@@ -45,14 +87,208 @@ func TestMetricsMiddleware(t *testing.T) {
 		Type: "test-type",
 	}
 
-	// Reset metrics if possible or just check increment
-	before := testutil.ToFloat64(subscribeCounter.WithLabelValues("test.subject", "test-type", "success"))
-
 	err := handler(context.Background(), "test.subject", env)
 	assert.NoError(t, err)
 
-	after := testutil.ToFloat64(subscribeCounter.WithLabelValues("test.subject", "test-type", "success"))
-	assert.Equal(t, before+1, after)
+	subscribeCounter := subscribeMetrics(reg, nil).counter
+	assert.Equal(t, float64(1), testutil.ToFloat64(subscribeCounter.WithLabelValues("test.subject", "test-type", "success")))
+}
+
+func TestMetricsMiddleware_TenantLabel(t *testing.T) {
+	reg := telemetry.NewMetricsRegistry()
+	handler := MetricsMiddleware(reg, WithTenantLabel())(func(ctx context.Context, subject string, env *MessageEnvelope) error {
+		return nil
+	})
+	env := &MessageEnvelope{ID: "test-id", Type: "test-type"}
+	env.SetTenantID("acme")
+	assert.NoError(t, handler(context.Background(), "test.subject", env))
+
+	publish := PublisherMetricsMiddleware(reg, WithTenantLabel())(func(ctx context.Context, subject string, msgType string, data interface{}, opts *PublishOptions) error {
+		return nil
+	})
+	assert.NoError(t, publish(tenant.NewContext(context.Background(), "globex"), "test.subject", "test-type", nil, nil))
+
+	subscribeCounter := subscribeMetrics(reg, []MetricsOption{WithTenantLabel()}).counter
+	assert.Equal(t, float64(1), testutil.ToFloat64(subscribeCounter.WithLabelValues("test.subject", "test-type", "acme", "success")))
+	publishCounter := publishMetrics(reg, []MetricsOption{WithTenantLabel()}).counter
+	assert.Equal(t, float64(1), testutil.ToFloat64(publishCounter.WithLabelValues("test.subject", "test-type", "globex", "success")))
+}
+
+func TestExpiryMiddleware(t *testing.T) {
+	reg := telemetry.NewMetricsRegistry()
+	called := 0
+	handler := ExpiryMiddleware(zap.NewNop(), reg)(func(ctx context.Context, subject string, env *MessageEnvelope) error {
+		called++
+		return nil
+	})
+
+	assert.NoError(t, handler(context.Background(), "test.subject", &MessageEnvelope{ID: "no-ttl", Type: "test-type"}))
+	assert.NoError(t, handler(context.Background(), "test.subject", &MessageEnvelope{ID: "fresh", Type: "test-type", ExpiresAt: time.Now().Add(time.Minute)}))
+	assert.Equal(t, 2, called)
+
+	stale := &MessageEnvelope{ID: "stale", Type: "test-type", ExpiresAt: time.Now().Add(-time.Second)}
+	assert.NoError(t, handler(context.Background(), "test.subject", stale), "expired messages are dropped, not failed")
+	assert.Equal(t, 2, called)
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(expiredMetric(reg).WithLabelValues("test.subject", "test-type")))
+}
+
+func TestLoadSheddingMiddleware(t *testing.T) {
+	reg := telemetry.NewMetricsRegistry()
+	shedder := loadshed.New(loadshed.Config{Enabled: true, MaxInFlight: 1})
+	called := 0
+	handler := LoadSheddingMiddleware(shedder, reg)(func(ctx context.Context, subject string, env *MessageEnvelope) error {
+		called++
+		return nil
+	})
+	env := &MessageEnvelope{ID: "test-id", Type: "test-type"}
+
+	assert.NoError(t, handler(context.Background(), "test.subject", env))
+	assert.Equal(t, 1, called)
+	assert.Equal(t, 0, shedder.InFlight())
+
+	// A message in flight fills the only slot
+	release, _, ok := shedder.Acquire()
+	require.True(t, ok)
+	assert.NoError(t, handler(context.Background(), "test.subject", env), "shed messages are dropped, not failed")
+	assert.Equal(t, 1, called)
+	release()
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(shedMetric(reg).WithLabelValues("test.subject", string(loadshed.ReasonInFlight))))
+}
+
+func TestChaosMiddleware(t *testing.T) {
+	reg := telemetry.NewMetricsRegistry()
+	inj, err := chaos.New(chaos.State{Active: true, Rules: []chaos.Rule{
+		{Target: chaos.TargetNATS, Match: "orders.*", Percent: 100, Fault: chaos.FaultError},
+		{Target: chaos.TargetNATS, Match: "audit.>", Percent: 100, Fault: chaos.FaultDrop},
+		{Target: chaos.TargetNATS, Match: "slow", Percent: 100, Fault: chaos.FaultLatency, Latency: 10 * time.Millisecond},
+	}})
+	require.NoError(t, err)
+	called := 0
+	handler := ChaosMiddleware(inj, reg)(func(ctx context.Context, subject string, env *MessageEnvelope) error {
+		called++
+		return nil
+	})
+	env := &MessageEnvelope{ID: "test-id", Type: "test-type"}
+	ctx := context.Background()
+
+	assert.ErrorIs(t, handler(ctx, "orders.created", env), chaos.ErrInjected)
+	assert.NoError(t, handler(ctx, "audit.login.failed", env))
+	assert.Equal(t, 0, called, "failed and dropped messages are not handled")
+
+	start := time.Now()
+	assert.NoError(t, handler(ctx, "slow", env))
+	assert.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+	assert.NoError(t, handler(ctx, "payments.created", env))
+	assert.Equal(t, 2, called)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.ErrorIs(t, handler(cancelled, "slow", env), context.Canceled)
+
+	inj.SetActive(false)
+	assert.NoError(t, handler(ctx, "orders.created", env))
+	assert.Equal(t, 3, called)
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(chaosMetric(reg).WithLabelValues("orders.created", "error")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(chaosMetric(reg).WithLabelValues("slow", "latency")))
+}
+
+func TestSLOMiddleware(t *testing.T) {
+	tracker, err := slo.New(slo.Config{
+		Registry: telemetry.NewMetricsRegistry(),
+		Objectives: []slo.Objective{
+			{Name: "orders", Kind: slo.KindNATS, Match: "orders.>", Latency: 5 * time.Millisecond, Target: 0.99},
+		},
+	})
+	require.NoError(t, err)
+	handlerErr := errors.New("invalid order")
+	handler := SLOMiddleware(tracker)(func(ctx context.Context, subject string, env *MessageEnvelope) error {
+		switch subject {
+		case "orders.failed":
+			return handlerErr
+		case "orders.slow":
+			time.Sleep(10 * time.Millisecond)
+		}
+		return nil
+	})
+	env := &MessageEnvelope{ID: "test-id", Type: "test-type"}
+	ctx := context.Background()
+
+	assert.NoError(t, handler(ctx, "orders.created", env))
+	assert.ErrorIs(t, handler(ctx, "orders.failed", env), handlerErr)
+	assert.NoError(t, handler(ctx, "orders.slow", env))
+	assert.NoError(t, handler(ctx, "payments.created", env))
+
+	status := tracker.Status()[0]
+	assert.Equal(t, uint64(3), status.Total)
+	assert.Equal(t, uint64(2), status.Bad, "failed and slow messages are bad")
+}
+
+func TestLoggingMiddleware_Tenant(t *testing.T) {
+	core, obs := observer.New(zap.InfoLevel)
+	handler := LoggingMiddleware(zap.New(core))(func(ctx context.Context, subject string, env *MessageEnvelope) error {
+		applog.FromContext(ctx).Info("handling")
+		return nil
+	})
+
+	env := &MessageEnvelope{ID: "test-id", Type: "test-type"}
+	env.SetTenantID("acme")
+	assert.NoError(t, handler(context.Background(), "test.subject", env))
+
+	require.Equal(t, 2, obs.Len())
+	for _, entry := range obs.All() {
+		assert.Equal(t, "acme", entry.ContextMap()["tenant_id"], entry.Message)
+	}
+}
+
+func TestLoggingMiddleware_Sampling(t *testing.T) {
+	core, obs := observer.New(zap.DebugLevel)
+	sampling := applog.SamplingConfig{Enabled: true, Initial: 2, Thereafter: 5, Tick: time.Minute}
+	var fail bool
+	handler := LoggingMiddleware(zap.New(core), WithLogSampling(sampling))(func(ctx context.Context, subject string, env *MessageEnvelope) error {
+		if fail {
+			return assert.AnError
+		}
+		return nil
+	})
+	publish := PublisherLoggingMiddleware(zap.New(core), WithLogSampling(sampling))(func(ctx context.Context, subject, msgType string, data interface{}, opts *PublishOptions) error {
+		return nil
+	})
+
+	env := &MessageEnvelope{ID: "test-id", Type: "test-type"}
+	for i := 0; i < 12; i++ {
+		require.NoError(t, handler(context.Background(), "test.subject", env))
+		require.NoError(t, publish(context.Background(), "test.subject", "test-type", nil, nil))
+	}
+	assert.Equal(t, 4, obs.FilterMessage("Message processed successfully").Len(), "first 2, then the 5th and 10th")
+	assert.Equal(t, 4, obs.FilterMessage("Message published successfully").Len())
+
+	fail = true
+	for i := 0; i < 3; i++ {
+		assert.Error(t, handler(context.Background(), "test.subject", env))
+	}
+	assert.Equal(t, 3, obs.FilterMessage("Message processing failed").Len(), "failures are not sampled")
+}
+
+func TestLoggingMiddleware_LevelDisabled(t *testing.T) {
+	env := &MessageEnvelope{ID: "test-id", Type: "test-type"}
+	next := func(ctx context.Context, subject string, env *MessageEnvelope) error { return nil }
+	allocs := func(level zapcore.Level) (float64, int) {
+		core, obs := observer.New(level)
+		handler := LoggingMiddleware(zap.New(core))(next)
+		n := testing.AllocsPerRun(100, func() {
+			_ = handler(context.Background(), "test.subject", env)
+		})
+		return n, obs.Len()
+	}
+
+	enabled, logged := allocs(zap.InfoLevel)
+	assert.Positive(t, logged)
+	disabled, logged := allocs(zap.ErrorLevel)
+	assert.Zero(t, logged)
+	assert.Less(t, disabled, enabled, "fields are not built for disabled levels")
 }
 
 func TestTracingMiddleware(t *testing.T) {
@@ -98,3 +334,101 @@ func TestPublisherTracingMiddleware(t *testing.T) {
 	assert.Len(t, spans, 1)
 	assert.Equal(t, "messaging.send test.subject", spans[0].Name)
 }
+
+func TestTracingMiddleware_RequestReply(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := trace.NewTracerProvider(trace.WithSpanProcessor(trace.NewSimpleSpanProcessor(exporter)))
+	propagator := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(propagator) })
+	tracer := tp.Tracer("test")
+
+	// The responder replies on the reply subject of the request
+	var replyMetadata map[string]string
+	reply := PublisherTracingMiddleware(tracer)(func(ctx context.Context, subject string, msgType string, data interface{}, opts *PublishOptions) error {
+		if subject == "_INBOX.1" {
+			replyMetadata = make(map[string]string)
+			injectContext(ctx, replyMetadata)
+		}
+		return nil
+	})
+	request := RequestTracingMiddleware(tracer)(func(ctx context.Context, subject string, msgType string, data interface{}, timeout time.Duration) (*MessageEnvelope, error) {
+		env := &MessageEnvelope{ID: "req", Type: msgType, Reply: "_INBOX.1", Metadata: make(map[string]string)}
+		injectContext(ctx, env.Metadata)
+		replyCtx, span := tracer.Start(ContextWithEnvelope(context.Background(), env), "handler")
+		require.NoError(t, reply(replyCtx, "_INBOX.1", "pong", nil, nil))
+		require.NoError(t, reply(replyCtx, "audit", "audit", nil, nil))
+		span.End()
+
+		return &MessageEnvelope{ID: "resp", Type: "pong", Metadata: replyMetadata}, nil
+	})
+
+	_, err := request(context.Background(), "ping", "ping", nil, time.Second)
+	require.NoError(t, err)
+
+	spans := make(map[string]tracetest.SpanStub)
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	req, resp := spans["messaging.request ping"], spans["messaging.reply _INBOX.1"]
+	require.Len(t, resp.Links, 1, "replies link to their request")
+	assert.Equal(t, req.SpanContext.SpanID(), resp.Links[0].SpanContext.SpanID())
+	require.Len(t, req.Links, 1, "requests link to their reply")
+	assert.Equal(t, resp.SpanContext.SpanID(), req.Links[0].SpanContext.SpanID())
+	assert.Contains(t, spans, "messaging.send audit", "other publishes are sends")
+	assert.Empty(t, spans["messaging.send audit"].Links)
+}
+
+func TestRequestCoalescingMiddleware(t *testing.T) {
+	reg := telemetry.NewMetricsRegistry()
+	release := make(chan struct{})
+	var calls atomic.Int32
+	next := func(ctx context.Context, subject string, msgType string, data interface{}, timeout time.Duration) (*MessageEnvelope, error) {
+		calls.Add(1)
+		<-release
+		return &MessageEnvelope{ID: "reply", Metadata: map[string]string{"k": "v"}, Data: []byte(`"ok"`)}, nil
+	}
+	request := RequestCoalescingMiddleware(reg)(next)
+
+	ctx := context.Background()
+	replies := make(chan *MessageEnvelope, 10)
+	send := func(ctx context.Context, data interface{}) {
+		go func() {
+			resp, err := request(ctx, "quotes.get", "QuoteRequested", data, time.Second)
+			assert.NoError(t, err)
+			replies <- resp
+		}()
+	}
+	send(ctx, map[string]string{"symbol": "ACME"})
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, 5*time.Millisecond)
+	for range 3 {
+		send(ctx, map[string]string{"symbol": "ACME"})
+	}
+	send(ctx, map[string]string{"symbol": "INIT"})
+	send(tenant.NewContext(ctx, "acme"), map[string]string{"symbol": "ACME"})
+
+	// A caller giving up does not cancel the shared request
+	cancelled, cancel := context.WithCancel(ctx)
+	errs := make(chan error, 1)
+	go func() {
+		_, err := request(cancelled, "quotes.get", "QuoteRequested", map[string]string{"symbol": "ACME"}, time.Second)
+		errs <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-errs, context.Canceled)
+
+	close(release)
+	var got []*MessageEnvelope
+	for range 6 {
+		got = append(got, <-replies)
+	}
+	assert.Equal(t, int32(3), calls.Load(), "other data and tenants are not coalesced")
+	assert.Equal(t, 3.0, testutil.ToFloat64(coalescedMetric(reg).WithLabelValues("quotes.get")), "the answered followers")
+
+	// Each caller owns its reply
+	got[0].Metadata["k"] = "changed"
+	got[0].Data[1] = 'x'
+	assert.Equal(t, "v", got[1].Metadata["k"])
+	assert.Equal(t, `"ok"`, string(got[1].Data))
+}
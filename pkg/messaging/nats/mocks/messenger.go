@@ -0,0 +1,12 @@
+package mocks
+
+import (
+	messaging "grouter/pkg/messaging/nats"
+)
+
+// NewMessenger returns a messenger of a fake publisher and subscriber,
+// without a client: it is never connected
+func NewMessenger() (*messaging.Messenger, *Publisher, *Subscriber) {
+	pub, sub := NewPublisher(), NewSubscriber()
+	return messaging.NewMessenger(nil, pub, sub), pub, sub
+}
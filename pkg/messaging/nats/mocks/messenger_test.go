@@ -0,0 +1,20 @@
+package mocks
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMessenger(t *testing.T) {
+	m, pub, sub := NewMessenger()
+	assert.False(t, m.IsConnected())
+
+	require.NoError(t, m.Publisher.Publish(context.Background(), "orders.created", "order.created", nil, nil))
+	assert.Len(t, pub.Messages(), 1)
+
+	require.NoError(t, m.Close())
+	assert.True(t, sub.Closed())
+}
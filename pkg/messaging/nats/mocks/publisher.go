@@ -0,0 +1,194 @@
+// Package mocks provides fakes of the messaging interfaces for unit tests.
+// They record the calls and return the scripted errors and replies, without
+// a NATS connection.
+package mocks
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Message is a message recorded by Publisher
+type Message struct {
+	Subject string
+	Type    string
+	Data    interface{}
+	// Options are the options of Publish, nil for the other methods
+	Options *messaging.PublishOptions
+	// JetStream is set for PublishJS and PublishAsyncJS
+	JetStream bool
+}
+
+// RequestFunc answers the requests of a Publisher
+type RequestFunc func(ctx context.Context, subject, msgType string, data interface{}) (*messaging.MessageEnvelope, error)
+
+// Publisher is a fake messaging.Publisher recording the published messages
+type Publisher struct {
+	mu       sync.Mutex
+	messages []Message
+	requests []Message
+	err      error
+	request  RequestFunc
+
+	// The components set through the Use and Set methods
+	Middlewares        []messaging.PublisherMiddleware
+	RequestMiddlewares []messaging.RequestMiddleware
+	Validator          messaging.Validator
+	Signer             messaging.Signer
+	PayloadStore       messaging.PayloadStore
+	Compressor         *messaging.Compressor
+	Codec              messaging.Codec
+	SkipFlush          bool
+}
+
+var _ messaging.Publisher = (*Publisher)(nil)
+
+// NewPublisher creates a fake publisher
+func NewPublisher() *Publisher {
+	return &Publisher{}
+}
+
+// FailWith makes the publish methods and requests return err, nil to succeed
+// again
+func (p *Publisher) FailWith(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.err = err
+}
+
+// OnRequest answers the requests with fn. Without it they fail with
+// nats.ErrNoResponders.
+func (p *Publisher) OnRequest(fn RequestFunc) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.request = fn
+}
+
+// Messages returns the published messages in order
+func (p *Publisher) Messages() []Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Message{}, p.messages...)
+}
+
+// Last returns the last published message
+func (p *Publisher) Last() (Message, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.messages) == 0 {
+		return Message{}, false
+	}
+	return p.messages[len(p.messages)-1], true
+}
+
+// Requests returns the sent requests in order
+func (p *Publisher) Requests() []Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Message{}, p.requests...)
+}
+
+// Reset forgets the recorded messages and requests
+func (p *Publisher) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.messages, p.requests = nil, nil
+}
+
+func (p *Publisher) record(msg Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	p.messages = append(p.messages, msg)
+	return nil
+}
+
+// Publish records the message
+func (p *Publisher) Publish(ctx context.Context, subject string, msgType string, data interface{}, opts *messaging.PublishOptions) error {
+	return p.record(Message{Subject: subject, Type: msgType, Data: data, Options: opts})
+}
+
+// PublishError records an "error" message carrying errMsg
+func (p *Publisher) PublishError(ctx context.Context, subject string, errMsg string) error {
+	return p.record(Message{Subject: subject, Type: "error", Data: map[string]string{"error": errMsg}})
+}
+
+// Request records the request and answers it with the OnRequest function
+func (p *Publisher) Request(ctx context.Context, subject string, msgType string, data interface{}, timeout time.Duration) (*messaging.MessageEnvelope, error) {
+	p.mu.Lock()
+	err, fn := p.err, p.request
+	if err == nil {
+		p.requests = append(p.requests, Message{Subject: subject, Type: msgType, Data: data})
+	}
+	p.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if fn == nil {
+		return nil, nats.ErrNoResponders
+	}
+	return fn(ctx, subject, msgType, data)
+}
+
+// PublishJS records the message and acknowledges it
+func (p *Publisher) PublishJS(ctx context.Context, subject string, msgType string, data interface{}, opts ...nats.PubOpt) (*nats.PubAck, error) {
+	if err := p.record(Message{Subject: subject, Type: msgType, Data: data, JetStream: true}); err != nil {
+		return nil, err
+	}
+	return p.ack(), nil
+}
+
+// PublishAsyncJS records the message and returns a resolved future
+func (p *Publisher) PublishAsyncJS(ctx context.Context, subject string, msgType string, data interface{}, opts ...nats.PubOpt) (nats.PubAckFuture, error) {
+	if err := p.record(Message{Subject: subject, Type: msgType, Data: data, JetStream: true}); err != nil {
+		return nil, err
+	}
+	return newFuture(p.ack(), subject), nil
+}
+
+func (p *Publisher) ack() *nats.PubAck {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return &nats.PubAck{Stream: "mock", Sequence: uint64(len(p.messages))}
+}
+
+// Use records the middlewares
+func (p *Publisher) Use(mw ...messaging.PublisherMiddleware) {
+	p.Middlewares = append(p.Middlewares, mw...)
+}
+
+// UseRequest records the request middlewares
+func (p *Publisher) UseRequest(mw ...messaging.RequestMiddleware) {
+	p.RequestMiddlewares = append(p.RequestMiddlewares, mw...)
+}
+
+func (p *Publisher) SetValidator(v messaging.Validator)       { p.Validator = v }
+func (p *Publisher) SetSigner(s messaging.Signer)             { p.Signer = s }
+func (p *Publisher) SetPayloadStore(s messaging.PayloadStore) { p.PayloadStore = s }
+func (p *Publisher) SetCompressor(c *messaging.Compressor)    { p.Compressor = c }
+func (p *Publisher) SetCodec(c messaging.Codec)               { p.Codec = c }
+func (p *Publisher) SetSkipFlush(skip bool)                   { p.SkipFlush = skip }
+
+// future is a resolved nats.PubAckFuture
+type future struct {
+	ok  chan *nats.PubAck
+	err chan error
+	msg *nats.Msg
+}
+
+func newFuture(ack *nats.PubAck, subject string) *future {
+	f := &future{ok: make(chan *nats.PubAck, 1), err: make(chan error), msg: nats.NewMsg(subject)}
+	f.ok <- ack
+	return f
+}
+
+func (f *future) Ok() <-chan *nats.PubAck { return f.ok }
+func (f *future) Err() <-chan error       { return f.err }
+func (f *future) Msg() *nats.Msg          { return f.msg }
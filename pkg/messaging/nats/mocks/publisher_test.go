@@ -0,0 +1,65 @@
+package mocks
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublisher_Records(t *testing.T) {
+	p := NewPublisher()
+	ctx := context.Background()
+	opts := &messaging.PublishOptions{Priority: messaging.PriorityHigh}
+
+	require.NoError(t, p.Publish(ctx, "orders.created", "order.created", "1", opts))
+	require.NoError(t, p.PublishError(ctx, "inbox", "boom"))
+	ack, err := p.PublishJS(ctx, "orders.shipped", "order.shipped", "1")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), ack.Sequence)
+	future, err := p.PublishAsyncJS(ctx, "orders.shipped", "order.shipped", "2")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), (<-future.Ok()).Sequence)
+
+	msgs := p.Messages()
+	require.Len(t, msgs, 4)
+	assert.Equal(t, Message{Subject: "orders.created", Type: "order.created", Data: "1", Options: opts}, msgs[0])
+	assert.Equal(t, map[string]string{"error": "boom"}, msgs[1].Data)
+	assert.True(t, msgs[2].JetStream)
+
+	last, ok := p.Last()
+	require.True(t, ok)
+	assert.Equal(t, "2", last.Data)
+
+	p.Reset()
+	assert.Empty(t, p.Messages())
+}
+
+func TestPublisher_Scripted(t *testing.T) {
+	p := NewPublisher()
+	ctx := context.Background()
+
+	_, err := p.Request(ctx, "orders.get", "order.get", nil, time.Second)
+	assert.ErrorIs(t, err, nats.ErrNoResponders)
+
+	p.OnRequest(func(ctx context.Context, subject, msgType string, data interface{}) (*messaging.MessageEnvelope, error) {
+		return &messaging.MessageEnvelope{Type: msgType + ".reply"}, nil
+	})
+	reply, err := p.Request(ctx, "orders.get", "order.get", nil, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "order.get.reply", reply.Type)
+	assert.Len(t, p.Requests(), 2)
+
+	boom := errors.New("boom")
+	p.FailWith(boom)
+	assert.ErrorIs(t, p.Publish(ctx, "orders.created", "order.created", nil, nil), boom)
+	_, err = p.Request(ctx, "orders.get", "order.get", nil, time.Second)
+	assert.ErrorIs(t, err, boom)
+	assert.Empty(t, p.Messages(), "failed publishes are not recorded")
+}
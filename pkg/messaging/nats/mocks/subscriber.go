@@ -0,0 +1,245 @@
+package mocks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	messaging "grouter/pkg/messaging/nats"
+	"grouter/pkg/telemetry"
+
+	"github.com/nats-io/nats.go"
+)
+
+// ErrNoSubscription is returned by Deliver when no subscription matches
+var ErrNoSubscription = errors.New("mocks: no subscription matches the subject")
+
+// Subscription kinds
+const (
+	KindCore = "core"
+	KindPush = "push"
+	KindPull = "pull"
+)
+
+// Subscription is a subscription recorded by Subscriber
+type Subscription struct {
+	Pattern string
+	Kind    string
+	Handler messaging.HandlerFunc
+	// Options are the options of Subscribe and SubscribeSubject
+	Options *messaging.SubscribeOptions
+	// Durable is the consumer of SubscribePull
+	Durable string
+
+	sub     *Subscriber
+	drained atomic.Bool
+}
+
+// Subject returns the pattern of the subscription
+func (s *Subscription) Subject() string { return s.Pattern }
+
+// Unsubscribe removes the subscription from its subscriber
+func (s *Subscription) Unsubscribe() error {
+	s.sub.remove(func(other *Subscription) bool { return other == s })
+	return nil
+}
+
+// Drain removes the subscription from its subscriber, like Unsubscribe, and
+// marks it drained
+func (s *Subscription) Drain(ctx context.Context) error {
+	s.sub.remove(func(other *Subscription) bool { return other == s })
+	s.drained.Store(true)
+	return nil
+}
+
+// Drained reports whether Drain was called
+func (s *Subscription) Drained() bool { return s.drained.Load() }
+
+// QueueGroup returns the queue group of the subscription, if any
+func (s *Subscription) QueueGroup() string {
+	if s.Options == nil {
+		return ""
+	}
+	return s.Options.QueueGroup
+}
+
+// Subscriber is a fake messaging.Subscriber recording the subscriptions.
+// Deliver pushes envelopes to their handlers.
+type Subscriber struct {
+	mu     sync.Mutex
+	subs   []*Subscription
+	err    error
+	closed bool
+	depth  int
+
+	// The components set through the Use and Set methods
+	Middlewares  []messaging.SubscriberMiddleware
+	Validator    messaging.Validator
+	PayloadStore messaging.PayloadStore
+	Codec        messaging.Codec
+	Quarantine   *messaging.Quarantine
+	PoolMetrics  *telemetry.MetricsRegistry
+}
+
+var _ messaging.Subscriber = (*Subscriber)(nil)
+
+// NewSubscriber creates a fake subscriber
+func NewSubscriber() *Subscriber {
+	return &Subscriber{}
+}
+
+// FailWith makes the subscribe methods return err, nil to succeed again
+func (s *Subscriber) FailWith(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// SetQueueDepth sets the value of QueueDepth
+func (s *Subscriber) SetQueueDepth(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.depth = n
+}
+
+// Subscriptions returns the active subscriptions in order
+func (s *Subscriber) Subscriptions() []*Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Subscription{}, s.subs...)
+}
+
+// Subscription returns the first active subscription on pattern
+func (s *Subscriber) Subscription(pattern string) (*Subscription, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sub := range s.subs {
+		if sub.Pattern == pattern {
+			return sub, true
+		}
+	}
+	return nil, false
+}
+
+// Closed reports whether Close was called
+func (s *Subscriber) Closed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// Deliver calls the handlers of the subscriptions matching subject with env,
+// in order, and returns the first error. Middlewares are not applied.
+func (s *Subscriber) Deliver(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+	var handlers []messaging.HandlerFunc
+	s.mu.Lock()
+	for _, sub := range s.subs {
+		if messaging.MatchSubject(sub.Pattern, subject) {
+			handlers = append(handlers, sub.Handler)
+		}
+	}
+	s.mu.Unlock()
+	if len(handlers) == 0 {
+		return fmt.Errorf("%w: %s", ErrNoSubscription, subject)
+	}
+	for _, h := range handlers {
+		if err := h(ctx, subject, env); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Subscriber) add(sub *Subscription) (*Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	sub.sub = s
+	s.subs = append(s.subs, sub)
+	return sub, nil
+}
+
+func (s *Subscriber) remove(match func(*Subscription) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.subs[:0]
+	for _, sub := range s.subs {
+		if !match(sub) {
+			kept = append(kept, sub)
+		}
+	}
+	s.subs = kept
+}
+
+// Subscribe records the subscription
+func (s *Subscriber) Subscribe(subject string, handler messaging.HandlerFunc, opts *messaging.SubscribeOptions) error {
+	_, err := s.SubscribeSubject(subject, handler, opts)
+	return err
+}
+
+// SubscribeSubject records the subscription and returns it
+func (s *Subscriber) SubscribeSubject(subject string, handler messaging.HandlerFunc, opts *messaging.SubscribeOptions) (messaging.Subscription, error) {
+	sub, err := s.add(&Subscription{Pattern: subject, Kind: KindCore, Handler: handler, Options: opts})
+	if err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// SubscribePush records the JetStream push subscription
+func (s *Subscriber) SubscribePush(subject string, handler messaging.HandlerFunc, opts ...nats.SubOpt) error {
+	_, err := s.add(&Subscription{Pattern: subject, Kind: KindPush, Handler: handler})
+	return err
+}
+
+// SubscribePull records the JetStream pull subscription and returns it
+func (s *Subscriber) SubscribePull(subject, durable string, handler messaging.HandlerFunc, opts ...messaging.PullOption) (messaging.Subscription, error) {
+	sub, err := s.add(&Subscription{Pattern: subject, Kind: KindPull, Handler: handler, Durable: durable})
+	if err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// Unsubscribe removes every subscription
+func (s *Subscriber) Unsubscribe() error {
+	s.remove(func(*Subscription) bool { return true })
+	return nil
+}
+
+// UnsubscribeSubject removes the subscriptions on subject
+func (s *Subscriber) UnsubscribeSubject(subject string) error {
+	s.remove(func(sub *Subscription) bool { return sub.Pattern == subject })
+	return nil
+}
+
+// Close removes every subscription and marks the subscriber closed
+func (s *Subscriber) Close() error {
+	_ = s.Unsubscribe()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+// Use records the middlewares
+func (s *Subscriber) Use(mw ...messaging.SubscriberMiddleware) {
+	s.Middlewares = append(s.Middlewares, mw...)
+}
+
+func (s *Subscriber) SetValidator(v messaging.Validator)            { s.Validator = v }
+func (s *Subscriber) SetPayloadStore(p messaging.PayloadStore)      { s.PayloadStore = p }
+func (s *Subscriber) SetCodec(c messaging.Codec)                    { s.Codec = c }
+func (s *Subscriber) SetQuarantine(q *messaging.Quarantine)         { s.Quarantine = q }
+func (s *Subscriber) SetPoolMetrics(reg *telemetry.MetricsRegistry) { s.PoolMetrics = reg }
+
+// QueueDepth returns the depth set with SetQueueDepth
+func (s *Subscriber) QueueDepth() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.depth
+}
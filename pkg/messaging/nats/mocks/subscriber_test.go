@@ -0,0 +1,60 @@
+package mocks
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscriber_Deliver(t *testing.T) {
+	s := NewSubscriber()
+	var got []string
+	handler := func(name string) messaging.HandlerFunc {
+		return func(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+			got = append(got, name+":"+subject)
+			return nil
+		}
+	}
+	require.NoError(t, s.Subscribe("orders.>", handler("all"), &messaging.SubscribeOptions{QueueGroup: "workers"}))
+	sub, err := s.SubscribeSubject("orders.created", handler("created"), nil)
+	require.NoError(t, err)
+	_, err = s.SubscribePull("orders.*", "billing", handler("pull"))
+	require.NoError(t, err)
+
+	require.NoError(t, s.Deliver(context.Background(), "orders.created", &messaging.MessageEnvelope{}))
+	assert.Equal(t, []string{"all:orders.created", "created:orders.created", "pull:orders.created"}, got)
+
+	all, ok := s.Subscription("orders.>")
+	require.True(t, ok)
+	assert.Equal(t, "workers", all.QueueGroup())
+	pull, _ := s.Subscription("orders.*")
+	assert.Equal(t, KindPull, pull.Kind)
+	assert.Equal(t, "billing", pull.Durable)
+
+	require.NoError(t, sub.Unsubscribe())
+	require.NoError(t, s.UnsubscribeSubject("orders.*"))
+	assert.Len(t, s.Subscriptions(), 1)
+
+	err = s.Deliver(context.Background(), "payments.created", &messaging.MessageEnvelope{})
+	assert.ErrorIs(t, err, ErrNoSubscription)
+
+	require.NoError(t, s.Close())
+	assert.True(t, s.Closed())
+	assert.Empty(t, s.Subscriptions())
+}
+
+func TestSubscriber_Scripted(t *testing.T) {
+	s := NewSubscriber()
+	boom := errors.New("boom")
+	s.FailWith(boom)
+	assert.ErrorIs(t, s.Subscribe("orders.>", nil, nil), boom)
+	assert.Empty(t, s.Subscriptions())
+
+	s.SetQueueDepth(3)
+	assert.Equal(t, 3, s.QueueDepth())
+}
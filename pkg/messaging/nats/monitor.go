@@ -0,0 +1,229 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"grouter/pkg/telemetry"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// MonitorConfig configures the monitoring of JetStream streams and consumers
+type MonitorConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Interval between two polls of the stream and consumer infos
+	Interval time.Duration `mapstructure:"interval"`
+	// Streams limits the monitoring to these streams, empty for all
+	Streams []string `mapstructure:"streams"`
+	// MaxPending, MaxAckPending and MaxRedelivered are the lag thresholds of
+	// the durable consumers, zero for none. A consumer above one fails Check.
+	MaxPending     uint64 `mapstructure:"max_pending"`
+	MaxAckPending  int    `mapstructure:"max_ack_pending"`
+	MaxRedelivered int    `mapstructure:"max_redelivered"`
+}
+
+// StreamMonitor polls the JetStream streams and their durable consumers,
+// exporting their sizes and lag as gauges
+type StreamMonitor struct {
+	client *Client
+	cfg    MonitorConfig
+	logger *zap.Logger
+
+	streamMessages      *prometheus.GaugeVec
+	streamBytes         *prometheus.GaugeVec
+	streamLastSeq       *prometheus.GaugeVec
+	consumerPending     *prometheus.GaugeVec
+	consumerAck         *prometheus.GaugeVec
+	consumerRedelivered *prometheus.GaugeVec
+	consumerLagging     *prometheus.GaugeVec
+	stop                chan struct{}
+	done                chan struct{}
+	startOnce           sync.Once
+	stopOnce            sync.Once
+
+	mu      sync.Mutex
+	err     error
+	lagging []string
+}
+
+// NewStreamMonitor creates a monitor exporting to reg (nil uses the global
+// registry)
+func NewStreamMonitor(client *Client, cfg MonitorConfig, reg *telemetry.MetricsRegistry, logger *zap.Logger) *StreamMonitor {
+	stream := []string{"stream"}
+	consumer := []string{"stream", "consumer"}
+	return &StreamMonitor{
+		client: client,
+		cfg:    cfg,
+		logger: logger,
+		streamMessages: reg.GaugeVec(prometheus.GaugeOpts{
+			Name: "messaging_stream_messages",
+			Help: "Number of messages stored in the JetStream stream",
+		}, stream),
+		streamBytes: reg.GaugeVec(prometheus.GaugeOpts{
+			Name: "messaging_stream_bytes",
+			Help: "Number of bytes stored in the JetStream stream",
+		}, stream),
+		streamLastSeq: reg.GaugeVec(prometheus.GaugeOpts{
+			Name: "messaging_stream_last_sequence",
+			Help: "Sequence of the last message of the JetStream stream",
+		}, stream),
+		consumerPending: reg.GaugeVec(prometheus.GaugeOpts{
+			Name: "messaging_consumer_pending",
+			Help: "Number of messages of the stream not yet delivered to the consumer",
+		}, consumer),
+		consumerAck: reg.GaugeVec(prometheus.GaugeOpts{
+			Name: "messaging_consumer_ack_pending",
+			Help: "Number of messages delivered to the consumer and not yet acknowledged",
+		}, consumer),
+		consumerRedelivered: reg.GaugeVec(prometheus.GaugeOpts{
+			Name: "messaging_consumer_redelivered",
+			Help: "Number of messages of the consumer redelivered and not yet acknowledged",
+		}, consumer),
+		consumerLagging: reg.GaugeVec(prometheus.GaugeOpts{
+			Name: "messaging_consumer_lagging",
+			Help: "Whether the consumer is above one of its lag thresholds (1) or not (0)",
+		}, consumer),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// Start polls every interval (30s when unset) in the background, starting now
+func (m *StreamMonitor) Start() {
+	m.startOnce.Do(func() {
+		interval := m.cfg.Interval
+		if interval <= 0 {
+			interval = 30 * time.Second
+		}
+		go m.run(interval)
+	})
+}
+
+func (m *StreamMonitor) run(interval time.Duration) {
+	defer close(m.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		if err := m.Collect(ctx); err != nil {
+			m.logger.Warn("Failed to collect stream metrics", zap.Error(err))
+		}
+		cancel()
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop stops the background polling started by Start
+func (m *StreamMonitor) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.stop)
+		// Never started, nothing to wait for
+		m.startOnce.Do(func() { close(m.done) })
+		<-m.done
+	})
+}
+
+// Collect polls the streams and consumers once, updating the gauges and the
+// result of Check
+func (m *StreamMonitor) Collect(ctx context.Context) error {
+	lagging, err := m.collect(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.err = err
+	m.lagging = lagging
+	return err
+}
+
+func (m *StreamMonitor) collect(ctx context.Context) ([]string, error) {
+	js, err := m.client.JetStream()
+	if err != nil {
+		return nil, err
+	}
+	streams := m.cfg.Streams
+	if len(streams) == 0 {
+		for name := range js.StreamNames(nats.Context(ctx)) {
+			streams = append(streams, name)
+		}
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("failed to list streams: %w", ctx.Err())
+		}
+	}
+
+	// Removed streams and consumers must not keep their last values
+	for _, vec := range []*prometheus.GaugeVec{m.streamMessages, m.streamBytes, m.streamLastSeq,
+		m.consumerPending, m.consumerAck, m.consumerRedelivered, m.consumerLagging} {
+		vec.Reset()
+	}
+
+	var lagging []string
+	for _, stream := range streams {
+		info, err := js.StreamInfo(stream, nats.Context(ctx))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get stream %s: %w", stream, err)
+		}
+		m.streamMessages.WithLabelValues(stream).Set(float64(info.State.Msgs))
+		m.streamBytes.WithLabelValues(stream).Set(float64(info.State.Bytes))
+		m.streamLastSeq.WithLabelValues(stream).Set(float64(info.State.LastSeq))
+
+		for ci := range js.Consumers(stream, nats.Context(ctx)) {
+			// Ephemeral consumers come and go under generated names
+			if ci.Config.Durable == "" {
+				continue
+			}
+			m.consumerPending.WithLabelValues(stream, ci.Name).Set(float64(ci.NumPending))
+			m.consumerAck.WithLabelValues(stream, ci.Name).Set(float64(ci.NumAckPending))
+			m.consumerRedelivered.WithLabelValues(stream, ci.Name).Set(float64(ci.NumRedelivered))
+
+			reasons := m.lag(ci)
+			if len(reasons) == 0 {
+				m.consumerLagging.WithLabelValues(stream, ci.Name).Set(0)
+				continue
+			}
+			m.consumerLagging.WithLabelValues(stream, ci.Name).Set(1)
+			lagging = append(lagging, fmt.Sprintf("%s/%s (%s)", stream, ci.Name, strings.Join(reasons, ", ")))
+		}
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("failed to list consumers of %s: %w", stream, ctx.Err())
+		}
+	}
+	return lagging, nil
+}
+
+// lag returns the thresholds the consumer is above
+func (m *StreamMonitor) lag(ci *nats.ConsumerInfo) []string {
+	var reasons []string
+	if m.cfg.MaxPending > 0 && ci.NumPending > m.cfg.MaxPending {
+		reasons = append(reasons, fmt.Sprintf("%d pending", ci.NumPending))
+	}
+	if m.cfg.MaxAckPending > 0 && ci.NumAckPending > m.cfg.MaxAckPending {
+		reasons = append(reasons, fmt.Sprintf("%d ack pending", ci.NumAckPending))
+	}
+	if m.cfg.MaxRedelivered > 0 && ci.NumRedelivered > m.cfg.MaxRedelivered {
+		reasons = append(reasons, fmt.Sprintf("%d redelivered", ci.NumRedelivered))
+	}
+	return reasons
+}
+
+// Check fails when the last poll failed or found consumers above their lag
+// thresholds, for use as a health check
+func (m *StreamMonitor) Check() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	if len(m.lagging) > 0 {
+		return fmt.Errorf("consumers lagging: %s", strings.Join(m.lagging, "; "))
+	}
+	return nil
+}
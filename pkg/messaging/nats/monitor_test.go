@@ -0,0 +1,77 @@
+package nats
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"grouter/pkg/telemetry"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestStreamMonitor(t *testing.T) {
+	// ORDERS holds five messages, see runReplayServer
+	client, js := runReplayServer(t)
+	_, err := js.AddConsumer("ORDERS", &nats.ConsumerConfig{Durable: "billing", AckPolicy: nats.AckExplicitPolicy})
+	require.NoError(t, err)
+	sub, err := js.PullSubscribe("orders.>", "billing", nats.Bind("ORDERS", "billing"))
+	require.NoError(t, err)
+	msgs, err := sub.Fetch(2, nats.MaxWait(time.Second))
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	// Ephemeral consumers are not monitored
+	_, err = js.SubscribeSync("orders.>", nats.BindStream("ORDERS"))
+	require.NoError(t, err)
+
+	reg := telemetry.NewMetricsRegistry()
+	monitor := NewStreamMonitor(client, MonitorConfig{MaxPending: 2, MaxAckPending: 5}, reg, zap.NewNop())
+	require.NoError(t, monitor.Collect(context.Background()))
+
+	assert.Equal(t, float64(5), testutil.ToFloat64(monitor.streamMessages.WithLabelValues("ORDERS")))
+	assert.Equal(t, float64(5), testutil.ToFloat64(monitor.streamLastSeq.WithLabelValues("ORDERS")))
+	assert.Positive(t, testutil.ToFloat64(monitor.streamBytes.WithLabelValues("ORDERS")))
+	assert.Equal(t, float64(3), testutil.ToFloat64(monitor.consumerPending.WithLabelValues("ORDERS", "billing")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(monitor.consumerAck.WithLabelValues("ORDERS", "billing")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(monitor.consumerLagging.WithLabelValues("ORDERS", "billing")))
+	assert.Equal(t, 1, testutil.CollectAndCount(monitor.consumerPending), "only the durable consumer")
+
+	err = monitor.Check()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ORDERS/billing (3 pending)")
+
+	for _, msg := range msgs {
+		require.NoError(t, msg.Ack())
+	}
+	msgs, err = sub.Fetch(3, nats.MaxWait(time.Second))
+	require.NoError(t, err)
+	for _, msg := range msgs {
+		require.NoError(t, msg.AckSync())
+	}
+	require.NoError(t, monitor.Collect(context.Background()))
+	assert.NoError(t, monitor.Check(), "caught up")
+	assert.Equal(t, float64(0), testutil.ToFloat64(monitor.consumerLagging.WithLabelValues("ORDERS", "billing")))
+
+	require.NoError(t, js.DeleteConsumer("ORDERS", "billing"))
+	require.NoError(t, monitor.Collect(context.Background()))
+	assert.Equal(t, 0, testutil.CollectAndCount(monitor.consumerPending), "removed consumers are dropped")
+}
+
+func TestStreamMonitor_Errors(t *testing.T) {
+	client, _ := runReplayServer(t)
+	monitor := NewStreamMonitor(client, MonitorConfig{Streams: []string{"MISSING"}}, telemetry.NewMetricsRegistry(), zap.NewNop())
+	assert.NoError(t, monitor.Check(), "not polled yet")
+
+	monitor.Start()
+	require.Eventually(t, func() bool { return monitor.Check() != nil }, 2*time.Second, 10*time.Millisecond)
+	assert.ErrorIs(t, monitor.Check(), nats.ErrStreamNotFound)
+	monitor.Stop()
+	monitor.Stop()
+
+	// Stopping a monitor never started returns
+	NewStreamMonitor(client, MonitorConfig{}, telemetry.NewMetricsRegistry(), zap.NewNop()).Stop()
+}
@@ -0,0 +1,28 @@
+package nats
+
+import (
+	"github.com/nats-io/nats.go"
+)
+
+// MetadataPartitionKey is the metadata key holding the partition key of a
+// message: ordered subscriptions handle the messages of a key in order, see
+// SubscribeOptions.Ordered
+const MetadataPartitionKey = "partition_key"
+
+// HeaderPartitionKey is the NATS header carrying the MetadataPartitionKey of
+// the envelope, so that ordered pools pick a worker without decoding it
+const HeaderPartitionKey = "Grouter-Partition-Key"
+
+// partitionKey returns the partition key of msg, empty when it has none
+func partitionKey(msg *nats.Msg) string {
+	return msg.Header.Get(HeaderPartitionKey)
+}
+
+// partitionHeader returns the headers of a message carrying the partition
+// key, nil without one
+func partitionHeader(key string) nats.Header {
+	if key == "" {
+		return nil
+	}
+	return nats.Header{HeaderPartitionKey: []string{key}}
+}
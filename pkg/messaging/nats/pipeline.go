@@ -0,0 +1,222 @@
+package nats
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// publishPipeline holds the settings of a publisher shared by NATSPublisher
+// and the publishers of other drivers (see DriverPublisher): the envelopes
+// are created, validated, signed, compressed and encoded the same way
+// whatever the transport.
+type publishPipeline struct {
+	source            string
+	validator         Validator
+	signer            Signer
+	compressor        *Compressor
+	codec             Codec
+	middleware        []PublisherMiddleware
+	requestMiddleware []RequestMiddleware
+}
+
+// Use adds middleware to the publisher
+func (p *publishPipeline) Use(mw ...PublisherMiddleware) {
+	p.middleware = append(p.middleware, mw...)
+}
+
+// UseRequest adds middleware to the publisher for requests
+func (p *publishPipeline) UseRequest(mw ...RequestMiddleware) {
+	p.requestMiddleware = append(p.requestMiddleware, mw...)
+}
+
+// SetValidator sets the validator for the publisher
+func (p *publishPipeline) SetValidator(v Validator) {
+	p.validator = v
+}
+
+// SetSigner sets the signer applied to every outgoing envelope
+func (p *publishPipeline) SetSigner(s Signer) {
+	p.signer = s
+}
+
+// SetCompressor sets the compressor of the data of outgoing envelopes
+func (p *publishPipeline) SetCompressor(c *Compressor) {
+	p.compressor = c
+}
+
+// SetCodec sets the codec applied to the data of outgoing envelopes, after
+// compression
+func (p *publishPipeline) SetCodec(c Codec) {
+	p.codec = c
+}
+
+// publishChain returns publish wrapped in the middleware, the first one
+// outermost
+func (p *publishPipeline) publishChain(publish PublisherFunc) PublisherFunc {
+	for i := len(p.middleware) - 1; i >= 0; i-- {
+		publish = p.middleware[i](publish)
+	}
+	return publish
+}
+
+// requestChain returns request wrapped in the request middleware, the first
+// one outermost
+func (p *publishPipeline) requestChain(request RequestFunc) RequestFunc {
+	for i := len(p.requestMiddleware) - 1; i >= 0; i-- {
+		request = p.requestMiddleware[i](request)
+	}
+	return request
+}
+
+// newEnvelope marshals data, into buf when not nil, and returns its envelope
+// carrying the trace context, the tenant and the correlation of ctx
+func (p *publishPipeline) newEnvelope(ctx context.Context, msgType string, data interface{}, buf *bytes.Buffer) (MessageEnvelope, error) {
+	dataBytes, err := marshalJSON(data, buf)
+	if err != nil {
+		return MessageEnvelope{}, fmt.Errorf("failed to marshal data: %w", err)
+	}
+	envelope := MessageEnvelope{
+		ID:        uuid.New().String(),
+		Type:      msgType,
+		Timestamp: time.Now(),
+		Source:    p.source,
+		Data:      dataBytes,
+		Version:   EnvelopeVersion,
+		// Room for the trace context and the tenant
+		Metadata: make(map[string]string, 4),
+	}
+	injectContext(ctx, envelope.Metadata)
+	correlate(ctx, &envelope)
+	return envelope, nil
+}
+
+// validate validates the data of the envelope if a validator is set
+func (p *publishPipeline) validate(envelope *MessageEnvelope) error {
+	if p.validator == nil {
+		return nil
+	}
+	if err := p.validator.Validate(envelope.Type, envelope.Data); err != nil {
+		return fmt.Errorf("validation failed for type %s: %w", envelope.Type, err)
+	}
+	return nil
+}
+
+// applyOptions sets the expiry and the partition key of opts on envelope
+func applyOptions(envelope *MessageEnvelope, opts *PublishOptions) {
+	if opts == nil {
+		return
+	}
+	if opts.TTL > 0 {
+		envelope.ExpiresAt = envelope.Timestamp.Add(opts.TTL)
+	}
+	if opts.PartitionKey != "" {
+		envelope.Metadata[MetadataPartitionKey] = opts.PartitionKey
+	}
+}
+
+// sign signs the envelope if a signer is set
+func (p *publishPipeline) sign(envelope *MessageEnvelope) error {
+	if p.signer == nil {
+		return nil
+	}
+	if err := p.signer.Sign(envelope); err != nil {
+		return fmt.Errorf("failed to sign envelope: %w", err)
+	}
+	return nil
+}
+
+// encode compresses, then encodes the data of the envelope published on
+// subject
+func (p *publishPipeline) encode(subject string, envelope *MessageEnvelope) error {
+	if p.compressor != nil {
+		if err := p.compressor.Compress(envelope); err != nil {
+			return err
+		}
+	}
+	if p.codec != nil {
+		if err := p.codec.Encode(subject, envelope); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// subscribePipeline holds the settings of a subscriber shared by
+// NATSSubscriber and the subscribers of other drivers (see
+// DriverSubscriber): the envelopes are validated and handed to the handler
+// through the middleware the same way whatever the transport.
+type subscribePipeline struct {
+	validator  Validator
+	codec      Codec
+	middleware []SubscriberMiddleware
+}
+
+// Use adds middleware to the subscriber
+func (p *subscribePipeline) Use(mw ...SubscriberMiddleware) {
+	p.middleware = append(p.middleware, mw...)
+}
+
+// SetValidator sets the validator for the subscriber
+func (p *subscribePipeline) SetValidator(v Validator) {
+	p.validator = v
+}
+
+// SetCodec sets the codec that decodes the data of incoming envelopes, before
+// decompression
+func (p *subscribePipeline) SetCodec(c Codec) {
+	p.codec = c
+}
+
+// validate validates the data of the envelope received on subject if a
+// validator is set, logging its failure with fields
+func (p *subscribePipeline) validate(logger *zap.Logger, subject string, envelope *MessageEnvelope, fields ...zap.Field) error {
+	if p.validator == nil {
+		return nil
+	}
+	err := p.validator.Validate(envelope.Type, envelope.Data)
+	if err != nil {
+		logger.Error("Validation failed", append([]zap.Field{
+			zap.Error(err),
+			zap.String("subject", subject),
+			zap.String("type", envelope.Type),
+			zap.String("id", envelope.ID),
+		}, fields...)...)
+	}
+	return err
+}
+
+// chain returns handler wrapped in the middleware, the first one outermost
+func (p *subscribePipeline) chain(handler HandlerFunc) HandlerFunc {
+	for i := len(p.middleware) - 1; i >= 0; i-- {
+		handler = p.middleware[i](handler)
+	}
+	return handler
+}
+
+// handle passes the envelope received on subject to handler, logging its
+// reception and the failure of the handler with fields
+func handle(ctx context.Context, logger *zap.Logger, subject string, envelope *MessageEnvelope, handler HandlerFunc, fields ...zap.Field) error {
+	if ce := logger.Check(zap.DebugLevel, "Received message"); ce != nil {
+		ce.Write(append([]zap.Field{
+			zap.String("subject", subject),
+			zap.String("type", envelope.Type),
+			zap.String("id", envelope.ID),
+			zap.String("reply", envelope.Reply),
+		}, fields...)...)
+	}
+
+	err := handler(ctx, subject, envelope)
+	if err != nil {
+		logger.Error("Handler error", append([]zap.Field{
+			zap.Error(err),
+			zap.String("subject", subject),
+			zap.String("message_id", envelope.ID),
+		}, fields...)...)
+	}
+	return err
+}
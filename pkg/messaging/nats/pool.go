@@ -0,0 +1,182 @@
+package nats
+
+import (
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"grouter/pkg/telemetry"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// poolMetrics are the utilization gauges of the worker pools, by subject
+type poolMetrics struct {
+	workers *prometheus.GaugeVec
+	busy    *prometheus.GaugeVec
+	queued  *prometheus.GaugeVec
+}
+
+func newPoolMetrics(reg *telemetry.MetricsRegistry) *poolMetrics {
+	labels := []string{"subject"}
+	return &poolMetrics{
+		workers: reg.GaugeVec(prometheus.GaugeOpts{
+			Name: "messaging_worker_pool_workers",
+			Help: "Number of workers of the subscription worker pools",
+		}, labels),
+		busy: reg.GaugeVec(prometheus.GaugeOpts{
+			Name: "messaging_worker_pool_busy",
+			Help: "Number of workers of the subscription worker pools handling a message",
+		}, labels),
+		queued: reg.GaugeVec(prometheus.GaugeOpts{
+			Name: "messaging_worker_pool_queued",
+			Help: "Number of messages waiting for a worker of the subscription worker pools",
+		}, labels),
+	}
+}
+
+// workerPool hands the messages of a subscription to a fixed number of
+// workers through bounded queues. The delivery goroutine of the
+// subscription only waits when a queue is full, and other subscriptions
+// never wait on its handlers. Unordered pools share one queue of n messages
+// among the workers; ordered pools give every worker its own queue of one
+// message and hand the messages of a partition key to the same worker, so
+// that they are handled one at a time and in order. Stopping the pool stops
+// the deliveries; the workers handle the messages left in the queues first.
+type workerPool struct {
+	queues []chan *nats.Msg
+	done   chan struct{}
+	stop   func()
+	// next spreads the messages without partition key of ordered pools
+	next atomic.Uint32
+	// pending counts the messages queued or being handled
+	pending atomic.Int64
+
+	// closed stops deliveries once the pool stops, so that the queues can be
+	// closed
+	mu     sync.RWMutex
+	closed bool
+
+	workers, busy, queued prometheus.Gauge
+}
+
+// newWorkerPool starts n workers running handle on the messages of subject,
+// ordered by partition key when ordered. metrics may be nil.
+func newWorkerPool(subject string, n int, ordered bool, metrics *poolMetrics, handle func(*nats.Msg)) *workerPool {
+	p := &workerPool{
+		queues: []chan *nats.Msg{make(chan *nats.Msg, n)},
+		done:   make(chan struct{}),
+	}
+	if ordered {
+		p.queues = make([]chan *nats.Msg, n)
+		for i := range p.queues {
+			p.queues[i] = make(chan *nats.Msg, 1)
+		}
+	}
+	if metrics != nil {
+		p.workers = metrics.workers.WithLabelValues(subject)
+		p.busy = metrics.busy.WithLabelValues(subject)
+		p.queued = metrics.queued.WithLabelValues(subject)
+		p.workers.Add(float64(n))
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := range n {
+		queue := p.queues[i%len(p.queues)]
+		go func() {
+			defer wg.Done()
+			for msg := range queue {
+				p.add(p.queued, -1)
+				p.add(p.busy, 1)
+				handle(msg)
+				p.add(p.busy, -1)
+				p.pending.Add(-1)
+			}
+		}()
+	}
+
+	p.stop = sync.OnceFunc(func() {
+		// done releases the deliveries waiting for room, so that the
+		// queues can be closed
+		close(p.done)
+		p.mu.Lock()
+		p.closed = true
+		for _, queue := range p.queues {
+			close(queue)
+		}
+		p.mu.Unlock()
+
+		stopped := make(chan struct{})
+		go func() {
+			wg.Wait()
+			p.add(p.workers, -float64(n))
+			close(stopped)
+		}()
+		// Bounded, so that a handler stopping its own subscription does not
+		// wait for itself forever
+		select {
+		case <-stopped:
+		case <-time.After(poolStopTimeout):
+		}
+	})
+	return p
+}
+
+// poolStopTimeout bounds the wait of a stopping pool for its workers
+const poolStopTimeout = 5 * time.Second
+
+// deliver queues msg, waiting for room unless the pool stops. It is the
+// handler of the subscription.
+func (p *workerPool) deliver(msg *nats.Msg) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return
+	}
+	// Counted before queuing, so that a worker never sees it uncounted
+	p.pending.Add(1)
+	select {
+	case p.queue(msg) <- msg:
+		p.add(p.queued, 1)
+	case <-p.done:
+		p.pending.Add(-1)
+	}
+}
+
+// queue returns the queue of msg: the queue of the worker of its partition
+// key in ordered pools, the next queue for messages without one
+func (p *workerPool) queue(msg *nats.Msg) chan *nats.Msg {
+	if len(p.queues) == 1 {
+		return p.queues[0]
+	}
+	if key := partitionKey(msg); key != "" {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(key))
+		return p.queues[h.Sum32()%uint32(len(p.queues))]
+	}
+	return p.queues[p.next.Add(1)%uint32(len(p.queues))]
+}
+
+// depth returns the number of queued messages
+func (p *workerPool) depth() int {
+	n := 0
+	for _, queue := range p.queues {
+		n += len(queue)
+	}
+	return n
+}
+
+// idle reports whether no message is queued or being handled
+func (p *workerPool) idle() bool {
+	return p.pending.Load() == 0
+}
+
+// add adds delta to gauge, without metrics too
+func (p *workerPool) add(gauge prometheus.Gauge, delta float64) {
+	if gauge != nil {
+		gauge.Add(delta)
+	}
+}
@@ -0,0 +1,206 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"grouter/pkg/telemetry"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// runPoolServer runs a core NATS server and returns a client connected to it
+func runPoolServer(t *testing.T) *Client {
+	t.Helper()
+	s, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1, NoLog: true, NoSigs: true})
+	require.NoError(t, err)
+	go s.Start()
+	require.True(t, s.ReadyForConnections(5*time.Second))
+	t.Cleanup(s.Shutdown)
+
+	client, err := NewNATSClient(Config{URL: s.ClientURL(), ConnectionTimeout: time.Second}, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, client.Connect())
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func TestSubscriber_WorkerPool(t *testing.T) {
+	client := runPoolServer(t)
+	reg := telemetry.NewMetricsRegistry()
+	subscriber := NewSubscriber(client, "test-subscriber")
+	subscriber.SetPoolMetrics(reg)
+	metrics := subscriber.(*NATSSubscriber).poolMetrics
+	publisher := NewPublisher(client, "test-service")
+
+	release := make(chan struct{})
+	started := make(chan struct{}, 10)
+	slow := func(ctx context.Context, subject string, msg *MessageEnvelope) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}
+	fast := make(chan struct{}, 10)
+	sub, err := subscriber.SubscribeSubject("jobs.slow", slow, &SubscribeOptions{MaxWorkers: 3})
+	require.NoError(t, err)
+	require.NoError(t, subscriber.Subscribe("jobs.fast", func(ctx context.Context, subject string, msg *MessageEnvelope) error {
+		fast <- struct{}{}
+		return nil
+	}, nil))
+
+	// 3 handled at once, 3 queued and 1 held by the delivery goroutine
+	ctx := context.Background()
+	for range 7 {
+		require.NoError(t, publisher.Publish(ctx, "jobs.slow", "job", nil, nil))
+	}
+	for range 3 {
+		select {
+		case <-started:
+		case <-time.After(2 * time.Second):
+			t.Fatal("workers not handling messages concurrently")
+		}
+	}
+	require.NoError(t, publisher.Publish(ctx, "jobs.fast", "job", nil, nil))
+	select {
+	case <-fast:
+	case <-time.After(2 * time.Second):
+		t.Fatal("slow handlers stall other subjects")
+	}
+
+	assert.Equal(t, 3.0, testutil.ToFloat64(metrics.workers.WithLabelValues("jobs.slow")))
+	assert.Equal(t, 3.0, testutil.ToFloat64(metrics.busy.WithLabelValues("jobs.slow")))
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(metrics.queued.WithLabelValues("jobs.slow")) == 3
+	}, 2*time.Second, 10*time.Millisecond)
+
+	close(release)
+	for range 4 {
+		select {
+		case <-started:
+		case <-time.After(2 * time.Second):
+			t.Fatal("queued messages not handled")
+		}
+	}
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(metrics.busy.WithLabelValues("jobs.slow")) == 0
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.queued.WithLabelValues("jobs.slow")))
+
+	// Unsubscribing stops the workers
+	require.NoError(t, sub.Unsubscribe())
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(metrics.workers.WithLabelValues("jobs.slow")) == 0
+	}, 2*time.Second, 10*time.Millisecond)
+	require.NoError(t, subscriber.Close())
+}
+
+func TestWorkerPool_StopHandlesQueued(t *testing.T) {
+	metrics := newPoolMetrics(telemetry.NewMetricsRegistry())
+	release := make(chan struct{})
+	handled := make(chan struct{}, 10)
+	pool := newWorkerPool("jobs", 1, false, metrics, func(msg *nats.Msg) {
+		<-release
+		handled <- struct{}{}
+	})
+
+	pool.deliver(&nats.Msg{Subject: "jobs"})
+	pool.deliver(&nats.Msg{Subject: "jobs"})
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(metrics.busy.WithLabelValues("jobs")) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	stopped := make(chan struct{})
+	go func() {
+		pool.stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		t.Fatal("stop returned with a busy worker")
+	case <-time.After(50 * time.Millisecond):
+	}
+	// Deliveries after stop return at once
+	pool.deliver(&nats.Msg{Subject: "jobs"})
+	close(release)
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("stop did not return once the workers were done")
+	}
+	assert.Len(t, handled, 2, "the queued message is handled before stop returns")
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.workers.WithLabelValues("jobs")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.queued.WithLabelValues("jobs")))
+}
+
+func TestSubscriber_OrderedWorkers(t *testing.T) {
+	client := runPoolServer(t)
+	subscriber := NewSubscriber(client, "test-subscriber")
+	publisher := NewPublisher(client, "test-service")
+	keys := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	const n = 20
+
+	var mu sync.Mutex
+	got := make(map[string][]int)
+	var active, maxActive atomic.Int32
+	done := make(chan struct{}, len(keys)*n)
+	require.NoError(t, subscriber.Subscribe("orders", func(ctx context.Context, subject string, msg *MessageEnvelope) error {
+		maxActive.Store(max(maxActive.Load(), active.Add(1)))
+		defer active.Add(-1)
+		var seq int
+		assert.NoError(t, json.Unmarshal(msg.Data, &seq))
+		time.Sleep(time.Duration(rand.IntN(2000)) * time.Microsecond)
+		mu.Lock()
+		got[msg.Metadata[MetadataPartitionKey]] = append(got[msg.Metadata[MetadataPartitionKey]], seq)
+		mu.Unlock()
+		done <- struct{}{}
+		return nil
+	}, &SubscribeOptions{MaxWorkers: 4, Ordered: true}))
+
+	ctx := context.Background()
+	for i := range n {
+		for _, key := range keys {
+			require.NoError(t, publisher.Publish(ctx, "orders", "order", i, &PublishOptions{PartitionKey: key}))
+		}
+	}
+	for range len(keys) * n {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("messages not handled")
+		}
+	}
+
+	for _, key := range keys {
+		require.Len(t, got[key], n)
+		assert.IsIncreasing(t, got[key], "the messages of key %s are handled in order", key)
+	}
+	assert.Greater(t, maxActive.Load(), int32(1), "keys are handled in parallel")
+	require.NoError(t, subscriber.Close())
+}
+
+func TestWorkerPool_Partitions(t *testing.T) {
+	pool := newWorkerPool("jobs", 4, true, nil, func(msg *nats.Msg) {})
+	defer pool.stop()
+
+	keyed := func(key string) *nats.Msg {
+		return &nats.Msg{Header: partitionHeader(key), Data: []byte("not decoded")}
+	}
+	assert.Equal(t, pool.queue(keyed("a")), pool.queue(keyed("a")), "a key has one worker")
+	queues := make(map[chan *nats.Msg]bool)
+	for range 4 {
+		// Only the header counts, not the metadata of the envelope
+		queues[pool.queue(&nats.Msg{Data: []byte(`{"metadata":{"partition_key":"a"}}`)})] = true
+	}
+	assert.Len(t, queues, 4, "messages without key are spread")
+}
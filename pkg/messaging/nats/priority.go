@@ -0,0 +1,123 @@
+package nats
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+// Priority is the lane of a published message
+type Priority int
+
+const (
+	// PriorityDefault publishes on the subject itself, handled by the normal
+	// lane of priority subscriptions
+	PriorityDefault Priority = iota
+	// PriorityHigh publishes on <subject>.p0
+	PriorityHigh
+	// PriorityNormal publishes on <subject>.p1
+	PriorityNormal
+	// PriorityLow publishes on <subject>.p2
+	PriorityLow
+)
+
+// lanes is the number of priority lanes
+const lanes = 3
+
+// LaneWeights are the shares of the workers of the high, normal and low lanes
+type LaneWeights [lanes]int
+
+// DefaultLaneWeights splits the workers 6:3:1
+var DefaultLaneWeights = LaneWeights{6, 3, 1}
+
+// LaneSubject returns the subject of the lane of p
+func LaneSubject(subject string, p Priority) string {
+	if p <= PriorityDefault || p > PriorityLow {
+		return subject
+	}
+	return fmt.Sprintf("%s.p%d", subject, p-PriorityHigh)
+}
+
+// workers splits n workers by weight, at least one per lane; the rounding
+// remainder goes to the high lane
+func (w LaneWeights) workers(n int) [lanes]int {
+	total := 0
+	for _, weight := range w {
+		total += weight
+	}
+	if n <= 0 {
+		n = total
+	}
+	var split [lanes]int
+	assigned := 0
+	for i, weight := range w {
+		split[i] = max(1, n*weight/total)
+		assigned += split[i]
+	}
+	if assigned < n {
+		split[0] += n - assigned
+	}
+	return split
+}
+
+// subscribeLanes subscribes handler to the priority lanes of subject, each
+// with its own worker pool, so that messages of a lane never wait behind
+// those of a lower one. Messages on the subject itself go to the normal lane.
+func (s *NATSSubscriber) subscribeLanes(subject string, handler HandlerFunc, opts *SubscribeOptions) (Subscription, error) {
+	if strings.HasSuffix(subject, ">") {
+		return nil, fmt.Errorf("failed to subscribe: priority lanes need a subject without '>', got %q", subject)
+	}
+	weights := opts.LaneWeights
+	if weights == (LaneWeights{}) {
+		weights = DefaultLaneWeights
+	}
+	for _, weight := range weights {
+		if weight < 0 {
+			return nil, fmt.Errorf("failed to subscribe: negative lane weight in %v", weights)
+		}
+	}
+	workers := weights.workers(opts.MaxWorkers)
+
+	var pools []*workerPool
+	var subs []*nats.Subscription
+	for lane := range lanes {
+		priority := PriorityHigh + Priority(lane)
+		// Each subscription delivers on its own goroutine, so a full lane
+		// only holds up its own messages
+		pool := s.newPool(LaneSubject(subject, priority), workers[lane], opts.Ordered, handler)
+		pools = append(pools, pool)
+
+		subjects := []string{LaneSubject(subject, priority)}
+		if priority == PriorityNormal {
+			subjects = append(subjects, subject)
+		}
+		for _, laneSubject := range subjects {
+			sub, err := s.subscribe(laneSubject, opts.QueueGroup, pool.deliver)
+			if err != nil {
+				for _, sub := range subs {
+					_ = sub.Unsubscribe()
+				}
+				for _, pool := range pools {
+					pool.stop()
+				}
+				return nil, err
+			}
+			subs = append(subs, sub)
+		}
+	}
+
+	handle := s.add(subject, subs...)
+	handle.pools = pools
+	if err := s.limit(handle, opts); err != nil {
+		_ = handle.Unsubscribe()
+		return nil, err
+	}
+	s.client.logger.Info("Subscribed to priority lanes",
+		zap.String("subject", subject),
+		zap.String("queue_group", opts.QueueGroup),
+		zap.Ints("workers", workers[:]),
+	)
+	return handle, nil
+}
@@ -0,0 +1,98 @@
+package nats
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestLaneSubject(t *testing.T) {
+	assert.Equal(t, "orders", LaneSubject("orders", PriorityDefault))
+	assert.Equal(t, "orders.p0", LaneSubject("orders", PriorityHigh))
+	assert.Equal(t, "orders.p1", LaneSubject("orders", PriorityNormal))
+	assert.Equal(t, "orders.p2", LaneSubject("orders", PriorityLow))
+	assert.Equal(t, "orders", LaneSubject("orders", Priority(9)))
+}
+
+func TestLaneWeights_Workers(t *testing.T) {
+	assert.Equal(t, [lanes]int{6, 3, 1}, DefaultLaneWeights.workers(0), "defaults to the sum of the weights")
+	assert.Equal(t, [lanes]int{12, 6, 2}, DefaultLaneWeights.workers(20))
+	assert.Equal(t, [lanes]int{2, 1, 1}, DefaultLaneWeights.workers(4), "the remainder goes to the high lane")
+	assert.Equal(t, [lanes]int{1, 1, 1}, DefaultLaneWeights.workers(2), "every lane gets a worker")
+	assert.Equal(t, [lanes]int{1, 1, 1}, LaneWeights{1, 1, 1}.workers(0))
+}
+
+func TestSubscriber_PriorityLanes(t *testing.T) {
+	s, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1, NoLog: true, NoSigs: true})
+	require.NoError(t, err)
+	go s.Start()
+	require.True(t, s.ReadyForConnections(5*time.Second))
+	defer s.Shutdown()
+
+	client, err := NewNATSClient(Config{URL: s.ClientURL(), ConnectionTimeout: time.Second}, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, client.Connect())
+	defer client.Close()
+
+	subscriber := NewSubscriber(client, "test-subscriber")
+	publisher := NewPublisher(client, "test-service")
+
+	// Bulk messages block their lane until released
+	release := make(chan struct{})
+	handled := make(chan string, 10)
+	_, err = subscriber.SubscribeSubject("jobs", func(ctx context.Context, subject string, msg *MessageEnvelope) error {
+		if msg.Type == "bulk" {
+			<-release
+		}
+		handled <- subject
+		return nil
+	}, &SubscribeOptions{Priority: true, MaxWorkers: 3})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for range 3 {
+		require.NoError(t, publisher.Publish(ctx, "jobs", "bulk", nil, &PublishOptions{Priority: PriorityLow}))
+	}
+	require.NoError(t, publisher.Publish(ctx, "jobs", "urgent", nil, &PublishOptions{Priority: PriorityHigh}))
+	require.NoError(t, publisher.Publish(ctx, "jobs", "plain", nil, nil))
+
+	got := map[string]bool{}
+	for range 2 {
+		select {
+		case subject := <-handled:
+			got[subject] = true
+		case <-time.After(2 * time.Second):
+			t.Fatalf("messages stuck behind the low lane, handled %v", got)
+		}
+	}
+	assert.Equal(t, map[string]bool{"jobs.p0": true, "jobs": true}, got, "the high and normal lanes do not wait for the low one")
+
+	close(release)
+	for range 3 {
+		select {
+		case subject := <-handled:
+			assert.Equal(t, "jobs.p2", subject)
+		case <-time.After(2 * time.Second):
+			t.Fatal("low lane not handled")
+		}
+	}
+
+	// The lanes are removed with their subject
+	require.NoError(t, subscriber.UnsubscribeSubject("jobs"))
+	require.NoError(t, publisher.Publish(ctx, "jobs", "urgent", nil, &PublishOptions{Priority: PriorityHigh}))
+	select {
+	case subject := <-handled:
+		t.Fatalf("message on %s after UnsubscribeSubject", subject)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	_, err = subscriber.SubscribeSubject("jobs.>", func(ctx context.Context, subject string, msg *MessageEnvelope) error {
+		return nil
+	}, &SubscribeOptions{Priority: true})
+	assert.Error(t, err, "lanes cannot follow '>'")
+}
@@ -1,12 +1,15 @@
 package nats
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"sync"
 	"time"
 
-	"github.com/google/uuid"
+	"grouter/pkg/tenant"
+
 	"github.com/nats-io/nats.go"
 	"go.opentelemetry.io/otel"
 	"go.uber.org/zap"
@@ -16,108 +19,168 @@ import (
 
 // NATSPublisher handles message publishing
 type NATSPublisher struct {
-	client            *Client
-	source            string
-	validator         Validator
-	middleware        []PublisherMiddleware
-	requestMiddleware []RequestMiddleware
+	publishPipeline
+	client   *Client
+	payloads PayloadStore
+	// skipFlush leaves sync publishes in the connection buffer
+	skipFlush bool
 }
 
 // NewPublisher creates a new publisher
 func NewPublisher(client *Client, source string) Publisher {
 	return &NATSPublisher{
-		client:            client,
-		source:            source,
-		middleware:        make([]PublisherMiddleware, 0),
-		requestMiddleware: make([]RequestMiddleware, 0),
+		publishPipeline: publishPipeline{source: source},
+		client:          client,
 	}
 }
 
-// Use adds middleware to the publisher
-func (p *NATSPublisher) Use(mw ...PublisherMiddleware) {
-	p.middleware = append(p.middleware, mw...)
+// SetPayloadStore sets the store of the envelopes above the server's max
+// payload, which are published as references instead
+func (p *NATSPublisher) SetPayloadStore(s PayloadStore) {
+	p.payloads = s
 }
 
-// UseRequest adds middleware to the publisher for requests
-func (p *NATSPublisher) UseRequest(mw ...RequestMiddleware) {
-	p.requestMiddleware = append(p.requestMiddleware, mw...)
+// SetSkipFlush makes sync publishes return once the message is in the
+// connection buffer, like async ones, instead of flushing the connection
+// after every message. The buffer is then sent by the background flusher of
+// the connection, and errors are only reported asynchronously.
+func (p *NATSPublisher) SetSkipFlush(skip bool) {
+	p.skipFlush = skip
 }
 
-// SetValidator sets the validator for the publisher
-func (p *NATSPublisher) SetValidator(v Validator) {
-	p.validator = v
+const (
+	// envelopeBufferSize is the initial capacity of the marshal buffers,
+	// enough for the envelope of a small message
+	envelopeBufferSize = 1024
+	// maxPooledBuffer keeps the buffers grown by large messages out of the
+	// pool
+	maxPooledBuffer = 64 * 1024
+)
+
+// envelopeBuffers recycles the buffers that data and envelopes are marshaled
+// into
+var envelopeBuffers = sync.Pool{
+	New: func() any { return bytes.NewBuffer(make([]byte, 0, envelopeBufferSize)) },
 }
 
-// Publish publishes a message to a subject
-func (p *NATSPublisher) Publish(ctx context.Context, subject string, msgType string, data interface{}, opts *PublishOptions) error {
-	publishFunc := p.publish
+func getBuffer() *bytes.Buffer {
+	return envelopeBuffers.Get().(*bytes.Buffer)
+}
 
-	// Apply middleware in reverse order
-	for i := len(p.middleware) - 1; i >= 0; i-- {
-		publishFunc = p.middleware[i](publishFunc)
+// putBuffer returns buf to the pool; the bytes marshaled into it must no
+// longer be used
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBuffer {
+		return
 	}
+	buf.Reset()
+	envelopeBuffers.Put(buf)
+}
 
-	return publishFunc(ctx, subject, msgType, data, opts)
+// marshalJSON marshals v as json.Marshal does, into buf when not nil
+func marshalJSON(v interface{}, buf *bytes.Buffer) ([]byte, error) {
+	if buf == nil {
+		return json.Marshal(v)
+	}
+	if err := json.NewEncoder(buf).Encode(v); err != nil {
+		return nil, err
+	}
+	// Encode terminates the value with a newline
+	return buf.Bytes()[:buf.Len()-1], nil
 }
 
-func (p *NATSPublisher) publish(ctx context.Context, subject string, msgType string, data interface{}, opts *PublishOptions) error {
-	// Marshal data
-	dataBytes, err := json.Marshal(data)
+// marshal compresses, encodes and marshals the signed envelope, into buf when
+// not nil, spilling it to the payload store when it is too large for a NATS
+// message
+func (p *NATSPublisher) marshal(ctx context.Context, subject string, envelope *MessageEnvelope, buf *bytes.Buffer) ([]byte, error) {
+	if err := p.encode(subject, envelope); err != nil {
+		return nil, err
+	}
+	envelopeBytes, err := marshalJSON(envelope, buf)
 	if err != nil {
-		return fmt.Errorf("failed to marshal data: %w", err)
+		return nil, fmt.Errorf("failed to marshal envelope: %w", err)
+	}
+	if p.payloads == nil || int64(len(envelopeBytes)) <= p.client.Conn().MaxPayload() {
+		return envelopeBytes, nil
 	}
+	return spill(ctx, p.payloads, envelope, envelopeBytes)
+}
 
-	// Validate data if validator is set
-	if p.validator != nil {
-		if err := p.validator.Validate(msgType, dataBytes); err != nil {
-			return fmt.Errorf("validation failed for type %s: %w", msgType, err)
-		}
+// injectContext adds the trace context and the tenant of ctx to metadata
+func injectContext(ctx context.Context, metadata map[string]string) {
+	otel.GetTextMapPropagator().Inject(ctx, metadataCarrier(metadata))
+	if id := tenant.FromContext(ctx); id != "" {
+		metadata[MetadataTenantID] = id
 	}
+}
 
-	if !p.client.IsConnected() {
-		return fmt.Errorf("not connected to NATS")
+// Publish publishes a message to a subject
+func (p *NATSPublisher) Publish(ctx context.Context, subject string, msgType string, data interface{}, opts *PublishOptions) error {
+	if len(p.middleware) == 0 {
+		return p.publish(ctx, subject, msgType, data, opts)
 	}
+	return p.publishChain(p.publish)(ctx, subject, msgType, data, opts)
+}
+
+func (p *NATSPublisher) publish(ctx context.Context, subject string, msgType string, data interface{}, opts *PublishOptions) error {
+	if opts != nil {
+		subject = LaneSubject(subject, opts.Priority)
+	}
+
+	// The connection copies the message, so the buffers are reused once
+	// published
+	dataBuf, envelopeBuf := getBuffer(), getBuffer()
+	defer putBuffer(dataBuf)
+	defer putBuffer(envelopeBuf)
 
 	// Create envelope
-	envelope := MessageEnvelope{
-		ID:        uuid.New().String(),
-		Type:      msgType,
-		Timestamp: time.Now(),
-		Source:    p.source,
-		Data:      dataBytes,
-		Metadata:  make(map[string]string),
+	envelope, err := p.newEnvelope(ctx, msgType, data, dataBuf)
+	if err != nil {
+		return err
+	}
+	if err := p.validate(&envelope); err != nil {
+		return err
+	}
+
+	if !p.client.IsConnected() {
+		return fmt.Errorf("not connected to NATS")
 	}
 
-	// Inject trace context into metadata
-	otel.GetTextMapPropagator().Inject(ctx, metadataCarrier(envelope.Metadata))
+	applyOptions(&envelope, opts)
+
+	if err := p.sign(&envelope); err != nil {
+		return err
+	}
 
 	// Marshal envelope
-	envelopeBytes, err := json.Marshal(envelope)
+	envelopeBytes, err := p.marshal(ctx, subject, &envelope, envelopeBuf)
 	if err != nil {
-		return fmt.Errorf("failed to marshal envelope: %w", err)
+		return err
 	}
 
-	// Publish
-	if opts != nil && opts.Async {
-		// Async publish
-		if err := p.client.Conn().Publish(subject, envelopeBytes); err != nil {
-			return fmt.Errorf("failed to publish message: %w", err)
-		}
+	// Publish, flushing sync publishes. The partition key goes in a header for
+	// the ordered pools of the subscribers.
+	if key := envelope.Metadata[MetadataPartitionKey]; key != "" {
+		err = p.client.Conn().PublishMsg(&nats.Msg{Subject: subject, Data: envelopeBytes, Header: partitionHeader(key)})
 	} else {
-		// Sync publish with flush
-		if err := p.client.Conn().Publish(subject, envelopeBytes); err != nil {
-			return fmt.Errorf("failed to publish message: %w", err)
-		}
+		err = p.client.Conn().Publish(subject, envelopeBytes)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
+	if (opts == nil || !opts.Async) && !p.skipFlush {
 		if err := p.client.Conn().Flush(); err != nil {
 			return fmt.Errorf("failed to flush: %w", err)
 		}
 	}
 
-	p.client.logger.Debug("Published message",
-		zap.String("subject", subject),
-		zap.String("type", msgType),
-		zap.String("id", envelope.ID),
-	)
+	if ce := p.client.logger.Check(zap.DebugLevel, "Published message"); ce != nil {
+		ce.Write(
+			zap.String("subject", subject),
+			zap.String("type", msgType),
+			zap.String("id", envelope.ID),
+		)
+	}
 
 	return nil
 }
@@ -135,14 +198,10 @@ func (p *NATSPublisher) PublishError(ctx context.Context, subject string, errMsg
 
 // Request sends a request and waits for a response
 func (p *NATSPublisher) Request(ctx context.Context, subject string, msgType string, data interface{}, timeout time.Duration) (*MessageEnvelope, error) {
-	requestFunc := p.request
-
-	// Apply middleware in reverse order
-	for i := len(p.requestMiddleware) - 1; i >= 0; i-- {
-		requestFunc = p.requestMiddleware[i](requestFunc)
+	if len(p.requestMiddleware) == 0 {
+		return p.request(ctx, subject, msgType, data, timeout)
 	}
-
-	return requestFunc(ctx, subject, msgType, data, timeout)
+	return p.requestChain(p.request)(ctx, subject, msgType, data, timeout)
 }
 
 func (p *NATSPublisher) request(ctx context.Context, subject string, msgType string, data interface{}, timeout time.Duration) (*MessageEnvelope, error) {
@@ -150,29 +209,26 @@ func (p *NATSPublisher) request(ctx context.Context, subject string, msgType str
 		return nil, fmt.Errorf("not connected to NATS")
 	}
 
-	// Marshal data
-	dataBytes, err := json.Marshal(data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal data: %w", err)
-	}
+	dataBuf, envelopeBuf := getBuffer(), getBuffer()
+	defer putBuffer(dataBuf)
+	defer putBuffer(envelopeBuf)
 
 	// Create envelope
-	envelope := MessageEnvelope{
-		ID:        uuid.New().String(),
-		Type:      msgType,
-		Timestamp: time.Now(),
-		Source:    p.source,
-		Data:      dataBytes,
-		Metadata:  make(map[string]string),
+	envelope, err := p.newEnvelope(ctx, msgType, data, dataBuf)
+	if err != nil {
+		return nil, err
 	}
+	// Tell the responder how long we wait
+	setDeadline(ctx, envelope.Metadata, timeout)
 
-	// Inject trace context into metadata
-	otel.GetTextMapPropagator().Inject(ctx, metadataCarrier(envelope.Metadata))
+	if err := p.sign(&envelope); err != nil {
+		return nil, err
+	}
 
 	// Marshal envelope
-	envelopeBytes, err := json.Marshal(envelope)
+	envelopeBytes, err := p.marshal(ctx, subject, &envelope, envelopeBuf)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal envelope: %w", err)
+		return nil, err
 	}
 
 	// Send request with context support
@@ -221,32 +277,35 @@ func (p *NATSPublisher) request(ctx context.Context, subject string, msgType str
 
 	// Unmarshal response
 	var response MessageEnvelope
-	if err := json.Unmarshal(msg.Data, &response); err != nil {
+	if err := decodeEnvelope(msg.Data, "", p.payloads, p.codec, &response); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
 	}
 
-	p.client.logger.Debug("Request completed",
-		zap.String("subject", subject),
-		zap.String("request_id", envelope.ID),
-		zap.String("response_id", response.ID),
-	)
+	if ce := p.client.logger.Check(zap.DebugLevel, "Request completed"); ce != nil {
+		ce.Write(
+			zap.String("subject", subject),
+			zap.String("request_id", envelope.ID),
+			zap.String("response_id", response.ID),
+		)
+	}
 
 	return &response, nil
 }
 
 // PublishJS publishes a message to a JetStream subject
 func (p *NATSPublisher) PublishJS(ctx context.Context, subject string, msgType string, data interface{}, opts ...nats.PubOpt) (*nats.PubAck, error) {
-	// Marshal data
-	dataBytes, err := json.Marshal(data)
+	// Published once acknowledged, so the buffers are reused on return
+	dataBuf, envelopeBuf := getBuffer(), getBuffer()
+	defer putBuffer(dataBuf)
+	defer putBuffer(envelopeBuf)
+
+	// Create envelope
+	envelope, err := p.newEnvelope(ctx, msgType, data, dataBuf)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal data: %w", err)
+		return nil, err
 	}
-
-	// Validate data if validator is set
-	if p.validator != nil {
-		if err := p.validator.Validate(msgType, dataBytes); err != nil {
-			return nil, fmt.Errorf("validation failed for type %s: %w", msgType, err)
-		}
+	if err := p.validate(&envelope); err != nil {
+		return nil, err
 	}
 
 	js, err := p.client.JetStream()
@@ -254,23 +313,14 @@ func (p *NATSPublisher) PublishJS(ctx context.Context, subject string, msgType s
 		return nil, err
 	}
 
-	// Create envelope
-	envelope := MessageEnvelope{
-		ID:        uuid.New().String(),
-		Type:      msgType,
-		Timestamp: time.Now(),
-		Source:    p.source,
-		Data:      dataBytes,
-		Metadata:  make(map[string]string),
+	if err := p.sign(&envelope); err != nil {
+		return nil, err
 	}
 
-	// Inject trace context into metadata
-	otel.GetTextMapPropagator().Inject(ctx, metadataCarrier(envelope.Metadata))
-
 	// Marshal envelope
-	envelopeBytes, err := json.Marshal(envelope)
+	envelopeBytes, err := p.marshal(ctx, subject, &envelope, envelopeBuf)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal envelope: %w", err)
+		return nil, err
 	}
 
 	// Publish to JetStream with context
@@ -282,29 +332,28 @@ func (p *NATSPublisher) PublishJS(ctx context.Context, subject string, msgType s
 		return nil, fmt.Errorf("failed to publish to JetStream: %w", err)
 	}
 
-	p.client.logger.Debug("Published JetStream message",
-		zap.String("subject", subject),
-		zap.String("type", msgType),
-		zap.String("id", envelope.ID),
-		zap.Uint64("stream_seq", ack.Sequence),
-	)
+	if ce := p.client.logger.Check(zap.DebugLevel, "Published JetStream message"); ce != nil {
+		ce.Write(
+			zap.String("subject", subject),
+			zap.String("type", msgType),
+			zap.String("id", envelope.ID),
+			zap.Uint64("stream_seq", ack.Sequence),
+		)
+	}
 
 	return ack, nil
 }
 
 // PublishAsyncJS publishes a message to a JetStream subject asynchronously
 func (p *NATSPublisher) PublishAsyncJS(ctx context.Context, subject string, msgType string, data interface{}, opts ...nats.PubOpt) (nats.PubAckFuture, error) {
-	// Marshal data
-	dataBytes, err := json.Marshal(data)
+	// The future keeps the message until acknowledged, so the buffers are not
+	// pooled
+	envelope, err := p.newEnvelope(ctx, msgType, data, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal data: %w", err)
+		return nil, err
 	}
-
-	// Validate data if validator is set
-	if p.validator != nil {
-		if err := p.validator.Validate(msgType, dataBytes); err != nil {
-			return nil, fmt.Errorf("validation failed for type %s: %w", msgType, err)
-		}
+	if err := p.validate(&envelope); err != nil {
+		return nil, err
 	}
 
 	js, err := p.client.JetStream()
@@ -323,23 +372,14 @@ func (p *NATSPublisher) PublishAsyncJS(ctx context.Context, subject string, msgT
 	// )
 	// defer span.End()
 
-	// Create envelope
-	envelope := MessageEnvelope{
-		ID:        uuid.New().String(),
-		Type:      msgType,
-		Timestamp: time.Now(),
-		Source:    p.source,
-		Data:      dataBytes,
-		Metadata:  make(map[string]string),
+	if err := p.sign(&envelope); err != nil {
+		return nil, err
 	}
 
-	// Inject trace context into metadata
-	otel.GetTextMapPropagator().Inject(ctx, metadataCarrier(envelope.Metadata))
-
 	// Marshal envelope
-	envelopeBytes, err := json.Marshal(envelope)
+	envelopeBytes, err := p.marshal(ctx, subject, &envelope, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal envelope: %w", err)
+		return nil, err
 	}
 
 	// Publish to JetStream asynchronously
@@ -348,11 +388,13 @@ func (p *NATSPublisher) PublishAsyncJS(ctx context.Context, subject string, msgT
 		return nil, fmt.Errorf("failed to publish async to JetStream: %w", err)
 	}
 
-	p.client.logger.Debug("Published JetStream message asynchronously",
-		zap.String("subject", subject),
-		zap.String("type", msgType),
-		zap.String("id", envelope.ID),
-	)
+	if ce := p.client.logger.Check(zap.DebugLevel, "Published JetStream message asynchronously"); ce != nil {
+		ce.Write(
+			zap.String("subject", subject),
+			zap.String("type", msgType),
+			zap.String("id", envelope.ID),
+		)
+	}
 
 	return future, nil
 }
@@ -264,3 +264,68 @@ func TestPublisher_InvalidData(t *testing.T) {
 		t.Error("Publish() should return error for unmarshalable data")
 	}
 }
+
+func TestMarshalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+	}{
+		{"map", map[string]string{"key": "value"}},
+		{"html escaped", "<a href=\"x\">&</a>"},
+		{"raw message", json.RawMessage(`{"a":1}`)},
+		{"nil", nil},
+		{"envelope", MessageEnvelope{ID: "1", Type: "t", Data: json.RawMessage(`[1,2]`), Metadata: map[string]string{"k": "v"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := json.Marshal(tt.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			buf := getBuffer()
+			defer putBuffer(buf)
+			got, err := marshalJSON(tt.v, buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("marshalJSON() = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestPublisher_PooledBuffers(t *testing.T) {
+	client, _ := runReplayServer(t)
+	publisher := NewPublisher(client, "test-service")
+	publisher.SetSkipFlush(true)
+
+	received := make(chan *nats.Msg, 100)
+	sub, err := client.Conn().ChanSubscribe("test.pooled", received)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	// Reused buffers must not change the messages already published
+	for i := 0; i < 50; i++ {
+		if err := publisher.Publish(context.Background(), "test.pooled", "test.event", i, nil); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+	}
+	for i := 0; i < 50; i++ {
+		select {
+		case msg := <-received:
+			var env MessageEnvelope
+			if err := json.Unmarshal(msg.Data, &env); err != nil {
+				t.Fatalf("invalid envelope: %v", err)
+			}
+			var n int
+			if err := json.Unmarshal(env.Data, &n); err != nil || n != i {
+				t.Errorf("message %d has data %s", i, env.Data)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("message %d not received without flush", i)
+		}
+	}
+}
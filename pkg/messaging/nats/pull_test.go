@@ -67,7 +67,7 @@ func TestSubscriber_Pull_Integration(t *testing.T) {
 
 	// Subscribe using Pull Consumer
 	received := make(chan int, 5)
-	err = subscriber.SubscribePull("test.pull.event", "test-durable", func(ctx context.Context, subject string, msg *MessageEnvelope) error {
+	_, err = subscriber.SubscribePull("test.pull.event", "test-durable", func(ctx context.Context, subject string, msg *MessageEnvelope) error {
 		var data map[string]int
 		if err := json.Unmarshal(msg.Data, &data); err != nil {
 			return err
@@ -0,0 +1,269 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"grouter/pkg/telemetry"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// ErrNotQuarantined is returned for sequences missing from the quarantine
+var ErrNotQuarantined = errors.New("message not quarantined")
+
+// QuarantineConfig configures the quarantine of poison messages: JetStream
+// messages failing delivery after delivery
+type QuarantineConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// MaxDeliveries is the delivery whose failure quarantines the message
+	// (5 when unset)
+	MaxDeliveries int `mapstructure:"max_deliveries"`
+	// Stream keeps the quarantined messages on the subjects under Prefix,
+	// created when missing ("QUARANTINE" and "quarantine" when unset)
+	Stream string `mapstructure:"stream"`
+	Prefix string `mapstructure:"prefix"`
+	// MaxAge removes the quarantined messages after this age, zero keeps them
+	MaxAge time.Duration `mapstructure:"max_age"`
+}
+
+// DeliveryError is the failure of one delivery of a message
+type DeliveryError struct {
+	Delivery uint64    `json:"delivery"`
+	Error    string    `json:"error"`
+	At       time.Time `json:"at"`
+}
+
+// QuarantinedMessage is a poison message with its error history
+type QuarantinedMessage struct {
+	// Seq is the sequence of the message in the quarantine stream
+	Seq uint64 `json:"seq"`
+	// Subject, Stream, Consumer and StreamSeq locate the original message
+	Subject   string `json:"subject"`
+	Stream    string `json:"stream"`
+	Consumer  string `json:"consumer"`
+	StreamSeq uint64 `json:"stream_seq"`
+	// Deliveries is the number of deliveries of the message; Errors are the
+	// failures seen by this process, the last one at least
+	Deliveries    uint64          `json:"deliveries"`
+	Errors        []DeliveryError `json:"errors"`
+	QuarantinedAt time.Time       `json:"quarantined_at"`
+	// Data is the message as received, requeued unchanged
+	Data []byte `json:"data"`
+}
+
+// maxTrackedMessages bounds the error histories kept in memory
+const maxTrackedMessages = 10000
+
+// Quarantine terminates the JetStream messages failing MaxDeliveries times
+// and keeps a copy of them, with their error history, in its stream until
+// they are requeued
+type Quarantine struct {
+	client      *Client
+	cfg         QuarantineConfig
+	logger      *zap.Logger
+	quarantined *prometheus.CounterVec
+
+	mu      sync.Mutex
+	history map[string][]DeliveryError
+}
+
+// NewQuarantine creates the quarantine of cfg, creating its stream when
+// missing, and counts the quarantined messages in reg (nil uses the global
+// registry)
+func NewQuarantine(client *Client, cfg QuarantineConfig, reg *telemetry.MetricsRegistry, logger *zap.Logger) (*Quarantine, error) {
+	if cfg.MaxDeliveries <= 0 {
+		cfg.MaxDeliveries = 5
+	}
+	if cfg.Stream == "" {
+		cfg.Stream = "QUARANTINE"
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = "quarantine"
+	}
+	js, err := client.JetStream()
+	if err != nil {
+		return nil, err
+	}
+	_, err = js.StreamInfo(cfg.Stream)
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		_, err = js.AddStream(&nats.StreamConfig{
+			Name:        cfg.Stream,
+			Description: "Poison messages and their error history",
+			Subjects:    []string{cfg.Prefix + ".>"},
+			MaxAge:      cfg.MaxAge,
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open quarantine stream %q: %w", cfg.Stream, err)
+	}
+	return &Quarantine{
+		client: client,
+		cfg:    cfg,
+		logger: logger,
+		quarantined: reg.CounterVec(prometheus.CounterOpts{
+			Name: "messaging_messages_quarantined_total",
+			Help: "Total number of poison messages terminated and quarantined",
+		}, []string{"subject"}),
+		history: make(map[string][]DeliveryError),
+	}, nil
+}
+
+// historyKey identifies a message for a consumer across its deliveries
+func historyKey(meta *nats.MsgMetadata) string {
+	return fmt.Sprintf("%s.%s.%d", meta.Stream, meta.Consumer, meta.Sequence.Stream)
+}
+
+// fail records the failure of a delivery of msg and, from its MaxDeliveries
+// delivery on, copies it to the quarantine. It returns whether msg was
+// quarantined, in which case it must be terminated.
+func (q *Quarantine) fail(msg *nats.Msg, cause error) (bool, error) {
+	meta, err := msg.Metadata()
+	if err != nil {
+		return false, fmt.Errorf("failed to read message metadata: %w", err)
+	}
+	key := historyKey(meta)
+
+	q.mu.Lock()
+	errs := append(q.history[key], DeliveryError{Delivery: meta.NumDelivered, Error: cause.Error(), At: time.Now()})
+	if meta.NumDelivered < uint64(q.cfg.MaxDeliveries) {
+		if _, ok := q.history[key]; !ok && len(q.history) >= maxTrackedMessages {
+			// Histories of messages settled elsewhere are never removed
+			for k := range q.history {
+				delete(q.history, k)
+				break
+			}
+		}
+		q.history[key] = errs
+		q.mu.Unlock()
+		return false, nil
+	}
+	q.mu.Unlock()
+
+	record := QuarantinedMessage{
+		Subject:       msg.Subject,
+		Stream:        meta.Stream,
+		Consumer:      meta.Consumer,
+		StreamSeq:     meta.Sequence.Stream,
+		Deliveries:    meta.NumDelivered,
+		Errors:        errs,
+		QuarantinedAt: time.Now(),
+		Data:          msg.Data,
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal quarantined message: %w", err)
+	}
+	js, err := q.client.JetStream()
+	if err != nil {
+		return false, err
+	}
+	// The ID drops the copy of a message quarantined again after a failed Term
+	if _, err := js.Publish(q.cfg.Prefix+"."+msg.Subject, data, nats.MsgId(key)); err != nil {
+		return false, fmt.Errorf("failed to quarantine message: %w", err)
+	}
+	q.forget(msg)
+	q.quarantined.WithLabelValues(msg.Subject).Inc()
+	q.logger.Warn("Poison message quarantined",
+		zap.String("subject", msg.Subject),
+		zap.String("stream", meta.Stream),
+		zap.Uint64("stream_seq", meta.Sequence.Stream),
+		zap.Uint64("deliveries", meta.NumDelivered),
+		zap.Error(cause),
+	)
+	return true, nil
+}
+
+// forget drops the error history of msg, once handled
+func (q *Quarantine) forget(msg *nats.Msg) {
+	meta, err := msg.Metadata()
+	if err != nil {
+		return
+	}
+	q.mu.Lock()
+	delete(q.history, historyKey(meta))
+	q.mu.Unlock()
+}
+
+// List returns up to limit quarantined messages (all when zero), the oldest
+// first
+func (q *Quarantine) List(ctx context.Context, limit int) ([]QuarantinedMessage, error) {
+	js, err := q.client.JetStream()
+	if err != nil {
+		return nil, err
+	}
+	info, err := js.StreamInfo(q.cfg.Stream, nats.Context(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quarantine stream: %w", err)
+	}
+	messages := make([]QuarantinedMessage, 0)
+	if info.State.Msgs == 0 {
+		return messages, nil
+	}
+	for seq := info.State.FirstSeq; seq <= info.State.LastSeq; seq++ {
+		if limit > 0 && len(messages) >= limit {
+			break
+		}
+		msg, err := q.Get(ctx, seq)
+		if errors.Is(err, ErrNotQuarantined) {
+			// Requeued
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+// Get returns the quarantined message seq
+func (q *Quarantine) Get(ctx context.Context, seq uint64) (QuarantinedMessage, error) {
+	var msg QuarantinedMessage
+	js, err := q.client.JetStream()
+	if err != nil {
+		return msg, err
+	}
+	raw, err := js.GetMsg(q.cfg.Stream, seq, nats.Context(ctx))
+	if errors.Is(err, nats.ErrMsgNotFound) {
+		return msg, fmt.Errorf("%w: %d", ErrNotQuarantined, seq)
+	}
+	if err != nil {
+		return msg, fmt.Errorf("failed to get quarantined message %d: %w", seq, err)
+	}
+	if err := json.Unmarshal(raw.Data, &msg); err != nil {
+		return msg, fmt.Errorf("failed to unmarshal quarantined message %d: %w", seq, err)
+	}
+	msg.Seq = seq
+	return msg, nil
+}
+
+// Requeue publishes the quarantined message seq again on its subject, for its
+// stream to deliver it anew, and removes it from the quarantine
+func (q *Quarantine) Requeue(ctx context.Context, seq uint64) (QuarantinedMessage, error) {
+	msg, err := q.Get(ctx, seq)
+	if err != nil {
+		return msg, err
+	}
+	js, err := q.client.JetStream()
+	if err != nil {
+		return msg, err
+	}
+	if _, err := js.Publish(msg.Subject, msg.Data, nats.Context(ctx)); err != nil {
+		return msg, fmt.Errorf("failed to requeue message %d: %w", seq, err)
+	}
+	if err := js.DeleteMsg(q.cfg.Stream, seq, nats.Context(ctx)); err != nil {
+		return msg, fmt.Errorf("failed to remove requeued message %d: %w", seq, err)
+	}
+	q.logger.Info("Quarantined message requeued",
+		zap.String("subject", msg.Subject),
+		zap.Uint64("seq", seq),
+	)
+	return msg, nil
+}
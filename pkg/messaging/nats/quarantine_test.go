@@ -0,0 +1,137 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"grouter/pkg/telemetry"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestQuarantine_PoisonMessage(t *testing.T) {
+	client, js := runReplayServer(t)
+	quarantine, err := NewQuarantine(client, QuarantineConfig{MaxDeliveries: 3}, telemetry.NewMetricsRegistry(), zap.NewNop())
+	require.NoError(t, err)
+	subscriber := NewSubscriber(client, "test-service")
+	subscriber.SetQuarantine(quarantine)
+	t.Cleanup(func() { _ = subscriber.Close() })
+
+	var fixed atomic.Bool
+	var deliveries atomic.Int32
+	handler := func(ctx context.Context, subject string, msg *MessageEnvelope) error {
+		var n int
+		require.NoError(t, json.Unmarshal(msg.Data, &n))
+		if n == 3 {
+			deliveries.Add(1)
+		}
+		return errIf(n == 3 && !fixed.Load())
+	}
+	require.NoError(t, subscriber.SubscribePush("orders.created", handler, nats.Durable("orders")))
+
+	var list []QuarantinedMessage
+	require.Eventually(t, func() bool {
+		list, err = quarantine.List(context.Background(), 0)
+		return err == nil && len(list) == 1
+	}, 5*time.Second, 50*time.Millisecond)
+	got := list[0]
+	assert.Equal(t, "orders.created", got.Subject)
+	assert.Equal(t, "ORDERS", got.Stream)
+	assert.Equal(t, "orders", got.Consumer)
+	assert.Equal(t, uint64(3), got.StreamSeq)
+	assert.Equal(t, uint64(3), got.Deliveries)
+	require.Len(t, got.Errors, 3, "error history of every delivery")
+	assert.Equal(t, uint64(1), got.Errors[0].Delivery)
+	assert.Equal(t, ErrHandlerFailed.Error(), got.Errors[2].Error)
+	var env MessageEnvelope
+	require.NoError(t, json.Unmarshal(got.Data, &env))
+	assert.Equal(t, "3", string(env.Data), "message kept as received")
+
+	// Terminated: no fourth delivery
+	time.Sleep(300 * time.Millisecond)
+	assert.Equal(t, int32(3), deliveries.Load())
+	assert.Equal(t, 1.0, testutil.ToFloat64(quarantine.quarantined.WithLabelValues("orders.created")))
+
+	one, err := quarantine.Get(context.Background(), got.Seq)
+	require.NoError(t, err)
+	assert.Equal(t, got, one)
+
+	// Requeued once the handler is fixed, the message is handled again
+	fixed.Store(true)
+	_, err = quarantine.Requeue(context.Background(), got.Seq)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return deliveries.Load() == 4 }, 2*time.Second, 20*time.Millisecond)
+
+	list, err = quarantine.List(context.Background(), 0)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+	_, err = quarantine.Get(context.Background(), got.Seq)
+	assert.ErrorIs(t, err, ErrNotQuarantined)
+	_, err = quarantine.Requeue(context.Background(), got.Seq)
+	assert.ErrorIs(t, err, ErrNotQuarantined)
+
+	info, err := js.StreamInfo("ORDERS")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(6), info.State.Msgs, "requeued message stored in its stream")
+}
+
+func TestQuarantine_Undecodable(t *testing.T) {
+	client, js := runReplayServer(t)
+	quarantine, err := NewQuarantine(client, QuarantineConfig{MaxDeliveries: 2, Stream: "POISON", Prefix: "poison"}, telemetry.NewMetricsRegistry(), zap.NewNop())
+	require.NoError(t, err)
+	subscriber := NewSubscriber(client, "test-service")
+	subscriber.SetQuarantine(quarantine)
+	t.Cleanup(func() { _ = subscriber.Close() })
+
+	handler := func(ctx context.Context, subject string, msg *MessageEnvelope) error { return nil }
+	require.NoError(t, subscriber.SubscribePush("orders.broken", handler, nats.Durable("broken"), nats.AckWait(100*time.Millisecond)))
+	_, err = js.Publish("orders.broken", []byte("not json"))
+	require.NoError(t, err)
+
+	var list []QuarantinedMessage
+	require.Eventually(t, func() bool {
+		list, err = quarantine.List(context.Background(), 0)
+		return err == nil && len(list) == 1
+	}, 5*time.Second, 50*time.Millisecond)
+	assert.Equal(t, []byte("not json"), list[0].Data)
+	assert.Len(t, list[0].Errors, 2)
+
+	info, err := js.StreamInfo("POISON")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"poison.>"}, info.Config.Subjects)
+}
+
+func TestQuarantine_ManualAckLeftAlone(t *testing.T) {
+	client, _ := runReplayServer(t)
+	quarantine, err := NewQuarantine(client, QuarantineConfig{MaxDeliveries: 1}, telemetry.NewMetricsRegistry(), zap.NewNop())
+	require.NoError(t, err)
+	subscriber := NewSubscriber(client, "test-service")
+	subscriber.SetQuarantine(quarantine)
+	t.Cleanup(func() { _ = subscriber.Close() })
+
+	handled := make(chan struct{}, 10)
+	handler := WithAckPolicy(AckManual, func(ctx context.Context, subject string, msg *MessageEnvelope) error {
+		handled <- struct{}{}
+		_ = AckerFromContext(ctx).Ack()
+		return ErrHandlerFailed
+	})
+	require.NoError(t, subscriber.SubscribePush("orders.deleted", handler))
+	for range 2 {
+		select {
+		case <-handled:
+		case <-time.After(2 * time.Second):
+			t.Fatal("message not handled")
+		}
+	}
+
+	list, err := quarantine.List(context.Background(), 0)
+	require.NoError(t, err)
+	assert.Empty(t, list, "handlers settling their messages keep them out of the quarantine")
+}
@@ -0,0 +1,207 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+// ReplayOptions selects the messages of a stream to replay and where they go
+type ReplayOptions struct {
+	// Stream is the JetStream stream to read
+	Stream string `json:"stream"`
+	// Subject filters the messages of the stream (wildcards allowed), empty
+	// for all
+	Subject string `json:"subject,omitempty"`
+	// StartSeq and EndSeq bound the stream sequences, zero for the first and
+	// last message. StartTime applies when StartSeq is zero.
+	StartSeq uint64 `json:"start_seq,omitempty"`
+	EndSeq   uint64 `json:"end_seq,omitempty"`
+	// StartTime and EndTime bound the times the messages were stored
+	StartTime time.Time `json:"start_time,omitzero"`
+	EndTime   time.Time `json:"end_time,omitzero"`
+	// Target republishes the messages, unchanged, on this subject instead of
+	// passing them to the handler
+	Target string `json:"target,omitempty"`
+	// Rate limits the messages replayed per second, zero is unlimited
+	Rate float64 `json:"rate,omitempty"`
+	// DryRun counts the selected messages without replaying them
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// ReplayProgress counts the messages of a replay
+type ReplayProgress struct {
+	// Matched are the messages selected so far, Replayed and Failed those
+	// handled or republished, with or without error
+	Matched  uint64 `json:"matched"`
+	Replayed uint64 `json:"replayed"`
+	Failed   uint64 `json:"failed"`
+	// LastSeq is the stream sequence of the last selected message
+	LastSeq uint64 `json:"last_seq"`
+	// Pending are the messages of the stream after LastSeq, a bound of those
+	// left to replay
+	Pending uint64 `json:"pending"`
+}
+
+// Replayer replays the messages stored in JetStream streams, e.g. to recover
+// from an incident once the failing handler is fixed
+type Replayer struct {
+	client   *Client
+	payloads PayloadStore
+	codec    Codec
+	// progress, when set, is called after every selected message
+	progress func(ReplayProgress)
+}
+
+// NewReplayer creates a Replayer on the client
+func NewReplayer(client *Client) *Replayer {
+	return &Replayer{client: client}
+}
+
+// SetPayloadStore sets the store that reference envelopes are fetched from
+// before they are passed to handlers
+func (r *Replayer) SetPayloadStore(s PayloadStore) {
+	r.payloads = s
+}
+
+// SetCodec sets the codec that decodes the data of envelopes before they are
+// passed to handlers
+func (r *Replayer) SetCodec(c Codec) {
+	r.codec = c
+}
+
+// OnProgress sets a function called with the progress after every selected
+// message
+func (r *Replayer) OnProgress(fn func(ReplayProgress)) {
+	r.progress = fn
+}
+
+// Replay replays the selected messages into handler, or to opts.Target. The
+// stream is read up to its last message when the replay starts, so messages
+// stored meanwhile, including republished ones, are not replayed again.
+// Handler errors are counted and the replay goes on; it stops on the first
+// error to republish, or when ctx is done.
+func (r *Replayer) Replay(ctx context.Context, opts ReplayOptions, handler HandlerFunc) (ReplayProgress, error) {
+	var progress ReplayProgress
+	if opts.Stream == "" {
+		return progress, fmt.Errorf("replay requires a stream")
+	}
+	if opts.Target == "" && handler == nil && !opts.DryRun {
+		return progress, fmt.Errorf("replay requires a handler or a target subject")
+	}
+	js, err := r.client.JetStream()
+	if err != nil {
+		return progress, err
+	}
+	info, err := js.StreamInfo(opts.Stream, nats.Context(ctx))
+	if err != nil {
+		return progress, fmt.Errorf("failed to get stream %s: %w", opts.Stream, err)
+	}
+	last := info.State.LastSeq
+	if opts.EndSeq > 0 && opts.EndSeq < last {
+		last = opts.EndSeq
+	}
+
+	subOpts := []nats.SubOpt{nats.BindStream(opts.Stream), nats.OrderedConsumer()}
+	switch {
+	case opts.StartSeq > 0:
+		subOpts = append(subOpts, nats.StartSequence(opts.StartSeq))
+	case !opts.StartTime.IsZero():
+		subOpts = append(subOpts, nats.StartTime(opts.StartTime))
+	default:
+		subOpts = append(subOpts, nats.DeliverAll())
+	}
+	sub, err := js.SubscribeSync(opts.Subject, subOpts...)
+	if err != nil {
+		return progress, fmt.Errorf("failed to read stream %s: %w", opts.Stream, err)
+	}
+	defer func() { _ = sub.Unsubscribe() }()
+
+	// Nothing is delivered when no message matches, so return rather than wait
+	ci, err := sub.ConsumerInfo()
+	if err != nil {
+		return progress, fmt.Errorf("failed to read stream %s: %w", opts.Stream, err)
+	}
+	if ci.NumPending == 0 && ci.Delivered.Consumer == 0 {
+		return progress, nil
+	}
+
+	var limiter *rate.Limiter
+	if opts.Rate > 0 {
+		limiter = rate.NewLimiter(rate.Limit(opts.Rate), 1)
+	}
+
+	for {
+		msg, err := sub.NextMsgWithContext(ctx)
+		if err != nil {
+			return progress, err
+		}
+		meta, err := msg.Metadata()
+		if err != nil {
+			return progress, fmt.Errorf("failed to read message metadata: %w", err)
+		}
+		if meta.Sequence.Stream > last || (!opts.EndTime.IsZero() && meta.Timestamp.After(opts.EndTime)) {
+			return progress, r.flush(opts)
+		}
+
+		progress.Matched++
+		progress.LastSeq = meta.Sequence.Stream
+		progress.Pending = meta.NumPending
+		if !opts.DryRun {
+			if limiter != nil {
+				if err := limiter.Wait(ctx); err != nil {
+					return progress, err
+				}
+			}
+			if err := r.replay(msg, opts.Target, handler); err != nil {
+				if opts.Target != "" {
+					return progress, err
+				}
+				progress.Failed++
+				r.client.logger.Warn("Replayed message failed",
+					zap.String("stream", opts.Stream),
+					zap.Uint64("seq", meta.Sequence.Stream),
+					zap.Error(err),
+				)
+			} else {
+				progress.Replayed++
+			}
+		}
+		if r.progress != nil {
+			r.progress(progress)
+		}
+
+		if meta.NumPending == 0 || meta.Sequence.Stream == last {
+			return progress, r.flush(opts)
+		}
+	}
+}
+
+// replay republishes msg to target, or passes its envelope to handler
+func (r *Replayer) replay(msg *nats.Msg, target string, handler HandlerFunc) error {
+	if target != "" {
+		if err := r.client.Conn().Publish(target, msg.Data); err != nil {
+			return fmt.Errorf("failed to republish message: %w", err)
+		}
+		return nil
+	}
+	var envelope MessageEnvelope
+	if err := decodeEnvelope(msg.Data, msg.Subject, r.payloads, r.codec, &envelope); err != nil {
+		return fmt.Errorf("failed to decode message: %w", err)
+	}
+	return handler(extractContext(&envelope), msg.Subject, &envelope)
+}
+
+func (r *Replayer) flush(opts ReplayOptions) error {
+	if opts.Target == "" || opts.DryRun {
+		return nil
+	}
+	if err := r.client.Conn().Flush(); err != nil {
+		return fmt.Errorf("failed to flush: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// runReplayServer runs a JetStream server with an "ORDERS" stream holding
+// five messages on orders.created and orders.deleted alternately
+func runReplayServer(t *testing.T) (*Client, nats.JetStreamContext) {
+	t.Helper()
+	s, err := server.NewServer(&server.Options{
+		Host: "127.0.0.1", Port: -1, NoLog: true, NoSigs: true,
+		JetStream: true, StoreDir: t.TempDir(),
+	})
+	require.NoError(t, err)
+	go s.Start()
+	require.True(t, s.ReadyForConnections(5*time.Second))
+	t.Cleanup(s.Shutdown)
+
+	client, err := NewNATSClient(Config{URL: s.ClientURL(), ConnectionTimeout: time.Second}, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, client.Connect())
+	t.Cleanup(func() { _ = client.Close() })
+
+	js, err := client.JetStream()
+	require.NoError(t, err)
+	_, err = js.AddStream(&nats.StreamConfig{Name: "ORDERS", Subjects: []string{"orders.>"}, Storage: nats.MemoryStorage})
+	require.NoError(t, err)
+
+	publisher := NewPublisher(client, "test-service")
+	for i := 1; i <= 5; i++ {
+		subject := "orders.created"
+		if i%2 == 0 {
+			subject = "orders.deleted"
+		}
+		_, err := publisher.PublishJS(context.Background(), subject, "order", i)
+		require.NoError(t, err)
+	}
+	return client, js
+}
+
+func TestReplayer_Handler(t *testing.T) {
+	client, _ := runReplayServer(t)
+	replayer := NewReplayer(client)
+
+	var got []int
+	handler := func(ctx context.Context, subject string, msg *MessageEnvelope) error {
+		var n int
+		require.NoError(t, json.Unmarshal(msg.Data, &n))
+		got = append(got, n)
+		if n == 3 {
+			return fmt.Errorf("handler failed")
+		}
+		return nil
+	}
+
+	progress, err := replayer.Replay(context.Background(), ReplayOptions{Stream: "ORDERS"}, handler)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, got)
+	assert.Equal(t, ReplayProgress{Matched: 5, Replayed: 4, Failed: 1, LastSeq: 5}, progress, "handler errors are counted")
+
+	t.Run("subject and sequence range", func(t *testing.T) {
+		got = nil
+		opts := ReplayOptions{Stream: "ORDERS", Subject: "orders.created", StartSeq: 2, EndSeq: 4}
+		progress, err := replayer.Replay(context.Background(), opts, handler)
+		require.NoError(t, err)
+		assert.Equal(t, []int{3}, got)
+		assert.Equal(t, uint64(3), progress.LastSeq)
+	})
+
+	t.Run("empty range", func(t *testing.T) {
+		got = nil
+		progress, err := replayer.Replay(context.Background(), ReplayOptions{Stream: "ORDERS", StartSeq: 10}, handler)
+		require.NoError(t, err)
+		assert.Empty(t, got)
+		assert.Zero(t, progress.Matched)
+	})
+
+	t.Run("time range", func(t *testing.T) {
+		got = nil
+		opts := ReplayOptions{Stream: "ORDERS", EndTime: time.Now().Add(-time.Hour)}
+		progress, err := replayer.Replay(context.Background(), opts, handler)
+		require.NoError(t, err)
+		assert.Empty(t, got, "every message was stored after the end time")
+		assert.Zero(t, progress.Matched)
+	})
+}
+
+func TestReplayer_Target(t *testing.T) {
+	client, js := runReplayServer(t)
+	replayer := NewReplayer(client)
+
+	replayed := make(chan *nats.Msg, 10)
+	sub, err := client.Conn().ChanSubscribe("orders.replayed", replayed)
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+
+	var updates []ReplayProgress
+	replayer.OnProgress(func(p ReplayProgress) { updates = append(updates, p) })
+	progress, err := replayer.Replay(context.Background(), ReplayOptions{Stream: "ORDERS", Subject: "orders.deleted", Target: "orders.replayed"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), progress.Replayed)
+	require.Len(t, updates, 2)
+	assert.Equal(t, uint64(1), updates[0].Replayed)
+
+	for _, want := range []int{2, 4} {
+		select {
+		case msg := <-replayed:
+			var env MessageEnvelope
+			require.NoError(t, json.Unmarshal(msg.Data, &env))
+			assert.Equal(t, fmt.Sprint(want), string(env.Data), "messages are republished unchanged")
+		case <-time.After(2 * time.Second):
+			t.Fatal("replayed message not received")
+		}
+	}
+
+	info, err := js.StreamInfo("ORDERS")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(7), info.State.Msgs, "replayed messages stored in the stream")
+}
+
+func TestReplayer_DryRunAndRate(t *testing.T) {
+	client, _ := runReplayServer(t)
+	replayer := NewReplayer(client)
+
+	called := false
+	handler := func(ctx context.Context, subject string, msg *MessageEnvelope) error {
+		called = true
+		return nil
+	}
+	progress, err := replayer.Replay(context.Background(), ReplayOptions{Stream: "ORDERS", DryRun: true}, handler)
+	require.NoError(t, err)
+	assert.False(t, called)
+	assert.Equal(t, ReplayProgress{Matched: 5, LastSeq: 5}, progress)
+
+	start := time.Now()
+	progress, err = replayer.Replay(context.Background(), ReplayOptions{Stream: "ORDERS", Rate: 20}, handler)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), progress.Replayed)
+	assert.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond, "5 messages at 20/s")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	replayer.OnProgress(func(ReplayProgress) { cancel() })
+	progress, err = replayer.Replay(ctx, ReplayOptions{Stream: "ORDERS", Rate: 1}, handler)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, uint64(1), progress.Replayed)
+}
+
+func TestReplayer_Errors(t *testing.T) {
+	client, _ := runReplayServer(t)
+	replayer := NewReplayer(client)
+
+	_, err := replayer.Replay(context.Background(), ReplayOptions{}, nil)
+	assert.Error(t, err)
+	_, err = replayer.Replay(context.Background(), ReplayOptions{Stream: "ORDERS"}, nil)
+	assert.Error(t, err, "a handler or target is required")
+	_, err = replayer.Replay(context.Background(), ReplayOptions{Stream: "MISSING", DryRun: true}, nil)
+	assert.ErrorIs(t, err, nats.ErrStreamNotFound)
+}
@@ -0,0 +1,120 @@
+package nats
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"grouter/pkg/cache"
+	"grouter/pkg/tenant"
+)
+
+// ResponseCacheConfig configures the caching of the replies to requests
+type ResponseCacheConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// TTL of the cached replies (1m when unset)
+	TTL time.Duration `mapstructure:"ttl"`
+	// Subjects limits caching to these subjects (NATS wildcards allowed), for
+	// read-mostly lookups. Empty caches every request.
+	Subjects []string `mapstructure:"subjects"`
+	// Cache stores the replies, an in-memory cache when nil
+	Cache cache.Cache `mapstructure:"-"`
+}
+
+type bypassKey struct{}
+
+// BypassResponseCache makes the requests of ctx skip the cached replies; the
+// fresh replies are cached in their place
+func BypassResponseCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassKey{}, true)
+}
+
+// ResponseCache caches the replies to requests by tenant, subject, type and
+// data, for TTL
+type ResponseCache struct {
+	cache    cache.Cache
+	ttl      time.Duration
+	subjects []string
+}
+
+// NewResponseCache creates the response cache of cfg
+func NewResponseCache(cfg ResponseCacheConfig) *ResponseCache {
+	c := cfg.Cache
+	if c == nil {
+		c = cache.New(cache.NewMemoryStore(0), cache.WithName("nats_responses"))
+	}
+	ttl := cfg.TTL
+	if ttl <= 0 {
+		ttl = time.Minute
+	}
+	return &ResponseCache{cache: c, ttl: ttl, subjects: cfg.Subjects}
+}
+
+// key returns the cache key of a request
+func (r *ResponseCache) key(ctx context.Context, subject, msgType string, data interface{}) (string, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request data: %w", err)
+	}
+	return fmt.Sprintf("nats:response:%s:%s:%s:%x", tenant.FromContext(ctx), subject, msgType, sha256.Sum256(raw)), nil
+}
+
+// Middleware returns a middleware that answers the requests on the cached
+// subjects from the cache, sending them only on a miss. Failed requests are
+// not cached, and an unreachable cache degrades to sending every request.
+func (r *ResponseCache) Middleware() RequestMiddleware {
+	return func(next RequestFunc) RequestFunc {
+		return func(ctx context.Context, subject string, msgType string, data interface{}, timeout time.Duration) (*MessageEnvelope, error) {
+			if len(r.subjects) > 0 && !matchAnySubject(r.subjects, subject) {
+				return next(ctx, subject, msgType, data, timeout)
+			}
+			key, err := r.key(ctx, subject, msgType, data)
+			if err != nil {
+				// Left to next to report
+				return next(ctx, subject, msgType, data, timeout)
+			}
+
+			if bypass, _ := ctx.Value(bypassKey{}).(bool); bypass {
+				resp, err := next(ctx, subject, msgType, data, timeout)
+				if err != nil {
+					return nil, err
+				}
+				if raw, err := json.Marshal(resp); err == nil {
+					_ = r.cache.Set(ctx, key, raw, r.ttl)
+				}
+				return resp, nil
+			}
+
+			raw, err := r.cache.GetOrLoad(ctx, key, r.ttl, func(ctx context.Context) ([]byte, error) {
+				resp, err := next(ctx, subject, msgType, data, timeout)
+				if err != nil {
+					return nil, err
+				}
+				return json.Marshal(resp)
+			})
+			if err != nil {
+				return nil, err
+			}
+			var resp MessageEnvelope
+			if err := json.Unmarshal(raw, &resp); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal cached response: %w", err)
+			}
+			return &resp, nil
+		}
+	}
+}
+
+// Invalidate removes the cached reply to the request of msgType with data on
+// subject, in the tenant of ctx
+func (r *ResponseCache) Invalidate(ctx context.Context, subject, msgType string, data interface{}) error {
+	key, err := r.key(ctx, subject, msgType, data)
+	if err != nil {
+		return err
+	}
+	if err := r.cache.Delete(ctx, key); err != nil {
+		return fmt.Errorf("failed to invalidate cached response: %w", err)
+	}
+	return nil
+}
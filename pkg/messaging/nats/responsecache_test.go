@@ -0,0 +1,100 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"grouter/pkg/cache"
+	"grouter/pkg/tenant"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingRequest is a RequestFunc answering with the number of requests sent
+// so far, failing while fail is set
+type countingRequest struct {
+	sent int
+	fail bool
+}
+
+func (c *countingRequest) request(ctx context.Context, subject string, msgType string, data interface{}, timeout time.Duration) (*MessageEnvelope, error) {
+	c.sent++
+	if c.fail {
+		return nil, errors.New("no responders")
+	}
+	return &MessageEnvelope{ID: "reply", Type: "reply", Data: []byte{byte('0' + c.sent)}}, nil
+}
+
+func TestResponseCache(t *testing.T) {
+	responses := NewResponseCache(ResponseCacheConfig{TTL: time.Minute, Subjects: []string{"catalog.>"}})
+	next := &countingRequest{}
+	request := responses.Middleware()(next.request)
+	ctx := context.Background()
+	query := map[string]string{"sku": "A1"}
+
+	resp, err := request(ctx, "catalog.get", "GetItem", query, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "1", string(resp.Data))
+	resp, err = request(ctx, "catalog.get", "GetItem", query, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "1", string(resp.Data), "answered from the cache")
+	assert.Equal(t, 1, next.sent)
+
+	// Other data, type or tenant miss
+	_, err = request(ctx, "catalog.get", "GetItem", map[string]string{"sku": "B2"}, time.Second)
+	require.NoError(t, err)
+	_, err = request(ctx, "catalog.get", "GetPrice", query, time.Second)
+	require.NoError(t, err)
+	_, err = request(tenant.NewContext(ctx, "acme"), "catalog.get", "GetItem", query, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, 4, next.sent)
+
+	// Subjects outside the cached ones are always sent
+	_, err = request(ctx, "orders.get", "GetOrder", query, time.Second)
+	require.NoError(t, err)
+	_, err = request(ctx, "orders.get", "GetOrder", query, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, 6, next.sent)
+
+	// Bypassing sends the request and caches the fresh reply
+	resp, err = request(BypassResponseCache(ctx), "catalog.get", "GetItem", query, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "7", string(resp.Data))
+	resp, err = request(ctx, "catalog.get", "GetItem", query, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "7", string(resp.Data))
+
+	// Invalidated replies are requested again
+	require.NoError(t, responses.Invalidate(ctx, "catalog.get", "GetItem", query))
+	resp, err = request(ctx, "catalog.get", "GetItem", query, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "8", string(resp.Data))
+}
+
+func TestResponseCache_FailuresNotCached(t *testing.T) {
+	store := cache.New(cache.NewMemoryStore(0))
+	responses := NewResponseCache(ResponseCacheConfig{Cache: store})
+	next := &countingRequest{fail: true}
+	request := responses.Middleware()(next.request)
+	ctx := context.Background()
+
+	_, err := request(ctx, "config.get", "GetConfig", nil, time.Second)
+	require.Error(t, err)
+	next.fail = false
+	resp, err := request(ctx, "config.get", "GetConfig", nil, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "2", string(resp.Data))
+	assert.Equal(t, time.Minute, responses.ttl, "default TTL")
+
+	// Every subject is cached without Subjects, in the given cache
+	resp, err = request(ctx, "config.get", "GetConfig", nil, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "2", string(resp.Data))
+	key, err := responses.key(ctx, "config.get", "GetConfig", nil)
+	require.NoError(t, err)
+	_, err = store.Get(ctx, key)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,164 @@
+package nats
+
+import (
+	"net/url"
+	"strings"
+
+	"grouter/pkg/telemetry"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// Servers returns the URLs of the server pool of cfg: those of URL, comma
+// separated, then URLs, without duplicates
+func (cfg Config) Servers() []string {
+	var servers []string
+	seen := make(map[string]bool)
+	for _, server := range append(strings.Split(cfg.URL, ","), cfg.URLs...) {
+		server = strings.TrimSpace(server)
+		if server == "" || seen[server] {
+			continue
+		}
+		seen[server] = true
+		servers = append(servers, server)
+	}
+	return servers
+}
+
+// serverMetrics are the per server connection metrics of a client
+type serverMetrics struct {
+	connected   *prometheus.GaugeVec
+	connects    *prometheus.CounterVec
+	disconnects *prometheus.CounterVec
+	servers     *prometheus.GaugeVec
+}
+
+// newServerMetrics registers the connection metrics in reg, nil using the
+// global registry
+func newServerMetrics(reg *telemetry.MetricsRegistry) *serverMetrics {
+	return &serverMetrics{
+		connected: reg.GaugeVec(prometheus.GaugeOpts{
+			Name: "messaging_server_connected",
+			Help: "1 for the NATS server the client is connected to, 0 for those it was connected to",
+		}, []string{"server"}),
+		connects: reg.CounterVec(prometheus.CounterOpts{
+			Name: "messaging_server_connects_total",
+			Help: "Total number of connections and reconnections to a NATS server",
+		}, []string{"server"}),
+		disconnects: reg.CounterVec(prometheus.CounterOpts{
+			Name: "messaging_server_disconnects_total",
+			Help: "Total number of disconnections from a NATS server",
+		}, []string{"server"}),
+		servers: reg.GaugeVec(prometheus.GaugeOpts{
+			Name: "messaging_servers",
+			Help: "Number of NATS servers in the server pool, configured or discovered from the cluster",
+		}, []string{"origin"}),
+	}
+}
+
+// serverOptions returns the server pool and connection event options of the
+// client
+func (c *Client) serverOptions() []nats.Option {
+	opts := []nats.Option{
+		nats.ConnectHandler(func(nc *nats.Conn) {
+			c.onConnect(nc)
+			c.emit(EventConnected, c.ConnectedServer(), nil)
+		}),
+		nats.ReconnectHandler(func(nc *nats.Conn) {
+			c.logger.Info("NATS reconnected", zap.String("url", nc.ConnectedUrlRedacted()))
+			c.onConnect(nc)
+			c.emit(EventReconnected, c.ConnectedServer(), nil)
+		}),
+		nats.DisconnectErrHandler(func(nc *nats.Conn, err error) {
+			server := c.ConnectedServer()
+			if err != nil {
+				c.logger.Error("NATS disconnected", zap.Error(err), zap.String("url", server))
+			}
+			c.onDisconnect()
+			c.emit(EventDisconnected, server, err)
+		}),
+		nats.ClosedHandler(func(nc *nats.Conn) {
+			c.logger.Warn("NATS connection closed")
+			c.emit(EventClosed, "", nil)
+		}),
+		nats.DiscoveredServersHandler(func(nc *nats.Conn) {
+			c.logger.Info("NATS servers discovered", zap.Strings("servers", redactURLs(nc.DiscoveredServers())))
+			c.countServers(nc)
+			c.emit(EventServersDiscovered, nc.ConnectedUrlRedacted(), nil)
+		}),
+		nats.LameDuckModeHandler(func(nc *nats.Conn) {
+			c.logger.Warn("NATS server entering lame duck mode", zap.String("url", nc.ConnectedUrlRedacted()))
+			c.emit(EventLameDuck, nc.ConnectedUrlRedacted(), nil)
+		}),
+		nats.ErrorHandler(c.onAsyncError),
+	}
+	if c.config.NoRandomize {
+		opts = append(opts, nats.DontRandomize())
+	}
+	return opts
+}
+
+// onConnect records the server nc connected to
+func (c *Client) onConnect(nc *nats.Conn) {
+	server := nc.ConnectedUrlRedacted()
+	c.serverMu.Lock()
+	c.server = server
+	c.serverMu.Unlock()
+	if c.metrics != nil {
+		c.metrics.connected.WithLabelValues(server).Set(1)
+		c.metrics.connects.WithLabelValues(server).Inc()
+	}
+	c.countServers(nc)
+}
+
+// onDisconnect records the loss of the server connected to
+func (c *Client) onDisconnect() {
+	c.serverMu.Lock()
+	server := c.server
+	c.server = ""
+	c.serverMu.Unlock()
+	if server == "" || c.metrics == nil {
+		return
+	}
+	c.metrics.connected.WithLabelValues(server).Set(0)
+	c.metrics.disconnects.WithLabelValues(server).Inc()
+}
+
+func (c *Client) countServers(nc *nats.Conn) {
+	if c.metrics == nil {
+		return
+	}
+	discovered := len(nc.DiscoveredServers())
+	c.metrics.servers.WithLabelValues("configured").Set(float64(len(nc.Servers()) - discovered))
+	c.metrics.servers.WithLabelValues("discovered").Set(float64(discovered))
+}
+
+// ConnectedServer returns the URL of the server the client is connected to,
+// passwords redacted, or ""
+func (c *Client) ConnectedServer() string {
+	c.serverMu.Lock()
+	defer c.serverMu.Unlock()
+	return c.server
+}
+
+// Servers returns the URLs of the server pool, configured and discovered,
+// passwords redacted
+func (c *Client) Servers() []string {
+	if c.conn == nil {
+		return redactURLs(c.config.Servers())
+	}
+	return redactURLs(c.conn.Servers())
+}
+
+func redactURLs(servers []string) []string {
+	out := make([]string, 0, len(servers))
+	for _, server := range servers {
+		if u, err := url.Parse(server); err == nil {
+			server = u.Redacted()
+		}
+		out = append(out, server)
+	}
+	return out
+}
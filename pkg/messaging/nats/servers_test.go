@@ -0,0 +1,92 @@
+package nats
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"grouter/pkg/telemetry"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func runServer(t *testing.T, opts *server.Options) *server.Server {
+	t.Helper()
+	opts.Host, opts.NoLog, opts.NoSigs = "127.0.0.1", true, true
+	if opts.Port == 0 {
+		opts.Port = -1
+	}
+	s, err := server.NewServer(opts)
+	require.NoError(t, err)
+	go s.Start()
+	require.True(t, s.ReadyForConnections(5*time.Second))
+	t.Cleanup(s.Shutdown)
+	return s
+}
+
+func TestConfig_Servers(t *testing.T) {
+	cfg := Config{
+		URL:  "nats://a:4222, nats://b:4222",
+		URLs: []string{"nats://c:4222", "nats://a:4222", ""},
+	}
+	assert.Equal(t, []string{"nats://a:4222", "nats://b:4222", "nats://c:4222"}, cfg.Servers())
+	assert.Empty(t, Config{}.Servers())
+}
+
+func TestClient_ServerPool(t *testing.T) {
+	a := runServer(t, &server.Options{})
+	b := runServer(t, &server.Options{})
+	reg := telemetry.NewMetricsRegistry()
+	client, err := NewNATSClient(Config{
+		URLs:              []string{a.ClientURL(), b.ClientURL()},
+		NoRandomize:       true,
+		MaxReconnects:     -1,
+		ReconnectWait:     10 * time.Millisecond,
+		ConnectionTimeout: time.Second,
+		Metrics:           MetricsConfig{Enabled: true, Registry: reg},
+	}, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, client.Connect())
+	defer client.Close()
+
+	metrics := newServerMetrics(reg)
+	require.Eventually(t, func() bool { return client.ConnectedServer() == a.ClientURL() }, 2*time.Second, 10*time.Millisecond,
+		"servers are tried in order without randomization")
+	assert.Equal(t, []string{a.ClientURL(), b.ClientURL()}, client.Servers())
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.connected.WithLabelValues(a.ClientURL())))
+	assert.Equal(t, float64(2), testutil.ToFloat64(metrics.servers.WithLabelValues("configured")))
+
+	// The client rotates to the next server of the pool
+	a.Shutdown()
+	require.Eventually(t, func() bool { return client.ConnectedServer() == b.ClientURL() }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, float64(0), testutil.ToFloat64(metrics.connected.WithLabelValues(a.ClientURL())))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.disconnects.WithLabelValues(a.ClientURL())))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.connected.WithLabelValues(b.ClientURL())))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.connects.WithLabelValues(b.ClientURL())))
+}
+
+func TestClient_DiscoveredServers(t *testing.T) {
+	a := runServer(t, &server.Options{Cluster: server.ClusterOpts{Name: "test", Host: "127.0.0.1", Port: -1}})
+	routes := server.RoutesFromStr(fmt.Sprintf("nats://127.0.0.1:%d", a.ClusterAddr().Port))
+	b := runServer(t, &server.Options{Cluster: server.ClusterOpts{Name: "test", Host: "127.0.0.1", Port: -1}, Routes: routes})
+
+	reg := telemetry.NewMetricsRegistry()
+	client, err := NewNATSClient(Config{
+		URL:               a.ClientURL(),
+		ConnectionTimeout: time.Second,
+		Metrics:           MetricsConfig{Enabled: true, Registry: reg},
+	}, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, client.Connect())
+	defer client.Close()
+
+	metrics := newServerMetrics(reg)
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(metrics.servers.WithLabelValues("discovered")) == 1
+	}, 5*time.Second, 10*time.Millisecond, "the other member of the cluster is discovered")
+	assert.Contains(t, client.Servers(), b.ClientURL())
+}
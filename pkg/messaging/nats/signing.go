@@ -0,0 +1,350 @@
+package nats
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"grouter/pkg/telemetry"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Envelope metadata keys used for signatures
+const (
+	MetadataSignature      = "signature"
+	MetadataSignatureKeyID = "signature_key_id"
+	MetadataSignatureAlg   = "signature_alg"
+)
+
+// Supported signing algorithms
+const (
+	SigningAlgHMAC    = "hmac-sha256"
+	SigningAlgEd25519 = "ed25519"
+)
+
+// Verification errors
+var (
+	ErrMissingSignature   = errors.New("envelope is not signed")
+	ErrUnknownSigningKey  = errors.New("unknown signing key")
+	ErrInvalidSignature   = errors.New("invalid envelope signature")
+	ErrEnvelopeExpired    = errors.New("envelope timestamp outside allowed age")
+	errSigningKeyReadOnly = errors.New("signing key has no private material")
+)
+
+// authRejectedMetric returns the counter of rejected envelopes of reg
+func authRejectedMetric(reg *telemetry.MetricsRegistry) *prometheus.CounterVec {
+	return reg.CounterVec(prometheus.CounterOpts{
+		Name: "messaging_auth_rejected_total",
+		Help: "Total number of messages rejected by envelope signature verification",
+	}, []string{"subject", "type", "reason"})
+}
+
+// SigningConfig configures envelope signing and verification
+type SigningConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// KeyID selects the key used to sign outgoing envelopes. Leave empty for
+	// services that only verify.
+	KeyID string `mapstructure:"key_id"`
+	// Keys are the known keys, used for signing (KeyID) and verification
+	Keys []SigningKey `mapstructure:"keys"`
+	// Verify enables signature verification on the subscriber
+	Verify bool `mapstructure:"verify"`
+	// Subjects limits verification to these subjects (NATS wildcards allowed).
+	// Empty verifies every subject.
+	Subjects []string `mapstructure:"subjects"`
+	// MaxAge rejects envelopes whose timestamp is further than MaxAge from now
+	MaxAge time.Duration `mapstructure:"max_age"`
+}
+
+// SigningKey is a named HMAC secret or ed25519 key pair. Ed25519 keys are
+// base64 encoded; the private key may be the 32 byte seed or the 64 byte key.
+type SigningKey struct {
+	ID         string `mapstructure:"id"`
+	Algorithm  string `mapstructure:"algorithm"`
+	Secret     string `mapstructure:"secret"`
+	PublicKey  string `mapstructure:"public_key"`
+	PrivateKey string `mapstructure:"private_key"`
+}
+
+type envelopeKey struct {
+	alg     string
+	secret  []byte
+	public  ed25519.PublicKey
+	private ed25519.PrivateKey
+}
+
+// EnvelopeSigner signs and verifies message envelopes. The signature covers
+// the ID, type, timestamp, source, data and metadata of the envelope.
+type EnvelopeSigner struct {
+	keyID  string
+	keys   map[string]envelopeKey
+	maxAge time.Duration
+	now    func() time.Time
+}
+
+// NewEnvelopeSigner creates an EnvelopeSigner from the configured keys
+func NewEnvelopeSigner(cfg SigningConfig) (*EnvelopeSigner, error) {
+	s := &EnvelopeSigner{
+		keyID:  cfg.KeyID,
+		keys:   make(map[string]envelopeKey, len(cfg.Keys)),
+		maxAge: cfg.MaxAge,
+		now:    time.Now,
+	}
+
+	for _, k := range cfg.Keys {
+		if k.ID == "" {
+			return nil, fmt.Errorf("signing key without id")
+		}
+		key, err := parseSigningKey(k)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signing key %q: %w", k.ID, err)
+		}
+		s.keys[k.ID] = key
+	}
+
+	if s.keyID != "" {
+		key, ok := s.keys[s.keyID]
+		if !ok {
+			return nil, fmt.Errorf("signing key %q not found", s.keyID)
+		}
+		if key.alg == SigningAlgEd25519 && key.private == nil {
+			return nil, fmt.Errorf("signing key %q: %w", s.keyID, errSigningKeyReadOnly)
+		}
+	}
+	if len(s.keys) == 0 {
+		return nil, fmt.Errorf("signing enabled but no keys configured")
+	}
+	return s, nil
+}
+
+func parseSigningKey(k SigningKey) (envelopeKey, error) {
+	switch k.Algorithm {
+	case SigningAlgHMAC, "":
+		if k.Secret == "" {
+			return envelopeKey{}, fmt.Errorf("hmac key requires a secret")
+		}
+		return envelopeKey{alg: SigningAlgHMAC, secret: []byte(k.Secret)}, nil
+	case SigningAlgEd25519:
+		key := envelopeKey{alg: SigningAlgEd25519}
+		if k.PrivateKey != "" {
+			raw, err := base64.StdEncoding.DecodeString(k.PrivateKey)
+			if err != nil {
+				return envelopeKey{}, fmt.Errorf("failed to decode private key: %w", err)
+			}
+			switch len(raw) {
+			case ed25519.SeedSize:
+				key.private = ed25519.NewKeyFromSeed(raw)
+			case ed25519.PrivateKeySize:
+				key.private = ed25519.PrivateKey(raw)
+			default:
+				return envelopeKey{}, fmt.Errorf("invalid ed25519 private key size %d", len(raw))
+			}
+			key.public = key.private.Public().(ed25519.PublicKey)
+		}
+		if k.PublicKey != "" {
+			raw, err := base64.StdEncoding.DecodeString(k.PublicKey)
+			if err != nil {
+				return envelopeKey{}, fmt.Errorf("failed to decode public key: %w", err)
+			}
+			if len(raw) != ed25519.PublicKeySize {
+				return envelopeKey{}, fmt.Errorf("invalid ed25519 public key size %d", len(raw))
+			}
+			key.public = ed25519.PublicKey(raw)
+		}
+		if key.public == nil {
+			return envelopeKey{}, fmt.Errorf("ed25519 key requires a public or private key")
+		}
+		return key, nil
+	default:
+		return envelopeKey{}, fmt.Errorf("unsupported algorithm %q", k.Algorithm)
+	}
+}
+
+// Sign adds the signature metadata to the envelope
+func (s *EnvelopeSigner) Sign(env *MessageEnvelope) error {
+	if s.keyID == "" {
+		return nil
+	}
+	key := s.keys[s.keyID]
+
+	if env.Metadata == nil {
+		env.Metadata = make(map[string]string)
+	}
+	env.Metadata[MetadataSignatureKeyID] = s.keyID
+	env.Metadata[MetadataSignatureAlg] = key.alg
+	delete(env.Metadata, MetadataSignature)
+
+	payload := signingPayload(env)
+	var sig []byte
+	switch key.alg {
+	case SigningAlgHMAC:
+		mac := hmac.New(sha256.New, key.secret)
+		mac.Write(payload)
+		sig = mac.Sum(nil)
+	case SigningAlgEd25519:
+		sig = ed25519.Sign(key.private, payload)
+	}
+	env.Metadata[MetadataSignature] = base64.StdEncoding.EncodeToString(sig)
+	return nil
+}
+
+// Verify checks the envelope signature and, when MaxAge is set, its timestamp
+func (s *EnvelopeSigner) Verify(env *MessageEnvelope) error {
+	sigB64 := env.Metadata[MetadataSignature]
+	if sigB64 == "" {
+		return ErrMissingSignature
+	}
+	keyID := env.Metadata[MetadataSignatureKeyID]
+	key, ok := s.keys[keyID]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownSigningKey, keyID)
+	}
+	// The algorithm is bound to the key, never taken from the message alone
+	if env.Metadata[MetadataSignatureAlg] != key.alg {
+		return fmt.Errorf("%w: algorithm mismatch", ErrInvalidSignature)
+	}
+	sig, err := base64.StdEncoding.DecodeString(sigB64)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+
+	payload := signingPayload(env)
+	switch key.alg {
+	case SigningAlgHMAC:
+		mac := hmac.New(sha256.New, key.secret)
+		mac.Write(payload)
+		ok = hmac.Equal(sig, mac.Sum(nil))
+	case SigningAlgEd25519:
+		ok = ed25519.Verify(key.public, payload, sig)
+	}
+	if !ok {
+		return ErrInvalidSignature
+	}
+
+	if s.maxAge > 0 {
+		age := s.now().Sub(env.Timestamp)
+		if age > s.maxAge || age < -s.maxAge {
+			return ErrEnvelopeExpired
+		}
+	}
+	return nil
+}
+
+// signingPayload builds the canonical bytes covered by the signature. Reply is
+// excluded because the subscriber overwrites it with the NATS reply subject.
+func signingPayload(env *MessageEnvelope) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(env.ID)
+	buf.WriteByte('\n')
+	buf.WriteString(env.Type)
+	buf.WriteByte('\n')
+	buf.WriteString(env.Timestamp.UTC().Format(time.RFC3339Nano))
+	buf.WriteByte('\n')
+	buf.WriteString(env.Source)
+	buf.WriteByte('\n')
+	// Only set expiries and correlations are signed, so envelopes without
+	// them keep the signatures of earlier versions
+	if !env.ExpiresAt.IsZero() {
+		buf.WriteString("expires_at:")
+		buf.WriteString(env.ExpiresAt.UTC().Format(time.RFC3339Nano))
+		buf.WriteByte('\n')
+	}
+	if env.CorrelationID != "" {
+		buf.WriteString("correlation_id:")
+		buf.WriteString(env.CorrelationID)
+		buf.WriteByte('\n')
+	}
+	if env.CausationID != "" {
+		buf.WriteString("causation_id:")
+		buf.WriteString(env.CausationID)
+		buf.WriteByte('\n')
+	}
+
+	keys := make([]string, 0, len(env.Metadata))
+	for k := range env.Metadata {
+		if k != MetadataSignature {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		buf.WriteString(k)
+		buf.WriteByte('=')
+		buf.WriteString(env.Metadata[k])
+		buf.WriteByte('\n')
+	}
+
+	// Data is re-encoded compactly when the envelope is marshaled
+	if err := json.Compact(&buf, env.Data); err != nil {
+		buf.Write(env.Data)
+	}
+	return buf.Bytes()
+}
+
+// VerificationMiddleware returns a middleware that rejects envelopes without a
+// valid signature. Only subjects matching one of the patterns are checked; no
+// patterns checks every subject. Rejections are counted in reg (nil uses the
+// global registry).
+func VerificationMiddleware(v Verifier, subjects []string, reg *telemetry.MetricsRegistry) SubscriberMiddleware {
+	authRejectedCounter := authRejectedMetric(reg)
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, subject string, env *MessageEnvelope) error {
+			if len(subjects) > 0 && !matchAnySubject(subjects, subject) {
+				return next(ctx, subject, env)
+			}
+			if err := v.Verify(env); err != nil {
+				authRejectedCounter.WithLabelValues(subject, env.Type, rejectReason(err)).Inc()
+				return fmt.Errorf("message rejected: %w", err)
+			}
+			return next(ctx, subject, env)
+		}
+	}
+}
+
+func rejectReason(err error) string {
+	switch {
+	case errors.Is(err, ErrMissingSignature):
+		return "missing_signature"
+	case errors.Is(err, ErrUnknownSigningKey):
+		return "unknown_key"
+	case errors.Is(err, ErrEnvelopeExpired):
+		return "expired"
+	default:
+		return "invalid_signature"
+	}
+}
+
+func matchAnySubject(patterns []string, subject string) bool {
+	for _, p := range patterns {
+		if MatchSubject(p, subject) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchSubject reports whether a subject matches a NATS subject pattern, where
+// "*" matches one token and a trailing ">" matches one or more tokens.
+func MatchSubject(pattern, subject string) bool {
+	pt := strings.Split(pattern, ".")
+	st := strings.Split(subject, ".")
+	for i, p := range pt {
+		if p == ">" {
+			return i == len(pt)-1 && len(st) > i
+		}
+		if i >= len(st) || (p != "*" && p != st[i]) {
+			return false
+		}
+	}
+	return len(pt) == len(st)
+}
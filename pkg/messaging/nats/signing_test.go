@@ -0,0 +1,194 @@
+package nats
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"grouter/pkg/telemetry"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestEnvelope() *MessageEnvelope {
+	return &MessageEnvelope{
+		ID:        "msg-1",
+		Type:      "start",
+		Timestamp: time.Now(),
+		Source:    "controller",
+		Data:      json.RawMessage(`{"force": true}`),
+		Metadata:  map[string]string{"traceparent": "00-abc-def-01"},
+	}
+}
+
+// roundTrip simulates sending the envelope over the wire
+func roundTrip(t *testing.T, env *MessageEnvelope) *MessageEnvelope {
+	t.Helper()
+	b, err := json.Marshal(env)
+	require.NoError(t, err)
+	var out MessageEnvelope
+	require.NoError(t, json.Unmarshal(b, &out))
+	return &out
+}
+
+func TestEnvelopeSigner_HMAC(t *testing.T) {
+	keys := []SigningKey{{ID: "ctl", Algorithm: SigningAlgHMAC, Secret: "s3cret"}}
+	signer, err := NewEnvelopeSigner(SigningConfig{KeyID: "ctl", Keys: keys})
+	require.NoError(t, err)
+	verifier, err := NewEnvelopeSigner(SigningConfig{Keys: keys, MaxAge: time.Minute})
+	require.NoError(t, err)
+
+	env := newTestEnvelope()
+	require.NoError(t, signer.Sign(env))
+	assert.Equal(t, "ctl", env.Metadata[MetadataSignatureKeyID])
+	assert.Equal(t, SigningAlgHMAC, env.Metadata[MetadataSignatureAlg])
+
+	received := roundTrip(t, env)
+	received.Reply = "_INBOX.123"
+	assert.NoError(t, verifier.Verify(received))
+
+	tampered := roundTrip(t, env)
+	tampered.Type = "stop"
+	assert.ErrorIs(t, verifier.Verify(tampered), ErrInvalidSignature)
+
+	tampered = roundTrip(t, env)
+	tampered.Metadata["roles"] = "admin"
+	assert.ErrorIs(t, verifier.Verify(tampered), ErrInvalidSignature)
+
+	tampered = roundTrip(t, env)
+	tampered.ExpiresAt = time.Now().Add(time.Hour)
+	assert.ErrorIs(t, verifier.Verify(tampered), ErrInvalidSignature, "the expiry is signed once set")
+
+	tampered = roundTrip(t, env)
+	tampered.CorrelationID = "flow-2"
+	assert.ErrorIs(t, verifier.Verify(tampered), ErrInvalidSignature, "the correlation is signed once set")
+
+	other, err := NewEnvelopeSigner(SigningConfig{Keys: []SigningKey{{ID: "ctl", Secret: "other"}}})
+	require.NoError(t, err)
+	assert.ErrorIs(t, other.Verify(roundTrip(t, env)), ErrInvalidSignature)
+
+	verifier.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	assert.ErrorIs(t, verifier.Verify(roundTrip(t, env)), ErrEnvelopeExpired)
+}
+
+func TestEnvelopeSigner_Ed25519(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	signer, err := NewEnvelopeSigner(SigningConfig{
+		KeyID: "ctl",
+		Keys: []SigningKey{{
+			ID:         "ctl",
+			Algorithm:  SigningAlgEd25519,
+			PrivateKey: base64.StdEncoding.EncodeToString(priv.Seed()),
+		}},
+	})
+	require.NoError(t, err)
+	verifier, err := NewEnvelopeSigner(SigningConfig{
+		Keys: []SigningKey{{
+			ID:        "ctl",
+			Algorithm: SigningAlgEd25519,
+			PublicKey: base64.StdEncoding.EncodeToString(pub),
+		}},
+	})
+	require.NoError(t, err)
+
+	env := newTestEnvelope()
+	require.NoError(t, signer.Sign(env))
+	assert.NoError(t, verifier.Verify(roundTrip(t, env)))
+
+	env.Data = json.RawMessage(`{"force":false}`)
+	assert.ErrorIs(t, verifier.Verify(roundTrip(t, env)), ErrInvalidSignature)
+
+	// A verify-only signer cannot sign with a public key
+	_, err = NewEnvelopeSigner(SigningConfig{KeyID: "ctl", Keys: []SigningKey{{
+		ID:        "ctl",
+		Algorithm: SigningAlgEd25519,
+		PublicKey: base64.StdEncoding.EncodeToString(pub),
+	}}})
+	assert.Error(t, err)
+}
+
+func TestEnvelopeSigner_AlgorithmMismatch(t *testing.T) {
+	signer, err := NewEnvelopeSigner(SigningConfig{KeyID: "ctl", Keys: []SigningKey{{ID: "ctl", Secret: "s3cret"}}})
+	require.NoError(t, err)
+
+	env := newTestEnvelope()
+	require.NoError(t, signer.Sign(env))
+	env.Metadata[MetadataSignatureAlg] = SigningAlgEd25519
+	assert.ErrorIs(t, signer.Verify(env), ErrInvalidSignature)
+
+	env.Metadata[MetadataSignatureKeyID] = "unknown"
+	assert.ErrorIs(t, signer.Verify(env), ErrUnknownSigningKey)
+}
+
+func TestNewEnvelopeSigner_InvalidConfig(t *testing.T) {
+	_, err := NewEnvelopeSigner(SigningConfig{})
+	assert.Error(t, err)
+
+	_, err = NewEnvelopeSigner(SigningConfig{KeyID: "missing", Keys: []SigningKey{{ID: "a", Secret: "x"}}})
+	assert.Error(t, err)
+
+	_, err = NewEnvelopeSigner(SigningConfig{Keys: []SigningKey{{ID: "a", Algorithm: "rsa"}}})
+	assert.Error(t, err)
+
+	_, err = NewEnvelopeSigner(SigningConfig{Keys: []SigningKey{{ID: "a", Algorithm: SigningAlgEd25519, PublicKey: "bm90LWEta2V5"}}})
+	assert.Error(t, err)
+}
+
+func TestVerificationMiddleware(t *testing.T) {
+	keys := []SigningKey{{ID: "ctl", Secret: "s3cret"}}
+	signer, err := NewEnvelopeSigner(SigningConfig{KeyID: "ctl", Keys: keys})
+	require.NoError(t, err)
+
+	called := 0
+	reg := telemetry.NewMetricsRegistry()
+	handler := VerificationMiddleware(signer, []string{"*.start", "ops.>"}, reg)(
+		func(ctx context.Context, subject string, env *MessageEnvelope) error {
+			called++
+			return nil
+		})
+
+	err = handler(context.Background(), "app.start", newTestEnvelope())
+	assert.ErrorIs(t, err, ErrMissingSignature)
+	assert.Equal(t, 0, called)
+	assert.Equal(t, float64(1), testutil.ToFloat64(authRejectedMetric(reg).WithLabelValues("app.start", "start", "missing_signature")))
+
+	signed := newTestEnvelope()
+	require.NoError(t, signer.Sign(signed))
+	assert.NoError(t, handler(context.Background(), "app.start", signed))
+	assert.Equal(t, 1, called)
+
+	// Subjects outside the patterns are not checked
+	assert.NoError(t, handler(context.Background(), "app.natdemo.create", newTestEnvelope()))
+	assert.Equal(t, 2, called)
+
+	assert.Error(t, handler(context.Background(), "ops.restart.now", newTestEnvelope()))
+	assert.Equal(t, 2, called)
+}
+
+func TestMatchSubject(t *testing.T) {
+	tests := []struct {
+		pattern, subject string
+		want             bool
+	}{
+		{"app.start", "app.start", true},
+		{"app.start", "app.stop", false},
+		{"*.start", "app.start", true},
+		{"*.start", "app.x.start", false},
+		{"app.>", "app.start", true},
+		{"app.>", "app.a.b", true},
+		{"app.>", "app", false},
+		{"app.*", "app", false},
+		{"app", "app.start", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, MatchSubject(tt.pattern, tt.subject), "%s ~ %s", tt.pattern, tt.subject)
+	}
+}
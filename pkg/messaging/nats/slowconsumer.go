@@ -0,0 +1,199 @@
+package nats
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// SlowConsumerPolicy is what a subscription does once it drops messages
+// beyond its pending limits
+type SlowConsumerPolicy int
+
+const (
+	// SlowConsumerDrop keeps dropping the messages beyond the limits
+	SlowConsumerDrop SlowConsumerPolicy = iota
+	// SlowConsumerGrow doubles the pending limits, up to maxPendingGrowth
+	// times the initial ones, then drops
+	SlowConsumerGrow
+	// SlowConsumerUnsubscribe removes the subscription and logs an error,
+	// so that the other members of its queue group take its messages
+	SlowConsumerUnsubscribe
+)
+
+func (p SlowConsumerPolicy) String() string {
+	switch p {
+	case SlowConsumerDrop:
+		return "drop"
+	case SlowConsumerGrow:
+		return "grow"
+	case SlowConsumerUnsubscribe:
+		return "unsubscribe"
+	}
+	return fmt.Sprintf("SlowConsumerPolicy(%d)", int(p))
+}
+
+// ParseSlowConsumerPolicy returns the policy named drop, grow or unsubscribe;
+// "" is drop
+func ParseSlowConsumerPolicy(name string) (SlowConsumerPolicy, error) {
+	switch name {
+	case "", "drop":
+		return SlowConsumerDrop, nil
+	case "grow":
+		return SlowConsumerGrow, nil
+	case "unsubscribe":
+		return SlowConsumerUnsubscribe, nil
+	}
+	return SlowConsumerDrop, fmt.Errorf("unknown slow consumer policy %q", name)
+}
+
+const (
+	// maxPendingGrowth caps the limits of SlowConsumerGrow
+	maxPendingGrowth = 16
+	// slowConsumerWindow is how long SlowConsumerCheck reports a slow
+	// consumer
+	slowConsumerWindow = time.Minute
+)
+
+// slowConsumers are the slow consumer handlers of the subscriptions of a
+// client and the last slow consumer by subject
+type slowConsumers struct {
+	mu       sync.Mutex
+	handlers map[*nats.Subscription]func()
+	last     map[string]time.Time
+	total    *prometheus.CounterVec // nil without metrics
+}
+
+// onSlowConsumer runs fn, on its own goroutine, when sub drops messages,
+// until the returned function is called
+func (c *Client) onSlowConsumer(sub *nats.Subscription, fn func()) (remove func()) {
+	c.slow.mu.Lock()
+	defer c.slow.mu.Unlock()
+	if c.slow.handlers == nil {
+		c.slow.handlers = make(map[*nats.Subscription]func())
+	}
+	c.slow.handlers[sub] = fn
+	return func() {
+		c.slow.mu.Lock()
+		defer c.slow.mu.Unlock()
+		delete(c.slow.handlers, sub)
+	}
+}
+
+// slowConsumer records the slow consumer sub and runs its handler
+func (c *Client) slowConsumer(sub *nats.Subscription) {
+	if sub == nil {
+		return
+	}
+	c.slow.mu.Lock()
+	if c.slow.last == nil {
+		c.slow.last = make(map[string]time.Time)
+	}
+	c.slow.last[sub.Subject] = time.Now()
+	fn := c.slow.handlers[sub]
+	c.slow.mu.Unlock()
+
+	if c.slow.total != nil {
+		c.slow.total.WithLabelValues(sub.Subject).Inc()
+	}
+	if fn != nil {
+		go fn()
+	}
+}
+
+// SlowConsumerCheck reports an error while a subscription dropped messages
+// within the last minute, for a non-critical readiness check
+func (c *Client) SlowConsumerCheck() error {
+	c.slow.mu.Lock()
+	defer c.slow.mu.Unlock()
+	var subjects []string
+	for subject, at := range c.slow.last {
+		if time.Since(at) < slowConsumerWindow {
+			subjects = append(subjects, subject)
+		} else {
+			delete(c.slow.last, subject)
+		}
+	}
+	if len(subjects) > 0 {
+		return fmt.Errorf("slow consumers dropping messages on %v", subjects)
+	}
+	return nil
+}
+
+func slowConsumerMetric(c *Client) *prometheus.CounterVec {
+	return c.config.Metrics.Registry.CounterVec(prometheus.CounterOpts{
+		Name: "messaging_slow_consumer_total",
+		Help: "Total number of times a subscription dropped messages beyond its pending limits",
+	}, []string{"subject"})
+}
+
+// limit applies the pending limits and the slow consumer policy of opts to
+// the subscription handle
+func (s *NATSSubscriber) limit(handle *subscription, opts *SubscribeOptions) error {
+	if opts == nil {
+		opts = &SubscribeOptions{}
+	}
+	msgs, bytes := opts.PendingMsgs, opts.PendingBytes
+	if msgs == 0 {
+		msgs = nats.DefaultSubPendingMsgsLimit
+	}
+	if bytes == 0 {
+		bytes = nats.DefaultSubPendingBytesLimit
+	}
+	for _, sub := range handle.subs {
+		if opts.PendingMsgs != 0 || opts.PendingBytes != 0 {
+			if err := sub.SetPendingLimits(msgs, bytes); err != nil {
+				return fmt.Errorf("failed to set pending limits of %s: %w", sub.Subject, err)
+			}
+		}
+		handle.removers = append(handle.removers, s.client.onSlowConsumer(sub, s.slowConsumerHandler(handle, sub, opts.SlowConsumer, msgs, bytes)))
+	}
+	return nil
+}
+
+// slowConsumerHandler applies policy to sub of handle, whose initial limits
+// are msgs and bytes
+func (s *NATSSubscriber) slowConsumerHandler(handle *subscription, sub *nats.Subscription, policy SlowConsumerPolicy, msgs, bytes int) func() {
+	log := s.client.logger.With(zap.String("subject", sub.Subject), zap.Stringer("policy", policy))
+	return func() {
+		switch policy {
+		case SlowConsumerGrow:
+			current, currentBytes, err := sub.PendingLimits()
+			if err != nil {
+				return
+			}
+			nextMsgs, nextBytes := grow(current, msgs), grow(currentBytes, bytes)
+			if nextMsgs == current && nextBytes == currentBytes {
+				log.Warn("Slow consumer at its maximum pending limits, dropping messages")
+				return
+			}
+			if err := sub.SetPendingLimits(nextMsgs, nextBytes); err != nil {
+				log.Error("Failed to grow pending limits", zap.Error(err))
+				return
+			}
+			log.Warn("Slow consumer, pending limits grown",
+				zap.Int("pending_msgs", nextMsgs), zap.Int("pending_bytes", nextBytes))
+		case SlowConsumerUnsubscribe:
+			log.Error("Slow consumer, unsubscribing")
+			if err := handle.Unsubscribe(); err != nil {
+				log.Error("Failed to unsubscribe slow consumer", zap.Error(err))
+			}
+		default:
+			dropped, _ := sub.Dropped()
+			log.Warn("Slow consumer, dropping messages", zap.Int("dropped", dropped))
+		}
+	}
+}
+
+// grow doubles the limit current, up to maxPendingGrowth times initial;
+// negative limits are unlimited
+func grow(current, initial int) int {
+	if current < 0 || initial < 0 {
+		return current
+	}
+	return min(2*current, maxPendingGrowth*initial)
+}
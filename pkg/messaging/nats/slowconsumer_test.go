@@ -0,0 +1,103 @@
+package nats
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"grouter/pkg/telemetry"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestParseSlowConsumerPolicy(t *testing.T) {
+	for name, want := range map[string]SlowConsumerPolicy{
+		"":            SlowConsumerDrop,
+		"drop":        SlowConsumerDrop,
+		"grow":        SlowConsumerGrow,
+		"unsubscribe": SlowConsumerUnsubscribe,
+	} {
+		got, err := ParseSlowConsumerPolicy(name)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	_, err := ParseSlowConsumerPolicy("block")
+	assert.Error(t, err)
+	assert.Equal(t, "grow", SlowConsumerGrow.String())
+}
+
+func TestGrow(t *testing.T) {
+	assert.Equal(t, 20, grow(10, 10))
+	assert.Equal(t, 160, grow(100, 10))
+	assert.Equal(t, 160, grow(160, 10))
+	assert.Equal(t, -1, grow(-1, 10))
+}
+
+// slowSubscription subscribes a blocked handler to subject with opts and
+// publishes more messages than its pending limits hold
+func slowSubscription(t *testing.T, client *Client, subject string, opts *SubscribeOptions) *subscription {
+	t.Helper()
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	sub, err := NewSubscriber(client, "test").SubscribeSubject(subject, func(ctx context.Context, subject string, msg *MessageEnvelope) error {
+		<-release
+		return nil
+	}, opts)
+	require.NoError(t, err)
+
+	publisher := NewPublisher(client, "test")
+	for range 20 {
+		require.NoError(t, publisher.Publish(context.Background(), subject, "test.event", nil, nil))
+	}
+	require.NoError(t, client.Conn().Flush())
+	return sub.(*subscription)
+}
+
+func TestSlowConsumer(t *testing.T) {
+	s := runServer(t, &server.Options{})
+	reg := telemetry.NewMetricsRegistry()
+	client, err := NewNATSClient(Config{
+		URL:               s.ClientURL(),
+		ConnectionTimeout: time.Second,
+		Metrics:           MetricsConfig{Enabled: true, Registry: reg},
+	}, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, client.Connect())
+	defer client.Close()
+	require.NoError(t, client.SlowConsumerCheck())
+
+	t.Run("drop", func(t *testing.T) {
+		handle := slowSubscription(t, client, "test.drop", &SubscribeOptions{PendingMsgs: 2})
+		msgs, _, err := handle.subs[0].PendingLimits()
+		require.NoError(t, err)
+		assert.Equal(t, 2, msgs)
+
+		require.Eventually(t, func() bool { return client.SlowConsumerCheck() != nil }, 2*time.Second, 10*time.Millisecond)
+		assert.ErrorContains(t, client.SlowConsumerCheck(), "test.drop")
+		assert.GreaterOrEqual(t, testutil.ToFloat64(slowConsumerMetric(client).WithLabelValues("test.drop")), float64(1))
+		assert.True(t, handle.subs[0].IsValid())
+	})
+
+	t.Run("grow", func(t *testing.T) {
+		handle := slowSubscription(t, client, "test.grow", &SubscribeOptions{PendingMsgs: 2, SlowConsumer: SlowConsumerGrow})
+		require.Eventually(t, func() bool {
+			msgs, _, err := handle.subs[0].PendingLimits()
+			return err == nil && msgs > 2
+		}, 2*time.Second, 10*time.Millisecond)
+		msgs, _, _ := handle.subs[0].PendingLimits()
+		assert.LessOrEqual(t, msgs, 2*maxPendingGrowth)
+	})
+
+	t.Run("unsubscribe", func(t *testing.T) {
+		handle := slowSubscription(t, client, "test.unsubscribe", &SubscribeOptions{PendingMsgs: 2, SlowConsumer: SlowConsumerUnsubscribe})
+		require.Eventually(t, func() bool { return !handle.subs[0].IsValid() }, 2*time.Second, 10*time.Millisecond)
+		client.slow.mu.Lock()
+		defer client.slow.mu.Unlock()
+		_, ok := client.slow.handlers[handle.subs[0]]
+		assert.False(t, ok)
+	})
+}
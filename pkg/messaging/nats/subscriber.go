@@ -2,11 +2,14 @@ package nats
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
+	"grouter/pkg/telemetry"
+	"grouter/pkg/tenant"
+
 	"github.com/nats-io/nats.go"
 	"go.opentelemetry.io/otel"
 	"go.uber.org/zap"
@@ -14,11 +17,13 @@ import (
 
 // NATSSubscriber handles message subscriptions
 type NATSSubscriber struct {
+	subscribePipeline
 	client        *Client
 	source        string
-	validator     Validator
-	subscriptions []*nats.Subscription
-	middleware    []SubscriberMiddleware
+	payloads      PayloadStore
+	quarantine    *Quarantine
+	poolMetrics   *poolMetrics
+	subscriptions []*subscription
 	mu            sync.Mutex
 	wg            sync.WaitGroup
 }
@@ -28,142 +33,413 @@ func NewSubscriber(client *Client, source string) Subscriber {
 	return &NATSSubscriber{
 		client:        client,
 		source:        source,
-		subscriptions: make([]*nats.Subscription, 0),
-		middleware:    make([]SubscriberMiddleware, 0),
+		subscriptions: make([]*subscription, 0),
 	}
 }
 
-// Use adds middleware to the subscriber
-func (s *NATSSubscriber) Use(mw ...SubscriberMiddleware) {
-	s.middleware = append(s.middleware, mw...)
+// SetPayloadStore sets the store that reference envelopes are fetched from
+func (s *NATSSubscriber) SetPayloadStore(p PayloadStore) {
+	s.payloads = p
+}
+
+// SetQuarantine sets the quarantine of the JetStream messages failing too
+// many deliveries
+func (s *NATSSubscriber) SetQuarantine(q *Quarantine) {
+	s.quarantine = q
 }
 
-// SetValidator sets the validator for the subscriber
-func (s *NATSSubscriber) SetValidator(v Validator) {
-	s.validator = v
+// SetPoolMetrics exports the utilization of the worker pools of the
+// subscriptions made from now on to reg (nil uses the global registry)
+func (s *NATSSubscriber) SetPoolMetrics(reg *telemetry.MetricsRegistry) {
+	s.poolMetrics = newPoolMetrics(reg)
+}
+
+// decodeEnvelope unmarshals and upgrades an envelope received on subject (""
+// for responses), fetching it from the payload store when data is a
+// reference, then decodes and decompresses its data
+func decodeEnvelope(data []byte, subject string, store PayloadStore, codec Codec, envelope *MessageEnvelope) error {
+	if err := unmarshalEnvelope(data, envelope); err != nil {
+		return err
+	}
+	if envelope.Metadata[MetadataPayloadRef] != "" {
+		if err := unspill(context.Background(), store, envelope); err != nil {
+			return err
+		}
+	}
+	if codec != nil {
+		if err := codec.Decode(subject, envelope); err != nil {
+			return err
+		}
+	}
+	return Decompress(envelope)
+}
+
+// extractContext returns a context with the trace context, the tenant and
+// the correlation of the envelope
+func extractContext(env *MessageEnvelope) context.Context {
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), metadataCarrier(env.Metadata))
+	ctx = ContextWithEnvelope(ctx, env)
+	return tenant.NewContext(ctx, env.TenantID())
 }
 
 // Subscribe subscribes to a subject with a handler
 func (s *NATSSubscriber) Subscribe(subject string, handler HandlerFunc, opts *SubscribeOptions) error {
+	_, err := s.SubscribeSubject(subject, handler, opts)
+	return err
+}
 
-	// Setup concurrency control if MaxWorkers is set
-	var sem chan struct{}
-	if opts != nil && opts.MaxWorkers > 0 {
-		sem = make(chan struct{}, opts.MaxWorkers)
+// SubscribeSubject subscribes to a subject with a handler and returns the
+// subscription
+func (s *NATSSubscriber) SubscribeSubject(subject string, handler HandlerFunc, opts *SubscribeOptions) (Subscription, error) {
+	if opts != nil && opts.Priority {
+		return s.subscribeLanes(subject, handler, opts)
 	}
 
-	// Create message handler wrapper
+	// Without MaxWorkers, messages are handled one at a time on the delivery
+	// goroutine of the subscription
 	msgHandler := func(msg *nats.Msg) {
 		s.wg.Add(1)
 		defer s.wg.Done()
+		s.process(msg, handler, false)
+	}
+	var pool *workerPool
+	if opts != nil && opts.MaxWorkers > 0 {
+		pool = s.newPool(subject, opts.MaxWorkers, opts.Ordered, handler)
+		msgHandler = pool.deliver
+	}
 
-		if sem != nil {
-			sem <- struct{}{}
-			defer func() { <-sem }()
+	var queueGroup string
+	if opts != nil {
+		queueGroup = opts.QueueGroup
+	}
+	sub, err := s.subscribe(subject, queueGroup, msgHandler)
+	if err != nil {
+		if pool != nil {
+			pool.stop()
 		}
+		return nil, err
+	}
+
+	// Store subscription
+	handle := s.add(subject, sub)
+	if pool != nil {
+		handle.pools = []*workerPool{pool}
+	}
+	if err := s.limit(handle, opts); err != nil {
+		_ = handle.Unsubscribe()
+		return nil, err
+	}
+
+	s.client.logger.Info("Subscribed to subject",
+		zap.String("subject", subject),
+		zap.String("queue_group", func() string {
+			if opts != nil {
+				return opts.QueueGroup
+			}
+			return ""
+		}()),
+		zap.Int("max_workers", func() int {
+			if opts != nil {
+				return opts.MaxWorkers
+			}
+			return 0
+		}()),
+	)
+
+	return handle, nil
+}
+
+// newPool starts a worker pool of n workers handling the messages of subject
+// with handler, in order per partition key when ordered
+func (s *NATSSubscriber) newPool(subject string, n int, ordered bool, handler HandlerFunc) *workerPool {
+	return newWorkerPool(subject, n, ordered, s.poolMetrics, func(msg *nats.Msg) {
+		s.wg.Add(1)
+		defer s.wg.Done()
+		s.process(msg, handler, false)
+	})
+}
 
-		// Unmarshal envelope
-		var envelope MessageEnvelope
-		if err := json.Unmarshal(msg.Data, &envelope); err != nil {
-			s.client.logger.Error("Failed to unmarshal message",
+// subscribe subscribes msgHandler to subject, with or without queue group
+func (s *NATSSubscriber) subscribe(subject, queueGroup string, msgHandler nats.MsgHandler) (*nats.Subscription, error) {
+	var sub *nats.Subscription
+	var err error
+	if queueGroup != "" {
+		sub, err = s.client.Conn().QueueSubscribe(subject, queueGroup, msgHandler)
+	} else {
+		sub, err = s.client.Conn().Subscribe(subject, msgHandler)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe: %w", err)
+	}
+	return sub, nil
+}
+
+// process is the pipeline shared by core NATS and JetStream messages: it
+// decodes and validates msg and passes it to handler through the middleware.
+// JetStream messages are acknowledged per the AckPolicy of the handler (see
+// WithAckPolicy), which gets their Acker from its context; those that cannot
+// be decoded or validated are not acked, so they go back to the stream after
+// AckWait until MaxDeliver. With a quarantine, the messages failing their
+// last delivery are quarantined and terminated instead.
+func (s *NATSSubscriber) process(msg *nats.Msg, handler HandlerFunc, jetStream bool) {
+	var acker *jsAcker
+	if jetStream {
+		acker = newJSAcker(msg)
+	}
+
+	// Unmarshal envelope
+	var envelope MessageEnvelope
+	if err := decodeEnvelope(msg.Data, msg.Subject, s.payloads, s.codec, &envelope); err != nil {
+		s.client.logger.Error("Failed to unmarshal message",
+			zap.Error(err),
+			zap.String("subject", msg.Subject),
+			zap.Bool("jetstream", jetStream),
+		)
+		s.fail(msg, acker, err)
+		return
+	}
+
+	// ✅ capture NATS reply subject for request-reply, before the context
+	// tracing the replies. The reply subject of JetStream messages is their
+	// ack subject.
+	request := msg.Reply != "" && !jetStream
+	if request {
+		envelope.Reply = msg.Reply
+	}
+
+	// Extract trace context and tenant
+	ctx := extractContext(&envelope)
+	if acker != nil {
+		ctx = ContextWithAcker(ctx, acker)
+	}
+
+	// Requests are handled until their requester stops waiting
+	if request {
+		var cancel context.CancelFunc
+		ctx, cancel = withRequestDeadline(ctx, &envelope)
+		defer cancel()
+	}
+
+	if err := s.validate(s.client.logger, msg.Subject, &envelope, zap.Bool("jetstream", jetStream)); err != nil {
+		s.fail(msg, acker, err)
+		return
+	}
+
+	// Handle message
+	err := handle(ctx, s.client.logger, msg.Subject, &envelope, s.chain(handler), zap.Bool("jetstream", jetStream))
+	if err != nil {
+		s.fail(msg, acker, err)
+	} else if acker != nil && s.quarantine != nil {
+		s.quarantine.forget(msg)
+	}
+
+	if acker != nil {
+		if err := acker.finish(err); err != nil {
+			s.client.logger.Error("Failed to acknowledge JetStream message",
 				zap.Error(err),
 				zap.String("subject", msg.Subject),
+				zap.String("message_id", envelope.ID),
 			)
-			return
 		}
+	}
+}
 
-		// Extract trace context
-		ctx := otel.GetTextMapPropagator().Extract(context.Background(), metadataCarrier(envelope.Metadata))
+// subscription is a subscription of a NATSSubscriber. subject is the one
+// subscribed to: JetStream push subscriptions receive on an inbox, and
+// priority subscriptions on several lanes.
+type subscription struct {
+	subscriber *NATSSubscriber
+	subject    string
+	subs       []*nats.Subscription
+	// pools are the worker pools, none without MaxWorkers or lanes
+	pools []*workerPool
+	// removers unregister the slow consumer handlers
+	removers []func()
+}
+
+func (s *subscription) Subject() string {
+	return s.subject
+}
 
-		// ✅ capture NATS reply subject for request-reply
-		if msg.Reply != "" {
-			envelope.Reply = msg.Reply
+// Unsubscribe removes the subscription from the subscriber
+func (s *subscription) Unsubscribe() error {
+	s.subscriber.mu.Lock()
+	for i, other := range s.subscriber.subscriptions {
+		if other == s {
+			s.subscriber.subscriptions = append(s.subscriber.subscriptions[:i], s.subscriber.subscriptions[i+1:]...)
+			break
 		}
+	}
+	s.subscriber.mu.Unlock()
+	return s.unsubscribe()
+}
 
-		// Validate data if validator is set
-		if s.validator != nil {
-			if err := s.validator.Validate(envelope.Type, envelope.Data); err != nil {
-				s.client.logger.Error("Validation failed",
-					zap.Error(err),
-					zap.String("subject", msg.Subject),
-					zap.String("type", envelope.Type),
-					zap.String("id", envelope.ID),
-				)
-				return
-			}
+// unsubscribe removes the subscription from the server, which it may
+// already be, and waits for the worker pools to handle the messages queued.
+// It is called without the lock of the subscriber, which the handlers may
+// need.
+func (s *subscription) unsubscribe() error {
+	var errs []error
+	for _, sub := range s.subs {
+		if err := sub.Unsubscribe(); err != nil && !errors.Is(err, nats.ErrBadSubscription) {
+			errs = append(errs, fmt.Errorf("failed to unsubscribe from %s: %w", sub.Subject, err))
 		}
+	}
+	for _, pool := range s.pools {
+		pool.stop()
+	}
+	for _, remove := range s.removers {
+		remove()
+	}
+	return errors.Join(errs...)
+}
 
-		s.client.logger.Debug("Received message",
-			zap.String("subject", msg.Subject),
-			zap.String("type", envelope.Type),
-			zap.String("id", envelope.ID),
-			zap.String("reply", envelope.Reply),
-		)
+// drainPoll is the interval at which Drain checks the subscriptions
+const drainPoll = 10 * time.Millisecond
 
-		// Apply middleware
-		h := handler
-		for i := len(s.middleware) - 1; i >= 0; i-- {
-			h = s.middleware[i](h)
+// Drain removes the interest of the subscription from the server and waits
+// for the messages received, which the handler still gets. The subscription
+// stays in the subscriber until Unsubscribe.
+func (s *subscription) Drain(ctx context.Context) error {
+	for _, sub := range s.subs {
+		if err := sub.Drain(); err != nil && !errors.Is(err, nats.ErrBadSubscription) {
+			return fmt.Errorf("failed to drain %s: %w", sub.Subject, err)
 		}
+	}
+	ticker := time.NewTicker(drainPoll)
+	defer ticker.Stop()
+	for !s.drained() {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed to drain %s: %w", s.subject, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
 
-		// Handle message
-		if err := h(ctx, msg.Subject, &envelope); err != nil {
-			s.client.logger.Error("Handler error",
-				zap.Error(err),
-				zap.String("subject", msg.Subject),
-				zap.String("message_id", envelope.ID),
-			)
+// drained reports whether the server subscriptions are drained and the
+// worker pools have no message queued or being handled
+func (s *subscription) drained() bool {
+	for _, sub := range s.subs {
+		if sub.IsValid() {
+			return false
+		}
+	}
+	for _, pool := range s.pools {
+		if !pool.idle() {
+			return false
 		}
 	}
+	return true
+}
 
-	var sub *nats.Subscription
-	var err error
+// QueueDepth returns the number of messages of the subscription received
+// and waiting to be handled, its lag
+func (s *subscription) QueueDepth() int {
+	n := 0
+	for _, sub := range s.subs {
+		// Unsubscribed subscriptions have nothing pending
+		if msgs, _, err := sub.Pending(); err == nil {
+			n += msgs
+		}
+	}
+	for _, pool := range s.pools {
+		n += pool.depth()
+	}
+	return n
+}
 
-	// Subscribe with or without queue group
-	if opts != nil && opts.QueueGroup != "" {
-		sub, err = s.client.Conn().QueueSubscribe(subject, opts.QueueGroup, msgHandler)
-	} else {
-		sub, err = s.client.Conn().Subscribe(subject, msgHandler)
+// QueueDepth returns the number of messages received and waiting to be
+// handled, over all subscriptions
+func (s *NATSSubscriber) QueueDepth() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, sub := range s.subscriptions {
+		n += sub.QueueDepth()
 	}
+	return n
+}
 
+// fail hands the failed JetStream message to the quarantine, terminating it
+// once quarantined. Messages settled by their handler, or under AckManual,
+// are left alone.
+func (s *NATSSubscriber) fail(msg *nats.Msg, acker *jsAcker, cause error) {
+	if acker == nil || s.quarantine == nil || !acker.owned() {
+		return
+	}
+	quarantined, err := s.quarantine.fail(msg, cause)
 	if err != nil {
-		return fmt.Errorf("failed to subscribe: %w", err)
+		s.client.logger.Error("Failed to quarantine message",
+			zap.Error(err),
+			zap.String("subject", msg.Subject),
+		)
+		return
 	}
+	if quarantined {
+		if err := acker.Term(); err != nil {
+			s.client.logger.Error("Failed to terminate quarantined message",
+				zap.Error(err),
+				zap.String("subject", msg.Subject),
+			)
+		}
+	}
+}
 
-	// Store subscription
+// add stores the subscriptions subs on subject
+func (s *NATSSubscriber) add(subject string, subs ...*nats.Subscription) *subscription {
+	handle := &subscription{subscriber: s, subject: subject, subs: subs}
 	s.mu.Lock()
-	s.subscriptions = append(s.subscriptions, sub)
+	s.subscriptions = append(s.subscriptions, handle)
 	s.mu.Unlock()
-
-	s.client.logger.Info("Subscribed to subject",
-		zap.String("subject", subject),
-		zap.String("queue_group", func() string {
-			if opts != nil {
-				return opts.QueueGroup
-			}
-			return ""
-		}()),
-	)
-
-	return nil
+	return handle
 }
 
 // Unsubscribe unsubscribes from all subscriptions
 func (s *NATSSubscriber) Unsubscribe() error {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	subscriptions := s.subscriptions
+	s.subscriptions = make([]*subscription, 0)
+	s.mu.Unlock()
 
-	for _, sub := range s.subscriptions {
-		if err := sub.Unsubscribe(); err != nil {
+	for _, sub := range subscriptions {
+		if err := sub.unsubscribe(); err != nil {
 			s.client.logger.Error("Failed to unsubscribe", zap.Error(err))
 		}
 	}
-
-	s.subscriptions = make([]*nats.Subscription, 0)
 	s.client.logger.Info("Unsubscribed from all subjects")
 	return nil
 }
 
+// UnsubscribeSubject removes the subscriptions on subject, core and
+// JetStream alike, leaving the others in place. It does nothing when there
+// is none.
+func (s *NATSSubscriber) UnsubscribeSubject(subject string) error {
+	s.mu.Lock()
+	var removed []*subscription
+	kept := s.subscriptions[:0]
+	for _, sub := range s.subscriptions {
+		if sub.subject != subject {
+			kept = append(kept, sub)
+			continue
+		}
+		removed = append(removed, sub)
+	}
+	clear(s.subscriptions[len(kept):])
+	s.subscriptions = kept
+	s.mu.Unlock()
+
+	var errs []error
+	for _, sub := range removed {
+		errs = append(errs, sub.unsubscribe())
+	}
+	if len(removed) > 0 {
+		s.client.logger.Info("Unsubscribed from subject", zap.String("subject", subject))
+	}
+	return errors.Join(errs...)
+}
+
 // SubscribePush subscribes to a JetStream subject with a handler
 func (s *NATSSubscriber) SubscribePush(subject string, handler HandlerFunc, opts ...nats.SubOpt) error {
 	js, err := s.client.JetStream()
@@ -171,89 +447,21 @@ func (s *NATSSubscriber) SubscribePush(subject string, handler HandlerFunc, opts
 		return err
 	}
 
-	// Create message handler wrapper
 	msgHandler := func(msg *nats.Msg) {
 		s.wg.Add(1)
 		defer s.wg.Done()
-
-		// Unmarshal envelope
-		var envelope MessageEnvelope
-		if err := json.Unmarshal(msg.Data, &envelope); err != nil {
-			s.client.logger.Error("Failed to unmarshal JetStream message",
-				zap.Error(err),
-				zap.String("subject", msg.Subject),
-			)
-			// We don't Ack here, so it will be redelivered based on AckWait
-			return
-		}
-
-		// Extract trace context
-		ctx := otel.GetTextMapPropagator().Extract(context.Background(), metadataCarrier(envelope.Metadata))
-
-		// Capture NATS reply subject
-		if msg.Reply != "" {
-			envelope.Reply = msg.Reply
-		}
-
-		// Validate data if validator is set
-		if s.validator != nil {
-			if err := s.validator.Validate(envelope.Type, envelope.Data); err != nil {
-				s.client.logger.Error("JetStream validation failed",
-					zap.Error(err),
-					zap.String("subject", msg.Subject),
-					zap.String("type", envelope.Type),
-					zap.String("id", envelope.ID),
-				)
-				// We don't Ack here, so it will be redelivered or go to DLQ
-				return
-			}
-		}
-
-		s.client.logger.Debug("Received JetStream message",
-			zap.String("subject", msg.Subject),
-			zap.String("type", envelope.Type),
-			zap.String("id", envelope.ID),
-		)
-
-		// Apply middleware
-		h := handler
-		for i := len(s.middleware) - 1; i >= 0; i-- {
-			h = s.middleware[i](h)
-		}
-
-		// Handle message
-		if err := h(ctx, msg.Subject, &envelope); err != nil {
-			s.client.logger.Error("JetStream handler error",
-				zap.Error(err),
-				zap.String("subject", msg.Subject),
-				zap.String("message_id", envelope.ID),
-			)
-			// Explicitly Nak to trigger redelivery
-			if err := msg.Nak(); err != nil {
-				s.client.logger.Error("Failed to nak JetStream message", zap.Error(err))
-			}
-			return
-		}
-
-		// Acknowledge message
-		if err := msg.Ack(); err != nil {
-			s.client.logger.Error("Failed to ack JetStream message",
-				zap.Error(err),
-				zap.String("subject", msg.Subject),
-				zap.String("message_id", envelope.ID),
-			)
-		}
+		s.process(msg, handler, true)
 	}
 
+	// The pipeline acknowledges, the library must not ack after the handler
+	opts = append(opts, nats.ManualAck())
 	sub, err := js.Subscribe(subject, msgHandler, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to subscribe to JetStream: %w", err)
 	}
 
 	// Store subscription
-	s.mu.Lock()
-	s.subscriptions = append(s.subscriptions, sub)
-	s.mu.Unlock()
+	s.add(subject, sub)
 
 	s.client.logger.Info("Subscribed to JetStream subject",
 		zap.String("subject", subject),
@@ -262,11 +470,12 @@ func (s *NATSSubscriber) SubscribePush(subject string, handler HandlerFunc, opts
 	return nil
 }
 
-// SubscribePull subscribes to a JetStream subject using a pull consumer
-func (s *NATSSubscriber) SubscribePull(subject, durable string, handler HandlerFunc, opts ...PullOption) error {
+// SubscribePull subscribes to a JetStream subject using a pull consumer and
+// returns the subscription
+func (s *NATSSubscriber) SubscribePull(subject, durable string, handler HandlerFunc, opts ...PullOption) (Subscription, error) {
 	js, err := s.client.JetStream()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Default options
@@ -283,13 +492,11 @@ func (s *NATSSubscriber) SubscribePull(subject, durable string, handler HandlerF
 	// Create pull subscription
 	sub, err := js.PullSubscribe(subject, durable)
 	if err != nil {
-		return fmt.Errorf("failed to create pull subscription: %w", err)
+		return nil, fmt.Errorf("failed to create pull subscription: %w", err)
 	}
 
 	// Store subscription
-	s.mu.Lock()
-	s.subscriptions = append(s.subscriptions, sub)
-	s.mu.Unlock()
+	handle := s.add(subject, sub)
 
 	s.client.logger.Info("Created pull subscription",
 		zap.String("subject", subject),
@@ -329,83 +536,12 @@ func (s *NATSSubscriber) SubscribePull(subject, durable string, handler HandlerF
 
 			// Process batch
 			for _, msg := range msgs {
-				s.processJetStreamMessage(msg, handler)
+				s.process(msg, handler, true)
 			}
 		}
 	}()
 
-	return nil
-}
-
-// processJetStreamMessage handles a single JetStream message
-func (s *NATSSubscriber) processJetStreamMessage(msg *nats.Msg, handler HandlerFunc) {
-	// Unmarshal envelope
-	var envelope MessageEnvelope
-	if err := json.Unmarshal(msg.Data, &envelope); err != nil {
-		s.client.logger.Error("Failed to unmarshal JetStream message",
-			zap.Error(err),
-			zap.String("subject", msg.Subject),
-		)
-		// We don't Ack here, so it will be redelivered based on AckWait
-		return
-	}
-
-	// Extract trace context
-	ctx := otel.GetTextMapPropagator().Extract(context.Background(), metadataCarrier(envelope.Metadata))
-
-	// Capture NATS reply subject
-	if msg.Reply != "" {
-		envelope.Reply = msg.Reply
-	}
-
-	// Validate data if validator is set
-	if s.validator != nil {
-		if err := s.validator.Validate(envelope.Type, envelope.Data); err != nil {
-			s.client.logger.Error("JetStream validation failed",
-				zap.Error(err),
-				zap.String("subject", msg.Subject),
-				zap.String("type", envelope.Type),
-				zap.String("id", envelope.ID),
-			)
-			// We don't Ack here, so it will be redelivered or go to DLQ
-			return
-		}
-	}
-
-	s.client.logger.Debug("Received JetStream message",
-		zap.String("subject", msg.Subject),
-		zap.String("type", envelope.Type),
-		zap.String("id", envelope.ID),
-	)
-
-	// Apply middleware
-	h := handler
-	for i := len(s.middleware) - 1; i >= 0; i-- {
-		h = s.middleware[i](h)
-	}
-
-	// Handle message
-	if err := h(ctx, msg.Subject, &envelope); err != nil {
-		s.client.logger.Error("JetStream handler error",
-			zap.Error(err),
-			zap.String("subject", msg.Subject),
-			zap.String("message_id", envelope.ID),
-		)
-		// Explicitly Nak to trigger redelivery
-		if err := msg.Nak(); err != nil {
-			s.client.logger.Error("Failed to nak JetStream message", zap.Error(err))
-		}
-		return
-	}
-
-	// Acknowledge message
-	if err := msg.Ack(); err != nil {
-		s.client.logger.Error("Failed to ack JetStream message",
-			zap.Error(err),
-			zap.String("subject", msg.Subject),
-			zap.String("message_id", envelope.ID),
-		)
-	}
+	return handle, nil
 }
 
 // Close closes the subscriber and unsubscribes from all subjects
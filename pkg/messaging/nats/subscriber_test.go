@@ -4,10 +4,18 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"grouter/pkg/telemetry"
+	"grouter/pkg/tenant"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
 
@@ -321,6 +329,175 @@ func TestSubscriber_Unsubscribe(t *testing.T) {
 	}
 }
 
+func TestSubscriber_SubscribeSubject(t *testing.T) {
+	s, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1, NoLog: true, NoSigs: true})
+	require.NoError(t, err)
+	go s.Start()
+	require.True(t, s.ReadyForConnections(5*time.Second))
+	defer s.Shutdown()
+
+	client, err := NewNATSClient(Config{URL: s.ClientURL(), ConnectionTimeout: time.Second}, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, client.Connect())
+	defer client.Close()
+
+	subscriber := NewSubscriber(client, "test-subscriber")
+	publisher := NewPublisher(client, "test-service")
+
+	var mu sync.Mutex
+	received := map[string]int{}
+	handler := func(ctx context.Context, subject string, msg *MessageEnvelope) error {
+		mu.Lock()
+		defer mu.Unlock()
+		received[subject]++
+		return nil
+	}
+	count := func(subject string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return received[subject]
+	}
+
+	orders, err := subscriber.SubscribeSubject("test.orders", handler, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "test.orders", orders.Subject())
+	require.NoError(t, subscriber.Subscribe("test.payments", handler, nil))
+
+	// Only the orders subscription is removed
+	require.NoError(t, orders.Unsubscribe())
+	require.NoError(t, publisher.Publish(context.Background(), "test.orders", "test.event", nil, nil))
+	require.NoError(t, publisher.Publish(context.Background(), "test.payments", "test.event", nil, nil))
+	require.Eventually(t, func() bool { return count("test.payments") == 1 }, 2*time.Second, 10*time.Millisecond)
+	assert.Zero(t, count("test.orders"))
+
+	// Unsubscribing twice, or after Unsubscribe, is harmless
+	assert.NoError(t, orders.Unsubscribe())
+	require.NoError(t, subscriber.Unsubscribe())
+}
+
+func TestSubscriber_UnsubscribeSubject(t *testing.T) {
+	s, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1, NoLog: true, NoSigs: true, JetStream: true, StoreDir: t.TempDir()})
+	require.NoError(t, err)
+	go s.Start()
+	require.True(t, s.ReadyForConnections(5*time.Second))
+	defer s.Shutdown()
+
+	client, err := NewNATSClient(Config{URL: s.ClientURL(), ConnectionTimeout: time.Second}, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, client.Connect())
+	defer client.Close()
+	js, err := client.JetStream()
+	require.NoError(t, err)
+	_, err = js.AddStream(&nats.StreamConfig{Name: "EVENTS", Subjects: []string{"events.>"}})
+	require.NoError(t, err)
+
+	subscriber := NewSubscriber(client, "test-subscriber").(*NATSSubscriber)
+	handler := func(ctx context.Context, subject string, msg *MessageEnvelope) error { return nil }
+	require.NoError(t, subscriber.Subscribe("test.orders", handler, nil))
+	require.NoError(t, subscriber.Subscribe("test.orders", handler, &SubscribeOptions{QueueGroup: "workers"}))
+	require.NoError(t, subscriber.Subscribe("test.payments", handler, nil))
+	require.NoError(t, subscriber.SubscribePush("events.orders", handler))
+
+	subjects := func() []string {
+		subscriber.mu.Lock()
+		defer subscriber.mu.Unlock()
+		var out []string
+		for _, sub := range subscriber.subscriptions {
+			out = append(out, sub.subject)
+		}
+		return out
+	}
+
+	require.NoError(t, subscriber.UnsubscribeSubject("test.orders"))
+	assert.Equal(t, []string{"test.payments", "events.orders"}, subjects())
+
+	require.NoError(t, subscriber.UnsubscribeSubject("events.orders"))
+	assert.Equal(t, []string{"test.payments"}, subjects())
+
+	assert.NoError(t, subscriber.UnsubscribeSubject("test.unknown"), "nothing to remove")
+	assert.Equal(t, []string{"test.payments"}, subjects())
+}
+
+func TestSubscription_Drain(t *testing.T) {
+	client := runPoolServer(t)
+	publisher := NewPublisher(client, "test-service")
+	leaving := NewSubscriber(client, "leaving")
+	staying := NewSubscriber(client, "staying")
+
+	var mu sync.Mutex
+	received := map[string]int{}
+	handler := func(name string) HandlerFunc {
+		return func(ctx context.Context, subject string, msg *MessageEnvelope) error {
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			defer mu.Unlock()
+			received[name]++
+			return nil
+		}
+	}
+	count := func(name string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return received[name]
+	}
+
+	sub, err := leaving.SubscribeSubject("test.orders", handler("leaving"),
+		&SubscribeOptions{QueueGroup: "workers", MaxWorkers: 2})
+	require.NoError(t, err)
+	require.NoError(t, staying.Subscribe("test.orders", handler("staying"), &SubscribeOptions{QueueGroup: "workers"}))
+
+	for range 50 {
+		require.NoError(t, publisher.Publish(context.Background(), "test.orders", "order.created", nil, nil))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, sub.Drain(ctx))
+	require.Eventually(t, func() bool { return count("leaving")+count("staying") == 50 }, 2*time.Second, 10*time.Millisecond,
+		"the messages received before the drain are handled")
+
+	before := count("leaving")
+	for range 10 {
+		require.NoError(t, publisher.Publish(context.Background(), "test.orders", "order.created", nil, nil))
+	}
+	require.Eventually(t, func() bool { return count("staying")+before == 60 }, 2*time.Second, 10*time.Millisecond,
+		"the other members of the queue group get the new messages")
+	assert.Equal(t, before, count("leaving"))
+
+	// Draining again, or unsubscribing after the drain, is harmless
+	assert.NoError(t, sub.Drain(ctx))
+	assert.NoError(t, sub.Unsubscribe())
+
+	// Drain gives up with ctx while the handler holds up the queue
+	release := make(chan struct{})
+	blocked, err := leaving.SubscribeSubject("test.blocked", func(ctx context.Context, subject string, msg *MessageEnvelope) error {
+		<-release
+		return nil
+	}, &SubscribeOptions{MaxWorkers: 1})
+	require.NoError(t, err)
+	defer close(release)
+	for range 5 {
+		require.NoError(t, publisher.Publish(context.Background(), "test.blocked", "order.created", nil, nil))
+	}
+	require.NoError(t, client.Conn().Flush())
+	short, cancelShort := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelShort()
+	assert.ErrorIs(t, blocked.Drain(short), context.DeadlineExceeded)
+
+	// and while a handler is busy with the last message
+	busy := make(chan struct{})
+	last, err := leaving.SubscribeSubject("test.last", func(ctx context.Context, subject string, msg *MessageEnvelope) error {
+		close(busy)
+		<-release
+		return nil
+	}, &SubscribeOptions{MaxWorkers: 1})
+	require.NoError(t, err)
+	require.NoError(t, publisher.Publish(context.Background(), "test.last", "order.created", nil, nil))
+	<-busy
+	shortLast, cancelLast := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelLast()
+	assert.ErrorIs(t, last.Drain(shortLast), context.DeadlineExceeded)
+}
+
 func TestSubscriber_HandlerError(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping integration test")
@@ -551,3 +728,167 @@ func TestSubscriber_GracefulShutdown(t *testing.T) {
 		t.Error("Handler did not finish before Close() returned")
 	}
 }
+
+func TestSubscriber_Tenant(t *testing.T) {
+	s, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1, NoLog: true, NoSigs: true})
+	require.NoError(t, err)
+	go s.Start()
+	require.True(t, s.ReadyForConnections(5*time.Second))
+	defer s.Shutdown()
+
+	client, err := NewNATSClient(Config{URL: s.ClientURL(), ConnectionTimeout: time.Second}, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, client.Connect())
+	defer client.Close()
+
+	subscriber := NewSubscriber(client, "test-subscriber")
+	publisher := NewPublisher(client, "test-service")
+
+	tenants := make(chan [2]string, 1)
+	require.NoError(t, subscriber.Subscribe("test.tenant", func(ctx context.Context, subject string, msg *MessageEnvelope) error {
+		tenants <- [2]string{msg.TenantID(), tenant.FromContext(ctx)}
+		return nil
+	}, nil))
+
+	ctx := tenant.NewContext(context.Background(), "acme")
+	require.NoError(t, publisher.Publish(ctx, "test.tenant", "test.event", nil, nil))
+	select {
+	case got := <-tenants:
+		assert.Equal(t, [2]string{"acme", "acme"}, got, "envelope and handler context carry the tenant")
+	case <-time.After(2 * time.Second):
+		t.Fatal("message not received")
+	}
+}
+
+func TestSubscriber_ExpiredMessages(t *testing.T) {
+	s, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1, NoLog: true, NoSigs: true})
+	require.NoError(t, err)
+	go s.Start()
+	require.True(t, s.ReadyForConnections(5*time.Second))
+	defer s.Shutdown()
+
+	client, err := NewNATSClient(Config{URL: s.ClientURL(), ConnectionTimeout: time.Second}, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, client.Connect())
+	defer client.Close()
+
+	subscriber := NewSubscriber(client, "test-subscriber")
+	subscriber.Use(ExpiryMiddleware(zap.NewNop(), telemetry.NewMetricsRegistry()))
+	publisher := NewPublisher(client, "test-service")
+
+	handled := make(chan *MessageEnvelope, 2)
+	require.NoError(t, subscriber.Subscribe("test.ttl", func(ctx context.Context, subject string, msg *MessageEnvelope) error {
+		handled <- msg
+		return nil
+	}, nil))
+
+	ctx := context.Background()
+	require.NoError(t, publisher.Publish(ctx, "test.ttl", "stale", nil, &PublishOptions{TTL: time.Nanosecond}))
+	require.NoError(t, publisher.Publish(ctx, "test.ttl", "fresh", nil, &PublishOptions{TTL: time.Minute}))
+	select {
+	case msg := <-handled:
+		assert.Equal(t, "fresh", msg.Type, "the expired message is dropped")
+		assert.WithinDuration(t, msg.Timestamp.Add(time.Minute), msg.ExpiresAt, time.Millisecond)
+	case <-time.After(2 * time.Second):
+		t.Fatal("message not received")
+	}
+}
+
+func TestSubscriber_PipelineParity(t *testing.T) {
+	client, js := runReplayServer(t)
+	_, err := js.AddStream(&nats.StreamConfig{Name: "JOBS", Subjects: []string{"jobs.>"}, Storage: nats.MemoryStorage})
+	require.NoError(t, err)
+
+	keys, err := NewKeyRing(EncryptionConfig{KeyID: "k1", Keys: []EncryptionKey{{ID: "k1", Key: testEncryptionKey('k')}}})
+	require.NoError(t, err)
+	codec := NewEncryptingCodec(keys, nil)
+	compressor, err := NewCompressor(CompressionConfig{Threshold: 16}, telemetry.NewMetricsRegistry())
+	require.NoError(t, err)
+	publisher := NewPublisher(client, "test-service")
+	publisher.SetCodec(codec)
+	publisher.SetCompressor(compressor)
+
+	validator := NewMapValidator()
+	validator.Register("invalid", func([]byte) error { return fmt.Errorf("invalid") })
+
+	type delivery struct {
+		data, reply string
+		middleware  bool
+	}
+	subscribe := map[string]func(s Subscriber, subject string, h HandlerFunc) error{
+		"core": func(s Subscriber, subject string, h HandlerFunc) error { return s.Subscribe(subject, h, nil) },
+		"push": func(s Subscriber, subject string, h HandlerFunc) error {
+			return s.SubscribePush(subject, h, nats.AckWait(200*time.Millisecond))
+		},
+		"pull": func(s Subscriber, subject string, h HandlerFunc) error {
+			_, err := s.SubscribePull(subject, "pull", h, WithFetchTimeout(100*time.Millisecond))
+			return err
+		},
+	}
+	for mode, sub := range subscribe {
+		t.Run(mode, func(t *testing.T) {
+			subject := "jobs." + mode
+			subscriber := NewSubscriber(client, "test-subscriber")
+			defer subscriber.Close()
+			subscriber.SetCodec(codec)
+			subscriber.SetValidator(validator)
+			subscriber.Use(func(next HandlerFunc) HandlerFunc {
+				return func(ctx context.Context, subject string, msg *MessageEnvelope) error {
+					msg.Metadata["middleware"] = "yes"
+					return next(ctx, subject, msg)
+				}
+			})
+
+			received := make(chan delivery, 10)
+			var attempts sync.Map
+			require.NoError(t, sub(subscriber, subject, func(ctx context.Context, subject string, msg *MessageEnvelope) error {
+				var data string
+				require.NoError(t, json.Unmarshal(msg.Data, &data))
+				received <- delivery{data: data, reply: msg.Reply, middleware: msg.Metadata["middleware"] == "yes"}
+				n, _ := attempts.LoadOrStore(msg.ID, new(int))
+				*n.(*int)++
+				if msg.Type == "fail" && *n.(*int) == 1 {
+					return ErrHandlerFailed
+				}
+				return nil
+			}))
+
+			ctx := context.Background()
+			long := strings.Repeat("compressed ", 10)
+			publish := func(msgType, data string) {
+				if mode == "core" {
+					require.NoError(t, publisher.Publish(ctx, subject, msgType, data, nil))
+					return
+				}
+				_, err := publisher.PublishJS(ctx, subject, msgType, data)
+				require.NoError(t, err)
+			}
+			publish("invalid", "dropped")
+			publish("job", long)
+			publish("fail", "retried")
+
+			next := func() delivery {
+				select {
+				case d := <-received:
+					return d
+				case <-time.After(3 * time.Second):
+					t.Fatal("message not received")
+					return delivery{}
+				}
+			}
+			d := next()
+			assert.Equal(t, long, d.data, "decrypted and decompressed")
+			assert.True(t, d.middleware)
+			assert.Empty(t, d.reply, "the ack subject is not a reply subject")
+			assert.Equal(t, "retried", next().data)
+			if mode != "core" {
+				assert.Equal(t, "retried", next().data, "nacked on handler errors")
+			}
+			select {
+			case d := <-received:
+				t.Fatalf("unexpected delivery of %q", d.data)
+			case <-time.After(300 * time.Millisecond):
+			}
+		})
+	}
+}
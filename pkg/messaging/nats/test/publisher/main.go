@@ -12,7 +12,6 @@ import (
 	messaging "grouter/pkg/messaging/nats"
 	"grouter/pkg/telemetry"
 
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/viper"
 	"go.opentelemetry.io/otel"
 	"go.uber.org/zap"
@@ -32,6 +31,7 @@ func main() {
 	sSubject := flag.String("subject", "gRouter.natsdemosvc.echo", "NATS subject to publish to")
 	sType := flag.String("type", "echo.request", "Message type")
 	sData := flag.String("data", "{}", "JSON data payload")
+	metricsAddr := flag.String("metrics-addr", ":8082", "Address of the metrics endpoint (empty disables it)")
 	flag.Parse()
 
 	// Load Configuration
@@ -97,9 +97,10 @@ func main() {
 	pub.Use(messaging.PublisherLoggingMiddleware(logger))
 	pub.UseRequest(messaging.RequestLoggingMiddleware(logger))
 
-	// Use Metrics Middleware
-	pub.Use(messaging.PublisherMetricsMiddleware())
-	pub.UseRequest(messaging.RequestMetricsMiddleware())
+	// Use Metrics Middleware, recorded in a registry owned by this binary
+	metrics := telemetry.NewMetricsRegistry()
+	pub.Use(messaging.PublisherMetricsMiddleware(metrics))
+	pub.UseRequest(messaging.RequestMetricsMiddleware(metrics))
 
 	// Use Tracing Middleware
 	if cfg.Tracing.Enabled {
@@ -128,14 +129,17 @@ func main() {
 		// I will just use it for Publish.
 	}
 
-	// Start Metrics Server
-	go func() {
-		http.Handle("/metrics", promhttp.Handler())
-		logger.Info("Metrics server starting on :8082")
-		if err := http.ListenAndServe(":8082", nil); err != nil {
-			logger.Error("Metrics server failed", zap.Error(err))
-		}
-	}()
+	// Serve the metrics registry
+	if *metricsAddr != "" {
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", metrics.Handler())
+			logger.Info("Metrics server starting", zap.String("addr", *metricsAddr))
+			if err := http.ListenAndServe(*metricsAddr, mux); err != nil {
+				logger.Error("Metrics server failed", zap.Error(err))
+			}
+		}()
+	}
 
 	// Topic and Payload
 	topic := *sSubject
@@ -14,7 +14,6 @@ import (
 	messaging "grouter/pkg/messaging/nats"
 	"grouter/pkg/telemetry"
 
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/viper"
 	"go.opentelemetry.io/otel"
 	"go.uber.org/zap"
@@ -31,6 +30,7 @@ func main() {
 	// Parse flags
 	queueGroup := flag.String("queue", "", "Queue group name for load balancing")
 	maxWorkers := flag.Int("workers", 0, "Max concurrent workers")
+	metricsAddr := flag.String("metrics-addr", ":8081", "Address of the metrics endpoint (empty disables it)")
 	flag.Parse()
 
 	// Load Configuration
@@ -92,9 +92,9 @@ func main() {
 	// Use Logging Middleware
 	sub.Use(messaging.LoggingMiddleware(logger))
 
-	// Use Metrics Middleware (if enabled in config, though we force enabled in this test logic for now or check rootCfg if available)
-	// We will just enable it to test
-	sub.Use(messaging.MetricsMiddleware())
+	// Use Metrics Middleware, recorded in a registry owned by this binary
+	metrics := telemetry.NewMetricsRegistry()
+	sub.Use(messaging.MetricsMiddleware(metrics))
 
 	if cfg.Tracing.Enabled {
 		tracer := otel.Tracer("nats-subscriber")
@@ -109,14 +109,17 @@ func main() {
 		zap.Int("max_workers", *maxWorkers),
 	)
 
-	// Start Metrics Server
-	go func() {
-		http.Handle("/metrics", promhttp.Handler())
-		logger.Info("Metrics server starting on :8081")
-		if err := http.ListenAndServe(":8081", nil); err != nil {
-			logger.Error("Metrics server failed", zap.Error(err))
-		}
-	}()
+	// Serve the metrics registry
+	if *metricsAddr != "" {
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", metrics.Handler())
+			logger.Info("Metrics server starting", zap.String("addr", *metricsAddr))
+			if err := http.ListenAndServe(*metricsAddr, mux); err != nil {
+				logger.Error("Metrics server failed", zap.Error(err))
+			}
+		}()
+	}
 
 	// Create Publisher for replies
 	pub := messaging.NewPublisher(client, "test-subscriber")
@@ -5,11 +5,17 @@ import (
 	"encoding/json"
 	"time"
 
+	"grouter/pkg/telemetry"
+
 	"github.com/nats-io/nats.go"
 )
 
 // topic = <service_manager_identity>.<service>.<operation>
 
+// MetadataTenantID is the metadata key holding the tenant of a message,
+// covered by the envelope signature like the other metadata
+const MetadataTenantID = "tenant_id"
+
 // MessageEnvelope wraps all messages with metadata. It implements the Envelope Pattern,
 // providing a consistent structure for all messages while allowing for deferred
 // parsing of the actual payload.
@@ -24,10 +30,41 @@ type MessageEnvelope struct {
 	Source string `json:"source"`
 	// Reply is an optional subject where responses should be sent.
 	Reply string `json:"reply,omitempty"`
+	// CorrelationID identifies the flow of the message: the ID of the message
+	// starting it, inherited by the replies and messages it causes.
+	CorrelationID string `json:"correlation_id,omitempty"`
+	// CausationID is the ID of the message whose handler published this one,
+	// empty for the first message of a flow.
+	CausationID string `json:"causation_id,omitempty"`
 	// Data is the raw message payload, to be unmarshaled based on the Type.
 	Data json.RawMessage `json:"data"`
 	// Metadata contains optional key-value pairs for tracing, routing, or other purposes.
 	Metadata map[string]string `json:"metadata,omitempty"`
+	// ExpiresAt is the time after which the message is stale and dropped by
+	// ExpiryMiddleware. Zero never expires.
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+	// Version is the format of the envelope, EnvelopeVersion when published
+	// by this package. Received envelopes are upgraded to it, see
+	// EnvelopeUpgrades. It is not signed, so that upgrades keep signatures.
+	Version int `json:"version,omitempty"`
+}
+
+// Expired reports whether the message has an expiry before now
+func (e *MessageEnvelope) Expired(now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && now.After(e.ExpiresAt)
+}
+
+// TenantID returns the tenant of the message, or ""
+func (e *MessageEnvelope) TenantID() string {
+	return e.Metadata[MetadataTenantID]
+}
+
+// SetTenantID sets the tenant of the message
+func (e *MessageEnvelope) SetTenantID(id string) {
+	if e.Metadata == nil {
+		e.Metadata = make(map[string]string)
+	}
+	e.Metadata[MetadataTenantID] = id
 }
 
 // HandlerFunc is the function signature for message handlers
@@ -39,6 +76,18 @@ type Validator interface {
 	Validate(msgType string, data []byte) error
 }
 
+// Signer signs outgoing message envelopes.
+type Signer interface {
+	// Sign adds the signature to the envelope metadata.
+	Sign(env *MessageEnvelope) error
+}
+
+// Verifier verifies the signature of incoming message envelopes.
+type Verifier interface {
+	// Verify returns an error if the envelope signature is missing or invalid.
+	Verify(env *MessageEnvelope) error
+}
+
 // Publisher defines the interface for publishing messages.
 type Publisher interface {
 	Publish(ctx context.Context, subject string, msgType string, data interface{}, opts *PublishOptions) error
@@ -50,15 +99,31 @@ type Publisher interface {
 	Use(mw ...PublisherMiddleware)
 	UseRequest(mw ...RequestMiddleware)
 	SetValidator(v Validator)
+	SetSigner(s Signer)
+	SetPayloadStore(s PayloadStore)
+	SetCompressor(c *Compressor)
+	SetCodec(c Codec)
+	// SetSkipFlush stops sync publishes from flushing the connection
+	SetSkipFlush(skip bool)
 }
 
 // PublishOptions configures message publishing behavior.
 type PublishOptions struct {
 	// Async determines if the publish should be asynchronous.
-	// If false, the publisher will flush the connection to ensure the message is sent.
+	// If false, the publisher will flush the connection to ensure the message
+	// is sent, unless the publisher skips flushes (see SetSkipFlush).
 	Async bool
 	// Timeout specifies how long to wait for a response in request-response patterns.
 	Timeout time.Duration
+	// Priority publishes on a priority lane of the subject, see LaneSubject.
+	Priority Priority
+	// TTL sets the ExpiresAt of the envelope, TTL after its timestamp.
+	// Zero never expires.
+	TTL time.Duration
+	// PartitionKey sets the MetadataPartitionKey of the envelope, whose
+	// messages ordered subscriptions handle in order. The NATS publisher
+	// also sends it in the HeaderPartitionKey header.
+	PartitionKey string
 }
 
 // SubscribeOptions configures message subscription behavior.
@@ -68,6 +133,36 @@ type SubscribeOptions struct {
 	QueueGroup string
 	// MaxWorkers specifies the maximum number of concurrent workers for processing messages.
 	MaxWorkers int
+	// Ordered handles the messages of a partition key (MetadataPartitionKey)
+	// one at a time and in order of delivery, the MaxWorkers workers
+	// handling different keys in parallel. Keys are spread over the workers
+	// by hash, messages without key over all of them. With Priority, the
+	// order holds within each lane.
+	Ordered bool
+	// Priority also subscribes the .p0, .p1 and .p2 lanes of the subject and
+	// splits MaxWorkers (default: the sum of the weights) among them by
+	// LaneWeights (default DefaultLaneWeights).
+	Priority    bool
+	LaneWeights LaneWeights
+	// PendingMsgs and PendingBytes limit the messages received and not yet
+	// handled by the subscription (default nats.DefaultSubPendingMsgsLimit
+	// and nats.DefaultSubPendingBytesLimit). Messages beyond are dropped and
+	// SlowConsumer applies.
+	PendingMsgs  int
+	PendingBytes int
+	SlowConsumer SlowConsumerPolicy
+}
+
+// Subscription is a subscription made with SubscribeSubject or SubscribePull
+type Subscription interface {
+	Subject() string
+	// Unsubscribe removes the subscription; messages being handled finish
+	Unsubscribe() error
+	// Drain stops the deliveries of the server, so that queue groups hand
+	// the new messages to the other members, and returns once the messages
+	// received are handed to the handler or ctx is done. Handlers may still
+	// be running on return.
+	Drain(ctx context.Context) error
 }
 
 // PublisherMiddleware defines the middleware for publishing messages.
@@ -88,13 +183,28 @@ type SubscriberMiddleware func(next HandlerFunc) HandlerFunc
 // Subscriber defines the interface for subscribing to messages.
 type Subscriber interface {
 	Subscribe(subject string, handler HandlerFunc, opts *SubscribeOptions) error
+	// SubscribeSubject subscribes like Subscribe and returns the subscription,
+	// so that it can be removed on its own
+	SubscribeSubject(subject string, handler HandlerFunc, opts *SubscribeOptions) (Subscription, error)
 	SubscribePush(subject string, handler HandlerFunc, opts ...nats.SubOpt) error
-	SubscribePull(subject, durable string, handler HandlerFunc, opts ...PullOption) error
+	// SubscribePull consumes subject through the durable pull consumer and
+	// returns the subscription, so that it can be removed on its own
+	SubscribePull(subject, durable string, handler HandlerFunc, opts ...PullOption) (Subscription, error)
 	Unsubscribe() error
+	// UnsubscribeSubject removes the subscriptions on subject only
+	UnsubscribeSubject(subject string) error
 	Close() error
 
 	Use(mw ...SubscriberMiddleware)
 	SetValidator(v Validator)
+	SetPayloadStore(s PayloadStore)
+	SetCodec(c Codec)
+	// SetQuarantine sets the quarantine of poison JetStream messages
+	SetQuarantine(q *Quarantine)
+	// SetPoolMetrics exports the utilization of the MaxWorkers pools
+	SetPoolMetrics(reg *telemetry.MetricsRegistry)
+	// QueueDepth returns the number of messages waiting to be handled
+	QueueDepth() int
 }
 
 // PullOptions configures behavior for pull consumers.
@@ -2,6 +2,7 @@ package nats
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
 	"time"
 )
@@ -56,6 +57,41 @@ func TestMessageEnvelope_Marshal(t *testing.T) {
 	}
 }
 
+func TestMessageEnvelope_ExpiresAt(t *testing.T) {
+	now := time.Now()
+	envelope := MessageEnvelope{ID: "test-id-123", Timestamp: now}
+
+	envelopeBytes, err := json.Marshal(envelope)
+	if err != nil {
+		t.Fatalf("Failed to marshal envelope: %v", err)
+	}
+	if strings.Contains(string(envelopeBytes), "expires_at") {
+		t.Errorf("envelope without expiry = %s, want no expires_at", envelopeBytes)
+	}
+	if envelope.Expired(now.Add(time.Hour)) {
+		t.Error("envelope without expiry expired")
+	}
+
+	envelope.ExpiresAt = now.Add(time.Minute)
+	envelopeBytes, err = json.Marshal(envelope)
+	if err != nil {
+		t.Fatalf("Failed to marshal envelope: %v", err)
+	}
+	var decoded MessageEnvelope
+	if err := json.Unmarshal(envelopeBytes, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal envelope: %v", err)
+	}
+	if !decoded.ExpiresAt.Equal(envelope.ExpiresAt) {
+		t.Errorf("ExpiresAt = %v, want %v", decoded.ExpiresAt, envelope.ExpiresAt)
+	}
+	if decoded.Expired(now) {
+		t.Error("envelope expired before its expiry")
+	}
+	if !decoded.Expired(now.Add(2 * time.Minute)) {
+		t.Error("envelope not expired after its expiry")
+	}
+}
+
 func TestPublishOptions(t *testing.T) {
 	tests := []struct {
 		name string
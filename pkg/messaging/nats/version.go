@@ -0,0 +1,103 @@
+package nats
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// EnvelopeVersion is the version of the envelope format published by this
+// package. Envelopes without version, published before the format was
+// versioned or by hand-rolled clients, are version 0.
+//
+//   - 1: Version
+//   - 2: CorrelationID and CausationID
+//
+// To evolve the format, bump EnvelopeVersion and register the upgrade from
+// the previous version in DefaultEnvelopeUpgrades.
+const EnvelopeVersion = 2
+
+// EnvelopeUpgradeFunc upgrades the fields of an envelope of a version to the
+// next one, in place. Fields are keyed by their JSON name.
+type EnvelopeUpgradeFunc func(fields map[string]json.RawMessage) error
+
+// EnvelopeUpgrades is a registry of upgrades of older envelope formats,
+// applied to received envelopes before they reach the middleware, so that
+// handlers only see the current format
+type EnvelopeUpgrades struct {
+	mu       sync.RWMutex
+	upgrades map[int]EnvelopeUpgradeFunc
+}
+
+// NewEnvelopeUpgrades creates an empty registry. Versions without upgrade are
+// compatible with the next one.
+func NewEnvelopeUpgrades() *EnvelopeUpgrades {
+	return &EnvelopeUpgrades{upgrades: make(map[int]EnvelopeUpgradeFunc)}
+}
+
+// DefaultEnvelopeUpgrades holds the upgrades applied by the subscribers,
+// requests and replayers of this package
+var DefaultEnvelopeUpgrades = NewEnvelopeUpgrades()
+
+// Register sets the upgrade of the envelopes of version from to from+1, nil
+// removes it
+func (u *EnvelopeUpgrades) Register(from int, fn EnvelopeUpgradeFunc) error {
+	if from < 0 || from >= EnvelopeVersion {
+		return fmt.Errorf("invalid envelope version %d, want 0 to %d", from, EnvelopeVersion-1)
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.upgrades[from] = fn
+	return nil
+}
+
+// Upgrade brings env, decoded from data, to EnvelopeVersion. Envelopes of a
+// newer version are kept as decoded, their unknown fields dropped.
+//
+// Upgrades run before signature verification: those changing signed fields
+// (see EnvelopeSigner) make older signed envelopes fail verification.
+func (u *EnvelopeUpgrades) Upgrade(data []byte, env *MessageEnvelope) error {
+	if env.Version >= EnvelopeVersion {
+		return nil
+	}
+
+	u.mu.RLock()
+	steps := make([]EnvelopeUpgradeFunc, 0, EnvelopeVersion-env.Version)
+	for v := env.Version; v < EnvelopeVersion; v++ {
+		if fn := u.upgrades[v]; fn != nil {
+			steps = append(steps, fn)
+		}
+	}
+	u.mu.RUnlock()
+	if len(steps) == 0 {
+		env.Version = EnvelopeVersion
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	from := env.Version
+	for _, fn := range steps {
+		if err := fn(fields); err != nil {
+			return fmt.Errorf("failed to upgrade envelope from version %d: %w", from, err)
+		}
+	}
+	fields["version"] = json.RawMessage(fmt.Sprint(EnvelopeVersion))
+	upgraded, err := json.Marshal(fields)
+	if err != nil {
+		return fmt.Errorf("failed to marshal upgraded envelope: %w", err)
+	}
+	*env = MessageEnvelope{}
+	return json.Unmarshal(upgraded, env)
+}
+
+// unmarshalEnvelope unmarshals data into env and upgrades it with
+// DefaultEnvelopeUpgrades
+func unmarshalEnvelope(data []byte, env *MessageEnvelope) error {
+	if err := json.Unmarshal(data, env); err != nil {
+		return err
+	}
+	return DefaultEnvelopeUpgrades.Upgrade(data, env)
+}
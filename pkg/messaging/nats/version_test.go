@@ -0,0 +1,126 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// legacyEnvelope is an envelope published before versioning, by a client
+// with its own envelope struct and a top level correlation ID
+const legacyEnvelope = `{"id":"1","type":"order.created","source":"legacy","timestamp":"2024-01-02T03:04:05Z","data":{"id":"42"},"correlation_id":"c-1"}`
+
+// moveCorrelationID moves the correlation_id field of version 0 envelopes to
+// their metadata
+func moveCorrelationID(fields map[string]json.RawMessage) error {
+	raw, ok := fields["correlation_id"]
+	if !ok {
+		return nil
+	}
+	var id string
+	if err := json.Unmarshal(raw, &id); err != nil {
+		return err
+	}
+	metadata := map[string]string{}
+	if m, ok := fields["metadata"]; ok {
+		if err := json.Unmarshal(m, &metadata); err != nil {
+			return err
+		}
+	}
+	metadata["correlation_id"] = id
+	m, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	fields["metadata"] = m
+	delete(fields, "correlation_id")
+	return nil
+}
+
+func decodeWith(t *testing.T, u *EnvelopeUpgrades, data string) (*MessageEnvelope, error) {
+	t.Helper()
+	var env MessageEnvelope
+	require.NoError(t, json.Unmarshal([]byte(data), &env))
+	return &env, u.Upgrade([]byte(data), &env)
+}
+
+func TestEnvelopeUpgrades_Register(t *testing.T) {
+	u := NewEnvelopeUpgrades()
+	assert.NoError(t, u.Register(0, moveCorrelationID))
+	assert.Error(t, u.Register(-1, moveCorrelationID))
+	assert.Error(t, u.Register(EnvelopeVersion, moveCorrelationID), "the current version has nothing to upgrade to")
+}
+
+func TestEnvelopeUpgrades_Upgrade(t *testing.T) {
+	t.Run("compatible version", func(t *testing.T) {
+		env, err := decodeWith(t, NewEnvelopeUpgrades(), legacyEnvelope)
+		require.NoError(t, err)
+		assert.Equal(t, EnvelopeVersion, env.Version)
+		assert.Equal(t, "order.created", env.Type)
+		assert.JSONEq(t, `{"id":"42"}`, string(env.Data))
+		assert.Empty(t, env.Metadata)
+	})
+
+	t.Run("registered upgrade", func(t *testing.T) {
+		u := NewEnvelopeUpgrades()
+		require.NoError(t, u.Register(0, moveCorrelationID))
+		env, err := decodeWith(t, u, legacyEnvelope)
+		require.NoError(t, err)
+		assert.Equal(t, EnvelopeVersion, env.Version)
+		assert.Equal(t, map[string]string{"correlation_id": "c-1"}, env.Metadata)
+		assert.Equal(t, "1", env.ID)
+		assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), env.Timestamp.UTC())
+	})
+
+	t.Run("failed upgrade", func(t *testing.T) {
+		u := NewEnvelopeUpgrades()
+		require.NoError(t, u.Register(0, func(map[string]json.RawMessage) error { return errors.New("boom") }))
+		_, err := decodeWith(t, u, legacyEnvelope)
+		assert.ErrorContains(t, err, "failed to upgrade envelope from version 0: boom")
+	})
+
+	t.Run("current and newer versions", func(t *testing.T) {
+		u := NewEnvelopeUpgrades()
+		require.NoError(t, u.Register(0, func(map[string]json.RawMessage) error { return errors.New("not called") }))
+		env, err := decodeWith(t, u, `{"id":"1","type":"t","version":1}`)
+		require.NoError(t, err)
+		assert.Equal(t, EnvelopeVersion, env.Version, "upgrades apply from the received version")
+		env, err = decodeWith(t, u, `{"id":"1","type":"t","version":7,"headers":{"a":"b"}}`)
+		require.NoError(t, err)
+		assert.Equal(t, 7, env.Version, "newer envelopes are kept as decoded")
+	})
+}
+
+func TestSubscriber_UpgradesEnvelopes(t *testing.T) {
+	client := runPoolServer(t)
+	require.NoError(t, DefaultEnvelopeUpgrades.Register(0, moveCorrelationID))
+	t.Cleanup(func() { _ = DefaultEnvelopeUpgrades.Register(0, nil) })
+
+	received := make(chan *MessageEnvelope, 2)
+	subscriber := NewSubscriber(client, "test-subscriber")
+	require.NoError(t, subscriber.Subscribe("test.version", func(ctx context.Context, subject string, msg *MessageEnvelope) error {
+		received <- msg
+		return nil
+	}, nil))
+
+	require.NoError(t, client.Conn().Publish("test.version", []byte(legacyEnvelope)))
+	require.NoError(t, NewPublisher(client, "test-service").Publish(context.Background(), "test.version", "order.created", nil, nil))
+	for _, want := range []struct {
+		source      string
+		correlation string
+	}{{"legacy", "c-1"}, {"test-service", ""}} {
+		select {
+		case msg := <-received:
+			assert.Equal(t, want.source, msg.Source)
+			assert.Equal(t, EnvelopeVersion, msg.Version)
+			assert.Equal(t, want.correlation, msg.Metadata["correlation_id"])
+		case <-time.After(2 * time.Second):
+			t.Fatalf("message from %s not received", want.source)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package pubsub
+
+import (
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+)
+
+// pulledMessage is the messaging.DriverMessage of a pulled message, settled by a
+// messaging.DriverAcker. Rejected messages go to the DeadLetterTopic, if
+// any; those not acknowledged under AckManual are redelivered after the
+// AckDeadline.
+type pulledMessage struct {
+	sub      *subscription
+	delivery delivery
+}
+
+var _ messaging.DriverMessage = pulledMessage{}
+
+// Ack acknowledges the message: acknowledge is answered once applied
+func (m pulledMessage) Ack() error {
+	m.sub.retry(m.delivery.msg.Message.MessageID, false)
+	return m.sub.ack(m.delivery.msg.AckID)
+}
+
+// Nak asks for the message again after delay, in seconds
+func (m pulledMessage) Nak(delay time.Duration) error {
+	m.sub.retry(m.delivery.msg.Message.MessageID, true)
+	return m.sub.setDeadline(delay, m.delivery.msg.AckID)
+}
+
+// InProgress extends the ack deadline of the message by the AckDeadline, so
+// that long handlers keep it
+func (m pulledMessage) InProgress() error {
+	return m.sub.setDeadline(m.sub.subscriber.driver.cfg.AckDeadline, m.delivery.msg.AckID)
+}
+
+// Term rejects the message
+func (m pulledMessage) Term() error {
+	m.sub.retry(m.delivery.msg.Message.MessageID, false)
+	return m.sub.deadLetter(m.delivery.msg)
+}
+
+func (m pulledMessage) Redelivered() bool {
+	return m.sub.redelivered(m.delivery)
+}
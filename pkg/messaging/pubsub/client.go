@@ -0,0 +1,168 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// errAlreadyExists is returned by createSubscription for existing
+// subscriptions
+var errAlreadyExists = errors.New("already exists")
+
+// client calls the Pub/Sub REST API, v1
+type client struct {
+	base string
+	http *http.Client
+}
+
+// message is a PubsubMessage of the REST API
+type message struct {
+	// Data is base64 encoded by encoding/json
+	Data        []byte            `json:"data,omitempty"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	MessageID   string            `json:"messageId,omitempty"`
+	PublishTime time.Time         `json:"publishTime,omitzero"`
+	OrderingKey string            `json:"orderingKey,omitempty"`
+}
+
+// receivedMessage is a message pulled from a subscription
+type receivedMessage struct {
+	AckID   string  `json:"ackId"`
+	Message message `json:"message"`
+	// DeliveryAttempt is set by subscriptions with a dead letter policy
+	DeliveryAttempt int `json:"deliveryAttempt,omitempty"`
+}
+
+// subscriptionResource is the Subscription resource of the REST API
+type subscriptionResource struct {
+	Topic                 string            `json:"topic"`
+	AckDeadlineSeconds    int               `json:"ackDeadlineSeconds,omitempty"`
+	Filter                string            `json:"filter,omitempty"`
+	EnableMessageOrdering bool              `json:"enableMessageOrdering,omitempty"`
+	ExpirationPolicy      *expirationPolicy `json:"expirationPolicy,omitempty"`
+}
+
+type expirationPolicy struct {
+	TTL string `json:"ttl"`
+}
+
+// call sends a request with the JSON of in to the resource path and decodes
+// the response into out when not nil
+func (c *client) call(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read pubsub response: %w", err)
+	}
+	if resp.StatusCode == http.StatusConflict {
+		return errAlreadyExists
+	}
+	if resp.StatusCode != http.StatusOK {
+		var status struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		_ = json.Unmarshal(data, &status)
+		if status.Error.Message != "" {
+			return fmt.Errorf("pubsub returned %s: %s", resp.Status, status.Error.Message)
+		}
+		return fmt.Errorf("pubsub returned %s", resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode pubsub response: %w", err)
+	}
+	return nil
+}
+
+// getTopic checks that the topic exists
+func (c *client) getTopic(ctx context.Context, topic string) error {
+	return c.call(ctx, http.MethodGet, topic, nil, nil)
+}
+
+// publish publishes messages on a topic
+func (c *client) publish(ctx context.Context, topic string, msgs ...message) error {
+	in := struct {
+		Messages []message `json:"messages"`
+	}{msgs}
+	return c.call(ctx, http.MethodPost, topic+":publish", in, nil)
+}
+
+// createSubscription creates the subscription name, errAlreadyExists if it
+// exists
+func (c *client) createSubscription(ctx context.Context, name string, sub subscriptionResource) error {
+	return c.call(ctx, http.MethodPut, name, sub, nil)
+}
+
+func (c *client) deleteSubscription(ctx context.Context, name string) error {
+	return c.call(ctx, http.MethodDelete, name, nil, nil)
+}
+
+// pull returns up to max messages of the subscription, waiting for the
+// first one until the server gives up
+func (c *client) pull(ctx context.Context, sub string, max int) ([]receivedMessage, error) {
+	in := struct {
+		MaxMessages int `json:"maxMessages"`
+	}{max}
+	var out struct {
+		ReceivedMessages []receivedMessage `json:"receivedMessages"`
+	}
+	if err := c.call(ctx, http.MethodPost, sub+":pull", in, &out); err != nil {
+		return nil, err
+	}
+	return out.ReceivedMessages, nil
+}
+
+func (c *client) acknowledge(ctx context.Context, sub string, ackIDs ...string) error {
+	in := struct {
+		AckIDs []string `json:"ackIds"`
+	}{ackIDs}
+	return c.call(ctx, http.MethodPost, sub+":acknowledge", in, nil)
+}
+
+// modifyAckDeadline redelivers the messages after seconds, at once for 0
+func (c *client) modifyAckDeadline(ctx context.Context, sub string, seconds int, ackIDs ...string) error {
+	in := struct {
+		AckIDs             []string `json:"ackIds"`
+		AckDeadlineSeconds int      `json:"ackDeadlineSeconds"`
+	}{ackIDs, seconds}
+	return c.call(ctx, http.MethodPost, sub+":modifyAckDeadline", in, nil)
+}
+
+// resource returns the full name of a topic or subscription: name itself
+// when it starts with projects/
+func resource(project, kind, name string) string {
+	if strings.HasPrefix(name, "projects/") {
+		return name
+	}
+	return "projects/" + project + "/" + kind + "/" + name
+}
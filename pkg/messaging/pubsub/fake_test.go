@@ -0,0 +1,291 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakePubSub is an in-memory Pub/Sub REST API: topics fan messages out to
+// the subscriptions whose filter matches, subscriptions hide the pulled
+// messages until their ack deadline
+type fakePubSub struct {
+	mu     sync.Mutex
+	seq    int
+	topics map[string]bool
+	subs   map[string]*fakeSubscription
+	server *httptest.Server
+}
+
+type fakeSubscription struct {
+	topic    string
+	filter   string
+	deadline time.Duration
+	msgs     []*fakeMessage
+}
+
+type fakeMessage struct {
+	message
+	visibleAt  time.Time
+	ackID      string
+	deliveries int
+}
+
+// newFakePubSub returns a fake with the given topics of the project test,
+// stopped at the end of the test
+func newFakePubSub(t *testing.T, topics ...string) *fakePubSub {
+	f := &fakePubSub{topics: make(map[string]bool), subs: make(map[string]*fakeSubscription)}
+	for _, name := range topics {
+		f.topics[resource("test", "topics", name)] = true
+	}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakePubSub) next() string {
+	f.seq++
+	return strconv.Itoa(f.seq)
+}
+
+// subscriptions returns the names of the subscriptions
+func (f *fakePubSub) subscriptions() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var names []string
+	for name := range f.subs {
+		names = append(names, strings.TrimPrefix(name, "projects/test/subscriptions/"))
+	}
+	return names
+}
+
+// messages returns the messages of the subscription name of the project
+func (f *fakePubSub) messages(name string) []message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sub, ok := f.subs[resource("test", "subscriptions", name)]
+	if !ok {
+		return nil
+	}
+	msgs := make([]message, 0, len(sub.msgs))
+	for _, m := range sub.msgs {
+		msgs = append(msgs, m.message)
+	}
+	return msgs
+}
+
+// subscribe creates the subscription name of the topic, like another
+// consumer
+func (f *fakePubSub) subscribe(topic, name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.subs[resource("test", "subscriptions", name)] = &fakeSubscription{topic: resource("test", "topics", topic), deadline: 10 * time.Second}
+}
+
+func (f *fakePubSub) serve(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/v1/")
+	name, method, _ := strings.Cut(path, ":")
+	var in struct {
+		Messages           []message `json:"messages"`
+		MaxMessages        int       `json:"maxMessages"`
+		AckIDs             []string  `json:"ackIds"`
+		AckDeadlineSeconds int       `json:"ackDeadlineSeconds"`
+	}
+	var sub subscriptionResource
+	body, _ := io.ReadAll(r.Body)
+	if len(body) > 0 {
+		_ = json.Unmarshal(body, &in)
+		_ = json.Unmarshal(body, &sub)
+	}
+
+	switch {
+	case r.Method == http.MethodGet && method == "":
+		f.mu.Lock()
+		ok := f.topics[name]
+		f.mu.Unlock()
+		if !ok {
+			fail(w, http.StatusNotFound, "Resource not found")
+			return
+		}
+		reply(w, map[string]string{"name": name})
+	case method == "publish":
+		f.publish(w, name, in.Messages)
+	case r.Method == http.MethodPut:
+		f.create(w, name, sub)
+	case r.Method == http.MethodDelete:
+		f.mu.Lock()
+		_, ok := f.subs[name]
+		delete(f.subs, name)
+		f.mu.Unlock()
+		if !ok {
+			fail(w, http.StatusNotFound, "Resource not found")
+			return
+		}
+		reply(w, struct{}{})
+	case method == "pull":
+		f.pull(w, r, name, in.MaxMessages)
+	case method == "acknowledge":
+		f.settle(w, name, in.AckIDs, -1)
+	case method == "modifyAckDeadline":
+		f.settle(w, name, in.AckIDs, in.AckDeadlineSeconds)
+	default:
+		fail(w, http.StatusNotFound, "unknown method")
+	}
+}
+
+func (f *fakePubSub) publish(w http.ResponseWriter, topic string, msgs []message) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.topics[topic] {
+		fail(w, http.StatusNotFound, "Resource not found")
+		return
+	}
+	ids := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		msg.MessageID = f.next()
+		msg.PublishTime = time.Now().UTC()
+		ids = append(ids, msg.MessageID)
+		for _, sub := range f.subs {
+			if sub.topic == topic && matchFilter(sub.filter, msg.Attributes) {
+				sub.msgs = append(sub.msgs, &fakeMessage{message: msg})
+			}
+		}
+	}
+	reply(w, map[string][]string{"messageIds": ids})
+}
+
+func (f *fakePubSub) create(w http.ResponseWriter, name string, sub subscriptionResource) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.subs[name]; ok {
+		fail(w, http.StatusConflict, "Resource already exists in the project")
+		return
+	}
+	if !f.topics[sub.Topic] {
+		fail(w, http.StatusNotFound, "Resource not found")
+		return
+	}
+	if sub.AckDeadlineSeconds < 10 || sub.AckDeadlineSeconds > 600 {
+		fail(w, http.StatusBadRequest, "invalid ack deadline")
+		return
+	}
+	if sub.Filter != "" && filterPattern.FindStringSubmatch(sub.Filter) == nil {
+		fail(w, http.StatusBadRequest, "invalid filter")
+		return
+	}
+	f.subs[name] = &fakeSubscription{topic: sub.Topic, filter: sub.Filter, deadline: time.Duration(sub.AckDeadlineSeconds) * time.Second}
+	reply(w, sub)
+}
+
+// fakePullSize is the most messages a pull returns
+const fakePullSize = 5
+
+// pull waits a little for visible messages
+func (f *fakePubSub) pull(w http.ResponseWriter, r *http.Request, name string, max int) {
+	deadline := time.Now().Add(500 * time.Millisecond)
+	for {
+		msgs, ok := f.receive(name, max)
+		if !ok {
+			fail(w, http.StatusNotFound, "Resource not found")
+			return
+		}
+		if len(msgs) > 0 || time.Now().After(deadline) {
+			reply(w, map[string][]receivedMessage{"receivedMessages": msgs})
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
+
+func (f *fakePubSub) receive(name string, max int) ([]receivedMessage, bool) {
+	// Pub/Sub returns fewer messages than asked for, sharing the messages of
+	// a subscription among its subscribers
+	max = min(max, fakePullSize)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sub, ok := f.subs[name]
+	if !ok {
+		return nil, false
+	}
+	now := time.Now()
+	var msgs []receivedMessage
+	for _, m := range sub.msgs {
+		if len(msgs) == max {
+			break
+		}
+		if now.Before(m.visibleAt) {
+			continue
+		}
+		m.deliveries++
+		m.ackID = f.next()
+		m.visibleAt = now.Add(sub.deadline)
+		msgs = append(msgs, receivedMessage{AckID: m.ackID, Message: m.message})
+	}
+	return msgs, true
+}
+
+// settle acknowledges the messages of ackIDs, for seconds < 0, or sets
+// their ack deadline
+func (f *fakePubSub) settle(w http.ResponseWriter, name string, ackIDs []string, seconds int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sub, ok := f.subs[name]
+	if !ok {
+		fail(w, http.StatusNotFound, "Resource not found")
+		return
+	}
+	for _, id := range ackIDs {
+		for i, m := range sub.msgs {
+			if m.ackID != id {
+				continue
+			}
+			if seconds < 0 {
+				sub.msgs = append(sub.msgs[:i], sub.msgs[i+1:]...)
+			} else {
+				m.visibleAt = time.Now().Add(time.Duration(seconds) * time.Second)
+			}
+			break
+		}
+	}
+	reply(w, struct{}{})
+}
+
+// filterPattern matches the filters of the driver
+var filterPattern = regexp.MustCompile(`^(?:attributes\.(\w+) = ("[^"]*")|hasPrefix\(attributes\.(\w+), ("[^"]*")\))$`)
+
+// matchFilter applies a filter to the attributes of a message
+func matchFilter(filter string, attrs map[string]string) bool {
+	if filter == "" {
+		return true
+	}
+	m := filterPattern.FindStringSubmatch(filter)
+	if m[1] != "" {
+		value, _ := strconv.Unquote(m[2])
+		return attrs[m[1]] == value
+	}
+	prefix, _ := strconv.Unquote(m[4])
+	value, ok := attrs[m[3]]
+	return ok && strings.HasPrefix(value, prefix)
+}
+
+func reply(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func fail(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{"code": status, "message": msg}})
+}
@@ -0,0 +1,201 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+)
+
+// Publisher publishes envelopes on the Pub/Sub topics of their subjects. It
+// implements messaging.Publisher, without JetStream and payload store.
+//
+// The subject, envelope version and type are message attributes, along with
+// the metadata of the envelope, for the subscription filters of other
+// consumers.
+type Publisher struct {
+	*messaging.DriverPublisher
+	driver *Driver
+
+	// replies receives the responses of the requests, see request
+	replies replies
+}
+
+var _ messaging.Publisher = (*Publisher)(nil)
+
+func newPublisher(d *Driver, source string) *Publisher {
+	p := &Publisher{driver: d}
+	p.DriverPublisher = messaging.NewDriverPublisher(source, d.logger, p.publish, p.request)
+	p.replies.subject = replyPrefix + uuid.NewString()
+	p.replies.waiting = make(map[string]chan *messaging.MessageEnvelope)
+	return p
+}
+
+// SetPayloadStore is a no-op: messages are limited to the 10 MB of Pub/Sub
+func (p *Publisher) SetPayloadStore(messaging.PayloadStore) {}
+
+// SetSkipFlush is a no-op: every publish waits for the reply of Pub/Sub
+func (p *Publisher) SetSkipFlush(bool) {}
+
+// HealthCheck reads the default topic
+func (p *Publisher) HealthCheck() error {
+	return p.driver.HealthCheck()
+}
+
+// publish sends the envelope on the topic of subject
+func (p *Publisher) publish(ctx context.Context, subject string, envelope *messaging.MessageEnvelope, _ *messaging.PublishOptions) error {
+	return p.send(ctx, subject, envelope)
+}
+
+// send publishes the envelope on the topic of subject, with the subject as
+// ordering key when Ordering is set
+func (p *Publisher) send(ctx context.Context, subject string, envelope *messaging.MessageEnvelope) error {
+	body, err := p.Marshal(subject, envelope)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, p.driver.cfg.PublishTimeout)
+	defer cancel()
+
+	msg := message{Data: body, Attributes: attributes(subject, envelope)}
+	if p.driver.cfg.Ordering {
+		msg.OrderingKey = subject
+	}
+	if err := p.driver.client.publish(ctx, p.driver.topic(subject), msg); err != nil {
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
+	return nil
+}
+
+// attributes returns the attributes of the envelope published on subject:
+// the metadata with valid names and values
+func attributes(subject string, envelope *messaging.MessageEnvelope) map[string]string {
+	attrs := make(map[string]string, len(envelope.Metadata)+3)
+	for k, v := range envelope.Metadata {
+		if k != "" && len(k) <= 256 && len(v) <= 1024 && !strings.HasPrefix(strings.ToLower(k), "goog") {
+			attrs[k] = v
+		}
+	}
+	attrs[AttributeSubject] = subject
+	attrs[AttributeEnvelope] = strconv.Itoa(envelope.Version)
+	if envelope.Type != "" {
+		attrs[AttributeType] = envelope.Type
+	} else {
+		delete(attrs, AttributeType)
+	}
+	return attrs
+}
+
+// request publishes the request with the reply subject
+// _REPLY.<publisher>.<request> and waits for the response, received by the
+// reply subscription of the publisher
+func (p *Publisher) request(ctx context.Context, subject string, envelope *messaging.MessageEnvelope) (*messaging.MessageEnvelope, error) {
+	if err := p.replies.start(p.driver); err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	envelope.Reply = p.replies.subject + "." + uuid.NewString()
+	response := p.replies.wait(envelope.Reply)
+	defer p.replies.cancel(envelope.Reply)
+
+	if err := p.send(ctx, subject, envelope); err != nil {
+		return nil, err
+	}
+	select {
+	case env := <-response:
+		return env, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("request failed: %w", ctx.Err())
+	}
+}
+
+// PublishJS is not supported: Pub/Sub has no JetStream
+func (p *Publisher) PublishJS(ctx context.Context, subject string, msgType string, data interface{}, opts ...nats.PubOpt) (*nats.PubAck, error) {
+	return nil, fmt.Errorf("pubsub: PublishJS: %w, use Publish: subscriptions are durable", errors.ErrUnsupported)
+}
+
+// PublishAsyncJS is not supported: Pub/Sub has no JetStream
+func (p *Publisher) PublishAsyncJS(ctx context.Context, subject string, msgType string, data interface{}, opts ...nats.PubOpt) (nats.PubAckFuture, error) {
+	return nil, fmt.Errorf("pubsub: PublishAsyncJS: %w, use Publish", errors.ErrUnsupported)
+}
+
+// replies is the reply subscription of a publisher, subscribed to
+// _REPLY.<publisher>.> on the first request, and the requests waiting for a
+// response
+type replies struct {
+	subject string
+
+	mu      sync.Mutex
+	sub     *subscription
+	closed  bool
+	waiting map[string]chan *messaging.MessageEnvelope
+}
+
+// start subscribes the reply subscription unless it is subscribed already
+func (r *replies) start(d *Driver) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return errors.New("driver closed")
+	}
+	if r.sub != nil {
+		return nil
+	}
+	sub, err := d.subscriber.subscribe(r.subject+".>", r.handle, &messaging.SubscribeOptions{MaxWorkers: 4}, true)
+	if err != nil {
+		return err
+	}
+	r.sub = sub
+	return nil
+}
+
+// wait returns the channel of the response to the reply subject
+func (r *replies) wait(reply string) <-chan *messaging.MessageEnvelope {
+	ch := make(chan *messaging.MessageEnvelope, 1)
+	r.mu.Lock()
+	r.waiting[reply] = ch
+	r.mu.Unlock()
+	return ch
+}
+
+func (r *replies) cancel(reply string) {
+	r.mu.Lock()
+	delete(r.waiting, reply)
+	r.mu.Unlock()
+}
+
+// handle hands a response to its request, dropping the late ones
+func (r *replies) handle(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+	r.mu.Lock()
+	ch, ok := r.waiting[subject]
+	delete(r.waiting, subject)
+	r.mu.Unlock()
+	if ok {
+		ch <- env
+	}
+	return nil
+}
+
+// closeReplies unsubscribes the reply subscription, deleting it
+func (p *Publisher) closeReplies() {
+	r := &p.replies
+	r.mu.Lock()
+	sub := r.sub
+	r.closed, r.sub = true, nil
+	r.mu.Unlock()
+	if sub == nil {
+		return
+	}
+	_ = sub.Stop()
+	select {
+	case <-sub.done:
+	case <-time.After(5 * time.Second):
+	}
+}
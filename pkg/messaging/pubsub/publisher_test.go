@@ -0,0 +1,99 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+	"grouter/pkg/messaging/nats/conformance"
+	"grouter/pkg/tenant"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublisher_Publish(t *testing.T) {
+	f := newFakePubSub(t, "grouter", "audit")
+	cfg := testConfig()
+	cfg.Topics = []TopicRoute{{Subject: "audit.>", Topic: "audit"}}
+	d := newDriver(t, f, cfg)
+	f.subscribe("grouter", "orders")
+	f.subscribe("audit", "audit")
+
+	ctx := tenant.NewContext(context.Background(), "acme")
+	require.NoError(t, d.Publisher().Publish(ctx, "orders.created", "order.created", map[string]int{"id": 42}, &messaging.PublishOptions{TTL: time.Minute}))
+	require.NoError(t, d.Publisher().Publish(ctx, "audit.login", "login", nil, nil))
+
+	msgs := f.messages("orders")
+	require.Len(t, msgs, 1, "routed subjects go to their topic")
+	assert.Equal(t, "orders.created", msgs[0].Attributes[AttributeSubject])
+	assert.Equal(t, "order.created", msgs[0].Attributes[AttributeType])
+	assert.Equal(t, "2", msgs[0].Attributes[AttributeEnvelope])
+	assert.Equal(t, "acme", msgs[0].Attributes[messaging.MetadataTenantID], "metadata attributes")
+	assert.Empty(t, msgs[0].OrderingKey, "unordered by default")
+
+	var env messaging.MessageEnvelope
+	require.NoError(t, json.Unmarshal(msgs[0].Data, &env))
+	assert.Equal(t, "order.created", env.Type)
+	assert.Equal(t, "test", env.Source)
+	assert.JSONEq(t, `{"id":42}`, string(env.Data))
+	assert.False(t, env.ExpiresAt.IsZero(), "TTL")
+	assert.Len(t, f.messages("audit"), 1)
+}
+
+func TestAttributes(t *testing.T) {
+	env := &messaging.MessageEnvelope{Version: 2, Metadata: map[string]string{"tenant_id": "acme", "googclient": "x", "type": "x", "subject": "x"}}
+	attrs := attributes("orders", env)
+	assert.Equal(t, map[string]string{"tenant_id": "acme", "subject": "orders", "envelope": "2"}, attrs,
+		"reserved names are left out and metadata does not override the attributes")
+}
+
+func TestPublisher_Ordering(t *testing.T) {
+	f := newFakePubSub(t, "grouter")
+	cfg := testConfig()
+	cfg.Ordering = true
+	d := newDriver(t, f, cfg)
+	f.subscribe("grouter", "orders")
+
+	require.NoError(t, d.Publisher().Publish(context.Background(), "orders", "order", nil, nil))
+	msgs := f.messages("orders")
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "orders", msgs[0].OrderingKey, "ordered per subject")
+}
+
+func TestPublisher_Validation(t *testing.T) {
+	f := newFakePubSub(t, "grouter")
+	d := newDriver(t, f, testConfig())
+	f.subscribe("grouter", "all")
+	d.Publisher().SetValidator(conformance.RejectAll{})
+
+	err := d.Publisher().Publish(context.Background(), "orders.created", "order.created", nil, nil)
+	assert.ErrorContains(t, err, "validation failed for type order.created")
+	assert.Empty(t, f.messages("all"))
+}
+
+func TestPublisher_Errors(t *testing.T) {
+	f := newFakePubSub(t)
+	d := newDriver(t, f, testConfig())
+
+	err := d.Publisher().Publish(context.Background(), "orders", "order", nil, nil)
+	assert.ErrorContains(t, err, "failed to publish message: pubsub returned 404 Not Found: Resource not found")
+}
+
+func TestPublisher_Request(t *testing.T) {
+	f := newFakePubSub(t, "grouter")
+	d := newDriver(t, f, testConfig())
+
+	start := time.Now()
+	_, err := d.Publisher().Request(context.Background(), "nobody", "ping", nil, 100*time.Millisecond)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second, "the request timeout bounds the wait")
+	assert.Len(t, f.subscriptions(), 1, "the reply subscription is created on the first request")
+
+	require.NoError(t, d.Close())
+	assert.Empty(t, f.subscriptions(), "closing deletes the reply subscription")
+	_, err = d.Publisher().Request(context.Background(), "nobody", "ping", nil, 100*time.Millisecond)
+	assert.ErrorContains(t, err, "driver closed")
+}
@@ -0,0 +1,302 @@
+// Package pubsub is a messaging driver running the Publisher and Subscriber
+// of pkg/messaging/nats on Google Cloud Pub/Sub, through its REST API:
+// envelopes are published on topics and subscriptions pull them in batches
+// through Pub/Sub subscriptions filtered on the subject attribute. Messages
+// are acknowledged once handled and redelivered on failure, following the
+// ack policy of the handler. The driver registers itself as "pubsub" for
+// messaging.driver.
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+
+	"go.uber.org/zap"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// DriverName is the name of the driver in the messaging driver registry
+const DriverName = "pubsub"
+
+// DefaultMessageType is the envelope type of the plain messages received
+// without a type attribute, see Subscriber
+const DefaultMessageType = "pubsub.message"
+
+// The message attributes set on published messages
+const (
+	// AttributeSubject holds the subject, filtered by the subscriptions
+	AttributeSubject = "subject"
+	// AttributeEnvelope marks the messages whose data is an envelope,
+	// holding its version. Messages without it are plain messages.
+	AttributeEnvelope = "envelope"
+	// AttributeType holds the envelope type
+	AttributeType = "type"
+	// AttributeGroup holds the subscription of dead letters
+	AttributeGroup = "group"
+)
+
+// Endpoint is the endpoint of the Pub/Sub REST API
+const Endpoint = "https://pubsub.googleapis.com/v1/"
+
+// scope is the OAuth2 scope of the Pub/Sub API
+const scope = "https://www.googleapis.com/auth/pubsub"
+
+// replyPrefix starts the reply subjects of requests, received by a
+// subscription of the publisher
+const replyPrefix = "_REPLY."
+
+func init() {
+	messaging.RegisterDialer(DriverName, DefaultConfig, func(cfg Config, logger *zap.Logger, source string) (*Driver, error) {
+		return Dial(context.Background(), cfg, logger, source)
+	})
+}
+
+// Config holds the topics and subscriptions of the driver
+type Config struct {
+	// Project is the Google Cloud project, from the credentials when empty.
+	Project string `mapstructure:"project"`
+	// Endpoint overrides the API endpoint, without authentication, e.g. the
+	// emulator on http://localhost:8085/v1/. PUBSUB_EMULATOR_HOST sets it
+	// too.
+	Endpoint string `mapstructure:"endpoint"`
+	// CredentialsFile is a service account key file. Empty uses the
+	// application default credentials.
+	CredentialsFile string `mapstructure:"credentials_file"`
+	// Topic is the topic of the subjects not mapped by Topics, a name of
+	// the project or projects/<project>/topics/<name>.
+	Topic string `mapstructure:"topic"`
+	// Topics map subject patterns to other topics. The first match wins.
+	Topics []TopicRoute `mapstructure:"topics"`
+	// SubscriptionPrefix prefixes the names of the subscriptions.
+	SubscriptionPrefix string `mapstructure:"subscription_prefix"`
+	// DeadLetterTopic receives the messages rejected by the subscriptions:
+	// invalid, failing twice or terminated. Empty drops them.
+	DeadLetterTopic string `mapstructure:"dead_letter_topic"`
+	// BatchSize is the number of messages pulled at once, up to 1000.
+	BatchSize int `mapstructure:"batch_size"`
+	// AckDeadline is the time given to the handlers before redelivery, from
+	// 10s to 10m.
+	AckDeadline time.Duration `mapstructure:"ack_deadline"`
+	// Ordering publishes with the subject as ordering key and orders the
+	// deliveries of the subscriptions per subject.
+	Ordering bool `mapstructure:"ordering"`
+	// PublishTimeout bounds the publishes and the API calls.
+	PublishTimeout time.Duration `mapstructure:"publish_timeout"`
+}
+
+// TopicRoute maps the subjects matching Subject, a NATS subject pattern, to
+// Topic.
+type TopicRoute struct {
+	Subject string `mapstructure:"subject"`
+	Topic   string `mapstructure:"topic"`
+}
+
+// DefaultConfig returns the default driver configuration.
+func DefaultConfig() Config {
+	return Config{
+		SubscriptionPrefix: "grouter-",
+		BatchSize:          100,
+		AckDeadline:        30 * time.Second,
+		PublishTimeout:     5 * time.Second,
+	}
+}
+
+// withDefaults returns cfg with the defaults of its unset fields, and the
+// batch size and ack deadline within the limits of Pub/Sub
+func withDefaults(cfg Config) Config {
+	messaging.ApplyDefaults(&cfg, DefaultConfig())
+	cfg.BatchSize = min(cfg.BatchSize, 1000)
+	cfg.AckDeadline = min(max(cfg.AckDeadline, 10*time.Second), 10*time.Minute)
+	return cfg
+}
+
+// Driver is a Pub/Sub API client with the Publisher and Subscriber running
+// over it.
+type Driver struct {
+	cfg    Config
+	client *client
+	logger *zap.Logger
+
+	publisher  *Publisher
+	subscriber *Subscriber
+
+	closeOnce sync.Once
+}
+
+// Dial creates the API client of cfg, authenticated with CredentialsFile or
+// the application default credentials unless an endpoint is set, and checks
+// that the topic exists. source is the source of the published envelopes,
+// usually the app name.
+func Dial(ctx context.Context, cfg Config, logger *zap.Logger, source string) (*Driver, error) {
+	endpoint := cfg.Endpoint
+	if host := os.Getenv("PUBSUB_EMULATOR_HOST"); endpoint == "" && host != "" {
+		endpoint = "http://" + host + "/v1/"
+	}
+	httpClient := &http.Client{}
+	if endpoint == "" {
+		var creds *google.Credentials
+		var err error
+		if cfg.CredentialsFile != "" {
+			data, readErr := os.ReadFile(cfg.CredentialsFile)
+			if readErr != nil {
+				return nil, fmt.Errorf("failed to read pubsub credentials: %w", readErr)
+			}
+			creds, err = google.CredentialsFromJSON(ctx, data, scope)
+		} else {
+			creds, err = google.FindDefaultCredentials(ctx, scope)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load pubsub credentials: %w", err)
+		}
+		if cfg.Project == "" {
+			cfg.Project = creds.ProjectID
+		}
+		// The token source outlives the dial
+		httpClient = oauth2.NewClient(context.Background(), creds.TokenSource)
+		endpoint = Endpoint
+	}
+
+	d, err := New(httpClient, endpoint, cfg, logger, source)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.HealthCheck(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// New returns the driver calling the API at endpoint with httpClient, which
+// authenticates the requests
+func New(httpClient *http.Client, endpoint string, cfg Config, logger *zap.Logger, source string) (*Driver, error) {
+	cfg = withDefaults(cfg)
+	if cfg.Project == "" {
+		return nil, errors.New("pubsub project is required")
+	}
+	if cfg.Topic == "" {
+		return nil, errors.New("pubsub topic is required")
+	}
+	for _, r := range cfg.Topics {
+		if r.Subject == "" || r.Topic == "" {
+			return nil, errors.New("pubsub topic routes require a subject and a topic")
+		}
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	d := &Driver{
+		cfg:    cfg,
+		client: &client{base: strings.TrimRight(endpoint, "/") + "/", http: httpClient},
+		logger: logger,
+	}
+	d.publisher = newPublisher(d, source)
+	d.subscriber = newSubscriber(d)
+	return d, nil
+}
+
+// Publisher returns the publisher of the driver
+func (d *Driver) Publisher() *Publisher {
+	return d.publisher
+}
+
+// Subscriber returns the subscriber of the driver
+func (d *Driver) Subscriber() *Subscriber {
+	return d.subscriber
+}
+
+// Messenger returns a messenger of the publisher and subscriber, for
+// manager.WithMessagingDriver. It has no NATS client; closing it closes the
+// driver.
+func (d *Driver) Messenger() *messaging.Messenger {
+	return messaging.NewMessenger(nil, d.publisher, d.subscriber)
+}
+
+// HealthCheck reads the default topic
+func (d *Driver) HealthCheck() error {
+	ctx, cancel := context.WithTimeout(context.Background(), d.cfg.PublishTimeout)
+	defer cancel()
+	topic := d.resource("topics", d.cfg.Topic)
+	if err := d.client.getTopic(ctx, topic); err != nil {
+		return fmt.Errorf("failed to reach topic %s: %w", topic, err)
+	}
+	return nil
+}
+
+// Close closes the subscriber, waiting for the handlers in flight, then
+// deletes the reply subscription
+func (d *Driver) Close() error {
+	return d.subscriber.Close()
+}
+
+// close deletes the reply subscription of the publisher once
+func (d *Driver) close() {
+	d.closeOnce.Do(d.publisher.closeReplies)
+}
+
+// resource returns the full name of a topic or subscription of the project
+func (d *Driver) resource(kind, name string) string {
+	return resource(d.cfg.Project, kind, name)
+}
+
+// topic returns the topic of a subject or subject pattern
+func (d *Driver) topic(subject string) string {
+	for _, r := range d.cfg.Topics {
+		if messaging.MatchSubject(r.Subject, subject) {
+			return d.resource("topics", r.Topic)
+		}
+	}
+	return d.resource("topics", d.cfg.Topic)
+}
+
+// subscriptionName returns the name of the subscription <prefix><name>, at
+// most 255 characters of letters, digits and -_.~+%, starting with a letter
+func subscriptionName(prefix, name string) string {
+	var b strings.Builder
+	for _, r := range prefix + name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', strings.ContainsRune("-_.~+%", r):
+			b.WriteRune(r)
+		case r == '*':
+			b.WriteString("star")
+		case r == '>':
+			b.WriteString("all")
+		default:
+			b.WriteRune('-')
+		}
+	}
+	sub := b.String()
+	if c := sub[0]; !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') || strings.HasPrefix(sub, "goog") {
+		sub = "s" + sub
+	}
+	if len(sub) > 255 {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(sub))
+		sub = fmt.Sprintf("%s-%08x", sub[:246], h.Sum32())
+	}
+	return sub
+}
+
+// filter returns the subscription filter of the messages of a subject
+// pattern: the subject itself, or the prefix before its first wildcard. The
+// subscriber matches the wildcards. Empty for patterns starting with one.
+func filter(subject string) string {
+	i := strings.IndexAny(subject, "*>")
+	switch {
+	case i < 0:
+		return "attributes." + AttributeSubject + " = " + strconv.Quote(subject)
+	case i == 0:
+		return ""
+	}
+	return "hasPrefix(attributes." + AttributeSubject + ", " + strconv.Quote(subject[:i]) + ")"
+}
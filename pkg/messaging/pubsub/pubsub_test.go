@@ -0,0 +1,166 @@
+package pubsub
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+	"grouter/pkg/messaging/nats/conformance"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// testConfig returns a config of the topic grouter of the project test
+func testConfig() Config {
+	return Config{Project: "test", Topic: "grouter"}
+}
+
+// newDriver returns a driver on f with cfg, closed at the end of the test
+func newDriver(t *testing.T, f *fakePubSub, cfg Config) *Driver {
+	t.Helper()
+	d, err := New(f.server.Client(), f.server.URL+"/v1", cfg, zap.NewNop(), "test")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = d.Close() })
+	return d
+}
+
+func TestConformance(t *testing.T) {
+	f := newFakePubSub(t, "grouter")
+	conformance.Run(t, func(t *testing.T) *messaging.Messenger {
+		m := newDriver(t, f, testConfig()).Messenger()
+		t.Cleanup(func() { _ = m.Close() })
+		return m
+	})
+}
+
+func TestAcks(t *testing.T) {
+	conformance.RunAcks(t, func(t *testing.T) conformance.AckBroker {
+		f := newFakePubSub(t, "grouter")
+		// Messages left unacknowledged come back after the AckDeadline, at
+		// least 10s: not waited for
+		return conformance.AckBroker{
+			Messenger:   newDriver(t, f, deadLetters(f)).Messenger(),
+			DeadLetters: func() int { return len(f.messages("dead")) },
+			NakDelay:    time.Second,
+		}
+	})
+}
+
+func TestUnsupported(t *testing.T) {
+	f := newFakePubSub(t, "grouter")
+	conformance.RunUnsupported(t, func(t *testing.T) *messaging.Messenger {
+		return newDriver(t, f, testConfig()).Messenger()
+	})
+}
+
+// TestConformance_PubSub runs the contract tests on the topic PUBSUB_TOPIC
+// of PUBSUB_PROJECT, through the emulator of PUBSUB_EMULATOR_HOST or with
+// the application default credentials
+func TestConformance_PubSub(t *testing.T) {
+	topic := os.Getenv("PUBSUB_TOPIC")
+	if topic == "" {
+		t.Skip("PUBSUB_TOPIC not set")
+	}
+	conformance.Run(t, func(t *testing.T) *messaging.Messenger {
+		cfg := Config{Project: os.Getenv("PUBSUB_PROJECT"), Topic: topic, Ordering: true}
+		d, err := Dial(context.Background(), cfg, zap.NewNop(), "test")
+		require.NoError(t, err)
+		m := d.Messenger()
+		t.Cleanup(func() { _ = m.Close() })
+		return m
+	})
+}
+
+func TestNew(t *testing.T) {
+	f := newFakePubSub(t, "grouter", "audit")
+	cfg := testConfig()
+	cfg.Topics = []TopicRoute{{Subject: "audit.>", Topic: "projects/other/topics/audit"}}
+	d := newDriver(t, f, cfg)
+
+	assert.Equal(t, DefaultConfig().SubscriptionPrefix, d.cfg.SubscriptionPrefix, "defaults")
+	assert.Equal(t, DefaultConfig().AckDeadline, d.cfg.AckDeadline)
+	assert.Equal(t, "projects/other/topics/audit", d.topic("audit.login"), "full names as is")
+	assert.Equal(t, "projects/other/topics/audit", d.topic("audit.*"), "patterns within the route")
+	assert.Equal(t, "projects/test/topics/grouter", d.topic("orders.created"))
+	require.NoError(t, d.HealthCheck())
+
+	d = newDriver(t, f, Config{Project: "test", Topic: "missing", BatchSize: 5000, AckDeadline: time.Second})
+	assert.Equal(t, 1000, d.cfg.BatchSize, "Pub/Sub limits")
+	assert.Equal(t, 10*time.Second, d.cfg.AckDeadline)
+	assert.ErrorContains(t, d.HealthCheck(), "failed to reach topic projects/test/topics/missing")
+}
+
+func TestNew_Errors(t *testing.T) {
+	_, err := New(http.DefaultClient, Endpoint, Config{Topic: "grouter"}, nil, "test")
+	assert.ErrorContains(t, err, "pubsub project is required")
+
+	_, err = New(http.DefaultClient, Endpoint, Config{Project: "test"}, nil, "test")
+	assert.ErrorContains(t, err, "pubsub topic is required")
+
+	_, err = New(http.DefaultClient, Endpoint, Config{Project: "test", Topic: "grouter", Topics: []TopicRoute{{Subject: "audit.>"}}}, nil, "test")
+	assert.ErrorContains(t, err, "require a subject and a topic")
+}
+
+func TestDial_Emulator(t *testing.T) {
+	f := newFakePubSub(t, "grouter")
+	t.Setenv("PUBSUB_EMULATOR_HOST", strings.TrimPrefix(f.server.URL, "http://"))
+
+	d, err := Dial(context.Background(), testConfig(), nil, "test")
+	require.NoError(t, err, "the emulator needs no credentials")
+	require.NoError(t, d.Close())
+
+	_, err = Dial(context.Background(), Config{Project: "test", Topic: "missing"}, nil, "test")
+	assert.ErrorContains(t, err, "failed to reach topic")
+}
+
+func TestDial_Credentials(t *testing.T) {
+	t.Setenv("PUBSUB_EMULATOR_HOST", "")
+	_, err := Dial(context.Background(), Config{Project: "test", Topic: "grouter", CredentialsFile: "missing.json"}, nil, "test")
+	assert.ErrorContains(t, err, "failed to read pubsub credentials")
+
+	path := t.TempDir() + "/key.json"
+	require.NoError(t, os.WriteFile(path, []byte(`{"type":"unknown"}`), 0o600))
+	_, err = Dial(context.Background(), Config{Project: "test", Topic: "grouter", CredentialsFile: path}, nil, "test")
+	assert.ErrorContains(t, err, "failed to load pubsub credentials")
+}
+
+func TestSubscriptionName(t *testing.T) {
+	assert.Equal(t, "grouter-billing-orders.star", subscriptionName("grouter-", "billing-orders.*"))
+	assert.Equal(t, "grouter-billing-orders.all", subscriptionName("grouter-", "billing-orders.>"))
+	assert.Equal(t, "s1-orders", subscriptionName("", "1-orders"), "names start with a letter")
+	assert.Equal(t, "sgoog-orders", subscriptionName("", "goog-orders"), "goog is reserved")
+
+	long := subscriptionName("grouter-", strings.Repeat("x", 300))
+	assert.Len(t, long, 255)
+	assert.NotEqual(t, long, subscriptionName("grouter-", strings.Repeat("x", 301)), "truncated names keep a hash")
+}
+
+func TestFilter(t *testing.T) {
+	assert.Equal(t, `attributes.subject = "orders.created"`, filter("orders.created"))
+	assert.Equal(t, `hasPrefix(attributes.subject, "orders.")`, filter("orders.*.eu"))
+	assert.Equal(t, `hasPrefix(attributes.subject, "orders.")`, filter("orders.>"))
+	assert.Empty(t, filter(">"))
+	assert.Empty(t, filter("*.created"))
+}
+
+func TestRegisteredDriver(t *testing.T) {
+	f := newFakePubSub(t, "grouter")
+	assert.Contains(t, messaging.Drivers(), DriverName)
+
+	msg, err := messaging.OpenDriver(DriverName, func(cfg any) error {
+		c := cfg.(*Config)
+		assert.Equal(t, DefaultConfig(), *c, "settings decoded over the defaults")
+		c.Project, c.Topic, c.Endpoint = "test", "grouter", f.server.URL+"/v1/"
+		return nil
+	}, zap.NewNop(), "test")
+	require.NoError(t, err)
+	assert.Nil(t, msg.Client, "no NATS client")
+	require.NoError(t, msg.Publisher.(*Publisher).HealthCheck())
+	require.NoError(t, msg.Close())
+}
@@ -0,0 +1,396 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Subscriber pulls the messages of the subscribed subjects from Pub/Sub
+// subscriptions to their topics. It implements messaging.Subscriber, without
+// JetStream, payload store and priority lanes.
+//
+// A subscription without queue group has a Pub/Sub subscription of its own,
+// deleted with it and expiring after a day unused. The members of a queue
+// group share the subscription <prefix><group>-<subject>, which keeps the
+// messages while no member runs. Subscriptions filter the messages on the
+// subject attribute, the subscriber matches the wildcards.
+//
+// Messages are pulled in batches of BatchSize and acknowledged once handled,
+// see pulledMessage. Messages not acknowledged are redelivered once their ack
+// deadline expires.
+//
+// Messages without the envelope attribute, published by other producers,
+// are handled as envelopes of type DefaultMessageType, or of their type
+// attribute, with the data as data and the attributes as metadata.
+type Subscriber struct {
+	*messaging.DriverSubscriber
+	driver *Driver
+}
+
+var _ messaging.Subscriber = (*Subscriber)(nil)
+
+func newSubscriber(d *Driver) *Subscriber {
+	s := &Subscriber{driver: d}
+	// Closing deletes the reply subscription of the publisher
+	s.DriverSubscriber = messaging.NewDriverSubscriber("pubsub", d.logger, s.open, func() error {
+		d.close()
+		return nil
+	})
+	return s
+}
+
+// open starts a subscription of SubscribeSubject
+func (s *Subscriber) open(subject string, handler messaging.HandlerFunc, opts *messaging.SubscribeOptions) (messaging.DriverSubscription, error) {
+	sub, err := s.subscribe(subject, handler, opts, false)
+	if err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// subscribe creates the Pub/Sub subscription of a subscription on the topic
+// of subject and pulls it with MaxWorkers handlers (default 1). The replies
+// of the reply subscription of the publisher are handled as responses.
+func (s *Subscriber) subscribe(subject string, handler messaging.HandlerFunc, opts *messaging.SubscribeOptions, replies bool) (*subscription, error) {
+	d := s.driver
+	name := uuid.NewString()
+	if opts.QueueGroup != "" {
+		name = opts.QueueGroup + "-" + subject
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	sub := &subscription{
+		subscriber: s,
+		subject:    subject,
+		topic:      d.topic(subject),
+		name:       d.resource("subscriptions", subscriptionName(d.cfg.SubscriptionPrefix, name)),
+		plain:      opts.QueueGroup == "",
+		replies:    replies,
+		retried:    make(map[string]struct{}),
+		deliveries: make(chan delivery),
+		ctx:        ctx,
+		stop:       stop,
+		done:       make(chan struct{}),
+	}
+	if err := sub.create(); err != nil {
+		stop()
+		return nil, err
+	}
+
+	sub.loops.Add(1)
+	go sub.receive()
+	for i := 0; i < max(opts.MaxWorkers, 1); i++ {
+		sub.workers.Add(1)
+		go sub.run(handler)
+	}
+
+	d.logger.Info("Subscribed to subject",
+		zap.String("subject", subject),
+		zap.String("topic", sub.topic),
+		zap.String("subscription", sub.name),
+		zap.String("queue_group", opts.QueueGroup),
+	)
+	return sub, nil
+}
+
+// process hands a message to the handler and acknowledges it
+func (s *Subscriber) process(sub *subscription, d delivery, handler messaging.HandlerFunc) {
+	msg := d.msg.Message
+	subject := msg.Attributes[AttributeSubject]
+	if subject == "" {
+		// Published without subject, e.g. by another producer
+		subject = sub.subject
+	}
+	s.Process(messaging.Delivery{
+		Subject: subject,
+		Message: pulledMessage{sub: sub, delivery: d},
+		Decode: func(codec messaging.Codec, envelope *messaging.MessageEnvelope) error {
+			if _, ok := msg.Attributes[AttributeEnvelope]; !ok {
+				*envelope = plainEnvelope(msg)
+				return nil
+			}
+			return messaging.DecodeEnvelope(msg.Data, subject, codec, envelope)
+		},
+		Response: sub.replies,
+		Fields:   []zap.Field{zap.String("subscription", sub.name), zap.String("id", msg.MessageID)},
+	}, handler)
+}
+
+// plainEnvelope returns the envelope of a message without envelope
+// attribute: its data, a JSON string unless it is JSON, and its attributes
+// as metadata
+func plainEnvelope(msg message) messaging.MessageEnvelope {
+	env := messaging.MessageEnvelope{
+		ID:        msg.MessageID,
+		Type:      msg.Attributes[AttributeType],
+		Timestamp: msg.PublishTime,
+		Data:      json.RawMessage(msg.Data),
+	}
+	if env.Type == "" {
+		env.Type = DefaultMessageType
+	}
+	if !json.Valid(env.Data) {
+		env.Data, _ = json.Marshal(string(msg.Data))
+	}
+	for name, value := range msg.Attributes {
+		if name == AttributeSubject || name == AttributeType {
+			continue
+		}
+		if env.Metadata == nil {
+			env.Metadata = make(map[string]string)
+		}
+		env.Metadata[name] = value
+	}
+	return env
+}
+
+// delivery is a message pulled by a subscription
+type delivery struct {
+	msg receivedMessage
+}
+
+// subscription is a Pub/Sub subscription to the topic of a subject, of its
+// own or shared by its queue group
+type subscription struct {
+	subscriber *Subscriber
+	subject    string
+	topic      string
+	// name is the full name of the Pub/Sub subscription
+	name string
+	// plain subscriptions have a Pub/Sub subscription of their own
+	plain bool
+	// replies is the reply subscription of the publisher, handled without
+	// validator and middleware like the responses of NATS requests
+	replies bool
+
+	// retried holds the IDs of the messages asked again, redelivered
+	// without the delivery attempts of a dead letter policy
+	retriedMu sync.Mutex
+	retried   map[string]struct{}
+
+	deliveries chan delivery
+	// loops is the pulling goroutine, workers the handlers
+	loops   sync.WaitGroup
+	workers sync.WaitGroup
+	// ctx is canceled by stop, interrupting the pulls
+	ctx      context.Context
+	stop     context.CancelFunc
+	stopOnce sync.Once
+	// done is closed once the handlers returned and the subscription is
+	// cleaned up
+	done chan struct{}
+}
+
+func (s *subscription) Subject() string {
+	return s.subject
+}
+
+func (s *subscription) stopped() bool {
+	return s.ctx.Err() != nil
+}
+
+// create creates the Pub/Sub subscription with the filter of the subject.
+// Queue groups reuse the existing one.
+func (s *subscription) create() error {
+	d := s.subscriber.driver
+	ctx, cancel := context.WithTimeout(context.Background(), d.cfg.PublishTimeout)
+	defer cancel()
+
+	resource := subscriptionResource{
+		Topic:                 s.topic,
+		AckDeadlineSeconds:    int(d.cfg.AckDeadline / time.Second),
+		Filter:                filter(s.subject),
+		EnableMessageOrdering: d.cfg.Ordering,
+	}
+	if s.plain {
+		// Left behind by crashed subscribers, deleted after a day
+		resource.ExpirationPolicy = &expirationPolicy{TTL: "86400s"}
+	}
+	err := d.client.createSubscription(ctx, s.name, resource)
+	if err != nil && !(errors.Is(err, errAlreadyExists) && !s.plain) {
+		return fmt.Errorf("failed to create subscription %s on %s: %w", s.name, s.topic, err)
+	}
+	return nil
+}
+
+// receive hands the batches of messages of the subscription to the workers
+// until the subscription stops
+func (s *subscription) receive() {
+	defer s.loops.Done()
+	d := s.subscriber.driver
+	for !s.stopped() {
+		msgs, err := d.client.pull(s.ctx, s.name, d.cfg.BatchSize)
+		if s.stopped() {
+			if len(msgs) > 0 {
+				s.release(msgs)
+			}
+			return
+		}
+		if err != nil {
+			d.logger.Warn("Failed to pull messages", zap.Error(err), zap.String("subscription", s.name))
+			select {
+			case <-s.ctx.Done():
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		if !s.deliver(msgs) {
+			return
+		}
+	}
+}
+
+// deliver acknowledges the messages of the other subjects of the filter and
+// hands the others to the workers. It returns false once the subscription
+// stops, releasing the messages not handed over for redelivery.
+func (s *subscription) deliver(msgs []receivedMessage) bool {
+	var others []string
+	for i, msg := range msgs {
+		subject := msg.Message.Attributes[AttributeSubject]
+		// The filter of "orders.*" also lets "orders.a.b" through
+		if subject != "" && !messaging.MatchSubject(s.subject, subject) {
+			others = append(others, msg.AckID)
+			continue
+		}
+		s.subscriber.AddPending(1)
+		select {
+		case s.deliveries <- delivery{msg: msg}:
+		case <-s.ctx.Done():
+			s.subscriber.AddPending(-1)
+			s.release(msgs[i:])
+			return false
+		}
+	}
+	if len(others) > 0 {
+		if err := s.ack(others...); err != nil && !s.stopped() {
+			s.subscriber.driver.logger.Warn("Failed to acknowledge messages of other subjects", zap.Error(err), zap.String("subscription", s.name))
+		}
+	}
+	return true
+}
+
+// release makes messages available for redelivery at once
+func (s *subscription) release(msgs []receivedMessage) {
+	ackIDs := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		ackIDs = append(ackIDs, msg.AckID)
+	}
+	_ = s.setDeadline(0, ackIDs...)
+}
+
+// run hands the deliveries to handler until the subscription stops
+func (s *subscription) run(handler messaging.HandlerFunc) {
+	defer s.workers.Done()
+	for {
+		select {
+		case d := <-s.deliveries:
+			s.subscriber.process(s, d, handler)
+			s.subscriber.AddPending(-1)
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// redelivered reports whether the message was delivered before
+func (s *subscription) redelivered(d delivery) bool {
+	if d.msg.DeliveryAttempt > 1 {
+		return true
+	}
+	s.retriedMu.Lock()
+	defer s.retriedMu.Unlock()
+	_, ok := s.retried[d.msg.Message.MessageID]
+	return ok
+}
+
+// retry records a message asked again, forgotten once settled. The record
+// is bounded, so that lost messages do not grow it.
+func (s *subscription) retry(id string, retried bool) {
+	s.retriedMu.Lock()
+	defer s.retriedMu.Unlock()
+	if !retried {
+		delete(s.retried, id)
+		return
+	}
+	if len(s.retried) >= 10000 {
+		clear(s.retried)
+	}
+	s.retried[id] = struct{}{}
+}
+
+// ack acknowledges messages
+func (s *subscription) ack(ackIDs ...string) error {
+	d := s.subscriber.driver
+	ctx, cancel := context.WithTimeout(context.Background(), d.cfg.PublishTimeout)
+	defer cancel()
+	return d.client.acknowledge(ctx, s.name, ackIDs...)
+}
+
+// setDeadline redelivers messages after timeout, up to the 10 minutes of
+// Pub/Sub
+func (s *subscription) setDeadline(timeout time.Duration, ackIDs ...string) error {
+	d := s.subscriber.driver
+	ctx, cancel := context.WithTimeout(context.Background(), d.cfg.PublishTimeout)
+	defer cancel()
+	return d.client.modifyAckDeadline(ctx, s.name, int(min(max(timeout, 0), 10*time.Minute)/time.Second), ackIDs...)
+}
+
+// deadLetter publishes the message on the DeadLetterTopic, if any, with the
+// group attribute naming the subscription, and acknowledges it
+func (s *subscription) deadLetter(msg receivedMessage) error {
+	d := s.subscriber.driver
+	if d.cfg.DeadLetterTopic != "" {
+		attrs := make(map[string]string, len(msg.Message.Attributes)+1)
+		for name, value := range msg.Message.Attributes {
+			attrs[name] = value
+		}
+		attrs[AttributeGroup] = s.name
+		ctx, cancel := context.WithTimeout(context.Background(), d.cfg.PublishTimeout)
+		defer cancel()
+		if err := d.client.publish(ctx, d.resource("topics", d.cfg.DeadLetterTopic), message{Data: msg.Message.Data, Attributes: attrs}); err != nil {
+			return fmt.Errorf("failed to publish dead letter: %w", err)
+		}
+	}
+	return s.ack(msg.AckID)
+}
+
+// Stop stops the pulls, then cleans the subscription up once the
+// handlers return, so that they can still acknowledge their messages
+func (s *subscription) Stop() error {
+	s.stopOnce.Do(func() {
+		s.stop()
+		go func() {
+			defer close(s.done)
+			s.workers.Wait()
+			s.loops.Wait()
+			s.cleanup()
+		}()
+	})
+	return nil
+}
+
+func (s *subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// cleanup deletes the Pub/Sub subscription of plain subscriptions. The
+// subscriptions of queue groups keep the messages for the members.
+func (s *subscription) cleanup() {
+	if !s.plain {
+		return
+	}
+	d := s.subscriber.driver
+	ctx, cancel := context.WithTimeout(context.Background(), d.cfg.PublishTimeout)
+	defer cancel()
+	if err := d.client.deleteSubscription(ctx, s.name); err != nil {
+		d.logger.Warn("Failed to delete subscription", zap.Error(err), zap.String("subscription", s.name))
+	}
+}
@@ -0,0 +1,146 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+	"grouter/pkg/messaging/nats/conformance"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// deadLetters returns a config whose dead letters go to the topic dead of
+// f, subscribed by the subscription dead
+func deadLetters(f *fakePubSub) Config {
+	f.mu.Lock()
+	f.topics[resource("test", "topics", "dead")] = true
+	f.mu.Unlock()
+	f.subscribe("dead", "dead")
+	cfg := testConfig()
+	cfg.DeadLetterTopic = "dead"
+	return cfg
+}
+
+func TestSubscriber_Redelivery(t *testing.T) {
+	f := newFakePubSub(t, "grouter")
+	d := newDriver(t, f, deadLetters(f))
+
+	var calls atomic.Int32
+	require.NoError(t, d.Subscriber().Subscribe("jobs", func(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+		calls.Add(1)
+		return errors.New("boom")
+	}, &messaging.SubscribeOptions{QueueGroup: "workers"}))
+	require.NoError(t, d.Publisher().Publish(context.Background(), "jobs", "job", nil, nil))
+
+	conformance.WaitFor(t, func() bool { return len(f.messages("dead")) == 1 }, "failing twice dead-letters the message")
+	assert.Equal(t, int32(2), calls.Load(), "redelivered once, then rejected")
+
+	dead := f.messages("dead")[0]
+	assert.Equal(t, "jobs", dead.Attributes[AttributeSubject])
+	assert.Equal(t, "projects/test/subscriptions/grouter-workers-jobs", dead.Attributes[AttributeGroup])
+	conformance.WaitFor(t, func() bool { return len(f.messages("grouter-workers-jobs")) == 0 }, "dead letters are acknowledged")
+}
+
+func TestSubscriber_Wildcards(t *testing.T) {
+	f := newFakePubSub(t, "grouter")
+	d := newDriver(t, f, testConfig())
+	ctx := context.Background()
+
+	subjects := make(chan string, 4)
+	require.NoError(t, d.Subscriber().Subscribe("orders.*", func(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+		subjects <- subject
+		return nil
+	}, &messaging.SubscribeOptions{QueueGroup: "billing"}))
+
+	// orders.a.b passes the prefix filter of Pub/Sub, not the wildcard
+	require.NoError(t, d.Publisher().Publish(ctx, "orders.a.b", "order", nil, nil))
+	require.NoError(t, d.Publisher().Publish(ctx, "payments.created", "payment", nil, nil))
+	require.NoError(t, d.Publisher().Publish(ctx, "orders.created", "order", nil, nil))
+	select {
+	case subject := <-subjects:
+		assert.Equal(t, "orders.created", subject)
+	case <-time.After(3 * time.Second):
+		require.FailNow(t, "message not received")
+	}
+	conformance.WaitFor(t, func() bool { return len(f.messages("grouter-billing-orders.star")) == 0 }, "other subjects are acknowledged")
+	assert.Empty(t, subjects)
+}
+
+func TestSubscriber_PlainMessages(t *testing.T) {
+	f := newFakePubSub(t, "grouter")
+	d := newDriver(t, f, testConfig())
+
+	received := make(chan *messaging.MessageEnvelope, 2)
+	require.NoError(t, d.Subscriber().Subscribe("legacy", func(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+		received <- env
+		return nil
+	}, nil))
+
+	topic := d.topic("legacy")
+	require.NoError(t, d.client.publish(context.Background(), topic,
+		message{Data: []byte(`{"id":42}`), Attributes: map[string]string{AttributeSubject: "legacy", "origin": "erp"}},
+		message{Data: []byte("hello"), Attributes: map[string]string{AttributeSubject: "legacy", AttributeType: "greeting"}},
+	))
+	for _, want := range []struct{ typ, data string }{{DefaultMessageType, `{"id":42}`}, {"greeting", `"hello"`}} {
+		select {
+		case env := <-received:
+			assert.Equal(t, want.typ, env.Type)
+			assert.NotEmpty(t, env.ID)
+			assert.False(t, env.Timestamp.IsZero())
+			assert.JSONEq(t, want.data, string(env.Data), "JSON data as is, other data as strings")
+			if want.typ == DefaultMessageType {
+				assert.Equal(t, map[string]string{"origin": "erp"}, env.Metadata, "attributes as metadata")
+			}
+		case <-time.After(3 * time.Second):
+			require.FailNow(t, "message not received")
+		}
+	}
+}
+
+func TestSubscriber_InvalidMessages(t *testing.T) {
+	f := newFakePubSub(t, "grouter")
+	d := newDriver(t, f, deadLetters(f))
+	ctx := context.Background()
+
+	var calls atomic.Int32
+	require.NoError(t, d.Subscriber().Subscribe("orders.*", func(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+		calls.Add(1)
+		return nil
+	}, nil))
+	require.NoError(t, d.client.publish(ctx, d.topic("orders.created"), message{
+		Data:       []byte("not json"),
+		Attributes: map[string]string{AttributeSubject: "orders.created", AttributeEnvelope: "2"},
+	}))
+
+	conformance.WaitFor(t, func() bool { return len(f.messages("dead")) == 1 }, "undecodable messages are dead-lettered")
+	assert.Zero(t, calls.Load())
+	assert.Zero(t, d.Subscriber().QueueDepth())
+}
+
+func TestSubscriber_Unsubscribe(t *testing.T) {
+	f := newFakePubSub(t, "grouter")
+	d := newDriver(t, f, testConfig())
+	ctx := context.Background()
+	handler := func(ctx context.Context, subject string, env *messaging.MessageEnvelope) error { return nil }
+
+	plain, err := d.Subscriber().SubscribeSubject("orders", handler, nil)
+	require.NoError(t, err)
+	member, err := d.Subscriber().SubscribeSubject("orders", handler, &messaging.SubscribeOptions{QueueGroup: "billing"})
+	require.NoError(t, err)
+	other, err := d.Subscriber().SubscribeSubject("orders", handler, &messaging.SubscribeOptions{QueueGroup: "billing"})
+	require.NoError(t, err, "members share the subscription")
+	assert.Len(t, f.subscriptions(), 2)
+
+	require.NoError(t, plain.Drain(ctx))
+	require.NoError(t, member.Drain(ctx))
+	require.NoError(t, other.Drain(ctx))
+	assert.Equal(t, []string{"grouter-billing-orders"}, f.subscriptions(), "queue groups keep their subscription")
+
+	require.NoError(t, d.Publisher().Publish(ctx, "orders", "order", nil, nil))
+	assert.Len(t, f.messages("grouter-billing-orders"), 1, "and its messages")
+}
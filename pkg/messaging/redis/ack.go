@@ -0,0 +1,44 @@
+package redis
+
+import (
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+)
+
+// message is the messaging.DriverMessage of a stream entry, settled by a
+// messaging.DriverAcker. Rejected entries go to the DeadLetterStream, if
+// any; those left pending under AckManual are claimed again after MinIdle.
+type message struct {
+	sub      *subscription
+	delivery delivery
+}
+
+var _ messaging.DriverMessage = message{}
+
+// Ack acknowledges the entry: XACK is answered once applied
+func (m message) Ack() error {
+	return m.sub.ack(m.delivery.msg.ID)
+}
+
+// Nak makes the entry claimable after delay, handled again at the first
+// claim after it
+func (m message) Nak(delay time.Duration) error {
+	return m.sub.setIdle(m.delivery.msg.ID, m.sub.subscriber.driver.cfg.MinIdle-delay)
+}
+
+// InProgress resets the idle time of the entry, so that long handlers keep
+// it from being claimed
+func (m message) InProgress() error {
+	return m.sub.setIdle(m.delivery.msg.ID, 0)
+}
+
+// Term rejects the entry
+func (m message) Term() error {
+	return m.sub.deadLetter(m.delivery.msg)
+}
+
+// Redelivered reports whether the entry was claimed from a consumer
+func (m message) Redelivered() bool {
+	return m.delivery.redelivered
+}
@@ -0,0 +1,129 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"github.com/redis/go-redis/v9"
+)
+
+// Publisher adds envelopes to the streams of their subjects. It implements
+// messaging.Publisher, without JetStream and payload store.
+type Publisher struct {
+	*messaging.DriverPublisher
+	driver *Driver
+}
+
+var _ messaging.Publisher = (*Publisher)(nil)
+
+func newPublisher(d *Driver, source string) *Publisher {
+	p := &Publisher{driver: d}
+	p.DriverPublisher = messaging.NewDriverPublisher(source, d.logger, p.publish, p.request)
+	return p
+}
+
+// SetPayloadStore is a no-op: stream entries take values of any size up to
+// the proto-max-bulk-len of the server
+func (p *Publisher) SetPayloadStore(messaging.PayloadStore) {}
+
+// SetSkipFlush is a no-op: every publish waits for the reply of the server
+func (p *Publisher) SetSkipFlush(bool) {}
+
+// HealthCheck pings the Redis server
+func (p *Publisher) HealthCheck() error {
+	return p.driver.HealthCheck()
+}
+
+// publish adds the envelope to the stream of subject
+func (p *Publisher) publish(ctx context.Context, subject string, envelope *messaging.MessageEnvelope, _ *messaging.PublishOptions) error {
+	return p.send(ctx, subject, envelope, "")
+}
+
+// send adds the envelope to the stream of subject, or to the stream of a
+// reply subject
+func (p *Publisher) send(ctx context.Context, subject string, envelope *messaging.MessageEnvelope, reply string) error {
+	body, err := p.Marshal(subject, envelope)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, p.driver.cfg.PublishTimeout)
+	defer cancel()
+
+	client := p.driver.client
+	if isReply(subject) {
+		_, err := client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.XAdd(ctx, &redis.XAddArgs{Stream: subject, Values: []interface{}{FieldEnvelope, body}})
+			pipe.PExpire(ctx, subject, replyTTL)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to publish reply: %w", err)
+		}
+		return nil
+	}
+
+	values := []interface{}{FieldSubject, subject, FieldEnvelope, body}
+	if reply != "" {
+		values = append(values, FieldReply, reply)
+	}
+	err = client.XAdd(ctx, &redis.XAddArgs{
+		Stream: p.driver.stream(subject),
+		MaxLen: p.driver.cfg.MaxLen,
+		Approx: true,
+		Values: values,
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
+	return nil
+}
+
+// request publishes the request with a stream of its own as reply subject,
+// and waits for the first envelope added to it
+func (p *Publisher) request(ctx context.Context, subject string, envelope *messaging.MessageEnvelope) (*messaging.MessageEnvelope, error) {
+	reply := replyPrefix + uuid.NewString()
+	if err := p.send(ctx, subject, envelope, reply); err != nil {
+		return nil, err
+	}
+	defer func() { _ = p.driver.client.Del(context.Background(), reply).Err() }()
+	streams, err := p.driver.client.XRead(ctx, &redis.XReadArgs{
+		Streams: []string{reply, "0"},
+		Count:   1,
+		Block:   max(time.Until(deadline(ctx)), time.Millisecond),
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("request failed: %w", context.DeadlineExceeded)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	var response messaging.MessageEnvelope
+	body, _ := streams[0].Messages[0].Values[FieldEnvelope].(string)
+	if err := messaging.DecodeEnvelope([]byte(body), reply, p.Codec(), &response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return &response, nil
+}
+
+// PublishJS is not supported: Redis has no JetStream
+func (p *Publisher) PublishJS(ctx context.Context, subject string, msgType string, data interface{}, opts ...nats.PubOpt) (*nats.PubAck, error) {
+	return nil, fmt.Errorf("redis: PublishJS: %w, use Publish: streams are durable", errors.ErrUnsupported)
+}
+
+// PublishAsyncJS is not supported: Redis has no JetStream
+func (p *Publisher) PublishAsyncJS(ctx context.Context, subject string, msgType string, data interface{}, opts ...nats.PubOpt) (nats.PubAckFuture, error) {
+	return nil, fmt.Errorf("redis: PublishAsyncJS: %w, use Publish", errors.ErrUnsupported)
+}
+
+// deadline returns the deadline of ctx, the timeout of Request
+func deadline(ctx context.Context) time.Time {
+	d, _ := ctx.Deadline()
+	return d
+}
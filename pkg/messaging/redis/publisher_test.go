@@ -0,0 +1,79 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+	"grouter/pkg/messaging/nats/conformance"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublisher_Publish(t *testing.T) {
+	mr := miniredis.RunT(t)
+	cfg := testConfig(mr.Addr())
+	cfg.Streams = []StreamRoute{{Subject: "audit.>", Stream: "audit"}}
+	d := dial(t, cfg)
+	ctx := context.Background()
+
+	require.NoError(t, d.Publisher().Publish(ctx, "orders.created", "order.created", map[string]int{"id": 42}, &messaging.PublishOptions{TTL: time.Minute}))
+	require.NoError(t, d.Publisher().Publish(ctx, "audit.login", "login", nil, nil))
+
+	entries, err := d.client.XRange(ctx, "grouter", "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "orders.created", entries[0].Values[FieldSubject])
+	assert.NotContains(t, entries[0].Values, FieldReply, "only requests have a reply subject")
+
+	var env messaging.MessageEnvelope
+	require.NoError(t, json.Unmarshal([]byte(entries[0].Values[FieldEnvelope].(string)), &env))
+	assert.Equal(t, "order.created", env.Type)
+	assert.Equal(t, "test", env.Source)
+	assert.JSONEq(t, `{"id":42}`, string(env.Data))
+	assert.False(t, env.ExpiresAt.IsZero(), "TTL")
+
+	n, err := d.client.XLen(ctx, "audit").Result()
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n, "routed subjects go to their stream")
+}
+
+func TestPublisher_PublishReply(t *testing.T) {
+	mr := miniredis.RunT(t)
+	d := dial(t, testConfig(mr.Addr()))
+
+	reply := replyPrefix + "42"
+	require.NoError(t, d.Publisher().PublishError(context.Background(), reply, "boom"))
+	n, err := d.client.XLen(context.Background(), reply).Result()
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n, "replies are added to the stream of the request")
+	assert.Equal(t, replyTTL, mr.TTL(reply))
+	assert.False(t, mr.Exists("grouter"), "replies are not added to streams")
+
+	assert.NoError(t, d.Publisher().PublishError(context.Background(), "", "boom"), "no reply subject")
+}
+
+func TestPublisher_Validation(t *testing.T) {
+	mr := miniredis.RunT(t)
+	d := dial(t, testConfig(mr.Addr()))
+	d.Publisher().SetValidator(conformance.RejectAll{})
+
+	err := d.Publisher().Publish(context.Background(), "orders.created", "order.created", nil, nil)
+	assert.ErrorContains(t, err, "validation failed for type order.created")
+	assert.False(t, mr.Exists("grouter"))
+}
+
+func TestPublisher_RequestTimeout(t *testing.T) {
+	mr := miniredis.RunT(t)
+	d := dial(t, testConfig(mr.Addr()))
+
+	start := time.Now()
+	_, err := d.Publisher().Request(context.Background(), "nobody", "ping", nil, 100*time.Millisecond)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second, "the request timeout bounds the wait")
+	require.NoError(t, d.HealthCheck(), "the client survives the timeout")
+}
@@ -0,0 +1,222 @@
+// Package redis is a messaging driver running the Publisher and Subscriber
+// of pkg/messaging/nats over Redis Streams, for deployments that run Redis
+// and not NATS. Subjects are published on streams, subscriptions read them
+// through consumer groups, and messages left pending by a failed or crashed
+// consumer are claimed again with XAUTOCLAIM. The driver registers itself as
+// "redis" for messaging.driver.
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// DriverName is the name of the driver in the messaging driver registry
+const DriverName = "redis"
+
+// The fields of the stream entries
+const (
+	// FieldSubject holds the subject of the entry
+	FieldSubject = "subject"
+	// FieldEnvelope holds the JSON envelope
+	FieldEnvelope = "envelope"
+	// FieldReply holds the reply subject of requests
+	FieldReply = "reply"
+	// FieldGroup holds the consumer group of dead letters
+	FieldGroup = "group"
+)
+
+// replyPrefix starts the reply subjects of requests, streams of their own
+// whatever Streams says
+const replyPrefix = "_REPLY."
+
+// replyTTL bounds the life of the reply streams of requests no longer
+// waited for
+const replyTTL = time.Minute
+
+func init() {
+	messaging.RegisterDialer(DriverName, DefaultConfig, Dial)
+}
+
+// Config holds the Redis connection and the mapping of subjects to streams.
+type Config struct {
+	// Addr is the host:port of the Redis server.
+	Addr     string `mapstructure:"addr"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	DB       int    `mapstructure:"db"`
+	// Stream is the stream of the subjects not mapped by Streams.
+	Stream string `mapstructure:"stream"`
+	// Streams map subject patterns to other streams, to split the traffic:
+	// every subscription reads the whole stream of its subject. The first
+	// match wins.
+	Streams []StreamRoute `mapstructure:"streams"`
+	// MaxLen caps the length of the streams, trimmed approximately on
+	// publish.
+	MaxLen int64 `mapstructure:"max_len"`
+	// DeadLetterStream receives the entries rejected by the subscriptions:
+	// invalid, failing twice or terminated. Empty drops them.
+	DeadLetterStream string `mapstructure:"dead_letter_stream"`
+	// Prefetch is the number of entries read at once by a subscription.
+	Prefetch int `mapstructure:"prefetch"`
+	// Block bounds the wait of a read for new entries.
+	Block time.Duration `mapstructure:"block"`
+	// MinIdle is the time after which the entries left pending by a
+	// consumer are claimed by another one.
+	MinIdle time.Duration `mapstructure:"min_idle"`
+	// ClaimInterval is the interval of the claims of the pending entries.
+	ClaimInterval time.Duration `mapstructure:"claim_interval"`
+	// DialTimeout bounds the connection to Redis.
+	DialTimeout time.Duration `mapstructure:"dial_timeout"`
+	// PublishTimeout bounds the publishes.
+	PublishTimeout time.Duration `mapstructure:"publish_timeout"`
+}
+
+// StreamRoute maps the subjects matching Subject, a NATS subject pattern, to
+// Stream.
+type StreamRoute struct {
+	Subject string `mapstructure:"subject"`
+	Stream  string `mapstructure:"stream"`
+}
+
+// DefaultConfig returns the default driver configuration.
+func DefaultConfig() Config {
+	return Config{
+		Stream:         "grouter",
+		MaxLen:         100000,
+		Prefetch:       32,
+		Block:          time.Second,
+		MinIdle:        30 * time.Second,
+		ClaimInterval:  5 * time.Second,
+		DialTimeout:    5 * time.Second,
+		PublishTimeout: 5 * time.Second,
+	}
+}
+
+// Driver is a Redis client with the Publisher and Subscriber running over
+// it.
+type Driver struct {
+	cfg    Config
+	client redis.UniversalClient
+	logger *zap.Logger
+
+	publisher  *Publisher
+	subscriber *Subscriber
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// Dial connects to the Redis server of cfg. source is the source of the
+// published envelopes, usually the app name.
+func Dial(cfg Config, logger *zap.Logger, source string) (*Driver, error) {
+	messaging.ApplyDefaults(&cfg, DefaultConfig())
+	if cfg.Addr == "" {
+		return nil, errors.New("redis addr is required")
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:        cfg.Addr,
+		Username:    cfg.Username,
+		Password:    cfg.Password,
+		DB:          cfg.DB,
+		DialTimeout: cfg.DialTimeout,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.DialTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+	d, err := New(client, cfg, logger, source)
+	if err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+// New returns the driver of a connected client, e.g. the one of the shared
+// cache. Closing the driver closes the client.
+func New(client redis.UniversalClient, cfg Config, logger *zap.Logger, source string) (*Driver, error) {
+	messaging.ApplyDefaults(&cfg, DefaultConfig())
+	for _, r := range cfg.Streams {
+		if r.Subject == "" || r.Stream == "" {
+			return nil, errors.New("redis stream routes require a subject and a stream")
+		}
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	d := &Driver{cfg: cfg, client: client, logger: logger}
+	d.publisher = newPublisher(d, source)
+	d.subscriber = newSubscriber(d)
+	return d, nil
+}
+
+// Publisher returns the publisher of the driver
+func (d *Driver) Publisher() *Publisher {
+	return d.publisher
+}
+
+// Subscriber returns the subscriber of the driver
+func (d *Driver) Subscriber() *Subscriber {
+	return d.subscriber
+}
+
+// Messenger returns a messenger of the publisher and subscriber, for
+// manager.WithMessagingDriver. It has no NATS client; closing it closes the
+// driver.
+func (d *Driver) Messenger() *messaging.Messenger {
+	return messaging.NewMessenger(nil, d.publisher, d.subscriber)
+}
+
+// HealthCheck pings the Redis server. The client reconnects by itself.
+func (d *Driver) HealthCheck() error {
+	ctx, cancel := context.WithTimeout(context.Background(), d.cfg.DialTimeout)
+	defer cancel()
+	if err := d.client.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("redis ping failed: %w", err)
+	}
+	return nil
+}
+
+// Close closes the subscriber, waiting for the handlers in flight, then the
+// client
+func (d *Driver) Close() error {
+	_ = d.subscriber.Close()
+	return d.close()
+}
+
+// close closes the client once
+func (d *Driver) close() error {
+	d.closeOnce.Do(func() {
+		if err := d.client.Close(); err != nil && !errors.Is(err, redis.ErrClosed) {
+			d.closeErr = fmt.Errorf("failed to close Redis client: %w", err)
+		}
+	})
+	return d.closeErr
+}
+
+// stream returns the stream of a subject or subject pattern
+func (d *Driver) stream(subject string) string {
+	for _, r := range d.cfg.Streams {
+		if messaging.MatchSubject(r.Subject, subject) {
+			return r.Stream
+		}
+	}
+	return d.cfg.Stream
+}
+
+// isReply reports whether subject is the reply subject of a request
+func isReply(subject string) bool {
+	return strings.HasPrefix(subject, replyPrefix)
+}
@@ -0,0 +1,128 @@
+package redis
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+	"grouter/pkg/messaging/nats/conformance"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// testConfig returns a config of the server at addr with short waits
+func testConfig(addr string) Config {
+	return Config{
+		Addr:          addr,
+		Block:         50 * time.Millisecond,
+		MinIdle:       200 * time.Millisecond,
+		ClaimInterval: 50 * time.Millisecond,
+	}
+}
+
+// dial returns a driver connected with cfg, closed at the end of the test
+func dial(t *testing.T, cfg Config) *Driver {
+	t.Helper()
+	d, err := Dial(cfg, zap.NewNop(), "test")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = d.Close() })
+	return d
+}
+
+func TestConformance(t *testing.T) {
+	mr := miniredis.RunT(t)
+	conformance.Run(t, func(t *testing.T) *messaging.Messenger {
+		m := dial(t, testConfig(mr.Addr())).Messenger()
+		t.Cleanup(func() { _ = m.Close() })
+		return m
+	})
+}
+
+// TestConformance_Redis runs the contract tests on the server of REDIS_ADDR,
+// e.g. localhost:6379
+func TestConformance_Redis(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR not set")
+	}
+	conformance.Run(t, func(t *testing.T) *messaging.Messenger {
+		cfg := testConfig(addr)
+		cfg.Stream = "grouter.conformance"
+		m := dial(t, cfg).Messenger()
+		t.Cleanup(func() { _ = m.Close() })
+		return m
+	})
+}
+
+func TestAcks(t *testing.T) {
+	conformance.RunAcks(t, func(t *testing.T) conformance.AckBroker {
+		cfg := testConfig(miniredis.RunT(t).Addr())
+		cfg.DeadLetterStream = "grouter.dead"
+		d := dial(t, cfg)
+		return conformance.AckBroker{
+			Messenger: d.Messenger(),
+			DeadLetters: func() int {
+				n, _ := d.client.XLen(context.Background(), cfg.DeadLetterStream).Result()
+				return int(n)
+			},
+			NakDelay:   50 * time.Millisecond,
+			Redelivery: cfg.MinIdle,
+		}
+	})
+}
+
+func TestUnsupported(t *testing.T) {
+	mr := miniredis.RunT(t)
+	conformance.RunUnsupported(t, func(t *testing.T) *messaging.Messenger {
+		return dial(t, testConfig(mr.Addr())).Messenger()
+	})
+}
+
+func TestDial(t *testing.T) {
+	mr := miniredis.RunT(t)
+	d := dial(t, Config{Addr: mr.Addr(), Streams: []StreamRoute{{Subject: "audit.>", Stream: "audit"}}})
+
+	assert.Equal(t, DefaultConfig().Stream, d.cfg.Stream, "defaults")
+	assert.Equal(t, DefaultConfig().MinIdle, d.cfg.MinIdle)
+	assert.Equal(t, "audit", d.stream("audit.login"))
+	assert.Equal(t, "audit", d.stream("audit.*"), "patterns within the route")
+	assert.Equal(t, "grouter", d.stream("orders.created"))
+
+	require.NoError(t, d.HealthCheck())
+	require.NoError(t, d.Messenger().Close())
+	assert.Error(t, d.HealthCheck(), "closing the messenger closes the client")
+	assert.NoError(t, d.Close(), "Close is idempotent")
+}
+
+func TestDial_Errors(t *testing.T) {
+	_, err := Dial(Config{}, nil, "test")
+	assert.ErrorContains(t, err, "redis addr is required")
+
+	_, err = Dial(Config{Addr: "127.0.0.1:1", DialTimeout: time.Second}, nil, "test")
+	assert.ErrorContains(t, err, "failed to connect to Redis")
+
+	mr := miniredis.RunT(t)
+	_, err = Dial(Config{Addr: mr.Addr(), Streams: []StreamRoute{{Subject: "audit.>"}}}, nil, "test")
+	assert.ErrorContains(t, err, "require a subject and a stream")
+}
+
+func TestRegisteredDriver(t *testing.T) {
+	mr := miniredis.RunT(t)
+	assert.Contains(t, messaging.Drivers(), DriverName)
+
+	msg, err := messaging.OpenDriver(DriverName, func(cfg any) error {
+		c := cfg.(*Config)
+		assert.Equal(t, DefaultConfig(), *c, "settings decoded over the defaults")
+		c.Addr = mr.Addr()
+		return nil
+	}, zap.NewNop(), "test")
+	require.NoError(t, err)
+	assert.Nil(t, msg.Client, "no NATS client")
+	require.NoError(t, msg.Publisher.(*Publisher).HealthCheck())
+	require.NoError(t, msg.Close())
+}
@@ -0,0 +1,361 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// Subscriber reads the streams of the subscribed subjects through consumer
+// groups. It implements messaging.Subscriber, without JetStream, payload
+// store and priority lanes.
+//
+// A subscription without queue group reads the stream with a consumer group
+// of its own, destroyed with the subscription. The members of a queue group
+// share the consumer group <group>:<subject>, which keeps the entries while
+// no member runs. Entries of the other subjects of the stream are
+// acknowledged without being handled.
+//
+// Entries are acknowledged once handled, see message. The entries left pending
+// by a consumer for MinIdle, not acknowledged or asked again, are claimed by
+// a member of the group every ClaimInterval and handled again.
+type Subscriber struct {
+	*messaging.DriverSubscriber
+	driver *Driver
+}
+
+var _ messaging.Subscriber = (*Subscriber)(nil)
+
+func newSubscriber(d *Driver) *Subscriber {
+	s := &Subscriber{driver: d}
+	s.DriverSubscriber = messaging.NewDriverSubscriber("redis", d.logger, s.subscribe, d.close)
+	return s
+}
+
+// subscribe creates the consumer group of a subscription on the stream of
+// subject and reads it with MaxWorkers handlers (default 1)
+func (s *Subscriber) subscribe(subject string, handler messaging.HandlerFunc, opts *messaging.SubscribeOptions) (messaging.DriverSubscription, error) {
+	d := s.driver
+	sub := &subscription{
+		subscriber: s,
+		subject:    subject,
+		stream:     d.stream(subject),
+		group:      opts.QueueGroup + ":" + subject,
+		consumer:   "grouter-" + uuid.NewString(),
+		deliveries: make(chan delivery),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	if opts.QueueGroup == "" {
+		sub.group, sub.plain = sub.consumer, true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), d.cfg.PublishTimeout)
+	defer cancel()
+	// New groups start with the entries added from now on
+	err := d.client.XGroupCreateMkStream(ctx, sub.stream, sub.group, "$").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil, fmt.Errorf("failed to create consumer group %s on %s: %w", sub.group, sub.stream, err)
+	}
+
+	sub.loops.Add(2)
+	go sub.read()
+	go sub.claim()
+	for i := 0; i < max(opts.MaxWorkers, 1); i++ {
+		sub.workers.Add(1)
+		go sub.run(handler)
+	}
+
+	d.logger.Info("Subscribed to subject",
+		zap.String("subject", subject),
+		zap.String("stream", sub.stream),
+		zap.String("queue_group", opts.QueueGroup),
+	)
+	return sub, nil
+}
+
+// process hands an entry to the handler and acknowledges it
+func (s *Subscriber) process(sub *subscription, d delivery, handler messaging.HandlerFunc) {
+	subject, _ := d.msg.Values[FieldSubject].(string)
+	s.Process(messaging.Delivery{
+		Subject: subject,
+		Message: message{sub: sub, delivery: d},
+		Decode: func(codec messaging.Codec, envelope *messaging.MessageEnvelope) error {
+			body, _ := d.msg.Values[FieldEnvelope].(string)
+			if err := messaging.DecodeEnvelope([]byte(body), subject, codec, envelope); err != nil {
+				return err
+			}
+			envelope.Reply, _ = d.msg.Values[FieldReply].(string)
+			return nil
+		},
+		Fields: []zap.Field{zap.String("stream", sub.stream), zap.String("id", d.msg.ID)},
+	}, handler)
+}
+
+// delivery is a stream entry read by a subscription
+type delivery struct {
+	msg redis.XMessage
+	// redelivered is set for the entries claimed from a consumer
+	redelivered bool
+}
+
+// subscription is a consumer of the stream of a subject, in the consumer
+// group of its queue group or of its own
+type subscription struct {
+	subscriber *Subscriber
+	subject    string
+	stream     string
+	group      string
+	consumer   string
+	// plain subscriptions have a consumer group of their own
+	plain bool
+
+	deliveries chan delivery
+	// loops are the reading and claiming goroutines, workers the handlers
+	loops    sync.WaitGroup
+	workers  sync.WaitGroup
+	stop     chan struct{}
+	stopOnce sync.Once
+	// done is closed once the handlers returned and the group is cleaned up
+	done chan struct{}
+}
+
+func (s *subscription) Subject() string {
+	return s.subject
+}
+
+func (s *subscription) stopped() bool {
+	select {
+	case <-s.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// read hands the new entries of the stream to the workers until the
+// subscription stops
+func (s *subscription) read() {
+	defer s.loops.Done()
+	d := s.subscriber.driver
+	for !s.stopped() {
+		streams, err := d.client.XReadGroup(context.Background(), &redis.XReadGroupArgs{
+			Group:    s.group,
+			Consumer: s.consumer,
+			Streams:  []string{s.stream, ">"},
+			Count:    int64(d.cfg.Prefetch),
+			Block:    d.cfg.Block,
+		}).Result()
+		if s.stopped() || errors.Is(err, redis.ErrClosed) {
+			return
+		}
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			d.logger.Warn("Failed to read stream", zap.Error(err), zap.String("stream", s.stream), zap.String("group", s.group))
+			select {
+			case <-s.stop:
+			case <-time.After(d.cfg.Block):
+			}
+			continue
+		}
+		for _, stream := range streams {
+			if !s.deliver(stream.Messages, false) {
+				return
+			}
+		}
+	}
+}
+
+// claim takes over the entries of the group left pending for MinIdle, every
+// ClaimInterval, and hands them to the workers again
+func (s *subscription) claim() {
+	defer s.loops.Done()
+	d := s.subscriber.driver
+	ticker := time.NewTicker(d.cfg.ClaimInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+		start := "0-0"
+		for {
+			msgs, next, err := d.client.XAutoClaim(context.Background(), &redis.XAutoClaimArgs{
+				Stream:   s.stream,
+				Group:    s.group,
+				Consumer: s.consumer,
+				MinIdle:  d.cfg.MinIdle,
+				Start:    start,
+				Count:    int64(d.cfg.Prefetch),
+			}).Result()
+			if err != nil {
+				if !s.stopped() && !errors.Is(err, redis.ErrClosed) {
+					d.logger.Warn("Failed to claim pending entries", zap.Error(err), zap.String("stream", s.stream), zap.String("group", s.group))
+				}
+				break
+			}
+			if !s.deliver(msgs, true) {
+				return
+			}
+			if next == "" || next == "0-0" {
+				break
+			}
+			start = next
+		}
+	}
+}
+
+// deliver acknowledges the entries of the other subjects of the stream and
+// hands the others to the workers. It returns false once the subscription
+// stops; the entries not handed over stay pending.
+func (s *subscription) deliver(msgs []redis.XMessage, redelivered bool) bool {
+	var others []string
+	matched := msgs[:0:0]
+	for _, msg := range msgs {
+		subject, _ := msg.Values[FieldSubject].(string)
+		// The group of "orders.>" also reads "orders", the stream holds
+		// every subject
+		if messaging.MatchSubject(s.subject, subject) {
+			matched = append(matched, msg)
+		} else {
+			others = append(others, msg.ID)
+		}
+	}
+	if len(others) > 0 {
+		if err := s.ack(others...); err != nil && !s.stopped() {
+			s.subscriber.driver.logger.Warn("Failed to acknowledge entries of other subjects", zap.Error(err), zap.String("stream", s.stream))
+		}
+	}
+
+	for _, msg := range matched {
+		s.subscriber.AddPending(1)
+		select {
+		case s.deliveries <- delivery{msg: msg, redelivered: redelivered}:
+		case <-s.stop:
+			s.subscriber.AddPending(-1)
+			return false
+		}
+	}
+	return true
+}
+
+// run hands the deliveries to handler until the subscription stops
+func (s *subscription) run(handler messaging.HandlerFunc) {
+	defer s.workers.Done()
+	for {
+		select {
+		case d := <-s.deliveries:
+			s.subscriber.process(s, d, handler)
+			s.subscriber.AddPending(-1)
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// ack acknowledges entries in the group
+func (s *subscription) ack(ids ...string) error {
+	d := s.subscriber.driver
+	ctx, cancel := context.WithTimeout(context.Background(), d.cfg.PublishTimeout)
+	defer cancel()
+	return d.client.XAck(ctx, s.stream, s.group, ids...).Err()
+}
+
+// setIdle sets the idle time of a pending entry: claimed again once it
+// reaches MinIdle, see claim
+func (s *subscription) setIdle(id string, idle time.Duration) error {
+	d := s.subscriber.driver
+	ctx, cancel := context.WithTimeout(context.Background(), d.cfg.PublishTimeout)
+	defer cancel()
+	return d.client.Do(ctx, "XCLAIM", s.stream, s.group, s.consumer, 0, id,
+		"IDLE", max(idle, 0).Milliseconds(), "JUSTID").Err()
+}
+
+// deadLetter adds the entry to the DeadLetterStream, if any, and
+// acknowledges it
+func (s *subscription) deadLetter(msg redis.XMessage) error {
+	d := s.subscriber.driver
+	if d.cfg.DeadLetterStream == "" {
+		return s.ack(msg.ID)
+	}
+	values := make(map[string]interface{}, len(msg.Values)+1)
+	for k, v := range msg.Values {
+		values[k] = v
+	}
+	values[FieldGroup] = s.group
+
+	ctx, cancel := context.WithTimeout(context.Background(), d.cfg.PublishTimeout)
+	defer cancel()
+	_, err := d.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: d.cfg.DeadLetterStream,
+			MaxLen: d.cfg.MaxLen,
+			Approx: true,
+			Values: values,
+		})
+		pipe.XAck(ctx, s.stream, s.group, msg.ID)
+		return nil
+	})
+	return err
+}
+
+// Stop stops the reads, then cleans the group up once the handlers return,
+// so that they can still acknowledge their entries
+func (s *subscription) Stop() error {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+		go func() {
+			defer close(s.done)
+			s.workers.Wait()
+			s.cleanup()
+		}()
+	})
+	return nil
+}
+
+func (s *subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// cleanup destroys the consumer group of plain subscriptions, with the
+// entries left pending. The consumer of a queue group member is removed once
+// it has no pending entry, left to the other members otherwise.
+func (s *subscription) cleanup() {
+	d := s.subscriber.driver
+	ctx, cancel := context.WithTimeout(context.Background(), d.cfg.PublishTimeout)
+	defer cancel()
+	var err error
+	if s.plain {
+		err = d.client.XGroupDestroy(ctx, s.stream, s.group).Err()
+	} else {
+		// The reader may still add entries to the consumer
+		s.loops.Wait()
+		var pending []redis.XPendingExt
+		pending, err = d.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+			Stream:   s.stream,
+			Group:    s.group,
+			Start:    "-",
+			End:      "+",
+			Count:    1,
+			Consumer: s.consumer,
+		}).Result()
+		if err == nil && len(pending) == 0 {
+			err = d.client.XGroupDelConsumer(ctx, s.stream, s.group, s.consumer).Err()
+		}
+	}
+	if err != nil && !errors.Is(err, redis.ErrClosed) {
+		d.logger.Warn("Failed to clean up consumer group", zap.Error(err), zap.String("stream", s.stream), zap.String("group", s.group))
+	}
+}
@@ -0,0 +1,121 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+	"grouter/pkg/messaging/nats/conformance"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// streamLen returns the number of entries of stream
+func streamLen(t *testing.T, d *Driver, stream string) int64 {
+	t.Helper()
+	n, err := d.client.XLen(context.Background(), stream).Result()
+	require.NoError(t, err)
+	return n
+}
+
+func TestSubscriber_Redelivery(t *testing.T) {
+	mr := miniredis.RunT(t)
+	cfg := testConfig(mr.Addr())
+	cfg.DeadLetterStream = "grouter.dead"
+	d := dial(t, cfg)
+
+	var calls atomic.Int32
+	require.NoError(t, d.Subscriber().Subscribe("jobs", func(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+		calls.Add(1)
+		return errors.New("boom")
+	}, &messaging.SubscribeOptions{QueueGroup: "workers"}))
+	require.NoError(t, d.Publisher().Publish(context.Background(), "jobs", "job", nil, nil))
+
+	conformance.WaitFor(t, func() bool { return streamLen(t, d, "grouter.dead") == 1 }, "failing twice dead-letters the entry")
+	assert.Equal(t, int32(2), calls.Load(), "claimed again once, then rejected")
+
+	entries, err := d.client.XRange(context.Background(), "grouter.dead", "-", "+").Result()
+	require.NoError(t, err)
+	assert.Equal(t, "jobs", entries[0].Values[FieldSubject])
+	assert.Equal(t, "workers:jobs", entries[0].Values[FieldGroup])
+	pending, err := d.client.XPending(context.Background(), "grouter", "workers:jobs").Result()
+	require.NoError(t, err)
+	assert.Zero(t, pending.Count, "dead letters are acknowledged")
+}
+
+func TestSubscriber_ClaimsFromCrashedMember(t *testing.T) {
+	mr := miniredis.RunT(t)
+	d := dial(t, testConfig(mr.Addr()))
+	ctx := context.Background()
+
+	// A member read the entry and died without acknowledging it
+	require.NoError(t, d.client.XGroupCreateMkStream(ctx, "grouter", "workers:jobs", "$").Err())
+	require.NoError(t, d.Publisher().Publish(ctx, "jobs", "job", nil, nil))
+	_, err := d.client.XReadGroup(ctx, &redis.XReadGroupArgs{Group: "workers:jobs", Consumer: "crashed", Streams: []string{"grouter", ">"}}).Result()
+	require.NoError(t, err)
+
+	handled := make(chan *messaging.MessageEnvelope, 1)
+	require.NoError(t, d.Subscriber().Subscribe("jobs", func(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+		handled <- env
+		return nil
+	}, &messaging.SubscribeOptions{QueueGroup: "workers"}))
+	select {
+	case env := <-handled:
+		assert.Equal(t, "job", env.Type)
+	case <-time.After(3 * time.Second):
+		require.FailNow(t, "pending entry not claimed")
+	}
+	conformance.WaitFor(t, func() bool {
+		pending, err := d.client.XPending(ctx, "grouter", "workers:jobs").Result()
+		return err == nil && pending.Count == 0
+	}, "claimed entries are acknowledged")
+}
+
+func TestSubscriber_InvalidEntries(t *testing.T) {
+	mr := miniredis.RunT(t)
+	cfg := testConfig(mr.Addr())
+	cfg.DeadLetterStream = "grouter.dead"
+	d := dial(t, cfg)
+	ctx := context.Background()
+
+	var calls atomic.Int32
+	require.NoError(t, d.Subscriber().Subscribe("orders.*", func(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+		calls.Add(1)
+		return nil
+	}, nil))
+	require.NoError(t, d.client.XAdd(ctx, &redis.XAddArgs{Stream: "grouter", Values: []string{FieldSubject, "orders.created", FieldEnvelope, "not json"}}).Err())
+	require.NoError(t, d.Publisher().Publish(ctx, "payments.created", "payment", nil, nil))
+
+	conformance.WaitFor(t, func() bool { return streamLen(t, d, "grouter.dead") == 1 }, "undecodable entries are dead-lettered")
+	assert.Zero(t, calls.Load())
+	assert.Zero(t, d.Subscriber().QueueDepth())
+}
+
+func TestSubscriber_Unsubscribe(t *testing.T) {
+	mr := miniredis.RunT(t)
+	d := dial(t, testConfig(mr.Addr()))
+	ctx := context.Background()
+	handler := func(ctx context.Context, subject string, env *messaging.MessageEnvelope) error { return nil }
+
+	plain, err := d.Subscriber().SubscribeSubject("orders", handler, nil)
+	require.NoError(t, err)
+	member, err := d.Subscriber().SubscribeSubject("orders", handler, &messaging.SubscribeOptions{QueueGroup: "billing"})
+	require.NoError(t, err)
+	groups, err := d.client.XInfoGroups(ctx, "grouter").Result()
+	require.NoError(t, err)
+	assert.Len(t, groups, 2)
+
+	require.NoError(t, plain.Drain(ctx))
+	require.NoError(t, member.Drain(ctx))
+	groups, err = d.client.XInfoGroups(ctx, "grouter").Result()
+	require.NoError(t, err)
+	require.Len(t, groups, 1, "the group of plain subscriptions is destroyed")
+	assert.Equal(t, "billing:orders", groups[0].Name, "queue groups keep their entries")
+	assert.Zero(t, groups[0].Consumers, "idle members are removed")
+}
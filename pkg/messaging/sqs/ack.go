@@ -0,0 +1,44 @@
+package sqs
+
+import (
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+)
+
+// message is the messaging.DriverMessage of a received message, settled by a
+// messaging.DriverAcker. Rejected messages go to the DeadLetterQueueURL, if
+// any; those left in the queue under AckManual are received again after the
+// VisibilityTimeout.
+type message struct {
+	sub      *subscription
+	delivery delivery
+}
+
+var _ messaging.DriverMessage = message{}
+
+// Ack deletes the message from the queue: DeleteMessage is answered once
+// applied
+func (m message) Ack() error {
+	return m.sub.delete(m.delivery.msg)
+}
+
+// Nak makes the message visible again after delay, in seconds
+func (m message) Nak(delay time.Duration) error {
+	return m.sub.setVisibility(m.delivery.msg, delay)
+}
+
+// InProgress extends the visibility timeout of the message by the
+// VisibilityTimeout, so that long handlers keep it
+func (m message) InProgress() error {
+	return m.sub.setVisibility(m.delivery.msg, m.sub.subscriber.driver.cfg.VisibilityTimeout)
+}
+
+// Term rejects the message
+func (m message) Term() error {
+	return m.sub.deadLetter(m.delivery.msg)
+}
+
+func (m message) Redelivered() bool {
+	return m.delivery.redelivered()
+}
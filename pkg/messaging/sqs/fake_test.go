@@ -0,0 +1,355 @@
+package sqs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// fakeAWS is an in-memory SNS and SQS: topics fan raw messages out to the
+// queues of the subscriptions whose filter policy matches, queues hide the
+// received messages for their visibility timeout
+type fakeAWS struct {
+	mu            sync.Mutex
+	seq           int
+	topics        map[string]map[string]*fakeSubscription
+	queues        map[string]*fakeQueue
+	subscriptions map[string]*fakeSubscription
+}
+
+type fakeSubscription struct {
+	topic    string
+	queueARN string
+	filter   map[string][]any
+}
+
+type fakeQueue struct {
+	name       string
+	url        string
+	arn        string
+	visibility time.Duration
+	msgs       []*fakeMessage
+}
+
+type fakeMessage struct {
+	id        string
+	body      string
+	attrs     map[string]types.MessageAttributeValue
+	sent      time.Time
+	visibleAt time.Time
+	receives  int
+	handle    string
+}
+
+const fakeAccount = "arn:aws:sns:us-east-1:000000000000:"
+
+// newFakeAWS returns a fake with the given topics
+func newFakeAWS(topics ...string) *fakeAWS {
+	f := &fakeAWS{
+		topics:        make(map[string]map[string]*fakeSubscription),
+		queues:        make(map[string]*fakeQueue),
+		subscriptions: make(map[string]*fakeSubscription),
+	}
+	for _, name := range topics {
+		f.topics[fakeAccount+name] = make(map[string]*fakeSubscription)
+	}
+	return f
+}
+
+func (f *fakeAWS) next() string {
+	f.seq++
+	return strconv.Itoa(f.seq)
+}
+
+// queueNames returns the names of the queues
+func (f *fakeAWS) queueNames() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var names []string
+	for _, q := range f.queues {
+		names = append(names, q.name)
+	}
+	return names
+}
+
+// messages returns the number of messages of the queue of name
+func (f *fakeAWS) messages(name string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, q := range f.queues {
+		if q.name == name {
+			return len(q.msgs)
+		}
+	}
+	return 0
+}
+
+// bodies returns the messages of the queue of name
+func (f *fakeAWS) bodies(name string) []*fakeMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, q := range f.queues {
+		if q.name == name {
+			return append([]*fakeMessage(nil), q.msgs...)
+		}
+	}
+	return nil
+}
+
+func (f *fakeAWS) Publish(ctx context.Context, in *sns.PublishInput, _ ...func(*sns.Options)) (*sns.PublishOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	subs, ok := f.topics[aws.ToString(in.TopicArn)]
+	if !ok {
+		return nil, fmt.Errorf("NotFound: topic %s does not exist", aws.ToString(in.TopicArn))
+	}
+	if isFIFO(aws.ToString(in.TopicArn)) && in.MessageGroupId == nil {
+		return nil, fmt.Errorf("InvalidParameter: MessageGroupId is required")
+	}
+	attrs := make(map[string]types.MessageAttributeValue, len(in.MessageAttributes))
+	for name, value := range in.MessageAttributes {
+		if aws.ToString(value.StringValue) == "" {
+			return nil, fmt.Errorf("InvalidParameterValue: attribute %s has no value", name)
+		}
+		attrs[name] = types.MessageAttributeValue{DataType: value.DataType, StringValue: value.StringValue}
+	}
+	id := f.next()
+	for _, sub := range subs {
+		if !sub.matches(attrs) {
+			continue
+		}
+		for _, q := range f.queues {
+			if q.arn == sub.queueARN {
+				q.msgs = append(q.msgs, &fakeMessage{id: id, body: aws.ToString(in.Message), attrs: attrs, sent: time.Now()})
+			}
+		}
+	}
+	return &sns.PublishOutput{MessageId: aws.String(id)}, nil
+}
+
+// matches applies the filter policy of the subscription to the attributes
+func (s *fakeSubscription) matches(attrs map[string]types.MessageAttributeValue) bool {
+	for name, conditions := range s.filter {
+		value, ok := attrs[name]
+		if !ok {
+			return false
+		}
+		matched := false
+		for _, c := range conditions {
+			switch c := c.(type) {
+			case string:
+				matched = matched || c == aws.ToString(value.StringValue)
+			case map[string]any:
+				prefix, _ := c["prefix"].(string)
+				matched = matched || strings.HasPrefix(aws.ToString(value.StringValue), prefix)
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *fakeAWS) Subscribe(ctx context.Context, in *sns.SubscribeInput, _ ...func(*sns.Options)) (*sns.SubscribeOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	subs, ok := f.topics[aws.ToString(in.TopicArn)]
+	if !ok {
+		return nil, fmt.Errorf("NotFound: topic %s does not exist", aws.ToString(in.TopicArn))
+	}
+	if in.Attributes["RawMessageDelivery"] != "true" {
+		return nil, fmt.Errorf("only raw message delivery is faked")
+	}
+	sub := &fakeSubscription{topic: aws.ToString(in.TopicArn), queueARN: aws.ToString(in.Endpoint)}
+	if policy := in.Attributes["FilterPolicy"]; policy != "" {
+		if err := json.Unmarshal([]byte(policy), &sub.filter); err != nil {
+			return nil, fmt.Errorf("InvalidParameter: FilterPolicy: %w", err)
+		}
+	}
+	// Subscribing the same endpoint again returns its subscription
+	for arn, other := range subs {
+		if other.queueARN == sub.queueARN {
+			return &sns.SubscribeOutput{SubscriptionArn: aws.String(arn)}, nil
+		}
+	}
+	arn := sub.topic + ":" + f.next()
+	subs[arn] = sub
+	f.subscriptions[arn] = sub
+	return &sns.SubscribeOutput{SubscriptionArn: aws.String(arn)}, nil
+}
+
+func (f *fakeAWS) Unsubscribe(ctx context.Context, in *sns.UnsubscribeInput, _ ...func(*sns.Options)) (*sns.UnsubscribeOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	arn := aws.ToString(in.SubscriptionArn)
+	if sub, ok := f.subscriptions[arn]; ok {
+		delete(f.topics[sub.topic], arn)
+		delete(f.subscriptions, arn)
+	}
+	return &sns.UnsubscribeOutput{}, nil
+}
+
+func (f *fakeAWS) GetTopicAttributes(ctx context.Context, in *sns.GetTopicAttributesInput, _ ...func(*sns.Options)) (*sns.GetTopicAttributesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.topics[aws.ToString(in.TopicArn)]; !ok {
+		return nil, fmt.Errorf("NotFound: topic %s does not exist", aws.ToString(in.TopicArn))
+	}
+	return &sns.GetTopicAttributesOutput{Attributes: map[string]string{"TopicArn": aws.ToString(in.TopicArn)}}, nil
+}
+
+func (f *fakeAWS) CreateQueue(ctx context.Context, in *sqs.CreateQueueInput, _ ...func(*sqs.Options)) (*sqs.CreateQueueOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := aws.ToString(in.QueueName)
+	if len(name) > 80 {
+		return nil, fmt.Errorf("InvalidParameterValue: queue name %s is too long", name)
+	}
+	if isFIFO(name) != (in.Attributes[string(types.QueueAttributeNameFifoQueue)] == "true") {
+		return nil, fmt.Errorf("InvalidParameterValue: FIFO queue names end with .fifo")
+	}
+	for _, q := range f.queues {
+		if q.name == name {
+			return &sqs.CreateQueueOutput{QueueUrl: aws.String(q.url)}, nil
+		}
+	}
+	seconds, _ := strconv.Atoi(in.Attributes[string(types.QueueAttributeNameVisibilityTimeout)])
+	q := &fakeQueue{
+		name:       name,
+		url:        queueURL(name),
+		arn:        "arn:aws:sqs:us-east-1:000000000000:" + name,
+		visibility: time.Duration(seconds) * time.Second,
+	}
+	f.queues[q.url] = q
+	return &sqs.CreateQueueOutput{QueueUrl: aws.String(q.url)}, nil
+}
+
+func (f *fakeAWS) DeleteQueue(ctx context.Context, in *sqs.DeleteQueueInput, _ ...func(*sqs.Options)) (*sqs.DeleteQueueOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.queues[aws.ToString(in.QueueUrl)]; !ok {
+		return nil, fmt.Errorf("QueueDoesNotExist: %s", aws.ToString(in.QueueUrl))
+	}
+	delete(f.queues, aws.ToString(in.QueueUrl))
+	return &sqs.DeleteQueueOutput{}, nil
+}
+
+// ReceiveMessage polls the queue until a message is visible or the wait
+// time elapses
+func (f *fakeAWS) ReceiveMessage(ctx context.Context, in *sqs.ReceiveMessageInput, _ ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	deadline := time.Now().Add(time.Duration(in.WaitTimeSeconds) * time.Second)
+	for {
+		// a round trip per poll, like SQS, lets the other consumers of the
+		// queue take their share
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Millisecond):
+		}
+		msgs, err := f.receive(in)
+		if err != nil || len(msgs) > 0 || !time.Now().Before(deadline) {
+			return &sqs.ReceiveMessageOutput{Messages: msgs}, err
+		}
+	}
+}
+
+func (f *fakeAWS) receive(in *sqs.ReceiveMessageInput) ([]types.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	q, ok := f.queues[aws.ToString(in.QueueUrl)]
+	if !ok {
+		return nil, fmt.Errorf("QueueDoesNotExist: %s", aws.ToString(in.QueueUrl))
+	}
+	now := time.Now()
+	var msgs []types.Message
+	for _, m := range q.msgs {
+		if len(msgs) == int(max(in.MaxNumberOfMessages, 1)) {
+			break
+		}
+		if now.Before(m.visibleAt) {
+			continue
+		}
+		m.receives++
+		m.handle = f.next()
+		m.visibleAt = now.Add(q.visibility)
+		msgs = append(msgs, types.Message{
+			MessageId:         aws.String(m.id),
+			ReceiptHandle:     aws.String(m.handle),
+			Body:              aws.String(m.body),
+			MessageAttributes: m.attrs,
+			Attributes: map[string]string{
+				string(types.MessageSystemAttributeNameApproximateReceiveCount): strconv.Itoa(m.receives),
+				string(types.MessageSystemAttributeNameSentTimestamp):           strconv.FormatInt(m.sent.UnixMilli(), 10),
+			},
+		})
+	}
+	return msgs, nil
+}
+
+// message returns the message of a receipt handle
+func (f *fakeAWS) message(url string, handle *string) (*fakeQueue, int, error) {
+	q, ok := f.queues[url]
+	if !ok {
+		return nil, 0, fmt.Errorf("QueueDoesNotExist: %s", url)
+	}
+	for i, m := range q.msgs {
+		if m.handle == aws.ToString(handle) {
+			return q, i, nil
+		}
+	}
+	return nil, 0, fmt.Errorf("ReceiptHandleIsInvalid: %s", aws.ToString(handle))
+}
+
+func (f *fakeAWS) DeleteMessage(ctx context.Context, in *sqs.DeleteMessageInput, _ ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	q, i, err := f.message(aws.ToString(in.QueueUrl), in.ReceiptHandle)
+	if err != nil {
+		return nil, err
+	}
+	q.msgs = append(q.msgs[:i], q.msgs[i+1:]...)
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func (f *fakeAWS) ChangeMessageVisibility(ctx context.Context, in *sqs.ChangeMessageVisibilityInput, _ ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	q, i, err := f.message(aws.ToString(in.QueueUrl), in.ReceiptHandle)
+	if err != nil {
+		return nil, err
+	}
+	q.msgs[i].visibleAt = time.Now().Add(time.Duration(in.VisibilityTimeout) * time.Second)
+	return &sqs.ChangeMessageVisibilityOutput{}, nil
+}
+
+func (f *fakeAWS) SendMessage(ctx context.Context, in *sqs.SendMessageInput, _ ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	q, ok := f.queues[aws.ToString(in.QueueUrl)]
+	if !ok {
+		return nil, fmt.Errorf("QueueDoesNotExist: %s", aws.ToString(in.QueueUrl))
+	}
+	id := f.next()
+	q.msgs = append(q.msgs, &fakeMessage{id: id, body: aws.ToString(in.MessageBody), attrs: in.MessageAttributes, sent: time.Now()})
+	return &sqs.SendMessageOutput{MessageId: aws.String(id)}, nil
+}
+
+// queueURL returns the URL of the queue name created with CreateQueue
+func queueURL(name string) string {
+	return "https://sqs.us-east-1.amazonaws.com/000000000000/" + name
+}
+
+var (
+	_ SNSAPI = (*fakeAWS)(nil)
+	_ SQSAPI = (*fakeAWS)(nil)
+)
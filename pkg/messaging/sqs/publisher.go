@@ -0,0 +1,234 @@
+package sqs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+)
+
+// Publisher publishes envelopes on the SNS topics of their subjects. It
+// implements messaging.Publisher, without JetStream and payload store.
+//
+// The subject, envelope version and type are message attributes, followed
+// by the metadata of the envelope with valid attribute names, up to the 10
+// attributes of SNS, for the filter policies of other consumers.
+type Publisher struct {
+	*messaging.DriverPublisher
+	driver *Driver
+
+	// replies receives the responses of the requests, see request
+	replies replies
+}
+
+var _ messaging.Publisher = (*Publisher)(nil)
+
+func newPublisher(d *Driver, source string) *Publisher {
+	p := &Publisher{driver: d}
+	p.DriverPublisher = messaging.NewDriverPublisher(source, d.logger, p.publish, p.request)
+	p.replies.subject = replyPrefix + uuid.NewString()
+	p.replies.waiting = make(map[string]chan *messaging.MessageEnvelope)
+	return p
+}
+
+// SetPayloadStore is a no-op: messages are limited to the 256 KiB of SNS,
+// compress larger envelopes with SetCompressor
+func (p *Publisher) SetPayloadStore(messaging.PayloadStore) {}
+
+// SetSkipFlush is a no-op: every publish waits for the reply of SNS
+func (p *Publisher) SetSkipFlush(bool) {}
+
+// HealthCheck reads the attributes of the default topic
+func (p *Publisher) HealthCheck() error {
+	return p.driver.HealthCheck()
+}
+
+// publish sends the envelope on the topic of subject
+func (p *Publisher) publish(ctx context.Context, subject string, envelope *messaging.MessageEnvelope, _ *messaging.PublishOptions) error {
+	return p.send(ctx, subject, envelope)
+}
+
+// send publishes the envelope on the topic of subject. Messages of FIFO
+// topics are ordered per subject and deduplicated on the envelope ID.
+func (p *Publisher) send(ctx context.Context, subject string, envelope *messaging.MessageEnvelope) error {
+	body, err := p.Marshal(subject, envelope)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, p.driver.cfg.PublishTimeout)
+	defer cancel()
+
+	topic := p.driver.topic(subject)
+	in := &sns.PublishInput{
+		TopicArn:          aws.String(topic),
+		Message:           aws.String(string(body)),
+		MessageAttributes: attributes(subject, envelope),
+	}
+	if isFIFO(topic) {
+		in.MessageGroupId = aws.String(subject)
+		in.MessageDeduplicationId = aws.String(envelope.ID)
+	}
+	if _, err := p.driver.sns.Publish(ctx, in); err != nil {
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
+	return nil
+}
+
+// attributeName matches the valid message attribute names
+var attributeName = regexp.MustCompile(`^[A-Za-z0-9_-]+(\.[A-Za-z0-9_-]+)*$`)
+
+// attributes returns the message attributes of the envelope published on
+// subject
+func attributes(subject string, envelope *messaging.MessageEnvelope) map[string]snstypes.MessageAttributeValue {
+	attrs := map[string]snstypes.MessageAttributeValue{
+		AttributeSubject:  stringAttribute(subject),
+		AttributeEnvelope: {DataType: aws.String("Number"), StringValue: aws.String(strconv.Itoa(envelope.Version))},
+	}
+	if envelope.Type != "" {
+		attrs[AttributeType] = stringAttribute(envelope.Type)
+	}
+	keys := make([]string, 0, len(envelope.Metadata))
+	for k := range envelope.Metadata {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		if len(attrs) == maxAttributes {
+			break
+		}
+		if _, ok := attrs[k]; ok || envelope.Metadata[k] == "" || len(k) > 256 || !attributeName.MatchString(k) || reservedAttribute(k) {
+			continue
+		}
+		attrs[k] = stringAttribute(envelope.Metadata[k])
+	}
+	return attrs
+}
+
+// reservedAttribute reports whether name is reserved by AWS
+func reservedAttribute(name string) bool {
+	name = strings.ToLower(name)
+	return strings.HasPrefix(name, "aws.") || strings.HasPrefix(name, "amazon.")
+}
+
+func stringAttribute(value string) snstypes.MessageAttributeValue {
+	return snstypes.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(value)}
+}
+
+// request publishes the request with the reply subject
+// _REPLY.<publisher>.<request> and waits for the response, received by the
+// reply queue of the publisher
+func (p *Publisher) request(ctx context.Context, subject string, envelope *messaging.MessageEnvelope) (*messaging.MessageEnvelope, error) {
+	if err := p.replies.start(p.driver); err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	envelope.Reply = p.replies.subject + "." + uuid.NewString()
+	response := p.replies.wait(envelope.Reply)
+	defer p.replies.cancel(envelope.Reply)
+
+	if err := p.send(ctx, subject, envelope); err != nil {
+		return nil, err
+	}
+	select {
+	case env := <-response:
+		return env, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("request failed: %w", ctx.Err())
+	}
+}
+
+// PublishJS is not supported: SNS has no JetStream
+func (p *Publisher) PublishJS(ctx context.Context, subject string, msgType string, data interface{}, opts ...nats.PubOpt) (*nats.PubAck, error) {
+	return nil, fmt.Errorf("sqs: PublishJS: %w, use Publish: queues are durable", errors.ErrUnsupported)
+}
+
+// PublishAsyncJS is not supported: SNS has no JetStream
+func (p *Publisher) PublishAsyncJS(ctx context.Context, subject string, msgType string, data interface{}, opts ...nats.PubOpt) (nats.PubAckFuture, error) {
+	return nil, fmt.Errorf("sqs: PublishAsyncJS: %w, use Publish", errors.ErrUnsupported)
+}
+
+// replies is the reply queue of a publisher, subscribed to
+// _REPLY.<publisher>.> on the first request, and the requests waiting for a
+// response
+type replies struct {
+	subject string
+
+	mu      sync.Mutex
+	sub     *subscription
+	closed  bool
+	waiting map[string]chan *messaging.MessageEnvelope
+}
+
+// start subscribes the reply queue unless it is subscribed already
+func (r *replies) start(d *Driver) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return errors.New("driver closed")
+	}
+	if r.sub != nil {
+		return nil
+	}
+	sub, err := d.subscriber.subscribe(r.subject+".>", r.handle, &messaging.SubscribeOptions{MaxWorkers: 4}, true)
+	if err != nil {
+		return err
+	}
+	r.sub = sub
+	return nil
+}
+
+// wait returns the channel of the response to the reply subject
+func (r *replies) wait(reply string) <-chan *messaging.MessageEnvelope {
+	ch := make(chan *messaging.MessageEnvelope, 1)
+	r.mu.Lock()
+	r.waiting[reply] = ch
+	r.mu.Unlock()
+	return ch
+}
+
+func (r *replies) cancel(reply string) {
+	r.mu.Lock()
+	delete(r.waiting, reply)
+	r.mu.Unlock()
+}
+
+// handle hands a response to its request, dropping the late ones
+func (r *replies) handle(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+	r.mu.Lock()
+	ch, ok := r.waiting[subject]
+	delete(r.waiting, subject)
+	r.mu.Unlock()
+	if ok {
+		ch <- env
+	}
+	return nil
+}
+
+// closeReplies unsubscribes the reply queue, deleting it
+func (p *Publisher) closeReplies() {
+	r := &p.replies
+	r.mu.Lock()
+	sub := r.sub
+	r.closed, r.sub = true, nil
+	r.mu.Unlock()
+	if sub == nil {
+		return
+	}
+	_ = sub.Stop()
+	select {
+	case <-sub.done:
+	case <-time.After(5 * time.Second):
+	}
+}
@@ -0,0 +1,115 @@
+package sqs
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+	"grouter/pkg/messaging/nats/conformance"
+	"grouter/pkg/tenant"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// subscribeQueue creates the queue of a member of group on subject, stops
+// the member and returns the queue, left subscribed to the topic
+func subscribeQueue(t *testing.T, d *Driver, subject, group string) string {
+	t.Helper()
+	sub, err := d.Subscriber().SubscribeSubject(subject, func(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+		return nil
+	}, &messaging.SubscribeOptions{QueueGroup: group})
+	require.NoError(t, err)
+	require.NoError(t, sub.Unsubscribe(), "the queue of the group stays subscribed")
+	queue := messaging.DriverSubscriptionOf(sub).(*subscription)
+	<-queue.Done()
+	return queue.queue
+}
+
+func TestPublisher_Publish(t *testing.T) {
+	f := newFakeAWS("grouter", "audit")
+	cfg := testConfig()
+	cfg.Topics = []TopicRoute{{Subject: "audit.>", TopicARN: fakeAccount + "audit"}}
+	d := newDriver(t, f, cfg)
+	ctx := context.Background()
+	orders := subscribeQueue(t, d, "orders.*", "billing")
+	audit := subscribeQueue(t, d, "audit.>", "billing")
+
+	ctx = tenant.NewContext(ctx, "acme")
+	require.NoError(t, d.Publisher().Publish(ctx, "orders.created", "order.created", map[string]int{"id": 42}, &messaging.PublishOptions{TTL: time.Minute}))
+	require.NoError(t, d.Publisher().Publish(ctx, "audit.login", "login", nil, nil))
+
+	msgs := f.bodies(orders)
+	require.Len(t, msgs, 1, "routed subjects go to their topic")
+	assert.Equal(t, "orders.created", aws.ToString(msgs[0].attrs[AttributeSubject].StringValue))
+	assert.Equal(t, "order.created", aws.ToString(msgs[0].attrs[AttributeType].StringValue))
+	assert.Equal(t, "Number", aws.ToString(msgs[0].attrs[AttributeEnvelope].DataType))
+	assert.Equal(t, "acme", aws.ToString(msgs[0].attrs[messaging.MetadataTenantID].StringValue), "metadata attributes")
+
+	var env messaging.MessageEnvelope
+	require.NoError(t, json.Unmarshal([]byte(msgs[0].body), &env))
+	assert.Equal(t, "order.created", env.Type)
+	assert.Equal(t, "test", env.Source)
+	assert.JSONEq(t, `{"id":42}`, string(env.Data))
+	assert.False(t, env.ExpiresAt.IsZero(), "TTL")
+	assert.Equal(t, 1, f.messages(audit))
+}
+
+func TestAttributes(t *testing.T) {
+	env := &messaging.MessageEnvelope{Metadata: map[string]string{"tenant_id": "acme", "AWS.reserved": "x", "bad name": "x", "a..b": "x", "empty": ""}}
+	attrs := attributes("orders", env)
+	assert.Len(t, attrs, 3, "invalid and reserved names and empty values are left out")
+	assert.Equal(t, "acme", aws.ToString(attrs["tenant_id"].StringValue))
+	assert.NotContains(t, attrs, AttributeType, "no empty type")
+
+	env = &messaging.MessageEnvelope{Type: "order", Metadata: map[string]string{}}
+	for _, k := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "subject"} {
+		env.Metadata[k] = "x"
+	}
+	attrs = attributes("orders", env)
+	assert.Len(t, attrs, maxAttributes)
+	assert.Equal(t, "orders", aws.ToString(attrs[AttributeSubject].StringValue), "metadata does not override the subject")
+	assert.NotContains(t, attrs, "h", "first keys in order")
+}
+
+func TestPublisher_FIFO(t *testing.T) {
+	f := newFakeAWS("grouter.fifo")
+	cfg := testConfig()
+	cfg.TopicARN = fakeAccount + "grouter.fifo"
+	d := newDriver(t, f, cfg)
+
+	queue := subscribeQueue(t, d, "orders", "billing")
+	assert.True(t, isFIFO(queue), "queues of FIFO topics are FIFO")
+	require.NoError(t, d.Publisher().Publish(context.Background(), "orders", "order", nil, nil))
+	assert.Equal(t, 1, f.messages(queue))
+}
+
+func TestPublisher_Validation(t *testing.T) {
+	f := newFakeAWS("grouter")
+	d := newDriver(t, f, testConfig())
+	queue := subscribeQueue(t, d, ">", "all")
+	d.Publisher().SetValidator(conformance.RejectAll{})
+
+	err := d.Publisher().Publish(context.Background(), "orders.created", "order.created", nil, nil)
+	assert.ErrorContains(t, err, "validation failed for type order.created")
+	assert.Zero(t, f.messages(queue))
+}
+
+func TestPublisher_Request(t *testing.T) {
+	f := newFakeAWS("grouter")
+	d := newDriver(t, f, testConfig())
+
+	start := time.Now()
+	_, err := d.Publisher().Request(context.Background(), "nobody", "ping", nil, 100*time.Millisecond)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second, "the request timeout bounds the wait")
+	assert.Len(t, f.queueNames(), 1, "the reply queue is created on the first request")
+
+	require.NoError(t, d.Close())
+	assert.Empty(t, f.queueNames(), "closing deletes the reply queue")
+	_, err = d.Publisher().Request(context.Background(), "nobody", "ping", nil, 100*time.Millisecond)
+	assert.ErrorContains(t, err, "driver closed")
+}
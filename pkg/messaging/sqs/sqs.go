@@ -0,0 +1,326 @@
+// Package sqs is a messaging driver running the Publisher and Subscriber of
+// pkg/messaging/nats on AWS: envelopes are published on SNS topics and
+// subscriptions receive them through SQS queues subscribed to the topics,
+// filtered on the subject attribute. Messages are deleted once handled and
+// made visible again on failure, following the ack policy of the handler.
+// The driver registers itself as "sqs" for messaging.driver.
+package sqs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"go.uber.org/zap"
+)
+
+// DriverName is the name of the driver in the messaging driver registry
+const DriverName = "sqs"
+
+// DefaultMessageType is the envelope type of the plain messages received
+// without a type attribute, see Subscriber
+const DefaultMessageType = "sqs.message"
+
+// The message attributes set on published messages
+const (
+	// AttributeSubject holds the subject, filtered by the subscriptions
+	AttributeSubject = "subject"
+	// AttributeEnvelope marks the messages whose body is an envelope,
+	// holding its version. Messages without it are plain messages.
+	AttributeEnvelope = "envelope"
+	// AttributeType holds the envelope type
+	AttributeType = "type"
+	// AttributeGroup holds the queue of dead letters
+	AttributeGroup = "group"
+)
+
+// maxAttributes is the number of message attributes of SNS and SQS
+const maxAttributes = 10
+
+// replyPrefix starts the reply subjects of requests, received by a queue of
+// the publisher
+const replyPrefix = "_REPLY."
+
+func init() {
+	messaging.RegisterDialer(DriverName, DefaultConfig, func(cfg Config, logger *zap.Logger, source string) (*Driver, error) {
+		return Dial(context.Background(), cfg, logger, source)
+	})
+}
+
+// Config holds the topics and queues of the driver. Topics and queues are
+// in the same account and region.
+type Config struct {
+	// Region is the AWS region, from the environment when empty.
+	Region string `mapstructure:"region"`
+	// Endpoint overrides the SNS and SQS endpoints, e.g. LocalStack.
+	Endpoint string `mapstructure:"endpoint"`
+	// TopicARN is the topic of the subjects not mapped by Topics.
+	TopicARN string `mapstructure:"topic_arn"`
+	// Topics map subject patterns to other topics. The first match wins.
+	Topics []TopicRoute `mapstructure:"topics"`
+	// QueuePrefix prefixes the names of the queues of the subscriptions.
+	QueuePrefix string `mapstructure:"queue_prefix"`
+	// DeadLetterQueueURL receives the messages rejected by the
+	// subscriptions: invalid, failing twice or terminated. Empty drops them.
+	DeadLetterQueueURL string `mapstructure:"dead_letter_queue_url"`
+	// BatchSize is the number of messages received at once, up to 10.
+	BatchSize int `mapstructure:"batch_size"`
+	// WaitTime is the long polling wait of the receives, up to 20s.
+	WaitTime time.Duration `mapstructure:"wait_time"`
+	// VisibilityTimeout hides the received messages from the other
+	// consumers until they are deleted; they are received again after it.
+	VisibilityTimeout time.Duration `mapstructure:"visibility_timeout"`
+	// PublishTimeout bounds the publishes.
+	PublishTimeout time.Duration `mapstructure:"publish_timeout"`
+}
+
+// TopicRoute maps the subjects matching Subject, a NATS subject pattern, to
+// TopicARN.
+type TopicRoute struct {
+	Subject  string `mapstructure:"subject"`
+	TopicARN string `mapstructure:"topic_arn"`
+}
+
+// DefaultConfig returns the default driver configuration.
+func DefaultConfig() Config {
+	return Config{
+		QueuePrefix:       "grouter-",
+		BatchSize:         10,
+		WaitTime:          20 * time.Second,
+		VisibilityTimeout: 30 * time.Second,
+		PublishTimeout:    5 * time.Second,
+	}
+}
+
+// withDefaults returns cfg with the defaults of its unset fields, and of
+// the batch size and wait time above the limits of SQS
+func withDefaults(cfg Config) Config {
+	defaults := DefaultConfig()
+	messaging.ApplyDefaults(&cfg, defaults)
+	cfg.BatchSize = min(cfg.BatchSize, defaults.BatchSize)
+	cfg.WaitTime = min(cfg.WaitTime, defaults.WaitTime)
+	return cfg
+}
+
+// SNSAPI is the part of the SNS client used by the driver
+type SNSAPI interface {
+	Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
+	Subscribe(ctx context.Context, params *sns.SubscribeInput, optFns ...func(*sns.Options)) (*sns.SubscribeOutput, error)
+	Unsubscribe(ctx context.Context, params *sns.UnsubscribeInput, optFns ...func(*sns.Options)) (*sns.UnsubscribeOutput, error)
+	GetTopicAttributes(ctx context.Context, params *sns.GetTopicAttributesInput, optFns ...func(*sns.Options)) (*sns.GetTopicAttributesOutput, error)
+}
+
+// SQSAPI is the part of the SQS client used by the driver
+type SQSAPI interface {
+	CreateQueue(ctx context.Context, params *sqs.CreateQueueInput, optFns ...func(*sqs.Options)) (*sqs.CreateQueueOutput, error)
+	DeleteQueue(ctx context.Context, params *sqs.DeleteQueueInput, optFns ...func(*sqs.Options)) (*sqs.DeleteQueueOutput, error)
+	ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+	DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error)
+	ChangeMessageVisibility(ctx context.Context, params *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error)
+	SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+}
+
+// Driver is a pair of SNS and SQS clients with the Publisher and Subscriber
+// running over them.
+type Driver struct {
+	cfg    Config
+	sns    SNSAPI
+	sqs    SQSAPI
+	logger *zap.Logger
+
+	publisher  *Publisher
+	subscriber *Subscriber
+
+	closeOnce sync.Once
+}
+
+// Dial creates the SNS and SQS clients of cfg with the default AWS
+// credential chain and checks that the topic exists. source is the source
+// of the published envelopes, usually the app name.
+func Dial(ctx context.Context, cfg Config, logger *zap.Logger, source string) (*Driver, error) {
+	var opts []func(*awsconfig.LoadOptions) error
+	if cfg.Region != "" {
+		opts = append(opts, awsconfig.WithRegion(cfg.Region))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load aws config: %w", err)
+	}
+	snsClient := sns.NewFromConfig(awsCfg, func(o *sns.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+	})
+	sqsClient := sqs.NewFromConfig(awsCfg, func(o *sqs.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+	})
+	d, err := New(snsClient, sqsClient, cfg, logger, source)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.HealthCheck(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// New returns the driver of the given clients
+func New(snsClient SNSAPI, sqsClient SQSAPI, cfg Config, logger *zap.Logger, source string) (*Driver, error) {
+	cfg = withDefaults(cfg)
+	if cfg.TopicARN == "" {
+		return nil, errors.New("sqs topic_arn is required")
+	}
+	for _, r := range cfg.Topics {
+		if r.Subject == "" || r.TopicARN == "" {
+			return nil, errors.New("sqs topic routes require a subject and a topic_arn")
+		}
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	d := &Driver{cfg: cfg, sns: snsClient, sqs: sqsClient, logger: logger}
+	d.publisher = newPublisher(d, source)
+	d.subscriber = newSubscriber(d)
+	return d, nil
+}
+
+// Publisher returns the publisher of the driver
+func (d *Driver) Publisher() *Publisher {
+	return d.publisher
+}
+
+// Subscriber returns the subscriber of the driver
+func (d *Driver) Subscriber() *Subscriber {
+	return d.subscriber
+}
+
+// Messenger returns a messenger of the publisher and subscriber, for
+// manager.WithMessagingDriver. It has no NATS client; closing it closes the
+// driver.
+func (d *Driver) Messenger() *messaging.Messenger {
+	return messaging.NewMessenger(nil, d.publisher, d.subscriber)
+}
+
+// HealthCheck reads the attributes of the default topic
+func (d *Driver) HealthCheck() error {
+	ctx, cancel := context.WithTimeout(context.Background(), d.cfg.PublishTimeout)
+	defer cancel()
+	if _, err := d.sns.GetTopicAttributes(ctx, &sns.GetTopicAttributesInput{TopicArn: aws.String(d.cfg.TopicARN)}); err != nil {
+		return fmt.Errorf("failed to reach topic %s: %w", d.cfg.TopicARN, err)
+	}
+	return nil
+}
+
+// Close closes the subscriber, waiting for the handlers in flight, then
+// deletes the reply queue
+func (d *Driver) Close() error {
+	return d.subscriber.Close()
+}
+
+// close deletes the reply queue of the publisher once
+func (d *Driver) close() {
+	d.closeOnce.Do(d.publisher.closeReplies)
+}
+
+// topic returns the topic of a subject or subject pattern
+func (d *Driver) topic(subject string) string {
+	for _, r := range d.cfg.Topics {
+		if messaging.MatchSubject(r.Subject, subject) {
+			return r.TopicARN
+		}
+	}
+	return d.cfg.TopicARN
+}
+
+// isFIFO reports whether the topic or queue is a FIFO one
+func isFIFO(name string) bool {
+	return strings.HasSuffix(name, ".fifo")
+}
+
+// queueName returns the name of the queue <prefix><name>, at most 80
+// characters of letters, digits, hyphens and underscores, with the .fifo
+// suffix of the queues of FIFO topics
+func queueName(prefix, name string, fifo bool) string {
+	var b strings.Builder
+	for _, r := range prefix + name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			b.WriteRune(r)
+		case r == '.':
+			b.WriteRune('_')
+		case r == '*':
+			b.WriteString("star")
+		case r == '>':
+			b.WriteString("all")
+		default:
+			b.WriteRune('-')
+		}
+	}
+	queue, limit := b.String(), 80
+	if fifo {
+		limit -= len(".fifo")
+	}
+	if len(queue) > limit {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(queue))
+		queue = fmt.Sprintf("%s-%08x", queue[:limit-9], h.Sum32())
+	}
+	if fifo {
+		queue += ".fifo"
+	}
+	return queue
+}
+
+// queueARN returns the ARN of the queue name in the account and region of
+// topicARN
+func queueARN(topicARN, name string) string {
+	parts := strings.Split(topicARN, ":")
+	if len(parts) != 6 {
+		return ""
+	}
+	parts[2], parts[5] = "sqs", name
+	return strings.Join(parts, ":")
+}
+
+// queuePolicy returns the policy allowing topicARN to send to queueARN
+func queuePolicy(topicARN, queueARN string) string {
+	policy, _ := json.Marshal(map[string]any{
+		"Version": "2012-10-17",
+		"Statement": []map[string]any{{
+			"Effect":    "Allow",
+			"Principal": map[string]string{"Service": "sns.amazonaws.com"},
+			"Action":    "sqs:SendMessage",
+			"Resource":  queueARN,
+			"Condition": map[string]any{"ArnEquals": map[string]string{"aws:SourceArn": topicARN}},
+		}},
+	})
+	return string(policy)
+}
+
+// filterPolicy returns the SNS filter policy of the messages of a subject
+// pattern: the subject itself, or the prefix before its first wildcard. The
+// subscriber matches the wildcards. Empty for patterns starting with one.
+func filterPolicy(subject string) string {
+	var match any = subject
+	if i := strings.IndexAny(subject, "*>"); i >= 0 {
+		if i == 0 {
+			return ""
+		}
+		match = map[string]string{"prefix": subject[:i]}
+	}
+	policy, _ := json.Marshal(map[string][]any{AttributeSubject: {match}})
+	return string(policy)
+}
@@ -0,0 +1,141 @@
+package sqs
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+	"grouter/pkg/messaging/nats/conformance"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// testConfig returns a config of the topic grouter of the fake with short
+// waits
+func testConfig() Config {
+	return Config{
+		TopicARN:          fakeAccount + "grouter",
+		WaitTime:          time.Second,
+		VisibilityTimeout: time.Second,
+	}
+}
+
+// newDriver returns a driver on f with cfg, closed at the end of the test
+func newDriver(t *testing.T, f *fakeAWS, cfg Config) *Driver {
+	t.Helper()
+	d, err := New(f, f, cfg, zap.NewNop(), "test")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = d.Close() })
+	return d
+}
+
+func TestConformance(t *testing.T) {
+	f := newFakeAWS("grouter")
+	conformance.Run(t, func(t *testing.T) *messaging.Messenger {
+		m := newDriver(t, f, testConfig()).Messenger()
+		t.Cleanup(func() { _ = m.Close() })
+		return m
+	})
+}
+
+func TestAcks(t *testing.T) {
+	conformance.RunAcks(t, func(t *testing.T) conformance.AckBroker {
+		f := newFakeAWS("grouter")
+		d := newDriver(t, f, deadLetters(t, f))
+		return conformance.AckBroker{
+			Messenger:   d.Messenger(),
+			DeadLetters: func() int { return f.messages("dead") },
+			NakDelay:    time.Second,
+			Redelivery:  d.cfg.VisibilityTimeout,
+		}
+	})
+}
+
+func TestUnsupported(t *testing.T) {
+	f := newFakeAWS("grouter")
+	conformance.RunUnsupported(t, func(t *testing.T) *messaging.Messenger {
+		return newDriver(t, f, testConfig()).Messenger()
+	})
+}
+
+// TestConformance_AWS runs the contract tests on the topic of SQS_TOPIC_ARN,
+// with the endpoint of SQS_ENDPOINT if set, e.g. LocalStack on
+// http://localhost:4566. Ordering needs a FIFO topic on AWS.
+func TestConformance_AWS(t *testing.T) {
+	topic := os.Getenv("SQS_TOPIC_ARN")
+	if topic == "" {
+		t.Skip("SQS_TOPIC_ARN not set")
+	}
+	conformance.Run(t, func(t *testing.T) *messaging.Messenger {
+		cfg := testConfig()
+		cfg.TopicARN, cfg.Endpoint = topic, os.Getenv("SQS_ENDPOINT")
+		d, err := Dial(context.Background(), cfg, zap.NewNop(), "test")
+		require.NoError(t, err)
+		m := d.Messenger()
+		t.Cleanup(func() { _ = m.Close() })
+		return m
+	})
+}
+
+func TestNew(t *testing.T) {
+	f := newFakeAWS("grouter", "audit")
+	d := newDriver(t, f, Config{TopicARN: fakeAccount + "grouter", Topics: []TopicRoute{{Subject: "audit.>", TopicARN: fakeAccount + "audit"}}})
+
+	assert.Equal(t, DefaultConfig().QueuePrefix, d.cfg.QueuePrefix, "defaults")
+	assert.Equal(t, DefaultConfig().VisibilityTimeout, d.cfg.VisibilityTimeout)
+	assert.Equal(t, fakeAccount+"audit", d.topic("audit.login"))
+	assert.Equal(t, fakeAccount+"audit", d.topic("audit.*"), "patterns within the route")
+	assert.Equal(t, fakeAccount+"grouter", d.topic("orders.created"))
+	require.NoError(t, d.HealthCheck())
+
+	d = newDriver(t, f, Config{TopicARN: fakeAccount + "missing", BatchSize: 50, WaitTime: time.Minute})
+	assert.Equal(t, 10, d.cfg.BatchSize, "SQS limits")
+	assert.Equal(t, 20*time.Second, d.cfg.WaitTime)
+	assert.ErrorContains(t, d.HealthCheck(), "failed to reach topic")
+}
+
+func TestNew_Errors(t *testing.T) {
+	f := newFakeAWS()
+	_, err := New(f, f, Config{}, nil, "test")
+	assert.ErrorContains(t, err, "sqs topic_arn is required")
+
+	_, err = New(f, f, Config{TopicARN: fakeAccount + "grouter", Topics: []TopicRoute{{Subject: "audit.>"}}}, nil, "test")
+	assert.ErrorContains(t, err, "require a subject and a topic_arn")
+}
+
+func TestQueueName(t *testing.T) {
+	assert.Equal(t, "grouter-billing-orders_star", queueName("grouter-", "billing-orders.*", false))
+	assert.Equal(t, "grouter-billing-orders_all.fifo", queueName("grouter-", "billing-orders.>", true))
+
+	long := queueName("grouter-", "billing-"+string(make([]byte, 100)), true)
+	assert.Len(t, long, 80)
+	assert.True(t, isFIFO(long))
+	assert.NotEqual(t, long, queueName("grouter-", "billing-"+string(make([]byte, 101)), true), "truncated names keep a hash")
+}
+
+func TestFilterPolicy(t *testing.T) {
+	assert.JSONEq(t, `{"subject":["orders.created"]}`, filterPolicy("orders.created"))
+	assert.JSONEq(t, `{"subject":[{"prefix":"orders."}]}`, filterPolicy("orders.*.eu"))
+	assert.JSONEq(t, `{"subject":[{"prefix":"orders."}]}`, filterPolicy("orders.>"))
+	assert.Empty(t, filterPolicy(">"))
+	assert.Empty(t, filterPolicy("*.created"))
+}
+
+func TestQueueARN(t *testing.T) {
+	assert.Equal(t, "arn:aws:sqs:eu-west-1:123456789012:grouter-orders", queueARN("arn:aws:sns:eu-west-1:123456789012:grouter", "grouter-orders"))
+	assert.Empty(t, queueARN("grouter", "grouter-orders"))
+}
+
+func TestRegisteredDriver(t *testing.T) {
+	assert.Contains(t, messaging.Drivers(), DriverName)
+
+	_, err := messaging.OpenDriver(DriverName, func(cfg any) error {
+		assert.Equal(t, DefaultConfig(), *cfg.(*Config), "settings decoded over the defaults")
+		return nil
+	}, zap.NewNop(), "test")
+	assert.ErrorContains(t, err, "sqs topic_arn is required")
+}
@@ -0,0 +1,414 @@
+package sqs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Subscriber receives the messages of the subscribed subjects from SQS
+// queues subscribed to their SNS topics. It implements messaging.Subscriber,
+// without JetStream, payload store and priority lanes.
+//
+// A subscription without queue group has a queue of its own, deleted with
+// the subscription. The members of a queue group share the durable queue
+// <prefix><group>-<subject>, which keeps the messages while no member runs.
+// The topic subscriptions deliver raw messages filtered on the subject
+// attribute, the subscriber matches the wildcards.
+//
+// Messages are received in batches of BatchSize with long polling and
+// deleted once handled, see message. Messages not deleted are received again
+// once their visibility timeout expires.
+//
+// Messages without the envelope attribute, sent by other producers, are
+// handled as envelopes of type DefaultMessageType, or of their type
+// attribute, with the body as data and the string attributes as metadata.
+type Subscriber struct {
+	*messaging.DriverSubscriber
+	driver *Driver
+}
+
+var _ messaging.Subscriber = (*Subscriber)(nil)
+
+func newSubscriber(d *Driver) *Subscriber {
+	s := &Subscriber{driver: d}
+	// Closing deletes the reply queue of the publisher
+	s.DriverSubscriber = messaging.NewDriverSubscriber("sqs", d.logger, s.open, func() error {
+		d.close()
+		return nil
+	})
+	return s
+}
+
+// open starts a subscription of SubscribeSubject
+func (s *Subscriber) open(subject string, handler messaging.HandlerFunc, opts *messaging.SubscribeOptions) (messaging.DriverSubscription, error) {
+	sub, err := s.subscribe(subject, handler, opts, false)
+	if err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// subscribe creates the queue of a subscription, subscribes it to the topic
+// of subject and receives it with MaxWorkers handlers (default 1). The
+// replies of the reply queue of the publisher are handled as responses.
+func (s *Subscriber) subscribe(subject string, handler messaging.HandlerFunc, opts *messaging.SubscribeOptions, replies bool) (*subscription, error) {
+	d := s.driver
+	topic := d.topic(subject)
+	fifo := isFIFO(topic)
+	ctx, stop := context.WithCancel(context.Background())
+	sub := &subscription{
+		subscriber: s,
+		subject:    subject,
+		topic:      topic,
+		queue:      queueName(d.cfg.QueuePrefix, uuid.NewString(), fifo),
+		plain:      opts.QueueGroup == "",
+		replies:    replies,
+		deliveries: make(chan delivery),
+		ctx:        ctx,
+		stop:       stop,
+		done:       make(chan struct{}),
+	}
+	if !sub.plain {
+		sub.queue = queueName(d.cfg.QueuePrefix, opts.QueueGroup+"-"+subject, fifo)
+	}
+	if err := sub.create(); err != nil {
+		stop()
+		return nil, err
+	}
+
+	sub.loops.Add(1)
+	go sub.receive()
+	for i := 0; i < max(opts.MaxWorkers, 1); i++ {
+		sub.workers.Add(1)
+		go sub.run(handler)
+	}
+
+	d.logger.Info("Subscribed to subject",
+		zap.String("subject", subject),
+		zap.String("topic", topic),
+		zap.String("queue", sub.queue),
+		zap.String("queue_group", opts.QueueGroup),
+	)
+	return sub, nil
+}
+
+// process hands a message to the handler and acknowledges it
+func (s *Subscriber) process(sub *subscription, d delivery, handler messaging.HandlerFunc) {
+	subject := stringValue(d.msg.MessageAttributes, AttributeSubject)
+	if subject == "" {
+		// Sent to the queue directly
+		subject = sub.subject
+	}
+	s.Process(messaging.Delivery{
+		Subject: subject,
+		Message: message{sub: sub, delivery: d},
+		Decode: func(codec messaging.Codec, envelope *messaging.MessageEnvelope) error {
+			if _, ok := d.msg.MessageAttributes[AttributeEnvelope]; !ok {
+				*envelope = plainEnvelope(d.msg)
+				return nil
+			}
+			return messaging.DecodeEnvelope([]byte(aws.ToString(d.msg.Body)), subject, codec, envelope)
+		},
+		Response: sub.replies,
+		Fields:   []zap.Field{zap.String("queue", sub.queue), zap.String("id", aws.ToString(d.msg.MessageId))},
+	}, handler)
+}
+
+// plainEnvelope returns the envelope of a message without envelope
+// attribute: its body as data, a JSON string unless it is JSON, and its
+// string attributes as metadata
+func plainEnvelope(msg types.Message) messaging.MessageEnvelope {
+	env := messaging.MessageEnvelope{
+		ID:   aws.ToString(msg.MessageId),
+		Type: stringValue(msg.MessageAttributes, AttributeType),
+		Data: json.RawMessage(aws.ToString(msg.Body)),
+	}
+	if env.Type == "" {
+		env.Type = DefaultMessageType
+	}
+	if !json.Valid(env.Data) {
+		env.Data, _ = json.Marshal(aws.ToString(msg.Body))
+	}
+	if ms, err := strconv.ParseInt(msg.Attributes[string(types.MessageSystemAttributeNameSentTimestamp)], 10, 64); err == nil {
+		env.Timestamp = time.UnixMilli(ms)
+	}
+	for name, value := range msg.MessageAttributes {
+		if name == AttributeSubject || name == AttributeType || value.StringValue == nil {
+			continue
+		}
+		if env.Metadata == nil {
+			env.Metadata = make(map[string]string)
+		}
+		env.Metadata[name] = *value.StringValue
+	}
+	return env
+}
+
+// stringValue returns the string value of a message attribute
+func stringValue(attrs map[string]types.MessageAttributeValue, name string) string {
+	return aws.ToString(attrs[name].StringValue)
+}
+
+// delivery is a message received by a subscription
+type delivery struct {
+	msg types.Message
+}
+
+// redelivered reports whether the message was received before
+func (d delivery) redelivered() bool {
+	n, _ := strconv.Atoi(d.msg.Attributes[string(types.MessageSystemAttributeNameApproximateReceiveCount)])
+	return n > 1
+}
+
+// subscription is a queue subscribed to the topic of a subject, of its own
+// or shared by its queue group
+type subscription struct {
+	subscriber *Subscriber
+	subject    string
+	topic      string
+	queue      string
+	queueURL   string
+	// subscriptionARN is the subscription of the queue to the topic
+	subscriptionARN string
+	// plain subscriptions have a queue of their own
+	plain bool
+	// replies is the reply queue of the publisher, handled without
+	// validator and middleware like the responses of NATS requests
+	replies bool
+
+	deliveries chan delivery
+	// loops is the receiving goroutine, workers the handlers
+	loops   sync.WaitGroup
+	workers sync.WaitGroup
+	// ctx is canceled by stop, interrupting the receives
+	ctx      context.Context
+	stop     context.CancelFunc
+	stopOnce sync.Once
+	// done is closed once the handlers returned and the queue is cleaned up
+	done chan struct{}
+}
+
+func (s *subscription) Subject() string {
+	return s.subject
+}
+
+func (s *subscription) stopped() bool {
+	return s.ctx.Err() != nil
+}
+
+// create creates the queue, allowing the topic to send to it, and subscribes
+// it to the topic with the filter policy of the subject. Both calls return
+// the existing queue and subscription of queue groups.
+func (s *subscription) create() error {
+	d := s.subscriber.driver
+	ctx, cancel := context.WithTimeout(context.Background(), d.cfg.PublishTimeout)
+	defer cancel()
+
+	arn := queueARN(s.topic, s.queue)
+	attrs := map[string]string{
+		string(types.QueueAttributeNameVisibilityTimeout): strconv.Itoa(int(d.cfg.VisibilityTimeout / time.Second)),
+		string(types.QueueAttributeNamePolicy):            queuePolicy(s.topic, arn),
+	}
+	if isFIFO(s.queue) {
+		attrs[string(types.QueueAttributeNameFifoQueue)] = "true"
+	}
+	out, err := d.sqs.CreateQueue(ctx, &sqs.CreateQueueInput{QueueName: aws.String(s.queue), Attributes: attrs})
+	if err != nil {
+		return fmt.Errorf("failed to create queue %s: %w", s.queue, err)
+	}
+	s.queueURL = aws.ToString(out.QueueUrl)
+
+	subAttrs := map[string]string{"RawMessageDelivery": "true"}
+	if policy := filterPolicy(s.subject); policy != "" {
+		subAttrs["FilterPolicy"] = policy
+	}
+	subscribed, err := d.sns.Subscribe(ctx, &sns.SubscribeInput{
+		TopicArn:              aws.String(s.topic),
+		Protocol:              aws.String("sqs"),
+		Endpoint:              aws.String(arn),
+		Attributes:            subAttrs,
+		ReturnSubscriptionArn: true,
+	})
+	if err != nil {
+		if s.plain {
+			_, _ = d.sqs.DeleteQueue(ctx, &sqs.DeleteQueueInput{QueueUrl: out.QueueUrl})
+		}
+		return fmt.Errorf("failed to subscribe queue %s to %s: %w", s.queue, s.topic, err)
+	}
+	s.subscriptionARN = aws.ToString(subscribed.SubscriptionArn)
+	return nil
+}
+
+// receive hands the batches of messages of the queue to the workers until
+// the subscription stops
+func (s *subscription) receive() {
+	defer s.loops.Done()
+	d := s.subscriber.driver
+	for !s.stopped() {
+		out, err := d.sqs.ReceiveMessage(s.ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:                    aws.String(s.queueURL),
+			MaxNumberOfMessages:         int32(d.cfg.BatchSize),
+			WaitTimeSeconds:             int32(d.cfg.WaitTime / time.Second),
+			MessageAttributeNames:       []string{"All"},
+			MessageSystemAttributeNames: []types.MessageSystemAttributeName{types.MessageSystemAttributeNameApproximateReceiveCount, types.MessageSystemAttributeNameSentTimestamp},
+		})
+		if s.stopped() {
+			return
+		}
+		if err != nil {
+			d.logger.Warn("Failed to receive messages", zap.Error(err), zap.String("queue", s.queue))
+			select {
+			case <-s.ctx.Done():
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		if !s.deliver(out.Messages) {
+			return
+		}
+	}
+}
+
+// deliver deletes the messages of the other subjects of the filter policy
+// and hands the others to the workers. It returns false once the
+// subscription stops, making the messages not handed over visible again.
+func (s *subscription) deliver(msgs []types.Message) bool {
+	for i, msg := range msgs {
+		subject := stringValue(msg.MessageAttributes, AttributeSubject)
+		// The filter of "orders.*" also lets "orders.a.b" through
+		if subject != "" && !messaging.MatchSubject(s.subject, subject) {
+			if err := s.delete(msg); err != nil && !s.stopped() {
+				s.subscriber.driver.logger.Warn("Failed to delete message of other subject", zap.Error(err), zap.String("queue", s.queue))
+			}
+			continue
+		}
+		s.subscriber.AddPending(1)
+		select {
+		case s.deliveries <- delivery{msg: msg}:
+		case <-s.ctx.Done():
+			s.subscriber.AddPending(-1)
+			for _, msg := range msgs[i:] {
+				_ = s.setVisibility(msg, 0)
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// run hands the deliveries to handler until the subscription stops
+func (s *subscription) run(handler messaging.HandlerFunc) {
+	defer s.workers.Done()
+	for {
+		select {
+		case d := <-s.deliveries:
+			s.subscriber.process(s, d, handler)
+			s.subscriber.AddPending(-1)
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// delete deletes a handled message from the queue
+func (s *subscription) delete(msg types.Message) error {
+	d := s.subscriber.driver
+	ctx, cancel := context.WithTimeout(context.Background(), d.cfg.PublishTimeout)
+	defer cancel()
+	_, err := d.sqs.DeleteMessage(ctx, &sqs.DeleteMessageInput{QueueUrl: aws.String(s.queueURL), ReceiptHandle: msg.ReceiptHandle})
+	return err
+}
+
+// setVisibility makes a message visible again after timeout, up to the 12
+// hours of SQS
+func (s *subscription) setVisibility(msg types.Message, timeout time.Duration) error {
+	d := s.subscriber.driver
+	ctx, cancel := context.WithTimeout(context.Background(), d.cfg.PublishTimeout)
+	defer cancel()
+	_, err := d.sqs.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(s.queueURL),
+		ReceiptHandle:     msg.ReceiptHandle,
+		VisibilityTimeout: int32(min(max(timeout, 0), 12*time.Hour) / time.Second),
+	})
+	return err
+}
+
+// deadLetter sends the message to the DeadLetterQueueURL, if any, with the
+// group attribute naming the queue, and deletes it
+func (s *subscription) deadLetter(msg types.Message) error {
+	d := s.subscriber.driver
+	if d.cfg.DeadLetterQueueURL != "" {
+		attrs := make(map[string]types.MessageAttributeValue, len(msg.MessageAttributes)+1)
+		for name, value := range msg.MessageAttributes {
+			if len(attrs) < maxAttributes-1 {
+				attrs[name] = value
+			}
+		}
+		attrs[AttributeGroup] = types.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(s.queue)}
+		in := &sqs.SendMessageInput{
+			QueueUrl:          aws.String(d.cfg.DeadLetterQueueURL),
+			MessageBody:       msg.Body,
+			MessageAttributes: attrs,
+		}
+		if isFIFO(d.cfg.DeadLetterQueueURL) {
+			in.MessageGroupId = aws.String(s.queue)
+			in.MessageDeduplicationId = msg.MessageId
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), d.cfg.PublishTimeout)
+		defer cancel()
+		if _, err := d.sqs.SendMessage(ctx, in); err != nil {
+			return fmt.Errorf("failed to send dead letter: %w", err)
+		}
+	}
+	return s.delete(msg)
+}
+
+// Stop stops the receives, then cleans the queue up once the handlers
+// return, so that they can still acknowledge their messages
+func (s *subscription) Stop() error {
+	s.stopOnce.Do(func() {
+		s.stop()
+		go func() {
+			defer close(s.done)
+			s.workers.Wait()
+			s.loops.Wait()
+			s.cleanup()
+		}()
+	})
+	return nil
+}
+
+func (s *subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// cleanup unsubscribes the queue of plain subscriptions from the topic and
+// deletes it. The queues of queue groups keep the messages for the members.
+func (s *subscription) cleanup() {
+	if !s.plain {
+		return
+	}
+	d := s.subscriber.driver
+	ctx, cancel := context.WithTimeout(context.Background(), d.cfg.PublishTimeout)
+	defer cancel()
+	_, err := d.sns.Unsubscribe(ctx, &sns.UnsubscribeInput{SubscriptionArn: aws.String(s.subscriptionARN)})
+	if err == nil {
+		_, err = d.sqs.DeleteQueue(ctx, &sqs.DeleteQueueInput{QueueUrl: aws.String(s.queueURL)})
+	}
+	if err != nil {
+		d.logger.Warn("Failed to delete queue", zap.Error(err), zap.String("queue", s.queue))
+	}
+}
@@ -0,0 +1,157 @@
+package sqs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+	"grouter/pkg/messaging/nats/conformance"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// deadLetters returns a config whose dead letters go to the queue "dead"
+// of f
+func deadLetters(t *testing.T, f *fakeAWS) Config {
+	t.Helper()
+	_, err := f.CreateQueue(context.Background(), &sqs.CreateQueueInput{QueueName: aws.String("dead")})
+	require.NoError(t, err)
+	cfg := testConfig()
+	cfg.DeadLetterQueueURL = queueURL("dead")
+	return cfg
+}
+
+// sendPlain sends a message without envelope attribute to the queue name
+func sendPlain(t *testing.T, f *fakeAWS, name, body string) {
+	t.Helper()
+	_, err := f.SendMessage(context.Background(), &sqs.SendMessageInput{QueueUrl: aws.String(queueURL(name)), MessageBody: aws.String(body)})
+	require.NoError(t, err)
+}
+
+func TestSubscriber_Redelivery(t *testing.T) {
+	f := newFakeAWS("grouter")
+	d := newDriver(t, f, deadLetters(t, f))
+
+	var calls atomic.Int32
+	require.NoError(t, d.Subscriber().Subscribe("jobs", func(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+		calls.Add(1)
+		return errors.New("boom")
+	}, &messaging.SubscribeOptions{QueueGroup: "workers"}))
+	require.NoError(t, d.Publisher().Publish(context.Background(), "jobs", "job", nil, nil))
+
+	conformance.WaitFor(t, func() bool { return f.messages("dead") == 1 }, "failing twice dead-letters the message")
+	assert.Equal(t, int32(2), calls.Load(), "received again once, then rejected")
+
+	dead := f.bodies("dead")[0]
+	assert.Equal(t, "jobs", aws.ToString(dead.attrs[AttributeSubject].StringValue))
+	assert.Equal(t, "grouter-workers-jobs", aws.ToString(dead.attrs[AttributeGroup].StringValue))
+	conformance.WaitFor(t, func() bool { return f.messages("grouter-workers-jobs") == 0 }, "dead letters are deleted")
+}
+
+func TestSubscriber_Wildcards(t *testing.T) {
+	f := newFakeAWS("grouter")
+	d := newDriver(t, f, testConfig())
+	ctx := context.Background()
+
+	subjects := make(chan string, 4)
+	sub, err := d.Subscriber().SubscribeSubject("orders.*", func(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+		subjects <- subject
+		return nil
+	}, nil)
+	require.NoError(t, err)
+	queue := messaging.DriverSubscriptionOf(sub).(*subscription).queue
+
+	// orders.a.b passes the prefix filter of SNS, not the wildcard
+	require.NoError(t, d.Publisher().Publish(ctx, "orders.a.b", "order", nil, nil))
+	require.NoError(t, d.Publisher().Publish(ctx, "payments.created", "payment", nil, nil))
+	require.NoError(t, d.Publisher().Publish(ctx, "orders.created", "order", nil, nil))
+	select {
+	case subject := <-subjects:
+		assert.Equal(t, "orders.created", subject)
+	case <-time.After(3 * time.Second):
+		require.FailNow(t, "message not received")
+	}
+	conformance.WaitFor(t, func() bool { return f.messages(queue) == 0 }, "other subjects are deleted")
+	assert.Empty(t, subjects)
+}
+
+func TestSubscriber_PlainMessages(t *testing.T) {
+	f := newFakeAWS("grouter")
+	d := newDriver(t, f, testConfig())
+
+	received := make(chan *messaging.MessageEnvelope, 2)
+	sub, err := d.Subscriber().SubscribeSubject("legacy", func(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+		assert.Equal(t, "legacy", subject, "messages sent to the queue have its subject")
+		received <- env
+		return nil
+	}, nil)
+	require.NoError(t, err)
+	queue := messaging.DriverSubscriptionOf(sub).(*subscription).queue
+
+	sendPlain(t, f, queue, `{"id":42}`)
+	sendPlain(t, f, queue, "hello")
+	for _, data := range []string{`{"id":42}`, `"hello"`} {
+		select {
+		case env := <-received:
+			assert.Equal(t, DefaultMessageType, env.Type)
+			assert.NotEmpty(t, env.ID)
+			assert.False(t, env.Timestamp.IsZero())
+			assert.JSONEq(t, data, string(env.Data), "JSON bodies as is, others as strings")
+		case <-time.After(3 * time.Second):
+			require.FailNow(t, "message not received")
+		}
+	}
+}
+
+func TestSubscriber_InvalidMessages(t *testing.T) {
+	f := newFakeAWS("grouter")
+	d := newDriver(t, f, deadLetters(t, f))
+	ctx := context.Background()
+
+	var calls atomic.Int32
+	require.NoError(t, d.Subscriber().Subscribe("orders.*", func(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+		calls.Add(1)
+		return nil
+	}, nil))
+	_, err := f.Publish(ctx, &sns.PublishInput{
+		TopicArn: aws.String(d.cfg.TopicARN),
+		Message:  aws.String("not json"),
+		MessageAttributes: map[string]snstypes.MessageAttributeValue{
+			AttributeSubject:  stringAttribute("orders.created"),
+			AttributeEnvelope: stringAttribute("2"),
+		},
+	})
+	require.NoError(t, err)
+
+	conformance.WaitFor(t, func() bool { return f.messages("dead") == 1 }, "undecodable messages are dead-lettered")
+	assert.Zero(t, calls.Load())
+	assert.Zero(t, d.Subscriber().QueueDepth())
+}
+
+func TestSubscriber_Unsubscribe(t *testing.T) {
+	f := newFakeAWS("grouter")
+	d := newDriver(t, f, testConfig())
+	ctx := context.Background()
+	handler := func(ctx context.Context, subject string, env *messaging.MessageEnvelope) error { return nil }
+
+	plain, err := d.Subscriber().SubscribeSubject("orders", handler, nil)
+	require.NoError(t, err)
+	member, err := d.Subscriber().SubscribeSubject("orders", handler, &messaging.SubscribeOptions{QueueGroup: "billing"})
+	require.NoError(t, err)
+	assert.Len(t, f.queueNames(), 2)
+
+	require.NoError(t, plain.Drain(ctx))
+	require.NoError(t, member.Drain(ctx))
+	assert.Equal(t, []string{"grouter-billing-orders"}, f.queueNames(), "queue groups keep their queue")
+
+	require.NoError(t, d.Publisher().Publish(ctx, "orders", "order", nil, nil))
+	assert.Equal(t, 1, f.messages("grouter-billing-orders"), "and their subscription")
+}
@@ -0,0 +1,303 @@
+// Package profiling captures runtime profiles on demand. A Controller listens
+// for profile requests on NATS control subjects scoped by service and
+// instance, captures the profile and uploads it to a JetStream object store
+// bucket, replying with its location.
+package profiling
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"runtime/pprof"
+	"strings"
+	"sync"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+// Profile types accepted in a Request. Any other runtime/pprof profile name
+// (e.g. threadcreate) is accepted as well.
+const (
+	TypeCPU       = "cpu"
+	TypeHeap      = "heap"
+	TypeAllocs    = "allocs"
+	TypeGoroutine = "goroutine"
+	TypeBlock     = "block"
+	TypeMutex     = "mutex"
+)
+
+// Message types of the control protocol.
+const (
+	RequestType = "profile.capture"
+	ResultType  = "profile.result"
+)
+
+// ErrBusy is returned when a CPU profile is already being captured.
+var ErrBusy = errors.New("a CPU profile is already being captured")
+
+// Config holds profile capture settings.
+type Config struct {
+	// Subject is the prefix of the control subjects the controller listens
+	// on, see ServiceSubject and InstanceSubject.
+	Subject string `mapstructure:"subject"`
+	// Service scopes the control subjects to the instances of one service.
+	Service string `mapstructure:"service"`
+	// Broadcast also serves the requests on Subject itself, which every
+	// instance of every service on the NATS cluster receives.
+	Broadcast bool `mapstructure:"broadcast"`
+	// Bucket is the object store bucket receiving the profiles.
+	Bucket string `mapstructure:"bucket"`
+	// TTL expires stored profiles. Zero keeps them.
+	TTL time.Duration `mapstructure:"ttl"`
+	// DefaultDuration is the CPU profile duration when the request sets none.
+	DefaultDuration time.Duration `mapstructure:"default_duration"`
+	// MaxDuration caps the requested CPU profile duration.
+	MaxDuration time.Duration `mapstructure:"max_duration"`
+}
+
+// DefaultConfig returns the default profiling configuration.
+func DefaultConfig() Config {
+	return Config{
+		Subject:         "grouter.control.profile",
+		Bucket:          "profiles",
+		DefaultDuration: 30 * time.Second,
+		MaxDuration:     2 * time.Minute,
+	}
+}
+
+// Request asks for a profile capture.
+type Request struct {
+	Type string `json:"type"`
+	// Seconds is the CPU profile duration.
+	Seconds int `json:"seconds,omitempty"`
+}
+
+// Result describes a stored profile.
+type Result struct {
+	Instance string    `json:"instance"`
+	Type     string    `json:"type"`
+	Bucket   string    `json:"bucket"`
+	Object   string    `json:"object"`
+	Size     uint64    `json:"size"`
+	Captured time.Time `json:"captured"`
+}
+
+// ServiceSubject returns the control subject on which one instance of service,
+// picked by the NATS queue group, serves a request.
+func ServiceSubject(subject, service string) string {
+	return subject + "." + subjectToken(service)
+}
+
+// InstanceSubject returns the control subject of the instance of service,
+// named after its hostname as in Result.Instance.
+func InstanceSubject(subject, service, instance string) string {
+	return ServiceSubject(subject, service) + "." + subjectToken(instance)
+}
+
+// subjectToken turns name into a single subject token
+func subjectToken(name string) string {
+	return strings.NewReplacer(".", "_", "*", "_", ">", "_", " ", "_").Replace(name)
+}
+
+// Capture writes a profile in the pprof format. CPU profiles are recorded for
+// duration or until ctx is done; the other types are snapshots.
+func Capture(ctx context.Context, buf *bytes.Buffer, profileType string, duration time.Duration) error {
+	if profileType != TypeCPU {
+		p := pprof.Lookup(profileType)
+		if p == nil {
+			return fmt.Errorf("unknown profile type %q", profileType)
+		}
+		return p.WriteTo(buf, 0)
+	}
+
+	if err := pprof.StartCPUProfile(buf); err != nil {
+		return ErrBusy
+	}
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	pprof.StopCPUProfile()
+	return ctx.Err()
+}
+
+// Controller serves profile requests received over NATS.
+type Controller struct {
+	cfg        Config
+	store      nats.ObjectStore
+	subscriber messaging.Subscriber
+	publisher  messaging.Publisher
+	logger     *zap.Logger
+	instance   string
+	// mu serializes captures so concurrent snapshots do not skew CPU profiles
+	mu sync.Mutex
+}
+
+// New creates a Controller on top of the messenger, creating the object
+// store bucket if it does not exist.
+func New(m *messaging.Messenger, cfg Config, logger *zap.Logger) (*Controller, error) {
+	if m == nil || m.Client == nil {
+		return nil, fmt.Errorf("profiling requires an initialized messenger")
+	}
+	if cfg.Service == "" {
+		return nil, fmt.Errorf("profiling requires a service name")
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	defaults := DefaultConfig()
+	if cfg.Subject == "" {
+		cfg.Subject = defaults.Subject
+	}
+	if cfg.Bucket == "" {
+		cfg.Bucket = defaults.Bucket
+	}
+	if cfg.DefaultDuration <= 0 {
+		cfg.DefaultDuration = defaults.DefaultDuration
+	}
+	if cfg.MaxDuration <= 0 {
+		cfg.MaxDuration = defaults.MaxDuration
+	}
+
+	js, err := m.Client.JetStream()
+	if err != nil {
+		return nil, err
+	}
+	store, err := js.ObjectStore(cfg.Bucket)
+	if errors.Is(err, nats.ErrStreamNotFound) || errors.Is(err, nats.ErrBucketNotFound) {
+		store, err = js.CreateObjectStore(&nats.ObjectStoreConfig{
+			Bucket:      cfg.Bucket,
+			Description: "Runtime profiles",
+			TTL:         cfg.TTL,
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open object store %q: %w", cfg.Bucket, err)
+	}
+
+	instance, err := os.Hostname()
+	if err != nil || instance == "" {
+		instance = "unknown"
+	}
+
+	return &Controller{
+		cfg:        cfg,
+		store:      store,
+		subscriber: m.Subscriber,
+		publisher:  m.Publisher,
+		logger:     logger,
+		instance:   instance,
+	}, nil
+}
+
+// Start subscribes to the control subjects: the subject of the instance, the
+// subject of the service in a queue group, and Subject itself with Broadcast.
+func (c *Controller) Start() error {
+	if err := c.subscribe(InstanceSubject(c.cfg.Subject, c.cfg.Service, c.instance), nil); err != nil {
+		return err
+	}
+	queue := &messaging.SubscribeOptions{QueueGroup: subjectToken(c.cfg.Service)}
+	if err := c.subscribe(ServiceSubject(c.cfg.Subject, c.cfg.Service), queue); err != nil {
+		return err
+	}
+	if c.cfg.Broadcast {
+		if err := c.subscribe(c.cfg.Subject, nil); err != nil {
+			return err
+		}
+	}
+	c.logger.Info("Profiling control enabled",
+		zap.String("subject", ServiceSubject(c.cfg.Subject, c.cfg.Service)),
+		zap.String("instance", c.instance),
+		zap.Bool("broadcast", c.cfg.Broadcast),
+		zap.String("bucket", c.cfg.Bucket),
+	)
+	return nil
+}
+
+func (c *Controller) subscribe(subject string, opts *messaging.SubscribeOptions) error {
+	if err := c.subscriber.Subscribe(subject, c.Handle, opts); err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", subject, err)
+	}
+	return nil
+}
+
+// Handle captures the requested profile, stores it and replies with the
+// Result, or with an error message.
+func (c *Controller) Handle(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+	var req Request
+	if err := json.Unmarshal(env.Data, &req); err != nil {
+		return c.replyError(ctx, env, fmt.Errorf("invalid profile request: %w", err))
+	}
+
+	res, err := c.Capture(ctx, req)
+	if err != nil {
+		return c.replyError(ctx, env, err)
+	}
+	if env.Reply == "" {
+		return nil
+	}
+	return c.publisher.Publish(ctx, env.Reply, ResultType, res, nil)
+}
+
+// Capture captures a profile and uploads it to the bucket.
+func (c *Controller) Capture(ctx context.Context, req Request) (*Result, error) {
+	if req.Type == "" {
+		req.Type = TypeCPU
+	}
+	duration := c.cfg.DefaultDuration
+	if req.Seconds > 0 {
+		duration = time.Duration(req.Seconds) * time.Second
+	}
+	if duration > c.cfg.MaxDuration {
+		duration = c.cfg.MaxDuration
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	captured := time.Now().UTC()
+	var buf bytes.Buffer
+	if err := Capture(ctx, &buf, req.Type, duration); err != nil {
+		return nil, fmt.Errorf("failed to capture %s profile: %w", req.Type, err)
+	}
+
+	name := fmt.Sprintf("%s/%s-%s.pprof", c.instance, req.Type, captured.Format("20060102T150405Z"))
+	info, err := c.store.Put(&nats.ObjectMeta{
+		Name:        name,
+		Description: fmt.Sprintf("%s profile of %s", req.Type, c.instance),
+	}, &buf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload profile: %w", err)
+	}
+
+	c.logger.Info("Profile captured",
+		zap.String("type", req.Type),
+		zap.String("object", name),
+		zap.Uint64("size", info.Size),
+	)
+	return &Result{
+		Instance: c.instance,
+		Type:     req.Type,
+		Bucket:   c.cfg.Bucket,
+		Object:   name,
+		Size:     info.Size,
+		Captured: captured,
+	}, nil
+}
+
+func (c *Controller) replyError(ctx context.Context, env *messaging.MessageEnvelope, err error) error {
+	c.logger.Warn("Profile request failed", zap.Error(err))
+	if env.Reply == "" {
+		return nil
+	}
+	return c.publisher.PublishError(ctx, env.Reply, err.Error())
+}
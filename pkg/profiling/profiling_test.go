@@ -0,0 +1,138 @@
+package profiling
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTestController(t *testing.T, configure ...func(*Config)) (*Controller, *messaging.Messenger) {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	s, err := server.NewServer(&server.Options{
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+	})
+	require.NoError(t, err)
+	go s.Start()
+	t.Cleanup(s.Shutdown)
+	require.True(t, s.ReadyForConnections(5*time.Second))
+
+	logger := zap.NewNop()
+	m := &messaging.Messenger{}
+	require.NoError(t, m.Init(messaging.Config{
+		URL:               s.ClientURL(),
+		ConnectionTimeout: 2 * time.Second,
+	}, logger, "profiling-test"))
+	t.Cleanup(func() { _ = m.Close() })
+
+	cfg := Config{Service: "orders", MaxDuration: time.Second}
+	for _, fn := range configure {
+		fn(&cfg)
+	}
+	c, err := New(m, cfg, logger)
+	require.NoError(t, err)
+	require.NoError(t, c.Start())
+	return c, m
+}
+
+func TestCapture(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Capture(context.Background(), &buf, TypeHeap, 0))
+	assert.NotZero(t, buf.Len())
+
+	assert.Error(t, Capture(context.Background(), &bytes.Buffer{}, "nope", 0))
+
+	// A cancelled context ends a CPU profile early
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, Capture(ctx, &bytes.Buffer{}, TypeCPU, time.Minute), context.Canceled)
+}
+
+func TestController_HeapProfile(t *testing.T) {
+	c, m := newTestController(t)
+
+	resp, err := m.Publisher.Request(context.Background(), ServiceSubject(c.cfg.Subject, "orders"), RequestType,
+		Request{Type: TypeHeap}, 5*time.Second)
+	require.NoError(t, err)
+	require.Equal(t, ResultType, resp.Type)
+
+	var res Result
+	require.NoError(t, json.Unmarshal(resp.Data, &res))
+	assert.Equal(t, TypeHeap, res.Type)
+	assert.Equal(t, "profiles", res.Bucket)
+	assert.NotZero(t, res.Size)
+
+	data, err := c.store.GetBytes(res.Object)
+	require.NoError(t, err)
+	assert.Len(t, data, int(res.Size))
+}
+
+func TestController_CPUProfileCapped(t *testing.T) {
+	c, _ := newTestController(t)
+
+	start := time.Now()
+	res, err := c.Capture(context.Background(), Request{Type: TypeCPU, Seconds: 60})
+	require.NoError(t, err)
+	// MaxDuration (1s) caps the requested 60s
+	assert.Less(t, time.Since(start), 10*time.Second)
+	assert.Equal(t, TypeCPU, res.Type)
+}
+
+func TestController_UnknownType(t *testing.T) {
+	c, m := newTestController(t)
+
+	resp, err := m.Publisher.Request(context.Background(), ServiceSubject(c.cfg.Subject, "orders"), RequestType,
+		Request{Type: "nope"}, 5*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "error", resp.Type)
+	assert.Contains(t, string(resp.Data), "unknown profile type")
+}
+
+func TestSubjects(t *testing.T) {
+	assert.Equal(t, "grouter.control.profile.orders", ServiceSubject("grouter.control.profile", "orders"))
+	assert.Equal(t, "grouter.control.profile.orders.web-1_example_com",
+		InstanceSubject("grouter.control.profile", "orders", "web-1.example.com"))
+}
+
+func TestController_Subjects(t *testing.T) {
+	c, m := newTestController(t)
+	request := func(subject string) (*messaging.MessageEnvelope, error) {
+		return m.Publisher.Request(context.Background(), subject, RequestType, Request{Type: TypeHeap}, 5*time.Second)
+	}
+
+	resp, err := request(InstanceSubject(c.cfg.Subject, "orders", c.instance))
+	require.NoError(t, err)
+	var res Result
+	require.NoError(t, json.Unmarshal(resp.Data, &res))
+	assert.Equal(t, c.instance, res.Instance)
+
+	_, err = request(c.cfg.Subject)
+	assert.Error(t, err, "no broadcast unless configured")
+	_, err = request(ServiceSubject(c.cfg.Subject, "payments"))
+	assert.Error(t, err, "other services are not profiled")
+
+	c, m = newTestController(t, func(cfg *Config) { cfg.Broadcast = true })
+	resp, err = request(c.cfg.Subject)
+	require.NoError(t, err)
+	assert.Equal(t, ResultType, resp.Type)
+}
+
+func TestNew_RequiresService(t *testing.T) {
+	_, m := newTestController(t)
+	_, err := New(m, Config{}, nil)
+	assert.Error(t, err)
+}
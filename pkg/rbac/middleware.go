@@ -0,0 +1,66 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	messaging "grouter/pkg/messaging/nats"
+	"grouter/pkg/web"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MetadataRoles is the envelope metadata key carrying the caller's roles as a
+// comma separated list.
+const MetadataRoles = "roles"
+
+// ErrForbidden is returned by the message middleware for denied messages.
+var ErrForbidden = errors.New("forbidden")
+
+// GinMiddleware enforces the policy on HTTP routes using the roles of the
+// identity set by the auth middleware. It must run after authentication.
+func GinMiddleware(e *Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var roles []string
+		if id, ok := web.IdentityFromContext(c); ok {
+			roles = id.Roles
+		}
+		if !e.AllowRoute(roles, c.Request.Method, c.Request.URL.Path) {
+			web.AbortWithError(c, web.Forbidden("forbidden"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// MessageMiddleware enforces the policy on message types. The caller's roles
+// are read from the envelope's "roles" metadata, plus the roles configured for
+// the envelope's source service.
+func MessageMiddleware(e *Engine, sourceRoles map[string][]string) messaging.SubscriberMiddleware {
+	return func(next messaging.HandlerFunc) messaging.HandlerFunc {
+		return func(ctx context.Context, subject string, msg *messaging.MessageEnvelope) error {
+			roles := append(RolesFromMetadata(msg.Metadata), sourceRoles[msg.Source]...)
+			if !e.AllowMessage(roles, msg.Type) {
+				return fmt.Errorf("%w: message type %q", ErrForbidden, msg.Type)
+			}
+			return next(ctx, subject, msg)
+		}
+	}
+}
+
+// RolesFromMetadata parses the roles carried in envelope metadata.
+func RolesFromMetadata(md map[string]string) []string {
+	v := md[MetadataRoles]
+	if v == "" {
+		return nil
+	}
+	var roles []string
+	for _, r := range strings.Split(v, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			roles = append(roles, r)
+		}
+	}
+	return roles
+}
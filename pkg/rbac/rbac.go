@@ -0,0 +1,145 @@
+package rbac
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Wildcard grants access to every route or message type.
+const Wildcard = "*"
+
+// Permissions lists what a role may access.
+type Permissions struct {
+	// Routes are "METHOD /path" patterns, e.g. "DELETE /api/*" or "* /admin/*".
+	// A missing method matches every method.
+	Routes []string `mapstructure:"routes" json:"routes"`
+	// Messages are message type patterns, e.g. "natdemo.delete" or "natdemo.*".
+	Messages []string `mapstructure:"messages" json:"messages"`
+}
+
+// Policy maps roles to permissions. In patterns, * matches any sequence of
+// characters (including / and .).
+type Policy struct {
+	// DefaultDeny denies routes and messages that no role mentions. When
+	// false, only routes and messages matched by some role are restricted.
+	DefaultDeny bool `mapstructure:"default_deny" json:"default_deny"`
+	// Roles maps role names to their permissions.
+	Roles map[string]Permissions `mapstructure:"roles" json:"roles"`
+}
+
+type routeRule struct {
+	method string
+	path   *regexp.Regexp
+}
+
+func (r routeRule) match(method, path string) bool {
+	return (r.method == Wildcard || r.method == method) && r.path.MatchString(path)
+}
+
+type compiledRole struct {
+	routes   []routeRule
+	messages []*regexp.Regexp
+}
+
+// Engine evaluates a Policy. It is safe for concurrent use and the policy can
+// be replaced at runtime with Update (e.g. after reloading it from a database).
+type Engine struct {
+	mu          sync.RWMutex
+	defaultDeny bool
+	roles       map[string]compiledRole
+}
+
+// NewEngine creates an Engine for the given policy.
+func NewEngine(p Policy) *Engine {
+	e := &Engine{}
+	e.Update(p)
+	return e
+}
+
+// Update atomically replaces the policy.
+func (e *Engine) Update(p Policy) {
+	roles := make(map[string]compiledRole, len(p.Roles))
+	for name, perms := range p.Roles {
+		var cr compiledRole
+		for _, r := range perms.Routes {
+			cr.routes = append(cr.routes, compileRoute(r))
+		}
+		for _, m := range perms.Messages {
+			cr.messages = append(cr.messages, compilePattern(m))
+		}
+		roles[name] = cr
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.defaultDeny = p.DefaultDeny
+	e.roles = roles
+}
+
+// AllowRoute reports whether any of the roles may call the HTTP route.
+func (e *Engine) AllowRoute(roles []string, method, path string) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for _, name := range roles {
+		for _, r := range e.roles[name].routes {
+			if r.match(method, path) {
+				return true
+			}
+		}
+	}
+	if e.defaultDeny {
+		return false
+	}
+	// Unrestricted unless another role claims the route
+	for _, role := range e.roles {
+		for _, r := range role.routes {
+			if r.match(method, path) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// AllowMessage reports whether any of the roles may send the message type.
+func (e *Engine) AllowMessage(roles []string, msgType string) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for _, name := range roles {
+		for _, p := range e.roles[name].messages {
+			if p.MatchString(msgType) {
+				return true
+			}
+		}
+	}
+	if e.defaultDeny {
+		return false
+	}
+	for _, role := range e.roles {
+		for _, p := range role.messages {
+			if p.MatchString(msgType) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func compileRoute(rule string) routeRule {
+	method, path := Wildcard, strings.TrimSpace(rule)
+	if i := strings.IndexByte(path, ' '); i > 0 {
+		method, path = strings.ToUpper(path[:i]), strings.TrimSpace(path[i+1:])
+	}
+	return routeRule{method: method, path: compilePattern(path)}
+}
+
+func compilePattern(pattern string) *regexp.Regexp {
+	parts := strings.Split(pattern, Wildcard)
+	for i, p := range parts {
+		parts[i] = regexp.QuoteMeta(p)
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, ".*") + "$")
+}
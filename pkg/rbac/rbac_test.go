@@ -0,0 +1,143 @@
+package rbac
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"grouter/pkg/config"
+	"grouter/pkg/database"
+	messaging "grouter/pkg/messaging/nats"
+	"grouter/pkg/web"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func testPolicy() Policy {
+	return Policy{
+		Roles: map[string]Permissions{
+			"admin": {
+				Routes:   []string{"DELETE /*", "* /admin/*"},
+				Messages: []string{"natdemo.delete", "*.admin"},
+			},
+			"reader": {
+				Routes: []string{"GET /reports/*"},
+			},
+		},
+	}
+}
+
+func TestEngine_AllowRoute(t *testing.T) {
+	e := NewEngine(testPolicy())
+
+	assert.True(t, e.AllowRoute([]string{"admin"}, "DELETE", "/natdemo/1"))
+	assert.False(t, e.AllowRoute([]string{"reader"}, "DELETE", "/natdemo/1"))
+	assert.False(t, e.AllowRoute(nil, "POST", "/admin/users"))
+	assert.True(t, e.AllowRoute([]string{"admin"}, "POST", "/admin/users"))
+
+	// Routes no role mentions are unrestricted unless the policy denies by default
+	assert.True(t, e.AllowRoute(nil, "GET", "/natdemo/1"))
+	assert.False(t, e.AllowRoute(nil, "GET", "/reports/daily"))
+
+	p := testPolicy()
+	p.DefaultDeny = true
+	e.Update(p)
+	assert.False(t, e.AllowRoute(nil, "GET", "/natdemo/1"))
+	assert.True(t, e.AllowRoute([]string{"reader"}, "GET", "/reports/daily"))
+}
+
+func TestEngine_AllowMessage(t *testing.T) {
+	e := NewEngine(testPolicy())
+
+	assert.True(t, e.AllowMessage([]string{"admin"}, "natdemo.delete"))
+	assert.False(t, e.AllowMessage([]string{"reader"}, "natdemo.delete"))
+	assert.False(t, e.AllowMessage(nil, "billing.admin"))
+	assert.True(t, e.AllowMessage(nil, "natdemo.create"))
+}
+
+func TestGinMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	a, err := web.NewAuthenticator(context.Background(), web.AuthConfig{
+		Mode: web.AuthModeOptional,
+		APIKeys: []web.APIKeyConfig{
+			{Name: "ops", Key: "admin-key", Roles: []string{"admin"}},
+			{Name: "bi", Key: "reader-key", Roles: []string{"reader"}},
+		},
+	})
+	require.NoError(t, err)
+
+	engine := gin.New()
+	engine.Use(a.Middleware(), GinMiddleware(NewEngine(testPolicy())))
+	engine.DELETE("/natdemo/:id", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	for key, want := range map[string]int{
+		"admin-key":  http.StatusNoContent,
+		"reader-key": http.StatusForbidden,
+		"":           http.StatusForbidden,
+	} {
+		req := httptest.NewRequest(http.MethodDelete, "/natdemo/1", nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, want, w.Code, "key %q", key)
+	}
+}
+
+func TestMessageMiddleware(t *testing.T) {
+	var handled int
+	next := func(ctx context.Context, subject string, msg *messaging.MessageEnvelope) error {
+		handled++
+		return nil
+	}
+	h := MessageMiddleware(NewEngine(testPolicy()), map[string][]string{"opsvc": {"admin"}})(next)
+	ctx := context.Background()
+
+	err := h(ctx, "natdemo.delete", &messaging.MessageEnvelope{Type: "natdemo.delete"})
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	err = h(ctx, "natdemo.delete", &messaging.MessageEnvelope{Type: "natdemo.delete", Metadata: map[string]string{MetadataRoles: "reader, admin"}})
+	assert.NoError(t, err)
+
+	err = h(ctx, "natdemo.delete", &messaging.MessageEnvelope{Type: "natdemo.delete", Source: "opsvc"})
+	assert.NoError(t, err)
+
+	assert.Equal(t, 2, handled)
+}
+
+func TestDBStore(t *testing.T) {
+	db, err := database.New(config.DatabaseConfig{
+		Driver:   "sqlite",
+		DBName:   ":memory:",
+		LogLevel: "silent",
+	}, zap.NewNop())
+	require.NoError(t, err)
+
+	store, err := NewDBStore(db.DB)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	require.NoError(t, store.Grant(ctx, "admin", KindRoute, "DELETE /*"))
+	require.NoError(t, store.Grant(ctx, "admin", KindMessage, "natdemo.delete"))
+	require.NoError(t, store.Grant(ctx, "reader", KindRoute, "GET /*"))
+	assert.Error(t, store.Grant(ctx, "admin", "queue", "x"))
+
+	p, err := store.Load(ctx, true)
+	require.NoError(t, err)
+	assert.True(t, p.DefaultDeny)
+	assert.Equal(t, []string{"DELETE /*"}, p.Roles["admin"].Routes)
+	assert.Equal(t, []string{"natdemo.delete"}, p.Roles["admin"].Messages)
+
+	require.NoError(t, store.Revoke(ctx, "reader", KindRoute, "GET /*"))
+	p, err = store.Load(ctx, true)
+	require.NoError(t, err)
+	assert.NotContains(t, p.Roles, "reader")
+
+	e := NewEngine(p)
+	assert.True(t, e.AllowMessage([]string{"admin"}, "natdemo.delete"))
+}
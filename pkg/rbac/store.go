@@ -0,0 +1,86 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Permission kinds stored in the rbac_permissions table.
+const (
+	KindRoute   = "route"
+	KindMessage = "message"
+)
+
+// PermissionRecord is one row of the rbac_permissions table.
+type PermissionRecord struct {
+	ID      uint   `gorm:"primaryKey"`
+	Role    string `gorm:"index;not null"`
+	Kind    string `gorm:"not null"`
+	Pattern string `gorm:"not null"`
+}
+
+// TableName overrides the GORM table name.
+func (PermissionRecord) TableName() string {
+	return "rbac_permissions"
+}
+
+// DBStore loads policies from the rbac_permissions table.
+type DBStore struct {
+	db *gorm.DB
+}
+
+// NewDBStore creates a DBStore and migrates the rbac_permissions table.
+func NewDBStore(db *gorm.DB) (*DBStore, error) {
+	if err := db.AutoMigrate(&PermissionRecord{}); err != nil {
+		return nil, fmt.Errorf("failed to migrate rbac table: %w", err)
+	}
+	return &DBStore{db: db}, nil
+}
+
+// Load builds a policy from the stored permissions.
+func (s *DBStore) Load(ctx context.Context, defaultDeny bool) (Policy, error) {
+	var records []PermissionRecord
+	if err := s.db.WithContext(ctx).Order("id").Find(&records).Error; err != nil {
+		return Policy{}, fmt.Errorf("failed to load rbac permissions: %w", err)
+	}
+
+	p := Policy{DefaultDeny: defaultDeny, Roles: make(map[string]Permissions)}
+	for _, r := range records {
+		perms := p.Roles[r.Role]
+		switch r.Kind {
+		case KindRoute:
+			perms.Routes = append(perms.Routes, r.Pattern)
+		case KindMessage:
+			perms.Messages = append(perms.Messages, r.Pattern)
+		default:
+			return Policy{}, fmt.Errorf("unknown rbac permission kind %q for role %q", r.Kind, r.Role)
+		}
+		p.Roles[r.Role] = perms
+	}
+	return p, nil
+}
+
+// Grant adds a permission for a role.
+func (s *DBStore) Grant(ctx context.Context, role, kind, pattern string) error {
+	if kind != KindRoute && kind != KindMessage {
+		return fmt.Errorf("unknown rbac permission kind %q", kind)
+	}
+	rec := PermissionRecord{Role: role, Kind: kind, Pattern: pattern}
+	if err := s.db.WithContext(ctx).Create(&rec).Error; err != nil {
+		return fmt.Errorf("failed to store rbac permission: %w", err)
+	}
+	return nil
+}
+
+// Revoke removes a permission from a role.
+func (s *DBStore) Revoke(ctx context.Context, role, kind, pattern string) error {
+	err := s.db.WithContext(ctx).
+		Where("role = ? AND kind = ? AND pattern = ?", role, kind, pattern).
+		Delete(&PermissionRecord{}).Error
+	if err != nil {
+		return fmt.Errorf("failed to delete rbac permission: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+// Package scaffold generates the skeleton of a new service following the
+// layout of services/natsdemosvc and services/webdemosvc: a main package, an
+// app wiring the manager, a service package, its config file, Dockerfile,
+// tests and Bazel targets.
+package scaffold
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"go/format"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates/*.tmpl
+var templates embed.FS
+
+// ErrExists is returned when the service directory already exists and Force
+// is not set
+var ErrExists = errors.New("service directory already exists")
+
+var namePattern = regexp.MustCompile(`^[a-z][a-z0-9]*$`)
+
+// reserved are the package names clashing with the imports and packages of
+// the generated code
+var reserved = map[string]bool{
+	"app": true, "config": true, "context": true, "database": true, "fmt": true,
+	"gin": true, "http": true, "main": true, "manager": true, "messaging": true,
+	"mocks": true, "sync": true, "testing": true, "time": true, "zap": true,
+}
+
+// Options describes the service to generate
+type Options struct {
+	// Name of the service, its directory and binary, e.g. orderssvc. Its
+	// package drops the "svc" suffix, e.g. orders.
+	Name string
+	// Module is the Go module path of the repository, e.g. grouter
+	Module string
+	// Root is the directory of the module, "." by default
+	Root string
+	// Dir is the directory of the services, relative to Root ("services" by
+	// default)
+	Dir string
+
+	// Capabilities of the service
+	Web       bool
+	NATS      bool
+	Database  bool
+	Scheduler bool
+
+	// Port of the web server (8080 by default)
+	Port int
+	// Force overwrites the files of an existing directory
+	Force bool
+}
+
+// data is the input of the templates
+type data struct {
+	Options
+	// Package is the name of the service package
+	Package string
+	// ImportPath is the import path of the service directory
+	ImportPath string
+	// Target is the Bazel package of the service directory
+	Target string
+}
+
+// file is a generated file, relative to the service directory
+type file struct {
+	path     func(d *data) string
+	template string
+}
+
+func static(p string) func(*data) string {
+	return func(*data) string { return p }
+}
+
+var files = []file{
+	{path: static("BUILD.bazel"), template: "BUILD.bazel.tmpl"},
+	{path: static("Dockerfile"), template: "Dockerfile.tmpl"},
+	{path: static("README.md"), template: "README.md.tmpl"},
+	{path: func(d *data) string { return "cmd/" + d.Name + "/BUILD.bazel" }, template: "cmd_BUILD.bazel.tmpl"},
+	{path: func(d *data) string { return "cmd/" + d.Name + "/main.go" }, template: "main.go.tmpl"},
+	{path: static("internal/app/BUILD.bazel"), template: "app_BUILD.bazel.tmpl"},
+	{path: static("internal/app/app.go"), template: "app.go.tmpl"},
+	{path: static("internal/app/app_test.go"), template: "app_test.go.tmpl"},
+	{path: static("internal/config/BUILD.bazel"), template: "config_BUILD.bazel.tmpl"},
+	{path: static("internal/config/config.yaml"), template: "config.yaml.tmpl"},
+	{path: func(d *data) string { return "internal/pkg/" + d.Package + "/BUILD.bazel" }, template: "service_BUILD.bazel.tmpl"},
+	{path: func(d *data) string { return "internal/pkg/" + d.Package + "/config.go" }, template: "config.go.tmpl"},
+	{path: func(d *data) string { return "internal/pkg/" + d.Package + "/service.go" }, template: "service.go.tmpl"},
+	{path: func(d *data) string { return "internal/pkg/" + d.Package + "/service_test.go" }, template: "service_test.go.tmpl"},
+}
+
+// Generate writes the service described by opts and returns the paths of
+// the files written
+func Generate(opts Options) ([]string, error) {
+	d, err := newData(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(d.Root, filepath.FromSlash(d.Dir), d.Name)
+	if _, err := os.Stat(dir); err == nil && !d.Force {
+		return nil, fmt.Errorf("%w: %s", ErrExists, dir)
+	}
+
+	tmpl, err := template.ParseFS(templates, "templates/*.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse templates: %w", err)
+	}
+
+	written := make([]string, 0, len(files))
+	for _, f := range files {
+		content, err := render(tmpl, f.template, d)
+		if err != nil {
+			return written, err
+		}
+		target := filepath.Join(dir, filepath.FromSlash(f.path(d)))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return written, fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := os.WriteFile(target, content, 0o644); err != nil {
+			return written, fmt.Errorf("failed to write %s: %w", target, err)
+		}
+		written = append(written, target)
+	}
+	return written, nil
+}
+
+// newData validates opts and fills the defaults
+func newData(opts Options) (*data, error) {
+	if !namePattern.MatchString(opts.Name) {
+		return nil, fmt.Errorf("invalid service name %q: use lower case letters and digits, starting with a letter", opts.Name)
+	}
+	pkg := strings.TrimSuffix(opts.Name, "svc")
+	if pkg == "" {
+		pkg = opts.Name
+	}
+	if reserved[pkg] {
+		return nil, fmt.Errorf("invalid service name %q: package %s clashes with the generated code", opts.Name, pkg)
+	}
+	if opts.Module == "" {
+		return nil, fmt.Errorf("module path is required")
+	}
+	if opts.Root == "" {
+		opts.Root = "."
+	}
+	if opts.Dir == "" {
+		opts.Dir = "services"
+	}
+	opts.Dir = path.Clean(filepath.ToSlash(opts.Dir))
+	if path.IsAbs(opts.Dir) || strings.HasPrefix(opts.Dir, "..") {
+		return nil, fmt.Errorf("services directory %q must be inside the module", opts.Dir)
+	}
+	if opts.Port == 0 {
+		opts.Port = 8080
+	}
+	return &data{
+		Options:    opts,
+		Package:    pkg,
+		ImportPath: path.Join(opts.Module, opts.Dir, opts.Name),
+		Target:     "//" + path.Join(opts.Dir, opts.Name),
+	}, nil
+}
+
+// render executes the template name, formatting Go sources
+func render(tmpl *template.Template, name string, d *data) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, name, d); err != nil {
+		return nil, fmt.Errorf("failed to render %s: %w", name, err)
+	}
+	if !strings.HasSuffix(name, ".go.tmpl") {
+		return buf.Bytes(), nil
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format %s: %w", name, err)
+	}
+	return src, nil
+}
+
+// ModulePath returns the module path declared by the go.mod of root
+func ModulePath(root string) (string, error) {
+	f, err := os.Open(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return "", fmt.Errorf("failed to open go.mod: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if module, ok := strings.CutPrefix(line, "module "); ok {
+			return strings.Trim(strings.TrimSpace(module), `"`), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read go.mod: %w", err)
+	}
+	return "", fmt.Errorf("no module directive in %s", f.Name())
+}
@@ -0,0 +1,181 @@
+package scaffold
+
+import (
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate_Files(t *testing.T) {
+	root := t.TempDir()
+	written, err := Generate(Options{Name: "orderssvc", Module: "example.com/app", Root: root})
+	require.NoError(t, err)
+
+	rel := make([]string, 0, len(written))
+	for _, p := range written {
+		r, err := filepath.Rel(filepath.Join(root, "services", "orderssvc"), p)
+		require.NoError(t, err)
+		rel = append(rel, filepath.ToSlash(r))
+	}
+	assert.Equal(t, []string{
+		"BUILD.bazel",
+		"Dockerfile",
+		"README.md",
+		"cmd/orderssvc/BUILD.bazel",
+		"cmd/orderssvc/main.go",
+		"internal/app/BUILD.bazel",
+		"internal/app/app.go",
+		"internal/app/app_test.go",
+		"internal/config/BUILD.bazel",
+		"internal/config/config.yaml",
+		"internal/pkg/orders/BUILD.bazel",
+		"internal/pkg/orders/config.go",
+		"internal/pkg/orders/service.go",
+		"internal/pkg/orders/service_test.go",
+	}, rel)
+}
+
+func TestGenerate_Capabilities(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     Options
+		contains map[string][]string
+		excludes map[string][]string
+	}{
+		{
+			name: "none",
+			opts: Options{},
+			excludes: map[string][]string{
+				"internal/pkg/orders/service.go": {"gin-gonic", "database", "Subjects", "schedule"},
+				"internal/app/app.go":            {"NewAdminService"},
+			},
+			contains: map[string][]string{
+				"internal/config/config.yaml": {"nats:\n  enabled: false", "web:\n  enabled: false"},
+			},
+		},
+		{
+			name: "all",
+			opts: Options{Web: true, NATS: true, Database: true, Scheduler: true, Port: 9090},
+			contains: map[string][]string{
+				"internal/pkg/orders/service.go":  {"RegisterRoutes", "database.New", "Subjects", "schedule"},
+				"internal/pkg/orders/config.go":   {"Subject", "Interval"},
+				"internal/pkg/orders/BUILD.bazel": {"//pkg/database", "@com_github_gin_gonic_gin//:gin"},
+				"internal/app/app.go":             {"NewAdminService"},
+				"internal/config/config.yaml":     {"nats:\n  enabled: true", "port: 9090", "subject: \"orderssvc.orders\""},
+				"Dockerfile":                      {"EXPOSE 9090"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			tt.opts.Name, tt.opts.Module, tt.opts.Root = "orderssvc", "example.com/app", root
+			written, err := Generate(tt.opts)
+			require.NoError(t, err)
+
+			for _, p := range written {
+				if !strings.HasSuffix(p, ".go") {
+					continue
+				}
+				src, err := os.ReadFile(p)
+				require.NoError(t, err)
+				_, err = parser.ParseFile(token.NewFileSet(), p, src, parser.ParseComments)
+				require.NoError(t, err, p)
+				formatted, err := format.Source(src)
+				require.NoError(t, err)
+				assert.Equal(t, string(formatted), string(src), "%s is gofmt'd", p)
+			}
+
+			dir := filepath.Join(root, "services", "orderssvc")
+			read := func(name string) string {
+				b, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+				require.NoError(t, err)
+				return string(b)
+			}
+			for name, subs := range tt.contains {
+				for _, s := range subs {
+					assert.Contains(t, read(name), s, name)
+				}
+			}
+			for name, subs := range tt.excludes {
+				for _, s := range subs {
+					assert.NotContains(t, read(name), s, name)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerate_Exists(t *testing.T) {
+	root := t.TempDir()
+	opts := Options{Name: "orderssvc", Module: "example.com/app", Root: root}
+	_, err := Generate(opts)
+	require.NoError(t, err)
+
+	_, err = Generate(opts)
+	assert.ErrorIs(t, err, ErrExists)
+
+	opts.Force = true
+	opts.NATS = true
+	_, err = Generate(opts)
+	require.NoError(t, err)
+	b, err := os.ReadFile(filepath.Join(root, "services/orderssvc/internal/pkg/orders/service.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "Subjects", "Force overwrites the files")
+}
+
+func TestGenerate_Invalid(t *testing.T) {
+	tests := map[string]Options{
+		"empty name":    {Module: "example.com/app"},
+		"upper case":    {Name: "Orders", Module: "example.com/app"},
+		"dash":          {Name: "orders-svc", Module: "example.com/app"},
+		"leading digit": {Name: "1svc", Module: "example.com/app"},
+		"reserved":      {Name: "configsvc", Module: "example.com/app"},
+		"no module":     {Name: "orderssvc"},
+		"dir outside":   {Name: "orderssvc", Module: "example.com/app", Dir: "../services"},
+		"absolute dir":  {Name: "orderssvc", Module: "example.com/app", Dir: "/services"},
+	}
+	for name, opts := range tests {
+		t.Run(name, func(t *testing.T) {
+			opts.Root = t.TempDir()
+			written, err := Generate(opts)
+			assert.Error(t, err)
+			assert.Empty(t, written)
+		})
+	}
+}
+
+func TestGenerate_Package(t *testing.T) {
+	d, err := newData(Options{Name: "orderssvc", Module: "example.com/app", Dir: "./apps/"})
+	require.NoError(t, err)
+	assert.Equal(t, "orders", d.Package)
+	assert.Equal(t, "example.com/app/apps/orderssvc", d.ImportPath)
+	assert.Equal(t, "//apps/orderssvc", d.Target)
+	assert.Equal(t, 8080, d.Port)
+
+	d, err = newData(Options{Name: "svc", Module: "example.com/app"})
+	require.NoError(t, err)
+	assert.Equal(t, "svc", d.Package, "a name without prefix keeps its suffix")
+}
+
+func TestModulePath(t *testing.T) {
+	root := t.TempDir()
+	_, err := ModulePath(root)
+	assert.Error(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "go.mod"), []byte("// comment\nmodule \"example.com/app\" \n\ngo 1.24\n"), 0o644))
+	module, err := ModulePath(root)
+	require.NoError(t, err)
+	assert.Equal(t, "example.com/app", module)
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "go.mod"), []byte("go 1.24\n"), 0o644))
+	_, err = ModulePath(root)
+	assert.Error(t, err)
+}
@@ -0,0 +1,497 @@
+// Package slo tracks the service level objectives of HTTP routes and NATS
+// subjects. The web and messaging middlewares observe the requests and
+// messages; a Tracker computes the rolling compliance, error budget and burn
+// rates of each objective, exports them as metrics and reports the alerts of
+// the budgets burning fast.
+package slo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"grouter/pkg/telemetry"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Kind is what an objective observes
+type Kind string
+
+// Kinds
+const (
+	// KindHTTP objectives match request routes by prefix
+	KindHTTP Kind = "http"
+	// KindNATS objectives match message subjects, NATS wildcards allowed
+	KindNATS Kind = "nats"
+)
+
+// Defaults of Config
+const (
+	DefaultWindow             = 24 * time.Hour
+	DefaultResolution         = time.Minute
+	DefaultEvaluationInterval = 30 * time.Second
+)
+
+// Objective is the fraction Target of good requests or messages of Kind
+// matching Match. Failed ones are bad, and so are those slower than Latency
+// when set.
+type Objective struct {
+	Name string
+	Kind Kind
+	// Match is the route prefix (/api/orders) or subject pattern, empty for
+	// all
+	Match string
+	// Method restricts an http objective to a request method, empty for all
+	Method string
+	// Latency makes the slower requests or messages bad; zero only counts
+	// the failures
+	Latency time.Duration
+	// Target is the fraction of good events in (0, 1), e.g. 0.999
+	Target float64
+}
+
+// Validate reports the first invalid setting of the objective
+func (o Objective) Validate() error {
+	if o.Name == "" {
+		return errors.New("name is required")
+	}
+	switch o.Kind {
+	case KindHTTP, KindNATS:
+	default:
+		return fmt.Errorf("invalid kind %q, want http or nats", o.Kind)
+	}
+	if o.Target <= 0 || o.Target >= 1 {
+		return fmt.Errorf("target must be in (0, 1), got %g", o.Target)
+	}
+	if o.Latency < 0 {
+		return fmt.Errorf("latency must not be negative, got %s", o.Latency)
+	}
+	return nil
+}
+
+// AlertRule fires when the error budget burns at least BurnRate times faster
+// than the objective allows over both LongWindow and ShortWindow; the short
+// window resolves the alert soon after the burn stops
+type AlertRule struct {
+	Name        string
+	LongWindow  time.Duration
+	ShortWindow time.Duration
+	BurnRate    float64
+}
+
+// DefaultAlertRules page when 2% of a 30 day budget burns in an hour and open
+// a ticket when 5% burns in 6 hours
+var DefaultAlertRules = []AlertRule{
+	{Name: "page", LongWindow: time.Hour, ShortWindow: 5 * time.Minute, BurnRate: 14.4},
+	{Name: "ticket", LongWindow: 6 * time.Hour, ShortWindow: 30 * time.Minute, BurnRate: 6},
+}
+
+// Alert reports that a rule of an objective started or stopped firing
+type Alert struct {
+	SLO    string `json:"slo"`
+	Rule   string `json:"rule"`
+	Firing bool   `json:"firing"`
+	// BurnRate and ShortBurnRate are the burn rates over the windows of the
+	// rule, Threshold its burn rate
+	BurnRate      float64   `json:"burn_rate"`
+	ShortBurnRate float64   `json:"short_burn_rate"`
+	Threshold     float64   `json:"threshold"`
+	LongWindow    string    `json:"long_window"`
+	ShortWindow   string    `json:"short_window"`
+	Target        float64   `json:"target"`
+	Time          time.Time `json:"time"`
+}
+
+// Status is the state of an objective over the compliance window
+type Status struct {
+	Name    string  `json:"name"`
+	Kind    Kind    `json:"kind"`
+	Match   string  `json:"match,omitempty"`
+	Method  string  `json:"method,omitempty"`
+	Latency string  `json:"latency,omitempty"`
+	Target  float64 `json:"target"`
+	Window  string  `json:"window"`
+	Total   uint64  `json:"total"`
+	Bad     uint64  `json:"bad"`
+	// Compliance is the fraction of good events, 1 without events
+	Compliance float64 `json:"compliance"`
+	// ErrorBudgetRemaining is the fraction of the error budget left,
+	// negative once exhausted
+	ErrorBudgetRemaining float64 `json:"error_budget_remaining"`
+	// BurnRates by window of the alert rules, e.g. "1h"
+	BurnRates map[string]float64 `json:"burn_rates"`
+	// Alerts are the firing rules
+	Alerts []string `json:"alerts"`
+}
+
+// Config holds the objectives of a Tracker
+type Config struct {
+	Objectives []Objective
+	// Window is the rolling compliance window (default 24h)
+	Window time.Duration
+	// Resolution is the bucket size of the windows (default 1m)
+	Resolution time.Duration
+	// EvaluationInterval is the interval between two evaluations of the
+	// objectives by Start (default 30s)
+	EvaluationInterval time.Duration
+	// Alerts are the burn rate rules (default DefaultAlertRules)
+	Alerts []AlertRule
+	// OnAlert receives the alerts when rules start and stop firing
+	OnAlert func(Alert)
+	// Registry receives the metrics, nil uses the global registry
+	Registry *telemetry.MetricsRegistry
+}
+
+// Tracker observes the events of the objectives and evaluates them
+type Tracker struct {
+	mu         sync.RWMutex
+	objectives []*objective
+
+	window     time.Duration
+	resolution time.Duration
+	interval   time.Duration
+	rules      []AlertRule
+	onAlert    func(Alert)
+	metrics    metrics
+	now        func() time.Time
+
+	startOnce sync.Once
+	started   atomic.Bool
+	stopOnce  sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// objective is an Objective with its events and firing rules
+type objective struct {
+	Objective
+	events *series
+	good   prometheus.Counter
+	bad    prometheus.Counter
+	firing map[string]bool
+}
+
+type metrics struct {
+	events     *prometheus.CounterVec
+	target     *prometheus.GaugeVec
+	compliance *prometheus.GaugeVec
+	budget     *prometheus.GaugeVec
+	burnRate   *prometheus.GaugeVec
+	alerts     *prometheus.CounterVec
+}
+
+func newMetrics(reg *telemetry.MetricsRegistry) metrics {
+	return metrics{
+		events: reg.CounterVec(prometheus.CounterOpts{
+			Name: "slo_events_total",
+			Help: "Total number of requests and messages observed by the objectives",
+		}, []string{"slo", "result"}),
+		target: reg.GaugeVec(prometheus.GaugeOpts{
+			Name: "slo_target_ratio",
+			Help: "Fraction of good events of the objectives",
+		}, []string{"slo"}),
+		compliance: reg.GaugeVec(prometheus.GaugeOpts{
+			Name: "slo_compliance_ratio",
+			Help: "Fraction of good events over the compliance window",
+		}, []string{"slo"}),
+		budget: reg.GaugeVec(prometheus.GaugeOpts{
+			Name: "slo_error_budget_remaining_ratio",
+			Help: "Fraction of the error budget left over the compliance window",
+		}, []string{"slo"}),
+		burnRate: reg.GaugeVec(prometheus.GaugeOpts{
+			Name: "slo_burn_rate",
+			Help: "Rate the error budget burns at over the window, 1 exhausting it in the compliance window",
+		}, []string{"slo", "window"}),
+		alerts: reg.CounterVec(prometheus.CounterOpts{
+			Name: "slo_alerts_total",
+			Help: "Total number of burn rate alerts fired",
+		}, []string{"slo", "rule"}),
+	}
+}
+
+// New creates a tracker of the objectives of cfg
+func New(cfg Config) (*Tracker, error) {
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultWindow
+	}
+	if cfg.Resolution <= 0 {
+		cfg.Resolution = DefaultResolution
+	}
+	if cfg.EvaluationInterval <= 0 {
+		cfg.EvaluationInterval = DefaultEvaluationInterval
+	}
+	if len(cfg.Alerts) == 0 {
+		cfg.Alerts = DefaultAlertRules
+	}
+	for i, r := range cfg.Alerts {
+		if r.Name == "" {
+			return nil, fmt.Errorf("alert rule %d: name is required", i)
+		}
+		if r.ShortWindow < cfg.Resolution || r.LongWindow < r.ShortWindow || r.LongWindow > cfg.Window {
+			return nil, fmt.Errorf("alert rule %s: windows must satisfy resolution <= short_window <= long_window <= window", r.Name)
+		}
+		if r.BurnRate <= 0 {
+			return nil, fmt.Errorf("alert rule %s: burn rate must be positive, got %g", r.Name, r.BurnRate)
+		}
+	}
+
+	t := &Tracker{
+		window:     cfg.Window,
+		resolution: cfg.Resolution,
+		interval:   cfg.EvaluationInterval,
+		rules:      append([]AlertRule{}, cfg.Alerts...),
+		onAlert:    cfg.OnAlert,
+		metrics:    newMetrics(cfg.Registry),
+		now:        time.Now,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	if err := t.SetObjectives(cfg.Objectives); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// SetObjectives replaces the objectives, unless one is invalid. Unchanged
+// objectives keep their events.
+func (t *Tracker) SetObjectives(objectives []Objective) error {
+	names := make(map[string]bool, len(objectives))
+	for i, o := range objectives {
+		if err := o.Validate(); err != nil {
+			return fmt.Errorf("objective %d: %w", i, err)
+		}
+		if names[o.Name] {
+			return fmt.Errorf("objective %d: duplicate name %q", i, o.Name)
+		}
+		names[o.Name] = true
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	previous := make(map[string]*objective, len(t.objectives))
+	for _, o := range t.objectives {
+		previous[o.Name] = o
+		if !names[o.Name] {
+			t.deleteMetrics(o.Name)
+		}
+	}
+	t.objectives = make([]*objective, 0, len(objectives))
+	for _, o := range objectives {
+		if prev, ok := previous[o.Name]; ok && prev.Objective == o {
+			t.objectives = append(t.objectives, prev)
+			continue
+		}
+		if _, ok := previous[o.Name]; ok {
+			t.deleteMetrics(o.Name)
+		}
+		t.objectives = append(t.objectives, &objective{
+			Objective: o,
+			events:    newSeries(t.window, t.resolution),
+			good:      t.metrics.events.WithLabelValues(o.Name, "good"),
+			bad:       t.metrics.events.WithLabelValues(o.Name, "bad"),
+			firing:    make(map[string]bool),
+		})
+		t.metrics.target.WithLabelValues(o.Name).Set(o.Target)
+	}
+	return nil
+}
+
+// deleteMetrics removes the series of the objective name, its events
+// counted from zero again if it comes back
+func (t *Tracker) deleteMetrics(name string) {
+	labels := prometheus.Labels{"slo": name}
+	t.metrics.events.DeletePartialMatch(labels)
+	t.metrics.target.DeletePartialMatch(labels)
+	t.metrics.compliance.DeletePartialMatch(labels)
+	t.metrics.budget.DeletePartialMatch(labels)
+	t.metrics.burnRate.DeletePartialMatch(labels)
+}
+
+// Objectives returns the objectives of the tracker
+func (t *Tracker) Objectives() []Objective {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	objectives := make([]Objective, 0, len(t.objectives))
+	for _, o := range t.objectives {
+		objectives = append(objectives, o.Objective)
+	}
+	return objectives
+}
+
+// Observe records a request or message of kind, which failed or took d, in
+// the objectives whose pattern matches. method is the request method of http
+// events. A nil tracker observes nothing.
+func (t *Tracker) Observe(kind Kind, method string, match func(pattern string) bool, d time.Duration, failed bool) {
+	if t == nil {
+		return
+	}
+	now := t.now()
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, o := range t.objectives {
+		if o.Kind != kind || (o.Method != "" && !strings.EqualFold(o.Method, method)) ||
+			(o.Match != "" && !match(o.Match)) {
+			continue
+		}
+		bad := failed || (o.Latency > 0 && d > o.Latency)
+		o.events.add(now, bad)
+		if bad {
+			o.bad.Inc()
+		} else {
+			o.good.Inc()
+		}
+	}
+}
+
+// Status returns the state of the objectives
+func (t *Tracker) Status() []Status {
+	statuses, _ := t.evaluate(false)
+	return statuses
+}
+
+// Evaluate updates the metrics of the objectives and reports the alert rules
+// that started or stopped firing to OnAlert
+func (t *Tracker) Evaluate() {
+	_, alerts := t.evaluate(true)
+	if t.onAlert == nil {
+		return
+	}
+	for _, a := range alerts {
+		t.onAlert(a)
+	}
+}
+
+// evaluate returns the state of the objectives and, when update is set,
+// updates the metrics and firing rules and returns their changes
+func (t *Tracker) evaluate(update bool) ([]Status, []Alert) {
+	now := t.now()
+	if update {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+	} else {
+		t.mu.RLock()
+		defer t.mu.RUnlock()
+	}
+
+	statuses := make([]Status, 0, len(t.objectives))
+	var alerts []Alert
+	for _, o := range t.objectives {
+		total, bad := o.events.sum(now, t.window)
+		status := Status{
+			Name:                 o.Name,
+			Kind:                 o.Kind,
+			Match:                o.Match,
+			Method:               o.Method,
+			Target:               o.Target,
+			Window:               formatWindow(t.window),
+			Total:                total,
+			Bad:                  bad,
+			Compliance:           1,
+			ErrorBudgetRemaining: 1,
+			BurnRates:            make(map[string]float64),
+			Alerts:               []string{},
+		}
+		if o.Latency > 0 {
+			status.Latency = o.Latency.String()
+		}
+		if total > 0 {
+			errorRate := float64(bad) / float64(total)
+			status.Compliance = 1 - errorRate
+			status.ErrorBudgetRemaining = 1 - errorRate/(1-o.Target)
+		}
+
+		burnRate := func(window time.Duration) float64 {
+			total, bad := o.events.sum(now, window)
+			rate := 0.0
+			if total > 0 {
+				rate = float64(bad) / float64(total) / (1 - o.Target)
+			}
+			status.BurnRates[formatWindow(window)] = rate
+			return rate
+		}
+		for _, r := range t.rules {
+			long, short := burnRate(r.LongWindow), burnRate(r.ShortWindow)
+			firing := long >= r.BurnRate && short >= r.BurnRate
+			if firing {
+				status.Alerts = append(status.Alerts, r.Name)
+			}
+			if !update || firing == o.firing[r.Name] {
+				continue
+			}
+			o.firing[r.Name] = firing
+			if firing {
+				t.metrics.alerts.WithLabelValues(o.Name, r.Name).Inc()
+			}
+			alerts = append(alerts, Alert{
+				SLO:           o.Name,
+				Rule:          r.Name,
+				Firing:        firing,
+				BurnRate:      long,
+				ShortBurnRate: short,
+				Threshold:     r.BurnRate,
+				LongWindow:    formatWindow(r.LongWindow),
+				ShortWindow:   formatWindow(r.ShortWindow),
+				Target:        o.Target,
+				Time:          now.UTC(),
+			})
+		}
+
+		if update {
+			t.metrics.compliance.WithLabelValues(o.Name).Set(status.Compliance)
+			t.metrics.budget.WithLabelValues(o.Name).Set(status.ErrorBudgetRemaining)
+			for window, rate := range status.BurnRates {
+				t.metrics.burnRate.WithLabelValues(o.Name, window).Set(rate)
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, alerts
+}
+
+// Start evaluates the objectives every evaluation interval until Stop
+func (t *Tracker) Start() {
+	t.startOnce.Do(t.run)
+}
+
+func (t *Tracker) run() {
+	t.started.Store(true)
+	go func() {
+		defer close(t.done)
+		ticker := time.NewTicker(t.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				t.Evaluate()
+			case <-t.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the evaluations of Start
+func (t *Tracker) Stop() {
+	t.stopOnce.Do(func() {
+		close(t.stop)
+	})
+	if t.started.Load() {
+		<-t.done
+	}
+}
+
+// formatWindow formats d without its zero minutes and seconds, e.g. "1h"
+func formatWindow(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
@@ -0,0 +1,265 @@
+package slo
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"grouter/pkg/telemetry"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clock is a settable time for the tracker
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTracker(t *testing.T, cfg Config) (*Tracker, *clock) {
+	t.Helper()
+	if cfg.Registry == nil {
+		cfg.Registry = telemetry.NewMetricsRegistry()
+	}
+	tr, err := New(cfg)
+	require.NoError(t, err)
+	c := &clock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	tr.now = c.Now
+	return tr, c
+}
+
+// seriesOf returns the number of series of c for the objective name
+func seriesOf(t *testing.T, c prometheus.Collector, name string) int {
+	t.Helper()
+	ch := make(chan prometheus.Metric, 16)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+	n := 0
+	for m := range ch {
+		var pb dto.Metric
+		require.NoError(t, m.Write(&pb))
+		for _, l := range pb.GetLabel() {
+			if l.GetName() == "slo" && l.GetValue() == name {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func prefix(path string) func(string) bool {
+	return func(p string) bool { return strings.HasPrefix(path, p) }
+}
+
+func TestTracker_Observe(t *testing.T) {
+	tr, _ := newTracker(t, Config{Objectives: []Objective{
+		{Name: "orders", Kind: KindHTTP, Match: "/api/orders", Latency: 100 * time.Millisecond, Target: 0.9},
+		{Name: "orders-get", Kind: KindHTTP, Match: "/api/orders", Method: "GET", Target: 0.9},
+		{Name: "events", Kind: KindNATS, Target: 0.99},
+	}})
+
+	tr.Observe(KindHTTP, "GET", prefix("/api/orders/:id"), 10*time.Millisecond, false)
+	tr.Observe(KindHTTP, "POST", prefix("/api/orders"), 200*time.Millisecond, false)
+	tr.Observe(KindHTTP, "GET", prefix("/api/orders"), 10*time.Millisecond, true)
+	tr.Observe(KindHTTP, "GET", prefix("/api/users"), 10*time.Millisecond, true)
+	tr.Observe(KindNATS, "", func(string) bool { return false }, time.Second, false)
+
+	status := tr.Status()
+	require.Len(t, status, 3)
+	assert.Equal(t, uint64(3), status[0].Total)
+	assert.Equal(t, uint64(2), status[0].Bad, "slow and failed requests are bad")
+	assert.InDelta(t, 1.0/3, status[0].Compliance, 1e-9)
+	assert.InDelta(t, 1-(2.0/3)/0.1, status[0].ErrorBudgetRemaining, 1e-9)
+	assert.Equal(t, "100ms", status[0].Latency)
+	assert.Equal(t, "24h", status[0].Window)
+
+	assert.Equal(t, uint64(2), status[1].Total, "objectives with a method only observe its requests")
+	assert.Equal(t, uint64(1), status[1].Bad)
+	assert.Equal(t, uint64(1), status[2].Total, "objectives without a match observe all events")
+	assert.Equal(t, 1.0, status[2].Compliance)
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(tr.metrics.events.WithLabelValues("orders", "bad")))
+
+	var nilTracker *Tracker
+	nilTracker.Observe(KindHTTP, "GET", prefix("/"), 0, true)
+}
+
+func TestTracker_Window(t *testing.T) {
+	tr, c := newTracker(t, Config{
+		Window:     time.Hour,
+		Alerts:     []AlertRule{{Name: "fast", LongWindow: 10 * time.Minute, ShortWindow: time.Minute, BurnRate: 2}},
+		Objectives: []Objective{{Name: "orders", Kind: KindNATS, Target: 0.5}},
+	})
+	all := func(string) bool { return true }
+
+	tr.Observe(KindNATS, "", all, 0, true)
+	c.Add(30 * time.Minute)
+	tr.Observe(KindNATS, "", all, 0, false)
+
+	status := tr.Status()[0]
+	assert.Equal(t, uint64(2), status.Total)
+	assert.Equal(t, map[string]float64{"10m": 0, "1m": 0}, status.BurnRates, "burn rates only count their window")
+
+	c.Add(31 * time.Minute)
+	status = tr.Status()[0]
+	assert.Equal(t, uint64(1), status.Total, "events leave the compliance window")
+	assert.Equal(t, 1.0, status.Compliance)
+}
+
+func TestTracker_Alerts(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		alerts []Alert
+	)
+	tr, c := newTracker(t, Config{
+		Window: time.Hour,
+		Alerts: []AlertRule{{Name: "page", LongWindow: 10 * time.Minute, ShortWindow: time.Minute, BurnRate: 5}},
+		Objectives: []Objective{
+			{Name: "orders", Kind: KindNATS, Target: 0.9},
+		},
+		OnAlert: func(a Alert) {
+			mu.Lock()
+			defer mu.Unlock()
+			alerts = append(alerts, a)
+		},
+	})
+	all := func(string) bool { return true }
+	observe := func(n int, failed bool) {
+		for i := 0; i < n; i++ {
+			tr.Observe(KindNATS, "", all, 0, failed)
+		}
+	}
+
+	observe(9, false)
+	observe(1, true)
+	tr.Evaluate()
+	assert.Empty(t, alerts, "a burn rate of 1 is within budget")
+
+	observe(10, true)
+	tr.Evaluate()
+	require.Len(t, alerts, 1)
+	assert.Equal(t, "orders", alerts[0].SLO)
+	assert.Equal(t, "page", alerts[0].Rule)
+	assert.True(t, alerts[0].Firing)
+	assert.InDelta(t, 5.5, alerts[0].BurnRate, 1e-9)
+	assert.Equal(t, 5.0, alerts[0].Threshold)
+	assert.Equal(t, "10m", alerts[0].LongWindow)
+	assert.Equal(t, []string{"page"}, tr.Status()[0].Alerts)
+	assert.Equal(t, float64(1), testutil.ToFloat64(tr.metrics.alerts.WithLabelValues("orders", "page")))
+	assert.InDelta(t, 5.5, testutil.ToFloat64(tr.metrics.burnRate.WithLabelValues("orders", "10m")), 1e-9)
+
+	tr.Evaluate()
+	assert.Len(t, alerts, 1, "firing rules are reported once")
+
+	c.Add(2 * time.Minute)
+	observe(10, false)
+	tr.Evaluate()
+	require.Len(t, alerts, 2, "the alert resolves once the short window recovers")
+	assert.False(t, alerts[1].Firing)
+	assert.Equal(t, 0.0, alerts[1].ShortBurnRate)
+}
+
+func TestTracker_SetObjectives(t *testing.T) {
+	tr, _ := newTracker(t, Config{Objectives: []Objective{
+		{Name: "orders", Kind: KindNATS, Target: 0.9},
+		{Name: "users", Kind: KindNATS, Target: 0.9},
+	}})
+	all := func(string) bool { return true }
+	tr.Observe(KindNATS, "", all, 0, true)
+
+	require.NoError(t, tr.SetObjectives([]Objective{
+		{Name: "orders", Kind: KindNATS, Target: 0.9},
+		{Name: "users", Kind: KindNATS, Target: 0.99},
+	}))
+	status := tr.Status()
+	assert.Equal(t, uint64(1), status[0].Total, "unchanged objectives keep their events")
+	assert.Equal(t, uint64(0), status[1].Total, "changed objectives start over")
+
+	assert.EqualError(t, tr.SetObjectives([]Objective{{Name: "a", Kind: KindNATS, Target: 0.9}, {Name: "a", Kind: KindNATS, Target: 0.9}}),
+		`objective 1: duplicate name "a"`)
+	assert.Len(t, tr.Objectives(), 2, "invalid objectives are not applied")
+
+	require.NoError(t, tr.SetObjectives([]Objective{{Name: "users", Kind: KindNATS, Target: 0.99}}))
+	assert.Zero(t, seriesOf(t, tr.metrics.events, "orders"), "removed objectives are not exported")
+	assert.Zero(t, seriesOf(t, tr.metrics.target, "orders"))
+	assert.Equal(t, 2, seriesOf(t, tr.metrics.events, "users"), "the others are")
+}
+
+func TestTracker_StartStop(t *testing.T) {
+	var mu sync.Mutex
+	fired := false
+	tr, _ := newTracker(t, Config{
+		EvaluationInterval: 10 * time.Millisecond,
+		Objectives:         []Objective{{Name: "orders", Kind: KindNATS, Target: 0.9}},
+		OnAlert: func(Alert) {
+			mu.Lock()
+			defer mu.Unlock()
+			fired = true
+		},
+	})
+	tr.Observe(KindNATS, "", func(string) bool { return true }, 0, true)
+	tr.Start()
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return fired
+	}, time.Second, 5*time.Millisecond)
+	tr.Stop()
+	tr.Stop()
+
+	idle, _ := newTracker(t, Config{})
+	idle.Stop()
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		err  string
+	}{
+		{name: "kind", cfg: Config{Objectives: []Objective{{Name: "a", Kind: "grpc", Target: 0.9}}}, err: `objective 0: invalid kind "grpc", want http or nats`},
+		{name: "target", cfg: Config{Objectives: []Objective{{Name: "a", Kind: KindHTTP, Target: 1}}}, err: "objective 0: target must be in (0, 1), got 1"},
+		{name: "name", cfg: Config{Objectives: []Objective{{Kind: KindHTTP, Target: 0.9}}}, err: "objective 0: name is required"},
+		{name: "windows", cfg: Config{Window: time.Hour}, err: "alert rule ticket: windows must satisfy resolution <= short_window <= long_window <= window"},
+		{name: "burn rate", cfg: Config{Alerts: []AlertRule{{Name: "a", LongWindow: time.Hour, ShortWindow: time.Hour}}}, err: "alert rule a: burn rate must be positive, got 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.cfg)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestFormatWindow(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		time.Hour:                  "1h",
+		30 * time.Minute:           "30m",
+		90 * time.Minute:           "1h30m",
+		10 * time.Second:           "10s",
+		720 * time.Hour:            "720h",
+		time.Hour + 30*time.Second: "1h0m30s",
+		250 * time.Millisecond:     "250ms",
+	} {
+		assert.Equal(t, want, formatWindow(d))
+	}
+}
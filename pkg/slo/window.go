@@ -0,0 +1,65 @@
+package slo
+
+import (
+	"sync"
+	"time"
+)
+
+// bucket counts the events of a slot of the series
+type bucket struct {
+	slot  int64
+	total uint64
+	bad   uint64
+}
+
+// series counts events in a ring of buckets of resolution, covering window
+type series struct {
+	mu         sync.Mutex
+	resolution time.Duration
+	buckets    []bucket
+}
+
+func newSeries(window, resolution time.Duration) *series {
+	n := int((window + resolution - 1) / resolution)
+	return &series{resolution: resolution, buckets: make([]bucket, n)}
+}
+
+// slot returns the slot of t, and its bucket index
+func (s *series) slot(t time.Time) (int64, int) {
+	slot := t.UnixNano() / int64(s.resolution)
+	return slot, int(slot % int64(len(s.buckets)))
+}
+
+// add counts an event at now
+func (s *series) add(now time.Time, bad bool) {
+	slot, i := s.slot(now)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := &s.buckets[i]
+	if b.slot != slot {
+		*b = bucket{slot: slot}
+	}
+	b.total++
+	if bad {
+		b.bad++
+	}
+}
+
+// sum returns the events of the last window up to now
+func (s *series) sum(now time.Time, window time.Duration) (total, bad uint64) {
+	n := int64((window + s.resolution - 1) / s.resolution)
+	if n > int64(len(s.buckets)) {
+		n = int64(len(s.buckets))
+	}
+	current, _ := s.slot(now)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for slot := current - n + 1; slot <= current; slot++ {
+		b := s.buckets[slot%int64(len(s.buckets))]
+		if b.slot == slot {
+			total += b.total
+			bad += b.bad
+		}
+	}
+	return total, bad
+}
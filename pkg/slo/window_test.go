@@ -0,0 +1,35 @@
+package slo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeries(t *testing.T) {
+	s := newSeries(5*time.Minute, time.Minute)
+	assert.Len(t, s.buckets, 5)
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.add(start, true)
+	s.add(start.Add(30*time.Second), false)
+	s.add(start.Add(2*time.Minute), false)
+
+	total, bad := s.sum(start.Add(2*time.Minute), 5*time.Minute)
+	assert.Equal(t, uint64(3), total)
+	assert.Equal(t, uint64(1), bad)
+
+	total, _ = s.sum(start.Add(2*time.Minute), time.Minute)
+	assert.Equal(t, uint64(1), total, "only the buckets of the window are summed")
+
+	total, bad = s.sum(start.Add(5*time.Minute), time.Hour)
+	assert.Equal(t, uint64(1), total, "windows longer than the series are capped")
+	assert.Equal(t, uint64(0), bad)
+
+	// the bucket of the first minute is reused
+	s.add(start.Add(5*time.Minute), true)
+	total, bad = s.sum(start.Add(5*time.Minute), 5*time.Minute)
+	assert.Equal(t, uint64(2), total)
+	assert.Equal(t, uint64(1), bad)
+}
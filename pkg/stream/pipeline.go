@@ -0,0 +1,292 @@
+// Package stream runs stream processing pipelines: the messages of a source
+// subject go through filters and transforms to sinks publishing them,
+// handling them or storing them. A Pipeline is a manager.ServiceV2, so
+// simple enrichment services are a pipeline registered with the manager
+// instead of a Service of their own.
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"grouter/pkg/manager"
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+var _ manager.ServiceV2 = (*Pipeline)(nil)
+
+// FilterFunc reports whether a message goes on through the pipeline
+type FilterFunc func(ctx context.Context, env *messaging.MessageEnvelope) (bool, error)
+
+// TransformFunc returns the message going on through the pipeline in place
+// of env
+type TransformFunc func(ctx context.Context, env *messaging.MessageEnvelope) (*messaging.MessageEnvelope, error)
+
+// Sink consumes the messages reaching it. Sinks run in order, each with the
+// message of the stages before it.
+type Sink func(ctx context.Context, env *messaging.MessageEnvelope) error
+
+// Stage results of the stage metrics
+const (
+	resultPass  = "pass"
+	resultDrop  = "drop"
+	resultError = "error"
+)
+
+// stage is a step of a pipeline, returning nil for the messages it drops
+type stage struct {
+	name string
+	run  func(ctx context.Context, env *messaging.MessageEnvelope) (*messaging.MessageEnvelope, error)
+}
+
+// Pipeline is a stream processing pipeline. Define it with New and its
+// builder methods, then register it with the manager, or Init and Start it.
+//
+// Messages of a JetStream source (FromStream) are handled at least once: a
+// failing stage naks the message, which the stream delivers again, so the
+// stages of such pipelines must be idempotent. A core NATS source
+// (FromAtMostOnce) opts out of this: a failing stage loses the message.
+type Pipeline struct {
+	name       string
+	subject    string
+	durable    string
+	queueGroup string
+	workers    int
+	ordered    bool
+	stages     []stage
+	err        error
+
+	logger    *zap.Logger
+	messenger *messaging.Messenger
+	messages  *prometheus.CounterVec
+	duration  *prometheus.HistogramVec
+
+	// subs are the subscriptions of the running pipeline: the one of a core
+	// NATS source, or the pull consumers of a JetStream source
+	mu   sync.Mutex
+	subs []messaging.Subscription
+}
+
+// New returns the pipeline name, to be given a source, stages and sinks
+func New(name string) *Pipeline {
+	return &Pipeline{name: name, workers: 1}
+}
+
+// FromAtMostOnce reads the core NATS messages of subject, which may contain
+// wildcards. It opts out of the at-least-once delivery of FromStream: the
+// messages failing a stage, or received while the pipeline stops, are lost.
+func (p *Pipeline) FromAtMostOnce(subject string) *Pipeline {
+	p.subject, p.durable = subject, ""
+	return p
+}
+
+// FromStream reads the messages of subject with the JetStream pull consumer
+// durable, at least once. A stream must store subject.
+func (p *Pipeline) FromStream(subject, durable string) *Pipeline {
+	p.subject, p.durable = subject, durable
+	return p
+}
+
+// QueueGroup shares the messages of a core NATS source between the
+// instances of the pipeline. JetStream sources share their durable.
+func (p *Pipeline) QueueGroup(group string) *Pipeline {
+	p.queueGroup = group
+	return p
+}
+
+// Workers bounds the messages handled at once (default 1)
+func (p *Pipeline) Workers(n int) *Pipeline {
+	if n < 1 {
+		p.fail(fmt.Errorf("pipeline %s: workers must be at least 1, got %d", p.name, n))
+	}
+	p.workers = n
+	return p
+}
+
+// Ordered handles the messages of a partition key of a core NATS source in
+// order, see messaging.SubscribeOptions
+func (p *Pipeline) Ordered() *Pipeline {
+	p.ordered = true
+	return p
+}
+
+// Filter drops the messages fn rejects
+func (p *Pipeline) Filter(name string, fn FilterFunc) *Pipeline {
+	return p.add(name, func(ctx context.Context, env *messaging.MessageEnvelope) (*messaging.MessageEnvelope, error) {
+		ok, err := fn(ctx, env)
+		if err != nil || !ok {
+			return nil, err
+		}
+		return env, nil
+	})
+}
+
+// Transform replaces the messages with those fn returns, dropping them when
+// it returns nil
+func (p *Pipeline) Transform(name string, fn TransformFunc) *Pipeline {
+	return p.add(name, fn)
+}
+
+// To hands the messages to sink
+func (p *Pipeline) To(name string, sink Sink) *Pipeline {
+	return p.add(name, func(ctx context.Context, env *messaging.MessageEnvelope) (*messaging.MessageEnvelope, error) {
+		return env, sink(ctx, env)
+	})
+}
+
+// ToSubject publishes the messages on subject, with their type and data.
+// The published messages continue the correlation of the source messages.
+func (p *Pipeline) ToSubject(subject string) *Pipeline {
+	return p.To(subject, func(ctx context.Context, env *messaging.MessageEnvelope) error {
+		return p.messenger.Publisher.Publish(ctx, subject, env.Type, env.Data, nil)
+	})
+}
+
+func (p *Pipeline) add(name string, run func(ctx context.Context, env *messaging.MessageEnvelope) (*messaging.MessageEnvelope, error)) *Pipeline {
+	for _, s := range p.stages {
+		if s.name == name {
+			p.fail(fmt.Errorf("pipeline %s: duplicate stage %q", p.name, name))
+		}
+	}
+	p.stages = append(p.stages, stage{name: name, run: run})
+	return p
+}
+
+// fail records the first definition error, returned by Init
+func (p *Pipeline) fail(err error) {
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+// Name returns the name of the pipeline
+func (p *Pipeline) Name() string {
+	return p.name
+}
+
+// Init checks the definition of the pipeline and prepares its metrics in
+// deps.Metrics. The pipeline needs deps.Messenger.
+func (p *Pipeline) Init(ctx context.Context, deps manager.Deps) error {
+	switch {
+	case p.err != nil:
+		return p.err
+	case p.subject == "":
+		return fmt.Errorf("pipeline %s has no source", p.name)
+	case len(p.stages) == 0:
+		return fmt.Errorf("pipeline %s has no stages", p.name)
+	case deps.Messenger == nil:
+		return fmt.Errorf("pipeline %s needs messaging", p.name)
+	}
+
+	p.messenger = deps.Messenger
+	p.logger = deps.Logger
+	if p.logger == nil {
+		p.logger = zap.NewNop()
+	}
+	p.messages = deps.Metrics.CounterVec(prometheus.CounterOpts{
+		Name: "stream_stage_messages_total",
+		Help: "Total number of messages through the stages of the stream pipelines, by result",
+	}, []string{"pipeline", "stage", "result"})
+	p.duration = deps.Metrics.HistogramVec(prometheus.HistogramOpts{
+		Name:    "stream_stage_duration_seconds",
+		Help:    "Duration of the stages of the stream pipelines in seconds",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"pipeline", "stage"})
+	return nil
+}
+
+// Start subscribes the pipeline to its source
+func (p *Pipeline) Start(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.subs) > 0 {
+		return nil
+	}
+
+	subscriber := p.messenger.Subscriber
+	if p.durable == "" {
+		sub, err := subscriber.SubscribeSubject(p.subject, p.Handle, &messaging.SubscribeOptions{
+			QueueGroup: p.queueGroup,
+			MaxWorkers: p.workers,
+			Ordered:    p.ordered,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to start pipeline %s: %w", p.name, err)
+		}
+		p.subs = []messaging.Subscription{sub}
+	} else {
+		// Every pull consumer handles its batches one message at a time
+		for range p.workers {
+			sub, err := subscriber.SubscribePull(p.subject, p.durable, p.Handle, messaging.WithBatchSize(1))
+			if err != nil {
+				_ = p.unsubscribe()
+				return fmt.Errorf("failed to start pipeline %s: %w", p.name, err)
+			}
+			p.subs = append(p.subs, sub)
+		}
+	}
+
+	p.logger.Info("Stream pipeline started",
+		zap.String("pipeline", p.name),
+		zap.String("subject", p.subject),
+		zap.String("durable", p.durable),
+		zap.Int("workers", p.workers),
+	)
+	return nil
+}
+
+// Stop unsubscribes the pipeline from its source, leaving the other
+// subscriptions of the subject in place
+func (p *Pipeline) Stop(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.unsubscribe(); err != nil {
+		return fmt.Errorf("failed to stop pipeline %s: %w", p.name, err)
+	}
+	return nil
+}
+
+// unsubscribe removes the subscriptions of the pipeline. p.mu is held.
+func (p *Pipeline) unsubscribe() error {
+	var errs []error
+	for _, sub := range p.subs {
+		errs = append(errs, sub.Unsubscribe())
+	}
+	p.subs = nil
+	return errors.Join(errs...)
+}
+
+// Health reports whether the source of the pipeline is connected
+func (p *Pipeline) Health(ctx context.Context) error {
+	if !p.messenger.IsConnected() {
+		return errors.New("not connected to NATS")
+	}
+	return nil
+}
+
+// Handle runs the stages of the pipeline on env, stopping at the first
+// stage dropping it or failing
+func (p *Pipeline) Handle(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+	for _, s := range p.stages {
+		start := time.Now()
+		next, err := s.run(ctx, env)
+		p.duration.WithLabelValues(p.name, s.name).Observe(time.Since(start).Seconds())
+		switch {
+		case err != nil:
+			p.messages.WithLabelValues(p.name, s.name, resultError).Inc()
+			return fmt.Errorf("failed to run stage %s of pipeline %s: %w", s.name, p.name, err)
+		case next == nil:
+			p.messages.WithLabelValues(p.name, s.name, resultDrop).Inc()
+			return nil
+		}
+		p.messages.WithLabelValues(p.name, s.name, resultPass).Inc()
+		env = next
+	}
+	return nil
+}
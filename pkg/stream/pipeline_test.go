@@ -0,0 +1,194 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"grouter/pkg/manager"
+	messaging "grouter/pkg/messaging/nats"
+	"grouter/pkg/telemetry"
+	grtest "grouter/pkg/testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type order struct {
+	ID     string `json:"id"`
+	Amount int    `json:"amount"`
+}
+
+type enrichedOrder struct {
+	ID    string `json:"id"`
+	Large bool   `json:"large"`
+}
+
+func enrich(ctx context.Context, o order) (enrichedOrder, error) {
+	return enrichedOrder{ID: o.ID, Large: o.Amount >= 100}, nil
+}
+
+// startPipeline inits and starts p with m, stopping it at the end of the test
+func startPipeline(t *testing.T, p *Pipeline, m *messaging.Messenger) {
+	t.Helper()
+	deps := manager.Deps{Messenger: m, Logger: zap.NewNop(), Metrics: telemetry.NewMetricsRegistry()}
+	require.NoError(t, p.Init(context.Background(), deps))
+	require.NoError(t, p.Start(context.Background()))
+	t.Cleanup(func() { _ = p.Stop(context.Background()) })
+}
+
+func TestPipeline_Core(t *testing.T) {
+	s := grtest.RunNATSServer(t)
+	m := grtest.NewMessenger(t, s, "test-service")
+	nc := grtest.Connect(t, s)
+	out, err := nc.SubscribeSync("orders.enriched")
+	require.NoError(t, err)
+	require.NoError(t, nc.Flush())
+
+	p := New("enrich").
+		FromAtMostOnce("orders.created").
+		Filter("paid", Match(func(o order) bool { return o.Amount > 0 })).
+		Transform("enrich", Map("order.enriched", enrich)).
+		ToSubject("orders.enriched")
+	startPipeline(t, p, m)
+
+	ctx := context.Background()
+	require.NoError(t, m.Publisher.Publish(ctx, "orders.created", "order", order{ID: "1"}, nil))
+	require.NoError(t, m.Publisher.Publish(ctx, "orders.created", "order", order{ID: "2", Amount: 150}, nil))
+
+	msg, err := out.NextMsg(2 * time.Second)
+	require.NoError(t, err)
+	var env messaging.MessageEnvelope
+	require.NoError(t, json.Unmarshal(msg.Data, &env))
+	assert.Equal(t, "order.enriched", env.Type)
+	var got enrichedOrder
+	require.NoError(t, json.Unmarshal(env.Data, &got))
+	assert.Equal(t, enrichedOrder{ID: "2", Large: true}, got)
+
+	_, err = out.NextMsg(100 * time.Millisecond)
+	assert.ErrorIs(t, err, nats.ErrTimeout, "the filter drops the unpaid order")
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(p.messages.WithLabelValues("enrich", "paid", resultDrop)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(p.messages.WithLabelValues("enrich", "paid", resultPass)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(p.messages.WithLabelValues("enrich", "enrich", resultPass)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(p.messages.WithLabelValues("enrich", "orders.enriched", resultPass)))
+	assert.Equal(t, 3, testutil.CollectAndCount(p.duration))
+}
+
+func TestPipeline_AtLeastOnce(t *testing.T) {
+	s := grtest.RunNATSServer(t, grtest.WithJetStream())
+	m := grtest.NewMessenger(t, s, "test-service")
+	js, err := m.Client.JetStream()
+	require.NoError(t, err)
+	_, err = js.AddStream(&nats.StreamConfig{Name: "ORDERS", Subjects: []string{"orders.>"}, Storage: nats.MemoryStorage})
+	require.NoError(t, err)
+
+	var calls atomic.Int32
+	saved := make(chan string, 1)
+	p := New("store").
+		FromStream("orders.created", "store").
+		Workers(2).
+		To("save", func(ctx context.Context, env *messaging.MessageEnvelope) error {
+			if calls.Add(1) == 1 {
+				return errors.New("database unavailable")
+			}
+			saved <- env.ID
+			return nil
+		})
+	startPipeline(t, p, m)
+
+	_, err = m.Publisher.PublishJS(context.Background(), "orders.created", "order", order{ID: "1"})
+	require.NoError(t, err)
+
+	select {
+	case <-saved:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the failed message is not delivered again")
+	}
+	assert.Equal(t, int32(2), calls.Load())
+	assert.Equal(t, float64(1), testutil.ToFloat64(p.messages.WithLabelValues("store", "save", resultError)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(p.messages.WithLabelValues("store", "save", resultPass)))
+
+	require.NoError(t, p.Stop(context.Background()))
+	require.NoError(t, p.Start(context.Background()), "the pipeline restarts on its durable")
+	require.NoError(t, p.Health(context.Background()))
+}
+
+func TestPipeline_StopKeepsOtherSubscriptions(t *testing.T) {
+	s := grtest.RunNATSServer(t, grtest.WithJetStream())
+	m := grtest.NewMessenger(t, s, "test-service")
+	js, err := m.Client.JetStream()
+	require.NoError(t, err)
+	_, err = js.AddStream(&nats.StreamConfig{Name: "ORDERS", Subjects: []string{"orders.>"}, Storage: nats.MemoryStorage})
+	require.NoError(t, err)
+
+	received := make(chan string, 1)
+	require.NoError(t, m.Subscriber.Subscribe("orders.created", func(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+		received <- env.ID
+		return nil
+	}, nil))
+	sink := func(ctx context.Context, env *messaging.MessageEnvelope) error { return nil }
+	stopped := New("stopped").FromStream("orders.created", "stopped").To("sink", sink)
+	startPipeline(t, stopped, m)
+	kept := New("kept").FromStream("orders.created", "kept").To("sink", sink)
+	startPipeline(t, kept, m)
+	require.NoError(t, stopped.Stop(context.Background()))
+
+	require.NoError(t, m.Publisher.Publish(context.Background(), "orders.created", "order", order{ID: "1"}, nil))
+	select {
+	case <-received:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the subscription of another service was removed")
+	}
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(kept.messages.WithLabelValues("kept", "sink", resultPass)) == 1
+	}, 5*time.Second, 10*time.Millisecond, "the pipeline on another durable was stopped")
+}
+
+func TestPipeline_Handle(t *testing.T) {
+	p := New("enrich").
+		FromAtMostOnce("orders.created").
+		Transform("enrich", Map("order.enriched", enrich)).
+		To("fail", func(ctx context.Context, env *messaging.MessageEnvelope) error {
+			return errors.New("boom")
+		})
+	deps := manager.Deps{Messenger: &messaging.Messenger{}, Metrics: telemetry.NewMetricsRegistry()}
+	require.NoError(t, p.Init(context.Background(), deps))
+
+	env := grtest.NewEnvelope(t, "order", order{ID: "1"})
+	err := p.Handle(context.Background(), "orders.created", env)
+	assert.EqualError(t, err, "failed to run stage fail of pipeline enrich: boom")
+
+	env.Data = json.RawMessage(`"not an order"`)
+	assert.ErrorContains(t, p.Handle(context.Background(), "orders.created", env), "failed to run stage enrich")
+	assert.Equal(t, float64(1), testutil.ToFloat64(p.messages.WithLabelValues("enrich", "enrich", resultError)))
+}
+
+func TestPipeline_Init(t *testing.T) {
+	deps := manager.Deps{Messenger: &messaging.Messenger{}, Metrics: telemetry.NewMetricsRegistry()}
+	sink := func(ctx context.Context, env *messaging.MessageEnvelope) error { return nil }
+
+	tests := []struct {
+		name     string
+		pipeline *Pipeline
+		err      string
+	}{
+		{"no source", New("p").To("sink", sink), "pipeline p has no source"},
+		{"no stages", New("p").FromAtMostOnce("orders"), "pipeline p has no stages"},
+		{"workers", New("p").FromAtMostOnce("orders").Workers(0).To("sink", sink), "pipeline p: workers must be at least 1, got 0"},
+		{"duplicate stage", New("p").FromAtMostOnce("orders").To("sink", sink).To("sink", sink), `pipeline p: duplicate stage "sink"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.EqualError(t, tt.pipeline.Init(context.Background(), deps), tt.err)
+		})
+	}
+
+	assert.EqualError(t, New("p").FromAtMostOnce("orders").To("sink", sink).Init(context.Background(), manager.Deps{}), "pipeline p needs messaging")
+}
@@ -0,0 +1,62 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"grouter/pkg/database"
+	messaging "grouter/pkg/messaging/nats"
+)
+
+// Match returns a filter keeping the messages whose data, decoded into T,
+// fn accepts. Messages whose data is not a T fail the stage.
+func Match[T any](fn func(T) bool) FilterFunc {
+	return func(ctx context.Context, env *messaging.MessageEnvelope) (bool, error) {
+		var in T
+		if err := json.Unmarshal(env.Data, &in); err != nil {
+			return false, fmt.Errorf("failed to decode message: %w", err)
+		}
+		return fn(in), nil
+	}
+}
+
+// Map returns a transform replacing the data of the messages, decoded into
+// In, with the Out fn returns, as messages of type msgType. The metadata of
+// the messages is kept; an empty msgType keeps their type.
+func Map[In, Out any](msgType string, fn func(ctx context.Context, in In) (Out, error)) TransformFunc {
+	return func(ctx context.Context, env *messaging.MessageEnvelope) (*messaging.MessageEnvelope, error) {
+		var in In
+		if err := json.Unmarshal(env.Data, &in); err != nil {
+			return nil, fmt.Errorf("failed to decode message: %w", err)
+		}
+		out, err := fn(ctx, in)
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(out)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode message: %w", err)
+		}
+
+		next := *env
+		next.Data = data
+		if msgType != "" {
+			next.Type = msgType
+		}
+		return &next, nil
+	}
+}
+
+// ToRepository returns a sink saving the data of the messages, decoded into
+// T, with repo. Saving upserts by primary key, so that messages delivered
+// again are saved once.
+func ToRepository[T any](repo database.Repository[T]) Sink {
+	return func(ctx context.Context, env *messaging.MessageEnvelope) error {
+		entity := new(T)
+		if err := json.Unmarshal(env.Data, entity); err != nil {
+			return fmt.Errorf("failed to decode message: %w", err)
+		}
+		return repo.Update(ctx, entity)
+	}
+}
@@ -0,0 +1,68 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"grouter/pkg/config"
+	"grouter/pkg/database"
+	grtest "grouter/pkg/testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestMatch(t *testing.T) {
+	paid := Match(func(o order) bool { return o.Amount > 0 })
+
+	ok, err := paid(context.Background(), grtest.NewEnvelope(t, "order", order{Amount: 5}))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = paid(context.Background(), grtest.NewEnvelope(t, "order", order{}))
+	require.NoError(t, err)
+	assert.False(t, ok)
+	_, err = paid(context.Background(), grtest.NewEnvelope(t, "order", "not an order"))
+	assert.ErrorContains(t, err, "failed to decode message")
+}
+
+func TestMap(t *testing.T) {
+	env := grtest.NewEnvelope(t, "order", order{ID: "1", Amount: 100}, grtest.WithMetadata("tenant_id", "acme"))
+
+	next, err := Map("order.enriched", enrich)(context.Background(), env)
+	require.NoError(t, err)
+	assert.Equal(t, "order.enriched", next.Type)
+	assert.JSONEq(t, `{"id":"1","large":true}`, string(next.Data))
+	assert.Equal(t, env.ID, next.ID)
+	assert.Equal(t, "acme", next.Metadata["tenant_id"], "the metadata is kept")
+	assert.Equal(t, "order", env.Type, "the source message is left as is")
+
+	next, err = Map("", enrich)(context.Background(), env)
+	require.NoError(t, err)
+	assert.Equal(t, "order", next.Type)
+}
+
+type storedOrder struct {
+	ID     string `gorm:"primarykey" json:"id"`
+	Amount int    `json:"amount"`
+}
+
+func TestToRepository(t *testing.T) {
+	db, err := database.New(config.DatabaseConfig{Driver: "sqlite", DBName: ":memory:", LogLevel: "silent"}, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&storedOrder{}))
+	repo := database.NewRepository[storedOrder](db.DB)
+	sink := ToRepository(repo)
+
+	env := grtest.NewEnvelope(t, "order", order{ID: "1", Amount: 5})
+	require.NoError(t, sink(context.Background(), env))
+	require.NoError(t, sink(context.Background(), env), "saving a message delivered again upserts it")
+	env.Data = json.RawMessage(`{"id":"1","amount":7}`)
+	require.NoError(t, sink(context.Background(), env))
+
+	orders, _, err := repo.List(context.Background(), database.Pagination{Page: 1, PageSize: 10})
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+	assert.Equal(t, 7, orders[0].Amount)
+}
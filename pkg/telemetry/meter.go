@@ -0,0 +1,124 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"grouter/pkg/config"
+
+	promBridge "go.opentelemetry.io/contrib/bridges/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	otelprom "go.opentelemetry.io/otel/exporters/prometheus"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+// InitMeter initializes the OpenTelemetry meter provider. Two pipelines can
+// be combined:
+//   - pull: OTel instruments are added to reg and served on the Prometheus
+//     endpoint (cfg.OTelPrometheus)
+//   - push: metrics are exported over OTLP/gRPC or OTLP/HTTP (cfg.OTLP). With
+//     BridgePrometheus the collectors of reg are pushed as well.
+//
+// A nil reg uses the global Prometheus registry.
+func InitMeter(cfg config.MetricsConfig, serviceName string, reg *MetricsRegistry) (func(context.Context) error, error) {
+	noop := func(context.Context) error { return nil }
+	if !cfg.Enabled || (!cfg.OTLP.Enabled && !cfg.OTelPrometheus) {
+		return noop, nil
+	}
+
+	res, err := resource.New(
+		context.Background(),
+		resource.WithAttributes(
+			semconv.ServiceName(serviceName),
+		),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create resource: %w", err)
+	}
+	opts := []sdkmetric.Option{sdkmetric.WithResource(res)}
+
+	if cfg.OTelPrometheus {
+		exporter, err := otelprom.New(otelprom.WithRegisterer(reg.Registerer()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create prometheus metric exporter: %w", err)
+		}
+		opts = append(opts, sdkmetric.WithReader(exporter))
+	}
+
+	if cfg.OTLP.Enabled {
+		exporter, err := newOTLPMetricExporter(cfg.OTLP)
+		if err != nil {
+			return nil, err
+		}
+		interval := cfg.OTLP.Interval
+		if interval <= 0 {
+			interval = time.Minute
+		}
+		readerOpts := []sdkmetric.PeriodicReaderOption{sdkmetric.WithInterval(interval)}
+		if cfg.OTLP.BridgePrometheus {
+			readerOpts = append(readerOpts, sdkmetric.WithProducer(
+				promBridge.NewMetricProducer(promBridge.WithGatherer(reg.Gatherer())),
+			))
+		}
+		opts = append(opts, sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter, readerOpts...)))
+	}
+
+	mp := sdkmetric.NewMeterProvider(opts...)
+	otel.SetMeterProvider(mp)
+	return mp.Shutdown, nil
+}
+
+// newOTLPMetricExporter creates the OTLP exporter of the configured protocol.
+// Endpoints with a scheme ("http://localhost:4318") are used as URLs, others
+// as host:port.
+func newOTLPMetricExporter(cfg config.MetricsOTLPConfig) (sdkmetric.Exporter, error) {
+	ctx := context.Background()
+	withURL := strings.Contains(cfg.Endpoint, "://")
+
+	var (
+		exporter sdkmetric.Exporter
+		err      error
+	)
+	switch cfg.Protocol {
+	case "", "http":
+		var opts []otlpmetrichttp.Option
+		if cfg.Endpoint != "" && withURL {
+			opts = append(opts, otlpmetrichttp.WithEndpointURL(cfg.Endpoint))
+		} else if cfg.Endpoint != "" {
+			opts = append(opts, otlpmetrichttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlpmetrichttp.WithInsecure())
+		}
+		if len(cfg.Headers) > 0 {
+			opts = append(opts, otlpmetrichttp.WithHeaders(cfg.Headers))
+		}
+		exporter, err = otlpmetrichttp.New(ctx, opts...)
+	case "grpc":
+		var opts []otlpmetricgrpc.Option
+		if cfg.Endpoint != "" && withURL {
+			opts = append(opts, otlpmetricgrpc.WithEndpointURL(cfg.Endpoint))
+		} else if cfg.Endpoint != "" {
+			opts = append(opts, otlpmetricgrpc.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlpmetricgrpc.WithInsecure())
+		}
+		if len(cfg.Headers) > 0 {
+			opts = append(opts, otlpmetricgrpc.WithHeaders(cfg.Headers))
+		}
+		exporter, err = otlpmetricgrpc.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unknown OTLP metrics protocol: %s", cfg.Protocol)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP metric exporter: %w", err)
+	}
+	return exporter, nil
+}
@@ -0,0 +1,78 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"grouter/pkg/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+)
+
+func TestInitMeter_Disabled(t *testing.T) {
+	shutdown, err := InitMeter(config.MetricsConfig{Enabled: true}, "test-service", NewMetricsRegistry())
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+}
+
+func TestInitMeter_PrometheusPull(t *testing.T) {
+	reg := NewMetricsRegistry()
+	shutdown, err := InitMeter(config.MetricsConfig{Enabled: true, OTelPrometheus: true}, "test-service", reg)
+	require.NoError(t, err)
+	defer shutdown(context.Background())
+
+	counter, err := otel.Meter("test").Int64Counter("jobs_done")
+	require.NoError(t, err)
+	counter.Add(context.Background(), 3)
+
+	count, err := testutil.GatherAndCount(reg.Gatherer(), "jobs_done_total")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestInitMeter_OTLPPush(t *testing.T) {
+	var exports atomic.Int32
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/metrics" {
+			exports.Add(1)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer collector.Close()
+
+	reg := NewMetricsRegistry()
+	reg.CounterVec(prometheus.CounterOpts{Name: "bridged_total", Help: "Bridged"}, nil).WithLabelValues().Inc()
+
+	shutdown, err := InitMeter(config.MetricsConfig{
+		Enabled: true,
+		OTLP: config.MetricsOTLPConfig{
+			Enabled:          true,
+			Protocol:         "http",
+			Endpoint:         collector.URL,
+			Insecure:         true,
+			Interval:         time.Hour,
+			BridgePrometheus: true,
+		},
+	}, "test-service", reg)
+	require.NoError(t, err)
+
+	// Shutdown flushes the pending export
+	require.NoError(t, shutdown(context.Background()))
+	assert.GreaterOrEqual(t, exports.Load(), int32(1))
+}
+
+func TestInitMeter_UnknownProtocol(t *testing.T) {
+	_, err := InitMeter(config.MetricsConfig{
+		Enabled: true,
+		OTLP:    config.MetricsOTLPConfig{Enabled: true, Protocol: "udp"},
+	}, "test-service", nil)
+	assert.Error(t, err)
+}
@@ -5,50 +5,44 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-var (
-	httpRequestsTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
+// httpMetrics are the request metrics recorded by Middleware
+type httpMetrics struct {
+	requestsTotal   *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	activeRequests  *prometheus.GaugeVec
+}
+
+func newHTTPMetrics(reg *MetricsRegistry) *httpMetrics {
+	return &httpMetrics{
+		requestsTotal: reg.CounterVec(prometheus.CounterOpts{
 			Name: "http_requests_total",
 			Help: "Total number of HTTP requests processed",
-		},
-		[]string{"service", "method", "path", "status"},
-	)
-
-	httpRequestDuration = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
+		}, []string{"service", "method", "path", "status"}),
+		requestDuration: reg.HistogramVec(prometheus.HistogramOpts{
 			Name:    "http_request_duration_seconds",
 			Help:    "Duration of HTTP requests in seconds",
 			Buckets: prometheus.DefBuckets,
-		},
-		[]string{"service", "method", "path", "status"},
-	)
-
-	httpActiveRequests = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
+		}, []string{"service", "method", "path", "status"}),
+		activeRequests: reg.GaugeVec(prometheus.GaugeOpts{
 			Name: "http_active_requests",
 			Help: "Number of currently active HTTP requests",
-		},
-		[]string{"service"},
-	)
-)
+		}, []string{"service"}),
+	}
+}
 
-// InitMetrics registers the standard metrics with Prometheus
+// InitMetrics registers the standard metrics with the global Prometheus
+// registry. Calling it again is a no-op.
 func InitMetrics(cfg config.MetricsConfig) {
 	if !cfg.Enabled {
 		return
 	}
-	prometheus.MustRegister(httpRequestsTotal)
-	prometheus.MustRegister(httpRequestDuration)
-	prometheus.MustRegister(httpActiveRequests)
+	newHTTPMetrics(nil)
 }
 
-// PrometheusHandler returns a Gin handler for the metrics endpoint
-func PrometheusHandler() gin.HandlerFunc {
-	h := promhttp.Handler()
-	return func(c *gin.Context) {
-		h.ServeHTTP(c.Writer, c.Request)
-	}
+// PrometheusHandler returns a Gin handler for the metrics endpoint of reg
+// (nil serves the global registry)
+func PrometheusHandler(reg *MetricsRegistry) gin.HandlerFunc {
+	return gin.WrapH(reg.Handler())
 }
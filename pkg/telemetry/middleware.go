@@ -11,9 +11,11 @@ import (
 	"go.opentelemetry.io/otel/trace"
 )
 
-// Middleware returns a Gin middleware that handles both Tracing and Metrics
-func Middleware(serviceName string) gin.HandlerFunc {
+// Middleware returns a Gin middleware that handles both Tracing and Metrics.
+// Metrics are recorded in reg (nil uses the global registry).
+func Middleware(serviceName string, reg *MetricsRegistry) gin.HandlerFunc {
 	tracer := otel.Tracer("grouter/pkg/telemetry")
+	metrics := newHTTPMetrics(reg)
 
 	return func(c *gin.Context) {
 		start := time.Now()
@@ -35,8 +37,8 @@ func Middleware(serviceName string) gin.HandlerFunc {
 		c.Request = c.Request.WithContext(ctx)
 
 		// 2. Metrics: Increment active requests
-		httpActiveRequests.WithLabelValues(serviceName).Inc()
-		defer httpActiveRequests.WithLabelValues(serviceName).Dec()
+		metrics.activeRequests.WithLabelValues(serviceName).Inc()
+		defer metrics.activeRequests.WithLabelValues(serviceName).Dec()
 
 		// Process request
 		c.Next()
@@ -45,8 +47,8 @@ func Middleware(serviceName string) gin.HandlerFunc {
 		status := strconv.Itoa(c.Writer.Status())
 		duration := time.Since(start).Seconds()
 
-		httpRequestsTotal.WithLabelValues(serviceName, c.Request.Method, path, status).Inc()
-		httpRequestDuration.WithLabelValues(serviceName, c.Request.Method, path, status).Observe(duration)
+		metrics.requestsTotal.WithLabelValues(serviceName, c.Request.Method, path, status).Inc()
+		metrics.requestDuration.WithLabelValues(serviceName, c.Request.Method, path, status).Observe(duration)
 
 		// 4. Tracing: Update span with status
 		span.SetAttributes(semconv.HTTPStatusCode(c.Writer.Status()))
@@ -0,0 +1,118 @@
+package telemetry
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// MetricsRegistry is the Prometheus registry shared by the framework
+// packages. Registration is idempotent: registering a metric that already
+// exists returns the existing collector, so components can be created again
+// (engine resets, reconnects, restarts in tests) without panicking.
+//
+// A nil *MetricsRegistry uses the global Prometheus registry.
+type MetricsRegistry struct {
+	registerer prometheus.Registerer
+	gatherer   prometheus.Gatherer
+}
+
+// NewMetricsRegistry creates a registry with the Go runtime and process
+// collectors. Besides the default memory stats, the runtime collector exports
+// the GC pause and scheduler latency histograms (go_gc_*, go_sched_*).
+func NewMetricsRegistry() *MetricsRegistry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(collectors.WithGoCollectorRuntimeMetrics(
+			collectors.MetricsGC,
+			collectors.MetricsScheduler,
+		)),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return &MetricsRegistry{registerer: reg, gatherer: reg}
+}
+
+// DefaultMetricsRegistry returns a registry backed by the global Prometheus
+// registry
+func DefaultMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{registerer: prometheus.DefaultRegisterer, gatherer: prometheus.DefaultGatherer}
+}
+
+func (r *MetricsRegistry) orDefault() *MetricsRegistry {
+	if r == nil {
+		return DefaultMetricsRegistry()
+	}
+	return r
+}
+
+// Registerer returns the underlying Prometheus registerer
+func (r *MetricsRegistry) Registerer() prometheus.Registerer {
+	return r.orDefault().registerer
+}
+
+// Gatherer returns the underlying Prometheus gatherer
+func (r *MetricsRegistry) Gatherer() prometheus.Gatherer {
+	return r.orDefault().gatherer
+}
+
+// Register registers c and returns the collector to use: c, or the collector
+// registered before under the same descriptors
+func (r *MetricsRegistry) Register(c prometheus.Collector) (prometheus.Collector, error) {
+	err := r.Registerer().Register(c)
+	if err == nil {
+		return c, nil
+	}
+	var are prometheus.AlreadyRegisteredError
+	if errors.As(err, &are) {
+		return are.ExistingCollector, nil
+	}
+	return nil, fmt.Errorf("failed to register metric: %w", err)
+}
+
+// MustRegister is Register, panicking on conflicting definitions
+func (r *MetricsRegistry) MustRegister(c prometheus.Collector) prometheus.Collector {
+	existing, err := r.Register(c)
+	if err != nil {
+		panic(err)
+	}
+	return existing
+}
+
+// CounterVec returns the counter vector with these options, creating it on
+// first use
+func (r *MetricsRegistry) CounterVec(opts prometheus.CounterOpts, labels []string) *prometheus.CounterVec {
+	return mustCollector[*prometheus.CounterVec](r, prometheus.NewCounterVec(opts, labels))
+}
+
+// HistogramVec returns the histogram vector with these options, creating it
+// on first use
+func (r *MetricsRegistry) HistogramVec(opts prometheus.HistogramOpts, labels []string) *prometheus.HistogramVec {
+	return mustCollector[*prometheus.HistogramVec](r, prometheus.NewHistogramVec(opts, labels))
+}
+
+// GaugeVec returns the gauge vector with these options, creating it on first
+// use
+func (r *MetricsRegistry) GaugeVec(opts prometheus.GaugeOpts, labels []string) *prometheus.GaugeVec {
+	return mustCollector[*prometheus.GaugeVec](r, prometheus.NewGaugeVec(opts, labels))
+}
+
+func mustCollector[T prometheus.Collector](r *MetricsRegistry, c T) T {
+	existing := r.MustRegister(c)
+	typed, ok := existing.(T)
+	if !ok {
+		panic(fmt.Sprintf("metric registered with a different type: %T", existing))
+	}
+	return typed
+}
+
+// Handler serves the registry in the Prometheus exposition format, or in
+// OpenMetrics with the exemplars of the histograms when the scraper accepts it
+func (r *MetricsRegistry) Handler() http.Handler {
+	r = r.orDefault()
+	return promhttp.InstrumentMetricHandler(r.registerer,
+		promhttp.HandlerFor(r.gatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}))
+}
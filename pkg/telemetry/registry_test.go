@@ -0,0 +1,59 @@
+package telemetry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsRegistry_DuplicateRegistration(t *testing.T) {
+	reg := NewMetricsRegistry()
+	opts := prometheus.CounterOpts{Name: "jobs_total", Help: "Jobs"}
+
+	first := reg.CounterVec(opts, []string{"queue"})
+	second := reg.CounterVec(opts, []string{"queue"})
+	assert.Same(t, first, second)
+
+	first.WithLabelValues("a").Inc()
+	second.WithLabelValues("a").Inc()
+	assert.Equal(t, float64(2), testutil.ToFloat64(first.WithLabelValues("a")))
+
+	// Same name with other labels is a conflicting definition
+	assert.Panics(t, func() { reg.CounterVec(opts, []string{"other"}) })
+}
+
+func TestMetricsRegistry_Isolated(t *testing.T) {
+	a, b := NewMetricsRegistry(), NewMetricsRegistry()
+	a.CounterVec(prometheus.CounterOpts{Name: "only_in_a_total", Help: "A"}, nil).WithLabelValues().Inc()
+
+	count, err := testutil.GatherAndCount(b.Gatherer(), "only_in_a_total")
+	require.NoError(t, err)
+	assert.Zero(t, count)
+	count, err = testutil.GatherAndCount(a.Gatherer(), "only_in_a_total")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestMetricsRegistry_Handler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	reg := NewMetricsRegistry()
+	r := gin.New()
+	// Middleware can be installed again, e.g. when an engine is rebuilt
+	r.Use(Middleware("svc", reg), Middleware("svc", reg))
+	r.GET("/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+	r.GET("/metrics", PrometheusHandler(reg))
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ping", nil))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `http_requests_total{method="GET",path="/ping",service="svc",status="200"} 2`)
+	assert.Contains(t, w.Body.String(), "go_goroutines")
+}
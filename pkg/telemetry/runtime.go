@@ -0,0 +1,53 @@
+package telemetry
+
+import (
+	"runtime"
+	"runtime/debug"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Commit is the VCS revision of the build. Set it at link time
+// (-ldflags "-X grouter/pkg/telemetry.Commit=$(git rev-parse HEAD)"); when
+// empty the revision recorded by the Go toolchain is used.
+var Commit string
+
+// BuildInfo identifies the running binary
+type BuildInfo struct {
+	Version   string
+	Commit    string
+	GoVersion string
+}
+
+// ReadBuildInfo returns the build info of the binary with the given
+// application version
+func ReadBuildInfo(version string) BuildInfo {
+	info := BuildInfo{
+		Version:   version,
+		Commit:    Commit,
+		GoVersion: runtime.Version(),
+	}
+	if info.Commit == "" {
+		if bi, ok := debug.ReadBuildInfo(); ok {
+			for _, s := range bi.Settings {
+				if s.Key == "vcs.revision" {
+					info.Commit = s.Value
+				}
+			}
+		}
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	return info
+}
+
+// RegisterBuildInfo exposes the build info as the constant gauge
+// grouter_build_info{service,version,commit,go_version} = 1
+func RegisterBuildInfo(reg *MetricsRegistry, service string, info BuildInfo) {
+	reg.GaugeVec(prometheus.GaugeOpts{
+		Name: "grouter_build_info",
+		Help: "Build information of the running binary, always 1",
+	}, []string{"service", "version", "commit", "go_version"}).
+		WithLabelValues(service, info.Version, info.Commit, info.GoVersion).Set(1)
+}
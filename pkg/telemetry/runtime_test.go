@@ -0,0 +1,44 @@
+package telemetry
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadBuildInfo(t *testing.T) {
+	info := ReadBuildInfo("1.2.3")
+	assert.Equal(t, "1.2.3", info.Version)
+	assert.Equal(t, runtime.Version(), info.GoVersion)
+	assert.NotEmpty(t, info.Commit)
+
+	Commit = "abc123"
+	defer func() { Commit = "" }()
+	assert.Equal(t, "abc123", ReadBuildInfo("1.2.3").Commit)
+}
+
+func TestRegisterBuildInfo(t *testing.T) {
+	reg := NewMetricsRegistry()
+	info := BuildInfo{Version: "1.2.3", Commit: "abc123", GoVersion: "go1.24"}
+	RegisterBuildInfo(reg, "grouter", info)
+	// Registering again (manager re-init) keeps a single series
+	RegisterBuildInfo(reg, "grouter", info)
+
+	expected := `
+# HELP grouter_build_info Build information of the running binary, always 1
+# TYPE grouter_build_info gauge
+grouter_build_info{commit="abc123",go_version="go1.24",service="grouter",version="1.2.3"} 1
+`
+	assert.NoError(t, testutil.GatherAndCompare(reg.Gatherer(), strings.NewReader(expected), "grouter_build_info"))
+}
+
+func TestNewMetricsRegistry_RuntimeMetrics(t *testing.T) {
+	count, err := testutil.GatherAndCount(NewMetricsRegistry().Gatherer(),
+		"go_goroutines", "go_gc_pauses_seconds", "go_sched_latencies_seconds")
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+}
@@ -2,20 +2,30 @@ package telemetry
 
 import (
 	"context"
+	"errors"
 
 	"grouter/pkg/config"
 )
 
-// Init initializes both Tracing and Metrics systems
+// Init initializes both Tracing and Metrics systems. OpenTelemetry metrics
+// use the global Prometheus registry; see InitMeter for a custom one.
 func Init(cfg config.Config) (func(context.Context) error, error) {
 	// 1. Init Metrics
 	InitMetrics(cfg.Metrics)
 
+	meterShutdown, err := InitMeter(cfg.Metrics, cfg.Tracing.ServiceName, nil)
+	if err != nil {
+		return nil, err
+	}
+
 	// 2. Init Tracer
-	shutdown, err := InitTracer(cfg.Tracing)
+	tracerShutdown, err := InitTracer(cfg.Tracing)
 	if err != nil {
+		_ = meterShutdown(context.Background())
 		return nil, err
 	}
 
-	return shutdown, nil
+	return func(ctx context.Context) error {
+		return errors.Join(tracerShutdown(ctx), meterShutdown(ctx))
+	}, nil
 }
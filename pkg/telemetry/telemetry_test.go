@@ -34,7 +34,7 @@ func TestTelemetry(t *testing.T) {
 
 	// 2. Setup Router with Middleware
 	r := gin.New()
-	r.Use(Middleware("test-service"))
+	r.Use(Middleware("test-service", nil))
 	r.GET("/ping", func(c *gin.Context) {
 		time.Sleep(10 * time.Millisecond) // Simulate work
 		c.String(200, "pong")
@@ -86,7 +86,7 @@ func TestTelemetry(t *testing.T) {
 	wMetrics := httptest.NewRecorder()
 
 	// Create a separate handler for metrics to avoid interference with the router used above
-	metricsHandler := PrometheusHandler()
+	metricsHandler := PrometheusHandler(nil)
 	metricsContext, _ := gin.CreateTestContext(wMetrics)
 	metricsContext.Request = reqMetrics
 
@@ -3,12 +3,17 @@ package telemetry
 import (
 	"context"
 	"fmt"
+	"os"
+	"strings"
 
 	"grouter/pkg/config"
 
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
 	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/exporters/zipkin"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
@@ -21,52 +26,29 @@ func InitTracer(cfg config.TracingConfig) (func(context.Context) error, error) {
 		return func(context.Context) error { return nil }, nil
 	}
 
-	var exporter sdktrace.SpanExporter
-	var err error
-
-	switch cfg.Exporter {
-	case "stdout":
-		exporter, err = stdouttrace.New(
-			stdouttrace.WithPrettyPrint(),
-		)
-	case "otlp", "jaeger":
-		// Use OTLP HTTP exporter
-		// We use WithInsecure() for local development/Jaeger
-		opts := []otlptracehttp.Option{
-			otlptracehttp.WithInsecure(),
-		}
-		if cfg.Endpoint != "" {
-			// Endpoint should be like "http://localhost:4318"
-			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
-		}
-		exporter, err = otlptracehttp.New(context.Background(), opts...)
-	default:
-		// Default to no-op if unknown or empty, strictly speaking we could error but
-		// for now let's just default to stdout or return error
-		if cfg.Exporter != "" {
-			return nil, fmt.Errorf("unknown exporter: %s", cfg.Exporter)
-		}
-		// logic for other exporters (jaeger/otlp) can be added here
+	exporter, err := newSpanExporter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if exporter == nil {
+		// No exporter configured
 		return func(context.Context) error { return nil }, nil
 	}
 
+	sampler, err := newSampler(cfg.Sampler)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create exporter: %w", err)
+		return nil, err
 	}
 
-	res, err := resource.New(
-		context.Background(),
-		resource.WithAttributes(
-			semconv.ServiceName(cfg.ServiceName),
-		),
-	)
+	res, err := newTraceResource(cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create resource: %w", err)
 	}
 
 	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exporter),
+		sdktrace.WithBatcher(exporter, batchOptions(cfg.Batch)...),
 		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sampler),
 	)
 
 	// Set global provider
@@ -80,3 +62,142 @@ func InitTracer(cfg config.TracingConfig) (func(context.Context) error, error) {
 
 	return tp.Shutdown, nil
 }
+
+// newSpanExporter creates the configured exporter, or nil when none is set
+func newSpanExporter(cfg config.TracingConfig) (sdktrace.SpanExporter, error) {
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+	// Endpoints with a scheme are URLs (the scheme selects TLS), others are
+	// host:port. The default local collector is plaintext.
+	withURL := strings.Contains(cfg.Endpoint, "://")
+	insecure := cfg.Insecure || cfg.Endpoint == ""
+
+	switch cfg.Exporter {
+	case "stdout":
+		exporter, err = stdouttrace.New(
+			stdouttrace.WithPrettyPrint(),
+		)
+	case "otlp", "otlp-http", "jaeger":
+		// Jaeger accepts OTLP natively
+		var opts []otlptracehttp.Option
+		if withURL {
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+		} else if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+		if insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		if len(cfg.Headers) > 0 {
+			opts = append(opts, otlptracehttp.WithHeaders(cfg.Headers))
+		}
+		exporter, err = otlptracehttp.New(context.Background(), opts...)
+	case "otlp-grpc":
+		var opts []otlptracegrpc.Option
+		if withURL {
+			opts = append(opts, otlptracegrpc.WithEndpointURL(cfg.Endpoint))
+		} else if cfg.Endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(cfg.Endpoint))
+		}
+		if insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		if len(cfg.Headers) > 0 {
+			opts = append(opts, otlptracegrpc.WithHeaders(cfg.Headers))
+		}
+		exporter, err = otlptracegrpc.New(context.Background(), opts...)
+	case "zipkin":
+		endpoint := cfg.Endpoint
+		if endpoint == "" {
+			endpoint = "http://localhost:9411/api/v2/spans"
+		}
+		var opts []zipkin.Option
+		if len(cfg.Headers) > 0 {
+			opts = append(opts, zipkin.WithHeaders(cfg.Headers))
+		}
+		exporter, err = zipkin.New(endpoint, opts...)
+	case "":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown exporter: %s", cfg.Exporter)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exporter: %w", err)
+	}
+	return exporter, nil
+}
+
+// newSampler returns the sampler of the configuration. The default samples
+// every root span and follows the parent's decision otherwise.
+func newSampler(cfg config.TracingSamplerConfig) (sdktrace.Sampler, error) {
+	if cfg.Type == "ratio" || cfg.Type == "parent" {
+		if cfg.Ratio < 0 || cfg.Ratio > 1 {
+			return nil, fmt.Errorf("sampler ratio must be between 0 and 1, got %v", cfg.Ratio)
+		}
+	}
+	switch cfg.Type {
+	case "always":
+		return sdktrace.AlwaysSample(), nil
+	case "never":
+		return sdktrace.NeverSample(), nil
+	case "ratio":
+		return sdktrace.TraceIDRatioBased(cfg.Ratio), nil
+	case "", "parent":
+		root := sdktrace.AlwaysSample()
+		if cfg.Type == "parent" {
+			root = sdktrace.TraceIDRatioBased(cfg.Ratio)
+		}
+		return sdktrace.ParentBased(root), nil
+	default:
+		return nil, fmt.Errorf("unknown sampler: %s", cfg.Type)
+	}
+}
+
+// newTraceResource describes the service: name, environment, version,
+// instance ID (default the hostname) and custom attributes
+func newTraceResource(cfg config.TracingConfig) (*resource.Resource, error) {
+	attrs := []attribute.KeyValue{semconv.ServiceName(cfg.ServiceName)}
+	if cfg.Resource.Environment != "" {
+		attrs = append(attrs, semconv.DeploymentEnvironment(cfg.Resource.Environment))
+	}
+	if cfg.Resource.Version != "" {
+		attrs = append(attrs, semconv.ServiceVersion(cfg.Resource.Version))
+	}
+	instanceID := cfg.Resource.InstanceID
+	if instanceID == "" {
+		instanceID, _ = os.Hostname()
+	}
+	if instanceID != "" {
+		attrs = append(attrs, semconv.ServiceInstanceID(instanceID))
+	}
+	for k, v := range cfg.Resource.Attributes {
+		attrs = append(attrs, attribute.String(k, v))
+	}
+
+	return resource.New(
+		context.Background(),
+		resource.WithAttributes(attrs...),
+	)
+}
+
+// batchOptions tunes the batch span processor; zero values keep the SDK
+// defaults
+func batchOptions(cfg config.TracingBatchConfig) []sdktrace.BatchSpanProcessorOption {
+	var opts []sdktrace.BatchSpanProcessorOption
+	if cfg.MaxQueueSize > 0 {
+		opts = append(opts, sdktrace.WithMaxQueueSize(cfg.MaxQueueSize))
+	}
+	if cfg.MaxExportBatchSize > 0 {
+		opts = append(opts, sdktrace.WithMaxExportBatchSize(cfg.MaxExportBatchSize))
+	}
+	if cfg.BatchTimeout > 0 {
+		opts = append(opts, sdktrace.WithBatchTimeout(cfg.BatchTimeout))
+	}
+	if cfg.ExportTimeout > 0 {
+		opts = append(opts, sdktrace.WithExportTimeout(cfg.ExportTimeout))
+	}
+	return opts
+}
@@ -3,10 +3,13 @@ package telemetry
 import (
 	"context"
 	"testing"
+	"time"
 
 	"grouter/pkg/config"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
 )
 
 func TestInitTracer(t *testing.T) {
@@ -40,6 +43,47 @@ func TestInitTracer(t *testing.T) {
 			},
 			expectErr: true,
 		},
+		{
+			name: "Enabled with OTLP gRPC exporter",
+			cfg: config.TracingConfig{
+				Enabled:     true,
+				ServiceName: "test-service",
+				Exporter:    "otlp-grpc",
+				Endpoint:    "localhost:4317",
+				Insecure:    true,
+			},
+			expectErr: false,
+		},
+		{
+			name: "Enabled with OTLP HTTP exporter",
+			cfg: config.TracingConfig{
+				Enabled:     true,
+				ServiceName: "test-service",
+				Exporter:    "otlp-http",
+				Endpoint:    "http://localhost:4318",
+				Headers:     map[string]string{"Authorization": "Bearer token"},
+			},
+			expectErr: false,
+		},
+		{
+			name: "Enabled with zipkin exporter",
+			cfg: config.TracingConfig{
+				Enabled:     true,
+				ServiceName: "test-service",
+				Exporter:    "zipkin",
+			},
+			expectErr: false,
+		},
+		{
+			name: "Enabled with unknown sampler",
+			cfg: config.TracingConfig{
+				Enabled:     true,
+				ServiceName: "test-service",
+				Exporter:    "stdout",
+				Sampler:     config.TracingSamplerConfig{Type: "sometimes"},
+			},
+			expectErr: true,
+		},
 		{
 			name: "Enabled with empty exporter (defaults to no-op or stdout logic depending on implementation)",
 			cfg: config.TracingConfig{
@@ -67,3 +111,65 @@ func TestInitTracer(t *testing.T) {
 		})
 	}
 }
+
+func TestNewSampler(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       config.TracingSamplerConfig
+		expected  string
+		expectErr bool
+	}{
+		{name: "Default", cfg: config.TracingSamplerConfig{}, expected: "ParentBased{root:AlwaysOnSampler"},
+		{name: "Always", cfg: config.TracingSamplerConfig{Type: "always"}, expected: "AlwaysOnSampler"},
+		{name: "Never", cfg: config.TracingSamplerConfig{Type: "never"}, expected: "AlwaysOffSampler"},
+		{name: "Ratio", cfg: config.TracingSamplerConfig{Type: "ratio", Ratio: 0.25}, expected: "TraceIDRatioBased{0.25}"},
+		{name: "Parent ratio", cfg: config.TracingSamplerConfig{Type: "parent", Ratio: 0.5}, expected: "ParentBased{root:TraceIDRatioBased{0.5}"},
+		{name: "Ratio out of range", cfg: config.TracingSamplerConfig{Type: "ratio", Ratio: 1.5}, expectErr: true},
+		{name: "Unknown", cfg: config.TracingSamplerConfig{Type: "sometimes"}, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sampler, err := newSampler(tt.cfg)
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Contains(t, sampler.Description(), tt.expected)
+		})
+	}
+}
+
+func TestNewTraceResource(t *testing.T) {
+	res, err := newTraceResource(config.TracingConfig{
+		ServiceName: "test-service",
+		Resource: config.TracingResourceConfig{
+			Environment: "staging",
+			Version:     "1.2.3",
+			InstanceID:  "pod-1",
+			Attributes:  map[string]string{"team": "platform"},
+		},
+	})
+	require.NoError(t, err)
+
+	attrs := map[string]string{}
+	for _, kv := range res.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	assert.Equal(t, "test-service", attrs[string(semconv.ServiceNameKey)])
+	assert.Equal(t, "staging", attrs[string(semconv.DeploymentEnvironmentKey)])
+	assert.Equal(t, "1.2.3", attrs[string(semconv.ServiceVersionKey)])
+	assert.Equal(t, "pod-1", attrs[string(semconv.ServiceInstanceIDKey)])
+	assert.Equal(t, "platform", attrs["team"])
+}
+
+func TestBatchOptions(t *testing.T) {
+	assert.Empty(t, batchOptions(config.TracingBatchConfig{}))
+	assert.Len(t, batchOptions(config.TracingBatchConfig{
+		MaxQueueSize:       4096,
+		MaxExportBatchSize: 1024,
+		BatchTimeout:       time.Second,
+		ExportTimeout:      10 * time.Second,
+	}), 4)
+}
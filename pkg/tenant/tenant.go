@@ -0,0 +1,67 @@
+// Package tenant carries the tenant of a request or message through contexts
+// and NATS subjects of the form <prefix>.<tenant>.<service>.<op>
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"grouter/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// ErrInvalidTenant is returned for a tenant ID that is not a subject token
+var ErrInvalidTenant = errors.New("invalid tenant")
+
+type contextKey struct{}
+
+// NewContext returns ctx carrying the tenant id. The context logger (see
+// logger.FromContext) gets a tenant_id field. An empty id returns ctx.
+func NewContext(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, contextKey{}, id)
+	return logger.WithContext(ctx, logger.FromContext(ctx).With(zap.String("tenant_id", id)))
+}
+
+// FromContext returns the tenant of ctx, or ""
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Validate checks that id can be a NATS subject token
+func Validate(id string) error {
+	if id == "" {
+		return fmt.Errorf("%w: empty", ErrInvalidTenant)
+	}
+	if strings.ContainsAny(id, ".*> \t\r\n") {
+		return fmt.Errorf("%w: %q contains '.', '*', '>' or whitespace", ErrInvalidTenant, id)
+	}
+	return nil
+}
+
+// Subject returns the subject of topic (<service>.<op>) for the tenant id
+func Subject(prefix, id, topic string) string {
+	return prefix + "." + id + "." + topic
+}
+
+// ParseSubject splits a subject of the form <prefix>.<tenant>.<topic>
+func ParseSubject(prefix, subject string) (id, topic string, err error) {
+	rest, ok := strings.CutPrefix(subject, prefix+".")
+	if !ok {
+		return "", "", fmt.Errorf("subject %q is not under %q", subject, prefix)
+	}
+	id, topic, ok = strings.Cut(rest, ".")
+	if !ok || topic == "" {
+		return "", "", fmt.Errorf("subject %q has no tenant and topic", subject)
+	}
+	if err := Validate(id); err != nil {
+		return "", "", err
+	}
+	return id, topic, nil
+}
@@ -0,0 +1,49 @@
+package tenant
+
+import (
+	"context"
+	"testing"
+
+	"grouter/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestContext(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	ctx := logger.WithContext(context.Background(), zap.New(core))
+	assert.Empty(t, FromContext(ctx))
+	assert.Equal(t, ctx, NewContext(ctx, ""), "an empty tenant leaves ctx unchanged")
+
+	ctx = NewContext(ctx, "acme")
+	assert.Equal(t, "acme", FromContext(ctx))
+
+	logger.FromContext(ctx).Info("handled")
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, "acme", logs.All()[0].ContextMap()["tenant_id"])
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate("acme-1"))
+	for _, id := range []string{"", "acme.eu", "*", "a>", "ac me"} {
+		assert.ErrorIs(t, Validate(id), ErrInvalidTenant, id)
+	}
+}
+
+func TestParseSubject(t *testing.T) {
+	subject := Subject("gRouter", "acme", "orders.create")
+	assert.Equal(t, "gRouter.acme.orders.create", subject)
+
+	id, topic, err := ParseSubject("gRouter", subject)
+	require.NoError(t, err)
+	assert.Equal(t, "acme", id)
+	assert.Equal(t, "orders.create", topic)
+
+	for _, s := range []string{"other.acme.orders.create", "gRouter.acme", "gRouter.acme.", "gRouter..orders"} {
+		_, _, err := ParseSubject("gRouter", s)
+		assert.Error(t, err, s)
+	}
+}
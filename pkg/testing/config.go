@@ -0,0 +1,55 @@
+package testing
+
+import (
+	"os"
+	"path/filepath"
+
+	"grouter/pkg/config"
+
+	"github.com/stretchr/testify/require"
+)
+
+// WriteConfig writes content to configs/config.yaml in a temporary directory
+// and returns its path
+func WriteConfig(t TB, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "configs", "config.yaml")
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+// UseConfig writes content with WriteConfig and moves to its directory until
+// the end of the test, so that services loading the default configs/config.yaml
+// read it
+func UseConfig(t TB, content string) {
+	t.Helper()
+	t.Chdir(filepath.Dir(filepath.Dir(WriteConfig(t, content))))
+}
+
+// NewLoader returns a loader reading the config file of content, without
+// the flags of the test binary
+func NewLoader(t TB, content string) *config.Loader {
+	t.Helper()
+	l := config.NewLoader(config.WithArgs([]string{"--config", WriteConfig(t, content)}))
+	t.Cleanup(l.Close)
+	return l
+}
+
+// Config returns the default configuration of a test service: NATS runs
+// embedded on a random port and the web server is disabled. configure
+// changes it.
+func Config(configure ...func(*config.Config)) *config.Config {
+	cfg := config.Default()
+	cfg.App.Name = "grouter-test"
+	cfg.App.Environment = "test"
+	cfg.Log.Level = "error"
+	cfg.NATS.Enabled = true
+	cfg.NATS.Embedded = true
+	cfg.NATS.EmbeddedServer.Port = -1
+	cfg.Web.Enabled = false
+	for _, fn := range configure {
+		fn(cfg)
+	}
+	return cfg
+}
@@ -0,0 +1,42 @@
+package testing
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"grouter/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const appConfig = `
+app:
+  name: orders
+  version: 1.0.0
+  environment: test
+`
+
+func TestNewLoader(t *testing.T) {
+	cfg, err := NewLoader(t, appConfig).Load()
+	require.NoError(t, err)
+	assert.Equal(t, "orders", cfg.App.Name)
+}
+
+func TestUseConfig(t *testing.T) {
+	UseConfig(t, appConfig)
+
+	raw, err := os.ReadFile(filepath.Join("configs", "config.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, appConfig, string(raw))
+}
+
+func TestConfig(t *testing.T) {
+	cfg := Config(func(c *config.Config) { c.App.Name = "orders" })
+
+	assert.Equal(t, "orders", cfg.App.Name)
+	assert.True(t, cfg.NATS.Embedded)
+	assert.Equal(t, -1, cfg.NATS.EmbeddedServer.Port)
+	assert.False(t, cfg.Web.Enabled)
+}
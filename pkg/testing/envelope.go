@@ -0,0 +1,122 @@
+package testing
+
+import (
+	"encoding/json"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// EnvelopeOption configures the envelope of NewEnvelope
+type EnvelopeOption func(*messaging.MessageEnvelope)
+
+// WithSource sets the source of the envelope ("test" by default)
+func WithSource(source string) EnvelopeOption {
+	return func(e *messaging.MessageEnvelope) {
+		e.Source = source
+	}
+}
+
+// WithReply sets the reply subject of the envelope
+func WithReply(subject string) EnvelopeOption {
+	return func(e *messaging.MessageEnvelope) {
+		e.Reply = subject
+	}
+}
+
+// WithTenant sets the tenant of the envelope
+func WithTenant(id string) EnvelopeOption {
+	return func(e *messaging.MessageEnvelope) {
+		e.SetTenantID(id)
+	}
+}
+
+// WithMetadata sets a metadata entry of the envelope
+func WithMetadata(key, value string) EnvelopeOption {
+	return func(e *messaging.MessageEnvelope) {
+		if e.Metadata == nil {
+			e.Metadata = make(map[string]string)
+		}
+		e.Metadata[key] = value
+	}
+}
+
+// NewEnvelope returns an envelope of msgType carrying data as JSON, or no
+// data when nil. It starts a flow: its correlation ID is its ID.
+func NewEnvelope(t TB, msgType string, data any, opts ...EnvelopeOption) *messaging.MessageEnvelope {
+	t.Helper()
+	id := uuid.NewString()
+	env := &messaging.MessageEnvelope{
+		ID:            id,
+		CorrelationID: id,
+		Type:          msgType,
+		Timestamp:     time.Now(),
+		Source:        "test",
+		Version:       messaging.EnvelopeVersion,
+	}
+	if data != nil {
+		raw, err := json.Marshal(data)
+		require.NoError(t, err)
+		env.Data = raw
+	}
+	for _, opt := range opts {
+		opt(env)
+	}
+	return env
+}
+
+// Publish publishes the envelope of msgType and data on subject with a plain
+// connection, as another service would
+func Publish(t TB, nc *nats.Conn, subject, msgType string, data any, opts ...EnvelopeOption) {
+	t.Helper()
+	raw, err := json.Marshal(NewEnvelope(t, msgType, data, opts...))
+	require.NoError(t, err)
+	require.NoError(t, nc.Publish(subject, raw))
+}
+
+// DecodeEnvelope decodes the envelope of msg
+func DecodeEnvelope(t TB, msg *nats.Msg) *messaging.MessageEnvelope {
+	t.Helper()
+	var env messaging.MessageEnvelope
+	require.NoError(t, json.Unmarshal(msg.Data, &env), "not an envelope: %s", msg.Data)
+	return &env
+}
+
+// AssertEnvelope asserts that env is of msgType and carries the JSON of want
+func AssertEnvelope(t TB, env *messaging.MessageEnvelope, msgType string, want any) bool {
+	t.Helper()
+	raw, err := json.Marshal(want)
+	require.NoError(t, err)
+	return assert.Equal(t, msgType, env.Type, "envelope type") &&
+		assert.JSONEq(t, string(raw), string(env.Data), "envelope data")
+}
+
+// Subscription receives the envelopes published on a subject
+type Subscription struct {
+	t   TB
+	sub *nats.Subscription
+}
+
+// Subscribe subscribes to subject with a plain connection until the end of
+// the test
+func Subscribe(t TB, nc *nats.Conn, subject string) *Subscription {
+	t.Helper()
+	sub, err := nc.SubscribeSync(subject)
+	require.NoError(t, err)
+	require.NoError(t, nc.Flush())
+	t.Cleanup(func() { _ = sub.Unsubscribe() })
+	return &Subscription{t: t, sub: sub}
+}
+
+// Next returns the next envelope, failing the test after timeout
+func (s *Subscription) Next(timeout time.Duration) *messaging.MessageEnvelope {
+	s.t.Helper()
+	msg, err := s.sub.NextMsg(timeout)
+	require.NoError(s.t, err, "no message on %s", s.sub.Subject)
+	return DecodeEnvelope(s.t, msg)
+}
@@ -0,0 +1,33 @@
+package testing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewEnvelope(t *testing.T) {
+	env := NewEnvelope(t, "order.created", map[string]int{"qty": 2},
+		WithReply("orders.reply"), WithTenant("acme"), WithMetadata("k", "v"))
+
+	assert.NotEmpty(t, env.ID)
+	assert.Equal(t, "test", env.Source)
+	assert.Equal(t, "orders.reply", env.Reply)
+	assert.Equal(t, "acme", env.TenantID())
+	assert.Equal(t, "v", env.Metadata["k"])
+	assert.JSONEq(t, `{"qty":2}`, string(env.Data))
+
+	assert.Empty(t, NewEnvelope(t, "ping", nil).Data)
+}
+
+func TestPublish(t *testing.T) {
+	nc := Connect(t, RunNATSServer(t))
+	sub := Subscribe(t, nc, "orders.>")
+
+	Publish(t, nc, "orders.created", "order.created", []string{"a"}, WithSource("shop"))
+
+	env := sub.Next(2 * time.Second)
+	assert.Equal(t, "shop", env.Source)
+	AssertEnvelope(t, env, "order.created", []string{"a"})
+}
@@ -0,0 +1,83 @@
+package testing
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/stretchr/testify/require"
+)
+
+// HTTPClient sends requests to a handler in memory, such as a web.Server,
+// without listening on a port
+type HTTPClient struct {
+	t       TB
+	handler http.Handler
+	// Header is sent with every request
+	Header http.Header
+}
+
+// NewHTTPClient returns a client of handler
+func NewHTTPClient(t TB, handler http.Handler) *HTTPClient {
+	return &HTTPClient{t: t, handler: handler, Header: make(http.Header)}
+}
+
+// Do sends a request with body, encoded as JSON unless it is nil, an
+// io.Reader or a string
+func (c *HTTPClient) Do(method, path string, body any) *httptest.ResponseRecorder {
+	c.t.Helper()
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case io.Reader:
+		reader = b
+	case string:
+		reader = bytes.NewBufferString(b)
+	default:
+		raw, err := json.Marshal(b)
+		require.NoError(c.t, err)
+		reader = bytes.NewReader(raw)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	for k, v := range c.Header {
+		req.Header[k] = v
+	}
+	if reader != nil && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	c.handler.ServeHTTP(w, req)
+	return w
+}
+
+// Get sends a GET request
+func (c *HTTPClient) Get(path string) *httptest.ResponseRecorder {
+	c.t.Helper()
+	return c.Do(http.MethodGet, path, nil)
+}
+
+// Post sends a POST request
+func (c *HTTPClient) Post(path string, body any) *httptest.ResponseRecorder {
+	c.t.Helper()
+	return c.Do(http.MethodPost, path, body)
+}
+
+// DecodeJSON requires a response of status and decodes its body into v
+func DecodeJSON(t TB, w *httptest.ResponseRecorder, status int, v any) {
+	t.Helper()
+	require.Equal(t, status, w.Code, "response: %s", w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), v))
+}
+
+// FreePort returns a local TCP port that is free, e.g. for web.port when the
+// test starts the web server
+func FreePort(t TB) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
@@ -0,0 +1,44 @@
+package testing
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPClient(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"method":  r.Method,
+			"type":    r.Header.Get("Content-Type"),
+			"tenant":  r.Header.Get("X-Tenant-ID"),
+			"message": body["message"],
+		})
+	})
+	c := NewHTTPClient(t, handler)
+	c.Header.Set("X-Tenant-ID", "acme")
+
+	var got map[string]string
+	DecodeJSON(t, c.Post("/echo", map[string]string{"message": "hi"}), http.StatusOK, &got)
+	assert.Equal(t, map[string]string{"method": "POST", "type": "application/json", "tenant": "acme", "message": "hi"}, got)
+
+	DecodeJSON(t, c.Get("/echo"), http.StatusOK, &got)
+	assert.Equal(t, "GET", got["method"])
+	assert.Empty(t, got["type"])
+}
+
+func TestFreePort(t *testing.T) {
+	port := FreePort(t)
+	assert.Positive(t, port)
+	l, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	require.NoError(t, err)
+	l.Close()
+}
@@ -0,0 +1,27 @@
+package testing
+
+import (
+	"context"
+	"time"
+
+	"grouter/pkg/config"
+	"grouter/pkg/manager"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// NewManager returns an initialized manager with the Config of configure,
+// its NATS server in the process, stopped at the end of the test. Register
+// the services, then Start it.
+func NewManager(t TB, configure ...func(*config.Config)) *manager.ServiceManager {
+	t.Helper()
+	m, err := manager.New(manager.WithConfig(Config(configure...)), manager.WithLogger(zap.NewNop()))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = m.Stop(ctx)
+	})
+	return m
+}
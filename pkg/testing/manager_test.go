@@ -0,0 +1,33 @@
+package testing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewManager(t *testing.T) {
+	m := NewManager(t)
+	require.NotNil(t, m.Messenger())
+	require.NotNil(t, m.Messenger().EmbeddedServer(), "messaging runs in the process")
+
+	got := make(chan *messaging.MessageEnvelope, 1)
+	require.NoError(t, m.Messenger().Subscriber.Subscribe("orders.created", func(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+		got <- env
+		return nil
+	}, nil))
+	require.NoError(t, m.Publisher().Publish(context.Background(), "orders.created", "order.created", "1", nil))
+
+	select {
+	case env := <-got:
+		AssertEnvelope(t, env, "order.created", "1")
+	case <-time.After(2 * time.Second):
+		t.Fatal("message not received")
+	}
+	assert.Nil(t, m.WebServer())
+}
@@ -0,0 +1,79 @@
+// Package testing holds the helpers of the integration tests of grouter and
+// of the services built on it: an embedded NATS server, config files, a
+// manager with in-process messaging, envelope builders and assertions, and an
+// HTTP client calling handlers in memory. Import it under another name, e.g.
+//
+//	grtest "grouter/pkg/testing"
+package testing
+
+import (
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// TB is the part of testing.TB the helpers use
+type TB interface {
+	require.TestingT
+	Helper()
+	Cleanup(func())
+	TempDir() string
+	Chdir(dir string)
+}
+
+// ServerOption configures the server of RunNATSServer
+type ServerOption func(*server.Options)
+
+// WithJetStream enables JetStream, stored in a temporary directory
+func WithJetStream() ServerOption {
+	return func(o *server.Options) {
+		o.JetStream = true
+	}
+}
+
+// RunNATSServer runs a NATS server on a random local port until the end of
+// the test
+func RunNATSServer(t TB, opts ...ServerOption) *server.Server {
+	t.Helper()
+	o := &server.Options{Host: "127.0.0.1", Port: -1, NoLog: true, NoSigs: true}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.JetStream && o.StoreDir == "" {
+		o.StoreDir = t.TempDir()
+	}
+	s, err := server.NewServer(o)
+	require.NoError(t, err)
+	go s.Start()
+	t.Cleanup(s.Shutdown)
+	require.True(t, s.ReadyForConnections(5*time.Second), "NATS server not ready")
+	return s
+}
+
+// NewMessenger returns a messenger publishing as source on s, closed at the
+// end of the test. configure changes its config before it connects.
+func NewMessenger(t TB, s *server.Server, source string, configure ...func(*messaging.Config)) *messaging.Messenger {
+	t.Helper()
+	cfg := messaging.Config{URL: s.ClientURL(), ConnectionTimeout: 2 * time.Second}
+	for _, fn := range configure {
+		fn(&cfg)
+	}
+	m := &messaging.Messenger{}
+	require.NoError(t, m.Init(cfg, zap.NewNop(), source))
+	t.Cleanup(func() { _ = m.Close() })
+	return m
+}
+
+// Connect returns a plain connection to s, closed at the end of the test
+func Connect(t TB, s *server.Server) *nats.Conn {
+	t.Helper()
+	nc, err := nats.Connect(s.ClientURL())
+	require.NoError(t, err)
+	t.Cleanup(nc.Close)
+	return nc
+}
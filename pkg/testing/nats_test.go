@@ -0,0 +1,25 @@
+package testing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunNATSServer(t *testing.T) {
+	s := RunNATSServer(t, WithJetStream())
+	assert.True(t, s.JetStreamEnabled())
+
+	nc := Connect(t, s)
+	sub := Subscribe(t, nc, "orders.created")
+
+	m := NewMessenger(t, s, "orders")
+	require.NoError(t, m.Publisher.Publish(context.Background(), "orders.created", "order.created", map[string]string{"id": "1"}, nil))
+
+	env := sub.Next(2 * time.Second)
+	assert.Equal(t, "orders", env.Source)
+	AssertEnvelope(t, env, "order.created", map[string]string{"id": "1"})
+}
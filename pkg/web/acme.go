@@ -0,0 +1,91 @@
+package web
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// ACMEConfig holds configuration for automatic certificates from an ACME CA
+// such as Let's Encrypt
+type ACMEConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Domains the server may obtain certificates for (required)
+	Domains []string `mapstructure:"domains"`
+	// Email is the contact address registered with the CA
+	Email string `mapstructure:"email"`
+	// CacheDir stores the account key and certificates across restarts
+	// (default "acme-cache")
+	CacheDir string `mapstructure:"cache_dir"`
+	// DirectoryURL is the ACME directory (default Let's Encrypt production)
+	DirectoryURL string `mapstructure:"directory_url"`
+	// HTTPAddr is where the HTTP-01 challenge handler listens (default ":80").
+	// Other requests on it are redirected to HTTPS.
+	HTTPAddr string `mapstructure:"http_addr"`
+}
+
+// newACMEManager creates the certificate manager for cfg
+func newACMEManager(cfg ACMEConfig) (*autocert.Manager, error) {
+	if len(cfg.Domains) == 0 {
+		return nil, fmt.Errorf("ACME enabled but no domains configured")
+	}
+	if cfg.CacheDir == "" {
+		cfg.CacheDir = "acme-cache"
+	}
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(cfg.CacheDir),
+		HostPolicy: autocert.HostWhitelist(cfg.Domains...),
+		Email:      cfg.Email,
+	}
+	if cfg.DirectoryURL != "" {
+		m.Client = &acme.Client{DirectoryURL: cfg.DirectoryURL}
+	}
+	return m, nil
+}
+
+// startACME sets up automatic certificates: it returns the TLS configuration
+// for the main listener and starts the HTTP-01 challenge server
+func (s *Server) startACME() (*tls.Config, error) {
+	m, err := newACMEManager(s.cfg.TLS.ACME)
+	if err != nil {
+		return nil, err
+	}
+
+	addr := s.cfg.TLS.ACME.HTTPAddr
+	if addr == "" {
+		addr = ":80"
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for ACME challenges on %s: %w", addr, err)
+	}
+	challenge := &http.Server{
+		Handler:     m.HTTPHandler(nil),
+		ReadTimeout: s.cfg.ReadTimeout,
+	}
+	s.challenge = challenge
+	s.challengeListener = listener
+
+	s.logger.Info("Starting ACME challenge server",
+		zap.String("addr", listener.Addr().String()),
+		zap.Strings("domains", s.cfg.TLS.ACME.Domains))
+	go func() {
+		if err := challenge.Serve(listener); err != nil && err != http.ErrServerClosed {
+			s.logger.Error("ACME challenge server stopped", zap.Error(err))
+		}
+	}()
+
+	cfg := m.TLSConfig()
+	cfg.MinVersion = tls.VersionTLS12
+	if !s.cfg.HTTP2.Enabled {
+		cfg.NextProtos = slices.DeleteFunc(cfg.NextProtos, func(p string) bool { return p == "h2" })
+	}
+	return cfg, nil
+}
@@ -0,0 +1,88 @@
+package web
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestNewACMEManager(t *testing.T) {
+	_, err := newACMEManager(ACMEConfig{Enabled: true})
+	assert.Error(t, err, "domains are required")
+
+	m, err := newACMEManager(ACMEConfig{Enabled: true, Domains: []string{"example.com"}, CacheDir: t.TempDir()})
+	require.NoError(t, err)
+	assert.NoError(t, m.HostPolicy(context.Background(), "example.com"))
+	assert.Error(t, m.HostPolicy(context.Background(), "evil.example.org"))
+
+	// Non-challenge requests on the HTTP listener are redirected to HTTPS
+	w := httptest.NewRecorder()
+	m.HTTPHandler(nil).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://example.com/ping", nil))
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://example.com/ping", w.Header().Get("Location"))
+}
+
+func TestServer_ACME(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := DefaultConfig()
+	cfg.Port = 0
+	cfg.TLS = TLSConfig{Enabled: true, ACME: ACMEConfig{Enabled: true}}
+
+	server := newTestServer(t, cfg, zap.NewNop(), nil)
+	assert.Error(t, server.Start(), "no domains")
+
+	cfg.TLS.ACME = ACMEConfig{
+		Enabled:  true,
+		Domains:  []string{"example.com"},
+		CacheDir: t.TempDir(),
+		HTTPAddr: "127.0.0.1:0",
+	}
+	server = newTestServer(t, cfg, zap.NewNop(), nil)
+	require.NoError(t, server.Start())
+	defer server.Stop(context.Background())
+
+	// Hosts outside the allowlist are refused without contacting the CA
+	conn, err := tls.Dial("tcp", fmt.Sprintf("localhost:%d", server.Port()), &tls.Config{ServerName: "localhost", InsecureSkipVerify: true})
+	if err == nil {
+		conn.Close()
+	}
+	assert.Error(t, err)
+}
+
+func TestServer_ACMEReleasedOnStartError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// The profiling port is taken: Start fails after the challenge server
+	// started
+	busy, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	defer busy.Close()
+
+	cfg := DefaultConfig()
+	cfg.Port = 0
+	cfg.Auth = profilingAuthConfig()
+	cfg.Profiling = ProfilingConfig{Enabled: true, Port: busy.Addr().(*net.TCPAddr).Port}
+	cfg.TLS = TLSConfig{Enabled: true, ACME: ACMEConfig{
+		Enabled:  true,
+		Domains:  []string{"example.com"},
+		CacheDir: t.TempDir(),
+		HTTPAddr: fmt.Sprintf("127.0.0.1:%d", freePort(t)),
+	}}
+	server := newTestServer(t, cfg, zap.NewNop(), nil)
+	require.Error(t, server.Start())
+	assert.Nil(t, server.challenge)
+	assert.Empty(t, server.Addr())
+
+	// The challenge port is free again
+	l, err := net.Listen("tcp", cfg.TLS.ACME.HTTPAddr)
+	require.NoError(t, err, "the challenge server is shut down")
+	l.Close()
+}
@@ -0,0 +1,37 @@
+package web
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminUIPath is where AdminUI serves the dashboard
+const AdminUIPath = "/admin/ui"
+
+//go:embed adminui
+var adminUIFiles embed.FS
+
+// AdminUI serves the admin dashboard, a static page showing the services,
+// health, NATS subscriptions, recent errors and configuration read from the
+// admin API mounted next to it under /admin
+type AdminUI struct {
+	files http.FileSystem
+}
+
+// NewAdminUI creates the admin dashboard from its embedded files
+func NewAdminUI() *AdminUI {
+	sub, err := fs.Sub(adminUIFiles, "adminui")
+	if err != nil {
+		// the directory is embedded at build time
+		panic(err)
+	}
+	return &AdminUI{files: http.FS(sub)}
+}
+
+// RegisterRoutes serves the dashboard under AdminUIPath
+func (u *AdminUI) RegisterRoutes(router *gin.RouterGroup) {
+	router.StaticFS(AdminUIPath, u.files)
+}
@@ -0,0 +1,183 @@
+// The dashboard reads the admin API mounted next to it: served under
+// /admin/ui/, "../services" is /admin/services.
+(function () {
+  "use strict";
+
+  const api = "../";
+  const refreshInterval = 5000;
+
+  function el(tag, text, className) {
+    const node = document.createElement(tag);
+    if (text !== undefined && text !== null) {
+      node.textContent = String(text);
+    }
+    if (className) {
+      node.className = className;
+    }
+    return node;
+  }
+
+  // request calls the admin API, rejecting with the detail of error responses
+  async function request(path, options) {
+    const res = await fetch(api + path, Object.assign({ headers: { Accept: "application/json" } }, options));
+    const body = await res.json().catch(() => null);
+    if (!res.ok) {
+      const detail = body && (body.detail || body.title);
+      throw new Error(path + ": " + (detail || res.status + " " + res.statusText));
+    }
+    return body;
+  }
+
+  // fill replaces the rows of the table of section with those of cells, one
+  // array of cells per item
+  function fill(section, items, cells, empty) {
+    const tbody = document.querySelector("#" + section + " tbody");
+    tbody.replaceChildren();
+    if (!items || items.length === 0) {
+      const td = el("td", empty, "empty");
+      td.colSpan = tbody.parentElement.querySelectorAll("th").length;
+      tbody.append(el("tr"));
+      tbody.lastChild.append(td);
+      return;
+    }
+    for (const item of items) {
+      const tr = el("tr");
+      for (const cell of cells(item)) {
+        tr.append(cell instanceof Node ? cell : el("td", cell));
+      }
+      tbody.append(tr);
+    }
+  }
+
+  function num(value) {
+    return el("td", value || 0, "num");
+  }
+
+  function status(value) {
+    return el("td", value, value);
+  }
+
+  function action(service, verb) {
+    const td = el("td");
+    const button = el("button", verb);
+    button.type = "button";
+    button.addEventListener("click", async () => {
+      button.disabled = true;
+      try {
+        await request("services/" + encodeURIComponent(service) + "/" + verb, { method: "POST" });
+        clearError();
+      } catch (err) {
+        showError(err);
+      }
+      refresh();
+    });
+    td.append(button);
+    return td;
+  }
+
+  function renderServices(services) {
+    fill("services", services, (s) => [
+      s.name,
+      status(s.state),
+      s.error || "",
+      s.state === "running" ? action(s.name, "stop") : s.state === "stopped" ? action(s.name, "start") : el("td"),
+    ], "No services registered");
+  }
+
+  function renderHealth(report) {
+    const heading = document.querySelector("#health .status");
+    heading.textContent = report.status;
+    heading.className = "status " + report.status;
+    if (report.app) {
+      document.getElementById("app").textContent = [report.app, report.version, report.instance].filter(Boolean).join(" · ");
+    }
+
+    const checks = [];
+    for (const probe of ["liveness", "readiness", "startup"]) {
+      for (const [name, check] of Object.entries(report[probe] || {}).sort()) {
+        checks.push(Object.assign({ probe: probe, name: name }, check));
+      }
+    }
+    fill("health", checks, (c) => [
+      c.probe,
+      c.name,
+      status(c.status),
+      c.critical ? "yes" : "no",
+      el("td", c.duration_ms.toFixed(1) + " ms", "num"),
+      c.error || "",
+    ], "No health checks");
+  }
+
+  function renderSubscriptions(subs) {
+    fill("subscriptions", subs, (s) => [
+      s.service || "(topics)",
+      s.subject,
+      s.queue_group || "",
+      num(s.workers),
+      num(s.received),
+      num(s.failed),
+      num(s.in_flight),
+      num(s.pending),
+    ], "No subscriptions");
+  }
+
+  function renderErrors(errors) {
+    fill("errors", errors, (e) => [
+      new Date(e.time).toLocaleString(),
+      e.service || "",
+      e.topic,
+      e.type || "",
+      e.id || "",
+      el("td", e.error, "failed"),
+    ], "No errors");
+  }
+
+  function renderConfig(config) {
+    document.querySelector("#config pre").textContent = JSON.stringify(config, null, 2);
+  }
+
+  function showError(err) {
+    const banner = document.getElementById("banner");
+    banner.textContent = err.message;
+    banner.hidden = false;
+  }
+
+  function clearError() {
+    document.getElementById("banner").hidden = true;
+  }
+
+  async function refresh() {
+    const views = [
+      ["services", renderServices],
+      ["health", renderHealth],
+      ["subscriptions", renderSubscriptions],
+      ["errors", renderErrors],
+      ["config", renderConfig],
+    ];
+    const results = await Promise.allSettled(views.map(([path, render]) => request(path).then(render)));
+    const failed = results.find((r) => r.status === "rejected");
+    if (failed) {
+      showError(failed.reason);
+    } else {
+      clearError();
+    }
+    document.getElementById("updated").textContent = "Updated " + new Date().toLocaleTimeString();
+  }
+
+  document.getElementById("refresh").addEventListener("click", refresh);
+  document.getElementById("reload").addEventListener("click", async () => {
+    try {
+      await request("reload", { method: "POST" });
+      clearError();
+    } catch (err) {
+      showError(err);
+    }
+    refresh();
+  });
+  setInterval(() => {
+    if (document.getElementById("auto").checked && !document.hidden) {
+      refresh();
+    }
+  }, refreshInterval);
+  refresh();
+})();
@@ -0,0 +1,37 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminUI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	NewAdminUI().RegisterRoutes(engine.Group("/"))
+
+	tests := []struct {
+		name     string
+		path     string
+		status   int
+		contains string
+	}{
+		{name: "index", path: "/admin/ui/", status: http.StatusOK, contains: "<title>gRouter admin</title>"},
+		{name: "script", path: "/admin/ui/app.js", status: http.StatusOK, contains: `"subscriptions", renderSubscriptions`},
+		{name: "stylesheet", path: "/admin/ui/style.css", status: http.StatusOK, contains: "table"},
+		{name: "without slash", path: "/admin/ui", status: http.StatusMovedPermanently},
+		{name: "missing file", path: "/admin/ui/nope.js", status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			assert.Equal(t, tt.status, w.Code)
+			assert.Contains(t, w.Body.String(), tt.contains)
+		})
+	}
+}
@@ -2,72 +2,251 @@ package web
 
 import (
 	"context"
+	"crypto/subtle"
 	"fmt"
-	"net/http"
 	"strings"
 
 	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/gin-gonic/gin"
 )
 
-// AuthMiddleware creates a middleware that verifies OIDC ID tokens
-func AuthMiddleware(cfg AuthConfig) gin.HandlerFunc {
-	// If disabled, just pass through
-	if !cfg.Enabled {
-		return func(c *gin.Context) {
-			c.Next()
-		}
+// Auth modes
+const (
+	// AuthModeRequired rejects every request without valid credentials
+	AuthModeRequired = "required"
+	// AuthModeOptional only populates the identity; route groups opt in with RequireAuth
+	AuthModeOptional = "optional"
+)
+
+// Authentication methods reported in Identity.Method
+const (
+	AuthMethodOIDC   = "oidc"
+	AuthMethodJWT    = "jwt"
+	AuthMethodAPIKey = "apikey"
+)
+
+// identityKey is the Gin context key holding the *Identity
+const identityKey = "identity"
+
+// Identity is the authenticated caller of a request
+type Identity struct {
+	// Subject is the token subject or the API key name
+	Subject string
+	Email   string
+	// Method is the authentication method that produced the identity
+	Method string
+	Roles  []string
+	// Claims holds the raw token claims (nil for API keys)
+	Claims map[string]interface{}
+}
+
+// IdentityFromContext returns the identity set by the auth middleware
+func IdentityFromContext(c *gin.Context) (*Identity, bool) {
+	v, ok := c.Get(identityKey)
+	if !ok {
+		return nil, false
 	}
+	id, ok := v.(*Identity)
+	return id, ok
+}
 
-	provider, err := oidc.NewProvider(context.Background(), cfg.Issuer)
-	if err != nil {
-		// If provider initialization fails, we panic because auth is critical but misconfigured
-		// In production, might want to retry or error out gracefully at startup.
-		// For middleware factory, we usually return error or panic.
-		// Since gin.HandlerFunc signature doesn't allow error return, we'll log and panic.
-		panic(fmt.Sprintf("failed to init OIDC provider: %v", err))
+// Authenticator validates bearer tokens (OIDC discovery or a JWKS URL) and
+// static API keys
+type Authenticator struct {
+	cfg      AuthConfig
+	verifier *oidc.IDTokenVerifier
+	method   string
+	skip     map[string]bool
+}
+
+// NewAuthenticator creates an Authenticator. Bearer tokens are verified
+// against JWKSURL when set, otherwise against the keys discovered from Issuer.
+func NewAuthenticator(ctx context.Context, cfg AuthConfig) (*Authenticator, error) {
+	if cfg.Mode == "" {
+		cfg.Mode = AuthModeRequired
+	}
+	if cfg.Mode != AuthModeRequired && cfg.Mode != AuthModeOptional {
+		return nil, fmt.Errorf("invalid auth mode %q", cfg.Mode)
+	}
+	if cfg.APIKeyHeader == "" {
+		cfg.APIKeyHeader = "X-API-Key"
+	}
+	if cfg.RolesClaim == "" {
+		cfg.RolesClaim = "roles"
+	}
+
+	a := &Authenticator{cfg: cfg, skip: make(map[string]bool)}
+	for _, p := range cfg.SkipPaths {
+		a.skip[p] = true
+	}
+
+	oidcConfig := &oidc.Config{
+		ClientID:             cfg.Audience,
+		SkipClientIDCheck:    cfg.Audience == "",
+		SupportedSigningAlgs: cfg.Algorithms,
+	}
+	switch {
+	case cfg.JWKSURL != "":
+		// RemoteKeySet caches the keys and refetches them when a token is
+		// signed with an unknown key ID
+		keySet := oidc.NewRemoteKeySet(ctx, cfg.JWKSURL)
+		oidcConfig.SkipIssuerCheck = cfg.Issuer == ""
+		a.verifier = oidc.NewVerifier(cfg.Issuer, keySet, oidcConfig)
+		a.method = AuthMethodJWT
+	case cfg.Issuer != "":
+		provider, err := oidc.NewProvider(ctx, cfg.Issuer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to init OIDC provider: %w", err)
+		}
+		a.verifier = provider.Verifier(oidcConfig)
+		a.method = AuthMethodOIDC
 	}
 
-	verifier := provider.Verifier(&oidc.Config{
-		ClientID: cfg.Audience,
-	})
+	if a.verifier == nil && len(cfg.APIKeys) == 0 {
+		return nil, fmt.Errorf("auth enabled but no issuer, jwks_url or api_keys configured")
+	}
+	return a, nil
+}
 
+// Middleware authenticates the request and stores the Identity in the Gin
+// context. In required mode, requests without valid credentials are rejected.
+func (a *Authenticator) Middleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing authorization header"})
+		if a.skip[c.FullPath()] || a.skip[c.Request.URL.Path] {
+			c.Next()
 			return
 		}
 
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid authorization header format"})
+		id, err := a.Authenticate(c)
+		if err != nil {
+			AbortWithError(c, Unauthorized(err.Error()))
 			return
 		}
+		if id == nil && a.cfg.Mode == AuthModeRequired {
+			AbortWithError(c, Unauthorized("missing authorization header"))
+			return
+		}
+		c.Next()
+	}
+}
 
-		tokenString := parts[1]
-
-		// Verify token
-		idToken, err := verifier.Verify(c.Request.Context(), tokenString)
+// Authenticate validates the credentials of the request, if any. It returns a
+// nil identity and no error when the request carries no credentials.
+func (a *Authenticator) Authenticate(c *gin.Context) (*Identity, error) {
+	if key := c.GetHeader(a.cfg.APIKeyHeader); key != "" && len(a.cfg.APIKeys) > 0 {
+		id, err := a.authenticateAPIKey(key)
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token: " + err.Error()})
-			return
+			return nil, err
 		}
+		setIdentity(c, id)
+		return id, nil
+	}
+
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		return nil, nil
+	}
+	if a.verifier == nil {
+		return nil, fmt.Errorf("bearer tokens are not accepted")
+	}
+
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return nil, fmt.Errorf("invalid authorization header format")
+	}
 
-		// Store claims/token in context
-		c.Set("token", idToken)
+	idToken, err := a.verifier.Verify(c.Request.Context(), parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("invalid token claims: %w", err)
+	}
 
-		// Extract claims if needed
-		var claims struct {
-			Email    string `json:"email"`
-			Verified bool   `json:"email_verified"`
-			Sub      string `json:"sub"`
+	id := &Identity{
+		Subject: idToken.Subject,
+		Method:  a.method,
+		Roles:   stringSlice(claims[a.cfg.RolesClaim]),
+		Claims:  claims,
+	}
+	if email, ok := claims["email"].(string); ok {
+		id.Email = email
+	}
+
+	// Store claims/token in context
+	c.Set("token", idToken)
+	setIdentity(c, id)
+	return id, nil
+}
+
+func (a *Authenticator) authenticateAPIKey(key string) (*Identity, error) {
+	for _, k := range a.cfg.APIKeys {
+		if subtle.ConstantTimeCompare([]byte(k.Key), []byte(key)) == 1 {
+			return &Identity{
+				Subject: k.Name,
+				Method:  AuthMethodAPIKey,
+				Roles:   k.Roles,
+			}, nil
 		}
-		if err := idToken.Claims(&claims); err == nil {
-			c.Set("user_email", claims.Email)
-			c.Set("user_id", claims.Sub)
+	}
+	return nil, fmt.Errorf("invalid api key")
+}
+
+func setIdentity(c *gin.Context, id *Identity) {
+	c.Set(identityKey, id)
+	// Kept for handlers and the gRPC gateway that read these keys directly
+	c.Set("user_id", id.Subject)
+	c.Set("user_email", id.Email)
+}
+
+// stringSlice converts a roles claim, either a list or a space separated string
+func stringSlice(v interface{}) []string {
+	switch t := v.(type) {
+	case []interface{}:
+		out := make([]string, 0, len(t))
+		for _, e := range t {
+			if s, ok := e.(string); ok {
+				out = append(out, s)
+			}
 		}
+		return out
+	case string:
+		return strings.Fields(t)
+	}
+	return nil
+}
 
+// RequireAuth rejects requests that were not authenticated by the auth
+// middleware. Use it on route groups when the auth mode is optional:
+//
+//	admin := router.Group("/admin", web.RequireAuth())
+func RequireAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := IdentityFromContext(c); !ok {
+			AbortWithError(c, Unauthorized("authentication required"))
+			return
+		}
 		c.Next()
 	}
 }
+
+// AuthMiddleware creates a middleware that authenticates requests using
+// OIDC/JWT bearer tokens or API keys
+func AuthMiddleware(cfg AuthConfig) gin.HandlerFunc {
+	// If disabled, just pass through
+	if !cfg.Enabled {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	a, err := NewAuthenticator(context.Background(), cfg)
+	if err != nil {
+		// Auth is critical; a misconfiguration must not silently expose the API.
+		// Since gin.HandlerFunc signature doesn't allow error return, we panic.
+		panic(err.Error())
+	}
+	return a.Middleware()
+}
@@ -0,0 +1,150 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// jwksFixture serves a JWKS document and signs tokens with the matching key
+type jwksFixture struct {
+	server *httptest.Server
+	signer jose.Signer
+}
+
+func newJWKSFixture(t *testing.T) *jwksFixture {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	jwks := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: &key.PublicKey, KeyID: "k1", Algorithm: "RS256", Use: "sig"}}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(jwks)
+	}))
+	t.Cleanup(server.Close)
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", "k1"))
+	require.NoError(t, err)
+	return &jwksFixture{server: server, signer: signer}
+}
+
+func (f *jwksFixture) token(t *testing.T, claims map[string]interface{}) string {
+	t.Helper()
+	std := jwt.Claims{
+		Subject:  "user-1",
+		Issuer:   "https://issuer.example.com",
+		Audience: jwt.Audience{"grouter"},
+		Expiry:   jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+	raw, err := jwt.Signed(f.signer).Claims(std).Claims(claims).Serialize()
+	require.NoError(t, err)
+	return raw
+}
+
+func newAuthEngine(t *testing.T, cfg AuthConfig) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	a, err := NewAuthenticator(context.Background(), cfg)
+	require.NoError(t, err)
+
+	engine := gin.New()
+	engine.Use(a.Middleware())
+	handler := func(c *gin.Context) {
+		id, ok := IdentityFromContext(c)
+		if !ok {
+			c.JSON(http.StatusOK, gin.H{"subject": ""})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"subject": id.Subject, "method": id.Method, "roles": id.Roles, "email": id.Email})
+	}
+	engine.GET("/public", handler)
+	engine.GET("/health/live", handler)
+	engine.GET("/private", RequireAuth(), handler)
+	return engine
+}
+
+func doAuthRequest(engine *gin.Engine, path string, headers map[string]string) (int, map[string]interface{}) {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	var body map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &body)
+	return w.Code, body
+}
+
+func TestAuthenticator_JWKS(t *testing.T) {
+	f := newJWKSFixture(t)
+	engine := newAuthEngine(t, AuthConfig{
+		Enabled:   true,
+		Issuer:    "https://issuer.example.com",
+		Audience:  "grouter",
+		JWKSURL:   f.server.URL,
+		SkipPaths: []string{"/health/live"},
+	})
+
+	token := f.token(t, map[string]interface{}{"email": "a@example.com", "roles": []string{"admin"}})
+	code, body := doAuthRequest(engine, "/public", map[string]string{"Authorization": "Bearer " + token})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "user-1", body["subject"])
+	assert.Equal(t, AuthMethodJWT, body["method"])
+	assert.Equal(t, "a@example.com", body["email"])
+	assert.Equal(t, []interface{}{"admin"}, body["roles"])
+
+	code, _ = doAuthRequest(engine, "/public", nil)
+	assert.Equal(t, http.StatusUnauthorized, code, "required mode rejects anonymous requests")
+
+	code, _ = doAuthRequest(engine, "/public", map[string]string{"Authorization": "Bearer " + token + "x"})
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	code, _ = doAuthRequest(engine, "/health/live", nil)
+	assert.Equal(t, http.StatusOK, code, "skip paths bypass authentication")
+}
+
+func TestAuthenticator_APIKeys(t *testing.T) {
+	engine := newAuthEngine(t, AuthConfig{
+		Enabled: true,
+		Mode:    AuthModeOptional,
+		APIKeys: []APIKeyConfig{{Name: "billing", Key: "secret-key", Roles: []string{"reader"}}},
+	})
+
+	code, body := doAuthRequest(engine, "/private", map[string]string{"X-API-Key": "secret-key"})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "billing", body["subject"])
+	assert.Equal(t, AuthMethodAPIKey, body["method"])
+
+	code, _ = doAuthRequest(engine, "/private", map[string]string{"X-API-Key": "wrong"})
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	// Optional mode: anonymous requests pass, RequireAuth groups opt in
+	code, _ = doAuthRequest(engine, "/public", nil)
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = doAuthRequest(engine, "/private", nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	// Bearer tokens are rejected when only API keys are configured
+	code, _ = doAuthRequest(engine, "/public", map[string]string{"Authorization": "Bearer abc"})
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
+
+func TestNewAuthenticator_InvalidConfig(t *testing.T) {
+	_, err := NewAuthenticator(context.Background(), AuthConfig{Enabled: true})
+	assert.Error(t, err)
+
+	_, err = NewAuthenticator(context.Background(), AuthConfig{Enabled: true, Mode: "sometimes", JWKSURL: "http://localhost"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,56 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+
+	"grouter/pkg/chaos"
+	"grouter/pkg/telemetry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ChaosMiddleware injects the faults of the http rules of inj into the
+// matching requests, counting them in reg (nil uses the global registry).
+// Health probes and /admin/ are never affected, so that the experiment can
+// always be stopped.
+func ChaosMiddleware(inj *chaos.Injector, reg *telemetry.MetricsRegistry) gin.HandlerFunc {
+	injected := reg.CounterVec(prometheus.CounterOpts{
+		Name: "http_chaos_faults_total",
+		Help: "Total number of faults injected into HTTP requests",
+	}, []string{"fault"})
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		if strings.HasPrefix(path, "/health/") || strings.HasPrefix(path, "/admin/") {
+			c.Next()
+			return
+		}
+		rule, ok := inj.Pick(chaos.TargetHTTP, func(prefix string) bool {
+			return strings.HasPrefix(path, prefix)
+		})
+		if !ok {
+			c.Next()
+			return
+		}
+		injected.WithLabelValues(string(rule.Fault)).Inc()
+
+		switch rule.Fault {
+		case chaos.FaultLatency:
+			// A cancelled request reaches the handler with its context done
+			_ = chaos.Sleep(c.Request.Context(), rule.Latency)
+		case chaos.FaultError:
+			status := rule.Status
+			if status == 0 {
+				status = http.StatusInternalServerError
+			}
+			AbortWithError(c, NewError(status, CodeInjectedFault, "injected fault"))
+			return
+		case chaos.FaultDrop:
+			// net/http closes the connection (HTTP/1) or resets the stream
+			// (HTTP/2) without a response
+			panic(http.ErrAbortHandler)
+		}
+		c.Next()
+	}
+}
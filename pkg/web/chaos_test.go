@@ -0,0 +1,67 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"grouter/pkg/chaos"
+	"grouter/pkg/telemetry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestChaosMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	reg := telemetry.NewMetricsRegistry()
+	inj, err := chaos.New(chaos.State{Active: true, Rules: []chaos.Rule{
+		{Target: chaos.TargetHTTP, Match: "/api/orders", Percent: 100, Fault: chaos.FaultError, Status: http.StatusBadGateway},
+		{Target: chaos.TargetHTTP, Match: "/api/slow", Percent: 100, Fault: chaos.FaultLatency, Latency: 20 * time.Millisecond},
+		{Target: chaos.TargetHTTP, Match: "/api/lost", Percent: 100, Fault: chaos.FaultDrop},
+		{Target: chaos.TargetHTTP, Percent: 100, Fault: chaos.FaultError},
+	}})
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.Use(ErrorMiddleware(zap.NewNop()), ChaosMiddleware(inj, reg))
+	for _, path := range []string{"/api/orders", "/api/slow", "/api/lost", "/admin/chaos", "/health/live"} {
+		r.GET(path, func(c *gin.Context) { c.Status(http.StatusOK) })
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := get("/api/orders")
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Contains(t, w.Body.String(), CodeInjectedFault)
+
+	start := time.Now()
+	assert.Equal(t, http.StatusOK, get("/api/slow").Code)
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() { get("/api/lost") }, "the connection is dropped")
+
+	// The admin API and health probes are never affected
+	assert.Equal(t, http.StatusOK, get("/admin/chaos").Code)
+	assert.Equal(t, http.StatusOK, get("/health/live").Code)
+
+	inj.SetActive(false)
+	assert.Equal(t, http.StatusOK, get("/api/orders").Code)
+
+	expected := `
+# HELP http_chaos_faults_total Total number of faults injected into HTTP requests
+# TYPE http_chaos_faults_total counter
+http_chaos_faults_total{fault="drop"} 1
+http_chaos_faults_total{fault="error"} 1
+http_chaos_faults_total{fault="latency"} 1
+`
+	assert.NoError(t, testutil.GatherAndCompare(reg.Gatherer(), strings.NewReader(expected), "http_chaos_faults_total"))
+}
@@ -0,0 +1,248 @@
+package web
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+)
+
+// Supported content encodings
+const (
+	EncodingBrotli  = "br"
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+)
+
+// CompressionConfig holds configuration for response compression
+type CompressionConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Encodings in server preference order (default br, gzip, deflate)
+	Encodings []string `mapstructure:"encodings"`
+	// Level is the compression level (0 = encoder default)
+	Level int `mapstructure:"level"`
+	// MinSize is the minimum response size in bytes to compress (default 1024)
+	MinSize int `mapstructure:"min_size"`
+	// ContentTypes are the compressible content type prefixes
+	// (default JSON, text, JavaScript and XML)
+	ContentTypes []string `mapstructure:"content_types"`
+	// ExcludePaths are path prefixes that are never compressed, e.g. streams
+	ExcludePaths []string `mapstructure:"exclude_paths"`
+}
+
+var defaultCompressibleTypes = []string{
+	"application/json",
+	"application/problem+json",
+	"application/javascript",
+	"application/xml",
+	"text/",
+	"image/svg+xml",
+}
+
+// CompressionMiddleware compresses responses with the best encoding accepted by
+// the client. Responses smaller than MinSize or with a content type outside the
+// allowlist are sent as is. Attach it to a route group to compress only that
+// group.
+func CompressionMiddleware(cfg CompressionConfig) gin.HandlerFunc {
+	if len(cfg.Encodings) == 0 {
+		cfg.Encodings = []string{EncodingBrotli, EncodingGzip, EncodingDeflate}
+	}
+	if cfg.MinSize <= 0 {
+		cfg.MinSize = 1024
+	}
+	if len(cfg.ContentTypes) == 0 {
+		cfg.ContentTypes = defaultCompressibleTypes
+	}
+
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodHead || hasPrefixAny(c.Request.URL.Path, cfg.ExcludePaths) {
+			c.Next()
+			return
+		}
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"), cfg.Encodings)
+		if encoding == "" {
+			c.Next()
+			return
+		}
+
+		w := &compressWriter{ResponseWriter: c.Writer, cfg: &cfg, encoding: encoding}
+		c.Writer = w
+		defer func() {
+			c.Writer = w.ResponseWriter
+		}()
+		c.Header("Vary", "Accept-Encoding")
+
+		c.Next()
+		w.close()
+	}
+}
+
+// negotiateEncoding picks the first server encoding accepted by the client
+func negotiateEncoding(accept string, encodings []string) string {
+	if accept == "" {
+		return ""
+	}
+	accepted := make(map[string]float64)
+	for _, part := range strings.Split(accept, ",") {
+		name, q := parseQuality(part)
+		accepted[strings.ToLower(name)] = q
+	}
+	for _, enc := range encodings {
+		q, ok := accepted[enc]
+		if !ok {
+			q, ok = accepted["*"]
+		}
+		if ok && q > 0 {
+			return enc
+		}
+	}
+	return ""
+}
+
+// parseQuality splits "gzip;q=0.5" into its name and quality
+func parseQuality(part string) (string, float64) {
+	name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+	q := 1.0
+	if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			q = f
+		}
+	}
+	return strings.TrimSpace(name), q
+}
+
+func hasPrefixAny(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// compressWriter buffers the first MinSize bytes to decide whether the
+// response is worth compressing
+type compressWriter struct {
+	gin.ResponseWriter
+	cfg      *CompressionConfig
+	encoding string
+	buf      bytes.Buffer
+	encoder  io.WriteCloser
+	// decided is set once the response is either compressed or passed through
+	decided bool
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if !w.decided {
+		if !w.compressible() {
+			w.decided = true
+			return w.ResponseWriter.Write(data)
+		}
+		w.buf.Write(data)
+		if w.buf.Len() < w.cfg.MinSize {
+			return len(data), nil
+		}
+		if err := w.startEncoder(); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+	if w.encoder != nil {
+		return w.encoder.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush sends buffered data; streaming responses are compressed from the
+// first flush when their content type allows it
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if w.compressible() && w.buf.Len() > 0 {
+			_ = w.startEncoder()
+		} else {
+			w.passthrough()
+		}
+	}
+	if f, ok := w.encoder.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// compressible reports whether the response may be compressed
+func (w *compressWriter) compressible() bool {
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	switch w.Status() {
+	case http.StatusNoContent, http.StatusNotModified, http.StatusPartialContent:
+		return false
+	}
+	ct := h.Get("Content-Type")
+	return ct != "" && hasPrefixAny(strings.ToLower(ct), w.cfg.ContentTypes)
+}
+
+func (w *compressWriter) startEncoder() error {
+	w.decided = true
+	h := w.Header()
+	h.Set("Content-Encoding", w.encoding)
+	h.Del("Content-Length")
+
+	var err error
+	switch w.encoding {
+	case EncodingBrotli:
+		level := brotli.DefaultCompression
+		if w.cfg.Level > 0 {
+			level = w.cfg.Level
+		}
+		w.encoder = brotli.NewWriterLevel(w.ResponseWriter, level)
+	case EncodingGzip:
+		level := gzip.DefaultCompression
+		if w.cfg.Level != 0 {
+			level = w.cfg.Level
+		}
+		w.encoder, err = gzip.NewWriterLevel(w.ResponseWriter, level)
+	case EncodingDeflate:
+		level := flate.DefaultCompression
+		if w.cfg.Level != 0 {
+			level = w.cfg.Level
+		}
+		w.encoder, err = flate.NewWriter(w.ResponseWriter, level)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = w.encoder.Write(w.buf.Bytes())
+	w.buf.Reset()
+	return err
+}
+
+// passthrough writes the buffered bytes uncompressed
+func (w *compressWriter) passthrough() {
+	w.decided = true
+	if w.buf.Len() > 0 {
+		_, _ = w.ResponseWriter.Write(w.buf.Bytes())
+		w.buf.Reset()
+	}
+}
+
+// close flushes the buffer or finishes the compressed stream
+func (w *compressWriter) close() {
+	if !w.decided {
+		w.passthrough()
+	}
+	if w.encoder != nil {
+		_ = w.encoder.Close()
+	}
+}
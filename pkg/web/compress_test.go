@@ -0,0 +1,107 @@
+package web
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCompressionEngine(cfg CompressionConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(CompressionMiddleware(cfg))
+	large := strings.Repeat("gRouter compresses this text. ", 100)
+	r.GET("/large", func(c *gin.Context) { c.String(http.StatusOK, large) })
+	r.GET("/small", func(c *gin.Context) { c.String(http.StatusOK, "tiny") })
+	r.GET("/binary", func(c *gin.Context) { c.Data(http.StatusOK, "image/png", []byte(large)) })
+	r.GET("/stream/data", func(c *gin.Context) { c.String(http.StatusOK, large) })
+	return r
+}
+
+func getWithEncoding(r *gin.Engine, path, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if accept != "" {
+		req.Header.Set("Accept-Encoding", accept)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func decode(t *testing.T, encoding string, body io.Reader) string {
+	t.Helper()
+	var rd io.Reader
+	switch encoding {
+	case EncodingGzip:
+		gz, err := gzip.NewReader(body)
+		require.NoError(t, err)
+		rd = gz
+	case EncodingDeflate:
+		rd = flate.NewReader(body)
+	case EncodingBrotli:
+		rd = brotli.NewReader(body)
+	default:
+		rd = body
+	}
+	b, err := io.ReadAll(rd)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestCompressionMiddleware_Encodings(t *testing.T) {
+	r := newCompressionEngine(CompressionConfig{Enabled: true})
+	want := strings.Repeat("gRouter compresses this text. ", 100)
+
+	tests := []struct {
+		accept, encoding string
+	}{
+		{"gzip", EncodingGzip},
+		{"deflate", EncodingDeflate},
+		{"gzip, deflate, br", EncodingBrotli},
+		{"br;q=0, gzip", EncodingGzip},
+		{"*", EncodingBrotli},
+		{"identity", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			w := getWithEncoding(r, "/large", tt.accept)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tt.encoding, w.Header().Get("Content-Encoding"))
+			if tt.encoding != "" {
+				assert.Less(t, w.Body.Len(), len(want))
+			}
+			assert.Equal(t, want, decode(t, tt.encoding, w.Body))
+		})
+	}
+}
+
+func TestCompressionMiddleware_Skips(t *testing.T) {
+	r := newCompressionEngine(CompressionConfig{Enabled: true, ExcludePaths: []string{"/stream"}})
+
+	w := getWithEncoding(r, "/small", "gzip")
+	assert.Empty(t, w.Header().Get("Content-Encoding"), "below min size")
+	assert.Equal(t, "tiny", w.Body.String())
+
+	w = getWithEncoding(r, "/binary", "gzip")
+	assert.Empty(t, w.Header().Get("Content-Encoding"), "content type not allowed")
+
+	w = getWithEncoding(r, "/stream/data", "gzip")
+	assert.Empty(t, w.Header().Get("Content-Encoding"), "excluded path")
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	prefs := []string{EncodingGzip, EncodingDeflate}
+	assert.Equal(t, EncodingGzip, negotiateEncoding("deflate, gzip", prefs))
+	assert.Equal(t, EncodingDeflate, negotiateEncoding("GZIP;q=0, deflate;q=0.5", prefs))
+	assert.Equal(t, "", negotiateEncoding("br", prefs))
+}
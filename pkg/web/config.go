@@ -1,6 +1,12 @@
 package web
 
-import "time"
+import (
+	"time"
+
+	"grouter/pkg/chaos"
+	"grouter/pkg/slo"
+	"grouter/pkg/telemetry"
+)
 
 // Config holds configuration for the Web Server
 type Config struct {
@@ -28,6 +34,9 @@ type Config struct {
 	// TLS configuration
 	TLS TLSConfig `mapstructure:"tls"`
 
+	// HTTP2 configuration
+	HTTP2 HTTP2Config `mapstructure:"http2"`
+
 	// CORS configuration
 	CORS CORSConfig `mapstructure:"cors"`
 
@@ -37,24 +46,121 @@ type Config struct {
 	// RateLimit configuration
 	RateLimit RateLimitConfig `mapstructure:"rate_limit"`
 
+	// Limits configuration (body size and timeouts)
+	Limits LimitsConfig `mapstructure:"limits"`
+
+	// LoadShedding configuration (429 under load)
+	LoadShedding LoadSheddingConfig `mapstructure:"load_shedding"`
+
+	// Chaos injects the faults of chaos experiments, none when nil
+	Chaos *chaos.Injector `mapstructure:"-"`
+
+	// SLO observes the requests of the http objectives, none when nil
+	SLO *slo.Tracker `mapstructure:"-"`
+
+	// Compression configuration
+	Compression CompressionConfig `mapstructure:"compression"`
+
+	// Session configuration (cookie sessions)
+	Session SessionConfig `mapstructure:"session"`
+
+	// Idempotency configuration (Idempotency-Key replay)
+	Idempotency IdempotencyConfig `mapstructure:"idempotency"`
+
+	// ResponseCache configuration (cached GET responses)
+	ResponseCache ResponseCacheConfig `mapstructure:"response_cache"`
+
 	// Swagger configuration
 	Swagger SwaggerConfig `mapstructure:"swagger"`
 
+	// Profiling configuration (pprof endpoints)
+	Profiling ProfilingConfig `mapstructure:"profiling"`
+
+	// OpenAPI configuration (document generated from the registered routes)
+	OpenAPI OpenAPIConfig `mapstructure:"openapi"`
+
 	// Logging configuration
 	Logging LoggingConfig `mapstructure:"logging"`
 	Auth    AuthConfig    `mapstructure:"auth"`
+
+	// Tenant configuration (tenant of the requests)
+	Tenant TenantConfig `mapstructure:"tenant"`
+
+	// SSE configuration
+	SSE SSEConfig `mapstructure:"sse"`
+
+	// NATSGateway configuration
+	NATSGateway NATSGatewayConfig `mapstructure:"nats_gateway"`
+
+	// Validation configuration (translated validation messages)
+	Validation ValidationConfig `mapstructure:"validation"`
+
+	// Versioning configuration (deprecation and sunset per API version)
+	Versioning VersioningConfig `mapstructure:"versioning"`
+}
+
+// TenantConfig holds configuration for the tenant of requests
+type TenantConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+
+	// Header is the header carrying the tenant
+	Header string `mapstructure:"header"`
+
+	// Claim is the token claim holding the tenant; it wins over the header
+	Claim string `mapstructure:"claim"`
+
+	// Required rejects requests without a tenant
+	Required bool `mapstructure:"required"`
+
+	// SkipPaths are route paths that need no tenant (e.g. health probes)
+	SkipPaths []string `mapstructure:"skip_paths"`
 }
 
+// AuthConfig holds configuration for authentication
 type AuthConfig struct {
-	Enabled  bool   `mapstructure:"enabled"`
+	Enabled bool `mapstructure:"enabled"`
+
+	// Mode is "required" (reject unauthenticated requests) or "optional"
+	// (populate the identity; route groups opt in with RequireAuth)
+	Mode string `mapstructure:"mode"`
+
+	// Issuer is the OIDC issuer used for discovery and the iss claim check
 	Issuer   string `mapstructure:"issuer"`
 	Audience string `mapstructure:"audience"`
+
+	// JWKSURL validates JWTs against this key set instead of OIDC discovery
+	JWKSURL string `mapstructure:"jwks_url"`
+
+	// Algorithms restricts the accepted signing algorithms (default RS256)
+	Algorithms []string `mapstructure:"algorithms"`
+
+	// RolesClaim is the token claim holding the caller's roles
+	RolesClaim string `mapstructure:"roles_claim"`
+
+	// APIKeyHeader is the header carrying static API keys
+	APIKeyHeader string `mapstructure:"api_key_header"`
+
+	// APIKeys are the accepted static API keys
+	APIKeys []APIKeyConfig `mapstructure:"api_keys"`
+
+	// SkipPaths are route paths that bypass authentication (e.g. health probes)
+	SkipPaths []string `mapstructure:"skip_paths"`
+}
+
+// APIKeyConfig holds a static API key and the identity it maps to
+type APIKeyConfig struct {
+	Name  string   `mapstructure:"name"`
+	Key   string   `mapstructure:"key"`
+	Roles []string `mapstructure:"roles"`
 }
 
 // MetricsConfig holds configuration for metrics
 type MetricsConfig struct {
 	Enabled bool   `mapstructure:"enabled"`
 	Path    string `mapstructure:"path"`
+	// Registry receives the HTTP metrics and is served on Path (nil uses the
+	// global registry)
+	Registry *telemetry.MetricsRegistry `mapstructure:"-"`
 }
 
 // TracingConfig holds configuration for tracing
@@ -68,6 +174,23 @@ type TLSConfig struct {
 	Enabled  bool   `mapstructure:"enabled"`
 	CertFile string `mapstructure:"cert_file"`
 	KeyFile  string `mapstructure:"key_file"`
+	// Reload watches the certificate files and reloads them on change or
+	// SIGHUP, without restarting the server
+	Reload bool `mapstructure:"reload"`
+	// ACME obtains and renews certificates automatically instead of reading
+	// CertFile and KeyFile
+	ACME ACMEConfig `mapstructure:"acme"`
+}
+
+// HTTP2Config holds configuration for HTTP/2. HTTP/1.1 is always served.
+type HTTP2Config struct {
+	// Enabled negotiates HTTP/2 over TLS with ALPN
+	Enabled bool `mapstructure:"enabled"`
+	// H2C serves unencrypted HTTP/2 with prior knowledge when TLS is disabled,
+	// e.g. behind a service mesh sidecar
+	H2C bool `mapstructure:"h2c"`
+	// MaxConcurrentStreams limits the streams per connection (0 = Go default)
+	MaxConcurrentStreams int `mapstructure:"max_concurrent_streams"`
 }
 
 // CORSConfig holds configuration for CORS
@@ -99,6 +222,33 @@ type RateLimitConfig struct {
 	Enabled           bool    `mapstructure:"enabled"`
 	RequestsPerSecond float64 `mapstructure:"requests_per_second"`
 	Burst             int     `mapstructure:"burst"`
+	// Key selects what clients are limited by: "ip" (default), "api_key" or "header"
+	Key string `mapstructure:"key"`
+	// Header is the header used by the "header" key (and the "api_key" fallback)
+	Header string `mapstructure:"header"`
+	// Store is "memory" (default) or "redis" for limits shared across instances
+	Store string               `mapstructure:"store"`
+	Redis RateLimitRedisConfig `mapstructure:"redis"`
+	// Routes override the limit for route groups by path prefix
+	Routes []RateLimitRoute `mapstructure:"routes"`
+}
+
+// RateLimitRedisConfig holds the Redis connection of a shared store (rate
+// limits, idempotency keys)
+type RateLimitRedisConfig struct {
+	Addr     string `mapstructure:"addr"`
+	Password string `mapstructure:"password"`
+	DB       int    `mapstructure:"db"`
+	Prefix   string `mapstructure:"prefix"`
+}
+
+// RateLimitRoute is the limit of a route group
+type RateLimitRoute struct {
+	PathPrefix        string  `mapstructure:"path_prefix"`
+	RequestsPerSecond float64 `mapstructure:"requests_per_second"`
+	Burst             int     `mapstructure:"burst"`
+	Key               string  `mapstructure:"key"`
+	Header            string  `mapstructure:"header"`
 }
 
 // SwaggerConfig holds configuration for Swagger documentation
@@ -120,6 +270,9 @@ func DefaultConfig() Config {
 		WriteTimeout:    10 * time.Second,
 		ShutdownTimeout: 5 * time.Second,
 		Mode:            "release",
+		HTTP2: HTTP2Config{
+			Enabled: true,
+		},
 		Metrics: MetricsConfig{
 			Enabled: true,
 			Path:    "/metrics",
@@ -137,6 +290,9 @@ func DefaultConfig() Config {
 			Enabled: true,
 			Path:    "/swagger",
 		},
+		OpenAPI: OpenAPIConfig{
+			Path: "/openapi.json",
+		},
 		Logging: LoggingConfig{
 			Enabled: true,
 		},
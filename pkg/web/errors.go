@@ -0,0 +1,244 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// MIMEProblemJSON is the media type of error responses (RFC 9457)
+const MIMEProblemJSON = "application/problem+json"
+
+// Error codes used by the helpers
+const (
+	CodeBadRequest      = "bad_request"
+	CodeValidation      = "validation_failed"
+	CodeUnauthorized    = "unauthorized"
+	CodeForbidden       = "forbidden"
+	CodeNotFound        = "not_found"
+	CodeConflict        = "conflict"
+	CodeTooLarge        = "request_too_large"
+	CodeTooManyRequests = "too_many_requests"
+	CodeInternal        = "internal"
+	CodeUnavailable     = "unavailable"
+	CodeTimeout         = "timeout"
+	// CodeIdempotencyKeyReused is returned when an idempotency key is sent
+	// again with a different request
+	CodeIdempotencyKeyReused = "idempotency_key_reused"
+	// CodeInjectedFault is returned by the error faults of chaos experiments
+	CodeInjectedFault = "injected_fault"
+)
+
+// Error is the error response shared by all services. It is written as a
+// problem details document with the code, details and request ID as
+// extension members.
+type Error struct {
+	// Status is the HTTP status code
+	Status int
+	// Code is a stable, machine readable error code
+	Code string
+	// Message is a human readable explanation, sent as "detail"
+	Message string
+	// Details carries structured data such as field errors
+	Details interface{}
+	// RequestID is filled from the request when the error is written
+	RequestID string
+	// Err is the underlying cause; it is logged but never sent
+	Err error
+}
+
+// NewError creates an Error
+func NewError(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// BadRequest returns a 400 error
+func BadRequest(message string) *Error {
+	return NewError(http.StatusBadRequest, CodeBadRequest, message)
+}
+
+// Unauthorized returns a 401 error
+func Unauthorized(message string) *Error {
+	return NewError(http.StatusUnauthorized, CodeUnauthorized, message)
+}
+
+// Forbidden returns a 403 error
+func Forbidden(message string) *Error {
+	return NewError(http.StatusForbidden, CodeForbidden, message)
+}
+
+// NotFound returns a 404 error
+func NotFound(message string) *Error {
+	return NewError(http.StatusNotFound, CodeNotFound, message)
+}
+
+// Conflict returns a 409 error
+func Conflict(message string) *Error {
+	return NewError(http.StatusConflict, CodeConflict, message)
+}
+
+// Unavailable returns a 503 error
+func Unavailable(message string) *Error {
+	return NewError(http.StatusServiceUnavailable, CodeUnavailable, message)
+}
+
+// Internal returns a 500 error wrapping err. The cause is not sent to the
+// client.
+func Internal(err error) *Error {
+	e := NewError(http.StatusInternalServerError, CodeInternal, "internal server error")
+	e.Err = err
+	return e
+}
+
+// WithDetails sets the structured details
+func (e *Error) WithDetails(details interface{}) *Error {
+	e.Details = details
+	return e
+}
+
+// Wrap sets the underlying cause
+func (e *Error) Wrap(err error) *Error {
+	e.Err = err
+	return e
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// MarshalJSON writes the problem details document
+func (e *Error) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type      string      `json:"type"`
+		Title     string      `json:"title"`
+		Status    int         `json:"status"`
+		Detail    string      `json:"detail,omitempty"`
+		Code      string      `json:"code"`
+		Details   interface{} `json:"details,omitempty"`
+		RequestID string      `json:"request_id,omitempty"`
+	}{
+		Type:      "about:blank",
+		Title:     http.StatusText(e.Status),
+		Status:    e.Status,
+		Detail:    e.Message,
+		Code:      e.Code,
+		Details:   e.Details,
+		RequestID: e.RequestID,
+	})
+}
+
+// StatusCoder is implemented by errors that know their HTTP status
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// AsError converts err into an Error. *Error values (also wrapped) are kept,
+// errors implementing StatusCoder keep their status, context deadlines become
+// 504 and anything else 500.
+func AsError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	var sc StatusCoder
+	if errors.As(err, &sc) {
+		status := sc.StatusCode()
+		code := CodeInternal
+		if status < http.StatusInternalServerError {
+			code = CodeBadRequest
+		}
+		msg := err.Error()
+		if status >= http.StatusInternalServerError {
+			msg = http.StatusText(status)
+		}
+		return NewError(status, code, msg).Wrap(err)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return NewError(http.StatusGatewayTimeout, CodeTimeout, "request timed out").Wrap(err)
+	}
+	return Internal(err)
+}
+
+// AbortWithError writes err as a problem details response and aborts the
+// chain
+func AbortWithError(c *gin.Context, err error) {
+	e := AsError(err)
+	// Copy so that shared sentinel errors are not mutated
+	resp := *e
+	resp.RequestID = c.GetString("RequestID")
+	c.Abort()
+	c.Header("Content-Type", MIMEProblemJSON)
+	c.Render(resp.Status, problemRender{&resp})
+}
+
+// ErrorMiddleware turns errors added with c.Error, and panics, into problem
+// details responses. Handlers either return web errors directly with
+// AbortWithError or record them with c.Error and return.
+func ErrorMiddleware(logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+			if r == http.ErrAbortHandler {
+				panic(r)
+			}
+			logger.Error("HTTP handler panic",
+				zap.String("request_id", c.GetString("RequestID")),
+				zap.String("method", c.Request.Method),
+				zap.String("path", c.Request.URL.Path),
+				zap.Any("panic", r),
+				zap.ByteString("stack", debug.Stack()),
+			)
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+			AbortWithError(c, Internal(nil))
+		}()
+
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		err := c.Errors.Last()
+		e := AsError(err)
+		if e.Status >= http.StatusInternalServerError {
+			logger.Error("HTTP handler error",
+				zap.String("request_id", c.GetString("RequestID")),
+				zap.String("path", c.Request.URL.Path),
+				zap.Int("status", e.Status),
+				zap.Error(err.Err),
+			)
+		}
+		AbortWithError(c, e)
+	}
+}
+
+// problemRender renders an Error with the problem details media type
+type problemRender struct {
+	err *Error
+}
+
+func (r problemRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return json.NewEncoder(w).Encode(r.err)
+}
+
+func (r problemRender) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", MIMEProblemJSON)
+}
@@ -0,0 +1,108 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type teapotError struct{}
+
+func (teapotError) Error() string   { return "short and stout" }
+func (teapotError) StatusCode() int { return http.StatusTeapot }
+
+func newErrorEngine() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestIDMiddleware(), ErrorMiddleware(zap.NewNop()))
+	r.GET("/missing", func(c *gin.Context) {
+		AbortWithError(c, NotFound("widget not found").WithDetails(map[string]string{"id": "42"}))
+	})
+	r.GET("/recorded", func(c *gin.Context) {
+		_ = c.Error(fmt.Errorf("loading widget: %w", Conflict("widget is locked")))
+	})
+	r.GET("/internal", func(c *gin.Context) {
+		_ = c.Error(errors.New("db password is hunter2"))
+	})
+	r.GET("/teapot", func(c *gin.Context) { _ = c.Error(teapotError{}) })
+	r.GET("/deadline", func(c *gin.Context) { _ = c.Error(context.DeadlineExceeded) })
+	r.GET("/panic", func(c *gin.Context) { panic("boom") })
+	r.GET("/written", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+		_ = c.Error(errors.New("after write"))
+	})
+	return r
+}
+
+func doErrorRequest(t *testing.T, r *gin.Engine, path string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set(HeaderXRequestID, "req-1")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var body map[string]interface{}
+	if w.Header().Get("Content-Type") == MIMEProblemJSON {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	}
+	return w, body
+}
+
+func TestErrorMiddleware(t *testing.T) {
+	r := newErrorEngine()
+
+	w, body := doErrorRequest(t, r, "/missing")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, map[string]interface{}{
+		"type":       "about:blank",
+		"title":      "Not Found",
+		"status":     float64(404),
+		"detail":     "widget not found",
+		"code":       CodeNotFound,
+		"details":    map[string]interface{}{"id": "42"},
+		"request_id": "req-1",
+	}, body)
+
+	tests := []struct {
+		path   string
+		status int
+		code   string
+		detail string
+	}{
+		{"/recorded", http.StatusConflict, CodeConflict, "widget is locked"},
+		{"/internal", http.StatusInternalServerError, CodeInternal, "internal server error"},
+		{"/teapot", http.StatusTeapot, CodeBadRequest, "short and stout"},
+		{"/deadline", http.StatusGatewayTimeout, CodeTimeout, "request timed out"},
+		{"/panic", http.StatusInternalServerError, CodeInternal, "internal server error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w, body := doErrorRequest(t, r, tt.path)
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, tt.code, body["code"])
+			assert.Equal(t, tt.detail, body["detail"], "causes of 5xx errors are not exposed")
+			assert.Equal(t, "req-1", body["request_id"])
+		})
+	}
+
+	// Errors recorded after the response was written are only logged
+	w, _ = doErrorRequest(t, r, "/written")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "ok", w.Body.String())
+}
+
+func TestError_Unwrap(t *testing.T) {
+	cause := errors.New("no rows")
+	err := NotFound("widget not found").Wrap(cause)
+	assert.ErrorIs(t, err, cause)
+	assert.Equal(t, "widget not found: no rows", err.Error())
+	assert.Same(t, err, AsError(fmt.Errorf("handler: %w", err)))
+}
@@ -0,0 +1,496 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// HeaderIdempotencyKey is the default header carrying the idempotency key
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+// HeaderIdempotentReplayed marks responses replayed from the idempotency store
+const HeaderIdempotentReplayed = "Idempotent-Replayed"
+
+// Idempotency stores
+const (
+	IdempotencyStoreMemory = "memory"
+	IdempotencyStoreRedis  = "redis"
+)
+
+// IdempotencyConfig holds configuration for the Idempotency-Key middleware
+type IdempotencyConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Header carries the key (default Idempotency-Key)
+	Header string `mapstructure:"header"`
+	// TTL is how long responses are kept for replay (default 24h)
+	TTL time.Duration `mapstructure:"ttl"`
+	// LockTimeout bounds how long a key stays reserved by a request in flight
+	// (default 1m)
+	LockTimeout time.Duration `mapstructure:"lock_timeout"`
+	// Methods the middleware applies to (default POST and PATCH)
+	Methods []string `mapstructure:"methods"`
+	// Paths are the path prefixes the middleware applies to (default all)
+	Paths []string `mapstructure:"paths"`
+	// Required rejects requests without a key on the covered routes
+	Required bool `mapstructure:"required"`
+	// MaxBodySize is the largest response that is stored (default 1MB);
+	// larger responses are not replayed
+	MaxBodySize int `mapstructure:"max_body_size"`
+	// Store is "memory" (default) or "redis". Services with a database can
+	// pass a GormIdempotencyStore to IdempotencyMiddleware instead.
+	Store string               `mapstructure:"store"`
+	Redis RateLimitRedisConfig `mapstructure:"redis"`
+}
+
+// CachedResponse is a response stored for replay
+type CachedResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+	// Fingerprint identifies the request the response belongs to
+	Fingerprint string `json:"fingerprint"`
+}
+
+// IdempotencyStore keeps responses by idempotency key. Implementations must be
+// safe for concurrent use; shared stores deduplicate across instances.
+type IdempotencyStore interface {
+	// Begin reserves key for a request. It returns the stored response if the
+	// key has completed, or acquired=false while another request holds it.
+	Begin(ctx context.Context, key string, lockTimeout time.Duration) (resp *CachedResponse, acquired bool, err error)
+	// Complete stores the response of a reserved key for ttl
+	Complete(ctx context.Context, key string, resp *CachedResponse, ttl time.Duration) error
+	// Release frees a reserved key without storing a response, so that the
+	// request can be retried
+	Release(ctx context.Context, key string) error
+}
+
+// ErrIdempotencyInProgress is returned when a request with the same key is
+// still being processed
+var ErrIdempotencyInProgress = errors.New("a request with this idempotency key is in progress")
+
+// NewIdempotencyStore creates the store selected in the configuration
+func NewIdempotencyStore(cfg IdempotencyConfig) (IdempotencyStore, error) {
+	switch cfg.Store {
+	case "", IdempotencyStoreMemory:
+		return NewMemoryIdempotencyStore(), nil
+	case IdempotencyStoreRedis:
+		if cfg.Redis.Addr == "" {
+			return nil, fmt.Errorf("redis idempotency store requires an address")
+		}
+		client := redis.NewClient(&redis.Options{
+			Addr:     cfg.Redis.Addr,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+		})
+		return NewRedisIdempotencyStore(client, cfg.Redis.Prefix), nil
+	default:
+		return nil, fmt.Errorf("invalid idempotency store %q", cfg.Store)
+	}
+}
+
+// IdempotencyFromConfig creates the middleware and its store from the
+// configuration
+func IdempotencyFromConfig(cfg IdempotencyConfig) (gin.HandlerFunc, error) {
+	store, err := NewIdempotencyStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return IdempotencyMiddleware(cfg, store), nil
+}
+
+// IdempotencyMiddleware replays the stored response of requests that repeat an
+// Idempotency-Key, so that clients can safely retry. Keys are scoped to the
+// authenticated identity. Responses with a 5xx status are not stored. Reusing
+// a key for a different request is rejected with 422.
+func IdempotencyMiddleware(cfg IdempotencyConfig, store IdempotencyStore) gin.HandlerFunc {
+	if cfg.Header == "" {
+		cfg.Header = HeaderIdempotencyKey
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = 24 * time.Hour
+	}
+	if cfg.LockTimeout <= 0 {
+		cfg.LockTimeout = time.Minute
+	}
+	if len(cfg.Methods) == 0 {
+		cfg.Methods = []string{http.MethodPost, http.MethodPatch}
+	}
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = 1 << 20
+	}
+	methods := make(map[string]bool, len(cfg.Methods))
+	for _, m := range cfg.Methods {
+		methods[strings.ToUpper(m)] = true
+	}
+
+	return func(c *gin.Context) {
+		if !methods[c.Request.Method] || (len(cfg.Paths) > 0 && !hasPrefixAny(c.Request.URL.Path, cfg.Paths)) {
+			c.Next()
+			return
+		}
+		key := c.GetHeader(cfg.Header)
+		if key == "" {
+			if cfg.Required {
+				AbortWithError(c, BadRequest(cfg.Header+" header is required"))
+				return
+			}
+			c.Next()
+			return
+		}
+		if len(key) > 255 {
+			AbortWithError(c, BadRequest(cfg.Header+" header is too long"))
+			return
+		}
+
+		fingerprint, err := requestFingerprint(c)
+		if err != nil {
+			AbortWithError(c, BadRequest("failed to read request body").Wrap(err))
+			return
+		}
+		if id, ok := IdentityFromContext(c); ok {
+			key = id.Subject + ":" + key
+		}
+		ctx := c.Request.Context()
+
+		cached, acquired, err := store.Begin(ctx, key, cfg.LockTimeout)
+		if err != nil {
+			AbortWithError(c, Unavailable("idempotency store unavailable").Wrap(err))
+			return
+		}
+		if cached != nil {
+			if cached.Fingerprint != fingerprint {
+				AbortWithError(c, NewError(http.StatusUnprocessableEntity, CodeIdempotencyKeyReused,
+					"idempotency key was used for a different request"))
+				return
+			}
+			replay(c, cached)
+			return
+		}
+		if !acquired {
+			AbortWithError(c, NewError(http.StatusConflict, CodeConflict, ErrIdempotencyInProgress.Error()))
+			return
+		}
+
+		w := &recordingWriter{ResponseWriter: c.Writer, limit: cfg.MaxBodySize}
+		c.Writer = w
+		completed := false
+		defer func() {
+			c.Writer = w.ResponseWriter
+			if !completed {
+				// Panics and failures leave the key free for a retry
+				_ = store.Release(context.WithoutCancel(ctx), key)
+			}
+		}()
+
+		c.Next()
+
+		if w.Status() >= http.StatusInternalServerError || w.overflow {
+			return
+		}
+		resp := &CachedResponse{
+			Status:      w.Status(),
+			Header:      w.Header().Clone(),
+			Body:        w.body.Bytes(),
+			Fingerprint: fingerprint,
+		}
+		if err := store.Complete(context.WithoutCancel(ctx), key, resp, cfg.TTL); err == nil {
+			completed = true
+		}
+	}
+}
+
+// requestFingerprint hashes the method, path and body of the request. The body
+// is restored for the handler.
+func requestFingerprint(c *gin.Context) (string, error) {
+	h := sha256.New()
+	h.Write([]byte(c.Request.Method + " " + c.Request.URL.Path + "\n"))
+	if c.Request.Body != nil {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return "", err
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		h.Write(body)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func replay(c *gin.Context, resp *CachedResponse) {
+	h := c.Writer.Header()
+	for k, v := range resp.Header {
+		h[k] = v
+	}
+	h.Set(HeaderIdempotentReplayed, "true")
+	c.Abort()
+	c.Status(resp.Status)
+	_, _ = c.Writer.Write(resp.Body)
+}
+
+// recordingWriter copies the response body up to limit bytes
+type recordingWriter struct {
+	gin.ResponseWriter
+	body     bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.record(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.record([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *recordingWriter) record(data []byte) {
+	if w.overflow {
+		return
+	}
+	if w.body.Len()+len(data) > w.limit {
+		w.overflow = true
+		w.body.Reset()
+		return
+	}
+	w.body.Write(data)
+}
+
+// MemoryIdempotencyStore keeps responses in process memory
+type MemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	entries   map[string]*memoryIdempotencyEntry
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type memoryIdempotencyEntry struct {
+	resp    *CachedResponse
+	expires time.Time
+}
+
+// NewMemoryIdempotencyStore creates an in-memory store
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		entries: make(map[string]*memoryIdempotencyEntry),
+		now:     time.Now,
+	}
+}
+
+// Begin reserves the key or returns its stored response
+func (s *MemoryIdempotencyStore) Begin(_ context.Context, key string, lockTimeout time.Duration) (*CachedResponse, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.lastSweep) > time.Minute {
+		for k, e := range s.entries {
+			if now.After(e.expires) {
+				delete(s.entries, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	if e, ok := s.entries[key]; ok && now.Before(e.expires) {
+		return e.resp, false, nil
+	}
+	s.entries[key] = &memoryIdempotencyEntry{expires: now.Add(lockTimeout)}
+	return nil, true, nil
+}
+
+// Complete stores the response
+func (s *MemoryIdempotencyStore) Complete(_ context.Context, key string, resp *CachedResponse, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = &memoryIdempotencyEntry{resp: resp, expires: s.now().Add(ttl)}
+	return nil
+}
+
+// Release frees the key
+func (s *MemoryIdempotencyStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[key]; ok && e.resp == nil {
+		delete(s.entries, key)
+	}
+	return nil
+}
+
+// redisIdempotencyLock is the value of a reserved key
+const redisIdempotencyLock = "in-progress"
+
+// RedisIdempotencyStore keeps responses in Redis so that retries are
+// deduplicated across instances
+type RedisIdempotencyStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisIdempotencyStore creates a Redis backed store. Keys are prefixed
+// with prefix (default "idempotency:").
+func NewRedisIdempotencyStore(client redis.UniversalClient, prefix string) *RedisIdempotencyStore {
+	if prefix == "" {
+		prefix = "idempotency:"
+	}
+	return &RedisIdempotencyStore{client: client, prefix: prefix}
+}
+
+// Begin reserves the key or returns its stored response
+func (s *RedisIdempotencyStore) Begin(ctx context.Context, key string, lockTimeout time.Duration) (*CachedResponse, bool, error) {
+	ok, err := s.client.SetNX(ctx, s.prefix+key, redisIdempotencyLock, lockTimeout).Result()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+	if ok {
+		return nil, true, nil
+	}
+	val, err := s.client.Get(ctx, s.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) || (err == nil && string(val) == redisIdempotencyLock) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read idempotency key: %w", err)
+	}
+	var resp CachedResponse
+	if err := json.Unmarshal(val, &resp); err != nil {
+		return nil, false, fmt.Errorf("failed to decode idempotent response: %w", err)
+	}
+	return &resp, false, nil
+}
+
+// Complete stores the response
+func (s *RedisIdempotencyStore) Complete(ctx context.Context, key string, resp *CachedResponse, ttl time.Duration) error {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("failed to encode idempotent response: %w", err)
+	}
+	if err := s.client.Set(ctx, s.prefix+key, data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to store idempotent response: %w", err)
+	}
+	return nil
+}
+
+// Release frees the key if it is still reserved
+func (s *RedisIdempotencyStore) Release(ctx context.Context, key string) error {
+	val, err := s.client.Get(ctx, s.prefix+key).Result()
+	if errors.Is(err, redis.Nil) || val != redisIdempotencyLock {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read idempotency key: %w", err)
+	}
+	return s.client.Del(ctx, s.prefix+key).Err()
+}
+
+// IdempotencyRecord is the database row of a GormIdempotencyStore
+type IdempotencyRecord struct {
+	IdempotencyKey string `gorm:"primaryKey;size:320"`
+	Completed      bool
+	Status         int
+	Header         []byte
+	Body           []byte
+	Fingerprint    string    `gorm:"size:64"`
+	ExpiresAt      time.Time `gorm:"index"`
+}
+
+// TableName returns the table of the records
+func (IdempotencyRecord) TableName() string {
+	return "idempotency_keys"
+}
+
+// GormIdempotencyStore keeps responses in a database table
+type GormIdempotencyStore struct {
+	db  *gorm.DB
+	now func() time.Time
+}
+
+// NewGormIdempotencyStore creates a database backed store and migrates its
+// table
+func NewGormIdempotencyStore(db *gorm.DB) (*GormIdempotencyStore, error) {
+	if err := db.AutoMigrate(&IdempotencyRecord{}); err != nil {
+		return nil, fmt.Errorf("failed to migrate idempotency table: %w", err)
+	}
+	return &GormIdempotencyStore{db: db, now: time.Now}, nil
+}
+
+// Begin reserves the key or returns its stored response
+func (s *GormIdempotencyStore) Begin(ctx context.Context, key string, lockTimeout time.Duration) (*CachedResponse, bool, error) {
+	now := s.now()
+	db := s.db.WithContext(ctx)
+
+	// Expired records, completed or abandoned, no longer hold the key
+	if err := db.Where("idempotency_key = ? AND expires_at < ?", key, now).Delete(&IdempotencyRecord{}).Error; err != nil {
+		return nil, false, fmt.Errorf("failed to expire idempotency key: %w", err)
+	}
+	res := db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&IdempotencyRecord{IdempotencyKey: key, ExpiresAt: now.Add(lockTimeout)})
+	if res.Error != nil {
+		return nil, false, fmt.Errorf("failed to reserve idempotency key: %w", res.Error)
+	}
+	if res.RowsAffected == 1 {
+		return nil, true, nil
+	}
+
+	var rec IdempotencyRecord
+	if err := db.Where("idempotency_key = ?", key).Take(&rec).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to read idempotency key: %w", err)
+	}
+	if !rec.Completed {
+		return nil, false, nil
+	}
+	resp := &CachedResponse{Status: rec.Status, Body: rec.Body, Fingerprint: rec.Fingerprint}
+	if len(rec.Header) > 0 {
+		if err := json.Unmarshal(rec.Header, &resp.Header); err != nil {
+			return nil, false, fmt.Errorf("failed to decode idempotent response headers: %w", err)
+		}
+	}
+	return resp, false, nil
+}
+
+// Complete stores the response
+func (s *GormIdempotencyStore) Complete(ctx context.Context, key string, resp *CachedResponse, ttl time.Duration) error {
+	header, err := json.Marshal(resp.Header)
+	if err != nil {
+		return fmt.Errorf("failed to encode idempotent response headers: %w", err)
+	}
+	err = s.db.WithContext(ctx).Model(&IdempotencyRecord{}).Where("idempotency_key = ?", key).Updates(map[string]interface{}{
+		"completed":   true,
+		"status":      resp.Status,
+		"header":      header,
+		"body":        resp.Body,
+		"fingerprint": resp.Fingerprint,
+		"expires_at":  s.now().Add(ttl),
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to store idempotent response: %w", err)
+	}
+	return nil
+}
+
+// Release frees the key if it is still reserved
+func (s *GormIdempotencyStore) Release(ctx context.Context, key string) error {
+	err := s.db.WithContext(ctx).Where("idempotency_key = ? AND completed = ?", key, false).Delete(&IdempotencyRecord{}).Error
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newIdempotencyEngine(store IdempotencyStore, calls *int64) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(ErrorMiddleware(zap.NewNop()), IdempotencyMiddleware(IdempotencyConfig{}, store))
+	r.POST("/orders", func(c *gin.Context) {
+		n := atomic.AddInt64(calls, 1)
+		c.Header("Location", fmt.Sprintf("/orders/%d", n))
+		c.JSON(http.StatusCreated, gin.H{"id": n})
+	})
+	r.POST("/fail", func(c *gin.Context) {
+		atomic.AddInt64(calls, 1)
+		c.Status(http.StatusBadGateway)
+	})
+	return r
+}
+
+func postIdempotent(r *gin.Engine, path, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(HeaderIdempotencyKey, key)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func testIdempotencyStore(t *testing.T, store IdempotencyStore) {
+	var calls int64
+	r := newIdempotencyEngine(store, &calls)
+
+	first := postIdempotent(r, "/orders", "k1", `{"sku":"a"}`)
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Empty(t, first.Header().Get(HeaderIdempotentReplayed))
+
+	// A retry replays the stored response without running the handler
+	retry := postIdempotent(r, "/orders", "k1", `{"sku":"a"}`)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, "/orders/1", retry.Header().Get("Location"))
+	assert.Equal(t, "true", retry.Header().Get(HeaderIdempotentReplayed))
+	assert.Equal(t, int64(1), atomic.LoadInt64(&calls))
+
+	// Same key, different request
+	w := postIdempotent(r, "/orders", "k1", `{"sku":"b"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), CodeIdempotencyKeyReused)
+
+	// Without a key every request runs
+	postIdempotent(r, "/orders", "", `{}`)
+	postIdempotent(r, "/orders", "", `{}`)
+	assert.Equal(t, int64(3), atomic.LoadInt64(&calls))
+
+	// Server errors are not stored, so the retry runs again
+	postIdempotent(r, "/fail", "k2", "")
+	postIdempotent(r, "/fail", "k2", "")
+	assert.Equal(t, int64(5), atomic.LoadInt64(&calls))
+
+	// A key held by a request in flight is reported as a conflict
+	_, acquired, err := store.Begin(context.Background(), "busy", time.Minute)
+	require.NoError(t, err)
+	require.True(t, acquired)
+	w = postIdempotent(r, "/orders", "busy", "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	require.NoError(t, store.Release(context.Background(), "busy"))
+	w = postIdempotent(r, "/orders", "busy", "")
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestIdempotencyMiddleware_Memory(t *testing.T) {
+	testIdempotencyStore(t, NewMemoryIdempotencyStore())
+}
+
+func TestIdempotencyMiddleware_Redis(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	testIdempotencyStore(t, NewRedisIdempotencyStore(client, ""))
+	assert.True(t, mr.Exists("idempotency:k1"))
+}
+
+func TestIdempotencyMiddleware_Gorm(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "idem.db")), &gorm.Config{})
+	require.NoError(t, err)
+	store, err := NewGormIdempotencyStore(db)
+	require.NoError(t, err)
+	testIdempotencyStore(t, store)
+
+	// Expired records free the key
+	store.now = func() time.Time { return time.Now().Add(48 * time.Hour) }
+	_, acquired, err := store.Begin(context.Background(), "k1", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, acquired)
+}
+
+func TestIdempotencyMiddleware_Required(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(IdempotencyMiddleware(IdempotencyConfig{Required: true, Paths: []string{"/orders"}}, NewMemoryIdempotencyStore()))
+	r.POST("/orders", func(c *gin.Context) { c.Status(http.StatusCreated) })
+	r.POST("/other", func(c *gin.Context) { c.Status(http.StatusCreated) })
+
+	assert.Equal(t, http.StatusBadRequest, postIdempotent(r, "/orders", "", "").Code)
+	assert.Equal(t, http.StatusCreated, postIdempotent(r, "/other", "", "").Code)
+}
+
+func TestNewIdempotencyStore(t *testing.T) {
+	_, err := NewIdempotencyStore(IdempotencyConfig{Store: "redis"})
+	assert.Error(t, err)
+	_, err = NewIdempotencyStore(IdempotencyConfig{Store: "etcd"})
+	assert.Error(t, err)
+	store, err := NewIdempotencyStore(IdempotencyConfig{})
+	require.NoError(t, err)
+	assert.IsType(t, &MemoryIdempotencyStore{}, store)
+}
@@ -2,12 +2,13 @@ package web
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"testing"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 
 	"grouter/pkg/health"
@@ -34,40 +35,16 @@ func TestServerIntegration(t *testing.T) {
 	cfg.Swagger.Enabled = false // Disable swagger for test to avoid dependency issues
 
 	healthSvc := health.NewHealthService()
-	server := NewWebServer(cfg, logger, healthSvc)
+	server := newTestServer(t, cfg, logger, healthSvc)
 	server.RegisterWebService(&IntegrationTestService{})
 
-	// Start server in goroutine
-	go func() {
-		if err := server.Start(); err != nil && err != http.ErrServerClosed {
-			t.Errorf("Failed to start server: %v", err)
-		}
-	}()
-
-	// Wait for server to start
-	time.Sleep(200 * time.Millisecond)
-	defer server.Stop(context.Background())
-
-	// Get the actual port (since we used 0)
-	// In a real scenario we'd need a way to get the listener address,
-	// but for this test we can assume it bound to the port in config if it was non-zero.
-	// Since we used 0, we need to find the port.
-	// However, Server struct doesn't expose the listener.
-	// For integration test, let's use a fixed port to simplify, or we'd need to modify Server to expose Addr.
-	// Let's use a fixed port that is likely free.
-
-	// RESTART with fixed port
-	server.Stop(context.Background())
-	cfg.Port = 18085
-	server = NewWebServer(cfg, logger, healthSvc)
-	server.RegisterWebService(&IntegrationTestService{})
-	go func() {
-		server.Start()
-	}()
-	time.Sleep(200 * time.Millisecond)
+	require.NoError(t, server.Start())
 	defer server.Stop(context.Background())
 
-	baseURL := "http://localhost:18085"
+	// Port 0 binds a random port; Port reports it
+	require.NotZero(t, server.Port())
+	assert.NotEmpty(t, server.Addr())
+	baseURL := fmt.Sprintf("http://localhost:%d", server.Port())
 
 	// Test 1: Service Endpoint
 	resp, err := http.Get(baseURL + "/integration")
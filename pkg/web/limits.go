@@ -0,0 +1,204 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LimitsConfig bounds request bodies and processing time. Route groups can
+// override the defaults by path prefix; zero values keep the default.
+type LimitsConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// MaxBodySize is the maximum request body size in bytes (0 = unlimited).
+	// Larger bodies are rejected with 413.
+	MaxBodySize int64 `mapstructure:"max_body_size"`
+	// ReadTimeout is the deadline for reading the request body. Slow clients
+	// get 408. It replaces the server read timeout for the request, so upload
+	// routes can allow more time than the rest of the API.
+	ReadTimeout time.Duration `mapstructure:"read_timeout"`
+	// HandlerTimeout cancels the request context after the duration. Handlers
+	// that return without responding get 503.
+	HandlerTimeout time.Duration `mapstructure:"handler_timeout"`
+	// Routes override the limits for route groups
+	Routes []RouteLimits `mapstructure:"routes"`
+}
+
+// RouteLimits are the limits of the routes under PathPrefix
+type RouteLimits struct {
+	PathPrefix     string        `mapstructure:"path_prefix"`
+	MaxBodySize    int64         `mapstructure:"max_body_size"`
+	ReadTimeout    time.Duration `mapstructure:"read_timeout"`
+	HandlerTimeout time.Duration `mapstructure:"handler_timeout"`
+}
+
+// RequestLimitsMiddleware applies the limits of the longest matching route
+// prefix, or the defaults
+func RequestLimitsMiddleware(cfg LimitsConfig) gin.HandlerFunc {
+	def := RouteLimits{
+		MaxBodySize:    cfg.MaxBodySize,
+		ReadTimeout:    cfg.ReadTimeout,
+		HandlerTimeout: cfg.HandlerTimeout,
+	}
+
+	routes := make([]RouteLimits, 0, len(cfg.Routes))
+	for _, r := range cfg.Routes {
+		if r.MaxBodySize == 0 {
+			r.MaxBodySize = def.MaxBodySize
+		}
+		if r.ReadTimeout == 0 {
+			r.ReadTimeout = def.ReadTimeout
+		}
+		if r.HandlerTimeout == 0 {
+			r.HandlerTimeout = def.HandlerTimeout
+		}
+		routes = append(routes, r)
+	}
+	sort.SliceStable(routes, func(i, j int) bool {
+		return len(routes[i].PathPrefix) > len(routes[j].PathPrefix)
+	})
+
+	return func(c *gin.Context) {
+		limits := def
+		for _, r := range routes {
+			if strings.HasPrefix(c.Request.URL.Path, r.PathPrefix) {
+				limits = r
+				break
+			}
+		}
+		applyLimits(c, limits)
+	}
+}
+
+// BodyLimit limits the request body size of the routes it is attached to:
+//
+//	uploads := router.Group("/uploads", web.BodyLimit(50<<20))
+func BodyLimit(maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		applyLimits(c, RouteLimits{MaxBodySize: maxBytes})
+	}
+}
+
+// HandlerTimeout cancels the request context of the routes it is attached to
+// after the duration
+func HandlerTimeout(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		applyLimits(c, RouteLimits{HandlerTimeout: d})
+	}
+}
+
+func applyLimits(c *gin.Context, l RouteLimits) {
+	if l.MaxBodySize > 0 && c.Request.ContentLength > l.MaxBodySize {
+		AbortWithError(c, NewError(http.StatusRequestEntityTooLarge, CodeTooLarge, "request body too large"))
+		return
+	}
+
+	body := &limitedBody{ReadCloser: c.Request.Body}
+	if l.MaxBodySize > 0 && c.Request.Body != nil {
+		body.ReadCloser = http.MaxBytesReader(c.Writer, c.Request.Body, l.MaxBodySize)
+	}
+	if c.Request.Body != nil {
+		c.Request.Body = body
+		c.Writer = &limitsWriter{ResponseWriter: c.Writer, body: body}
+	}
+
+	if l.ReadTimeout > 0 {
+		// Not every writer supports deadlines (e.g. in tests); the server read
+		// timeout still applies then
+		_ = http.NewResponseController(c.Writer).SetReadDeadline(time.Now().Add(l.ReadTimeout))
+	}
+
+	if l.HandlerTimeout > 0 {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), l.HandlerTimeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+	}
+
+	c.Next()
+
+	if c.Writer.Written() {
+		return
+	}
+	if status, ok := bodyErrorStatus(body.Err()); ok {
+		code := CodeTimeout
+		if status == http.StatusRequestEntityTooLarge {
+			code = CodeTooLarge
+		}
+		AbortWithError(c, NewError(status, code, http.StatusText(status)))
+		return
+	}
+	if errors.Is(c.Request.Context().Err(), context.DeadlineExceeded) {
+		AbortWithError(c, NewError(http.StatusServiceUnavailable, CodeTimeout, "request timed out"))
+	}
+}
+
+// bodyErrorStatus maps body read errors to 413 and 408
+func bodyErrorStatus(err error) (int, bool) {
+	if err == nil {
+		return 0, false
+	}
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return http.StatusRequestEntityTooLarge, true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return http.StatusRequestTimeout, true
+	}
+	return 0, false
+}
+
+// limitedBody records the first read error of the request body
+type limitedBody struct {
+	io.ReadCloser
+	mu  sync.Mutex
+	err error
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		b.mu.Lock()
+		if b.err == nil {
+			b.err = err
+		}
+		b.mu.Unlock()
+	}
+	return n, err
+}
+
+// Err returns the first read error, if any
+func (b *limitedBody) Err() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.err
+}
+
+// limitsWriter replaces the error status of handlers that failed to read the
+// body (usually 400 from binding) with 413 or 408
+type limitsWriter struct {
+	gin.ResponseWriter
+	body *limitedBody
+}
+
+func (w *limitsWriter) WriteHeader(code int) {
+	if code >= http.StatusBadRequest {
+		if status, ok := bodyErrorStatus(w.body.Err()); ok {
+			code = status
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap returns the underlying writer for http.ResponseController
+func (w *limitsWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,122 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newLimitsEngine(cfg LimitsConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestLimitsMiddleware(cfg))
+
+	bind := func(c *gin.Context) {
+		var body map[string]interface{}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, body)
+	}
+	r.POST("/api/items", bind)
+	r.POST("/api/uploads", bind)
+	r.POST("/api/raw", func(c *gin.Context) {
+		// Ignores the read error without responding
+		_, _ = io.ReadAll(c.Request.Body)
+	})
+	r.GET("/api/slow", func(c *gin.Context) {
+		<-c.Request.Context().Done()
+	})
+	return r
+}
+
+func postBody(r *gin.Engine, path, body string, chunked bool) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if chunked {
+		req.ContentLength = -1
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRequestLimits_BodySize(t *testing.T) {
+	r := newLimitsEngine(LimitsConfig{
+		Enabled:     true,
+		MaxBodySize: 16,
+		Routes:      []RouteLimits{{PathPrefix: "/api/uploads", MaxBodySize: 1024}},
+	})
+	large := `{"name":"` + strings.Repeat("x", 64) + `"}`
+
+	assert.Equal(t, http.StatusOK, postBody(r, "/api/items", `{"a":1}`, false).Code)
+
+	// Rejected up front from Content-Length
+	assert.Equal(t, http.StatusRequestEntityTooLarge, postBody(r, "/api/items", large, false).Code)
+
+	// Detected while reading: the handler's 400 becomes 413
+	w := postBody(r, "/api/items", large, true)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	// Handlers that do not respond get 413 as well
+	assert.Equal(t, http.StatusRequestEntityTooLarge, postBody(r, "/api/raw", large, true).Code)
+
+	// The upload group has a larger limit
+	assert.Equal(t, http.StatusOK, postBody(r, "/api/uploads", large, true).Code)
+
+	// Other client errors are left untouched
+	assert.Equal(t, http.StatusBadRequest, postBody(r, "/api/items", `{`, false).Code)
+}
+
+func TestRequestLimits_HandlerTimeout(t *testing.T) {
+	r := newLimitsEngine(LimitsConfig{Enabled: true, HandlerTimeout: 20 * time.Millisecond})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/slow", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestRequestLimits_ReadTimeout(t *testing.T) {
+	r := newLimitsEngine(LimitsConfig{Enabled: true, ReadTimeout: 50 * time.Millisecond})
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	// Send the headers, then stall before the body
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/api/items", pr)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	go func() {
+		_, _ = pw.Write([]byte(`{"a":`))
+	}()
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusRequestTimeout, resp.StatusCode)
+}
+
+func TestBodyLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/upload", BodyLimit(4), func(c *gin.Context) {
+		if _, err := io.ReadAll(c.Request.Body); err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	assert.Equal(t, http.StatusOK, postBody(r, "/upload", "abc", true).Code)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, postBody(r, "/upload", "abcdef", true).Code)
+}
@@ -0,0 +1,52 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"grouter/pkg/loadshed"
+	"grouter/pkg/telemetry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// LoadSheddingConfig rejects requests with 429 while the server is above one
+// of the thresholds of Config
+type LoadSheddingConfig struct {
+	loadshed.Config `mapstructure:",squash"`
+	// ExcludePaths are path prefixes that are never shed. Health probes are
+	// always excluded.
+	ExcludePaths []string `mapstructure:"exclude_paths"`
+	// Shedder counts the requests in flight, one per Config when nil. The
+	// engines built by ResetEngine share the one of the server.
+	Shedder *loadshed.Shedder `mapstructure:"-"`
+}
+
+// LoadSheddingMiddleware rejects the requests arriving while s is above one
+// of its thresholds with 429 and a Retry-After header, counting them in reg
+// (nil uses the global registry)
+func LoadSheddingMiddleware(s *loadshed.Shedder, reg *telemetry.MetricsRegistry, excludePaths ...string) gin.HandlerFunc {
+	shed := reg.CounterVec(prometheus.CounterOpts{
+		Name: "http_requests_shed_total",
+		Help: "Total number of HTTP requests rejected under load",
+	}, []string{"reason"})
+	retryAfter := strconv.Itoa(max(1, ceilSeconds(s.RetryAfter())))
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		if strings.HasPrefix(path, "/health/") || hasPrefixAny(path, excludePaths) {
+			c.Next()
+			return
+		}
+		release, reason, ok := s.Acquire()
+		if !ok {
+			shed.WithLabelValues(string(reason)).Inc()
+			c.Header("Retry-After", retryAfter)
+			AbortWithError(c, NewError(http.StatusTooManyRequests, CodeTooManyRequests, "server overloaded"))
+			return
+		}
+		defer release()
+		c.Next()
+	}
+}
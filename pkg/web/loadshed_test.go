@@ -0,0 +1,57 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"grouter/pkg/loadshed"
+	"grouter/pkg/telemetry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadSheddingMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	reg := telemetry.NewMetricsRegistry()
+	shedder := loadshed.New(loadshed.Config{Enabled: true, MaxInFlight: 1, RetryAfter: 1500 * time.Millisecond})
+	r := gin.New()
+	r.Use(LoadSheddingMiddleware(shedder, reg, "/admin/"))
+	for _, path := range []string{"/api/items", "/admin/stats", "/health/live"} {
+		r.GET(path, func(c *gin.Context) { c.Status(http.StatusOK) })
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, get("/api/items").Code)
+	assert.Equal(t, 0, shedder.InFlight(), "released after the request")
+
+	// A request in flight fills the only slot
+	release, _, ok := shedder.Acquire()
+	require.True(t, ok)
+	w := get("/api/items")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), "server overloaded")
+
+	// Health probes and excluded paths are never shed
+	assert.Equal(t, http.StatusOK, get("/health/live").Code)
+	assert.Equal(t, http.StatusOK, get("/admin/stats").Code)
+	release()
+	assert.Equal(t, http.StatusOK, get("/api/items").Code)
+
+	expected := `
+# HELP http_requests_shed_total Total number of HTTP requests rejected under load
+# TYPE http_requests_shed_total counter
+http_requests_shed_total{reason="in_flight"} 1
+`
+	assert.NoError(t, testutil.GatherAndCompare(reg.Gatherer(), strings.NewReader(expected), "http_requests_shed_total"))
+}
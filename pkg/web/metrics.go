@@ -4,37 +4,25 @@ import (
 	"strconv"
 	"time"
 
+	"grouter/pkg/telemetry"
+
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-var (
-	httpRequestsTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "http_requests_total",
-			Help: "Total number of HTTP requests",
-		},
-		[]string{"method", "path", "status"},
-	)
-
-	httpRequestDuration = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "http_request_duration_seconds",
-			Help:    "Duration of HTTP requests in seconds",
-			Buckets: prometheus.DefBuckets,
-		},
-		[]string{"method", "path"},
-	)
-)
+// MetricsMiddleware records HTTP metrics in reg (nil uses the global
+// registry)
+func MetricsMiddleware(reg *telemetry.MetricsRegistry) gin.HandlerFunc {
+	requestsTotal := reg.CounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Total number of HTTP requests",
+	}, []string{"method", "path", "status", "version"})
+	requestDuration := reg.HistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Duration of HTTP requests in seconds",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "path", "version"})
 
-func init() {
-	prometheus.MustRegister(httpRequestsTotal)
-	prometheus.MustRegister(httpRequestDuration)
-}
-
-// MetricsMiddleware records HTTP metrics
-func MetricsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
 		path := c.FullPath()
@@ -47,12 +35,14 @@ func MetricsMiddleware() gin.HandlerFunc {
 		status := strconv.Itoa(c.Writer.Status())
 		duration := time.Since(start).Seconds()
 
-		httpRequestsTotal.WithLabelValues(c.Request.Method, path, status).Inc()
-		httpRequestDuration.WithLabelValues(c.Request.Method, path).Observe(duration)
+		version := APIVersion(c)
+
+		requestsTotal.WithLabelValues(c.Request.Method, path, status, version).Inc()
+		requestDuration.WithLabelValues(c.Request.Method, path, version).Observe(duration)
 	}
 }
 
-// RegisterMetricsHandler registers the /metrics endpoint
-func RegisterMetricsHandler(r *gin.Engine) {
-	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+// RegisterMetricsHandler registers the /metrics endpoint serving reg
+func RegisterMetricsHandler(r *gin.Engine, reg *telemetry.MetricsRegistry) {
+	r.GET("/metrics", gin.WrapH(reg.Handler()))
 }
@@ -1,6 +1,7 @@
 package web
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -8,6 +9,13 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	applog "grouter/pkg/logger"
 )
 
 func TestRequestIDMiddleware(t *testing.T) {
@@ -51,7 +59,7 @@ func TestRateLimitMiddleware(t *testing.T) {
 func TestMetricsMiddleware(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
-	r.Use(MetricsMiddleware())
+	r.Use(MetricsMiddleware(nil))
 	r.GET("/test", func(c *gin.Context) {
 		time.Sleep(10 * time.Millisecond)
 		c.Status(http.StatusOK)
@@ -65,3 +73,30 @@ func TestMetricsMiddleware(t *testing.T) {
 	// Note: Verifying actual Prometheus metrics requires more setup with the global registry,
 	// which might interfere with other tests. For unit test, we ensure middleware doesn't panic.
 }
+
+func TestLoggerMiddleware_TraceCorrelation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	core, logs := observer.New(zap.InfoLevel)
+	tp := sdktrace.NewTracerProvider()
+	defer tp.Shutdown(context.Background())
+
+	r := gin.New()
+	r.Use(RequestIDMiddleware())
+	r.Use(otelgin.Middleware("test", otelgin.WithTracerProvider(tp)))
+	r.Use(LoggerMiddleware(zap.New(core)))
+	r.GET("/test", func(c *gin.Context) {
+		applog.FromContext(c.Request.Context()).Info("handling")
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/test", nil)
+	r.ServeHTTP(w, req)
+
+	require.Equal(t, 2, logs.Len())
+	handler, access := logs.All()[0].ContextMap(), logs.All()[1].ContextMap()
+	assert.Equal(t, w.Header().Get(HeaderXRequestID), handler["request_id"])
+	assert.NotEmpty(t, handler[applog.TraceIDKey])
+	assert.Equal(t, handler[applog.TraceIDKey], access[applog.TraceIDKey])
+	assert.Equal(t, handler[applog.SpanIDKey], access[applog.SpanIDKey])
+}
@@ -0,0 +1,227 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+
+	messaging "grouter/pkg/messaging/nats"
+)
+
+// MetadataHTTPStatus is the reply metadata key a service can set to choose the
+// HTTP status code returned by the NATS gateway
+const MetadataHTTPStatus = "http_status"
+
+// NATSGatewayConfig holds configuration for the HTTP to NATS gateway
+type NATSGatewayConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+
+	// Timeout is the default request/reply timeout for routes that do not set one
+	Timeout time.Duration `mapstructure:"timeout"`
+
+	// Routes maps HTTP endpoints to NATS subjects
+	Routes []NATSRoute `mapstructure:"routes"`
+}
+
+// NATSRoute maps one HTTP endpoint to a NATS request/reply subject
+type NATSRoute struct {
+	// Method is the HTTP method (GET, POST, ...)
+	Method string `mapstructure:"method"`
+
+	// Path is the Gin route path, e.g. /api/orders/:id
+	Path string `mapstructure:"path"`
+
+	// Subject is the NATS subject template. {name} placeholders are replaced
+	// by the path parameter of the same name, e.g. orders.{id}.get
+	Subject string `mapstructure:"subject"`
+
+	// Type is the envelope message type sent with the request
+	Type string `mapstructure:"type"`
+
+	// Timeout overrides the gateway default timeout
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// Requester is the subset of messaging.Publisher used by the gateway
+type Requester interface {
+	Request(ctx context.Context, subject string, msgType string, data interface{}, timeout time.Duration) (*messaging.MessageEnvelope, error)
+}
+
+var subjectPlaceholder = regexp.MustCompile(`\{([A-Za-z0-9_]+)\}`)
+
+// NATSGateway serves config-defined HTTP routes by forwarding the request body
+// as envelope data to a NATS subject and returning the reply as the response
+type NATSGateway struct {
+	cfg       NATSGatewayConfig
+	requester Requester
+	logger    *zap.Logger
+}
+
+// NewNATSGateway creates a new NATSGateway instance, validating the route definitions
+func NewNATSGateway(cfg NATSGatewayConfig, requester Requester, logger *zap.Logger) (*NATSGateway, error) {
+	if requester == nil {
+		return nil, fmt.Errorf("nats gateway requires a requester")
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	for i, route := range cfg.Routes {
+		if err := validateRoute(route); err != nil {
+			return nil, fmt.Errorf("invalid nats gateway route %d (%s %s): %w", i, route.Method, route.Path, err)
+		}
+	}
+	return &NATSGateway{
+		cfg:       cfg,
+		requester: requester,
+		logger:    logger,
+	}, nil
+}
+
+func validateRoute(route NATSRoute) error {
+	if route.Method == "" || route.Path == "" || route.Subject == "" {
+		return fmt.Errorf("method, path and subject are required")
+	}
+
+	params := make(map[string]bool)
+	for _, segment := range strings.Split(route.Path, "/") {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			params[segment[1:]] = true
+		}
+	}
+	for _, match := range subjectPlaceholder.FindAllStringSubmatch(route.Subject, -1) {
+		if !params[match[1]] {
+			return fmt.Errorf("subject placeholder {%s} has no matching path parameter", match[1])
+		}
+	}
+	return nil
+}
+
+// RegisterRoutes mounts the configured routes. It implements WebService.
+func (g *NATSGateway) RegisterRoutes(router *gin.RouterGroup) {
+	for _, route := range g.cfg.Routes {
+		router.Handle(strings.ToUpper(route.Method), route.Path, g.handler(route))
+		g.logger.Info("Registered NATS gateway route",
+			zap.String("method", route.Method),
+			zap.String("path", route.Path),
+			zap.String("subject", route.Subject),
+		)
+	}
+}
+
+func (g *NATSGateway) handler(route NATSRoute) gin.HandlerFunc {
+	timeout := route.Timeout
+	if timeout <= 0 {
+		timeout = g.cfg.Timeout
+	}
+
+	return func(c *gin.Context) {
+		subject, err := resolveSubject(route.Subject, c)
+		if err != nil {
+			AbortWithError(c, BadRequest(err.Error()))
+			return
+		}
+
+		data, err := readJSONBody(c.Request)
+		if err != nil {
+			AbortWithError(c, BadRequest(err.Error()))
+			return
+		}
+
+		reply, err := g.requester.Request(c.Request.Context(), subject, route.Type, data, timeout)
+		if err != nil {
+			status := requestErrorStatus(err)
+			g.logger.Warn("NATS gateway request failed",
+				zap.String("subject", subject),
+				zap.Int("status", status),
+				zap.Error(err),
+			)
+			AbortWithError(c, NewError(status, requestErrorCode(status), http.StatusText(status)))
+			return
+		}
+
+		status := http.StatusOK
+		if reply.Type == "error" {
+			status = http.StatusBadGateway
+		}
+		if s, ok := reply.Metadata[MetadataHTTPStatus]; ok {
+			if code, err := strconv.Atoi(s); err == nil && code >= 100 && code <= 599 {
+				status = code
+			}
+		}
+
+		c.Header("X-Message-ID", reply.ID)
+		if len(reply.Data) == 0 {
+			c.Status(status)
+			return
+		}
+		c.Data(status, "application/json; charset=utf-8", reply.Data)
+	}
+}
+
+// resolveSubject fills the subject template from the path parameters.
+// Values that would change the subject structure are rejected.
+func resolveSubject(template string, c *gin.Context) (string, error) {
+	var err error
+	subject := subjectPlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		name := placeholder[1 : len(placeholder)-1]
+		value := strings.TrimPrefix(c.Param(name), "/")
+		if value == "" || strings.ContainsAny(value, ".*> \t\r\n") {
+			err = fmt.Errorf("invalid value for path parameter %q", name)
+		}
+		return value
+	})
+	return subject, err
+}
+
+// readJSONBody returns the request body as raw JSON, or nil for an empty body
+func readJSONBody(r *http.Request) (json.RawMessage, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	if len(body) == 0 {
+		return nil, nil
+	}
+	if !json.Valid(body) {
+		return nil, fmt.Errorf("request body must be valid JSON")
+	}
+	return body, nil
+}
+
+// requestErrorStatus maps NATS request errors to HTTP status codes
+// requestErrorCode returns the error code for a requestErrorStatus status
+func requestErrorCode(status int) string {
+	switch status {
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	case http.StatusGatewayTimeout:
+		return CodeTimeout
+	default:
+		return CodeInternal
+	}
+}
+
+func requestErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, nats.ErrNoResponders):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, nats.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusBadGateway
+	}
+}
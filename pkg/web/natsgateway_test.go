@@ -0,0 +1,138 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type mockRequester struct {
+	subject string
+	msgType string
+	data    interface{}
+	timeout time.Duration
+
+	reply *messaging.MessageEnvelope
+	err   error
+}
+
+func (m *mockRequester) Request(ctx context.Context, subject string, msgType string, data interface{}, timeout time.Duration) (*messaging.MessageEnvelope, error) {
+	m.subject = subject
+	m.msgType = msgType
+	m.data = data
+	m.timeout = timeout
+	return m.reply, m.err
+}
+
+func newNATSGatewayEngine(t *testing.T, requester Requester) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	gw, err := NewNATSGateway(NATSGatewayConfig{
+		Enabled: true,
+		Timeout: 2 * time.Second,
+		Routes: []NATSRoute{
+			{Method: "POST", Path: "/api/orders/:id", Subject: "orders.{id}.update", Type: "order.update"},
+			{Method: "GET", Path: "/api/orders/:id", Subject: "orders.{id}.get", Type: "order.get", Timeout: time.Second},
+		},
+	}, requester, zap.NewNop())
+	require.NoError(t, err)
+
+	engine := gin.New()
+	gw.RegisterRoutes(engine.Group("/"))
+	return engine
+}
+
+func TestNATSGateway_ForwardsRequest(t *testing.T) {
+	requester := &mockRequester{reply: &messaging.MessageEnvelope{ID: "r1", Type: "order.updated", Data: json.RawMessage(`{"ok":true}`)}}
+	engine := newNATSGatewayEngine(t, requester)
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/orders/42", strings.NewReader(`{"qty":3}`)))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"ok":true}`, w.Body.String())
+	assert.Equal(t, "r1", w.Header().Get("X-Message-ID"))
+	assert.Equal(t, "orders.42.update", requester.subject)
+	assert.Equal(t, "order.update", requester.msgType)
+	assert.Equal(t, 2*time.Second, requester.timeout)
+
+	data, err := json.Marshal(requester.data)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"qty":3}`, string(data))
+
+	// Route timeout overrides the default; an empty body is sent as null
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/orders/42", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, time.Second, requester.timeout)
+	data, _ = json.Marshal(requester.data)
+	assert.Equal(t, "null", string(data))
+}
+
+func TestNATSGateway_ReplyStatus(t *testing.T) {
+	requester := &mockRequester{}
+	engine := newNATSGatewayEngine(t, requester)
+
+	requester.reply = &messaging.MessageEnvelope{Type: "error", Data: json.RawMessage(`{"error":"boom"}`)}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/orders/1", nil))
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.JSONEq(t, `{"error":"boom"}`, w.Body.String())
+
+	requester.reply = &messaging.MessageEnvelope{Type: "order", Metadata: map[string]string{MetadataHTTPStatus: "404"}}
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/orders/1", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestNATSGateway_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		path   string
+		body   string
+		status int
+	}{
+		{"no responders", fmt.Errorf("request failed: %w", nats.ErrNoResponders), "/api/orders/1", "", http.StatusServiceUnavailable},
+		{"timeout", fmt.Errorf("request failed: %w", context.DeadlineExceeded), "/api/orders/1", "", http.StatusGatewayTimeout},
+		{"other", fmt.Errorf("not connected to NATS"), "/api/orders/1", "", http.StatusBadGateway},
+		{"invalid json", nil, "/api/orders/1", "not-json", http.StatusBadRequest},
+		{"subject injection", nil, "/api/orders/1.*", "", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requester := &mockRequester{err: tt.err, reply: &messaging.MessageEnvelope{}}
+			engine := newNATSGatewayEngine(t, requester)
+
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body)))
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
+
+func TestNewNATSGateway_InvalidRoute(t *testing.T) {
+	_, err := NewNATSGateway(NATSGatewayConfig{
+		Routes: []NATSRoute{{Method: "GET", Path: "/api/orders", Subject: "orders.{id}.get"}},
+	}, &mockRequester{}, zap.NewNop())
+	assert.Error(t, err)
+
+	_, err = NewNATSGateway(NATSGatewayConfig{
+		Routes: []NATSRoute{{Method: "GET", Path: "/api/orders"}},
+	}, &mockRequester{}, zap.NewNop())
+	assert.Error(t, err)
+}
@@ -0,0 +1,80 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Negotiated media types
+const (
+	MIMEJSON     = "application/json"
+	MIMEProtobuf = "application/x-protobuf"
+)
+
+// ProtoJSON options matching the gRPC gateway defaults
+var (
+	protoJSON      = protojson.MarshalOptions{EmitUnpopulated: true}
+	protoJSONInput = protojson.UnmarshalOptions{DiscardUnknown: true}
+)
+
+// NegotiateFormat returns the response media type preferred by the Accept
+// header, MIMEJSON by default
+func NegotiateFormat(c *gin.Context) string {
+	if f := c.NegotiateFormat(MIMEJSON, MIMEProtobuf, "application/protobuf"); f != "" {
+		if f == "application/protobuf" {
+			return MIMEProtobuf
+		}
+		return f
+	}
+	return MIMEJSON
+}
+
+// Respond writes obj in the format negotiated from the Accept header. Proto
+// messages are sent as binary protobuf when requested and as ProtoJSON
+// otherwise; other values are always sent as JSON.
+func Respond(c *gin.Context, code int, obj interface{}) {
+	msg, isProto := obj.(proto.Message)
+	if !isProto {
+		c.JSON(code, obj)
+		return
+	}
+
+	if NegotiateFormat(c) == MIMEProtobuf {
+		data, err := proto.Marshal(msg)
+		if err != nil {
+			AbortWithError(c, Internal(err))
+			return
+		}
+		c.Data(code, MIMEProtobuf, data)
+		return
+	}
+
+	data, err := protoJSON.Marshal(msg)
+	if err != nil {
+		AbortWithError(c, Internal(err))
+		return
+	}
+	c.Data(code, MIMEJSON+"; charset=utf-8", data)
+}
+
+// Bind decodes the request body into obj according to its Content-Type.
+// Proto messages accept binary protobuf and ProtoJSON; other values use
+// Gin's binding.
+func Bind(c *gin.Context, obj interface{}) error {
+	msg, isProto := obj.(proto.Message)
+	if !isProto {
+		return c.ShouldBind(obj)
+	}
+
+	data, err := c.GetRawData()
+	if err != nil {
+		return err
+	}
+	switch c.ContentType() {
+	case MIMEProtobuf, "application/protobuf":
+		return proto.Unmarshal(data, msg)
+	default:
+		return protoJSONInput.Unmarshal(data, msg)
+	}
+}
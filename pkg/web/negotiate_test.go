@@ -0,0 +1,88 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func newNegotiateEngine(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	msg, err := structpb.NewStruct(map[string]interface{}{"name": "widget"})
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.GET("/proto", func(c *gin.Context) { Respond(c, http.StatusOK, msg) })
+	r.GET("/plain", func(c *gin.Context) { Respond(c, http.StatusOK, gin.H{"name": "widget"}) })
+	r.POST("/echo", func(c *gin.Context) {
+		in := &structpb.Struct{}
+		if err := Bind(c, in); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		Respond(c, http.StatusOK, in)
+	})
+	return r
+}
+
+func TestRespond(t *testing.T) {
+	r := newNegotiateEngine(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/proto", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), MIMEJSON))
+	assert.JSONEq(t, `{"name":"widget"}`, w.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/proto", nil)
+	req.Header.Set("Accept", "application/x-protobuf")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, MIMEProtobuf, w.Header().Get("Content-Type"))
+	out := &structpb.Struct{}
+	require.NoError(t, proto.Unmarshal(w.Body.Bytes(), out))
+	assert.Equal(t, "widget", out.Fields["name"].GetStringValue())
+
+	// Non-proto values are always JSON
+	req = httptest.NewRequest(http.MethodGet, "/plain", nil)
+	req.Header.Set("Accept", "application/x-protobuf")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.JSONEq(t, `{"name":"widget"}`, w.Body.String())
+}
+
+func TestBind(t *testing.T) {
+	r := newNegotiateEngine(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(`{"name":"gadget"}`))
+	req.Header.Set("Content-Type", MIMEJSON)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"name":"gadget"}`, w.Body.String())
+
+	in, err := structpb.NewStruct(map[string]interface{}{"name": "binary"})
+	require.NoError(t, err)
+	data, err := proto.Marshal(in)
+	require.NoError(t, err)
+	req = httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(string(data)))
+	req.Header.Set("Content-Type", MIMEProtobuf)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.JSONEq(t, `{"name":"binary"}`, w.Body.String())
+
+	req = httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(`{`))
+	req.Header.Set("Content-Type", MIMEJSON)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
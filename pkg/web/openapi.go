@@ -0,0 +1,424 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swaggo/swag"
+	"go.uber.org/zap"
+)
+
+// OpenAPIConfig holds configuration for the runtime OpenAPI document
+type OpenAPIConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Path serves the document (default /openapi.json)
+	Path        string `mapstructure:"path"`
+	Title       string `mapstructure:"title"`
+	Version     string `mapstructure:"version"`
+	Description string `mapstructure:"description"`
+	// MergeSwagger fills undocumented operations from the swaggo annotations
+	// compiled into grouter/docs
+	MergeSwagger bool `mapstructure:"merge_swagger"`
+}
+
+// Schema is a JSON Schema object, e.g. from SchemaOf
+type Schema map[string]interface{}
+
+// Operation documents a route. Method and Path identify it; Path is relative to
+// the router group the service registers on, in Gin syntax (/items/:id).
+type Operation struct {
+	Method      string
+	Path        string
+	OperationID string
+	Summary     string
+	Description string
+	Tags        []string
+	Deprecated  bool
+	Parameters  []Parameter
+	// RequestBody is the JSON body schema, nil if the route takes no body
+	RequestBody Schema
+	// Responses by status code (default 200 OK)
+	Responses map[int]Response
+}
+
+// Parameter documents a query, header or path parameter. Path parameters are
+// added automatically when not documented.
+type Parameter struct {
+	Name        string
+	In          string
+	Description string
+	Required    bool
+	Schema      Schema
+}
+
+// Response documents a response
+type Response struct {
+	Description string
+	Schema      Schema
+}
+
+// OpenAPIDocumented is implemented by web services that describe their routes
+// for the runtime OpenAPI document
+type OpenAPIDocumented interface {
+	OpenAPIOperations() []Operation
+}
+
+// openAPIRegistry holds the operations described by services, keyed by
+// method and full Gin path
+type openAPIRegistry struct {
+	mu  sync.RWMutex
+	ops map[string]Operation
+}
+
+func (r *openAPIRegistry) add(prefix string, ops []Operation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ops == nil {
+		r.ops = make(map[string]Operation)
+	}
+	for _, op := range ops {
+		full := joinPaths(prefix, op.Path)
+		r.ops[strings.ToUpper(op.Method)+" "+full] = op
+	}
+}
+
+func (r *openAPIRegistry) get(method, path string) (Operation, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	op, ok := r.ops[method+" "+path]
+	return op, ok
+}
+
+// DescribeRoute documents a route registered outside a WebService. path is the
+// full Gin path.
+func (s *Server) DescribeRoute(op Operation) {
+	s.openapi.add("/", []Operation{op})
+}
+
+// describe records the operations of service registered under prefix
+func (s *Server) describe(service WebService, prefix string) {
+	if d, ok := service.(OpenAPIDocumented); ok {
+		s.openapi.add(prefix, d.OpenAPIOperations())
+	}
+}
+
+// OpenAPI builds an OpenAPI 3 document from the routes of the live engine
+func (s *Server) OpenAPI() map[string]interface{} {
+	cfg := s.cfg.OpenAPI
+	info := map[string]interface{}{
+		"title":   defaultString(cfg.Title, "gRouter API"),
+		"version": defaultString(cfg.Version, "1.0"),
+	}
+	if cfg.Description != "" {
+		info["description"] = cfg.Description
+	}
+
+	var swagger map[string]map[string]swaggerOperation
+	if cfg.MergeSwagger {
+		swagger = s.swaggerPaths()
+	}
+
+	routes := s.live.Load().Routes()
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+
+	paths := make(map[string]interface{})
+	for _, route := range routes {
+		if s.isDocsRoute(route.Path) {
+			continue
+		}
+		op, _ := s.openapi.get(route.Method, route.Path)
+		path := openAPIPath(route.Path)
+		if sw, ok := swagger[path][strings.ToLower(route.Method)]; ok {
+			op = mergeSwagger(op, sw)
+		}
+		if first, _, _ := strings.Cut(strings.TrimPrefix(route.Path, "/"), "/"); s.isVersion(first) {
+			if s.cfg.Versioning.Versions[first].Deprecated {
+				op.Deprecated = true
+			}
+		}
+
+		item, _ := paths[path].(map[string]interface{})
+		if item == nil {
+			item = make(map[string]interface{})
+			paths[path] = item
+		}
+		item[strings.ToLower(route.Method)] = operationObject(route.Path, op)
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info":    info,
+		"paths":   paths,
+	}
+}
+
+// openAPIHandler serves the document
+func (s *Server) openAPIHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, s.OpenAPI())
+}
+
+// registerOpenAPI mounts the document on engine
+func (s *Server) registerOpenAPI(engine *gin.Engine) {
+	if s.cfg.OpenAPI.Enabled {
+		engine.GET(s.openAPIPath(), s.openAPIHandler)
+	}
+}
+
+func (s *Server) openAPIPath() string {
+	return defaultString(s.cfg.OpenAPI.Path, "/openapi.json")
+}
+
+// isDocsRoute reports whether path serves documentation rather than the API
+func (s *Server) isDocsRoute(path string) bool {
+	if path == s.openAPIPath() {
+		return true
+	}
+	swaggerPath := defaultString(s.cfg.Swagger.Path, "/swagger")
+	return s.cfg.Swagger.Enabled && strings.HasPrefix(path, swaggerPath+"/")
+}
+
+func operationObject(ginPath string, op Operation) map[string]interface{} {
+	obj := make(map[string]interface{})
+	if op.OperationID != "" {
+		obj["operationId"] = op.OperationID
+	}
+	if op.Summary != "" {
+		obj["summary"] = op.Summary
+	}
+	if op.Description != "" {
+		obj["description"] = op.Description
+	}
+	if len(op.Tags) > 0 {
+		obj["tags"] = op.Tags
+	}
+	if op.Deprecated {
+		obj["deprecated"] = true
+	}
+
+	var params []interface{}
+	documented := make(map[string]bool)
+	for _, p := range op.Parameters {
+		documented[p.In+":"+p.Name] = true
+		params = append(params, parameterObject(p))
+	}
+	for _, name := range pathParams(ginPath) {
+		if !documented["path:"+name] {
+			params = append(params, parameterObject(Parameter{Name: name, In: "path"}))
+		}
+	}
+	if len(params) > 0 {
+		obj["parameters"] = params
+	}
+
+	if op.RequestBody != nil {
+		obj["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  map[string]interface{}{MIMEJSON: map[string]interface{}{"schema": op.RequestBody}},
+		}
+	}
+
+	responses := make(map[string]interface{})
+	for code, r := range op.Responses {
+		resp := map[string]interface{}{"description": defaultString(r.Description, http.StatusText(code))}
+		if r.Schema != nil {
+			resp["content"] = map[string]interface{}{MIMEJSON: map[string]interface{}{"schema": r.Schema}}
+		}
+		responses[strconv.Itoa(code)] = resp
+	}
+	if len(responses) == 0 {
+		responses["200"] = map[string]interface{}{"description": "OK"}
+	}
+	obj["responses"] = responses
+	return obj
+}
+
+func parameterObject(p Parameter) map[string]interface{} {
+	schema := p.Schema
+	if schema == nil {
+		schema = Schema{"type": "string"}
+	}
+	obj := map[string]interface{}{
+		"name":   p.Name,
+		"in":     p.In,
+		"schema": schema,
+	}
+	if p.Description != "" {
+		obj["description"] = p.Description
+	}
+	// Path parameters are always required
+	if p.Required || p.In == "path" {
+		obj["required"] = true
+	}
+	return obj
+}
+
+// openAPIPath converts /items/:id and /files/*path to /items/{id} and
+// /files/{path}
+func openAPIPath(ginPath string) string {
+	segments := strings.Split(ginPath, "/")
+	for i, seg := range segments {
+		if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") {
+			segments[i] = "{" + seg[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func pathParams(ginPath string) []string {
+	var names []string
+	for _, seg := range strings.Split(ginPath, "/") {
+		if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") {
+			names = append(names, seg[1:])
+		}
+	}
+	return names
+}
+
+// swaggerOperation is the part of a swaggo operation merged into the document
+type swaggerOperation struct {
+	OperationID string   `json:"operationId"`
+	Summary     string   `json:"summary"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+	Deprecated  bool     `json:"deprecated"`
+}
+
+// swaggerPaths reads the operations of the swaggo document
+func (s *Server) swaggerPaths() map[string]map[string]swaggerOperation {
+	raw, err := swag.ReadDoc()
+	if err != nil {
+		s.logger.Debug("No swagger document to merge", zap.Error(err))
+		return nil
+	}
+	var doc struct {
+		BasePath string                                 `json:"basePath"`
+		Paths    map[string]map[string]swaggerOperation `json:"paths"`
+	}
+	if err := json.Unmarshal([]byte(raw), &doc); err != nil {
+		s.logger.Warn("Failed to parse swagger document", zap.Error(err))
+		return nil
+	}
+	paths := make(map[string]map[string]swaggerOperation, len(doc.Paths))
+	for p, ops := range doc.Paths {
+		paths[joinPaths(doc.BasePath, p)] = ops
+	}
+	return paths
+}
+
+// mergeSwagger fills the fields op leaves empty from the swaggo annotations
+func mergeSwagger(op Operation, sw swaggerOperation) Operation {
+	if op.OperationID == "" {
+		op.OperationID = sw.OperationID
+	}
+	if op.Summary == "" {
+		op.Summary = sw.Summary
+	}
+	if op.Description == "" {
+		op.Description = sw.Description
+	}
+	if len(op.Tags) == 0 {
+		op.Tags = sw.Tags
+	}
+	op.Deprecated = op.Deprecated || sw.Deprecated
+	return op
+}
+
+// SchemaOf derives a JSON Schema from the type of v, following json tags
+func SchemaOf(v interface{}) Schema {
+	return schemaOf(reflect.TypeOf(v), 0)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func schemaOf(t reflect.Type, depth int) Schema {
+	if t == nil {
+		return Schema{}
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return Schema{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Schema{"type": "string", "format": "byte"}
+		}
+		return Schema{"type": "array", "items": schemaOf(t.Elem(), depth+1)}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": schemaOf(t.Elem(), depth+1)}
+	case reflect.Struct:
+		// Guard against recursive types
+		if depth > 8 {
+			return Schema{"type": "object"}
+		}
+		props := make(map[string]interface{})
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			props[name] = schemaOf(f.Type, depth+1)
+			if strings.Contains(f.Tag.Get("binding"), "required") ||
+				(!strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Pointer) {
+				required = append(required, name)
+			}
+		}
+		s := Schema{"type": "object", "properties": props}
+		if len(required) > 0 {
+			s["required"] = required
+		}
+		return s
+	default:
+		return Schema{}
+	}
+}
+
+func joinPaths(prefix, path string) string {
+	if path == "" {
+		return "/" + strings.Trim(prefix, "/")
+	}
+	joined := strings.TrimSuffix(prefix, "/") + "/" + strings.TrimPrefix(path, "/")
+	if !strings.HasPrefix(joined, "/") {
+		joined = "/" + joined
+	}
+	return joined
+}
+
+func defaultString(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
@@ -0,0 +1,129 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type widget struct {
+	ID      string    `json:"id" binding:"required"`
+	Name    string    `json:"name,omitempty"`
+	Tags    []string  `json:"tags,omitempty"`
+	Created time.Time `json:"created"`
+	secret  string
+}
+
+type widgetService struct{}
+
+func (s *widgetService) RegisterRoutes(g *gin.RouterGroup) {
+	g.GET("/widgets/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+	g.POST("/widgets", func(c *gin.Context) { c.Status(http.StatusCreated) })
+}
+
+func (s *widgetService) OpenAPIOperations() []Operation {
+	return []Operation{
+		{
+			Method:    http.MethodGet,
+			Path:      "/widgets/:id",
+			Summary:   "Get a widget",
+			Tags:      []string{"widgets"},
+			Responses: map[int]Response{http.StatusOK: {Schema: SchemaOf(widget{})}, http.StatusNotFound: {}},
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        "/widgets",
+			OperationID: "createWidget",
+			RequestBody: SchemaOf(&widget{}),
+		},
+	}
+}
+
+func fetchOpenAPI(t *testing.T, server *Server) map[string]interface{} {
+	t.Helper()
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	return doc
+}
+
+func TestServer_OpenAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := DefaultConfig()
+	cfg.OpenAPI.Enabled = true
+	cfg.OpenAPI.Title = "Widgets"
+	cfg.Versioning.Versions = map[string]VersionPolicy{"v1": {Deprecated: true}}
+	server := newTestServer(t, cfg, zap.NewNop(), nil)
+	server.RegisterWebService(&widgetService{})
+	server.RegisterWebServiceV(&widgetService{}, "v1")
+	server.Activate()
+
+	doc := fetchOpenAPI(t, server)
+	assert.Equal(t, "3.0.3", doc["openapi"])
+	assert.Equal(t, "Widgets", doc["info"].(map[string]interface{})["title"])
+
+	paths := doc["paths"].(map[string]interface{})
+	assert.NotContains(t, paths, "/openapi.json")
+	assert.NotContains(t, paths, "/swagger/{any}")
+	assert.Contains(t, paths, "/metrics", "undocumented routes are listed")
+
+	get := paths["/widgets/{id}"].(map[string]interface{})["get"].(map[string]interface{})
+	assert.Equal(t, "Get a widget", get["summary"])
+	assert.Equal(t, []interface{}{"widgets"}, get["tags"])
+	params := get["parameters"].([]interface{})
+	require.Len(t, params, 1)
+	assert.Equal(t, map[string]interface{}{
+		"name": "id", "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"},
+	}, params[0])
+	responses := get["responses"].(map[string]interface{})
+	assert.Contains(t, responses, "404")
+	schema := responses["200"].(map[string]interface{})["content"].(map[string]interface{})[MIMEJSON].(map[string]interface{})["schema"].(map[string]interface{})
+	assert.ElementsMatch(t, []interface{}{"id", "created"}, schema["required"])
+	props := schema["properties"].(map[string]interface{})
+	assert.NotContains(t, props, "secret")
+	assert.Equal(t, map[string]interface{}{"type": "string", "format": "date-time"}, props["created"])
+
+	post := paths["/widgets"].(map[string]interface{})["post"].(map[string]interface{})
+	assert.Equal(t, "createWidget", post["operationId"])
+	assert.Contains(t, post, "requestBody")
+
+	// Versioned routes are documented under their prefix and inherit the
+	// version's deprecation
+	v1 := paths["/v1/widgets/{id}"].(map[string]interface{})["get"].(map[string]interface{})
+	assert.Equal(t, "Get a widget", v1["summary"])
+	assert.Equal(t, true, v1["deprecated"])
+}
+
+func TestServer_OpenAPIMergeSwagger(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := DefaultConfig()
+	cfg.OpenAPI.Enabled = true
+	cfg.OpenAPI.MergeSwagger = true
+	server := newTestServer(t, cfg, zap.NewNop(), nil)
+	server.engine.Load().POST("/natsdemosvc/echo", func(c *gin.Context) {})
+	server.DescribeRoute(Operation{Method: http.MethodGet, Path: "/metrics", Summary: "Prometheus metrics"})
+	server.Activate()
+
+	paths := fetchOpenAPI(t, server)["paths"].(map[string]interface{})
+	echo := paths["/natsdemosvc/echo"].(map[string]interface{})["post"].(map[string]interface{})
+	assert.Equal(t, "Echoes the input message", echo["summary"], "filled from the swaggo annotations")
+	metrics := paths["/metrics"].(map[string]interface{})["get"].(map[string]interface{})
+	assert.Equal(t, "Prometheus metrics", metrics["summary"])
+}
+
+func TestOpenAPIPath(t *testing.T) {
+	assert.Equal(t, "/files/{path}", openAPIPath("/files/*path"))
+	assert.Equal(t, "/a/{b}/c", openAPIPath("/a/:b/c"))
+	assert.Equal(t, []string{"b", "path"}, pathParams("/a/:b/*path"))
+	assert.Equal(t, "/v1/items", joinPaths("/v1", "/items"))
+	assert.Equal(t, "/items", joinPaths("/", "items"))
+}
@@ -0,0 +1,149 @@
+package web
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ProfilingConfig holds configuration for the pprof endpoints
+type ProfilingConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+
+	// Path is the route prefix of the profiles (default /debug/pprof)
+	Path string `mapstructure:"path"`
+
+	// Port serves the profiles on a separate admin listener instead of the
+	// main server. 0 mounts them on the main server.
+	Port int `mapstructure:"port"`
+
+	// Roles restricts the profiles to callers holding one of these roles.
+	// Empty only requires an authenticated caller.
+	Roles []string `mapstructure:"roles"`
+
+	// AllowUnauthenticated serves the profiles without authentication. Only
+	// use it when the admin port is not reachable from outside.
+	AllowUnauthenticated bool `mapstructure:"allow_unauthenticated"`
+}
+
+// profilingPath returns the configured prefix without a trailing slash
+func profilingPath(cfg ProfilingConfig) string {
+	path := strings.TrimSuffix(cfg.Path, "/")
+	if path == "" {
+		path = "/debug/pprof"
+	}
+	return path
+}
+
+// requireRoles rejects callers without one of the roles
+func requireRoles(roles []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(roles) == 0 {
+			c.Next()
+			return
+		}
+		if id, ok := IdentityFromContext(c); ok {
+			for _, want := range roles {
+				for _, have := range id.Roles {
+					if want == have {
+						c.Next()
+						return
+					}
+				}
+			}
+		}
+		AbortWithError(c, Forbidden("insufficient role for profiling"))
+	}
+}
+
+// RegisterProfiling mounts the pprof handlers under cfg.Path. Unless
+// AllowUnauthenticated is set, the caller must have been authenticated by the
+// auth middleware (and hold one of cfg.Roles).
+func RegisterProfiling(r gin.IRouter, cfg ProfilingConfig) {
+	handlers := []gin.HandlerFunc{}
+	if !cfg.AllowUnauthenticated {
+		handlers = append(handlers, RequireAuth(), requireRoles(cfg.Roles))
+	}
+	group := r.Group(profilingPath(cfg), handlers...)
+
+	group.GET("/", gin.WrapF(pprof.Index))
+	group.POST("/symbol", gin.WrapF(pprof.Symbol))
+	group.GET("/:name", func(c *gin.Context) {
+		switch name := c.Param("name"); name {
+		case "cmdline":
+			pprof.Cmdline(c.Writer, c.Request)
+		case "profile":
+			pprof.Profile(c.Writer, c.Request)
+		case "symbol":
+			pprof.Symbol(c.Writer, c.Request)
+		case "trace":
+			pprof.Trace(c.Writer, c.Request)
+		default:
+			// pprof.Index only resolves names under /debug/pprof/, so named
+			// profiles are served directly to support other prefixes
+			pprof.Handler(name).ServeHTTP(c.Writer, c.Request)
+		}
+	})
+}
+
+// validateProfiling rejects profiling without any authentication
+func validateProfiling(cfg Config) error {
+	if cfg.Profiling.Enabled && !cfg.Auth.Enabled && !cfg.Profiling.AllowUnauthenticated {
+		return fmt.Errorf("profiling requires web auth or allow_unauthenticated")
+	}
+	return nil
+}
+
+// newProfilingEngine builds the engine of the admin listener
+func newProfilingEngine(cfg Config, logger *zap.Logger) *gin.Engine {
+	engine := gin.New()
+	engine.Use(RequestIDMiddleware())
+	engine.Use(ErrorMiddleware(logger))
+	if cfg.Auth.Enabled {
+		engine.Use(AuthMiddleware(cfg.Auth))
+	}
+	RegisterProfiling(engine, cfg.Profiling)
+	return engine
+}
+
+// startProfiling starts the admin listener serving the profiles
+func (s *Server) startProfiling() error {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", s.cfg.Profiling.Port))
+	if err != nil {
+		return fmt.Errorf("failed to listen on profiling port %d: %w", s.cfg.Profiling.Port, err)
+	}
+	// No write timeout: CPU profiles and traces stream for their duration
+	server := &http.Server{
+		Handler:     newProfilingEngine(s.cfg, s.logger),
+		ReadTimeout: s.cfg.ReadTimeout,
+	}
+	s.profiling = server
+	s.profilingListener = listener
+
+	s.logger.Info("Starting profiling server",
+		zap.String("addr", listener.Addr().String()),
+		zap.String("path", profilingPath(s.cfg.Profiling)),
+	)
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			s.logger.Error("Profiling server stopped", zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+// ProfilingAddr returns the address of the admin profiling listener, or "" if
+// it is not running
+func (s *Server) ProfilingAddr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.profilingListener == nil {
+		return ""
+	}
+	return s.profilingListener.Addr().String()
+}
@@ -0,0 +1,120 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func profilingAuthConfig() AuthConfig {
+	return AuthConfig{
+		Enabled: true,
+		APIKeys: []APIKeyConfig{
+			{Name: "ops", Key: "ops-key", Roles: []string{"ops"}},
+			{Name: "app", Key: "app-key", Roles: []string{"reader"}},
+		},
+	}
+}
+
+func TestProfiling_MainServer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine, err := InitEngine(Config{
+		Auth:      profilingAuthConfig(),
+		Profiling: ProfilingConfig{Enabled: true, Path: "/admin/pprof/", Roles: []string{"ops"}},
+	}, zap.NewNop())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		path   string
+		key    string
+		status int
+	}{
+		{name: "no credentials", path: "/admin/pprof/", status: http.StatusUnauthorized},
+		{name: "missing role", path: "/admin/pprof/", key: "app-key", status: http.StatusForbidden},
+		{name: "index", path: "/admin/pprof/", key: "ops-key", status: http.StatusOK},
+		{name: "named profile", path: "/admin/pprof/goroutine?debug=1", key: "ops-key", status: http.StatusOK},
+		{name: "cmdline", path: "/admin/pprof/cmdline", key: "ops-key", status: http.StatusOK},
+		{name: "unknown profile", path: "/admin/pprof/nope", key: "ops-key", status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.key != "" {
+				req.Header.Set("X-API-Key", tt.key)
+			}
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/pprof/goroutine?debug=1", nil)
+	req.Header.Set("X-API-Key", "ops-key")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Contains(t, w.Body.String(), "goroutine profile")
+}
+
+func TestProfiling_RequiresAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	_, err := InitEngine(Config{Profiling: ProfilingConfig{Enabled: true}}, zap.NewNop())
+	assert.Error(t, err)
+	_, err = NewWebServer(Config{Mode: gin.TestMode, Profiling: ProfilingConfig{Enabled: true}}, zap.NewNop(), nil)
+	assert.Error(t, err, "NewWebServer reports it too")
+
+	engine, err := InitEngine(Config{
+		Profiling: ProfilingConfig{Enabled: true, AllowUnauthenticated: true},
+	}, zap.NewNop())
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestProfiling_AdminPort(t *testing.T) {
+	port := freePort(t)
+	server := newTestServer(t, Config{
+		Port:      0,
+		Mode:      gin.TestMode,
+		Auth:      profilingAuthConfig(),
+		Profiling: ProfilingConfig{Enabled: true, Port: port},
+	}, zap.NewNop(), nil)
+	require.NoError(t, server.Start())
+	defer server.Stop(context.Background())
+
+	require.NotEmpty(t, server.ProfilingAddr())
+
+	get := func(addr, key string) int {
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s/debug/pprof/heap", addr), nil)
+		require.NoError(t, err)
+		req.Header.Set("X-API-Key", key)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusOK, get(fmt.Sprintf("127.0.0.1:%d", port), "ops-key"))
+	assert.Equal(t, http.StatusUnauthorized, get(fmt.Sprintf("127.0.0.1:%d", port), "wrong"))
+	// The main server does not expose the profiles
+	assert.Equal(t, http.StatusNotFound, get(fmt.Sprintf("127.0.0.1:%d", server.Port()), "ops-key"))
+}
+
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
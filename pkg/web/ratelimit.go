@@ -1,14 +1,34 @@
 package web
 
 import (
+	"context"
+	"fmt"
+	"math"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 	"golang.org/x/time/rate"
 )
 
+// Rate limit key types
+const (
+	RateLimitKeyIP     = "ip"
+	RateLimitKeyAPIKey = "api_key"
+	RateLimitKeyHeader = "header"
+)
+
+// Rate limit stores
+const (
+	RateLimitStoreMemory = "memory"
+	RateLimitStoreRedis  = "redis"
+)
+
 // IPRateLimiter manages rate limiters for each IP address
 type IPRateLimiter struct {
 	ips map[string]*rate.Limiter
@@ -54,32 +74,363 @@ func (i *IPRateLimiter) GetLimiter(ip string) *rate.Limiter {
 	return limiter
 }
 
-// RateLimitMiddleware limits requests based on IP
-func RateLimitMiddleware(requestsPerSecond float64, burst int) gin.HandlerFunc {
-	limiter := NewIPRateLimiter(rate.Limit(requestsPerSecond), burst)
-
-	// Start a cleanup goroutine to remove old entries (simplified for this example)
-	go func() {
-		for {
-			time.Sleep(1 * time.Minute)
-			limiter.mu.Lock()
-			// Basic cleanup: if map grows too large, reset it to prevent memory leaks
-			if len(limiter.ips) > 10000 {
-				limiter.ips = make(map[string]*rate.Limiter)
+// RateLimit is a token bucket refilled at Rate tokens per second up to Burst
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimitResult is the outcome of taking a token from a bucket
+type RateLimitResult struct {
+	Allowed   bool
+	Remaining int
+	// Reset is the time until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is the time until the next token is available (0 if allowed)
+	RetryAfter time.Duration
+}
+
+// RateLimitStore holds token buckets by key. Implementations must be safe for
+// concurrent use; shared stores (Redis) enforce limits across instances.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error)
+}
+
+// RateLimitKeyFunc returns the client key a request is limited by
+type RateLimitKeyFunc func(c *gin.Context) string
+
+// KeyByIP limits by client IP
+func KeyByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// KeyByHeader limits by the value of a header, falling back to the client IP
+// when the header is missing
+func KeyByHeader(header string) RateLimitKeyFunc {
+	return func(c *gin.Context) string {
+		if v := c.GetHeader(header); v != "" {
+			return "h:" + v
+		}
+		return KeyByIP(c)
+	}
+}
+
+// KeyByAPIKey limits by the authenticated API key name, or by the raw API key
+// header when auth is disabled, falling back to the client IP
+func KeyByAPIKey(header string) RateLimitKeyFunc {
+	byHeader := KeyByHeader(header)
+	return func(c *gin.Context) string {
+		if id, ok := IdentityFromContext(c); ok && id.Method == AuthMethodAPIKey {
+			return "key:" + id.Subject
+		}
+		return byHeader(c)
+	}
+}
+
+// keyFuncFor resolves a key type from configuration
+func keyFuncFor(key, header string) (RateLimitKeyFunc, error) {
+	switch key {
+	case "", RateLimitKeyIP:
+		return KeyByIP, nil
+	case RateLimitKeyAPIKey:
+		if header == "" {
+			header = "X-API-Key"
+		}
+		return KeyByAPIKey(header), nil
+	case RateLimitKeyHeader:
+		if header == "" {
+			return nil, fmt.Errorf("rate limit key %q requires a header", key)
+		}
+		return KeyByHeader(header), nil
+	default:
+		return nil, fmt.Errorf("invalid rate limit key %q", key)
+	}
+}
+
+// MemoryRateLimitStore keeps token buckets in process memory
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type memoryBucket struct {
+	tokens float64
+	last   time.Time
+	limit  RateLimit
+}
+
+// NewMemoryRateLimitStore creates an in-memory store. Idle buckets are dropped
+// once they are full again.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets: make(map[string]*memoryBucket),
+		now:     time.Now,
+	}
+}
+
+// Take takes a token from the bucket of the key
+func (s *MemoryRateLimitStore) Take(_ context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.lastSweep) > time.Minute {
+		s.sweep(now)
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{tokens: float64(limit.Burst), last: now}
+		s.buckets[key] = b
+	}
+	b.limit = limit
+
+	tokens := math.Min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	allowed := tokens >= 1
+	if allowed {
+		tokens--
+	}
+	b.tokens, b.last = tokens, now
+	return bucketResult(allowed, tokens, limit), nil
+}
+
+// sweep removes buckets that have refilled completely
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	for k, b := range s.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*b.limit.Rate >= float64(b.limit.Burst) {
+			delete(s.buckets, k)
+		}
+	}
+	s.lastSweep = now
+}
+
+// redisTokenBucket atomically refills and takes a token. Tokens are returned
+// as a string because Redis truncates Lua numbers to integers.
+var redisTokenBucket = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local data = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(data[1])
+local ts = tonumber(data[2])
+if tokens == nil or ts == nil then
+  tokens = burst
+  ts = now
+end
+tokens = math.min(burst, tokens + math.max(0, now - ts) / 1000 * rate)
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", tostring(now))
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+// RedisRateLimitStore keeps token buckets in Redis so that limits are shared
+// by every instance
+type RedisRateLimitStore struct {
+	client redis.UniversalClient
+	prefix string
+	now    func() time.Time
+}
+
+// NewRedisRateLimitStore creates a Redis backed store. Keys are prefixed with
+// prefix (default "ratelimit:").
+func NewRedisRateLimitStore(client redis.UniversalClient, prefix string) *RedisRateLimitStore {
+	if prefix == "" {
+		prefix = "ratelimit:"
+	}
+	return &RedisRateLimitStore{client: client, prefix: prefix, now: time.Now}
+}
+
+// Take takes a token from the bucket of the key
+func (s *RedisRateLimitStore) Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	nowMs := s.now().UnixMilli()
+	res, err := redisTokenBucket.Run(ctx, s.client, []string{s.prefix + key}, limit.Rate, limit.Burst, nowMs).Slice()
+	if err != nil {
+		return RateLimitResult{}, fmt.Errorf("failed to run rate limit script: %w", err)
+	}
+	if len(res) != 2 {
+		return RateLimitResult{}, fmt.Errorf("unexpected rate limit script result %v", res)
+	}
+	allowed, _ := res[0].(int64)
+	tokensStr, _ := res[1].(string)
+	tokens, err := strconv.ParseFloat(tokensStr, 64)
+	if err != nil {
+		return RateLimitResult{}, fmt.Errorf("invalid rate limit tokens %q: %w", tokensStr, err)
+	}
+	return bucketResult(allowed == 1, tokens, limit), nil
+}
+
+func bucketResult(allowed bool, tokens float64, limit RateLimit) RateLimitResult {
+	r := RateLimitResult{
+		Allowed:   allowed,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     time.Duration((float64(limit.Burst) - tokens) / limit.Rate * float64(time.Second)),
+	}
+	if !allowed {
+		r.RetryAfter = time.Duration((1 - tokens) / limit.Rate * float64(time.Second))
+	}
+	return r
+}
+
+// NewRateLimitStore creates the store selected in the configuration
+func NewRateLimitStore(cfg RateLimitConfig) (RateLimitStore, error) {
+	switch cfg.Store {
+	case "", RateLimitStoreMemory:
+		return NewMemoryRateLimitStore(), nil
+	case RateLimitStoreRedis:
+		if cfg.Redis.Addr == "" {
+			return nil, fmt.Errorf("redis rate limit store requires an address")
+		}
+		client := redis.NewClient(&redis.Options{
+			Addr:     cfg.Redis.Addr,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+		})
+		return NewRedisRateLimitStore(client, cfg.Redis.Prefix), nil
+	default:
+		return nil, fmt.Errorf("invalid rate limit store %q", cfg.Store)
+	}
+}
+
+type rateLimitRule struct {
+	name   string
+	prefix string
+	limit  RateLimit
+	key    RateLimitKeyFunc
+}
+
+// RateLimiter applies the default limit and per route group limits, selected
+// by the longest matching path prefix
+type RateLimiter struct {
+	store RateLimitStore
+	rules []rateLimitRule
+	def   rateLimitRule
+}
+
+// NewRateLimiter creates a RateLimiter from the configuration
+func NewRateLimiter(cfg RateLimitConfig, store RateLimitStore) (*RateLimiter, error) {
+	key, err := keyFuncFor(cfg.Key, cfg.Header)
+	if err != nil {
+		return nil, err
+	}
+	l := &RateLimiter{
+		store: store,
+		def: rateLimitRule{
+			name:  "default",
+			limit: RateLimit{Rate: cfg.RequestsPerSecond, Burst: cfg.Burst},
+			key:   key,
+		},
+	}
+	if err := validateRateLimit(l.def.limit); err != nil {
+		return nil, err
+	}
+
+	for _, r := range cfg.Routes {
+		if r.PathPrefix == "" {
+			return nil, fmt.Errorf("rate limit route without path_prefix")
+		}
+		rule := rateLimitRule{
+			name:   r.PathPrefix,
+			prefix: r.PathPrefix,
+			limit:  RateLimit{Rate: r.RequestsPerSecond, Burst: r.Burst},
+			key:    key,
+		}
+		if err := validateRateLimit(rule.limit); err != nil {
+			return nil, fmt.Errorf("rate limit route %q: %w", r.PathPrefix, err)
+		}
+		if r.Key != "" || r.Header != "" {
+			if rule.key, err = keyFuncFor(r.Key, r.Header); err != nil {
+				return nil, fmt.Errorf("rate limit route %q: %w", r.PathPrefix, err)
 			}
-			limiter.mu.Unlock()
 		}
-	}()
+		l.rules = append(l.rules, rule)
+	}
+	sort.SliceStable(l.rules, func(i, j int) bool {
+		return len(l.rules[i].prefix) > len(l.rules[j].prefix)
+	})
+	return l, nil
+}
 
+func validateRateLimit(l RateLimit) error {
+	if l.Rate <= 0 || l.Burst <= 0 {
+		return fmt.Errorf("rate limit requires requests_per_second and burst > 0")
+	}
+	return nil
+}
+
+// Middleware limits each request with the rule of its path
+func (l *RateLimiter) Middleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ip := c.ClientIP()
-		l := limiter.GetLimiter(ip)
-		if !l.Allow() {
-			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
-				"error": "Too many requests",
-			})
-			return
+		rule := l.def
+		for _, r := range l.rules {
+			if strings.HasPrefix(c.Request.URL.Path, r.prefix) {
+				rule = r
+				break
+			}
 		}
+		limitRequest(c, l.store, rule)
+	}
+}
+
+// KeyedRateLimitMiddleware limits the routes it is attached to, e.g. a route
+// group, independently of the global limiter:
+//
+//	api := router.Group("/api", web.KeyedRateLimitMiddleware(store, "api", web.RateLimit{Rate: 10, Burst: 20}, web.KeyByIP))
+func KeyedRateLimitMiddleware(store RateLimitStore, name string, limit RateLimit, key RateLimitKeyFunc) gin.HandlerFunc {
+	rule := rateLimitRule{name: name, limit: limit, key: key}
+	return func(c *gin.Context) {
+		limitRequest(c, store, rule)
+	}
+}
+
+// limitRequest takes a token and sets the RateLimit-* response headers. Store
+// errors fail open so that a Redis outage does not take the API down.
+func limitRequest(c *gin.Context, store RateLimitStore, rule rateLimitRule) {
+	res, err := store.Take(c.Request.Context(), rule.name+"|"+rule.key(c), rule.limit)
+	if err != nil {
+		_ = c.Error(fmt.Errorf("rate limit store: %w", err))
 		c.Next()
+		return
+	}
+
+	h := c.Writer.Header()
+	h.Set("RateLimit-Limit", strconv.Itoa(rule.limit.Burst))
+	h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+	if !res.Allowed {
+		h.Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+		AbortWithError(c, NewError(http.StatusTooManyRequests, CodeTooManyRequests, "too many requests"))
+		return
+	}
+	c.Next()
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// RateLimitFromConfig creates the rate limiting middleware from the
+// configuration, using the configured store
+func RateLimitFromConfig(cfg RateLimitConfig) (gin.HandlerFunc, error) {
+	store, err := NewRateLimitStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+	l, err := NewRateLimiter(cfg, store)
+	if err != nil {
+		return nil, err
 	}
+	return l.Middleware(), nil
+}
+
+// RateLimitMiddleware limits requests based on IP using an in-memory store
+func RateLimitMiddleware(requestsPerSecond float64, burst int) gin.HandlerFunc {
+	return KeyedRateLimitMiddleware(NewMemoryRateLimitStore(), "default",
+		RateLimit{Rate: requestsPerSecond, Burst: burst}, KeyByIP)
 }
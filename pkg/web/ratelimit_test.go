@@ -0,0 +1,177 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testStores(t *testing.T) map[string]RateLimitStore {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	return map[string]RateLimitStore{
+		RateLimitStoreMemory: NewMemoryRateLimitStore(),
+		RateLimitStoreRedis:  NewRedisRateLimitStore(client, ""),
+	}
+}
+
+func TestRateLimitStores(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			limit := RateLimit{Rate: 1, Burst: 2}
+
+			res, err := store.Take(ctx, "client-a", limit)
+			require.NoError(t, err)
+			assert.True(t, res.Allowed)
+			assert.Equal(t, 1, res.Remaining)
+
+			res, err = store.Take(ctx, "client-a", limit)
+			require.NoError(t, err)
+			assert.True(t, res.Allowed)
+			assert.Equal(t, 0, res.Remaining)
+
+			res, err = store.Take(ctx, "client-a", limit)
+			require.NoError(t, err)
+			assert.False(t, res.Allowed)
+			assert.Greater(t, res.RetryAfter, time.Duration(0))
+			assert.LessOrEqual(t, res.RetryAfter, time.Second)
+
+			// Buckets are independent per key
+			res, err = store.Take(ctx, "client-b", limit)
+			require.NoError(t, err)
+			assert.True(t, res.Allowed)
+		})
+	}
+}
+
+func TestMemoryRateLimitStore_Refill(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	limit := RateLimit{Rate: 2, Burst: 1}
+
+	res, _ := store.Take(context.Background(), "k", limit)
+	assert.True(t, res.Allowed)
+	res, _ = store.Take(context.Background(), "k", limit)
+	assert.False(t, res.Allowed)
+
+	now = now.Add(500 * time.Millisecond)
+	res, _ = store.Take(context.Background(), "k", limit)
+	assert.True(t, res.Allowed)
+
+	// Full buckets are swept
+	now = now.Add(2 * time.Minute)
+	store.mu.Lock()
+	store.sweep(now)
+	assert.Empty(t, store.buckets)
+	store.mu.Unlock()
+}
+
+func newRateLimitEngine(t *testing.T, cfg RateLimitConfig) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	l, err := NewRateLimiter(cfg, NewMemoryRateLimitStore())
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.Use(l.Middleware())
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.GET("/api/items", ok)
+	r.GET("/api/nats/send", ok)
+	return r
+}
+
+func doRateLimitRequest(r *gin.Engine, path string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimiter_HeadersAndRoutes(t *testing.T) {
+	r := newRateLimitEngine(t, RateLimitConfig{
+		RequestsPerSecond: 1,
+		Burst:             3,
+		Routes:            []RateLimitRoute{{PathPrefix: "/api/nats", RequestsPerSecond: 1, Burst: 1}},
+	})
+
+	w := doRateLimitRequest(r, "/api/items", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "3", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Reset"))
+
+	// The route group has its own, smaller bucket
+	assert.Equal(t, http.StatusOK, doRateLimitRequest(r, "/api/nats/send", nil).Code)
+	w = doRateLimitRequest(r, "/api/nats/send", nil)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+
+	assert.Equal(t, http.StatusOK, doRateLimitRequest(r, "/api/items", nil).Code)
+}
+
+func TestRateLimiter_KeyByHeader(t *testing.T) {
+	r := newRateLimitEngine(t, RateLimitConfig{
+		RequestsPerSecond: 1,
+		Burst:             1,
+		Key:               RateLimitKeyHeader,
+		Header:            "X-Tenant-ID",
+	})
+
+	tenantA := map[string]string{"X-Tenant-ID": "a"}
+	assert.Equal(t, http.StatusOK, doRateLimitRequest(r, "/api/items", tenantA).Code)
+	assert.Equal(t, http.StatusTooManyRequests, doRateLimitRequest(r, "/api/items", tenantA).Code)
+	assert.Equal(t, http.StatusOK, doRateLimitRequest(r, "/api/items", map[string]string{"X-Tenant-ID": "b"}).Code)
+}
+
+func TestRateLimiter_KeyByAPIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	l, err := NewRateLimiter(RateLimitConfig{RequestsPerSecond: 1, Burst: 1, Key: RateLimitKeyAPIKey}, NewMemoryRateLimitStore())
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.Use(AuthMiddleware(AuthConfig{
+		Enabled: true,
+		Mode:    AuthModeOptional,
+		APIKeys: []APIKeyConfig{{Name: "billing", Key: "k1"}, {Name: "search", Key: "k2"}},
+	}), l.Middleware())
+	r.GET("/api/items", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	assert.Equal(t, http.StatusOK, doRateLimitRequest(r, "/api/items", map[string]string{"X-API-Key": "k1"}).Code)
+	assert.Equal(t, http.StatusTooManyRequests, doRateLimitRequest(r, "/api/items", map[string]string{"X-API-Key": "k1"}).Code)
+	assert.Equal(t, http.StatusOK, doRateLimitRequest(r, "/api/items", map[string]string{"X-API-Key": "k2"}).Code)
+}
+
+func TestNewRateLimiter_InvalidConfig(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	_, err := NewRateLimiter(RateLimitConfig{RequestsPerSecond: 0, Burst: 1}, store)
+	assert.Error(t, err)
+
+	_, err = NewRateLimiter(RateLimitConfig{RequestsPerSecond: 1, Burst: 1, Key: "cookie"}, store)
+	assert.Error(t, err)
+
+	_, err = NewRateLimiter(RateLimitConfig{RequestsPerSecond: 1, Burst: 1, Key: RateLimitKeyHeader}, store)
+	assert.Error(t, err)
+
+	_, err = NewRateLimiter(RateLimitConfig{RequestsPerSecond: 1, Burst: 1, Routes: []RateLimitRoute{{PathPrefix: "/api"}}}, store)
+	assert.Error(t, err)
+
+	_, err = NewRateLimitStore(RateLimitConfig{Store: RateLimitStoreRedis})
+	assert.Error(t, err)
+}
@@ -0,0 +1,71 @@
+package web
+
+import (
+	"net/http"
+	"reflect"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// requestTimeouts are the read and write timeouts applied to each request
+// once ApplyConfig has changed them, since the http.Server fields cannot be
+// changed while it is serving
+type requestTimeouts struct {
+	read  time.Duration
+	write time.Duration
+}
+
+// apply sets the deadlines of the request. A zero timeout clears the deadline.
+func (t *requestTimeouts) apply(w http.ResponseWriter) {
+	rc := http.NewResponseController(w)
+	_ = rc.SetReadDeadline(deadline(t.read))
+	_ = rc.SetWriteDeadline(deadline(t.write))
+}
+
+func deadline(timeout time.Duration) time.Time {
+	if timeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(timeout)
+}
+
+// ApplyConfig replaces the configuration of the server. The timeouts apply to
+// the next requests and the middleware settings (CORS, rate limits, ...) to
+// the engine built by the next ResetEngine. The listener settings (port, TLS,
+// HTTP/2, profiling port) keep their values until a restart.
+func (s *Server) ApplyConfig(cfg Config) error {
+	if _, err := InitEngine(cfg, s.logger); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if cfg.Port != s.cfg.Port || !reflect.DeepEqual(cfg.TLS, s.cfg.TLS) || cfg.HTTP2 != s.cfg.HTTP2 ||
+		cfg.Profiling.Port != s.cfg.Profiling.Port {
+		s.logger.Warn("Web listener settings changed, they apply after a restart")
+		cfg.Port = s.cfg.Port
+		cfg.TLS = s.cfg.TLS
+		cfg.HTTP2 = s.cfg.HTTP2
+		cfg.Profiling.Port = s.cfg.Profiling.Port
+	}
+	// The engines share the cache of the server, see NewWebServer
+	if s.responseCache != nil {
+		cfg.ResponseCache.Cache = s.responseCache.Cache()
+	}
+	// and, while its thresholds are unchanged, its load shedding count
+	if cfg.LoadShedding.Config == s.cfg.LoadShedding.Config {
+		cfg.LoadShedding.Shedder = s.cfg.LoadShedding.Shedder
+	}
+
+	if cfg.ReadTimeout != s.cfg.ReadTimeout || cfg.WriteTimeout != s.cfg.WriteTimeout || s.timeouts.Load() != nil {
+		s.timeouts.Store(&requestTimeouts{read: cfg.ReadTimeout, write: cfg.WriteTimeout})
+	}
+	s.cfg = cfg
+	s.logger.Info("Web server configuration applied",
+		zap.Duration("read_timeout", cfg.ReadTimeout),
+		zap.Duration("write_timeout", cfg.WriteTimeout),
+	)
+	return nil
+}
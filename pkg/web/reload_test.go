@@ -0,0 +1,88 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newReloadTestServer(t *testing.T) (*Server, Config) {
+	gin.SetMode(gin.TestMode)
+	cfg := DefaultConfig()
+	cfg.Metrics.Enabled = false
+	cfg.RateLimit.Enabled = false
+	cfg.Swagger.Enabled = false
+	return newTestServer(t, cfg, zap.NewNop(), nil), cfg
+}
+
+func TestServer_ApplyConfig_CORS(t *testing.T) {
+	server, cfg := newReloadTestServer(t)
+	server.RegisterWebService(&TestService{})
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	get := func() *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, httpServer.URL+"/ping", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+	assert.Empty(t, get().Header.Get("Access-Control-Allow-Origin"))
+
+	cfg.CORS = CORSConfig{Enabled: true, AllowedOrigins: []string{"https://app.example.com"}}
+	require.NoError(t, server.ApplyConfig(cfg))
+	assert.Empty(t, get().Header.Get("Access-Control-Allow-Origin"), "applies to the next engine")
+
+	require.NoError(t, server.ResetEngine(context.Background()))
+	server.RegisterWebService(&TestService{})
+	server.Activate()
+	assert.Equal(t, "https://app.example.com", get().Header.Get("Access-Control-Allow-Origin"))
+}
+
+func TestServer_ApplyConfig_Invalid(t *testing.T) {
+	server, cfg := newReloadTestServer(t)
+
+	cfg.RateLimit = RateLimitConfig{Enabled: true, RequestsPerSecond: 10, Burst: 10, Store: "memcached"}
+	assert.Error(t, server.ApplyConfig(cfg))
+	assert.False(t, server.cfg.RateLimit.Enabled, "the previous config is kept")
+}
+
+func TestServer_ApplyConfig_KeepsListener(t *testing.T) {
+	server, cfg := newReloadTestServer(t)
+
+	cfg.Port = cfg.Port + 1
+	cfg.HTTP2.H2C = !cfg.HTTP2.H2C
+	cfg.ShutdownTimeout = time.Minute
+	require.NoError(t, server.ApplyConfig(cfg))
+	assert.Equal(t, DefaultConfig().Port, server.cfg.Port)
+	assert.Equal(t, DefaultConfig().HTTP2, server.cfg.HTTP2)
+	assert.Equal(t, time.Minute, server.cfg.ShutdownTimeout)
+}
+
+func TestServer_ApplyConfig_WriteTimeout(t *testing.T) {
+	server, cfg := newReloadTestServer(t)
+	server.engine.Load().GET("/sleep", func(c *gin.Context) {
+		time.Sleep(200 * time.Millisecond)
+		c.String(http.StatusOK, "done")
+	})
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	resp, err := http.Get(httpServer.URL + "/sleep")
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	cfg.WriteTimeout = 50 * time.Millisecond
+	require.NoError(t, server.ApplyConfig(cfg))
+	_, err = http.Get(httpServer.URL + "/sleep")
+	assert.Error(t, err, "the response is cut by the new write timeout")
+}
@@ -0,0 +1,348 @@
+package web
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"grouter/pkg/cache"
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// HeaderXCache reports whether a response was served from the response cache
+const HeaderXCache = "X-Cache"
+
+// ResponseCacheInvalidateType is the message type of invalidation requests
+const ResponseCacheInvalidateType = "cache.invalidate"
+
+// ResponseCacheConfig holds configuration for the response cache middleware
+type ResponseCacheConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// TTL of responses without a Cache-Control max-age (default 1m)
+	TTL time.Duration `mapstructure:"ttl"`
+	// MaxTTL caps the TTL taken from Cache-Control (default 1h)
+	MaxTTL time.Duration `mapstructure:"max_ttl"`
+	// Paths are the path prefixes that are cached (default all)
+	Paths []string `mapstructure:"paths"`
+	// ExcludePaths are path prefixes that are never cached. Health probes are
+	// always excluded.
+	ExcludePaths []string `mapstructure:"exclude_paths"`
+	// VaryHeaders are the request headers that are part of the cache key
+	// (default Accept and Accept-Encoding). Requests with an Authorization or
+	// Cookie header are only cached when that header is listed.
+	VaryHeaders []string `mapstructure:"vary_headers"`
+	// MaxBodySize is the largest response that is stored (default 1MB)
+	MaxBodySize int `mapstructure:"max_body_size"`
+	// KeyPrefix namespaces the cache keys (default "httpcache:")
+	KeyPrefix string `mapstructure:"key_prefix"`
+	// InvalidationSubject receives ResponseCacheInvalidation messages over
+	// NATS (default "<app>.cache.invalidate")
+	InvalidationSubject string `mapstructure:"invalidation_subject"`
+	// Cache stores the responses (nil uses an in-memory cache)
+	Cache cache.Cache `mapstructure:"-"`
+}
+
+// ResponseCacheInvalidation is the payload of an invalidation request
+type ResponseCacheInvalidation struct {
+	// Paths whose responses are dropped, for every query string and header
+	Paths []string `json:"paths,omitempty"`
+	// All drops every cached response
+	All bool `json:"all,omitempty"`
+}
+
+// responseCacheEntry is a stored response
+type responseCacheEntry struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+	Stored time.Time   `json:"stored"`
+}
+
+// ResponseCache caches GET responses. All state lives in its cache, so
+// instances sharing a cache also share their entries and invalidations.
+type ResponseCache struct {
+	cfg   ResponseCacheConfig
+	cache cache.Cache
+	vary  []string
+}
+
+// NewResponseCache creates a response cache, applying the defaults of cfg
+func NewResponseCache(cfg ResponseCacheConfig) *ResponseCache {
+	if cfg.TTL <= 0 {
+		cfg.TTL = time.Minute
+	}
+	if cfg.MaxTTL <= 0 {
+		cfg.MaxTTL = time.Hour
+	}
+	if cfg.MaxTTL < cfg.TTL {
+		cfg.MaxTTL = cfg.TTL
+	}
+	if len(cfg.VaryHeaders) == 0 {
+		cfg.VaryHeaders = []string{"Accept", "Accept-Encoding"}
+	}
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = 1 << 20
+	}
+	if cfg.KeyPrefix == "" {
+		cfg.KeyPrefix = "httpcache:"
+	}
+	if cfg.Cache == nil {
+		cfg.Cache = cache.New(cache.NewMemoryStore(10000), cache.WithName("http"))
+	}
+
+	vary := make([]string, len(cfg.VaryHeaders))
+	for i, h := range cfg.VaryHeaders {
+		vary[i] = http.CanonicalHeaderKey(h)
+	}
+	sort.Strings(vary)
+	return &ResponseCache{cfg: cfg, cache: cfg.Cache, vary: vary}
+}
+
+// Cache returns the cache holding the responses
+func (rc *ResponseCache) Cache() cache.Cache {
+	return rc.cache
+}
+
+// Middleware serves GET requests from the cache and stores cacheable
+// responses. It honors Cache-Control: requests with no-cache or no-store
+// bypass the cache, and responses with no-store, no-cache or private, a
+// Set-Cookie header or a status other than 200 are not stored.
+func (rc *ResponseCache) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !rc.applies(c.Request) {
+			c.Next()
+			return
+		}
+		ctx := c.Request.Context()
+		key, err := rc.key(ctx, c.Request)
+		if err != nil {
+			c.Next()
+			return
+		}
+
+		reqCC := parseCacheControl(c.Request.Header.Values("Cache-Control"))
+		_, noStore := reqCC["no-store"]
+		if !noStore && !reqCC.noCache() {
+			if entry, err := cache.GetJSON[responseCacheEntry](ctx, rc.cache, key); err == nil {
+				serveCached(c, &entry)
+				return
+			}
+		}
+
+		c.Header(HeaderXCache, "MISS")
+		w := &recordingWriter{ResponseWriter: c.Writer, limit: rc.cfg.MaxBodySize}
+		c.Writer = w
+		defer func() { c.Writer = w.ResponseWriter }()
+
+		c.Next()
+
+		if noStore || w.overflow {
+			return
+		}
+		ttl, ok := rc.storable(w.Status(), w.Header())
+		if !ok {
+			return
+		}
+		header := w.Header().Clone()
+		header.Del(HeaderXCache)
+		entry := responseCacheEntry{
+			Status: w.Status(),
+			Header: header,
+			Body:   w.body.Bytes(),
+			Stored: time.Now(),
+		}
+		_ = cache.SetJSON(context.WithoutCancel(ctx), rc.cache, key, entry, ttl)
+	}
+}
+
+// Invalidate drops the cached responses of paths, for every query string and
+// header
+func (rc *ResponseCache) Invalidate(ctx context.Context, paths ...string) error {
+	for _, p := range paths {
+		if err := rc.bump(ctx, rc.cfg.KeyPrefix+"gen:"+p); err != nil {
+			return fmt.Errorf("failed to invalidate %s: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// InvalidateAll drops every cached response
+func (rc *ResponseCache) InvalidateAll(ctx context.Context) error {
+	if err := rc.bump(ctx, rc.cfg.KeyPrefix+"gen"); err != nil {
+		return fmt.Errorf("failed to invalidate response cache: %w", err)
+	}
+	return nil
+}
+
+// HandleInvalidation is a NATS handler applying ResponseCacheInvalidation
+// messages
+func (rc *ResponseCache) HandleInvalidation(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+	var inv ResponseCacheInvalidation
+	if err := json.Unmarshal(env.Data, &inv); err != nil {
+		return fmt.Errorf("invalid cache invalidation: %w", err)
+	}
+	if inv.All {
+		return rc.InvalidateAll(ctx)
+	}
+	return rc.Invalidate(ctx, inv.Paths...)
+}
+
+func (rc *ResponseCache) applies(r *http.Request) bool {
+	if r.Method != http.MethodGet {
+		return false
+	}
+	path := r.URL.Path
+	if strings.HasPrefix(path, "/health/") || hasPrefixAny(path, rc.cfg.ExcludePaths) {
+		return false
+	}
+	if len(rc.cfg.Paths) > 0 && !hasPrefixAny(path, rc.cfg.Paths) {
+		return false
+	}
+	// Credentials that are not part of the key would leak responses across
+	// callers
+	for _, h := range []string{"Authorization", "Cookie"} {
+		if r.Header.Get(h) != "" && !rc.varies(h) {
+			return false
+		}
+	}
+	return true
+}
+
+func (rc *ResponseCache) varies(header string) bool {
+	i := sort.SearchStrings(rc.vary, header)
+	return i < len(rc.vary) && rc.vary[i] == header
+}
+
+// key hashes the request with the current generations of the cache and of
+// the path, so that bumping either one orphans the stored responses
+func (rc *ResponseCache) key(ctx context.Context, r *http.Request) (string, error) {
+	global, err := rc.generation(ctx, rc.cfg.KeyPrefix+"gen")
+	if err != nil {
+		return "", err
+	}
+	path, err := rc.generation(ctx, rc.cfg.KeyPrefix+"gen:"+r.URL.Path)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s?%s\n", global, path, r.URL.Path, r.URL.Query().Encode())
+	for _, name := range rc.vary {
+		fmt.Fprintf(h, "%s: %s\n", name, strings.Join(r.Header.Values(name), ","))
+	}
+	return rc.cfg.KeyPrefix + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (rc *ResponseCache) generation(ctx context.Context, key string) (string, error) {
+	gen, err := rc.cache.Get(ctx, key)
+	if errors.Is(err, cache.ErrNotFound) {
+		return "0", nil
+	}
+	return string(gen), err
+}
+
+// bump starts a new generation. It outlives every response stored under the
+// previous one, so an expired generation cannot revive them.
+func (rc *ResponseCache) bump(ctx context.Context, key string) error {
+	return rc.cache.Set(ctx, key, []byte(uuid.NewString()), rc.cfg.MaxTTL)
+}
+
+// storable returns the TTL of a response, or false if it must not be stored
+func (rc *ResponseCache) storable(status int, header http.Header) (time.Duration, bool) {
+	if status != http.StatusOK || header.Get("Set-Cookie") != "" {
+		return 0, false
+	}
+	if strings.HasPrefix(header.Get("Content-Type"), "text/event-stream") {
+		return 0, false
+	}
+	for _, v := range header.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name == "*" || (name != "" && !rc.varies(name)) {
+				return 0, false
+			}
+		}
+	}
+
+	cc := parseCacheControl(header.Values("Cache-Control"))
+	if _, ok := cc["no-store"]; ok {
+		return 0, false
+	}
+	if _, ok := cc["private"]; ok {
+		return 0, false
+	}
+	if cc.noCache() {
+		return 0, false
+	}
+	ttl := rc.cfg.TTL
+	if age, ok := cc.seconds("s-maxage"); ok {
+		ttl = age
+	} else if age, ok := cc.seconds("max-age"); ok {
+		ttl = age
+	}
+	if ttl <= 0 {
+		return 0, false
+	}
+	return min(ttl, rc.cfg.MaxTTL), true
+}
+
+func serveCached(c *gin.Context, entry *responseCacheEntry) {
+	h := c.Writer.Header()
+	for k, v := range entry.Header {
+		h[k] = v
+	}
+	h.Set(HeaderXCache, "HIT")
+	h.Set("Age", strconv.Itoa(int(time.Since(entry.Stored).Seconds())))
+	c.Abort()
+	c.Status(entry.Status)
+	_, _ = c.Writer.Write(entry.Body)
+}
+
+// cacheControl holds Cache-Control directives by lower case name
+type cacheControl map[string]string
+
+func parseCacheControl(values []string) cacheControl {
+	cc := cacheControl{}
+	for _, v := range values {
+		for _, d := range strings.Split(v, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(d), "=")
+			if name != "" {
+				cc[strings.ToLower(name)] = strings.Trim(value, `"`)
+			}
+		}
+	}
+	return cc
+}
+
+func (cc cacheControl) seconds(directive string) (time.Duration, bool) {
+	v, ok := cc[directive]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+// noCache reports whether the directives require revalidation, which the
+// cache does not support
+func (cc cacheControl) noCache() bool {
+	if _, ok := cc["no-cache"]; ok {
+		return true
+	}
+	age, ok := cc.seconds("max-age")
+	return ok && age == 0
+}
@@ -0,0 +1,202 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"grouter/pkg/cache"
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newResponseCacheEngine(rc *ResponseCache, calls *int64) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(rc.Middleware())
+	r.GET("/items", func(c *gin.Context) {
+		n := atomic.AddInt64(calls, 1)
+		c.JSON(http.StatusOK, gin.H{"call": n, "q": c.Query("q")})
+	})
+	r.GET("/private", func(c *gin.Context) {
+		atomic.AddInt64(calls, 1)
+		c.Header("Cache-Control", "private")
+		c.String(http.StatusOK, "mine")
+	})
+	r.GET("/short", func(c *gin.Context) {
+		atomic.AddInt64(calls, 1)
+		c.Header("Cache-Control", "public, max-age=1")
+		c.String(http.StatusOK, "short")
+	})
+	r.GET("/missing", func(c *gin.Context) {
+		atomic.AddInt64(calls, 1)
+		c.Status(http.StatusNotFound)
+	})
+	r.GET("/health/ready", func(c *gin.Context) {
+		atomic.AddInt64(calls, 1)
+		c.String(http.StatusOK, "ready")
+	})
+	return r
+}
+
+func getCached(r *gin.Engine, path string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestResponseCache_HitAndMiss(t *testing.T) {
+	var calls int64
+	r := newResponseCacheEngine(NewResponseCache(ResponseCacheConfig{}), &calls)
+
+	first := getCached(r, "/items?q=a")
+	assert.Equal(t, "MISS", first.Header().Get(HeaderXCache))
+
+	second := getCached(r, "/items?q=a")
+	assert.Equal(t, "HIT", second.Header().Get(HeaderXCache))
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, "application/json; charset=utf-8", second.Header().Get("Content-Type"))
+	assert.NotEmpty(t, second.Header().Get("Age"))
+	assert.Equal(t, int64(1), atomic.LoadInt64(&calls))
+
+	// The query string and the vary headers are part of the key
+	assert.Equal(t, "MISS", getCached(r, "/items?q=b").Header().Get(HeaderXCache))
+	assert.Equal(t, "MISS", getCached(r, "/items?q=a", "Accept", "text/plain").Header().Get(HeaderXCache))
+	assert.Equal(t, int64(3), atomic.LoadInt64(&calls))
+}
+
+func TestResponseCache_CacheControl(t *testing.T) {
+	var calls int64
+	r := newResponseCacheEngine(NewResponseCache(ResponseCacheConfig{}), &calls)
+
+	// Clients can bypass the cache
+	getCached(r, "/items")
+	assert.Equal(t, "MISS", getCached(r, "/items", "Cache-Control", "no-cache").Header().Get(HeaderXCache))
+	assert.Equal(t, "MISS", getCached(r, "/items", "Cache-Control", "max-age=0").Header().Get(HeaderXCache))
+	assert.Equal(t, int64(3), atomic.LoadInt64(&calls))
+
+	// Private responses, errors and health probes are not stored
+	for _, path := range []string{"/private", "/missing", "/health/ready"} {
+		getCached(r, path)
+		assert.NotEqual(t, "HIT", getCached(r, path).Header().Get(HeaderXCache), path)
+	}
+
+	// Authenticated requests are not cached unless keyed by their credentials
+	atomic.StoreInt64(&calls, 0)
+	getCached(r, "/items", "Authorization", "Bearer a")
+	getCached(r, "/items", "Authorization", "Bearer a")
+	assert.Equal(t, int64(2), atomic.LoadInt64(&calls))
+}
+
+func TestResponseCache_MaxAge(t *testing.T) {
+	rc := NewResponseCache(ResponseCacheConfig{TTL: time.Hour})
+	ttl, ok := rc.storable(http.StatusOK, http.Header{"Cache-Control": {"public, max-age=1"}})
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, ttl)
+
+	ttl, ok = rc.storable(http.StatusOK, http.Header{"Cache-Control": {"max-age=60, s-maxage=30"}})
+	assert.True(t, ok)
+	assert.Equal(t, 30*time.Second, ttl)
+
+	ttl, ok = rc.storable(http.StatusOK, http.Header{"Cache-Control": {"max-age=86400"}})
+	assert.True(t, ok)
+	assert.Equal(t, time.Hour, ttl, "capped at MaxTTL")
+
+	_, ok = rc.storable(http.StatusOK, http.Header{"Vary": {"X-Tenant"}})
+	assert.False(t, ok, "varies on a header outside the key")
+	_, ok = rc.storable(http.StatusOK, http.Header{"Vary": {"Accept-Encoding"}})
+	assert.True(t, ok)
+	_, ok = rc.storable(http.StatusOK, http.Header{"Set-Cookie": {"a=b"}})
+	assert.False(t, ok)
+}
+
+func TestResponseCache_Invalidate(t *testing.T) {
+	var calls int64
+	rc := NewResponseCache(ResponseCacheConfig{})
+	r := newResponseCacheEngine(rc, &calls)
+	ctx := context.Background()
+
+	getCached(r, "/items?q=a")
+	getCached(r, "/items?q=b")
+	getCached(r, "/short")
+	require.NoError(t, rc.Invalidate(ctx, "/items"))
+
+	// Every query string of the path is dropped, other paths are kept
+	assert.Equal(t, "MISS", getCached(r, "/items?q=a").Header().Get(HeaderXCache))
+	assert.Equal(t, "MISS", getCached(r, "/items?q=b").Header().Get(HeaderXCache))
+	assert.Equal(t, "HIT", getCached(r, "/short").Header().Get(HeaderXCache))
+
+	require.NoError(t, rc.InvalidateAll(ctx))
+	assert.Equal(t, "MISS", getCached(r, "/short").Header().Get(HeaderXCache))
+	assert.Equal(t, "MISS", getCached(r, "/items?q=a").Header().Get(HeaderXCache))
+}
+
+func TestResponseCache_HandleInvalidation(t *testing.T) {
+	var calls int64
+	rc := NewResponseCache(ResponseCacheConfig{})
+	r := newResponseCacheEngine(rc, &calls)
+
+	getCached(r, "/items")
+	data, _ := json.Marshal(ResponseCacheInvalidation{Paths: []string{"/items"}})
+	require.NoError(t, rc.HandleInvalidation(context.Background(), "app.cache.invalidate",
+		&messaging.MessageEnvelope{Type: ResponseCacheInvalidateType, Data: data}))
+	assert.Equal(t, "MISS", getCached(r, "/items").Header().Get(HeaderXCache))
+
+	assert.Error(t, rc.HandleInvalidation(context.Background(), "app.cache.invalidate",
+		&messaging.MessageEnvelope{Data: []byte("{")}))
+}
+
+func TestResponseCache_SharedRedis(t *testing.T) {
+	mr := miniredis.RunT(t)
+	newInstance := func() *ResponseCache {
+		store := cache.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}), "")
+		return NewResponseCache(ResponseCacheConfig{Cache: cache.New(store)})
+	}
+
+	var callsA, callsB int64
+	a, b := newInstance(), newInstance()
+	ra, rb := newResponseCacheEngine(a, &callsA), newResponseCacheEngine(b, &callsB)
+
+	getCached(ra, "/items")
+	// Another instance serves the response stored by the first one
+	assert.Equal(t, "HIT", getCached(rb, "/items").Header().Get(HeaderXCache))
+	assert.Equal(t, int64(0), atomic.LoadInt64(&callsB))
+
+	// and sees its invalidations
+	require.NoError(t, a.Invalidate(context.Background(), "/items"))
+	assert.Equal(t, "MISS", getCached(rb, "/items").Header().Get(HeaderXCache))
+}
+
+func TestServer_ResponseCache(t *testing.T) {
+	s := newTestServer(t, Config{Mode: gin.TestMode, ResponseCache: ResponseCacheConfig{Enabled: true}}, zap.NewNop(), nil)
+	var calls int64
+	s.engine.Load().GET("/items", func(c *gin.Context) {
+		c.String(http.StatusOK, fmt.Sprint(atomic.AddInt64(&calls, 1)))
+	})
+
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.engine.Load().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items", nil))
+		return w
+	}
+	get()
+	assert.Equal(t, "HIT", get().Header().Get(HeaderXCache))
+	require.NotNil(t, s.ResponseCache())
+	require.NoError(t, s.ResponseCache().Invalidate(context.Background(), "/items"))
+	assert.Equal(t, "2", get().Body.String())
+}
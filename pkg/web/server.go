@@ -2,14 +2,17 @@ package web
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"net"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-contrib/secure"
 	"github.com/gin-gonic/gin"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
@@ -17,6 +20,8 @@ import (
 
 	_ "grouter/docs" // Import generated docs
 	"grouter/pkg/health"
+	"grouter/pkg/loadshed"
+	applog "grouter/pkg/logger"
 )
 
 // Server wraps the Gin engine and manages the HTTP server lifecycle
@@ -36,28 +41,73 @@ import (
 // @BasePath /
 // @schemes http https
 type Server struct {
-	engine *gin.Engine
-	server *http.Server
-	cfg    Config
-	logger *zap.Logger
-	health *health.HealthService
+	// engine receives route registrations; it becomes live on Activate. It is
+	// swapped atomically by ResetEngine while live keeps serving.
+	engine atomic.Pointer[gin.Engine]
+	// live is the engine serving requests, swapped atomically on Activate
+	live     atomic.Pointer[gin.Engine]
+	mu       sync.Mutex
+	server   *http.Server
+	listener net.Listener
+	certs    *CertReloader
+	// challenge serves ACME HTTP-01 challenges when autocert is enabled
+	challenge         *http.Server
+	challengeListener net.Listener
+	// profiling serves pprof on the admin port when one is configured
+	profiling         *http.Server
+	profilingListener net.Listener
+	// versions registered with RegisterWebServiceV, for Accept negotiation
+	versions sync.Map
+	// openapi holds the operations described by registered services
+	openapi openAPIRegistry
+	// middleware added with Use, re-applied when the engine is reset
+	middleware []gin.HandlerFunc
+	cfg        Config
+	logger     *zap.Logger
+	health     *health.HealthService
+
+	// responseCache invalidates the cached responses, nil when disabled
+	responseCache *ResponseCache
+	// timeouts replace the http.Server timeouts after ApplyConfig
+	timeouts atomic.Pointer[requestTimeouts]
 }
 
-func InitEngine(cfg Config, logger *zap.Logger) *gin.Engine {
+// InitEngine builds the engine of cfg with the framework middleware. It
+// reports invalid middleware settings as an error.
+func InitEngine(cfg Config, logger *zap.Logger) (*gin.Engine, error) {
 	engine := gin.New()
 	engine.Use(RequestIDMiddleware())
-	engine.Use(gin.Recovery())
+	engine.Use(ErrorMiddleware(logger))
+
+	// Tracing wraps logging so request logs carry trace_id/span_id
+	if cfg.Tracing.Enabled {
+		engine.Use(otelgin.Middleware(cfg.Tracing.ServiceName))
+	}
 
 	if cfg.Logging.Enabled {
 		engine.Use(LoggerMiddleware(logger))
 	}
 
-	if cfg.Tracing.Enabled {
-		engine.Use(otelgin.Middleware(cfg.Tracing.ServiceName))
+	// Shed load before any costly middleware runs
+	if cfg.LoadShedding.Enabled {
+		shedder := cfg.LoadShedding.Shedder
+		if shedder == nil {
+			shedder = loadshed.New(cfg.LoadShedding.Config)
+		}
+		engine.Use(LoadSheddingMiddleware(shedder, cfg.Metrics.Registry, cfg.LoadShedding.ExcludePaths...))
 	}
 
 	if cfg.Auth.Enabled {
-		engine.Use(AuthMiddleware(cfg.Auth))
+		// Auth is critical; a misconfiguration must not silently expose the API
+		auth, err := NewAuthenticator(context.Background(), cfg.Auth)
+		if err != nil {
+			return nil, fmt.Errorf("invalid auth config: %w", err)
+		}
+		engine.Use(auth.Middleware())
+	}
+
+	if cfg.Tenant.Enabled {
+		engine.Use(TenantMiddleware(cfg.Tenant))
 	}
 
 	if cfg.CORS.Enabled {
@@ -123,17 +173,67 @@ func InitEngine(cfg Config, logger *zap.Logger) *gin.Engine {
 	}
 
 	if cfg.RateLimit.Enabled {
-		engine.Use(RateLimitMiddleware(cfg.RateLimit.RequestsPerSecond, cfg.RateLimit.Burst))
+		limiter, err := RateLimitFromConfig(cfg.RateLimit)
+		if err != nil {
+			// Same as auth: a broken limiter must not silently disable protection
+			return nil, fmt.Errorf("invalid rate limit config: %w", err)
+		}
+		engine.Use(limiter)
+	}
+
+	if cfg.Limits.Enabled {
+		engine.Use(RequestLimitsMiddleware(cfg.Limits))
+	}
+
+	if cfg.Compression.Enabled {
+		engine.Use(CompressionMiddleware(cfg.Compression))
 	}
 
 	if cfg.Metrics.Enabled {
-		engine.Use(MetricsMiddleware())
+		engine.Use(MetricsMiddleware(cfg.Metrics.Registry))
 		// Register metrics handler
 		path := cfg.Metrics.Path
 		if path == "" {
 			path = "/metrics"
 		}
-		engine.GET(path, gin.WrapH(promhttp.Handler()))
+		engine.GET(path, gin.WrapH(cfg.Metrics.Registry.Handler()))
+	}
+
+	// Injected faults count against the objectives
+	if cfg.SLO != nil {
+		engine.Use(SLOMiddleware(cfg.SLO))
+	}
+
+	// Injected faults show in the request logs and metrics
+	if cfg.Chaos != nil {
+		engine.Use(ChaosMiddleware(cfg.Chaos, cfg.Metrics.Registry))
+	}
+
+	if cfg.Session.Enabled {
+		sessions, err := SessionFromConfig(cfg.Session, logger)
+		if err != nil {
+			return nil, fmt.Errorf("invalid session config: %w", err)
+		}
+		engine.Use(sessions)
+	}
+
+	if cfg.Idempotency.Enabled {
+		idempotency, err := IdempotencyFromConfig(cfg.Idempotency)
+		if err != nil {
+			return nil, fmt.Errorf("invalid idempotency config: %w", err)
+		}
+		engine.Use(idempotency)
+	}
+
+	if cfg.ResponseCache.Enabled {
+		engine.Use(NewResponseCache(cfg.ResponseCache).Middleware())
+	}
+
+	if cfg.Profiling.Enabled && cfg.Profiling.Port == 0 {
+		if err := validateProfiling(cfg); err != nil {
+			return nil, err
+		}
+		RegisterProfiling(engine, cfg.Profiling)
 	}
 
 	if cfg.Swagger.Enabled {
@@ -143,39 +243,72 @@ func InitEngine(cfg Config, logger *zap.Logger) *gin.Engine {
 		}
 		engine.GET(path+"/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 	}
-	return engine
+	return engine, nil
 }
 
-// NewWebServer creates a new Web Server instance
-func NewWebServer(cfg Config, logger *zap.Logger, healthSvc *health.HealthService) *Server {
+// NewWebServer creates a new Web Server instance. It fails if the middleware
+// settings of cfg are invalid.
+func NewWebServer(cfg Config, logger *zap.Logger, healthSvc *health.HealthService) (*Server, error) {
 	// Set Gin mode
 	gin.SetMode(cfg.Mode)
 
-	engine := InitEngine(cfg, logger)
+	// The engines built by ResetEngine share the cache of the first one, so
+	// that invalidations through ResponseCache apply to all of them
+	var responseCache *ResponseCache
+	if cfg.ResponseCache.Enabled {
+		responseCache = NewResponseCache(cfg.ResponseCache)
+		cfg.ResponseCache.Cache = responseCache.Cache()
+	}
+	// They also share the count of requests in flight of load shedding
+	if cfg.LoadShedding.Enabled && cfg.LoadShedding.Shedder == nil {
+		cfg.LoadShedding.Shedder = loadshed.New(cfg.LoadShedding.Config)
+	}
+
+	engine, err := InitEngine(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ConfigureValidation(cfg.Validation); err != nil {
+		logger.Warn("Invalid validation config, using English messages", zap.Error(err))
+	}
 
 	server := &Server{
-		engine: engine,
 		cfg:    cfg,
 		logger: logger,
 		health: healthSvc,
+
+		responseCache: responseCache,
 	}
+	server.engine.Store(engine)
+	server.live.Store(engine)
 
 	// Register health handlers
 	if healthSvc != nil {
-		server.engine.GET("/health/live", healthSvc.LivenessHandler)
-		server.engine.GET("/health/ready", healthSvc.ReadinessHandler)
+		engine.GET("/health/live", healthSvc.LivenessHandler)
+		engine.GET("/health/ready", healthSvc.ReadinessHandler)
+		engine.GET("/health/startup", healthSvc.StartupHandler)
 	}
-	return server
+	server.registerOpenAPI(engine)
+	return server, nil
 }
 
 // RegisterService registers a service's routes with the server
 func (s *Server) RegisterWebService(service WebService) {
-	service.RegisterRoutes(s.engine.Group("/"))
+	s.describe(service, "/")
+	service.RegisterRoutes(s.engine.Load().Group("/"))
 }
 
-// Use adds middleware to the web server engine
+// Use adds middleware to the web server engine. It only applies to routes
+// registered afterwards and is kept across ResetEngine.
 func (s *Server) Use(middleware ...gin.HandlerFunc) {
-	s.engine.Use(middleware...)
+	s.middleware = append(s.middleware, middleware...)
+	s.engine.Load().Use(middleware...)
+}
+
+// ResponseCache returns the response cache, or nil if it is disabled
+func (s *Server) ResponseCache() *ResponseCache {
+	return s.responseCache
 }
 
 // Health returns the underlying health service
@@ -183,32 +316,121 @@ func (s *Server) Health() *health.HealthService {
 	return s.health
 }
 
-// Start starts the HTTP server
+// ServeHTTP serves the request with the live engine. Requests in flight keep
+// the engine they started with when a new one is activated.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if t := s.timeouts.Load(); t != nil {
+		t.apply(w)
+	}
+	s.negotiateVersion(r)
+	s.live.Load().ServeHTTP(w, r)
+}
+
+// Activate atomically makes the engine built since the last ResetEngine
+// serve new requests
+func (s *Server) Activate() {
+	engine := s.engine.Load()
+	if s.live.Swap(engine) != engine {
+		s.logger.Info("Activated new web engine")
+	}
+}
+
+// Start starts the HTTP server. If it is already running, the engine rebuilt
+// by ResetEngine is activated instead, without touching the listener.
 func (s *Server) Start() error {
-	s.server = &http.Server{
-		Addr:         fmt.Sprintf(":%d", s.cfg.Port),
-		Handler:      s.engine,
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.server != nil {
+		s.Activate()
+		return nil
+	}
+
+	if s.cfg.TLS.Enabled && !s.cfg.TLS.ACME.Enabled && (s.cfg.TLS.CertFile == "" || s.cfg.TLS.KeyFile == "") {
+		return fmt.Errorf("TLS enabled but cert or key file missing")
+	}
+	if err := validateProfiling(s.cfg); err != nil {
+		return err
+	}
+
+	// Listen synchronously so that bind errors are returned and the bound
+	// address is known when Start returns
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", s.cfg.Port))
+	if err != nil {
+		return fmt.Errorf("failed to listen on port %d: %w", s.cfg.Port, err)
+	}
+	// Release everything acquired below if a later step fails
+	started := false
+	defer func() {
+		if !started {
+			_ = listener.Close()
+			s.release()
+		}
+	}()
+
+	server := &http.Server{
+		Handler:      s,
 		ReadTimeout:  s.cfg.ReadTimeout,
 		WriteTimeout: s.cfg.WriteTimeout,
+		Protocols:    s.protocols(),
+	}
+	if s.cfg.HTTP2.MaxConcurrentStreams > 0 {
+		server.HTTP2 = &http.HTTP2Config{MaxConcurrentStreams: s.cfg.HTTP2.MaxConcurrentStreams}
+	}
+
+	if s.cfg.TLS.Enabled && s.cfg.TLS.ACME.Enabled {
+		tlsConfig, err := s.startACME()
+		if err != nil {
+			return err
+		}
+		server.TLSConfig = tlsConfig
+	} else if s.cfg.TLS.Enabled {
+		certs, err := NewCertReloader(s.cfg.TLS.CertFile, s.cfg.TLS.KeyFile, s.logger)
+		if err != nil {
+			return err
+		}
+		s.certs = certs
+		if s.cfg.TLS.Reload {
+			if err := certs.Watch(); err != nil {
+				return err
+			}
+		}
+		server.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: certs.GetCertificate,
+		}
+	}
+
+	if s.cfg.Profiling.Enabled && s.cfg.Profiling.Port > 0 {
+		if err := s.startProfiling(); err != nil {
+			return err
+		}
 	}
 
-	s.logger.Info("Starting web server", zap.Int("port", s.cfg.Port), zap.Bool("tls", s.cfg.TLS.Enabled))
+	started = true
+	s.live.Store(s.engine.Load())
+	s.listener = listener
+	s.server = server
+
+	s.logger.Info("Starting web server",
+		zap.String("addr", listener.Addr().String()),
+		zap.Bool("tls", s.cfg.TLS.Enabled),
+		zap.Bool("http2", s.cfg.TLS.Enabled && s.cfg.HTTP2.Enabled),
+		zap.Bool("h2c", !s.cfg.TLS.Enabled && s.cfg.HTTP2.H2C),
+	)
 
+	// ApplyConfig may replace s.cfg while serving
+	useTLS := s.cfg.TLS.Enabled
 	go func() {
 		var err error
-		if s.cfg.TLS.Enabled {
-			if s.cfg.TLS.CertFile == "" || s.cfg.TLS.KeyFile == "" {
-				s.logger.Fatal("TLS enabled but cert or key file missing")
-			}
-			err = s.server.ListenAndServeTLS(s.cfg.TLS.CertFile, s.cfg.TLS.KeyFile)
+		if useTLS {
+			// The certificate comes from TLSConfig.GetCertificate
+			err = server.ServeTLS(listener, "", "")
 		} else {
-			err = s.server.ListenAndServe()
+			err = server.Serve(listener)
 		}
 
 		if err != nil && err != http.ErrServerClosed {
-			// In a restart scenario, we might just log error instead of Fatal if it's transient
-			// But for now, sticking to Fatal for critical failures, except we can't Fatal in restart loop ideally.
-			// Let's degrade to Error for robustness if it was a restart.
 			s.logger.Error("Web server stopped", zap.Error(err))
 		}
 	}()
@@ -216,64 +438,150 @@ func (s *Server) Start() error {
 	return nil
 }
 
+// protocols returns the protocols served: HTTP/1.1 plus HTTP/2 over TLS, or
+// unencrypted HTTP/2 (h2c) without TLS
+func (s *Server) protocols() *http.Protocols {
+	p := new(http.Protocols)
+	p.SetHTTP1(true)
+	if s.cfg.TLS.Enabled {
+		p.SetHTTP2(s.cfg.HTTP2.Enabled)
+	} else {
+		p.SetUnencryptedHTTP2(s.cfg.HTTP2.H2C)
+	}
+	return p
+}
+
+// Addr returns the address the server is listening on, or "" if it is not
+// running. With Port 0 it contains the port chosen by the OS.
+func (s *Server) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// Port returns the bound TCP port, or 0 if the server is not running
+func (s *Server) Port() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return 0
+	}
+	if addr, ok := s.listener.Addr().(*net.TCPAddr); ok {
+		return addr.Port
+	}
+	return 0
+}
+
+// HealthCheck reports an error unless the server is listening
+func (s *Server) HealthCheck() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.server == nil {
+		return fmt.Errorf("web server is not running")
+	}
+	return nil
+}
+
 // Stop gracefully shuts down the HTTP server
 func (s *Server) Stop(ctx context.Context) error {
 	s.logger.Info("Stopping web server")
 
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	if s.server == nil {
 		return nil
 	}
+	server := s.server
+	s.server = nil
+	s.listener = nil
+	s.release()
 
 	ctx, cancel := context.WithTimeout(ctx, s.cfg.ShutdownTimeout)
 	defer cancel()
 
-	if err := s.server.Shutdown(ctx); err != nil {
+	if err := server.Shutdown(ctx); err != nil {
 		// Attempt force close if shutdown fails
-		s.server.Close()
+		server.Close()
 		return fmt.Errorf("web server forced to shutdown: %w", err)
 	}
 
 	return nil
 }
 
-// Restart stops and starts the web server
+// release closes the certificate watcher and the ACME challenge and profiling
+// servers started with the main listener
+func (s *Server) release() {
+	if s.certs != nil {
+		_ = s.certs.Close()
+		s.certs = nil
+	}
+	// The listeners are closed here too: Close misses them until Serve runs
+	if s.challenge != nil {
+		_ = s.challenge.Close()
+		_ = s.challengeListener.Close()
+		s.challenge = nil
+		s.challengeListener = nil
+	}
+	if s.profiling != nil {
+		// In-flight CPU profiles would otherwise hold up the shutdown
+		_ = s.profiling.Close()
+		_ = s.profilingListener.Close()
+		s.profiling = nil
+		s.profilingListener = nil
+	}
+}
+
+// ResetEngine builds a new engine with the framework routes and middleware.
+// Routes registered afterwards go to the new engine while the current one keeps
+// serving; Activate (or Start) swaps it in without restarting the listener.
 func (s *Server) ResetEngine(ctx context.Context) error {
-	s.logger.Info("Restarting web server...")
+	s.logger.Info("Rebuilding web engine")
 
-	if err := s.Stop(ctx); err != nil {
-		s.logger.Error("Failed to stop server during restart", zap.Error(err))
-		// Proceeding to start anyway
+	engine, err := InitEngine(s.cfg, s.logger)
+	if err != nil {
+		return err
 	}
-	s.engine = nil
-	// Small delay to allow port release
-	time.Sleep(1 * time.Second)
-
-	s.engine = InitEngine(s.cfg, s.logger)
 	if s.health != nil {
-		s.engine.GET("/health/live", s.health.LivenessHandler)
-		s.engine.GET("/health/ready", s.health.ReadinessHandler)
+		engine.GET("/health/live", s.health.LivenessHandler)
+		engine.GET("/health/ready", s.health.ReadinessHandler)
+		engine.GET("/health/startup", s.health.StartupHandler)
 	}
+	s.registerOpenAPI(engine)
+	engine.Use(s.middleware...)
+	s.engine.Store(engine)
 	return nil
 }
 
-// LoggerMiddleware logs HTTP requests using zap
+// LoggerMiddleware logs HTTP requests using zap. Register it after the
+// tracing middleware to include the IDs of the request span.
 func LoggerMiddleware(logger *zap.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
 		path := c.Request.URL.Path
 		query := c.Request.URL.RawQuery
 
+		// Handlers get a request-scoped logger through applog.FromContext,
+		// including trace_id/span_id of the active span
+		reqLogger := logger.With(zap.String("request_id", c.GetString("RequestID")))
+		c.Request = c.Request.WithContext(applog.WithContext(c.Request.Context(), reqLogger))
+
 		c.Next()
 
+		traceFields := applog.TraceFieldsFromContext(c.Request.Context())
+
 		end := time.Now()
 		latency := end.Sub(start)
 
 		if len(c.Errors) > 0 {
 			for _, e := range c.Errors.Errors() {
-				logger.Error(e)
+				logger.Error(e, append([]zap.Field{zap.String("request_id", c.GetString("RequestID"))}, traceFields...)...)
 			}
 		} else {
-			logger.Info("HTTP Request",
+			fields := []zap.Field{
 				zap.String("request_id", c.GetString("RequestID")),
 				zap.Int("status", c.Writer.Status()),
 				zap.String("method", c.Request.Method),
@@ -282,7 +590,15 @@ func LoggerMiddleware(logger *zap.Logger) gin.HandlerFunc {
 				zap.String("ip", c.ClientIP()),
 				zap.String("user-agent", c.Request.UserAgent()),
 				zap.Duration("latency", latency),
-			)
+			}
+			if id, ok := IdentityFromContext(c); ok {
+				fields = append(fields, zap.String("user_id", id.Subject), zap.String("auth_method", id.Method))
+			}
+			if id := TenantFromContext(c); id != "" {
+				fields = append(fields, zap.String("tenant_id", id))
+			}
+			fields = append(fields, traceFields...)
+			logger.Info("HTTP Request", fields...)
 		}
 	}
 }
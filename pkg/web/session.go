@@ -0,0 +1,685 @@
+package web
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ContextKeySession is the context key holding the request's *Session
+const ContextKeySession = "session"
+
+// Session stores
+const (
+	SessionStoreCookie = "cookie"
+	SessionStoreMemory = "memory"
+	SessionStoreRedis  = "redis"
+)
+
+// SessionConfig holds configuration for cookie sessions
+type SessionConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// CookieName is the session cookie (default grouter_session)
+	CookieName string `mapstructure:"cookie_name"`
+	// Secrets sign and encrypt cookies. The first one is used for new cookies;
+	// the others are still accepted, for key rotation. At least 32 bytes each.
+	Secrets []string `mapstructure:"secrets"`
+	// Store is "cookie" (default, the data is encrypted into the cookie),
+	// "memory" or "redis" (the cookie holds a signed session ID). Services with
+	// a database can use a GormSessionStore with NewSessionManager.
+	Store string               `mapstructure:"store"`
+	Redis RateLimitRedisConfig `mapstructure:"redis"`
+	// IdleTimeout ends sessions without requests for this long (default 30m)
+	IdleTimeout time.Duration `mapstructure:"idle_timeout"`
+	// AbsoluteTimeout ends sessions this long after they started (default 24h)
+	AbsoluteTimeout time.Duration `mapstructure:"absolute_timeout"`
+	Domain          string        `mapstructure:"domain"`
+	// Path of the cookie (default /)
+	Path   string `mapstructure:"path"`
+	Secure bool   `mapstructure:"secure"`
+	// SameSite is "lax" (default), "strict" or "none"
+	SameSite string `mapstructure:"same_site"`
+}
+
+// SessionData is the stored state of a session
+type SessionData struct {
+	Values    map[string]interface{} `json:"values"`
+	CreatedAt time.Time              `json:"created_at"`
+	LastSeen  time.Time              `json:"last_seen"`
+}
+
+// SessionStore keeps session data by ID. Implementations must be safe for
+// concurrent use.
+type SessionStore interface {
+	// Load returns the data of a session, or nil if it does not exist
+	Load(ctx context.Context, id string) (*SessionData, error)
+	Save(ctx context.Context, id string, data *SessionData, ttl time.Duration) error
+	Delete(ctx context.Context, id string) error
+}
+
+// Session is the session of a request. Values must be JSON serializable and
+// come back as JSON types (numbers as float64) on later requests.
+type Session struct {
+	id         string
+	data       SessionData
+	isNew      bool
+	changed    bool
+	destroyed  bool
+	regenerate bool
+}
+
+// ID returns the session ID ("" for cookie sessions and unsaved sessions)
+func (s *Session) ID() string {
+	return s.id
+}
+
+// IsNew reports whether the session started with this request
+func (s *Session) IsNew() bool {
+	return s.isNew
+}
+
+// Get returns a value
+func (s *Session) Get(key string) (interface{}, bool) {
+	v, ok := s.data.Values[key]
+	return v, ok
+}
+
+// GetString returns a string value, or "" if it is missing
+func (s *Session) GetString(key string) string {
+	v, _ := s.data.Values[key].(string)
+	return v
+}
+
+// Set stores a value
+func (s *Session) Set(key string, value interface{}) {
+	if s.data.Values == nil {
+		s.data.Values = make(map[string]interface{})
+	}
+	s.data.Values[key] = value
+	s.changed = true
+}
+
+// Delete removes a value
+func (s *Session) Delete(key string) {
+	if _, ok := s.data.Values[key]; ok {
+		delete(s.data.Values, key)
+		s.changed = true
+	}
+}
+
+// Regenerate issues a new session ID while keeping the values. Call it on
+// login to prevent session fixation.
+func (s *Session) Regenerate() {
+	s.regenerate = true
+	s.changed = true
+}
+
+// Destroy ends the session and clears its cookie, e.g. on logout
+func (s *Session) Destroy() {
+	s.destroyed = true
+	s.data.Values = nil
+}
+
+// GetSession returns the session of the request, or nil if the session
+// middleware is not installed
+func GetSession(c *gin.Context) *Session {
+	s, _ := c.Get(ContextKeySession)
+	sess, _ := s.(*Session)
+	return sess
+}
+
+// SessionManager reads and writes sessions
+type SessionManager struct {
+	cfg      SessionConfig
+	store    SessionStore
+	logger   *zap.Logger
+	signKeys [][]byte
+	aeads    []cipher.AEAD
+	sameSite http.SameSite
+	now      func() time.Time
+}
+
+// NewSessionManager creates a session manager. A nil store keeps the session
+// data encrypted in the cookie.
+func NewSessionManager(cfg SessionConfig, store SessionStore, logger *zap.Logger) (*SessionManager, error) {
+	if len(cfg.Secrets) == 0 {
+		return nil, fmt.Errorf("sessions require at least one secret")
+	}
+	if cfg.CookieName == "" {
+		cfg.CookieName = "grouter_session"
+	}
+	if cfg.Path == "" {
+		cfg.Path = "/"
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = 30 * time.Minute
+	}
+	if cfg.AbsoluteTimeout <= 0 {
+		cfg.AbsoluteTimeout = 24 * time.Hour
+	}
+
+	m := &SessionManager{cfg: cfg, store: store, logger: logger, now: time.Now}
+	switch strings.ToLower(cfg.SameSite) {
+	case "", "lax":
+		m.sameSite = http.SameSiteLaxMode
+	case "strict":
+		m.sameSite = http.SameSiteStrictMode
+	case "none":
+		m.sameSite = http.SameSiteNoneMode
+	default:
+		return nil, fmt.Errorf("invalid session same_site %q", cfg.SameSite)
+	}
+
+	for _, secret := range cfg.Secrets {
+		if len(secret) < 32 {
+			return nil, fmt.Errorf("session secrets must be at least 32 bytes")
+		}
+		signKey, err := hkdf.Key(sha256.New, []byte(secret), nil, "grouter session signing", 32)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive session signing key: %w", err)
+		}
+		encKey, err := hkdf.Key(sha256.New, []byte(secret), nil, "grouter session encryption", 32)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive session encryption key: %w", err)
+		}
+		block, err := aes.NewCipher(encKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create session cipher: %w", err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create session cipher: %w", err)
+		}
+		m.signKeys = append(m.signKeys, signKey)
+		m.aeads = append(m.aeads, aead)
+	}
+	return m, nil
+}
+
+// NewSessionStore creates the store selected in the configuration; the cookie
+// store is nil
+func NewSessionStore(cfg SessionConfig) (SessionStore, error) {
+	switch cfg.Store {
+	case "", SessionStoreCookie:
+		return nil, nil
+	case SessionStoreMemory:
+		return NewMemorySessionStore(), nil
+	case SessionStoreRedis:
+		if cfg.Redis.Addr == "" {
+			return nil, fmt.Errorf("redis session store requires an address")
+		}
+		client := redis.NewClient(&redis.Options{
+			Addr:     cfg.Redis.Addr,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+		})
+		return NewRedisSessionStore(client, cfg.Redis.Prefix), nil
+	default:
+		return nil, fmt.Errorf("invalid session store %q", cfg.Store)
+	}
+}
+
+// SessionFromConfig creates the session middleware from the configuration
+func SessionFromConfig(cfg SessionConfig, logger *zap.Logger) (gin.HandlerFunc, error) {
+	store, err := NewSessionStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+	m, err := NewSessionManager(cfg, store, logger)
+	if err != nil {
+		return nil, err
+	}
+	return m.Middleware(), nil
+}
+
+// touchInterval limits how often unchanged sessions are saved to extend their
+// idle timeout
+const touchInterval = time.Minute
+
+// Middleware loads the session into the context and saves it before the
+// response headers are written
+func (m *SessionManager) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sess := m.load(c)
+		c.Set(ContextKeySession, sess)
+
+		w := &sessionWriter{ResponseWriter: c.Writer}
+		w.commit = func() { m.commit(c, sess) }
+		c.Writer = w
+		defer func() {
+			c.Writer = w.ResponseWriter
+		}()
+
+		c.Next()
+		w.commitOnce()
+	}
+}
+
+// load returns the session of the cookie, or a new one
+func (m *SessionManager) load(c *gin.Context) *Session {
+	now := m.now()
+	fresh := &Session{isNew: true, data: SessionData{CreatedAt: now, LastSeen: now}}
+
+	cookie, err := c.Cookie(m.cfg.CookieName)
+	if err != nil || cookie == "" {
+		return fresh
+	}
+
+	var (
+		id   string
+		data *SessionData
+	)
+	if m.store == nil {
+		data, err = m.decrypt(cookie)
+	} else {
+		id, err = m.verify(cookie)
+		if err == nil {
+			data, err = m.store.Load(c.Request.Context(), id)
+		}
+	}
+	if err != nil {
+		m.logger.Debug("Ignoring invalid session cookie", zap.Error(err))
+		return fresh
+	}
+	if data == nil {
+		return fresh
+	}
+	if now.Sub(data.LastSeen) > m.cfg.IdleTimeout || now.Sub(data.CreatedAt) > m.cfg.AbsoluteTimeout {
+		if id != "" {
+			_ = m.store.Delete(c.Request.Context(), id)
+		}
+		return fresh
+	}
+	return &Session{id: id, data: *data}
+}
+
+// commit saves the session and writes its cookie
+func (m *SessionManager) commit(c *gin.Context, sess *Session) {
+	ctx := context.WithoutCancel(c.Request.Context())
+	now := m.now()
+
+	if sess.destroyed {
+		if m.store != nil && sess.id != "" {
+			if err := m.store.Delete(ctx, sess.id); err != nil {
+				m.logger.Warn("Failed to delete session", zap.Error(err))
+			}
+		}
+		if !sess.isNew {
+			m.setCookie(c, "", -1)
+		}
+		return
+	}
+	if sess.isNew && len(sess.data.Values) == 0 {
+		// Nothing to remember: no cookie for anonymous visitors
+		return
+	}
+	if !sess.changed && now.Sub(sess.data.LastSeen) < touchInterval {
+		return
+	}
+
+	sess.data.LastSeen = now
+	ttl := min(m.cfg.IdleTimeout, m.cfg.AbsoluteTimeout-now.Sub(sess.data.CreatedAt))
+	if ttl <= 0 {
+		return
+	}
+
+	var value string
+	if m.store == nil {
+		v, err := m.encrypt(&sess.data)
+		if err != nil {
+			m.logger.Error("Failed to encode session", zap.Error(err))
+			return
+		}
+		value = v
+	} else {
+		if sess.id == "" || sess.regenerate {
+			if sess.id != "" {
+				_ = m.store.Delete(ctx, sess.id)
+			}
+			sess.id = newSessionID()
+		}
+		if err := m.store.Save(ctx, sess.id, &sess.data, ttl); err != nil {
+			m.logger.Error("Failed to save session", zap.Error(err))
+			return
+		}
+		value = m.sign(sess.id)
+	}
+	if len(value) > 4000 {
+		m.logger.Error("Session cookie too large, use a server-side store", zap.Int("size", len(value)))
+		return
+	}
+	m.setCookie(c, value, int(ttl.Seconds()))
+}
+
+func (m *SessionManager) setCookie(c *gin.Context, value string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     m.cfg.CookieName,
+		Value:    value,
+		Path:     m.cfg.Path,
+		Domain:   m.cfg.Domain,
+		MaxAge:   maxAge,
+		Secure:   m.cfg.Secure,
+		HttpOnly: true,
+		SameSite: m.sameSite,
+	})
+}
+
+// sign returns "id.signature"
+func (m *SessionManager) sign(id string) string {
+	return id + "." + base64.RawURLEncoding.EncodeToString(m.mac(m.signKeys[0], id))
+}
+
+// verify checks the signature against every key and returns the session ID
+func (m *SessionManager) verify(value string) (string, error) {
+	id, sig, ok := strings.Cut(value, ".")
+	if !ok {
+		return "", errors.New("malformed session cookie")
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return "", fmt.Errorf("malformed session cookie: %w", err)
+	}
+	for _, key := range m.signKeys {
+		if hmac.Equal(got, m.mac(key, id)) {
+			return id, nil
+		}
+	}
+	return "", errors.New("invalid session cookie signature")
+}
+
+func (m *SessionManager) mac(key []byte, id string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(m.cfg.CookieName + "=" + id))
+	return h.Sum(nil)
+}
+
+// encrypt seals the session data with the first key. The cookie name is
+// authenticated so that values cannot be moved between cookies.
+func (m *SessionManager) encrypt(data *SessionData) (string, error) {
+	plain, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	aead := m.aeads[0]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, plain, []byte(m.cfg.CookieName))
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// decrypt opens the session data with any key
+func (m *SessionManager) decrypt(value string) (*SessionData, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("malformed session cookie: %w", err)
+	}
+	for _, aead := range m.aeads {
+		if len(sealed) < aead.NonceSize() {
+			break
+		}
+		plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(m.cfg.CookieName))
+		if err != nil {
+			continue
+		}
+		var data SessionData
+		if err := json.Unmarshal(plain, &data); err != nil {
+			return nil, fmt.Errorf("failed to decode session: %w", err)
+		}
+		return &data, nil
+	}
+	return nil, errors.New("invalid session cookie")
+}
+
+func newSessionID() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// sessionWriter saves the session right before the headers are sent, so that
+// the cookie is part of the response
+type sessionWriter struct {
+	gin.ResponseWriter
+	commit    func()
+	committed bool
+}
+
+func (w *sessionWriter) commitOnce() {
+	if !w.committed {
+		w.committed = true
+		w.commit()
+	}
+}
+
+func (w *sessionWriter) WriteHeaderNow() {
+	w.commitOnce()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *sessionWriter) Write(data []byte) (int, error) {
+	w.commitOnce()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *sessionWriter) WriteString(s string) (int, error) {
+	w.commitOnce()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *sessionWriter) Flush() {
+	w.commitOnce()
+	w.ResponseWriter.Flush()
+}
+
+// MemorySessionStore keeps sessions in process memory
+type MemorySessionStore struct {
+	mu        sync.Mutex
+	sessions  map[string]memorySession
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type memorySession struct {
+	data    []byte
+	expires time.Time
+}
+
+// NewMemorySessionStore creates an in-memory store
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: make(map[string]memorySession), now: time.Now}
+}
+
+// Load returns the session data
+func (s *MemorySessionStore) Load(_ context.Context, id string) (*SessionData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.sessions[id]
+	if !ok || s.now().After(e.expires) {
+		return nil, nil
+	}
+	var data SessionData
+	if err := json.Unmarshal(e.data, &data); err != nil {
+		return nil, fmt.Errorf("failed to decode session: %w", err)
+	}
+	return &data, nil
+}
+
+// Save stores the session data
+func (s *MemorySessionStore) Save(_ context.Context, id string, data *SessionData, ttl time.Duration) error {
+	// Stored encoded so that callers cannot mutate it, and values round trip
+	// like in the other stores
+	b, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if now.Sub(s.lastSweep) > time.Minute {
+		for k, e := range s.sessions {
+			if now.After(e.expires) {
+				delete(s.sessions, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	s.sessions[id] = memorySession{data: b, expires: now.Add(ttl)}
+	return nil
+}
+
+// Delete removes the session
+func (s *MemorySessionStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+	return nil
+}
+
+// RedisSessionStore keeps sessions in Redis so that they are shared by every
+// instance
+type RedisSessionStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisSessionStore creates a Redis backed store. Keys are prefixed with
+// prefix (default "session:").
+func NewRedisSessionStore(client redis.UniversalClient, prefix string) *RedisSessionStore {
+	if prefix == "" {
+		prefix = "session:"
+	}
+	return &RedisSessionStore{client: client, prefix: prefix}
+}
+
+// Load returns the session data
+func (s *RedisSessionStore) Load(ctx context.Context, id string) (*SessionData, error) {
+	b, err := s.client.Get(ctx, s.prefix+id).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session: %w", err)
+	}
+	var data SessionData
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, fmt.Errorf("failed to decode session: %w", err)
+	}
+	return &data, nil
+}
+
+// Save stores the session data
+func (s *RedisSessionStore) Save(ctx context.Context, id string, data *SessionData, ttl time.Duration) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+	if err := s.client.Set(ctx, s.prefix+id, b, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the session
+func (s *RedisSessionStore) Delete(ctx context.Context, id string) error {
+	if err := s.client.Del(ctx, s.prefix+id).Err(); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// SessionRecord is the database row of a GormSessionStore
+type SessionRecord struct {
+	ID        string `gorm:"primaryKey;size:64"`
+	Data      []byte
+	ExpiresAt time.Time `gorm:"index"`
+}
+
+// TableName returns the table of the records
+func (SessionRecord) TableName() string {
+	return "sessions"
+}
+
+// GormSessionStore keeps sessions in a database table
+type GormSessionStore struct {
+	db  *gorm.DB
+	now func() time.Time
+}
+
+// NewGormSessionStore creates a database backed store and migrates its table
+func NewGormSessionStore(db *gorm.DB) (*GormSessionStore, error) {
+	if err := db.AutoMigrate(&SessionRecord{}); err != nil {
+		return nil, fmt.Errorf("failed to migrate session table: %w", err)
+	}
+	return &GormSessionStore{db: db, now: time.Now}, nil
+}
+
+// Load returns the session data
+func (s *GormSessionStore) Load(ctx context.Context, id string) (*SessionData, error) {
+	var rec SessionRecord
+	err := s.db.WithContext(ctx).Where("id = ? AND expires_at > ?", id, s.now()).Take(&rec).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session: %w", err)
+	}
+	var data SessionData
+	if err := json.Unmarshal(rec.Data, &data); err != nil {
+		return nil, fmt.Errorf("failed to decode session: %w", err)
+	}
+	return &data, nil
+}
+
+// Save stores the session data
+func (s *GormSessionStore) Save(ctx context.Context, id string, data *SessionData, ttl time.Duration) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+	rec := SessionRecord{ID: id, Data: b, ExpiresAt: s.now().Add(ttl)}
+	err = s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"data", "expires_at"}),
+	}).Create(&rec).Error
+	if err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the session
+func (s *GormSessionStore) Delete(ctx context.Context, id string) error {
+	if err := s.db.WithContext(ctx).Where("id = ?", id).Delete(&SessionRecord{}).Error; err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpired removes expired sessions; run it periodically
+func (s *GormSessionStore) DeleteExpired(ctx context.Context) (int64, error) {
+	res := s.db.WithContext(ctx).Where("expires_at <= ?", s.now()).Delete(&SessionRecord{})
+	if res.Error != nil {
+		return 0, fmt.Errorf("failed to delete expired sessions: %w", res.Error)
+	}
+	return res.RowsAffected, nil
+}
@@ -0,0 +1,207 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+const testSessionSecret = "0123456789abcdef0123456789abcdef"
+
+func newSessionEngine(m *SessionManager) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(m.Middleware())
+	r.POST("/login", func(c *gin.Context) {
+		sess := GetSession(c)
+		sess.Regenerate()
+		sess.Set("user", c.Query("user"))
+		c.Status(http.StatusNoContent)
+	})
+	r.GET("/me", func(c *gin.Context) {
+		user := GetSession(c).GetString("user")
+		if user == "" {
+			AbortWithError(c, Unauthorized("not logged in"))
+			return
+		}
+		c.String(http.StatusOK, user)
+	})
+	r.POST("/logout", func(c *gin.Context) {
+		GetSession(c).Destroy()
+		c.Status(http.StatusNoContent)
+	})
+	return r
+}
+
+func sessionRequest(r *gin.Engine, method, path string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func sessionCookie(w *httptest.ResponseRecorder) *http.Cookie {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "grouter_session" {
+			return c
+		}
+	}
+	return nil
+}
+
+func testSessionStore(t *testing.T, store SessionStore) {
+	m, err := NewSessionManager(SessionConfig{Secrets: []string{testSessionSecret}}, store, zap.NewNop())
+	require.NoError(t, err)
+	r := newSessionEngine(m)
+
+	// Anonymous requests get no cookie
+	w := sessionRequest(r, http.MethodGet, "/me", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Nil(t, sessionCookie(w))
+
+	w = sessionRequest(r, http.MethodPost, "/login?user=alice", nil)
+	cookie := sessionCookie(w)
+	require.NotNil(t, cookie)
+	assert.True(t, cookie.HttpOnly)
+	assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+
+	w = sessionRequest(r, http.MethodGet, "/me", cookie)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "alice", w.Body.String())
+
+	// Tampered cookies are ignored
+	tampered := *cookie
+	flip := "x"
+	if strings.HasPrefix(cookie.Value, flip) {
+		flip = "y"
+	}
+	tampered.Value = flip + cookie.Value[1:]
+	assert.Equal(t, http.StatusUnauthorized, sessionRequest(r, http.MethodGet, "/me", &tampered).Code)
+
+	w = sessionRequest(r, http.MethodPost, "/logout", cookie)
+	cleared := sessionCookie(w)
+	require.NotNil(t, cleared)
+	assert.Negative(t, cleared.MaxAge)
+	if store != nil {
+		// The server side session is gone even if the client keeps the cookie
+		assert.Equal(t, http.StatusUnauthorized, sessionRequest(r, http.MethodGet, "/me", cookie).Code)
+	}
+}
+
+func TestSession_Cookie(t *testing.T) {
+	testSessionStore(t, nil)
+}
+
+func TestSession_Memory(t *testing.T) {
+	testSessionStore(t, NewMemorySessionStore())
+}
+
+func TestSession_Redis(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	testSessionStore(t, NewRedisSessionStore(client, ""))
+}
+
+func TestSession_Gorm(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "sessions.db")), &gorm.Config{})
+	require.NoError(t, err)
+	store, err := NewGormSessionStore(db)
+	require.NoError(t, err)
+	testSessionStore(t, store)
+}
+
+func TestSession_Regenerate(t *testing.T) {
+	m, err := NewSessionManager(SessionConfig{Secrets: []string{testSessionSecret}}, NewMemorySessionStore(), zap.NewNop())
+	require.NoError(t, err)
+	r := newSessionEngine(m)
+
+	first := sessionCookie(sessionRequest(r, http.MethodPost, "/login?user=alice", nil))
+	require.NotNil(t, first)
+	second := sessionCookie(sessionRequest(r, http.MethodPost, "/login?user=bob", first))
+	require.NotNil(t, second)
+	assert.NotEqual(t, first.Value, second.Value)
+	// The old ID no longer works
+	assert.Equal(t, http.StatusUnauthorized, sessionRequest(r, http.MethodGet, "/me", first).Code)
+	assert.Equal(t, "bob", sessionRequest(r, http.MethodGet, "/me", second).Body.String())
+}
+
+func TestSession_Timeouts(t *testing.T) {
+	now := time.Now()
+	m, err := NewSessionManager(SessionConfig{
+		Secrets:         []string{testSessionSecret},
+		IdleTimeout:     10 * time.Minute,
+		AbsoluteTimeout: time.Hour,
+	}, nil, zap.NewNop())
+	require.NoError(t, err)
+	m.now = func() time.Time { return now }
+	r := newSessionEngine(m)
+
+	cookie := sessionCookie(sessionRequest(r, http.MethodPost, "/login?user=alice", nil))
+	require.NotNil(t, cookie)
+
+	// Activity extends the idle timeout
+	for i := 0; i < 5; i++ {
+		now = now.Add(8 * time.Minute)
+		w := sessionRequest(r, http.MethodGet, "/me", cookie)
+		require.Equal(t, http.StatusOK, w.Code)
+		if c := sessionCookie(w); c != nil {
+			cookie = c
+		}
+	}
+
+	// Idle sessions expire
+	now = now.Add(11 * time.Minute)
+	assert.Equal(t, http.StatusUnauthorized, sessionRequest(r, http.MethodGet, "/me", cookie).Code)
+
+	// Active sessions still end after the absolute timeout
+	cookie = sessionCookie(sessionRequest(r, http.MethodPost, "/login?user=alice", nil))
+	for i := 0; i < 7; i++ {
+		now = now.Add(9 * time.Minute)
+		if c := sessionCookie(sessionRequest(r, http.MethodGet, "/me", cookie)); c != nil {
+			cookie = c
+		}
+	}
+	assert.Equal(t, http.StatusUnauthorized, sessionRequest(r, http.MethodGet, "/me", cookie).Code)
+}
+
+func TestSession_KeyRotation(t *testing.T) {
+	oldSecret := strings.Repeat("o", 32)
+	old, err := NewSessionManager(SessionConfig{Secrets: []string{oldSecret}}, nil, zap.NewNop())
+	require.NoError(t, err)
+	cookie := sessionCookie(sessionRequest(newSessionEngine(old), http.MethodPost, "/login?user=alice", nil))
+	require.NotNil(t, cookie)
+
+	rotated, err := NewSessionManager(SessionConfig{Secrets: []string{testSessionSecret, oldSecret}}, nil, zap.NewNop())
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, sessionRequest(newSessionEngine(rotated), http.MethodGet, "/me", cookie).Code)
+
+	removed, err := NewSessionManager(SessionConfig{Secrets: []string{testSessionSecret}}, nil, zap.NewNop())
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, sessionRequest(newSessionEngine(removed), http.MethodGet, "/me", cookie).Code)
+}
+
+func TestNewSessionManager_Invalid(t *testing.T) {
+	_, err := NewSessionManager(SessionConfig{}, nil, zap.NewNop())
+	assert.Error(t, err)
+	_, err = NewSessionManager(SessionConfig{Secrets: []string{"short"}}, nil, zap.NewNop())
+	assert.Error(t, err)
+	_, err = NewSessionManager(SessionConfig{Secrets: []string{testSessionSecret}, SameSite: "loose"}, nil, zap.NewNop())
+	assert.Error(t, err)
+	_, err = NewSessionStore(SessionConfig{Store: "redis"})
+	assert.Error(t, err)
+}
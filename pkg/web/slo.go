@@ -0,0 +1,27 @@
+package web
+
+import (
+	"strings"
+	"time"
+
+	"grouter/pkg/slo"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SLOMiddleware observes the requests of the http objectives of tracker by
+// route prefix, counting the 5xx responses as failures. Requests matching no
+// route are not observed.
+func SLOMiddleware(tracker *slo.Tracker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			return
+		}
+		tracker.Observe(slo.KindHTTP, c.Request.Method, func(prefix string) bool {
+			return strings.HasPrefix(route, prefix)
+		}, time.Since(start), c.Writer.Status() >= 500)
+	}
+}
@@ -0,0 +1,51 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"grouter/pkg/slo"
+	"grouter/pkg/telemetry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSLOMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tracker, err := slo.New(slo.Config{
+		Registry: telemetry.NewMetricsRegistry(),
+		Objectives: []slo.Objective{
+			{Name: "orders", Kind: slo.KindHTTP, Match: "/api/orders", Target: 0.99},
+			{Name: "writes", Kind: slo.KindHTTP, Method: http.MethodPost, Target: 0.99},
+		},
+	})
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.Use(SLOMiddleware(tracker))
+	r.GET("/api/orders/:id", func(c *gin.Context) {
+		if c.Param("id") == "broken" {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+	r.POST("/api/orders", func(c *gin.Context) { c.Status(http.StatusBadRequest) })
+	serve := func(method, path string) {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, path, nil))
+	}
+
+	serve(http.MethodGet, "/api/orders/1")
+	serve(http.MethodGet, "/api/orders/broken")
+	serve(http.MethodPost, "/api/orders")
+	serve(http.MethodGet, "/api/unknown")
+
+	status := tracker.Status()
+	assert.Equal(t, uint64(3), status[0].Total, "requests are matched by route, unrouted ones skipped")
+	assert.Equal(t, uint64(1), status[0].Bad, "5xx responses fail")
+	assert.Equal(t, uint64(1), status[1].Total)
+	assert.Equal(t, uint64(0), status[1].Bad, "4xx responses do not fail")
+}
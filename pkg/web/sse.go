@@ -0,0 +1,249 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	messaging "grouter/pkg/messaging/nats"
+)
+
+// SSEConfig holds configuration for the NATS to Server-Sent Events bridge
+type SSEConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+
+	// Path is the HTTP path clients connect to
+	Path string `mapstructure:"path"`
+
+	// Subject is the NATS subject (wildcards allowed) whose envelopes are streamed
+	Subject string `mapstructure:"subject"`
+
+	// BufferSize is the number of recent events kept for Last-Event-ID replay
+	BufferSize int `mapstructure:"buffer_size"`
+
+	// ClientBufferSize is the number of pending events per client before it is
+	// considered too slow and disconnected
+	ClientBufferSize int `mapstructure:"client_buffer_size"`
+
+	// KeepAlive is the interval between keep-alive comments on idle streams
+	KeepAlive time.Duration `mapstructure:"keep_alive"`
+}
+
+// DefaultSSEConfig returns the default SSE configuration
+func DefaultSSEConfig() SSEConfig {
+	return SSEConfig{
+		Path:             "/events",
+		BufferSize:       100,
+		ClientBufferSize: 64,
+		KeepAlive:        15 * time.Second,
+	}
+}
+
+// SSEvent is a single Server-Sent Event
+type SSEvent struct {
+	// ID is sent as the event id and echoed back by clients in Last-Event-ID
+	ID string
+	// Event is the event name clients can listen for with addEventListener
+	Event string
+	// Data is the payload; strings are sent as-is, everything else as JSON
+	Data interface{}
+}
+
+// StreamSSE streams events to the client until the channel is closed or the
+// client disconnects. Idle streams receive a keep-alive comment every
+// keepAlive interval (0 disables keep-alives).
+func StreamSSE(c *gin.Context, events <-chan SSEvent, keepAlive time.Duration) {
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Header("Content-Type", sse.ContentType)
+
+	// Streams outlive the server's WriteTimeout
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	var tick <-chan time.Time
+	if keepAlive > 0 {
+		ticker := time.NewTicker(keepAlive)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	// Send headers immediately so clients see the connection as open
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	ctx := c.Request.Context()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.Render(-1, sse.Event{Id: ev.ID, Event: ev.Event, Data: ev.Data})
+			return true
+		case <-tick:
+			_, err := w.Write([]byte(":keep-alive\n\n"))
+			return err == nil
+		case <-ctx.Done():
+			return false
+		}
+	})
+}
+
+// SSEBridge fans out NATS envelopes to connected SSE clients. It keeps a
+// bounded history of recent events so reconnecting clients can resume from
+// their Last-Event-ID.
+type SSEBridge struct {
+	cfg    SSEConfig
+	logger *zap.Logger
+
+	mu      sync.Mutex
+	history []SSEvent
+	clients map[chan SSEvent]struct{}
+	closed  bool
+}
+
+// NewSSEBridge creates a new SSEBridge instance
+func NewSSEBridge(cfg SSEConfig, logger *zap.Logger) *SSEBridge {
+	defaults := DefaultSSEConfig()
+	if cfg.Path == "" {
+		cfg.Path = defaults.Path
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = defaults.BufferSize
+	}
+	if cfg.ClientBufferSize <= 0 {
+		cfg.ClientBufferSize = defaults.ClientBufferSize
+	}
+	if cfg.KeepAlive == 0 {
+		cfg.KeepAlive = defaults.KeepAlive
+	}
+	return &SSEBridge{
+		cfg:     cfg,
+		logger:  logger,
+		clients: make(map[chan SSEvent]struct{}),
+	}
+}
+
+// HandleMessage is a messaging.HandlerFunc that broadcasts the envelope to all clients
+func (b *SSEBridge) HandleMessage(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+	data, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	b.Broadcast(SSEvent{ID: env.ID, Event: env.Type, Data: string(data)})
+	return nil
+}
+
+// Broadcast records the event in the replay history and sends it to all clients.
+// Clients whose buffer is full are disconnected; they resume via Last-Event-ID.
+func (b *SSEBridge) Broadcast(ev SSEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	b.history = append(b.history, ev)
+	if len(b.history) > b.cfg.BufferSize {
+		b.history = b.history[len(b.history)-b.cfg.BufferSize:]
+	}
+
+	for ch := range b.clients {
+		select {
+		case ch <- ev:
+		default:
+			b.logger.Warn("SSE client too slow, disconnecting", zap.String("event_id", ev.ID))
+			delete(b.clients, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribe registers a client and returns its channel pre-filled with the
+// events missed since lastEventID. If lastEventID is no longer in the history
+// the whole history is replayed.
+func (b *SSEBridge) subscribe(lastEventID string) (chan SSEvent, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, false
+	}
+
+	var missed []SSEvent
+	if lastEventID != "" {
+		missed = b.history
+		for i := len(b.history) - 1; i >= 0; i-- {
+			if b.history[i].ID == lastEventID {
+				missed = b.history[i+1:]
+				break
+			}
+		}
+	}
+
+	ch := make(chan SSEvent, b.cfg.ClientBufferSize+len(missed))
+	for _, ev := range missed {
+		ch <- ev
+	}
+	b.clients[ch] = struct{}{}
+	return ch, true
+}
+
+func (b *SSEBridge) unsubscribe(ch chan SSEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.clients[ch]; ok {
+		delete(b.clients, ch)
+		close(ch)
+	}
+}
+
+// Clients returns the number of connected clients
+func (b *SSEBridge) Clients() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.clients)
+}
+
+// Handler streams events to a client, replaying missed events when the client
+// reconnects with a Last-Event-ID header (or lastEventId query parameter)
+func (b *SSEBridge) Handler(c *gin.Context) {
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("lastEventId")
+	}
+
+	ch, ok := b.subscribe(lastEventID)
+	if !ok {
+		AbortWithError(c, Unavailable("event stream closed"))
+		return
+	}
+	defer b.unsubscribe(ch)
+
+	StreamSSE(c, ch, b.cfg.KeepAlive)
+}
+
+// RegisterRoutes mounts the event stream endpoint. It implements WebService.
+func (b *SSEBridge) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET(b.cfg.Path, b.Handler)
+}
+
+// Close disconnects all clients and stops accepting new ones
+func (b *SSEBridge) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for ch := range b.clients {
+		delete(b.clients, ch)
+		close(ch)
+	}
+}
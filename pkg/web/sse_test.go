@@ -0,0 +1,144 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type sseFrame struct {
+	id, event, data string
+}
+
+// readFrames parses n events from an SSE stream, skipping keep-alive comments
+func readFrames(t *testing.T, r *bufio.Reader, n int) []sseFrame {
+	t.Helper()
+	var frames []sseFrame
+	var cur sseFrame
+	for len(frames) < n {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "":
+			if cur != (sseFrame{}) {
+				frames = append(frames, cur)
+				cur = sseFrame{}
+			}
+		case strings.HasPrefix(line, "id:"):
+			cur.id = strings.TrimPrefix(line, "id:")
+		case strings.HasPrefix(line, "event:"):
+			cur.event = strings.TrimPrefix(line, "event:")
+		case strings.HasPrefix(line, "data:"):
+			cur.data = strings.TrimPrefix(line, "data:")
+		}
+	}
+	return frames
+}
+
+func newSSETestServer(t *testing.T, bridge *SSEBridge) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	bridge.RegisterRoutes(engine.Group("/"))
+	srv := httptest.NewServer(engine)
+	t.Cleanup(srv.Close)
+	t.Cleanup(bridge.Close)
+	return srv
+}
+
+func connectSSE(t *testing.T, url, lastEventID string) *bufio.Reader {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	require.NoError(t, err)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream"))
+	return bufio.NewReader(resp.Body)
+}
+
+func waitForClients(t *testing.T, bridge *SSEBridge, n int) {
+	t.Helper()
+	require.Eventually(t, func() bool { return bridge.Clients() == n }, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestSSEBridge_StreamsEnvelopes(t *testing.T) {
+	bridge := NewSSEBridge(SSEConfig{}, zap.NewNop())
+	srv := newSSETestServer(t, bridge)
+
+	stream := connectSSE(t, srv.URL+"/events", "")
+	waitForClients(t, bridge, 1)
+
+	env := &messaging.MessageEnvelope{ID: "evt-1", Type: "order.created", Data: json.RawMessage(`{"id":42}`)}
+	require.NoError(t, bridge.HandleMessage(context.Background(), "gRouter.events.order", env))
+
+	frames := readFrames(t, stream, 1)
+	assert.Equal(t, "evt-1", frames[0].id)
+	assert.Equal(t, "order.created", frames[0].event)
+
+	var got messaging.MessageEnvelope
+	require.NoError(t, json.Unmarshal([]byte(frames[0].data), &got))
+	assert.JSONEq(t, `{"id":42}`, string(got.Data))
+}
+
+func TestSSEBridge_LastEventIDReplay(t *testing.T) {
+	bridge := NewSSEBridge(SSEConfig{BufferSize: 3}, zap.NewNop())
+	srv := newSSETestServer(t, bridge)
+
+	for _, id := range []string{"1", "2", "3", "4"} {
+		bridge.Broadcast(SSEvent{ID: id, Event: "tick", Data: id})
+	}
+
+	// Resume after a known event
+	frames := readFrames(t, connectSSE(t, srv.URL+"/events", "3"), 1)
+	assert.Equal(t, "4", frames[0].id)
+
+	// An event that fell out of the history replays everything retained
+	frames = readFrames(t, connectSSE(t, srv.URL+"/events", "1"), 3)
+	assert.Equal(t, []string{"2", "3", "4"}, []string{frames[0].id, frames[1].id, frames[2].id})
+}
+
+func TestSSEBridge_SlowClientDisconnected(t *testing.T) {
+	bridge := NewSSEBridge(SSEConfig{ClientBufferSize: 1}, zap.NewNop())
+	ch, ok := bridge.subscribe("")
+	require.True(t, ok)
+
+	bridge.Broadcast(SSEvent{ID: "1"})
+	bridge.Broadcast(SSEvent{ID: "2"})
+	assert.Equal(t, 0, bridge.Clients())
+
+	ev := <-ch
+	assert.Equal(t, "1", ev.ID)
+	_, open := <-ch
+	assert.False(t, open)
+}
+
+func TestSSEBridge_Close(t *testing.T) {
+	bridge := NewSSEBridge(SSEConfig{}, zap.NewNop())
+	srv := newSSETestServer(t, bridge)
+	bridge.Close()
+
+	resp, err := http.Get(srv.URL + "/events")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
@@ -0,0 +1,76 @@
+package web
+
+import (
+	"grouter/pkg/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tenantKey is the Gin context key of the request tenant
+const tenantKey = "TenantID"
+
+// TenantFromContext returns the tenant set by the tenant middleware, or ""
+func TenantFromContext(c *gin.Context) string {
+	return c.GetString(tenantKey)
+}
+
+// TenantMiddleware resolves the tenant of the request from the token claim,
+// else the header, and stores it in the Gin and request contexts (see
+// tenant.FromContext). It runs after the auth middleware, whose identity
+// carries the claims.
+func TenantMiddleware(cfg TenantConfig) gin.HandlerFunc {
+	skip := make(map[string]bool, len(cfg.SkipPaths))
+	for _, p := range cfg.SkipPaths {
+		skip[p] = true
+	}
+
+	return func(c *gin.Context) {
+		if skip[c.FullPath()] || skip[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+
+		var id string
+		if cfg.Header != "" {
+			id = c.GetHeader(cfg.Header)
+		}
+		if claimed := tenantClaim(c, cfg.Claim); claimed != "" {
+			// A caller cannot act for another tenant than the one of its token
+			if id != "" && id != claimed {
+				AbortWithError(c, Forbidden("tenant does not match the token"))
+				return
+			}
+			id = claimed
+		}
+
+		if id == "" {
+			if cfg.Required {
+				AbortWithError(c, BadRequest("tenant is required"))
+				return
+			}
+			c.Next()
+			return
+		}
+		if err := tenant.Validate(id); err != nil {
+			AbortWithError(c, BadRequest(err.Error()))
+			return
+		}
+
+		c.Set(tenantKey, id)
+		c.Request = c.Request.WithContext(tenant.NewContext(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// tenantClaim returns the string claim of the authenticated identity, or ""
+func tenantClaim(c *gin.Context, claim string) string {
+	if claim == "" {
+		return ""
+	}
+	id, ok := IdentityFromContext(c)
+	if !ok {
+		return ""
+	}
+	v, _ := id.Claims[claim].(string)
+	return v
+}
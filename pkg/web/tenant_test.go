@@ -0,0 +1,75 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"grouter/pkg/tenant"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTenantEngine(t *testing.T, auth AuthConfig, cfg TenantConfig) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	if auth.Enabled {
+		a, err := NewAuthenticator(context.Background(), auth)
+		require.NoError(t, err)
+		engine.Use(a.Middleware())
+	}
+	engine.Use(TenantMiddleware(cfg))
+	handler := func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"tenant": TenantFromContext(c), "context": tenant.FromContext(c.Request.Context())})
+	}
+	engine.GET("/orders", handler)
+	engine.GET("/health/live", handler)
+	return engine
+}
+
+func TestTenantMiddleware_Header(t *testing.T) {
+	engine := newTenantEngine(t, AuthConfig{}, TenantConfig{
+		Enabled:   true,
+		Header:    "X-Tenant-ID",
+		Required:  true,
+		SkipPaths: []string{"/health/live"},
+	})
+
+	code, body := doAuthRequest(engine, "/orders", map[string]string{"X-Tenant-ID": "acme"})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "acme", body["tenant"])
+	assert.Equal(t, "acme", body["context"], "the request context carries the tenant")
+
+	code, _ = doAuthRequest(engine, "/orders", nil)
+	assert.Equal(t, http.StatusBadRequest, code, "the tenant is required")
+	code, _ = doAuthRequest(engine, "/orders", map[string]string{"X-Tenant-ID": "acme.eu"})
+	assert.Equal(t, http.StatusBadRequest, code, "the tenant must be a subject token")
+	code, _ = doAuthRequest(engine, "/health/live", nil)
+	assert.Equal(t, http.StatusOK, code, "skipped paths need no tenant")
+}
+
+func TestTenantMiddleware_Claim(t *testing.T) {
+	f := newJWKSFixture(t)
+	engine := newTenantEngine(t, AuthConfig{
+		Enabled:  true,
+		Mode:     AuthModeOptional,
+		Issuer:   "https://issuer.example.com",
+		Audience: "grouter",
+		JWKSURL:  f.server.URL,
+	}, TenantConfig{Enabled: true, Header: "X-Tenant-ID", Claim: "tenant_id"})
+
+	bearer := "Bearer " + f.token(t, map[string]interface{}{"tenant_id": "acme"})
+	code, body := doAuthRequest(engine, "/orders", map[string]string{"Authorization": bearer})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "acme", body["tenant"])
+
+	code, _ = doAuthRequest(engine, "/orders", map[string]string{"Authorization": bearer, "X-Tenant-ID": "globex"})
+	assert.Equal(t, http.StatusForbidden, code, "the header cannot override the token")
+
+	code, body = doAuthRequest(engine, "/orders", nil)
+	assert.Equal(t, http.StatusOK, code, "the tenant is optional")
+	assert.Equal(t, "", body["tenant"])
+}
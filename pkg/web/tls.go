@@ -0,0 +1,132 @@
+package web
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// CertReloader serves a TLS certificate that can be replaced at runtime. With
+// Watch, the certificate is reloaded when the files change or on SIGHUP; a
+// failed reload keeps the previous certificate.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	logger   *zap.Logger
+	cert     atomic.Pointer[tls.Certificate]
+
+	watcher   *fsnotify.Watcher
+	signals   chan os.Signal
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewCertReloader loads the certificate and key files
+func NewCertReloader(certFile, keyFile string, logger *zap.Logger) (*CertReloader, error) {
+	r := &CertReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		logger:   logger,
+		done:     make(chan struct{}),
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the certificate and key files again
+func (r *CertReloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	r.cert.Store(&cert)
+	return nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load(), nil
+}
+
+// Watch reloads the certificate when the files change or the process receives
+// SIGHUP. The directories are watched rather than the files so that atomic
+// replacements (e.g. Kubernetes secret updates) are seen.
+func (r *CertReloader) Watch() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	dirs := map[string]bool{filepath.Dir(r.certFile): true, filepath.Dir(r.keyFile): true}
+	for dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			_ = watcher.Close()
+			return fmt.Errorf("failed to watch %s: %w", dir, err)
+		}
+	}
+	r.watcher = watcher
+
+	r.signals = make(chan os.Signal, 1)
+	signal.Notify(r.signals, syscall.SIGHUP)
+
+	go r.loop()
+	return nil
+}
+
+func (r *CertReloader) loop() {
+	for {
+		select {
+		case ev, ok := <-r.watcher.Events:
+			if !ok {
+				return
+			}
+			if ev.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) == 0 {
+				continue
+			}
+			r.reload("file change")
+		case err, ok := <-r.watcher.Errors:
+			if !ok {
+				return
+			}
+			r.logger.Warn("TLS certificate watcher error", zap.Error(err))
+		case <-r.signals:
+			r.reload("SIGHUP")
+		case <-r.done:
+			return
+		}
+	}
+}
+
+func (r *CertReloader) reload(reason string) {
+	if err := r.Reload(); err != nil {
+		// The key and certificate are often written separately; the next
+		// event completes the pair
+		r.logger.Warn("TLS certificate reload failed, keeping the current certificate",
+			zap.String("reason", reason), zap.Error(err))
+		return
+	}
+	r.logger.Info("TLS certificate reloaded", zap.String("reason", reason))
+}
+
+// Close stops watching
+func (r *CertReloader) Close() error {
+	r.closeOnce.Do(func() {
+		close(r.done)
+		if r.signals != nil {
+			signal.Stop(r.signals)
+		}
+		if r.watcher != nil {
+			_ = r.watcher.Close()
+		}
+	})
+	return nil
+}
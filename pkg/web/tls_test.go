@@ -0,0 +1,150 @@
+package web
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// writeTestCert writes a self-signed certificate for localhost with the given
+// serial number
+func writeTestCert(t *testing.T, certFile, keyFile string, serial int64) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	// Write the key first so the pair is consistent once the cert changes
+	require.NoError(t, os.WriteFile(keyFile+".tmp", pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	require.NoError(t, os.WriteFile(certFile+".tmp", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.Rename(keyFile+".tmp", keyFile))
+	require.NoError(t, os.Rename(certFile+".tmp", certFile))
+}
+
+func insecureTLSClient() *http.Client {
+	return &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			ForceAttemptHTTP2: true,
+		},
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+
+	_, err := NewCertReloader(certFile, keyFile, zap.NewNop())
+	assert.Error(t, err, "missing files")
+
+	writeTestCert(t, certFile, keyFile, 1)
+	r, err := NewCertReloader(certFile, keyFile, zap.NewNop())
+	require.NoError(t, err)
+	defer r.Close()
+
+	cert, err := r.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), cert.Leaf.SerialNumber.Int64())
+
+	// A broken file keeps the current certificate
+	require.NoError(t, os.WriteFile(certFile, []byte("garbage"), 0o600))
+	assert.Error(t, r.Reload())
+	cert, _ = r.GetCertificate(nil)
+	assert.Equal(t, int64(1), cert.Leaf.SerialNumber.Int64())
+
+	writeTestCert(t, certFile, keyFile, 2)
+	require.NoError(t, r.Reload())
+	cert, _ = r.GetCertificate(nil)
+	assert.Equal(t, int64(2), cert.Leaf.SerialNumber.Int64())
+}
+
+func TestServer_TLSReloadHTTP2(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeTestCert(t, certFile, keyFile, 1)
+
+	cfg := DefaultConfig()
+	cfg.Port = 0
+	cfg.TLS = TLSConfig{Enabled: true, CertFile: certFile, KeyFile: keyFile, Reload: true}
+	server := newTestServer(t, cfg, zap.NewNop(), nil)
+	server.RegisterWebService(&TestService{})
+	require.NoError(t, server.Start())
+	defer server.Stop(context.Background())
+
+	client := insecureTLSClient()
+	url := fmt.Sprintf("https://localhost:%d/ping", server.Port())
+	serial := func() int64 {
+		resp, err := client.Get(url)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, 2, resp.ProtoMajor)
+		return resp.TLS.PeerCertificates[0].SerialNumber.Int64()
+	}
+	assert.Equal(t, int64(1), serial())
+
+	// New connections pick up the replaced certificate without a restart
+	writeTestCert(t, certFile, keyFile, 2)
+	assert.Eventually(t, func() bool {
+		client.CloseIdleConnections()
+		return serial() == 2
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestServer_H2C(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := DefaultConfig()
+	cfg.Port = 0
+	cfg.HTTP2.H2C = true
+	server := newTestServer(t, cfg, zap.NewNop(), nil)
+	server.RegisterWebService(&TestService{})
+	require.NoError(t, server.Start())
+	defer server.Stop(context.Background())
+
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Timeout: 5 * time.Second, Transport: &http.Transport{Protocols: protocols}}
+
+	resp, err := client.Get(fmt.Sprintf("http://localhost:%d/ping", server.Port()))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 2, resp.ProtoMajor)
+
+	// HTTP/1.1 clients are still served
+	resp1, err := http.Get(fmt.Sprintf("http://localhost:%d/ping", server.Port()))
+	require.NoError(t, err)
+	defer resp1.Body.Close()
+	assert.Equal(t, 1, resp1.ProtoMajor)
+}
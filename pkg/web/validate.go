@@ -0,0 +1,264 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/locales"
+	"github.com/go-playground/locales/de"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/es"
+	"github.com/go-playground/locales/fr"
+	"github.com/go-playground/locales/ja"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	de_translations "github.com/go-playground/validator/v10/translations/de"
+	en_translations "github.com/go-playground/validator/v10/translations/en"
+	es_translations "github.com/go-playground/validator/v10/translations/es"
+	fr_translations "github.com/go-playground/validator/v10/translations/fr"
+	ja_translations "github.com/go-playground/validator/v10/translations/ja"
+)
+
+// ValidationConfig holds configuration for request validation messages
+type ValidationConfig struct {
+	// Translate picks the message language from the Accept-Language header
+	Translate bool `mapstructure:"translate"`
+	// Locales are the languages offered when translating (default all
+	// supported: en, de, es, fr, ja). English is always available.
+	Locales []string `mapstructure:"locales"`
+}
+
+// FieldError describes why a request field failed validation
+type FieldError struct {
+	// Field is the JSON name of the field, with its path for nested structs
+	Field   string `json:"field"`
+	Tag     string `json:"tag"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+type localeSupport struct {
+	locale   func() locales.Translator
+	register func(*validator.Validate, ut.Translator) error
+}
+
+var supportedLocales = map[string]localeSupport{
+	"en": {en.New, en_translations.RegisterDefaultTranslations},
+	"de": {de.New, de_translations.RegisterDefaultTranslations},
+	"es": {es.New, es_translations.RegisterDefaultTranslations},
+	"fr": {fr.New, fr_translations.RegisterDefaultTranslations},
+	"ja": {ja.New, ja_translations.RegisterDefaultTranslations},
+}
+
+// validation holds the translators of the Gin validator. Gin's validator is
+// process wide, so this state is too.
+var validation = struct {
+	mu          sync.RWMutex
+	once        sync.Once
+	validate    *validator.Validate
+	uni         *ut.UniversalTranslator
+	translators map[string]ut.Translator
+	cfg         ValidationConfig
+}{}
+
+// validatorEngine returns Gin's validator, set up to report JSON field names
+// and English messages
+func validatorEngine() *validator.Validate {
+	validation.once.Do(func() {
+		v, ok := binding.Validator.Engine().(*validator.Validate)
+		if !ok {
+			v = validator.New()
+		}
+		v.RegisterTagNameFunc(jsonFieldName)
+		validation.validate = v
+
+		english := en.New()
+		validation.uni = ut.New(english, english)
+		validation.translators = make(map[string]ut.Translator)
+		if err := addLocale("en"); err != nil {
+			panic(fmt.Sprintf("failed to register validation messages: %v", err))
+		}
+	})
+	return validation.validate
+}
+
+// ConfigureValidation registers the translated messages of cfg.Locales
+func ConfigureValidation(cfg ValidationConfig) error {
+	validatorEngine()
+	validation.mu.Lock()
+	defer validation.mu.Unlock()
+
+	validation.cfg = cfg
+	if !cfg.Translate {
+		return nil
+	}
+	names := cfg.Locales
+	if len(names) == 0 {
+		for name := range supportedLocales {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		if err := addLocale(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addLocale registers a locale; callers hold validation.mu or run in once
+func addLocale(name string) error {
+	name = strings.ToLower(name)
+	if _, ok := validation.translators[name]; ok {
+		return nil
+	}
+	support, ok := supportedLocales[name]
+	if !ok {
+		return fmt.Errorf("unsupported validation locale %q", name)
+	}
+	if name != "en" {
+		if err := validation.uni.AddTranslator(support.locale(), true); err != nil {
+			return fmt.Errorf("failed to add locale %s: %w", name, err)
+		}
+	}
+	trans, _ := validation.uni.GetTranslator(name)
+	if err := support.register(validation.validate, trans); err != nil {
+		return fmt.Errorf("failed to register %s validation messages: %w", name, err)
+	}
+	validation.translators[name] = trans
+	return nil
+}
+
+// RegisterValidation adds a custom validation tag. message is the English
+// template, where {0} is the field name and {1} the tag parameter.
+func RegisterValidation(tag string, fn validator.Func, message string) error {
+	v := validatorEngine()
+	if err := v.RegisterValidation(tag, fn); err != nil {
+		return fmt.Errorf("failed to register validation %s: %w", tag, err)
+	}
+	if message == "" {
+		return nil
+	}
+	return RegisterTranslation("en", tag, message)
+}
+
+// RegisterTranslation sets the message of a validation tag for a locale
+func RegisterTranslation(locale, tag, message string) error {
+	v := validatorEngine()
+	validation.mu.Lock()
+	defer validation.mu.Unlock()
+
+	if err := addLocale(locale); err != nil {
+		return err
+	}
+	trans := validation.translators[strings.ToLower(locale)]
+	register := func(t ut.Translator) error {
+		return t.Add(tag, message, true)
+	}
+	translate := func(t ut.Translator, fe validator.FieldError) string {
+		msg, err := t.T(fe.Tag(), fe.Field(), fe.Param())
+		if err != nil {
+			return fe.Error()
+		}
+		return msg
+	}
+	if err := v.RegisterTranslation(tag, trans, register, translate); err != nil {
+		return fmt.Errorf("failed to register %s message for %s: %w", locale, tag, err)
+	}
+	return nil
+}
+
+// BindRequest decodes and validates the request body into obj. It returns a
+// 400 *Error, with field errors for invalid values, ready for AbortWithError.
+//
+//	var req CreateWidgetRequest
+//	if err := web.BindRequest(c, &req); err != nil {
+//		web.AbortWithError(c, err)
+//		return
+//	}
+func BindRequest(c *gin.Context, obj interface{}) error {
+	validatorEngine()
+	// Gin validates structs as part of binding
+	if err := Bind(c, obj); err != nil {
+		return ValidationError(c, err)
+	}
+	return nil
+}
+
+// ValidateStruct validates obj against its binding tags
+func ValidateStruct(obj interface{}) error {
+	return binding.Validator.ValidateStruct(obj)
+}
+
+// ValidationError converts a binding or validation error into the error model,
+// with field messages in the request's language
+func ValidationError(c *gin.Context, err error) *Error {
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return BadRequest(err.Error()).Wrap(err)
+	}
+	trans, english := requestTranslator(c)
+	fields := make([]FieldError, 0, len(verrs))
+	for _, fe := range verrs {
+		msg := fe.Translate(trans)
+		// Tags without a message in the requested language fall back to English
+		if msg == fe.Error() && trans != english {
+			msg = fe.Translate(english)
+		}
+		fields = append(fields, FieldError{
+			Field:   fieldPath(fe),
+			Tag:     fe.Tag(),
+			Param:   fe.Param(),
+			Message: msg,
+		})
+	}
+	return NewError(http.StatusBadRequest, CodeValidation, "request validation failed").
+		WithDetails(fields).
+		Wrap(err)
+}
+
+// requestTranslator picks the translator for the Accept-Language header, and
+// returns the English one as fallback
+func requestTranslator(c *gin.Context) (ut.Translator, ut.Translator) {
+	validation.mu.RLock()
+	defer validation.mu.RUnlock()
+	english := validation.translators["en"]
+	if validation.cfg.Translate {
+		for _, part := range strings.Split(c.GetHeader("Accept-Language"), ",") {
+			tag, _ := parseQuality(part)
+			lang, _, _ := strings.Cut(strings.ToLower(tag), "-")
+			if t, ok := validation.translators[lang]; ok {
+				return t, english
+			}
+		}
+	}
+	return english, english
+}
+
+// fieldPath returns the JSON path of the field without the top level type,
+// e.g. "items[0].name"
+func fieldPath(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if _, rest, ok := strings.Cut(ns, "."); ok {
+		return rest
+	}
+	return fe.Field()
+}
+
+func jsonFieldName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return f.Name
+	default:
+		return name
+	}
+}
@@ -0,0 +1,114 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type orderItem struct {
+	SKU      string `json:"sku" binding:"required,sku"`
+	Quantity int    `json:"quantity" binding:"min=1"`
+}
+
+type createOrder struct {
+	Customer string      `json:"customer" binding:"required"`
+	Email    string      `json:"email" binding:"omitempty,email"`
+	Items    []orderItem `json:"items" binding:"required,dive"`
+}
+
+func newValidationEngine(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	require.NoError(t, RegisterValidation("sku", func(fl validator.FieldLevel) bool {
+		return strings.HasPrefix(fl.Field().String(), "SKU-")
+	}, "{0} must be a SKU"))
+	require.NoError(t, RegisterTranslation("de", "sku", "{0} muss eine Artikelnummer sein"))
+	require.NoError(t, ConfigureValidation(ValidationConfig{Translate: true, Locales: []string{"de", "fr"}}))
+	t.Cleanup(func() { _ = ConfigureValidation(ValidationConfig{}) })
+
+	r := gin.New()
+	r.POST("/orders", func(c *gin.Context) {
+		var req createOrder
+		if err := BindRequest(c, &req); err != nil {
+			AbortWithError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, req)
+	})
+	return r
+}
+
+func postOrder(t *testing.T, r *gin.Engine, body, lang string) (int, []FieldError) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+	req.Header.Set("Content-Type", MIMEJSON)
+	if lang != "" {
+		req.Header.Set("Accept-Language", lang)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var problem struct {
+		Code    string       `json:"code"`
+		Details []FieldError `json:"details"`
+	}
+	if w.Code == http.StatusBadRequest {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+		if problem.Details != nil {
+			assert.Equal(t, CodeValidation, problem.Code)
+		}
+	}
+	return w.Code, problem.Details
+}
+
+func TestBindRequest(t *testing.T) {
+	r := newValidationEngine(t)
+
+	code, _ := postOrder(t, r, `{"customer":"ada","items":[{"sku":"SKU-1","quantity":2}]}`, "")
+	assert.Equal(t, http.StatusCreated, code)
+
+	code, fields := postOrder(t, r, `{"customer":`, "")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Empty(t, fields, "malformed JSON has no field errors")
+
+	code, fields = postOrder(t, r, `{"email":"nope","items":[{"sku":"X","quantity":0}]}`, "")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, []FieldError{
+		{Field: "customer", Tag: "required", Message: "customer is a required field"},
+		{Field: "email", Tag: "email", Message: "email must be a valid email address"},
+		{Field: "items[0].sku", Tag: "sku", Message: "sku must be a SKU"},
+		{Field: "items[0].quantity", Tag: "min", Param: "1", Message: "quantity must be 1 or greater"},
+	}, fields)
+}
+
+func TestBindRequest_Translations(t *testing.T) {
+	r := newValidationEngine(t)
+	body := `{"items":[{"sku":"X","quantity":1}]}`
+
+	_, fields := postOrder(t, r, body, "de-DE,de;q=0.9")
+	require.Len(t, fields, 2)
+	assert.Equal(t, "customer ist ein Pflichtfeld", fields[0].Message)
+	assert.Equal(t, "sku muss eine Artikelnummer sein", fields[1].Message)
+
+	// No French message for the custom tag: English is used
+	_, fields = postOrder(t, r, body, "fr")
+	require.Len(t, fields, 2)
+	assert.Equal(t, "sku must be a SKU", fields[1].Message)
+
+	// Locales that are not configured fall back to English
+	_, fields = postOrder(t, r, body, "ja")
+	assert.Equal(t, "customer is a required field", fields[0].Message)
+}
+
+func TestConfigureValidation_UnknownLocale(t *testing.T) {
+	assert.Error(t, ConfigureValidation(ValidationConfig{Translate: true, Locales: []string{"xx"}}))
+	require.NoError(t, ConfigureValidation(ValidationConfig{}))
+}
@@ -0,0 +1,149 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ContextKeyAPIVersion is the context key holding the API version of a request
+const ContextKeyAPIVersion = "api_version"
+
+// VersioningConfig holds configuration for API versions
+type VersioningConfig struct {
+	// Versions holds the deprecation policy by version name (e.g. "v1")
+	Versions map[string]VersionPolicy `mapstructure:"versions"`
+}
+
+// VersionPolicy describes the lifecycle of an API version
+type VersionPolicy struct {
+	// Deprecated adds a Deprecation header to responses
+	Deprecated bool `mapstructure:"deprecated"`
+	// Sunset is the date the version is removed (RFC 3339 or YYYY-MM-DD),
+	// sent in the Sunset header
+	Sunset string `mapstructure:"sunset"`
+	// Link points to migration documentation
+	Link string `mapstructure:"link"`
+}
+
+// VersionedWebService is a WebService whose routes belong to an API version
+type VersionedWebService interface {
+	WebService
+	// APIVersion returns the version the routes are registered under, e.g. "v1"
+	APIVersion() string
+}
+
+// versionPattern matches vendor media types such as
+// application/vnd.grouter.v2+json
+var versionPattern = regexp.MustCompile(`\.(v\d+)\+`)
+
+// RegisterWebServiceV registers a service's routes under /<version>. Clients
+// select the version with the path, or with the Accept header on the
+// unversioned path (application/vnd.<name>.v2+json or
+// application/json; version=v2).
+func (s *Server) RegisterWebServiceV(service WebService, version string) {
+	version = normalizeVersion(version)
+	s.versions.Store(version, true)
+
+	policy := s.cfg.Versioning.Versions[version]
+	s.describe(service, "/"+version)
+	service.RegisterRoutes(s.engine.Load().Group("/"+version, VersionMiddleware(version, policy, s.logger)))
+}
+
+// VersionMiddleware tags requests with the API version and adds the
+// deprecation headers of its policy
+func VersionMiddleware(version string, policy VersionPolicy, logger *zap.Logger) gin.HandlerFunc {
+	var sunset string
+	if policy.Sunset != "" {
+		t, err := parseSunset(policy.Sunset)
+		if err != nil {
+			logger.Warn("Ignoring invalid API version sunset", zap.String("version", version), zap.Error(err))
+		} else {
+			sunset = t.UTC().Format(http.TimeFormat)
+		}
+	}
+
+	return func(c *gin.Context) {
+		c.Set(ContextKeyAPIVersion, version)
+		if policy.Deprecated {
+			c.Header("Deprecation", "true")
+		}
+		if sunset != "" {
+			c.Header("Sunset", sunset)
+		}
+		if policy.Link != "" && (policy.Deprecated || sunset != "") {
+			c.Header("Link", fmt.Sprintf("<%s>; rel=\"deprecation\"", policy.Link))
+		}
+		c.Next()
+	}
+}
+
+// APIVersion returns the API version of the request, or "" for unversioned
+// routes
+func APIVersion(c *gin.Context) string {
+	return c.GetString(ContextKeyAPIVersion)
+}
+
+// negotiateVersion rewrites an unversioned request to the registered version
+// named in its Accept header
+func (s *Server) negotiateVersion(r *http.Request) {
+	version := acceptVersion(r.Header.Get("Accept"))
+	if version == "" {
+		return
+	}
+	if _, ok := s.versions.Load(version); !ok {
+		return
+	}
+	if first, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/"); s.isVersion(first) {
+		return
+	}
+	r.URL.Path = "/" + version + r.URL.Path
+	if r.URL.RawPath != "" {
+		r.URL.RawPath = "/" + version + r.URL.RawPath
+	}
+}
+
+func (s *Server) isVersion(segment string) bool {
+	_, ok := s.versions.Load(segment)
+	return ok
+}
+
+// acceptVersion extracts the version from an Accept header
+func acceptVersion(accept string) string {
+	if accept == "" {
+		return ""
+	}
+	for _, part := range strings.Split(accept, ",") {
+		if m := versionPattern.FindStringSubmatch(part); m != nil {
+			return m[1]
+		}
+		_, params, _ := strings.Cut(part, ";")
+		for _, p := range strings.Split(params, ";") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(p), "version="); ok {
+				return normalizeVersion(strings.Trim(v, `"`))
+			}
+		}
+	}
+	return ""
+}
+
+// normalizeVersion turns "2" and "/v2" into "v2"
+func normalizeVersion(v string) string {
+	v = strings.Trim(strings.TrimSpace(v), "/")
+	if v != "" && !strings.HasPrefix(v, "v") {
+		v = "v" + v
+	}
+	return v
+}
+
+func parseSunset(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, s)
+}
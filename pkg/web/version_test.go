@@ -0,0 +1,106 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"grouter/pkg/telemetry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type versionedService struct {
+	reply string
+}
+
+func (s *versionedService) RegisterRoutes(g *gin.RouterGroup) {
+	g.GET("/items", func(c *gin.Context) {
+		c.String(http.StatusOK, s.reply+":"+APIVersion(c))
+	})
+}
+
+func newVersionedServer(t *testing.T) *Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	cfg := DefaultConfig()
+	cfg.Versioning.Versions = map[string]VersionPolicy{
+		"v1": {Deprecated: true, Sunset: "2027-01-31", Link: "https://example.com/migrate"},
+	}
+	cfg.Metrics.Registry = telemetry.NewMetricsRegistry()
+	server := newTestServer(t, cfg, zap.NewNop(), nil)
+	server.RegisterWebServiceV(&versionedService{reply: "old"}, "v1")
+	server.RegisterWebServiceV(&versionedService{reply: "new"}, "2")
+	server.Activate()
+	return server
+}
+
+func TestRegisterWebServiceV(t *testing.T) {
+	server := newVersionedServer(t)
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/items", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "old:v1", w.Body.String())
+	assert.Equal(t, "true", w.Header().Get("Deprecation"))
+	assert.Equal(t, "Sun, 31 Jan 2027 00:00:00 GMT", w.Header().Get("Sunset"))
+	assert.Equal(t, `<https://example.com/migrate>; rel="deprecation"`, w.Header().Get("Link"))
+
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v2/items", nil))
+	assert.Equal(t, "new:v2", w.Body.String())
+	assert.Empty(t, w.Header().Get("Deprecation"))
+}
+
+func TestVersionNegotiation(t *testing.T) {
+	server := newVersionedServer(t)
+
+	tests := []struct {
+		name, path, accept, want string
+		code                     int
+	}{
+		{"vendor media type", "/items", "application/vnd.grouter.v2+json", "new:v2", http.StatusOK},
+		{"version parameter", "/items", "application/json; version=1", "old:v1", http.StatusOK},
+		{"path wins", "/v1/items", "application/vnd.grouter.v2+json", "old:v1", http.StatusOK},
+		{"unknown version", "/items", "application/vnd.grouter.v9+json", "", http.StatusNotFound},
+		{"no version", "/items", "application/json", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("Accept", tt.accept)
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, req)
+			assert.Equal(t, tt.code, w.Code)
+			if tt.want != "" {
+				assert.Equal(t, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestVersionMetricsLabel(t *testing.T) {
+	server := newVersionedServer(t)
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v2/items", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	expected := `
+# HELP http_requests_total Total number of HTTP requests
+# TYPE http_requests_total counter
+http_requests_total{method="GET",path="/v2/items",status="200",version="v2"} 1
+`
+	err := testutil.GatherAndCompare(server.cfg.Metrics.Registry.Gatherer(), strings.NewReader(expected), "http_requests_total")
+	assert.NoError(t, err)
+}
+
+func TestAcceptVersion(t *testing.T) {
+	assert.Equal(t, "v3", acceptVersion("text/html, application/vnd.acme.v3+json"))
+	assert.Equal(t, "v2", acceptVersion(`application/json; version="v2"`))
+	assert.Equal(t, "", acceptVersion("application/json"))
+	assert.Equal(t, "v1", normalizeVersion("/1/"))
+}
@@ -0,0 +1,46 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const signaturePrefix = "sha256="
+
+// Sign returns the X-GRouter-Signature value for a delivery: the hex
+// HMAC-SHA256 of "<timestamp>.<body>" keyed with the target secret.
+// Including the timestamp lets receivers reject replayed deliveries.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a delivery signature and rejects timestamps older than
+// tolerance (0 disables the age check). Receivers written in Go can use it
+// directly with the X-GRouter-Timestamp and X-GRouter-Signature headers.
+func Verify(secret, timestamp, signature string, body []byte, tolerance time.Duration) error {
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return fmt.Errorf("unsupported signature format")
+	}
+	if !hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature)) {
+		return fmt.Errorf("signature mismatch")
+	}
+	if tolerance > 0 {
+		ts, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid timestamp: %w", err)
+		}
+		if age := time.Since(time.Unix(ts, 0)); age > tolerance || age < -tolerance {
+			return fmt.Errorf("timestamp outside tolerance")
+		}
+	}
+	return nil
+}
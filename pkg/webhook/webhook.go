@@ -0,0 +1,313 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+	"grouter/pkg/telemetry"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// Headers set on every webhook delivery.
+const (
+	HeaderEvent     = "X-GRouter-Event"
+	HeaderDelivery  = "X-GRouter-Delivery"
+	HeaderSubject   = "X-GRouter-Subject"
+	HeaderTimestamp = "X-GRouter-Timestamp"
+	HeaderSignature = "X-GRouter-Signature"
+)
+
+// forwarderMetrics are the delivery metrics of a Forwarder.
+type forwarderMetrics struct {
+	deliveries *prometheus.CounterVec
+	attempts   *prometheus.CounterVec
+	duration   *prometheus.HistogramVec
+}
+
+func newForwarderMetrics(reg *telemetry.MetricsRegistry) *forwarderMetrics {
+	return &forwarderMetrics{
+		deliveries: reg.CounterVec(prometheus.CounterOpts{
+			Name: "webhook_deliveries_total",
+			Help: "Total number of webhook deliveries by final result (success, failed, dropped)",
+		}, []string{"target", "result"}),
+		attempts: reg.CounterVec(prometheus.CounterOpts{
+			Name: "webhook_delivery_attempts_total",
+			Help: "Total number of webhook HTTP attempts, including retries",
+		}, []string{"target"}),
+		duration: reg.HistogramVec(prometheus.HistogramOpts{
+			Name:    "webhook_delivery_duration_seconds",
+			Help:    "Duration of webhook deliveries including retries in seconds",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"target"}),
+	}
+}
+
+// errPermanent marks responses that must not be retried.
+var errPermanent = errors.New("permanent delivery failure")
+
+// Config holds webhook forwarder settings.
+type Config struct {
+	// Workers is the number of concurrent deliveries.
+	Workers int `mapstructure:"workers"`
+	// QueueSize is the number of envelopes buffered for delivery. Envelopes
+	// received while the queue is full are dropped.
+	QueueSize int `mapstructure:"queue_size"`
+	// Targets are the webhook endpoints.
+	Targets []Target `mapstructure:"targets"`
+	// Registry receives the delivery metrics. Nil uses the global registry.
+	Registry *telemetry.MetricsRegistry `mapstructure:"-"`
+}
+
+// Target forwards the envelopes of a subject to a webhook URL.
+type Target struct {
+	// Name identifies the target in logs and metrics.
+	Name string `mapstructure:"name"`
+	// Subject is the NATS subject to forward (wildcards allowed).
+	Subject string `mapstructure:"subject"`
+	// QueueGroup load-balances deliveries between instances so each envelope
+	// is delivered once. Defaults to webhook.<name>.
+	QueueGroup string `mapstructure:"queue_group"`
+	// URL receives an HTTP POST with the JSON envelope as body.
+	URL string `mapstructure:"url"`
+	// Secret signs the body with HMAC-SHA256. Empty disables signing.
+	Secret string `mapstructure:"secret"`
+	// Headers are added to every request.
+	Headers map[string]string `mapstructure:"headers"`
+	// Timeout is the per-attempt HTTP timeout.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// MaxRetries is the number of retries after the first attempt.
+	MaxRetries int `mapstructure:"max_retries"`
+	// RetryBackoff is the initial backoff, doubled after each retry.
+	RetryBackoff time.Duration `mapstructure:"retry_backoff"`
+}
+
+// DefaultConfig returns the default forwarder configuration.
+func DefaultConfig() Config {
+	return Config{
+		Workers:   4,
+		QueueSize: 1000,
+	}
+}
+
+type delivery struct {
+	target  *Target
+	subject string
+	env     *messaging.MessageEnvelope
+}
+
+// Forwarder subscribes to the configured subjects and POSTs each envelope to
+// the matching webhook URL, retrying transient failures with backoff.
+type Forwarder struct {
+	cfg        Config
+	subscriber messaging.Subscriber
+	client     *http.Client
+	logger     *zap.Logger
+	metrics    *forwarderMetrics
+
+	queue  chan delivery
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New creates a Forwarder, validating the targets and applying defaults.
+func New(subscriber messaging.Subscriber, cfg Config, logger *zap.Logger) (*Forwarder, error) {
+	defaults := DefaultConfig()
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaults.Workers
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaults.QueueSize
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	targets := make([]Target, len(cfg.Targets))
+	for i, t := range cfg.Targets {
+		if t.Name == "" || t.Subject == "" || t.URL == "" {
+			return nil, fmt.Errorf("webhook target %d: name, subject and url are required", i)
+		}
+		if t.QueueGroup == "" {
+			t.QueueGroup = "webhook." + t.Name
+		}
+		if t.Timeout <= 0 {
+			t.Timeout = 10 * time.Second
+		}
+		if t.RetryBackoff <= 0 {
+			t.RetryBackoff = time.Second
+		}
+		if t.MaxRetries < 0 {
+			t.MaxRetries = 0
+		}
+		targets[i] = t
+	}
+	cfg.Targets = targets
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Forwarder{
+		cfg:        cfg,
+		subscriber: subscriber,
+		client:     &http.Client{},
+		logger:     logger,
+		metrics:    newForwarderMetrics(cfg.Registry),
+		queue:      make(chan delivery, cfg.QueueSize),
+		ctx:        ctx,
+		cancel:     cancel,
+	}, nil
+}
+
+// Start launches the delivery workers and subscribes to every target subject.
+func (f *Forwarder) Start() error {
+	if f.subscriber == nil {
+		return fmt.Errorf("webhook forwarder requires a subscriber")
+	}
+
+	for i := 0; i < f.cfg.Workers; i++ {
+		f.wg.Add(1)
+		go f.worker()
+	}
+	for i := range f.cfg.Targets {
+		target := &f.cfg.Targets[i]
+		handler := func(ctx context.Context, subject string, env *messaging.MessageEnvelope) error {
+			f.Enqueue(target, subject, env)
+			return nil
+		}
+		if err := f.subscriber.Subscribe(target.Subject, handler, &messaging.SubscribeOptions{QueueGroup: target.QueueGroup}); err != nil {
+			return fmt.Errorf("failed to subscribe webhook %s to %s: %w", target.Name, target.Subject, err)
+		}
+		f.logger.Info("Webhook target registered",
+			zap.String("target", target.Name),
+			zap.String("subject", target.Subject),
+			zap.String("url", target.URL),
+		)
+	}
+	return nil
+}
+
+// Enqueue schedules an envelope for delivery without blocking the NATS
+// dispatcher. It reports false when the queue is full and the envelope was dropped.
+func (f *Forwarder) Enqueue(target *Target, subject string, env *messaging.MessageEnvelope) bool {
+	select {
+	case f.queue <- delivery{target: target, subject: subject, env: env}:
+		return true
+	default:
+		f.metrics.deliveries.WithLabelValues(target.Name, "dropped").Inc()
+		f.logger.Error("Webhook queue full, dropping envelope",
+			zap.String("target", target.Name),
+			zap.String("id", env.ID),
+		)
+		return false
+	}
+}
+
+// Stop stops accepting deliveries, aborts pending retries and waits for the workers.
+func (f *Forwarder) Stop() {
+	f.cancel()
+	f.wg.Wait()
+}
+
+func (f *Forwarder) worker() {
+	defer f.wg.Done()
+	for {
+		select {
+		case <-f.ctx.Done():
+			return
+		case d := <-f.queue:
+			start := time.Now()
+			err := f.deliver(f.ctx, d)
+			f.metrics.duration.WithLabelValues(d.target.Name).Observe(time.Since(start).Seconds())
+			if err != nil {
+				f.metrics.deliveries.WithLabelValues(d.target.Name, "failed").Inc()
+				f.logger.Error("Webhook delivery failed",
+					zap.String("target", d.target.Name),
+					zap.String("id", d.env.ID),
+					zap.Error(err),
+				)
+				continue
+			}
+			f.metrics.deliveries.WithLabelValues(d.target.Name, "success").Inc()
+		}
+	}
+}
+
+// deliver POSTs the envelope, retrying network errors, 429 and 5xx responses.
+func (f *Forwarder) deliver(ctx context.Context, d delivery) error {
+	body, err := json.Marshal(d.env)
+	if err != nil {
+		return fmt.Errorf("failed to marshal envelope: %w", err)
+	}
+
+	backoff := d.target.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		f.metrics.attempts.WithLabelValues(d.target.Name).Inc()
+		err = f.post(ctx, d, body)
+		if err == nil || errors.Is(err, errPermanent) || attempt >= d.target.MaxRetries {
+			return err
+		}
+
+		f.logger.Warn("Webhook delivery attempt failed, retrying",
+			zap.String("target", d.target.Name),
+			zap.String("id", d.env.ID),
+			zap.Int("attempt", attempt+1),
+			zap.Duration("backoff", backoff),
+			zap.Error(err),
+		)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (f *Forwarder) post(ctx context.Context, d delivery, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, d.target.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.target.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: failed to create request: %v", errPermanent, err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, d.env.Type)
+	req.Header.Set(HeaderDelivery, d.env.ID)
+	req.Header.Set(HeaderSubject, d.subject)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	if d.target.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(d.target.Secret, timestamp, body))
+	}
+	for k, v := range d.target.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	default:
+		return fmt.Errorf("%w: webhook responded with status %d", errPermanent, resp.StatusCode)
+	}
+}
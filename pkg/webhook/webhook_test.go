@@ -0,0 +1,143 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+	"grouter/pkg/messaging/nats/mocks"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func startForwarder(t *testing.T, target Target) (*Forwarder, *mocks.Subscriber) {
+	t.Helper()
+	sub := mocks.NewSubscriber()
+	f, err := New(sub, Config{Targets: []Target{target}}, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, f.Start())
+	t.Cleanup(f.Stop)
+	return f, sub
+}
+
+func TestForwarder_DeliversSignedEnvelope(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	defer srv.Close()
+
+	_, sub := startForwarder(t, Target{
+		Name:    "crm",
+		Subject: "gRouter.events.>",
+		URL:     srv.URL,
+		Secret:  "s3cret",
+		Headers: map[string]string{"Authorization": "Bearer abc"},
+	})
+	events, ok := sub.Subscription("gRouter.events.>")
+	require.True(t, ok)
+	assert.Equal(t, "webhook.crm", events.QueueGroup())
+
+	env := &messaging.MessageEnvelope{ID: "evt-1", Type: "user.created", Data: json.RawMessage(`{"id":1}`)}
+	require.NoError(t, sub.Deliver(context.Background(), "gRouter.events.user", env))
+
+	select {
+	case r := <-received:
+		body := <-bodies
+		assert.Equal(t, "user.created", r.Header.Get(HeaderEvent))
+		assert.Equal(t, "evt-1", r.Header.Get(HeaderDelivery))
+		assert.Equal(t, "gRouter.events.user", r.Header.Get(HeaderSubject))
+		assert.Equal(t, "Bearer abc", r.Header.Get("Authorization"))
+		assert.NoError(t, Verify("s3cret", r.Header.Get(HeaderTimestamp), r.Header.Get(HeaderSignature), body, time.Minute))
+
+		var got messaging.MessageEnvelope
+		require.NoError(t, json.Unmarshal(body, &got))
+		assert.Equal(t, "evt-1", got.ID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not called")
+	}
+}
+
+func TestForwarder_Retries(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		attempts int32
+		result   string
+	}{
+		{"server error is retried", http.StatusServiceUnavailable, 3, "failed"},
+		{"client error is permanent", http.StatusBadRequest, 1, "failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			name := "retry-" + http.StatusText(tt.status)
+			f, _ := startForwarder(t, Target{
+				Name:         name,
+				Subject:      "events",
+				URL:          srv.URL,
+				MaxRetries:   2,
+				RetryBackoff: time.Millisecond,
+			})
+			f.Enqueue(&f.cfg.Targets[0], "events", &messaging.MessageEnvelope{ID: "1"})
+
+			require.Eventually(t, func() bool {
+				return testutil.ToFloat64(f.metrics.deliveries.WithLabelValues(name, tt.result)) == 1
+			}, 5*time.Second, 10*time.Millisecond)
+			assert.Equal(t, tt.attempts, calls.Load())
+		})
+	}
+}
+
+func TestForwarder_RecoversAfterRetry(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	f, _ := startForwarder(t, Target{Name: "flaky", Subject: "events", URL: srv.URL, MaxRetries: 3, RetryBackoff: time.Millisecond})
+	f.Enqueue(&f.cfg.Targets[0], "events", &messaging.MessageEnvelope{ID: "1"})
+
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(f.metrics.deliveries.WithLabelValues("flaky", "success")) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestNew_InvalidTarget(t *testing.T) {
+	_, err := New(mocks.NewSubscriber(), Config{Targets: []Target{{Name: "x", Subject: "events"}}}, zap.NewNop())
+	assert.Error(t, err)
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"id":"1"}`)
+	ts := "1700000000"
+	sig := Sign("secret", ts, body)
+
+	assert.NoError(t, Verify("secret", ts, sig, body, 0))
+	assert.Error(t, Verify("other", ts, sig, body, 0))
+	assert.Error(t, Verify("secret", ts, sig, []byte(`{}`), 0))
+	assert.Error(t, Verify("secret", ts, sig, body, time.Minute), "stale timestamp must be rejected")
+}
@@ -0,0 +1,69 @@
+package workflow
+
+import (
+	"errors"
+	"net/http"
+
+	"grouter/pkg/web"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminService exposes workflow status over HTTP. It implements both the
+// manager Service and web.WebService interfaces, so registering it with the
+// ServiceManager mounts its routes on the web server.
+type AdminService struct {
+	orchestrator *Orchestrator
+}
+
+// NewAdminService creates a new AdminService for the given orchestrator.
+func NewAdminService(o *Orchestrator) *AdminService {
+	return &AdminService{orchestrator: o}
+}
+
+// Name returns the service name.
+func (s *AdminService) Name() string {
+	return "workflow"
+}
+
+// RegisterRoutes registers the admin endpoints.
+func (s *AdminService) RegisterRoutes(router *gin.RouterGroup) {
+	g := router.Group("/admin/workflows")
+	g.GET("", s.ListHandler)
+	g.GET("/:id", s.GetHandler)
+}
+
+// ListHandler returns all workflow instances, optionally filtered by ?status=
+func (s *AdminService) ListHandler(c *gin.Context) {
+	instances, err := s.orchestrator.List(c.Request.Context())
+	if err != nil {
+		web.AbortWithError(c, web.Internal(err))
+		return
+	}
+
+	if status := c.Query("status"); status != "" {
+		filtered := make([]*Instance, 0, len(instances))
+		for _, inst := range instances {
+			if string(inst.Status) == status {
+				filtered = append(filtered, inst)
+			}
+		}
+		instances = filtered
+	}
+
+	c.JSON(http.StatusOK, instances)
+}
+
+// GetHandler returns a single workflow instance
+func (s *AdminService) GetHandler(c *gin.Context) {
+	inst, err := s.orchestrator.Get(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, ErrNotFound) {
+		web.AbortWithError(c, web.NotFound(err.Error()))
+		return
+	}
+	if err != nil {
+		web.AbortWithError(c, web.Internal(err))
+		return
+	}
+	c.JSON(http.StatusOK, inst)
+}
@@ -0,0 +1,251 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	messaging "grouter/pkg/messaging/nats"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Orchestrator executes registered workflows and persists their progress
+// after every step so that interrupted instances can be resumed.
+type Orchestrator struct {
+	store  Store
+	logger *zap.Logger
+
+	mu          sync.RWMutex
+	definitions map[string]Definition
+}
+
+// NewOrchestrator creates a new Orchestrator using the given store.
+func NewOrchestrator(store Store, logger *zap.Logger) *Orchestrator {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &Orchestrator{
+		store:       store,
+		logger:      logger,
+		definitions: make(map[string]Definition),
+	}
+}
+
+// Register adds a workflow definition. Registering a name twice replaces
+// the previous definition.
+func (o *Orchestrator) Register(def Definition) error {
+	if err := def.Validate(); err != nil {
+		return err
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.definitions[def.Name] = def
+	return nil
+}
+
+// Store returns the store used to persist instances.
+func (o *Orchestrator) Store() Store {
+	return o.store
+}
+
+func (o *Orchestrator) definition(name string) (Definition, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	def, ok := o.definitions[name]
+	return def, ok
+}
+
+// Start creates a new instance of the named workflow and runs it to
+// completion. The returned instance reflects the final state; a non-nil
+// error means the workflow did not complete successfully.
+func (o *Orchestrator) Start(ctx context.Context, name string, data map[string]interface{}) (*Instance, error) {
+	def, ok := o.definition(name)
+	if !ok {
+		return nil, fmt.Errorf("workflow %q is not registered", name)
+	}
+
+	now := time.Now().UTC()
+	inst := &Instance{
+		ID:        uuid.New().String(),
+		Workflow:  name,
+		Status:    StatusRunning,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	for k, v := range data {
+		if err := inst.Set(k, v); err != nil {
+			return nil, err
+		}
+	}
+	if err := o.save(ctx, inst); err != nil {
+		return nil, err
+	}
+
+	o.logger.Info("Workflow started",
+		zap.String("workflow", name),
+		zap.String("id", inst.ID),
+	)
+
+	return inst, o.run(ctx, def, inst)
+}
+
+// Resume continues every non-terminal instance found in the store. It is
+// meant to be called once on startup, after all definitions are registered.
+func (o *Orchestrator) Resume(ctx context.Context) error {
+	instances, err := o.store.List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list workflow instances: %w", err)
+	}
+
+	for _, inst := range instances {
+		if inst.Status.Terminal() {
+			continue
+		}
+		def, ok := o.definition(inst.Workflow)
+		if !ok {
+			o.logger.Warn("Cannot resume workflow with unknown definition",
+				zap.String("workflow", inst.Workflow),
+				zap.String("id", inst.ID),
+			)
+			continue
+		}
+
+		o.logger.Info("Resuming workflow",
+			zap.String("workflow", inst.Workflow),
+			zap.String("id", inst.ID),
+			zap.String("status", string(inst.Status)),
+			zap.Int("step", inst.Step),
+		)
+		if err := o.run(ctx, def, inst); err != nil {
+			o.logger.Warn("Resumed workflow did not complete",
+				zap.String("workflow", inst.Workflow),
+				zap.String("id", inst.ID),
+				zap.Error(err),
+			)
+		}
+	}
+	return nil
+}
+
+// Get returns the current state of an instance.
+func (o *Orchestrator) Get(ctx context.Context, id string) (*Instance, error) {
+	return o.store.Load(ctx, id)
+}
+
+// List returns all known instances.
+func (o *Orchestrator) List(ctx context.Context) ([]*Instance, error) {
+	return o.store.List(ctx)
+}
+
+func (o *Orchestrator) run(ctx context.Context, def Definition, inst *Instance) error {
+	if inst.Status == StatusRunning {
+		for inst.Step < len(def.Steps) {
+			step := def.Steps[inst.Step]
+			if err := step.Action(ctx, inst); err != nil {
+				o.logger.Error("Workflow step failed",
+					zap.String("workflow", def.Name),
+					zap.String("id", inst.ID),
+					zap.String("step", step.Name),
+					zap.Error(err),
+				)
+				inst.Error = fmt.Sprintf("step %q failed: %v", step.Name, err)
+				inst.Status = StatusCompensating
+				// The failed step is assumed to have left no side effects,
+				// so compensation starts with the previous one.
+				inst.Step--
+				if err := o.save(ctx, inst); err != nil {
+					return err
+				}
+				break
+			}
+			inst.Step++
+			if err := o.save(ctx, inst); err != nil {
+				return err
+			}
+		}
+
+		if inst.Status == StatusRunning {
+			inst.Status = StatusCompleted
+			o.logger.Info("Workflow completed",
+				zap.String("workflow", def.Name),
+				zap.String("id", inst.ID),
+			)
+			return o.save(ctx, inst)
+		}
+	}
+
+	if inst.Status == StatusCompensating {
+		for inst.Step >= 0 {
+			step := def.Steps[inst.Step]
+			if step.Compensate != nil {
+				if err := step.Compensate(ctx, inst); err != nil {
+					o.logger.Error("Workflow compensation failed",
+						zap.String("workflow", def.Name),
+						zap.String("id", inst.ID),
+						zap.String("step", step.Name),
+						zap.Error(err),
+					)
+					inst.Error = fmt.Sprintf("%s; compensation of step %q failed: %v", inst.Error, step.Name, err)
+					inst.Status = StatusFailed
+					if serr := o.save(ctx, inst); serr != nil {
+						return serr
+					}
+					return fmt.Errorf("workflow %s failed: %s", inst.ID, inst.Error)
+				}
+			}
+			inst.Step--
+			if err := o.save(ctx, inst); err != nil {
+				return err
+			}
+		}
+
+		inst.Step = 0
+		inst.Status = StatusCompensated
+		if err := o.save(ctx, inst); err != nil {
+			return err
+		}
+		o.logger.Info("Workflow compensated",
+			zap.String("workflow", def.Name),
+			zap.String("id", inst.ID),
+		)
+		return fmt.Errorf("workflow %s compensated: %s", inst.ID, inst.Error)
+	}
+
+	return nil
+}
+
+func (o *Orchestrator) save(ctx context.Context, inst *Instance) error {
+	inst.UpdatedAt = time.Now().UTC()
+	if err := o.store.Save(ctx, inst); err != nil {
+		return fmt.Errorf("failed to persist workflow %s: %w", inst.ID, err)
+	}
+	return nil
+}
+
+// RequestStep returns a StepFunc that sends the instance data as a NATS
+// request and stores the response payload under the given message type.
+// An "error" reply (see Publisher.PublishError) fails the step.
+func RequestStep(pub messaging.Publisher, subject, msgType string, timeout time.Duration) StepFunc {
+	return func(ctx context.Context, inst *Instance) error {
+		resp, err := pub.Request(ctx, subject, msgType, inst.Data, timeout)
+		if err != nil {
+			return err
+		}
+		if resp.Type == "error" {
+			var body struct {
+				Error string `json:"error"`
+			}
+			_ = json.Unmarshal(resp.Data, &body)
+			return fmt.Errorf("%s replied with error: %s", subject, body.Error)
+		}
+		if inst.Data == nil {
+			inst.Data = make(map[string]json.RawMessage)
+		}
+		inst.Data[msgType] = resp.Data
+		return nil
+	}
+}
@@ -0,0 +1,237 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"gorm.io/gorm"
+)
+
+// MemoryStore keeps workflow instances in memory. It does not survive a
+// restart and is intended for tests and local development.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	instances map[string][]byte
+}
+
+// NewMemoryStore creates a new MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		instances: make(map[string][]byte),
+	}
+}
+
+// Save stores a copy of the instance.
+func (s *MemoryStore) Save(ctx context.Context, inst *Instance) error {
+	b, err := json.Marshal(inst)
+	if err != nil {
+		return fmt.Errorf("failed to marshal workflow instance: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.instances[inst.ID] = b
+	return nil
+}
+
+// Load returns a copy of the instance with the given ID.
+func (s *MemoryStore) Load(ctx context.Context, id string) (*Instance, error) {
+	s.mu.RLock()
+	b, ok := s.instances[id]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return decodeInstance(b)
+}
+
+// List returns copies of all stored instances.
+func (s *MemoryStore) List(ctx context.Context) ([]*Instance, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]*Instance, 0, len(s.instances))
+	for _, b := range s.instances {
+		inst, err := decodeInstance(b)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, inst)
+	}
+	sortInstances(out)
+	return out, nil
+}
+
+// KVStore persists workflow instances in a JetStream key-value bucket.
+type KVStore struct {
+	kv nats.KeyValue
+}
+
+// NewKVStore creates a KVStore backed by the given bucket, creating the
+// bucket if it does not exist yet.
+func NewKVStore(js nats.JetStreamContext, bucket string) (*KVStore, error) {
+	if bucket == "" {
+		bucket = "workflows"
+	}
+	kv, err := js.KeyValue(bucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		kv, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:      bucket,
+			Description: "gRouter workflow state",
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open key-value bucket %q: %w", bucket, err)
+	}
+	return &KVStore{kv: kv}, nil
+}
+
+// Save writes the instance to the bucket.
+func (s *KVStore) Save(ctx context.Context, inst *Instance) error {
+	b, err := json.Marshal(inst)
+	if err != nil {
+		return fmt.Errorf("failed to marshal workflow instance: %w", err)
+	}
+	if _, err := s.kv.Put(inst.ID, b); err != nil {
+		return fmt.Errorf("failed to store workflow instance: %w", err)
+	}
+	return nil
+}
+
+// Load reads the instance with the given ID from the bucket.
+func (s *KVStore) Load(ctx context.Context, id string) (*Instance, error) {
+	entry, err := s.kv.Get(id)
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load workflow instance: %w", err)
+	}
+	return decodeInstance(entry.Value())
+}
+
+// List returns all instances in the bucket.
+func (s *KVStore) List(ctx context.Context) ([]*Instance, error) {
+	keys, err := s.kv.Keys(nats.Context(ctx))
+	if errors.Is(err, nats.ErrNoKeysFound) {
+		return []*Instance{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workflow instances: %w", err)
+	}
+	out := make([]*Instance, 0, len(keys))
+	for _, key := range keys {
+		inst, err := s.Load(ctx, key)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, inst)
+	}
+	sortInstances(out)
+	return out, nil
+}
+
+// instanceRecord is the database representation of an Instance.
+type instanceRecord struct {
+	ID        string `gorm:"primaryKey;size:64"`
+	Workflow  string `gorm:"index;size:128"`
+	Status    string `gorm:"index;size:32"`
+	State     []byte
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (instanceRecord) TableName() string {
+	return "workflow_instances"
+}
+
+// DBStore persists workflow instances in a SQL database via GORM.
+type DBStore struct {
+	db *gorm.DB
+}
+
+// NewDBStore creates a DBStore and migrates the workflow_instances table.
+func NewDBStore(db *gorm.DB) (*DBStore, error) {
+	if err := db.AutoMigrate(&instanceRecord{}); err != nil {
+		return nil, fmt.Errorf("failed to migrate workflow table: %w", err)
+	}
+	return &DBStore{db: db}, nil
+}
+
+// Save upserts the instance.
+func (s *DBStore) Save(ctx context.Context, inst *Instance) error {
+	b, err := json.Marshal(inst)
+	if err != nil {
+		return fmt.Errorf("failed to marshal workflow instance: %w", err)
+	}
+	rec := instanceRecord{
+		ID:        inst.ID,
+		Workflow:  inst.Workflow,
+		Status:    string(inst.Status),
+		State:     b,
+		CreatedAt: inst.CreatedAt,
+		UpdatedAt: inst.UpdatedAt,
+	}
+	if err := s.db.WithContext(ctx).Save(&rec).Error; err != nil {
+		return fmt.Errorf("failed to store workflow instance: %w", err)
+	}
+	return nil
+}
+
+// Load reads the instance with the given ID.
+func (s *DBStore) Load(ctx context.Context, id string) (*Instance, error) {
+	var rec instanceRecord
+	err := s.db.WithContext(ctx).First(&rec, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load workflow instance: %w", err)
+	}
+	return decodeInstance(rec.State)
+}
+
+// List returns all stored instances ordered by creation time.
+func (s *DBStore) List(ctx context.Context) ([]*Instance, error) {
+	var recs []instanceRecord
+	if err := s.db.WithContext(ctx).Order("created_at").Find(&recs).Error; err != nil {
+		return nil, fmt.Errorf("failed to list workflow instances: %w", err)
+	}
+	out := make([]*Instance, 0, len(recs))
+	for _, rec := range recs {
+		inst, err := decodeInstance(rec.State)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, inst)
+	}
+	return out, nil
+}
+
+func decodeInstance(b []byte) (*Instance, error) {
+	var inst Instance
+	if err := json.Unmarshal(b, &inst); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal workflow instance: %w", err)
+	}
+	return &inst, nil
+}
+
+func sortInstances(list []*Instance) {
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+}
+
+// Ensure stores implement the Store interface.
+var (
+	_ Store = (*MemoryStore)(nil)
+	_ Store = (*KVStore)(nil)
+	_ Store = (*DBStore)(nil)
+)
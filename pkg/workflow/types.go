@@ -0,0 +1,121 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotFound is returned by a Store when a workflow instance does not exist.
+var ErrNotFound = errors.New("workflow instance not found")
+
+// Status describes where a workflow instance is in its lifecycle.
+type Status string
+
+const (
+	// StatusRunning means forward steps are being executed.
+	StatusRunning Status = "running"
+	// StatusCompleted means every step finished successfully.
+	StatusCompleted Status = "completed"
+	// StatusCompensating means a step failed and compensations are being executed.
+	StatusCompensating Status = "compensating"
+	// StatusCompensated means a step failed and all compensations succeeded.
+	StatusCompensated Status = "compensated"
+	// StatusFailed means a compensation failed and manual intervention is required.
+	StatusFailed Status = "failed"
+)
+
+// Terminal reports whether no further progress will be made on the instance.
+func (s Status) Terminal() bool {
+	return s == StatusCompleted || s == StatusCompensated || s == StatusFailed
+}
+
+// StepFunc executes (or compensates) a single step of a workflow.
+// Steps may be re-executed after a crash, so they should be idempotent.
+type StepFunc func(ctx context.Context, inst *Instance) error
+
+// Step is a single unit of work in a workflow together with its compensation.
+type Step struct {
+	// Name identifies the step in logs and in the instance state.
+	Name string
+	// Action performs the step.
+	Action StepFunc
+	// Compensate undoes the step. It is optional for steps with no side effects.
+	Compensate StepFunc
+}
+
+// Definition describes an ordered list of steps that make up a workflow.
+type Definition struct {
+	Name  string
+	Steps []Step
+}
+
+// Validate checks the definition for obvious mistakes.
+func (d Definition) Validate() error {
+	if d.Name == "" {
+		return fmt.Errorf("workflow name is required")
+	}
+	if len(d.Steps) == 0 {
+		return fmt.Errorf("workflow %q has no steps", d.Name)
+	}
+	for i, step := range d.Steps {
+		if step.Name == "" {
+			return fmt.Errorf("workflow %q: step %d has no name", d.Name, i)
+		}
+		if step.Action == nil {
+			return fmt.Errorf("workflow %q: step %q has no action", d.Name, step.Name)
+		}
+	}
+	return nil
+}
+
+// Instance is the persisted state of a single workflow execution.
+type Instance struct {
+	ID       string `json:"id"`
+	Workflow string `json:"workflow"`
+	Status   Status `json:"status"`
+	// Step is the index of the step being executed or, while compensating,
+	// the index of the next step to compensate.
+	Step int `json:"step"`
+	// Data is shared between steps. Values are stored as JSON so that the
+	// state survives a round trip through any Store.
+	Data      map[string]json.RawMessage `json:"data,omitempty"`
+	Error     string                     `json:"error,omitempty"`
+	CreatedAt time.Time                  `json:"created_at"`
+	UpdatedAt time.Time                  `json:"updated_at"`
+}
+
+// Set stores a value in the instance data under the given key.
+func (i *Instance) Set(key string, value interface{}) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal workflow data %q: %w", key, err)
+	}
+	if i.Data == nil {
+		i.Data = make(map[string]json.RawMessage)
+	}
+	i.Data[key] = b
+	return nil
+}
+
+// Get decodes the value stored under key into out.
+// It returns false if the key is not present.
+func (i *Instance) Get(key string, out interface{}) (bool, error) {
+	raw, ok := i.Data[key]
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return true, fmt.Errorf("failed to unmarshal workflow data %q: %w", key, err)
+	}
+	return true, nil
+}
+
+// Store persists workflow instances so that they can be resumed after a crash.
+type Store interface {
+	Save(ctx context.Context, inst *Instance) error
+	Load(ctx context.Context, id string) (*Instance, error)
+	List(ctx context.Context) ([]*Instance, error)
+}
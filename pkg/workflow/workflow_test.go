@@ -0,0 +1,264 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"grouter/pkg/config"
+	"grouter/pkg/database"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func recordingStep(name string, calls *[]string, fail bool) Step {
+	return Step{
+		Name: name,
+		Action: func(ctx context.Context, inst *Instance) error {
+			*calls = append(*calls, "do:"+name)
+			if fail {
+				return errors.New("boom")
+			}
+			return inst.Set(name, true)
+		},
+		Compensate: func(ctx context.Context, inst *Instance) error {
+			*calls = append(*calls, "undo:"+name)
+			return nil
+		},
+	}
+}
+
+func TestOrchestrator_Completes(t *testing.T) {
+	var calls []string
+	o := NewOrchestrator(NewMemoryStore(), zap.NewNop())
+	require.NoError(t, o.Register(Definition{
+		Name: "order",
+		Steps: []Step{
+			recordingStep("reserve", &calls, false),
+			recordingStep("charge", &calls, false),
+		},
+	}))
+
+	inst, err := o.Start(context.Background(), "order", map[string]interface{}{"order_id": "42"})
+	assert.NoError(t, err)
+	assert.Equal(t, StatusCompleted, inst.Status)
+	assert.Equal(t, []string{"do:reserve", "do:charge"}, calls)
+
+	stored, err := o.Get(context.Background(), inst.ID)
+	require.NoError(t, err)
+	var orderID string
+	ok, err := stored.Get("order_id", &orderID)
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	assert.Equal(t, "42", orderID)
+}
+
+func TestOrchestrator_Compensates(t *testing.T) {
+	var calls []string
+	o := NewOrchestrator(NewMemoryStore(), zap.NewNop())
+	require.NoError(t, o.Register(Definition{
+		Name: "order",
+		Steps: []Step{
+			recordingStep("reserve", &calls, false),
+			recordingStep("charge", &calls, false),
+			recordingStep("ship", &calls, true),
+		},
+	}))
+
+	inst, err := o.Start(context.Background(), "order", nil)
+	assert.Error(t, err)
+	assert.Equal(t, StatusCompensated, inst.Status)
+	assert.Contains(t, inst.Error, "ship")
+	assert.Equal(t, []string{"do:reserve", "do:charge", "do:ship", "undo:charge", "undo:reserve"}, calls)
+}
+
+func TestOrchestrator_CompensationFailure(t *testing.T) {
+	o := NewOrchestrator(NewMemoryStore(), zap.NewNop())
+	require.NoError(t, o.Register(Definition{
+		Name: "order",
+		Steps: []Step{
+			{
+				Name:       "reserve",
+				Action:     func(ctx context.Context, inst *Instance) error { return nil },
+				Compensate: func(ctx context.Context, inst *Instance) error { return errors.New("stuck") },
+			},
+			{
+				Name:   "charge",
+				Action: func(ctx context.Context, inst *Instance) error { return errors.New("declined") },
+			},
+		},
+	}))
+
+	inst, err := o.Start(context.Background(), "order", nil)
+	assert.Error(t, err)
+	assert.Equal(t, StatusFailed, inst.Status)
+	assert.Contains(t, inst.Error, "stuck")
+}
+
+func TestOrchestrator_Resume(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	// Simulate a crash after the first step completed.
+	require.NoError(t, store.Save(ctx, &Instance{
+		ID:       "wf-1",
+		Workflow: "order",
+		Status:   StatusRunning,
+		Step:     1,
+	}))
+	require.NoError(t, store.Save(ctx, &Instance{
+		ID:       "wf-2",
+		Workflow: "order",
+		Status:   StatusCompleted,
+		Step:     2,
+	}))
+
+	var calls []string
+	o := NewOrchestrator(store, zap.NewNop())
+	require.NoError(t, o.Register(Definition{
+		Name: "order",
+		Steps: []Step{
+			recordingStep("reserve", &calls, false),
+			recordingStep("charge", &calls, false),
+		},
+	}))
+
+	require.NoError(t, o.Resume(ctx))
+	assert.Equal(t, []string{"do:charge"}, calls)
+
+	inst, err := o.Get(ctx, "wf-1")
+	require.NoError(t, err)
+	assert.Equal(t, StatusCompleted, inst.Status)
+}
+
+func TestOrchestrator_Errors(t *testing.T) {
+	o := NewOrchestrator(NewMemoryStore(), nil)
+	assert.Error(t, o.Register(Definition{Name: "empty"}))
+	assert.Error(t, o.Register(Definition{Steps: []Step{{Name: "a"}}}))
+
+	_, err := o.Start(context.Background(), "missing", nil)
+	assert.Error(t, err)
+
+	_, err = o.Get(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestDBStore(t *testing.T) {
+	db, err := database.New(config.DatabaseConfig{
+		Driver:   "sqlite",
+		DBName:   ":memory:",
+		LogLevel: "silent",
+	}, zap.NewNop())
+	require.NoError(t, err)
+
+	store, err := NewDBStore(db.DB)
+	require.NoError(t, err)
+	testStore(t, store)
+}
+
+func TestKVStore(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	s, err := server.NewServer(&server.Options{
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+	})
+	require.NoError(t, err)
+	go s.Start()
+	defer s.Shutdown()
+	require.True(t, s.ReadyForConnections(5*time.Second))
+
+	nc, err := nats.Connect(s.ClientURL())
+	require.NoError(t, err)
+	defer nc.Close()
+
+	js, err := nc.JetStream()
+	require.NoError(t, err)
+
+	store, err := NewKVStore(js, "workflows_test")
+	require.NoError(t, err)
+	testStore(t, store)
+}
+
+func testStore(t *testing.T, store Store) {
+	ctx := context.Background()
+
+	list, err := store.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+
+	_, err = store.Load(ctx, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	inst := &Instance{
+		ID:        "wf-1",
+		Workflow:  "order",
+		Status:    StatusRunning,
+		CreatedAt: time.Now().UTC(),
+	}
+	require.NoError(t, inst.Set("amount", 10))
+	require.NoError(t, store.Save(ctx, inst))
+
+	inst.Step = 1
+	require.NoError(t, store.Save(ctx, inst))
+
+	loaded, err := store.Load(ctx, "wf-1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, loaded.Step)
+	var amount int
+	_, err = loaded.Get("amount", &amount)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, amount)
+
+	list, err = store.List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, list, 1)
+}
+
+func TestAdminService(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	o := NewOrchestrator(NewMemoryStore(), zap.NewNop())
+	require.NoError(t, o.Register(Definition{
+		Name: "noop",
+		Steps: []Step{{
+			Name:   "noop",
+			Action: func(ctx context.Context, inst *Instance) error { return nil },
+		}},
+	}))
+	inst, err := o.Start(context.Background(), "noop", nil)
+	require.NoError(t, err)
+
+	svc := NewAdminService(o)
+	assert.Equal(t, "workflow", svc.Name())
+
+	r := gin.New()
+	svc.RegisterRoutes(r.Group("/"))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/workflows/"+inst.ID, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var got Instance
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, StatusCompleted, got.Status)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/workflows?status=failed", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, "[]", w.Body.String())
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/workflows/unknown", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
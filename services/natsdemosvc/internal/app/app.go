@@ -8,8 +8,6 @@ import (
 	"grouter/services/natsdemosvc/internal/pkg/natdemo"
 
 	"github.com/google/uuid"
-
-	"github.com/go-viper/mapstructure/v2"
 	"go.uber.org/zap"
 )
 
@@ -39,6 +37,9 @@ func (a *App) Init() error {
 	if err := a.manager.InitWebServer(); err != nil {
 		return err
 	}
+	if err := a.manager.InitGRPCServer(); err != nil {
+		return err
+	}
 	// Generate unique AppId
 	a.AppId = a.manager.Config().App.Name + "-" + strings.Split(uuid.New().String(), "-")[0]
 	a.manager.Logger().Info("App initialized", zap.String("AppId", a.AppId))
@@ -84,21 +85,6 @@ func (a *App) RegisterStop() error {
 	return nil
 }
 
-func (a *App) RegisterHealth() error {
-	logger := a.manager.Logger()
-	// Register Health Service to listen for health signal
-	healthSvc := NewHealthService(a)
-	if err := a.manager.RegisterService(healthSvc); err != nil {
-		return err
-	}
-	subject := a.GetAppName() + ".health.>"
-	logger.Info("Registering Health Service to listen for health signal on topic " + subject)
-	if err := a.manager.SubscribeToTopics(subject, ""); err != nil {
-		return err
-	}
-	return nil
-}
-
 func (a *App) InitAppStartupServices() error {
 	logger := a.manager.Logger()
 
@@ -110,10 +96,6 @@ func (a *App) InitAppStartupServices() error {
 		logger.Error("Failed to register stop service", zap.Error(err))
 		return err
 	}
-	if err := a.RegisterHealth(); err != nil {
-		logger.Error("Failed to register health service", zap.Error(err))
-		return err
-	}
 	return nil
 }
 
@@ -170,7 +152,7 @@ func (a *App) UnregisterServices() error {
 	services := a.manager.ListServices()
 
 	for _, service := range services {
-		if service == "start" || service == "stop" || service == "health" {
+		if service == "start" || service == "stop" {
 			continue
 		}
 		logger.Info("Unregistering service: " + service)
@@ -199,11 +181,11 @@ func (a *App) RegisterServices() error {
 	logger.Info("Registering service: " + a.GetAppName() + " to topic: " + topic)
 
 	// build services list from cfg.Services
-	for name, serviceCfg := range cfg.Services {
+	for name := range cfg.Services {
 
 		if name == "natdemo" {
 			var natConfig natdemo.NATDemoConfig
-			if err := decodeConfig(serviceCfg, &natConfig); err != nil {
+			if err := a.manager.RegisterServiceConfig(name, &natConfig); err != nil {
 				logger.Error("Failed to decode NATDemo config", zap.Error(err))
 				return err
 			}
@@ -224,15 +206,3 @@ func (a *App) RegisterServices() error {
 func (a *App) Logger() *zap.Logger {
 	return a.manager.Logger()
 }
-
-func decodeConfig(input interface{}, output interface{}) error {
-	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
-		Metadata: nil,
-		Result:   output,
-		TagName:  "mapstructure",
-	})
-	if err != nil {
-		return err
-	}
-	return decoder.Decode(input)
-}
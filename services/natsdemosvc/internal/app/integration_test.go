@@ -2,37 +2,17 @@ package app
 
 import (
 	"context"
-	"encoding/json"
 	"os"
 	"testing"
 	"time"
 
-	messaging "grouter/pkg/messaging/nats"
+	grtest "grouter/pkg/testing"
 
-	"github.com/nats-io/nats-server/v2/server"
 	"github.com/nats-io/nats.go"
 	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/require"
 )
 
-// runServer starts an embedded NATS server for testing
-func runServer(t *testing.T) *server.Server {
-	opts := &server.Options{
-		Port: -1, // Random port
-	}
-	s, err := server.NewServer(opts)
-	require.NoError(t, err)
-
-	go s.Start()
-
-	// Wait for server to be ready
-	if !s.ReadyForConnections(5 * time.Second) {
-		t.Fatal("NATS server failed to start")
-	}
-
-	return s
-}
-
 func TestApp_Integration(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping integration test in short mode")
@@ -40,19 +20,13 @@ func TestApp_Integration(t *testing.T) {
 
 	// 1. Start Embedded NATS
 	pflag.CommandLine = pflag.NewFlagSet(os.Args[0], pflag.ExitOnError)
-	s := runServer(t)
-	defer s.Shutdown()
+	s := grtest.RunNATSServer(t)
 	natsURL := s.ClientURL()
 	t.Logf("Started NATS server at %s", natsURL)
 
 	// 2. Configure App to use this NATS
 	// We set the env var which config.Load() picks up
-	os.Setenv("GROUTER_NATS_URL", natsURL)
-	defer os.Unsetenv("GROUTER_NATS_URL")
-
-	// Set config path to the generated config file
-	os.Setenv("GROUTER_CONFIG", "configs/config.yaml")
-	defer os.Unsetenv("GROUTER_CONFIG")
+	t.Setenv("GROUTER_NATS_URL", natsURL)
 
 	// 3. Connect Test Client
 	nc, err := nats.Connect(natsURL)
@@ -60,10 +34,6 @@ func TestApp_Integration(t *testing.T) {
 	defer nc.Close()
 
 	// Create temporary config file
-	err = os.MkdirAll("configs", 0755)
-	require.NoError(t, err)
-	defer os.RemoveAll("configs")
-
 	configContent := `
 app:
   name: gRouterTest
@@ -87,8 +57,7 @@ tracing:
   service_name: "test-svc"
   exporter: "stdout"
 `
-	err = os.WriteFile("configs/config.yaml", []byte(configContent), 0644)
-	require.NoError(t, err)
+	grtest.UseConfig(t, configContent)
 
 	// 4. Start App
 	app := New()
@@ -115,39 +84,24 @@ tracing:
 	stopTopic := appName + ".stop"
 
 	t.Logf("Sending start signal to %s", startTopic)
-	err = publishMessage(nc, startTopic, "start")
-	require.NoError(t, err)
+	grtest.Publish(t, nc, startTopic, "start", nil, grtest.WithSource("integration-test"))
 
 	// Wait for services to register
 	time.Sleep(1 * time.Second)
 
 	// 6. Test Health (Proof of Life)
-	// HealthService listens on: appName + ".health"
-	healthSubject := appName + ".health"
+	// The manager answers health requests on: appName + ".health.{live,ready,detail}"
+	healthSubject := appName + ".health.live"
 	replySubject := "test.reply.health"
-	sub, err := nc.SubscribeSync(replySubject)
-	require.NoError(t, err)
+	sub := grtest.Subscribe(t, nc, replySubject)
+	grtest.Publish(t, nc, healthSubject, "health.live", nil, grtest.WithReply(replySubject))
 
-	healthMsg := &messaging.MessageEnvelope{
-		ID:    "test-health",
-		Type:  "liveness",
-		Reply: replySubject,
-	}
-	healthData, _ := json.Marshal(healthMsg)
-	err = nc.Publish(healthSubject, healthData)
-	require.NoError(t, err)
-
-	msg, err := sub.NextMsg(2 * time.Second)
-	if err == nil {
-		t.Logf("Received health response: %s", string(msg.Data))
-	} else {
-		t.Logf("Health check timed out: %v", err)
-	}
+	reply := sub.Next(2 * time.Second)
+	t.Logf("Received health response: %s", string(reply.Data))
 
 	// 7. Send Stop Signal
 	t.Logf("Sending stop signal to %s", stopTopic)
-	err = publishMessage(nc, stopTopic, "stop")
-	require.NoError(t, err)
+	grtest.Publish(t, nc, stopTopic, "stop", nil, grtest.WithSource("integration-test"))
 
 	// Wait for shutdown log
 	time.Sleep(1 * time.Second)
@@ -162,14 +116,3 @@ tracing:
 	default:
 	}
 }
-
-func publishMessage(nc *nats.Conn, subject, msgType string) error {
-	env := messaging.MessageEnvelope{
-		ID:        "test-" + msgType,
-		Type:      msgType,
-		Source:    "integration-test",
-		Timestamp: time.Now(),
-	}
-	data, _ := json.Marshal(env)
-	return nc.Publish(subject, data)
-}
@@ -3,45 +3,18 @@ package natdemo
 import (
 	"context"
 	"testing"
-	"time"
 
+	"grouter/pkg/manager"
 	messaging "grouter/pkg/messaging/nats"
+	"grouter/pkg/messaging/nats/mocks"
 
-	"github.com/nats-io/nats.go"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 )
 
-type mockPublisher struct {
-}
-
-func (m *mockPublisher) Publish(ctx context.Context, subject string, msgType string, data interface{}, opts *messaging.PublishOptions) error {
-	return nil
-}
-
-func (m *mockPublisher) PublishError(ctx context.Context, subject string, errMsg string) error {
-	return nil
-}
-
-func (m *mockPublisher) Request(ctx context.Context, subject string, msgType string, data interface{}, timeout time.Duration) (*messaging.MessageEnvelope, error) {
-	return nil, nil
-}
-
-func (m *mockPublisher) PublishJS(ctx context.Context, subject string, msgType string, data interface{}, opts ...nats.PubOpt) (*nats.PubAck, error) {
-	return nil, nil
-}
-
-func (m *mockPublisher) PublishAsyncJS(ctx context.Context, subject string, msgType string, data interface{}, opts ...nats.PubOpt) (nats.PubAckFuture, error) {
-	return nil, nil
-}
-
-func (m *mockPublisher) Use(mw ...messaging.PublisherMiddleware)      {}
-func (m *mockPublisher) UseRequest(mw ...messaging.RequestMiddleware) {}
-func (m *mockPublisher) SetValidator(v messaging.Validator)           {}
-
 func TestNATDemo_New(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
-	pub := &mockPublisher{}
+	pub := mocks.NewPublisher()
 	cfg := NATDemoConfig{Enabled: true}
 
 	demo := NewNATDemo(pub, logger, cfg)
@@ -51,19 +24,20 @@ func TestNATDemo_New(t *testing.T) {
 
 func TestNATDemo_Lifecycle(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
-	pub := &mockPublisher{}
+	pub := mocks.NewPublisher()
 	cfg := NATDemoConfig{Enabled: true}
 	demo := NewNATDemo(pub, logger, cfg)
 	ctx := context.Background()
 
-	assert.NoError(t, demo.Ready(ctx))
+	assert.NoError(t, demo.Init(ctx, manager.Deps{Logger: logger}))
+	assert.NoError(t, demo.Health(ctx))
 	assert.NoError(t, demo.Start(ctx))
 	assert.NoError(t, demo.Stop(ctx))
 }
 
 func TestNATDemo_Handle(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
-	pub := &mockPublisher{}
+	pub := mocks.NewPublisher()
 	cfg := NATDemoConfig{Enabled: true}
 	demo := NewNATDemo(pub, logger, cfg)
 	ctx := context.Background()
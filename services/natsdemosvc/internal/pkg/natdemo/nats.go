@@ -3,11 +3,14 @@ package natdemo
 import (
 	"context"
 
+	"grouter/pkg/manager"
 	messaging "grouter/pkg/messaging/nats"
 
 	"go.uber.org/zap"
 )
 
+var _ manager.ServiceV2 = (*NATDemo)(nil)
+
 type NATDemo struct {
 	publisher messaging.Publisher
 	natsSvc   *NATSService
@@ -23,7 +26,12 @@ func (e *NATDemo) Name() string {
 	return e.natsSvc.Name()
 }
 
-func (e *NATDemo) Ready(ctx context.Context) error {
+func (e *NATDemo) Init(ctx context.Context, deps manager.Deps) error {
+	e.logger.Info("NATDemo initialized", zap.String("subject", e.config.Subject))
+	return nil
+}
+
+func (e *NATDemo) Health(ctx context.Context) error {
 	return nil
 }
 
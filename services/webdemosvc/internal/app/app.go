@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/go-viper/mapstructure/v2"
 	"github.com/google/uuid"
 
 	"grouter/pkg/manager"
@@ -33,12 +32,18 @@ func (a *App) Init() error {
 	if err := a.manager.Init(); err != nil {
 		return fmt.Errorf("failed to init manager: %w", err)
 	}
+	if err := a.manager.InitCache(); err != nil {
+		return fmt.Errorf("failed to init cache: %w", err)
+	}
 	if err := a.manager.InitNATS(); err != nil {
 		return fmt.Errorf("failed to init nats: %w", err)
 	}
 	if err := a.manager.InitWebServer(); err != nil {
 		return fmt.Errorf("failed to init web server: %w", err)
 	}
+	if err := a.manager.InitGRPCServer(); err != nil {
+		return fmt.Errorf("failed to init grpc server: %w", err)
+	}
 	// Generate unique AppId
 	a.AppId = a.manager.Config().App.Name + "-" + uuid.New().String()
 	a.manager.Logger().Info("App initialized", zap.String("AppId", a.AppId))
@@ -115,17 +120,10 @@ func (a *App) Start(ctx context.Context) error {
 				logger.Error("Failed to register services", zap.Error(err))
 			}
 
-			// Reload web server to apply new services
-			logger.Info("Reloading web server to apply new services...")
-			// Note: This might cause a race with the /start response if not handled carefully,
-			// but for now it's necessary to register routes on the running engine.
-			// ResetEngine stops the server first.
-			if err := a.manager.WebServer().ResetEngine(context.Background()); err != nil {
-				logger.Error("Failed to reset engine", zap.Error(err))
-			}
-			a.manager.ReRegisterServices()
-			if err := a.manager.WebServer().Start(); err != nil {
-				logger.Error("Failed to start web server", zap.Error(err))
+			// Swap in the new routes; the listener keeps serving
+			logger.Info("Reloading web routes to apply new services...")
+			if err := a.manager.ReloadWebServer(ctx); err != nil {
+				logger.Error("Failed to reload web server", zap.Error(err))
 			}
 		case <-ctx.Done():
 			return ctx.Err()
@@ -139,17 +137,10 @@ func (a *App) Start(ctx context.Context) error {
 			if err := a.UnregisterServices(); err != nil {
 				logger.Error("Failed to unregister services", zap.Error(err))
 			}
-			// Reload web server to apply new services
-			logger.Info("Reloading web server to apply new services...")
-			// Note: This might cause a race with the /start response if not handled carefully,
-			// but for now it's necessary to register routes on the running engine.
-			// ResetEngine stops the server first.
-			if err := a.manager.WebServer().ResetEngine(context.Background()); err != nil {
-				logger.Error("Failed to reset engine", zap.Error(err))
-			}
-			a.manager.ReRegisterServices()
-			if err := a.manager.WebServer().Start(); err != nil {
-				logger.Error("Failed to start web server", zap.Error(err))
+			// Swap in the new routes; the listener keeps serving
+			logger.Info("Reloading web routes to apply new services...")
+			if err := a.manager.ReloadWebServer(ctx); err != nil {
+				logger.Error("Failed to reload web server", zap.Error(err))
 			}
 		case <-ctx.Done():
 			return ctx.Err()
@@ -190,10 +181,10 @@ func (a *App) RegisterServices() error {
 	// build services list from cfg.Services
 	// build services list from cfg.Services
 
-	for name, serviceCfg := range cfg.Services {
+	for name := range cfg.Services {
 		if name == "webdemosvc" {
 			var webConfig webdemo.WebDemoConfig
-			if err := decodeConfig(serviceCfg, &webConfig); err != nil {
+			if err := a.manager.RegisterServiceConfig(name, &webConfig); err != nil {
 				logger.Error("Failed to decode WebDemo config", zap.Error(err))
 				return err
 			}
@@ -215,15 +206,3 @@ func (a *App) RegisterServices() error {
 func (a *App) Logger() *zap.Logger {
 	return a.manager.Logger()
 }
-
-func decodeConfig(input interface{}, output interface{}) error {
-	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
-		Metadata: nil,
-		Result:   output,
-		TagName:  "mapstructure",
-	})
-	if err != nil {
-		return err
-	}
-	return decoder.Decode(input)
-}